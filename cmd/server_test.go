@@ -19,6 +19,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/mitchellh/go-homedir"
 	"github.com/runatlantis/atlantis/cmd"
@@ -127,6 +128,437 @@ func TestExecute_ValidateLogLevel(t *testing.T) {
 	Equals(t, "invalid log level: not one of debug, info, warn, error", err.Error())
 }
 
+func TestExecute_ValidateDBType(t *testing.T) {
+	cases := []struct {
+		description string
+		flags       map[string]interface{}
+		expErr      string
+	}{
+		{
+			"invalid db type",
+			map[string]interface{}{
+				cmd.DBTypeFlag: "mysql",
+			},
+			"invalid --db-type: not one of boltdb, redis, postgres",
+		},
+		{
+			"redis without host",
+			map[string]interface{}{
+				cmd.DBTypeFlag: "redis",
+			},
+			"--redis-host must be set when --db-type=redis",
+		},
+		{
+			"redis with host",
+			map[string]interface{}{
+				cmd.DBTypeFlag:        "redis",
+				cmd.RedisHostFlag:     "localhost",
+				cmd.RedisPasswordFlag: "password",
+			},
+			"",
+		},
+		{
+			"postgres without connection string",
+			map[string]interface{}{
+				cmd.DBTypeFlag: "postgres",
+			},
+			"--db-connection-string must be set when --db-type=postgres",
+		},
+		{
+			"postgres with connection string",
+			map[string]interface{}{
+				cmd.DBTypeFlag:             "postgres",
+				cmd.DBConnectionStringFlag: "postgres://localhost/atlantis",
+			},
+			"",
+		},
+		{
+			"deprecated locking db",
+			map[string]interface{}{
+				cmd.LockingDBFlag: "redis",
+			},
+			"--redis-host must be set when --db-type=redis",
+		},
+		{
+			"db type overrides locking db",
+			map[string]interface{}{
+				cmd.DBTypeFlag:    "boltdb",
+				cmd.LockingDBFlag: "redis",
+			},
+			"",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			err := setupWithDefaults(c.flags).Execute()
+			if c.expErr == "" {
+				Ok(t, err)
+			} else {
+				ErrEquals(t, c.expErr, err)
+			}
+		})
+	}
+}
+
+func TestExecute_ValidateDriftDetectionCron(t *testing.T) {
+	err := setupWithDefaults(map[string]interface{}{
+		cmd.DriftDetectionCronFlag: "0 25 * * *",
+	}).Execute()
+	ErrEquals(t, "invalid --drift-detection-cron: value 25 in hour field is out of range, must be between 0 and 23", err)
+}
+
+func TestExecute_ValidateAutoplanFileList(t *testing.T) {
+	err := setupWithDefaults(map[string]interface{}{
+		cmd.AutoplanFileListFlag: "**/*.tf,!",
+	}).Execute()
+	ErrEquals(t, `invalid --autoplan-file-list: illegal exclusion pattern: "!"`, err)
+}
+
+func TestExecute_ValidateAutomergeMethod(t *testing.T) {
+	err := setupWithDefaults(map[string]interface{}{
+		cmd.AutomergeMethodFlag: "fast-forward",
+	}).Execute()
+	ErrEquals(t, "invalid --automerge-method: not one of merge, squash, rebase", err)
+}
+
+func TestExecute_ValidateExecutableName(t *testing.T) {
+	err := setupWithDefaults(map[string]interface{}{
+		cmd.ExecutableNameFlag: "atlantis prod",
+	}).Execute()
+	ErrEquals(t, "--executable-name cannot contain whitespace", err)
+}
+
+func TestExecute_ValidateProjectDirs(t *testing.T) {
+	err := setupWithDefaults(map[string]interface{}{
+		cmd.ProjectDirsFlag: "prod/**,!",
+	}).Execute()
+	ErrEquals(t, `invalid --project-dirs: illegal exclusion pattern: "!"`, err)
+}
+
+func TestExecute_ValidateAutodiscover(t *testing.T) {
+	err := setupWithDefaults(map[string]interface{}{
+		cmd.AutodiscoverIncludeFlag: "live/**,!",
+	}).Execute()
+	ErrEquals(t, `invalid --autodiscover-include: illegal exclusion pattern: "!"`, err)
+
+	err = setupWithDefaults(map[string]interface{}{
+		cmd.AutodiscoverExcludeFlag: "!",
+	}).Execute()
+	ErrEquals(t, `invalid --autodiscover-exclude: illegal exclusion pattern: "!"`, err)
+}
+
+func TestExecute_ValidateAuditLog(t *testing.T) {
+	err := setupWithDefaults(map[string]interface{}{
+		cmd.AuditLogFileFlag: "/tmp/audit.log",
+	}).Execute()
+	ErrEquals(t, "--audit-log-file and --audit-syslog require --enable-audit-log", err)
+
+	err = setupWithDefaults(map[string]interface{}{
+		cmd.EnableAuditLogFlag: true,
+		cmd.AuditSyslogFlag:    "syslog:514",
+	}).Execute()
+	ErrEquals(t, `invalid --audit-syslog: "syslog:514" must be "local", udp://host:port or tcp://host:port`, err)
+}
+
+func TestExecute_ValidateArtifactBackend(t *testing.T) {
+	err := setupWithDefaults(map[string]interface{}{
+		cmd.ArtifactBackendFlag: "azure",
+		cmd.ArtifactBucketFlag:  "plans",
+	}).Execute()
+	ErrEquals(t, `invalid --artifact-backend "azure": must be s3 or gcs`, err)
+
+	err = setupWithDefaults(map[string]interface{}{
+		cmd.ArtifactBackendFlag: "gcs",
+	}).Execute()
+	ErrEquals(t, "--artifact-backend requires --artifact-bucket", err)
+
+	err = setupWithDefaults(map[string]interface{}{
+		cmd.ArtifactBucketFlag: "plans",
+	}).Execute()
+	ErrEquals(t, "--artifact-bucket requires --artifact-backend", err)
+}
+
+func TestExecute_ValidateBitbucketTokenType(t *testing.T) {
+	err := setupWithDefaults(map[string]interface{}{
+		cmd.BitbucketTokenTypeFlag: "oauth",
+	}).Execute()
+	ErrEquals(t, `invalid --bitbucket-token-type "oauth": must be app-password or access-token`, err)
+
+	err = setupWithDefaults(map[string]interface{}{
+		cmd.BitbucketTokenTypeFlag: "access-token",
+		cmd.BitbucketBaseURLFlag:   "https://bitbucket.corp",
+	}).Execute()
+	ErrEquals(t, "--bitbucket-token-type=access-token is only supported for Bitbucket Cloud", err)
+
+	err = setupWithDefaults(map[string]interface{}{
+		cmd.BitbucketTokenTypeFlag: "access-token",
+	}).Execute()
+	Ok(t, err)
+	Equals(t, "access-token", passedConfig.BitbucketTokenType)
+}
+
+func TestExecute_ValidateCheckoutDepth(t *testing.T) {
+	err := setupWithDefaults(map[string]interface{}{
+		cmd.CheckoutDepthFlag: -1,
+	}).Execute()
+	ErrEquals(t, "--checkout-depth cannot be negative", err)
+}
+
+func TestExecute_ValidateDataDirMaxGB(t *testing.T) {
+	err := setupWithDefaults(map[string]interface{}{
+		cmd.DataDirMaxGBFlag: -1,
+	}).Execute()
+	ErrEquals(t, "--data-dir-max-gb cannot be negative", err)
+}
+
+func TestExecute_ValidateWorkingDirTTL(t *testing.T) {
+	for _, ttl := range []string{"3d", "-1h", "0s"} {
+		err := setupWithDefaults(map[string]interface{}{
+			cmd.WorkingDirTTLFlag: ttl,
+		}).Execute()
+		ErrEquals(t, `invalid --working-dir-ttl "`+ttl+`": must be a positive duration, ex. 72h`, err)
+	}
+}
+
+func TestExecute_ValidateRole(t *testing.T) {
+	err := setupWithDefaults(map[string]interface{}{
+		cmd.RoleFlag: "frontend",
+	}).Execute()
+	ErrEquals(t, "invalid --role: not one of all, webhooks, worker", err)
+
+	err = setupWithDefaults(map[string]interface{}{
+		cmd.RoleFlag: "worker",
+	}).Execute()
+	ErrEquals(t, "--role=worker requires a database that can be shared between processes, --db-type=redis or --db-type=postgres", err)
+
+	err = setupWithDefaults(map[string]interface{}{
+		cmd.RoleFlag:        "webhooks",
+		cmd.DBTypeFlag:      "redis",
+		cmd.RedisHostFlag:   "redis.corp.com",
+		cmd.WorkerCountFlag: -1,
+	}).Execute()
+	ErrEquals(t, "--worker-count cannot be negative", err)
+
+	err = setupWithDefaults(map[string]interface{}{
+		cmd.RoleFlag:               "worker",
+		cmd.DBTypeFlag:             "redis",
+		cmd.RedisHostFlag:          "redis.corp.com",
+		cmd.WorkerClaimTimeoutFlag: "0s",
+	}).Execute()
+	ErrEquals(t, `invalid --worker-claim-timeout "0s": must be a positive duration, ex. 5m`, err)
+
+	err = setupWithDefaults(map[string]interface{}{
+		cmd.RoleFlag:      "webhooks",
+		cmd.DBTypeFlag:    "redis",
+		cmd.RedisHostFlag: "redis.corp.com",
+	}).Execute()
+	Ok(t, err)
+	Equals(t, "webhooks", passedConfig.Role)
+}
+
+func TestExecute_ValidateExecutionMode(t *testing.T) {
+	err := setupWithDefaults(map[string]interface{}{
+		cmd.ExecutionModeFlag: "vm",
+	}).Execute()
+	ErrEquals(t, "invalid --execution-mode: not one of host, docker, kubernetes", err)
+
+	err = setupWithDefaults(map[string]interface{}{
+		cmd.ExecutionModeFlag: "docker",
+	}).Execute()
+	ErrEquals(t, "--docker-image must be set when --execution-mode=docker", err)
+
+	err = setupWithDefaults(map[string]interface{}{
+		cmd.ExecutionModeFlag: "kubernetes",
+	}).Execute()
+	ErrEquals(t, "--kubernetes-image must be set when --execution-mode=kubernetes", err)
+
+	err = setupWithDefaults(map[string]interface{}{
+		cmd.ExecutionModeFlag:   "kubernetes",
+		cmd.KubernetesImageFlag: "hashicorp/terraform",
+	}).Execute()
+	ErrEquals(t, "--kubernetes-pvc must be set when --execution-mode=kubernetes", err)
+
+	err = setupWithDefaults(map[string]interface{}{
+		cmd.KubernetesSAFlag: "owner/repo=sa,default-sa",
+	}).Execute()
+	ErrEquals(t, `invalid --kubernetes-service-accounts: "default-sa" isn't in the format repo=service-account`, err)
+}
+
+func TestExecute_ValidateMaxCloneAttempts(t *testing.T) {
+	err := setupWithDefaults(map[string]interface{}{
+		cmd.MaxCloneAttemptsFlag: -1,
+	}).Execute()
+	ErrEquals(t, "--max-clone-attempts cannot be negative", err)
+
+	err = setupWithDefaults(map[string]interface{}{
+		cmd.MaxCloneAttemptsFlag: 1,
+	}).Execute()
+	Ok(t, err)
+	Equals(t, 1, passedConfig.MaxCloneAttempts)
+}
+
+func TestExecute_ValidateDrainTimeout(t *testing.T) {
+	err := setupWithDefaults(map[string]interface{}{
+		cmd.DrainTimeoutFlag: "10",
+	}).Execute()
+	ErrEquals(t, `invalid --drain-timeout "10": must be a duration, ex. 5m`, err)
+
+	err = setupWithDefaults(map[string]interface{}{
+		cmd.DrainTimeoutFlag: "30s",
+	}).Execute()
+	Ok(t, err)
+	Equals(t, 30*time.Second, passedConfig.ToDrainTimeout())
+}
+
+func TestExecute_ValidateParallelPoolSize(t *testing.T) {
+	t.Log("Should error if the parallel pool size is negative.")
+	err := setupWithDefaults(map[string]interface{}{
+		cmd.ParallelPoolSizeFlag: -1,
+	}).Execute()
+	ErrEquals(t, "--parallel-pool-size cannot be negative", err)
+
+	t.Log("Should use the parallel pool size if it's positive.")
+	err = setupWithDefaults(map[string]interface{}{
+		cmd.ParallelPoolSizeFlag: 4,
+	}).Execute()
+	Ok(t, err)
+	Equals(t, 4, passedConfig.ParallelPoolSize)
+}
+
+func TestExecute_ValidateWebhookLimits(t *testing.T) {
+	err := setupWithDefaults(map[string]interface{}{
+		cmd.MaxQueuedCommandsFlag: -1,
+	}).Execute()
+	ErrEquals(t, "--max-queued-commands cannot be negative", err)
+
+	err = setupWithDefaults(map[string]interface{}{
+		cmd.WebhookRateLimitFlag: -1,
+	}).Execute()
+	ErrEquals(t, "--webhook-rate-limit cannot be negative", err)
+}
+
+func TestExecute_ValidateVCSProxyURL(t *testing.T) {
+	err := setupWithDefaults(map[string]interface{}{
+		cmd.VCSProxyURLFlag: "proxy:3128",
+	}).Execute()
+	ErrEquals(t, "--vcs-proxy-url must be an absolute URL, ex. http://proxy:3128", err)
+}
+
+func TestExecute_ValidateVCSTLSCert(t *testing.T) {
+	err := setupWithDefaults(map[string]interface{}{
+		cmd.VCSTLSCertFileFlag: "cert.pem",
+	}).Execute()
+	ErrEquals(t, "--vcs-tls-cert-file and --vcs-tls-key-file must be set together", err)
+
+	err = setupWithDefaults(map[string]interface{}{
+		cmd.VCSTLSKeyFileFlag: "key.pem",
+	}).Execute()
+	ErrEquals(t, "--vcs-tls-cert-file and --vcs-tls-key-file must be set together", err)
+}
+
+func TestExecute_ValidateWebhookAllowIPs(t *testing.T) {
+	err := setupWithDefaults(map[string]interface{}{
+		cmd.WebhookAllowIPsFlag: "10.0.0.0/8,not-an-ip",
+	}).Execute()
+	ErrEquals(t, `invalid --webhook-allow-ips: "not-an-ip" is not an IP address or CIDR`, err)
+
+	err = setupWithDefaults(map[string]interface{}{
+		cmd.WebhookTrustedProxiesFlag: "10.0.0.1",
+	}).Execute()
+	ErrEquals(t, "--webhook-trusted-proxies requires --webhook-allow-ips", err)
+}
+
+func TestExecute_ValidateTFDownloadVersions(t *testing.T) {
+	t.Log("Should validate terraform versions to download.")
+	c := setupWithDefaults(map[string]interface{}{
+		cmd.TFDownloadVersionsFlag: "0.11.10, not-a-version",
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, "invalid --tf-download-versions: \"not-a-version\" is not a valid version", err.Error())
+}
+
+func TestExecute_ValidateTFDownloadURL(t *testing.T) {
+	t.Log("Should validate the terraform download URL.")
+	c := setupWithDefaults(map[string]interface{}{
+		cmd.TFDownloadURLFlag: "mirror.example.com",
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, "--tf-download-url must have http:// or https://, got \"mirror.example.com\"", err.Error())
+}
+
+func TestExecute_ValidateWebOIDC(t *testing.T) {
+	cases := []struct {
+		description string
+		flags       map[string]interface{}
+		expErr      string
+	}{
+		{
+			"all set",
+			map[string]interface{}{
+				cmd.WebOIDCIssuerFlag:       "https://accounts.google.com",
+				cmd.WebOIDCClientIDFlag:     "atlantis",
+				cmd.WebOIDCClientSecretFlag: "secret",
+				cmd.WebOIDCAdminGroupsFlag:  "admins",
+			},
+			"",
+		},
+		{
+			"missing client secret",
+			map[string]interface{}{
+				cmd.WebOIDCIssuerFlag:   "https://accounts.google.com",
+				cmd.WebOIDCClientIDFlag: "atlantis",
+			},
+			"--web-oidc-issuer, --web-oidc-client-id and --web-oidc-client-secret must all be set to log in to the web UI",
+		},
+		{
+			"issuer without scheme",
+			map[string]interface{}{
+				cmd.WebOIDCIssuerFlag:       "accounts.google.com",
+				cmd.WebOIDCClientIDFlag:     "atlantis",
+				cmd.WebOIDCClientSecretFlag: "secret",
+			},
+			"--web-oidc-issuer must have http:// or https://, got \"accounts.google.com\"",
+		},
+		{
+			"admin groups without issuer",
+			map[string]interface{}{
+				cmd.WebOIDCAdminGroupsFlag: "admins",
+			},
+			"--web-oidc-admin-groups requires --web-oidc-issuer",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			err := setupWithDefaults(c.flags).Execute()
+			if c.expErr == "" {
+				Ok(t, err)
+			} else {
+				ErrEquals(t, c.expErr, err)
+			}
+		})
+	}
+}
+
+func TestExecute_ValidateWebBasicAuth(t *testing.T) {
+	t.Log("Should require a password for basic auth.")
+	c := setupWithDefaults(map[string]interface{}{
+		cmd.WebBasicAuthFlag: true,
+	})
+	ErrEquals(t, "--web-password must be set when using --web-basic-auth", c.Execute())
+
+	t.Log("Should not allow basic auth and OIDC login at the same time.")
+	c = setupWithDefaults(map[string]interface{}{
+		cmd.WebBasicAuthFlag:        true,
+		cmd.WebPasswordFlag:         "password",
+		cmd.WebOIDCIssuerFlag:       "https://accounts.google.com",
+		cmd.WebOIDCClientIDFlag:     "atlantis",
+		cmd.WebOIDCClientSecretFlag: "secret",
+	})
+	ErrEquals(t, "--web-basic-auth cannot be used with --web-oidc-issuer", c.Execute())
+}
+
 func TestExecute_ValidateSSLConfig(t *testing.T) {
 	expErr := "--ssl-key-file and --ssl-cert-file are both required for ssl"
 	cases := []struct {
@@ -176,7 +608,7 @@ func TestExecute_ValidateSSLConfig(t *testing.T) {
 }
 
 func TestExecute_ValidateVCSConfig(t *testing.T) {
-	expErr := "--gh-user/--gh-token or --gitlab-user/--gitlab-token or --bitbucket-user/--bitbucket-token must be set"
+	expErr := "--gh-user/--gh-token or --gh-app-id/--gh-app-key-file or --gitlab-user/--gitlab-token or --bitbucket-user/--bitbucket-token or --azuredevops-user/--azuredevops-token or --gitea-user/--gitea-token must be set"
 	cases := []struct {
 		description string
 		flags       map[string]interface{}
@@ -208,6 +640,20 @@ func TestExecute_ValidateVCSConfig(t *testing.T) {
 			},
 			true,
 		},
+		{
+			"just azure devops token set",
+			map[string]interface{}{
+				cmd.ADTokenFlag: "token",
+			},
+			true,
+		},
+		{
+			"just gitea token set",
+			map[string]interface{}{
+				cmd.GiteaTokenFlag: "token",
+			},
+			true,
+		},
 		{
 			"just github user set",
 			map[string]interface{}{
@@ -229,6 +675,21 @@ func TestExecute_ValidateVCSConfig(t *testing.T) {
 			},
 			true,
 		},
+		{
+			"just azure devops user set",
+			map[string]interface{}{
+				cmd.ADUserFlag: "user",
+			},
+			true,
+		},
+		{
+			"just gitea user set",
+			map[string]interface{}{
+				cmd.GiteaUserFlag:    "user",
+				cmd.GiteaBaseURLFlag: "https://gitea.example.com",
+			},
+			true,
+		},
 		{
 			"github user and gitlab token set",
 			map[string]interface{}{
@@ -261,6 +722,23 @@ func TestExecute_ValidateVCSConfig(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"github app id and key file set and should be successful",
+			map[string]interface{}{
+				cmd.GHAppIDFlag:      1,
+				cmd.GHAppKeyFileFlag: "key.pem",
+			},
+			false,
+		},
+		{
+			"github app and github user set and should be successful",
+			map[string]interface{}{
+				cmd.GHAppIDFlag:      1,
+				cmd.GHAppKeyFileFlag: "key.pem",
+				cmd.GHUserFlag:       "app[bot]",
+			},
+			false,
+		},
 		{
 			"gitlab user and gitlab token set and should be successful",
 			map[string]interface{}{
@@ -277,6 +755,23 @@ func TestExecute_ValidateVCSConfig(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"azure devops user and azure devops token set and should be successful",
+			map[string]interface{}{
+				cmd.ADUserFlag:  "user",
+				cmd.ADTokenFlag: "token",
+			},
+			false,
+		},
+		{
+			"gitea user and gitea token set and should be successful",
+			map[string]interface{}{
+				cmd.GiteaUserFlag:    "user",
+				cmd.GiteaTokenFlag:   "token",
+				cmd.GiteaBaseURLFlag: "https://gitea.example.com",
+			},
+			false,
+		},
 		{
 			"all set should be successful",
 			map[string]interface{}{
@@ -286,6 +781,11 @@ func TestExecute_ValidateVCSConfig(t *testing.T) {
 				cmd.GitlabTokenFlag:    "token",
 				cmd.BitbucketUserFlag:  "user",
 				cmd.BitbucketTokenFlag: "token",
+				cmd.ADUserFlag:         "user",
+				cmd.ADTokenFlag:        "token",
+				cmd.GiteaUserFlag:      "user",
+				cmd.GiteaTokenFlag:     "token",
+				cmd.GiteaBaseURLFlag:   "https://gitea.example.com",
 			},
 			false,
 		},
@@ -305,6 +805,102 @@ func TestExecute_ValidateVCSConfig(t *testing.T) {
 	}
 }
 
+func TestExecute_ValidateGiteaBaseURL(t *testing.T) {
+	cases := []struct {
+		baseURL string
+		expErr  string
+	}{
+		{
+			"",
+			"--gitea-base-url must be set when using Gitea",
+		},
+		{
+			"gitea.example.com",
+			"--gitea-base-url must have http:// or https://, got \"gitea.example.com\"",
+		},
+		{
+			"https://example.com/gitea",
+			"",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.baseURL, func(t *testing.T) {
+			flags := map[string]interface{}{
+				cmd.RepoWhitelistFlag: "*",
+				cmd.GiteaUserFlag:     "user",
+				cmd.GiteaTokenFlag:    "token",
+			}
+			if c.baseURL != "" {
+				flags[cmd.GiteaBaseURLFlag] = c.baseURL
+			}
+			err := setup(flags).Execute()
+			if c.expErr == "" {
+				Ok(t, err)
+			} else {
+				ErrEquals(t, c.expErr, err)
+			}
+		})
+	}
+}
+
+func TestExecute_ValidateGithubApp(t *testing.T) {
+	cases := []struct {
+		description string
+		flags       map[string]interface{}
+		expErr      string
+	}{
+		{
+			"just app id set",
+			map[string]interface{}{
+				cmd.GHAppIDFlag: 1,
+			},
+			"--gh-app-id and --gh-app-key-file are both required to authenticate as a GitHub App",
+		},
+		{
+			"just app key file set",
+			map[string]interface{}{
+				cmd.GHAppKeyFileFlag: "key.pem",
+			},
+			"--gh-app-id and --gh-app-key-file are both required to authenticate as a GitHub App",
+		},
+		{
+			"negative app id",
+			map[string]interface{}{
+				cmd.GHAppIDFlag:      -1,
+				cmd.GHAppKeyFileFlag: "key.pem",
+			},
+			"--gh-app-id cannot be negative",
+		},
+		{
+			"app and token set",
+			map[string]interface{}{
+				cmd.GHAppIDFlag:      1,
+				cmd.GHAppKeyFileFlag: "key.pem",
+				cmd.GHTokenFlag:      "token",
+			},
+			"--gh-token cannot be used with --gh-app-id",
+		},
+		{
+			"checks api set without app",
+			map[string]interface{}{
+				cmd.GHUserFlag:       "user",
+				cmd.GHTokenFlag:      "token",
+				cmd.UseChecksAPIFlag: true,
+			},
+			"--use-checks-api requires --gh-app-id since only GitHub Apps can create check runs",
+		},
+	}
+	for _, testCase := range cases {
+		t.Log("Should validate github app config when " + testCase.description)
+		testCase.flags[cmd.RepoWhitelistFlag] = "*"
+
+		c := setup(testCase.flags)
+		err := c.Execute()
+		Assert(t, err != nil, "should be an error")
+		Equals(t, testCase.expErr, err.Error())
+	}
+}
+
 func TestExecute_Defaults(t *testing.T) {
 	t.Log("Should set the defaults for all unspecified flags.")
 	c := setup(map[string]interface{}{
@@ -325,11 +921,29 @@ func TestExecute_Defaults(t *testing.T) {
 	Equals(t, "http://"+hostname+":4141", passedConfig.AtlantisURL)
 	Equals(t, false, passedConfig.AllowForkPRs)
 	Equals(t, false, passedConfig.AllowRepoConfig)
+	Equals(t, true, passedConfig.AllowTargetFlag)
+	Equals(t, false, passedConfig.DisableExtraArgs)
+	Equals(t, "**/*.tf,**/*.tf.json,**/*.tfvars,**/*.tfvars.json", passedConfig.AutoplanFileList)
+	Equals(t, false, passedConfig.Automerge)
+	Equals(t, false, passedConfig.AutomergeDeleteSourceBranch)
+	Equals(t, "merge", passedConfig.AutomergeMethod)
+	Equals(t, "", passedConfig.ArtifactBackend)
+	Equals(t, "", passedConfig.ArtifactBucket)
+	Equals(t, "", passedConfig.ArtifactPrefix)
+	Equals(t, "", passedConfig.ArtifactS3Endpoint)
+	Equals(t, "us-east-1", passedConfig.ArtifactS3Region)
+	Equals(t, "", passedConfig.AutodiscoverExclude)
+	Equals(t, "", passedConfig.AutodiscoverInclude)
 
 	// Get our home dir since that's what gets defaulted to
 	dataDir, err := homedir.Expand("~/.atlantis")
 	Ok(t, err)
 	Equals(t, dataDir, passedConfig.DataDir)
+	Equals(t, "", passedConfig.CloneRoot)
+	Equals(t, 0, passedConfig.CheckoutDepth)
+	Equals(t, false, passedConfig.SparseCheckout)
+	Equals(t, 0, passedConfig.DataDirMaxGB)
+	Equals(t, "", passedConfig.WorkingDirTTL)
 
 	Equals(t, "github.com", passedConfig.GithubHostname)
 	Equals(t, "token", passedConfig.GithubToken)
@@ -339,17 +953,63 @@ func TestExecute_Defaults(t *testing.T) {
 	Equals(t, "gitlab-token", passedConfig.GitlabToken)
 	Equals(t, "gitlab-user", passedConfig.GitlabUser)
 	Equals(t, "", passedConfig.GitlabWebhookSecret)
+	Equals(t, false, passedConfig.GitlabPipelineStatuses)
 	Equals(t, "https://api.bitbucket.org", passedConfig.BitbucketBaseURL)
 	Equals(t, "bitbucket-token", passedConfig.BitbucketToken)
+	Equals(t, "app-password", passedConfig.BitbucketTokenType)
 	Equals(t, "bitbucket-user", passedConfig.BitbucketUser)
 	Equals(t, "", passedConfig.BitbucketWebhookSecret)
+	Equals(t, "", passedConfig.AllowApplyFrom)
+	Equals(t, "", passedConfig.DriftDetectionCron)
+	Equals(t, false, passedConfig.DryRun)
+	Equals(t, "", passedConfig.AuditLogFile)
+	Equals(t, "", passedConfig.AuditSyslog)
+	Equals(t, false, passedConfig.EnableAuditLog)
+	Equals(t, false, passedConfig.EnableCloneCache)
+	Equals(t, false, passedConfig.EnableCostEstimation)
+	Equals(t, false, passedConfig.EnablePrometheus)
+	Equals(t, false, passedConfig.EnableTerragrunt)
+	Equals(t, "atlantis", passedConfig.ExecutableName)
+	Equals(t, "host", passedConfig.ExecutionMode)
+	Equals(t, "", passedConfig.DockerImage)
+	Equals(t, "", passedConfig.Kubeconfig)
+	Equals(t, "", passedConfig.KubernetesNamespace)
+	Equals(t, false, passedConfig.HidePrevPlanComments)
+	Equals(t, "boltdb", passedConfig.DBType)
 	Equals(t, "info", passedConfig.LogLevel)
+	Equals(t, 1, passedConfig.ParallelPoolSize)
+	Equals(t, 3, passedConfig.MaxCloneAttempts)
+	Equals(t, 0, passedConfig.MaxQueuedCommands)
+	Equals(t, "5m", passedConfig.DrainTimeout)
 	Equals(t, 4141, passedConfig.Port)
+	Equals(t, true, passedConfig.PlanDrafts)
+	Equals(t, "", passedConfig.ProjectDirs)
+	Equals(t, "", passedConfig.RedisHost)
+	Equals(t, "", passedConfig.RedisPassword)
+	Equals(t, false, passedConfig.RequireApplyConfirmation)
 	Equals(t, false, passedConfig.RequireApproval)
 	Equals(t, false, passedConfig.RequireMergeable)
+	Equals(t, "all", passedConfig.Role)
 	Equals(t, "", passedConfig.SSLCertFile)
 	Equals(t, "", passedConfig.SSLKeyFile)
 	Equals(t, "", passedConfig.TFEToken)
+	Equals(t, "https://releases.hashicorp.com", passedConfig.TFDownloadURL)
+	Equals(t, true, passedConfig.TFPluginCache)
+	Equals(t, false, passedConfig.UseChecksAPI)
+	Equals(t, "", passedConfig.SSLCAFile)
+	Equals(t, "", passedConfig.VCSCAFile)
+	Equals(t, "", passedConfig.VCSProxyURL)
+	Equals(t, "atlantis", passedConfig.VCSStatusName)
+	Equals(t, "", passedConfig.VCSTLSCertFile)
+	Equals(t, "", passedConfig.VCSTLSKeyFile)
+	Equals(t, false, passedConfig.WebBasicAuth)
+	Equals(t, "atlantis", passedConfig.WebUsername)
+	Equals(t, "", passedConfig.WebPassword)
+	Equals(t, "", passedConfig.WebhookAllowIPs)
+	Equals(t, 0, passedConfig.WebhookRateLimit)
+	Equals(t, "", passedConfig.WebhookTrustedProxies)
+	Equals(t, "5m", passedConfig.WorkerClaimTimeout)
+	Equals(t, 4, passedConfig.WorkerCount)
 }
 
 func TestExecute_ExpandHomeInDataDir(t *testing.T) {
@@ -383,6 +1043,20 @@ func TestExecute_RelativeDataDir(t *testing.T) {
 	Equals(t, expectedAbsolutePath, passedConfig.DataDir)
 }
 
+func TestExecute_RelativeCloneRoot(t *testing.T) {
+	t.Log("Should convert relative clone root to absolute.")
+	c := setupWithDefaults(map[string]interface{}{
+		cmd.CloneRootFlag: "../repos",
+	})
+
+	expectedAbsolutePath, err := filepath.Abs("../repos")
+	Ok(t, err)
+
+	err = c.Execute()
+	Ok(t, err)
+	Equals(t, expectedAbsolutePath, passedConfig.CloneRoot)
+}
+
 func TestExecute_GithubUser(t *testing.T) {
 	t.Log("Should remove the @ from the github username if it's passed.")
 	c := setup(map[string]interface{}{
@@ -426,41 +1100,139 @@ func TestExecute_Flags(t *testing.T) {
 	t.Log("Should use all flags that are set.")
 	c := setup(map[string]interface{}{
 		cmd.AtlantisURLFlag:            "url",
+		cmd.AuditLogFileFlag:           "/tmp/audit.log",
+		cmd.AuditSyslogFlag:            "local",
+		cmd.AllowApplyFromFlag:         "myorg/infra",
 		cmd.AllowForkPRsFlag:           true,
 		cmd.AllowRepoConfigFlag:        true,
+		cmd.AllowTargetFlagFlag:        false,
+		cmd.DisableExtraArgsFlag:       true,
+		cmd.APISecretFlag:              "api-secret",
+		cmd.ArtifactBackendFlag:        "s3",
+		cmd.ArtifactBucketFlag:         "plans",
+		cmd.ArtifactPrefixFlag:         "atlantis/",
+		cmd.ArtifactS3EndpointFlag:     "https://minio.example.com",
+		cmd.ArtifactS3RegionFlag:       "eu-west-1",
+		cmd.AutodiscoverExcludeFlag:    "sandbox/**",
+		cmd.AutodiscoverIncludeFlag:    "live/**",
+		cmd.AutomergeFlag:              true,
+		cmd.AutomergeDeleteBranchFlag:  true,
+		cmd.AutomergeMethodFlag:        "squash",
+		cmd.AutoplanFileListFlag:       "**/*.tf,**/*.pkr.hcl",
 		cmd.BitbucketBaseURLFlag:       "https://bitbucket-base-url.com",
 		cmd.BitbucketTokenFlag:         "bitbucket-token",
 		cmd.BitbucketUserFlag:          "bitbucket-user",
 		cmd.BitbucketWebhookSecretFlag: "bitbucket-secret",
+		cmd.CheckoutDepthFlag:          50,
 		cmd.DataDirFlag:                "/path",
+		cmd.DataDirMaxGBFlag:           20,
+		cmd.DriftDetectionCronFlag:     "0 6 * * *",
+		cmd.DryRunFlag:                 true,
+		cmd.EnableAuditLogFlag:         true,
+		cmd.EnableCloneCacheFlag:       true,
+		cmd.EnableCostEstimationFlag:   true,
+		cmd.EnablePrometheusFlag:       true,
+		cmd.EnableTerragruntFlag:       true,
+		cmd.ExecutableNameFlag:         "atlantis-prod",
+		cmd.ExecutionModeFlag:          "docker",
+		cmd.DockerImageFlag:            "hashicorp/terraform:0.12.29",
+		cmd.DockerMountsFlag:           "/a:/a:ro",
+		cmd.DockerCPUsFlag:             "1.5",
+		cmd.DockerMemoryFlag:           "2g",
+		cmd.KubeconfigFlag:             "/kubeconfig",
+		cmd.KubernetesImageFlag:        "hashicorp/terraform:light",
+		cmd.KubernetesNamespaceFlag:    "atlantis",
+		cmd.KubernetesPVCFlag:          "atlantis-data",
+		cmd.KubernetesSAFlag:           "owner/repo=repo-sa",
 		cmd.GHHostnameFlag:             "ghhostname",
 		cmd.GHTokenFlag:                "token",
 		cmd.GHUserFlag:                 "user",
 		cmd.GHWebhookSecretFlag:        "secret",
 		cmd.GitlabHostnameFlag:         "gitlab-hostname",
 		cmd.GitlabTokenFlag:            "gitlab-token",
+		cmd.GitlabPipelineStatusesFlag: true,
 		cmd.GitlabUserFlag:             "gitlab-user",
 		cmd.GitlabWebhookSecretFlag:    "gitlab-secret",
+		cmd.HidePrevPlanCommentsFlag:   true,
 		cmd.LogLevelFlag:               "debug",
+		cmd.MaxQueuedCommandsFlag:      100,
+		cmd.PlanDraftsFlag:             false,
 		cmd.PortFlag:                   8181,
+		cmd.ProjectDirsFlag:            "prod/**",
 		cmd.RepoWhitelistFlag:          "github.com/runatlantis/atlantis",
+		cmd.RequireApplyConfirmFlag:    true,
 		cmd.RequireApprovalFlag:        true,
 		cmd.RequireMergeableFlag:       true,
+		cmd.SparseCheckoutFlag:         true,
 		cmd.SSLCertFileFlag:            "cert-file",
 		cmd.SSLKeyFileFlag:             "key-file",
 		cmd.TFETokenFlag:               "my-token",
+		cmd.TFPluginCacheFlag:          false,
+		cmd.SSLCAFileFlag:              "outbound-ca.pem",
+		cmd.VCSCAFileFlag:              "ca.pem",
+		cmd.VCSProxyURLFlag:            "http://proxy:3128",
+		cmd.VCSStatusNameFlag:          "atlantis-prod",
+		cmd.VCSTLSCertFileFlag:         "cert.pem",
+		cmd.VCSTLSKeyFileFlag:          "key.pem",
+		cmd.WebBasicAuthFlag:           true,
+		cmd.WebUsernameFlag:            "admin",
+		cmd.WebhookAllowIPsFlag:        "104.192.136.0/21",
+		cmd.WebhookRateLimitFlag:       30,
+		cmd.WebhookTrustedProxiesFlag:  "10.0.0.1",
+		cmd.WorkerClaimTimeoutFlag:     "10m",
+		cmd.WorkerCountFlag:            8,
+		cmd.WorkingDirTTLFlag:          "72h",
+		cmd.WebPasswordFlag:            "password",
 	})
 	err := c.Execute()
 	Ok(t, err)
 
+	Equals(t, "myorg/infra", passedConfig.AllowApplyFrom)
+	Equals(t, 50, passedConfig.CheckoutDepth)
+	Equals(t, 20, passedConfig.DataDirMaxGB)
+	Equals(t, true, passedConfig.SparseCheckout)
 	Equals(t, "url", passedConfig.AtlantisURL)
+	Equals(t, "/tmp/audit.log", passedConfig.AuditLogFile)
+	Equals(t, "local", passedConfig.AuditSyslog)
 	Equals(t, true, passedConfig.AllowForkPRs)
 	Equals(t, true, passedConfig.AllowRepoConfig)
+	Equals(t, false, passedConfig.AllowTargetFlag)
+	Equals(t, true, passedConfig.DisableExtraArgs)
+	Equals(t, "api-secret", passedConfig.APISecret)
+	Equals(t, "s3", passedConfig.ArtifactBackend)
+	Equals(t, "plans", passedConfig.ArtifactBucket)
+	Equals(t, "atlantis/", passedConfig.ArtifactPrefix)
+	Equals(t, "https://minio.example.com", passedConfig.ArtifactS3Endpoint)
+	Equals(t, "eu-west-1", passedConfig.ArtifactS3Region)
+	Equals(t, "sandbox/**", passedConfig.AutodiscoverExclude)
+	Equals(t, "live/**", passedConfig.AutodiscoverInclude)
+	Equals(t, "**/*.tf,**/*.pkr.hcl", passedConfig.AutoplanFileList)
+	Equals(t, true, passedConfig.Automerge)
+	Equals(t, true, passedConfig.AutomergeDeleteSourceBranch)
+	Equals(t, "squash", passedConfig.AutomergeMethod)
 	Equals(t, "https://bitbucket-base-url.com", passedConfig.BitbucketBaseURL)
 	Equals(t, "bitbucket-token", passedConfig.BitbucketToken)
 	Equals(t, "bitbucket-user", passedConfig.BitbucketUser)
 	Equals(t, "bitbucket-secret", passedConfig.BitbucketWebhookSecret)
 	Equals(t, "/path", passedConfig.DataDir)
+	Equals(t, "0 6 * * *", passedConfig.DriftDetectionCron)
+	Equals(t, true, passedConfig.DryRun)
+	Equals(t, true, passedConfig.EnableAuditLog)
+	Equals(t, true, passedConfig.EnableCloneCache)
+	Equals(t, true, passedConfig.EnableCostEstimation)
+	Equals(t, true, passedConfig.EnablePrometheus)
+	Equals(t, true, passedConfig.EnableTerragrunt)
+	Equals(t, "atlantis-prod", passedConfig.ExecutableName)
+	Equals(t, "docker", passedConfig.ExecutionMode)
+	Equals(t, "hashicorp/terraform:0.12.29", passedConfig.DockerImage)
+	Equals(t, "/a:/a:ro", passedConfig.DockerMounts)
+	Equals(t, "1.5", passedConfig.DockerCPUs)
+	Equals(t, "2g", passedConfig.DockerMemory)
+	Equals(t, "/kubeconfig", passedConfig.Kubeconfig)
+	Equals(t, "hashicorp/terraform:light", passedConfig.KubernetesImage)
+	Equals(t, "atlantis", passedConfig.KubernetesNamespace)
+	Equals(t, "atlantis-data", passedConfig.KubernetesPVC)
+	Equals(t, "owner/repo=repo-sa", passedConfig.KubernetesServiceAccounts)
 	Equals(t, "ghhostname", passedConfig.GithubHostname)
 	Equals(t, "token", passedConfig.GithubToken)
 	Equals(t, "user", passedConfig.GithubUser)
@@ -469,14 +1241,36 @@ func TestExecute_Flags(t *testing.T) {
 	Equals(t, "gitlab-token", passedConfig.GitlabToken)
 	Equals(t, "gitlab-user", passedConfig.GitlabUser)
 	Equals(t, "gitlab-secret", passedConfig.GitlabWebhookSecret)
+	Equals(t, true, passedConfig.GitlabPipelineStatuses)
+	Equals(t, true, passedConfig.HidePrevPlanComments)
 	Equals(t, "debug", passedConfig.LogLevel)
+	Equals(t, false, passedConfig.PlanDrafts)
 	Equals(t, 8181, passedConfig.Port)
+	Equals(t, "prod/**", passedConfig.ProjectDirs)
 	Equals(t, "github.com/runatlantis/atlantis", passedConfig.RepoWhitelist)
+	Equals(t, true, passedConfig.RequireApplyConfirmation)
 	Equals(t, true, passedConfig.RequireApproval)
 	Equals(t, true, passedConfig.RequireMergeable)
 	Equals(t, "cert-file", passedConfig.SSLCertFile)
 	Equals(t, "key-file", passedConfig.SSLKeyFile)
 	Equals(t, "my-token", passedConfig.TFEToken)
+	Equals(t, false, passedConfig.TFPluginCache)
+	Equals(t, "outbound-ca.pem", passedConfig.SSLCAFile)
+	Equals(t, "ca.pem", passedConfig.VCSCAFile)
+	Equals(t, "http://proxy:3128", passedConfig.VCSProxyURL)
+	Equals(t, "atlantis-prod", passedConfig.VCSStatusName)
+	Equals(t, "cert.pem", passedConfig.VCSTLSCertFile)
+	Equals(t, "key.pem", passedConfig.VCSTLSKeyFile)
+	Equals(t, true, passedConfig.WebBasicAuth)
+	Equals(t, "admin", passedConfig.WebUsername)
+	Equals(t, "104.192.136.0/21", passedConfig.WebhookAllowIPs)
+	Equals(t, 30, passedConfig.WebhookRateLimit)
+	Equals(t, "10.0.0.1", passedConfig.WebhookTrustedProxies)
+	Equals(t, 100, passedConfig.MaxQueuedCommands)
+	Equals(t, "10m", passedConfig.WorkerClaimTimeout)
+	Equals(t, 8, passedConfig.WorkerCount)
+	Equals(t, "72h", passedConfig.WorkingDirTTL)
+	Equals(t, "password", passedConfig.WebPassword)
 }
 
 func TestExecute_ConfigFile(t *testing.T) {
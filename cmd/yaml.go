@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/yaml"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+	"github.com/spf13/cobra"
+)
+
+// YAMLCmd validates atlantis.yaml files offline, ex. in CI before a change
+// to one is merged.
+type YAMLCmd struct {
+	// Out is where the resolved projects are printed. Defaults to stdout.
+	Out io.Writer
+
+	repoConfig string
+	repo       string
+}
+
+// Init returns the runnable cobra command.
+func (y *YAMLCmd) Init() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "yaml",
+		Short: "Work with atlantis.yaml files",
+	}
+	validate := &cobra.Command{
+		Use:   "validate [dir]",
+		Short: "Validate the atlantis.yaml file in dir and print its projects",
+		Long: `Validate the atlantis.yaml file in dir, or the current directory, like the
+server does when a pull request is planned and print the projects it defines
+after the server-side repo config is applied.`,
+		Args:          cobra.MaximumNArgs(1),
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := "."
+			if len(args) > 0 {
+				dir = args[0]
+			}
+			err := y.validate(dir)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "\033[31mError: %s\033[39m\n", err.Error())
+			}
+			return err
+		},
+	}
+	validate.Flags().StringVar(&y.repoConfig, RepoConfigFlag, "", "Path to the server-side repo config file to apply, ex. to check the workflows the projects use are allowed.")
+	validate.Flags().StringVar(&y.repo, "repo", "", "The repo's hostname and full name, ex. github.com/owner/repo. Selects the repos in --"+RepoConfigFlag+" whose config is applied.")
+	c.AddCommand(validate)
+	return c
+}
+
+// validate validates the atlantis.yaml file in dir and prints its projects.
+func (y *YAMLCmd) validate(dir string) error {
+	repo, err := y.parseRepo()
+	if err != nil {
+		return err
+	}
+	parserValidator := &yaml.ParserValidator{}
+	var serverConfig valid.ServerConfig
+	if y.repoConfig != "" {
+		serverConfig, err = parserValidator.ReadServerConfig(y.repoConfig)
+		if err != nil {
+			return errors.Wrapf(err, "reading --%s", RepoConfigFlag)
+		}
+		parserValidator.ServerWorkflows = serverConfig.WorkflowNames()
+	}
+	hasConfigFile, err := parserValidator.HasConfigFile(dir)
+	if err != nil {
+		return err
+	}
+	if !hasConfigFile {
+		return fmt.Errorf("no %s file in %s", yaml.AtlantisYAMLFilename, dir)
+	}
+
+	// The server only reads atlantis.yaml files with --allow-repo-config so
+	// we assume it's set.
+	builder := &events.DefaultProjectCommandBuilder{
+		ParserValidator:     parserValidator,
+		ServerConfig:        serverConfig,
+		AllowRepoConfig:     true,
+		AllowRepoConfigFlag: AllowRepoConfigFlag,
+	}
+	config, err := builder.ReadRepoConfig(repo, dir)
+	if err != nil {
+		return err
+	}
+
+	out := y.Out
+	if out == nil {
+		out = os.Stdout
+	}
+	fmt.Fprintf(out, "%s is valid and defines %d project(s):\n\n", yaml.AtlantisYAMLFilename, len(config.Projects)) // nolint: errcheck
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tDIR\tWORKSPACE\tWORKFLOW\tTERRAFORM\tAUTOPLAN\tAPPLY REQUIREMENTS\tDEPENDS ON") // nolint: errcheck
+	for _, p := range config.Projects {
+		tfVersion := ""
+		if p.TerraformVersion != nil {
+			tfVersion = p.TerraformVersion.String()
+		}
+		autoplan := "no"
+		if p.Autoplan.Enabled {
+			autoplan = strings.Join(p.Autoplan.WhenModified, ",")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", // nolint: errcheck
+			orDash(p.GetName()),
+			p.Dir,
+			p.Workspace,
+			orDash(derefOrEmpty(p.Workflow)),
+			orDash(tfVersion),
+			orDash(autoplan),
+			orDash(formatApplyRequirements(p.ApplyRequirements, p.ApplyRequirementGroups)),
+			orDash(strings.Join(p.DependsOn, ",")),
+		)
+	}
+	return w.Flush()
+}
+
+// parseRepo returns the repo that --repo names. It's empty if --repo isn't
+// set.
+func (y *YAMLCmd) parseRepo() (models.Repo, error) {
+	if y.repo == "" {
+		return models.Repo{}, nil
+	}
+	parts := strings.SplitN(y.repo, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || !strings.Contains(parts[1], "/") {
+		return models.Repo{}, fmt.Errorf("invalid --repo %q: must be a hostname and repo name, ex. github.com/owner/repo", y.repo)
+	}
+	return models.Repo{
+		FullName: parts[1],
+		VCSHost:  models.VCSHost{Hostname: parts[0]},
+	}, nil
+}
+
+// formatApplyRequirements formats reqs and groups like they're written in
+// atlantis.yaml, ex. approved,any_of(approved,mergeable).
+func formatApplyRequirements(reqs []string, groups []valid.ApplyRequirementGroup) string {
+	formatted := append([]string{}, reqs...)
+	for _, g := range groups {
+		key := "all_of"
+		if g.AnyOf {
+			key = "any_of"
+		}
+		formatted = append(formatted, fmt.Sprintf("%s(%s)", key, strings.Join(g.Requirements, ",")))
+	}
+	return strings.Join(formatted, ",")
+}
+
+// derefOrEmpty returns *s or "" if s is nil.
+func derefOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// orDash returns s or "-" if it's empty so empty columns stand out.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
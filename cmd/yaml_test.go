@@ -0,0 +1,92 @@
+package cmd_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/runatlantis/atlantis/cmd"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestYAMLCmd_Validate(t *testing.T) {
+	tmpDir, cleanup := TempDir(t)
+	defer cleanup()
+	Ok(t, ioutil.WriteFile(filepath.Join(tmpDir, "atlantis.yaml"), []byte(`
+version: 2
+projects:
+- name: network
+  dir: network
+  apply_requirements: [mergeable]
+- name: compute
+  dir: compute
+  workspace: staging
+  terraform_version: 0.11.0
+  depends_on: [network]
+  autoplan:
+    enabled: false
+`), 0600))
+	repoConfig := filepath.Join(tmpDir, "repos.yaml")
+	Ok(t, ioutil.WriteFile(repoConfig, []byte(`
+repos:
+- id: github.com/owner/repo
+  workflow: standard
+  apply_requirements: [approved]
+workflows:
+  standard:
+    plan:
+      steps: [init, plan]
+`), 0600))
+
+	run := func(args ...string) (string, error) {
+		out := new(bytes.Buffer)
+		c := (&cmd.YAMLCmd{Out: out}).Init()
+		c.SetArgs(append([]string{"validate"}, args...))
+		err := c.Execute()
+		return out.String(), err
+	}
+
+	t.Run("without the server-side repo config", func(t *testing.T) {
+		out, err := run(tmpDir)
+		Ok(t, err)
+		Equals(t, `atlantis.yaml is valid and defines 2 project(s):
+
+NAME     DIR      WORKSPACE  WORKFLOW  TERRAFORM  AUTOPLAN  APPLY REQUIREMENTS  DEPENDS ON
+network  network  default    -         -          **/*.tf*  mergeable           -
+compute  compute  staging    -         0.11.0     no        -                   network
+`, out)
+	})
+
+	t.Run("with the server-side repo config", func(t *testing.T) {
+		out, err := run(tmpDir, "--repo-config", repoConfig, "--repo", "github.com/owner/repo")
+		Ok(t, err)
+		Equals(t, `atlantis.yaml is valid and defines 2 project(s):
+
+NAME     DIR      WORKSPACE  WORKFLOW  TERRAFORM  AUTOPLAN  APPLY REQUIREMENTS  DEPENDS ON
+network  network  default    standard  -          **/*.tf*  approved,mergeable  -
+compute  compute  staging    standard  0.11.0     no        approved            network
+`, out)
+	})
+
+	t.Run("invalid repo", func(t *testing.T) {
+		_, err := run(tmpDir, "--repo", "owner/repo")
+		ErrEquals(t, `invalid --repo "owner/repo": must be a hostname and repo name, ex. github.com/owner/repo`, err)
+	})
+
+	t.Run("no atlantis.yaml", func(t *testing.T) {
+		_, err := run(filepath.Join(tmpDir, "network"))
+		ErrContains(t, "no atlantis.yaml file in", err)
+	})
+
+	t.Run("invalid atlantis.yaml", func(t *testing.T) {
+		Ok(t, ioutil.WriteFile(filepath.Join(tmpDir, "atlantis.yaml"), []byte(`
+version: 2
+projects:
+- dir: network
+  workflow: undefined
+`), 0600))
+		_, err := run(tmpDir)
+		ErrEquals(t, `parsing atlantis.yaml: workflow "undefined" is not defined`, err)
+	})
+}
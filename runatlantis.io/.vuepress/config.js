@@ -53,6 +53,7 @@ module.exports = {
                         'deployment',
                         'configuring-webhooks',
                         'server-configuration',
+                        'server-side-repo-config',
                         'provider-credentials',
                         'terraform-enterprise'
                     ]
@@ -61,7 +62,12 @@ module.exports = {
                     title: 'Using Atlantis',
                     collapsable: true,
                     children: [
-                        ['using-atlantis', 'Overview']
+                        ['using-atlantis', 'Overview'],
+                        'viewing-jobs',
+                        'api-endpoints',
+                        'audit-log',
+                        'plan-artifacts',
+                        'automerging'
                     ]
                 },
                 {
@@ -71,7 +77,12 @@ module.exports = {
                         ['customizing-atlantis', 'Overview'],
                         'atlantis-yaml-reference',
                         'upgrading-atlantis-yaml-to-version-2',
-                        'apply-requirements'
+                        'apply-requirements',
+                        'policy-checking',
+                        'cost-estimation',
+                        'drift-detection',
+                        'workflow-hooks',
+                        'terragrunt'
                     ]
                 },
                 {
@@ -0,0 +1,415 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/audit"
+	"github.com/runatlantis/atlantis/server/events/locking"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// APITokenHeader is the header requests to the API must send the API secret
+// in.
+const APITokenHeader = "X-Atlantis-Token"
+
+// apiUser is the user that plans and applies from the API are run as.
+const apiUser = "atlantis-api"
+
+// APIController handles the /api routes which let CI systems plan and apply
+// without commenting on a pull request.
+type APIController struct {
+	// APISecret is the secret requests must send in the APITokenHeader
+	// header. If it's empty, the API is disabled.
+	APISecret             []byte
+	Locker                locking.Locker
+	ApplyLocker           locking.ApplyLocker
+	Logger                *logging.SimpleLogger
+	Parser                *events.EventParser
+	ProjectCommandBuilder events.ProjectCommandBuilder
+	ProjectCommandRunner  events.ProjectCommandRunner
+	RepoWhitelistChecker  *events.RepoWhitelistChecker
+	// CloneHosts are the VCS hosts we can clone from, keyed by hostname.
+	CloneHosts map[string]CloneHost
+	// AuditLog is listed by GET /api/audit. If nil, the audit log isn't
+	// enabled.
+	AuditLog *audit.Log
+	// DryRun is true if Atlantis is in dry-run mode so applies are refused.
+	DryRun bool
+	// ServerConfig is the server-side repo config. Applies are refused for
+	// its plan-only repos and, since requests don't say which branch the pull
+	// request is into, for repos that only allow some branches to be applied.
+	ServerConfig valid.ServerConfig
+}
+
+// APIRequest is the body of the POST /api/plan and /api/apply routes.
+type APIRequest struct {
+	// Repository is the repo's hostname and full name, ex.
+	// github.com/runatlantis/atlantis.
+	Repository string `json:"repository"`
+	// Ref is the branch, tag or commit to plan. It's only used by plan since
+	// apply applies the plans that were last made for PR.
+	Ref string `json:"ref"`
+	// PR is the number of the pull request to run as. Its apply requirements
+	// are checked and its locks are used. If 0, the run isn't for a pull
+	// request.
+	PR int `json:"pr"`
+	// Projects are the names of the projects to run.
+	Projects []string `json:"projects"`
+	// Paths are the dirs and workspaces to run.
+	Paths []APIRequestPath `json:"paths"`
+}
+
+// APIRequestPath is a dir and workspace to run.
+type APIRequestPath struct {
+	Directory string `json:"directory"`
+	// Workspace defaults to the default workspace.
+	Workspace string `json:"workspace"`
+}
+
+// APIResponse is the response of the API routes.
+type APIResponse struct {
+	// Error is set if the request failed before any projects were run.
+	Error          string             `json:"error,omitempty"`
+	ProjectResults []APIProjectResult `json:"project_results,omitempty"`
+	// AppliesLockedAt is when applies were locked. It's only set by
+	// POST /api/locks/global.
+	AppliesLockedAt *time.Time `json:"applies_locked_at,omitempty"`
+	// AuditEvents are set by GET /api/audit, oldest first.
+	AuditEvents []models.AuditEvent `json:"audit_events,omitempty"`
+	// NextAfter is the ID of the last of AuditEvents. It's passed as the
+	// after query param to get the events after them.
+	NextAfter string `json:"next_after,omitempty"`
+}
+
+// APIProjectResult is the result of running one project.
+type APIProjectResult struct {
+	RepoRelDir  string `json:"dir"`
+	Workspace   string `json:"workspace"`
+	ProjectName string `json:"project_name,omitempty"`
+	// JobID is the ID of the job whose output can be viewed at /jobs/{id}.
+	JobID   string `json:"job_id,omitempty"`
+	Success bool   `json:"success"`
+	// Output is the output of plan or apply.
+	Output string `json:"output,omitempty"`
+	// Failure is why the command didn't run, ex. because the project is
+	// locked.
+	Failure string `json:"failure,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Plan is the POST /api/plan route. It plans the requested projects at the
+// requested ref and responds once they've all been planned.
+func (a *APIController) Plan(w http.ResponseWriter, r *http.Request) {
+	a.run(w, r, events.PlanCommand)
+}
+
+// Apply is the POST /api/apply route. It applies the plans of the requested
+// projects that were made for the requested pull request, or by the API if
+// no pull request is given.
+func (a *APIController) Apply(w http.ResponseWriter, r *http.Request) {
+	a.run(w, r, events.ApplyCommand)
+}
+
+// LockApplies is the POST /api/locks/global route. It stops all applies,
+// whether from comments or the API, until they're unlocked.
+func (a *APIController) LockApplies(w http.ResponseWriter, r *http.Request) {
+	if !a.authenticate(w, r) {
+		return
+	}
+	lock, err := a.ApplyLocker.LockApplies()
+	if err != nil {
+		a.respond(w, logging.Error, http.StatusInternalServerError, APIResponse{Error: fmt.Sprintf("locking applies: %s", err)})
+		return
+	}
+	a.Logger.Info("locked applies")
+	a.respond(w, logging.Info, http.StatusOK, APIResponse{AppliesLockedAt: &lock.Time})
+}
+
+// UnlockApplies is the DELETE /api/locks/global route. It lets applies run
+// again.
+func (a *APIController) UnlockApplies(w http.ResponseWriter, r *http.Request) {
+	if !a.authenticate(w, r) {
+		return
+	}
+	if err := a.ApplyLocker.UnlockApplies(); err != nil {
+		a.respond(w, logging.Error, http.StatusInternalServerError, APIResponse{Error: fmt.Sprintf("unlocking applies: %s", err)})
+		return
+	}
+	a.Logger.Info("unlocked applies")
+	a.respond(w, logging.Info, http.StatusOK, APIResponse{})
+}
+
+// ListAuditEvents is the GET /api/audit route. It lists the audit log, oldest
+// first. The after query param is the ID of the event to list from and limit
+// is how many to list.
+func (a *APIController) ListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	if !a.authenticate(w, r) {
+		return
+	}
+	if a.AuditLog == nil {
+		a.respond(w, logging.Warn, http.StatusBadRequest, APIResponse{Error: "the audit log is disabled: --enable-audit-log isn't set"})
+		return
+	}
+	var limit int
+	if l := r.URL.Query().Get("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil || limit < 1 {
+			a.respond(w, logging.Warn, http.StatusBadRequest, APIResponse{Error: fmt.Sprintf("invalid limit %q: must be a positive number", l)})
+			return
+		}
+	}
+	list, err := a.AuditLog.List(r.URL.Query().Get("after"), limit)
+	if err != nil {
+		a.respond(w, logging.Error, http.StatusInternalServerError, APIResponse{Error: fmt.Sprintf("listing audit events: %s", err)})
+		return
+	}
+	resp := APIResponse{AuditEvents: list}
+	if len(list) > 0 {
+		resp.NextAfter = list[len(list)-1].ID
+	}
+	a.respond(w, logging.Debug, http.StatusOK, resp)
+}
+
+// authenticate returns true if r has the API secret. Otherwise it responds
+// with why not.
+func (a *APIController) authenticate(w http.ResponseWriter, r *http.Request) bool {
+	if len(a.APISecret) == 0 {
+		a.respond(w, logging.Warn, http.StatusBadRequest, APIResponse{Error: "ignoring request since the API is disabled: --api-secret isn't set"})
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get(APITokenHeader)), a.APISecret) != 1 {
+		a.respond(w, logging.Warn, http.StatusUnauthorized, APIResponse{Error: fmt.Sprintf("missing or invalid %s header", APITokenHeader)})
+		return false
+	}
+	return true
+}
+
+func (a *APIController) run(w http.ResponseWriter, r *http.Request, cmdName events.CommandName) {
+	if !a.authenticate(w, r) {
+		return
+	}
+
+	var req APIRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.respond(w, logging.Warn, http.StatusBadRequest, APIResponse{Error: fmt.Sprintf("parsing request body: %s", err)})
+		return
+	}
+	cmds, err := a.commentCommands(req, cmdName)
+	if err != nil {
+		a.respond(w, logging.Warn, http.StatusBadRequest, APIResponse{Error: err.Error()})
+		return
+	}
+	repo, err := a.parseRepo(req.Repository)
+	if err != nil {
+		a.respond(w, logging.Warn, http.StatusBadRequest, APIResponse{Error: err.Error()})
+		return
+	}
+	if a.DryRun && cmdName.ChangesState() {
+		a.respond(w, logging.Info, http.StatusForbidden, APIResponse{Error: events.DryRunMessage(cmdName, "")})
+		return
+	}
+	if cmdName.ChangesState() {
+		if repoCfg := a.ServerConfig.FindRepo(repo.FullName, repo.VCSHost.Hostname); repoCfg != nil {
+			if err := repoCfg.CheckChangesAllowed(""); err != nil {
+				a.respond(w, logging.Info, http.StatusForbidden, APIResponse{Error: fmt.Sprintf("%s is disabled: %s", cmdName.String(), err)})
+				return
+			}
+		}
+	}
+	if cmdName == events.ApplyCommand {
+		lock, err := a.ApplyLocker.GetApplyLock()
+		if err != nil {
+			a.respond(w, logging.Error, http.StatusInternalServerError, APIResponse{Error: fmt.Sprintf("checking if applies are locked: %s", err)})
+			return
+		}
+		if lock != nil {
+			a.respond(w, logging.Info, http.StatusLocked, APIResponse{Error: events.ApplyLockedMessage(*lock, events.ApplyCommand, "")})
+			return
+		}
+	}
+
+	ctx := &events.CommandContext{
+		BaseRepo: repo,
+		HeadRepo: repo,
+		Pull: models.PullRequest{
+			Num:        req.PR,
+			HeadCommit: req.Ref,
+			Branch:     req.Ref,
+			Author:     apiUser,
+			State:      models.OpenPullState,
+			BaseRepo:   repo,
+		},
+		User: models.User{Username: apiUser},
+		Log:  logging.NewSimpleLogger(fmt.Sprintf("api %s#%d", repo.FullName, req.PR), false, a.Logger.Level),
+	}
+	var projCtxs []models.ProjectCommandContext
+	for _, cmd := range cmds {
+		var built []models.ProjectCommandContext
+		if cmdName == events.PlanCommand {
+			built, err = a.ProjectCommandBuilder.BuildPlanCommands(ctx, cmd)
+		} else {
+			built, err = a.ProjectCommandBuilder.BuildApplyCommands(ctx, cmd)
+		}
+		if err != nil {
+			a.respond(w, logging.Error, http.StatusInternalServerError, APIResponse{Error: fmt.Sprintf("building %s command: %s", cmdName.String(), err)})
+			return
+		}
+		projCtxs = append(projCtxs, built...)
+	}
+
+	var resp APIResponse
+	code := http.StatusOK
+	for _, projCtx := range projCtxs {
+		var res events.ProjectResult
+		if cmdName == events.PlanCommand {
+			res = a.ProjectCommandRunner.Plan(projCtx)
+		} else {
+			res = a.ProjectCommandRunner.Apply(projCtx)
+			a.unlockApplied(ctx, res)
+		}
+		projRes := APIProjectResult{
+			RepoRelDir:  res.RepoRelDir,
+			Workspace:   res.Workspace,
+			ProjectName: res.ProjectName,
+			JobID:       res.JobID,
+			Success:     res.Status() == models.SuccessCommitStatus,
+			Output:      res.ApplySuccess,
+			Failure:     res.Failure,
+		}
+		if res.PlanSuccess != nil {
+			projRes.Output = res.PlanSuccess.TerraformOutput
+		}
+		if res.Error != nil {
+			projRes.Error = res.Error.Error()
+		}
+		if !projRes.Success {
+			code = http.StatusInternalServerError
+		}
+		resp.ProjectResults = append(resp.ProjectResults, projRes)
+	}
+	a.respond(w, logging.Info, code, resp)
+}
+
+// commentCommands returns the commands for each of the projects and paths in
+// req, the same as if they'd been commented.
+func (a *APIController) commentCommands(req APIRequest, cmdName events.CommandName) ([]*events.CommentCommand, error) {
+	if req.Repository == "" {
+		return nil, fmt.Errorf("repository is required")
+	}
+	if cmdName == events.PlanCommand && req.Ref == "" {
+		return nil, fmt.Errorf("ref is required")
+	}
+	if req.Ref != "" && !validRef(req.Ref) {
+		return nil, fmt.Errorf("invalid ref: %q", req.Ref)
+	}
+	if len(req.Projects) == 0 && len(req.Paths) == 0 {
+		return nil, fmt.Errorf("at least one of projects or paths is required")
+	}
+
+	var cmds []*events.CommentCommand
+	for _, project := range req.Projects {
+		if project == "" {
+			return nil, fmt.Errorf("project names can't be empty")
+		}
+		cmds = append(cmds, &events.CommentCommand{Name: cmdName, ProjectName: project})
+	}
+	for _, path := range req.Paths {
+		// Paths are validated like the -d and -w comment flags.
+		dir := filepath.Clean(filepath.Join(".", filepath.Clean(path.Directory)))
+		if strings.HasPrefix(dir, "..") {
+			return nil, fmt.Errorf("using a relative path %q is not allowed", path.Directory)
+		}
+		workspace := path.Workspace
+		if workspace == "" {
+			workspace = events.DefaultWorkspace
+		}
+		if workspace != url.PathEscape(workspace) || strings.Contains(workspace, "..") {
+			return nil, fmt.Errorf("invalid workspace: %q", workspace)
+		}
+		cmds = append(cmds, &events.CommentCommand{Name: cmdName, RepoRelDir: dir, Workspace: workspace})
+	}
+	return cmds, nil
+}
+
+// validRef returns true if ref is a valid branch, tag or commit by the rules
+// of git check-ref-format and can't be mistaken for an option since it's
+// passed to git checkout.
+func validRef(ref string) bool {
+	if strings.HasPrefix(ref, "-") || strings.HasPrefix(ref, "/") || strings.HasSuffix(ref, "/") ||
+		strings.HasSuffix(ref, ".") || ref == "@" {
+		return false
+	}
+	for _, invalid := range []string{"..", "//", "@{", "\\"} {
+		if strings.Contains(ref, invalid) {
+			return false
+		}
+	}
+	for _, r := range ref {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(" ~^:?*[", r) {
+			return false
+		}
+	}
+	for _, component := range strings.Split(ref, "/") {
+		if strings.HasPrefix(component, ".") || strings.HasSuffix(component, ".lock") {
+			return false
+		}
+	}
+	return true
+}
+
+// parseRepo returns the repo for id, ex. github.com/owner/repo, with our
+// credentials to clone it.
+func (a *APIController) parseRepo(id string) (models.Repo, error) {
+	split := strings.SplitN(id, "/", 2)
+	if len(split) != 2 {
+		return models.Repo{}, fmt.Errorf("repository %q isn't a hostname and repo name, ex. github.com/owner/repo", id)
+	}
+	hostname, fullName := strings.ToLower(split[0]), split[1]
+	host, ok := a.CloneHosts[hostname]
+	if !ok {
+		return models.Repo{}, fmt.Errorf("Atlantis isn't configured to clone from %s: only GitHub, GitLab, Bitbucket Cloud and Gitea repos are supported", hostname)
+	}
+	if !a.RepoWhitelistChecker.IsWhitelisted(fullName, hostname) {
+		return models.Repo{}, fmt.Errorf("repository %q isn't in the repo whitelist", id)
+	}
+	return a.Parser.ParseRepoCloneURL(host.HostType, fullName, fmt.Sprintf("%s/%s.git", host.BaseURL, fullName))
+}
+
+// unlockApplied deletes the lock on a project that was planned and applied
+// without a pull request. Pull requests' locks are deleted when they're
+// closed but there's nothing to close for the API's plans.
+func (a *APIController) unlockApplied(ctx *events.CommandContext, res events.ProjectResult) {
+	if ctx.Pull.Num != 0 || res.Status() != models.SuccessCommitStatus {
+		return
+	}
+	key := locking.GenerateLockKey(models.NewProject(ctx.BaseRepo.FullName, res.RepoRelDir), res.Workspace)
+	if _, err := a.Locker.Unlock(key); err != nil {
+		ctx.Log.Warn("unable to delete lock %q after applying: %s", key, err)
+	}
+}
+
+func (a *APIController) respond(w http.ResponseWriter, lvl logging.LogLevel, responseCode int, resp APIResponse) {
+	if resp.Error != "" {
+		a.Logger.Log(lvl, "%s", resp.Error)
+	}
+	body, err := json.Marshal(resp)
+	if err != nil {
+		a.Logger.Err("marshalling API response: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(responseCode)
+	w.Write(body) // nolint: errcheck
+}
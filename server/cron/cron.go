@@ -0,0 +1,186 @@
+// Package cron parses cron schedule expressions and works out when they next
+// run. It supports the standard five fields: minute, hour, day of month,
+// month and day of week.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// descriptors are the shorthand expressions we support.
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// field is the range of values one of the fields can hold.
+type field struct {
+	name string
+	min  int
+	max  int
+}
+
+var fields = []field{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	// 7 is also Sunday.
+	{"day of week", 0, 7},
+}
+
+// Schedule is a parsed cron expression.
+type Schedule struct {
+	expr     string
+	minute   map[int]bool
+	hour     map[int]bool
+	dom      map[int]bool
+	month    map[int]bool
+	dow      map[int]bool
+	anyDom   bool
+	anyDow   bool
+	location *time.Location
+}
+
+// Parse parses expr, ex. "0 6 * * 1-5". See
+// https://en.wikipedia.org/wiki/Cron#Overview. Times are in UTC.
+func Parse(expr string) (*Schedule, error) {
+	trimmed := strings.TrimSpace(expr)
+	if d, ok := descriptors[trimmed]; ok {
+		trimmed = d
+	}
+	parts := strings.Fields(trimmed)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("expected %d fields (minute hour day-of-month month day-of-week) but found %d in %q", len(fields), len(parts), expr)
+	}
+	var sets []map[int]bool
+	for i, part := range parts {
+		set, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, set)
+	}
+	// Sunday can be 0 or 7.
+	if sets[4][7] {
+		sets[4][0] = true
+	}
+	return &Schedule{
+		expr:     expr,
+		minute:   sets[0],
+		hour:     sets[1],
+		dom:      sets[2],
+		month:    sets[3],
+		dow:      sets[4],
+		anyDom:   parts[2] == "*",
+		anyDow:   parts[4] == "*",
+		location: time.UTC,
+	}, nil
+}
+
+// String returns the expression the schedule was parsed from.
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+// Next returns the first time after t that the schedule runs. It returns the
+// zero time if the schedule never runs, ex. "0 0 30 2 *".
+func (s *Schedule) Next(t time.Time) time.Time {
+	next := t.In(s.location).Truncate(time.Minute).Add(time.Minute)
+	// Every schedule that can run does so within five years, the longest
+	// being ones that only run on the 29th of February.
+	end := next.AddDate(5, 0, 0)
+	for next.Before(end) {
+		switch {
+		case !s.month[int(next.Month())]:
+			next = time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0, s.location)
+		case !s.dayMatches(next):
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, s.location)
+		case !s.hour[next.Hour()]:
+			next = next.Truncate(time.Hour).Add(time.Hour)
+		case !s.minute[next.Minute()]:
+			next = next.Add(time.Minute)
+		default:
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches returns true if t's day matches the schedule. Like cron, if both
+// the day of month and day of week are restricted then either can match.
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom[t.Day()]
+	dowMatch := s.dow[int(t.Weekday())]
+	switch {
+	case s.anyDom && s.anyDow:
+		return true
+	case s.anyDom:
+		return dowMatch
+	case s.anyDow:
+		return domMatch
+	default:
+		return domMatch || dowMatch
+	}
+}
+
+// parseField parses a comma-separated list of values, ranges, ex. 1-5, and
+// steps, ex. */15 or 0-30/10.
+func parseField(value string, f field) (map[int]bool, error) {
+	set := make(map[int]bool)
+	for _, item := range strings.Split(value, ",") {
+		rangePart, step := item, 1
+		if i := strings.Index(item, "/"); i != -1 {
+			rangePart = item[:i]
+			var err error
+			step, err = strconv.Atoi(item[i+1:])
+			if err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step %q in %s field", item[i+1:], f.name)
+			}
+		}
+
+		start, end := f.min, f.max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if start, err = parseValue(bounds[0], f); err != nil {
+				return nil, err
+			}
+			end = start
+			if len(bounds) == 2 {
+				if end, err = parseValue(bounds[1], f); err != nil {
+					return nil, err
+				}
+			} else if step != 1 {
+				// Like cron, 5/10 means from 5 to the end in steps of 10.
+				end = f.max
+			}
+			if start > end {
+				return nil, fmt.Errorf("invalid range %q in %s field: start is after end", rangePart, f.name)
+			}
+		}
+		for v := start; v <= end; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+func parseValue(value string, f field) (int, error) {
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q in %s field", value, f.name)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("value %d in %s field is out of range, must be between %d and %d", v, f.name, f.min, f.max)
+	}
+	return v, nil
+}
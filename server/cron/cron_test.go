@@ -0,0 +1,88 @@
+package cron_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/cron"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestParse_Errors(t *testing.T) {
+	cases := []struct {
+		expr   string
+		expErr string
+	}{
+		{
+			"* * * *",
+			"expected 5 fields (minute hour day-of-month month day-of-week) but found 4 in \"* * * *\"",
+		},
+		{
+			"60 * * * *",
+			"value 60 in minute field is out of range, must be between 0 and 59",
+		},
+		{
+			"* * 0 * *",
+			"value 0 in day of month field is out of range, must be between 1 and 31",
+		},
+		{
+			"a * * * *",
+			"invalid value \"a\" in minute field",
+		},
+		{
+			"*/0 * * * *",
+			"invalid step \"0\" in minute field",
+		},
+		{
+			"* 5-1 * * *",
+			"invalid range \"5-1\" in hour field: start is after end",
+		},
+		{
+			"@often",
+			"expected 5 fields (minute hour day-of-month month day-of-week) but found 1 in \"@often\"",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.expr, func(t *testing.T) {
+			_, err := cron.Parse(c.expr)
+			ErrEquals(t, c.expErr, err)
+		})
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	// A Wednesday.
+	now := time.Date(2019, 1, 2, 10, 30, 45, 0, time.UTC)
+	cases := []struct {
+		expr string
+		exp  time.Time
+	}{
+		{"* * * * *", time.Date(2019, 1, 2, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2019, 1, 2, 10, 45, 0, 0, time.UTC)},
+		{"0 * * * *", time.Date(2019, 1, 2, 11, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2019, 1, 2, 11, 0, 0, 0, time.UTC)},
+		{"0 6 * * *", time.Date(2019, 1, 3, 6, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2019, 1, 3, 0, 0, 0, 0, time.UTC)},
+		{"30 10 * * *", time.Date(2019, 1, 3, 10, 30, 0, 0, time.UTC)},
+		{"0 9,17 * * *", time.Date(2019, 1, 2, 17, 0, 0, 0, time.UTC)},
+		// Saturday.
+		{"0 0 * * 6", time.Date(2019, 1, 5, 0, 0, 0, 0, time.UTC)},
+		// Sunday can be 0 or 7.
+		{"0 0 * * 7", time.Date(2019, 1, 6, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 1-5", time.Date(2019, 1, 3, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2019, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 6 *", time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)},
+		// If both day fields are set, either can match: the 10th or a Friday.
+		{"0 0 10 * 5", time.Date(2019, 1, 4, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"5/20 * * * *", time.Date(2019, 1, 2, 10, 45, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, c := range cases {
+		t.Run(c.expr, func(t *testing.T) {
+			s, err := cron.Parse(c.expr)
+			Ok(t, err)
+			Equals(t, c.exp, s.Next(now))
+		})
+	}
+}
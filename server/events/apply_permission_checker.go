@@ -0,0 +1,127 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+)
+
+// DefaultTeamMembershipCacheTTL is how long DefaultApplyPermissionChecker
+// remembers whether a user is in a team.
+const DefaultTeamMembershipCacheTTL = 5 * time.Minute
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_apply_permission_checker.go ApplyPermissionChecker
+
+// ApplyPermissionChecker checks who can run atlantis apply and the other
+// commands that change state, ex. atlantis import.
+type ApplyPermissionChecker interface {
+	// CheckApply returns an error explaining why user can't apply in repo or
+	// nil if they can.
+	CheckApply(repo models.Repo, user models.User) error
+}
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_team_membership_getter.go TeamMembershipGetter
+
+// TeamMembershipGetter makes API calls to check if users are in teams. It's
+// implemented by the GitHub client, where teams are org/team-slug, and the
+// GitLab client, where teams are full group paths, ex. group/subgroup.
+type TeamMembershipGetter interface {
+	// IsTeamMember returns true if username is a member of team.
+	IsTeamMember(team string, username string) (bool, error)
+}
+
+// DefaultApplyPermissionChecker only lets the members of the teams in
+// AllowApplyFrom, or the repo's allow_apply_from in the server-side repo
+// config, apply. Team memberships are cached for CacheTTL so that every apply
+// doesn't make API calls.
+type DefaultApplyPermissionChecker struct {
+	// AllowApplyFrom are the teams that can apply. If empty, anyone can
+	// apply unless the repo sets allow_apply_from.
+	AllowApplyFrom []string
+	ServerConfig   valid.ServerConfig
+	// GithubTeamGetter and GitlabGroupGetter check the memberships on
+	// GitHub and GitLab. We can't check them on the other VCS hosts so
+	// applies are refused there if they're restricted.
+	GithubTeamGetter  TeamMembershipGetter
+	GitlabGroupGetter TeamMembershipGetter
+	// CacheTTL defaults to DefaultTeamMembershipCacheTTL.
+	CacheTTL time.Duration
+
+	mu    sync.Mutex
+	cache map[teamMembershipKey]teamMembership
+}
+
+type teamMembershipKey struct {
+	vcsHost  models.VCSHostType
+	team     string
+	username string
+}
+
+type teamMembership struct {
+	member  bool
+	expires time.Time
+}
+
+func (d *DefaultApplyPermissionChecker) CheckApply(repo models.Repo, user models.User) error {
+	teams := d.AllowApplyFrom
+	if repoCfg := d.ServerConfig.FindRepo(repo.FullName, repo.VCSHost.Hostname); repoCfg != nil && repoCfg.AllowApplyFrom != nil {
+		teams = repoCfg.AllowApplyFrom
+	}
+	if len(teams) == 0 {
+		return nil
+	}
+
+	var getter TeamMembershipGetter
+	switch repo.VCSHost.Type {
+	case models.Github:
+		getter = d.GithubTeamGetter
+	case models.Gitlab:
+		getter = d.GitlabGroupGetter
+	}
+	if getter == nil {
+		return fmt.Errorf("applies are restricted to the members of %s but team membership can't be checked on %s", strings.Join(teams, ", "), repo.VCSHost.Type)
+	}
+	for _, team := range teams {
+		member, err := d.isTeamMember(getter, repo.VCSHost.Type, team, user.Username)
+		if err != nil {
+			return errors.Wrapf(err, "checking if %s is a member of %s", user.Username, team)
+		}
+		if member {
+			return nil
+		}
+	}
+	return fmt.Errorf("user %s can't apply in this repo because only the members of %s can", user.Username, strings.Join(teams, ", "))
+}
+
+// isTeamMember returns whether username is in team, from the cache if we've
+// checked recently. Errors aren't cached so the next apply tries again.
+func (d *DefaultApplyPermissionChecker) isTeamMember(getter TeamMembershipGetter, vcsHost models.VCSHostType, team string, username string) (bool, error) {
+	key := teamMembershipKey{vcsHost, strings.ToLower(team), strings.ToLower(username)}
+	d.mu.Lock()
+	cached, ok := d.cache[key]
+	d.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.member, nil
+	}
+
+	member, err := getter.IsTeamMember(team, username)
+	if err != nil {
+		return false, err
+	}
+	ttl := d.CacheTTL
+	if ttl == 0 {
+		ttl = DefaultTeamMembershipCacheTTL
+	}
+	d.mu.Lock()
+	if d.cache == nil {
+		d.cache = make(map[teamMembershipKey]teamMembership)
+	}
+	d.cache[key] = teamMembership{member: member, expires: time.Now().Add(ttl)}
+	d.mu.Unlock()
+	return member, nil
+}
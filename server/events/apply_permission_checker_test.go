@@ -0,0 +1,138 @@
+package events_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+var permissionRepo = models.Repo{
+	FullName: "owner/repo",
+	VCSHost:  models.VCSHost{Hostname: "github.com", Type: models.Github},
+}
+
+func TestCheckApply_NoTeams(t *testing.T) {
+	RegisterMockTestingT(t)
+	getter := mocks.NewMockTeamMembershipGetter()
+	checker := &events.DefaultApplyPermissionChecker{GithubTeamGetter: getter}
+	Ok(t, checker.CheckApply(permissionRepo, models.User{Username: "alice"}))
+	getter.VerifyWasCalled(Never()).IsTeamMember(AnyString(), AnyString())
+}
+
+func TestCheckApply_Member(t *testing.T) {
+	RegisterMockTestingT(t)
+	getter := mocks.NewMockTeamMembershipGetter()
+	When(getter.IsTeamMember("myorg/infra", "alice")).ThenReturn(false, nil)
+	When(getter.IsTeamMember("myorg/sre", "alice")).ThenReturn(true, nil)
+	checker := &events.DefaultApplyPermissionChecker{
+		AllowApplyFrom:   []string{"myorg/infra", "myorg/sre"},
+		GithubTeamGetter: getter,
+	}
+	Ok(t, checker.CheckApply(permissionRepo, models.User{Username: "alice"}))
+}
+
+func TestCheckApply_NotMember(t *testing.T) {
+	RegisterMockTestingT(t)
+	getter := mocks.NewMockTeamMembershipGetter()
+	When(getter.IsTeamMember(AnyString(), AnyString())).ThenReturn(false, nil)
+	checker := &events.DefaultApplyPermissionChecker{
+		AllowApplyFrom:   []string{"myorg/infra", "myorg/sre"},
+		GithubTeamGetter: getter,
+	}
+	err := checker.CheckApply(permissionRepo, models.User{Username: "bob"})
+	ErrEquals(t, "user bob can't apply in this repo because only the members of myorg/infra, myorg/sre can", err)
+}
+
+func TestCheckApply_CachesMemberships(t *testing.T) {
+	RegisterMockTestingT(t)
+	getter := mocks.NewMockTeamMembershipGetter()
+	When(getter.IsTeamMember("myorg/infra", "alice")).ThenReturn(true, nil)
+	checker := &events.DefaultApplyPermissionChecker{
+		AllowApplyFrom:   []string{"myorg/infra"},
+		GithubTeamGetter: getter,
+	}
+	Ok(t, checker.CheckApply(permissionRepo, models.User{Username: "alice"}))
+	Ok(t, checker.CheckApply(permissionRepo, models.User{Username: "Alice"}))
+	getter.VerifyWasCalledOnce().IsTeamMember(AnyString(), AnyString())
+
+	t.Log("once the cache expires we should check again")
+	checker.CacheTTL = time.Millisecond
+	time.Sleep(5 * time.Millisecond)
+	Ok(t, checker.CheckApply(permissionRepo, models.User{Username: "alice"}))
+	getter.VerifyWasCalledOnce().IsTeamMember(AnyString(), AnyString())
+}
+
+func TestCheckApply_ErrsNotCached(t *testing.T) {
+	RegisterMockTestingT(t)
+	getter := mocks.NewMockTeamMembershipGetter()
+	When(getter.IsTeamMember("myorg/infra", "alice")).ThenReturn(false, errors.New("api error"))
+	checker := &events.DefaultApplyPermissionChecker{
+		AllowApplyFrom:   []string{"myorg/infra"},
+		GithubTeamGetter: getter,
+	}
+	err := checker.CheckApply(permissionRepo, models.User{Username: "alice"})
+	ErrEquals(t, "checking if alice is a member of myorg/infra: api error", err)
+	err = checker.CheckApply(permissionRepo, models.User{Username: "alice"})
+	ErrEquals(t, "checking if alice is a member of myorg/infra: api error", err)
+	getter.VerifyWasCalled(Times(2)).IsTeamMember(AnyString(), AnyString())
+}
+
+func TestCheckApply_RepoConfigOverrides(t *testing.T) {
+	RegisterMockTestingT(t)
+	getter := mocks.NewMockTeamMembershipGetter()
+	When(getter.IsTeamMember("myorg/infra", "alice")).ThenReturn(false, nil)
+	When(getter.IsTeamMember("myorg/payments", "alice")).ThenReturn(true, nil)
+	checker := &events.DefaultApplyPermissionChecker{
+		AllowApplyFrom: []string{"myorg/infra"},
+		ServerConfig: valid.ServerConfig{
+			Repos: []valid.Repo{
+				{ID: "github.com/owner/repo", AllowApplyFrom: []string{"myorg/payments"}},
+			},
+		},
+		GithubTeamGetter: getter,
+	}
+	Ok(t, checker.CheckApply(permissionRepo, models.User{Username: "alice"}))
+
+	t.Log("other repos should still use the server's teams")
+	otherRepo := permissionRepo
+	otherRepo.FullName = "owner/other"
+	err := checker.CheckApply(otherRepo, models.User{Username: "alice"})
+	ErrEquals(t, "user alice can't apply in this repo because only the members of myorg/infra can", err)
+}
+
+func TestCheckApply_GitlabGroups(t *testing.T) {
+	RegisterMockTestingT(t)
+	githubGetter := mocks.NewMockTeamMembershipGetter()
+	gitlabGetter := mocks.NewMockTeamMembershipGetter()
+	When(gitlabGetter.IsTeamMember("mygroup/infra", "alice")).ThenReturn(true, nil)
+	checker := &events.DefaultApplyPermissionChecker{
+		AllowApplyFrom:    []string{"mygroup/infra"},
+		GithubTeamGetter:  githubGetter,
+		GitlabGroupGetter: gitlabGetter,
+	}
+	repo := models.Repo{
+		FullName: "mygroup/repo",
+		VCSHost:  models.VCSHost{Hostname: "gitlab.com", Type: models.Gitlab},
+	}
+	Ok(t, checker.CheckApply(repo, models.User{Username: "alice"}))
+	githubGetter.VerifyWasCalled(Never()).IsTeamMember(AnyString(), AnyString())
+}
+
+func TestCheckApply_UnsupportedVCSHost(t *testing.T) {
+	checker := &events.DefaultApplyPermissionChecker{
+		AllowApplyFrom: []string{"infra"},
+	}
+	repo := models.Repo{
+		FullName: "owner/repo",
+		VCSHost:  models.VCSHost{Hostname: "bitbucket.org", Type: models.BitbucketCloud},
+	}
+	err := checker.CheckApply(repo, models.User{Username: "alice"})
+	ErrEquals(t, "applies are restricted to the members of infra but team membership can't be checked on BitbucketCloud", err)
+}
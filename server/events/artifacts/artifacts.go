@@ -0,0 +1,55 @@
+// Package artifacts exports the files of each plan to an object store outside
+// the Atlantis server, ex. S3 or GCS, so they can be audited after the fact and
+// applied by another replica that doesn't have the pull request's clone.
+package artifacts
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Backends are the object stores plans can be exported to.
+const (
+	S3Backend  = "s3"
+	GCSBackend = "gcs"
+)
+
+// Store persists plan artifacts. Keys are /-separated paths.
+type Store interface {
+	// Put creates or replaces the object at key.
+	Put(key string, contents []byte) error
+	// Get returns the object at key or nil if there isn't one.
+	Get(key string) ([]byte, error)
+	// List returns the keys of the objects that start with prefix.
+	List(prefix string) ([]string, error)
+	// Delete deletes the object at key. It's not an error if there isn't one.
+	Delete(key string) error
+}
+
+// PullPrefix returns the prefix of the keys of the artifacts of the plans
+// made for headCommit of the pull. Plans for older commits can't be applied
+// so they're never restored, but they're kept for audits.
+func PullPrefix(repoFullName string, pullNum int, headCommit string) string {
+	return fmt.Sprintf("%s/%d/%s/", repoFullName, pullNum, headCommit)
+}
+
+// Key returns the key of the artifact filename of the project at repoRelDir
+// and workspace under pullPrefix.
+func Key(pullPrefix string, workspace string, repoRelDir string, filename string) string {
+	return pullPrefix + path.Join(workspace, repoRelDir, filename)
+}
+
+// ParseKey returns the workspace and the path, relative to the repo root, of
+// the artifact at key under pullPrefix. It returns false if key isn't under
+// pullPrefix.
+func ParseKey(pullPrefix string, key string) (workspace string, relPath string, ok bool) {
+	if !strings.HasPrefix(key, pullPrefix) {
+		return "", "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(key, pullPrefix), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
@@ -0,0 +1,33 @@
+package artifacts_test
+
+import (
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/artifacts"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestKey(t *testing.T) {
+	prefix := artifacts.PullPrefix("owner/repo", 1, "sha")
+	Equals(t, "owner/repo/1/sha/", prefix)
+	Equals(t, "owner/repo/1/sha/default/default.tfplan", artifacts.Key(prefix, "default", ".", "default.tfplan"))
+	key := artifacts.Key(prefix, "staging", "modules/vpc", "staging.tfplan")
+	Equals(t, "owner/repo/1/sha/staging/modules/vpc/staging.tfplan", key)
+
+	workspace, relPath, ok := artifacts.ParseKey(prefix, key)
+	Assert(t, ok, "exp key to parse")
+	Equals(t, "staging", workspace)
+	Equals(t, "modules/vpc/staging.tfplan", relPath)
+}
+
+func TestParseKey_Invalid(t *testing.T) {
+	prefix := artifacts.PullPrefix("owner/repo", 1, "sha")
+	for _, key := range []string{
+		"owner/repo/2/sha/default/default.tfplan",
+		"owner/repo/1/sha/default",
+		"owner/repo/1/sha//default.tfplan",
+	} {
+		_, _, ok := artifacts.ParseKey(prefix, key)
+		Assert(t, !ok, "exp %q not to parse", key)
+	}
+}
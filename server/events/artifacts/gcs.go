@@ -0,0 +1,281 @@
+package artifacts
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultGCSEndpoint is the URL of the Cloud Storage JSON API.
+const DefaultGCSEndpoint = "https://storage.googleapis.com"
+
+// gcsScope is the OAuth scope that can read and write objects.
+const gcsScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+// gcsMetadataTokenURL is where GCE and GKE workload identity serve tokens for
+// the instance's service account.
+const gcsMetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// GCSStore stores artifacts in a Google Cloud Storage bucket with the JSON
+// API. See https://cloud.google.com/storage/docs/json_api.
+type GCSStore struct {
+	Bucket string
+	// Prefix is prepended to every key, ex. atlantis/.
+	Prefix string
+	// Endpoint defaults to DefaultGCSEndpoint.
+	Endpoint string
+	// Tokens authorize the requests. They default to GCSDefaultTokens.
+	Tokens GCSTokenSource
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// GCSTokenSource returns the OAuth access token to authorize a request with.
+type GCSTokenSource interface {
+	Token() (string, error)
+}
+
+// GCSDefaultTokens gets tokens for the service account whose key file is at
+// GOOGLE_APPLICATION_CREDENTIALS or, if it isn't set, for the instance's
+// service account from the metadata server.
+type GCSDefaultTokens struct {
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// Token returns a cached token or gets a new one if it's about to expire.
+func (g *GCSDefaultTokens) Token() (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if time.Now().Before(g.expires.Add(-5 * time.Minute)) {
+		return g.token, nil
+	}
+	client := g.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var req *http.Request
+	var err error
+	if keyFile := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); keyFile != "" {
+		req, err = serviceAccountTokenRequest(keyFile)
+	} else {
+		req, err = http.NewRequest("GET", gcsMetadataTokenURL, nil)
+		if err == nil {
+			req.Header.Set("Metadata-Flavor", "Google")
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "getting access token")
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if err := checkStatus(resp, http.StatusOK); err != nil {
+		return "", errors.Wrap(err, "getting access token")
+	}
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", errors.Wrap(err, "parsing access token")
+	}
+	g.token = body.AccessToken
+	g.expires = time.Now().Add(time.Duration(body.ExpiresIn) * time.Second)
+	return g.token, nil
+}
+
+// serviceAccountTokenRequest returns the request that exchanges a JWT signed
+// with the service account key in keyFile for an access token.
+// See https://developers.google.com/identity/protocols/oauth2/service-account#httprest.
+func serviceAccountTokenRequest(keyFile string) (*http.Request, error) {
+	keyJSON, err := ioutil.ReadFile(keyFile) // nolint: gosec
+	if err != nil {
+		return nil, errors.Wrap(err, "reading service account key")
+	}
+	var sa struct {
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(keyJSON, &sa); err != nil {
+		return nil, errors.Wrapf(err, "parsing service account key %s", keyFile)
+	}
+	block, _ := pem.Decode([]byte(sa.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("service account key %s has no PEM private key", keyFile)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing private key of %s", keyFile)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key of %s isn't an RSA key", keyFile)
+	}
+
+	now := time.Now()
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return nil, err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   sa.ClientEmail,
+		"scope": gcsScope,
+		"aud":   sa.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return nil, errors.Wrap(err, "signing service account JWT")
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {unsigned + "." + base64.RawURLEncoding.EncodeToString(sig)},
+	}
+	req, err := http.NewRequest("POST", sa.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req, nil
+}
+
+// Put uploads contents to key.
+func (g *GCSStore) Put(key string, contents []byte) error {
+	query := url.Values{"uploadType": {"media"}, "name": {g.Prefix + key}}
+	resp, err := g.do("POST", fmt.Sprintf("/upload/storage/v1/b/%s/o?%s", url.PathEscape(g.Bucket), query.Encode()), contents)
+	if err != nil {
+		return errors.Wrapf(err, "uploading %s", key)
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	return errors.Wrapf(checkStatus(resp, http.StatusOK), "uploading %s", key)
+}
+
+// Get downloads key.
+func (g *GCSStore) Get(key string) ([]byte, error) {
+	resp, err := g.do("GET", g.objectPath(key)+"?alt=media", nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "downloading %s", key)
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err := checkStatus(resp, http.StatusOK); err != nil {
+		return nil, errors.Wrapf(err, "downloading %s", key)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// List lists the keys under prefix.
+func (g *GCSStore) List(prefix string) ([]string, error) {
+	var keys []string
+	pageToken := ""
+	for {
+		query := url.Values{"prefix": {g.Prefix + prefix}, "fields": {"items(name),nextPageToken"}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		resp, err := g.do("GET", fmt.Sprintf("/storage/v1/b/%s/o?%s", url.PathEscape(g.Bucket), query.Encode()), nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "listing %s", prefix)
+		}
+		if err := checkStatus(resp, http.StatusOK); err != nil {
+			resp.Body.Close() // nolint: errcheck
+			return nil, errors.Wrapf(err, "listing %s", prefix)
+		}
+		var result struct {
+			Items []struct {
+				Name string `json:"name"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close() // nolint: errcheck
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing listing of %s", prefix)
+		}
+		for _, item := range result.Items {
+			keys = append(keys, strings.TrimPrefix(item.Name, g.Prefix))
+		}
+		if result.NextPageToken == "" {
+			return keys, nil
+		}
+		pageToken = result.NextPageToken
+	}
+}
+
+// Delete deletes key.
+func (g *GCSStore) Delete(key string) error {
+	resp, err := g.do("DELETE", g.objectPath(key), nil)
+	if err != nil {
+		return errors.Wrapf(err, "deleting %s", key)
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	return errors.Wrapf(checkStatus(resp, http.StatusNoContent, http.StatusOK, http.StatusNotFound), "deleting %s", key)
+}
+
+// objectPath returns the API path of the object at key. Object names are
+// escaped as a single path segment, including their /'s.
+func (g *GCSStore) objectPath(key string) string {
+	return fmt.Sprintf("/storage/v1/b/%s/o/%s", url.PathEscape(g.Bucket), url.PathEscape(g.Prefix+key))
+}
+
+// do sends an authorized request to path, which includes its query, under the
+// API's endpoint.
+func (g *GCSStore) do(method string, path string, body []byte) (*http.Response, error) {
+	endpoint := g.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultGCSEndpoint
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(endpoint, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	tokens := g.Tokens
+	if tokens == nil {
+		tokens = &GCSDefaultTokens{HTTPClient: g.HTTPClient}
+	}
+	token, err := tokens.Token()
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	client := g.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
@@ -0,0 +1,98 @@
+package artifacts_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/artifacts"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+type staticGCSToken string
+
+func (s staticGCSToken) Token() (string, error) {
+	return string(s), nil
+}
+
+// fakeGCS serves the subset of the Cloud Storage JSON API the store uses for
+// bucket from objects.
+func fakeGCS(t *testing.T, bucket string, objects map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Equals(t, "Bearer token", r.Header.Get("Authorization"))
+		objectsPath := "/storage/v1/b/" + bucket + "/o"
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/upload"+objectsPath:
+			Equals(t, "media", r.URL.Query().Get("uploadType"))
+			body, err := ioutil.ReadAll(r.Body)
+			Ok(t, err)
+			objects[r.URL.Query().Get("name")] = string(body)
+			fmt.Fprint(w, "{}")
+		case r.Method == "GET" && r.URL.Path == objectsPath:
+			var items []map[string]string
+			for k := range objects {
+				if strings.HasPrefix(k, r.URL.Query().Get("prefix")) {
+					items = append(items, map[string]string{"name": k})
+				}
+			}
+			sort.Slice(items, func(i, j int) bool { return items[i]["name"] < items[j]["name"] })
+			Ok(t, json.NewEncoder(w).Encode(map[string]interface{}{"items": items}))
+		case strings.HasPrefix(r.URL.EscapedPath(), objectsPath+"/"):
+			name, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), objectsPath+"/"))
+			Ok(t, err)
+			contents, ok := objects[name]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if r.Method == "DELETE" {
+				delete(objects, name)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			Equals(t, "media", r.URL.Query().Get("alt"))
+			fmt.Fprint(w, contents)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+	}))
+}
+
+func TestGCSStore(t *testing.T) {
+	objects := map[string]string{"other/key": "other"}
+	server := fakeGCS(t, "bucket", objects)
+	defer server.Close()
+	store := &artifacts.GCSStore{
+		Bucket:   "bucket",
+		Prefix:   "atlantis/",
+		Endpoint: server.URL,
+		Tokens:   staticGCSToken("token"),
+	}
+
+	Ok(t, store.Put("owner/repo/1/sha/default/dir/default.tfplan", []byte("plan")))
+	Ok(t, store.Put("owner/repo/1/sha/default/default.tfplan", []byte("root plan")))
+	Equals(t, "plan", objects["atlantis/owner/repo/1/sha/default/dir/default.tfplan"])
+
+	contents, err := store.Get("owner/repo/1/sha/default/dir/default.tfplan")
+	Ok(t, err)
+	Equals(t, "plan", string(contents))
+	contents, err = store.Get("owner/repo/1/sha/default/missing.tfplan")
+	Ok(t, err)
+	Assert(t, contents == nil, "exp nil for missing object")
+
+	keys, err := store.List("owner/repo/1/")
+	Ok(t, err)
+	Equals(t, []string{"owner/repo/1/sha/default/default.tfplan", "owner/repo/1/sha/default/dir/default.tfplan"}, keys)
+
+	Ok(t, store.Delete("owner/repo/1/sha/default/default.tfplan"))
+	Ok(t, store.Delete("owner/repo/1/sha/default/default.tfplan"))
+	keys, err = store.List("owner/repo/1/")
+	Ok(t, err)
+	Equals(t, []string{"owner/repo/1/sha/default/dir/default.tfplan"}, keys)
+}
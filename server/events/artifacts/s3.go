@@ -0,0 +1,361 @@
+package artifacts
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// S3Store stores artifacts in an S3 bucket, or a bucket on an S3 compatible
+// service like MinIO. Requests are signed with AWS Signature Version 4.
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-authenticating-requests.html.
+type S3Store struct {
+	Bucket string
+	Region string
+	// Prefix is prepended to every key, ex. atlantis/.
+	Prefix string
+	// Endpoint is the URL of an S3 compatible service, ex.
+	// https://minio.example.com. Its buckets are addressed by path. If empty,
+	// the bucket is addressed by hostname on AWS.
+	Endpoint string
+	// Credentials sign the requests. They default to S3EnvCredentials.
+	Credentials S3CredentialsProvider
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// now is overridden in tests.
+	now func() time.Time
+}
+
+// S3Credentials are the AWS credentials requests are signed with.
+type S3Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is set for temporary credentials.
+	SessionToken string
+}
+
+// S3CredentialsProvider returns the credentials to sign a request with.
+type S3CredentialsProvider interface {
+	Credentials() (S3Credentials, error)
+}
+
+// S3EnvCredentials reads credentials from the same environment variables as
+// the AWS CLI. If AWS_WEB_IDENTITY_TOKEN_FILE and AWS_ROLE_ARN are set, ex. by
+// IAM roles for service accounts on EKS, it assumes the role with the token.
+// Otherwise it uses AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN.
+type S3EnvCredentials struct {
+	// Region is the region of the STS endpoint roles are assumed with.
+	Region string
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	mu      sync.Mutex
+	cached  S3Credentials
+	expires time.Time
+}
+
+// Credentials returns the credentials from the environment.
+func (e *S3EnvCredentials) Credentials() (S3Credentials, error) {
+	tokenFile, roleARN := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"), os.Getenv("AWS_ROLE_ARN")
+	if tokenFile == "" || roleARN == "" {
+		creds := S3Credentials{
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}
+		if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+			return creds, errors.New("no AWS credentials: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or AWS_WEB_IDENTITY_TOKEN_FILE and AWS_ROLE_ARN")
+		}
+		return creds, nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// Refresh a few minutes early so the credentials don't expire mid-request.
+	if time.Now().Before(e.expires.Add(-5 * time.Minute)) {
+		return e.cached, nil
+	}
+	token, err := ioutil.ReadFile(tokenFile) // nolint: gosec
+	if err != nil {
+		return S3Credentials{}, errors.Wrap(err, "reading web identity token")
+	}
+	query := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {roleARN},
+		"RoleSessionName":  {"atlantis"},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	stsURL := fmt.Sprintf("https://sts.%s.amazonaws.com/?%s", e.Region, query.Encode())
+	client := e.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Get(stsURL)
+	if err != nil {
+		return S3Credentials{}, errors.Wrap(err, "assuming role")
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return S3Credentials{}, errors.Wrap(err, "reading response from STS")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return S3Credentials{}, fmt.Errorf("assuming role %s: unexpected status %d: %s", roleARN, resp.StatusCode, string(body))
+	}
+	var result struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return S3Credentials{}, errors.Wrap(err, "parsing response from STS")
+	}
+	e.cached = S3Credentials{
+		AccessKeyID:     result.Credentials.AccessKeyID,
+		SecretAccessKey: result.Credentials.SecretAccessKey,
+		SessionToken:    result.Credentials.SessionToken,
+	}
+	e.expires = result.Credentials.Expiration
+	return e.cached, nil
+}
+
+// Put uploads contents to key.
+func (s *S3Store) Put(key string, contents []byte) error {
+	resp, err := s.do("PUT", s.Prefix+key, nil, contents)
+	if err != nil {
+		return errors.Wrapf(err, "uploading %s", key)
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	return errors.Wrapf(checkStatus(resp, http.StatusOK), "uploading %s", key)
+}
+
+// Get downloads key.
+func (s *S3Store) Get(key string) ([]byte, error) {
+	resp, err := s.do("GET", s.Prefix+key, nil, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "downloading %s", key)
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err := checkStatus(resp, http.StatusOK); err != nil {
+		return nil, errors.Wrapf(err, "downloading %s", key)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// List lists the keys under prefix with ListObjectsV2.
+func (s *S3Store) List(prefix string) ([]string, error) {
+	var keys []string
+	continuation := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {s.Prefix + prefix}}
+		if continuation != "" {
+			query.Set("continuation-token", continuation)
+		}
+		resp, err := s.do("GET", "", query, nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "listing %s", prefix)
+		}
+		if err := checkStatus(resp, http.StatusOK); err != nil {
+			resp.Body.Close() // nolint: errcheck
+			return nil, errors.Wrapf(err, "listing %s", prefix)
+		}
+		var result struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close() // nolint: errcheck
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing listing of %s", prefix)
+		}
+		for _, c := range result.Contents {
+			keys = append(keys, strings.TrimPrefix(c.Key, s.Prefix))
+		}
+		if !result.IsTruncated {
+			return keys, nil
+		}
+		continuation = result.NextContinuationToken
+	}
+}
+
+// Delete deletes key.
+func (s *S3Store) Delete(key string) error {
+	resp, err := s.do("DELETE", s.Prefix+key, nil, nil)
+	if err != nil {
+		return errors.Wrapf(err, "deleting %s", key)
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	return errors.Wrapf(checkStatus(resp, http.StatusNoContent, http.StatusOK), "deleting %s", key)
+}
+
+// do sends a signed request for the object at key, or the bucket if key is
+// empty.
+func (s *S3Store) do(method string, key string, query url.Values, body []byte) (*http.Response, error) {
+	var u *url.URL
+	var err error
+	if s.Endpoint != "" {
+		u, err = url.Parse(strings.TrimSuffix(s.Endpoint, "/") + "/" + s.Bucket + "/")
+	} else {
+		u, err = url.Parse(fmt.Sprintf("https://%s.s3.%s.amazonaws.com/", s.Bucket, s.Region))
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The path and query are sent encoded the same way they're signed.
+	u.Path += key
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	provider := s.Credentials
+	if provider == nil {
+		provider = &S3EnvCredentials{Region: s.Region, HTTPClient: s.HTTPClient}
+	}
+	creds, err := provider.Credentials()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	signV4(req, body, creds, s.Region, "s3", now().UTC())
+	client := s.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// signV4 signs req, whose body is body, for service in region with AWS
+// Signature Version 4. Every header already set on req is signed along with
+// the host.
+func signV4(req *http.Request, body []byte, creds S3Credentials, region string, service string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalQuery returns query sorted by key with its keys and values
+// encoded as Signature Version 4 expects.
+func canonicalQuery(query url.Values) string {
+	var keys []string
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, k := range keys {
+		values := query[k]
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes every byte of s except the unreserved characters
+// and, unless encodeSlash is true, '/'.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data)) // nolint: errcheck
+	return h.Sum(nil)
+}
+
+// checkStatus returns an error with the response's body if its status isn't
+// one of okStatuses.
+func checkStatus(resp *http.Response, okStatuses ...int) error {
+	for _, ok := range okStatuses {
+		if resp.StatusCode == ok {
+			return nil
+		}
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,108 @@
+package artifacts_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/artifacts"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+type staticS3Credentials artifacts.S3Credentials
+
+func (s staticS3Credentials) Credentials() (artifacts.S3Credentials, error) {
+	return artifacts.S3Credentials(s), nil
+}
+
+// fakeS3 serves the subset of the S3 API the store uses for bucket from
+// objects.
+func fakeS3(t *testing.T, bucket string, objects map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Assert(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=id/"), "exp signed request, got %q", r.Header.Get("Authorization"))
+		key := strings.TrimPrefix(r.URL.Path, "/"+bucket+"/")
+		switch {
+		case r.Method == "GET" && key == "":
+			var keys []string
+			for k := range objects {
+				if strings.HasPrefix(k, r.URL.Query().Get("prefix")) {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+			fmt.Fprint(w, "<ListBucketResult>")
+			for _, k := range keys {
+				fmt.Fprintf(w, "<Contents><Key>%s</Key></Contents>", k)
+			}
+			fmt.Fprint(w, "<IsTruncated>false</IsTruncated></ListBucketResult>")
+		case r.Method == "GET":
+			contents, ok := objects[key]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			fmt.Fprint(w, contents)
+		case r.Method == "PUT":
+			body, err := ioutil.ReadAll(r.Body)
+			Ok(t, err)
+			objects[key] = string(body)
+		case r.Method == "DELETE":
+			delete(objects, key)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+	}))
+}
+
+func TestS3Store(t *testing.T) {
+	objects := map[string]string{"other/key": "other"}
+	server := fakeS3(t, "bucket", objects)
+	defer server.Close()
+	store := &artifacts.S3Store{
+		Bucket:      "bucket",
+		Region:      "us-east-1",
+		Prefix:      "atlantis/",
+		Endpoint:    server.URL,
+		Credentials: staticS3Credentials{AccessKeyID: "id", SecretAccessKey: "secret"},
+	}
+
+	Ok(t, store.Put("owner/repo/1/sha/default/dir/default.tfplan", []byte("plan")))
+	Ok(t, store.Put("owner/repo/1/sha/default/default.tfplan", []byte("root plan")))
+	Equals(t, "plan", objects["atlantis/owner/repo/1/sha/default/dir/default.tfplan"])
+
+	contents, err := store.Get("owner/repo/1/sha/default/dir/default.tfplan")
+	Ok(t, err)
+	Equals(t, "plan", string(contents))
+	contents, err = store.Get("owner/repo/1/sha/default/missing.tfplan")
+	Ok(t, err)
+	Assert(t, contents == nil, "exp nil for missing object")
+
+	keys, err := store.List("owner/repo/1/")
+	Ok(t, err)
+	Equals(t, []string{"owner/repo/1/sha/default/default.tfplan", "owner/repo/1/sha/default/dir/default.tfplan"}, keys)
+
+	Ok(t, store.Delete("owner/repo/1/sha/default/default.tfplan"))
+	keys, err = store.List("owner/repo/1/")
+	Ok(t, err)
+	Equals(t, []string{"owner/repo/1/sha/default/dir/default.tfplan"}, keys)
+}
+
+func TestS3Store_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, "<Error><Code>AccessDenied</Code></Error>")
+	}))
+	defer server.Close()
+	store := &artifacts.S3Store{
+		Bucket:      "bucket",
+		Endpoint:    server.URL,
+		Credentials: staticS3Credentials{AccessKeyID: "id", SecretAccessKey: "secret"},
+	}
+	err := store.Put("key", []byte("plan"))
+	ErrEquals(t, "uploading key: unexpected status 403: <Error><Code>AccessDenied</Code></Error>", err)
+}
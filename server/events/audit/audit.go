@@ -0,0 +1,83 @@
+// Package audit keeps an append-only log of the commands Atlantis runs so
+// there's a record of who planned, applied and unlocked what.
+package audit
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// DefaultListLimit is how many events List returns if no limit is given.
+const DefaultListLimit = 100
+
+// MaxListLimit is the most events List returns at once.
+const MaxListLimit = 1000
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_store.go Store
+
+// Store persists the audit log. It's implemented by the locking backends so
+// the log is stored in the same database as the locks. Events are never
+// updated or deleted.
+type Store interface {
+	// AppendAuditEvent adds event to the log. Its ID must be set.
+	AppendAuditEvent(event models.AuditEvent) error
+	// ListAuditEvents returns up to limit events with IDs after afterID,
+	// oldest first. If afterID is empty, it starts from the oldest event.
+	ListAuditEvents(afterID string, limit int) ([]models.AuditEvent, error)
+}
+
+// Sink is somewhere, other than the Store, that events are copied to as
+// they're recorded, ex. a file or syslog.
+type Sink interface {
+	Write(event models.AuditEvent) error
+}
+
+// Log records events to its Store and Sinks.
+type Log struct {
+	Store  Store
+	Sinks  []Sink
+	Logger *logging.SimpleLogger
+}
+
+// Record sets event's ID, and its StartedAt if it isn't set, and then saves it
+// to the Store and writes it to each of the Sinks. Failures are logged rather
+// than returned because they shouldn't stop the command from running.
+func (l *Log) Record(event models.AuditEvent) {
+	if event.StartedAt.IsZero() {
+		event.StartedAt = time.Now()
+	}
+	event.ID = newID(event.StartedAt)
+	if err := l.Store.AppendAuditEvent(event); err != nil {
+		l.Logger.Err("saving audit event: %s", err)
+	}
+	for _, sink := range l.Sinks {
+		if err := sink.Write(event); err != nil {
+			l.Logger.Err("writing audit event: %s", err)
+		}
+	}
+}
+
+// List returns up to limit events after the event with ID afterID, oldest
+// first. limit defaults to DefaultListLimit and is capped at MaxListLimit.
+func (l *Log) List(afterID string, limit int) ([]models.AuditEvent, error) {
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	if limit > MaxListLimit {
+		limit = MaxListLimit
+	}
+	return l.Store.ListAuditEvents(afterID, limit)
+}
+
+// newID returns an ID that sorts in the order the events started. The random
+// suffix keeps IDs unique when multiple Atlantis instances share a Store.
+func newID(startedAt time.Time) string {
+	suffix := make([]byte, 4)
+	rand.Read(suffix) // nolint: errcheck
+	return fmt.Sprintf("%019d-%s", startedAt.UnixNano(), hex.EncodeToString(suffix))
+}
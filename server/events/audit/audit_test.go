@@ -0,0 +1,77 @@
+package audit_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events/audit"
+	"github.com/runatlantis/atlantis/server/events/audit/mocks"
+	"github.com/runatlantis/atlantis/server/events/audit/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+type sliceSink struct {
+	events []models.AuditEvent
+}
+
+func (s *sliceSink) Write(event models.AuditEvent) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+func TestRecord(t *testing.T) {
+	RegisterMockTestingT(t)
+	store := mocks.NewMockStore()
+	sink := &sliceSink{}
+	l := audit.Log{Store: store, Sinks: []audit.Sink{sink}, Logger: logging.NewNoopLogger()}
+	startedAt := time.Unix(1, 0)
+	l.Record(models.AuditEvent{Command: "plan", StartedAt: startedAt})
+	l.Record(models.AuditEvent{Command: "plan", StartedAt: startedAt})
+
+	Equals(t, 2, len(sink.events))
+	first, second := sink.events[0], sink.events[1]
+	Assert(t, first.ID != second.ID, "exp IDs to be unique but both were %q", first.ID)
+	Equals(t, "0000000001000000000-", first.ID[:20])
+	store.VerifyWasCalledOnce().AppendAuditEvent(first)
+	store.VerifyWasCalledOnce().AppendAuditEvent(second)
+}
+
+func TestRecord_SetsStartedAt(t *testing.T) {
+	RegisterMockTestingT(t)
+	sink := &sliceSink{}
+	l := audit.Log{Store: mocks.NewMockStore(), Sinks: []audit.Sink{sink}, Logger: logging.NewNoopLogger()}
+	l.Record(models.AuditEvent{Command: "unlock"})
+	Assert(t, time.Since(sink.events[0].StartedAt) < time.Minute, "exp StartedAt to be set")
+}
+
+// Sinks should still get the event if it can't be saved.
+func TestRecord_StoreErr(t *testing.T) {
+	RegisterMockTestingT(t)
+	store := mocks.NewMockStore()
+	When(store.AppendAuditEvent(matchers.AnyModelsAuditEvent())).ThenReturn(errors.New("err"))
+	sink := &sliceSink{}
+	l := audit.Log{Store: store, Sinks: []audit.Sink{sink}, Logger: logging.NewNoopLogger()}
+	l.Record(models.AuditEvent{Command: "apply"})
+	Equals(t, 1, len(sink.events))
+}
+
+func TestList_Limits(t *testing.T) {
+	RegisterMockTestingT(t)
+	cases := map[int]int{
+		0:    audit.DefaultListLimit,
+		-1:   audit.DefaultListLimit,
+		10:   10,
+		5000: audit.MaxListLimit,
+	}
+	for limit, exp := range cases {
+		store := mocks.NewMockStore()
+		l := audit.Log{Store: store}
+		_, err := l.List("id", limit)
+		Ok(t, err)
+		store.VerifyWasCalledOnce().ListAuditEvents("id", exp)
+	}
+}
@@ -0,0 +1,83 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/syslog"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// syslogTag is the tag of the messages sent to syslog.
+const syslogTag = "atlantis"
+
+// FileSink appends each event to a file as a line of JSON.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileSink opens path for appending, creating it if it doesn't exist.
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening audit log file")
+	}
+	return &FileSink{file: f}, nil
+}
+
+func (f *FileSink) Write(event models.AuditEvent) error {
+	serialized, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "serializing audit event")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, err = f.file.Write(append(serialized, '\n'))
+	return errors.Wrapf(err, "writing to %s", f.file.Name())
+}
+
+// SyslogSink sends each event to syslog as a JSON message.
+type SyslogSink struct {
+	writer *syslog.Writer
+}
+
+// NewSyslogSink connects to the syslog server at addr. See
+// ParseSyslogAddr for its format.
+func NewSyslogSink(addr string) (*SyslogSink, error) {
+	network, raddr, err := ParseSyslogAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	w, err := syslog.Dial(network, raddr, syslog.LOG_INFO|syslog.LOG_AUTH, syslogTag)
+	if err != nil {
+		return nil, errors.Wrap(err, "connecting to syslog")
+	}
+	return &SyslogSink{writer: w}, nil
+}
+
+func (s *SyslogSink) Write(event models.AuditEvent) error {
+	serialized, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "serializing audit event")
+	}
+	return errors.Wrap(s.writer.Info(string(serialized)), "writing to syslog")
+}
+
+// ParseSyslogAddr splits addr into the network and address to pass to
+// syslog.Dial. addr is either "local", for the local syslog daemon, or
+// udp://host:port or tcp://host:port.
+func ParseSyslogAddr(addr string) (network string, raddr string, err error) {
+	if addr == "local" {
+		return "", "", nil
+	}
+	split := strings.SplitN(addr, "://", 2)
+	if len(split) != 2 || (split[0] != "udp" && split[0] != "tcp") || split[1] == "" {
+		return "", "", fmt.Errorf("%q must be \"local\", udp://host:port or tcp://host:port", addr)
+	}
+	return split[0], split[1], nil
+}
@@ -0,0 +1,82 @@
+package audit_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/audit"
+	"github.com/runatlantis/atlantis/server/events/models"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestFileSink(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	path := filepath.Join(tmp, "audit.log")
+	sink, err := audit.NewFileSink(path)
+	Ok(t, err)
+	Ok(t, sink.Write(models.AuditEvent{ID: "1", Command: "plan"}))
+
+	t.Log("opening the file again should append to it")
+	sink, err = audit.NewFileSink(path)
+	Ok(t, err)
+	Ok(t, sink.Write(models.AuditEvent{ID: "2", Command: "apply"}))
+
+	contents, err := ioutil.ReadFile(path)
+	Ok(t, err)
+	lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	Equals(t, 2, len(lines))
+	var event models.AuditEvent
+	Ok(t, json.Unmarshal([]byte(lines[1]), &event))
+	Equals(t, "apply", event.Command)
+}
+
+func TestSyslogSink(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	Ok(t, err)
+	defer conn.Close() // nolint: errcheck
+	sink, err := audit.NewSyslogSink("udp://" + conn.LocalAddr().String())
+	Ok(t, err)
+	Ok(t, sink.Write(models.AuditEvent{ID: "1", Command: "plan", User: "lkysow"}))
+
+	buf := make([]byte, 4096)
+	Ok(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	Ok(t, err)
+	msg := string(buf[:n])
+	Assert(t, strings.Contains(msg, "atlantis"), "exp the message to be tagged but got %q", msg)
+	Assert(t, strings.Contains(msg, `"user":"lkysow"`), "exp the message to contain the event but got %q", msg)
+}
+
+func TestParseSyslogAddr(t *testing.T) {
+	cases := []struct {
+		addr       string
+		expNetwork string
+		expRaddr   string
+		expErr     bool
+	}{
+		{"local", "", "", false},
+		{"udp://syslog:514", "udp", "syslog:514", false},
+		{"tcp://syslog:514", "tcp", "syslog:514", false},
+		{"syslog:514", "", "", true},
+		{"http://syslog:514", "", "", true},
+		{"udp://", "", "", true},
+	}
+	for _, c := range cases {
+		t.Run(c.addr, func(t *testing.T) {
+			network, raddr, err := audit.ParseSyslogAddr(c.addr)
+			if c.expErr {
+				Assert(t, err != nil, "exp err")
+				return
+			}
+			Ok(t, err)
+			Equals(t, c.expNetwork, network)
+			Equals(t, c.expRaddr, raddr)
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package events
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+)
+
+// Automerger merges pull requests once all of their plans have been applied.
+type Automerger struct {
+	VCSClient         vcs.ClientProxy
+	WorkingDir        WorkingDir
+	PendingPlanFinder *PendingPlanFinder
+	// Enabled is true if all pull requests are merged, ex. with --automerge.
+	// If false, only the ones whose atlantis.yaml sets automerge are.
+	Enabled bool
+	// MergeMethod is how pull requests are merged, one of vcs.MergeMethods.
+	// Defaults to vcs.MergeCommitMethod.
+	MergeMethod string
+	// DeleteSourceBranch is true if pull requests' source branches are
+	// deleted once they're merged, ex. with --automerge-delete-source-branch.
+	// If false, only the ones whose atlantis.yaml sets
+	// delete_source_branch_on_merge are.
+	DeleteSourceBranch bool
+}
+
+// Automerge merges ctx's pull request if automerge is enabled for it, res,
+// the result of applying projectCmds, has no errors and there are no plans
+// left to apply. It comments that it's merging the pull request or why it
+// couldn't.
+func (a *Automerger) Automerge(ctx *CommandContext, projectCmds []models.ProjectCommandContext, res CommandResult) {
+	if !a.enabled(projectCmds) || len(projectCmds) == 0 || res.HasErrors() {
+		return
+	}
+	pending, err := a.pendingPlans(ctx)
+	if err != nil {
+		ctx.Log.Warn("not automerging since we couldn't tell if all plans have been applied: %s", err)
+		return
+	}
+	if pending > 0 {
+		ctx.Log.Info("not automerging since %d plan(s) haven't been applied", pending)
+		return
+	}
+
+	method := a.MergeMethod
+	if method == "" {
+		method = vcs.MergeCommitMethod
+	}
+	opts := vcs.MergeOptions{
+		Method:             method,
+		DeleteSourceBranch: a.deleteSourceBranch(ctx, projectCmds),
+	}
+	ctx.Log.Info("automerging pull request with method %q", method)
+	if err := a.VCSClient.CreateComment(ctx.BaseRepo, ctx.Pull.Num, "Automatically merging because all plans have been successfully applied."); err != nil {
+		ctx.Log.Err("unable to comment: %s", err)
+	}
+	if err := a.VCSClient.MergePull(ctx.BaseRepo, ctx.Pull, opts); err != nil {
+		ctx.Log.Warn("automerging failed: %s", err)
+		comment := fmt.Sprintf("Automerging failed:\n```\n%s\n```", err)
+		if err := a.VCSClient.CreateComment(ctx.BaseRepo, ctx.Pull.Num, comment); err != nil {
+			ctx.Log.Err("unable to comment: %s", err)
+		}
+	}
+}
+
+// enabled returns true if the pull request that projectCmds were run for
+// should be merged.
+func (a *Automerger) enabled(projectCmds []models.ProjectCommandContext) bool {
+	if a.Enabled {
+		return true
+	}
+	for _, pCmd := range projectCmds {
+		if pCmd.GlobalConfig != nil && pCmd.GlobalConfig.Automerge {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteSourceBranch returns true if ctx's pull request's source branch should
+// be deleted once it's merged. Branches in forks are never deleted since
+// they're not ours to delete.
+func (a *Automerger) deleteSourceBranch(ctx *CommandContext, projectCmds []models.ProjectCommandContext) bool {
+	enabled := a.DeleteSourceBranch
+	for _, pCmd := range projectCmds {
+		if pCmd.GlobalConfig != nil && pCmd.GlobalConfig.DeleteSourceBranchOnMerge {
+			enabled = true
+		}
+	}
+	if !enabled {
+		return false
+	}
+	if ctx.HeadRepo.FullName != ctx.BaseRepo.FullName {
+		ctx.Log.Info("not deleting source branch %q since it's in the fork %s", ctx.Pull.Branch, ctx.HeadRepo.FullName)
+		return false
+	}
+	return true
+}
+
+// pendingPlans returns the number of plans for ctx's pull request that
+// haven't been applied.
+func (a *Automerger) pendingPlans(ctx *CommandContext) (int, error) {
+	pullDir, err := a.WorkingDir.GetPullDir(ctx.BaseRepo, ctx.Pull)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "getting pull dir")
+	}
+	plans, err := a.PendingPlanFinder.Find(pullDir)
+	if err != nil {
+		return 0, errors.Wrap(err, "finding pending plans")
+	}
+	return len(plans), nil
+}
@@ -0,0 +1,124 @@
+package events_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/models/fixtures"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+	vcsmocks "github.com/runatlantis/atlantis/server/events/vcs/mocks"
+	"github.com/runatlantis/atlantis/server/events/vcs/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func setupAutomerger(t *testing.T, pullDirFiles map[string]interface{}) (*events.Automerger, *vcsmocks.MockClientProxy, *events.CommandContext, func()) {
+	RegisterMockTestingT(t)
+	pullDir, cleanup := DirStructure(t, pullDirFiles)
+	for dirname, contents := range pullDirFiles {
+		if contents != nil {
+			runCmd(t, filepath.Join(pullDir, dirname), "git", "init")
+		}
+	}
+	workingDir := mocks.NewMockWorkingDir()
+	When(workingDir.GetPullDir(fixtures.GithubRepo, fixtures.Pull)).ThenReturn(pullDir, nil)
+	vcsClient := vcsmocks.NewMockClientProxy()
+	ctx := &events.CommandContext{
+		BaseRepo: fixtures.GithubRepo,
+		HeadRepo: fixtures.GithubRepo,
+		Pull:     fixtures.Pull,
+		Log:      logging.NewNoopLogger(),
+	}
+	return &events.Automerger{
+		VCSClient:         vcsClient,
+		WorkingDir:        workingDir,
+		PendingPlanFinder: &events.PendingPlanFinder{},
+		Enabled:           true,
+	}, vcsClient, ctx, cleanup
+}
+
+var automergeProjectCmds = []models.ProjectCommandContext{{RepoRelDir: ".", Workspace: "default"}}
+
+func TestAutomerger_Automerge(t *testing.T) {
+	a, vcsClient, ctx, cleanup := setupAutomerger(t, nil)
+	defer cleanup()
+	a.Automerge(ctx, automergeProjectCmds, events.CommandResult{ProjectResults: []events.ProjectResult{{ApplySuccess: "success"}}})
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, fixtures.Pull.Num, "Automatically merging because all plans have been successfully applied.")
+	vcsClient.VerifyWasCalledOnce().MergePull(fixtures.GithubRepo, fixtures.Pull, vcs.MergeOptions{Method: "merge"})
+}
+
+func TestAutomerger_AutomergeMethod(t *testing.T) {
+	a, vcsClient, ctx, cleanup := setupAutomerger(t, nil)
+	defer cleanup()
+	a.MergeMethod = "squash"
+	a.Automerge(ctx, automergeProjectCmds, events.CommandResult{})
+	vcsClient.VerifyWasCalledOnce().MergePull(fixtures.GithubRepo, fixtures.Pull, vcs.MergeOptions{Method: "squash"})
+}
+
+func TestAutomerger_EnabledInRepoConfig(t *testing.T) {
+	a, vcsClient, ctx, cleanup := setupAutomerger(t, nil)
+	defer cleanup()
+	a.Enabled = false
+	a.Automerge(ctx, automergeProjectCmds, events.CommandResult{})
+	vcsClient.VerifyWasCalled(Never()).MergePull(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsMergeOptions())
+
+	a.Automerge(ctx, []models.ProjectCommandContext{{GlobalConfig: &valid.Config{Automerge: true}}}, events.CommandResult{})
+	vcsClient.VerifyWasCalledOnce().MergePull(fixtures.GithubRepo, fixtures.Pull, vcs.MergeOptions{Method: "merge"})
+}
+
+func TestAutomerger_ApplyErrors(t *testing.T) {
+	a, vcsClient, ctx, cleanup := setupAutomerger(t, nil)
+	defer cleanup()
+	a.Automerge(ctx, automergeProjectCmds, events.CommandResult{ProjectResults: []events.ProjectResult{{Error: errors.New("err")}}})
+	vcsClient.VerifyWasCalled(Never()).CreateComment(matchers.AnyModelsRepo(), AnyInt(), AnyString())
+	vcsClient.VerifyWasCalled(Never()).MergePull(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsMergeOptions())
+}
+
+func TestAutomerger_PendingPlans(t *testing.T) {
+	a, vcsClient, ctx, cleanup := setupAutomerger(t, map[string]interface{}{
+		"staging": map[string]interface{}{
+			"default.tfplan": nil,
+		},
+	})
+	defer cleanup()
+	a.Automerge(ctx, automergeProjectCmds, events.CommandResult{})
+	vcsClient.VerifyWasCalled(Never()).MergePull(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsMergeOptions())
+}
+
+func TestAutomerger_MergeErr(t *testing.T) {
+	a, vcsClient, ctx, cleanup := setupAutomerger(t, nil)
+	defer cleanup()
+	When(vcsClient.MergePull(fixtures.GithubRepo, fixtures.Pull, vcs.MergeOptions{Method: "merge"})).ThenReturn(errors.New("pull request is not mergeable"))
+	a.Automerge(ctx, automergeProjectCmds, events.CommandResult{})
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, fixtures.Pull.Num, "Automerging failed:\n```\npull request is not mergeable\n```")
+}
+
+func TestAutomerger_DeleteSourceBranch(t *testing.T) {
+	a, vcsClient, ctx, cleanup := setupAutomerger(t, nil)
+	defer cleanup()
+	a.DeleteSourceBranch = true
+	a.Automerge(ctx, automergeProjectCmds, events.CommandResult{})
+	vcsClient.VerifyWasCalledOnce().MergePull(fixtures.GithubRepo, fixtures.Pull, vcs.MergeOptions{Method: "merge", DeleteSourceBranch: true})
+}
+
+func TestAutomerger_DeleteSourceBranchInRepoConfig(t *testing.T) {
+	a, vcsClient, ctx, cleanup := setupAutomerger(t, nil)
+	defer cleanup()
+	a.Automerge(ctx, []models.ProjectCommandContext{{GlobalConfig: &valid.Config{DeleteSourceBranchOnMerge: true}}}, events.CommandResult{})
+	vcsClient.VerifyWasCalledOnce().MergePull(fixtures.GithubRepo, fixtures.Pull, vcs.MergeOptions{Method: "merge", DeleteSourceBranch: true})
+}
+
+func TestAutomerger_DeleteSourceBranchFork(t *testing.T) {
+	a, vcsClient, ctx, cleanup := setupAutomerger(t, nil)
+	defer cleanup()
+	a.DeleteSourceBranch = true
+	ctx.HeadRepo = models.Repo{FullName: "forker/atlantis"}
+	a.Automerge(ctx, automergeProjectCmds, events.CommandResult{})
+	vcsClient.VerifyWasCalledOnce().MergePull(fixtures.GithubRepo, fixtures.Pull, vcs.MergeOptions{Method: "merge"})
+}
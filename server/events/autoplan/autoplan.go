@@ -0,0 +1,25 @@
+// Package autoplan keeps track of the pull requests that autoplanning has been
+// turned off for with atlantis autoplan off, ex. so a noisy refactor can be
+// pushed to without being planned each time.
+package autoplan
+
+import "fmt"
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_store.go Store
+
+// Store persists which pull requests aren't autoplanned. It's implemented by
+// the locking backends so it's stored in the same database as the locks.
+type Store interface {
+	// SetAutoplanDisabled turns autoplanning of the pull off if disabled is
+	// true, or back on if it's false.
+	SetAutoplanDisabled(repoFullName string, pullNum int, disabled bool) error
+	// AutoplanDisabled returns true if autoplanning of the pull is off.
+	AutoplanDisabled(repoFullName string, pullNum int) (bool, error)
+}
+
+// Key returns the key that the setting for the pull is stored under, ex.
+// owner/repo/1. The pull number comes last so keys are unique even though
+// repo names contain slashes.
+func Key(repoFullName string, pullNum int) string {
+	return fmt.Sprintf("%s/%d", repoFullName, pullNum)
+}
@@ -0,0 +1,13 @@
+package autoplan_test
+
+import (
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/autoplan"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestKey(t *testing.T) {
+	Equals(t, "owner/repo/1", autoplan.Key("owner/repo", 1))
+	Equals(t, "group/subgroup/repo/12", autoplan.Key("group/subgroup/repo", 12))
+}
@@ -0,0 +1,296 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+)
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_github_check_run_updater.go GithubCheckRunUpdater
+
+// GithubCheckRunUpdater creates and updates GitHub check runs.
+type GithubCheckRunUpdater interface {
+	// UpdateCheckRun creates or updates the check run named run.Name on the
+	// head commit of pull.
+	UpdateCheckRun(repo models.Repo, pull models.PullRequest, run vcs.CheckRun) error
+}
+
+// ChecksCommitStatusUpdater implements CommitStatusUpdater by reporting
+// commands as GitHub check runs, which have room for the plan's output, a
+// summary of its changes and annotations on the lines terraform errored on.
+// The check runs are named like the statuses Statuses sets. Pulls on other VCS
+// hosts get statuses from Statuses.
+type ChecksCommitStatusUpdater struct {
+	Checks   GithubCheckRunUpdater
+	Statuses *DefaultCommitStatusUpdater
+}
+
+// checkRunID is the external ID of our check runs. It's sent back when a
+// check run is re-run so we know what to plan.
+type checkRunID struct {
+	Command   string `json:"command"`
+	Dir       string `json:"dir,omitempty"`
+	Workspace string `json:"workspace,omitempty"`
+	Project   string `json:"project,omitempty"`
+}
+
+// ParseCheckRunExternalID returns the plan command to run when the check run
+// with externalID is re-run. It plans the check run's project or, for the
+// check run of the whole command, every project.
+func ParseCheckRunExternalID(externalID string) (*CommentCommand, error) {
+	var id checkRunID
+	if err := json.Unmarshal([]byte(externalID), &id); err != nil {
+		return nil, errors.Wrapf(err, "parsing check run external ID %q", externalID)
+	}
+	if id.Command == "" {
+		return nil, fmt.Errorf("check run external ID %q has no command", externalID)
+	}
+	return NewCommentCommand(id.Dir, nil, PlanCommand, false, id.Workspace, id.Project), nil
+}
+
+// Update sets the check run of command.
+func (c *ChecksCommitStatusUpdater) Update(repo models.Repo, pull models.PullRequest, status models.CommitStatus, command CommandName) error {
+	if repo.VCSHost.Type != models.Github {
+		return c.Statuses.Update(repo, pull, status, command)
+	}
+	title := fmt.Sprintf("%s %s", command.TitleString(), strings.Title(status.String()))
+	return c.Checks.UpdateCheckRun(repo, pull, vcs.CheckRun{
+		Name:       c.Statuses.statusSrc(command),
+		Status:     status,
+		Title:      title,
+		Summary:    title,
+		ExternalID: c.externalID(checkRunID{Command: command.String()}),
+	})
+}
+
+// UpdatePlanRequired sets a pending plan check run since the pull can't be
+// applied until it's planned again.
+func (c *ChecksCommitStatusUpdater) UpdatePlanRequired(repo models.Repo, pull models.PullRequest) error {
+	if repo.VCSHost.Type != models.Github {
+		return c.Statuses.UpdatePlanRequired(repo, pull)
+	}
+	return c.Checks.UpdateCheckRun(repo, pull, vcs.CheckRun{
+		Name:       c.Statuses.statusSrc(PlanCommand),
+		Status:     models.PendingCommitStatus,
+		Title:      "Plan Required",
+		Summary:    "New commits were pushed so the plans were discarded. Comment `atlantis plan` or re-run this check to plan again.",
+		ExternalID: c.externalID(checkRunID{Command: PlanCommand.String()}),
+	})
+}
+
+// UpdatePullClosed sets a successful plan check run since there's nothing
+// left to plan or apply.
+func (c *ChecksCommitStatusUpdater) UpdatePullClosed(repo models.Repo, pull models.PullRequest) error {
+	if repo.VCSHost.Type != models.Github {
+		return c.Statuses.UpdatePullClosed(repo, pull)
+	}
+	return c.Checks.UpdateCheckRun(repo, pull, vcs.CheckRun{
+		Name:    c.Statuses.statusSrc(PlanCommand),
+		Status:  models.SuccessCommitStatus,
+		Title:   "Plans Discarded",
+		Summary: "The pull request was closed so its plans were discarded.",
+	})
+}
+
+// UpdateProjectResult sets a check run for each project in res with its
+// output and then one for the command that summarizes them.
+func (c *ChecksCommitStatusUpdater) UpdateProjectResult(ctx *CommandContext, commandName CommandName, res CommandResult) error {
+	if ctx.BaseRepo.VCSHost.Type != models.Github {
+		return c.Statuses.UpdateProjectResult(ctx, commandName, res)
+	}
+	for _, p := range res.ProjectResults {
+		if err := c.Checks.UpdateCheckRun(ctx.BaseRepo, ctx.Pull, c.projectCheckRun(commandName, p)); err != nil {
+			return err
+		}
+	}
+
+	var status models.CommitStatus
+	if res.Error != nil || res.Failure != "" {
+		status = models.FailedCommitStatus
+	} else {
+		var statuses []models.CommitStatus
+		for _, p := range res.ProjectResults {
+			statuses = append(statuses, p.Status())
+		}
+		status = c.Statuses.worstStatus(statuses)
+	}
+	destroys := c.Statuses.thresholdExceededDestroys(res)
+	if status == models.SuccessCommitStatus && destroys > 0 && c.Statuses.FailOnDestroy {
+		status = models.FailedCommitStatus
+	}
+
+	title := fmt.Sprintf("%s %s", commandName.TitleString(), strings.Title(status.String()))
+	if destroys > 0 {
+		title += fmt.Sprintf(": %d to destroy", destroys)
+	}
+	var summary bytes.Buffer
+	if res.Error != nil {
+		fmt.Fprintf(&summary, "**Error**\n```\n%s\n```\n", res.Error)
+	} else if res.Failure != "" {
+		fmt.Fprintf(&summary, "**Failed**: %s\n", res.Failure)
+	}
+	if len(res.ProjectResults) > 0 {
+		summary.WriteString("| Project | Result | Add | Change | Destroy |\n| --- | --- | --- | --- | --- |\n")
+		for _, p := range res.ProjectResults {
+			add, change, destroy := "-", "-", "-"
+			if plan := c.planSummary(p); plan != nil {
+				add, change, destroy = strconv.Itoa(plan.Add), strconv.Itoa(plan.Change), strconv.Itoa(plan.Destroy)
+			}
+			fmt.Fprintf(&summary, "| %s | %s | %s | %s | %s |\n", c.projectLabel(p), strings.Title(p.Status().String()), add, change, destroy)
+		}
+	}
+	if summary.Len() == 0 {
+		summary.WriteString(title)
+	}
+	return c.Checks.UpdateCheckRun(ctx.BaseRepo, ctx.Pull, vcs.CheckRun{
+		Name:       c.Statuses.statusSrc(commandName),
+		Status:     status,
+		Title:      title,
+		Summary:    summary.String(),
+		ExternalID: c.externalID(checkRunID{Command: commandName.String()}),
+	})
+}
+
+// projectCheckRun returns the check run for the project that p is the result
+// of running commandName in.
+func (c *ChecksCommitStatusUpdater) projectCheckRun(commandName CommandName, p ProjectResult) vcs.CheckRun {
+	status := p.Status()
+	if status == models.SuccessCommitStatus && p.PlanSuccess != nil && p.PlanSuccess.DestroyThresholdExceeded && c.Statuses.FailOnDestroy {
+		status = models.FailedCommitStatus
+	}
+	run := vcs.CheckRun{
+		Name:   c.Statuses.projectStatusSrc(commandName, p),
+		Status: status,
+		Title:  fmt.Sprintf("%s %s", commandName.TitleString(), strings.Title(status.String())),
+	}
+	id := checkRunID{Command: commandName.String(), Project: p.ProjectName}
+	if p.ProjectName == "" {
+		id.Dir = p.RepoRelDir
+		id.Workspace = p.Workspace
+	}
+	run.ExternalID = c.externalID(id)
+
+	var summary bytes.Buffer
+	switch {
+	case p.Error != nil:
+		fmt.Fprintf(&summary, "**Error**\n```\n%s\n```\n", p.Error)
+		run.Annotations = terraformErrorAnnotations(p.RepoRelDir, p.Error.Error())
+	case p.Failure != "":
+		fmt.Fprintf(&summary, "**Failed**: %s\n", p.Failure)
+	case p.PlanSuccess != nil:
+		plan := c.planSummary(p)
+		run.Title += fmt.Sprintf(": %d to add, %d to change, %d to destroy", plan.Add, plan.Change, plan.Destroy)
+		if len(p.PlanSuccess.ResourceSummaries) > 0 {
+			summary.WriteString("| Resource Type | Add | Change | Destroy |\n| --- | --- | --- | --- |\n")
+			for _, r := range p.PlanSuccess.ResourceSummaries {
+				fmt.Fprintf(&summary, "| `%s` | %d | %d | %d |\n", r.Type, r.Add, r.Change, r.Destroy)
+			}
+		} else {
+			fmt.Fprintf(&summary, "%d to add, %d to change, %d to destroy.\n", plan.Add, plan.Change, plan.Destroy)
+		}
+		if p.PlanSuccess.DestroyThresholdExceeded {
+			fmt.Fprintf(&summary, "\n:warning: **This plan destroys %d resources.**\n", p.PlanSuccess.DestroyCount)
+		}
+		if p.PlanSuccess.PolicyCheckFailed {
+			summary.WriteString("\n:x: **The plan failed its policy checks.** It can't be applied until a policy owner comments `atlantis approve_policies`.\n")
+		}
+		if p.PlanSuccess.PolicyCheckOutput != "" {
+			fmt.Fprintf(&summary, "\n**Policy Checks**\n```\n%s\n```\n", p.PlanSuccess.PolicyCheckOutput)
+		}
+		run.Text = fmt.Sprintf("```diff\n%s\n```", p.PlanSuccess.TerraformOutput)
+		run.DetailsURL = p.PlanSuccess.PlanURL
+	case p.ApplySuccess != "":
+		summary.WriteString(run.Title)
+		run.Text = fmt.Sprintf("```diff\n%s\n```", p.ApplySuccess)
+	default:
+		summary.WriteString(run.Title)
+	}
+	run.Summary = summary.String()
+	return run
+}
+
+// planSummary returns how many resources the plan in p will add, change and
+// destroy. It's nil if p isn't a successful plan.
+func (c *ChecksCommitStatusUpdater) planSummary(p ProjectResult) *runtime.PlanSummary {
+	if p.PlanSuccess == nil || p.Error != nil || p.Failure != "" {
+		return nil
+	}
+	if len(p.PlanSuccess.ResourceSummaries) == 0 {
+		plan, _ := runtime.ParsePlanSummary(p.PlanSuccess.TerraformOutput)
+		return &plan
+	}
+	var plan runtime.PlanSummary
+	for _, r := range p.PlanSuccess.ResourceSummaries {
+		plan.Add += r.Add
+		plan.Change += r.Change
+		plan.Destroy += r.Destroy
+	}
+	return &plan
+}
+
+func (c *ChecksCommitStatusUpdater) projectLabel(p ProjectResult) string {
+	if p.ProjectName != "" {
+		return p.ProjectName
+	}
+	return fmt.Sprintf("%s/%s", p.RepoRelDir, p.Workspace)
+}
+
+func (c *ChecksCommitStatusUpdater) externalID(id checkRunID) string {
+	// checkRunID only has strings so it can't fail to serialize.
+	b, _ := json.Marshal(id)
+	return string(b)
+}
+
+// terraformErrorRegex matches the errors terraform 0.12 and later print, ex.
+// "Error: Unsupported argument", a blank line and then
+// "  on main.tf line 3, in resource ...".
+var terraformErrorRegex = regexp.MustCompile(`(?m)^Error: (.+)\n\n\s+on (\S+) line (\d+)`)
+
+// terraformErrorAnnotations returns an annotation for each error in output
+// that says which line it's on. repoRelDir is the dir terraform was run in,
+// which the paths in its errors are relative to.
+func terraformErrorAnnotations(repoRelDir string, output string) []vcs.CheckRunAnnotation {
+	// Terraform 0.15 and later draw a box around each error.
+	var lines []string
+	for _, l := range strings.Split(output, "\n") {
+		l = strings.TrimPrefix(l, "╷")
+		l = strings.TrimPrefix(l, "╵")
+		l = strings.TrimPrefix(strings.TrimPrefix(l, "│"), " ")
+		lines = append(lines, strings.TrimRight(l, " "))
+	}
+	output = strings.Join(lines, "\n")
+
+	var annotations []vcs.CheckRunAnnotation
+	matches := terraformErrorRegex.FindAllStringSubmatchIndex(output, -1)
+	for i, m := range matches {
+		file := path.Join(repoRelDir, output[m[4]:m[5]])
+		if file == ".." || strings.HasPrefix(file, "../") || path.IsAbs(file) {
+			// The error is in a module outside the repo.
+			continue
+		}
+		line, err := strconv.Atoi(output[m[6]:m[7]])
+		if err != nil {
+			continue
+		}
+		end := len(output)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		annotations = append(annotations, vcs.CheckRunAnnotation{
+			Path:    file,
+			Line:    line,
+			Title:   output[m[2]:m[3]],
+			Message: strings.TrimSpace(output[m[0]:end]),
+		})
+	}
+	return annotations
+}
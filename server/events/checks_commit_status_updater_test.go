@@ -0,0 +1,158 @@
+package events_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+	vcsmocks "github.com/runatlantis/atlantis/server/events/vcs/mocks"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func newChecksCommitStatusUpdater(t *testing.T) (*events.ChecksCommitStatusUpdater, *mocks.MockGithubCheckRunUpdater, *vcsmocks.MockClientProxy) {
+	RegisterMockTestingT(t)
+	checks := mocks.NewMockGithubCheckRunUpdater()
+	client := vcsmocks.NewMockClientProxy()
+	return &events.ChecksCommitStatusUpdater{
+		Checks:   checks,
+		Statuses: &events.DefaultCommitStatusUpdater{Client: client},
+	}, checks, client
+}
+
+func TestChecksCommitStatusUpdater_Update(t *testing.T) {
+	s, checks, _ := newChecksCommitStatusUpdater(t)
+	Ok(t, s.Update(repoModel, pullModel, models.PendingCommitStatus, events.PlanCommand))
+	checks.VerifyWasCalledOnce().UpdateCheckRun(repoModel, pullModel, vcs.CheckRun{
+		Name:       "atlantis/plan",
+		Status:     models.PendingCommitStatus,
+		Title:      "Plan Pending",
+		Summary:    "Plan Pending",
+		ExternalID: `{"command":"plan"}`,
+	})
+}
+
+// Pulls on other VCS hosts still get statuses.
+func TestChecksCommitStatusUpdater_OtherVCSHost(t *testing.T) {
+	s, checks, client := newChecksCommitStatusUpdater(t)
+	repo := models.Repo{VCSHost: models.VCSHost{Type: models.Gitlab}}
+	Ok(t, s.UpdatePlanRequired(repo, pullModel))
+	client.VerifyWasCalledOnce().UpdateStatus(repo, pullModel, models.PendingCommitStatus, "atlantis/plan", "Plan Required: new commits were pushed")
+	checks.VerifyWasCalled(Never()).UpdateCheckRun(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsCheckRun())
+}
+
+func TestChecksCommitStatusUpdater_UpdateProjectResult_Plan(t *testing.T) {
+	s, checks, _ := newChecksCommitStatusUpdater(t)
+	s.Statuses.FailOnDestroy = true
+	ctx := &events.CommandContext{BaseRepo: repoModel, Pull: pullModel}
+	Ok(t, s.UpdateProjectResult(ctx, events.PlanCommand, events.CommandResult{
+		ProjectResults: []events.ProjectResult{
+			{
+				RepoRelDir: "dir",
+				Workspace:  "default",
+				PlanSuccess: &events.PlanSuccess{
+					TerraformOutput:          "Plan: 1 to add, 0 to change, 2 to destroy.",
+					PlanURL:                  "https://atlantis/plans/1",
+					DestroyCount:             2,
+					DestroyThresholdExceeded: true,
+					PolicyCheckFailed:        true,
+					PolicyCheckOutput:        "FAIL - no public buckets",
+					ResourceSummaries: []runtime.ResourceTypeSummary{
+						{Type: "aws_instance", PlanSummary: runtime.PlanSummary{Add: 1}},
+						{Type: "aws_s3_bucket", PlanSummary: runtime.PlanSummary{Destroy: 2}},
+					},
+				},
+			},
+			{ProjectName: "errored", Error: errors.New("err")},
+		},
+	}))
+
+	_, _, runs := checks.VerifyWasCalled(Times(3)).UpdateCheckRun(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsCheckRun()).GetAllCapturedArguments()
+	plan := runs[0]
+	Equals(t, "atlantis/plan: dir/default", plan.Name)
+	Equals(t, models.FailedCommitStatus, plan.Status)
+	Equals(t, "Plan Failed: 1 to add, 0 to change, 2 to destroy", plan.Title)
+	Equals(t, `{"command":"plan","dir":"dir","workspace":"default"}`, plan.ExternalID)
+	Equals(t, "https://atlantis/plans/1", plan.DetailsURL)
+	Equals(t, "```diff\nPlan: 1 to add, 0 to change, 2 to destroy.\n```", plan.Text)
+	for _, exp := range []string{
+		"| `aws_instance` | 1 | 0 | 0 |",
+		"| `aws_s3_bucket` | 0 | 0 | 2 |",
+		"This plan destroys 2 resources",
+		"failed its policy checks",
+		"FAIL - no public buckets",
+	} {
+		Assert(t, strings.Contains(plan.Summary, exp), "exp %q in summary %q", exp, plan.Summary)
+	}
+
+	errored := runs[1]
+	Equals(t, "atlantis/plan: errored", errored.Name)
+	Equals(t, `{"command":"plan","project":"errored"}`, errored.ExternalID)
+
+	command := runs[2]
+	Equals(t, "atlantis/plan", command.Name)
+	Equals(t, models.FailedCommitStatus, command.Status)
+	Equals(t, "Plan Failed: 2 to destroy", command.Title)
+	Assert(t, strings.Contains(command.Summary, "| dir/default | Failed | 1 | 0 | 2 |"), "got summary %q", command.Summary)
+	Assert(t, strings.Contains(command.Summary, "| errored | Failed | - | - | - |"), "got summary %q", command.Summary)
+}
+
+func TestChecksCommitStatusUpdater_UpdateProjectResult_Annotations(t *testing.T) {
+	s, checks, _ := newChecksCommitStatusUpdater(t)
+	ctx := &events.CommandContext{BaseRepo: repoModel, Pull: pullModel}
+	output := `exit status 1: running "terraform plan" in "/data/repos/owner/repo/1/default/dir":
+
+Error: Unsupported argument
+
+  on main.tf line 3, in resource "null_resource" "a":
+   3:   foo = "bar"
+
+An argument named "foo" is not expected here.
+
+╷
+│ Error: Missing required argument
+│
+│   on ../modules/vpc/main.tf line 7, in module "vpc":
+│    7: module "vpc" {
+│
+│ The argument "cidr" is required.
+╵
+
+Error: Module not installed
+
+  on ../../../outside.tf line 1:
+`
+	Ok(t, s.UpdateProjectResult(ctx, events.PlanCommand, events.CommandResult{
+		ProjectResults: []events.ProjectResult{{RepoRelDir: "envs/prod", Workspace: "default", Error: errors.New(output)}},
+	}))
+
+	_, _, runs := checks.VerifyWasCalled(Times(2)).UpdateCheckRun(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsCheckRun()).GetAllCapturedArguments()
+	annotations := runs[0].Annotations
+	Equals(t, 2, len(annotations))
+	Equals(t, "envs/prod/main.tf", annotations[0].Path)
+	Equals(t, 3, annotations[0].Line)
+	Equals(t, "Unsupported argument", annotations[0].Title)
+	Assert(t, strings.Contains(annotations[0].Message, `An argument named "foo" is not expected here.`), "got message %q", annotations[0].Message)
+	Equals(t, "envs/modules/vpc/main.tf", annotations[1].Path)
+	Equals(t, 7, annotations[1].Line)
+	Equals(t, "Missing required argument", annotations[1].Title)
+}
+
+func TestParseCheckRunExternalID(t *testing.T) {
+	cmd, err := events.ParseCheckRunExternalID(`{"command":"apply","project":"prod"}`)
+	Ok(t, err)
+	Equals(t, events.NewCommentCommand("", nil, events.PlanCommand, false, "", "prod"), cmd)
+
+	cmd, err = events.ParseCheckRunExternalID(`{"command":"plan"}`)
+	Ok(t, err)
+	Equals(t, events.NewCommentCommand("", nil, events.PlanCommand, false, "", ""), cmd)
+
+	_, err = events.ParseCheckRunExternalID("other-app")
+	ErrContains(t, "parsing check run external ID", err)
+}
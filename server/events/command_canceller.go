@@ -0,0 +1,82 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+//go:generate pegomock generate --use-experimental-model-gen --package mocks -o mocks/mock_command_canceller.go CommandCanceller
+
+// CommandCanceller tracks the commands running for each pull request so that
+// they can be cancelled with atlantis cancel.
+type CommandCanceller interface {
+	// Track records that a command is running for this repo and pull. It
+	// returns a context that will be cancelled if Cancel is called for the
+	// pull and a function that must be called once the command is done.
+	Track(repoFullName string, pullNum int) (context.Context, func())
+	// Cancel cancels all the commands running for this repo and pull. It
+	// returns the number of commands that were cancelled.
+	Cancel(repoFullName string, pullNum int) int
+}
+
+// DefaultCommandCanceller implements CommandCanceller.
+type DefaultCommandCanceller struct {
+	// mutex guards running and nextID.
+	mutex sync.Mutex
+	// running maps each pull's key to the cancel funcs of the commands
+	// running for it, keyed by an id so each can be removed when it finishes.
+	running map[string]map[int]context.CancelFunc
+	nextID  int
+}
+
+// NewDefaultCommandCanceller is a constructor.
+func NewDefaultCommandCanceller() *DefaultCommandCanceller {
+	return &DefaultCommandCanceller{
+		running: make(map[string]map[int]context.CancelFunc),
+	}
+}
+
+func (d *DefaultCommandCanceller) Track(repoFullName string, pullNum int) (context.Context, func()) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	key := d.pullKey(repoFullName, pullNum)
+	if d.running[key] == nil {
+		d.running[key] = make(map[int]context.CancelFunc)
+	}
+	id := d.nextID
+	d.nextID++
+	d.running[key][id] = cancel
+
+	return ctx, func() {
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+		delete(d.running[key], id)
+		if len(d.running[key]) == 0 {
+			delete(d.running, key)
+		}
+		// Release the context's resources.
+		cancel()
+	}
+}
+
+func (d *DefaultCommandCanceller) Cancel(repoFullName string, pullNum int) int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	key := d.pullKey(repoFullName, pullNum)
+	cancels := d.running[key]
+	for _, cancel := range cancels {
+		cancel()
+	}
+	// The commands will remove themselves once they've stopped but we
+	// remove them now so they're not counted by another cancel.
+	delete(d.running, key)
+	return len(cancels)
+}
+
+func (d *DefaultCommandCanceller) pullKey(repoFullName string, pullNum int) string {
+	return fmt.Sprintf("%s/%d", repoFullName, pullNum)
+}
@@ -0,0 +1,41 @@
+package events_test
+
+import (
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestCancel_NothingRunning(t *testing.T) {
+	canceller := events.NewDefaultCommandCanceller()
+	Equals(t, 0, canceller.Cancel("owner/repo", 1))
+}
+
+func TestCancel_CancelsPull(t *testing.T) {
+	canceller := events.NewDefaultCommandCanceller()
+	ctx1, done1 := canceller.Track("owner/repo", 1)
+	ctx2, done2 := canceller.Track("owner/repo", 1)
+	otherPull, doneOtherPull := canceller.Track("owner/repo", 2)
+	defer doneOtherPull()
+	otherRepo, doneOtherRepo := canceller.Track("owner/other", 1)
+	defer doneOtherRepo()
+
+	Equals(t, 2, canceller.Cancel("owner/repo", 1))
+	Assert(t, ctx1.Err() != nil, "exp first command to be cancelled")
+	Assert(t, ctx2.Err() != nil, "exp second command to be cancelled")
+	Assert(t, otherPull.Err() == nil, "exp other pull's command to not be cancelled")
+	Assert(t, otherRepo.Err() == nil, "exp other repo's command to not be cancelled")
+
+	t.Log("cancelled commands shouldn't be counted again")
+	done1()
+	done2()
+	Equals(t, 0, canceller.Cancel("owner/repo", 1))
+}
+
+func TestCancel_AfterDone(t *testing.T) {
+	canceller := events.NewDefaultCommandCanceller()
+	_, done := canceller.Track("owner/repo", 1)
+	done()
+	Equals(t, 0, canceller.Cancel("owner/repo", 1))
+}
@@ -14,6 +14,8 @@
 package events
 
 import (
+	"context"
+
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/logging"
 )
@@ -32,4 +34,9 @@ type CommandContext struct {
 	// User is the user that triggered this command.
 	User models.User
 	Log  *logging.SimpleLogger
+	// CancelCtx is cancelled when someone runs atlantis cancel on the pull
+	// request. It's set from the start of the command, before the repo is
+	// cloned, so that it can be cancelled at any point. If nil, the command
+	// can't be cancelled.
+	CancelCtx context.Context
 }
@@ -13,9 +13,25 @@
 
 package events
 
+import "github.com/runatlantis/atlantis/server/events/models"
+
 // CommandResult is the result of running a Command.
 type CommandResult struct {
 	Error          error
 	Failure        string
 	ProjectResults []ProjectResult
 }
+
+// HasErrors returns true if there were any errors or failures during the
+// command or any of its projects.
+func (c CommandResult) HasErrors() bool {
+	if c.Error != nil || c.Failure != "" {
+		return true
+	}
+	for _, r := range c.ProjectResults {
+		if r.Status() == models.FailedCommitStatus {
+			return true
+		}
+	}
+	return false
+}
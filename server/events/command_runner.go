@@ -14,14 +14,23 @@
 package events
 
 import (
+	"encoding/json"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/google/go-github/github"
 	"github.com/lkysow/go-gitlab"
 	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/autoplan"
+	"github.com/runatlantis/atlantis/server/events/confirmations"
+	"github.com/runatlantis/atlantis/server/events/locking"
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/events/vcs"
+	"github.com/runatlantis/atlantis/server/events/vcs/gitea"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
 	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/metrics"
 	"github.com/runatlantis/atlantis/server/recovery"
 )
 
@@ -52,11 +61,20 @@ type GitlabMergeRequestGetter interface {
 	GetMergeRequest(repoFullName string, pullNum int) (*gitlab.MergeRequest, error)
 }
 
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_gitea_pull_getter.go GiteaPullGetter
+
+// GiteaPullGetter makes API calls to get pull requests.
+type GiteaPullGetter interface {
+	// GetPullRequest gets the pull request with id pullNum for the repo.
+	GetPullRequest(repo models.Repo, pullNum int) (*gitea.PullRequest, error)
+}
+
 // DefaultCommandRunner is the first step when processing a comment command.
 type DefaultCommandRunner struct {
 	VCSClient                vcs.ClientProxy
 	GithubPullGetter         GithubPullGetter
 	GitlabMergeRequestGetter GitlabMergeRequestGetter
+	GiteaPullGetter          GiteaPullGetter
 	CommitStatusUpdater      CommitStatusUpdater
 	EventParser              EventParsing
 	MarkdownRenderer         *MarkdownRenderer
@@ -69,12 +87,71 @@ type DefaultCommandRunner struct {
 	AllowForkPRsFlag      string
 	ProjectCommandBuilder ProjectCommandBuilder
 	ProjectCommandRunner  ProjectCommandRunner
+	// CommandCanceller tracks running commands so they can be cancelled by
+	// atlantis cancel.
+	CommandCanceller CommandCanceller
+	// PullUnlocker discards plans and releases locks for atlantis unlock.
+	PullUnlocker PullUnlocker
+	// ParallelPoolSize is the number of workers that run plans concurrently.
+	// If it's 1 or less, plans are run one after the other.
+	ParallelPoolSize int
+	// Metrics records the plans and applies we run. If nil, nothing is
+	// recorded.
+	Metrics *metrics.Metrics
+	// WorkflowHooksRunner runs the server-side pre and post-workflow hooks
+	// around each command. If nil, no hooks are run.
+	WorkflowHooksRunner WorkflowHooksRunner
+	// HidePrevPlanComments controls whether our earlier plan comments are
+	// hidden when all of their projects are planned again.
+	HidePrevPlanComments bool
+	// ApplyLocker is checked before applying so applies can be locked
+	// globally. If nil, applies can't be locked.
+	ApplyLocker locking.ApplyLocker
+	// ExecutableName is the name that comments start with to run commands.
+	// Defaults to DefaultExecutableName.
+	ExecutableName string
+	// ApplyPermissionChecker is checked before applying so that only some
+	// users can apply. If nil, anyone who can comment can apply.
+	ApplyPermissionChecker ApplyPermissionChecker
+	// AuditRecorder records each unlock to the audit log. If nil, they
+	// aren't recorded.
+	AuditRecorder AuditRecorder
+	// Drainer stops commands from starting once Atlantis is shutting down
+	// and lets it wait for the running ones. If nil, commands always run.
+	Drainer *Drainer
+	// DryRun is true if Atlantis only plans, in which case the commands that
+	// change state, ex. apply, aren't run.
+	DryRun bool
+	// ServerConfig is the server-side repo config. Repos in it can be
+	// plan-only or only allow pull requests into some branches to be applied.
+	ServerConfig valid.ServerConfig
+	// Automerger merges pull requests once all of their plans are applied.
+	// If nil, they're never merged.
+	Automerger *Automerger
+	// AutoplanStore records the pull requests that autoplanning has been
+	// turned off for with atlantis autoplan off. If nil, it can't be turned
+	// off.
+	AutoplanStore autoplan.Store
+	// RequireApplyConfirmation is true if atlantis apply only comments with
+	// the plans it would apply, which are applied once someone comments
+	// atlantis confirm, ex. with --require-apply-confirmation.
+	RequireApplyConfirmation bool
+	// ConfirmationStore stores the applies waiting to be confirmed. It must
+	// be set if RequireApplyConfirmation is.
+	ConfirmationStore confirmations.Store
 }
 
 // RunAutoplanCommand runs plan when a pull request is opened or updated.
 func (c *DefaultCommandRunner) RunAutoplanCommand(baseRepo models.Repo, headRepo models.Repo, pull models.PullRequest, user models.User) {
 	log := c.buildLogger(baseRepo.FullName, pull.Num)
 	defer c.logPanics(baseRepo, pull.Num, log)
+	if c.Drainer != nil {
+		if !c.Drainer.StartOp() {
+			log.Warn("not autoplanning since Atlantis is shutting down")
+			return
+		}
+		defer c.Drainer.OpDone()
+	}
 	ctx := &CommandContext{
 		User:     user,
 		Log:      log,
@@ -85,13 +162,24 @@ func (c *DefaultCommandRunner) RunAutoplanCommand(baseRepo models.Repo, headRepo
 	if !c.validateCtxAndComment(ctx) {
 		return
 	}
+	defer c.trackCancellation(ctx)()
 	if err := c.CommitStatusUpdater.Update(ctx.BaseRepo, ctx.Pull, models.PendingCommitStatus, PlanCommand); err != nil {
 		ctx.Log.Warn("unable to update commit status: %s", err)
 	}
 
+	if err := c.runPreWorkflowHooks(ctx, PlanCommand); err != nil {
+		c.updatePull(ctx, AutoplanCommand{}, CommandResult{Error: err})
+		return
+	}
+
 	projectCmds, err := c.ProjectCommandBuilder.BuildAutoplanCommands(ctx)
+	if err == nil {
+		err = c.cancelledErr(ctx)
+	}
 	if err != nil {
-		c.updatePull(ctx, AutoplanCommand{}, CommandResult{Error: err})
+		res := CommandResult{Error: err}
+		c.updatePull(ctx, AutoplanCommand{}, res)
+		c.runPostWorkflowHooks(ctx, PlanCommand, res)
 		return
 	}
 	if len(projectCmds) == 0 {
@@ -99,11 +187,15 @@ func (c *DefaultCommandRunner) RunAutoplanCommand(baseRepo models.Repo, headRepo
 		if err := c.CommitStatusUpdater.Update(baseRepo, pull, models.SuccessCommitStatus, PlanCommand); err != nil {
 			ctx.Log.Warn("unable to update commit status: %s", err)
 		}
+		c.runPostWorkflowHooks(ctx, PlanCommand, CommandResult{})
 		return
 	}
 
+	setCancelCtx(ctx, projectCmds)
 	results := c.runProjectCmds(projectCmds, PlanCommand)
-	c.updatePull(ctx, AutoplanCommand{}, CommandResult{ProjectResults: results})
+	res := CommandResult{ProjectResults: results}
+	c.updatePull(ctx, AutoplanCommand{}, res)
+	c.runPostWorkflowHooks(ctx, PlanCommand, res)
 }
 
 // RunCommentCommand executes the command.
@@ -114,6 +206,16 @@ func (c *DefaultCommandRunner) RunAutoplanCommand(baseRepo models.Repo, headRepo
 func (c *DefaultCommandRunner) RunCommentCommand(baseRepo models.Repo, maybeHeadRepo *models.Repo, maybePull *models.PullRequest, user models.User, pullNum int, cmd *CommentCommand) {
 	log := c.buildLogger(baseRepo.FullName, pullNum)
 	defer c.logPanics(baseRepo, pullNum, log)
+	if c.Drainer != nil {
+		if !c.Drainer.StartOp() {
+			log.Warn("not running command since Atlantis is shutting down")
+			if err := c.VCSClient.CreateComment(baseRepo, pullNum, ShuttingDownComment); err != nil {
+				log.Err("unable to comment: %s", err)
+			}
+			return
+		}
+		defer c.Drainer.OpDone()
+	}
 
 	var headRepo models.Repo
 	if maybeHeadRepo != nil {
@@ -127,7 +229,9 @@ func (c *DefaultCommandRunner) RunCommentCommand(baseRepo models.Repo, maybeHead
 		pull, headRepo, err = c.getGithubData(baseRepo, pullNum)
 	case models.Gitlab:
 		pull, err = c.getGitlabData(baseRepo, pullNum)
-	case models.BitbucketCloud, models.BitbucketServer:
+	case models.Gitea:
+		pull, headRepo, err = c.getGiteaData(baseRepo, pullNum)
+	case models.BitbucketCloud, models.BitbucketServer, models.AzureDevops:
 		if maybePull == nil {
 			err = errors.New("pull request should not be nil–this is a bug")
 		}
@@ -140,6 +244,7 @@ func (c *DefaultCommandRunner) RunCommentCommand(baseRepo models.Repo, maybeHead
 		if commentErr := c.VCSClient.CreateComment(baseRepo, pullNum, fmt.Sprintf("`Error: %s`", err)); commentErr != nil {
 			log.Err("unable to comment: %s", commentErr)
 		}
+		c.reactToComment(log, baseRepo, pullNum, cmd, false)
 		return
 	}
 	ctx := &CommandContext{
@@ -150,49 +255,579 @@ func (c *DefaultCommandRunner) RunCommentCommand(baseRepo models.Repo, maybeHead
 		BaseRepo: baseRepo,
 	}
 	if !c.validateCtxAndComment(ctx) {
+		c.reactToComment(log, baseRepo, pullNum, cmd, false)
+		return
+	}
+	if cmd.Name == CancelCommand {
+		c.cancel(ctx, cmd)
+		return
+	}
+	if cmd.Name == UnlockCommand {
+		c.unlock(ctx, cmd)
+		return
+	}
+	if cmd.Name == ToggleAutoplanCommand {
+		c.toggleAutoplan(ctx, cmd)
+		return
+	}
+	defer c.trackCancellation(ctx)()
+	// atlantis confirm runs the apply it confirms, which is checked like
+	// any other apply.
+	confirmed := cmd.Name == ConfirmCommand
+	if confirmed {
+		applyCmd, ok := c.takePendingApply(ctx, cmd)
+		if !ok {
+			c.reactToComment(log, baseRepo, pullNum, cmd, false)
+			return
+		}
+		cmd = applyCmd
+	}
+	if c.DryRun && cmd.Name.ChangesState() {
+		c.commentDryRun(ctx, cmd.Name)
+		c.reactToComment(log, baseRepo, pullNum, cmd, false)
+		return
+	}
+	if cmd.Name.ChangesState() && !c.changesAllowed(ctx, cmd.Name) {
+		c.reactToComment(log, baseRepo, pullNum, cmd, false)
+		return
+	}
+	// Locking applies is meant to stop all changes, ex. during an incident,
+	// so it stops import and state too.
+	if cmd.Name.ChangesState() && c.appliesLocked(ctx, cmd.Name) {
+		c.reactToComment(log, baseRepo, pullNum, cmd, false)
+		return
+	}
+	// Import and state change state like apply so they're restricted to the
+	// same users.
+	if cmd.Name.ChangesState() && !c.applyAllowed(ctx, cmd.Name) {
+		c.reactToComment(log, baseRepo, pullNum, cmd, false)
+		return
+	}
+	if cmd.Name == ApplyCommand && c.RequireApplyConfirmation && !confirmed {
+		c.requestApplyConfirmation(ctx, cmd)
 		return
 	}
 	if err = c.CommitStatusUpdater.Update(ctx.BaseRepo, ctx.Pull, models.PendingCommitStatus, cmd.CommandName()); err != nil {
 		ctx.Log.Warn("unable to update commit status: %s", err)
 	}
 
+	if err = c.runPreWorkflowHooks(ctx, cmd.Name); err != nil {
+		c.updatePull(ctx, cmd, CommandResult{Error: err})
+		c.reactToComment(log, baseRepo, pullNum, cmd, false)
+		return
+	}
+
 	var projectCmds []models.ProjectCommandContext
 	switch cmd.Name {
 	case PlanCommand:
 		projectCmds, err = c.ProjectCommandBuilder.BuildPlanCommands(ctx, cmd)
 	case ApplyCommand:
 		projectCmds, err = c.ProjectCommandBuilder.BuildApplyCommands(ctx, cmd)
+	case ApprovePoliciesCommand:
+		projectCmds, err = c.ProjectCommandBuilder.BuildApprovePoliciesCommands(ctx, cmd)
+	case ImportCommand:
+		projectCmds, err = c.ProjectCommandBuilder.BuildImportCommands(ctx, cmd)
+	case StateCommand:
+		projectCmds, err = c.ProjectCommandBuilder.BuildStateCommands(ctx, cmd)
 	default:
-		ctx.Log.Err("failed to determine desired command, neither plan nor apply")
+		ctx.Log.Err("failed to determine desired command, neither plan, apply, approve_policies, import nor state")
 		return
 	}
+	if err == nil {
+		err = c.cancelledErr(ctx)
+	}
 	if err != nil {
-		c.updatePull(ctx, cmd, CommandResult{Error: err})
+		res := CommandResult{Error: err}
+		c.updatePull(ctx, cmd, res)
+		c.reactToComment(log, baseRepo, pullNum, cmd, false)
+		c.runPostWorkflowHooks(ctx, cmd.Name, res)
 		return
 	}
+	setCancelCtx(ctx, projectCmds)
 	results := c.runProjectCmds(projectCmds, cmd.Name)
-	c.updatePull(
-		ctx,
-		cmd,
-		CommandResult{
-			ProjectResults: results})
+	res := CommandResult{ProjectResults: results}
+	c.updatePull(ctx, cmd, res)
+	c.reactToComment(log, baseRepo, pullNum, cmd, !res.HasErrors())
+	if cmd.Name == ApplyCommand && c.Automerger != nil {
+		c.Automerger.Automerge(ctx, projectCmds, res)
+	}
+	c.runPostWorkflowHooks(ctx, cmd.Name, res)
+}
+
+// appliesLocked returns true if applies are locked globally, in which case it
+// comments that cmdName, which changes state, won't run. If we can't tell,
+// it returns true too since we'd rather not run than run while locked.
+func (c *DefaultCommandRunner) appliesLocked(ctx *CommandContext, cmdName CommandName) bool {
+	if c.ApplyLocker == nil {
+		return false
+	}
+	lock, err := c.ApplyLocker.GetApplyLock()
+	if err != nil {
+		// If we can't tell then applies might be locked so we don't run.
+		ctx.Log.Err("unable to check if applies are locked: %s", err)
+		if err := c.VCSClient.CreateComment(ctx.BaseRepo, ctx.Pull.Num, fmt.Sprintf("**Error:** not running %s since Atlantis couldn't check if applies are locked: %s", cmdName.String(), err)); err != nil {
+			ctx.Log.Err("unable to comment: %s", err)
+		}
+		return true
+	}
+	if lock == nil {
+		return false
+	}
+	ctx.Log.Info("not running %s since applies are locked", cmdName.String())
+	if err := c.VCSClient.CreateComment(ctx.BaseRepo, ctx.Pull.Num, fmt.Sprintf("**Error:** %s", ApplyLockedMessage(*lock, cmdName, c.ExecutableName))); err != nil {
+		ctx.Log.Err("unable to comment: %s", err)
+	}
+	return true
+}
+
+// applyAllowed returns true if the user who commented can apply, and so run
+// cmdName, which changes state. If they can't, it comments why.
+func (c *DefaultCommandRunner) applyAllowed(ctx *CommandContext, cmdName CommandName) bool {
+	if c.ApplyPermissionChecker == nil {
+		return true
+	}
+	err := c.ApplyPermissionChecker.CheckApply(ctx.BaseRepo, ctx.User)
+	if err == nil {
+		return true
+	}
+	ctx.Log.Info("not running %s: %s", cmdName.String(), err)
+	if commentErr := c.VCSClient.CreateComment(ctx.BaseRepo, ctx.Pull.Num, fmt.Sprintf("**Error:** %s", err)); commentErr != nil {
+		ctx.Log.Err("unable to comment: %s", commentErr)
+	}
+	return false
+}
+
+// commentDryRun comments that cmdName won't run because Atlantis is in
+// dry-run mode.
+func (c *DefaultCommandRunner) commentDryRun(ctx *CommandContext, cmdName CommandName) {
+	ctx.Log.Info("not running %s since Atlantis is in dry-run mode", cmdName.String())
+	if err := c.VCSClient.CreateComment(ctx.BaseRepo, ctx.Pull.Num, fmt.Sprintf("**Error:** %s", DryRunMessage(cmdName, c.ExecutableName))); err != nil {
+		ctx.Log.Err("unable to comment: %s", err)
+	}
+}
+
+// changesAllowed returns true if the server-side repo config allows cmdName,
+// which changes state, to run for ctx's pull request. If not, it comments
+// why.
+func (c *DefaultCommandRunner) changesAllowed(ctx *CommandContext, cmdName CommandName) bool {
+	repoCfg := c.ServerConfig.FindRepo(ctx.BaseRepo.FullName, ctx.BaseRepo.VCSHost.Hostname)
+	if repoCfg == nil {
+		return true
+	}
+	err := repoCfg.CheckChangesAllowed(ctx.Pull.BaseBranch)
+	if err == nil {
+		return true
+	}
+	ctx.Log.Info("not running %s: %s", cmdName.String(), err)
+	comment := fmt.Sprintf("**Error:** `%s %s` is disabled: %s.", executableName(c.ExecutableName), cmdName.String(), err)
+	if commentErr := c.VCSClient.CreateComment(ctx.BaseRepo, ctx.Pull.Num, comment); commentErr != nil {
+		ctx.Log.Err("unable to comment: %s", commentErr)
+	}
+	return false
+}
+
+// DryRunMessage explains why cmdName isn't run in dry-run mode. executable is
+// the name comments start with, see CommentParser.ExecutableName.
+func DryRunMessage(cmdName CommandName, executable string) string {
+	return fmt.Sprintf("Atlantis is running in dry-run mode so `%s %s` is disabled. It only plans so that it can be tried out without changing any infrastructure.", executableName(executable), cmdName.String())
+}
+
+// ApplyLockedMessage explains why cmdName, which changes state, isn't being
+// run while lock is held. executable is the name comments start with, see
+// CommentParser.ExecutableName.
+func ApplyLockedMessage(lock models.ApplyLock, cmdName CommandName, executable string) string {
+	return fmt.Sprintf("Applies have been locked since %s so `%s %s` is disabled. Try again once they've been unlocked in the Atlantis UI.", lock.Time.Format(time.RFC1123), executableName(executable), cmdName.String())
+}
+
+// runPreWorkflowHooks runs the pre-workflow hooks before cmdName works out
+// which projects to run in.
+func (c *DefaultCommandRunner) runPreWorkflowHooks(ctx *CommandContext, cmdName CommandName) error {
+	if c.WorkflowHooksRunner == nil {
+		return nil
+	}
+	return c.WorkflowHooksRunner.RunPreHooks(ctx, cmdName)
+}
+
+// runPostWorkflowHooks runs the post-workflow hooks once cmdName's results
+// have been commented.
+func (c *DefaultCommandRunner) runPostWorkflowHooks(ctx *CommandContext, cmdName CommandName, res CommandResult) {
+	if c.WorkflowHooksRunner == nil {
+		return
+	}
+	c.WorkflowHooksRunner.RunPostHooks(ctx, cmdName, res)
+}
+
+// trackCancellation sets ctx.CancelCtx so that the command can be cancelled by
+// atlantis cancel from now on, ex. while the repo is cloned or pre-workflow
+// hooks run. The returned function must be called once the command is done.
+func (c *DefaultCommandRunner) trackCancellation(ctx *CommandContext) func() {
+	cancelCtx, done := c.CommandCanceller.Track(ctx.BaseRepo.FullName, ctx.Pull.Num)
+	ctx.CancelCtx = cancelCtx
+	return done
+}
+
+// cancelledErr returns an error if ctx's command was cancelled before its
+// projects started running.
+func (c *DefaultCommandRunner) cancelledErr(ctx *CommandContext) error {
+	if ctx.CancelCtx != nil && ctx.CancelCtx.Err() != nil {
+		return errors.New("cancelled by atlantis cancel before any project ran")
+	}
+	return nil
+}
+
+// setCancelCtx makes cmds cancellable along with ctx's command.
+func setCancelCtx(ctx *CommandContext, cmds []models.ProjectCommandContext) {
+	for i := range cmds {
+		cmds[i].CancelCtx = ctx.CancelCtx
+	}
+}
+
+// cancel cancels the commands running for the pull request and comments back
+// with what was cancelled.
+func (c *DefaultCommandRunner) cancel(ctx *CommandContext, cmd *CommentCommand) {
+	cancelled := c.CommandCanceller.Cancel(ctx.BaseRepo.FullName, ctx.Pull.Num)
+	comment := "No commands are running for this pull request so there was nothing to cancel."
+	if cancelled > 0 {
+		ctx.Log.Info("cancelled %d running command(s)", cancelled)
+		comment = fmt.Sprintf("Cancelled %d running command(s) for this pull request. Their results will be commented once they've stopped.", cancelled)
+	}
+	if err := c.VCSClient.CreateComment(ctx.BaseRepo, ctx.Pull.Num, comment); err != nil {
+		ctx.Log.Err("unable to comment: %s", err)
+	}
+	c.reactToComment(ctx.Log, ctx.BaseRepo, ctx.Pull.Num, cmd, true)
+}
+
+// unlock discards the pull request's plans and releases its locks, then
+// comments with the locks that were released.
+func (c *DefaultCommandRunner) unlock(ctx *CommandContext, cmd *CommentCommand) {
+	startedAt := time.Now()
+	locks, err := c.PullUnlocker.UnlockPull(ctx.BaseRepo, ctx.Pull)
+	if c.AuditRecorder != nil {
+		event := models.AuditEvent{
+			Command:      UnlockCommand.String(),
+			User:         ctx.User.Username,
+			RepoFullName: ctx.BaseRepo.FullName,
+			PullNum:      ctx.Pull.Num,
+			HeadCommit:   ctx.Pull.HeadCommit,
+			Success:      err == nil,
+			StartedAt:    startedAt,
+			DurationMS:   int64(time.Since(startedAt) / time.Millisecond),
+		}
+		if err != nil {
+			event.Error = err.Error()
+		}
+		c.AuditRecorder.Record(event)
+	}
+	if err != nil {
+		ctx.Log.Err("unable to unlock: %s", err)
+		if commentErr := c.VCSClient.CreateComment(ctx.BaseRepo, ctx.Pull.Num, fmt.Sprintf("**Unlock Failed**: %s", err)); commentErr != nil {
+			ctx.Log.Err("unable to comment: %s", commentErr)
+		}
+		c.reactToComment(ctx.Log, ctx.BaseRepo, ctx.Pull.Num, cmd, false)
+		return
+	}
+	ctx.Log.Info("discarded plans and released %d lock(s)", len(locks))
+	comment := "Plans discarded. This pull request didn't hold any locks."
+	if len(locks) > 0 {
+		comment = "Plans discarded and locks released for:\n"
+		for _, l := range locks {
+			comment += fmt.Sprintf("\n- dir: `%s` workspace: `%s`", l.Project.Path, l.Workspace)
+		}
+	}
+	comment += "\n\nTo `apply` you must run `plan` again."
+	if err := c.VCSClient.CreateComment(ctx.BaseRepo, ctx.Pull.Num, comment); err != nil {
+		ctx.Log.Err("unable to comment: %s", err)
+	}
+	c.reactToComment(ctx.Log, ctx.BaseRepo, ctx.Pull.Num, cmd, true)
+}
+
+// toggleAutoplan turns autoplanning of the pull request off or back on for
+// atlantis autoplan, then comments that it has.
+func (c *DefaultCommandRunner) toggleAutoplan(ctx *CommandContext, cmd *CommentCommand) {
+	err := errors.New("autoplanning can't be turned off on this Atlantis server")
+	if c.AutoplanStore != nil {
+		err = c.AutoplanStore.SetAutoplanDisabled(ctx.BaseRepo.FullName, ctx.Pull.Num, !cmd.AutoplanEnabled)
+	}
+	if err != nil {
+		ctx.Log.Err("unable to change autoplanning: %s", err)
+		if commentErr := c.VCSClient.CreateComment(ctx.BaseRepo, ctx.Pull.Num, fmt.Sprintf("**Autoplan Failed**: %s", err)); commentErr != nil {
+			ctx.Log.Err("unable to comment: %s", commentErr)
+		}
+		c.reactToComment(ctx.Log, ctx.BaseRepo, ctx.Pull.Num, cmd, false)
+		return
+	}
+	executable := executableName(c.ExecutableName)
+	comment := fmt.Sprintf("Autoplanning is off for this pull request. Pushing to it won't run `plan` until you comment `%s autoplan on`, but you can still comment `%s plan`.", executable, executable)
+	if cmd.AutoplanEnabled {
+		ctx.Log.Info("turned autoplanning back on")
+		comment = "Autoplanning is back on for this pull request. It will be planned the next time it's pushed to."
+	} else {
+		ctx.Log.Info("turned autoplanning off")
+	}
+	if err := c.VCSClient.CreateComment(ctx.BaseRepo, ctx.Pull.Num, comment); err != nil {
+		ctx.Log.Err("unable to comment: %s", err)
+	}
+	c.reactToComment(ctx.Log, ctx.BaseRepo, ctx.Pull.Num, cmd, true)
+}
+
+// requestApplyConfirmation saves cmd, an atlantis apply, as the pull
+// request's pending apply and comments with the plans it will apply once it's
+// confirmed with atlantis confirm.
+func (c *DefaultCommandRunner) requestApplyConfirmation(ctx *CommandContext, cmd *CommentCommand) {
+	projectCmds, err := c.ProjectCommandBuilder.BuildApplyCommands(ctx, cmd)
+	if err == nil && len(projectCmds) == 0 {
+		err = errors.New("there are no plans to apply")
+	}
+	if err == nil {
+		err = c.savePendingApply(ctx, cmd)
+	}
+	if err != nil {
+		ctx.Log.Warn("not requesting apply confirmation: %s", err)
+		if commentErr := c.VCSClient.CreateComment(ctx.BaseRepo, ctx.Pull.Num, fmt.Sprintf("**Apply Failed**: %s", err)); commentErr != nil {
+			ctx.Log.Err("unable to comment: %s", commentErr)
+		}
+		c.reactToComment(ctx.Log, ctx.BaseRepo, ctx.Pull.Num, cmd, false)
+		return
+	}
+
+	ctx.Log.Info("waiting for %d plan(s) to be confirmed before applying", len(projectCmds))
+	executable := executableName(c.ExecutableName)
+	comment := fmt.Sprintf("Comment `%s confirm` to apply:\n", executable)
+	for _, pCmd := range projectCmds {
+		comment += "\n- "
+		if name := pCmd.GetProjectName(); name != "" {
+			comment += fmt.Sprintf("project: `%s` ", name)
+		}
+		comment += fmt.Sprintf("dir: `%s` workspace: `%s`", pCmd.RepoRelDir, pCmd.Workspace)
+	}
+	comment += fmt.Sprintf("\n\nIf commits are pushed first, comment `%s apply` again.", executable)
+	if err := c.VCSClient.CreateComment(ctx.BaseRepo, ctx.Pull.Num, comment); err != nil {
+		ctx.Log.Err("unable to comment: %s", err)
+	}
+	c.reactToComment(ctx.Log, ctx.BaseRepo, ctx.Pull.Num, cmd, true)
+}
+
+// savePendingApply saves cmd as the pull request's pending apply, replacing
+// any earlier one.
+func (c *DefaultCommandRunner) savePendingApply(ctx *CommandContext, cmd *CommentCommand) error {
+	if c.ConfirmationStore == nil {
+		return errors.New("there's nowhere to store the apply until it's confirmed")
+	}
+	serialized, err := json.Marshal(cmd)
+	if err != nil {
+		return errors.Wrap(err, "serializing apply")
+	}
+	return c.ConfirmationStore.SavePendingApply(models.PendingApply{
+		RepoFullName: ctx.BaseRepo.FullName,
+		PullNum:      ctx.Pull.Num,
+		HeadCommit:   ctx.Pull.HeadCommit,
+		Username:     ctx.User.Username,
+		Command:      serialized,
+		CreatedAt:    time.Now(),
+	})
+}
+
+// takePendingApply returns the apply that cmd, an atlantis confirm, confirms.
+// If there isn't one that can be applied, it comments why.
+func (c *DefaultCommandRunner) takePendingApply(ctx *CommandContext, cmd *CommentCommand) (*CommentCommand, bool) {
+	executable := executableName(c.ExecutableName)
+	var apply *models.PendingApply
+	err := errors.New("applies don't need to be confirmed on this Atlantis server")
+	if c.ConfirmationStore != nil {
+		apply, err = c.ConfirmationStore.TakePendingApply(ctx.BaseRepo.FullName, ctx.Pull.Num)
+	}
+	var applyCmd CommentCommand
+	switch {
+	case err != nil:
+	case apply == nil:
+		err = fmt.Errorf("there's no apply waiting to be confirmed, comment `%s apply` first", executable)
+	case apply.HeadCommit != ctx.Pull.HeadCommit:
+		err = fmt.Errorf("commits have been pushed since the apply was requested, comment `%s apply` again", executable)
+	default:
+		err = errors.Wrap(json.Unmarshal(apply.Command, &applyCmd), "deserializing apply")
+	}
+	if err != nil {
+		ctx.Log.Info("not confirming apply: %s", err)
+		if commentErr := c.VCSClient.CreateComment(ctx.BaseRepo, ctx.Pull.Num, fmt.Sprintf("**Confirm Failed**: %s", err)); commentErr != nil {
+			ctx.Log.Err("unable to comment: %s", commentErr)
+		}
+		return nil, false
+	}
+	ctx.Log.Info("confirmed the apply requested by %s", apply.Username)
+	applyCmd.CommentID = cmd.CommentID
+	return &applyCmd, true
+}
+
+// reactToComment reacts to the comment that triggered cmd to show whether the
+// command succeeded. It does nothing if we don't know the comment's id.
+func (c *DefaultCommandRunner) reactToComment(log *logging.SimpleLogger, baseRepo models.Repo, pullNum int, cmd *CommentCommand, success bool) {
+	if cmd == nil || cmd.CommentID == 0 {
+		return
+	}
+	reaction := vcs.SuccessReaction
+	if !success {
+		reaction = vcs.FailureReaction
+	}
+	if err := c.VCSClient.ReactToComment(baseRepo, pullNum, cmd.CommentID, reaction); err != nil {
+		log.Warn("unable to react to comment: %s", err)
+	}
 }
 
 func (c *DefaultCommandRunner) runProjectCmds(cmds []models.ProjectCommandContext, cmdName CommandName) []ProjectResult {
+	cmds = sortByDependencies(cmds)
+	if cmdName == PlanCommand && c.ParallelPoolSize > 1 {
+		return c.runProjectCmdsParallel(cmds, cmdName)
+	}
 	var results []ProjectResult
+	// failed are the names of the projects that failed to apply.
+	failed := make(map[string]bool)
 	for _, pCmd := range cmds {
 		var res ProjectResult
-		switch cmdName {
-		case PlanCommand:
-			res = c.ProjectCommandRunner.Plan(pCmd)
-		case ApplyCommand:
-			res = c.ProjectCommandRunner.Apply(pCmd)
+		if dep := failedDependency(pCmd, failed); cmdName == ApplyCommand && dep != "" {
+			res = ProjectResult{
+				Error:       fmt.Errorf("not applied because it depends on project %q which failed to apply", dep),
+				RepoRelDir:  pCmd.RepoRelDir,
+				Workspace:   pCmd.Workspace,
+				ProjectName: pCmd.GetProjectName(),
+			}
+		} else {
+			res = c.runProjectCmd(pCmd, cmdName)
+		}
+		if name := pCmd.GetProjectName(); name != "" && res.Status() == models.FailedCommitStatus {
+			failed[name] = true
 		}
 		results = append(results, res)
 	}
 	return results
 }
 
+// sortByDependencies sorts cmds so that each project comes after the
+// projects it depends on, ex. network before compute. Otherwise projects
+// keep their order. Dependencies on projects that aren't in cmds are ignored
+// since they aren't being run.
+func sortByDependencies(cmds []models.ProjectCommandContext) []models.ProjectCommandContext {
+	inCmds := make(map[string]bool)
+	for _, pCmd := range cmds {
+		if name := pCmd.GetProjectName(); name != "" {
+			inCmds[name] = true
+		}
+	}
+
+	var sorted []models.ProjectCommandContext
+	added := make(map[string]bool)
+	done := make([]bool, len(cmds))
+	for len(sorted) < len(cmds) {
+		progress := false
+		for i, pCmd := range cmds {
+			if done[i] || !dependenciesAdded(pCmd, inCmds, added) {
+				continue
+			}
+			sorted = append(sorted, pCmd)
+			added[pCmd.GetProjectName()] = true
+			done[i] = true
+			progress = true
+		}
+		// Cycles are rejected when atlantis.yaml is parsed but if there is
+		// one anyway we run the rest in their original order.
+		if !progress {
+			for i, pCmd := range cmds {
+				if !done[i] {
+					sorted = append(sorted, pCmd)
+				}
+			}
+		}
+	}
+	return sorted
+}
+
+func dependenciesAdded(pCmd models.ProjectCommandContext, inCmds map[string]bool, added map[string]bool) bool {
+	if pCmd.ProjectConfig == nil {
+		return true
+	}
+	for _, dep := range pCmd.ProjectConfig.DependsOn {
+		if inCmds[dep] && !added[dep] {
+			return false
+		}
+	}
+	return true
+}
+
+// failedDependency returns the name of a project that pCmd depends on which
+// is in failed, or an empty string.
+func failedDependency(pCmd models.ProjectCommandContext, failed map[string]bool) string {
+	if pCmd.ProjectConfig == nil {
+		return ""
+	}
+	for _, dep := range pCmd.ProjectConfig.DependsOn {
+		if failed[dep] {
+			return dep
+		}
+	}
+	return ""
+}
+
+// runProjectCmdsParallel runs cmds on a pool of ParallelPoolSize workers and
+// returns their results in the same order as cmds. Projects in the same
+// workspace share a clone of the repo but each locks only its own dir in it,
+// so they run concurrently too.
+func (c *DefaultCommandRunner) runProjectCmdsParallel(cmds []models.ProjectCommandContext, cmdName CommandName) []ProjectResult {
+	results := make([]ProjectResult, len(cmds))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < c.ParallelPoolSize && w < len(cmds); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = c.runProjectCmdRecover(cmds[i], cmdName)
+			}
+		}()
+	}
+	for i := range cmds {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
+
+// runProjectCmdRecover runs pCmd and turns a panic into an error result since
+// the panic would otherwise crash the server when it happens in a worker.
+func (c *DefaultCommandRunner) runProjectCmdRecover(pCmd models.ProjectCommandContext, cmdName CommandName) (res ProjectResult) {
+	defer func() {
+		if err := recover(); err != nil {
+			stack := recovery.Stack(3)
+			pCmd.Log.Err("PANIC: %s\n%s", err, stack)
+			res = ProjectResult{
+				Error:       fmt.Errorf("running %s panicked: %s", cmdName.String(), err),
+				RepoRelDir:  pCmd.RepoRelDir,
+				Workspace:   pCmd.Workspace,
+				ProjectName: pCmd.GetProjectName(),
+			}
+		}
+	}()
+	return c.runProjectCmd(pCmd, cmdName)
+}
+
+func (c *DefaultCommandRunner) runProjectCmd(pCmd models.ProjectCommandContext, cmdName CommandName) ProjectResult {
+	start := time.Now()
+	var res ProjectResult
+	switch cmdName {
+	case PlanCommand:
+		res = c.ProjectCommandRunner.Plan(pCmd)
+	case ApplyCommand:
+		res = c.ProjectCommandRunner.Apply(pCmd)
+	case ApprovePoliciesCommand:
+		res = c.ProjectCommandRunner.ApprovePolicies(pCmd)
+	case ImportCommand:
+		res = c.ProjectCommandRunner.Import(pCmd)
+	case StateCommand:
+		res = c.ProjectCommandRunner.State(pCmd)
+	}
+	c.Metrics.CommandRun(cmdName.String(), time.Since(start), res.Status() == models.FailedCommitStatus)
+	return res
+}
+
 func (c *DefaultCommandRunner) getGithubData(baseRepo models.Repo, pullNum int) (models.PullRequest, models.Repo, error) {
 	if c.GithubPullGetter == nil {
 		return models.PullRequest{}, models.Repo{}, errors.New("Atlantis not configured to support GitHub")
@@ -220,6 +855,21 @@ func (c *DefaultCommandRunner) getGitlabData(baseRepo models.Repo, pullNum int)
 	return pull, nil
 }
 
+func (c *DefaultCommandRunner) getGiteaData(baseRepo models.Repo, pullNum int) (models.PullRequest, models.Repo, error) {
+	if c.GiteaPullGetter == nil {
+		return models.PullRequest{}, models.Repo{}, errors.New("Atlantis not configured to support Gitea")
+	}
+	giteaPull, err := c.GiteaPullGetter.GetPullRequest(baseRepo, pullNum)
+	if err != nil {
+		return models.PullRequest{}, models.Repo{}, errors.Wrap(err, "making pull request API call to Gitea")
+	}
+	pull, _, headRepo, err := c.EventParser.ParseGiteaPull(giteaPull)
+	if err != nil {
+		return pull, headRepo, errors.Wrap(err, "extracting required fields from comment data")
+	}
+	return pull, headRepo, nil
+}
+
 func (c *DefaultCommandRunner) buildLogger(repoFullName string, pullNum int) *logging.SimpleLogger {
 	src := fmt.Sprintf("%s#%d", repoFullName, pullNum)
 	return c.Logger.NewLogger(src, true, c.Logger.GetLevel())
@@ -256,9 +906,26 @@ func (c *DefaultCommandRunner) updatePull(ctx *CommandContext, command PullComma
 	if err := c.CommitStatusUpdater.UpdateProjectResult(ctx, command.CommandName(), res); err != nil {
 		ctx.Log.Warn("unable to update commit status: %s", err)
 	}
-	comment := c.MarkdownRenderer.Render(res, command.CommandName(), ctx.Log.History.String(), command.IsVerbose(), ctx.BaseRepo.VCSHost.Type)
-	if err := c.VCSClient.CreateComment(ctx.BaseRepo, ctx.Pull.Num, comment); err != nil {
-		ctx.Log.Err("unable to comment: %s", err)
+	if c.HidePrevPlanComments && command.CommandName() == PlanCommand && res.Error == nil && res.Failure == "" && len(res.ProjectResults) > 0 {
+		c.hidePrevPlanComments(ctx, res)
+	}
+	// Globs can match dozens of projects so each gets its own comment
+	// rather than one comment too long to read.
+	results := []CommandResult{res}
+	if commentCmd, ok := command.(*CommentCommand); ok && commentCmd.HasDirGlob() && len(res.ProjectResults) > 1 {
+		results = nil
+		for _, pr := range res.ProjectResults {
+			results = append(results, CommandResult{ProjectResults: []ProjectResult{pr}})
+		}
+	}
+	for _, r := range results {
+		comment := c.MarkdownRenderer.Render(r, command.CommandName(), ctx.Log.History.String(), command.IsVerbose(), ctx.BaseRepo.VCSHost.Type)
+		for _, part := range c.MarkdownRenderer.SplitComment(comment, ctx.BaseRepo.VCSHost.Type) {
+			if err := c.VCSClient.CreateComment(ctx.BaseRepo, ctx.Pull.Num, part); err != nil {
+				ctx.Log.Err("unable to comment: %s", err)
+				return
+			}
+		}
 	}
 }
 
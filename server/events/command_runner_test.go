@@ -14,21 +14,31 @@
 package events_test
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/runatlantis/atlantis/server/logging"
 
 	"github.com/google/go-github/github"
 	. "github.com/petergtz/pegomock"
 	"github.com/runatlantis/atlantis/server/events"
+	autoplanmocks "github.com/runatlantis/atlantis/server/events/autoplan/mocks"
+	confirmationmocks "github.com/runatlantis/atlantis/server/events/confirmations/mocks"
+	confirmationmatchers "github.com/runatlantis/atlantis/server/events/confirmations/mocks/matchers"
+	lockmocks "github.com/runatlantis/atlantis/server/events/locking/mocks"
 	"github.com/runatlantis/atlantis/server/events/mocks"
 	"github.com/runatlantis/atlantis/server/events/mocks/matchers"
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/events/models/fixtures"
+	"github.com/runatlantis/atlantis/server/events/vcs"
 	vcsmocks "github.com/runatlantis/atlantis/server/events/vcs/mocks"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
 	logmocks "github.com/runatlantis/atlantis/server/logging/mocks"
 	. "github.com/runatlantis/atlantis/testing"
 )
@@ -38,6 +48,7 @@ var eventParsing *mocks.MockEventParsing
 var ghStatus *mocks.MockCommitStatusUpdater
 var githubGetter *mocks.MockGithubPullGetter
 var gitlabGetter *mocks.MockGitlabMergeRequestGetter
+var giteaGetter *mocks.MockGiteaPullGetter
 var ch events.DefaultCommandRunner
 var pullLogger *logging.SimpleLogger
 
@@ -49,6 +60,7 @@ func setup(t *testing.T) *vcsmocks.MockClientProxy {
 	vcsClient := vcsmocks.NewMockClientProxy()
 	githubGetter = mocks.NewMockGithubPullGetter()
 	gitlabGetter = mocks.NewMockGitlabMergeRequestGetter()
+	giteaGetter = mocks.NewMockGiteaPullGetter()
 	logger := logmocks.NewMockSimpleLogging()
 	pullLogger = logging.NewSimpleLogger("runatlantis/atlantis#1", true, logging.Info)
 	projectCommandRunner := mocks.NewMockProjectCommandRunner()
@@ -62,11 +74,13 @@ func setup(t *testing.T) *vcsmocks.MockClientProxy {
 		MarkdownRenderer:         &events.MarkdownRenderer{},
 		GithubPullGetter:         githubGetter,
 		GitlabMergeRequestGetter: gitlabGetter,
+		GiteaPullGetter:          giteaGetter,
 		Logger:                   logger,
 		AllowForkPRs:             false,
 		AllowForkPRsFlag:         "allow-fork-prs-flag",
 		ProjectCommandBuilder:    projectCommandBuilder,
 		ProjectCommandRunner:     projectCommandRunner,
+		CommandCanceller:         events.NewDefaultCommandCanceller(),
 	}
 	return vcsClient
 }
@@ -80,6 +94,26 @@ func TestRunCommentCommand_LogPanics(t *testing.T) {
 	Assert(t, strings.Contains(comment, "Error: goroutine panic"), fmt.Sprintf("comment should be about a goroutine panic but was %q", comment))
 }
 
+func TestRunCommentCommand_ShuttingDown(t *testing.T) {
+	t.Log("if Atlantis is shutting down we comment that the command wasn't run")
+	vcsClient := setup(t)
+	ch.Drainer = &events.Drainer{}
+	ch.Drainer.Drain(context.Background())
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, 1, &events.CommentCommand{Name: events.PlanCommand})
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, 1, events.ShuttingDownComment)
+	githubGetter.VerifyWasCalled(Never()).GetPullRequest(matchers.AnyModelsRepo(), AnyInt())
+}
+
+func TestRunAutoplanCommand_ShuttingDown(t *testing.T) {
+	t.Log("if Atlantis is shutting down we don't autoplan")
+	vcsClient := setup(t)
+	ch.Drainer = &events.Drainer{}
+	ch.Drainer.Drain(context.Background())
+	ch.RunAutoplanCommand(fixtures.GithubRepo, fixtures.GithubRepo, fixtures.Pull, fixtures.User)
+	projectCommandBuilder.VerifyWasCalled(Never()).BuildAutoplanCommands(matchers.AnyPtrToEventsCommandContext())
+	vcsClient.VerifyWasCalled(Never()).CreateComment(matchers.AnyModelsRepo(), AnyInt(), AnyString())
+}
+
 func TestRunCommentCommand_NoGithubPullGetter(t *testing.T) {
 	t.Log("if DefaultCommandRunner was constructed with a nil GithubPullGetter an error should be logged")
 	setup(t)
@@ -96,6 +130,14 @@ func TestRunCommentCommand_NoGitlabMergeGetter(t *testing.T) {
 	Equals(t, "[EROR] Atlantis not configured to support GitLab\n", pullLogger.History.String())
 }
 
+func TestRunCommentCommand_NoGiteaPullGetter(t *testing.T) {
+	t.Log("if DefaultCommandRunner was constructed with a nil GiteaPullGetter an error should be logged")
+	setup(t)
+	ch.GiteaPullGetter = nil
+	ch.RunCommentCommand(fixtures.GiteaRepo, &fixtures.GiteaRepo, nil, fixtures.User, 1, nil)
+	Equals(t, "[EROR] Atlantis not configured to support Gitea\n", pullLogger.History.String())
+}
+
 func TestRunCommentCommand_GithubPullErr(t *testing.T) {
 	t.Log("if getting the github pull request fails an error should be logged")
 	vcsClient := setup(t)
@@ -112,6 +154,14 @@ func TestRunCommentCommand_GitlabMergeRequestErr(t *testing.T) {
 	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GitlabRepo, fixtures.Pull.Num, "`Error: making merge request API call to GitLab: err`")
 }
 
+func TestRunCommentCommand_GiteaPullErr(t *testing.T) {
+	t.Log("if getting the gitea pull request fails an error should be logged")
+	vcsClient := setup(t)
+	When(giteaGetter.GetPullRequest(fixtures.GiteaRepo, fixtures.Pull.Num)).ThenReturn(nil, errors.New("err"))
+	ch.RunCommentCommand(fixtures.GiteaRepo, &fixtures.GiteaRepo, nil, fixtures.User, fixtures.Pull.Num, nil)
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GiteaRepo, fixtures.Pull.Num, "`Error: making pull request API call to Gitea: err`")
+}
+
 func TestRunCommentCommand_GithubPullParseErr(t *testing.T) {
 	t.Log("if parsing the returned github pull request fails an error should be logged")
 	vcsClient := setup(t)
@@ -155,3 +205,716 @@ func TestRunCommentCommand_ClosedPull(t *testing.T) {
 	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, nil)
 	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, modelPull.Num, "Atlantis commands can't be run on closed pull requests")
 }
+
+func TestRunCommentCommand_ReactsWithFailure(t *testing.T) {
+	t.Log("if a command comment can't be run we should react to it with the failure reaction")
+	vcsClient := setup(t)
+	pull := &github.PullRequest{
+		State: github.String("closed"),
+	}
+	modelPull := models.PullRequest{State: models.ClosedPullState}
+	When(githubGetter.GetPullRequest(fixtures.GithubRepo, fixtures.Pull.Num)).ThenReturn(pull, nil)
+	When(eventParsing.ParseGithubPull(pull)).ThenReturn(modelPull, modelPull.BaseRepo, fixtures.GithubRepo, nil)
+
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.PlanCommand, CommentID: 123})
+	vcsClient.VerifyWasCalledOnce().ReactToComment(fixtures.GithubRepo, fixtures.Pull.Num, int64(123), vcs.FailureReaction)
+}
+
+func TestRunCommentCommand_CancelNothingRunning(t *testing.T) {
+	t.Log("if nothing is running, cancel should comment saying so")
+	vcsClient := setup(t)
+	pull := &github.PullRequest{
+		State: github.String("open"),
+	}
+	modelPull := models.PullRequest{State: models.OpenPullState, Num: fixtures.Pull.Num}
+	When(githubGetter.GetPullRequest(fixtures.GithubRepo, fixtures.Pull.Num)).ThenReturn(pull, nil)
+	When(eventParsing.ParseGithubPull(pull)).ThenReturn(modelPull, fixtures.GithubRepo, fixtures.GithubRepo, nil)
+
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.CancelCommand, CommentID: 123})
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, fixtures.Pull.Num, "No commands are running for this pull request so there was nothing to cancel.")
+	vcsClient.VerifyWasCalledOnce().ReactToComment(fixtures.GithubRepo, fixtures.Pull.Num, int64(123), vcs.SuccessReaction)
+	projectCommandBuilder.VerifyWasCalled(Never()).BuildPlanCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())
+}
+
+func TestRunCommentCommand_CancelRunning(t *testing.T) {
+	t.Log("cancel should cancel the commands running for the pull request")
+	vcsClient := setup(t)
+	canceller := events.NewDefaultCommandCanceller()
+	ch.CommandCanceller = canceller
+	pull := &github.PullRequest{
+		State: github.String("open"),
+	}
+	modelPull := models.PullRequest{State: models.OpenPullState, Num: fixtures.Pull.Num}
+	When(githubGetter.GetPullRequest(fixtures.GithubRepo, fixtures.Pull.Num)).ThenReturn(pull, nil)
+	When(eventParsing.ParseGithubPull(pull)).ThenReturn(modelPull, fixtures.GithubRepo, fixtures.GithubRepo, nil)
+
+	running, done := canceller.Track(fixtures.GithubRepo.FullName, fixtures.Pull.Num)
+	defer done()
+	other, otherDone := canceller.Track(fixtures.GithubRepo.FullName, fixtures.Pull.Num+1)
+	defer otherDone()
+
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.CancelCommand})
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, fixtures.Pull.Num, "Cancelled 1 running command(s) for this pull request. Their results will be commented once they've stopped.")
+	Assert(t, running.Err() != nil, "exp command for pull to be cancelled")
+	Assert(t, other.Err() == nil, "exp command for other pull to still be running")
+}
+
+func TestRunCommentCommand_CancelBeforeProjectsRun(t *testing.T) {
+	t.Log("a command cancelled while its projects are being built, ex. while " +
+		"the repo is cloned, should stop before running them")
+	vcsClient := setup(t)
+	projectCommandRunner := mocks.NewMockProjectCommandRunner()
+	ch.ProjectCommandRunner = projectCommandRunner
+	pull := &github.PullRequest{
+		State: github.String("open"),
+	}
+	modelPull := models.PullRequest{State: models.OpenPullState, Num: fixtures.Pull.Num}
+	When(githubGetter.GetPullRequest(fixtures.GithubRepo, fixtures.Pull.Num)).ThenReturn(pull, nil)
+	When(eventParsing.ParseGithubPull(pull)).ThenReturn(modelPull, fixtures.GithubRepo, fixtures.GithubRepo, nil)
+	var cancelled int
+	When(projectCommandBuilder.BuildPlanCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())).Then(func(params []Param) ReturnValues {
+		cancelled = ch.CommandCanceller.Cancel(fixtures.GithubRepo.FullName, fixtures.Pull.Num)
+		return ReturnValues{[]models.ProjectCommandContext{{RepoRelDir: "dir1", Workspace: "default"}}, nil}
+	})
+
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.PlanCommand})
+	Equals(t, 1, cancelled)
+	projectCommandRunner.VerifyWasCalled(Never()).Plan(matchers.AnyModelsProjectCommandContext())
+	_, _, comment := vcsClient.VerifyWasCalledOnce().CreateComment(matchers.AnyModelsRepo(), AnyInt(), AnyString()).GetCapturedArguments()
+	Assert(t, strings.Contains(comment, "cancelled by atlantis cancel before any project ran"), "exp comment to say the command was cancelled but got %q", comment)
+}
+
+func TestRunCommentCommand_Unlock(t *testing.T) {
+	t.Log("unlock should discard the pull's plans and release its locks")
+	vcsClient := setup(t)
+	unlocker := mocks.NewMockPullUnlocker()
+	ch.PullUnlocker = unlocker
+	pull := &github.PullRequest{
+		State: github.String("open"),
+	}
+	modelPull := models.PullRequest{State: models.OpenPullState, Num: fixtures.Pull.Num}
+	When(githubGetter.GetPullRequest(fixtures.GithubRepo, fixtures.Pull.Num)).ThenReturn(pull, nil)
+	When(eventParsing.ParseGithubPull(pull)).ThenReturn(modelPull, fixtures.GithubRepo, fixtures.GithubRepo, nil)
+	When(unlocker.UnlockPull(fixtures.GithubRepo, modelPull)).ThenReturn([]models.ProjectLock{
+		{Project: models.NewProject(fixtures.GithubRepo.FullName, "dir1"), Workspace: "default"},
+		{Project: models.NewProject(fixtures.GithubRepo.FullName, "dir2"), Workspace: "staging"},
+	}, nil)
+
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.UnlockCommand, CommentID: 123})
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, fixtures.Pull.Num, "Plans discarded and locks released for:\n\n- dir: `dir1` workspace: `default`\n- dir: `dir2` workspace: `staging`\n\nTo `apply` you must run `plan` again.")
+	vcsClient.VerifyWasCalledOnce().ReactToComment(fixtures.GithubRepo, fixtures.Pull.Num, int64(123), vcs.SuccessReaction)
+	ghStatus.VerifyWasCalled(Never()).Update(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyModelsCommitStatus(), matchers.AnyEventsCommandName())
+}
+
+func TestRunCommentCommand_UnlockErr(t *testing.T) {
+	t.Log("if unlocking fails we should comment with the error")
+	vcsClient := setup(t)
+	unlocker := mocks.NewMockPullUnlocker()
+	ch.PullUnlocker = unlocker
+	recorder := mocks.NewMockAuditRecorder()
+	ch.AuditRecorder = recorder
+	pull := &github.PullRequest{
+		State: github.String("open"),
+	}
+	modelPull := models.PullRequest{State: models.OpenPullState, Num: fixtures.Pull.Num}
+	When(githubGetter.GetPullRequest(fixtures.GithubRepo, fixtures.Pull.Num)).ThenReturn(pull, nil)
+	When(eventParsing.ParseGithubPull(pull)).ThenReturn(modelPull, fixtures.GithubRepo, fixtures.GithubRepo, nil)
+	When(unlocker.UnlockPull(fixtures.GithubRepo, modelPull)).ThenReturn(nil, errors.New("the Atlantis working dir is currently locked"))
+
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.UnlockCommand, CommentID: 123})
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, fixtures.Pull.Num, "**Unlock Failed**: the Atlantis working dir is currently locked")
+	vcsClient.VerifyWasCalledOnce().ReactToComment(fixtures.GithubRepo, fixtures.Pull.Num, int64(123), vcs.FailureReaction)
+	event := recorder.VerifyWasCalledOnce().Record(matchers.AnyModelsAuditEvent()).GetCapturedArguments()
+	Equals(t, "unlock", event.Command)
+	Equals(t, fixtures.User.Username, event.User)
+	Equals(t, fixtures.Pull.Num, event.PullNum)
+	Equals(t, false, event.Success)
+	Equals(t, "the Atlantis working dir is currently locked", event.Error)
+}
+
+func TestRunCommentCommand_Autoplan(t *testing.T) {
+	t.Log("atlantis autoplan should turn autoplanning of the pull off and back on")
+	vcsClient := setup(t)
+	store := autoplanmocks.NewMockStore()
+	ch.AutoplanStore = store
+	pull := &github.PullRequest{
+		State: github.String("open"),
+	}
+	modelPull := models.PullRequest{State: models.OpenPullState, Num: fixtures.Pull.Num}
+	When(githubGetter.GetPullRequest(fixtures.GithubRepo, fixtures.Pull.Num)).ThenReturn(pull, nil)
+	When(eventParsing.ParseGithubPull(pull)).ThenReturn(modelPull, fixtures.GithubRepo, fixtures.GithubRepo, nil)
+
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.ToggleAutoplanCommand, CommentID: 123})
+	store.VerifyWasCalledOnce().SetAutoplanDisabled(fixtures.GithubRepo.FullName, fixtures.Pull.Num, true)
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, fixtures.Pull.Num, "Autoplanning is off for this pull request. Pushing to it won't run `plan` until you comment `atlantis autoplan on`, but you can still comment `atlantis plan`.")
+	vcsClient.VerifyWasCalledOnce().ReactToComment(fixtures.GithubRepo, fixtures.Pull.Num, int64(123), vcs.SuccessReaction)
+	ghStatus.VerifyWasCalled(Never()).Update(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyModelsCommitStatus(), matchers.AnyEventsCommandName())
+
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.ToggleAutoplanCommand, AutoplanEnabled: true})
+	store.VerifyWasCalledOnce().SetAutoplanDisabled(fixtures.GithubRepo.FullName, fixtures.Pull.Num, false)
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, fixtures.Pull.Num, "Autoplanning is back on for this pull request. It will be planned the next time it's pushed to.")
+}
+
+func TestRunCommentCommand_AutoplanErr(t *testing.T) {
+	t.Log("if the autoplan setting can't be saved we should comment with the error")
+	vcsClient := setup(t)
+	store := autoplanmocks.NewMockStore()
+	ch.AutoplanStore = store
+	pull := &github.PullRequest{
+		State: github.String("open"),
+	}
+	modelPull := models.PullRequest{State: models.OpenPullState, Num: fixtures.Pull.Num}
+	When(githubGetter.GetPullRequest(fixtures.GithubRepo, fixtures.Pull.Num)).ThenReturn(pull, nil)
+	When(eventParsing.ParseGithubPull(pull)).ThenReturn(modelPull, fixtures.GithubRepo, fixtures.GithubRepo, nil)
+	When(store.SetAutoplanDisabled(fixtures.GithubRepo.FullName, fixtures.Pull.Num, true)).ThenReturn(errors.New("DB transaction failed"))
+
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.ToggleAutoplanCommand, CommentID: 123})
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, fixtures.Pull.Num, "**Autoplan Failed**: DB transaction failed")
+	vcsClient.VerifyWasCalledOnce().ReactToComment(fixtures.GithubRepo, fixtures.Pull.Num, int64(123), vcs.FailureReaction)
+}
+
+// setupConfirmation sets up the runner to require applies to be confirmed for
+// an open pull request at commit abc123.
+func setupConfirmation(t *testing.T) (*vcsmocks.MockClientProxy, *confirmationmocks.MockStore) {
+	vcsClient := setup(t)
+	store := confirmationmocks.NewMockStore()
+	ch.RequireApplyConfirmation = true
+	ch.ConfirmationStore = store
+	pull := &github.PullRequest{
+		State: github.String("open"),
+	}
+	modelPull := models.PullRequest{State: models.OpenPullState, Num: fixtures.Pull.Num, HeadCommit: "abc123"}
+	When(githubGetter.GetPullRequest(fixtures.GithubRepo, fixtures.Pull.Num)).ThenReturn(pull, nil)
+	When(eventParsing.ParseGithubPull(pull)).ThenReturn(modelPull, fixtures.GithubRepo, fixtures.GithubRepo, nil)
+	return vcsClient, store
+}
+
+func TestRunCommentCommand_RequireApplyConfirmation(t *testing.T) {
+	t.Log("if applies require confirmation, apply should comment what it would apply and save the apply")
+	vcsClient, store := setupConfirmation(t)
+	When(projectCommandBuilder.BuildApplyCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())).
+		ThenReturn([]models.ProjectCommandContext{
+			{RepoRelDir: "network", Workspace: "default", ProjectConfig: &valid.Project{Name: String("network")}},
+			{RepoRelDir: "compute", Workspace: "staging"},
+		}, nil)
+
+	cmd := &events.CommentCommand{Name: events.ApplyCommand, ProjectName: "network,compute", CommentID: 123}
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, cmd)
+	apply := store.VerifyWasCalledOnce().SavePendingApply(confirmationmatchers.AnyModelsPendingApply()).GetCapturedArguments()
+	Equals(t, fixtures.GithubRepo.FullName, apply.RepoFullName)
+	Equals(t, fixtures.Pull.Num, apply.PullNum)
+	Equals(t, "abc123", apply.HeadCommit)
+	Equals(t, fixtures.User.Username, apply.Username)
+	var savedCmd events.CommentCommand
+	Ok(t, json.Unmarshal(apply.Command, &savedCmd))
+	Equals(t, *cmd, savedCmd)
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, fixtures.Pull.Num, "Comment `atlantis confirm` to apply:\n\n- project: `network` dir: `network` workspace: `default`\n- dir: `compute` workspace: `staging`\n\nIf commits are pushed first, comment `atlantis apply` again.")
+	vcsClient.VerifyWasCalledOnce().ReactToComment(fixtures.GithubRepo, fixtures.Pull.Num, int64(123), vcs.SuccessReaction)
+	ghStatus.VerifyWasCalled(Never()).Update(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyModelsCommitStatus(), matchers.AnyEventsCommandName())
+}
+
+func TestRunCommentCommand_RequireApplyConfirmationNoPlans(t *testing.T) {
+	t.Log("if there's nothing to apply, apply shouldn't ask for confirmation")
+	vcsClient, store := setupConfirmation(t)
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.ApplyCommand, CommentID: 123})
+	store.VerifyWasCalled(Never()).SavePendingApply(confirmationmatchers.AnyModelsPendingApply())
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, fixtures.Pull.Num, "**Apply Failed**: there are no plans to apply")
+	vcsClient.VerifyWasCalledOnce().ReactToComment(fixtures.GithubRepo, fixtures.Pull.Num, int64(123), vcs.FailureReaction)
+}
+
+func TestRunCommentCommand_Confirm(t *testing.T) {
+	t.Log("atlantis confirm should run the apply that's waiting to be confirmed")
+	_, store := setupConfirmation(t)
+	When(store.TakePendingApply(fixtures.GithubRepo.FullName, fixtures.Pull.Num)).ThenReturn(&models.PendingApply{
+		RepoFullName: fixtures.GithubRepo.FullName,
+		PullNum:      fixtures.Pull.Num,
+		HeadCommit:   "abc123",
+		Username:     "someone",
+		Command:      json.RawMessage(`{"Name":0,"ProjectName":"network,compute","CommentID":123}`),
+	}, nil)
+
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.ConfirmCommand, CommentID: 456})
+	projectCommandBuilder.VerifyWasCalledOnce().BuildApplyCommands(matchers.AnyPtrToEventsCommandContext(), matchers.EqPtrToEventsCommentCommand(&events.CommentCommand{Name: events.ApplyCommand, ProjectName: "network,compute", CommentID: 456}))
+	store.VerifyWasCalled(Never()).SavePendingApply(confirmationmatchers.AnyModelsPendingApply())
+}
+
+func TestRunCommentCommand_ConfirmErrs(t *testing.T) {
+	cases := []struct {
+		description string
+		apply       *models.PendingApply
+		err         error
+		expComment  string
+	}{
+		{
+			"no pending apply",
+			nil,
+			nil,
+			"**Confirm Failed**: there's no apply waiting to be confirmed, comment `atlantis apply` first",
+		},
+		{
+			"commits pushed since the apply was requested",
+			&models.PendingApply{HeadCommit: "def456", Command: json.RawMessage(`{"Name":0}`)},
+			nil,
+			"**Confirm Failed**: commits have been pushed since the apply was requested, comment `atlantis apply` again",
+		},
+		{
+			"store error",
+			nil,
+			errors.New("DB transaction failed"),
+			"**Confirm Failed**: DB transaction failed",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			vcsClient, store := setupConfirmation(t)
+			When(store.TakePendingApply(fixtures.GithubRepo.FullName, fixtures.Pull.Num)).ThenReturn(c.apply, c.err)
+			ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.ConfirmCommand, CommentID: 123})
+			projectCommandBuilder.VerifyWasCalled(Never()).BuildApplyCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())
+			vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, fixtures.Pull.Num, c.expComment)
+			vcsClient.VerifyWasCalledOnce().ReactToComment(fixtures.GithubRepo, fixtures.Pull.Num, int64(123), vcs.FailureReaction)
+		})
+	}
+}
+
+func TestRunCommentCommand_PreWorkflowHookErr(t *testing.T) {
+	t.Log("if a pre-workflow hook fails we should comment with the error and not run the command")
+	vcsClient := setup(t)
+	hooks := mocks.NewMockWorkflowHooksRunner()
+	ch.WorkflowHooksRunner = hooks
+	pull := &github.PullRequest{
+		State: github.String("open"),
+	}
+	modelPull := models.PullRequest{State: models.OpenPullState, Num: fixtures.Pull.Num}
+	When(githubGetter.GetPullRequest(fixtures.GithubRepo, fixtures.Pull.Num)).ThenReturn(pull, nil)
+	When(eventParsing.ParseGithubPull(pull)).ThenReturn(modelPull, fixtures.GithubRepo, fixtures.GithubRepo, nil)
+	When(hooks.RunPreHooks(matchers.AnyPtrToEventsCommandContext(), matchers.AnyEventsCommandName())).ThenReturn(errors.New("running pre-workflow hooks: exit status 1"))
+
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.PlanCommand})
+	_, _, comment := vcsClient.VerifyWasCalledOnce().CreateComment(matchers.AnyModelsRepo(), AnyInt(), AnyString()).GetCapturedArguments()
+	Assert(t, strings.Contains(comment, "running pre-workflow hooks: exit status 1"), "exp hook error in comment, got %q", comment)
+	_, cmdName := hooks.VerifyWasCalledOnce().RunPreHooks(matchers.AnyPtrToEventsCommandContext(), matchers.AnyEventsCommandName()).GetCapturedArguments()
+	Equals(t, events.PlanCommand, cmdName)
+	projectCommandBuilder.VerifyWasCalled(Never()).BuildPlanCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())
+	hooks.VerifyWasCalled(Never()).RunPostHooks(matchers.AnyPtrToEventsCommandContext(), matchers.AnyEventsCommandName(), matchers.AnyEventsCommandResult())
+}
+
+func TestRunAutoplanCommand_WorkflowHooks(t *testing.T) {
+	t.Log("the pre and post-workflow hooks should run around autoplan, even if there's nothing to plan")
+	setup(t)
+	hooks := mocks.NewMockWorkflowHooksRunner()
+	ch.WorkflowHooksRunner = hooks
+	modelPull := models.PullRequest{State: models.OpenPullState, Num: fixtures.Pull.Num}
+
+	ch.RunAutoplanCommand(fixtures.GithubRepo, fixtures.GithubRepo, modelPull, fixtures.User)
+	hooks.VerifyWasCalledOnce().RunPreHooks(matchers.AnyPtrToEventsCommandContext(), matchers.EqEventsCommandName(events.PlanCommand))
+	projectCommandBuilder.VerifyWasCalledOnce().BuildAutoplanCommands(matchers.AnyPtrToEventsCommandContext())
+	_, cmdName, res := hooks.VerifyWasCalledOnce().RunPostHooks(matchers.AnyPtrToEventsCommandContext(), matchers.AnyEventsCommandName(), matchers.AnyEventsCommandResult()).GetCapturedArguments()
+	Equals(t, events.PlanCommand, cmdName)
+	Assert(t, !res.HasErrors(), "exp no errors")
+}
+
+func TestRunCommentCommand_ParallelPlans(t *testing.T) {
+	t.Log("with a pool size > 1, plans should run at the same time, including " +
+		"plans for different dirs in the same workspace")
+	vcsClient := setup(t)
+	runner := &blockingPlanRunner{
+		running: make(map[string]int),
+		started: make(chan string, 3),
+		release: make(chan struct{}),
+	}
+	ch.ProjectCommandRunner = runner
+	ch.ParallelPoolSize = 3
+	pull := &github.PullRequest{
+		State: github.String("open"),
+	}
+	modelPull := models.PullRequest{State: models.OpenPullState, Num: fixtures.Pull.Num}
+	When(githubGetter.GetPullRequest(fixtures.GithubRepo, fixtures.Pull.Num)).ThenReturn(pull, nil)
+	When(eventParsing.ParseGithubPull(pull)).ThenReturn(modelPull, fixtures.GithubRepo, fixtures.GithubRepo, nil)
+	When(projectCommandBuilder.BuildPlanCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())).
+		ThenReturn([]models.ProjectCommandContext{
+			{RepoRelDir: "dir1", Workspace: "default"},
+			{RepoRelDir: "dir2", Workspace: "default"},
+			{RepoRelDir: "dir3", Workspace: "staging"},
+		}, nil)
+
+	done := make(chan struct{})
+	go func() {
+		ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.PlanCommand})
+		close(done)
+	}()
+
+	// Every project should start planning before any is allowed to finish.
+	var started []string
+	for len(started) < 3 {
+		select {
+		case dir := <-runner.started:
+			started = append(started, dir)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("exp plans for all projects to be running but only got %v", started)
+		}
+	}
+	close(runner.release)
+	<-done
+
+	Assert(t, runner.sameWorkspaceOverlap, "exp plans in the same workspace to overlap")
+	_, _, comment := vcsClient.VerifyWasCalledOnce().CreateComment(matchers.AnyModelsRepo(), AnyInt(), AnyString()).GetCapturedArguments()
+	dir1 := strings.Index(comment, "### 1. dir: `dir1` workspace: `default`")
+	dir2 := strings.Index(comment, "### 2. dir: `dir2` workspace: `default`")
+	dir3 := strings.Index(comment, "### 3. dir: `dir3` workspace: `staging`")
+	Assert(t, dir1 != -1 && dir1 < dir2 && dir2 < dir3, "exp results in the order the projects were given but got %q", comment)
+}
+
+func TestRunCommentCommand_DirGlobCommentsPerProject(t *testing.T) {
+	t.Log("plans for a dir glob should comment once per project")
+	vcsClient := setup(t)
+	pull := &github.PullRequest{
+		State: github.String("open"),
+	}
+	modelPull := models.PullRequest{State: models.OpenPullState, Num: fixtures.Pull.Num}
+	When(githubGetter.GetPullRequest(fixtures.GithubRepo, fixtures.Pull.Num)).ThenReturn(pull, nil)
+	When(eventParsing.ParseGithubPull(pull)).ThenReturn(modelPull, fixtures.GithubRepo, fixtures.GithubRepo, nil)
+	When(projectCommandBuilder.BuildPlanCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())).
+		ThenReturn([]models.ProjectCommandContext{
+			{RepoRelDir: "modules/prod/db", Workspace: "default"},
+			{RepoRelDir: "modules/prod/web", Workspace: "default"},
+		}, nil)
+
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.PlanCommand, RepoRelDir: "modules/prod/*"})
+	vcsClient.VerifyWasCalled(Times(2)).CreateComment(matchers.AnyModelsRepo(), AnyInt(), AnyString())
+
+	t.Log("other plans should still comment once")
+	vcsClient = setup(t)
+	When(githubGetter.GetPullRequest(fixtures.GithubRepo, fixtures.Pull.Num)).ThenReturn(pull, nil)
+	When(eventParsing.ParseGithubPull(pull)).ThenReturn(modelPull, fixtures.GithubRepo, fixtures.GithubRepo, nil)
+	When(projectCommandBuilder.BuildPlanCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())).
+		ThenReturn([]models.ProjectCommandContext{
+			{RepoRelDir: "modules/prod/db", Workspace: "default"},
+			{RepoRelDir: "modules/prod/web", Workspace: "default"},
+		}, nil)
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.PlanCommand})
+	vcsClient.VerifyWasCalledOnce().CreateComment(matchers.AnyModelsRepo(), AnyInt(), AnyString())
+}
+
+func TestRunCommentCommand_HidePrevPlanComments(t *testing.T) {
+	t.Log("with --hide-prev-plan-comments our earlier plan comments whose " +
+		"projects were all planned again should be hidden before commenting")
+	vcsClient := setup(t)
+	ch.HidePrevPlanComments = true
+	projectCommandRunner := mocks.NewMockProjectCommandRunner()
+	ch.ProjectCommandRunner = projectCommandRunner
+	pull := &github.PullRequest{
+		State: github.String("open"),
+	}
+	modelPull := models.PullRequest{State: models.OpenPullState, Num: fixtures.Pull.Num}
+	dir1 := models.ProjectCommandContext{RepoRelDir: "dir1", Workspace: "default"}
+	dir2 := models.ProjectCommandContext{RepoRelDir: "dir2", Workspace: "default"}
+	When(githubGetter.GetPullRequest(fixtures.GithubRepo, fixtures.Pull.Num)).ThenReturn(pull, nil)
+	When(eventParsing.ParseGithubPull(pull)).ThenReturn(modelPull, fixtures.GithubRepo, fixtures.GithubRepo, nil)
+	When(projectCommandBuilder.BuildPlanCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())).
+		ThenReturn([]models.ProjectCommandContext{dir1, dir2}, nil)
+	// The contexts get a CancelCtx before they're run so they can't be
+	// matched exactly.
+	When(projectCommandRunner.Plan(matchers.AnyModelsProjectCommandContext())).Then(func(params []Param) ReturnValues {
+		if params[0].(models.ProjectCommandContext).RepoRelDir == "dir1" {
+			return ReturnValues{events.ProjectResult{RepoRelDir: "dir1", Workspace: "default", PlanSuccess: &events.PlanSuccess{}}}
+		}
+		return ReturnValues{events.ProjectResult{RepoRelDir: "dir2", Workspace: "default", ProjectName: "proj", Failure: "locked"}}
+	})
+
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.PlanCommand})
+	inOrder := new(InOrderContext)
+	_, _, shouldHide := vcsClient.VerifyWasCalledInOrder(Once(), inOrder).HidePrevComments(matchers.AnyModelsRepo(), AnyInt(), matchers.AnyFuncStringBool()).GetCapturedArguments()
+	vcsClient.VerifyWasCalledInOrder(Once(), inOrder).CreateComment(matchers.AnyModelsRepo(), AnyInt(), AnyString())
+
+	comments := []struct {
+		body    string
+		expHide bool
+	}{
+		{"Ran Plan for dir: `dir1` workspace: `default`\n\nplan output", true},
+		{"Continued from previous comment.\nmore plan output", true},
+		{"Ran Plan for 2 projects:\n1. dir: `dir1` workspace: `default`\n1. dir: `dir3` workspace: `default`\n\n### 1. dir: `dir1`", false},
+		{"Continued from previous comment.\nmore plan output", false},
+		{"Ran Plan for 2 projects:\n1. dir: `dir1` workspace: `default`\n1. project: `proj` dir: `dir2` workspace: `default`\n\n### 1. dir: `dir1`", true},
+		{"Ran Plan for project: `other` dir: `dir2` workspace: `default`\n\nplan output", false},
+		{"Ran Plan for dir: `dir2` workspace: `default`\n\n**Plan Error**", true},
+		{"Ran Apply for dir: `dir1` workspace: `default`\n\napply output", false},
+		{"atlantis plan", false},
+	}
+	for _, c := range comments {
+		Assert(t, c.expHide == shouldHide(c.body), "exp shouldHide to be %t for %q", c.expHide, c.body)
+	}
+}
+
+func TestRunCommentCommand_KeepsPrevPlanCommentsByDefault(t *testing.T) {
+	t.Log("without --hide-prev-plan-comments no comments should be hidden")
+	vcsClient := setup(t)
+	pull := &github.PullRequest{
+		State: github.String("open"),
+	}
+	modelPull := models.PullRequest{State: models.OpenPullState, Num: fixtures.Pull.Num}
+	When(githubGetter.GetPullRequest(fixtures.GithubRepo, fixtures.Pull.Num)).ThenReturn(pull, nil)
+	When(eventParsing.ParseGithubPull(pull)).ThenReturn(modelPull, fixtures.GithubRepo, fixtures.GithubRepo, nil)
+	When(projectCommandBuilder.BuildPlanCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())).
+		ThenReturn([]models.ProjectCommandContext{{RepoRelDir: "dir1", Workspace: "default"}}, nil)
+
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.PlanCommand})
+	vcsClient.VerifyWasCalled(Never()).HidePrevComments(matchers.AnyModelsRepo(), AnyInt(), matchers.AnyFuncStringBool())
+	vcsClient.VerifyWasCalledOnce().CreateComment(matchers.AnyModelsRepo(), AnyInt(), AnyString())
+}
+
+func TestRunCommentCommand_AppliesLocked(t *testing.T) {
+	t.Log("while applies are locked, apply should comment why instead of applying")
+	vcsClient := setup(t)
+	applyLocker := lockmocks.NewMockApplyLocker()
+	ch.ApplyLocker = applyLocker
+	pull := &github.PullRequest{
+		State: github.String("open"),
+	}
+	modelPull := models.PullRequest{State: models.OpenPullState, Num: fixtures.Pull.Num}
+	When(githubGetter.GetPullRequest(fixtures.GithubRepo, fixtures.Pull.Num)).ThenReturn(pull, nil)
+	When(eventParsing.ParseGithubPull(pull)).ThenReturn(modelPull, fixtures.GithubRepo, fixtures.GithubRepo, nil)
+	lockTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	When(applyLocker.GetApplyLock()).ThenReturn(&models.ApplyLock{Time: lockTime}, nil)
+
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.ApplyCommand})
+	projectCommandBuilder.VerifyWasCalled(Never()).BuildApplyCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, fixtures.Pull.Num, "**Error:** Applies have been locked since Thu, 02 Jan 2020 03:04:05 UTC so `atlantis apply` is disabled. Try again once they've been unlocked in the Atlantis UI.")
+
+	t.Log("import and state should be locked too")
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.ImportCommand, Flags: []string{"aws_instance.web", "i-123"}})
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.StateCommand, Flags: []string{"rm", "aws_instance.web"}})
+	projectCommandBuilder.VerifyWasCalled(Never()).BuildImportCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())
+	projectCommandBuilder.VerifyWasCalled(Never()).BuildStateCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, fixtures.Pull.Num, "**Error:** Applies have been locked since Thu, 02 Jan 2020 03:04:05 UTC so `atlantis import` is disabled. Try again once they've been unlocked in the Atlantis UI.")
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, fixtures.Pull.Num, "**Error:** Applies have been locked since Thu, 02 Jan 2020 03:04:05 UTC so `atlantis state` is disabled. Try again once they've been unlocked in the Atlantis UI.")
+
+	t.Log("plans should still run")
+	When(projectCommandBuilder.BuildPlanCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())).
+		ThenReturn([]models.ProjectCommandContext{{RepoRelDir: "dir1", Workspace: "default"}}, nil)
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.PlanCommand})
+	projectCommandBuilder.VerifyWasCalledOnce().BuildPlanCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())
+
+	t.Log("the comment should use the executable name")
+	ch.ExecutableName = "infra"
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.ApplyCommand})
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, fixtures.Pull.Num, "**Error:** Applies have been locked since Thu, 02 Jan 2020 03:04:05 UTC so `infra apply` is disabled. Try again once they've been unlocked in the Atlantis UI.")
+}
+
+func TestRunCommentCommand_ApplyLockErr(t *testing.T) {
+	t.Log("if we can't check if applies are locked, apply shouldn't run")
+	vcsClient := setup(t)
+	applyLocker := lockmocks.NewMockApplyLocker()
+	ch.ApplyLocker = applyLocker
+	pull := &github.PullRequest{
+		State: github.String("open"),
+	}
+	modelPull := models.PullRequest{State: models.OpenPullState, Num: fixtures.Pull.Num}
+	When(githubGetter.GetPullRequest(fixtures.GithubRepo, fixtures.Pull.Num)).ThenReturn(pull, nil)
+	When(eventParsing.ParseGithubPull(pull)).ThenReturn(modelPull, fixtures.GithubRepo, fixtures.GithubRepo, nil)
+	When(applyLocker.GetApplyLock()).ThenReturn(nil, errors.New("db is down"))
+
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.ApplyCommand})
+	projectCommandBuilder.VerifyWasCalled(Never()).BuildApplyCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, fixtures.Pull.Num, "**Error:** not running apply since Atlantis couldn't check if applies are locked: db is down")
+}
+
+func TestRunCommentCommand_ApplyNotAllowed(t *testing.T) {
+	t.Log("if the user can't apply, apply should comment why instead of applying")
+	vcsClient := setup(t)
+	checker := mocks.NewMockApplyPermissionChecker()
+	ch.ApplyPermissionChecker = checker
+	pull := &github.PullRequest{
+		State: github.String("open"),
+	}
+	modelPull := models.PullRequest{State: models.OpenPullState, Num: fixtures.Pull.Num}
+	When(githubGetter.GetPullRequest(fixtures.GithubRepo, fixtures.Pull.Num)).ThenReturn(pull, nil)
+	When(eventParsing.ParseGithubPull(pull)).ThenReturn(modelPull, fixtures.GithubRepo, fixtures.GithubRepo, nil)
+	When(checker.CheckApply(fixtures.GithubRepo, fixtures.User)).ThenReturn(errors.New("user can't apply"))
+
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.ApplyCommand})
+	projectCommandBuilder.VerifyWasCalled(Never()).BuildApplyCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, fixtures.Pull.Num, "**Error:** user can't apply")
+
+	t.Log("plans aren't restricted")
+	When(projectCommandBuilder.BuildPlanCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())).
+		ThenReturn([]models.ProjectCommandContext{{RepoRelDir: "dir1", Workspace: "default"}}, nil)
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.PlanCommand})
+	projectCommandBuilder.VerifyWasCalledOnce().BuildPlanCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())
+	checker.VerifyWasCalledOnce().CheckApply(matchers.AnyModelsRepo(), matchers.AnyModelsUser())
+}
+
+func TestRunCommentCommand_ChangesStateNotAllowed(t *testing.T) {
+	t.Log("if the user can't apply, they can't import or change state either")
+	for _, cmd := range []*events.CommentCommand{
+		{Name: events.ImportCommand, Flags: []string{"aws_instance.web", "i-123"}},
+		{Name: events.StateCommand, Flags: []string{"rm", "aws_instance.web"}},
+	} {
+		t.Run(cmd.Name.String(), func(t *testing.T) {
+			vcsClient := setup(t)
+			checker := mocks.NewMockApplyPermissionChecker()
+			ch.ApplyPermissionChecker = checker
+			pull := &github.PullRequest{
+				State: github.String("open"),
+			}
+			modelPull := models.PullRequest{State: models.OpenPullState, Num: fixtures.Pull.Num}
+			When(githubGetter.GetPullRequest(fixtures.GithubRepo, fixtures.Pull.Num)).ThenReturn(pull, nil)
+			When(eventParsing.ParseGithubPull(pull)).ThenReturn(modelPull, fixtures.GithubRepo, fixtures.GithubRepo, nil)
+			When(checker.CheckApply(fixtures.GithubRepo, fixtures.User)).ThenReturn(errors.New("user can't apply"))
+
+			ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, cmd)
+			projectCommandBuilder.VerifyWasCalled(Never()).BuildImportCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())
+			projectCommandBuilder.VerifyWasCalled(Never()).BuildStateCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())
+			vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, fixtures.Pull.Num, "**Error:** user can't apply")
+		})
+	}
+}
+
+func TestRunCommentCommand_DryRun(t *testing.T) {
+	t.Log("in dry-run mode, commands that change state should comment why instead of running")
+	vcsClient := setup(t)
+	ch.DryRun = true
+	pull := &github.PullRequest{
+		State: github.String("open"),
+	}
+	modelPull := models.PullRequest{State: models.OpenPullState, Num: fixtures.Pull.Num}
+	When(githubGetter.GetPullRequest(fixtures.GithubRepo, fixtures.Pull.Num)).ThenReturn(pull, nil)
+	When(eventParsing.ParseGithubPull(pull)).ThenReturn(modelPull, fixtures.GithubRepo, fixtures.GithubRepo, nil)
+
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.ApplyCommand})
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.ImportCommand})
+	projectCommandBuilder.VerifyWasCalled(Never()).BuildApplyCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())
+	projectCommandBuilder.VerifyWasCalled(Never()).BuildImportCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, fixtures.Pull.Num, "**Error:** Atlantis is running in dry-run mode so `atlantis apply` is disabled. It only plans so that it can be tried out without changing any infrastructure.")
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, fixtures.Pull.Num, "**Error:** Atlantis is running in dry-run mode so `atlantis import` is disabled. It only plans so that it can be tried out without changing any infrastructure.")
+
+	t.Log("plans should still run")
+	When(projectCommandBuilder.BuildPlanCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())).
+		ThenReturn([]models.ProjectCommandContext{{RepoRelDir: "dir1", Workspace: "default"}}, nil)
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.PlanCommand})
+	projectCommandBuilder.VerifyWasCalledOnce().BuildPlanCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())
+}
+
+func TestRunCommentCommand_ChangesNotAllowed(t *testing.T) {
+	t.Log("commands that change state should comment why instead of running " +
+		"if the server-side repo config doesn't allow them for the pull request")
+	vcsClient := setup(t)
+	ch.ServerConfig = valid.ServerConfig{
+		Repos: []valid.Repo{{ID: "/.*/", ApplyBranches: []string{"main"}}},
+	}
+	pull := &github.PullRequest{
+		State: github.String("open"),
+	}
+	modelPull := models.PullRequest{State: models.OpenPullState, Num: fixtures.Pull.Num, BaseBranch: "feature"}
+	When(githubGetter.GetPullRequest(fixtures.GithubRepo, fixtures.Pull.Num)).ThenReturn(pull, nil)
+	When(eventParsing.ParseGithubPull(pull)).ThenReturn(modelPull, fixtures.GithubRepo, fixtures.GithubRepo, nil)
+
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.ApplyCommand})
+	projectCommandBuilder.VerifyWasCalled(Never()).BuildApplyCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())
+	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.GithubRepo, fixtures.Pull.Num, "**Error:** `atlantis apply` is disabled: only pull requests into main can be applied in this repo, not ones into feature.")
+
+	t.Log("plans should still run")
+	When(projectCommandBuilder.BuildPlanCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())).
+		ThenReturn([]models.ProjectCommandContext{{RepoRelDir: "dir1", Workspace: "default"}}, nil)
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.PlanCommand})
+	projectCommandBuilder.VerifyWasCalledOnce().BuildPlanCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())
+}
+
+func TestRunCommentCommand_ApplyDependencyOrder(t *testing.T) {
+	t.Log("projects should be applied after the projects they depend on and " +
+		"not be applied if one of those fails")
+	vcsClient := setup(t)
+	runner := &recordingApplyRunner{failDirs: map[string]bool{"network": true}}
+	ch.ProjectCommandRunner = runner
+	pull := &github.PullRequest{
+		State: github.String("open"),
+	}
+	modelPull := models.PullRequest{State: models.OpenPullState, Num: fixtures.Pull.Num}
+	When(githubGetter.GetPullRequest(fixtures.GithubRepo, fixtures.Pull.Num)).ThenReturn(pull, nil)
+	When(eventParsing.ParseGithubPull(pull)).ThenReturn(modelPull, fixtures.GithubRepo, fixtures.GithubRepo, nil)
+	project := func(name string, dependsOn ...string) *valid.Project {
+		return &valid.Project{Name: &name, Dir: name, Workspace: "default", DependsOn: dependsOn}
+	}
+	When(projectCommandBuilder.BuildApplyCommands(matchers.AnyPtrToEventsCommandContext(), matchers.AnyPtrToEventsCommentCommand())).
+		ThenReturn([]models.ProjectCommandContext{
+			{RepoRelDir: "compute", Workspace: "default", ProjectConfig: project("compute", "network", "dns")},
+			{RepoRelDir: "dns", Workspace: "default", ProjectConfig: project("dns")},
+			{RepoRelDir: "network", Workspace: "default", ProjectConfig: project("network", "dns")},
+			{RepoRelDir: "other", Workspace: "default"},
+		}, nil)
+
+	ch.RunCommentCommand(fixtures.GithubRepo, &fixtures.GithubRepo, nil, fixtures.User, fixtures.Pull.Num, &events.CommentCommand{Name: events.ApplyCommand})
+
+	Equals(t, []string{"dns", "network", "other"}, runner.applied)
+	_, _, comment := vcsClient.VerifyWasCalledOnce().CreateComment(matchers.AnyModelsRepo(), AnyInt(), AnyString()).GetCapturedArguments()
+	Assert(t, strings.Contains(comment, `not applied because it depends on project "network" which failed to apply`), "exp compute to not be applied but got %q", comment)
+	dns := strings.Index(comment, "dir: `dns`")
+	network := strings.Index(comment, "dir: `network`")
+	compute := strings.Index(comment, "dir: `compute`")
+	Assert(t, dns != -1 && dns < network && network < compute, "exp results in dependency order but got %q", comment)
+}
+
+// recordingApplyRunner is a ProjectCommandRunner that records the dirs it
+// applies and fails the applies in failDirs.
+type recordingApplyRunner struct {
+	blockingPlanRunner
+	failDirs map[string]bool
+	applied  []string
+}
+
+func (r *recordingApplyRunner) Apply(ctx models.ProjectCommandContext) events.ProjectResult {
+	r.applied = append(r.applied, ctx.RepoRelDir)
+	res := events.ProjectResult{RepoRelDir: ctx.RepoRelDir, Workspace: ctx.Workspace, ProjectName: ctx.GetProjectName()}
+	if r.failDirs[ctx.RepoRelDir] {
+		res.Error = errors.New("apply failed")
+	} else {
+		res.ApplySuccess = "applied"
+	}
+	return res
+}
+
+// blockingPlanRunner is a ProjectCommandRunner whose plans block until
+// release is closed so we can see which plans run at the same time.
+type blockingPlanRunner struct {
+	mu                   sync.Mutex
+	running              map[string]int
+	sameWorkspaceOverlap bool
+	started              chan string
+	release              chan struct{}
+}
+
+func (b *blockingPlanRunner) Plan(ctx models.ProjectCommandContext) events.ProjectResult {
+	b.mu.Lock()
+	b.running[ctx.Workspace]++
+	if b.running[ctx.Workspace] > 1 {
+		b.sameWorkspaceOverlap = true
+	}
+	b.mu.Unlock()
+
+	b.started <- ctx.RepoRelDir
+	<-b.release
+
+	b.mu.Lock()
+	b.running[ctx.Workspace]--
+	b.mu.Unlock()
+	return events.ProjectResult{
+		RepoRelDir:  ctx.RepoRelDir,
+		Workspace:   ctx.Workspace,
+		PlanSuccess: &events.PlanSuccess{TerraformOutput: "plan " + ctx.RepoRelDir},
+	}
+}
+
+func (b *blockingPlanRunner) Apply(ctx models.ProjectCommandContext) events.ProjectResult {
+	return events.ProjectResult{}
+}
+
+func (b *blockingPlanRunner) ApprovePolicies(ctx models.ProjectCommandContext) events.ProjectResult {
+	return events.ProjectResult{}
+}
+
+func (b *blockingPlanRunner) Import(ctx models.ProjectCommandContext) events.ProjectResult {
+	return events.ProjectResult{}
+}
+
+func (b *blockingPlanRunner) State(ctx models.ProjectCommandContext) events.ProjectResult {
+	return events.ProjectResult{}
+}
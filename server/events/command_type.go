@@ -13,6 +13,8 @@
 
 package events
 
+import "strings"
+
 // CommandName is which command to run.
 type CommandName int
 
@@ -21,6 +23,25 @@ const (
 	ApplyCommand CommandName = iota
 	// PlanCommand is a command to run terraform plan.
 	PlanCommand
+	// CancelCommand is a command to cancel the commands running for a pull
+	// request.
+	CancelCommand
+	// ApprovePoliciesCommand is a command to approve the plans that failed
+	// their policy checks so they can be applied.
+	ApprovePoliciesCommand
+	// ImportCommand is a command to run terraform import.
+	ImportCommand
+	// StateCommand is a command to run terraform state rm or mv.
+	StateCommand
+	// UnlockCommand is a command to discard the plans and release the locks
+	// of a pull request.
+	UnlockCommand
+	// ToggleAutoplanCommand is a command to turn autoplanning of a pull
+	// request off or back on, ex. atlantis autoplan off.
+	ToggleAutoplanCommand
+	// ConfirmCommand is a command to run the apply that's waiting to be
+	// confirmed when applies require confirmation.
+	ConfirmCommand
 	// Adding more? Don't forget to update String() below
 )
 
@@ -31,6 +52,31 @@ func (c CommandName) String() string {
 		return "apply"
 	case PlanCommand:
 		return "plan"
+	case CancelCommand:
+		return "cancel"
+	case ApprovePoliciesCommand:
+		return "approve_policies"
+	case ImportCommand:
+		return "import"
+	case StateCommand:
+		return "state"
+	case UnlockCommand:
+		return "unlock"
+	case ToggleAutoplanCommand:
+		return "autoplan"
+	case ConfirmCommand:
+		return "confirm"
 	}
 	return ""
 }
+
+// TitleString returns c formatted for titles, ex. "Approve Policies".
+func (c CommandName) TitleString() string {
+	return strings.Title(strings.Replace(c.String(), "_", " ", -1))
+}
+
+// ChangesState returns true if c changes Terraform state, and so the
+// infrastructure it tracks, rather than just reading it.
+func (c CommandName) ChangesState() bool {
+	return c == ApplyCommand || c == ImportCommand || c == StateCommand
+}
@@ -17,11 +17,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/url"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
 
 	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	"github.com/runatlantis/atlantis/server/events/terraform"
 	"github.com/runatlantis/atlantis/server/events/yaml"
 	"github.com/spf13/pflag"
 )
@@ -35,9 +38,28 @@ const (
 	projectFlagShort   = "p"
 	verboseFlagLong    = "verbose"
 	verboseFlagShort   = ""
-	atlantisExecutable = "atlantis"
+	tfLogFlagLong      = "tf-log"
+	destroyFlagLong    = "destroy"
+	targetFlagLong     = "target"
 )
 
+// DefaultExecutableName is the name that comments start with to run Atlantis
+// commands unless CommentParser.ExecutableName is set, ex. atlantis plan.
+const DefaultExecutableName = "atlantis"
+
+// executableName returns name, or DefaultExecutableName if it's empty.
+func executableName(name string) string {
+	if name == "" {
+		return DefaultExecutableName
+	}
+	return name
+}
+
+// targetRegex matches the resource addresses that can be passed to -target,
+// ex. module.app.aws_instance.web[0] or aws_instance.web["key"]. It's strict
+// so that targets can't be used to run shell commands.
+var targetRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+(\.[A-Za-z0-9_-]+|\[([0-9]+|"[A-Za-z0-9_./:-]*")\])*$`)
+
 // multiLineRegex is used to ignore multi-line comments since those aren't valid
 // Atlantis commands. If the second line just has newlines then we let it pass
 // through because when you double click on a comment in GitHub and then you
@@ -68,6 +90,20 @@ type CommentParser struct {
 	GithubToken string
 	GitlabUser  string
 	GitlabToken string
+	// AzureDevopsUser is the Azure DevOps user we're running as.
+	AzureDevopsUser string
+	// GiteaUser is the Gitea user we're running as.
+	GiteaUser string
+	// ExecutableName is the name that comments start with to run commands,
+	// ex. atlantis-prod for atlantis-prod plan. It lets several Atlantis
+	// instances run against the same repo. Defaults to DefaultExecutableName.
+	ExecutableName string
+	// DisableTargetFlag is true if comments can't pass -target to plan,
+	// either with the -target flag or after --.
+	DisableTargetFlag bool
+	// DisableExtraArgs is true if comments can't pass extra args to
+	// terraform after --.
+	DisableExtraArgs bool
 }
 
 // CommentParseResult describes the result of parsing a comment as a command.
@@ -86,10 +122,17 @@ type CommentParseResult struct {
 //
 // Valid commands contain:
 // - The initial "executable" name, 'run' or 'atlantis' or '@GithubUser'
-//   where GithubUser is the API user Atlantis is running as.
-// - Then a command, either 'plan', 'apply', or 'help'.
-// - Then optional flags, then an optional separator '--' followed by optional
-//   extra flags to be appended to the terraform plan/apply command.
+//   where GithubUser is the API user Atlantis is running as. If
+//   ExecutableName is set, it replaces 'atlantis' and 'run' isn't accepted
+//   since other Atlantis instances would run it too.
+// - Then a command, either 'plan', 'apply', 'cancel', 'approve_policies',
+//   'import', 'state', 'unlock', 'autoplan', 'confirm' or 'help'.
+// - For 'state', then a subcommand, either 'rm' or 'mv'.
+// - For 'autoplan', then either 'on' or 'off'.
+// - Then optional flags, and for 'import' the resource's address and ID, or
+//   for 'state' the addresses to remove or move, then an optional separator
+//   '--' followed by optional extra flags to be appended to the terraform
+//   plan/apply/import/state command.
 //
 // Examples:
 // - atlantis help
@@ -97,6 +140,9 @@ type CommentParseResult struct {
 // - @GithubUser plan -w staging
 // - atlantis plan -w staging -d dir --verbose
 // - atlantis plan --verbose -- -key=value -key2 value2
+// - atlantis import -d dir aws_instance.web i-abcd1234
+// - atlantis state mv -d dir aws_instance.web aws_instance.app
+// - atlantis autoplan off
 //
 func (e *CommentParser) Parse(comment string, vcsHost models.VCSHostType) CommentParseResult {
 	if multiLineRegex.MatchString(comment) {
@@ -111,17 +157,27 @@ func (e *CommentParser) Parse(comment string, vcsHost models.VCSHostType) Commen
 	}
 
 	// Helpfully warn the user if they're using "terraform" instead of "atlantis"
-	if args[0] == "terraform" {
+	// unless we're one of several instances, which would all warn them.
+	executable := executableName(e.ExecutableName)
+	if args[0] == "terraform" && executable == DefaultExecutableName {
 		return CommentParseResult{CommentResponse: DidYouMeanAtlantisComment}
 	}
 
 	// Atlantis can be invoked using the name of the VCS host user we're
 	// running under. Need to be able to match against that user.
 	vcsUser := e.GithubUser
-	if vcsHost == models.Gitlab {
+	switch vcsHost {
+	case models.Gitlab:
 		vcsUser = e.GitlabUser
+	case models.AzureDevops:
+		vcsUser = e.AzureDevopsUser
+	case models.Gitea:
+		vcsUser = e.GiteaUser
+	}
+	executableNames := []string{executable, "@" + vcsUser}
+	if executable == DefaultExecutableName {
+		executableNames = append(executableNames, "run")
 	}
-	executableNames := []string{"run", atlantisExecutable, "@" + vcsUser}
 
 	// If the comment doesn't start with the name of our 'executable' then
 	// ignore it.
@@ -132,24 +188,28 @@ func (e *CommentParser) Parse(comment string, vcsHost models.VCSHostType) Commen
 	// If they've just typed the name of the executable then give them the help
 	// output.
 	if len(args) == 1 {
-		return CommentParseResult{CommentResponse: HelpComment}
+		return CommentParseResult{CommentResponse: e.helpComment()}
 	}
 	command := args[1]
 
 	// Help output.
 	if e.stringInSlice(command, []string{"help", "-h", "--help"}) {
-		return CommentParseResult{CommentResponse: HelpComment}
+		return CommentParseResult{CommentResponse: e.helpComment()}
 	}
 
-	// Need to have a plan or apply at this point.
-	if !e.stringInSlice(command, []string{PlanCommand.String(), ApplyCommand.String()}) {
-		return CommentParseResult{CommentResponse: fmt.Sprintf("```\nError: unknown command %q.\nRun 'atlantis --help' for usage.\n```", command)}
+	// Need to have a plan, apply, cancel, approve_policies, import, state,
+	// unlock, autoplan or confirm at this point.
+	if !e.stringInSlice(command, []string{PlanCommand.String(), ApplyCommand.String(), CancelCommand.String(), ApprovePoliciesCommand.String(), ImportCommand.String(), StateCommand.String(), UnlockCommand.String(), ToggleAutoplanCommand.String(), ConfirmCommand.String()}) {
+		return CommentParseResult{CommentResponse: fmt.Sprintf("```\nError: unknown command %q.\nRun '%s --help' for usage.\n```", command, executable)}
 	}
 
 	var workspace string
 	var dir string
 	var project string
 	var verbose bool
+	var tfLogLevel string
+	var destroy bool
+	var targets []string
 	var extraArgs []string
 	var flagSet *pflag.FlagSet
 	var name CommandName
@@ -164,13 +224,53 @@ func (e *CommentParser) Parse(comment string, vcsHost models.VCSHostType) Commen
 		flagSet.StringVarP(&dir, dirFlagLong, dirFlagShort, "", "Which directory to run plan in relative to root of repo, ex. 'child/dir'.")
 		flagSet.StringVarP(&project, projectFlagLong, projectFlagShort, "", fmt.Sprintf("Which project to run plan for. Refers to the name of the project configured in %s. Cannot be used at same time as workspace or dir flags.", yaml.AtlantisYAMLFilename))
 		flagSet.BoolVarP(&verbose, verboseFlagLong, verboseFlagShort, false, "Append Atlantis log to comment.")
+		flagSet.StringVar(&tfLogLevel, tfLogFlagLong, "", "Run plan with TF_LOG set to this level, ex. INFO, and add the log to the comment.")
+		flagSet.BoolVar(&destroy, destroyFlagLong, false, "Plan to destroy all the resources. Applying a destroy plan may have stricter requirements.")
+		flagSet.StringArrayVar(&targets, targetFlagLong, nil, "Only plan the resource at `address` and its dependencies, ex. aws_instance.web. Can be repeated.")
 	case ApplyCommand.String():
 		name = ApplyCommand
 		flagSet = pflag.NewFlagSet(ApplyCommand.String(), pflag.ContinueOnError)
 		flagSet.SetOutput(ioutil.Discard)
 		flagSet.StringVarP(&workspace, workspaceFlagLong, workspaceFlagShort, "", "Apply the plan for this Terraform workspace.")
 		flagSet.StringVarP(&dir, dirFlagLong, dirFlagShort, "", "Apply the plan for this directory, relative to root of repo, ex. 'child/dir'.")
-		flagSet.StringVarP(&project, projectFlagLong, projectFlagShort, "", fmt.Sprintf("Apply the plan for this project. Refers to the name of the project configured in %s. Cannot be used at same time as workspace or dir flags.", yaml.AtlantisYAMLFilename))
+		flagSet.StringVarP(&project, projectFlagLong, projectFlagShort, "", fmt.Sprintf("Apply the plans for these projects, separated by commas, ex. project1,project2. Refers to the names of the projects configured in %s. Cannot be used at same time as workspace or dir flags.", yaml.AtlantisYAMLFilename))
+		flagSet.BoolVarP(&verbose, verboseFlagLong, verboseFlagShort, false, "Append Atlantis log to comment.")
+	case CancelCommand.String():
+		name = CancelCommand
+		flagSet = pflag.NewFlagSet(CancelCommand.String(), pflag.ContinueOnError)
+		flagSet.SetOutput(ioutil.Discard)
+	case UnlockCommand.String():
+		name = UnlockCommand
+		flagSet = pflag.NewFlagSet(UnlockCommand.String(), pflag.ContinueOnError)
+		flagSet.SetOutput(ioutil.Discard)
+	case ToggleAutoplanCommand.String():
+		name = ToggleAutoplanCommand
+		flagSet = pflag.NewFlagSet(ToggleAutoplanCommand.String(), pflag.ContinueOnError)
+		flagSet.SetOutput(ioutil.Discard)
+	case ConfirmCommand.String():
+		name = ConfirmCommand
+		flagSet = pflag.NewFlagSet(ConfirmCommand.String(), pflag.ContinueOnError)
+		flagSet.SetOutput(ioutil.Discard)
+	case ApprovePoliciesCommand.String():
+		name = ApprovePoliciesCommand
+		flagSet = pflag.NewFlagSet(ApprovePoliciesCommand.String(), pflag.ContinueOnError)
+		flagSet.SetOutput(ioutil.Discard)
+		flagSet.BoolVarP(&verbose, verboseFlagLong, verboseFlagShort, false, "Append Atlantis log to comment.")
+	case ImportCommand.String():
+		name = ImportCommand
+		flagSet = pflag.NewFlagSet(ImportCommand.String(), pflag.ContinueOnError)
+		flagSet.SetOutput(ioutil.Discard)
+		flagSet.StringVarP(&workspace, workspaceFlagLong, workspaceFlagShort, "", "Import into this Terraform workspace.")
+		flagSet.StringVarP(&dir, dirFlagLong, dirFlagShort, "", "Which directory to run import in relative to root of repo, ex. 'child/dir'.")
+		flagSet.StringVarP(&project, projectFlagLong, projectFlagShort, "", fmt.Sprintf("Which project to run import for. Refers to the name of the project configured in %s. Cannot be used at same time as workspace or dir flags.", yaml.AtlantisYAMLFilename))
+		flagSet.BoolVarP(&verbose, verboseFlagLong, verboseFlagShort, false, "Append Atlantis log to comment.")
+	case StateCommand.String():
+		name = StateCommand
+		flagSet = pflag.NewFlagSet(StateCommand.String(), pflag.ContinueOnError)
+		flagSet.SetOutput(ioutil.Discard)
+		flagSet.StringVarP(&workspace, workspaceFlagLong, workspaceFlagShort, "", "Change the state of this Terraform workspace.")
+		flagSet.StringVarP(&dir, dirFlagLong, dirFlagShort, "", "Which directory to change the state of relative to root of repo, ex. 'child/dir'.")
+		flagSet.StringVarP(&project, projectFlagLong, projectFlagShort, "", fmt.Sprintf("Which project to change the state of. Refers to the name of the project configured in %s. Cannot be used at same time as workspace or dir flags.", yaml.AtlantisYAMLFilename))
 		flagSet.BoolVarP(&verbose, verboseFlagLong, verboseFlagShort, false, "Append Atlantis log to comment.")
 	default:
 		return CommentParseResult{CommentResponse: fmt.Sprintf("Error: unknown command %q – this is a bug", command)}
@@ -178,7 +278,7 @@ func (e *CommentParser) Parse(comment string, vcsHost models.VCSHostType) Commen
 
 	// Now parse the flags.
 	// It's safe to use [2:] because we know there's at least 2 elements in args.
-	err := flagSet.Parse(args[2:])
+	err := flagSet.Parse(e.normalizeLongFlags(args[2:]))
 	if err == pflag.ErrHelp {
 		return CommentParseResult{CommentResponse: fmt.Sprintf("```\nUsage of %s:\n%s\n```", command, flagSet.FlagUsagesWrapped(usagesCols))}
 	}
@@ -192,12 +292,54 @@ func (e *CommentParser) Parse(comment string, vcsHost models.VCSHostType) Commen
 	} else {
 		unusedArgs = flagSet.Args()[0:flagSet.ArgsLenAtDash()]
 	}
+	// Import's arguments are the address and ID of the resource to import.
+	var importAddress, importID string
+	if name == ImportCommand {
+		if len(unusedArgs) < 2 {
+			return CommentParseResult{CommentResponse: e.errMarkdown(fmt.Sprintf("import requires the address and ID of the resource to import, ex. %s import aws_instance.web i-abcd1234", executable), command, flagSet)}
+		}
+		importAddress, importID = unusedArgs[0], unusedArgs[1]
+		unusedArgs = unusedArgs[2:]
+	}
+	// State's arguments are the subcommand and the addresses it's run on.
+	var stateSubcommand string
+	var stateAddresses []string
+	if name == StateCommand {
+		if len(unusedArgs) > 0 {
+			stateSubcommand = unusedArgs[0]
+		}
+		switch {
+		case stateSubcommand == "rm" && len(unusedArgs) >= 2:
+			stateAddresses = unusedArgs[1:]
+		case stateSubcommand == "mv" && len(unusedArgs) == 3:
+			stateAddresses = unusedArgs[1:]
+		case stateSubcommand == "rm":
+			return CommentParseResult{CommentResponse: e.errMarkdown(fmt.Sprintf("state rm requires the addresses of the resources to remove, ex. %s state rm aws_instance.web", executable), command, flagSet)}
+		case stateSubcommand == "mv":
+			return CommentParseResult{CommentResponse: e.errMarkdown(fmt.Sprintf("state mv requires the source and destination addresses, ex. %s state mv aws_instance.web aws_instance.app", executable), command, flagSet)}
+		default:
+			return CommentParseResult{CommentResponse: e.errMarkdown(fmt.Sprintf("unknown state subcommand %q, must be rm or mv", stateSubcommand), command, flagSet)}
+		}
+		unusedArgs = nil
+	}
+	// Autoplan's argument is whether to turn autoplanning on or off.
+	var autoplanEnabled bool
+	if name == ToggleAutoplanCommand {
+		if len(unusedArgs) != 1 || (unusedArgs[0] != "on" && unusedArgs[0] != "off") {
+			return CommentParseResult{CommentResponse: e.errMarkdown(fmt.Sprintf("autoplan requires either on or off, ex. %s autoplan off", executable), command, flagSet)}
+		}
+		autoplanEnabled = unusedArgs[0] == "on"
+		unusedArgs = nil
+	}
 	if len(unusedArgs) > 0 {
 		return CommentParseResult{CommentResponse: e.errMarkdown(fmt.Sprintf("unknown argument(s) – %s", strings.Join(unusedArgs, " ")), command, flagSet)}
 	}
 
 	if flagSet.ArgsLenAtDash() != -1 {
 		extraArgsUnsafe := flagSet.Args()[flagSet.ArgsLenAtDash():]
+		if err := e.validateExtraArgs(extraArgsUnsafe, dir); err != nil {
+			return CommentParseResult{CommentResponse: e.errMarkdown(err.Error(), command, flagSet)}
+		}
 		// Quote all extra args so there isn't a security issue when we append
 		// them to the terraform commands, ex. "; cat /etc/passwd"
 		for _, arg := range extraArgsUnsafe {
@@ -206,10 +348,17 @@ func (e *CommentParser) Parse(comment string, vcsHost models.VCSHostType) Commen
 		}
 	}
 
+	if err := e.validateTargets(targets, flagSet); err != nil {
+		return CommentParseResult{CommentResponse: e.errMarkdown(err.Error(), command, flagSet)}
+	}
+
 	dir, err = e.validateDir(dir)
 	if err != nil {
 		return CommentParseResult{CommentResponse: e.errMarkdown(err.Error(), command, flagSet)}
 	}
+	if IsGlob(dir) && name != PlanCommand {
+		return CommentParseResult{CommentResponse: e.errMarkdown(fmt.Sprintf("-%s/--%s can only be a glob with %s", dirFlagShort, dirFlagLong, PlanCommand.String()), command, flagSet)}
+	}
 
 	// Use the same validation that Terraform uses: https://git.io/vxGhU. Plus
 	// we also don't allow '..'. We don't want the workspace to contain a path
@@ -228,8 +377,38 @@ func (e *CommentParser) Parse(comment string, vcsHost models.VCSHostType) Commen
 		return CommentParseResult{CommentResponse: e.errMarkdown(err, command, flagSet)}
 	}
 
+	// Only apply can be run for several projects at once, ex.
+	// atlantis apply -p project1,project2.
+	if strings.Contains(project, ",") {
+		if name != ApplyCommand {
+			return CommentParseResult{CommentResponse: e.errMarkdown(fmt.Sprintf("-%s/--%s can only name several projects with %s", projectFlagShort, projectFlagLong, ApplyCommand.String()), command, flagSet)}
+		}
+		var err error
+		project, err = e.validateProjects(project)
+		if err != nil {
+			return CommentParseResult{CommentResponse: e.errMarkdown(err.Error(), command, flagSet)}
+		}
+	}
+
+	if tfLogLevel != "" {
+		var ok bool
+		tfLogLevel, ok = terraform.NormalizeLogLevel(tfLogLevel)
+		if !ok || !terraform.IsCommentLogLevel(tfLogLevel) {
+			return CommentParseResult{CommentResponse: e.errMarkdown(fmt.Sprintf("invalid --%s level %q, must be one of %s", tfLogFlagLong, tfLogLevel, strings.Join(terraform.CommentLogLevels, ", ")), command, flagSet)}
+		}
+	}
+
+	cmd := NewCommentCommand(dir, extraArgs, name, verbose, workspace, project)
+	cmd.TFLogLevel = tfLogLevel
+	cmd.Destroy = destroy
+	cmd.Targets = targets
+	cmd.ImportAddress = importAddress
+	cmd.ImportID = importID
+	cmd.StateSubcommand = stateSubcommand
+	cmd.StateAddresses = stateAddresses
+	cmd.AutoplanEnabled = autoplanEnabled
 	return CommentParseResult{
-		Command: NewCommentCommand(dir, extraArgs, name, verbose, workspace, project),
+		Command: cmd,
 	}
 }
 
@@ -246,13 +425,13 @@ func (e *CommentParser) BuildPlanComment(repoRelDir string, workspace string, pr
 		}
 		commentFlags = fmt.Sprintf(" -- %s", strings.Join(flagsWithoutQuotes, " "))
 	}
-	return fmt.Sprintf("%s %s%s%s", atlantisExecutable, PlanCommand.String(), flags, commentFlags)
+	return fmt.Sprintf("%s %s%s%s", executableName(e.ExecutableName), PlanCommand.String(), flags, commentFlags)
 }
 
 // BuildApplyComment builds an apply comment for the specified args.
 func (e *CommentParser) BuildApplyComment(repoRelDir string, workspace string, project string) string {
 	flags := e.buildFlags(repoRelDir, workspace, project)
-	return fmt.Sprintf("%s %s%s", atlantisExecutable, ApplyCommand.String(), flags)
+	return fmt.Sprintf("%s %s%s", executableName(e.ExecutableName), ApplyCommand.String(), flags)
 }
 
 func (e *CommentParser) buildFlags(repoRelDir string, workspace string, project string) string {
@@ -280,6 +459,16 @@ func (e *CommentParser) validateDir(dir string) (string, error) {
 	if dir == "" {
 		return dir, nil
 	}
+	// Globs are usually quoted, ex. -d 'modules/*', but we don't run the
+	// comment through a shell so the quotes are still there.
+	if len(dir) > 1 && (dir[0] == '\'' || dir[0] == '"') && dir[len(dir)-1] == dir[0] {
+		dir = dir[1 : len(dir)-1]
+	}
+	if IsGlob(dir) {
+		if _, err := path.Match(dir, ""); err != nil {
+			return "", fmt.Errorf("invalid glob %q with -%s/--%s: %s", dir, dirFlagShort, dirFlagLong, err)
+		}
+	}
 	validatedDir := filepath.Clean(dir)
 	// Join with . so the path is relative. This helps us if they use '/',
 	// and is safe to do if their path is relative since it's a no-op.
@@ -294,6 +483,98 @@ func (e *CommentParser) validateDir(dir string) (string, error) {
 	return validatedDir, nil
 }
 
+// normalizeLongFlags rewrites -destroy and -target as --destroy and
+// --target so they can be written the same way as terraform's flags.
+// Otherwise they'd be parsed as -d estroy and -t arget. Args after -- are
+// passed to terraform so they're left alone.
+func (e *CommentParser) normalizeLongFlags(args []string) []string {
+	normalized := make([]string, len(args))
+	copy(normalized, args)
+	for i, arg := range normalized {
+		if arg == "--" {
+			break
+		}
+		for _, long := range []string{destroyFlagLong, targetFlagLong} {
+			if arg == "-"+long || strings.HasPrefix(arg, "-"+long+"=") {
+				normalized[i] = "-" + arg
+			}
+		}
+	}
+	return normalized
+}
+
+// validateProjects returns the comma-separated project names in projects
+// without any duplicates, or an error if any of them are empty.
+func (e *CommentParser) validateProjects(projects string) (string, error) {
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(projects, ",") {
+		if name == "" {
+			return "", fmt.Errorf("invalid project list %q: project names can't be empty", projects)
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ","), nil
+}
+
+// validateExtraArgs returns an error if extraArgs, the args after -- that
+// are appended to the terraform command, aren't allowed. The terraform
+// runner validates them again before running terraform but we check them
+// here so the error is commented before anything is run. dir is the -d flag.
+// Var files must be inside the repo when resolved against it. If it isn't set
+// the projects aren't known yet so the runner checks them against each
+// project's directory.
+func (e *CommentParser) validateExtraArgs(extraArgs []string, dir string) error {
+	if len(extraArgs) == 0 {
+		return nil
+	}
+	if e.DisableExtraArgs {
+		return fmt.Errorf("extra args after -- are disabled on this Atlantis server")
+	}
+	return runtime.ValidateExtraArgs(extraArgs, dir)
+}
+
+// validateTargets returns an error if the comment passes -target to
+// terraform, with the --target flag or after --, when it's disabled, or if
+// any of the targets isn't a resource address.
+func (e *CommentParser) validateTargets(targets []string, flagSet *pflag.FlagSet) error {
+	all := append([]string{}, targets...)
+	if flagSet.ArgsLenAtDash() != -1 {
+		extraArgs := flagSet.Args()[flagSet.ArgsLenAtDash():]
+		for i, arg := range extraArgs {
+			if !strings.HasPrefix(arg, "-") {
+				continue
+			}
+			name := strings.TrimLeft(arg, "-")
+			switch {
+			case name == targetFlagLong:
+				value := ""
+				if i+1 < len(extraArgs) {
+					value = extraArgs[i+1]
+				}
+				all = append(all, value)
+			case strings.HasPrefix(name, targetFlagLong+"="):
+				all = append(all, strings.TrimPrefix(name, targetFlagLong+"="))
+			}
+		}
+	}
+	if len(all) == 0 {
+		return nil
+	}
+	if e.DisableTargetFlag {
+		return fmt.Errorf("-%s is disabled on this Atlantis server", targetFlagLong)
+	}
+	for _, target := range all {
+		if !targetRegex.MatchString(target) {
+			return fmt.Errorf("invalid -%s %q, must be a resource address, ex. aws_instance.web", targetFlagLong, target)
+		}
+	}
+	return nil
+}
+
 func (e *CommentParser) stringInSlice(a string, list []string) bool {
 	for _, b := range list {
 		if b == a {
@@ -303,6 +584,11 @@ func (e *CommentParser) stringInSlice(a string, list []string) bool {
 	return false
 }
 
+// helpComment returns HelpComment with our executable name in its examples.
+func (e *CommentParser) helpComment() string {
+	return strings.Replace(HelpComment, DefaultExecutableName, executableName(e.ExecutableName), -1)
+}
+
 func (e *CommentParser) errMarkdown(errMsg string, command string, flagSet *pflag.FlagSet) string {
 	return fmt.Sprintf("```\nError: %s.\nUsage of %s:\n%s```", errMsg, command, flagSet.FlagUsagesWrapped(usagesCols))
 }
@@ -327,11 +613,26 @@ Examples:
   atlantis apply -d . -w staging
 
 Commands:
-  plan   Runs 'terraform plan' for the changes in this pull request.
-         To plan a specific project, use the -d, -w and -p flags.
-  apply  Runs 'terraform apply' on all unapplied plans from this pull request.
-         To only apply a specific plan, use the -d, -w and -p flags.
-  help   View help.
+  plan             Runs 'terraform plan' for the changes in this pull request.
+                   To plan a specific project, use the -d, -w and -p flags.
+  apply            Runs 'terraform apply' on all unapplied plans from this pull request.
+                   To only apply a specific plan, use the -d, -w and -p flags.
+  cancel           Cancels the plans and applies running for this pull request.
+  approve_policies Approves the plans from this pull request that failed their
+                   policy checks so they can be applied. Only policy owners
+                   can run it.
+  import           Runs 'terraform import ADDRESS ID' for a project. Its plan
+                   has to be run again afterwards.
+  state            Runs 'terraform state rm ADDRESS...' or
+                   'terraform state mv SOURCE DESTINATION' for a project.
+                   Its plan has to be run again afterwards.
+  unlock           Discards the plans and releases the locks of this pull
+                   request so other pull requests can plan its projects.
+  autoplan         Turns autoplanning of this pull request 'off' or back 'on'.
+                   Comment plans still run while it's off.
+  confirm          Runs the apply that's waiting to be confirmed if applies
+                   require confirmation.
+  help             View help.
 
 Flags:
   -h, --help   help for atlantis
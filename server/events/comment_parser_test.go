@@ -61,6 +61,53 @@ func TestParse_HelpResponse(t *testing.T) {
 	}
 }
 
+func TestParse_ExecutableName(t *testing.T) {
+	parser := events.CommentParser{
+		GithubUser:     "github-user",
+		GithubToken:    "github-token",
+		ExecutableName: "atlantis-prod",
+	}
+
+	t.Log("comments for other Atlantis instances are ignored")
+	for _, c := range []string{"atlantis plan", "run plan", "terraform plan", "atlantis-dev plan"} {
+		r := parser.Parse(c, models.Github)
+		Assert(t, r.Ignore, "expected Ignore to be true for comment %q", c)
+	}
+
+	r := parser.Parse("atlantis-prod plan -d dir", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, events.PlanCommand, r.Command.Name)
+	Equals(t, "dir", r.Command.RepoRelDir)
+
+	r = parser.Parse("@github-user apply", models.Github)
+	Equals(t, events.ApplyCommand, r.Command.Name)
+
+	r = parser.Parse("atlantis-prod help", models.Github)
+	Assert(t, strings.Contains(r.CommentResponse, "atlantis-prod plan"), "expected help to use the executable name, got %q", r.CommentResponse)
+	Assert(t, !strings.Contains(r.CommentResponse, "atlantis plan"), "expected help not to mention atlantis plan, got %q", r.CommentResponse)
+
+	Equals(t, "atlantis-prod plan -d dir", parser.BuildPlanComment("dir", "default", "", nil))
+	Equals(t, "atlantis-prod apply -p project", parser.BuildApplyComment("dir", "default", "project"))
+}
+
+func TestParse_ExecutableNameTerraform(t *testing.T) {
+	t.Log("terraform can be used as the executable name instead of getting the did you mean atlantis response")
+	parser := events.CommentParser{
+		GithubUser:     "github-user",
+		GithubToken:    "github-token",
+		ExecutableName: "terraform",
+	}
+	r := parser.Parse("terraform plan -d dir", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, events.PlanCommand, r.Command.Name)
+
+	r = parser.Parse("terraform", models.Github)
+	Assert(t, strings.Contains(r.CommentResponse, "terraform apply -d . -w staging"), "expected help to use the executable name, got %q", r.CommentResponse)
+
+	r = parser.Parse("terraform dance", models.Github)
+	Assert(t, strings.Contains(r.CommentResponse, "Run 'terraform --help' for usage."), "expected the error to use the executable name, got %q", r.CommentResponse)
+}
+
 func TestParse_UnusedArguments(t *testing.T) {
 	t.Log("if there are unused flags we return an error")
 	cases := []struct {
@@ -235,6 +282,27 @@ func TestParse_RelativeDirPath(t *testing.T) {
 	}
 }
 
+func TestParse_DirGlob(t *testing.T) {
+	for _, c := range []string{"atlantis plan -d modules/prod/*", "atlantis plan -d 'modules/prod/*'", `atlantis plan -d "modules/prod/*"`} {
+		r := commentParser.Parse(c, models.Github)
+		Equals(t, "", r.CommentResponse)
+		Equals(t, "modules/prod/*", r.Command.RepoRelDir)
+		Assert(t, r.Command.HasDirGlob(), "exp %q to have a dir glob", c)
+	}
+
+	t.Log("only plan supports globs")
+	r := commentParser.Parse("atlantis apply -d 'modules/*'", models.Github)
+	Assert(t, strings.Contains(r.CommentResponse, "Error: -d/--dir can only be a glob with plan"),
+		"expected CommentResponse %q to contain glob error", r.CommentResponse)
+
+	r = commentParser.Parse("atlantis plan -d 'modules/[prod'", models.Github)
+	Assert(t, strings.Contains(r.CommentResponse, `Error: invalid glob "modules/[prod" with -d/--dir`),
+		"expected CommentResponse %q to contain invalid glob error", r.CommentResponse)
+
+	r = commentParser.Parse("atlantis plan -d modules/prod", models.Github)
+	Assert(t, !r.Command.HasDirGlob(), "exp no dir glob")
+}
+
 // If there's multiple lines but it's whitespace, allow the command. This
 // occurs when you copy and paste via GitHub.
 func TestParse_Multiline(t *testing.T) {
@@ -296,6 +364,264 @@ func TestParse_UsingProjectAtSameTimeAsWorkspaceOrDir(t *testing.T) {
 	}
 }
 
+func TestParse_TFLog(t *testing.T) {
+	r := commentParser.Parse("atlantis plan -d dir --tf-log=info", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, "INFO", r.Command.TFLogLevel)
+
+	r = commentParser.Parse("atlantis plan --tf-log=loud", models.Github)
+	exp := `Error: invalid --tf-log level "LOUD", must be one of INFO, WARN, ERROR`
+	Assert(t, strings.Contains(r.CommentResponse, exp),
+		"expected CommentResponse %q to contain %q", r.CommentResponse, exp)
+
+	t.Log("levels that log providers' requests can't be picked in comments")
+	for _, level := range []string{"trace", "debug"} {
+		r = commentParser.Parse("atlantis plan --tf-log="+level, models.Github)
+		exp = fmt.Sprintf(`Error: invalid --tf-log level %q, must be one of INFO, WARN, ERROR`, strings.ToUpper(level))
+		Assert(t, strings.Contains(r.CommentResponse, exp),
+			"expected CommentResponse %q to contain %q", r.CommentResponse, exp)
+	}
+
+	t.Log("apply doesn't support --tf-log")
+	r = commentParser.Parse("atlantis apply --tf-log=debug", models.Github)
+	Assert(t, strings.Contains(r.CommentResponse, "unknown flag: --tf-log"),
+		"expected CommentResponse %q to contain unknown flag error", r.CommentResponse)
+}
+
+func TestParse_Destroy(t *testing.T) {
+	for _, c := range []string{"atlantis plan -destroy", "atlantis plan --destroy -d dir", "atlantis plan -d dir -destroy -- -var a=b"} {
+		r := commentParser.Parse(c, models.Github)
+		Equals(t, "", r.CommentResponse)
+		Assert(t, r.Command.Destroy, "exp %q to be a destroy plan", c)
+	}
+
+	r := commentParser.Parse("atlantis plan -d dir", models.Github)
+	Assert(t, !r.Command.Destroy, "exp not to be a destroy plan")
+
+	t.Log("-destroy after -- is passed to terraform")
+	r = commentParser.Parse("atlantis plan -- -destroy", models.Github)
+	Assert(t, !r.Command.Destroy, "exp not to be a destroy plan")
+	Equals(t, []string{`"-destroy"`}, r.Command.Flags)
+
+	t.Log("apply doesn't support -destroy")
+	r = commentParser.Parse("atlantis apply -destroy", models.Github)
+	Assert(t, strings.Contains(r.CommentResponse, "unknown flag: --destroy"),
+		"expected CommentResponse %q to contain unknown flag error", r.CommentResponse)
+}
+
+func TestParse_Target(t *testing.T) {
+	r := commentParser.Parse(`atlantis plan -d dir -target=aws_instance.web --target module.app.aws_instance.db["key"]`, models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, []string{"aws_instance.web", `module.app.aws_instance.db["key"]`}, r.Command.Targets)
+
+	t.Log("targets after -- are passed to terraform")
+	r = commentParser.Parse("atlantis plan -- -target=aws_instance.web[0]", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, 0, len(r.Command.Targets))
+	Equals(t, []string{`"-target=aws_instance.web[0]"`}, r.Command.Flags)
+
+	for _, c := range []string{
+		"atlantis plan -target=aws_instance.web;rm",
+		"atlantis plan -target=$(whoami)",
+		"atlantis plan -- -target aws_instance.web[x]",
+		"atlantis plan -- --target=",
+	} {
+		r = commentParser.Parse(c, models.Github)
+		Assert(t, strings.Contains(r.CommentResponse, "must be a resource address"),
+			"expected CommentResponse %q for %q to contain invalid target error", r.CommentResponse, c)
+	}
+
+	t.Log("-target can be disabled")
+	parser := events.CommentParser{DisableTargetFlag: true}
+	for _, c := range []string{"atlantis plan -target=aws_instance.web", "atlantis plan -- -target aws_instance.web"} {
+		r = parser.Parse(c, models.Github)
+		Assert(t, strings.Contains(r.CommentResponse, "Error: -target is disabled on this Atlantis server"),
+			"expected CommentResponse %q for %q to contain disabled error", r.CommentResponse, c)
+	}
+	r = parser.Parse("atlantis plan -- -var target=x", models.Github)
+	Equals(t, "", r.CommentResponse)
+}
+
+func TestParse_ExtraArgs(t *testing.T) {
+	r := commentParser.Parse("atlantis plan -d dir -- -var-file=staging.tfvars -var-file ../common.tfvars", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, []string{`"-var-file=staging.tfvars"`, `"-var-file"`, `"../common.tfvars"`}, r.Command.Flags)
+
+	cases := map[string]string{
+		"atlantis plan -- -var-file=/etc/passwd": `Error: -var-file "/etc/passwd" must be relative to the project's directory`,
+		"atlantis plan -- --var-file ~/secrets":  `Error: -var-file "~/secrets" must be relative to the project's directory`,
+		"atlantis plan -- -var a=$(whoami)":      `Error: extra arg "a=$(whoami)" can't contain shell metacharacters`,
+		"atlantis plan -- -var a=`whoami`":       "Error: extra arg \"a=`whoami`\" can't contain shell metacharacters",
+		`atlantis plan -- -var a=\b`:             `Error: extra arg "a=\\b" can't contain shell metacharacters`,
+		`atlantis plan -- ";echo "hi`:            `Error: extra arg "\";echo" can't contain shell metacharacters`,
+		"atlantis plan -- -lock=false && rm":     `Error: extra arg "&&" can't contain shell metacharacters`,
+		"atlantis plan -- -state=/tmp/state":     `Error: extra arg "-state=/tmp/state" isn't allowed, only -allow-missing-config`,
+		"atlantis plan -- -lock=false arg":       `Error: extra arg "arg" isn't a flag, only -allow-missing-config`,
+
+		// Var files are resolved against -d, if it's set.
+		"atlantis plan -d dir -- -var-file=../../../../../etc/passwd": `Error: -var-file "../../../../../etc/passwd" must be inside the repo`,
+		"atlantis plan -d . -- -var-file ../../..":                    `Error: -var-file "../../.." must be inside the repo`,
+		"atlantis apply -d dir -- -var-file=sub/../../../x":           `Error: -var-file "sub/../../../x" must be inside the repo`,
+	}
+	for c, exp := range cases {
+		r = commentParser.Parse(c, models.Github)
+		Assert(t, strings.Contains(r.CommentResponse, exp),
+			"expected CommentResponse %q for %q to contain %q", r.CommentResponse, c, exp)
+	}
+
+	t.Log("extra args can be disabled")
+	parser := events.CommentParser{DisableExtraArgs: true}
+	r = parser.Parse("atlantis plan -- -var-file=staging.tfvars", models.Github)
+	Assert(t, strings.Contains(r.CommentResponse, "Error: extra args after -- are disabled on this Atlantis server"),
+		"expected CommentResponse %q to contain disabled error", r.CommentResponse)
+	r = parser.Parse("atlantis plan -d dir", models.Github)
+	Equals(t, "", r.CommentResponse)
+}
+
+func TestParse_Cancel(t *testing.T) {
+	r := commentParser.Parse("atlantis cancel", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, events.CancelCommand, r.Command.Name)
+
+	t.Log("cancel doesn't take any flags")
+	r = commentParser.Parse("atlantis cancel -d dir", models.Github)
+	Assert(t, strings.Contains(r.CommentResponse, "unknown shorthand flag: 'd'"),
+		"expected CommentResponse %q to contain unknown flag error", r.CommentResponse)
+}
+
+func TestParse_Unlock(t *testing.T) {
+	r := commentParser.Parse("atlantis unlock", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, events.UnlockCommand, r.Command.Name)
+
+	t.Log("unlock doesn't take any flags")
+	r = commentParser.Parse("atlantis unlock -p project", models.Github)
+	Assert(t, strings.Contains(r.CommentResponse, "unknown shorthand flag: 'p'"),
+		"expected CommentResponse %q to contain unknown flag error", r.CommentResponse)
+}
+
+func TestParse_ApplyProjects(t *testing.T) {
+	r := commentParser.Parse("atlantis apply -p project1,project2,project1", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, "project1,project2", r.Command.ProjectName)
+	Equals(t, []string{"project1", "project2"}, r.Command.ProjectNames())
+
+	r = commentParser.Parse("atlantis apply -p project1,,project2", models.Github)
+	Assert(t, strings.Contains(r.CommentResponse, `invalid project list "project1,,project2": project names can't be empty`),
+		"expected CommentResponse %q to contain empty project error", r.CommentResponse)
+
+	for _, cmd := range []string{"plan", "import -p project1,project2 addr id", "state rm -p project1,project2 addr"} {
+		comment := "atlantis " + cmd
+		if cmd == "plan" {
+			comment += " -p project1,project2"
+		}
+		t.Run(comment, func(t *testing.T) {
+			r := commentParser.Parse(comment, models.Github)
+			Assert(t, strings.Contains(r.CommentResponse, "-p/--project can only name several projects with apply"),
+				"expected CommentResponse %q to contain several projects error", r.CommentResponse)
+		})
+	}
+}
+
+func TestParse_Autoplan(t *testing.T) {
+	r := commentParser.Parse("atlantis autoplan off", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, events.ToggleAutoplanCommand, r.Command.Name)
+	Equals(t, false, r.Command.AutoplanEnabled)
+
+	r = commentParser.Parse("atlantis autoplan on", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, true, r.Command.AutoplanEnabled)
+
+	for _, comment := range []string{"atlantis autoplan", "atlantis autoplan maybe", "atlantis autoplan on off"} {
+		t.Run(comment, func(t *testing.T) {
+			r := commentParser.Parse(comment, models.Github)
+			Assert(t, strings.Contains(r.CommentResponse, "autoplan requires either on or off, ex. atlantis autoplan off"),
+				"expected CommentResponse %q to contain on or off error", r.CommentResponse)
+		})
+	}
+}
+
+func TestParse_Confirm(t *testing.T) {
+	r := commentParser.Parse("atlantis confirm", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, events.ConfirmCommand, r.Command.Name)
+
+	t.Log("confirm doesn't take any flags")
+	r = commentParser.Parse("atlantis confirm -p project", models.Github)
+	Assert(t, strings.Contains(r.CommentResponse, "unknown shorthand flag: 'p'"),
+		"expected CommentResponse %q to contain unknown flag error", r.CommentResponse)
+}
+
+func TestParse_ApprovePolicies(t *testing.T) {
+	r := commentParser.Parse("atlantis approve_policies --verbose", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, events.ApprovePoliciesCommand, r.Command.Name)
+	Equals(t, true, r.Command.Verbose)
+
+	t.Log("approve_policies approves all failed plans so it doesn't take project flags")
+	r = commentParser.Parse("atlantis approve_policies -d dir", models.Github)
+	Assert(t, strings.Contains(r.CommentResponse, "unknown shorthand flag: 'd'"),
+		"expected CommentResponse %q to contain unknown flag error", r.CommentResponse)
+}
+
+func TestParse_Import(t *testing.T) {
+	r := commentParser.Parse(`atlantis import -d dir -w staging aws_instance.web["a"] i-abcd1234 -- -var=a=b`, models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, events.ImportCommand, r.Command.Name)
+	Equals(t, "dir", r.Command.RepoRelDir)
+	Equals(t, "staging", r.Command.Workspace)
+	Equals(t, `aws_instance.web["a"]`, r.Command.ImportAddress)
+	Equals(t, "i-abcd1234", r.Command.ImportID)
+	Equals(t, []string{`"-var=a=b"`}, r.Command.Flags)
+
+	r = commentParser.Parse("atlantis import -p project aws_instance.web i-abcd1234", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, "project", r.Command.ProjectName)
+
+	t.Log("import needs both the address and the ID")
+	r = commentParser.Parse("atlantis import aws_instance.web", models.Github)
+	Assert(t, strings.Contains(r.CommentResponse, "Error: import requires the address and ID of the resource to import"),
+		"expected CommentResponse %q to contain missing args error", r.CommentResponse)
+
+	r = commentParser.Parse("atlantis import aws_instance.web i-abcd1234 extra", models.Github)
+	Assert(t, strings.Contains(r.CommentResponse, "Error: unknown argument(s) – extra."),
+		"expected CommentResponse %q to contain unknown argument error", r.CommentResponse)
+}
+
+func TestParse_State(t *testing.T) {
+	r := commentParser.Parse("atlantis state rm -d dir -w staging aws_instance.web aws_instance.app -- -dry-run", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, events.StateCommand, r.Command.Name)
+	Equals(t, "dir", r.Command.RepoRelDir)
+	Equals(t, "staging", r.Command.Workspace)
+	Equals(t, "rm", r.Command.StateSubcommand)
+	Equals(t, []string{"aws_instance.web", "aws_instance.app"}, r.Command.StateAddresses)
+	Equals(t, []string{`"-dry-run"`}, r.Command.Flags)
+
+	r = commentParser.Parse("atlantis state mv -p project aws_instance.web aws_instance.app", models.Github)
+	Equals(t, "", r.CommentResponse)
+	Equals(t, "project", r.Command.ProjectName)
+	Equals(t, "mv", r.Command.StateSubcommand)
+	Equals(t, []string{"aws_instance.web", "aws_instance.app"}, r.Command.StateAddresses)
+
+	cases := map[string]string{
+		"atlantis state":                         `Error: unknown state subcommand ""`,
+		"atlantis state list":                    `Error: unknown state subcommand "list"`,
+		"atlantis state rm":                      "Error: state rm requires the addresses of the resources to remove",
+		"atlantis state mv aws_instance.web":     "Error: state mv requires the source and destination addresses",
+		"atlantis state mv a.b c.d e.f":          "Error: state mv requires the source and destination addresses",
+		"atlantis state rm -d .. aws_instance.a": "Error: using a relative path",
+	}
+	for comment, expErr := range cases {
+		t.Run(comment, func(t *testing.T) {
+			r := commentParser.Parse(comment, models.Github)
+			Assert(t, strings.Contains(r.CommentResponse, expErr),
+				"expected CommentResponse %q to contain %q", r.CommentResponse, expErr)
+		})
+	}
+}
+
 func TestParse_Parsing(t *testing.T) {
 	cases := []struct {
 		flags        string
@@ -415,19 +741,19 @@ func TestParse_Parsing(t *testing.T) {
 		},
 		// Test that flags after -- are ignored
 		{
-			"-w workspace -d dir -- --verbose",
+			"-w workspace -d dir -- --destroy",
 			"workspace",
 			"dir",
 			false,
-			"\"--verbose\"",
+			"\"--destroy\"",
 			"",
 		},
 		{
-			"-w workspace -- -d dir --verbose",
+			"-w workspace -- -var-file dir --destroy",
 			"workspace",
 			"",
 			false,
-			"\"-d\" \"dir\" \"--verbose\"",
+			"\"-var-file\" \"dir\" \"--destroy\"",
 			"",
 		},
 		// Test the extra args parsing.
@@ -441,36 +767,36 @@ func TestParse_Parsing(t *testing.T) {
 		},
 		// Test trying to escape quoting
 		{
-			"-- \";echo \"hi",
+			"-- -var \"a=\"b",
 			"",
 			"",
 			false,
-			`"\";echo" "\"hi"`,
+			`"-var" "\"a=\"b"`,
 			"",
 		},
 		{
-			"-w workspace -d dir --verbose -- arg one -two --three &&",
+			"-w workspace -d dir --verbose -- -var one=1 -lock=false --refresh=false",
 			"workspace",
 			"dir",
 			true,
-			"\"arg\" \"one\" \"-two\" \"--three\" \"&&\"",
+			"\"-var\" \"one=1\" \"-lock=false\" \"--refresh=false\"",
 			"",
 		},
 		// Test whitespace.
 		{
-			"\t-w\tworkspace\t-d\tdir\t--verbose\t--\targ\tone\t-two\t--three\t&&",
+			"\t-w\tworkspace\t-d\tdir\t--verbose\t--\t-var\tone=1\t-lock=false\t--refresh=false",
 			"workspace",
 			"dir",
 			true,
-			"\"arg\" \"one\" \"-two\" \"--three\" \"&&\"",
+			"\"-var\" \"one=1\" \"-lock=false\" \"--refresh=false\"",
 			"",
 		},
 		{
-			"   -w   workspace   -d   dir   --verbose   --   arg   one   -two   --three   &&",
+			"   -w   workspace   -d   dir   --verbose   --   -var   one=1   -lock=false   --refresh=false",
 			"workspace",
 			"dir",
 			true,
-			"\"arg\" \"one\" \"-two\" \"--three\" \"&&\"",
+			"\"-var\" \"one=1\" \"-lock=false\" \"--refresh=false\"",
 			"",
 		},
 		// Test that the dir string is normalized.
@@ -629,11 +955,17 @@ func TestBuildPlanApplyComment(t *testing.T) {
 }
 
 var PlanUsage = `Usage of plan:
+      --destroy            Plan to destroy all the resources. Applying a destroy
+                           plan may have stricter requirements.
   -d, --dir string         Which directory to run plan in relative to root of repo,
                            ex. 'child/dir'.
   -p, --project string     Which project to run plan for. Refers to the name of the
                            project configured in atlantis.yaml. Cannot be used at
                            same time as workspace or dir flags.
+      --target address     Only plan the resource at address and its dependencies,
+                           ex. aws_instance.web. Can be repeated.
+      --tf-log string      Run plan with TF_LOG set to this level, ex. INFO, and add
+                           the log to the comment.
       --verbose            Append Atlantis log to comment.
   -w, --workspace string   Switch to this Terraform workspace before planning.
 `
@@ -641,9 +973,10 @@ var PlanUsage = `Usage of plan:
 var ApplyUsage = `Usage of apply:
   -d, --dir string         Apply the plan for this directory, relative to root of
                            repo, ex. 'child/dir'.
-  -p, --project string     Apply the plan for this project. Refers to the name of
-                           the project configured in atlantis.yaml. Cannot be used
-                           at same time as workspace or dir flags.
+  -p, --project string     Apply the plans for these projects, separated by commas,
+                           ex. project1,project2. Refers to the names of the
+                           projects configured in atlantis.yaml. Cannot be used at
+                           same time as workspace or dir flags.
       --verbose            Append Atlantis log to comment.
   -w, --workspace string   Apply the plan for this Terraform workspace.
 `
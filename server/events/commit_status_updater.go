@@ -24,28 +24,65 @@ import (
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_commit_status_updater.go CommitStatusUpdater
 
 // CommitStatusUpdater updates the status of a commit with the VCS host. We set
-// the status to signify whether the plan/apply succeeds.
+// the status to signify whether the plan/apply succeeds. Each command has its
+// own status, ex. atlantis/plan, that covers all the projects, and each
+// project has its own status for each command, ex.
+// atlantis/plan: dir/workspace.
 type CommitStatusUpdater interface {
-	// Update updates the status of the head commit of pull.
+	// Update updates the status of command on the head commit of pull.
 	Update(repo models.Repo, pull models.PullRequest, status models.CommitStatus, command CommandName) error
-	// UpdateProjectResult updates the status of the head commit given the
-	// state of response.
+	// UpdateProjectResult updates the statuses of the head commit given the
+	// state of response: one for each project and one for the command.
 	UpdateProjectResult(ctx *CommandContext, commandName CommandName, res CommandResult) error
+	// UpdatePlanRequired updates the status of the head commit of pull to
+	// say that its plans were discarded and plan has to be run again.
+	UpdatePlanRequired(repo models.Repo, pull models.PullRequest) error
+	// UpdatePullClosed updates the status of the head commit of pull to say
+	// that its plans were discarded because it was closed.
+	UpdatePullClosed(repo models.Repo, pull models.PullRequest) error
 }
 
 // DefaultCommitStatusUpdater implements CommitStatusUpdater.
 type DefaultCommitStatusUpdater struct {
 	Client vcs.ClientProxy
+	// FailOnDestroy is true if plans that exceeded the destroy threshold
+	// should set a failing status rather than a successful one.
+	FailOnDestroy bool
+	// StatusName starts the names of the statuses, ex. atlantis-prod sets
+	// atlantis-prod/plan. Defaults to DefaultStatusName.
+	StatusName string
 }
 
+// DefaultStatusName starts the names of the statuses unless StatusName is set.
+const DefaultStatusName = "atlantis"
+
 // Update updates the commit status.
 func (d *DefaultCommitStatusUpdater) Update(repo models.Repo, pull models.PullRequest, status models.CommitStatus, command CommandName) error {
-	description := fmt.Sprintf("%s %s", strings.Title(command.String()), strings.Title(status.String()))
-	return d.Client.UpdateStatus(repo, pull, status, description)
+	description := fmt.Sprintf("%s %s", command.TitleString(), strings.Title(status.String()))
+	return d.Client.UpdateStatus(repo, pull, status, d.statusSrc(command), description)
+}
+
+// UpdatePlanRequired sets a pending commit status since the pull can't be
+// applied until it's planned again.
+func (d *DefaultCommitStatusUpdater) UpdatePlanRequired(repo models.Repo, pull models.PullRequest) error {
+	return d.Client.UpdateStatus(repo, pull, models.PendingCommitStatus, d.statusSrc(PlanCommand), "Plan Required: new commits were pushed")
 }
 
-// UpdateProjectResult updates the commit status based on the status of res.
+// UpdatePullClosed sets a successful commit status since there's nothing
+// left to plan or apply. Otherwise a pending plan status would never resolve.
+func (d *DefaultCommitStatusUpdater) UpdatePullClosed(repo models.Repo, pull models.PullRequest) error {
+	return d.Client.UpdateStatus(repo, pull, models.SuccessCommitStatus, d.statusSrc(PlanCommand), "Plans Discarded: pull request was closed")
+}
+
+// UpdateProjectResult updates the status of each project in res and then the
+// status of the command based on the status of res.
 func (d *DefaultCommitStatusUpdater) UpdateProjectResult(ctx *CommandContext, commandName CommandName, res CommandResult) error {
+	for _, p := range res.ProjectResults {
+		if err := d.updateProject(ctx, commandName, p); err != nil {
+			return err
+		}
+	}
+
 	var status models.CommitStatus
 	if res.Error != nil || res.Failure != "" {
 		status = models.FailedCommitStatus
@@ -56,9 +93,63 @@ func (d *DefaultCommitStatusUpdater) UpdateProjectResult(ctx *CommandContext, co
 		}
 		status = d.worstStatus(statuses)
 	}
+
+	// Plans that destroy too many resources get their own status so they
+	// stand out to reviewers.
+	if destroys := d.thresholdExceededDestroys(res); status == models.SuccessCommitStatus && destroys > 0 {
+		if d.FailOnDestroy {
+			status = models.FailedCommitStatus
+		}
+		description := fmt.Sprintf("%s %s: %d to destroy", commandName.TitleString(), strings.Title(status.String()), destroys)
+		return d.Client.UpdateStatus(ctx.BaseRepo, ctx.Pull, status, d.statusSrc(commandName), description)
+	}
 	return d.Update(ctx.BaseRepo, ctx.Pull, status, commandName)
 }
 
+// updateProject sets the status of the project that p is the result of.
+func (d *DefaultCommitStatusUpdater) updateProject(ctx *CommandContext, commandName CommandName, p ProjectResult) error {
+	status := p.Status()
+	description := fmt.Sprintf("%s %s", commandName.TitleString(), strings.Title(status.String()))
+	if status == models.SuccessCommitStatus && p.PlanSuccess != nil && p.PlanSuccess.DestroyThresholdExceeded {
+		if d.FailOnDestroy {
+			status = models.FailedCommitStatus
+		}
+		description = fmt.Sprintf("%s %s: %d to destroy", commandName.TitleString(), strings.Title(status.String()), p.PlanSuccess.DestroyCount)
+	}
+	return d.Client.UpdateStatus(ctx.BaseRepo, ctx.Pull, status, d.projectStatusSrc(commandName, p), description)
+}
+
+// statusSrc returns the name of the status for command, ex. atlantis/plan.
+func (d *DefaultCommitStatusUpdater) statusSrc(command CommandName) string {
+	name := d.StatusName
+	if name == "" {
+		name = DefaultStatusName
+	}
+	return name + "/" + command.String()
+}
+
+// projectStatusSrc returns the name of the status for command in the project
+// that p is the result of, ex. atlantis/plan: dir/workspace. Projects with a
+// name use it instead of their dir and workspace.
+func (d *DefaultCommitStatusUpdater) projectStatusSrc(command CommandName, p ProjectResult) string {
+	if p.ProjectName != "" {
+		return fmt.Sprintf("%s: %s", d.statusSrc(command), p.ProjectName)
+	}
+	return fmt.Sprintf("%s: %s/%s", d.statusSrc(command), p.RepoRelDir, p.Workspace)
+}
+
+// thresholdExceededDestroys returns the total number of resources that will be
+// destroyed by plans that exceeded the destroy threshold.
+func (d *DefaultCommitStatusUpdater) thresholdExceededDestroys(res CommandResult) int {
+	destroys := 0
+	for _, p := range res.ProjectResults {
+		if p.PlanSuccess != nil && p.PlanSuccess.DestroyThresholdExceeded {
+			destroys += p.PlanSuccess.DestroyCount
+		}
+	}
+	return destroys
+}
+
 func (d *DefaultCommitStatusUpdater) worstStatus(ss []models.CommitStatus) models.CommitStatus {
 	for _, s := range ss {
 		if s == models.FailedCommitStatus {
@@ -35,7 +35,37 @@ func TestUpdate(t *testing.T) {
 	s := events.DefaultCommitStatusUpdater{Client: client}
 	err := s.Update(repoModel, pullModel, status, events.PlanCommand)
 	Ok(t, err)
-	client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, status, "Plan Success")
+	client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, status, "atlantis/plan", "Plan Success")
+}
+
+func TestUpdate_StatusName(t *testing.T) {
+	RegisterMockTestingT(t)
+	client := mocks.NewMockClientProxy()
+	s := events.DefaultCommitStatusUpdater{Client: client, StatusName: "atlantis-prod"}
+	err := s.UpdateProjectResult(&events.CommandContext{BaseRepo: repoModel, Pull: pullModel}, events.ApplyCommand, events.CommandResult{
+		ProjectResults: []events.ProjectResult{{RepoRelDir: "dir", Workspace: "default", ApplySuccess: "success"}},
+	})
+	Ok(t, err)
+	client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, models.SuccessCommitStatus, "atlantis-prod/apply: dir/default", "Apply Success")
+	client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, models.SuccessCommitStatus, "atlantis-prod/apply", "Apply Success")
+}
+
+func TestUpdatePlanRequired(t *testing.T) {
+	RegisterMockTestingT(t)
+	client := mocks.NewMockClientProxy()
+	s := events.DefaultCommitStatusUpdater{Client: client}
+	err := s.UpdatePlanRequired(repoModel, pullModel)
+	Ok(t, err)
+	client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, models.PendingCommitStatus, "atlantis/plan", "Plan Required: new commits were pushed")
+}
+
+func TestUpdatePullClosed(t *testing.T) {
+	RegisterMockTestingT(t)
+	client := mocks.NewMockClientProxy()
+	s := events.DefaultCommitStatusUpdater{Client: client}
+	err := s.UpdatePullClosed(repoModel, pullModel)
+	Ok(t, err)
+	client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, models.SuccessCommitStatus, "atlantis/plan", "Plans Discarded: pull request was closed")
 }
 
 func TestUpdateProjectResult_Error(t *testing.T) {
@@ -48,7 +78,7 @@ func TestUpdateProjectResult_Error(t *testing.T) {
 	s := events.DefaultCommitStatusUpdater{Client: client}
 	err := s.UpdateProjectResult(ctx, events.PlanCommand, events.CommandResult{Error: errors.New("err")})
 	Ok(t, err)
-	client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, models.FailedCommitStatus, "Plan Failed")
+	client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, models.FailedCommitStatus, "atlantis/plan", "Plan Failed")
 }
 
 func TestUpdateProjectResult_Failure(t *testing.T) {
@@ -61,7 +91,7 @@ func TestUpdateProjectResult_Failure(t *testing.T) {
 	s := events.DefaultCommitStatusUpdater{Client: client}
 	err := s.UpdateProjectResult(ctx, events.PlanCommand, events.CommandResult{Failure: "failure"})
 	Ok(t, err)
-	client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, models.FailedCommitStatus, "Plan Failed")
+	client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, models.FailedCommitStatus, "atlantis/plan", "Plan Failed")
 }
 
 func TestUpdateProjectResult(t *testing.T) {
@@ -131,7 +161,64 @@ func TestUpdateProjectResult(t *testing.T) {
 			s := events.DefaultCommitStatusUpdater{Client: client}
 			err := s.UpdateProjectResult(ctx, events.PlanCommand, resp)
 			Ok(t, err)
-			client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, c.Expected, "Plan "+strings.Title(c.Expected.String()))
+			client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, c.Expected, "atlantis/plan", "Plan "+strings.Title(c.Expected.String()))
+		})
+	}
+}
+
+func TestUpdateProjectResult_DestroyThresholdExceeded(t *testing.T) {
+	ctx := &events.CommandContext{
+		BaseRepo: repoModel,
+		Pull:     pullModel,
+	}
+	res := events.CommandResult{
+		ProjectResults: []events.ProjectResult{
+			{PlanSuccess: &events.PlanSuccess{DestroyCount: 2, DestroyThresholdExceeded: true}},
+			{PlanSuccess: &events.PlanSuccess{DestroyCount: 1, DestroyThresholdExceeded: true}},
+			{PlanSuccess: &events.PlanSuccess{DestroyCount: 5}},
+		},
+	}
+	cases := []struct {
+		failOnDestroy bool
+		expStatus     models.CommitStatus
+		expDesc       string
+	}{
+		{false, models.SuccessCommitStatus, "Plan Success: 3 to destroy"},
+		{true, models.FailedCommitStatus, "Plan Failed: 3 to destroy"},
+	}
+	for _, c := range cases {
+		t.Run(c.expDesc, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			client := mocks.NewMockClientProxy()
+			s := events.DefaultCommitStatusUpdater{Client: client, FailOnDestroy: c.failOnDestroy}
+			err := s.UpdateProjectResult(ctx, events.PlanCommand, res)
+			Ok(t, err)
+			client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, c.expStatus, "atlantis/plan", c.expDesc)
 		})
 	}
 }
+
+func TestUpdateProjectResult_PerProject(t *testing.T) {
+	RegisterMockTestingT(t)
+	ctx := &events.CommandContext{
+		BaseRepo: repoModel,
+		Pull:     pullModel,
+	}
+	res := events.CommandResult{
+		ProjectResults: []events.ProjectResult{
+			{RepoRelDir: ".", Workspace: "default", PlanSuccess: &events.PlanSuccess{}},
+			{RepoRelDir: "staging", Workspace: "default", Error: errors.New("err")},
+			{RepoRelDir: "prod", Workspace: "default", PlanSuccess: &events.PlanSuccess{DestroyCount: 2, DestroyThresholdExceeded: true}},
+			{RepoRelDir: "prod", Workspace: "default", ProjectName: "prod-db", PlanSuccess: &events.PlanSuccess{}},
+		},
+	}
+	client := mocks.NewMockClientProxy()
+	s := events.DefaultCommitStatusUpdater{Client: client, FailOnDestroy: true}
+	err := s.UpdateProjectResult(ctx, events.PlanCommand, res)
+	Ok(t, err)
+	client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, models.SuccessCommitStatus, "atlantis/plan: ./default", "Plan Success")
+	client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, models.FailedCommitStatus, "atlantis/plan: staging/default", "Plan Failed")
+	client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, models.FailedCommitStatus, "atlantis/plan: prod/default", "Plan Failed: 2 to destroy")
+	client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, models.SuccessCommitStatus, "atlantis/plan: prod-db", "Plan Success")
+	client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, models.FailedCommitStatus, "atlantis/plan", "Plan Failed")
+}
@@ -0,0 +1,29 @@
+// Package confirmations keeps the applies that are waiting for an atlantis
+// confirm comment when Atlantis is run with --require-apply-confirmation.
+package confirmations
+
+import (
+	"fmt"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_store.go Store
+
+// Store persists the applies waiting to be confirmed. A pull request has at
+// most one. It's implemented by the locking backends so they're stored in the
+// same database as the locks.
+type Store interface {
+	// SavePendingApply creates or replaces the pending apply of its pull.
+	SavePendingApply(apply models.PendingApply) error
+	// TakePendingApply deletes the pending apply of the pull and returns it,
+	// or nil if there isn't one. If it's taken by several callers at once,
+	// only one of them gets it.
+	TakePendingApply(repoFullName string, pullNum int) (*models.PendingApply, error)
+}
+
+// Key returns the key that the pending apply of the pull is stored under,
+// ex. owner/repo/1.
+func Key(repoFullName string, pullNum int) string {
+	return fmt.Sprintf("%s/%d", repoFullName, pullNum)
+}
@@ -0,0 +1,13 @@
+package confirmations_test
+
+import (
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/confirmations"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestKey(t *testing.T) {
+	Equals(t, "owner/repo/1", confirmations.Key("owner/repo", 1))
+	Equals(t, "group/subgroup/repo/12", confirmations.Key("group/subgroup/repo", 12))
+}
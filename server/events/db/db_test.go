@@ -0,0 +1,28 @@
+package db_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/db"
+	"github.com/runatlantis/atlantis/server/events/locking/boltdb"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+// BoltDB should be the default.
+func TestNew_BoltDB(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	database, err := db.New("", db.Config{DataDir: tmp})
+	Ok(t, err)
+	_, ok := database.(*boltdb.BoltLocker)
+	Assert(t, ok, "exp a BoltLocker but got %T", database)
+	_, err = os.Stat(filepath.Join(tmp, "atlantis.db"))
+	Ok(t, err)
+}
+
+func TestNew_Unsupported(t *testing.T) {
+	_, err := db.New("mysql", db.Config{})
+	ErrEquals(t, `unsupported database type "mysql": not one of boltdb, redis, postgres`, err)
+}
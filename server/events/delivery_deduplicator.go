@@ -0,0 +1,68 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultDeliveryTTL is how long DeliveryDeduplicator remembers webhook
+// deliveries. VCS hosts retry failed deliveries within minutes and people
+// redeliver them from the webhook settings soon after, so an hour catches
+// both.
+const DefaultDeliveryTTL = time.Hour
+
+// DeliveryDeduplicator remembers the IDs of the webhook deliveries we've
+// processed so that redelivered webhooks, which have the same ID, aren't
+// processed twice, ex. autoplanning the same commit twice. It's in memory so
+// instances sharing a repo each process a delivery once.
+type DeliveryDeduplicator struct {
+	// TTL defaults to DefaultDeliveryTTL.
+	TTL time.Duration
+
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	lastPrune time.Time
+}
+
+// Seen records that the delivery with id is being processed. It returns true
+// if it already was within TTL, in which case it shouldn't be processed
+// again. Empty IDs are never seen because some VCS hosts don't send them.
+func (d *DeliveryDeduplicator) Seen(id string) bool {
+	if id == "" {
+		return false
+	}
+	ttl := d.TTL
+	if ttl == 0 {
+		ttl = DefaultDeliveryTTL
+	}
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.seen == nil {
+		d.seen = make(map[string]time.Time)
+	}
+	// Pruning is at most once per TTL so it doesn't cost every delivery a
+	// scan of the map.
+	if now.Sub(d.lastPrune) > ttl {
+		for k, at := range d.seen {
+			if now.Sub(at) > ttl {
+				delete(d.seen, k)
+			}
+		}
+		d.lastPrune = now
+	}
+	if at, ok := d.seen[id]; ok && now.Sub(at) <= ttl {
+		return true
+	}
+	d.seen[id] = now
+	return false
+}
+
+// Forget forgets the delivery with id so that if it's redelivered it's
+// processed. It's used when we failed to process it.
+func (d *DeliveryDeduplicator) Forget(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.seen, id)
+}
@@ -0,0 +1,35 @@
+package events_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestDeliveryDeduplicator_Seen(t *testing.T) {
+	d := &events.DeliveryDeduplicator{}
+	Equals(t, false, d.Seen("1"))
+	Equals(t, true, d.Seen("1"))
+	Equals(t, false, d.Seen("2"))
+
+	t.Log("empty IDs should never be seen")
+	Equals(t, false, d.Seen(""))
+	Equals(t, false, d.Seen(""))
+}
+
+func TestDeliveryDeduplicator_Forget(t *testing.T) {
+	d := &events.DeliveryDeduplicator{}
+	Equals(t, false, d.Seen("1"))
+	d.Forget("1")
+	Equals(t, false, d.Seen("1"))
+	Equals(t, true, d.Seen("1"))
+}
+
+func TestDeliveryDeduplicator_Expires(t *testing.T) {
+	d := &events.DeliveryDeduplicator{TTL: time.Millisecond}
+	Equals(t, false, d.Seen("1"))
+	time.Sleep(5 * time.Millisecond)
+	Equals(t, false, d.Seen("1"))
+}
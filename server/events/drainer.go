@@ -0,0 +1,70 @@
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+// ShuttingDownComment is our reply to commands we don't run because
+// Atlantis is shutting down.
+const ShuttingDownComment = "**Error:** Atlantis is shutting down so this command wasn't run. Please comment again once it's back up."
+
+// Drainer tracks the commands that are running so that when Atlantis shuts
+// down it can stop new commands from starting and wait for the running ones
+// to finish instead of killing terraform mid-apply.
+type Drainer struct {
+	mu       sync.Mutex
+	draining bool
+	running  int
+	wg       sync.WaitGroup
+}
+
+// StartOp records that a command is starting. It returns false if we're
+// draining, in which case the command shouldn't run. If it returns true,
+// OpDone must be called once the command finishes.
+func (d *Drainer) StartOp() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.draining {
+		return false
+	}
+	d.running++
+	d.wg.Add(1)
+	return true
+}
+
+// OpDone records that a command started with StartOp has finished.
+func (d *Drainer) OpDone() {
+	d.mu.Lock()
+	d.running--
+	d.mu.Unlock()
+	d.wg.Done()
+}
+
+// Drain stops new commands from starting and waits for the running ones to
+// finish or for ctx to be done. It returns false if commands are still
+// running.
+func (d *Drainer) Drain(ctx context.Context) bool {
+	d.mu.Lock()
+	d.draining = true
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Running returns how many commands are running.
+func (d *Drainer) Running() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.running
+}
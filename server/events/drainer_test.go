@@ -0,0 +1,46 @@
+package events_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestDrainer_WaitsForRunningOps(t *testing.T) {
+	d := &events.Drainer{}
+	Equals(t, true, d.StartOp())
+	Equals(t, 1, d.Running())
+
+	drained := make(chan bool)
+	go func() {
+		drained <- d.Drain(context.Background())
+	}()
+	// Wait for Drain to start draining.
+	for d.StartOp() {
+		d.OpDone()
+		time.Sleep(time.Millisecond)
+	}
+	Equals(t, false, d.StartOp())
+
+	d.OpDone()
+	Equals(t, true, <-drained)
+	Equals(t, 0, d.Running())
+}
+
+func TestDrainer_Timeout(t *testing.T) {
+	d := &events.Drainer{}
+	Equals(t, true, d.StartOp())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	Equals(t, false, d.Drain(ctx))
+	Equals(t, 1, d.Running())
+}
+
+func TestDrainer_NothingRunning(t *testing.T) {
+	d := &events.Drainer{}
+	Equals(t, true, d.Drain(context.Background()))
+	Equals(t, false, d.StartOp())
+}
@@ -0,0 +1,120 @@
+package events
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// driftDir is the directory in the data dir that default branches are
+// cloned into to detect drift.
+const driftDir = "drift"
+
+// DriftReport is the result of detecting drift in a repo.
+type DriftReport struct {
+	Repo     models.Repo
+	Projects []DriftProject
+}
+
+// DriftProject is the result of detecting drift in one project.
+type DriftProject struct {
+	RepoRelDir  string
+	Workspace   string
+	ProjectName string
+	// Summary is what the plan would change. It's only set if Drifted.
+	Summary runtime.PlanSummary
+	Drifted bool
+	// Output is the plan output.
+	Output string
+	// Error is set if the plan failed.
+	Error error
+}
+
+// HasDrift returns true if any project drifted or failed to plan. Projects
+// that fail to plan are included because whatever broke them may also be
+// hiding drift.
+func (r DriftReport) HasDrift() bool {
+	for _, p := range r.Projects {
+		if p.Drifted || p.Error != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// DriftDetector detects drift by planning the projects on a repo's default
+// branch. Drift is when the real infrastructure no longer matches what's
+// committed, ex. because someone changed it by hand.
+type DriftDetector struct {
+	// DataDir is where we clone the repos.
+	DataDir               string
+	ProjectCommandBuilder *DefaultProjectCommandBuilder
+	ProjectCommandRunner  *DefaultProjectCommandRunner
+	// TestingOverrideCloneURL can be used during testing to override the URL
+	// we clone from.
+	TestingOverrideCloneURL string
+}
+
+// Detect clones repo's default branch and plans each of its projects. It
+// only returns an error if we couldn't get far enough to plan. Errors from
+// planning are reported on each project.
+func (d *DriftDetector) Detect(log *logging.SimpleLogger, repo models.Repo) (DriftReport, error) {
+	repoDir, err := d.clone(log, repo)
+	if err != nil {
+		return DriftReport{}, err
+	}
+	projCtxs, err := d.ProjectCommandBuilder.BuildDriftCommands(log, repo, repoDir)
+	if err != nil {
+		return DriftReport{}, err
+	}
+	log.Info("detecting drift in %d project(s)", len(projCtxs))
+
+	report := DriftReport{Repo: repo}
+	for _, ctx := range projCtxs {
+		project := DriftProject{
+			RepoRelDir: ctx.RepoRelDir,
+			Workspace:  ctx.Workspace,
+		}
+		if ctx.ProjectConfig != nil {
+			project.ProjectName = ctx.ProjectConfig.GetName()
+		}
+		project.Output, project.Error = d.ProjectCommandRunner.PlanForDrift(ctx, repoDir)
+		if project.Error == nil {
+			project.Summary, project.Drifted = runtime.ParsePlanSummary(project.Output)
+		}
+		if project.Drifted {
+			log.Info("found drift in dir %q workspace %q", project.RepoRelDir, project.Workspace)
+		}
+		report.Projects = append(report.Projects, project)
+	}
+	return report, nil
+}
+
+// clone does a fresh shallow clone of repo's default branch and returns the
+// directory it was cloned into.
+func (d *DriftDetector) clone(log *logging.SimpleLogger, repo models.Repo) (string, error) {
+	cloneDir := filepath.Join(d.DataDir, driftDir, repo.VCSHost.Hostname, repo.FullName)
+	if err := os.RemoveAll(cloneDir); err != nil {
+		return "", errors.Wrapf(err, "deleting dir %q before cloning", cloneDir)
+	}
+	if err := os.MkdirAll(filepath.Dir(cloneDir), 0700); err != nil {
+		return "", errors.Wrap(err, "creating drift dir")
+	}
+
+	log.Info("git cloning default branch of %q into %q", repo.SanitizedCloneURL, cloneDir)
+	cloneURL := repo.CloneURL
+	if d.TestingOverrideCloneURL != "" {
+		cloneURL = d.TestingOverrideCloneURL
+	}
+	// Without a branch, git checks out the default branch.
+	cloneCmd := exec.Command("git", "clone", "--depth=1", cloneURL, cloneDir) // #nosec
+	if output, err := cloneCmd.CombinedOutput(); err != nil {
+		return "", errors.Wrapf(err, "cloning %s: %s", repo.SanitizedCloneURL, string(output))
+	}
+	return cloneDir, nil
+}
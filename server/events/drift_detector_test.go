@@ -0,0 +1,156 @@
+package events_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	"github.com/runatlantis/atlantis/server/events/yaml"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+var driftRepo = models.Repo{
+	FullName:          "owner/repo",
+	Owner:             "owner",
+	Name:              "repo",
+	SanitizedCloneURL: "https://github.com/owner/repo.git",
+	VCSHost: models.VCSHost{
+		Hostname: "github.com",
+		Type:     models.Github,
+	},
+}
+
+func TestDriftDetector_Detect(t *testing.T) {
+	RegisterMockTestingT(t)
+	repoDir, cleanup := initDriftRepo(t, map[string]string{
+		"drifted/main.tf":        "",
+		"unchanged/main.tf":      "",
+		"broken/main.tf":         "",
+		"README.md":              "",
+		"drifted/env/dev.tfvars": "",
+	})
+	defer cleanup()
+	dataDir, cleanupData := TempDir(t)
+	defer cleanupData()
+
+	mockPlan := mocks.NewMockStepRunner()
+	detector := newDriftDetector(dataDir, repoDir, mockPlan)
+	cloneDir := filepath.Join(dataDir, "drift", "github.com", "owner", "repo")
+	When(mockPlan.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), EqString(filepath.Join(cloneDir, "drifted")))).
+		ThenReturn("Plan: 1 to add, 2 to change, 0 to destroy.", nil)
+	When(mockPlan.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), EqString(filepath.Join(cloneDir, "unchanged")))).
+		ThenReturn("No changes. Infrastructure is up-to-date.", nil)
+	When(mockPlan.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), EqString(filepath.Join(cloneDir, "broken")))).
+		ThenReturn("", errors.New("plan failed"))
+
+	report, err := detector.Detect(logging.NewNoopLogger(), driftRepo)
+	Ok(t, err)
+	Equals(t, driftRepo, report.Repo)
+	Equals(t, true, report.HasDrift())
+	Equals(t, []events.DriftProject{
+		{
+			RepoRelDir: "broken",
+			Workspace:  "default",
+			Error:      errors.New("plan failed"),
+		},
+		{
+			RepoRelDir: "drifted",
+			Workspace:  "default",
+			Drifted:    true,
+			Summary:    runtime.PlanSummary{Add: 1, Change: 2},
+			Output:     "Plan: 1 to add, 2 to change, 0 to destroy.",
+		},
+		{
+			RepoRelDir: "unchanged",
+			Workspace:  "default",
+			Output:     "No changes. Infrastructure is up-to-date.",
+		},
+	}, report.Projects)
+}
+
+// If the repo has an atlantis.yaml file, only its projects are planned.
+func TestDriftDetector_DetectConfigFile(t *testing.T) {
+	RegisterMockTestingT(t)
+	repoDir, cleanup := initDriftRepo(t, map[string]string{
+		"atlantis.yaml": `
+version: 2
+projects:
+- name: staging
+  dir: project
+  workspace: staging
+`,
+		"project/main.tf": "",
+		"other/main.tf":   "",
+	})
+	defer cleanup()
+	dataDir, cleanupData := TempDir(t)
+	defer cleanupData()
+
+	mockPlan := mocks.NewMockStepRunner()
+	When(mockPlan.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())).
+		ThenReturn("No changes. Infrastructure is up-to-date.", nil)
+	detector := newDriftDetector(dataDir, repoDir, mockPlan)
+
+	report, err := detector.Detect(logging.NewNoopLogger(), driftRepo)
+	Ok(t, err)
+	Equals(t, false, report.HasDrift())
+	Equals(t, []events.DriftProject{
+		{
+			RepoRelDir:  "project",
+			Workspace:   "staging",
+			ProjectName: "staging",
+			Output:      "No changes. Infrastructure is up-to-date.",
+		},
+	}, report.Projects)
+}
+
+func TestDriftDetector_DetectCloneErr(t *testing.T) {
+	RegisterMockTestingT(t)
+	dataDir, cleanup := TempDir(t)
+	defer cleanup()
+	detector := newDriftDetector(dataDir, filepath.Join(dataDir, "does-not-exist"), mocks.NewMockStepRunner())
+	_, err := detector.Detect(logging.NewNoopLogger(), driftRepo)
+	ErrContains(t, "cloning https://github.com/owner/repo.git", err)
+}
+
+// newDriftDetector returns a detector that clones from cloneURL and plans with
+// mockPlan.
+func newDriftDetector(dataDir string, cloneURL string, mockPlan *mocks.MockStepRunner) *events.DriftDetector {
+	return &events.DriftDetector{
+		DataDir: dataDir,
+		ProjectCommandBuilder: &events.DefaultProjectCommandBuilder{
+			ParserValidator: &yaml.ParserValidator{},
+			ProjectFinder:   &events.DefaultProjectFinder{},
+			AllowRepoConfig: true,
+		},
+		ProjectCommandRunner: &events.DefaultProjectCommandRunner{
+			InitStepRunner: mocks.NewMockStepRunner(),
+			PlanStepRunner: mockPlan,
+		},
+		TestingOverrideCloneURL: cloneURL,
+	}
+}
+
+// initDriftRepo creates a git repo with files whose content is the map's
+// values and commits them to its default branch.
+func initDriftRepo(t *testing.T, files map[string]string) (string, func()) {
+	repoDir, cleanup := TempDir(t)
+	runGit(t, repoDir, "init")
+	for name, content := range files {
+		path := filepath.Join(repoDir, name)
+		Ok(t, os.MkdirAll(filepath.Dir(path), 0700))
+		Ok(t, ioutil.WriteFile(path, []byte(content), 0600))
+	}
+	runGit(t, repoDir, "add", "-A")
+	runGit(t, repoDir, "-c", "user.name=atlantis", "-c", "user.email=atlantis@example.com", "commit", "-m", "initial commit")
+	return repoDir, cleanup
+}
@@ -0,0 +1,158 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/nlopes/slack"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/webhooks"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+)
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_drift_notifier.go DriftNotifier
+
+// DriftNotifier reports drift somewhere.
+type DriftNotifier interface {
+	// Notify reports the drift in report. cfg is the repo's config for this
+	// notifier.
+	Notify(report DriftReport, cfg valid.DriftNotifier) error
+}
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_issue_creator.go IssueCreator
+
+// IssueCreator opens issues on repos.
+type IssueCreator interface {
+	CreateIssue(repo models.Repo, title string, body string) error
+}
+
+// VCSIssueDriftNotifier reports drift by opening an issue on the repo.
+type VCSIssueDriftNotifier struct {
+	// Clients are the clients for the VCS hosts that support issues. Only
+	// GitHub and GitLab do.
+	Clients map[models.VCSHostType]IssueCreator
+}
+
+// Notify opens an issue on the repo in report.
+func (v *VCSIssueDriftNotifier) Notify(report DriftReport, _ valid.DriftNotifier) error {
+	client, ok := v.Clients[report.Repo.VCSHost.Type]
+	if !ok {
+		return fmt.Errorf("opening issues on %s repos isn't supported", report.Repo.VCSHost.Type)
+	}
+	title := fmt.Sprintf("Atlantis detected drift in %s", report.Repo.FullName)
+	return client.CreateIssue(report.Repo, title, renderDriftReport(report))
+}
+
+// SlackDriftNotifier reports drift by posting a summary to a Slack channel.
+type SlackDriftNotifier struct {
+	Slack webhooks.UnderlyingSlackClient
+}
+
+// Notify posts a summary of report to the channel in cfg.
+func (s *SlackDriftNotifier) Notify(report DriftReport, cfg valid.DriftNotifier) error {
+	var lines []string
+	for _, p := range report.Projects {
+		switch {
+		case p.Error != nil:
+			lines = append(lines, fmt.Sprintf("• dir: `%s` workspace: `%s`: plan failed", p.RepoRelDir, p.Workspace))
+		case p.Drifted:
+			lines = append(lines, fmt.Sprintf("• dir: `%s` workspace: `%s`: %d to add, %d to change, %d to destroy",
+				p.RepoRelDir, p.Workspace, p.Summary.Add, p.Summary.Change, p.Summary.Destroy))
+		}
+	}
+	text := fmt.Sprintf("Atlantis detected drift in %s:\n%s", report.Repo.FullName, strings.Join(lines, "\n"))
+	params := slack.NewPostMessageParameters()
+	params.EscapeText = false
+	_, _, err := s.Slack.PostMessage(cfg.Channel, text, params)
+	return err
+}
+
+// WebhookDriftNotifier reports drift by POSTing the report as JSON to the URL
+// in the repo's config.
+type WebhookDriftNotifier struct {
+	HTTPClient *http.Client
+}
+
+// driftWebhookBody is the JSON body WebhookDriftNotifier sends.
+type driftWebhookBody struct {
+	Repo     string                `json:"repo"`
+	Projects []driftWebhookProject `json:"projects"`
+}
+
+type driftWebhookProject struct {
+	Dir         string `json:"dir"`
+	Workspace   string `json:"workspace"`
+	ProjectName string `json:"project_name,omitempty"`
+	Drifted     bool   `json:"drifted"`
+	Add         int    `json:"add"`
+	Change      int    `json:"change"`
+	Destroy     int    `json:"destroy"`
+	Error       string `json:"error,omitempty"`
+	Output      string `json:"output"`
+}
+
+// Notify POSTs report to the URL in cfg.
+func (w *WebhookDriftNotifier) Notify(report DriftReport, cfg valid.DriftNotifier) error {
+	body := driftWebhookBody{
+		Repo:     fmt.Sprintf("%s/%s", report.Repo.VCSHost.Hostname, report.Repo.FullName),
+		Projects: []driftWebhookProject{},
+	}
+	for _, p := range report.Projects {
+		project := driftWebhookProject{
+			Dir:         p.RepoRelDir,
+			Workspace:   p.Workspace,
+			ProjectName: p.ProjectName,
+			Drifted:     p.Drifted,
+			Add:         p.Summary.Add,
+			Change:      p.Summary.Change,
+			Destroy:     p.Summary.Destroy,
+			Output:      p.Output,
+		}
+		if p.Error != nil {
+			project.Error = p.Error.Error()
+		}
+		body.Projects = append(body.Projects, project)
+	}
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return errors.Wrap(err, "marshalling drift report")
+	}
+	client := w.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Post(cfg.URL, "application/json", bytes.NewReader(bodyBytes))
+	if err != nil {
+		return errors.Wrapf(err, "posting drift report to %s", cfg.URL)
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("posting drift report to %s: got status %d", cfg.URL, resp.StatusCode)
+	}
+	return nil
+}
+
+// renderDriftReport renders the drifted and failed projects in report as
+// markdown.
+func renderDriftReport(report DriftReport) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Atlantis planned the default branch of %s and found that the following projects don't match what's committed.\n", report.Repo.FullName)
+	for _, p := range report.Projects {
+		if !p.Drifted && p.Error == nil {
+			continue
+		}
+		fmt.Fprintf(&buf, "\n### dir: `%s` workspace: `%s`\n", p.RepoRelDir, p.Workspace)
+		if p.Error != nil {
+			fmt.Fprintf(&buf, "**Plan Failed**\n```\n%s\n```\n", strings.TrimSpace(p.Error.Error()))
+			continue
+		}
+		fmt.Fprintf(&buf, "%d to add, %d to change, %d to destroy.\n<details><summary>Show Output</summary>\n\n```diff\n%s\n```\n</details>\n",
+			p.Summary.Add, p.Summary.Change, p.Summary.Destroy, strings.TrimSpace(p.Output))
+	}
+	fmt.Fprint(&buf, "\nTo fix this, either apply the committed config or update it to match the real infrastructure.\n")
+	return buf.String()
+}
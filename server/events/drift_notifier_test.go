@@ -0,0 +1,109 @@
+package events_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nlopes/slack"
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	webhookmocks "github.com/runatlantis/atlantis/server/events/webhooks/mocks"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+var driftReport = events.DriftReport{
+	Repo: driftRepo,
+	Projects: []events.DriftProject{
+		{
+			RepoRelDir: "drifted",
+			Workspace:  "default",
+			Drifted:    true,
+			Summary:    runtime.PlanSummary{Add: 1, Change: 2},
+			Output:     "  + aws_instance.web\n\nPlan: 1 to add, 2 to change, 0 to destroy.",
+		},
+		{
+			RepoRelDir: "unchanged",
+			Workspace:  "default",
+			Output:     "No changes.",
+		},
+		{
+			RepoRelDir:  "broken",
+			Workspace:   "staging",
+			ProjectName: "broken-staging",
+			Error:       errors.New("plan failed"),
+		},
+	},
+}
+
+func TestVCSIssueDriftNotifier_Notify(t *testing.T) {
+	RegisterMockTestingT(t)
+	client := mocks.NewMockIssueCreator()
+	notifier := events.VCSIssueDriftNotifier{
+		Clients: map[models.VCSHostType]events.IssueCreator{models.Github: client},
+	}
+	Ok(t, notifier.Notify(driftReport, valid.DriftNotifier{Kind: valid.VCSIssueDriftNotifier}))
+
+	client.VerifyWasCalledOnce().CreateIssue(driftRepo, "Atlantis detected drift in owner/repo", "Atlantis planned the default branch of owner/repo and found that the following projects don't match what's committed.\n"+
+		"\n### dir: `drifted` workspace: `default`\n"+
+		"1 to add, 2 to change, 0 to destroy.\n<details><summary>Show Output</summary>\n\n```diff\n+ aws_instance.web\n\nPlan: 1 to add, 2 to change, 0 to destroy.\n```\n</details>\n"+
+		"\n### dir: `broken` workspace: `staging`\n"+
+		"**Plan Failed**\n```\nplan failed\n```\n"+
+		"\nTo fix this, either apply the committed config or update it to match the real infrastructure.\n")
+}
+
+func TestVCSIssueDriftNotifier_NotifyUnsupportedHost(t *testing.T) {
+	notifier := events.VCSIssueDriftNotifier{}
+	report := events.DriftReport{Repo: models.Repo{VCSHost: models.VCSHost{Type: models.Gitea}}}
+	err := notifier.Notify(report, valid.DriftNotifier{Kind: valid.VCSIssueDriftNotifier})
+	ErrEquals(t, "opening issues on Gitea repos isn't supported", err)
+}
+
+func TestSlackDriftNotifier_Notify(t *testing.T) {
+	RegisterMockTestingT(t)
+	client := webhookmocks.NewMockUnderlyingSlackClient()
+	notifier := events.SlackDriftNotifier{Slack: client}
+	Ok(t, notifier.Notify(driftReport, valid.DriftNotifier{Kind: valid.SlackDriftNotifier, Channel: "infra"}))
+
+	params := slack.NewPostMessageParameters()
+	params.EscapeText = false
+	client.VerifyWasCalledOnce().PostMessage("infra", "Atlantis detected drift in owner/repo:\n"+
+		"• dir: `drifted` workspace: `default`: 1 to add, 2 to change, 0 to destroy\n"+
+		"• dir: `broken` workspace: `staging`: plan failed", params)
+}
+
+func TestWebhookDriftNotifier_Notify(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Equals(t, "POST", r.Method)
+		Equals(t, "application/json", r.Header.Get("Content-Type"))
+		bodyBytes, err := ioutil.ReadAll(r.Body)
+		Ok(t, err)
+		body = string(bodyBytes)
+	}))
+	defer server.Close()
+
+	notifier := events.WebhookDriftNotifier{}
+	Ok(t, notifier.Notify(driftReport, valid.DriftNotifier{Kind: valid.WebhookDriftNotifier, URL: server.URL}))
+	Equals(t, `{"repo":"github.com/owner/repo","projects":[`+
+		`{"dir":"drifted","workspace":"default","drifted":true,"add":1,"change":2,"destroy":0,"output":"  + aws_instance.web\n\nPlan: 1 to add, 2 to change, 0 to destroy."},`+
+		`{"dir":"unchanged","workspace":"default","drifted":false,"add":0,"change":0,"destroy":0,"output":"No changes."},`+
+		`{"dir":"broken","workspace":"staging","project_name":"broken-staging","drifted":false,"add":0,"change":0,"destroy":0,"error":"plan failed","output":""}]}`, body)
+}
+
+func TestWebhookDriftNotifier_NotifyErrStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	notifier := events.WebhookDriftNotifier{}
+	err := notifier.Notify(driftReport, valid.DriftNotifier{Kind: valid.WebhookDriftNotifier, URL: server.URL})
+	ErrEquals(t, "posting drift report to "+server.URL+": got status 500", err)
+}
@@ -0,0 +1,137 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/cron"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// DriftRepo is a repo that's opted into drift detection.
+type DriftRepo struct {
+	VCSHostType  models.VCSHostType
+	RepoFullName string
+	// CloneURL is the URL to clone the repo from, without credentials.
+	CloneURL  string
+	Notifiers []valid.DriftNotifier
+}
+
+// DriftScheduler runs drift detection on Repos on Schedule and reports any
+// drift to each repo's notifiers.
+type DriftScheduler struct {
+	Schedule *cron.Schedule
+	Repos    []DriftRepo
+	Detector *DriftDetector
+	// Parser builds the repos to clone with our credentials. They're built on
+	// each run because GitHub App tokens expire.
+	Parser *EventParser
+	// Notifiers are the notifiers for each kind in valid.DriftNotifierKinds.
+	Notifiers map[string]DriftNotifier
+	Logger    *logging.SimpleLogger
+
+	// lastDrift is the drift we last notified about for each repo. We don't
+	// notify again until it changes so we don't open an issue on every run.
+	lastDrift map[string]string
+	stop      chan struct{}
+	done      sync.WaitGroup
+}
+
+// Start runs drift detection in the background until Stop is called.
+func (d *DriftScheduler) Start() {
+	d.stop = make(chan struct{})
+	d.done.Add(1)
+	go func() {
+		defer d.done.Done()
+		for {
+			next := d.Schedule.Next(time.Now())
+			if next.IsZero() {
+				d.Logger.Err("drift detection schedule %q never runs", d.Schedule)
+				return
+			}
+			d.Logger.Info("next drift detection run is at %s", next)
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-d.stop:
+				timer.Stop()
+				return
+			case <-timer.C:
+				d.Run()
+			}
+		}
+	}()
+}
+
+// Stop stops the scheduler. It waits for a run that's in progress to finish.
+func (d *DriftScheduler) Stop() {
+	close(d.stop)
+	d.done.Wait()
+}
+
+// Run detects drift in each repo once.
+func (d *DriftScheduler) Run() {
+	if d.lastDrift == nil {
+		d.lastDrift = make(map[string]string)
+	}
+	for _, r := range d.Repos {
+		log := logging.NewSimpleLogger(fmt.Sprintf("drift %s", r.RepoFullName), false, d.Logger.Level)
+		if err := d.runRepo(log, r); err != nil {
+			log.Err("detecting drift: %s", err)
+		}
+	}
+}
+
+func (d *DriftScheduler) runRepo(log *logging.SimpleLogger, r DriftRepo) error {
+	repo, err := d.Parser.ParseRepoCloneURL(r.VCSHostType, r.RepoFullName, r.CloneURL)
+	if err != nil {
+		return err
+	}
+	report, err := d.Detector.Detect(log, repo)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s/%s", repo.VCSHost.Hostname, repo.FullName)
+	if !report.HasDrift() {
+		log.Info("found no drift")
+		delete(d.lastDrift, key)
+		return nil
+	}
+	drift := driftFingerprint(report)
+	if d.lastDrift[key] == drift {
+		log.Info("drift hasn't changed since the last run so not notifying again")
+		return nil
+	}
+	d.lastDrift[key] = drift
+
+	for _, cfg := range r.Notifiers {
+		notifier, ok := d.Notifiers[cfg.Kind]
+		if !ok {
+			log.Err("no %s drift notifier is configured", cfg.Kind)
+			continue
+		}
+		if err := notifier.Notify(report, cfg); err != nil {
+			log.Err("sending drift notification with %s notifier: %s", cfg.Kind, err)
+		}
+	}
+	return nil
+}
+
+// driftFingerprint summarizes which projects drifted and by how much. Unlike
+// the plan output, it doesn't change between runs unless the drift does.
+func driftFingerprint(report DriftReport) string {
+	var parts []string
+	for _, p := range report.Projects {
+		switch {
+		case p.Error != nil:
+			parts = append(parts, fmt.Sprintf("%s/%s:error", p.RepoRelDir, p.Workspace))
+		case p.Drifted:
+			parts = append(parts, fmt.Sprintf("%s/%s:%d/%d/%d", p.RepoRelDir, p.Workspace, p.Summary.Add, p.Summary.Change, p.Summary.Destroy))
+		}
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,64 @@
+package events_test
+
+import (
+	"testing"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+// We should notify when drift is found but not again until it changes.
+func TestDriftScheduler_Run(t *testing.T) {
+	RegisterMockTestingT(t)
+	repoDir, cleanup := initDriftRepo(t, map[string]string{"main.tf": ""})
+	defer cleanup()
+	dataDir, cleanupData := TempDir(t)
+	defer cleanupData()
+
+	mockPlan := mocks.NewMockStepRunner()
+	notifier := mocks.NewMockDriftNotifier()
+	cfg := valid.DriftNotifier{Kind: valid.WebhookDriftNotifier, URL: "https://example.com"}
+	scheduler := events.DriftScheduler{
+		Repos: []events.DriftRepo{
+			{
+				VCSHostType:  models.Github,
+				RepoFullName: "owner/repo",
+				CloneURL:     "https://github.com/owner/repo.git",
+				Notifiers:    []valid.DriftNotifier{cfg},
+			},
+		},
+		Detector: newDriftDetector(dataDir, repoDir, mockPlan),
+		Parser:   &parser,
+		Notifiers: map[string]events.DriftNotifier{
+			valid.WebhookDriftNotifier: notifier,
+		},
+		Logger: logging.NewNoopLogger(),
+	}
+
+	When(mockPlan.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())).
+		ThenReturn("Plan: 1 to add, 0 to change, 0 to destroy.", nil)
+	scheduler.Run()
+	scheduler.Run()
+	notifier.VerifyWasCalledOnce().Notify(matchers.AnyEventsDriftReport(), matchers.EqValidDriftNotifier(cfg))
+
+	// Once the drift changes, we notify again.
+	When(mockPlan.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())).
+		ThenReturn("Plan: 2 to add, 0 to change, 0 to destroy.", nil)
+	scheduler.Run()
+	notifier.VerifyWasCalled(Times(2)).Notify(matchers.AnyEventsDriftReport(), matchers.EqValidDriftNotifier(cfg))
+
+	// And if the drift is fixed and comes back.
+	When(mockPlan.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())).
+		ThenReturn("No changes.", nil)
+	scheduler.Run()
+	When(mockPlan.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())).
+		ThenReturn("Plan: 2 to add, 0 to change, 0 to destroy.", nil)
+	scheduler.Run()
+	notifier.VerifyWasCalled(Times(3)).Notify(matchers.AnyEventsDriftReport(), matchers.EqValidDriftNotifier(cfg))
+}
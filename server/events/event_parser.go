@@ -16,6 +16,7 @@ package events
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"path"
 	"strings"
 
@@ -23,8 +24,11 @@ import (
 	"github.com/lkysow/go-gitlab"
 	"github.com/pkg/errors"
 	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+	"github.com/runatlantis/atlantis/server/events/vcs/azuredevops"
 	"github.com/runatlantis/atlantis/server/events/vcs/bitbucketcloud"
 	"github.com/runatlantis/atlantis/server/events/vcs/bitbucketserver"
+	"github.com/runatlantis/atlantis/server/events/vcs/gitea"
 	"gopkg.in/go-playground/validator.v9"
 )
 
@@ -76,9 +80,39 @@ type CommentCommand struct {
 	// If empty then the comment specified no workspace.
 	Workspace string
 	// ProjectName is the name of a project to run the command on. It refers to a
-	// project specified in an atlantis.yaml file.
+	// project specified in an atlantis.yaml file. Apply commands can name
+	// several projects separated by commas, see ProjectNames.
 	// If empty then the comment specified no project.
 	ProjectName string
+	// CommentID is the VCS host's id for the comment this command came from.
+	// It's used to react to the comment. If 0 then the id isn't known.
+	CommentID int64
+	// TFLogLevel is the TF_LOG level the comment asked plan to run with.
+	// If empty then the comment didn't specify a level.
+	TFLogLevel string
+	// ImportAddress is the address of the resource to import,
+	// ex. aws_instance.web. Only set for import commands.
+	ImportAddress string
+	// ImportID is the provider's ID of the resource to import,
+	// ex. i-abcd1234. Only set for import commands.
+	ImportID string
+	// StateSubcommand is the terraform state subcommand to run, either rm
+	// or mv. Only set for state commands.
+	StateSubcommand string
+	// StateAddresses are the addresses the state subcommand is run on: the
+	// addresses to remove for rm, or the source and destination for mv. Only
+	// set for state commands.
+	StateAddresses []string
+	// Destroy is true if the comment asked plan to destroy all the
+	// resources, ex. atlantis plan -destroy.
+	Destroy bool
+	// Targets are the resource addresses the comment asked plan to target,
+	// ex. atlantis plan -target=aws_instance.web.
+	Targets []string
+	// AutoplanEnabled is true if the comment turned autoplanning back on,
+	// ex. atlantis autoplan on, and false if it turned it off. Only set for
+	// autoplan commands.
+	AutoplanEnabled bool
 }
 
 // IsForSpecificProject returns true if the command is for a specific dir, workspace
@@ -88,6 +122,26 @@ func (c CommentCommand) IsForSpecificProject() bool {
 	return c.RepoRelDir != "" || c.Workspace != "" || c.ProjectName != ""
 }
 
+// ProjectNames returns the names of the projects in ProjectName, ex.
+// project1 and project2 for atlantis apply -p project1,project2.
+func (c CommentCommand) ProjectNames() []string {
+	if c.ProjectName == "" {
+		return nil
+	}
+	return strings.Split(c.ProjectName, ",")
+}
+
+// HasDirGlob returns true if RepoRelDir is a glob, ex. modules/prod/*, that
+// matches the dirs of many projects.
+func (c CommentCommand) HasDirGlob() bool {
+	return IsGlob(c.RepoRelDir)
+}
+
+// IsGlob returns true if pattern has any of path.Match's special characters.
+func IsGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
 // CommandName returns the name of this command.
 func (c CommentCommand) CommandName() CommandName {
 	return c.Name
@@ -234,6 +288,52 @@ type EventParsing interface {
 	// GetBitbucketServerPullEventType returns the type of the pull request
 	// event given the Bitbucket Server header.
 	GetBitbucketServerPullEventType(eventTypeHeader string) models.PullRequestEventType
+
+	// ParseAzureDevopsPullEvent parses a pull request event from Azure DevOps.
+	// pull is the parsed pull request.
+	// pullEventType is the type of event, for example opened/closed.
+	// baseRepo is the repo the pull request will be merged into.
+	// headRepo is the repo the pull request branch is from.
+	// user is the pull request author.
+	ParseAzureDevopsPullEvent(body []byte) (
+		pull models.PullRequest, pullEventType models.PullRequestEventType,
+		baseRepo models.Repo, headRepo models.Repo, user models.User, err error)
+
+	// ParseAzureDevopsPullCommentEvent parses a pull request comment event
+	// from Azure DevOps.
+	// pull is the parsed pull request.
+	// baseRepo is the repo the pull request will be merged into.
+	// headRepo is the repo the pull request branch is from.
+	// user is the comment author.
+	// comment is the comment that triggered the event.
+	ParseAzureDevopsPullCommentEvent(body []byte) (
+		pull models.PullRequest, baseRepo models.Repo, headRepo models.Repo,
+		user models.User, comment string, err error)
+
+	// ParseGiteaPullEvent parses Gitea pull request events.
+	// pull is the parsed pull request.
+	// pullEventType is the type of event, for example opened/closed.
+	// baseRepo is the repo the pull request will be merged into.
+	// headRepo is the repo the pull request branch is from.
+	// user is the user that triggered the event.
+	ParseGiteaPullEvent(event gitea.PullRequestEvent) (
+		pull models.PullRequest, pullEventType models.PullRequestEventType,
+		baseRepo models.Repo, headRepo models.Repo, user models.User, err error)
+
+	// ParseGiteaIssueCommentEvent parses Gitea pull request comment events.
+	// baseRepo is the repo that the pull request will be merged into.
+	// user is the comment author.
+	// pullNum is the number of the pull request that was commented on.
+	ParseGiteaIssueCommentEvent(event gitea.IssueCommentEvent) (
+		baseRepo models.Repo, user models.User, pullNum int, err error)
+
+	// ParseGiteaPull parses the response from the Gitea API endpoint that
+	// returns a pull request.
+	// pull is the parsed pull request.
+	// baseRepo is the repo the pull request will be merged into.
+	// headRepo is the repo the pull request branch is from.
+	ParseGiteaPull(giteaPull *gitea.PullRequest) (
+		pull models.PullRequest, baseRepo models.Repo, headRepo models.Repo, err error)
 }
 
 // EventParser parses VCS events.
@@ -245,6 +345,26 @@ type EventParser struct {
 	BitbucketUser      string
 	BitbucketToken     string
 	BitbucketServerURL string
+	// BitbucketTokenType is bitbucketcloud.TokenTypeAccessToken if
+	// BitbucketToken is a Bitbucket Cloud access token, which clones with
+	// its own username.
+	BitbucketTokenType string
+	AzureDevopsUser    string
+	AzureDevopsToken   string
+	GiteaUser          string
+	GiteaToken         string
+	// GithubAppCredentials is set if we're authenticating to GitHub as an
+	// app. Its installation tokens are used to clone instead of GithubToken.
+	GithubAppCredentials *vcs.GithubAppCredentials
+}
+
+// bitbucketCloudCloneUser returns the username to clone Bitbucket Cloud repos
+// with.
+func (e *EventParser) bitbucketCloudCloneUser() string {
+	if e.BitbucketTokenType == bitbucketcloud.TokenTypeAccessToken {
+		return bitbucketcloud.AccessTokenCloneUser
+	}
+	return e.BitbucketUser
 }
 
 // GetBitbucketCloudPullEventType returns the type of the pull request
@@ -288,7 +408,7 @@ func (e *EventParser) parseCommonBitbucketCloudEventData(event bitbucketcloud.Co
 		prState = models.ClosedPullState
 	case "SUPERSEDED":
 		prState = models.ClosedPullState
-	case "DECLINE":
+	case "DECLINED", "DECLINE":
 		prState = models.ClosedPullState
 	default:
 		err = fmt.Errorf("unable to determine pull request state from %q–this is a bug", *event.PullRequest.State)
@@ -299,7 +419,7 @@ func (e *EventParser) parseCommonBitbucketCloudEventData(event bitbucketcloud.Co
 		models.BitbucketCloud,
 		*event.PullRequest.Source.Repository.FullName,
 		*event.PullRequest.Source.Repository.Links.HTML.HREF,
-		e.BitbucketUser,
+		e.bitbucketCloudCloneUser(),
 		e.BitbucketToken)
 	if err != nil {
 		return
@@ -308,17 +428,23 @@ func (e *EventParser) parseCommonBitbucketCloudEventData(event bitbucketcloud.Co
 		models.BitbucketCloud,
 		*event.Repository.FullName,
 		*event.Repository.Links.HTML.HREF,
-		e.BitbucketUser,
+		e.bitbucketCloudCloneUser(),
 		e.BitbucketToken)
 	if err != nil {
 		return
 	}
 
+	var baseBranch string
+	if dest := event.PullRequest.Destination; dest != nil && dest.Branch != nil {
+		baseBranch = *dest.Branch.Name
+	}
+
 	pull = models.PullRequest{
 		Num:        *event.PullRequest.ID,
 		HeadCommit: *event.PullRequest.Source.Commit.Hash,
 		URL:        *event.PullRequest.Links.HTML.HREF,
 		Branch:     *event.PullRequest.Source.Branch.Name,
+		BaseBranch: baseBranch,
 		Author:     *event.Actor.Username,
 		State:      prState,
 		BaseRepo:   baseRepo,
@@ -394,6 +520,8 @@ func (e *EventParser) ParseGithubPullEvent(pullEvent *github.PullRequestEvent) (
 		pullEventType = models.OpenedPullEvent
 	case "synchronize":
 		pullEventType = models.UpdatedPullEvent
+	case "ready_for_review":
+		pullEventType = models.ReadyForReviewPullEvent
 	case "closed":
 		pullEventType = models.ClosedPullEvent
 	default:
@@ -450,6 +578,7 @@ func (e *EventParser) ParseGithubPull(pull *github.PullRequest) (pullModel model
 	pullModel = models.PullRequest{
 		Author:     authorUsername,
 		Branch:     branch,
+		BaseBranch: pull.Base.GetRef(),
 		HeadCommit: commit,
 		URL:        url,
 		Num:        num,
@@ -463,7 +592,46 @@ func (e *EventParser) ParseGithubPull(pull *github.PullRequest) (pullModel model
 // returns a repo into the Atlantis model.
 // See EventParsing for return value docs.
 func (e *EventParser) ParseGithubRepo(ghRepo *github.Repository) (models.Repo, error) {
-	return models.NewRepo(models.Github, ghRepo.GetFullName(), ghRepo.GetCloneURL(), e.GithubUser, e.GithubToken)
+	user, token := e.GithubUser, e.GithubToken
+	if e.GithubAppCredentials != nil {
+		var err error
+		token, err = e.GithubAppCredentials.Token()
+		if err != nil {
+			return models.Repo{}, errors.Wrap(err, "getting GitHub App token to clone with")
+		}
+		user = vcs.GithubAppCloneUser
+	}
+	return models.NewRepo(models.Github, ghRepo.GetFullName(), ghRepo.GetCloneURL(), user, token)
+}
+
+// ParseRepoCloneURL returns the repo repoFullName that's cloned from cloneURL
+// with the credentials we have for vcsHostType. It's for repos we haven't
+// received a webhook for, ex. ones we're detecting drift in. Bitbucket Server
+// and Azure DevOps aren't supported since their clone URLs can't be built
+// from the repo name.
+func (e *EventParser) ParseRepoCloneURL(vcsHostType models.VCSHostType, repoFullName string, cloneURL string) (models.Repo, error) {
+	var user, token string
+	switch vcsHostType {
+	case models.Github:
+		user, token = e.GithubUser, e.GithubToken
+		if e.GithubAppCredentials != nil {
+			var err error
+			token, err = e.GithubAppCredentials.Token()
+			if err != nil {
+				return models.Repo{}, errors.Wrap(err, "getting GitHub App token to clone with")
+			}
+			user = vcs.GithubAppCloneUser
+		}
+	case models.Gitlab:
+		user, token = e.GitlabUser, e.GitlabToken
+	case models.BitbucketCloud:
+		user, token = e.bitbucketCloudCloneUser(), e.BitbucketToken
+	case models.Gitea:
+		user, token = e.GiteaUser, e.GiteaToken
+	default:
+		return models.Repo{}, fmt.Errorf("can't build the clone url for %s repos", vcsHostType)
+	}
+	return models.NewRepo(vcsHostType, repoFullName, cloneURL, user, token)
 }
 
 // ParseGitlabMergeRequestEvent parses GitLab merge request events.
@@ -492,6 +660,7 @@ func (e *EventParser) ParseGitlabMergeRequestEvent(event gitlab.MergeEvent) (pul
 		Num:        event.ObjectAttributes.IID,
 		HeadCommit: event.ObjectAttributes.LastCommit.ID,
 		Branch:     event.ObjectAttributes.SourceBranch,
+		BaseBranch: event.ObjectAttributes.TargetBranch,
 		State:      modelState,
 		BaseRepo:   baseRepo,
 	}
@@ -554,6 +723,7 @@ func (e *EventParser) ParseGitlabMergeRequest(mr *gitlab.MergeRequest, baseRepo
 		Num:        mr.IID,
 		HeadCommit: mr.SHA,
 		Branch:     mr.SourceBranch,
+		BaseBranch: mr.TargetBranch,
 		State:      pullState,
 		BaseRepo:   baseRepo,
 	}
@@ -565,7 +735,9 @@ func (e *EventParser) GetBitbucketServerPullEventType(eventTypeHeader string) mo
 	switch eventTypeHeader {
 	case bitbucketserver.PullCreatedHeader:
 		return models.OpenedPullEvent
-	case bitbucketserver.PullMergedHeader, bitbucketserver.PullDeclinedHeader:
+	case bitbucketserver.PullFromRefUpdatedHeader:
+		return models.UpdatedPullEvent
+	case bitbucketserver.PullMergedHeader, bitbucketserver.PullDeclinedHeader, bitbucketserver.PullDeletedHeader:
 		return models.ClosedPullEvent
 	}
 	return models.OtherPullEvent
@@ -634,6 +806,7 @@ func (e *EventParser) parseCommonBitbucketServerEventData(event bitbucketserver.
 		HeadCommit: *event.PullRequest.FromRef.LatestCommit,
 		URL:        fmt.Sprintf("%s/projects/%s/repos/%s/pull-requests/%d", e.BitbucketServerURL, *event.PullRequest.ToRef.Repository.Project.Key, *event.PullRequest.ToRef.Repository.Slug, *event.PullRequest.ID),
 		Branch:     *event.PullRequest.FromRef.DisplayID,
+		BaseBranch: *event.PullRequest.ToRef.DisplayID,
 		Author:     *event.Actor.Username,
 		State:      prState,
 		BaseRepo:   baseRepo,
@@ -660,3 +833,206 @@ func (e *EventParser) ParseBitbucketServerPullEvent(body []byte) (pull models.Pu
 	pull, baseRepo, headRepo, user, err = e.parseCommonBitbucketServerEventData(event.CommonEventData)
 	return
 }
+
+// ParseAzureDevopsPullEvent parses a pull request event from Azure DevOps.
+// See EventParsing for return value docs.
+func (e *EventParser) ParseAzureDevopsPullEvent(body []byte) (pull models.PullRequest, pullEventType models.PullRequestEventType, baseRepo models.Repo, headRepo models.Repo, user models.User, err error) {
+	var event azuredevops.PullRequestEvent
+	if err = json.Unmarshal(body, &event); err != nil {
+		err = errors.Wrap(err, "parsing json")
+		return
+	}
+	if err = validator.New().Struct(event); err != nil {
+		err = errors.Wrapf(err, "API response %q was missing fields", string(body))
+		return
+	}
+	pull, baseRepo, headRepo, err = e.parseAzureDevopsPull(event.Resource)
+	if err != nil {
+		return
+	}
+
+	switch *event.EventType {
+	case azuredevops.PullCreatedEvent:
+		pullEventType = models.OpenedPullEvent
+	case azuredevops.PullUpdatedEvent, azuredevops.PullMergedEvent:
+		// Updated events are sent for status changes as well as pushes, and
+		// merged events are sent for every merge attempt, so we need to look
+		// at the status to know if the pull request was closed.
+		switch {
+		case pull.State == models.ClosedPullState:
+			pullEventType = models.ClosedPullEvent
+		case *event.EventType == azuredevops.PullUpdatedEvent:
+			pullEventType = models.UpdatedPullEvent
+		default:
+			pullEventType = models.OtherPullEvent
+		}
+	default:
+		pullEventType = models.OtherPullEvent
+	}
+	user = models.User{
+		Username: *event.Resource.CreatedBy.UniqueName,
+	}
+	return
+}
+
+// ParseAzureDevopsPullCommentEvent parses a pull request comment event from
+// Azure DevOps.
+// See EventParsing for return value docs.
+func (e *EventParser) ParseAzureDevopsPullCommentEvent(body []byte) (pull models.PullRequest, baseRepo models.Repo, headRepo models.Repo, user models.User, comment string, err error) {
+	var event azuredevops.CommentEvent
+	if err = json.Unmarshal(body, &event); err != nil {
+		err = errors.Wrap(err, "parsing json")
+		return
+	}
+	if err = validator.New().Struct(event); err != nil {
+		err = errors.Wrapf(err, "API response %q was missing fields", string(body))
+		return
+	}
+	pull, baseRepo, headRepo, err = e.parseAzureDevopsPull(event.Resource.PullRequest)
+	if err != nil {
+		return
+	}
+	user = models.User{
+		Username: *event.Resource.Comment.Author.UniqueName,
+	}
+	comment = *event.Resource.Comment.Content
+	return
+}
+
+func (e *EventParser) parseAzureDevopsPull(adPull *azuredevops.PullRequest) (pull models.PullRequest, baseRepo models.Repo, headRepo models.Repo, err error) {
+	var prState models.PullRequestState
+	switch *adPull.Status {
+	case azuredevops.PullActiveStatus:
+		prState = models.OpenPullState
+	case azuredevops.PullAbandonedStatus, azuredevops.PullCompletedStatus:
+		prState = models.ClosedPullState
+	default:
+		err = fmt.Errorf("unable to determine pull request state from %q–this is a bug", *adPull.Status)
+		return
+	}
+
+	baseRepo, err = e.parseAzureDevopsRepo(adPull.Repository)
+	if err != nil {
+		return
+	}
+	// Pull requests from forks aren't supported so the head repo is the
+	// base repo.
+	headRepo = baseRepo
+
+	var baseBranch string
+	if adPull.TargetRefName != nil {
+		baseBranch = strings.TrimPrefix(*adPull.TargetRefName, "refs/heads/")
+	}
+
+	pull = models.PullRequest{
+		Num:        *adPull.PullRequestID,
+		HeadCommit: *adPull.LastMergeSourceCommit.CommitID,
+		URL:        fmt.Sprintf("%s/pullrequest/%d", baseRepo.SanitizedCloneURL, *adPull.PullRequestID),
+		Branch:     strings.TrimPrefix(*adPull.SourceRefName, "refs/heads/"),
+		BaseBranch: baseBranch,
+		Author:     *adPull.CreatedBy.UniqueName,
+		State:      prState,
+		BaseRepo:   baseRepo,
+	}
+	return
+}
+
+// parseAzureDevopsRepo parses the repo from its remote url, ex.
+// https://org@dev.azure.com/org/project/_git/repo. Its full name is
+// {organization}/{project}/{repo}.
+func (e *EventParser) parseAzureDevopsRepo(adRepo *azuredevops.Repository) (models.Repo, error) {
+	remoteURL, err := url.Parse(*adRepo.RemoteURL)
+	if err != nil {
+		return models.Repo{}, errors.Wrapf(err, "parsing remote url %q", *adRepo.RemoteURL)
+	}
+	// The remote url can contain the organization as its user which we
+	// don't want in the clone url.
+	remoteURL.User = nil
+	parts := strings.Split(strings.Trim(remoteURL.Path, "/"), "/")
+	if len(parts) != 4 || parts[2] != "_git" {
+		return models.Repo{}, fmt.Errorf("expected remote url %q to have path /{organization}/{project}/_git/{repo}", *adRepo.RemoteURL)
+	}
+	fullName := fmt.Sprintf("%s/%s/%s", parts[0], *adRepo.Project.Name, *adRepo.Name)
+	return models.NewRepo(models.AzureDevops, fullName, remoteURL.String(), e.AzureDevopsUser, e.AzureDevopsToken)
+}
+
+// ParseGiteaPullEvent parses Gitea pull request events.
+// See EventParsing for return value docs.
+func (e *EventParser) ParseGiteaPullEvent(event gitea.PullRequestEvent) (pull models.PullRequest, pullEventType models.PullRequestEventType, baseRepo models.Repo, headRepo models.Repo, user models.User, err error) {
+	if err = validator.New().Struct(event); err != nil {
+		err = errors.Wrap(err, "event was missing fields")
+		return
+	}
+	pull, baseRepo, headRepo, err = e.ParseGiteaPull(event.PullRequest)
+	if err != nil {
+		return
+	}
+	switch *event.Action {
+	case gitea.PullOpenedAction:
+		pullEventType = models.OpenedPullEvent
+	case gitea.PullSynchronizedAction:
+		pullEventType = models.UpdatedPullEvent
+	case gitea.PullClosedAction:
+		pullEventType = models.ClosedPullEvent
+	default:
+		pullEventType = models.OtherPullEvent
+	}
+	user = models.User{Username: *event.Sender.Login}
+	return
+}
+
+// ParseGiteaIssueCommentEvent parses Gitea pull request comment events.
+// See EventParsing for return value docs.
+func (e *EventParser) ParseGiteaIssueCommentEvent(event gitea.IssueCommentEvent) (baseRepo models.Repo, user models.User, pullNum int, err error) {
+	if err = validator.New().Struct(event); err != nil {
+		err = errors.Wrap(err, "event was missing fields")
+		return
+	}
+	baseRepo, err = e.parseGiteaRepo(event.Repository)
+	if err != nil {
+		return
+	}
+	user = models.User{Username: *event.Comment.User.Login}
+	pullNum = *event.Issue.Number
+	return
+}
+
+// ParseGiteaPull parses the response from the Gitea API endpoint that
+// returns a pull request. The pull requests in webhook events are in the
+// same format.
+// See EventParsing for return value docs.
+func (e *EventParser) ParseGiteaPull(giteaPull *gitea.PullRequest) (pull models.PullRequest, baseRepo models.Repo, headRepo models.Repo, err error) {
+	if err = validator.New().Struct(giteaPull); err != nil {
+		err = errors.Wrap(err, "pull request was missing fields")
+		return
+	}
+	baseRepo, err = e.parseGiteaRepo(giteaPull.Base.Repo)
+	if err != nil {
+		return
+	}
+	headRepo, err = e.parseGiteaRepo(giteaPull.Head.Repo)
+	if err != nil {
+		return
+	}
+
+	pullState := models.ClosedPullState
+	if *giteaPull.State == gitea.PullOpenState {
+		pullState = models.OpenPullState
+	}
+
+	pull = models.PullRequest{
+		Author:     *giteaPull.User.Login,
+		Branch:     *giteaPull.Head.Ref,
+		BaseBranch: *giteaPull.Base.Ref,
+		HeadCommit: *giteaPull.Head.Sha,
+		URL:        *giteaPull.HTMLURL,
+		Num:        *giteaPull.Number,
+		State:      pullState,
+		BaseRepo:   baseRepo,
+	}
+	return
+}
+
+func (e *EventParser) parseGiteaRepo(giteaRepo *gitea.Repository) (models.Repo, error) {
+	return models.NewRepo(models.Gitea, *giteaRepo.FullName, *giteaRepo.CloneURL, e.GiteaUser, e.GiteaToken)
+}
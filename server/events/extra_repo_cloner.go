@@ -0,0 +1,71 @@
+package events
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// RepoResolver returns the repo, with the credentials to clone it, for a
+// hostname and full name, ex. github.com/owner/modules.
+type RepoResolver interface {
+	Resolve(id string) (models.Repo, error)
+}
+
+// ExtraRepoCloner clones the extra_repos of projects into their workspaces
+// so they can call modules from other repos by relative path.
+type ExtraRepoCloner struct {
+	Resolver RepoResolver
+	// mutex serializes clones since projects that run at the same time can
+	// share a workspace and its extra repos.
+	mutex sync.Mutex
+}
+
+// Clone clones extraRepos into the clone of the repo at repoDir. If update is
+// false, repos that were already cloned are left as they are so that apply
+// uses the same code as plan. Otherwise they're fetched again in case their
+// ref is a branch that's moved.
+func (e *ExtraRepoCloner) Clone(log *logging.SimpleLogger, repoDir string, extraRepos []valid.ExtraRepo, update bool) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	for _, extra := range extraRepos {
+		dest := filepath.Join(repoDir, filepath.FromSlash(extra.Dir))
+		_, err := os.Stat(filepath.Join(dest, ".git"))
+		exists := err == nil
+		if exists && !update {
+			continue
+		}
+		repo, err := e.Resolver.Resolve(extra.Repo)
+		if err != nil {
+			return errors.Wrapf(err, "cloning extra repo %s", extra.Repo)
+		}
+		if !exists {
+			if err := os.MkdirAll(dest, 0700); err != nil {
+				return errors.Wrapf(err, "creating dir for extra repo %s", extra.Repo)
+			}
+		}
+		log.Info("cloning %s at %q into %q", repo.SanitizedCloneURL, extra.Ref, extra.Dir)
+		// Fetching the ref into an empty repo works for branches, tags and
+		// commits, and only fetches the one commit.
+		for _, args := range [][]string{
+			{"init", "--quiet"},
+			{"fetch", "--quiet", "--depth=1", "--force", "--", repo.CloneURL, extra.Ref},
+			{"checkout", "--quiet", "--force", "--detach", "FETCH_HEAD"},
+		} {
+			cmd := exec.Command("git", args...) // #nosec
+			cmd.Dir = dest
+			if out, err := cmd.CombinedOutput(); err != nil {
+				output := strings.Replace(string(out), repo.CloneURL, repo.SanitizedCloneURL, -1)
+				return errors.Wrapf(err, "cloning extra repo %s at %q: git %s: %s", extra.Repo, extra.Ref, args[0], output)
+			}
+		}
+	}
+	return nil
+}
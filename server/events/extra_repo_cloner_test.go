@@ -0,0 +1,66 @@
+package events_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+// localRepoResolver resolves every repo to a local one.
+type localRepoResolver struct {
+	cloneURL string
+}
+
+func (l localRepoResolver) Resolve(id string) (models.Repo, error) {
+	if l.cloneURL == "" {
+		return models.Repo{}, errors.New("not allowed")
+	}
+	return models.Repo{FullName: id, CloneURL: l.cloneURL, SanitizedCloneURL: l.cloneURL}, nil
+}
+
+func TestExtraRepoCloner_Clone(t *testing.T) {
+	modulesDir, firstCommit, cleanupModules := initRepo(t)
+	defer cleanupModules()
+	Ok(t, ioutil.WriteFile(filepath.Join(modulesDir, "main.tf"), []byte("v1"), 0600))
+	runGit(t, modulesDir, "add", ".")
+	runGit(t, modulesDir, "-c", "user.name=atlantis", "-c", "user.email=atlantis@example.com", "commit", "-m", "v1")
+	runGit(t, modulesDir, "tag", "v1")
+	repoDir, cleanupRepo := TempDir(t)
+	defer cleanupRepo()
+
+	cloner := &events.ExtraRepoCloner{Resolver: localRepoResolver{cloneURL: modulesDir}}
+	extras := []valid.ExtraRepo{{Repo: "github.com/owner/modules", Ref: "v1", Dir: "vendor/modules"}}
+	Ok(t, cloner.Clone(logging.NewNoopLogger(), repoDir, extras, true))
+	contents, err := ioutil.ReadFile(filepath.Join(repoDir, "vendor", "modules", "main.tf"))
+	Ok(t, err)
+	Equals(t, "v1", string(contents))
+
+	t.Log("clones are kept unless they're updated")
+	extras[0].Ref = firstCommit
+	Ok(t, cloner.Clone(logging.NewNoopLogger(), repoDir, extras, false))
+	_, err = ioutil.ReadFile(filepath.Join(repoDir, "vendor", "modules", "main.tf"))
+	Ok(t, err)
+	Ok(t, cloner.Clone(logging.NewNoopLogger(), repoDir, extras, true))
+	Equals(t, firstCommit, runGit(t, filepath.Join(repoDir, "vendor", "modules"), "rev-parse", "HEAD"))
+}
+
+func TestExtraRepoCloner_Errors(t *testing.T) {
+	repoDir, cleanupRepo := TempDir(t)
+	defer cleanupRepo()
+	extras := []valid.ExtraRepo{{Repo: "github.com/owner/modules", Ref: "v1", Dir: "modules"}}
+
+	cloner := &events.ExtraRepoCloner{Resolver: localRepoResolver{}}
+	ErrEquals(t, "cloning extra repo github.com/owner/modules: not allowed", cloner.Clone(logging.NewNoopLogger(), repoDir, extras, true))
+
+	modulesDir, _, cleanupModules := initRepo(t)
+	defer cleanupModules()
+	cloner = &events.ExtraRepoCloner{Resolver: localRepoResolver{cloneURL: modulesDir}}
+	ErrContains(t, `cloning extra repo github.com/owner/modules at "v1": git fetch: `, cloner.Clone(logging.NewNoopLogger(), repoDir, extras, true))
+}
@@ -0,0 +1,131 @@
+package events
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/jobs"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// InterruptedJobNote is added to the output of the jobs that were running
+// when Atlantis stopped.
+const InterruptedJobNote = "Atlantis stopped before this job finished."
+
+// InterruptedJobRecoverer finds the jobs that were still running when
+// Atlantis last stopped, ex. because it crashed, and records that they
+// failed. Jobs are saved when they start so their records are how we know
+// what was running. An interrupted apply may have been partially applied so
+// we discard its plan, set a failing apply status and comment on its pull
+// request to say it has to be planned again.
+type InterruptedJobRecoverer struct {
+	Store jobs.Store
+	// Instance is our hostname. If set, only the jobs started by an
+	// Atlantis on the same host are recovered so that when instances share
+	// the Store we don't recover the jobs other instances are running.
+	Instance            string
+	WorkingDir          WorkingDir
+	VCSClient           vcs.ClientProxy
+	CommitStatusUpdater CommitStatusUpdater
+	// ExecutableName is the name that comments start with to run commands.
+	ExecutableName string
+	Logger         *logging.SimpleLogger
+}
+
+// interruptedPull is a pull request with interrupted applies.
+type interruptedPull struct {
+	repo     models.Repo
+	pull     models.PullRequest
+	projects []string
+}
+
+// Recover recovers the interrupted jobs. It must be called before we start
+// running jobs.
+func (r *InterruptedJobRecoverer) Recover() error {
+	list, err := r.Store.ListJobs()
+	if err != nil {
+		return errors.Wrap(err, "listing jobs")
+	}
+
+	pulls := make(map[string]*interruptedPull)
+	for _, j := range list {
+		if j.Status != models.RunningJobStatus || (r.Instance != "" && j.Instance != r.Instance) {
+			continue
+		}
+		// ListJobs doesn't return the output which we append to.
+		job, err := r.Store.GetJob(j.ID)
+		if err != nil || job == nil {
+			r.Logger.Warn("unable to get interrupted job %s: %v", j.ID, err)
+			continue
+		}
+		job.Status = models.FailedJobStatus
+		job.FinishedAt = time.Now()
+		job.Output += InterruptedJobNote + "\n"
+		if err := r.Store.SaveJob(*job); err != nil {
+			r.Logger.Warn("unable to save interrupted job %s: %s", job.ID, err)
+		}
+		r.Logger.Warn("%s in repo %s, pull %d, dir %q, workspace %q was interrupted", job.Command, job.RepoFullName, job.PullNum, job.RepoRelDir, job.Workspace)
+
+		// Jobs saved before we recorded the VCS host can't be recovered.
+		if job.Command != ApplyCommand.String() || job.VCSHost.Hostname == "" {
+			continue
+		}
+		repo := models.Repo{FullName: job.RepoFullName, VCSHost: job.VCSHost}
+		pull := models.PullRequest{Num: job.PullNum, HeadCommit: job.HeadCommit, URL: job.PullURL, BaseRepo: repo}
+		r.discardPlan(repo, pull, *job)
+
+		key := fmt.Sprintf("%s/%s#%d", repo.VCSHost.Hostname, repo.FullName, pull.Num)
+		if pulls[key] == nil {
+			pulls[key] = &interruptedPull{repo: repo, pull: pull}
+		}
+		pulls[key].projects = append(pulls[key].projects, fmt.Sprintf("dir: `%s` workspace: `%s`", job.RepoRelDir, job.Workspace))
+	}
+
+	var keys []string
+	for k := range pulls {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		p := pulls[k]
+		if err := r.CommitStatusUpdater.Update(p.repo, p.pull, models.FailedCommitStatus, ApplyCommand); err != nil {
+			r.Logger.Warn("unable to update commit status for %s: %s", k, err)
+		}
+		if err := r.VCSClient.CreateComment(p.repo, p.pull.Num, r.comment(p.projects)); err != nil {
+			r.Logger.Warn("unable to comment on %s: %s", k, err)
+		}
+	}
+	return nil
+}
+
+// discardPlan deletes the plan that job was applying so it can't be applied
+// again.
+func (r *InterruptedJobRecoverer) discardPlan(repo models.Repo, pull models.PullRequest, job models.Job) {
+	repoDir, err := r.WorkingDir.GetWorkingDir(repo, pull, job.Workspace)
+	if err != nil {
+		return
+	}
+	var projCfg *valid.Project
+	if job.ProjectName != "" {
+		projCfg = &valid.Project{Name: &job.ProjectName}
+	}
+	planPath := filepath.Join(repoDir, job.RepoRelDir, runtime.GetPlanFilename(job.Workspace, projCfg))
+	if err := os.Remove(planPath); err != nil && !os.IsNotExist(err) {
+		r.Logger.Warn("unable to discard plan %q: %s", planPath, err)
+	}
+}
+
+func (r *InterruptedJobRecoverer) comment(projects []string) string {
+	return fmt.Sprintf("**Error:** Atlantis stopped while applying:\n\n* %s\n\n"+
+		"These applies were interrupted and may have been partially applied so their plans were discarded. Comment `%s plan` to see what's left to apply.",
+		strings.Join(projects, "\n* "), executableName(r.ExecutableName))
+}
@@ -0,0 +1,86 @@
+package events_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	jobmocks "github.com/runatlantis/atlantis/server/events/jobs/mocks"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/models"
+	vcsmocks "github.com/runatlantis/atlantis/server/events/vcs/mocks"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestInterruptedJobRecoverer_Recover(t *testing.T) {
+	RegisterMockTestingT(t)
+	repoDir, cleanup := TempDir(t)
+	defer cleanup()
+	Ok(t, os.MkdirAll(filepath.Join(repoDir, "infra"), 0700))
+	planPath := filepath.Join(repoDir, "infra", "default.tfplan")
+	Ok(t, ioutil.WriteFile(planPath, nil, 0600))
+
+	vcsHost := models.VCSHost{Hostname: "github.com", Type: models.Github}
+	repo := models.Repo{FullName: "owner/repo", VCSHost: vcsHost}
+	pull := models.PullRequest{Num: 1, HeadCommit: "abc", BaseRepo: repo}
+	apply := models.Job{ID: "1", Command: "apply", RepoFullName: "owner/repo", PullNum: 1, RepoRelDir: "infra", Workspace: "default", VCSHost: vcsHost, HeadCommit: "abc", Instance: "host", Status: models.RunningJobStatus, Output: "Applying...\n"}
+	plan := models.Job{ID: "2", Command: "plan", RepoFullName: "owner/repo", PullNum: 2, Workspace: "default", VCSHost: vcsHost, Instance: "host", Status: models.RunningJobStatus}
+	otherInstance := models.Job{ID: "3", Command: "apply", RepoFullName: "owner/repo", PullNum: 3, VCSHost: vcsHost, Instance: "other-host", Status: models.RunningJobStatus}
+	finished := models.Job{ID: "4", Command: "apply", RepoFullName: "owner/repo", PullNum: 4, VCSHost: vcsHost, Instance: "host", Status: models.SucceededJobStatus}
+
+	store := jobmocks.NewMockStore()
+	When(store.ListJobs()).ThenReturn([]models.Job{finished, otherInstance, plan, apply}, nil)
+	When(store.GetJob("1")).ThenReturn(&apply, nil)
+	When(store.GetJob("2")).ThenReturn(&plan, nil)
+	workingDir := mocks.NewMockWorkingDir()
+	When(workingDir.GetWorkingDir(repo, pull, "default")).ThenReturn(repoDir, nil)
+	vcsClient := vcsmocks.NewMockClientProxy()
+	statusUpdater := mocks.NewMockCommitStatusUpdater()
+
+	recoverer := &events.InterruptedJobRecoverer{
+		Store:               store,
+		Instance:            "host",
+		WorkingDir:          workingDir,
+		VCSClient:           vcsClient,
+		CommitStatusUpdater: statusUpdater,
+		Logger:              logging.NewNoopLogger(),
+	}
+	Ok(t, recoverer.Recover())
+
+	saved := store.VerifyWasCalled(Times(2)).SaveJob(matchers.AnyModelsJob()).GetAllCapturedArguments()
+	Equals(t, "1", saved[1].ID)
+	Equals(t, models.FailedJobStatus, saved[1].Status)
+	Equals(t, "Applying...\n"+events.InterruptedJobNote+"\n", saved[1].Output)
+	Assert(t, !saved[1].FinishedAt.IsZero(), "exp FinishedAt to be set")
+	Equals(t, "2", saved[0].ID)
+	Equals(t, models.FailedJobStatus, saved[0].Status)
+
+	_, err := os.Stat(planPath)
+	Assert(t, os.IsNotExist(err), "exp plan to be discarded")
+	statusUpdater.VerifyWasCalledOnce().Update(repo, models.PullRequest{Num: 1, HeadCommit: "abc", BaseRepo: repo}, models.FailedCommitStatus, events.ApplyCommand)
+	_, pullNum, comment := vcsClient.VerifyWasCalledOnce().CreateComment(matchers.AnyModelsRepo(), AnyInt(), AnyString()).GetCapturedArguments()
+	Equals(t, 1, pullNum)
+	Equals(t, "**Error:** Atlantis stopped while applying:\n\n* dir: `infra` workspace: `default`\n\n"+
+		"These applies were interrupted and may have been partially applied so their plans were discarded. Comment `atlantis plan` to see what's left to apply.", comment)
+}
+
+func TestInterruptedJobRecoverer_AllInstances(t *testing.T) {
+	RegisterMockTestingT(t)
+	job := models.Job{ID: "1", Command: "plan", Instance: "other-host", Status: models.RunningJobStatus}
+	store := jobmocks.NewMockStore()
+	When(store.ListJobs()).ThenReturn([]models.Job{job}, nil)
+	When(store.GetJob("1")).ThenReturn(&job, nil)
+
+	recoverer := &events.InterruptedJobRecoverer{
+		Store:  store,
+		Logger: logging.NewNoopLogger(),
+	}
+	Ok(t, recoverer.Recover())
+	saved := store.VerifyWasCalledOnce().SaveJob(matchers.AnyModelsJob()).GetCapturedArguments()
+	Equals(t, models.FailedJobStatus, saved.Status)
+}
@@ -0,0 +1,75 @@
+package jobs
+
+import "sync"
+
+// subscriberBuffer is how many chunks of output can be queued for a
+// subscriber before it's considered too slow and dropped.
+const subscriberBuffer = 100
+
+// Broker fans the output of running jobs out to the subscribers watching
+// them, ex. websocket connections.
+type Broker struct {
+	mu   sync.Mutex
+	subs map[string]map[chan string]struct{}
+}
+
+// NewBroker returns a Broker with no subscribers.
+func NewBroker() *Broker {
+	return &Broker{subs: make(map[string]map[chan string]struct{})}
+}
+
+// Subscribe returns a channel that receives the output published for the job
+// with id. The channel is closed when the job finishes or if the subscriber
+// can't keep up. The returned func unsubscribes and must be called once the
+// subscriber is done.
+func (b *Broker) Subscribe(id string) (<-chan string, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ch := make(chan string, subscriberBuffer)
+	if b.subs[id] == nil {
+		b.subs[id] = make(map[chan string]struct{})
+	}
+	b.subs[id][ch] = struct{}{}
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.remove(id, ch)
+	}
+}
+
+// Publish sends output to the subscribers of the job with id. It never blocks:
+// subscribers whose buffer is full are dropped so a slow connection can't hold
+// up the command.
+func (b *Broker) Publish(id string, output string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs[id] {
+		select {
+		case ch <- output:
+		default:
+			b.remove(id, ch)
+		}
+	}
+}
+
+// Close closes the channels of all the subscribers of the job with id.
+func (b *Broker) Close(id string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs[id] {
+		b.remove(id, ch)
+	}
+}
+
+// remove closes ch and removes it from the subscribers of the job with id if
+// it's still subscribed. b.mu must be held.
+func (b *Broker) remove(id string, ch chan string) {
+	if _, ok := b.subs[id][ch]; !ok {
+		return
+	}
+	close(ch)
+	delete(b.subs[id], ch)
+	if len(b.subs[id]) == 0 {
+		delete(b.subs, id)
+	}
+}
@@ -0,0 +1,219 @@
+// Package jobs keeps track of the plans and applies Atlantis runs so they can
+// be viewed in the UI while they're running and after they've finished.
+package jobs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// Retention is how long finished jobs are kept in the Store for.
+const Retention = 14 * 24 * time.Hour
+
+// saveInterval is how often the output of running jobs is saved to the
+// Store. Until it's saved, other Atlantis instances sharing the Store won't
+// see it.
+const saveInterval = 2 * time.Second
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_store.go Store
+
+// Store persists jobs. It's implemented by the locking backends so jobs are
+// stored in the same database as the locks.
+type Store interface {
+	// SaveJob creates or updates job. Jobs older than Retention may be
+	// deleted.
+	SaveJob(job models.Job) error
+	// GetJob returns the job with id or nil if there isn't one.
+	GetJob(id string) (*models.Job, error)
+	// ListJobs returns all the jobs, newest first. Their output isn't set.
+	ListJobs() ([]models.Job, error)
+}
+
+// Tracker records jobs as they run. The jobs running in this process are kept
+// in memory so their output can be viewed as it's written.
+type Tracker struct {
+	Store  Store
+	Logger *logging.SimpleLogger
+	// Broker streams the output of running jobs to their subscribers.
+	Broker *Broker
+	// Instance is set on the jobs we start so it's known which Atlantis
+	// instance was running them.
+	Instance string
+
+	mu      sync.Mutex
+	running map[string]*runningJob
+	// lastID is the ID of the last job we started. It's used to make sure
+	// IDs are unique even if two jobs start at the same time.
+	lastID string
+}
+
+// runningJob is a job that's running in this process.
+type runningJob struct {
+	job     models.Job
+	output  strings.Builder
+	savedAt time.Time
+}
+
+// NewTracker returns a Tracker that saves jobs to store.
+func NewTracker(store Store, logger *logging.SimpleLogger) *Tracker {
+	return &Tracker{
+		Store:   store,
+		Logger:  logger,
+		Broker:  NewBroker(),
+		running: make(map[string]*runningJob),
+	}
+}
+
+// Start records that job has started and returns its ID. job's ID, Status,
+// StartedAt and Output are set by Start.
+func (t *Tracker) Start(job models.Job) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	job.StartedAt = time.Now()
+	job.ID = fmt.Sprintf("%019d", job.StartedAt.UnixNano())
+	if job.ID <= t.lastID {
+		job.ID = t.lastID + "0"
+	}
+	t.lastID = job.ID
+	job.Status = models.RunningJobStatus
+	job.Output = ""
+	job.Instance = t.Instance
+
+	r := &runningJob{job: job}
+	t.running[job.ID] = r
+	t.save(r)
+	return job.ID
+}
+
+// AppendOutput adds line to the output of the job with id.
+func (t *Tracker) AppendOutput(id string, line string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.running[id]
+	if !ok {
+		return
+	}
+	r.output.WriteString(line)
+	r.output.WriteString("\n")
+	t.Broker.Publish(id, line+"\n")
+	if time.Since(r.savedAt) >= saveInterval {
+		t.save(r)
+	}
+}
+
+// Finish records that the job with id has finished. success is whether it
+// succeeded.
+func (t *Tracker) Finish(id string, success bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.running[id]
+	if !ok {
+		return
+	}
+	r.job.FinishedAt = time.Now()
+	r.job.Status = models.FailedJobStatus
+	if success {
+		r.job.Status = models.SucceededJobStatus
+	}
+	t.save(r)
+	delete(t.running, id)
+	t.Broker.Close(id)
+}
+
+// Interrupt records that the running jobs failed because Atlantis is
+// shutting down, adding note to their output. It's called when they didn't
+// finish in time so that they aren't left running in the Store. It returns
+// how many jobs were running.
+func (t *Tracker) Interrupt(note string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := len(t.running)
+	for id, r := range t.running {
+		r.output.WriteString(note)
+		r.output.WriteString("\n")
+		r.job.FinishedAt = time.Now()
+		r.job.Status = models.FailedJobStatus
+		t.save(r)
+		delete(t.running, id)
+		t.Broker.Close(id)
+	}
+	return n
+}
+
+// Get returns the job with id, or nil if there isn't one.
+func (t *Tracker) Get(id string) (*models.Job, error) {
+	t.mu.Lock()
+	if r, ok := t.running[id]; ok {
+		job := r.current()
+		t.mu.Unlock()
+		return &job, nil
+	}
+	t.mu.Unlock()
+	return t.Store.GetJob(id)
+}
+
+// Subscribe returns the job with id, or nil if there isn't one, along with a
+// channel that receives its output from then on. Together they make up all of
+// the job's output. The channel is nil if the job isn't running in this
+// process, otherwise it's closed once the job finishes. The returned func must
+// be called once the subscriber is done.
+func (t *Tracker) Subscribe(id string) (*models.Job, <-chan string, func(), error) {
+	t.mu.Lock()
+	if r, ok := t.running[id]; ok {
+		defer t.mu.Unlock()
+		job := r.current()
+		ch, unsubscribe := t.Broker.Subscribe(id)
+		return &job, ch, unsubscribe, nil
+	}
+	t.mu.Unlock()
+	job, err := t.Store.GetJob(id)
+	return job, nil, func() {}, err
+}
+
+// List returns all the jobs, newest first, without their output.
+func (t *Tracker) List() ([]models.Job, error) {
+	stored, err := t.Store.ListJobs()
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var jobs []models.Job
+	for _, r := range t.running {
+		job := r.job
+		job.Output = ""
+		jobs = append(jobs, job)
+	}
+	for _, job := range stored {
+		// Our copy of a running job is more up to date.
+		if _, ok := t.running[job.ID]; !ok {
+			jobs = append(jobs, job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID > jobs[j].ID })
+	return jobs, nil
+}
+
+// save saves r to the Store. Errors are logged rather than returned because
+// a job we can't save shouldn't stop the command from running. t.mu must be
+// held.
+func (t *Tracker) save(r *runningJob) {
+	r.savedAt = time.Now()
+	if err := t.Store.SaveJob(r.current()); err != nil {
+		t.Logger.Warn("unable to save job %s: %s", r.job.ID, err)
+	}
+}
+
+// current returns the job with the output written so far.
+func (r *runningJob) current() models.Job {
+	job := r.job
+	job.Output = r.output.String()
+	return job
+}
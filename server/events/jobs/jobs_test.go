@@ -0,0 +1,187 @@
+package jobs_test
+
+import (
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/jobs"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestTracker(t *testing.T) {
+	store := &memoryStore{jobs: make(map[string]models.Job)}
+	tracker := jobs.NewTracker(store, logging.NewNoopLogger())
+
+	id := tracker.Start(models.Job{Command: "plan", RepoFullName: "owner/repo", PullNum: 1})
+	Equals(t, models.RunningJobStatus, store.jobs[id].Status)
+	Assert(t, !store.jobs[id].StartedAt.IsZero(), "exp StartedAt to be set")
+
+	tracker.AppendOutput(id, "Refreshing state...")
+	tracker.AppendOutput(id, "Plan: 1 to add, 0 to change, 0 to destroy.")
+
+	// While the job is running, its output comes from memory rather than the
+	// store.
+	job, err := tracker.Get(id)
+	Ok(t, err)
+	Equals(t, "Refreshing state...\nPlan: 1 to add, 0 to change, 0 to destroy.\n", job.Output)
+	Equals(t, models.RunningJobStatus, job.Status)
+
+	tracker.Finish(id, true)
+	Equals(t, models.SucceededJobStatus, store.jobs[id].Status)
+	Equals(t, "Refreshing state...\nPlan: 1 to add, 0 to change, 0 to destroy.\n", store.jobs[id].Output)
+	Assert(t, !store.jobs[id].FinishedAt.IsZero(), "exp FinishedAt to be set")
+
+	// Once it's finished, it's only in the store.
+	tracker.AppendOutput(id, "ignored")
+	job, err = tracker.Get(id)
+	Ok(t, err)
+	Equals(t, "Refreshing state...\nPlan: 1 to add, 0 to change, 0 to destroy.\n", job.Output)
+
+	failed := tracker.Start(models.Job{Command: "apply"})
+	tracker.Finish(failed, false)
+	Equals(t, models.FailedJobStatus, store.jobs[failed].Status)
+
+	job, err = tracker.Get("missing")
+	Ok(t, err)
+	Assert(t, job == nil, "exp no job")
+}
+
+// Interrupt should fail the running jobs and save them with the note.
+func TestTracker_Interrupt(t *testing.T) {
+	store := &memoryStore{jobs: make(map[string]models.Job)}
+	tracker := jobs.NewTracker(store, logging.NewNoopLogger())
+	id := tracker.Start(models.Job{Command: "apply"})
+	tracker.AppendOutput(id, "Applying...")
+	finished := tracker.Start(models.Job{Command: "plan"})
+	tracker.Finish(finished, true)
+
+	Equals(t, 1, tracker.Interrupt("shutting down"))
+	Equals(t, models.FailedJobStatus, store.jobs[id].Status)
+	Equals(t, "Applying...\nshutting down\n", store.jobs[id].Output)
+	Equals(t, models.SucceededJobStatus, store.jobs[finished].Status)
+	Equals(t, 0, tracker.Interrupt("shutting down"))
+}
+
+// Jobs should be listed newest first, including the ones running in this
+// process, without their output.
+func TestTracker_List(t *testing.T) {
+	store := &memoryStore{jobs: make(map[string]models.Job)}
+	tracker := jobs.NewTracker(store, logging.NewNoopLogger())
+
+	// Jobs started at the same time should still get unique IDs.
+	var ids []string
+	for i := 0; i < 3; i++ {
+		ids = append(ids, tracker.Start(models.Job{}))
+	}
+	tracker.AppendOutput(ids[2], "output")
+	tracker.Finish(ids[0], true)
+
+	list, err := tracker.List()
+	Ok(t, err)
+	Equals(t, 3, len(list))
+	for i, job := range list {
+		Equals(t, ids[2-i], job.ID)
+		Equals(t, "", job.Output)
+	}
+	Equals(t, models.SucceededJobStatus, list[2].Status)
+}
+
+// Subscribers should get the output written after they subscribed, and the
+// job should include the output before.
+func TestTracker_Subscribe(t *testing.T) {
+	store := &memoryStore{jobs: make(map[string]models.Job)}
+	tracker := jobs.NewTracker(store, logging.NewNoopLogger())
+	id := tracker.Start(models.Job{})
+	tracker.AppendOutput(id, "before")
+
+	job, output, unsubscribe, err := tracker.Subscribe(id)
+	Ok(t, err)
+	defer unsubscribe()
+	Equals(t, "before\n", job.Output)
+
+	tracker.AppendOutput(id, "after")
+	Equals(t, "after\n", <-output)
+	tracker.Finish(id, true)
+	_, ok := <-output
+	Assert(t, !ok, "exp output to be closed when the job finishes")
+
+	// Once it's finished, there's nothing to subscribe to.
+	job, output, unsubscribe, err = tracker.Subscribe(id)
+	Ok(t, err)
+	defer unsubscribe()
+	Equals(t, "before\nafter\n", job.Output)
+	Assert(t, output == nil, "exp no output channel")
+}
+
+// Subscribers that can't keep up should be dropped rather than blocking.
+func TestBroker_SlowSubscriber(t *testing.T) {
+	broker := jobs.NewBroker()
+	slow, unsubscribeSlow := broker.Subscribe("id")
+	defer unsubscribeSlow()
+	for i := 0; i < 101; i++ {
+		broker.Publish("id", "line\n")
+	}
+	for range slow {
+	}
+
+	fast, unsubscribeFast := broker.Subscribe("id")
+	broker.Publish("id", "line\n")
+	Equals(t, "line\n", <-fast)
+	unsubscribeFast()
+	_, ok := <-fast
+	Assert(t, !ok, "exp unsubscribing to close the channel")
+	// Closing after unsubscribing shouldn't panic.
+	broker.Close("id")
+}
+
+func TestTracker_StoreErr(t *testing.T) {
+	store := &memoryStore{err: errors.New("err")}
+	tracker := jobs.NewTracker(store, logging.NewNoopLogger())
+
+	// Saving errors shouldn't stop jobs being tracked.
+	id := tracker.Start(models.Job{})
+	tracker.AppendOutput(id, "output")
+	job, err := tracker.Get(id)
+	Ok(t, err)
+	Equals(t, "output\n", job.Output)
+
+	_, err = tracker.List()
+	ErrEquals(t, "err", err)
+}
+
+type memoryStore struct {
+	jobs map[string]models.Job
+	err  error
+}
+
+func (m *memoryStore) SaveJob(job models.Job) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.jobs[job.ID] = job
+	return nil
+}
+
+func (m *memoryStore) GetJob(id string) (*models.Job, error) {
+	job, ok := m.jobs[id]
+	if !ok || m.err != nil {
+		return nil, m.err
+	}
+	return &job, nil
+}
+
+func (m *memoryStore) ListJobs() ([]models.Job, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	var list []models.Job
+	for _, job := range m.jobs {
+		job.Output = ""
+		list = append(list, job)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID > list[j].ID })
+	return list, nil
+}
@@ -0,0 +1,102 @@
+package events
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/locking"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+)
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_lock_queue_notifier.go LockQueueNotifier
+
+// LockQueueNotifier tells pull requests waiting for a lock where they are in
+// line.
+type LockQueueNotifier interface {
+	// Notify tells the pull request the lock at lockKey was given to, if
+	// any, and comments on each pull request still waiting for it with its
+	// current position in line. It should be called after the lock is
+	// released.
+	Notify(lockKey string) error
+}
+
+// DefaultLockQueueNotifier implements LockQueueNotifier.
+type DefaultLockQueueNotifier struct {
+	Locker    locking.Locker
+	VCSClient vcs.ClientProxy
+	// CommandRunner re-plans the pull request the lock was given to. If nil,
+	// the pull request is asked to re-plan itself.
+	CommandRunner CommandRunner
+	// ExecutableName is the name that comments start with to run commands.
+	// Defaults to DefaultExecutableName.
+	ExecutableName string
+}
+
+// Notify implements LockQueueNotifier.Notify.
+func (d *DefaultLockQueueNotifier) Notify(lockKey string) error {
+	// Releasing a lock gives it to the first pull in line so if it's held
+	// now, it's by that pull.
+	lock, err := d.Locker.GetLock(lockKey)
+	if err != nil {
+		return errors.Wrap(err, "getting lock")
+	}
+	if lock != nil {
+		if err := d.notifyPromoted(*lock); err != nil {
+			return err
+		}
+	}
+
+	queue, err := d.Locker.GetQueue(lockKey)
+	if err != nil {
+		return errors.Wrap(err, "getting lock queue")
+	}
+	for i, waiter := range queue {
+		// Locks from older versions of Atlantis may not have the BaseRepo
+		// field so we can't comment on them.
+		if waiter.Pull.BaseRepo == (models.Repo{}) {
+			continue
+		}
+		comment := fmt.Sprintf("The lock for dir: `%s` workspace: `%s` was released. This pull request is now #%d in line for it.",
+			waiter.Project.Path, waiter.Workspace, i+1)
+		if err := d.VCSClient.CreateComment(waiter.Pull.BaseRepo, waiter.Pull.Num, comment); err != nil {
+			return errors.Wrapf(err, "commenting on pull #%d", waiter.Pull.Num)
+		}
+	}
+	return nil
+}
+
+// notifyPromoted tells the pull request that was first in line that it now
+// holds lock and re-plans the project for it. Its plan, if it had one, was
+// made before the last holder's changes so it can't be trusted. We never
+// apply for it since nobody has reviewed the new plan yet.
+func (d *DefaultLockQueueNotifier) notifyPromoted(lock models.ProjectLock) error {
+	if lock.Pull.BaseRepo == (models.Repo{}) {
+		return nil
+	}
+	// The command runner only fetches the latest details of the pull
+	// request for these hosts. For the others it needs them from the
+	// webhook, which we don't have.
+	hostType := lock.Pull.BaseRepo.VCSHost.Type
+	replan := d.CommandRunner != nil && (hostType == models.Github || hostType == models.Gitlab || hostType == models.Gitea)
+
+	comment := fmt.Sprintf("The lock for dir: `%s` workspace: `%s` was released and given to this pull request.\n\n", lock.Project.Path, lock.Workspace)
+	if replan {
+		comment += fmt.Sprintf("Atlantis is re-planning it now. Once you've reviewed the new plan, comment `%s apply` to apply it.", executableName(d.ExecutableName))
+	} else {
+		comment += fmt.Sprintf("Comment `%s plan` here to re-plan.", executableName(d.ExecutableName))
+	}
+	if err := d.VCSClient.CreateComment(lock.Pull.BaseRepo, lock.Pull.Num, comment); err != nil {
+		return errors.Wrapf(err, "commenting on pull #%d", lock.Pull.Num)
+	}
+	if replan {
+		// We're called while handling other requests so the plan runs in
+		// the background, the same as commands from comments.
+		go d.CommandRunner.RunCommentCommand(lock.Pull.BaseRepo, nil, nil, lock.User, lock.Pull.Num, &CommentCommand{
+			Name:       PlanCommand,
+			RepoRelDir: lock.Project.Path,
+			Workspace:  lock.Workspace,
+		})
+	}
+	return nil
+}
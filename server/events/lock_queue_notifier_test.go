@@ -0,0 +1,80 @@
+package events_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	lockmocks "github.com/runatlantis/atlantis/server/events/locking/mocks"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/models"
+	vcsmocks "github.com/runatlantis/atlantis/server/events/vcs/mocks"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestDefaultLockQueueNotifier_Notify(t *testing.T) {
+	RegisterMockTestingT(t)
+	locker := lockmocks.NewMockLocker()
+	vcsClient := vcsmocks.NewMockClientProxy()
+	commandRunner := mocks.NewMockCommandRunner()
+	notifier := events.DefaultLockQueueNotifier{
+		Locker:        locker,
+		VCSClient:     vcsClient,
+		CommandRunner: commandRunner,
+	}
+	repo := models.Repo{FullName: "owner/repo", VCSHost: models.VCSHost{Type: models.Github}}
+	user := models.User{Username: "user"}
+	promoted := models.ProjectLock{
+		Project:   models.NewProject("owner/repo", "path"),
+		Workspace: "default",
+		Pull:      models.PullRequest{Num: 2, BaseRepo: repo},
+		User:      user,
+	}
+	waiter := promoted
+	waiter.Pull.Num = 3
+	oldFormatWaiter := promoted
+	oldFormatWaiter.Pull = models.PullRequest{Num: 4}
+	When(locker.GetLock("owner/repo/path/default")).ThenReturn(&promoted, nil)
+	When(locker.GetQueue("owner/repo/path/default")).ThenReturn([]models.ProjectLock{waiter, oldFormatWaiter}, nil)
+
+	err := notifier.Notify("owner/repo/path/default")
+	Ok(t, err)
+	vcsClient.VerifyWasCalledOnce().CreateComment(repo, 2, "The lock for dir: `path` workspace: `default` was released and given to this pull request.\n\n"+
+		"Atlantis is re-planning it now. Once you've reviewed the new plan, comment `atlantis apply` to apply it.")
+	vcsClient.VerifyWasCalledOnce().CreateComment(repo, 3, "The lock for dir: `path` workspace: `default` was released. This pull request is now #1 in line for it.")
+	vcsClient.VerifyWasCalled(Never()).CreateComment(matchers.AnyModelsRepo(), EqInt(4), AnyString())
+	commandRunner.VerifyWasCalledEventually(Once(), time.Second).RunCommentCommand(repo, nil, nil, user, 2, &events.CommentCommand{
+		Name:       events.PlanCommand,
+		RepoRelDir: "path",
+		Workspace:  "default",
+	})
+}
+
+// If we can't re-plan on the host, the pull given the lock should be asked
+// to re-plan itself.
+func TestDefaultLockQueueNotifier_NotifyCantReplan(t *testing.T) {
+	RegisterMockTestingT(t)
+	locker := lockmocks.NewMockLocker()
+	vcsClient := vcsmocks.NewMockClientProxy()
+	commandRunner := mocks.NewMockCommandRunner()
+	notifier := events.DefaultLockQueueNotifier{
+		Locker:        locker,
+		VCSClient:     vcsClient,
+		CommandRunner: commandRunner,
+	}
+	repo := models.Repo{FullName: "owner/repo", VCSHost: models.VCSHost{Type: models.BitbucketCloud}}
+	promoted := models.ProjectLock{
+		Project:   models.NewProject("owner/repo", "path"),
+		Workspace: "default",
+		Pull:      models.PullRequest{Num: 2, BaseRepo: repo},
+	}
+	When(locker.GetLock("owner/repo/path/default")).ThenReturn(&promoted, nil)
+
+	err := notifier.Notify("owner/repo/path/default")
+	Ok(t, err)
+	vcsClient.VerifyWasCalledOnce().CreateComment(repo, 2, "The lock for dir: `path` workspace: `default` was released and given to this pull request.\n\n"+
+		"Comment `atlantis plan` here to re-plan.")
+	commandRunner.VerifyWasCalled(Never()).RunCommentCommand(matchers.AnyModelsRepo(), matchers.AnyPtrToModelsRepo(), matchers.AnyPtrToModelsPullRequest(), matchers.AnyModelsUser(), AnyInt(), matchers.AnyPtrToEventsCommentCommand())
+}
@@ -0,0 +1,66 @@
+package boltdb
+
+import (
+	"encoding/json"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// applyLockKey is the key in the global locks bucket of the lock stopping all
+// applies.
+const applyLockKey = "apply"
+
+// LockApplies saves lock so all applies are stopped until UnlockApplies is
+// called.
+func (b *BoltLocker) LockApplies(lock models.ApplyLock) error {
+	serialized, err := json.Marshal(lock)
+	if err != nil {
+		return errors.Wrap(err, "serializing apply lock")
+	}
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(b.globalLocksBucket)
+		if err != nil {
+			return errors.Wrap(err, "creating global locks bucket")
+		}
+		return bucket.Put([]byte(applyLockKey), serialized)
+	})
+	return errors.Wrap(err, "DB transaction failed")
+}
+
+// UnlockApplies deletes the lock stopping all applies. It doesn't error if
+// there isn't one.
+func (b *BoltLocker) UnlockApplies() error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.globalLocksBucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.Delete([]byte(applyLockKey))
+	})
+	return errors.Wrap(err, "DB transaction failed")
+}
+
+// GetApplyLock returns the lock stopping all applies or nil if there isn't
+// one.
+func (b *BoltLocker) GetApplyLock() (*models.ApplyLock, error) {
+	var serialized []byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket(b.globalLocksBucket); bucket != nil {
+			serialized = bucket.Get([]byte(applyLockKey))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "DB transaction failed")
+	}
+	if serialized == nil {
+		return nil, nil
+	}
+	var lock models.ApplyLock
+	if err := json.Unmarshal(serialized, &lock); err != nil {
+		return nil, errors.Wrap(err, "deserializing apply lock")
+	}
+	return &lock, nil
+}
@@ -0,0 +1,51 @@
+package boltdb
+
+import (
+	"encoding/json"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// AppendAuditEvent adds event to the audit log.
+func (b *BoltLocker) AppendAuditEvent(event models.AuditEvent) error {
+	serialized, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "serializing audit event")
+	}
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(b.auditBucket)
+		if err != nil {
+			return errors.Wrap(err, "creating audit bucket")
+		}
+		return bucket.Put([]byte(event.ID), serialized)
+	})
+	return errors.Wrap(err, "DB transaction failed")
+}
+
+// ListAuditEvents returns up to limit events with IDs after afterID, oldest
+// first.
+func (b *BoltLocker) ListAuditEvents(afterID string, limit int) ([]models.AuditEvent, error) {
+	var list []models.AuditEvent
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.auditBucket)
+		if bucket == nil {
+			return nil
+		}
+		c := bucket.Cursor()
+		k, v := c.Seek([]byte(afterID))
+		if k != nil && string(k) == afterID {
+			k, v = c.Next()
+		}
+		for ; k != nil && len(list) < limit; k, v = c.Next() {
+			var event models.AuditEvent
+			if err := json.Unmarshal(v, &event); err != nil {
+				return errors.Wrapf(err, "deserializing audit event %q", k)
+			}
+			list = append(list, event)
+		}
+		return nil
+	})
+	return list, errors.Wrap(err, "DB transaction failed")
+}
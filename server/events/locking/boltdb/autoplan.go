@@ -0,0 +1,36 @@
+package boltdb
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/autoplan"
+)
+
+// SetAutoplanDisabled turns autoplanning of the pull off if disabled is true,
+// or back on if it's false. Only the pulls it's off for are stored.
+func (b *BoltLocker) SetAutoplanDisabled(repoFullName string, pullNum int, disabled bool) error {
+	key := []byte(autoplan.Key(repoFullName, pullNum))
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(b.autoplanBucket)
+		if err != nil {
+			return errors.Wrap(err, "creating autoplan bucket")
+		}
+		if disabled {
+			return bucket.Put(key, []byte("disabled"))
+		}
+		return bucket.Delete(key)
+	})
+	return errors.Wrap(err, "DB transaction failed")
+}
+
+// AutoplanDisabled returns true if autoplanning of the pull is off.
+func (b *BoltLocker) AutoplanDisabled(repoFullName string, pullNum int) (bool, error) {
+	var disabled bool
+	err := b.db.View(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket(b.autoplanBucket); bucket != nil {
+			disabled = bucket.Get([]byte(autoplan.Key(repoFullName, pullNum))) != nil
+		}
+		return nil
+	})
+	return disabled, errors.Wrap(err, "DB transaction failed")
+}
@@ -33,9 +33,38 @@ import (
 type BoltLocker struct {
 	db     *bolt.DB
 	bucket []byte
+	// queueBucket stores the pulls waiting for each lock. It's keyed the
+	// same as bucket and each value is a serialized []models.ProjectLock in
+	// the order the pulls started waiting.
+	queueBucket []byte
+	// jobsBucket stores the jobs Atlantis has run, keyed by their ID.
+	jobsBucket []byte
+	// globalLocksBucket stores the locks that aren't for a single project,
+	// ex. the lock stopping all applies.
+	globalLocksBucket []byte
+	// auditBucket stores the audit log, keyed by the events' IDs.
+	auditBucket []byte
+	// plansBucket stores the outputs of plans, keyed by their IDs.
+	plansBucket []byte
+	// workQueueBucket stores the work queue, keyed by the items' IDs.
+	workQueueBucket []byte
+	// autoplanBucket stores the pulls that autoplanning is off for, keyed by
+	// autoplan.Key.
+	autoplanBucket []byte
+	// pendingAppliesBucket stores the applies waiting to be confirmed, keyed
+	// by confirmations.Key.
+	pendingAppliesBucket []byte
 }
 
 const bucketName = "runLocks"
+const queueBucketName = "runLockQueues"
+const jobsBucketName = "jobs"
+const globalLocksBucketName = "globalLocks"
+const auditBucketName = "audit"
+const plansBucketName = "plans"
+const workQueueBucketName = "workQueue"
+const autoplanBucketName = "autoplanDisabled"
+const pendingAppliesBucketName = "pendingApplies"
 
 // New returns a valid locker. We need to be able to write to dataDir
 // since bolt stores its data as a file
@@ -54,24 +83,50 @@ func New(dataDir string) (*BoltLocker, error) {
 		if _, err = tx.CreateBucketIfNotExists([]byte(bucketName)); err != nil {
 			return errors.Wrapf(err, "creating %q bucketName", bucketName)
 		}
+		if _, err = tx.CreateBucketIfNotExists([]byte(queueBucketName)); err != nil {
+			return errors.Wrapf(err, "creating %q bucketName", queueBucketName)
+		}
+		if _, err = tx.CreateBucketIfNotExists([]byte(jobsBucketName)); err != nil {
+			return errors.Wrapf(err, "creating %q bucketName", jobsBucketName)
+		}
+		if _, err = tx.CreateBucketIfNotExists([]byte(globalLocksBucketName)); err != nil {
+			return errors.Wrapf(err, "creating %q bucketName", globalLocksBucketName)
+		}
+		if _, err = tx.CreateBucketIfNotExists([]byte(auditBucketName)); err != nil {
+			return errors.Wrapf(err, "creating %q bucketName", auditBucketName)
+		}
+		if _, err = tx.CreateBucketIfNotExists([]byte(plansBucketName)); err != nil {
+			return errors.Wrapf(err, "creating %q bucketName", plansBucketName)
+		}
+		if _, err = tx.CreateBucketIfNotExists([]byte(workQueueBucketName)); err != nil {
+			return errors.Wrapf(err, "creating %q bucketName", workQueueBucketName)
+		}
+		if _, err = tx.CreateBucketIfNotExists([]byte(autoplanBucketName)); err != nil {
+			return errors.Wrapf(err, "creating %q bucketName", autoplanBucketName)
+		}
+		if _, err = tx.CreateBucketIfNotExists([]byte(pendingAppliesBucketName)); err != nil {
+			return errors.Wrapf(err, "creating %q bucketName", pendingAppliesBucketName)
+		}
 		return nil
 	})
 	if err != nil {
 		return nil, errors.Wrap(err, "starting BoltDB")
 	}
 	// todo: close BoltDB when server is sigtermed
-	return &BoltLocker{db, []byte(bucketName)}, nil
+	return &BoltLocker{db, []byte(bucketName), []byte(queueBucketName), []byte(jobsBucketName), []byte(globalLocksBucketName), []byte(auditBucketName), []byte(plansBucketName), []byte(workQueueBucketName), []byte(autoplanBucketName), []byte(pendingAppliesBucketName)}, nil
 }
 
 // NewWithDB is used for testing.
 func NewWithDB(db *bolt.DB, bucket string) (*BoltLocker, error) {
-	return &BoltLocker{db, []byte(bucket)}, nil
+	return &BoltLocker{db, []byte(bucket), []byte(queueBucketName), []byte(jobsBucketName), []byte(globalLocksBucketName), []byte(auditBucketName), []byte(plansBucketName), []byte(workQueueBucketName), []byte(autoplanBucketName), []byte(pendingAppliesBucketName)}, nil
 }
 
 // TryLock attempts to create a new lock. If the lock is
 // acquired, it will return true and the lock returned will be newLock.
 // If the lock is not acquired, it will return false and the current
-// lock that is preventing this lock from being acquired.
+// lock that is preventing this lock from being acquired. If the current lock
+// is held by a different pull request, newLock is added to the end of the
+// queue of pulls waiting for the lock.
 func (b *BoltLocker) TryLock(newLock models.ProjectLock) (bool, models.ProjectLock, error) {
 	var lockAcquired bool
 	var currLock models.ProjectLock
@@ -80,6 +135,15 @@ func (b *BoltLocker) TryLock(newLock models.ProjectLock) (bool, models.ProjectLo
 	transactionErr := b.db.Update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(b.bucket)
 
+		queueBucket, err := tx.CreateBucketIfNotExists(b.queueBucket)
+		if err != nil {
+			return errors.Wrap(err, "creating queue bucket")
+		}
+		queue, err := b.getQueue(queueBucket, key)
+		if err != nil {
+			return err
+		}
+
 		// if there is no run at that key then we're free to create the lock
 		currLockSerialized := bucket.Get([]byte(key))
 		if currLockSerialized == nil {
@@ -87,7 +151,8 @@ func (b *BoltLocker) TryLock(newLock models.ProjectLock) (bool, models.ProjectLo
 			bucket.Put([]byte(key), newLockSerialized) // nolint: errcheck
 			lockAcquired = true
 			currLock = newLock
-			return nil
+			// If this pull was waiting for the lock it isn't anymore.
+			return b.putQueue(queueBucket, key, b.removeFromQueue(queue, newLock.Pull.Num))
 		}
 
 		// otherwise the lock fails, return to caller the run that's holding the lock
@@ -95,6 +160,11 @@ func (b *BoltLocker) TryLock(newLock models.ProjectLock) (bool, models.ProjectLo
 			return errors.Wrap(err, "failed to deserialize current lock")
 		}
 		lockAcquired = false
+
+		// If another pull holds the lock then this pull waits in line for it.
+		if currLock.Pull.Num != newLock.Pull.Num && b.queuePosition(queue, newLock.Pull.Num) == 0 {
+			return b.putQueue(queueBucket, key, append(queue, newLock))
+		}
 		return nil
 	})
 
@@ -108,7 +178,8 @@ func (b *BoltLocker) TryLock(newLock models.ProjectLock) (bool, models.ProjectLo
 // Unlock attempts to unlock the project and workspace.
 // If there is no lock, then it will return a nil pointer.
 // If there is a lock, then it will delete it, and then return a pointer
-// to the deleted lock.
+// to the deleted lock. If pulls are waiting for the lock, it's given to the
+// first of them.
 func (b BoltLocker) Unlock(p models.Project, workspace string) (*models.ProjectLock, error) {
 	var lock models.ProjectLock
 	foundLock := false
@@ -116,13 +187,35 @@ func (b BoltLocker) Unlock(p models.Project, workspace string) (*models.ProjectL
 	err := b.db.Update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(b.bucket)
 		serialized := bucket.Get([]byte(key))
-		if serialized != nil {
-			if err := json.Unmarshal(serialized, &lock); err != nil {
-				return errors.Wrap(err, "failed to deserialize lock")
-			}
-			foundLock = true
+		if serialized == nil {
+			return bucket.Delete([]byte(key))
 		}
-		return bucket.Delete([]byte(key))
+		if err := json.Unmarshal(serialized, &lock); err != nil {
+			return errors.Wrap(err, "failed to deserialize lock")
+		}
+		foundLock = true
+
+		queueBucket, err := tx.CreateBucketIfNotExists(b.queueBucket)
+		if err != nil {
+			return errors.Wrap(err, "creating queue bucket")
+		}
+		queue, err := b.getQueue(queueBucket, key)
+		if err != nil {
+			return err
+		}
+		if len(queue) == 0 {
+			return bucket.Delete([]byte(key))
+		}
+		next := queue[0]
+		next.Time = time.Now().Local()
+		nextSerialized, err := json.Marshal(next)
+		if err != nil {
+			return errors.Wrap(err, "serializing lock")
+		}
+		if err := bucket.Put([]byte(key), nextSerialized); err != nil {
+			return err
+		}
+		return b.putQueue(queueBucket, key, queue[1:])
 	})
 	err = errors.Wrap(err, "DB transaction failed")
 	if foundLock {
@@ -159,7 +252,24 @@ func (b BoltLocker) List() ([]models.ProjectLock, error) {
 	return locks, nil
 }
 
+// GetQueue returns the pulls waiting for the lock on that project and
+// workspace in the order they started waiting.
+func (b BoltLocker) GetQueue(p models.Project, workspace string) ([]models.ProjectLock, error) {
+	var queue []models.ProjectLock
+	err := b.db.View(func(tx *bolt.Tx) error {
+		queueBucket := tx.Bucket(b.queueBucket)
+		if queueBucket == nil {
+			return nil
+		}
+		var err error
+		queue, err = b.getQueue(queueBucket, b.key(p, workspace))
+		return err
+	})
+	return queue, errors.Wrap(err, "DB transaction failed")
+}
+
 // UnlockByPull deletes all locks associated with that pull request and returns them.
+// It also removes the pull from the queues of any locks it was waiting for.
 func (b BoltLocker) UnlockByPull(repoFullName string, pullNum int) ([]models.ProjectLock, error) {
 	var locks []models.ProjectLock
 	err := b.db.View(func(tx *bolt.Tx) error {
@@ -187,7 +297,42 @@ func (b BoltLocker) UnlockByPull(repoFullName string, pullNum int) ([]models.Pro
 			return locks, errors.Wrapf(err, "unlocking repo %s, path %s, workspace %s", lock.Project.RepoFullName, lock.Project.Path, lock.Workspace)
 		}
 	}
-	return locks, nil
+
+	// remove the pull from any queues it's waiting in
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		queueBucket, err := tx.CreateBucketIfNotExists(b.queueBucket)
+		if err != nil {
+			return errors.Wrap(err, "creating queue bucket")
+		}
+		c := queueBucket.Cursor()
+		updated := make(map[string][]models.ProjectLock)
+		// The trailing / stops owner/repo matching the queues of owner/repo2.
+		prefix := []byte(repoFullName + "/")
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			queue, err := b.getQueue(queueBucket, string(k))
+			if err != nil {
+				return err
+			}
+			var filtered []models.ProjectLock
+			for _, l := range queue {
+				if l.Project.RepoFullName != repoFullName || l.Pull.Num != pullNum {
+					filtered = append(filtered, l)
+				}
+			}
+			if len(filtered) != len(queue) {
+				updated[string(k)] = filtered
+			}
+		}
+		// We update after iterating because modifying a bucket while
+		// iterating over it with a cursor is undefined.
+		for k, queue := range updated {
+			if err := b.putQueue(queueBucket, k, queue); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return locks, errors.Wrap(err, "DB transaction failed")
 }
 
 // GetLock returns a pointer to the lock for that project and workspace.
@@ -221,3 +366,48 @@ func (b BoltLocker) GetLock(p models.Project, workspace string) (*models.Project
 func (b BoltLocker) key(p models.Project, workspace string) string {
 	return fmt.Sprintf("%s/%s/%s", p.RepoFullName, p.Path, workspace)
 }
+
+func (b BoltLocker) getQueue(queueBucket *bolt.Bucket, key string) ([]models.ProjectLock, error) {
+	var queue []models.ProjectLock
+	serialized := queueBucket.Get([]byte(key))
+	if serialized == nil {
+		return nil, nil
+	}
+	if err := json.Unmarshal(serialized, &queue); err != nil {
+		return nil, errors.Wrapf(err, "deserializing queue at key %q", key)
+	}
+	return queue, nil
+}
+
+// putQueue stores queue at key, deleting the key if the queue is empty.
+func (b BoltLocker) putQueue(queueBucket *bolt.Bucket, key string, queue []models.ProjectLock) error {
+	if len(queue) == 0 {
+		return queueBucket.Delete([]byte(key))
+	}
+	serialized, err := json.Marshal(queue)
+	if err != nil {
+		return errors.Wrap(err, "serializing queue")
+	}
+	return queueBucket.Put([]byte(key), serialized)
+}
+
+// queuePosition returns the 1-indexed position of pullNum in the queue or 0
+// if it's not waiting.
+func (b BoltLocker) queuePosition(queue []models.ProjectLock, pullNum int) int {
+	for i, l := range queue {
+		if l.Pull.Num == pullNum {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func (b BoltLocker) removeFromQueue(queue []models.ProjectLock, pullNum int) []models.ProjectLock {
+	var filtered []models.ProjectLock
+	for _, l := range queue {
+		if l.Pull.Num != pullNum {
+			filtered = append(filtered, l)
+		}
+	}
+	return filtered
+}
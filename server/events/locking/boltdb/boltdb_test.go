@@ -14,6 +14,7 @@
 package boltdb_test
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"testing"
@@ -21,8 +22,10 @@ import (
 
 	"github.com/boltdb/bolt"
 	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/jobs"
 	"github.com/runatlantis/atlantis/server/events/locking/boltdb"
 	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/plans"
 	. "github.com/runatlantis/atlantis/testing"
 )
 
@@ -167,6 +170,90 @@ func TestLockingExistingLock(t *testing.T) {
 	}
 }
 
+func TestQueue(t *testing.T) {
+	t.Log("pulls that fail to get the lock should wait in line in order")
+	db, b := newTestDB()
+	defer cleanupDB(db)
+	_, _, err := b.TryLock(lock)
+	Ok(t, err)
+
+	queue, err := b.GetQueue(project, workspace)
+	Ok(t, err)
+	Equals(t, 0, len(queue))
+
+	secondLock := lock
+	secondLock.Pull.Num = 2
+	thirdLock := lock
+	thirdLock.Pull.Num = 3
+	for _, l := range []models.ProjectLock{secondLock, thirdLock, secondLock, lock} {
+		acquired, _, err := b.TryLock(l)
+		Ok(t, err)
+		Equals(t, false, acquired)
+	}
+
+	t.Log("...the pull holding the lock and duplicate attempts shouldn't be queued")
+	queue, err = b.GetQueue(project, workspace)
+	Ok(t, err)
+	Equals(t, 2, len(queue))
+	Equals(t, 2, queue[0].Pull.Num)
+	Equals(t, 3, queue[1].Pull.Num)
+
+	t.Log("...releasing the lock should give it to the first pull in line")
+	unlocked, err := b.Unlock(project, workspace)
+	Ok(t, err)
+	Equals(t, 1, unlocked.Pull.Num)
+	curr, err := b.GetLock(project, workspace)
+	Ok(t, err)
+	Equals(t, 2, curr.Pull.Num)
+	queue, err = b.GetQueue(project, workspace)
+	Ok(t, err)
+	Equals(t, 1, len(queue))
+	Equals(t, 3, queue[0].Pull.Num)
+
+	t.Log("...and a closed pull should leave the queue")
+	_, err = b.UnlockByPull(project.RepoFullName, 3)
+	Ok(t, err)
+	queue, err = b.GetQueue(project, workspace)
+	Ok(t, err)
+	Equals(t, 0, len(queue))
+
+	t.Log("...so releasing the lock again should free it")
+	_, err = b.Unlock(project, workspace)
+	Ok(t, err)
+	curr, err = b.GetLock(project, workspace)
+	Ok(t, err)
+	Assert(t, curr == nil, "exp lock to be free")
+}
+
+func TestQueue_UnlockByPullOnlyLeavesItsRepo(t *testing.T) {
+	t.Log("closing a pull shouldn't remove the same pull number from a repo sharing its name prefix")
+	db, b := newTestDB()
+	defer cleanupDB(db)
+
+	otherProject := models.NewProject("owner/repo2", "parent/child")
+	otherLock := lock
+	otherLock.Project = otherProject
+	for _, l := range []models.ProjectLock{lock, otherLock} {
+		_, _, err := b.TryLock(l)
+		Ok(t, err)
+		queued := l
+		queued.Pull.Num = 12
+		acquired, _, err := b.TryLock(queued)
+		Ok(t, err)
+		Equals(t, false, acquired)
+	}
+
+	_, err := b.UnlockByPull(project.RepoFullName, 12)
+	Ok(t, err)
+	queue, err := b.GetQueue(project, workspace)
+	Ok(t, err)
+	Equals(t, 0, len(queue))
+	queue, err = b.GetQueue(otherProject, workspace)
+	Ok(t, err)
+	Equals(t, 1, len(queue))
+	Equals(t, 12, queue[0].Pull.Num)
+}
+
 func TestUnlockingNoLocks(t *testing.T) {
 	t.Log("unlocking with no locks should succeed")
 	db, b := newTestDB()
@@ -353,6 +440,246 @@ func TestGetLock(t *testing.T) {
 }
 
 // newTestDB returns a TestDB using a temporary path.
+func TestJobs(t *testing.T) {
+	db, b := newTestDB()
+	defer cleanupDB(db)
+
+	expired := models.Job{ID: fmt.Sprintf("%019d", time.Now().Add(-jobs.Retention-time.Hour).UnixNano())}
+	Ok(t, b.SaveJob(expired))
+	first := models.Job{ID: fmt.Sprintf("%019d", time.Now().UnixNano()), Command: "plan", Output: "first"}
+	Ok(t, b.SaveJob(first))
+	second := models.Job{ID: fmt.Sprintf("%019d", time.Now().UnixNano()+1), Command: "apply", Output: "second"}
+	Ok(t, b.SaveJob(second))
+
+	// Saving a job again updates it.
+	first.Status = models.SucceededJobStatus
+	Ok(t, b.SaveJob(first))
+	job, err := b.GetJob(first.ID)
+	Ok(t, err)
+	Equals(t, first, *job)
+
+	t.Log("jobs should be listed newest first without their output")
+	list, err := b.ListJobs()
+	Ok(t, err)
+	second.Output = ""
+	first.Output = ""
+	Equals(t, []models.Job{second, first}, list)
+
+	t.Log("expired jobs should have been deleted")
+	job, err = b.GetJob(expired.ID)
+	Ok(t, err)
+	Assert(t, job == nil, "exp expired job to be deleted")
+}
+
+func TestAuditEvents(t *testing.T) {
+	db, b := newTestDB()
+	defer cleanupDB(db)
+
+	list, err := b.ListAuditEvents("", 10)
+	Ok(t, err)
+	Assert(t, len(list) == 0, "exp no events but got %v", list)
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		event := models.AuditEvent{ID: fmt.Sprintf("%019d-0", i+1), Command: "plan", PullNum: i}
+		Ok(t, b.AppendAuditEvent(event))
+		ids = append(ids, event.ID)
+	}
+
+	t.Log("events should be listed oldest first")
+	list, err = b.ListAuditEvents("", 10)
+	Ok(t, err)
+	Equals(t, 3, len(list))
+	Equals(t, ids[0], list[0].ID)
+	Equals(t, 2, list[2].PullNum)
+
+	t.Log("we should be able to page through the events")
+	list, err = b.ListAuditEvents(ids[0], 1)
+	Ok(t, err)
+	Equals(t, 1, len(list))
+	Equals(t, ids[1], list[0].ID)
+	list, err = b.ListAuditEvents(ids[2], 10)
+	Ok(t, err)
+	Assert(t, len(list) == 0, "exp no events after the last one but got %v", list)
+}
+
+func TestPlanOutputs(t *testing.T) {
+	db, b := newTestDB()
+	defer cleanupDB(db)
+
+	plan := models.PlanOutput{ID: "id", RepoFullName: "owner/repo", PullNum: 1, Output: "first", CreatedAt: time.Now().Round(time.Second).UTC()}
+	Ok(t, b.SavePlanOutput(plan))
+	got, err := b.GetPlanOutput("id")
+	Ok(t, err)
+	Equals(t, "first", got.Output)
+	Equals(t, 1, got.PullNum)
+
+	t.Log("saving the plan again should replace it")
+	plan.Output = "second"
+	Ok(t, b.SavePlanOutput(plan))
+	got, err = b.GetPlanOutput("id")
+	Ok(t, err)
+	Equals(t, "second", got.Output)
+
+	got, err = b.GetPlanOutput("missing")
+	Ok(t, err)
+	Assert(t, got == nil, "exp no plan")
+
+	t.Log("plans older than plans.Retention should be deleted")
+	old := models.PlanOutput{ID: "old", CreatedAt: time.Now().Add(-plans.Retention - time.Hour)}
+	Ok(t, b.SavePlanOutput(old))
+	got, err = b.GetPlanOutput("old")
+	Ok(t, err)
+	Assert(t, got == nil, "exp old plan to be deleted")
+}
+
+func TestAutoplanDisabled(t *testing.T) {
+	db, b := newTestDB()
+	defer cleanupDB(db)
+
+	disabled, err := b.AutoplanDisabled("owner/repo", 1)
+	Ok(t, err)
+	Assert(t, !disabled, "exp autoplanning to be on by default")
+
+	Ok(t, b.SetAutoplanDisabled("owner/repo", 1, true))
+	t.Log("setting it twice shouldn't error")
+	Ok(t, b.SetAutoplanDisabled("owner/repo", 1, true))
+	disabled, err = b.AutoplanDisabled("owner/repo", 1)
+	Ok(t, err)
+	Assert(t, disabled, "exp autoplanning to be off")
+
+	t.Log("other pulls should still be autoplanned")
+	disabled, err = b.AutoplanDisabled("owner/repo", 2)
+	Ok(t, err)
+	Assert(t, !disabled, "exp autoplanning of other pulls to be on")
+
+	Ok(t, b.SetAutoplanDisabled("owner/repo", 1, false))
+	disabled, err = b.AutoplanDisabled("owner/repo", 1)
+	Ok(t, err)
+	Assert(t, !disabled, "exp autoplanning to be back on")
+}
+
+func TestPendingApplies(t *testing.T) {
+	db, b := newTestDB()
+	defer cleanupDB(db)
+
+	got, err := b.TakePendingApply("owner/repo", 1)
+	Ok(t, err)
+	Assert(t, got == nil, "exp no pending apply")
+
+	apply := models.PendingApply{RepoFullName: "owner/repo", PullNum: 1, HeadCommit: "abc123", Username: "lkysow", Command: []byte(`{"name":0}`), CreatedAt: time.Now().Round(time.Second).UTC()}
+	Ok(t, b.SavePendingApply(apply))
+	t.Log("saving another apply for the pull should replace it")
+	apply.HeadCommit = "def456"
+	Ok(t, b.SavePendingApply(apply))
+	Ok(t, b.SavePendingApply(models.PendingApply{RepoFullName: "owner/repo", PullNum: 2}))
+
+	got, err = b.TakePendingApply("owner/repo", 1)
+	Ok(t, err)
+	Equals(t, apply, *got)
+
+	t.Log("a pending apply can only be taken once")
+	got, err = b.TakePendingApply("owner/repo", 1)
+	Ok(t, err)
+	Assert(t, got == nil, "exp pending apply to be deleted")
+	got, err = b.TakePendingApply("owner/repo", 2)
+	Ok(t, err)
+	Equals(t, 2, got.PullNum)
+}
+
+func TestWorkQueue(t *testing.T) {
+	db, b := newTestDB()
+	defer cleanupDB(db)
+
+	item := func(id string, pullNum int) models.WorkItem {
+		return models.WorkItem{ID: id, Kind: models.AutoplanWorkItem, BaseRepo: models.Repo{FullName: "owner/repo"}, PullNum: pullNum}
+	}
+	claimed, err := b.ClaimWork("worker")
+	Ok(t, err)
+	Assert(t, claimed == nil, "exp nothing to claim")
+
+	Ok(t, b.EnqueueWork(item("1", 1)))
+	Ok(t, b.EnqueueWork(item("2", 1)))
+	Ok(t, b.EnqueueWork(item("3", 2)))
+
+	claimed, err = b.ClaimWork("worker")
+	Ok(t, err)
+	Equals(t, "1", claimed.ID)
+	Equals(t, "worker", claimed.ClaimedBy)
+
+	t.Log("the next item for pull 1 should wait for the first to complete")
+	claimed, err = b.ClaimWork("worker")
+	Ok(t, err)
+	Equals(t, "3", claimed.ID)
+	claimed, err = b.ClaimWork("worker")
+	Ok(t, err)
+	Assert(t, claimed == nil, "exp nothing to claim but got %v", claimed)
+
+	Ok(t, b.CompleteWork("1"))
+	claimed, err = b.ClaimWork("worker")
+	Ok(t, err)
+	Equals(t, "2", claimed.ID)
+
+	list, err := b.ListWork()
+	Ok(t, err)
+	Equals(t, 2, len(list))
+	Equals(t, "2", list[0].ID)
+	Equals(t, "3", list[1].ID)
+	Equals(t, "owner/repo", list[0].BaseRepo.FullName)
+	Equals(t, "worker", list[1].ClaimedBy)
+
+	t.Log("renewing a claim should only update it for the worker that claimed the item")
+	before := list[1].ClaimedAt
+	Ok(t, b.RenewWork("3", "other-worker"))
+	list, err = b.ListWork()
+	Ok(t, err)
+	Assert(t, list[1].ClaimedAt.Equal(before), "exp the claim to not be renewed by another worker")
+	time.Sleep(time.Millisecond)
+	Ok(t, b.RenewWork("3", "worker"))
+	list, err = b.ListWork()
+	Ok(t, err)
+	Assert(t, list[1].ClaimedAt.After(before), "exp the claim to be renewed")
+	Ok(t, b.RenewWork("missing", "worker"))
+
+	t.Log("only claims that haven't been renewed within the timeout should be dropped")
+	time.Sleep(100 * time.Millisecond)
+	Ok(t, b.RenewWork("3", "worker"))
+	dropped, err := b.DropExpiredWork(50 * time.Millisecond)
+	Ok(t, err)
+	Equals(t, 1, len(dropped))
+	Equals(t, "2", dropped[0].ID)
+	list, err = b.ListWork()
+	Ok(t, err)
+	Equals(t, 1, len(list))
+	Equals(t, "3", list[0].ID)
+
+	Ok(t, b.CompleteWork("3"))
+	list, err = b.ListWork()
+	Ok(t, err)
+	Equals(t, 0, len(list))
+}
+
+func TestApplyLock(t *testing.T) {
+	db, b := newTestDB()
+	defer cleanupDB(db)
+
+	lock, err := b.GetApplyLock()
+	Ok(t, err)
+	Assert(t, lock == nil, "exp applies to not be locked")
+	Ok(t, b.UnlockApplies())
+
+	applyLock := models.ApplyLock{Time: time.Now().Round(time.Second)}
+	Ok(t, b.LockApplies(applyLock))
+	lock, err = b.GetApplyLock()
+	Ok(t, err)
+	Assert(t, lock != nil && lock.Time.Equal(applyLock.Time), "exp applies to be locked at %s but got %v", applyLock.Time, lock)
+
+	Ok(t, b.UnlockApplies())
+	lock, err = b.GetApplyLock()
+	Ok(t, err)
+	Assert(t, lock == nil, "exp applies to be unlocked")
+}
+
 func newTestDB() (*bolt.DB, *boltdb.BoltLocker) {
 	// Retrieve a temporary path.
 	f, err := ioutil.TempFile("", "")
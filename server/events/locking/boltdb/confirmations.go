@@ -0,0 +1,55 @@
+package boltdb
+
+import (
+	"encoding/json"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/confirmations"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// SavePendingApply creates or replaces the pending apply of its pull.
+func (b *BoltLocker) SavePendingApply(apply models.PendingApply) error {
+	serialized, err := json.Marshal(apply)
+	if err != nil {
+		return errors.Wrap(err, "serializing pending apply")
+	}
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(b.pendingAppliesBucket)
+		if err != nil {
+			return errors.Wrap(err, "creating pending applies bucket")
+		}
+		return bucket.Put([]byte(confirmations.Key(apply.RepoFullName, apply.PullNum)), serialized)
+	})
+	return errors.Wrap(err, "DB transaction failed")
+}
+
+// TakePendingApply deletes the pending apply of the pull and returns it, or
+// nil if there isn't one.
+func (b *BoltLocker) TakePendingApply(repoFullName string, pullNum int) (*models.PendingApply, error) {
+	key := []byte(confirmations.Key(repoFullName, pullNum))
+	var serialized []byte
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.pendingAppliesBucket)
+		if bucket == nil {
+			return nil
+		}
+		// The value is only valid during the transaction so we copy it.
+		if val := bucket.Get(key); val != nil {
+			serialized = append([]byte{}, val...)
+		}
+		return bucket.Delete(key)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "DB transaction failed")
+	}
+	if serialized == nil {
+		return nil, nil
+	}
+	var apply models.PendingApply
+	if err := json.Unmarshal(serialized, &apply); err != nil {
+		return nil, errors.Wrapf(err, "deserializing pending apply %q", key)
+	}
+	return &apply, nil
+}
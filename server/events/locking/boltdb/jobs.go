@@ -0,0 +1,91 @@
+package boltdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/jobs"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// SaveJob creates or updates job. Jobs that started more than jobs.Retention
+// ago are deleted.
+func (b *BoltLocker) SaveJob(job models.Job) error {
+	serialized, err := json.Marshal(job)
+	if err != nil {
+		return errors.Wrap(err, "serializing job")
+	}
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(b.jobsBucket)
+		if err != nil {
+			return errors.Wrap(err, "creating jobs bucket")
+		}
+		if err := bucket.Put([]byte(job.ID), serialized); err != nil {
+			return err
+		}
+
+		// IDs sort in the order the jobs started so the oldest are first. We
+		// don't delete while iterating because that makes the cursor skip
+		// keys.
+		cutoff := fmt.Sprintf("%019d", time.Now().Add(-jobs.Retention).UnixNano())
+		var expired [][]byte
+		c := bucket.Cursor()
+		for k, _ := c.First(); k != nil && string(k) < cutoff; k, _ = c.Next() {
+			expired = append(expired, k)
+		}
+		for _, k := range expired {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return errors.Wrap(err, "DB transaction failed")
+}
+
+// GetJob returns the job with id or nil if there isn't one.
+func (b *BoltLocker) GetJob(id string) (*models.Job, error) {
+	var serialized []byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket(b.jobsBucket); bucket != nil {
+			serialized = bucket.Get([]byte(id))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "DB transaction failed")
+	}
+	if serialized == nil {
+		return nil, nil
+	}
+	var job models.Job
+	if err := json.Unmarshal(serialized, &job); err != nil {
+		return nil, errors.Wrapf(err, "deserializing job %q", id)
+	}
+	return &job, nil
+}
+
+// ListJobs returns all the jobs, newest first, without their output.
+func (b *BoltLocker) ListJobs() ([]models.Job, error) {
+	var list []models.Job
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.jobsBucket)
+		if bucket == nil {
+			return nil
+		}
+		c := bucket.Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var job models.Job
+			if err := json.Unmarshal(v, &job); err != nil {
+				return errors.Wrapf(err, "deserializing job %q", string(k))
+			}
+			job.Output = ""
+			list = append(list, job)
+		}
+		return nil
+	})
+	return list, errors.Wrap(err, "DB transaction failed")
+}
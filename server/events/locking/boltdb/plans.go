@@ -0,0 +1,78 @@
+package boltdb
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/plans"
+)
+
+// SavePlanOutput creates or replaces plan. Plans created more than
+// plans.Retention ago are deleted.
+func (b *BoltLocker) SavePlanOutput(plan models.PlanOutput) error {
+	serialized, err := json.Marshal(plan)
+	if err != nil {
+		return errors.Wrap(err, "serializing plan")
+	}
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(b.plansBucket)
+		if err != nil {
+			return errors.Wrap(err, "creating plans bucket")
+		}
+		if err := bucket.Put([]byte(plan.ID), serialized); err != nil {
+			return err
+		}
+
+		// Unlike jobs, plan IDs don't sort by time so we have to check each
+		// plan's creation time.
+		cutoff := time.Now().Add(-plans.Retention)
+		var expired [][]byte
+		err = bucket.ForEach(func(k, v []byte) error {
+			var p struct {
+				CreatedAt time.Time `json:"created_at"`
+			}
+			if err := json.Unmarshal(v, &p); err != nil {
+				return errors.Wrapf(err, "deserializing plan %q", k)
+			}
+			if p.CreatedAt.Before(cutoff) {
+				expired = append(expired, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return errors.Wrap(err, "DB transaction failed")
+}
+
+// GetPlanOutput returns the plan with id or nil if there isn't one.
+func (b *BoltLocker) GetPlanOutput(id string) (*models.PlanOutput, error) {
+	var serialized []byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket(b.plansBucket); bucket != nil {
+			serialized = bucket.Get([]byte(id))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "DB transaction failed")
+	}
+	if serialized == nil {
+		return nil, nil
+	}
+	var plan models.PlanOutput
+	if err := json.Unmarshal(serialized, &plan); err != nil {
+		return nil, errors.Wrapf(err, "deserializing plan %q", id)
+	}
+	return &plan, nil
+}
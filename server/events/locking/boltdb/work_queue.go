@@ -0,0 +1,165 @@
+package boltdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/workqueue"
+)
+
+// EnqueueWork adds item to the end of the queue.
+func (b *BoltLocker) EnqueueWork(item models.WorkItem) error {
+	serialized, err := json.Marshal(item)
+	if err != nil {
+		return errors.Wrap(err, "serializing work item")
+	}
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(b.workQueueBucket)
+		if err != nil {
+			return errors.Wrap(err, "creating work queue bucket")
+		}
+		return bucket.Put([]byte(item.ID), serialized)
+	})
+	return errors.Wrap(err, "DB transaction failed")
+}
+
+// ClaimWork gives worker the oldest waiting item for a pull that has no other
+// item claimed and returns it, or nil if there isn't one.
+func (b *BoltLocker) ClaimWork(worker string) (*models.WorkItem, error) {
+	var claimed *models.WorkItem
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(b.workQueueBucket)
+		if err != nil {
+			return errors.Wrap(err, "creating work queue bucket")
+		}
+		items, err := b.workItems(bucket)
+		if err != nil {
+			return err
+		}
+		i := workqueue.Claimable(items)
+		if i == -1 {
+			return nil
+		}
+		item := items[i]
+		item.ClaimedBy = worker
+		item.ClaimedAt = time.Now()
+		serialized, err := json.Marshal(item)
+		if err != nil {
+			return errors.Wrap(err, "serializing work item")
+		}
+		claimed = &item
+		return bucket.Put([]byte(item.ID), serialized)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "DB transaction failed")
+	}
+	return claimed, nil
+}
+
+// RenewWork sets when the item with id was claimed to now if it's still
+// claimed by worker.
+func (b *BoltLocker) RenewWork(id string, worker string) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.workQueueBucket)
+		if bucket == nil {
+			return nil
+		}
+		serialized := bucket.Get([]byte(id))
+		if serialized == nil {
+			return nil
+		}
+		var item models.WorkItem
+		if err := json.Unmarshal(serialized, &item); err != nil {
+			return errors.Wrap(err, "deserializing work item")
+		}
+		if item.ClaimedBy != worker {
+			return nil
+		}
+		item.ClaimedAt = time.Now()
+		renewed, err := json.Marshal(item)
+		if err != nil {
+			return errors.Wrap(err, "serializing work item")
+		}
+		return bucket.Put([]byte(id), renewed)
+	})
+	return errors.Wrap(err, "DB transaction failed")
+}
+
+// CompleteWork deletes the item with id.
+func (b *BoltLocker) CompleteWork(id string) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket(b.workQueueBucket); bucket != nil {
+			return bucket.Delete([]byte(id))
+		}
+		return nil
+	})
+	return errors.Wrap(err, "DB transaction failed")
+}
+
+// DropExpiredWork deletes the items whose claims have expired and returns
+// them.
+func (b *BoltLocker) DropExpiredWork(timeout time.Duration) ([]models.WorkItem, error) {
+	var dropped []models.WorkItem
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.workQueueBucket)
+		if bucket == nil {
+			return nil
+		}
+		items, err := b.workItems(bucket)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, item := range items {
+			if !workqueue.ClaimExpired(item, timeout, now) {
+				continue
+			}
+			if err := bucket.Delete([]byte(item.ID)); err != nil {
+				return err
+			}
+			dropped = append(dropped, item)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "DB transaction failed")
+	}
+	return dropped, nil
+}
+
+// ListWork returns all the items, oldest first.
+func (b *BoltLocker) ListWork() ([]models.WorkItem, error) {
+	var items []models.WorkItem
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.workQueueBucket)
+		if bucket == nil {
+			return nil
+		}
+		var err error
+		items, err = b.workItems(bucket)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "DB transaction failed")
+	}
+	return items, nil
+}
+
+// workItems returns the items in bucket. IDs sort in the order the items were
+// enqueued so they're oldest first.
+func (b *BoltLocker) workItems(bucket *bolt.Bucket) ([]models.WorkItem, error) {
+	var items []models.WorkItem
+	err := bucket.ForEach(func(k, v []byte) error {
+		var item models.WorkItem
+		if err := json.Unmarshal(v, &item); err != nil {
+			return fmt.Errorf("failed to deserialize work item at key %q: %s", string(k), err)
+		}
+		items = append(items, item)
+		return nil
+	})
+	return items, err
+}
@@ -32,6 +32,10 @@ type Backend interface {
 	List() ([]models.ProjectLock, error)
 	GetLock(project models.Project, workspace string) (*models.ProjectLock, error)
 	UnlockByPull(repoFullName string, pullNum int) ([]models.ProjectLock, error)
+	GetQueue(project models.Project, workspace string) ([]models.ProjectLock, error)
+	LockApplies(lock models.ApplyLock) error
+	UnlockApplies() error
+	GetApplyLock() (*models.ApplyLock, error)
 }
 
 // TryLockResponse results from an attempted lock.
@@ -42,6 +46,10 @@ type TryLockResponse struct {
 	CurrLock models.ProjectLock
 	// LockKey is an identified by which to lookup and delete this lock.
 	LockKey string
+	// QueuePosition is this pull's position in the queue of pulls waiting for
+	// the lock, starting at 1. It's 0 if the lock was acquired or is already
+	// held by this pull.
+	QueuePosition int
 }
 
 // Client is used to perform locking actions.
@@ -57,6 +65,20 @@ type Locker interface {
 	List() (map[string]models.ProjectLock, error)
 	UnlockByPull(repoFullName string, pullNum int) ([]models.ProjectLock, error)
 	GetLock(key string) (*models.ProjectLock, error)
+	GetQueue(key string) ([]models.ProjectLock, error)
+}
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_apply_locker.go ApplyLocker
+
+// ApplyLocker locks and unlocks all applies at once.
+type ApplyLocker interface {
+	// LockApplies stops all applies until UnlockApplies is called. Locking
+	// applies that are already locked keeps the original lock.
+	LockApplies() (models.ApplyLock, error)
+	UnlockApplies() error
+	// GetApplyLock returns the lock stopping applies or nil if they aren't
+	// locked.
+	GetApplyLock() (*models.ApplyLock, error)
 }
 
 // NewClient returns a new locking client.
@@ -82,13 +104,31 @@ func (c *Client) TryLock(p models.Project, workspace string, pull models.PullReq
 	if err != nil {
 		return TryLockResponse{}, err
 	}
-	return TryLockResponse{lockAcquired, currLock, c.key(p, workspace)}, nil
+	resp := TryLockResponse{
+		LockAcquired: lockAcquired,
+		CurrLock:     currLock,
+		LockKey:      c.key(p, workspace),
+	}
+	if !lockAcquired && currLock.Pull.Num != pull.Num {
+		queue, err := c.backend.GetQueue(p, workspace)
+		if err != nil {
+			return TryLockResponse{}, err
+		}
+		for i, l := range queue {
+			if l.Pull.Num == pull.Num {
+				resp.QueuePosition = i + 1
+				break
+			}
+		}
+	}
+	return resp, nil
 }
 
 // Unlock attempts to unlock a project and workspace. If successful,
 // a pointer to the now deleted lock will be returned. Else, that
 // pointer will be nil. An error will only be returned if there was
 // an error deleting the lock (i.e. not if there was no lock).
+// If pulls are waiting for the lock, it's given to the first of them.
 func (c *Client) Unlock(key string) (*models.ProjectLock, error) {
 	project, workspace, err := c.lockKeyToProjectWorkspace(key)
 	if err != nil {
@@ -134,7 +174,48 @@ func (c *Client) GetLock(key string) (*models.ProjectLock, error) {
 	return projectLock, nil
 }
 
+// GetQueue returns the pulls waiting for the lock stored at key in the order
+// they started waiting.
+func (c *Client) GetQueue(key string) ([]models.ProjectLock, error) {
+	project, workspace, err := c.lockKeyToProjectWorkspace(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.backend.GetQueue(project, workspace)
+}
+
+// LockApplies stops all applies until UnlockApplies is called.
+func (c *Client) LockApplies() (models.ApplyLock, error) {
+	curr, err := c.backend.GetApplyLock()
+	if err != nil {
+		return models.ApplyLock{}, err
+	}
+	if curr != nil {
+		return *curr, nil
+	}
+	lock := models.ApplyLock{Time: time.Now().Local()}
+	return lock, c.backend.LockApplies(lock)
+}
+
+// UnlockApplies lets applies run again. It doesn't error if they weren't
+// locked.
+func (c *Client) UnlockApplies() error {
+	return c.backend.UnlockApplies()
+}
+
+// GetApplyLock returns the lock stopping applies or nil if they aren't
+// locked.
+func (c *Client) GetApplyLock() (*models.ApplyLock, error) {
+	return c.backend.GetApplyLock()
+}
+
 func (c *Client) key(p models.Project, workspace string) string {
+	return GenerateLockKey(p, workspace)
+}
+
+// GenerateLockKey returns the key used to lookup the lock for that project
+// and workspace.
+func GenerateLockKey(p models.Project, workspace string) string {
 	return fmt.Sprintf("%s/%s/%s", p.RepoFullName, p.Path, workspace)
 }
 
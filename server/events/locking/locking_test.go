@@ -57,6 +57,21 @@ func TestTryLock_Success(t *testing.T) {
 	Equals(t, locking.TryLockResponse{LockAcquired: true, CurrLock: currLock, LockKey: "owner/repo/path/workspace"}, r)
 }
 
+func TestTryLock_QueuePosition(t *testing.T) {
+	RegisterMockTestingT(t)
+	currLock := models.ProjectLock{Pull: models.PullRequest{Num: 2}}
+	backend := mocks.NewMockBackend()
+	When(backend.TryLock(matchers.AnyModelsProjectLock())).ThenReturn(false, currLock, nil)
+	When(backend.GetQueue(project, workspace)).ThenReturn([]models.ProjectLock{
+		{Pull: models.PullRequest{Num: 3}},
+		{Pull: pull},
+	}, nil)
+	l := locking.NewClient(backend)
+	r, err := l.TryLock(project, workspace, pull, user)
+	Ok(t, err)
+	Equals(t, locking.TryLockResponse{LockAcquired: false, CurrLock: currLock, LockKey: "owner/repo/path/workspace", QueuePosition: 2}, r)
+}
+
 func TestUnlock_InvalidKey(t *testing.T) {
 	RegisterMockTestingT(t)
 	backend := mocks.NewMockBackend()
@@ -144,3 +159,22 @@ func TestGetLock(t *testing.T) {
 	Ok(t, err)
 	Equals(t, &pl, lock)
 }
+
+func TestLockApplies(t *testing.T) {
+	RegisterMockTestingT(t)
+	backend := mocks.NewMockBackend()
+	l := locking.NewClient(backend)
+	lock, err := l.LockApplies()
+	Ok(t, err)
+	Assert(t, !lock.Time.IsZero(), "exp lock time to be set")
+	backend.VerifyWasCalledOnce().LockApplies(lock)
+
+	t.Log("locking applies again should keep the original lock")
+	backend = mocks.NewMockBackend()
+	l = locking.NewClient(backend)
+	When(backend.GetApplyLock()).ThenReturn(&lock, nil)
+	curr, err := l.LockApplies()
+	Ok(t, err)
+	Equals(t, lock, curr)
+	backend.VerifyWasCalled(Never()).LockApplies(matchers.AnyModelsApplyLock())
+}
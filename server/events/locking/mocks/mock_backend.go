@@ -117,6 +117,74 @@ func (mock *MockBackend) UnlockByPull(repoFullName string, pullNum int) ([]model
 	return ret0, ret1
 }
 
+func (mock *MockBackend) GetQueue(project models.Project, workspace string) ([]models.ProjectLock, error) {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockBackend().")
+	}
+	params := []pegomock.Param{project, workspace}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("GetQueue", params, []reflect.Type{reflect.TypeOf((*[]models.ProjectLock)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 []models.ProjectLock
+	var ret1 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].([]models.ProjectLock)
+		}
+		if result[1] != nil {
+			ret1 = result[1].(error)
+		}
+	}
+	return ret0, ret1
+}
+
+func (mock *MockBackend) LockApplies(lock models.ApplyLock) error {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockBackend().")
+	}
+	params := []pegomock.Param{lock}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("LockApplies", params, []reflect.Type{reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(error)
+		}
+	}
+	return ret0
+}
+
+func (mock *MockBackend) UnlockApplies() error {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockBackend().")
+	}
+	params := []pegomock.Param{}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("UnlockApplies", params, []reflect.Type{reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(error)
+		}
+	}
+	return ret0
+}
+
+func (mock *MockBackend) GetApplyLock() (*models.ApplyLock, error) {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockBackend().")
+	}
+	params := []pegomock.Param{}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("GetApplyLock", params, []reflect.Type{reflect.TypeOf((**models.ApplyLock)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 *models.ApplyLock
+	var ret1 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(*models.ApplyLock)
+		}
+		if result[1] != nil {
+			ret1 = result[1].(error)
+		}
+	}
+	return ret0, ret1
+}
+
 func (mock *MockBackend) VerifyWasCalledOnce() *VerifierBackend {
 	return &VerifierBackend{
 		mock:                   mock,
@@ -290,3 +358,95 @@ func (c *Backend_UnlockByPull_OngoingVerification) GetAllCapturedArguments() (_p
 	}
 	return
 }
+
+func (verifier *VerifierBackend) GetQueue(project models.Project, workspace string) *Backend_GetQueue_OngoingVerification {
+	params := []pegomock.Param{project, workspace}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "GetQueue", params, verifier.timeout)
+	return &Backend_GetQueue_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type Backend_GetQueue_OngoingVerification struct {
+	mock              *MockBackend
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *Backend_GetQueue_OngoingVerification) GetCapturedArguments() (models.Project, string) {
+	project, workspace := c.GetAllCapturedArguments()
+	return project[len(project)-1], workspace[len(workspace)-1]
+}
+
+func (c *Backend_GetQueue_OngoingVerification) GetAllCapturedArguments() (_param0 []models.Project, _param1 []string) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]models.Project, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(models.Project)
+		}
+		_param1 = make([]string, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(string)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierBackend) LockApplies(lock models.ApplyLock) *Backend_LockApplies_OngoingVerification {
+	params := []pegomock.Param{lock}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "LockApplies", params, verifier.timeout)
+	return &Backend_LockApplies_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type Backend_LockApplies_OngoingVerification struct {
+	mock              *MockBackend
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *Backend_LockApplies_OngoingVerification) GetCapturedArguments() models.ApplyLock {
+	lock := c.GetAllCapturedArguments()
+	return lock[len(lock)-1]
+}
+
+func (c *Backend_LockApplies_OngoingVerification) GetAllCapturedArguments() (_param0 []models.ApplyLock) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]models.ApplyLock, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(models.ApplyLock)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierBackend) UnlockApplies() *Backend_UnlockApplies_OngoingVerification {
+	params := []pegomock.Param{}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "UnlockApplies", params, verifier.timeout)
+	return &Backend_UnlockApplies_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type Backend_UnlockApplies_OngoingVerification struct {
+	mock              *MockBackend
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *Backend_UnlockApplies_OngoingVerification) GetCapturedArguments() {
+}
+
+func (c *Backend_UnlockApplies_OngoingVerification) GetAllCapturedArguments() {
+}
+
+func (verifier *VerifierBackend) GetApplyLock() *Backend_GetApplyLock_OngoingVerification {
+	params := []pegomock.Param{}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "GetApplyLock", params, verifier.timeout)
+	return &Backend_GetApplyLock_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type Backend_GetApplyLock_OngoingVerification struct {
+	mock              *MockBackend
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *Backend_GetApplyLock_OngoingVerification) GetCapturedArguments() {
+}
+
+func (c *Backend_GetApplyLock_OngoingVerification) GetAllCapturedArguments() {
+}
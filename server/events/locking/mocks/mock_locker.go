@@ -114,6 +114,25 @@ func (mock *MockLocker) GetLock(key string) (*models.ProjectLock, error) {
 	return ret0, ret1
 }
 
+func (mock *MockLocker) GetQueue(key string) ([]models.ProjectLock, error) {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockLocker().")
+	}
+	params := []pegomock.Param{key}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("GetQueue", params, []reflect.Type{reflect.TypeOf((*[]models.ProjectLock)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 []models.ProjectLock
+	var ret1 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].([]models.ProjectLock)
+		}
+		if result[1] != nil {
+			ret1 = result[1].(error)
+		}
+	}
+	return ret0, ret1
+}
+
 func (mock *MockLocker) VerifyWasCalledOnce() *VerifierLocker {
 	return &VerifierLocker{
 		mock:                   mock,
@@ -291,3 +310,30 @@ func (c *Locker_GetLock_OngoingVerification) GetAllCapturedArguments() (_param0
 	}
 	return
 }
+
+func (verifier *VerifierLocker) GetQueue(key string) *Locker_GetQueue_OngoingVerification {
+	params := []pegomock.Param{key}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "GetQueue", params, verifier.timeout)
+	return &Locker_GetQueue_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type Locker_GetQueue_OngoingVerification struct {
+	mock              *MockLocker
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *Locker_GetQueue_OngoingVerification) GetCapturedArguments() string {
+	key := c.GetAllCapturedArguments()
+	return key[len(key)-1]
+}
+
+func (c *Locker_GetQueue_OngoingVerification) GetAllCapturedArguments() (_param0 []string) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]string, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(string)
+		}
+	}
+	return
+}
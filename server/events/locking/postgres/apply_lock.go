@@ -0,0 +1,50 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// applyLockName is the name in the global locks table of the lock stopping
+// all applies.
+const applyLockName = "apply"
+
+// LockApplies saves lock so all applies are stopped until UnlockApplies is
+// called.
+func (p *PostgresLocker) LockApplies(lock models.ApplyLock) error {
+	serialized, err := json.Marshal(lock)
+	if err != nil {
+		return errors.Wrap(err, "serializing apply lock")
+	}
+	_, err = p.db.Exec(`INSERT INTO atlantis_global_locks (name, lock) VALUES ($1, $2) ON CONFLICT (name) DO UPDATE SET lock = EXCLUDED.lock`,
+		applyLockName, string(serialized))
+	return errors.Wrap(err, "saving apply lock")
+}
+
+// UnlockApplies deletes the lock stopping all applies. It doesn't error if
+// there isn't one.
+func (p *PostgresLocker) UnlockApplies() error {
+	_, err := p.db.Exec(`DELETE FROM atlantis_global_locks WHERE name = $1`, applyLockName)
+	return errors.Wrap(err, "deleting apply lock")
+}
+
+// GetApplyLock returns the lock stopping all applies or nil if there isn't
+// one.
+func (p *PostgresLocker) GetApplyLock() (*models.ApplyLock, error) {
+	var serialized string
+	err := p.db.QueryRow(`SELECT lock FROM atlantis_global_locks WHERE name = $1`, applyLockName).Scan(&serialized)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "getting apply lock")
+	}
+	var lock models.ApplyLock
+	if err := json.Unmarshal([]byte(serialized), &lock); err != nil {
+		return nil, errors.Wrap(err, "deserializing apply lock")
+	}
+	return &lock, nil
+}
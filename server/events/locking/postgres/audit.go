@@ -0,0 +1,42 @@
+package postgres
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// AppendAuditEvent adds event to the audit log.
+func (p *PostgresLocker) AppendAuditEvent(event models.AuditEvent) error {
+	serialized, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "serializing audit event")
+	}
+	_, err = p.db.Exec(`INSERT INTO atlantis_audit_events (id, started_at, event) VALUES ($1, $2, $3)`,
+		event.ID, event.StartedAt, string(serialized))
+	return errors.Wrap(err, "saving audit event")
+}
+
+// ListAuditEvents returns up to limit events with IDs after afterID, oldest
+// first.
+func (p *PostgresLocker) ListAuditEvents(afterID string, limit int) ([]models.AuditEvent, error) {
+	rows, err := p.db.Query(`SELECT event FROM atlantis_audit_events WHERE id > $1 ORDER BY id LIMIT $2`, afterID, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing audit events")
+	}
+	defer rows.Close() // nolint: errcheck
+	var list []models.AuditEvent
+	for rows.Next() {
+		var serialized string
+		if err := rows.Scan(&serialized); err != nil {
+			return nil, err
+		}
+		var event models.AuditEvent
+		if err := json.Unmarshal([]byte(serialized), &event); err != nil {
+			return nil, errors.Wrap(err, "deserializing audit event")
+		}
+		list = append(list, event)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,24 @@
+package postgres
+
+import (
+	"github.com/pkg/errors"
+)
+
+// SetAutoplanDisabled turns autoplanning of the pull off if disabled is true,
+// or back on if it's false. Only the pulls it's off for are stored.
+func (p *PostgresLocker) SetAutoplanDisabled(repoFullName string, pullNum int, disabled bool) error {
+	var err error
+	if disabled {
+		_, err = p.db.Exec(`INSERT INTO atlantis_autoplan_disabled (repo_full_name, pull_num) VALUES ($1, $2) ON CONFLICT DO NOTHING`, repoFullName, pullNum)
+	} else {
+		_, err = p.db.Exec(`DELETE FROM atlantis_autoplan_disabled WHERE repo_full_name = $1 AND pull_num = $2`, repoFullName, pullNum)
+	}
+	return errors.Wrap(err, "saving autoplan setting")
+}
+
+// AutoplanDisabled returns true if autoplanning of the pull is off.
+func (p *PostgresLocker) AutoplanDisabled(repoFullName string, pullNum int) (bool, error) {
+	var disabled bool
+	err := p.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM atlantis_autoplan_disabled WHERE repo_full_name = $1 AND pull_num = $2)`, repoFullName, pullNum).Scan(&disabled)
+	return disabled, errors.Wrap(err, "getting autoplan setting")
+}
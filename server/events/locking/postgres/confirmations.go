@@ -0,0 +1,38 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// SavePendingApply creates or replaces the pending apply of its pull.
+func (p *PostgresLocker) SavePendingApply(apply models.PendingApply) error {
+	serialized, err := json.Marshal(apply)
+	if err != nil {
+		return errors.Wrap(err, "serializing pending apply")
+	}
+	_, err = p.db.Exec(`INSERT INTO atlantis_pending_applies (repo_full_name, pull_num, apply) VALUES ($1, $2, $3) ON CONFLICT (repo_full_name, pull_num) DO UPDATE SET apply = EXCLUDED.apply`,
+		apply.RepoFullName, apply.PullNum, string(serialized))
+	return errors.Wrap(err, "saving pending apply")
+}
+
+// TakePendingApply deletes the pending apply of the pull and returns it, or
+// nil if there isn't one.
+func (p *PostgresLocker) TakePendingApply(repoFullName string, pullNum int) (*models.PendingApply, error) {
+	var serialized string
+	err := p.db.QueryRow(`DELETE FROM atlantis_pending_applies WHERE repo_full_name = $1 AND pull_num = $2 RETURNING apply`, repoFullName, pullNum).Scan(&serialized)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "taking pending apply")
+	}
+	var apply models.PendingApply
+	if err := json.Unmarshal([]byte(serialized), &apply); err != nil {
+		return nil, errors.Wrap(err, "deserializing pending apply")
+	}
+	return &apply, nil
+}
@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/jobs"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// SaveJob creates or updates job. Jobs that started more than jobs.Retention
+// ago are deleted.
+func (p *PostgresLocker) SaveJob(job models.Job) error {
+	serialized, err := json.Marshal(job)
+	if err != nil {
+		return errors.Wrap(err, "serializing job")
+	}
+	if _, err := p.db.Exec(`INSERT INTO atlantis_jobs (id, started_at, job) VALUES ($1, $2, $3) ON CONFLICT (id) DO UPDATE SET started_at = EXCLUDED.started_at, job = EXCLUDED.job`,
+		job.ID, job.StartedAt, string(serialized)); err != nil {
+		return errors.Wrap(err, "saving job")
+	}
+	_, err = p.db.Exec(`DELETE FROM atlantis_jobs WHERE started_at < $1`, time.Now().Add(-jobs.Retention))
+	return errors.Wrap(err, "deleting expired jobs")
+}
+
+// GetJob returns the job with id or nil if there isn't one.
+func (p *PostgresLocker) GetJob(id string) (*models.Job, error) {
+	var serialized string
+	err := p.db.QueryRow(`SELECT job FROM atlantis_jobs WHERE id = $1`, id).Scan(&serialized)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "getting job")
+	}
+	var job models.Job
+	if err := json.Unmarshal([]byte(serialized), &job); err != nil {
+		return nil, errors.Wrapf(err, "deserializing job %q", id)
+	}
+	return &job, nil
+}
+
+// ListJobs returns all the jobs, newest first, without their output.
+func (p *PostgresLocker) ListJobs() ([]models.Job, error) {
+	// IDs sort in the order the jobs started.
+	rows, err := p.db.Query(`SELECT job FROM atlantis_jobs ORDER BY id DESC`)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing jobs")
+	}
+	defer rows.Close() // nolint: errcheck
+	var list []models.Job
+	for rows.Next() {
+		var serialized string
+		if err := rows.Scan(&serialized); err != nil {
+			return nil, err
+		}
+		var job models.Job
+		if err := json.Unmarshal([]byte(serialized), &job); err != nil {
+			return nil, errors.Wrap(err, "deserializing job")
+		}
+		job.Output = ""
+		list = append(list, job)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,116 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// migrations are the statements that create and then update our schema. The
+// schema's version is how many of them have been run so new statements must
+// only ever be appended.
+var migrations = []string{
+	// 1: locks, the pulls queued for them, global locks like the apply lock
+	// and jobs. Locks and jobs are stored serialized as JSON, like in BoltDB
+	// and Redis, with the columns we query by alongside them.
+	`CREATE TABLE atlantis_locks (
+		lock_key       TEXT PRIMARY KEY,
+		repo_full_name TEXT NOT NULL,
+		pull_num       INTEGER NOT NULL,
+		lock           TEXT NOT NULL
+	);
+	CREATE INDEX atlantis_locks_pull ON atlantis_locks (repo_full_name, pull_num);
+	CREATE TABLE atlantis_lock_queue (
+		seq            BIGSERIAL NOT NULL,
+		lock_key       TEXT NOT NULL,
+		repo_full_name TEXT NOT NULL,
+		pull_num       INTEGER NOT NULL,
+		lock           TEXT NOT NULL,
+		PRIMARY KEY (lock_key, pull_num)
+	);
+	CREATE INDEX atlantis_lock_queue_pull ON atlantis_lock_queue (repo_full_name, pull_num);
+	CREATE TABLE atlantis_global_locks (
+		name TEXT PRIMARY KEY,
+		lock TEXT NOT NULL
+	);
+	CREATE TABLE atlantis_jobs (
+		id         TEXT PRIMARY KEY,
+		started_at TIMESTAMPTZ NOT NULL,
+		job        TEXT NOT NULL
+	);
+	CREATE INDEX atlantis_jobs_started_at ON atlantis_jobs (started_at);`,
+	// 2: the audit log. Events are only ever inserted and are listed in the
+	// order of their IDs.
+	`CREATE TABLE atlantis_audit_events (
+		id         TEXT PRIMARY KEY,
+		started_at TIMESTAMPTZ NOT NULL,
+		event      TEXT NOT NULL
+	);`,
+	// 3: the outputs of plans.
+	`CREATE TABLE atlantis_plans (
+		id         TEXT PRIMARY KEY,
+		created_at TIMESTAMPTZ NOT NULL,
+		plan       TEXT NOT NULL
+	);
+	CREATE INDEX atlantis_plans_created_at ON atlantis_plans (created_at);`,
+	// 4: the work queue. IDs sort in the order the items were enqueued.
+	`CREATE TABLE atlantis_work_queue (
+		id   TEXT PRIMARY KEY,
+		item TEXT NOT NULL
+	);`,
+	// 5: the pulls that autoplanning has been turned off for.
+	`CREATE TABLE atlantis_autoplan_disabled (
+		repo_full_name TEXT NOT NULL,
+		pull_num       INTEGER NOT NULL,
+		PRIMARY KEY (repo_full_name, pull_num)
+	);`,
+	// 6: the applies waiting to be confirmed, at most one per pull.
+	`CREATE TABLE atlantis_pending_applies (
+		repo_full_name TEXT NOT NULL,
+		pull_num       INTEGER NOT NULL,
+		apply          TEXT NOT NULL,
+		PRIMARY KEY (repo_full_name, pull_num)
+	);`,
+}
+
+// migrationLockKey is the second key of the advisory lock held while
+// migrating so that instances starting at the same time take turns.
+const migrationLockKey = 0
+
+// migrate runs any of migrations that haven't been run yet in a single
+// transaction. The versions that have been run are recorded in the
+// atlantis_schema_migrations table.
+func migrate(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // nolint: errcheck
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1, $2)`, lockNamespace, migrationLockKey); err != nil {
+		return errors.Wrap(err, "taking advisory lock")
+	}
+	if _, err := tx.Exec(`CREATE TABLE IF NOT EXISTS atlantis_schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`); err != nil {
+		return errors.Wrap(err, "creating migrations table")
+	}
+	var version int
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM atlantis_schema_migrations`).Scan(&version); err != nil {
+		return errors.Wrap(err, "getting schema version")
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("schema version %d is newer than the latest this version of Atlantis supports, %d", version, len(migrations))
+	}
+	for i := version; i < len(migrations); i++ {
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			return errors.Wrapf(err, "running migration %d", i+1)
+		}
+		if _, err := tx.Exec(`INSERT INTO atlantis_schema_migrations (version) VALUES ($1)`, i+1); err != nil {
+			return errors.Wrapf(err, "recording migration %d", i+1)
+		}
+	}
+	return tx.Commit()
+}
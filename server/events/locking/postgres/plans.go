@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/plans"
+)
+
+// SavePlanOutput creates or replaces plan. Plans created more than
+// plans.Retention ago are deleted.
+func (p *PostgresLocker) SavePlanOutput(plan models.PlanOutput) error {
+	serialized, err := json.Marshal(plan)
+	if err != nil {
+		return errors.Wrap(err, "serializing plan")
+	}
+	if _, err := p.db.Exec(`INSERT INTO atlantis_plans (id, created_at, plan) VALUES ($1, $2, $3) ON CONFLICT (id) DO UPDATE SET created_at = EXCLUDED.created_at, plan = EXCLUDED.plan`,
+		plan.ID, plan.CreatedAt, string(serialized)); err != nil {
+		return errors.Wrap(err, "saving plan")
+	}
+	_, err = p.db.Exec(`DELETE FROM atlantis_plans WHERE created_at < $1`, time.Now().Add(-plans.Retention))
+	return errors.Wrap(err, "deleting expired plans")
+}
+
+// GetPlanOutput returns the plan with id or nil if there isn't one.
+func (p *PostgresLocker) GetPlanOutput(id string) (*models.PlanOutput, error) {
+	var serialized string
+	err := p.db.QueryRow(`SELECT plan FROM atlantis_plans WHERE id = $1`, id).Scan(&serialized)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "getting plan")
+	}
+	var plan models.PlanOutput
+	if err := json.Unmarshal([]byte(serialized), &plan); err != nil {
+		return nil, errors.Wrapf(err, "deserializing plan %q", id)
+	}
+	return &plan, nil
+}
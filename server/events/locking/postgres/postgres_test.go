@@ -0,0 +1,559 @@
+package postgres_test
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/locking/postgres"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/plans"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+// testURLEnvVar is the env var with the connection string of the database to
+// run the tests against. The tests that need a database are skipped if it
+// isn't set. The database's Atlantis tables are dropped by each test.
+const testURLEnvVar = "ATLANTIS_TEST_POSTGRES_URL"
+
+var project = models.NewProject("owner/repo", "parent/child")
+var workspace = "default"
+var pullNum = 1
+var lock = models.ProjectLock{
+	Pull: models.PullRequest{
+		Num: pullNum,
+	},
+	User: models.User{
+		Username: "lkysow",
+	},
+	Workspace: workspace,
+	Project:   project,
+	Time:      time.Now(),
+}
+
+func TestNew_ConnectionErr(t *testing.T) {
+	// Nothing listens on port 1.
+	_, err := postgres.New("postgres://127.0.0.1:1/atlantis?sslmode=disable")
+	ErrContains(t, "starting Postgres", err)
+}
+
+func TestNew_Migrates(t *testing.T) {
+	t.Log("starting again with the latest schema shouldn't change it")
+	newTestLocker(t)
+	_, err := postgres.New(os.Getenv(testURLEnvVar))
+	Ok(t, err)
+	db, err := sql.Open("postgres", os.Getenv(testURLEnvVar))
+	Ok(t, err)
+	defer db.Close() // nolint: errcheck
+	var count int
+	Ok(t, db.QueryRow(`SELECT COUNT(*) FROM atlantis_schema_migrations`).Scan(&count))
+	Equals(t, 6, count)
+
+	t.Log("a schema newer than we support should error")
+	_, err = db.Exec(`INSERT INTO atlantis_schema_migrations (version) VALUES (1000)`)
+	Ok(t, err)
+	_, err = postgres.New(os.Getenv(testURLEnvVar))
+	ErrContains(t, "schema version 1000 is newer than the latest this version of Atlantis supports", err)
+}
+
+func TestListNoLocks(t *testing.T) {
+	t.Log("listing locks when there are none should return an empty list")
+	r := newTestLocker(t)
+	ls, err := r.List()
+	Ok(t, err)
+	Equals(t, 0, len(ls))
+}
+
+func TestListMultipleLocks(t *testing.T) {
+	t.Log("listing locks when there are multiple should return them all in order")
+	r := newTestLocker(t)
+
+	var repos []string
+	for i := 0; i < 5; i++ {
+		repos = append(repos, fmt.Sprintf("owner/repo%d", i))
+	}
+	for _, repo := range repos {
+		newLock := lock
+		newLock.Project = models.NewProject(repo, "path")
+		_, _, err := r.TryLock(newLock)
+		Ok(t, err)
+	}
+
+	ls, err := r.List()
+	Ok(t, err)
+	Equals(t, len(repos), len(ls))
+	for i, l := range ls {
+		Equals(t, repos[i], l.Project.RepoFullName)
+	}
+}
+
+func TestLockingExistingLock(t *testing.T) {
+	t.Log("if there is an existing lock, lock should...")
+	r := newTestLocker(t)
+	_, _, err := r.TryLock(lock)
+	Ok(t, err)
+
+	t.Log("...succeed if the new project has a different path")
+	{
+		newLock := lock
+		newLock.Project = models.NewProject(project.RepoFullName, "different/path")
+		acquired, currLock, err := r.TryLock(newLock)
+		Ok(t, err)
+		Equals(t, true, acquired)
+		Equals(t, pullNum, currLock.Pull.Num)
+	}
+
+	t.Log("...succeed if the new project has a different workspace")
+	{
+		newLock := lock
+		newLock.Workspace = "different-workspace"
+		acquired, currLock, err := r.TryLock(newLock)
+		Ok(t, err)
+		Equals(t, true, acquired)
+		Equals(t, newLock.Workspace, currLock.Workspace)
+	}
+
+	t.Log("...not succeed if the new project only has a different pullNum")
+	{
+		newLock := lock
+		newLock.Pull.Num = lock.Pull.Num + 1
+		acquired, currLock, err := r.TryLock(newLock)
+		Ok(t, err)
+		Equals(t, false, acquired)
+		Equals(t, currLock.Pull.Num, pullNum)
+	}
+}
+
+func TestQueue(t *testing.T) {
+	t.Log("pulls that fail to get the lock should wait in line for it")
+	r := newTestLocker(t)
+	_, _, err := r.TryLock(lock)
+	Ok(t, err)
+
+	second := lock
+	second.Pull.Num = 2
+	third := lock
+	third.Pull.Num = 3
+	for _, l := range []models.ProjectLock{second, third, second} {
+		acquired, _, err := r.TryLock(l)
+		Ok(t, err)
+		Equals(t, false, acquired)
+	}
+	queue, err := r.GetQueue(project, workspace)
+	Ok(t, err)
+	Equals(t, []int{2, 3}, pullNums(queue))
+
+	t.Log("the pull holding the lock shouldn't be added to the queue")
+	_, _, err = r.TryLock(lock)
+	Ok(t, err)
+	queue, err = r.GetQueue(project, workspace)
+	Ok(t, err)
+	Equals(t, []int{2, 3}, pullNums(queue))
+
+	t.Log("releasing the lock should give it to the first pull in line")
+	unlocked, err := r.Unlock(project, workspace)
+	Ok(t, err)
+	Equals(t, 1, unlocked.Pull.Num)
+	curr, err := r.GetLock(project, workspace)
+	Ok(t, err)
+	Equals(t, 2, curr.Pull.Num)
+	acquired, _, err := r.TryLock(third)
+	Ok(t, err)
+	Equals(t, false, acquired)
+	queue, err = r.GetQueue(project, workspace)
+	Ok(t, err)
+	Equals(t, []int{3}, pullNums(queue))
+
+	t.Log("UnlockByPull should remove the pull from the queue")
+	_, err = r.UnlockByPull(project.RepoFullName, 3)
+	Ok(t, err)
+	queue, err = r.GetQueue(project, workspace)
+	Ok(t, err)
+	Equals(t, 0, len(queue))
+
+	t.Log("once nobody is waiting, releasing the lock should free it")
+	_, err = r.Unlock(project, workspace)
+	Ok(t, err)
+	curr, err = r.GetLock(project, workspace)
+	Ok(t, err)
+	Assert(t, curr == nil, "exp lock to be free")
+}
+
+func TestUnlockingNoLocks(t *testing.T) {
+	t.Log("unlocking with no locks should succeed")
+	r := newTestLocker(t)
+	l, err := r.Unlock(project, workspace)
+	Ok(t, err)
+	Assert(t, l == nil, "exp nil lock")
+}
+
+func TestUnlocking(t *testing.T) {
+	t.Log("unlocking with an existing lock should succeed and return the lock")
+	r := newTestLocker(t)
+	_, _, err := r.TryLock(lock)
+	Ok(t, err)
+
+	l, err := r.Unlock(project, workspace)
+	Ok(t, err)
+	Assert(t, l != nil, "exp lock")
+	Equals(t, lock.Pull.Num, l.Pull.Num)
+	ls, err := r.List()
+	Ok(t, err)
+	Equals(t, 0, len(ls))
+}
+
+func TestUnlockByPullMatching(t *testing.T) {
+	t.Log("UnlockByPull should delete only the pull's locks in that repo")
+	r := newTestLocker(t)
+	_, _, err := r.TryLock(lock)
+	Ok(t, err)
+
+	// A different path in the same repo.
+	samePull := lock
+	samePull.Project.Path = "dir"
+	_, _, err = r.TryLock(samePull)
+	Ok(t, err)
+
+	// A different pull in the same repo.
+	otherPull := lock
+	otherPull.Project.Path = "other"
+	otherPull.Pull.Num = 2
+	_, _, err = r.TryLock(otherPull)
+	Ok(t, err)
+
+	// A repo whose name starts with the same characters.
+	otherRepo := lock
+	otherRepo.Project.RepoFullName = "owner/repo2"
+	_, _, err = r.TryLock(otherRepo)
+	Ok(t, err)
+
+	locks, err := r.UnlockByPull(project.RepoFullName, pullNum)
+	Ok(t, err)
+	Equals(t, 2, len(locks))
+	ls, err := r.List()
+	Ok(t, err)
+	Equals(t, 2, len(ls))
+	Equals(t, "other", ls[0].Project.Path)
+	Equals(t, "owner/repo2", ls[1].Project.RepoFullName)
+}
+
+func TestGetLock(t *testing.T) {
+	t.Log("getting a lock should return it, or nil if it's not there")
+	r := newTestLocker(t)
+	l, err := r.GetLock(project, workspace)
+	Ok(t, err)
+	Assert(t, l == nil, "exp nil lock")
+
+	_, _, err = r.TryLock(lock)
+	Ok(t, err)
+	l, err = r.GetLock(project, workspace)
+	Ok(t, err)
+	// We can't compare the time directly because it's deserialized.
+	Equals(t, lock.Time.Format(time.RFC3339Nano), l.Time.Format(time.RFC3339Nano))
+	l.Time = lock.Time
+	Equals(t, lock, *l)
+}
+
+func TestTryLock_Concurrent(t *testing.T) {
+	t.Log("when pulls lock at the same time only one should get the lock and the rest should wait in line")
+	r := newTestLocker(t)
+
+	var wg sync.WaitGroup
+	acquired := make(chan int, 10)
+	errs := make(chan error, 10)
+	for i := 1; i <= 10; i++ {
+		wg.Add(1)
+		go func(num int) {
+			defer wg.Done()
+			l := lock
+			l.Pull.Num = num
+			ok, _, err := r.TryLock(l)
+			if err != nil {
+				errs <- err
+			}
+			if ok {
+				acquired <- num
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		Ok(t, err)
+	}
+	Equals(t, 1, len(acquired))
+	queue, err := r.GetQueue(project, workspace)
+	Ok(t, err)
+	Equals(t, 9, len(queue))
+}
+
+func TestJobs(t *testing.T) {
+	r := newTestLocker(t)
+
+	first := models.Job{ID: fmt.Sprintf("%019d", time.Now().UnixNano()), Command: "plan", StartedAt: time.Now(), Output: "first"}
+	Ok(t, r.SaveJob(first))
+	second := models.Job{ID: fmt.Sprintf("%019d", time.Now().UnixNano()+1), Command: "apply", StartedAt: time.Now(), Output: "second"}
+	Ok(t, r.SaveJob(second))
+	second.Output = "second updated"
+	Ok(t, r.SaveJob(second))
+
+	job, err := r.GetJob(first.ID)
+	Ok(t, err)
+	Equals(t, first.Output, job.Output)
+	Equals(t, first.Command, job.Command)
+	job, err = r.GetJob(second.ID)
+	Ok(t, err)
+	Equals(t, "second updated", job.Output)
+
+	t.Log("jobs should be listed newest first without their output")
+	list, err := r.ListJobs()
+	Ok(t, err)
+	Equals(t, 2, len(list))
+	Equals(t, second.ID, list[0].ID)
+	Equals(t, first.ID, list[1].ID)
+	Equals(t, "", list[0].Output)
+
+	t.Log("jobs older than jobs.Retention should be deleted")
+	old := models.Job{ID: fmt.Sprintf("%019d", 1), Command: "plan", StartedAt: time.Unix(0, 1)}
+	Ok(t, r.SaveJob(old))
+	job, err = r.GetJob(old.ID)
+	Ok(t, err)
+	Assert(t, job == nil, "exp old job to be deleted")
+
+	job, err = r.GetJob("missing")
+	Ok(t, err)
+	Assert(t, job == nil, "exp no job")
+}
+
+func TestAuditEvents(t *testing.T) {
+	r := newTestLocker(t)
+
+	list, err := r.ListAuditEvents("", 10)
+	Ok(t, err)
+	Assert(t, len(list) == 0, "exp no events but got %v", list)
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		event := models.AuditEvent{ID: fmt.Sprintf("%019d-0", i+1), Command: "plan", PullNum: i}
+		Ok(t, r.AppendAuditEvent(event))
+		ids = append(ids, event.ID)
+	}
+
+	t.Log("events should be listed oldest first")
+	list, err = r.ListAuditEvents("", 10)
+	Ok(t, err)
+	Equals(t, 3, len(list))
+	Equals(t, ids[0], list[0].ID)
+	Equals(t, 2, list[2].PullNum)
+
+	t.Log("we should be able to page through the events")
+	list, err = r.ListAuditEvents(ids[0], 1)
+	Ok(t, err)
+	Equals(t, 1, len(list))
+	Equals(t, ids[1], list[0].ID)
+	list, err = r.ListAuditEvents(ids[2], 10)
+	Ok(t, err)
+	Assert(t, len(list) == 0, "exp no events after the last one but got %v", list)
+}
+
+func TestPlanOutputs(t *testing.T) {
+	r := newTestLocker(t)
+
+	plan := models.PlanOutput{ID: "id", RepoFullName: "owner/repo", PullNum: 1, Output: "first", CreatedAt: time.Now().Round(time.Second).UTC()}
+	Ok(t, r.SavePlanOutput(plan))
+	got, err := r.GetPlanOutput("id")
+	Ok(t, err)
+	Equals(t, "first", got.Output)
+	Equals(t, 1, got.PullNum)
+
+	t.Log("saving the plan again should replace it")
+	plan.Output = "second"
+	Ok(t, r.SavePlanOutput(plan))
+	got, err = r.GetPlanOutput("id")
+	Ok(t, err)
+	Equals(t, "second", got.Output)
+
+	got, err = r.GetPlanOutput("missing")
+	Ok(t, err)
+	Assert(t, got == nil, "exp no plan")
+
+	t.Log("plans older than plans.Retention should be deleted")
+	old := models.PlanOutput{ID: "old", CreatedAt: time.Now().Add(-plans.Retention - time.Hour)}
+	Ok(t, r.SavePlanOutput(old))
+	got, err = r.GetPlanOutput("old")
+	Ok(t, err)
+	Assert(t, got == nil, "exp old plan to be deleted")
+}
+
+func TestAutoplanDisabled(t *testing.T) {
+	r := newTestLocker(t)
+
+	disabled, err := r.AutoplanDisabled("owner/repo", 1)
+	Ok(t, err)
+	Assert(t, !disabled, "exp autoplanning to be on by default")
+
+	Ok(t, r.SetAutoplanDisabled("owner/repo", 1, true))
+	t.Log("setting it twice shouldn't error")
+	Ok(t, r.SetAutoplanDisabled("owner/repo", 1, true))
+	disabled, err = r.AutoplanDisabled("owner/repo", 1)
+	Ok(t, err)
+	Assert(t, disabled, "exp autoplanning to be off")
+
+	t.Log("other pulls should still be autoplanned")
+	disabled, err = r.AutoplanDisabled("owner/repo", 2)
+	Ok(t, err)
+	Assert(t, !disabled, "exp autoplanning of other pulls to be on")
+
+	Ok(t, r.SetAutoplanDisabled("owner/repo", 1, false))
+	disabled, err = r.AutoplanDisabled("owner/repo", 1)
+	Ok(t, err)
+	Assert(t, !disabled, "exp autoplanning to be back on")
+}
+
+func TestPendingApplies(t *testing.T) {
+	r := newTestLocker(t)
+
+	got, err := r.TakePendingApply("owner/repo", 1)
+	Ok(t, err)
+	Assert(t, got == nil, "exp no pending apply")
+
+	apply := models.PendingApply{RepoFullName: "owner/repo", PullNum: 1, HeadCommit: "abc123", Username: "lkysow", Command: []byte(`{"name":0}`), CreatedAt: time.Now().Round(time.Second).UTC()}
+	Ok(t, r.SavePendingApply(apply))
+	t.Log("saving another apply for the pull should replace it")
+	apply.HeadCommit = "def456"
+	Ok(t, r.SavePendingApply(apply))
+	Ok(t, r.SavePendingApply(models.PendingApply{RepoFullName: "owner/repo", PullNum: 2}))
+
+	got, err = r.TakePendingApply("owner/repo", 1)
+	Ok(t, err)
+	Equals(t, apply, *got)
+
+	t.Log("a pending apply can only be taken once")
+	got, err = r.TakePendingApply("owner/repo", 1)
+	Ok(t, err)
+	Assert(t, got == nil, "exp pending apply to be deleted")
+	got, err = r.TakePendingApply("owner/repo", 2)
+	Ok(t, err)
+	Equals(t, 2, got.PullNum)
+}
+
+func TestWorkQueue(t *testing.T) {
+	r := newTestLocker(t)
+
+	item := func(id string, pullNum int) models.WorkItem {
+		return models.WorkItem{ID: id, Kind: models.AutoplanWorkItem, BaseRepo: models.Repo{FullName: "owner/repo"}, PullNum: pullNum}
+	}
+	claimed, err := r.ClaimWork("worker")
+	Ok(t, err)
+	Assert(t, claimed == nil, "exp nothing to claim")
+
+	Ok(t, r.EnqueueWork(item("1", 1)))
+	Ok(t, r.EnqueueWork(item("2", 1)))
+	Ok(t, r.EnqueueWork(item("3", 2)))
+
+	claimed, err = r.ClaimWork("worker")
+	Ok(t, err)
+	Equals(t, "1", claimed.ID)
+	Equals(t, "worker", claimed.ClaimedBy)
+
+	t.Log("the next item for pull 1 should wait for the first to complete")
+	claimed, err = r.ClaimWork("worker")
+	Ok(t, err)
+	Equals(t, "3", claimed.ID)
+	claimed, err = r.ClaimWork("worker")
+	Ok(t, err)
+	Assert(t, claimed == nil, "exp nothing to claim but got %v", claimed)
+
+	Ok(t, r.CompleteWork("1"))
+	claimed, err = r.ClaimWork("worker")
+	Ok(t, err)
+	Equals(t, "2", claimed.ID)
+
+	list, err := r.ListWork()
+	Ok(t, err)
+	Equals(t, 2, len(list))
+	Equals(t, "2", list[0].ID)
+	Equals(t, "3", list[1].ID)
+	Equals(t, "owner/repo", list[0].BaseRepo.FullName)
+	Equals(t, "worker", list[1].ClaimedBy)
+
+	t.Log("renewing a claim should only update it for the worker that claimed the item")
+	before := list[1].ClaimedAt
+	Ok(t, r.RenewWork("3", "other-worker"))
+	list, err = r.ListWork()
+	Ok(t, err)
+	Assert(t, list[1].ClaimedAt.Equal(before), "exp the claim to not be renewed by another worker")
+	time.Sleep(time.Millisecond)
+	Ok(t, r.RenewWork("3", "worker"))
+	list, err = r.ListWork()
+	Ok(t, err)
+	Assert(t, list[1].ClaimedAt.After(before), "exp the claim to be renewed")
+	Ok(t, r.RenewWork("missing", "worker"))
+
+	t.Log("only claims that haven't been renewed within the timeout should be dropped")
+	time.Sleep(100 * time.Millisecond)
+	Ok(t, r.RenewWork("3", "worker"))
+	dropped, err := r.DropExpiredWork(50 * time.Millisecond)
+	Ok(t, err)
+	Equals(t, 1, len(dropped))
+	Equals(t, "2", dropped[0].ID)
+	list, err = r.ListWork()
+	Ok(t, err)
+	Equals(t, 1, len(list))
+	Equals(t, "3", list[0].ID)
+
+	Ok(t, r.CompleteWork("3"))
+	list, err = r.ListWork()
+	Ok(t, err)
+	Equals(t, 0, len(list))
+}
+
+func TestApplyLock(t *testing.T) {
+	r := newTestLocker(t)
+
+	lock, err := r.GetApplyLock()
+	Ok(t, err)
+	Assert(t, lock == nil, "exp applies to not be locked")
+	Ok(t, r.UnlockApplies())
+
+	applyLock := models.ApplyLock{Time: time.Now().Round(time.Second)}
+	Ok(t, r.LockApplies(applyLock))
+	Ok(t, r.LockApplies(applyLock))
+	lock, err = r.GetApplyLock()
+	Ok(t, err)
+	Assert(t, lock != nil && lock.Time.Equal(applyLock.Time), "exp applies to be locked at %s but got %v", applyLock.Time, lock)
+
+	Ok(t, r.UnlockApplies())
+	lock, err = r.GetApplyLock()
+	Ok(t, err)
+	Assert(t, lock == nil, "exp applies to be unlocked")
+}
+
+// newTestLocker returns a locker using the database in testURLEnvVar after
+// dropping its Atlantis tables. The test is skipped if it isn't set.
+func newTestLocker(t *testing.T) *postgres.PostgresLocker {
+	url := os.Getenv(testURLEnvVar)
+	if url == "" {
+		t.Skipf("%s isn't set", testURLEnvVar)
+	}
+	db, err := sql.Open("postgres", url)
+	Ok(t, err)
+	defer db.Close() // nolint: errcheck
+	_, err = db.Exec(`DROP TABLE IF EXISTS atlantis_schema_migrations, atlantis_locks, atlantis_lock_queue, atlantis_global_locks, atlantis_jobs, atlantis_audit_events, atlantis_plans, atlantis_work_queue, atlantis_autoplan_disabled, atlantis_pending_applies`)
+	Ok(t, err)
+
+	r, err := postgres.New(url)
+	Ok(t, err)
+	return r
+}
+
+func pullNums(locks []models.ProjectLock) []int {
+	var nums []int
+	for _, l := range locks {
+		nums = append(nums, l.Pull.Num)
+	}
+	return nums
+}
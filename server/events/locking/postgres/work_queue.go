@@ -0,0 +1,167 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/workqueue"
+)
+
+// workQueueLockKey is the second key of the advisory lock held while claiming
+// work so that two workers don't claim items for the same pull.
+const workQueueLockKey = 1
+
+// EnqueueWork adds item to the end of the queue.
+func (p *PostgresLocker) EnqueueWork(item models.WorkItem) error {
+	serialized, err := json.Marshal(item)
+	if err != nil {
+		return errors.Wrap(err, "serializing work item")
+	}
+	_, err = p.db.Exec(`INSERT INTO atlantis_work_queue (id, item) VALUES ($1, $2)`, item.ID, string(serialized))
+	return errors.Wrap(err, "enqueueing work item")
+}
+
+// ClaimWork gives worker the oldest waiting item for a pull that has no other
+// item claimed and returns it, or nil if there isn't one.
+func (p *PostgresLocker) ClaimWork(worker string) (*models.WorkItem, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return nil, errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback() // nolint: errcheck
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1, $2)`, lockNamespace, workQueueLockKey); err != nil {
+		return nil, errors.Wrap(err, "taking advisory lock")
+	}
+	rows, err := tx.Query(`SELECT item FROM atlantis_work_queue ORDER BY id`)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing work items")
+	}
+	items, err := scanWorkItems(rows)
+	if err != nil {
+		return nil, err
+	}
+	i := workqueue.Claimable(items)
+	if i == -1 {
+		return nil, nil
+	}
+	item := items[i]
+	item.ClaimedBy = worker
+	item.ClaimedAt = time.Now()
+	serialized, err := json.Marshal(item)
+	if err != nil {
+		return nil, errors.Wrap(err, "serializing work item")
+	}
+	if _, err := tx.Exec(`UPDATE atlantis_work_queue SET item = $2 WHERE id = $1`, item.ID, string(serialized)); err != nil {
+		return nil, errors.Wrap(err, "claiming work item")
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "committing transaction")
+	}
+	return &item, nil
+}
+
+// RenewWork sets when the item with id was claimed to now if it's still
+// claimed by worker.
+func (p *PostgresLocker) RenewWork(id string, worker string) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback() // nolint: errcheck
+
+	var serialized string
+	err = tx.QueryRow(`SELECT item FROM atlantis_work_queue WHERE id = $1 FOR UPDATE`, id).Scan(&serialized)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "getting work item")
+	}
+	var item models.WorkItem
+	if err := json.Unmarshal([]byte(serialized), &item); err != nil {
+		return errors.Wrap(err, "deserializing work item")
+	}
+	if item.ClaimedBy != worker {
+		return nil
+	}
+	item.ClaimedAt = time.Now()
+	renewed, err := json.Marshal(item)
+	if err != nil {
+		return errors.Wrap(err, "serializing work item")
+	}
+	if _, err := tx.Exec(`UPDATE atlantis_work_queue SET item = $2 WHERE id = $1`, id, string(renewed)); err != nil {
+		return errors.Wrap(err, "renewing work item")
+	}
+	return errors.Wrap(tx.Commit(), "committing transaction")
+}
+
+// CompleteWork deletes the item with id.
+func (p *PostgresLocker) CompleteWork(id string) error {
+	_, err := p.db.Exec(`DELETE FROM atlantis_work_queue WHERE id = $1`, id)
+	return errors.Wrap(err, "completing work item")
+}
+
+// DropExpiredWork deletes the items whose claims have expired and returns
+// them. The items are locked while they're checked so a claim can't be
+// renewed between the check and the delete.
+func (p *PostgresLocker) DropExpiredWork(timeout time.Duration) ([]models.WorkItem, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return nil, errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback() // nolint: errcheck
+
+	rows, err := tx.Query(`SELECT item FROM atlantis_work_queue ORDER BY id FOR UPDATE`)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing work items")
+	}
+	items, err := scanWorkItems(rows)
+	if err != nil {
+		return nil, err
+	}
+	var dropped []models.WorkItem
+	now := time.Now()
+	for _, item := range items {
+		if !workqueue.ClaimExpired(item, timeout, now) {
+			continue
+		}
+		if _, err := tx.Exec(`DELETE FROM atlantis_work_queue WHERE id = $1`, item.ID); err != nil {
+			return nil, errors.Wrap(err, "dropping expired work item")
+		}
+		dropped = append(dropped, item)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "committing transaction")
+	}
+	return dropped, nil
+}
+
+// ListWork returns all the items, oldest first.
+func (p *PostgresLocker) ListWork() ([]models.WorkItem, error) {
+	rows, err := p.db.Query(`SELECT item FROM atlantis_work_queue ORDER BY id`)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing work items")
+	}
+	return scanWorkItems(rows)
+}
+
+func scanWorkItems(rows *sql.Rows) ([]models.WorkItem, error) {
+	defer rows.Close() // nolint: errcheck
+	var items []models.WorkItem
+	for rows.Next() {
+		var serialized string
+		if err := rows.Scan(&serialized); err != nil {
+			return nil, errors.Wrap(err, "scanning work item")
+		}
+		var item models.WorkItem
+		if err := json.Unmarshal([]byte(serialized), &item); err != nil {
+			return nil, errors.Wrap(err, "deserializing work item")
+		}
+		items = append(items, item)
+	}
+	return items, errors.Wrap(rows.Err(), "listing work items")
+}
@@ -0,0 +1,46 @@
+package redis
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// applyLockKey is the key storing the lock that stops all applies.
+const applyLockKey = "atlantis:global:apply"
+
+// LockApplies saves lock so all applies are stopped until UnlockApplies is
+// called.
+func (r *RedisLocker) LockApplies(lock models.ApplyLock) error {
+	serialized, err := json.Marshal(lock)
+	if err != nil {
+		return errors.Wrap(err, "serializing apply lock")
+	}
+	_, err = r.client.Do("SET", applyLockKey, string(serialized))
+	return errors.Wrap(err, "saving apply lock")
+}
+
+// UnlockApplies deletes the lock stopping all applies. It doesn't error if
+// there isn't one.
+func (r *RedisLocker) UnlockApplies() error {
+	_, err := r.client.Do("DEL", applyLockKey)
+	return errors.Wrap(err, "deleting apply lock")
+}
+
+// GetApplyLock returns the lock stopping all applies or nil if there isn't
+// one.
+func (r *RedisLocker) GetApplyLock() (*models.ApplyLock, error) {
+	serialized, err := r.client.Get(applyLockKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting apply lock")
+	}
+	if serialized == nil {
+		return nil, nil
+	}
+	var lock models.ApplyLock
+	if err := json.Unmarshal(serialized, &lock); err != nil {
+		return nil, errors.Wrap(err, "deserializing apply lock")
+	}
+	return &lock, nil
+}
@@ -0,0 +1,56 @@
+package redis
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// auditKeyPrefix is prepended to the audit event IDs to get the keys storing
+// the events.
+const auditKeyPrefix = "atlantis:audit:"
+
+// AppendAuditEvent adds event to the audit log. Unlike jobs, audit events
+// don't expire.
+func (r *RedisLocker) AppendAuditEvent(event models.AuditEvent) error {
+	serialized, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "serializing audit event")
+	}
+	_, err = r.client.Do("SET", auditKeyPrefix+event.ID, string(serialized))
+	return errors.Wrap(err, "saving audit event")
+}
+
+// ListAuditEvents returns up to limit events with IDs after afterID, oldest
+// first.
+func (r *RedisLocker) ListAuditEvents(afterID string, limit int) ([]models.AuditEvent, error) {
+	keys, err := r.client.Scan(auditKeyPrefix + "*")
+	if err != nil {
+		return nil, errors.Wrap(err, "listing audit events")
+	}
+	sort.Strings(keys)
+	var list []models.AuditEvent
+	for _, k := range keys {
+		if len(list) == limit {
+			break
+		}
+		if k <= auditKeyPrefix+afterID {
+			continue
+		}
+		serialized, err := r.client.Get(k)
+		if err != nil {
+			return nil, errors.Wrap(err, "getting audit event")
+		}
+		if serialized == nil {
+			continue
+		}
+		var event models.AuditEvent
+		if err := json.Unmarshal(serialized, &event); err != nil {
+			return nil, errors.Wrapf(err, "deserializing audit event at key %q", k)
+		}
+		list = append(list, event)
+	}
+	return list, nil
+}
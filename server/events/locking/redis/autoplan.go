@@ -0,0 +1,32 @@
+package redis
+
+import (
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/autoplan"
+)
+
+// autoplanKeyPrefix is prepended to autoplan.Key to get the keys storing the
+// pulls that autoplanning is off for.
+const autoplanKeyPrefix = "atlantis:autoplan-disabled:"
+
+// SetAutoplanDisabled turns autoplanning of the pull off if disabled is true,
+// or back on if it's false. Only the pulls it's off for are stored.
+func (r *RedisLocker) SetAutoplanDisabled(repoFullName string, pullNum int, disabled bool) error {
+	key := autoplanKeyPrefix + autoplan.Key(repoFullName, pullNum)
+	var err error
+	if disabled {
+		_, err = r.client.Do("SET", key, "disabled")
+	} else {
+		_, err = r.client.Do("DEL", key)
+	}
+	return errors.Wrap(err, "saving autoplan setting")
+}
+
+// AutoplanDisabled returns true if autoplanning of the pull is off.
+func (r *RedisLocker) AutoplanDisabled(repoFullName string, pullNum int) (bool, error) {
+	val, err := r.client.Get(autoplanKeyPrefix + autoplan.Key(repoFullName, pullNum))
+	if err != nil {
+		return false, errors.Wrap(err, "getting autoplan setting")
+	}
+	return val != nil, nil
+}
@@ -0,0 +1,261 @@
+package redis
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const dialTimeout = 5 * time.Second
+const ioTimeout = 10 * time.Second
+
+// maxWatchRetries is how many times we retry a transaction that was aborted
+// because another Atlantis instance modified the keys it was watching.
+const maxWatchRetries = 10
+
+// getFunc returns the value at key or nil if the key doesn't exist.
+type getFunc func(key string) ([]byte, error)
+
+// redisError is an error reply from the Redis server.
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// client is a minimal Redis client that speaks RESP over a single
+// connection. Commands are serialized on the connection which is fine for the
+// small number of quick calls the locker makes. If the connection breaks it's
+// redialed on the next command.
+type client struct {
+	addr     string
+	password string
+
+	mu   sync.Mutex
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+// Do runs command args and returns its reply. Replies are a string for
+// status replies, an int64 for integers, a []byte for bulk strings (nil if the
+// key didn't exist) and an []interface{} for arrays.
+func (c *client) Do(args ...string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.do(args...)
+}
+
+// Get returns the value at key or nil if the key doesn't exist.
+func (c *client) Get(key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.get(key)
+}
+
+// Scan returns all the keys matching the glob pattern match.
+func (c *client) Scan(match string) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var keys []string
+	cursor := "0"
+	for {
+		reply, err := c.do("SCAN", cursor, "MATCH", match, "COUNT", "100")
+		if err != nil {
+			return nil, err
+		}
+		arr, ok := reply.([]interface{})
+		if !ok || len(arr) != 2 {
+			return nil, fmt.Errorf("unexpected SCAN reply %v", reply)
+		}
+		next, ok := arr[0].([]byte)
+		if !ok {
+			return nil, fmt.Errorf("unexpected SCAN cursor %v", arr[0])
+		}
+		page, _ := arr[1].([]interface{})
+		for _, k := range page {
+			if kb, ok := k.([]byte); ok {
+				keys = append(keys, string(kb))
+			}
+		}
+		cursor = string(next)
+		if cursor == "0" {
+			return keys, nil
+		}
+	}
+}
+
+// Watch watches keys and calls fn which can read the current values with get
+// and returns the commands to run. The commands are run atomically in a
+// MULTI/EXEC transaction. If any of keys was modified after fn read it, the
+// transaction is aborted by Redis and fn is called again with the new values.
+func (c *client) Watch(keys []string, fn func(get getFunc) ([][]string, error)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := 0; i < maxWatchRetries; i++ {
+		if _, err := c.do(append([]string{"WATCH"}, keys...)...); err != nil {
+			return err
+		}
+		cmds, err := fn(c.get)
+		if err != nil {
+			c.do("UNWATCH") // nolint: errcheck
+			return err
+		}
+		if _, err := c.do("MULTI"); err != nil {
+			return err
+		}
+		for _, cmd := range cmds {
+			if _, err := c.do(cmd...); err != nil {
+				c.do("DISCARD") // nolint: errcheck
+				return err
+			}
+		}
+		reply, err := c.do("EXEC")
+		if err != nil {
+			return err
+		}
+		// A nil reply means a watched key was modified so nothing was run.
+		if results, _ := reply.([]interface{}); results != nil {
+			for j, r := range results {
+				if rerr, ok := r.(redisError); ok {
+					return errors.Wrapf(rerr, "running %s", cmds[j][0])
+				}
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("transaction aborted %d times because of concurrent modifications", maxWatchRetries)
+}
+
+// get must be called with mu held.
+func (c *client) get(key string) ([]byte, error) {
+	reply, err := c.do("GET", key)
+	if err != nil {
+		return nil, err
+	}
+	val, _ := reply.([]byte)
+	return val, nil
+}
+
+// do must be called with mu held.
+func (c *client) do(args ...string) (interface{}, error) {
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+	reply, err := c.roundTrip(args)
+	if err != nil {
+		// Error replies leave the connection usable but anything else means
+		// we don't know what state it's in.
+		if _, ok := err.(redisError); !ok {
+			c.close()
+		}
+		return nil, errors.Wrapf(err, "running %s", args[0])
+	}
+	return reply, nil
+}
+
+func (c *client) connect() error {
+	if c.conn != nil {
+		return nil
+	}
+	conn, err := net.DialTimeout("tcp", c.addr, dialTimeout)
+	if err != nil {
+		return errors.Wrapf(err, "connecting to %s", c.addr)
+	}
+	c.conn = conn
+	c.rd = bufio.NewReader(conn)
+	if c.password != "" {
+		if _, err := c.roundTrip([]string{"AUTH", c.password}); err != nil {
+			c.close()
+			return errors.Wrap(err, "authenticating")
+		}
+	}
+	return nil
+}
+
+func (c *client) close() {
+	if c.conn != nil {
+		c.conn.Close() // nolint: errcheck
+	}
+	c.conn = nil
+	c.rd = nil
+}
+
+func (c *client) roundTrip(args []string) (interface{}, error) {
+	if err := c.conn.SetDeadline(time.Now().Add(ioTimeout)); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&buf, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := c.conn.Write(buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return readReply(c.rd)
+}
+
+// readReply reads a single RESP reply.
+func readReply(rd *bufio.Reader) (interface{}, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("invalid reply %q", line)
+	}
+	body := line[1 : len(line)-2]
+	switch line[0] {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bulk string length %q", body)
+		}
+		if n < 0 {
+			return []byte(nil), nil
+		}
+		val := make([]byte, n+2)
+		if _, err := io.ReadFull(rd, val); err != nil {
+			return nil, err
+		}
+		return val[:n], nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid array length %q", body)
+		}
+		if n < 0 {
+			return []interface{}(nil), nil
+		}
+		arr := make([]interface{}, n)
+		for i := range arr {
+			elem, err := readReply(rd)
+			// Error replies in arrays, ex. from EXEC, are kept as elements
+			// so we still read the rest of the array off the connection.
+			if rerr, ok := err.(redisError); ok {
+				elem = rerr
+			} else if err != nil {
+				return nil, err
+			}
+			arr[i] = elem
+		}
+		return arr, nil
+	default:
+		return nil, fmt.Errorf("invalid reply %q", line)
+	}
+}
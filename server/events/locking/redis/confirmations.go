@@ -0,0 +1,49 @@
+package redis
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/confirmations"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// pendingApplyKeyPrefix is prepended to confirmations.Key to get the keys
+// storing the pending applies.
+const pendingApplyKeyPrefix = "atlantis:pending-apply:"
+
+// SavePendingApply creates or replaces the pending apply of its pull.
+func (r *RedisLocker) SavePendingApply(apply models.PendingApply) error {
+	serialized, err := json.Marshal(apply)
+	if err != nil {
+		return errors.Wrap(err, "serializing pending apply")
+	}
+	_, err = r.client.Do("SET", pendingApplyKeyPrefix+confirmations.Key(apply.RepoFullName, apply.PullNum), string(serialized))
+	return errors.Wrap(err, "saving pending apply")
+}
+
+// TakePendingApply deletes the pending apply of the pull and returns it, or
+// nil if there isn't one. Only the caller whose DEL deleted the key gets it
+// so that it can't be taken twice.
+func (r *RedisLocker) TakePendingApply(repoFullName string, pullNum int) (*models.PendingApply, error) {
+	key := pendingApplyKeyPrefix + confirmations.Key(repoFullName, pullNum)
+	serialized, err := r.client.Get(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting pending apply")
+	}
+	if serialized == nil {
+		return nil, nil
+	}
+	deleted, err := r.client.Do("DEL", key)
+	if err != nil {
+		return nil, errors.Wrap(err, "deleting pending apply")
+	}
+	if n, ok := deleted.(int64); !ok || n == 0 {
+		return nil, nil
+	}
+	var apply models.PendingApply
+	if err := json.Unmarshal(serialized, &apply); err != nil {
+		return nil, errors.Wrapf(err, "deserializing pending apply %q", key)
+	}
+	return &apply, nil
+}
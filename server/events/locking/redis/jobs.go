@@ -0,0 +1,73 @@
+package redis
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/jobs"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// jobKeyPrefix is prepended to the job IDs to get the keys storing the jobs.
+const jobKeyPrefix = "atlantis:job:"
+
+// SaveJob creates or updates job. Jobs expire jobs.Retention after they
+// started.
+func (r *RedisLocker) SaveJob(job models.Job) error {
+	serialized, err := json.Marshal(job)
+	if err != nil {
+		return errors.Wrap(err, "serializing job")
+	}
+	ttl := int((jobs.Retention - time.Since(job.StartedAt)) / time.Second)
+	if ttl < 1 {
+		ttl = 1
+	}
+	_, err = r.client.Do("SET", jobKeyPrefix+job.ID, string(serialized), "EX", strconv.Itoa(ttl))
+	return errors.Wrap(err, "saving job")
+}
+
+// GetJob returns the job with id or nil if there isn't one.
+func (r *RedisLocker) GetJob(id string) (*models.Job, error) {
+	return r.getJob(jobKeyPrefix + id)
+}
+
+// ListJobs returns all the jobs, newest first, without their output.
+func (r *RedisLocker) ListJobs() ([]models.Job, error) {
+	keys, err := r.client.Scan(jobKeyPrefix + "*")
+	if err != nil {
+		return nil, errors.Wrap(err, "listing jobs")
+	}
+	// IDs sort in the order the jobs started.
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+	var list []models.Job
+	for _, k := range keys {
+		job, err := r.getJob(k)
+		if err != nil {
+			return nil, err
+		}
+		// The job could have expired since we listed the keys.
+		if job != nil {
+			job.Output = ""
+			list = append(list, *job)
+		}
+	}
+	return list, nil
+}
+
+func (r *RedisLocker) getJob(key string) (*models.Job, error) {
+	serialized, err := r.client.Get(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting job")
+	}
+	if serialized == nil {
+		return nil, nil
+	}
+	var job models.Job
+	if err := json.Unmarshal(serialized, &job); err != nil {
+		return nil, errors.Wrapf(err, "deserializing job at key %q", key)
+	}
+	return &job, nil
+}
@@ -0,0 +1,46 @@
+package redis
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/plans"
+)
+
+// planKeyPrefix is prepended to the plan IDs to get the keys storing the
+// plans.
+const planKeyPrefix = "atlantis:plan:"
+
+// SavePlanOutput creates or replaces plan. Plans expire plans.Retention after
+// they were created.
+func (r *RedisLocker) SavePlanOutput(plan models.PlanOutput) error {
+	serialized, err := json.Marshal(plan)
+	if err != nil {
+		return errors.Wrap(err, "serializing plan")
+	}
+	ttl := int((plans.Retention - time.Since(plan.CreatedAt)) / time.Second)
+	if ttl < 1 {
+		ttl = 1
+	}
+	_, err = r.client.Do("SET", planKeyPrefix+plan.ID, string(serialized), "EX", strconv.Itoa(ttl))
+	return errors.Wrap(err, "saving plan")
+}
+
+// GetPlanOutput returns the plan with id or nil if there isn't one.
+func (r *RedisLocker) GetPlanOutput(id string) (*models.PlanOutput, error) {
+	serialized, err := r.client.Get(planKeyPrefix + id)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting plan")
+	}
+	if serialized == nil {
+		return nil, nil
+	}
+	var plan models.PlanOutput
+	if err := json.Unmarshal(serialized, &plan); err != nil {
+		return nil, errors.Wrapf(err, "deserializing plan %q", id)
+	}
+	return &plan, nil
+}
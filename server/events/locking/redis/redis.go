@@ -0,0 +1,333 @@
+// Package redis provides a locking implementation using Redis.
+// Unlike BoltDB, which stores its data in a local file, Redis can be shared
+// by multiple Atlantis instances so they see the same locks.
+// See https://redis.io for more information.
+package redis
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// DefaultPort is the port we connect to if the address doesn't have one.
+const DefaultPort = "6379"
+
+// lockKeyPrefix is prepended to the keys storing the locks.
+const lockKeyPrefix = "atlantis:lock:"
+
+// queueKeyPrefix is prepended to the keys storing the pulls waiting for each
+// lock. They're keyed the same as the locks and each value is a serialized
+// []models.ProjectLock in the order the pulls started waiting.
+const queueKeyPrefix = "atlantis:queue:"
+
+// RedisLocker is a locking backend using Redis.
+type RedisLocker struct {
+	client *client
+}
+
+// New returns a locker using the Redis server at addr, ex. redis.corp.com:6379.
+// If addr doesn't have a port, DefaultPort is used. If password isn't empty
+// it's used to authenticate. An error is returned if the server can't be
+// reached.
+func New(addr string, password string) (*RedisLocker, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, DefaultPort)
+	}
+	c := &client{addr: addr, password: password}
+	if _, err := c.Do("PING"); err != nil {
+		return nil, errors.Wrap(err, "starting Redis")
+	}
+	return &RedisLocker{c}, nil
+}
+
+// TryLock attempts to create a new lock. If the lock is
+// acquired, it will return true and the lock returned will be newLock.
+// If the lock is not acquired, it will return false and the current
+// lock that is preventing this lock from being acquired. If the current lock
+// is held by a different pull request, newLock is added to the end of the
+// queue of pulls waiting for the lock.
+func (r *RedisLocker) TryLock(newLock models.ProjectLock) (bool, models.ProjectLock, error) {
+	var lockAcquired bool
+	var currLock models.ProjectLock
+	key := r.key(newLock.Project, newLock.Workspace)
+	newLockSerialized, _ := json.Marshal(newLock)
+	transactionErr := r.client.Watch([]string{lockKeyPrefix + key, queueKeyPrefix + key}, func(get getFunc) ([][]string, error) {
+		currLock = models.ProjectLock{}
+		queue, err := r.getQueue(get, key)
+		if err != nil {
+			return nil, err
+		}
+
+		// if there is no lock at that key then we're free to create the lock
+		currLockSerialized, err := get(lockKeyPrefix + key)
+		if err != nil {
+			return nil, err
+		}
+		if currLockSerialized == nil {
+			lockAcquired = true
+			currLock = newLock
+			// If this pull was waiting for the lock it isn't anymore.
+			putQueue, err := r.putQueue(key, r.removeFromQueue(queue, newLock.Pull.Num))
+			if err != nil {
+				return nil, err
+			}
+			return [][]string{{"SET", lockKeyPrefix + key, string(newLockSerialized)}, putQueue}, nil
+		}
+
+		// otherwise the lock fails, return to caller the run that's holding the lock
+		if err := json.Unmarshal(currLockSerialized, &currLock); err != nil {
+			return nil, errors.Wrap(err, "failed to deserialize current lock")
+		}
+		lockAcquired = false
+
+		// If another pull holds the lock then this pull waits in line for it.
+		if currLock.Pull.Num != newLock.Pull.Num && r.queuePosition(queue, newLock.Pull.Num) == 0 {
+			putQueue, err := r.putQueue(key, append(queue, newLock))
+			if err != nil {
+				return nil, err
+			}
+			return [][]string{putQueue}, nil
+		}
+		return nil, nil
+	})
+
+	if transactionErr != nil {
+		return false, currLock, errors.Wrap(transactionErr, "Redis transaction failed")
+	}
+
+	return lockAcquired, currLock, nil
+}
+
+// Unlock attempts to unlock the project and workspace.
+// If there is no lock, then it will return a nil pointer.
+// If there is a lock, then it will delete it, and then return a pointer
+// to the deleted lock. If pulls are waiting for the lock, it's given to the
+// first of them.
+func (r *RedisLocker) Unlock(p models.Project, workspace string) (*models.ProjectLock, error) {
+	var lock models.ProjectLock
+	foundLock := false
+	key := r.key(p, workspace)
+	lockKey := lockKeyPrefix + key
+	err := r.client.Watch([]string{lockKey, queueKeyPrefix + key}, func(get getFunc) ([][]string, error) {
+		lock = models.ProjectLock{}
+		foundLock = false
+		serialized, err := get(lockKey)
+		if err != nil {
+			return nil, err
+		}
+		if serialized == nil {
+			return nil, nil
+		}
+		if err := json.Unmarshal(serialized, &lock); err != nil {
+			return nil, errors.Wrap(err, "failed to deserialize lock")
+		}
+		foundLock = true
+
+		queue, err := r.getQueue(get, key)
+		if err != nil {
+			return nil, err
+		}
+		if len(queue) == 0 {
+			return [][]string{{"DEL", lockKey}}, nil
+		}
+		next := queue[0]
+		next.Time = time.Now().Local()
+		nextSerialized, err := json.Marshal(next)
+		if err != nil {
+			return nil, errors.Wrap(err, "serializing lock")
+		}
+		putQueue, err := r.putQueue(key, queue[1:])
+		if err != nil {
+			return nil, err
+		}
+		return [][]string{{"SET", lockKey, string(nextSerialized)}, putQueue}, nil
+	})
+	err = errors.Wrap(err, "Redis transaction failed")
+	if foundLock {
+		return &lock, err
+	}
+	return nil, err
+}
+
+// List lists all current locks.
+func (r *RedisLocker) List() ([]models.ProjectLock, error) {
+	var locks []models.ProjectLock
+	keys, err := r.client.Scan(lockKeyPrefix + "*")
+	if err != nil {
+		return locks, errors.Wrap(err, "listing locks")
+	}
+	// Sort so the locks are in the same order as BoltDB returns them.
+	sort.Strings(keys)
+	for _, k := range keys {
+		lock, err := r.getLock(k)
+		if err != nil {
+			return locks, err
+		}
+		// The lock could have been deleted since we listed the keys.
+		if lock != nil {
+			locks = append(locks, *lock)
+		}
+	}
+	return locks, nil
+}
+
+// GetQueue returns the pulls waiting for the lock on that project and
+// workspace in the order they started waiting.
+func (r *RedisLocker) GetQueue(p models.Project, workspace string) ([]models.ProjectLock, error) {
+	queue, err := r.getQueue(r.client.Get, r.key(p, workspace))
+	return queue, errors.Wrap(err, "getting queue")
+}
+
+// UnlockByPull deletes all locks associated with that pull request and returns them.
+// It also removes the pull from the queues of any locks it was waiting for.
+func (r *RedisLocker) UnlockByPull(repoFullName string, pullNum int) ([]models.ProjectLock, error) {
+	var locks []models.ProjectLock
+	// The repoFullName is the first part of the key so we can match on it.
+	lockKeys, err := r.client.Scan(lockKeyPrefix + escapeGlob(repoFullName) + "/*")
+	if err != nil {
+		return locks, errors.Wrap(err, "listing locks")
+	}
+	for _, k := range lockKeys {
+		lock, err := r.getLock(k)
+		if err != nil {
+			return locks, err
+		}
+		if lock != nil && lock.Pull.Num == pullNum {
+			locks = append(locks, *lock)
+		}
+	}
+
+	// delete the locks
+	for _, lock := range locks {
+		if _, err = r.Unlock(lock.Project, lock.Workspace); err != nil {
+			return locks, errors.Wrapf(err, "unlocking repo %s, path %s, workspace %s", lock.Project.RepoFullName, lock.Project.Path, lock.Workspace)
+		}
+	}
+
+	// remove the pull from any queues it's waiting in
+	queueKeys, err := r.client.Scan(queueKeyPrefix + escapeGlob(repoFullName) + "/*")
+	if err != nil {
+		return locks, errors.Wrap(err, "listing queues")
+	}
+	for _, k := range queueKeys {
+		key := strings.TrimPrefix(k, queueKeyPrefix)
+		err := r.client.Watch([]string{k}, func(get getFunc) ([][]string, error) {
+			queue, err := r.getQueue(get, key)
+			if err != nil {
+				return nil, err
+			}
+			if r.queuePosition(queue, pullNum) == 0 {
+				return nil, nil
+			}
+			putQueue, err := r.putQueue(key, r.removeFromQueue(queue, pullNum))
+			if err != nil {
+				return nil, err
+			}
+			return [][]string{putQueue}, nil
+		})
+		if err != nil {
+			return locks, errors.Wrap(err, "Redis transaction failed")
+		}
+	}
+	return locks, nil
+}
+
+// GetLock returns a pointer to the lock for that project and workspace.
+// If there is no lock, it returns a nil pointer.
+func (r *RedisLocker) GetLock(p models.Project, workspace string) (*models.ProjectLock, error) {
+	return r.getLock(lockKeyPrefix + r.key(p, workspace))
+}
+
+func (r *RedisLocker) getLock(lockKey string) (*models.ProjectLock, error) {
+	lockBytes, err := r.client.Get(lockKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting lock data")
+	}
+	// lockBytes will be nil if there was no data at that key
+	if lockBytes == nil {
+		return nil, nil
+	}
+
+	var lock models.ProjectLock
+	if err := json.Unmarshal(lockBytes, &lock); err != nil {
+		return nil, errors.Wrapf(err, "deserializing lock at key %q", lockKey)
+	}
+
+	// need to set it to Local after deserialization due to https://github.com/golang/go/issues/19486
+	lock.Time = lock.Time.Local()
+	return &lock, nil
+}
+
+func (r *RedisLocker) key(p models.Project, workspace string) string {
+	return fmt.Sprintf("%s/%s/%s", p.RepoFullName, p.Path, workspace)
+}
+
+func (r *RedisLocker) getQueue(get getFunc, key string) ([]models.ProjectLock, error) {
+	var queue []models.ProjectLock
+	serialized, err := get(queueKeyPrefix + key)
+	if err != nil {
+		return nil, err
+	}
+	if serialized == nil {
+		return nil, nil
+	}
+	if err := json.Unmarshal(serialized, &queue); err != nil {
+		return nil, errors.Wrapf(err, "deserializing queue at key %q", key)
+	}
+	return queue, nil
+}
+
+// putQueue returns the command that stores queue at key, deleting the key if
+// the queue is empty.
+func (r *RedisLocker) putQueue(key string, queue []models.ProjectLock) ([]string, error) {
+	if len(queue) == 0 {
+		return []string{"DEL", queueKeyPrefix + key}, nil
+	}
+	serialized, err := json.Marshal(queue)
+	if err != nil {
+		return nil, errors.Wrap(err, "serializing queue")
+	}
+	return []string{"SET", queueKeyPrefix + key, string(serialized)}, nil
+}
+
+// queuePosition returns the 1-indexed position of pullNum in the queue or 0
+// if it's not waiting.
+func (r *RedisLocker) queuePosition(queue []models.ProjectLock, pullNum int) int {
+	for i, l := range queue {
+		if l.Pull.Num == pullNum {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func (r *RedisLocker) removeFromQueue(queue []models.ProjectLock, pullNum int) []models.ProjectLock {
+	var filtered []models.ProjectLock
+	for _, l := range queue {
+		if l.Pull.Num != pullNum {
+			filtered = append(filtered, l)
+		}
+	}
+	return filtered
+}
+
+// escapeGlob escapes the characters that are special in Redis' glob-style
+// patterns so s is matched literally.
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch c {
+		case '*', '?', '[', ']', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,790 @@
+package redis_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/jobs"
+	"github.com/runatlantis/atlantis/server/events/locking/redis"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/plans"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+var project = models.NewProject("owner/repo", "parent/child")
+var workspace = "default"
+var pullNum = 1
+var lock = models.ProjectLock{
+	Pull: models.PullRequest{
+		Num: pullNum,
+	},
+	User: models.User{
+		Username: "lkysow",
+	},
+	Workspace: workspace,
+	Project:   project,
+	Time:      time.Now(),
+}
+
+func TestNew_ConnectionErr(t *testing.T) {
+	// Nothing listens on port 1.
+	_, err := redis.New("127.0.0.1:1", "")
+	ErrContains(t, "starting Redis", err)
+}
+
+func TestNew_Password(t *testing.T) {
+	f := newFakeRedis(t, "password")
+	defer f.Close()
+
+	_, err := redis.New(f.Addr(), "wrong")
+	ErrContains(t, "authenticating: WRONGPASS invalid password", err)
+
+	_, err = redis.New(f.Addr(), "password")
+	Ok(t, err)
+}
+
+func TestListNoLocks(t *testing.T) {
+	t.Log("listing locks when there are none should return an empty list")
+	f, r := newTestLocker(t)
+	defer f.Close()
+	ls, err := r.List()
+	Ok(t, err)
+	Equals(t, 0, len(ls))
+}
+
+func TestListMultipleLocks(t *testing.T) {
+	t.Log("listing locks when there are multiple should return them all in order")
+	f, r := newTestLocker(t)
+	defer f.Close()
+
+	// Enough locks that the scan is paginated.
+	var repos []string
+	for i := 0; i < 5; i++ {
+		repos = append(repos, fmt.Sprintf("owner/repo%d", i))
+	}
+	for _, repo := range repos {
+		newLock := lock
+		newLock.Project = models.NewProject(repo, "path")
+		_, _, err := r.TryLock(newLock)
+		Ok(t, err)
+	}
+
+	ls, err := r.List()
+	Ok(t, err)
+	Equals(t, len(repos), len(ls))
+	for i, l := range ls {
+		Equals(t, repos[i], l.Project.RepoFullName)
+	}
+}
+
+func TestLockingExistingLock(t *testing.T) {
+	t.Log("if there is an existing lock, lock should...")
+	f, r := newTestLocker(t)
+	defer f.Close()
+	_, _, err := r.TryLock(lock)
+	Ok(t, err)
+
+	t.Log("...succeed if the new project has a different path")
+	{
+		newLock := lock
+		newLock.Project = models.NewProject(project.RepoFullName, "different/path")
+		acquired, currLock, err := r.TryLock(newLock)
+		Ok(t, err)
+		Equals(t, true, acquired)
+		Equals(t, pullNum, currLock.Pull.Num)
+	}
+
+	t.Log("...succeed if the new project has a different workspace")
+	{
+		newLock := lock
+		newLock.Workspace = "different-workspace"
+		acquired, currLock, err := r.TryLock(newLock)
+		Ok(t, err)
+		Equals(t, true, acquired)
+		Equals(t, newLock.Workspace, currLock.Workspace)
+	}
+
+	t.Log("...not succeed if the new project only has a different pullNum")
+	{
+		newLock := lock
+		newLock.Pull.Num = lock.Pull.Num + 1
+		acquired, currLock, err := r.TryLock(newLock)
+		Ok(t, err)
+		Equals(t, false, acquired)
+		Equals(t, currLock.Pull.Num, pullNum)
+	}
+}
+
+func TestQueue(t *testing.T) {
+	t.Log("pulls that fail to get the lock should wait in line for it")
+	f, r := newTestLocker(t)
+	defer f.Close()
+	_, _, err := r.TryLock(lock)
+	Ok(t, err)
+
+	second := lock
+	second.Pull.Num = 2
+	third := lock
+	third.Pull.Num = 3
+	for _, l := range []models.ProjectLock{second, third, second} {
+		acquired, _, err := r.TryLock(l)
+		Ok(t, err)
+		Equals(t, false, acquired)
+	}
+	queue, err := r.GetQueue(project, workspace)
+	Ok(t, err)
+	Equals(t, []int{2, 3}, pullNums(queue))
+
+	t.Log("the pull holding the lock shouldn't be added to the queue")
+	_, _, err = r.TryLock(lock)
+	Ok(t, err)
+	queue, err = r.GetQueue(project, workspace)
+	Ok(t, err)
+	Equals(t, []int{2, 3}, pullNums(queue))
+
+	t.Log("releasing the lock should give it to the first pull in line")
+	unlocked, err := r.Unlock(project, workspace)
+	Ok(t, err)
+	Equals(t, 1, unlocked.Pull.Num)
+	curr, err := r.GetLock(project, workspace)
+	Ok(t, err)
+	Equals(t, 2, curr.Pull.Num)
+	acquired, _, err := r.TryLock(third)
+	Ok(t, err)
+	Equals(t, false, acquired)
+	queue, err = r.GetQueue(project, workspace)
+	Ok(t, err)
+	Equals(t, []int{3}, pullNums(queue))
+
+	t.Log("UnlockByPull should remove the pull from the queue")
+	_, err = r.UnlockByPull(project.RepoFullName, 3)
+	Ok(t, err)
+	queue, err = r.GetQueue(project, workspace)
+	Ok(t, err)
+	Equals(t, 0, len(queue))
+
+	t.Log("once nobody is waiting, releasing the lock should free it")
+	_, err = r.Unlock(project, workspace)
+	Ok(t, err)
+	curr, err = r.GetLock(project, workspace)
+	Ok(t, err)
+	Assert(t, curr == nil, "exp lock to be free")
+}
+
+func TestUnlockingNoLocks(t *testing.T) {
+	t.Log("unlocking with no locks should succeed")
+	f, r := newTestLocker(t)
+	defer f.Close()
+	l, err := r.Unlock(project, workspace)
+	Ok(t, err)
+	Assert(t, l == nil, "exp nil lock")
+}
+
+func TestUnlocking(t *testing.T) {
+	t.Log("unlocking with an existing lock should succeed and return the lock")
+	f, r := newTestLocker(t)
+	defer f.Close()
+	_, _, err := r.TryLock(lock)
+	Ok(t, err)
+
+	l, err := r.Unlock(project, workspace)
+	Ok(t, err)
+	Assert(t, l != nil, "exp lock")
+	Equals(t, lock.Pull.Num, l.Pull.Num)
+	ls, err := r.List()
+	Ok(t, err)
+	Equals(t, 0, len(ls))
+}
+
+func TestUnlockByPullMatching(t *testing.T) {
+	t.Log("UnlockByPull should delete only the pull's locks in that repo")
+	f, r := newTestLocker(t)
+	defer f.Close()
+	_, _, err := r.TryLock(lock)
+	Ok(t, err)
+
+	// A different path in the same repo.
+	samePull := lock
+	samePull.Project.Path = "dir"
+	_, _, err = r.TryLock(samePull)
+	Ok(t, err)
+
+	// A different pull in the same repo.
+	otherPull := lock
+	otherPull.Project.Path = "other"
+	otherPull.Pull.Num = 2
+	_, _, err = r.TryLock(otherPull)
+	Ok(t, err)
+
+	// A repo whose name starts with the same characters.
+	otherRepo := lock
+	otherRepo.Project.RepoFullName = "owner/repo2"
+	_, _, err = r.TryLock(otherRepo)
+	Ok(t, err)
+
+	locks, err := r.UnlockByPull(project.RepoFullName, pullNum)
+	Ok(t, err)
+	Equals(t, 2, len(locks))
+	ls, err := r.List()
+	Ok(t, err)
+	Equals(t, 2, len(ls))
+	Equals(t, "other", ls[0].Project.Path)
+	Equals(t, "owner/repo2", ls[1].Project.RepoFullName)
+}
+
+func TestGetLock(t *testing.T) {
+	t.Log("getting a lock should return it, or nil if it's not there")
+	f, r := newTestLocker(t)
+	defer f.Close()
+	l, err := r.GetLock(project, workspace)
+	Ok(t, err)
+	Assert(t, l == nil, "exp nil lock")
+
+	_, _, err = r.TryLock(lock)
+	Ok(t, err)
+	l, err = r.GetLock(project, workspace)
+	Ok(t, err)
+	// We can't compare the time directly because it's deserialized.
+	Equals(t, lock.Time.Format(time.RFC3339Nano), l.Time.Format(time.RFC3339Nano))
+	l.Time = lock.Time
+	Equals(t, lock, *l)
+}
+
+func TestTryLock_ConcurrentModification(t *testing.T) {
+	t.Log("if another instance takes the lock during our transaction we should retry and see its lock")
+	f, r := newTestLocker(t)
+	defer f.Close()
+
+	other := lock
+	other.Pull.Num = 2
+	f.beforeExec = func() {
+		f.beforeExec = nil
+		_, _, err := r2(t, f).TryLock(other)
+		Ok(t, err)
+	}
+	acquired, currLock, err := r.TryLock(lock)
+	Ok(t, err)
+	Equals(t, false, acquired)
+	Equals(t, 2, currLock.Pull.Num)
+	queue, err := r.GetQueue(project, workspace)
+	Ok(t, err)
+	Equals(t, []int{pullNum}, pullNums(queue))
+}
+
+func TestJobs(t *testing.T) {
+	f, r := newTestLocker(t)
+	defer f.Close()
+
+	first := models.Job{ID: fmt.Sprintf("%019d", time.Now().UnixNano()), Command: "plan", StartedAt: time.Now(), Output: "first"}
+	Ok(t, r.SaveJob(first))
+	second := models.Job{ID: fmt.Sprintf("%019d", time.Now().UnixNano()+1), Command: "apply", StartedAt: time.Now(), Output: "second"}
+	Ok(t, r.SaveJob(second))
+
+	job, err := r.GetJob(first.ID)
+	Ok(t, err)
+	Equals(t, first.Output, job.Output)
+	Equals(t, first.Command, job.Command)
+
+	t.Log("jobs should be listed newest first without their output")
+	list, err := r.ListJobs()
+	Ok(t, err)
+	Equals(t, 2, len(list))
+	Equals(t, second.ID, list[0].ID)
+	Equals(t, first.ID, list[1].ID)
+	Equals(t, "", list[0].Output)
+
+	t.Log("jobs should expire")
+	f.mu.Lock()
+	expiry := f.expiries[jobKey(first.ID)]
+	f.mu.Unlock()
+	Equals(t, 2, len(expiry))
+	Equals(t, "EX", expiry[0])
+	ttl, err := strconv.Atoi(expiry[1])
+	Ok(t, err)
+	Assert(t, ttl > int(jobs.Retention/time.Second)-60 && ttl <= int(jobs.Retention/time.Second), "exp the job to expire after jobs.Retention but got %ds", ttl)
+
+	job, err = r.GetJob("missing")
+	Ok(t, err)
+	Assert(t, job == nil, "exp no job")
+}
+
+func TestAuditEvents(t *testing.T) {
+	f, r := newTestLocker(t)
+	defer f.Close()
+
+	list, err := r.ListAuditEvents("", 10)
+	Ok(t, err)
+	Assert(t, len(list) == 0, "exp no events but got %v", list)
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		event := models.AuditEvent{ID: fmt.Sprintf("%019d-0", i+1), Command: "plan", PullNum: i}
+		Ok(t, r.AppendAuditEvent(event))
+		ids = append(ids, event.ID)
+	}
+
+	t.Log("events should be listed oldest first")
+	list, err = r.ListAuditEvents("", 10)
+	Ok(t, err)
+	Equals(t, 3, len(list))
+	Equals(t, ids[0], list[0].ID)
+	Equals(t, 2, list[2].PullNum)
+
+	t.Log("we should be able to page through the events")
+	list, err = r.ListAuditEvents(ids[0], 1)
+	Ok(t, err)
+	Equals(t, 1, len(list))
+	Equals(t, ids[1], list[0].ID)
+	list, err = r.ListAuditEvents(ids[2], 10)
+	Ok(t, err)
+	Assert(t, len(list) == 0, "exp no events after the last one but got %v", list)
+}
+
+func TestPlanOutputs(t *testing.T) {
+	f, r := newTestLocker(t)
+	defer f.Close()
+
+	plan := models.PlanOutput{ID: "id", RepoFullName: "owner/repo", PullNum: 1, Output: "first", CreatedAt: time.Now().Round(time.Second).UTC()}
+	Ok(t, r.SavePlanOutput(plan))
+	got, err := r.GetPlanOutput("id")
+	Ok(t, err)
+	Equals(t, "first", got.Output)
+	Equals(t, 1, got.PullNum)
+
+	t.Log("saving the plan again should replace it")
+	plan.Output = "second"
+	Ok(t, r.SavePlanOutput(plan))
+	got, err = r.GetPlanOutput("id")
+	Ok(t, err)
+	Equals(t, "second", got.Output)
+
+	got, err = r.GetPlanOutput("missing")
+	Ok(t, err)
+	Assert(t, got == nil, "exp no plan")
+
+	t.Log("plans should expire")
+	f.mu.Lock()
+	expiry := f.expiries["atlantis:plan:id"]
+	f.mu.Unlock()
+	Equals(t, 2, len(expiry))
+	ttl, err := strconv.Atoi(expiry[1])
+	Ok(t, err)
+	Assert(t, ttl > int(plans.Retention/time.Second)-60 && ttl <= int(plans.Retention/time.Second), "exp the plan to expire after plans.Retention but got %ds", ttl)
+}
+
+func TestAutoplanDisabled(t *testing.T) {
+	f, r := newTestLocker(t)
+	defer f.Close()
+
+	disabled, err := r.AutoplanDisabled("owner/repo", 1)
+	Ok(t, err)
+	Assert(t, !disabled, "exp autoplanning to be on by default")
+
+	Ok(t, r.SetAutoplanDisabled("owner/repo", 1, true))
+	t.Log("setting it twice shouldn't error")
+	Ok(t, r.SetAutoplanDisabled("owner/repo", 1, true))
+	disabled, err = r.AutoplanDisabled("owner/repo", 1)
+	Ok(t, err)
+	Assert(t, disabled, "exp autoplanning to be off")
+
+	t.Log("other pulls should still be autoplanned")
+	disabled, err = r.AutoplanDisabled("owner/repo", 2)
+	Ok(t, err)
+	Assert(t, !disabled, "exp autoplanning of other pulls to be on")
+
+	Ok(t, r.SetAutoplanDisabled("owner/repo", 1, false))
+	disabled, err = r.AutoplanDisabled("owner/repo", 1)
+	Ok(t, err)
+	Assert(t, !disabled, "exp autoplanning to be back on")
+}
+
+func TestPendingApplies(t *testing.T) {
+	f, r := newTestLocker(t)
+	defer f.Close()
+
+	got, err := r.TakePendingApply("owner/repo", 1)
+	Ok(t, err)
+	Assert(t, got == nil, "exp no pending apply")
+
+	apply := models.PendingApply{RepoFullName: "owner/repo", PullNum: 1, HeadCommit: "abc123", Username: "lkysow", Command: []byte(`{"name":0}`), CreatedAt: time.Now().Round(time.Second).UTC()}
+	Ok(t, r.SavePendingApply(apply))
+	t.Log("saving another apply for the pull should replace it")
+	apply.HeadCommit = "def456"
+	Ok(t, r.SavePendingApply(apply))
+	Ok(t, r.SavePendingApply(models.PendingApply{RepoFullName: "owner/repo", PullNum: 2}))
+
+	got, err = r.TakePendingApply("owner/repo", 1)
+	Ok(t, err)
+	Equals(t, apply, *got)
+
+	t.Log("a pending apply can only be taken once")
+	got, err = r.TakePendingApply("owner/repo", 1)
+	Ok(t, err)
+	Assert(t, got == nil, "exp pending apply to be deleted")
+	got, err = r.TakePendingApply("owner/repo", 2)
+	Ok(t, err)
+	Equals(t, 2, got.PullNum)
+}
+
+func TestWorkQueue(t *testing.T) {
+	f, r := newTestLocker(t)
+	defer f.Close()
+
+	item := func(id string, pullNum int) models.WorkItem {
+		return models.WorkItem{ID: id, Kind: models.AutoplanWorkItem, BaseRepo: models.Repo{FullName: "owner/repo"}, PullNum: pullNum}
+	}
+	claimed, err := r.ClaimWork("worker")
+	Ok(t, err)
+	Assert(t, claimed == nil, "exp nothing to claim")
+
+	Ok(t, r.EnqueueWork(item("1", 1)))
+	Ok(t, r.EnqueueWork(item("2", 1)))
+	Ok(t, r.EnqueueWork(item("3", 2)))
+
+	claimed, err = r.ClaimWork("worker")
+	Ok(t, err)
+	Equals(t, "1", claimed.ID)
+	Equals(t, "worker", claimed.ClaimedBy)
+
+	t.Log("the next item for pull 1 should wait for the first to complete")
+	claimed, err = r.ClaimWork("worker")
+	Ok(t, err)
+	Equals(t, "3", claimed.ID)
+	claimed, err = r.ClaimWork("worker")
+	Ok(t, err)
+	Assert(t, claimed == nil, "exp nothing to claim but got %v", claimed)
+
+	Ok(t, r.CompleteWork("1"))
+	claimed, err = r.ClaimWork("worker")
+	Ok(t, err)
+	Equals(t, "2", claimed.ID)
+
+	list, err := r.ListWork()
+	Ok(t, err)
+	Equals(t, 2, len(list))
+	Equals(t, "2", list[0].ID)
+	Equals(t, "3", list[1].ID)
+	Equals(t, "owner/repo", list[0].BaseRepo.FullName)
+	Equals(t, "worker", list[1].ClaimedBy)
+
+	t.Log("renewing a claim should only update it for the worker that claimed the item")
+	before := list[1].ClaimedAt
+	Ok(t, r.RenewWork("3", "other-worker"))
+	list, err = r.ListWork()
+	Ok(t, err)
+	Assert(t, list[1].ClaimedAt.Equal(before), "exp the claim to not be renewed by another worker")
+	time.Sleep(time.Millisecond)
+	Ok(t, r.RenewWork("3", "worker"))
+	list, err = r.ListWork()
+	Ok(t, err)
+	Assert(t, list[1].ClaimedAt.After(before), "exp the claim to be renewed")
+	Ok(t, r.RenewWork("missing", "worker"))
+
+	t.Log("only claims that haven't been renewed within the timeout should be dropped")
+	time.Sleep(100 * time.Millisecond)
+	Ok(t, r.RenewWork("3", "worker"))
+	dropped, err := r.DropExpiredWork(50 * time.Millisecond)
+	Ok(t, err)
+	Equals(t, 1, len(dropped))
+	Equals(t, "2", dropped[0].ID)
+	list, err = r.ListWork()
+	Ok(t, err)
+	Equals(t, 1, len(list))
+	Equals(t, "3", list[0].ID)
+
+	Ok(t, r.CompleteWork("3"))
+	list, err = r.ListWork()
+	Ok(t, err)
+	Equals(t, 0, len(list))
+}
+
+func TestApplyLock(t *testing.T) {
+	f, r := newTestLocker(t)
+	defer f.Close()
+
+	lock, err := r.GetApplyLock()
+	Ok(t, err)
+	Assert(t, lock == nil, "exp applies to not be locked")
+	Ok(t, r.UnlockApplies())
+
+	applyLock := models.ApplyLock{Time: time.Now().Round(time.Second)}
+	Ok(t, r.LockApplies(applyLock))
+	lock, err = r.GetApplyLock()
+	Ok(t, err)
+	Assert(t, lock != nil && lock.Time.Equal(applyLock.Time), "exp applies to be locked at %s but got %v", applyLock.Time, lock)
+
+	Ok(t, r.UnlockApplies())
+	lock, err = r.GetApplyLock()
+	Ok(t, err)
+	Assert(t, lock == nil, "exp applies to be unlocked")
+}
+
+func jobKey(id string) string {
+	return "atlantis:job:" + id
+}
+
+func newTestLocker(t *testing.T) (*fakeRedis, *redis.RedisLocker) {
+	f := newFakeRedis(t, "")
+	return f, r2(t, f)
+}
+
+// r2 returns a new locker connected to f, as if it were another Atlantis
+// instance.
+func r2(t *testing.T, f *fakeRedis) *redis.RedisLocker {
+	r, err := redis.New(f.Addr(), "")
+	Ok(t, err)
+	return r
+}
+
+func pullNums(locks []models.ProjectLock) []int {
+	var nums []int
+	for _, l := range locks {
+		nums = append(nums, l.Pull.Num)
+	}
+	return nums
+}
+
+// fakeRedis is an in-memory Redis server that supports the commands the
+// locker uses.
+type fakeRedis struct {
+	t        *testing.T
+	password string
+	ln       net.Listener
+	// beforeExec, if set, is called before each EXEC is run.
+	beforeExec func()
+
+	mu       sync.Mutex
+	data     map[string]string
+	versions map[string]int
+	// expiries are the expiry options, ex. EX 60, keys were last set with.
+	expiries map[string][]string
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	Ok(t, err)
+	f := &fakeRedis{
+		t:        t,
+		password: password,
+		ln:       ln,
+		data:     make(map[string]string),
+		versions: make(map[string]int),
+		expiries: make(map[string][]string),
+	}
+	go f.serve()
+	return f
+}
+
+func (f *fakeRedis) Addr() string {
+	return f.ln.Addr().String()
+}
+
+func (f *fakeRedis) Close() {
+	f.ln.Close() // nolint: errcheck
+}
+
+func (f *fakeRedis) serve() {
+	for {
+		conn, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close() // nolint: errcheck
+	rd := bufio.NewReader(conn)
+	authed := f.password == ""
+	watched := make(map[string]int)
+	var queued [][]string
+	inMulti := false
+	for {
+		args, err := readCommand(rd)
+		if err != nil {
+			if err != io.EOF {
+				f.t.Errorf("reading command: %s", err)
+			}
+			return
+		}
+		cmd := strings.ToUpper(args[0])
+		var reply string
+		switch {
+		case cmd == "AUTH":
+			if args[1] == f.password {
+				authed = true
+				reply = "+OK\r\n"
+			} else {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authed:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case cmd == "WATCH":
+			f.mu.Lock()
+			for _, k := range args[1:] {
+				watched[k] = f.versions[k]
+			}
+			f.mu.Unlock()
+			reply = "+OK\r\n"
+		case cmd == "UNWATCH":
+			watched = make(map[string]int)
+			reply = "+OK\r\n"
+		case cmd == "MULTI":
+			inMulti = true
+			reply = "+OK\r\n"
+		case cmd == "DISCARD":
+			inMulti = false
+			queued = nil
+			watched = make(map[string]int)
+			reply = "+OK\r\n"
+		case cmd == "EXEC":
+			if f.beforeExec != nil {
+				f.beforeExec()
+			}
+			f.mu.Lock()
+			aborted := false
+			for k, v := range watched {
+				if f.versions[k] != v {
+					aborted = true
+				}
+			}
+			if aborted {
+				reply = "*-1\r\n"
+			} else {
+				reply = fmt.Sprintf("*%d\r\n", len(queued))
+				for _, q := range queued {
+					reply += f.run(q)
+				}
+			}
+			f.mu.Unlock()
+			inMulti = false
+			queued = nil
+			watched = make(map[string]int)
+		case inMulti:
+			queued = append(queued, args)
+			reply = "+QUEUED\r\n"
+		default:
+			f.mu.Lock()
+			reply = f.run(args)
+			f.mu.Unlock()
+		}
+		if _, err := conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+// run must be called with mu held.
+func (f *fakeRedis) run(args []string) string {
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "GET":
+		v, ok := f.data[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return bulk(v)
+	case "SET":
+		f.data[args[1]] = args[2]
+		f.versions[args[1]]++
+		f.expiries[args[1]] = args[3:]
+		return "+OK\r\n"
+	case "DEL":
+		deleted := 0
+		for _, k := range args[1:] {
+			if _, ok := f.data[k]; ok {
+				delete(f.data, k)
+				f.versions[k]++
+				deleted++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", deleted)
+	case "SCAN":
+		// We return two keys per page to exercise the cursor.
+		cursor, _ := strconv.Atoi(args[1])
+		match := globToRegexp(args[3])
+		var keys []string
+		for k := range f.data {
+			if match.MatchString(k) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		end := cursor + 2
+		next := strconv.Itoa(end)
+		if end >= len(keys) {
+			end = len(keys)
+			next = "0"
+		}
+		reply := "*2\r\n" + bulk(next) + fmt.Sprintf("*%d\r\n", end-cursor)
+		for _, k := range keys[cursor:end] {
+			reply += bulk(k)
+		}
+		return reply
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+	}
+}
+
+func readCommand(rd *bufio.Reader) ([]string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		l, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, l+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:l])
+	}
+	return args, nil
+}
+
+func bulk(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+// globToRegexp converts the subset of Redis' glob-style patterns we use
+// (* and backslash escapes) to a regexp.
+func globToRegexp(glob string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			b.WriteString(".*")
+		case '\\':
+			i++
+			b.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
@@ -0,0 +1,147 @@
+package redis
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/workqueue"
+)
+
+// workQueueKey is the key storing the work queue, a JSON list of the items
+// oldest first. It's a single key so items can be claimed in one transaction.
+const workQueueKey = "atlantis:work-queue"
+
+// EnqueueWork adds item to the end of the queue.
+func (r *RedisLocker) EnqueueWork(item models.WorkItem) error {
+	err := r.updateWorkQueue(func(items []models.WorkItem) ([]models.WorkItem, error) {
+		return append(items, item), nil
+	})
+	return errors.Wrap(err, "enqueueing work item")
+}
+
+// ClaimWork gives worker the oldest waiting item for a pull that has no other
+// item claimed and returns it, or nil if there isn't one.
+func (r *RedisLocker) ClaimWork(worker string) (*models.WorkItem, error) {
+	var claimed *models.WorkItem
+	err := r.updateWorkQueue(func(items []models.WorkItem) ([]models.WorkItem, error) {
+		claimed = nil
+		i := workqueue.Claimable(items)
+		if i == -1 {
+			return nil, nil
+		}
+		items[i].ClaimedBy = worker
+		items[i].ClaimedAt = time.Now()
+		item := items[i]
+		claimed = &item
+		return items, nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "claiming work item")
+	}
+	return claimed, nil
+}
+
+// RenewWork sets when the item with id was claimed to now if it's still
+// claimed by worker.
+func (r *RedisLocker) RenewWork(id string, worker string) error {
+	err := r.updateWorkQueue(func(items []models.WorkItem) ([]models.WorkItem, error) {
+		for i, item := range items {
+			if item.ID == id && item.ClaimedBy == worker {
+				items[i].ClaimedAt = time.Now()
+				return items, nil
+			}
+		}
+		return nil, nil
+	})
+	return errors.Wrap(err, "renewing work item")
+}
+
+// CompleteWork deletes the item with id.
+func (r *RedisLocker) CompleteWork(id string) error {
+	err := r.updateWorkQueue(func(items []models.WorkItem) ([]models.WorkItem, error) {
+		for i, item := range items {
+			if item.ID == id {
+				return append(items[:i], items[i+1:]...), nil
+			}
+		}
+		return nil, nil
+	})
+	return errors.Wrap(err, "completing work item")
+}
+
+// DropExpiredWork deletes the items whose claims have expired and returns
+// them.
+func (r *RedisLocker) DropExpiredWork(timeout time.Duration) ([]models.WorkItem, error) {
+	var dropped []models.WorkItem
+	err := r.updateWorkQueue(func(items []models.WorkItem) ([]models.WorkItem, error) {
+		// The transaction is retried if the queue changes so start over.
+		dropped = nil
+		now := time.Now()
+		var kept []models.WorkItem
+		for _, item := range items {
+			if workqueue.ClaimExpired(item, timeout, now) {
+				dropped = append(dropped, item)
+			} else {
+				kept = append(kept, item)
+			}
+		}
+		if len(dropped) == 0 {
+			return nil, nil
+		}
+		// kept is nil if every item was dropped but nil means no change.
+		return append([]models.WorkItem{}, kept...), nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "dropping expired work items")
+	}
+	return dropped, nil
+}
+
+// ListWork returns all the items, oldest first.
+func (r *RedisLocker) ListWork() ([]models.WorkItem, error) {
+	serialized, err := r.client.Get(workQueueKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing work items")
+	}
+	return r.workItems(serialized)
+}
+
+// updateWorkQueue replaces the queue with what fn returns in a transaction.
+// If fn returns nil the queue isn't changed.
+func (r *RedisLocker) updateWorkQueue(fn func(items []models.WorkItem) ([]models.WorkItem, error)) error {
+	return r.client.Watch([]string{workQueueKey}, func(get getFunc) ([][]string, error) {
+		serialized, err := get(workQueueKey)
+		if err != nil {
+			return nil, err
+		}
+		items, err := r.workItems(serialized)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := fn(items)
+		if err != nil || updated == nil {
+			return nil, err
+		}
+		if len(updated) == 0 {
+			return [][]string{{"DEL", workQueueKey}}, nil
+		}
+		newSerialized, err := json.Marshal(updated)
+		if err != nil {
+			return nil, errors.Wrap(err, "serializing work queue")
+		}
+		return [][]string{{"SET", workQueueKey, string(newSerialized)}}, nil
+	})
+}
+
+func (r *RedisLocker) workItems(serialized []byte) ([]models.WorkItem, error) {
+	if serialized == nil {
+		return nil, nil
+	}
+	var items []models.WorkItem
+	if err := json.Unmarshal(serialized, &items); err != nil {
+		return nil, errors.Wrap(err, "deserializing work queue")
+	}
+	return items, nil
+}
@@ -18,34 +18,71 @@ import (
 	"fmt"
 	"strings"
 	"text/template"
+	"unicode/utf8"
 
 	"github.com/Masterminds/sprig"
 	"github.com/runatlantis/atlantis/server/events/models"
 )
 
 const (
-	planCommandTitle  = "Plan"
-	applyCommandTitle = "Apply"
+	planCommandTitle            = "Plan"
+	applyCommandTitle           = "Apply"
+	approvePoliciesCommandTitle = "Approve Policies"
+	importCommandTitle          = "Import"
+	stateCommandTitle           = "State"
 	// maxUnwrappedLines is the maximum number of lines the Terraform output
 	// can be before we wrap it in an expandable template.
 	maxUnwrappedLines = 12
 )
 
+// maxCommentLengths are the most bytes we put in a single comment on each VCS
+// host. Longer comments are split into multiple comments. Bitbucket Cloud
+// doesn't document its limit so it gets the same one as Bitbucket Server.
+var maxCommentLengths = map[models.VCSHostType]int{
+	models.Github:          65536,
+	models.Gitlab:          1000000,
+	models.BitbucketCloud:  32768,
+	models.BitbucketServer: 32768,
+	models.AzureDevops:     150000,
+	models.Gitea:           65536,
+}
+
 // MarkdownRenderer renders responses as markdown.
 type MarkdownRenderer struct {
 	// GitlabSupportsCommonMark is true if the version of GitLab we're
 	// using supports the CommonMark markdown format.
 	// If we're not configured with a GitLab client, this will be false.
 	GitlabSupportsCommonMark bool
+	// MaxCommentLength overrides the VCS host's comment size limit when
+	// splitting comments. If 0, the host's limit is used.
+	MaxCommentLength int
+	// ExecutableName is the name that comments start with to run commands.
+	// Defaults to DefaultExecutableName.
+	ExecutableName string
+	// DryRun is true if Atlantis is in dry-run mode. Comments are labelled
+	// as such and don't say how to apply.
+	DryRun bool
 }
 
 // CommonData is data that all responses have.
 type CommonData struct {
-	Command string
-	Verbose bool
-	Log     string
+	Command        string
+	Verbose        bool
+	Log            string
+	ExecutableName string
+	DryRun         bool
 }
 
+// planSuccessData is the data plan success templates are rendered with.
+type planSuccessData struct {
+	PlanSuccess
+	ExecutableName string
+	DryRun         bool
+}
+
+// dryRunBanner starts every comment in dry-run mode.
+const dryRunBanner = "> :test_tube: **Dry run:** Atlantis is running in dry-run mode. It plans but won't apply or change any state.\n\n"
+
 // ErrData is data about an error response.
 type ErrData struct {
 	Error string
@@ -74,15 +111,19 @@ type projectResultTmplData struct {
 // Render formats the data into a markdown string.
 // nolint: interfacer
 func (m *MarkdownRenderer) Render(res CommandResult, cmdName CommandName, log string, verbose bool, vcsHost models.VCSHostType) string {
-	commandStr := strings.Title(cmdName.String())
-	common := CommonData{commandStr, verbose, log}
+	commandStr := cmdName.TitleString()
+	common := CommonData{commandStr, verbose, log, executableName(m.ExecutableName), m.DryRun}
+	banner := ""
+	if m.DryRun {
+		banner = dryRunBanner
+	}
 	if res.Error != nil {
-		return m.renderTemplate(unwrappedErrWithLogTmpl, ErrData{res.Error.Error(), common})
+		return banner + m.renderTemplate(unwrappedErrWithLogTmpl, ErrData{res.Error.Error(), common})
 	}
 	if res.Failure != "" {
-		return m.renderTemplate(failureWithLogTmpl, FailureData{res.Failure, common})
+		return banner + m.renderTemplate(failureWithLogTmpl, FailureData{res.Failure, common})
 	}
-	return m.renderProjectResults(res.ProjectResults, common, vcsHost)
+	return banner + m.renderProjectResults(res.ProjectResults, common, vcsHost)
 }
 
 func (m *MarkdownRenderer) renderProjectResults(results []ProjectResult, common CommonData, vcsHost models.VCSHostType) string {
@@ -116,12 +157,29 @@ func (m *MarkdownRenderer) renderProjectResults(results []ProjectResult, common
 				Failure: result.Failure,
 			})
 		} else if result.PlanSuccess != nil {
-			if m.shouldUseWrappedTmpl(vcsHost, result.PlanSuccess.TerraformOutput) {
-				resultData.Rendered = m.renderTemplate(planSuccessWrappedTmpl, *result.PlanSuccess)
+			// When there's a summary, it's all reviewers need at first so
+			// the output is collapsed however short it is.
+			if m.shouldUseWrappedTmpl(vcsHost, result.PlanSuccess.TerraformOutput) ||
+				(len(result.PlanSuccess.ResourceSummaries) > 0 && m.supportsWrapping(vcsHost)) {
+				resultData.Rendered = m.renderTemplate(planSuccessWrappedTmpl, planSuccessData{*result.PlanSuccess, common.ExecutableName, common.DryRun})
 			} else {
-				resultData.Rendered = m.renderTemplate(planSuccessUnwrappedTmpl, *result.PlanSuccess)
+				resultData.Rendered = m.renderTemplate(planSuccessUnwrappedTmpl, planSuccessData{*result.PlanSuccess, common.ExecutableName, common.DryRun})
 			}
 			numPlanSuccesses++
+		} else if result.ImportSuccess != nil {
+			if m.shouldUseWrappedTmpl(vcsHost, result.ImportSuccess.TerraformOutput) {
+				resultData.Rendered = m.renderTemplate(stateChangedWrappedTmpl, *result.ImportSuccess)
+			} else {
+				resultData.Rendered = m.renderTemplate(stateChangedUnwrappedTmpl, *result.ImportSuccess)
+			}
+		} else if result.StateSuccess != nil {
+			if m.shouldUseWrappedTmpl(vcsHost, result.StateSuccess.TerraformOutput) {
+				resultData.Rendered = m.renderTemplate(stateChangedWrappedTmpl, *result.StateSuccess)
+			} else {
+				resultData.Rendered = m.renderTemplate(stateChangedUnwrappedTmpl, *result.StateSuccess)
+			}
+		} else if result.PoliciesApproved {
+			resultData.Rendered = "Approved the failed policy checks so this plan can now be applied."
 		} else if result.ApplySuccess != "" {
 			if m.shouldUseWrappedTmpl(vcsHost, result.ApplySuccess) {
 				resultData.Rendered = m.renderTemplate(applyWrappedSuccessTmpl, struct{ Output string }{result.ApplySuccess})
@@ -132,6 +190,13 @@ func (m *MarkdownRenderer) renderProjectResults(results []ProjectResult, common
 		} else {
 			resultData.Rendered = "Found no template. This is a bug!"
 		}
+		if result.TFLog != "" {
+			tmpl := tfLogUnwrappedTmpl
+			if m.shouldUseWrappedTmpl(vcsHost, result.TFLog) {
+				tmpl = tfLogWrappedTmpl
+			}
+			resultData.Rendered += m.renderTemplate(tmpl, struct{ TFLog string }{result.TFLog})
+		}
 		resultsTmplData = append(resultsTmplData, resultData)
 	}
 
@@ -141,11 +206,11 @@ func (m *MarkdownRenderer) renderProjectResults(results []ProjectResult, common
 		tmpl = singleProjectPlanSuccessTmpl
 	case len(resultsTmplData) == 1 && common.Command == planCommandTitle && numPlanSuccesses == 0:
 		tmpl = singleProjectPlanUnsuccessfulTmpl
-	case len(resultsTmplData) == 1 && common.Command == applyCommandTitle:
+	case len(resultsTmplData) == 1 && (common.Command == applyCommandTitle || common.Command == approvePoliciesCommandTitle || common.Command == importCommandTitle || common.Command == stateCommandTitle):
 		tmpl = singleProjectApplyTmpl
 	case common.Command == planCommandTitle:
 		tmpl = multiProjectPlanTmpl
-	case common.Command == applyCommandTitle:
+	case common.Command == applyCommandTitle || common.Command == approvePoliciesCommandTitle:
 		tmpl = multiProjectApplyTmpl
 	default:
 		return "no template matched–this is a bug"
@@ -158,16 +223,137 @@ func (m *MarkdownRenderer) renderProjectResults(results []ProjectResult, common
 // load. Some VCS providers or versions of VCS providers don't support this
 // syntax.
 func (m *MarkdownRenderer) shouldUseWrappedTmpl(vcsHost models.VCSHostType, output string) bool {
+	return m.supportsWrapping(vcsHost) && strings.Count(output, "\n") > maxUnwrappedLines
+}
+
+// supportsWrapping returns true if vcsHost supports the folding markdown
+// syntax.
+func (m *MarkdownRenderer) supportsWrapping(vcsHost models.VCSHostType) bool {
 	// Bitbucket Cloud and Server don't support the folding markdown syntax.
 	if vcsHost == models.BitbucketServer || vcsHost == models.BitbucketCloud {
 		return false
 	}
+	return vcsHost != models.Gitlab || m.GitlabSupportsCommonMark
+}
 
-	if vcsHost == models.Gitlab && !m.GitlabSupportsCommonMark {
-		return false
+// SplitComment splits comment into numbered comments that each fit in
+// vcsHost's comment size limit. It splits between lines where it can. Code
+// blocks and collapsible sections that are cut are closed at the end of one
+// comment and reopened at the start of the next so each comment renders on
+// its own.
+func (m *MarkdownRenderer) SplitComment(comment string, vcsHost models.VCSHostType) []string {
+	maxLen := m.MaxCommentLength
+	if maxLen == 0 {
+		maxLen = maxCommentLengths[vcsHost]
+	}
+	if maxLen == 0 || len(comment) <= maxLen {
+		return []string{comment}
 	}
 
-	return strings.Count(output, "\n") > maxUnwrappedLines
+	// Leave room for the part numbers added once we know how many parts
+	// there are.
+	budget := maxLen - len(commentPartHeader(999, 999)) - len(commentPartFooter(999, 999))
+	var parts []string
+	var part strings.Builder
+	// fence and details are the lines that opened the code block and the
+	// collapsible section we're in, if any.
+	var fence, details string
+	reopen := ""
+	for _, line := range strings.SplitAfter(comment, "\n") {
+		for line != "" {
+			closeStr := commentCloser(fence, details)
+			room := budget - part.Len() - len(closeStr)
+			if len(line) <= room {
+				part.WriteString(line)
+				fence, details = commentBlocksAfter(line, fence, details)
+				line = ""
+				continue
+			}
+			// If the line doesn't fit even in a new part, cut it.
+			if part.Len() == len(reopen) {
+				cut := room - 1
+				if cut < 1 {
+					cut = 1
+				}
+				for cut > 1 && !utf8.RuneStart(line[cut]) {
+					cut--
+				}
+				part.WriteString(line[:cut] + "\n")
+				line = line[cut:]
+			}
+			parts = append(parts, part.String()+closeStr)
+			reopen = commentOpener(fence, details)
+			part.Reset()
+			part.WriteString(reopen)
+		}
+	}
+	if part.Len() > len(reopen) {
+		parts = append(parts, part.String())
+	}
+
+	for i := range parts {
+		if i > 0 {
+			parts[i] = commentPartHeader(i+1, len(parts)) + parts[i]
+		}
+		if i < len(parts)-1 {
+			parts[i] += commentPartFooter(i+2, len(parts))
+		}
+	}
+	return parts
+}
+
+// commentBlocksAfter returns the code block and collapsible section that are
+// open after line given those that were open before it.
+func commentBlocksAfter(line string, fence string, details string) (string, string) {
+	trimmed := strings.TrimSpace(line)
+	switch {
+	case strings.HasPrefix(trimmed, "```"):
+		if fence == "" {
+			return trimmed, details
+		}
+		return "", details
+	case fence != "":
+		return fence, details
+	case strings.HasPrefix(trimmed, "<details>"):
+		return fence, trimmed
+	case strings.Contains(trimmed, "</details>"):
+		return fence, ""
+	}
+	return fence, details
+}
+
+// commentCloser closes the code block and collapsible section at the end of a
+// part of a split comment.
+func commentCloser(fence string, details string) string {
+	var s string
+	if fence != "" {
+		s += "```\n"
+	}
+	if details != "" {
+		s += "</details>\n"
+	}
+	return s
+}
+
+// commentOpener reopens the code block and collapsible section that were
+// closed at the end of the previous part of a split comment.
+func commentOpener(fence string, details string) string {
+	var s string
+	if details != "" {
+		s += details + "\n\n"
+	}
+	if fence != "" {
+		s += fence + "\n"
+	}
+	return s
+}
+
+func commentPartHeader(part int, total int) string {
+	return fmt.Sprintf("%s (part %d of %d).\n\n", continuedCommentPrefix, part, total)
+}
+
+func commentPartFooter(nextPart int, total int) string {
+	return fmt.Sprintf("\n**Warning**: Output length greater than max comment size. Continued in next comment (part %d of %d).", nextPart, total)
 }
 
 func (m *MarkdownRenderer) renderTemplate(tmpl *template.Template, data interface{}) string {
@@ -185,8 +371,8 @@ var singleProjectPlanSuccessTmpl = template.Must(template.New("").Parse(
 	"{{$result := index .Results 0}}Ran {{.Command}} for {{ if $result.ProjectName }}project: `{{$result.ProjectName}}` {{ end }}dir: `{{$result.RepoRelDir}}` workspace: `{{$result.Workspace}}`\n\n{{$result.Rendered}}\n" +
 		"\n" +
 		"---\n" +
-		"* :fast_forward: To **apply** all unapplied plans from this pull request, comment:\n" +
-		"    * `atlantis apply`" + logTmpl))
+		"{{ if not .DryRun }}* :fast_forward: To **apply** all unapplied plans from this pull request, comment:\n" +
+		"    * `{{.ExecutableName}} apply`{{ end }}" + logTmpl))
 var singleProjectPlanUnsuccessfulTmpl = template.Must(template.New("").Parse(
 	"{{$result := index .Results 0}}Ran {{.Command}} for dir: `{{$result.RepoRelDir}}` workspace: `{{$result.Workspace}}`\n\n" +
 		"{{$result.Rendered}}\n" + logTmpl))
@@ -198,8 +384,8 @@ var multiProjectPlanTmpl = template.Must(template.New("").Funcs(sprig.TxtFuncMap
 		"{{ range $i, $result := .Results }}" +
 		"### {{add $i 1}}. {{ if $result.ProjectName }}project: `{{$result.ProjectName}}` {{ end }}dir: `{{$result.RepoRelDir}}` workspace: `{{$result.Workspace}}`\n" +
 		"{{$result.Rendered}}\n\n" +
-		"---\n{{end}}{{ if gt (len .Results) 0 }}* :fast_forward: To **apply** all unapplied plans from this pull request, comment:\n" +
-		"    * `atlantis apply`{{end}}" +
+		"---\n{{end}}{{ if and (gt (len .Results) 0) (not .DryRun) }}* :fast_forward: To **apply** all unapplied plans from this pull request, comment:\n" +
+		"    * `{{.ExecutableName}} apply`{{end}}" +
 		logTmpl))
 var multiProjectApplyTmpl = template.Must(template.New("").Funcs(sprig.TxtFuncMap()).Parse(
 	"Ran {{.Command}} for {{ len .Results }} projects:\n" +
@@ -212,22 +398,71 @@ var multiProjectApplyTmpl = template.Must(template.New("").Funcs(sprig.TxtFuncMa
 		"---\n{{end}}" +
 		logTmpl))
 var planSuccessUnwrappedTmpl = template.Must(template.New("").Parse(
-	"```diff\n" +
+	destroyWarning +
+		resourceSummaries +
+		costEstimate +
+		terraformCloud +
+		"```diff\n" +
 		"{{.TerraformOutput}}\n" +
-		"```\n\n" + planNextSteps))
+		"```\n\n" + policyCheck + planNextSteps))
 var planSuccessWrappedTmpl = template.Must(template.New("").Parse(
-	"<details><summary>Show Output</summary>\n\n" +
+	destroyWarning +
+		resourceSummaries +
+		costEstimate +
+		terraformCloud +
+		"<details><summary>Show Output</summary>\n\n" +
 		"```diff\n" +
 		"{{.TerraformOutput}}\n" +
 		"```\n\n" +
+		policyCheck +
 		planNextSteps + "\n" +
 		"</details>"))
 
+// destroyWarning is shown before destroy plans, and before plans that
+// destroy more resources than the destroy threshold.
+var destroyWarning = "{{ if .Destroy }}**:bangbang: Destroy plan: applying this plan will destroy all of this project's resources.** " +
+	"Destroy plans may have stricter apply requirements.\n\n" +
+	"{{ else if .DestroyThresholdExceeded }}**:warning: Warning: this plan will destroy {{.DestroyCount}} resource{{ if ne .DestroyCount 1 }}s{{ end }}.**\n\n{{ end }}"
+
+// resourceSummaries is a table of how many resources of each type the plan
+// will add, change and destroy so big plans can be reviewed at a glance.
+var resourceSummaries = "{{ if .ResourceSummaries }}| Resource Type | Add | Change | Destroy |\n" +
+	"|---|--:|--:|--:|\n" +
+	"{{ range .ResourceSummaries }}| `{{.Type}}` | {{.Add}} | {{.Change}} | {{.Destroy}} |\n{{ end }}\n{{ end }}"
+
+// costEstimate is a table of how the plan changes the monthly cost of each
+// resource whose cost changes, estimated by Infracost.
+var costEstimate = "{{ with .CostEstimate }}**Monthly Cost Estimate:** {{ printf \"%.2f\" .PastMonthlyCost }} → {{ printf \"%.2f\" .MonthlyCost }} {{.Currency}} " +
+	"({{ printf \"%+.2f\" .DiffMonthlyCost }})\n" +
+	"{{ if .ResourceDiffs }}| Resource | Monthly Cost Change |\n" +
+	"|---|--:|\n" +
+	"{{ range .ResourceDiffs }}| `{{.Address}}` | {{ printf \"%+.2f\" .DiffMonthlyCost }} |\n{{ end }}{{ end }}\n{{ end }}"
+
+// terraformCloud links the Terraform Cloud workspace the project is mapped to
+// and the cost estimate and Sentinel policy checks of its run.
+var terraformCloud = "{{ with .TerraformCloud }}**Terraform Cloud:** workspace [`{{.Organization}}/{{.Workspace}}`]({{.WorkspaceURL}})" +
+	"{{ if .RunURL }}, [run]({{.RunURL}}){{ end }}\n" +
+	"{{ with .CostEstimate }}{{ if eq .Status \"finished\" }}* :moneybag: Estimated cost: ${{.ProposedMonthlyCost}}/month (change: ${{.DeltaMonthlyCost}}/month)\n" +
+	"{{ else }}* :moneybag: Cost estimate {{.Status}}\n{{ end }}{{ end }}" +
+	"{{ range .PolicyChecks }}* {{ if eq .Status \"passed\" }}:white_check_mark:{{ else }}:x:{{ end }} Sentinel policy check {{.Status}}: " +
+	"{{.Passed}} passed, {{.AdvisoryFailed}} advisory failed, {{.SoftFailed}} soft failed, {{.HardFailed}} hard failed\n{{ end }}\n{{ end }}"
+
+// policyCheck shows the output of checking the plan against the repo's
+// policy sets, if it has any.
+var policyCheck = "{{ if .PolicyCheckOutput }}**Policy Check {{ if .PolicyCheckFailed }}Failed{{ else }}Passed{{ end }}**\n" +
+	"```\n" +
+	"{{.PolicyCheckOutput}}\n" +
+	"```\n\n" +
+	"{{ if .PolicyCheckFailed }}* :no_entry: This plan can't be applied until a policy owner approves it by commenting:\n" +
+	"    * `{{.ExecutableName}} approve_policies`\n" +
+	"{{ end }}{{ end }}"
+
 // planNextSteps are instructions appended after successful plans as to what
 // to do next.
-var planNextSteps = "* :arrow_forward: To **apply** this plan, comment:\n" +
-	"    * `{{.ApplyCmd}}`\n" +
+var planNextSteps = "{{ if not .DryRun }}* :arrow_forward: To **apply** this plan, comment:\n" +
+	"    * `{{.ApplyCmd}}`\n{{ end }}" +
 	"* :put_litter_in_its_place: To **delete** this plan click [here]({{.LockURL}})\n" +
+	"{{ if .PlanURL }}* :page_facing_up: To **view** the full plan click [here]({{.PlanURL}})\n{{ end }}" +
 	"* :repeat: To **plan** this project again, comment:\n" +
 	"    * `{{.RePlanCmd}}`"
 var applyUnwrappedSuccessTmpl = template.Must(template.New("").Parse(
@@ -240,6 +475,33 @@ var applyWrappedSuccessTmpl = template.Must(template.New("").Parse(
 		"{{.Output}}\n" +
 		"```\n" +
 		"</details>"))
+var stateChangedUnwrappedTmpl = template.Must(template.New("").Parse(
+	"```diff\n" +
+		"{{.TerraformOutput}}\n" +
+		"```\n\n" + stateChangedNextSteps))
+var stateChangedWrappedTmpl = template.Must(template.New("").Parse(
+	"<details><summary>Show Output</summary>\n\n" +
+		"```diff\n" +
+		"{{.TerraformOutput}}\n" +
+		"```\n" +
+		"</details>\n\n" + stateChangedNextSteps))
+
+// stateChangedNextSteps are instructions appended after successful imports
+// and state commands since the project's plan was discarded.
+var stateChangedNextSteps = ":put_litter_in_its_place: Any plan for this project was discarded since it's now out of date.\n\n" +
+	"* :repeat: To **plan** this project again, comment:\n" +
+	"    * `{{.RePlanCmd}}`"
+var tfLogUnwrappedTmpl = template.Must(template.New("").Parse(
+	"\n\n**Terraform Log**\n" +
+		"```\n" +
+		"{{.TFLog}}\n" +
+		"```"))
+var tfLogWrappedTmpl = template.Must(template.New("").Parse(
+	"\n\n<details><summary>Show Terraform Log</summary>\n\n" +
+		"```\n" +
+		"{{.TFLog}}\n" +
+		"```\n" +
+		"</details>"))
 var unwrappedErrTmplText = "**{{.Command}} Error**\n" +
 	"```\n" +
 	"{{.Error}}\n" +
@@ -18,9 +18,12 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/runatlantis/atlantis/server/events"
 	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	"github.com/runatlantis/atlantis/server/events/terraform"
 	. "github.com/runatlantis/atlantis/testing"
 )
 
@@ -156,6 +159,41 @@ $$$
 * :repeat: To **plan** this project again, comment:
     * $atlantis plan -d path -w workspace$
 
+---
+* :fast_forward: To **apply** all unapplied plans from this pull request, comment:
+    * $atlantis apply$
+`,
+		},
+		{
+			"single successful plan with plan url",
+			events.PlanCommand,
+			[]events.ProjectResult{
+				{
+					PlanSuccess: &events.PlanSuccess{
+						TerraformOutput: "terraform-output",
+						LockURL:         "lock-url",
+						PlanURL:         "plan-url",
+						RePlanCmd:       "atlantis plan -d path -w workspace",
+						ApplyCmd:        "atlantis apply -d path -w workspace",
+					},
+					Workspace:  "workspace",
+					RepoRelDir: "path",
+				},
+			},
+			models.Github,
+			`Ran Plan for dir: $path$ workspace: $workspace$
+
+$$$diff
+terraform-output
+$$$
+
+* :arrow_forward: To **apply** this plan, comment:
+    * $atlantis apply -d path -w workspace$
+* :put_litter_in_its_place: To **delete** this plan click [here](lock-url)
+* :page_facing_up: To **view** the full plan click [here](plan-url)
+* :repeat: To **plan** this project again, comment:
+    * $atlantis plan -d path -w workspace$
+
 ---
 * :fast_forward: To **apply** all unapplied plans from this pull request, comment:
     * $atlantis apply$
@@ -931,3 +969,457 @@ $$$
 	expWithBackticks := strings.Replace(exp, "$", "`", -1)
 	Equals(t, expWithBackticks, rendered)
 }
+
+// Test that the terraform log is rendered after the output in its own
+// section.
+func TestRenderProjectResults_TFLog(t *testing.T) {
+	mr := events.MarkdownRenderer{}
+	tfLog := strings.Repeat("[DEBUG] line\n", 13)
+	rendered := mr.Render(events.CommandResult{
+		ProjectResults: []events.ProjectResult{
+			{
+				RepoRelDir: ".",
+				Workspace:  "default",
+				Error:      errors.New("error"),
+				TFLog:      tfLog,
+			},
+		},
+	}, events.PlanCommand, "log", false, models.Github)
+	exp := `Ran Plan for dir: $.$ workspace: $default$
+
+**Plan Error**
+$$$
+error
+$$$
+
+<details><summary>Show Terraform Log</summary>
+
+$$$
+` + tfLog + `
+$$$
+</details>
+
+`
+	expWithBackticks := strings.Replace(exp, "$", "`", -1)
+	Equals(t, expWithBackticks, rendered)
+
+	t.Log("on Bitbucket the log isn't wrapped")
+	rendered = mr.Render(events.CommandResult{
+		ProjectResults: []events.ProjectResult{
+			{
+				RepoRelDir: ".",
+				Workspace:  "default",
+				Error:      errors.New("error"),
+				TFLog:      "[DEBUG] line",
+			},
+		},
+	}, events.PlanCommand, "log", false, models.BitbucketCloud)
+	Assert(t, strings.Contains(rendered, "**Terraform Log**\n```\n[DEBUG] line\n```"), "exp unwrapped log, got %q", rendered)
+}
+
+func TestRenderProjectResults_DestroyWarning(t *testing.T) {
+	mr := events.MarkdownRenderer{}
+	rendered := mr.Render(events.CommandResult{
+		ProjectResults: []events.ProjectResult{
+			{
+				RepoRelDir: ".",
+				Workspace:  "default",
+				PlanSuccess: &events.PlanSuccess{
+					TerraformOutput:          "terraform-output",
+					LockURL:                  "lock-url",
+					RePlanCmd:                "atlantis plan -d .",
+					ApplyCmd:                 "atlantis apply -d .",
+					DestroyCount:             3,
+					DestroyThresholdExceeded: true,
+				},
+			},
+		},
+	}, events.PlanCommand, "log", false, models.Github)
+	Assert(t, strings.Contains(rendered, "**:warning: Warning: this plan will destroy 3 resources.**\n\n```diff\nterraform-output"), "exp destroy warning, got %q", rendered)
+
+	t.Log("no warning if the threshold wasn't exceeded")
+	rendered = mr.Render(events.CommandResult{
+		ProjectResults: []events.ProjectResult{
+			{
+				RepoRelDir: ".",
+				Workspace:  "default",
+				PlanSuccess: &events.PlanSuccess{
+					TerraformOutput: "terraform-output",
+					DestroyCount:    3,
+				},
+			},
+		},
+	}, events.PlanCommand, "log", false, models.Github)
+	Assert(t, !strings.Contains(rendered, "Warning"), "exp no destroy warning, got %q", rendered)
+
+	t.Log("destroy plans are always marked")
+	rendered = mr.Render(events.CommandResult{
+		ProjectResults: []events.ProjectResult{
+			{
+				RepoRelDir: ".",
+				Workspace:  "default",
+				PlanSuccess: &events.PlanSuccess{
+					TerraformOutput:          "terraform-output",
+					DestroyCount:             3,
+					DestroyThresholdExceeded: true,
+					Destroy:                  true,
+				},
+			},
+		},
+	}, events.PlanCommand, "log", false, models.Github)
+	Assert(t, strings.Contains(rendered, "**:bangbang: Destroy plan: applying this plan will destroy all of this project's resources.** Destroy plans may have stricter apply requirements.\n\n```diff\nterraform-output"), "exp destroy plan warning, got %q", rendered)
+	Assert(t, !strings.Contains(rendered, "Warning"), "exp only the destroy plan warning, got %q", rendered)
+}
+
+func TestRenderProjectResults_ResourceSummaries(t *testing.T) {
+	result := events.CommandResult{
+		ProjectResults: []events.ProjectResult{
+			{
+				RepoRelDir: ".",
+				Workspace:  "default",
+				PlanSuccess: &events.PlanSuccess{
+					TerraformOutput: "terraform-output",
+					LockURL:         "lock-url",
+					RePlanCmd:       "atlantis plan -d .",
+					ApplyCmd:        "atlantis apply -d .",
+					ResourceSummaries: []runtime.ResourceTypeSummary{
+						{Type: "aws_instance", PlanSummary: runtime.PlanSummary{Add: 2, Destroy: 1}},
+						{Type: "null_resource", PlanSummary: runtime.PlanSummary{Change: 1}},
+					},
+				},
+			},
+		},
+	}
+	expTable := "| Resource Type | Add | Change | Destroy |\n" +
+		"|---|--:|--:|--:|\n" +
+		"| `aws_instance` | 2 | 0 | 1 |\n" +
+		"| `null_resource` | 0 | 1 | 0 |\n\n"
+
+	mr := events.MarkdownRenderer{}
+	rendered := mr.Render(result, events.PlanCommand, "log", false, models.Github)
+	Assert(t, strings.Contains(rendered, expTable+"<details><summary>Show Output</summary>\n\n```diff\nterraform-output"), "exp summary table and wrapped output, got %q", rendered)
+
+	t.Log("the output isn't wrapped if the host can't fold it")
+	rendered = mr.Render(result, events.PlanCommand, "log", false, models.BitbucketCloud)
+	Assert(t, strings.Contains(rendered, expTable+"```diff\nterraform-output"), "exp summary table and unwrapped output, got %q", rendered)
+}
+
+func TestRenderProjectResults_CostEstimate(t *testing.T) {
+	result := events.CommandResult{
+		ProjectResults: []events.ProjectResult{
+			{
+				RepoRelDir: ".",
+				Workspace:  "default",
+				PlanSuccess: &events.PlanSuccess{
+					TerraformOutput: "terraform-output",
+					LockURL:         "lock-url",
+					RePlanCmd:       "atlantis plan -d .",
+					ApplyCmd:        "atlantis apply -d .",
+					CostEstimate: &runtime.CostEstimate{
+						Currency:        "USD",
+						PastMonthlyCost: 100,
+						MonthlyCost:     130.5,
+						DiffMonthlyCost: 30.5,
+						ResourceDiffs: []runtime.ResourceCostDiff{
+							{Address: "aws_instance.web", DiffMonthlyCost: 50.5},
+							{Address: "aws_instance.old", DiffMonthlyCost: -20},
+						},
+					},
+				},
+			},
+		},
+	}
+	exp := "**Monthly Cost Estimate:** 100.00 → 130.50 USD (+30.50)\n" +
+		"| Resource | Monthly Cost Change |\n" +
+		"|---|--:|\n" +
+		"| `aws_instance.web` | +50.50 |\n" +
+		"| `aws_instance.old` | -20.00 |\n\n" +
+		"```diff\nterraform-output"
+
+	mr := events.MarkdownRenderer{}
+	rendered := mr.Render(result, events.PlanCommand, "log", false, models.BitbucketCloud)
+	Assert(t, strings.Contains(rendered, exp), "exp cost estimate table, got %q", rendered)
+}
+
+func TestRenderProjectResults_TerraformCloud(t *testing.T) {
+	result := events.CommandResult{
+		ProjectResults: []events.ProjectResult{
+			{
+				RepoRelDir: ".",
+				Workspace:  "default",
+				PlanSuccess: &events.PlanSuccess{
+					TerraformOutput: "terraform-output",
+					LockURL:         "lock-url",
+					RePlanCmd:       "atlantis plan -d .",
+					ApplyCmd:        "atlantis apply -d .",
+					TerraformCloud: &events.TerraformCloudSummary{
+						Organization: "org",
+						Workspace:    "network",
+						WorkspaceURL: "https://app.terraform.io/app/org/workspaces/network",
+						RunURL:       "https://app.terraform.io/app/org/network/runs/run-abc",
+						CostEstimate: &terraform.CloudCostEstimate{Status: "finished", ProposedMonthlyCost: "35.5", DeltaMonthlyCost: "25.5"},
+						PolicyChecks: []terraform.CloudPolicyCheck{{Status: "soft_failed", Passed: 2, SoftFailed: 1}},
+					},
+				},
+			},
+		},
+	}
+	exp := "**Terraform Cloud:** workspace [`org/network`](https://app.terraform.io/app/org/workspaces/network), [run](https://app.terraform.io/app/org/network/runs/run-abc)\n" +
+		"* :moneybag: Estimated cost: $35.5/month (change: $25.5/month)\n" +
+		"* :x: Sentinel policy check soft_failed: 2 passed, 0 advisory failed, 1 soft failed, 0 hard failed\n\n" +
+		"```diff\nterraform-output"
+
+	mr := events.MarkdownRenderer{}
+	rendered := mr.Render(result, events.PlanCommand, "log", false, models.BitbucketCloud)
+	Assert(t, strings.Contains(rendered, exp), "exp terraform cloud summary, got %q", rendered)
+
+	t.Log("only the workspace is linked if the plan didn't run there")
+	result.ProjectResults[0].PlanSuccess.TerraformCloud = &events.TerraformCloudSummary{
+		Organization: "org",
+		Workspace:    "network",
+		WorkspaceURL: "https://app.terraform.io/app/org/workspaces/network",
+	}
+	rendered = mr.Render(result, events.PlanCommand, "log", false, models.BitbucketCloud)
+	Assert(t, strings.Contains(rendered, "**Terraform Cloud:** workspace [`org/network`](https://app.terraform.io/app/org/workspaces/network)\n\n```diff"), "exp workspace link, got %q", rendered)
+}
+
+func TestRenderProjectResults_PolicyCheck(t *testing.T) {
+	mr := events.MarkdownRenderer{}
+	rendered := mr.Render(events.CommandResult{
+		ProjectResults: []events.ProjectResult{
+			{
+				RepoRelDir: ".",
+				Workspace:  "default",
+				PlanSuccess: &events.PlanSuccess{
+					TerraformOutput:   "terraform-output",
+					LockURL:           "lock-url",
+					RePlanCmd:         "atlantis plan -d .",
+					ApplyCmd:          "atlantis apply -d .",
+					PolicyCheckOutput: "policy set: security\nFAIL",
+					PolicyCheckFailed: true,
+				},
+			},
+		},
+	}, events.PlanCommand, "log", false, models.Github)
+	exp := "```\n\n**Policy Check Failed**\n```\npolicy set: security\nFAIL\n```\n\n" +
+		"* :no_entry: This plan can't be applied until a policy owner approves it by commenting:\n" +
+		"    * `atlantis approve_policies`\n" +
+		"* :arrow_forward: To **apply** this plan"
+	Assert(t, strings.Contains(rendered, exp), "exp failed policy check, got %q", rendered)
+
+	t.Log("passing checks don't need approving")
+	rendered = mr.Render(events.CommandResult{
+		ProjectResults: []events.ProjectResult{
+			{
+				RepoRelDir: ".",
+				Workspace:  "default",
+				PlanSuccess: &events.PlanSuccess{
+					TerraformOutput:   "terraform-output",
+					PolicyCheckOutput: "policy set: security\nPASS",
+				},
+			},
+		},
+	}, events.PlanCommand, "log", false, models.Github)
+	Assert(t, strings.Contains(rendered, "**Policy Check Passed**\n```\npolicy set: security\nPASS\n```\n\n* :arrow_forward:"), "exp passed policy check, got %q", rendered)
+	Assert(t, !strings.Contains(rendered, "approve_policies"), "exp no approve instructions, got %q", rendered)
+
+	t.Log("no policy check section if there are no policy sets")
+	rendered = mr.Render(events.CommandResult{
+		ProjectResults: []events.ProjectResult{
+			{
+				RepoRelDir:  ".",
+				Workspace:   "default",
+				PlanSuccess: &events.PlanSuccess{TerraformOutput: "terraform-output"},
+			},
+		},
+	}, events.PlanCommand, "log", false, models.Github)
+	Assert(t, !strings.Contains(rendered, "Policy Check"), "exp no policy check, got %q", rendered)
+}
+
+func TestRenderProjectResults_ExecutableName(t *testing.T) {
+	mr := events.MarkdownRenderer{ExecutableName: "atlantis-prod"}
+	rendered := mr.Render(events.CommandResult{
+		ProjectResults: []events.ProjectResult{
+			{
+				RepoRelDir: "dir",
+				Workspace:  "default",
+				PlanSuccess: &events.PlanSuccess{
+					TerraformOutput: "terraform-output",
+					LockURL:         "lock-url",
+					RePlanCmd:       "atlantis-prod plan -d dir",
+					ApplyCmd:        "atlantis-prod apply -d dir",
+				},
+			},
+			{
+				RepoRelDir: "dir2",
+				Workspace:  "default",
+				PlanSuccess: &events.PlanSuccess{
+					TerraformOutput: "terraform-output2",
+					LockURL:         "lock-url2",
+					RePlanCmd:       "atlantis-prod plan -d dir2",
+					ApplyCmd:        "atlantis-prod apply -d dir2",
+				},
+			},
+		},
+	}, events.PlanCommand, "log", false, models.Github)
+	Assert(t, strings.Contains(rendered, "`atlantis-prod apply`"), "exp the executable name in the apply all instructions, got %q", rendered)
+	Assert(t, !strings.Contains(rendered, "`atlantis apply`"), "exp no atlantis apply instructions, got %q", rendered)
+}
+
+func TestRenderProjectResults_DryRun(t *testing.T) {
+	mr := events.MarkdownRenderer{DryRun: true}
+	rendered := mr.Render(events.CommandResult{
+		ProjectResults: []events.ProjectResult{
+			{
+				RepoRelDir: "dir",
+				Workspace:  "default",
+				PlanSuccess: &events.PlanSuccess{
+					TerraformOutput: "terraform-output",
+					LockURL:         "lock-url",
+					RePlanCmd:       "atlantis plan -d dir",
+					ApplyCmd:        "atlantis apply -d dir",
+				},
+			},
+		},
+	}, events.PlanCommand, "log", false, models.Github)
+	exp := `> :test_tube: **Dry run:** Atlantis is running in dry-run mode. It plans but won't apply or change any state.
+
+Ran Plan for dir: $dir$ workspace: $default$
+
+$$$diff
+terraform-output
+$$$
+
+* :put_litter_in_its_place: To **delete** this plan click [here](lock-url)
+* :repeat: To **plan** this project again, comment:
+    * $atlantis plan -d dir$
+
+---
+
+`
+	Equals(t, strings.Replace(exp, "$", "`", -1), rendered)
+
+	rendered = mr.Render(events.CommandResult{Error: errors.New("error")}, events.PlanCommand, "log", false, models.Github)
+	Assert(t, strings.HasPrefix(rendered, "> :test_tube: **Dry run:**"), "exp errors to be labelled, got %q", rendered)
+}
+
+func TestRenderProjectResults_ApprovePolicies(t *testing.T) {
+	mr := events.MarkdownRenderer{}
+	rendered := mr.Render(events.CommandResult{
+		ProjectResults: []events.ProjectResult{
+			{
+				RepoRelDir:       ".",
+				Workspace:        "default",
+				PoliciesApproved: true,
+			},
+		},
+	}, events.ApprovePoliciesCommand, "log", false, models.Github)
+	exp := "Ran Approve Policies for dir: `.` workspace: `default`\n\nApproved the failed policy checks so this plan can now be applied.\n\n"
+	Equals(t, exp, rendered)
+}
+
+func TestRenderProjectResults_Import(t *testing.T) {
+	mr := events.MarkdownRenderer{}
+	rendered := mr.Render(events.CommandResult{
+		ProjectResults: []events.ProjectResult{
+			{
+				RepoRelDir: ".",
+				Workspace:  "default",
+				ImportSuccess: &events.ImportSuccess{
+					TerraformOutput: "Import successful!",
+					RePlanCmd:       "atlantis plan -d .",
+				},
+			},
+		},
+	}, events.ImportCommand, "log", false, models.Github)
+	exp := "Ran Import for dir: `.` workspace: `default`\n\n```diff\nImport successful!\n```\n\n:put_litter_in_its_place: Any plan for this project was discarded since it's now out of date.\n\n* :repeat: To **plan** this project again, comment:\n    * `atlantis plan -d .`\n\n"
+	Equals(t, exp, rendered)
+}
+
+func TestRenderProjectResults_State(t *testing.T) {
+	mr := events.MarkdownRenderer{}
+	rendered := mr.Render(events.CommandResult{
+		ProjectResults: []events.ProjectResult{
+			{
+				RepoRelDir: ".",
+				Workspace:  "default",
+				StateSuccess: &events.StateSuccess{
+					TerraformOutput: "Successfully removed 1 resource instance(s).",
+					RePlanCmd:       "atlantis plan -d .",
+				},
+			},
+		},
+	}, events.StateCommand, "log", false, models.Github)
+	exp := "Ran State for dir: `.` workspace: `default`\n\n```diff\nSuccessfully removed 1 resource instance(s).\n```\n\n:put_litter_in_its_place: Any plan for this project was discarded since it's now out of date.\n\n* :repeat: To **plan** this project again, comment:\n    * `atlantis plan -d .`\n\n"
+	Equals(t, exp, rendered)
+}
+
+func TestSplitComment_UnderLimit(t *testing.T) {
+	r := events.MarkdownRenderer{}
+	comment := strings.Repeat("a", 65536)
+	Equals(t, []string{comment}, r.SplitComment(comment, models.Github))
+	// GitLab allows much longer comments than GitHub.
+	comment = strings.Repeat("a", 100000)
+	Equals(t, []string{comment}, r.SplitComment(comment, models.Gitlab))
+}
+
+func TestSplitComment_Plan(t *testing.T) {
+	var output []string
+	for i := 0; i < 100; i++ {
+		output = append(output, fmt.Sprintf("+ resource %d", i))
+	}
+	r := events.MarkdownRenderer{MaxCommentLength: 500}
+	comment := r.Render(events.CommandResult{
+		ProjectResults: []events.ProjectResult{
+			{
+				Workspace:  "workspace",
+				RepoRelDir: "path",
+				PlanSuccess: &events.PlanSuccess{
+					TerraformOutput: strings.Join(output, "\n"),
+					LockURL:         "lock-url",
+					RePlanCmd:       "atlantis plan -d path -w workspace",
+					ApplyCmd:        "atlantis apply -d path -w workspace",
+				},
+			},
+		},
+	}, events.PlanCommand, "log", false, models.Github)
+	parts := r.SplitComment(comment, models.Github)
+	Assert(t, len(parts) > 1, "exp comment to be split")
+	Assert(t, strings.HasPrefix(parts[0], "Ran Plan for dir: `path` workspace: `workspace`"), "exp first part to start with the header, got %q", parts[0])
+
+	var rest string
+	for i, part := range parts {
+		Assert(t, len(part) <= 500, "exp part %d to fit but it was %d long", i+1, len(part))
+		Equals(t, strings.Count(part, "<details>"), strings.Count(part, "</details>"))
+		Equals(t, 0, strings.Count(part, "```")%2)
+		if i > 0 {
+			Assert(t, strings.HasPrefix(part, fmt.Sprintf("Continued from previous comment (part %d of %d).\n\n<details><summary>Show Output</summary>\n\n", i+1, len(parts))),
+				"exp part %d to reopen the output, got %q", i+1, part)
+		}
+		if i < len(parts)-1 {
+			Assert(t, strings.HasSuffix(part, fmt.Sprintf("Continued in next comment (part %d of %d).", i+2, len(parts))),
+				"exp part %d to say where it's continued, got %q", i+1, part)
+		}
+		rest += part
+	}
+	// None of the output should be lost.
+	for _, line := range output {
+		Assert(t, strings.Contains(rest, line+"\n"), "exp %q in a part", line)
+	}
+	Assert(t, strings.Contains(rest, "* :arrow_forward: To **apply** this plan"), "exp next steps in a part")
+}
+
+func TestSplitComment_LongLine(t *testing.T) {
+	r := events.MarkdownRenderer{MaxCommentLength: 400}
+	line := strings.Repeat("é", 500)
+	comment := "```\n" + line + "\n```"
+	parts := r.SplitComment(comment, models.Github)
+	Assert(t, len(parts) > 1, "exp comment to be split")
+	var joined string
+	for i, part := range parts {
+		Assert(t, len(part) <= 400, "exp part %d to fit but it was %d long", i+1, len(part))
+		Assert(t, utf8.ValidString(part), "exp part %d to not cut a character", i+1)
+		joined += part
+	}
+	Equals(t, len(line), strings.Count(joined, "é")*len("é"))
+}
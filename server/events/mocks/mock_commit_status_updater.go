@@ -49,6 +49,36 @@ func (mock *MockCommitStatusUpdater) UpdateProjectResult(ctx *events.CommandCont
 	return ret0
 }
 
+func (mock *MockCommitStatusUpdater) UpdatePlanRequired(repo models.Repo, pull models.PullRequest) error {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockCommitStatusUpdater().")
+	}
+	params := []pegomock.Param{repo, pull}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("UpdatePlanRequired", params, []reflect.Type{reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(error)
+		}
+	}
+	return ret0
+}
+
+func (mock *MockCommitStatusUpdater) UpdatePullClosed(repo models.Repo, pull models.PullRequest) error {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockCommitStatusUpdater().")
+	}
+	params := []pegomock.Param{repo, pull}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("UpdatePullClosed", params, []reflect.Type{reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(error)
+		}
+	}
+	return ret0
+}
+
 func (mock *MockCommitStatusUpdater) VerifyWasCalledOnce() *VerifierCommitStatusUpdater {
 	return &VerifierCommitStatusUpdater{
 		mock:                   mock,
@@ -159,3 +189,65 @@ func (c *CommitStatusUpdater_UpdateProjectResult_OngoingVerification) GetAllCapt
 	}
 	return
 }
+
+func (verifier *VerifierCommitStatusUpdater) UpdatePlanRequired(repo models.Repo, pull models.PullRequest) *CommitStatusUpdater_UpdatePlanRequired_OngoingVerification {
+	params := []pegomock.Param{repo, pull}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "UpdatePlanRequired", params, verifier.timeout)
+	return &CommitStatusUpdater_UpdatePlanRequired_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type CommitStatusUpdater_UpdatePlanRequired_OngoingVerification struct {
+	mock              *MockCommitStatusUpdater
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *CommitStatusUpdater_UpdatePlanRequired_OngoingVerification) GetCapturedArguments() (models.Repo, models.PullRequest) {
+	repo, pull := c.GetAllCapturedArguments()
+	return repo[len(repo)-1], pull[len(pull)-1]
+}
+
+func (c *CommitStatusUpdater_UpdatePlanRequired_OngoingVerification) GetAllCapturedArguments() (_param0 []models.Repo, _param1 []models.PullRequest) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]models.Repo, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(models.Repo)
+		}
+		_param1 = make([]models.PullRequest, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(models.PullRequest)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierCommitStatusUpdater) UpdatePullClosed(repo models.Repo, pull models.PullRequest) *CommitStatusUpdater_UpdatePullClosed_OngoingVerification {
+	params := []pegomock.Param{repo, pull}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "UpdatePullClosed", params, verifier.timeout)
+	return &CommitStatusUpdater_UpdatePullClosed_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type CommitStatusUpdater_UpdatePullClosed_OngoingVerification struct {
+	mock              *MockCommitStatusUpdater
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *CommitStatusUpdater_UpdatePullClosed_OngoingVerification) GetCapturedArguments() (models.Repo, models.PullRequest) {
+	repo, pull := c.GetAllCapturedArguments()
+	return repo[len(repo)-1], pull[len(pull)-1]
+}
+
+func (c *CommitStatusUpdater_UpdatePullClosed_OngoingVerification) GetAllCapturedArguments() (_param0 []models.Repo, _param1 []models.PullRequest) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]models.Repo, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(models.Repo)
+		}
+		_param1 = make([]models.PullRequest, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(models.PullRequest)
+		}
+	}
+	return
+}
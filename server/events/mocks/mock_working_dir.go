@@ -106,6 +106,21 @@ func (mock *MockWorkingDir) DeleteForWorkspace(r models.Repo, p models.PullReque
 	return ret0
 }
 
+func (mock *MockWorkingDir) CheckoutDirs(log *logging.SimpleLogger, repoDir string, dirs []string) error {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockWorkingDir().")
+	}
+	params := []pegomock.Param{log, repoDir, dirs}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("CheckoutDirs", params, []reflect.Type{reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(error)
+		}
+	}
+	return ret0
+}
+
 func (mock *MockWorkingDir) VerifyWasCalledOnce() *VerifierWorkingDir {
 	return &VerifierWorkingDir{
 		mock:                   mock,
@@ -317,3 +332,38 @@ func (c *WorkingDir_DeleteForWorkspace_OngoingVerification) GetAllCapturedArgume
 	}
 	return
 }
+
+func (verifier *VerifierWorkingDir) CheckoutDirs(log *logging.SimpleLogger, repoDir string, dirs []string) *WorkingDir_CheckoutDirs_OngoingVerification {
+	params := []pegomock.Param{log, repoDir, dirs}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "CheckoutDirs", params, verifier.timeout)
+	return &WorkingDir_CheckoutDirs_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type WorkingDir_CheckoutDirs_OngoingVerification struct {
+	mock              *MockWorkingDir
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *WorkingDir_CheckoutDirs_OngoingVerification) GetCapturedArguments() (*logging.SimpleLogger, string, []string) {
+	log, repoDir, dirs := c.GetAllCapturedArguments()
+	return log[len(log)-1], repoDir[len(repoDir)-1], dirs[len(dirs)-1]
+}
+
+func (c *WorkingDir_CheckoutDirs_OngoingVerification) GetAllCapturedArguments() (_param0 []*logging.SimpleLogger, _param1 []string, _param2 [][]string) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]*logging.SimpleLogger, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(*logging.SimpleLogger)
+		}
+		_param1 = make([]string, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(string)
+		}
+		_param2 = make([][]string, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.([]string)
+		}
+	}
+	return
+}
@@ -36,6 +36,25 @@ func (mock *MockWorkingDirLocker) TryLock(repoFullName string, pullNum int, work
 	return ret0, ret1
 }
 
+func (mock *MockWorkingDirLocker) TryLockProject(repoFullName string, pullNum int, workspace string, path string) (func(), error) {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockWorkingDirLocker().")
+	}
+	params := []pegomock.Param{repoFullName, pullNum, workspace, path}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("TryLockProject", params, []reflect.Type{reflect.TypeOf((*func())(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 func()
+	var ret1 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(func())
+		}
+		if result[1] != nil {
+			ret1 = result[1].(error)
+		}
+	}
+	return ret0, ret1
+}
+
 func (mock *MockWorkingDirLocker) TryLockPull(repoFullName string, pullNum int) (func(), error) {
 	if mock == nil {
 		panic("mock must not be nil. Use myMock := NewMockWorkingDirLocker().")
@@ -127,6 +146,45 @@ func (c *WorkingDirLocker_TryLock_OngoingVerification) GetAllCapturedArguments()
 	return
 }
 
+func (verifier *VerifierWorkingDirLocker) TryLockProject(repoFullName string, pullNum int, workspace string, path string) *WorkingDirLocker_TryLockProject_OngoingVerification {
+	params := []pegomock.Param{repoFullName, pullNum, workspace, path}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "TryLockProject", params, verifier.timeout)
+	return &WorkingDirLocker_TryLockProject_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type WorkingDirLocker_TryLockProject_OngoingVerification struct {
+	mock              *MockWorkingDirLocker
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *WorkingDirLocker_TryLockProject_OngoingVerification) GetCapturedArguments() (string, int, string, string) {
+	repoFullName, pullNum, workspace, path := c.GetAllCapturedArguments()
+	return repoFullName[len(repoFullName)-1], pullNum[len(pullNum)-1], workspace[len(workspace)-1], path[len(path)-1]
+}
+
+func (c *WorkingDirLocker_TryLockProject_OngoingVerification) GetAllCapturedArguments() (_param0 []string, _param1 []int, _param2 []string, _param3 []string) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]string, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(string)
+		}
+		_param1 = make([]int, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(int)
+		}
+		_param2 = make([]string, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(string)
+		}
+		_param3 = make([]string, len(params[3]))
+		for u, param := range params[3] {
+			_param3[u] = param.(string)
+		}
+	}
+	return
+}
+
 func (verifier *VerifierWorkingDirLocker) TryLockPull(repoFullName string, pullNum int) *WorkingDirLocker_TryLockPull_OngoingVerification {
 	params := []pegomock.Param{repoFullName, pullNum}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "TryLockPull", params, verifier.timeout)
@@ -17,6 +17,8 @@
 package models
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/url"
 	paths "path"
@@ -64,7 +66,8 @@ func NewRepo(vcsHostType VCSHostType, repoFullName string, cloneURL string, vcsU
 		return Repo{}, errors.New("cloneURL can't be empty")
 	}
 
-	if !strings.HasSuffix(cloneURL, ".git") {
+	// Azure DevOps clone urls don't end in .git.
+	if !strings.HasSuffix(cloneURL, ".git") && vcsHostType != AzureDevops {
 		cloneURL += ".git"
 	}
 
@@ -76,9 +79,15 @@ func NewRepo(vcsHostType VCSHostType, repoFullName string, cloneURL string, vcsU
 	// Ensure the Clone URL is for the same repo to avoid something malicious.
 	// We skip this check for Bitbucket Server because its format is different
 	// and because the caller in that case actually constructs the clone url
-	// from the repo name and so there's no point checking if they match.
-	if vcsHostType != BitbucketServer {
+	// from the repo name and so there's no point checking if they match. The
+	// same goes for Azure DevOps whose clone urls contain /_git/.
+	// Gitea can be served from a sub path so its clone urls only need to end
+	// with the repo.
+	if vcsHostType != BitbucketServer && vcsHostType != AzureDevops {
 		expClonePath := fmt.Sprintf("/%s.git", repoFullName)
+		if vcsHostType == Gitea && strings.HasSuffix(cloneURLParsed.Path, expClonePath) {
+			expClonePath = cloneURLParsed.Path
+		}
 		if expClonePath != cloneURLParsed.Path {
 			return Repo{}, fmt.Errorf("expected clone url to have path %q but had %q", expClonePath, cloneURLParsed.Path)
 		}
@@ -100,9 +109,10 @@ func NewRepo(vcsHostType VCSHostType, repoFullName string, cloneURL string, vcsU
 	if owner == "" || repo == "" {
 		return Repo{}, fmt.Errorf("invalid repo format %q, owner %q or repo %q was empty", repoFullName, owner, repo)
 	}
-	// Only GitLab repos can have /'s in their owners. This is for GitLab
-	// subgroups.
-	if strings.Contains(owner, "/") && vcsHostType != Gitlab {
+	// Only GitLab and Azure DevOps repos can have /'s in their owners. This is
+	// for GitLab subgroups and for Azure DevOps where the owner is
+	// {organization}/{project}.
+	if strings.Contains(owner, "/") && vcsHostType != Gitlab && vcsHostType != AzureDevops {
 		return Repo{}, fmt.Errorf("invalid repo format %q, owner %q should not contain any /'s", repoFullName, owner)
 	}
 	if strings.Contains(repo, "/") {
@@ -137,6 +147,9 @@ type PullRequest struct {
 	URL string
 	// Branch is the name of the head branch (not the base).
 	Branch string
+	// BaseBranch is the name of the branch the pull request will be merged
+	// into. It's empty if the VCS host didn't send it.
+	BaseBranch string
 	// Author is the username of the pull request author.
 	Author string
 	// State will be one of Open or Closed.
@@ -161,6 +174,9 @@ const (
 	UpdatedPullEvent
 	ClosedPullEvent
 	OtherPullEvent
+	// ReadyForReviewPullEvent is when a draft pull request is marked as
+	// ready for review.
+	ReadyForReviewPullEvent
 )
 
 func (p PullRequestEventType) String() string {
@@ -173,6 +189,8 @@ func (p PullRequestEventType) String() string {
 		return "closed"
 	case OtherPullEvent:
 		return "other"
+	case ReadyForReviewPullEvent:
+		return "ready_for_review"
 	}
 	return "<missing String() implementation>"
 }
@@ -259,6 +277,8 @@ const (
 	Gitlab
 	BitbucketCloud
 	BitbucketServer
+	AzureDevops
+	Gitea
 )
 
 func (h VCSHostType) String() string {
@@ -271,6 +291,10 @@ func (h VCSHostType) String() string {
 		return "BitbucketCloud"
 	case BitbucketServer:
 		return "BitbucketServer"
+	case AzureDevops:
+		return "AzureDevops"
+	case Gitea:
+		return "Gitea"
 	}
 	return "<missing String() implementation>"
 }
@@ -281,10 +305,31 @@ type ProjectCommandContext struct {
 	ApplyCmd string
 	// BaseRepo is the repository that the pull request will be merged into.
 	BaseRepo Repo
+	// CancelCtx is cancelled when someone runs atlantis cancel on the pull
+	// request. Steps should stop what they're running when it's done. If nil,
+	// the command can't be cancelled.
+	CancelCtx context.Context
 	// CommentArgs are the extra arguments appended to comment,
 	// ex. atlantis plan -- -target=resource
-	CommentArgs  []string
+	CommentArgs []string
+	// Destroy is true if the plan should destroy all the project's
+	// resources, ex. atlantis plan -destroy.
+	Destroy      bool
 	GlobalConfig *valid.Config
+	// Targets are the resource addresses the plan targets with -target. If
+	// empty, the plan isn't targeted.
+	Targets []string
+	// ImportAddress and ImportID are the address and provider ID of the
+	// resource to import. Only set for import commands.
+	ImportAddress string
+	ImportID      string
+	// StateSubcommand and StateAddresses are the terraform state subcommand,
+	// rm or mv, and the addresses to run it on. Only set for state commands.
+	StateSubcommand string
+	StateAddresses  []string
+	// JobID is the ID of the job tracking this command's output for the UI.
+	// If empty, the command isn't being tracked.
+	JobID string
 	// HeadRepo is the repository that is getting merged into the BaseRepo.
 	// If the pull request branch is from the same repository then HeadRepo will
 	// be the same as BaseRepo.
@@ -293,11 +338,23 @@ type ProjectCommandContext struct {
 	Log           *logging.SimpleLogger
 	Pull          PullRequest
 	ProjectConfig *valid.Project
+	// PolicySets are the policies the plan is checked against after
+	// planning. If empty, no policy checks are run.
+	PolicySets []valid.PolicySet
+	// DisableCostEstimation is true if the repo opted out of having its plans'
+	// costs estimated.
+	DisableCostEstimation bool
 	// RePlanCmd is the command that users should run to re-plan this project.
 	// If this is an apply then this will be empty.
 	RePlanCmd        string
 	RepoRelDir       string
 	TerraformVersion *version.Version
+	// Terragrunt is true if the project is run with the built-in terragrunt
+	// workflow rather than the default terraform one.
+	Terragrunt bool
+	// TFLogLevel is the TF_LOG level to run plan with. If empty, TF_LOG won't
+	// be set.
+	TFLogLevel string
 	// User is the user that triggered this command.
 	User User
 	// Verbose is true when the user would like verbose output.
@@ -305,6 +362,27 @@ type ProjectCommandContext struct {
 	Workspace string
 }
 
+// WorkflowHookCommandContext defines the context for a pre or post-workflow
+// hook.
+type WorkflowHookCommandContext struct {
+	BaseRepo Repo
+	HeadRepo Repo
+	Pull     PullRequest
+	// User is the user that triggered the command the hook is run around.
+	User User
+	Log  *logging.SimpleLogger
+	// CommandName is the name of the command the hook is run around, ex.
+	// plan.
+	CommandName string
+	// CommandHasErrors is true if the command had errors. Only set for
+	// post-workflow hooks.
+	CommandHasErrors bool
+	// CancelCtx is cancelled when someone runs atlantis cancel on the pull
+	// request, in which case the hook is stopped. Only set for pre-workflow
+	// hooks. If nil, the hook can't be cancelled.
+	CancelCtx context.Context
+}
+
 // SplitRepoFullName splits a repo full name up into its owner and repo name
 // segments. If the repoFullName is malformed, may return empty strings
 // for owner or repo.
@@ -326,3 +404,167 @@ func (p *ProjectCommandContext) GetProjectName() string {
 	}
 	return ""
 }
+
+// JobStatus is the status of a Job.
+type JobStatus int
+
+const (
+	RunningJobStatus JobStatus = iota
+	SucceededJobStatus
+	FailedJobStatus
+)
+
+func (s JobStatus) String() string {
+	switch s {
+	case RunningJobStatus:
+		return "running"
+	case SucceededJobStatus:
+		return "succeeded"
+	case FailedJobStatus:
+		return "failed"
+	}
+	return "<missing String() implementation>"
+}
+
+// Job is a plan or apply that Atlantis ran for a project in a pull request.
+type Job struct {
+	// ID uniquely identifies the job. IDs sort in the order the jobs started.
+	ID string
+	// Command is the command that was run, ex. "plan".
+	Command      string
+	RepoFullName string
+	PullNum      int
+	PullURL      string
+	RepoRelDir   string
+	Workspace    string
+	ProjectName  string
+	// User is the username of who ran the command.
+	User string
+	// VCSHost and HeadCommit are the pull request's so that if Atlantis
+	// stops while the job is running we can update the pull request.
+	VCSHost    VCSHost
+	HeadCommit string
+	// Instance is the hostname of the Atlantis instance running the job.
+	Instance  string
+	Status    JobStatus
+	StartedAt time.Time
+	// FinishedAt is zero while the job is running.
+	FinishedAt time.Time
+	// Output is what the job has output so far.
+	Output string
+}
+
+// Duration returns how long the job ran for, or has been running for if it
+// hasn't finished.
+func (j Job) Duration() time.Duration {
+	if j.FinishedAt.IsZero() {
+		return time.Since(j.StartedAt)
+	}
+	return j.FinishedAt.Sub(j.StartedAt)
+}
+
+// AuditEvent records a command that was run, for auditing. Unlike jobs, audit
+// events are never updated or deleted.
+type AuditEvent struct {
+	// ID uniquely identifies the event. IDs sort in the order the events
+	// started.
+	ID string `json:"id"`
+	// Command is the command that was run, ex. "apply".
+	Command string `json:"command"`
+	// User is the username of who ran the command.
+	User         string `json:"user"`
+	RepoFullName string `json:"repo"`
+	// PullNum is 0 if the command wasn't run for a pull request, ex. by the
+	// API.
+	PullNum    int    `json:"pull_num"`
+	HeadCommit string `json:"head_commit,omitempty"`
+	// RepoRelDir, Workspace and ProjectName are empty for commands that
+	// aren't run for a single project, ex. unlock.
+	RepoRelDir  string `json:"dir,omitempty"`
+	Workspace   string `json:"workspace,omitempty"`
+	ProjectName string `json:"project_name,omitempty"`
+	// ExtraArgs are the args the comment passed to terraform after --, ex.
+	// -var-file=staging.tfvars.
+	ExtraArgs []string `json:"extra_args,omitempty"`
+	Success   bool     `json:"success"`
+	// Error is why the command failed.
+	Error     string    `json:"error,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	// DurationMS is how long the command ran for in milliseconds.
+	DurationMS int64 `json:"duration_ms"`
+}
+
+// PlanOutput is the output of a successful plan, saved so it can be viewed in
+// full in the UI even after the comment it was posted in is deleted.
+type PlanOutput struct {
+	// ID is derived from the pull, commit and project that were planned, see
+	// plans.ID, so planning the same commit again replaces the output.
+	ID           string    `json:"id"`
+	RepoFullName string    `json:"repo"`
+	PullNum      int       `json:"pull_num"`
+	PullURL      string    `json:"pull_url"`
+	HeadCommit   string    `json:"head_commit"`
+	RepoRelDir   string    `json:"dir"`
+	Workspace    string    `json:"workspace"`
+	ProjectName  string    `json:"project_name,omitempty"`
+	User         string    `json:"user"`
+	Output       string    `json:"output"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ApplyLock is the global lock that stops all applies, ex. during an incident
+// or a change freeze.
+type ApplyLock struct {
+	// Time is when applies were locked.
+	Time time.Time
+}
+
+// WorkItem kinds.
+const (
+	// AutoplanWorkItem autoplans a pull request.
+	AutoplanWorkItem = "autoplan"
+	// CommentWorkItem runs the command in a comment.
+	CommentWorkItem = "comment"
+)
+
+// WorkItem is a command from a webhook that's waiting in the work queue for a
+// worker to run it.
+type WorkItem struct {
+	// ID sorts in the order the items were enqueued.
+	ID string `json:"id"`
+	// Kind is AutoplanWorkItem or CommentWorkItem.
+	Kind     string       `json:"kind"`
+	BaseRepo Repo         `json:"base_repo"`
+	HeadRepo *Repo        `json:"head_repo,omitempty"`
+	Pull     *PullRequest `json:"pull,omitempty"`
+	User     User         `json:"user"`
+	PullNum  int          `json:"pull_num"`
+	// Command is the serialized comment command of CommentWorkItems.
+	Command    json.RawMessage `json:"command,omitempty"`
+	EnqueuedAt time.Time       `json:"enqueued_at"`
+	// ClaimedBy is the worker running the item. It's empty while the item is
+	// waiting.
+	ClaimedBy string    `json:"claimed_by,omitempty"`
+	ClaimedAt time.Time `json:"claimed_at,omitempty"`
+}
+
+// PullKey identifies the pull request the item is for. Only one item per pull
+// request is run at a time.
+func (w WorkItem) PullKey() string {
+	return fmt.Sprintf("%s/%s/%d", w.BaseRepo.VCSHost.Hostname, w.BaseRepo.FullName, w.PullNum)
+}
+
+// PendingApply is an apply that's waiting to be confirmed with atlantis
+// confirm because Atlantis requires applies to be confirmed.
+type PendingApply struct {
+	RepoFullName string `json:"repo_full_name"`
+	PullNum      int    `json:"pull_num"`
+	// HeadCommit is the commit the apply was requested for. If commits are
+	// pushed after it, the apply can't be confirmed.
+	HeadCommit string `json:"head_commit"`
+	// Username is who commented atlantis apply.
+	Username string `json:"username"`
+	// Command is the serialized apply comment command.
+	Command   json.RawMessage `json:"command"`
+	CreatedAt time.Time       `json:"created_at"`
+}
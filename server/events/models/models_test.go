@@ -59,6 +59,42 @@ func TestNewRepo_CloneURLBitbucketServer(t *testing.T) {
 	}, repo)
 }
 
+func TestNewRepo_CloneURLAzureDevops(t *testing.T) {
+	// Azure DevOps clone urls don't end in .git and the owner is the
+	// organization and project.
+	repo, err := models.NewRepo(models.AzureDevops, "org/project/repo", "https://dev.azure.com/org/project/_git/repo", "u", "p")
+	Ok(t, err)
+	Equals(t, models.Repo{
+		FullName:          "org/project/repo",
+		Owner:             "org/project",
+		Name:              "repo",
+		CloneURL:          "https://u:p@dev.azure.com/org/project/_git/repo",
+		SanitizedCloneURL: "https://dev.azure.com/org/project/_git/repo",
+		VCSHost: models.VCSHost{
+			Hostname: "dev.azure.com",
+			Type:     models.AzureDevops,
+		},
+	}, repo)
+}
+
+func TestNewRepo_CloneURLGiteaSubPath(t *testing.T) {
+	// Gitea can be served from a sub-path so the clone url path has a prefix
+	// before the repo's full name.
+	repo, err := models.NewRepo(models.Gitea, "owner/repo", "https://example.com/gitea/owner/repo.git", "u", "p")
+	Ok(t, err)
+	Equals(t, models.Repo{
+		FullName:          "owner/repo",
+		Owner:             "owner",
+		Name:              "repo",
+		CloneURL:          "https://u:p@example.com/gitea/owner/repo.git",
+		SanitizedCloneURL: "https://example.com/gitea/owner/repo.git",
+		VCSHost: models.VCSHost{
+			Hostname: "example.com",
+			Type:     models.Gitea,
+		},
+	}, repo)
+}
+
 func TestNewRepo_FullNameWrongFormat(t *testing.T) {
 	cases := []struct {
 		repoFullName string
@@ -195,6 +231,14 @@ func TestVCSHostType_ToString(t *testing.T) {
 			models.BitbucketServer,
 			"BitbucketServer",
 		},
+		{
+			models.AzureDevops,
+			"AzureDevops",
+		},
+		{
+			models.Gitea,
+			"Gitea",
+		},
 	}
 
 	for _, c := range cases {
@@ -0,0 +1,113 @@
+package events
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// moduleSourceRegex matches the source of a module that's called with a
+// local path, ex. source = "../modules/vpc" in a .tf file or
+// "source": "../modules/vpc" in a .tf.json file. Sources from registries or
+// git aren't in the repo so they're ignored.
+var moduleSourceRegex = regexp.MustCompile(`"?source"?\s*[=:]\s*"(\.\.?/[^"]*)"`)
+
+// ModuleGraph maps the local modules in a repo to the dirs that call them so
+// we know which projects to plan when a shared module is modified.
+type ModuleGraph struct {
+	// callers maps the dir of each module, relative to the repo root, to the
+	// dirs that call it.
+	callers map[string][]string
+}
+
+// BuildModuleGraph parses the module sources of every dir in repoDir that
+// has Terraform files.
+func BuildModuleGraph(repoDir string) (*ModuleGraph, error) {
+	g := &ModuleGraph{callers: make(map[string][]string)}
+	err := filepath.Walk(repoDir, func(absPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" || info.Name() == ".terraform" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(info.Name(), ".tf") && !strings.HasSuffix(info.Name(), ".tf.json") {
+			return nil
+		}
+		contents, err := ioutil.ReadFile(absPath)
+		if err != nil {
+			return err
+		}
+		relDir, err := filepath.Rel(repoDir, filepath.Dir(absPath))
+		if err != nil {
+			return err
+		}
+		for _, moduleDir := range parseModuleSources(contents, relDir) {
+			g.addCaller(moduleDir, relDir)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing module sources")
+	}
+	return g, nil
+}
+
+// parseModuleSources returns the dirs, relative to the repo root, of the
+// local modules called by contents, the contents of a file in relDir.
+// Modules outside the repo are skipped.
+func parseModuleSources(contents []byte, relDir string) []string {
+	var dirs []string
+	for _, match := range moduleSourceRegex.FindAllSubmatch(contents, -1) {
+		moduleDir := filepath.Join(relDir, string(match[1]))
+		if moduleDir == ".." || strings.HasPrefix(moduleDir, "../") {
+			continue
+		}
+		dirs = append(dirs, moduleDir)
+	}
+	return dirs
+}
+
+func (g *ModuleGraph) addCaller(moduleDir string, caller string) {
+	for _, c := range g.callers[moduleDir] {
+		if c == caller {
+			return
+		}
+	}
+	g.callers[moduleDir] = append(g.callers[moduleDir], caller)
+}
+
+// DependentProjects returns the dirs of the projects that call a module in one
+// of dirs, either directly or through other modules. A dir is a project if it
+// isn't called as a module itself. The dirs are relative to the repo root and
+// are sorted.
+func (g *ModuleGraph) DependentProjects(dirs []string) []string {
+	seen := make(map[string]bool)
+	var projects []string
+	queue := append([]string{}, dirs...)
+	for len(queue) > 0 {
+		dir := filepath.Clean(queue[0])
+		queue = queue[1:]
+		for _, caller := range g.callers[dir] {
+			if seen[caller] {
+				continue
+			}
+			seen[caller] = true
+			if _, isModule := g.callers[caller]; isModule {
+				queue = append(queue, caller)
+			} else {
+				projects = append(projects, caller)
+			}
+		}
+	}
+	sort.Strings(projects)
+	return projects
+}
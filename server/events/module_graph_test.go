@@ -0,0 +1,70 @@
+package events_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+// moduleRepo creates a repo where project1 calls modules/app which calls
+// modules/vpc, project2 calls modules/vpc directly, and project3 only calls
+// modules from outside the repo.
+func moduleRepo(t *testing.T) (string, func()) {
+	repoDir, cleanup := TempDir(t)
+	files := map[string]string{
+		"modules/vpc/main.tf": `resource "aws_vpc" "vpc" {}`,
+		"modules/app/main.tf": `
+module "vpc" {
+  source = "../vpc"
+}`,
+		"project1/main.tf": `
+module "app" {
+  source = "../modules/app"
+}`,
+		"project2/main.tf.json": `{"module": {"vpc": {"source": "./../modules/vpc"}}}`,
+		"project3/main.tf": `
+module "consul" {
+  source  = "hashicorp/consul/aws"
+}
+module "outside" {
+  source = "../../outside"
+}`,
+		".terraform/modules/app/main.tf": `module "vpc" { source = "../../../modules/vpc" }`,
+	}
+	for name, contents := range files {
+		Ok(t, os.MkdirAll(filepath.Join(repoDir, filepath.Dir(name)), 0700))
+		Ok(t, ioutil.WriteFile(filepath.Join(repoDir, name), []byte(contents), 0600))
+	}
+	return repoDir, cleanup
+}
+
+func TestModuleGraph_DependentProjects(t *testing.T) {
+	repoDir, cleanup := moduleRepo(t)
+	defer cleanup()
+	graph, err := events.BuildModuleGraph(repoDir)
+	Ok(t, err)
+
+	cases := []struct {
+		dirs []string
+		exp  []string
+	}{
+		{[]string{"modules/vpc"}, []string{"project1", "project2"}},
+		{[]string{"modules/app"}, []string{"project1"}},
+		{[]string{"modules/app", "modules/vpc/"}, []string{"project1", "project2"}},
+		{[]string{"project1"}, nil},
+		{[]string{"modules"}, nil},
+		{nil, nil},
+	}
+	for _, c := range cases {
+		Equals(t, c.exp, graph.DependentProjects(c.dirs))
+	}
+}
+
+func TestBuildModuleGraph_ErrIfRepoDoesNotExist(t *testing.T) {
+	_, err := events.BuildModuleGraph("/does/not/exist")
+	ErrContains(t, "parsing module sources", err)
+}
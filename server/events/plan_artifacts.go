@@ -0,0 +1,120 @@
+package events
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/artifacts"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/runtime"
+)
+
+// PlanArtifacts exports each project's plan file, its JSON and the markers
+// apply checks, ex. for destroy plans, to Store. They're restored from there
+// when an apply or approve_policies comment is handled by an Atlantis replica
+// that doesn't have the pull request's clone.
+type PlanArtifacts struct {
+	Store      artifacts.Store
+	WorkingDir WorkingDir
+}
+
+// Export syncs the artifacts of ctx's project in projAbsPath to the store:
+// the ones that exist are uploaded and the ones that don't, ex. the plan file
+// once it's applied, are deleted.
+func (p *PlanArtifacts) Export(ctx models.ProjectCommandContext, projAbsPath string) error {
+	prefix := artifacts.PullPrefix(ctx.BaseRepo.FullName, ctx.Pull.Num, ctx.Pull.HeadCommit)
+	for _, filename := range planArtifactFilenames(ctx) {
+		key := artifacts.Key(prefix, ctx.Workspace, ctx.RepoRelDir, filename)
+		contents, err := ioutil.ReadFile(filepath.Join(projAbsPath, filename)) // nolint: gosec
+		if os.IsNotExist(err) {
+			if err := p.Store.Delete(key); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "reading %s", filename)
+		}
+		if err := p.Store.Put(key, contents); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Restore clones the workspaces of the pull in ctx that have exported
+// artifacts but no clone, and writes the artifacts back into them. If
+// workspace isn't empty, only that workspace is restored. The caller must
+// hold the lock on the workspaces.
+func (p *PlanArtifacts) Restore(ctx *CommandContext, workspace string) error {
+	prefix := artifacts.PullPrefix(ctx.BaseRepo.FullName, ctx.Pull.Num, ctx.Pull.HeadCommit)
+	keys, err := p.Store.List(prefix)
+	if err != nil {
+		return err
+	}
+	byWorkspace := make(map[string][]string)
+	for _, key := range keys {
+		ws, relPath, ok := artifacts.ParseKey(prefix, key)
+		if !ok || (workspace != "" && ws != workspace) {
+			continue
+		}
+		// Don't write outside the clone if the artifacts were tampered with.
+		if relPath != path.Clean(relPath) || relPath == ".." || strings.HasPrefix(relPath, "../") || path.IsAbs(relPath) {
+			ctx.Log.Warn("not restoring plan artifact %q since it's outside the repo", key)
+			continue
+		}
+		byWorkspace[ws] = append(byWorkspace[ws], relPath)
+	}
+
+	var workspaces []string
+	for ws := range byWorkspace {
+		workspaces = append(workspaces, ws)
+	}
+	sort.Strings(workspaces)
+	for _, ws := range workspaces {
+		if _, err := p.WorkingDir.GetWorkingDir(ctx.BaseRepo, ctx.Pull, ws); err == nil {
+			continue
+		}
+		ctx.Log.Info("restoring plans for workspace %q from the artifact store", ws)
+		repoDir, err := p.WorkingDir.Clone(ctx.Log, ctx.BaseRepo, ctx.HeadRepo, ctx.Pull, ws)
+		if err != nil {
+			return err
+		}
+		var dirs []string
+		for _, relPath := range byWorkspace[ws] {
+			dirs = append(dirs, path.Dir(relPath))
+		}
+		if err := p.WorkingDir.CheckoutDirs(ctx.Log, repoDir, dirs); err != nil {
+			return err
+		}
+		for _, relPath := range byWorkspace[ws] {
+			contents, err := p.Store.Get(artifacts.Key(prefix, ws, ".", relPath))
+			if err != nil {
+				return err
+			}
+			if contents == nil {
+				continue
+			}
+			if err := ioutil.WriteFile(filepath.Join(repoDir, filepath.FromSlash(relPath)), contents, 0600); err != nil {
+				return errors.Wrapf(err, "restoring %s", relPath)
+			}
+		}
+	}
+	return nil
+}
+
+// planArtifactFilenames returns the filenames of the artifacts of ctx's
+// project.
+func planArtifactFilenames(ctx models.ProjectCommandContext) []string {
+	return []string{
+		runtime.GetPlanFilename(ctx.Workspace, ctx.ProjectConfig),
+		runtime.GetPlanJSONFilename(ctx.Workspace, ctx.ProjectConfig),
+		runtime.GetDestroyPlanFilename(ctx.Workspace, ctx.ProjectConfig),
+		runtime.GetPolicyCheckFailedFilename(ctx.Workspace, ctx.ProjectConfig),
+	}
+}
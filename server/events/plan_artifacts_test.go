@@ -0,0 +1,127 @@
+package events_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+// memoryStore is an artifacts.Store that keeps objects in memory.
+type memoryStore map[string][]byte
+
+func (m memoryStore) Put(key string, contents []byte) error {
+	m[key] = contents
+	return nil
+}
+
+func (m memoryStore) Get(key string) ([]byte, error) {
+	return m[key], nil
+}
+
+func (m memoryStore) List(prefix string) ([]string, error) {
+	var keys []string
+	for k := range m {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (m memoryStore) Delete(key string) error {
+	delete(m, key)
+	return nil
+}
+
+func TestPlanArtifacts_ExportAndRestore(t *testing.T) {
+	repoDir, _, cleanupRepo := initRepo(t)
+	defer cleanupRepo()
+	Ok(t, os.Mkdir(filepath.Join(repoDir, "dir"), 0700))
+	Ok(t, ioutil.WriteFile(filepath.Join(repoDir, "dir", "main.tf"), nil, 0600))
+	runGit(t, repoDir, "add", ".")
+	runGit(t, repoDir, "-c", "user.name=atlantis", "-c", "user.email=atlantis@example.com", "commit", "-m", "add dir")
+	commit := runGit(t, repoDir, "rev-parse", "HEAD")
+
+	repo := models.Repo{FullName: "owner/repo"}
+	pull := models.PullRequest{Num: 1, HeadCommit: commit, Branch: "branch"}
+	store := memoryStore{}
+
+	t.Log("plans are exported from the replica that made them")
+	planDataDir, cleanupPlanData := TempDir(t)
+	defer cleanupPlanData()
+	planWD := &events.FileWorkspace{DataDir: planDataDir, TestingOverrideCloneURL: repoDir}
+	cloneDir, err := planWD.Clone(logging.NewNoopLogger(), repo, repo, pull, "staging")
+	Ok(t, err)
+	projDir := filepath.Join(cloneDir, "dir")
+	Ok(t, ioutil.WriteFile(filepath.Join(projDir, "staging.tfplan"), []byte("plan"), 0600))
+	Ok(t, ioutil.WriteFile(filepath.Join(projDir, "staging.tfplan.json"), []byte("{}"), 0600))
+	Ok(t, ioutil.WriteFile(filepath.Join(projDir, "staging.destroy"), nil, 0600))
+	// Left over from an earlier plan that failed its policy checks.
+	store["owner/repo/1/"+commit+"/staging/dir/staging.policyfail"] = nil
+	ctx := models.ProjectCommandContext{
+		Log:        logging.NewNoopLogger(),
+		BaseRepo:   repo,
+		Pull:       pull,
+		Workspace:  "staging",
+		RepoRelDir: "dir",
+	}
+	exporter := &events.PlanArtifacts{Store: store, WorkingDir: planWD}
+	Ok(t, exporter.Export(ctx, projDir))
+	keys, _ := store.List("")
+	Equals(t, []string{
+		"owner/repo/1/" + commit + "/staging/dir/staging.destroy",
+		"owner/repo/1/" + commit + "/staging/dir/staging.tfplan",
+		"owner/repo/1/" + commit + "/staging/dir/staging.tfplan.json",
+	}, keys)
+
+	t.Log("and restored by a replica that hasn't cloned the pull request")
+	applyDataDir, cleanupApplyData := TempDir(t)
+	defer cleanupApplyData()
+	applyWD := &events.FileWorkspace{DataDir: applyDataDir, TestingOverrideCloneURL: repoDir}
+	restorer := &events.PlanArtifacts{Store: store, WorkingDir: applyWD}
+	cmdCtx := &events.CommandContext{Log: logging.NewNoopLogger(), BaseRepo: repo, HeadRepo: repo, Pull: pull}
+	Ok(t, restorer.Restore(cmdCtx, ""))
+	pullDir, err := applyWD.GetPullDir(repo, pull)
+	Ok(t, err)
+	plans, err := (&events.PendingPlanFinder{}).Find(pullDir)
+	Ok(t, err)
+	Equals(t, 1, len(plans))
+	Equals(t, "dir", plans[0].RepoRelDir)
+	Equals(t, "staging", plans[0].Workspace)
+	contents, err := ioutil.ReadFile(filepath.Join(pullDir, "staging", "dir", "staging.tfplan"))
+	Ok(t, err)
+	Equals(t, "plan", string(contents))
+	_, err = os.Stat(filepath.Join(pullDir, "staging", "dir", "staging.destroy"))
+	Ok(t, err)
+
+	t.Log("once the plan is applied it's removed from the store")
+	Ok(t, os.Remove(filepath.Join(projDir, "staging.tfplan")))
+	Ok(t, exporter.Export(ctx, projDir))
+	_, ok := store["owner/repo/1/"+commit+"/staging/dir/staging.tfplan"]
+	Assert(t, !ok, "exp applied plan to be deleted")
+}
+
+func TestPlanArtifacts_RestoreSkipsPathsOutsideRepo(t *testing.T) {
+	repoDir, commit, cleanupRepo := initRepo(t)
+	defer cleanupRepo()
+	dataDir, cleanupData := TempDir(t)
+	defer cleanupData()
+	repo := models.Repo{FullName: "owner/repo"}
+	pull := models.PullRequest{Num: 1, HeadCommit: commit, Branch: "branch"}
+	store := memoryStore{"owner/repo/1/" + commit + "/default/../../default.tfplan": []byte("plan")}
+	wd := &events.FileWorkspace{DataDir: dataDir, TestingOverrideCloneURL: repoDir}
+
+	restorer := &events.PlanArtifacts{Store: store, WorkingDir: wd}
+	Ok(t, restorer.Restore(&events.CommandContext{Log: logging.NewNoopLogger(), BaseRepo: repo, HeadRepo: repo, Pull: pull}, ""))
+	_, err := wd.GetPullDir(repo, pull)
+	Assert(t, os.IsNotExist(err), "exp nothing to be restored")
+}
@@ -0,0 +1,36 @@
+// Package plans keeps the output of plans so they can be viewed in full in
+// the UI rather than only in the pull request's comments, which may be split
+// up, hidden or deleted.
+package plans
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// Retention is how long plan outputs are kept in the Store for.
+const Retention = 30 * 24 * time.Hour
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_store.go Store
+
+// Store persists plan outputs. It's implemented by the locking backends so
+// they're stored in the same database as the locks.
+type Store interface {
+	// SavePlanOutput creates or replaces plan. Plans older than Retention may
+	// be deleted.
+	SavePlanOutput(plan models.PlanOutput) error
+	// GetPlanOutput returns the plan with id or nil if there isn't one.
+	GetPlanOutput(id string) (*models.PlanOutput, error)
+}
+
+// ID returns the ID of the plan of the project at repoRelDir and workspace
+// for headCommit of the pull. IDs are hashes so they can't be guessed from
+// the pull and are safe to put in URLs.
+func ID(repoFullName string, pullNum int, headCommit string, repoRelDir string, workspace string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%s\x00%s\x00%s", repoFullName, pullNum, headCommit, repoRelDir, workspace)))
+	return hex.EncodeToString(sum[:16])
+}
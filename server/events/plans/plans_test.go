@@ -0,0 +1,26 @@
+package plans_test
+
+import (
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/plans"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestID(t *testing.T) {
+	id := plans.ID("owner/repo", 1, "abc123", ".", "default")
+	Equals(t, 32, len(id))
+	Equals(t, id, plans.ID("owner/repo", 1, "abc123", ".", "default"))
+
+	t.Log("the ID should change with each of its parts")
+	others := []string{
+		plans.ID("owner/other", 1, "abc123", ".", "default"),
+		plans.ID("owner/repo", 2, "abc123", ".", "default"),
+		plans.ID("owner/repo", 1, "def456", ".", "default"),
+		plans.ID("owner/repo", 1, "abc123", "dir", "default"),
+		plans.ID("owner/repo", 1, "abc123", ".", "staging"),
+	}
+	for _, other := range others {
+		Assert(t, other != id, "exp IDs to differ")
+	}
+}
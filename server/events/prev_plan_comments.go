@@ -0,0 +1,110 @@
+package events
+
+import (
+	"regexp"
+	"strings"
+)
+
+// planCommentPrefix is how our plan comments start. See the plan templates in
+// markdown_renderer.go.
+const planCommentPrefix = "Ran " + planCommandTitle + " for "
+
+// continuedCommentPrefix is how the rest of a comment that was too long for
+// the VCS host starts.
+const continuedCommentPrefix = "Continued from previous comment"
+
+// planCommentProjectRegex matches how a project is written in the header of a
+// plan comment, ex. project: `name` dir: `dir` workspace: `default`.
+var planCommentProjectRegex = regexp.MustCompile("^(?:project: `([^`]*)` )?dir: `([^`]*)` workspace: `([^`]*)`$")
+
+// commentProject is a project listed in the header of a plan comment. Name is
+// empty if the comment doesn't say which project it was.
+type commentProject struct {
+	Name       string
+	RepoRelDir string
+	Workspace  string
+}
+
+// hidePrevPlanComments hides our earlier plan comments whose projects have
+// all been planned again in res so each project's latest plan is the only one
+// shown. It must be called before res is commented or the new comment would
+// be hidden too.
+func (c *DefaultCommandRunner) hidePrevPlanComments(ctx *CommandContext, res CommandResult) {
+	planned := make(map[commentProject]bool)
+	for _, r := range res.ProjectResults {
+		planned[commentProject{Name: r.ProjectName, RepoRelDir: r.RepoRelDir, Workspace: r.Workspace}] = true
+		// Comments for plans that failed don't include the project name.
+		planned[commentProject{RepoRelDir: r.RepoRelDir, Workspace: r.Workspace}] = true
+	}
+
+	// Comments are passed in order so when a long plan comment was split
+	// we can hide the rest of it along with its first part.
+	hidPrev := false
+	shouldHide := func(comment string) bool {
+		if strings.HasPrefix(comment, continuedCommentPrefix) {
+			return hidPrev
+		}
+		hidPrev = isSupersededPlanComment(comment, planned)
+		return hidPrev
+	}
+	if err := c.VCSClient.HidePrevComments(ctx.BaseRepo, ctx.Pull.Num, shouldHide); err != nil {
+		ctx.Log.Warn("unable to hide previous plan comments: %s", err)
+	}
+}
+
+// isSupersededPlanComment returns true if comment is one of our plan comments
+// and all of its projects are in planned.
+func isSupersededPlanComment(comment string, planned map[commentProject]bool) bool {
+	projects, ok := planCommentProjects(comment)
+	if !ok {
+		return false
+	}
+	for _, p := range projects {
+		if !planned[p] {
+			return false
+		}
+	}
+	return true
+}
+
+// planCommentProjects returns the projects listed in the header of comment.
+// It returns false if comment isn't one of our plan comments.
+func planCommentProjects(comment string) ([]commentProject, bool) {
+	lines := strings.Split(comment, "\n")
+	if !strings.HasPrefix(lines[0], planCommentPrefix) {
+		return nil, false
+	}
+	header := strings.TrimPrefix(lines[0], planCommentPrefix)
+
+	// Comments for one project have it in the first line.
+	if !strings.HasSuffix(header, " projects:") {
+		p, ok := parseCommentProject(header)
+		if !ok {
+			return nil, false
+		}
+		return []commentProject{p}, true
+	}
+
+	// Comments for multiple projects list them, one per line, until the
+	// first blank line.
+	var projects []commentProject
+	for _, line := range lines[1:] {
+		if line == "" {
+			break
+		}
+		p, ok := parseCommentProject(strings.TrimPrefix(line, "1. "))
+		if !ok {
+			return nil, false
+		}
+		projects = append(projects, p)
+	}
+	return projects, true
+}
+
+func parseCommentProject(s string) (commentProject, bool) {
+	match := planCommentProjectRegex.FindStringSubmatch(s)
+	if match == nil {
+		return commentProject{}, false
+	}
+	return commentProject{Name: match[1], RepoRelDir: match[2], Workspace: match[3]}, true
+}
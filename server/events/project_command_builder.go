@@ -1,12 +1,19 @@
 package events
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
 	"strings"
 
+	"github.com/docker/docker/pkg/fileutils"
 	"github.com/hashicorp/go-version"
 	"github.com/pkg/errors"
 	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/runtime"
 	"github.com/runatlantis/atlantis/server/events/vcs"
 	"github.com/runatlantis/atlantis/server/events/yaml"
 	"github.com/runatlantis/atlantis/server/events/yaml/valid"
@@ -37,6 +44,18 @@ type ProjectCommandBuilder interface {
 	// comment doesn't specify one project then there may be multiple commands
 	// to be run.
 	BuildApplyCommands(ctx *CommandContext, commentCommand *CommentCommand) ([]models.ProjectCommandContext, error)
+	// BuildApprovePoliciesCommands builds commands that approve the plans
+	// from this pull request that failed their policy checks. It returns an
+	// error if the user who commented isn't a policy owner.
+	BuildApprovePoliciesCommands(ctx *CommandContext, commentCommand *CommentCommand) ([]models.ProjectCommandContext, error)
+	// BuildImportCommands builds the command that imports the resource in
+	// the comment into the project it specifies, or the root directory and
+	// default workspace if it doesn't specify one.
+	BuildImportCommands(ctx *CommandContext, commentCommand *CommentCommand) ([]models.ProjectCommandContext, error)
+	// BuildStateCommands builds the command that runs the state subcommand in
+	// the comment for the project it specifies, or the root directory and
+	// default workspace if it doesn't specify one.
+	BuildStateCommands(ctx *CommandContext, commentCommand *CommentCommand) ([]models.ProjectCommandContext, error)
 }
 
 // DefaultProjectCommandBuilder implements ProjectCommandBuilder.
@@ -52,12 +71,31 @@ type DefaultProjectCommandBuilder struct {
 	AllowRepoConfigFlag string
 	PendingPlanFinder   *PendingPlanFinder
 	CommentBuilder      CommentBuilder
+	// ServerConfig is the server-side repo config.
+	ServerConfig valid.ServerConfig
+	// MaxProjectsPerCommand is the most projects a single command can run
+	// unless the server-side repo config overrides it. 0 means no limit.
+	MaxProjectsPerCommand int
+	// TFLogLevel is the TF_LOG level plans run with unless a comment
+	// specifies its own. If empty, TF_LOG isn't set.
+	TFLogLevel string
+	// EnableTerragrunt is true if projects with a terragrunt.hcl file that
+	// aren't configured to use a workflow should be run with terragrunt.
+	EnableTerragrunt bool
+	// ProjectDirs are patterns, relative to the repo root, of the
+	// directories we run projects in, ex. prod/**. Projects in other
+	// directories are left to other Atlantis instances. If empty, we run
+	// projects in every directory.
+	ProjectDirs []string
+	// PlanArtifacts restores the plans of pull requests this replica hasn't
+	// cloned before they're applied. If nil, only local plans are applied.
+	PlanArtifacts *PlanArtifacts
 }
 
 // TFCommandRunner runs Terraform commands.
 type TFCommandRunner interface {
 	// RunCommandWithVersion runs a Terraform command using the version v.
-	RunCommandWithVersion(log *logging.SimpleLogger, path string, args []string, v *version.Version, workspace string) (string, error)
+	RunCommandWithVersion(ctx context.Context, log *logging.SimpleLogger, path string, args []string, v *version.Version, workspace string) (string, error)
 }
 
 // BuildAutoplanCommands builds project commands that will run plan on
@@ -67,18 +105,46 @@ func (p *DefaultProjectCommandBuilder) BuildAutoplanCommands(ctx *CommandContext
 	if err != nil {
 		return nil, err
 	}
-	// Filter out projects where autoplanning is specifically disabled.
+	p.setTFLogLevel(cmds, nil)
+	p.setPolicySets(ctx.BaseRepo, cmds)
+	// Filter out projects where autoplanning is specifically disabled or
+	// that only autoplan pull requests into other branches.
+	repoCfg := p.serverRepoCfg(ctx.BaseRepo)
 	var autoplanEnabled []models.ProjectCommandContext
 	for _, cmd := range cmds {
 		if cmd.ProjectConfig != nil && !cmd.ProjectConfig.Autoplan.Enabled {
 			ctx.Log.Debug("ignoring project at dir %q, workspace: %q because autoplan is disabled", cmd.RepoRelDir, cmd.Workspace)
 			continue
 		}
+		if !autoplansBranch(repoCfg, cmd.ProjectConfig, ctx.Pull.BaseBranch) {
+			ctx.Log.Debug("ignoring project at dir %q, workspace: %q because it isn't autoplanned for pull requests into %q", cmd.RepoRelDir, cmd.Workspace, ctx.Pull.BaseBranch)
+			continue
+		}
 		autoplanEnabled = append(autoplanEnabled, cmd)
 	}
+	if err := p.validateProjectCount(ctx.BaseRepo, PlanCommand, len(autoplanEnabled)); err != nil {
+		return nil, err
+	}
 	return autoplanEnabled, nil
 }
 
+// autoplansBranch returns true if the project configured by projCfg, which
+// is nil if it isn't configured, is autoplanned for pull requests into
+// baseBranch. The project's branch regex is used, or else repoCfg's. If the
+// base branch isn't known, the project is autoplanned.
+func autoplansBranch(repoCfg valid.Repo, projCfg *valid.Project, baseBranch string) bool {
+	expr := repoCfg.Branch
+	if projCfg != nil && projCfg.Branch != nil {
+		expr = projCfg.Branch
+	}
+	if expr == nil || baseBranch == "" {
+		return true
+	}
+	// The regex was validated when the config was parsed.
+	match, _ := regexp.MatchString(*expr, baseBranch)
+	return match
+}
+
 func (p *DefaultProjectCommandBuilder) buildPlanAllCommands(ctx *CommandContext, commentFlags []string, verbose bool) ([]models.ProjectCommandContext, error) {
 	// Need to lock the workspace we're about to clone to.
 	workspace := DefaultWorkspace
@@ -101,11 +167,11 @@ func (p *DefaultProjectCommandBuilder) buildPlanAllCommands(ctx *CommandContext,
 	if err != nil {
 		return nil, errors.Wrapf(err, "looking for %s file in %q", yaml.AtlantisYAMLFilename, repoDir)
 	}
+	if err := p.validateConfigFileRequirement(ctx.BaseRepo, hasConfigFile); err != nil {
+		return nil, err
+	}
 	if hasConfigFile {
-		if !p.AllowRepoConfig {
-			return nil, fmt.Errorf("%s files not allowed because Atlantis is not running with --%s", yaml.AtlantisYAMLFilename, p.AllowRepoConfigFlag)
-		}
-		config, err = p.ParserValidator.ReadConfig(repoDir)
+		config, err = p.ReadRepoConfig(ctx.BaseRepo, repoDir)
 		if err != nil {
 			return nil, err
 		}
@@ -121,6 +187,17 @@ func (p *DefaultProjectCommandBuilder) buildPlanAllCommands(ctx *CommandContext,
 	}
 	ctx.Log.Debug("%d files were modified in this pull request", len(modifiedFiles))
 
+	// With a sparse checkout, the modified dirs, or the dirs of the projects
+	// in the config file, need to be checked out before we can tell which
+	// projects were modified.
+	checkoutDirs := p.modifiedDirs(modifiedFiles)
+	for _, proj := range config.Projects {
+		checkoutDirs = append(checkoutDirs, proj.Dir)
+	}
+	if err := p.WorkingDir.CheckoutDirs(ctx.Log, repoDir, checkoutDirs); err != nil {
+		return nil, err
+	}
+
 	// Prepare the project contexts so the ProjectCommandRunner can execute.
 	var projCtxs []models.ProjectCommandContext
 
@@ -130,21 +207,29 @@ func (p *DefaultProjectCommandBuilder) buildPlanAllCommands(ctx *CommandContext,
 		modifiedProjects := p.ProjectFinder.DetermineProjects(ctx.Log, modifiedFiles, ctx.BaseRepo.FullName, repoDir)
 		ctx.Log.Info("automatically determined that there were %d projects modified in this pull request: %s", len(modifiedProjects), modifiedProjects)
 		for _, mp := range modifiedProjects {
-			projCtxs = append(projCtxs, models.ProjectCommandContext{
-				BaseRepo:      ctx.BaseRepo,
-				HeadRepo:      ctx.HeadRepo,
-				Pull:          ctx.Pull,
-				User:          ctx.User,
-				Log:           ctx.Log,
-				RepoRelDir:    mp.Path,
-				ProjectConfig: nil,
-				GlobalConfig:  nil,
-				CommentArgs:   commentFlags,
-				Workspace:     DefaultWorkspace,
-				Verbose:       verbose,
-				RePlanCmd:     p.CommentBuilder.BuildPlanComment(mp.Path, DefaultWorkspace, "", commentFlags),
-				ApplyCmd:      p.CommentBuilder.BuildApplyComment(mp.Path, DefaultWorkspace, ""),
-			})
+			workspaces, err := p.projectWorkspaces(ctx.BaseRepo, ctx.Pull.BaseBranch, repoDir, mp.Path, modifiedFiles)
+			if err != nil {
+				return nil, err
+			}
+			for _, workspace := range workspaces {
+				projCfg, globalCfg := p.defaultProjectCfg(ctx.BaseRepo, nil, mp.Path, workspace)
+				projCtxs = append(projCtxs, models.ProjectCommandContext{
+					BaseRepo:      ctx.BaseRepo,
+					HeadRepo:      ctx.HeadRepo,
+					Pull:          ctx.Pull,
+					User:          ctx.User,
+					Log:           ctx.Log,
+					RepoRelDir:    mp.Path,
+					ProjectConfig: projCfg,
+					GlobalConfig:  globalCfg,
+					CommentArgs:   commentFlags,
+					Workspace:     workspace,
+					Terragrunt:    p.usesTerragrunt(repoDir, mp.Path, projCfg),
+					Verbose:       verbose,
+					RePlanCmd:     p.CommentBuilder.BuildPlanComment(mp.Path, workspace, "", commentFlags),
+					ApplyCmd:      p.CommentBuilder.BuildApplyComment(mp.Path, workspace, ""),
+				})
+			}
 		}
 	} else {
 		// Otherwise, we use the projects that match the WhenModified fields
@@ -170,20 +255,42 @@ func (p *DefaultProjectCommandBuilder) buildPlanAllCommands(ctx *CommandContext,
 				RepoRelDir:    mp.Dir,
 				ProjectConfig: &mp,
 				GlobalConfig:  &config,
+				Terragrunt:    p.usesTerragrunt(repoDir, mp.Dir, &mp),
 				Verbose:       verbose,
 				RePlanCmd:     p.CommentBuilder.BuildPlanComment(mp.Dir, mp.Workspace, mp.GetName(), commentFlags),
 				ApplyCmd:      p.CommentBuilder.BuildApplyComment(mp.Dir, mp.Workspace, mp.GetName()),
 			})
 		}
 	}
-	return projCtxs, nil
+
+	// Check out the whole of each project that will be planned, and the
+	// modules it calls, if only some of its files were.
+	var projDirs []string
+	for _, projCtx := range projCtxs {
+		projDirs = append(projDirs, projCtx.RepoRelDir)
+	}
+	if err := p.WorkingDir.CheckoutDirs(ctx.Log, repoDir, projDirs); err != nil {
+		return nil, err
+	}
+	return p.filterProjectDirs(ctx.Log, projCtxs)
+}
+
+// modifiedDirs returns the dirs that contain modifiedFiles.
+func (p *DefaultProjectCommandBuilder) modifiedDirs(modifiedFiles []string) []string {
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, file := range modifiedFiles {
+		dir := path.Dir(file)
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
 }
 
 func (p *DefaultProjectCommandBuilder) buildProjectPlanCommand(ctx *CommandContext, cmd *CommentCommand) (models.ProjectCommandContext, error) {
-	workspace := DefaultWorkspace
-	if cmd.Workspace != "" {
-		workspace = cmd.Workspace
-	}
+	workspace := p.commentWorkspace(ctx, cmd)
 
 	var pcc models.ProjectCommandContext
 	ctx.Log.Debug("building plan command")
@@ -207,21 +314,185 @@ func (p *DefaultProjectCommandBuilder) buildProjectPlanCommand(ctx *CommandConte
 	return p.buildProjectCommandCtx(ctx, cmd.ProjectName, cmd.Flags, repoDir, repoRelDir, workspace)
 }
 
+// buildPlanGlobCommands builds the plan commands for the projects whose dirs
+// match cmd's dir glob, ex. atlantis plan -d 'modules/prod/*'. If the repo has
+// an atlantis.yaml file, its projects are matched. Otherwise every dir with
+// Terraform files is, whether or not it was modified.
+func (p *DefaultProjectCommandBuilder) buildPlanGlobCommands(ctx *CommandContext, cmd *CommentCommand) ([]models.ProjectCommandContext, error) {
+	unlockFn, err := p.WorkingDirLocker.TryLock(ctx.BaseRepo.FullName, ctx.Pull.Num, DefaultWorkspace)
+	if err != nil {
+		return nil, err
+	}
+	defer unlockFn()
+	repoDir, err := p.WorkingDir.Clone(ctx.Log, ctx.BaseRepo, ctx.HeadRepo, ctx.Pull, DefaultWorkspace)
+	if err != nil {
+		return nil, err
+	}
+	hasConfigFile, err := p.ParserValidator.HasConfigFile(repoDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "looking for %s file in %q", yaml.AtlantisYAMLFilename, repoDir)
+	}
+	if err := p.validateConfigFileRequirement(ctx.BaseRepo, hasConfigFile); err != nil {
+		return nil, err
+	}
+
+	type match struct {
+		dir, workspace, projectName string
+	}
+	var matches []match
+	if hasConfigFile {
+		config, err := p.ReadRepoConfig(ctx.BaseRepo, repoDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, proj := range config.Projects {
+			if ok, _ := path.Match(cmd.RepoRelDir, proj.Dir); !ok || (cmd.Workspace != "" && cmd.Workspace != proj.Workspace) {
+				continue
+			}
+			matches = append(matches, match{proj.Dir, proj.Workspace, proj.GetName()})
+		}
+	} else {
+		files, err := p.listRepoFiles(repoDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, mp := range p.ProjectFinder.DetermineProjects(ctx.Log, files, ctx.BaseRepo.FullName, repoDir) {
+			if ok, _ := path.Match(cmd.RepoRelDir, mp.Path); !ok {
+				continue
+			}
+			workspaces := []string{cmd.Workspace}
+			if cmd.Workspace == "" {
+				if workspaces, err = p.projectWorkspaces(ctx.BaseRepo, ctx.Pull.BaseBranch, repoDir, mp.Path, nil); err != nil {
+					return nil, err
+				}
+			}
+			for _, workspace := range workspaces {
+				matches = append(matches, match{mp.Path, workspace, ""})
+			}
+		}
+	}
+
+	var cmds []models.ProjectCommandContext
+	for _, m := range matches {
+		// Skip the dirs this instance doesn't run rather than failing the
+		// whole command.
+		if inProjectDirs, err := p.inProjectDirs(m.dir); err != nil || !inProjectDirs {
+			continue
+		}
+		pcc, err := p.buildProjectCommandCtx(ctx, m.projectName, cmd.Flags, repoDir, m.dir, m.workspace)
+		if err != nil {
+			return nil, errors.Wrapf(err, "building command for dir %q", m.dir)
+		}
+		pcc.Verbose = cmd.Verbose
+		cmds = append(cmds, pcc)
+	}
+	if len(cmds) == 0 {
+		return nil, fmt.Errorf("no projects have a dir that matches %q", cmd.RepoRelDir)
+	}
+	ctx.Log.Info("%d projects matched %q", len(cmds), cmd.RepoRelDir)
+	return cmds, nil
+}
+
 // BuildPlanCommands builds project plan commands for this comment. If the
 // comment doesn't specify one project then there may be multiple commands
 // to be run.
 func (p *DefaultProjectCommandBuilder) BuildPlanCommands(ctx *CommandContext, cmd *CommentCommand) ([]models.ProjectCommandContext, error) {
+	var cmds []models.ProjectCommandContext
 	if !cmd.IsForSpecificProject() {
-		return p.buildPlanAllCommands(ctx, cmd.Flags, cmd.Verbose)
+		var err error
+		cmds, err = p.buildPlanAllCommands(ctx, cmd.Flags, cmd.Verbose)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.validateProjectCount(ctx.BaseRepo, PlanCommand, len(cmds)); err != nil {
+			return nil, err
+		}
+	} else if cmd.HasDirGlob() {
+		var err error
+		cmds, err = p.buildPlanGlobCommands(ctx, cmd)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.validateProjectCount(ctx.BaseRepo, PlanCommand, len(cmds)); err != nil {
+			return nil, err
+		}
+	} else {
+		pcc, err := p.buildProjectPlanCommand(ctx, cmd)
+		if err != nil {
+			return nil, err
+		}
+		cmds = []models.ProjectCommandContext{pcc}
 	}
-	pcc, err := p.buildProjectPlanCommand(ctx, cmd)
-	if err != nil {
-		return nil, err
+	p.setTFLogLevel(cmds, cmd)
+	p.setDestroy(cmds, cmd)
+	p.setTargets(cmds, cmd)
+	p.setPolicySets(ctx.BaseRepo, cmds)
+	return cmds, nil
+}
+
+// setDestroy marks each plan command as a destroy plan if cmd asked for one.
+// Their RePlanCmds keep the -destroy flag so re-planning doesn't silently
+// turn them back into regular plans.
+func (p *DefaultProjectCommandBuilder) setDestroy(cmds []models.ProjectCommandContext, cmd *CommentCommand) {
+	if !cmd.Destroy {
+		return
+	}
+	for i := range cmds {
+		cmds[i].Destroy = true
+		cmds[i].RePlanCmd = addRePlanFlag(cmds[i].RePlanCmd, "-destroy")
+	}
+}
+
+// setTargets sets the resource addresses that each plan command targets if
+// cmd has any. Their RePlanCmds keep the -target flags too.
+func (p *DefaultProjectCommandBuilder) setTargets(cmds []models.ProjectCommandContext, cmd *CommentCommand) {
+	for i := range cmds {
+		cmds[i].Targets = cmd.Targets
+		for _, target := range cmd.Targets {
+			cmds[i].RePlanCmd = addRePlanFlag(cmds[i].RePlanCmd, "-target="+target)
+		}
 	}
-	return []models.ProjectCommandContext{pcc}, nil
 }
 
-func (p *DefaultProjectCommandBuilder) buildApplyAllCommands(ctx *CommandContext, commentCmd *CommentCommand) ([]models.ProjectCommandContext, error) {
+// addRePlanFlag adds flag to the Atlantis flags of rePlanCmd, before the
+// extra args for terraform if it has any.
+func addRePlanFlag(rePlanCmd string, flag string) string {
+	if idx := strings.Index(rePlanCmd, " -- "); idx != -1 {
+		return rePlanCmd[:idx] + " " + flag + rePlanCmd[idx:]
+	}
+	return rePlanCmd + " " + flag
+}
+
+// setTFLogLevel sets the TF_LOG level on each plan command. A level set on the
+// comment takes precedence over the server's default level. cmd will be nil
+// for autoplans.
+func (p *DefaultProjectCommandBuilder) setTFLogLevel(cmds []models.ProjectCommandContext, cmd *CommentCommand) {
+	level := p.TFLogLevel
+	if cmd != nil && cmd.TFLogLevel != "" {
+		level = cmd.TFLogLevel
+	}
+	for i := range cmds {
+		cmds[i].TFLogLevel = level
+	}
+}
+
+// setPolicySets sets the policy sets from the server-side repo config that
+// each plan command will be checked against, and whether the repo opted out
+// of cost estimates.
+func (p *DefaultProjectCommandBuilder) setPolicySets(repo models.Repo, cmds []models.ProjectCommandContext) {
+	repoCfg := p.ServerConfig.FindRepo(repo.FullName, repo.VCSHost.Hostname)
+	if repoCfg == nil {
+		return
+	}
+	for i := range cmds {
+		cmds[i].PolicySets = repoCfg.PolicySets
+		cmds[i].DisableCostEstimation = repoCfg.DisableCostEstimation
+	}
+}
+
+// buildPendingPlanCommands builds commands for all the unapplied plans in
+// this pull request.
+func (p *DefaultProjectCommandBuilder) buildPendingPlanCommands(ctx *CommandContext, commentCmd *CommentCommand, cmdName CommandName) ([]models.ProjectCommandContext, error) {
 	// lock all dirs in this pull request
 	unlockFn, err := p.WorkingDirLocker.TryLockPull(ctx.BaseRepo.FullName, ctx.Pull.Num)
 	if err != nil {
@@ -229,6 +500,9 @@ func (p *DefaultProjectCommandBuilder) buildApplyAllCommands(ctx *CommandContext
 	}
 	defer unlockFn()
 
+	if err := p.restorePlans(ctx, ""); err != nil {
+		return nil, err
+	}
 	pullDir, err := p.WorkingDir.GetPullDir(ctx.BaseRepo, ctx.Pull)
 	if err != nil {
 		return nil, err
@@ -238,6 +512,9 @@ func (p *DefaultProjectCommandBuilder) buildApplyAllCommands(ctx *CommandContext
 	if err != nil {
 		return nil, err
 	}
+	if err := p.validateProjectCount(ctx.BaseRepo, cmdName, len(plans)); err != nil {
+		return nil, err
+	}
 
 	var cmds []models.ProjectCommandContext
 	for _, plan := range plans {
@@ -250,25 +527,269 @@ func (p *DefaultProjectCommandBuilder) buildApplyAllCommands(ctx *CommandContext
 	return cmds, nil
 }
 
+// restorePlans restores the exported plans of workspace, or every workspace
+// if it's empty, that aren't cloned on this replica.
+func (p *DefaultProjectCommandBuilder) restorePlans(ctx *CommandContext, workspace string) error {
+	if p.PlanArtifacts == nil {
+		return nil
+	}
+	return errors.Wrap(p.PlanArtifacts.Restore(ctx, workspace), "restoring plans from the artifact store")
+}
+
 // BuildApplyCommands builds project apply commands for this comment. If the
 // comment doesn't specify one project then there may be multiple commands
 // to be run.
 func (p *DefaultProjectCommandBuilder) BuildApplyCommands(ctx *CommandContext, cmd *CommentCommand) ([]models.ProjectCommandContext, error) {
 	if !cmd.IsForSpecificProject() {
-		return p.buildApplyAllCommands(ctx, cmd)
+		return p.buildPendingPlanCommands(ctx, cmd, ApplyCommand)
+	}
+	// Each of several projects is applied as if it were named on its own.
+	var pacs []models.ProjectCommandContext
+	for _, projectCmd := range p.splitProjects(cmd) {
+		pac, err := p.buildProjectApplyCommand(ctx, projectCmd)
+		if err != nil {
+			if projectCmd.ProjectName != cmd.ProjectName {
+				err = errors.Wrapf(err, "building command for project %q", projectCmd.ProjectName)
+			}
+			return nil, err
+		}
+		pacs = append(pacs, pac)
 	}
-	pac, err := p.buildProjectApplyCommand(ctx, cmd)
+	return pacs, nil
+}
+
+// splitProjects returns a copy of cmd for each of the projects it names, or
+// just cmd if it names at most one.
+func (p *DefaultProjectCommandBuilder) splitProjects(cmd *CommentCommand) []*CommentCommand {
+	names := cmd.ProjectNames()
+	if len(names) <= 1 {
+		return []*CommentCommand{cmd}
+	}
+	var cmds []*CommentCommand
+	for _, name := range names {
+		projectCmd := *cmd
+		projectCmd.ProjectName = name
+		cmds = append(cmds, &projectCmd)
+	}
+	return cmds
+}
+
+// BuildApprovePoliciesCommands builds commands that approve the plans from
+// this pull request that failed their policy checks. It returns an error if
+// the user who commented isn't a policy owner.
+func (p *DefaultProjectCommandBuilder) BuildApprovePoliciesCommands(ctx *CommandContext, cmd *CommentCommand) ([]models.ProjectCommandContext, error) {
+	repoCfg := p.ServerConfig.FindRepo(ctx.BaseRepo.FullName, ctx.BaseRepo.VCSHost.Hostname)
+	if repoCfg == nil || !repoCfg.IsPolicyOwner(ctx.User.Username) {
+		return nil, fmt.Errorf("user %s is not a policy owner for this repo: policy owners are set with policy_owners in the server-side repo config", ctx.User.Username)
+	}
+	cmds, err := p.buildPendingPlanCommands(ctx, cmd, ApprovePoliciesCommand)
 	if err != nil {
 		return nil, err
 	}
-	return []models.ProjectCommandContext{pac}, nil
+
+	// Only the plans that failed need approving.
+	var failed []models.ProjectCommandContext
+	for _, c := range cmds {
+		repoDir, err := p.WorkingDir.GetWorkingDir(ctx.BaseRepo, ctx.Pull, c.Workspace)
+		if err != nil {
+			return nil, err
+		}
+		failedPath := filepath.Join(repoDir, c.RepoRelDir, runtime.GetPolicyCheckFailedFilename(c.Workspace, c.ProjectConfig))
+		if _, err := os.Stat(failedPath); err == nil {
+			failed = append(failed, c)
+		}
+	}
+	return failed, nil
 }
 
-func (p *DefaultProjectCommandBuilder) buildProjectApplyCommand(ctx *CommandContext, cmd *CommentCommand) (models.ProjectCommandContext, error) {
-	workspace := DefaultWorkspace
+// BuildImportCommands builds the command that imports the resource in the
+// comment into the project it specifies, or the root directory and default
+// workspace if it doesn't specify one.
+func (p *DefaultProjectCommandBuilder) BuildImportCommands(ctx *CommandContext, cmd *CommentCommand) ([]models.ProjectCommandContext, error) {
+	// Import runs in the same clone as plan.
+	pcc, err := p.buildProjectPlanCommand(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	pcc.ImportAddress = cmd.ImportAddress
+	pcc.ImportID = cmd.ImportID
+	return []models.ProjectCommandContext{pcc}, nil
+}
+
+// BuildStateCommands builds the command that runs the state subcommand in the
+// comment for the project it specifies, or the root directory and default
+// workspace if it doesn't specify one.
+func (p *DefaultProjectCommandBuilder) BuildStateCommands(ctx *CommandContext, cmd *CommentCommand) ([]models.ProjectCommandContext, error) {
+	pcc, err := p.buildProjectPlanCommand(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	pcc.StateSubcommand = cmd.StateSubcommand
+	pcc.StateAddresses = cmd.StateAddresses
+	return []models.ProjectCommandContext{pcc}, nil
+}
+
+// BuildDriftCommands builds the commands that plan every project in repoDir,
+// a clone of repo's default branch, to detect drift. If the repo has an
+// atlantis.yaml file, its projects are planned. Otherwise every directory
+// with Terraform files is.
+func (p *DefaultProjectCommandBuilder) BuildDriftCommands(log *logging.SimpleLogger, repo models.Repo, repoDir string) ([]models.ProjectCommandContext, error) {
+	hasConfigFile, err := p.ParserValidator.HasConfigFile(repoDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "looking for %s file in %q", yaml.AtlantisYAMLFilename, repoDir)
+	}
+	if err := p.validateConfigFileRequirement(repo, hasConfigFile); err != nil {
+		return nil, err
+	}
+
+	var projCtxs []models.ProjectCommandContext
+	if hasConfigFile {
+		config, err := p.ReadRepoConfig(repo, repoDir)
+		if err != nil {
+			return nil, err
+		}
+		for i := range config.Projects {
+			proj := config.Projects[i]
+			projCtxs = append(projCtxs, models.ProjectCommandContext{
+				BaseRepo:      repo,
+				HeadRepo:      repo,
+				Log:           log,
+				Workspace:     proj.Workspace,
+				RepoRelDir:    proj.Dir,
+				ProjectConfig: &proj,
+				GlobalConfig:  &config,
+				Terragrunt:    p.usesTerragrunt(repoDir, proj.Dir, &proj),
+			})
+		}
+		return p.filterProjectDirs(log, projCtxs)
+	}
+
+	files, err := p.listRepoFiles(repoDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, mp := range p.ProjectFinder.DetermineProjects(log, files, repo.FullName, repoDir) {
+		// Nothing was modified so every detected workspace is planned. The
+		// default branch isn't a pull request's base branch so it isn't
+		// mapped to a workspace.
+		workspaces, err := p.projectWorkspaces(repo, "", repoDir, mp.Path, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, workspace := range workspaces {
+			projCfg, globalCfg := p.defaultProjectCfg(repo, nil, mp.Path, workspace)
+			projCtxs = append(projCtxs, models.ProjectCommandContext{
+				BaseRepo:      repo,
+				HeadRepo:      repo,
+				Log:           log,
+				Workspace:     workspace,
+				RepoRelDir:    mp.Path,
+				ProjectConfig: projCfg,
+				GlobalConfig:  globalCfg,
+				Terragrunt:    p.usesTerragrunt(repoDir, mp.Path, projCfg),
+			})
+		}
+	}
+	return p.filterProjectDirs(log, projCtxs)
+}
+
+// projectWorkspaces returns the workspaces to plan the project at repoRelDir
+// in when it isn't configured in an atlantis.yaml file. That's the default
+// workspace, or the one the server-side repo config maps baseBranch to,
+// unless the server-side repo config enables workspace detection and the
+// project has env/{workspace}.tfvars files, which the plan step passes as var
+// files. If only some of those files were modified, only their workspaces are
+// planned. If anything else in the project was modified, all of them are.
+func (p *DefaultProjectCommandBuilder) projectWorkspaces(repo models.Repo, baseBranch string, repoDir string, repoRelDir string, modifiedFiles []string) ([]string, error) {
+	if !p.serverRepoCfg(repo).DetectWorkspaces {
+		return []string{p.branchWorkspace(repo, baseBranch)}, nil
+	}
+	envDir := filepath.Join(repoRelDir, "env")
+	varFiles, err := filepath.Glob(filepath.Join(repoDir, envDir, "*.tfvars"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "listing var files in %q", envDir)
+	}
+	var detected []string
+	isDetected := make(map[string]bool)
+	for _, f := range varFiles {
+		workspace := strings.TrimSuffix(filepath.Base(f), ".tfvars")
+		detected = append(detected, workspace)
+		isDetected[workspace] = true
+	}
+	if len(detected) == 0 {
+		return []string{p.branchWorkspace(repo, baseBranch)}, nil
+	}
+
+	var modified []string
+	for _, f := range modifiedFiles {
+		f = filepath.Clean(f)
+		if filepath.Dir(f) == envDir && filepath.Ext(f) == ".tfvars" {
+			// Deleted var files aren't detected so they're skipped.
+			if workspace := strings.TrimSuffix(filepath.Base(f), ".tfvars"); isDetected[workspace] {
+				modified = append(modified, workspace)
+			}
+			continue
+		}
+		if repoRelDir == "." || strings.HasPrefix(f, repoRelDir+string(filepath.Separator)) {
+			return detected, nil
+		}
+	}
+	// If no var files were modified, the project was modified outside its
+	// dir, ex. in a module, so that affects every workspace.
+	if len(modified) == 0 {
+		return detected, nil
+	}
+	return modified, nil
+}
+
+// listRepoFiles returns the paths, relative to repoDir, of all the files in
+// the repo except for those in the .git and .terraform directories.
+// commentWorkspace returns the workspace cmd runs in: the one it sets with
+// -w or, unless it names a project, the one the server-side repo config maps
+// the pull request's base branch to. Otherwise it's the default workspace.
+func (p *DefaultProjectCommandBuilder) commentWorkspace(ctx *CommandContext, cmd *CommentCommand) string {
 	if cmd.Workspace != "" {
-		workspace = cmd.Workspace
+		return cmd.Workspace
+	}
+	if cmd.ProjectName != "" {
+		return DefaultWorkspace
 	}
+	return p.branchWorkspace(ctx.BaseRepo, ctx.Pull.BaseBranch)
+}
+
+// branchWorkspace returns the workspace the server-side repo config maps
+// baseBranch to for repo, or the default workspace if it isn't mapped.
+func (p *DefaultProjectCommandBuilder) branchWorkspace(repo models.Repo, baseBranch string) string {
+	if workspace, ok := p.serverRepoCfg(repo).BranchWorkspaces[baseBranch]; ok && baseBranch != "" {
+		return workspace
+	}
+	return DefaultWorkspace
+}
+
+func (p *DefaultProjectCommandBuilder) listRepoFiles(repoDir string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(repoDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" || info.Name() == ".terraform" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(repoDir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	return files, errors.Wrapf(err, "listing files in %q", repoDir)
+}
+
+func (p *DefaultProjectCommandBuilder) buildProjectApplyCommand(ctx *CommandContext, cmd *CommentCommand) (models.ProjectCommandContext, error) {
+	workspace := p.commentWorkspace(ctx, cmd)
 
 	var projCtx models.ProjectCommandContext
 	unlockFn, err := p.WorkingDirLocker.TryLock(ctx.BaseRepo.FullName, ctx.Pull.Num, workspace)
@@ -277,6 +798,9 @@ func (p *DefaultProjectCommandBuilder) buildProjectApplyCommand(ctx *CommandCont
 	}
 	defer unlockFn()
 
+	if err := p.restorePlans(ctx, workspace); err != nil {
+		return projCtx, err
+	}
 	repoDir, err := p.WorkingDir.GetWorkingDir(ctx.BaseRepo, ctx.Pull, workspace)
 	if err != nil {
 		return projCtx, err
@@ -291,7 +815,7 @@ func (p *DefaultProjectCommandBuilder) buildProjectApplyCommand(ctx *CommandCont
 }
 
 func (p *DefaultProjectCommandBuilder) buildProjectCommandCtx(ctx *CommandContext, projectName string, commentFlags []string, repoDir string, repoRelDir string, workspace string) (models.ProjectCommandContext, error) {
-	projCfg, globalCfg, err := p.getCfg(projectName, repoRelDir, workspace, repoDir)
+	projCfg, globalCfg, err := p.getCfg(ctx.BaseRepo, projectName, repoRelDir, workspace, repoDir)
 	if err != nil {
 		return models.ProjectCommandContext{}, err
 	}
@@ -307,6 +831,16 @@ func (p *DefaultProjectCommandBuilder) buildProjectCommandCtx(ctx *CommandContex
 	if err := p.validateWorkspaceAllowed(globalCfg, repoRelDir, workspace); err != nil {
 		return models.ProjectCommandContext{}, err
 	}
+	inProjectDirs, err := p.inProjectDirs(repoRelDir)
+	if err != nil {
+		return models.ProjectCommandContext{}, err
+	}
+	if !inProjectDirs {
+		return models.ProjectCommandContext{}, fmt.Errorf("dir %q isn't run by this Atlantis instance because it doesn't match any of %s", repoRelDir, strings.Join(p.ProjectDirs, ", "))
+	}
+	if err := p.WorkingDir.CheckoutDirs(ctx.Log, repoDir, []string{repoRelDir}); err != nil {
+		return models.ProjectCommandContext{}, err
+	}
 
 	return models.ProjectCommandContext{
 		BaseRepo:      ctx.BaseRepo,
@@ -319,31 +853,31 @@ func (p *DefaultProjectCommandBuilder) buildProjectCommandCtx(ctx *CommandContex
 		RepoRelDir:    repoRelDir,
 		ProjectConfig: projCfg,
 		GlobalConfig:  globalCfg,
+		Terragrunt:    p.usesTerragrunt(repoDir, repoRelDir, projCfg),
 		RePlanCmd:     p.CommentBuilder.BuildPlanComment(repoRelDir, workspace, projectName, commentFlags),
 		ApplyCmd:      p.CommentBuilder.BuildApplyComment(repoRelDir, workspace, projectName),
 	}, nil
 }
 
-func (p *DefaultProjectCommandBuilder) getCfg(projectName string, dir string, workspace string, repoDir string) (projectCfg *valid.Project, globalCfg *valid.Config, err error) {
+func (p *DefaultProjectCommandBuilder) getCfg(repo models.Repo, projectName string, dir string, workspace string, repoDir string) (projectCfg *valid.Project, globalCfg *valid.Config, err error) {
 	hasConfigFile, err := p.ParserValidator.HasConfigFile(repoDir)
 	if err != nil {
 		err = errors.Wrapf(err, "looking for %s file in %q", yaml.AtlantisYAMLFilename, repoDir)
 		return
 	}
+	if err = p.validateConfigFileRequirement(repo, hasConfigFile); err != nil {
+		return
+	}
 	if !hasConfigFile {
 		if projectName != "" {
 			err = fmt.Errorf("cannot specify a project name unless an %s file exists to configure projects", yaml.AtlantisYAMLFilename)
 			return
 		}
+		projectCfg, globalCfg = p.defaultProjectCfg(repo, nil, dir, workspace)
 		return
 	}
 
-	if !p.AllowRepoConfig {
-		err = fmt.Errorf("%s files not allowed because Atlantis is not running with --%s", yaml.AtlantisYAMLFilename, p.AllowRepoConfigFlag)
-		return
-	}
-
-	globalCfgStruct, err := p.ParserValidator.ReadConfig(repoDir)
+	globalCfgStruct, err := p.ReadRepoConfig(repo, repoDir)
 	if err != nil {
 		return
 	}
@@ -362,6 +896,7 @@ func (p *DefaultProjectCommandBuilder) getCfg(projectName string, dir string, wo
 
 	projCfgs := globalCfg.FindProjectsByDirWorkspace(dir, workspace)
 	if len(projCfgs) == 0 {
+		projectCfg, globalCfg = p.defaultProjectCfg(repo, globalCfg, dir, workspace)
 		return
 	}
 	if len(projCfgs) > 1 {
@@ -372,6 +907,176 @@ func (p *DefaultProjectCommandBuilder) getCfg(projectName string, dir string, wo
 	return
 }
 
+// ReadRepoConfig reads the atlantis.yaml file in repoDir and applies the
+// server-side repo config for repo to it.
+func (p *DefaultProjectCommandBuilder) ReadRepoConfig(repo models.Repo, repoDir string) (valid.Config, error) {
+	repoCfg := p.serverRepoCfg(repo)
+	allowed := p.AllowRepoConfig
+	if repoCfg.AllowRepoConfig != nil {
+		allowed = *repoCfg.AllowRepoConfig
+	}
+	if !allowed {
+		if repoCfg.AllowRepoConfig != nil {
+			return valid.Config{}, fmt.Errorf("%s files not allowed for this repo by the server-side repo config", yaml.AtlantisYAMLFilename)
+		}
+		return valid.Config{}, fmt.Errorf("%s files not allowed because Atlantis is not running with --%s", yaml.AtlantisYAMLFilename, p.AllowRepoConfigFlag)
+	}
+
+	config, err := p.ParserValidator.ReadConfig(repoDir)
+	if err != nil {
+		return valid.Config{}, err
+	}
+	if len(config.Workflows) > 0 && !repoCfg.AllowCustomWorkflows {
+		return valid.Config{}, fmt.Errorf("%s files cannot define workflows for this repo because the server-side repo config doesn't allow custom workflows", yaml.AtlantisYAMLFilename)
+	}
+	for i := range config.Projects {
+		proj := &config.Projects[i]
+		if proj.Workflow == nil {
+			proj.Workflow = repoCfg.Workflow
+		}
+		if proj.Workflow != nil {
+			if _, ok := config.Workflows[*proj.Workflow]; !ok {
+				// It isn't one of the repo's own workflows so it must be a
+				// server-side workflow.
+				if !repoCfg.IsWorkflowAllowed(*proj.Workflow) {
+					return valid.Config{}, fmt.Errorf("workflow %q is not allowed for this repo by the server-side repo config", *proj.Workflow)
+				}
+				if config.Workflows == nil {
+					config.Workflows = make(map[string]valid.Workflow)
+				}
+				config.Workflows[*proj.Workflow] = p.ServerConfig.Workflows[*proj.Workflow]
+			}
+		}
+		p.addApplyRequirements(repoCfg, proj)
+	}
+	return config, nil
+}
+
+// defaultProjectCfg returns the config for the project at dir and workspace
+// when it isn't configured in an atlantis.yaml file. Unless the server-side
+// repo config sets a workflow or (destroy) apply requirements for repo, it
+// returns nil and globalCfg as is.
+func (p *DefaultProjectCommandBuilder) defaultProjectCfg(repo models.Repo, globalCfg *valid.Config, dir string, workspace string) (*valid.Project, *valid.Config) {
+	repoCfg := p.serverRepoCfg(repo)
+	if repoCfg.Workflow == nil && len(repoCfg.ApplyRequirements) == 0 && len(repoCfg.ApplyRequirementGroups) == 0 &&
+		len(repoCfg.DestroyApplyRequirements) == 0 && len(repoCfg.DestroyApplyRequirementGroups) == 0 {
+		return nil, globalCfg
+	}
+	if globalCfg == nil {
+		globalCfg = &valid.Config{Version: 2}
+	}
+	projCfg := &valid.Project{
+		Dir:       dir,
+		Workspace: workspace,
+		Workflow:  repoCfg.Workflow,
+		Autoplan:  valid.Autoplan{Enabled: true},
+	}
+	if repoCfg.Workflow != nil {
+		if _, ok := globalCfg.Workflows[*repoCfg.Workflow]; !ok {
+			if globalCfg.Workflows == nil {
+				globalCfg.Workflows = make(map[string]valid.Workflow)
+			}
+			globalCfg.Workflows[*repoCfg.Workflow] = p.ServerConfig.Workflows[*repoCfg.Workflow]
+		}
+	}
+	p.addApplyRequirements(repoCfg, projCfg)
+	return projCfg, globalCfg
+}
+
+// usesTerragrunt returns true if the project at repoRelDir should be run with
+// the built-in terragrunt workflow: terragrunt is enabled, the project has a
+// terragrunt.hcl file and it isn't configured to use another workflow.
+func (p *DefaultProjectCommandBuilder) usesTerragrunt(repoDir string, repoRelDir string, projCfg *valid.Project) bool {
+	if !p.EnableTerragrunt || (projCfg != nil && projCfg.Workflow != nil) {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(repoDir, repoRelDir, terragruntConfigFilename))
+	return err == nil
+}
+
+// addApplyRequirements adds the apply requirements from the server-side repo
+// config to proj's own. Destroy apply requirements can only be set
+// server-side.
+func (p *DefaultProjectCommandBuilder) addApplyRequirements(repoCfg valid.Repo, proj *valid.Project) {
+	proj.DestroyApplyRequirements = repoCfg.DestroyApplyRequirements
+	proj.DestroyApplyRequirementGroups = repoCfg.DestroyApplyRequirementGroups
+	if len(repoCfg.ApplyRequirements) > 0 {
+		proj.ApplyRequirements = append(append([]string{}, repoCfg.ApplyRequirements...), proj.ApplyRequirements...)
+	}
+	if len(repoCfg.ApplyRequirementGroups) > 0 {
+		proj.ApplyRequirementGroups = append(append([]valid.ApplyRequirementGroup{}, repoCfg.ApplyRequirementGroups...), proj.ApplyRequirementGroups...)
+	}
+}
+
+// serverRepoCfg returns the server-side repo config for repo. If none
+// matches, it returns the defaults.
+func (p *DefaultProjectCommandBuilder) serverRepoCfg(repo models.Repo) valid.Repo {
+	if repoCfg := p.ServerConfig.FindRepo(repo.FullName, repo.VCSHost.Hostname); repoCfg != nil {
+		return *repoCfg
+	}
+	return valid.Repo{AllowCustomWorkflows: true}
+}
+
+// validateConfigFileRequirement returns an error if the server-side repo
+// config requires repo to have an atlantis.yaml file and it doesn't.
+func (p *DefaultProjectCommandBuilder) validateConfigFileRequirement(repo models.Repo, hasConfigFile bool) error {
+	if hasConfigFile {
+		return nil
+	}
+	repoCfg := p.ServerConfig.FindRepo(repo.FullName, repo.VCSHost.Hostname)
+	if repoCfg != nil && repoCfg.RequireAtlantisYAML {
+		return fmt.Errorf("%s file is required for this repo but none was found: add one to configure its projects", yaml.AtlantisYAMLFilename)
+	}
+	return nil
+}
+
+// validateProjectCount returns an error if running cmdName on numProjects
+// projects would exceed the maximum for repo. This protects the server from
+// pull requests that modify huge numbers of projects.
+func (p *DefaultProjectCommandBuilder) validateProjectCount(repo models.Repo, cmdName CommandName, numProjects int) error {
+	max := p.MaxProjectsPerCommand
+	if repoCfg := p.ServerConfig.FindRepo(repo.FullName, repo.VCSHost.Hostname); repoCfg != nil && repoCfg.MaxProjectsPerCommand != nil {
+		max = *repoCfg.MaxProjectsPerCommand
+	}
+	if max <= 0 || numProjects <= max {
+		return nil
+	}
+	return fmt.Errorf("refusing to %s %d projects because the limit for this repo is %d: run %s on specific projects with -d, -w or -p instead", cmdName.String(), numProjects, max, cmdName.String())
+}
+
+// filterProjectDirs returns the commands in cmds whose projects are in
+// ProjectDirs.
+func (p *DefaultProjectCommandBuilder) filterProjectDirs(log *logging.SimpleLogger, cmds []models.ProjectCommandContext) ([]models.ProjectCommandContext, error) {
+	if len(p.ProjectDirs) == 0 {
+		return cmds, nil
+	}
+	var filtered []models.ProjectCommandContext
+	for _, cmd := range cmds {
+		ok, err := p.inProjectDirs(cmd.RepoRelDir)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			log.Debug("ignoring project at dir %q, workspace: %q because it isn't in the project dirs of this Atlantis instance", cmd.RepoRelDir, cmd.Workspace)
+			continue
+		}
+		filtered = append(filtered, cmd)
+	}
+	return filtered, nil
+}
+
+// inProjectDirs returns true if we run the projects in repoRelDir.
+func (p *DefaultProjectCommandBuilder) inProjectDirs(repoRelDir string) (bool, error) {
+	if len(p.ProjectDirs) == 0 {
+		return true, nil
+	}
+	pm, err := fileutils.NewPatternMatcher(p.ProjectDirs)
+	if err != nil {
+		return false, errors.Wrapf(err, "parsing project dirs %v", p.ProjectDirs)
+	}
+	return pm.Matches(filepath.Clean(repoRelDir))
+}
+
 // validateWorkspaceAllowed returns an error if there are projects configured
 // in globalCfg for repoRelDir and none of those projects use workspace.
 func (p *DefaultProjectCommandBuilder) validateWorkspaceAllowed(globalCfg *valid.Config, repoRelDir string, workspace string) error {
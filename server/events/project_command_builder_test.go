@@ -3,6 +3,7 @@ package events_test
 import (
 	"io/ioutil"
 	"path/filepath"
+	"sort"
 	"testing"
 
 	. "github.com/petergtz/pegomock"
@@ -576,6 +577,11 @@ func TestDefaultProjectCommandBuilder_BuildMultiPlanNoAtlantisYAML(t *testing.T)
 	Equals(t, "project2", ctxs[1].RepoRelDir)
 	Equals(t, "default", ctxs[1].Workspace)
 	Equals(t, nilProjectConfig, ctxs[1].ProjectConfig)
+
+	// The modified dirs are checked out before finding the projects, then
+	// the projects' dirs.
+	_, _, checkedOut := workingDir.VerifyWasCalled(Times(2)).CheckoutDirs(matchers.AnyPtrToLoggingSimpleLogger(), AnyString(), AnyStringSlice()).GetAllCapturedArguments()
+	Equals(t, [][]string{{"project1", "project2"}, {"project1", "project2"}}, checkedOut)
 }
 
 // Test building plan command for multiple projects when the comment
@@ -624,6 +630,360 @@ func TestDefaultProjectCommandBuilder_BuildMultiPlanNoAtlantisYAMLNoModified(t *
 	Equals(t, 0, len(ctxs))
 }
 
+// Test that with workspace detection enabled, projects without an
+// atlantis.yaml are planned in the workspaces of their env/{workspace}.tfvars
+// files.
+func TestDefaultProjectCommandBuilder_DetectWorkspaces(t *testing.T) {
+	cases := []struct {
+		description   string
+		detect        bool
+		baseBranch    string
+		modifiedFiles []string
+		exp           []string
+	}{
+		{
+			description:   "detection disabled",
+			modifiedFiles: []string{"project1/main.tf"},
+			exp:           []string{"project1/default"},
+		},
+		{
+			description:   "tf file modified",
+			detect:        true,
+			modifiedFiles: []string{"project1/main.tf"},
+			exp:           []string{"project1/production", "project1/staging"},
+		},
+		{
+			description:   "var file modified",
+			detect:        true,
+			modifiedFiles: []string{"project1/env/staging.tfvars"},
+			exp:           []string{"project1/staging"},
+		},
+		{
+			description:   "deleted var file",
+			detect:        true,
+			modifiedFiles: []string{"project1/env/staging.tfvars", "project1/env/deleted.tfvars"},
+			exp:           []string{"project1/staging"},
+		},
+		{
+			description:   "var file and tf file modified",
+			detect:        true,
+			modifiedFiles: []string{"project1/env/staging.tfvars", "project1/main.tf"},
+			exp:           []string{"project1/production", "project1/staging"},
+		},
+		{
+			description:   "project without var files",
+			detect:        true,
+			modifiedFiles: []string{"project2/main.tf", "project1/env/production.tfvars"},
+			exp:           []string{"project1/production", "project2/default"},
+		},
+		{
+			description:   "base branch mapped to a workspace",
+			baseBranch:    "staging",
+			modifiedFiles: []string{"project1/main.tf"},
+			exp:           []string{"project1/staging"},
+		},
+		{
+			description:   "base branch mapped to a workspace for a project without var files",
+			detect:        true,
+			baseBranch:    "staging",
+			modifiedFiles: []string{"project2/main.tf", "project1/env/production.tfvars"},
+			exp:           []string{"project1/production", "project2/staging"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			tmpDir, cleanup := DirStructure(t, map[string]interface{}{
+				"project1": map[string]interface{}{
+					"main.tf": nil,
+					"env": map[string]interface{}{
+						"staging.tfvars":    nil,
+						"production.tfvars": nil,
+					},
+				},
+				"project2": map[string]interface{}{
+					"main.tf": nil,
+				},
+			})
+			defer cleanup()
+			workingDir := mocks.NewMockWorkingDir()
+			When(workingDir.Clone(
+				matchers.AnyPtrToLoggingSimpleLogger(),
+				matchers.AnyModelsRepo(),
+				matchers.AnyModelsRepo(),
+				matchers.AnyModelsPullRequest(),
+				AnyString())).ThenReturn(tmpDir, nil)
+			vcsClient := vcsmocks.NewMockClientProxy()
+			When(vcsClient.GetModifiedFiles(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest())).ThenReturn(c.modifiedFiles, nil)
+
+			builder := &events.DefaultProjectCommandBuilder{
+				WorkingDirLocker:    events.NewDefaultWorkingDirLocker(),
+				WorkingDir:          workingDir,
+				ParserValidator:     &yaml.ParserValidator{},
+				VCSClient:           vcsClient,
+				ProjectFinder:       &events.DefaultProjectFinder{},
+				AllowRepoConfig:     true,
+				AllowRepoConfigFlag: "allow-repo-config",
+				CommentBuilder:      &events.CommentParser{},
+				ServerConfig: valid.ServerConfig{
+					Repos: []valid.Repo{
+						{ID: "github.com/owner/repo", DetectWorkspaces: c.detect, BranchWorkspaces: map[string]string{"staging": "staging"}},
+					},
+				},
+			}
+
+			ctxs, err := builder.BuildAutoplanCommands(&events.CommandContext{
+				BaseRepo: models.Repo{
+					FullName: "owner/repo",
+					VCSHost:  models.VCSHost{Hostname: "github.com"},
+				},
+				Pull: models.PullRequest{BaseBranch: c.baseBranch},
+				Log:  logging.NewNoopLogger(),
+			})
+			Ok(t, err)
+			var act []string
+			for _, ctx := range ctxs {
+				act = append(act, ctx.RepoRelDir+"/"+ctx.Workspace)
+			}
+			sort.Strings(act)
+			Equals(t, c.exp, act)
+		})
+	}
+}
+
+// Test that comments without -w run in the workspace the server-side repo
+// config maps the pull request's base branch to.
+func TestDefaultProjectCommandBuilder_BranchWorkspaces(t *testing.T) {
+	cases := []struct {
+		description  string
+		baseBranch   string
+		workspace    string
+		expWorkspace string
+	}{
+		{"mapped branch", "staging", "", "staging"},
+		{"unmapped branch", "feature", "", "default"},
+		{"workspace set with -w", "staging", "production", "production"},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			tmpDir, cleanup := DirStructure(t, map[string]interface{}{
+				"project1": map[string]interface{}{"main.tf": nil},
+			})
+			defer cleanup()
+			workingDir := mocks.NewMockWorkingDir()
+			When(workingDir.Clone(
+				matchers.AnyPtrToLoggingSimpleLogger(),
+				matchers.AnyModelsRepo(),
+				matchers.AnyModelsRepo(),
+				matchers.AnyModelsPullRequest(),
+				AnyString())).ThenReturn(tmpDir, nil)
+			builder := &events.DefaultProjectCommandBuilder{
+				WorkingDirLocker:    events.NewDefaultWorkingDirLocker(),
+				WorkingDir:          workingDir,
+				ParserValidator:     &yaml.ParserValidator{},
+				ProjectFinder:       &events.DefaultProjectFinder{},
+				AllowRepoConfig:     true,
+				AllowRepoConfigFlag: "allow-repo-config",
+				CommentBuilder:      &events.CommentParser{},
+				ServerConfig: valid.ServerConfig{
+					Repos: []valid.Repo{
+						{ID: "github.com/owner/repo", BranchWorkspaces: map[string]string{"staging": "staging"}},
+					},
+				},
+			}
+
+			cmds, err := builder.BuildPlanCommands(&events.CommandContext{
+				BaseRepo: models.Repo{
+					FullName: "owner/repo",
+					VCSHost:  models.VCSHost{Hostname: "github.com"},
+				},
+				Pull: models.PullRequest{BaseBranch: c.baseBranch},
+				Log:  logging.NewNoopLogger(),
+			}, &events.CommentCommand{
+				RepoRelDir: "project1",
+				Name:       events.PlanCommand,
+				Workspace:  c.workspace,
+			})
+			Ok(t, err)
+			Equals(t, 1, len(cmds))
+			Equals(t, c.expWorkspace, cmds[0].Workspace)
+			workingDir.VerifyWasCalledOnce().Clone(
+				matchers.AnyPtrToLoggingSimpleLogger(),
+				matchers.AnyModelsRepo(),
+				matchers.AnyModelsRepo(),
+				matchers.AnyModelsPullRequest(),
+				EqString(c.expWorkspace))
+		})
+	}
+}
+
+// Test that atlantis plan -d with a glob plans the projects whose dirs match,
+// whether or not they were modified.
+// Test that destroy and targeted plans keep -destroy and -target in their
+// re-plan commands, and destroy plans get the destroy apply requirements from
+// the server-side repo config.
+func TestDefaultProjectCommandBuilder_BuildPlanDestroy(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmpDir, cleanup := DirStructure(t, map[string]interface{}{
+		"dir": map[string]interface{}{"main.tf": nil},
+	})
+	defer cleanup()
+	workingDir := mocks.NewMockWorkingDir()
+	When(workingDir.Clone(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString())).ThenReturn(tmpDir, nil)
+	builder := &events.DefaultProjectCommandBuilder{
+		WorkingDirLocker:    events.NewDefaultWorkingDirLocker(),
+		WorkingDir:          workingDir,
+		ParserValidator:     &yaml.ParserValidator{},
+		VCSClient:           vcsmocks.NewMockClientProxy(),
+		ProjectFinder:       &events.DefaultProjectFinder{},
+		AllowRepoConfig:     true,
+		AllowRepoConfigFlag: "allow-repo-config",
+		CommentBuilder:      &events.CommentParser{},
+		ServerConfig: valid.ServerConfig{
+			Repos: []valid.Repo{{ID: "github.com/owner/repo", DestroyApplyRequirements: []string{"mergeable"}}},
+		},
+	}
+
+	ctxs, err := builder.BuildPlanCommands(&events.CommandContext{
+		Log:      logging.NewNoopLogger(),
+		BaseRepo: models.Repo{FullName: "owner/repo", VCSHost: models.VCSHost{Hostname: "github.com"}},
+	}, &events.CommentCommand{
+		RepoRelDir: "dir",
+		Name:       events.PlanCommand,
+		Flags:      []string{`"-var"`, `"a=b"`},
+		Destroy:    true,
+		Targets:    []string{"aws_instance.web"},
+	})
+	Ok(t, err)
+	Equals(t, 1, len(ctxs))
+	Equals(t, true, ctxs[0].Destroy)
+	Equals(t, []string{"aws_instance.web"}, ctxs[0].Targets)
+	Equals(t, "atlantis plan -d dir -destroy -target=aws_instance.web -- -var a=b", ctxs[0].RePlanCmd)
+	Equals(t, []string{"mergeable"}, ctxs[0].ProjectConfig.DestroyApplyRequirements)
+}
+
+func TestDefaultProjectCommandBuilder_BuildPlanDirGlob(t *testing.T) {
+	cases := []struct {
+		description  string
+		atlantisYAML string
+		dir          string
+		workspace    string
+		exp          []string
+		expErr       string
+	}{
+		{
+			description: "no atlantis.yaml",
+			dir:         "stacks/prod/*",
+			exp:         []string{"stacks/prod/db/default", "stacks/prod/web/default"},
+		},
+		{
+			description: "no atlantis.yaml with workspace",
+			dir:         "stacks/*/web",
+			workspace:   "blue",
+			exp:         []string{"stacks/prod/web/blue", "stacks/staging/web/blue"},
+		},
+		{
+			description: "atlantis.yaml",
+			atlantisYAML: `
+version: 2
+projects:
+- dir: modules/prod/db
+- dir: modules/prod/web
+  workspace: blue
+- dir: modules/prod/web
+  workspace: green
+- dir: modules/staging/web`,
+			dir: "modules/prod/*",
+			exp: []string{"modules/prod/db/default", "modules/prod/web/blue", "modules/prod/web/green"},
+		},
+		{
+			description: "atlantis.yaml with workspace",
+			atlantisYAML: `
+version: 2
+projects:
+- dir: modules/prod/web
+  workspace: blue
+- dir: modules/prod/web
+  workspace: green`,
+			dir:       "modules/prod/*",
+			workspace: "green",
+			exp:       []string{"modules/prod/web/green"},
+		},
+		{
+			description: "no matches",
+			dir:         "other/*",
+			expErr:      `no projects have a dir that matches "other/*"`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			stacks := map[string]interface{}{
+				"prod": map[string]interface{}{
+					"db":  map[string]interface{}{"main.tf": nil},
+					"web": map[string]interface{}{"main.tf": nil},
+				},
+				"staging": map[string]interface{}{
+					"web": map[string]interface{}{"main.tf": nil},
+				},
+			}
+			tmpDir, cleanup := DirStructure(t, map[string]interface{}{
+				"modules": stacks,
+				"stacks":  stacks,
+			})
+			defer cleanup()
+			if c.atlantisYAML != "" {
+				Ok(t, ioutil.WriteFile(filepath.Join(tmpDir, yaml.AtlantisYAMLFilename), []byte(c.atlantisYAML), 0600))
+			}
+			workingDir := mocks.NewMockWorkingDir()
+			When(workingDir.Clone(
+				matchers.AnyPtrToLoggingSimpleLogger(),
+				matchers.AnyModelsRepo(),
+				matchers.AnyModelsRepo(),
+				matchers.AnyModelsPullRequest(),
+				AnyString())).ThenReturn(tmpDir, nil)
+
+			builder := &events.DefaultProjectCommandBuilder{
+				WorkingDirLocker:    events.NewDefaultWorkingDirLocker(),
+				WorkingDir:          workingDir,
+				ParserValidator:     &yaml.ParserValidator{},
+				VCSClient:           vcsmocks.NewMockClientProxy(),
+				ProjectFinder:       &events.DefaultProjectFinder{},
+				AllowRepoConfig:     true,
+				AllowRepoConfigFlag: "allow-repo-config",
+				CommentBuilder:      &events.CommentParser{},
+			}
+
+			ctxs, err := builder.BuildPlanCommands(&events.CommandContext{
+				Log: logging.NewNoopLogger(),
+			}, &events.CommentCommand{
+				RepoRelDir: c.dir,
+				Name:       events.PlanCommand,
+				Workspace:  c.workspace,
+			})
+			if c.expErr != "" {
+				ErrEquals(t, c.expErr, err)
+				return
+			}
+			Ok(t, err)
+			var act []string
+			for _, ctx := range ctxs {
+				act = append(act, ctx.RepoRelDir+"/"+ctx.Workspace)
+			}
+			sort.Strings(act)
+			Equals(t, c.exp, act)
+		})
+	}
+}
+
 // Test building plan command for multiple projects when the comment
 // isn't for a specific project, i.e. atlantis plan and there is an atlantis.yaml.
 // In this case we should follow the when_modified section of the autoplan config.
@@ -839,6 +1199,59 @@ func TestDefaultProjectCommandBuilder_BuildMultiApply(t *testing.T) {
 	Equals(t, "workspace2", ctxs[3].Workspace)
 }
 
+// Test that apply can be run for several projects at once, ex.
+// atlantis apply -p project1,project2.
+func TestDefaultProjectCommandBuilder_BuildApplyProjects(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmpDir, cleanup := DirStructure(t, map[string]interface{}{
+		"project1": map[string]interface{}{
+			"main.tf": nil,
+		},
+		"project2": map[string]interface{}{
+			"main.tf": nil,
+		},
+		"project3": map[string]interface{}{
+			"main.tf": nil,
+		},
+	})
+	defer cleanup()
+	Ok(t, ioutil.WriteFile(filepath.Join(tmpDir, yaml.AtlantisYAMLFilename), []byte(`
+version: 2
+projects:
+- name: project1
+  dir: project1
+- name: project2
+  dir: project2
+- name: project3
+  dir: project3
+`), 0600))
+
+	workingDir := mocks.NewMockWorkingDir()
+	When(workingDir.GetWorkingDir(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), AnyString())).ThenReturn(tmpDir, nil)
+	builder := &events.DefaultProjectCommandBuilder{
+		WorkingDirLocker:    events.NewDefaultWorkingDirLocker(),
+		WorkingDir:          workingDir,
+		ParserValidator:     &yaml.ParserValidator{},
+		ProjectFinder:       &events.DefaultProjectFinder{},
+		AllowRepoConfig:     true,
+		AllowRepoConfigFlag: "allow-repo-config",
+		PendingPlanFinder:   &events.PendingPlanFinder{},
+		CommentBuilder:      &events.CommentParser{},
+	}
+	cmdCtx := &events.CommandContext{Log: logging.NewNoopLogger()}
+
+	ctxs, err := builder.BuildApplyCommands(cmdCtx, &events.CommentCommand{Name: events.ApplyCommand, ProjectName: "project3,project1"})
+	Ok(t, err)
+	Equals(t, 2, len(ctxs))
+	Equals(t, "project3", ctxs[0].GetProjectName())
+	Equals(t, "project3", ctxs[0].RepoRelDir)
+	Equals(t, "project1", ctxs[1].GetProjectName())
+	Equals(t, "project1", ctxs[1].RepoRelDir)
+
+	_, err = builder.BuildApplyCommands(cmdCtx, &events.CommentCommand{Name: events.ApplyCommand, ProjectName: "project1,missing"})
+	ErrContains(t, `building command for project "missing"`, err)
+}
+
 // Test that if repo config is disabled we error out if there's an atlantis.yaml
 // file.
 func TestDefaultProjectCommandBuilder_RepoConfigDisabled(t *testing.T) {
@@ -967,3 +1380,589 @@ projects:
 }
 
 func String(v string) *string { return &v }
+
+func Int(v int) *int { return &v }
+
+func Bool(v bool) *bool { return &v }
+
+// Test that repos the server-side config requires an atlantis.yaml for error
+// out instead of auto-discovering projects.
+func TestDefaultProjectCommandBuilder_RequireAtlantisYAML(t *testing.T) {
+	RegisterMockTestingT(t)
+	workingDir := mocks.NewMockWorkingDir()
+
+	tmpDir, cleanup := DirStructure(t, map[string]interface{}{
+		"main.tf": nil,
+	})
+	defer cleanup()
+
+	When(workingDir.Clone(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString())).ThenReturn(tmpDir, nil)
+	When(workingDir.GetWorkingDir(
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString())).ThenReturn(tmpDir, nil)
+
+	builder := &events.DefaultProjectCommandBuilder{
+		WorkingDirLocker:    events.NewDefaultWorkingDirLocker(),
+		WorkingDir:          workingDir,
+		ParserValidator:     &yaml.ParserValidator{},
+		VCSClient:           nil,
+		ProjectFinder:       &events.DefaultProjectFinder{},
+		AllowRepoConfig:     true,
+		AllowRepoConfigFlag: "allow-repo-config",
+		CommentBuilder:      &events.CommentParser{},
+		ServerConfig: valid.ServerConfig{
+			Repos: []valid.Repo{
+				{ID: "github.com/governed/*", RequireAtlantisYAML: true},
+			},
+		},
+	}
+
+	ctx := &events.CommandContext{
+		BaseRepo: models.Repo{
+			FullName: "governed/repo",
+			VCSHost:  models.VCSHost{Hostname: "github.com"},
+		},
+		Log: logging.NewNoopLogger(),
+	}
+	expErr := "atlantis.yaml file is required for this repo but none was found: add one to configure its projects"
+	_, err := builder.BuildAutoplanCommands(ctx)
+	ErrEquals(t, expErr, err)
+
+	commentCmd := &events.CommentCommand{
+		RepoRelDir: ".",
+		Name:       events.PlanCommand,
+		Workspace:  "default",
+	}
+	_, err = builder.BuildPlanCommands(ctx, commentCmd)
+	ErrEquals(t, expErr, err)
+
+	t.Log("repos that don't match should still be able to plan")
+	ctx.BaseRepo.FullName = "other/repo"
+	cmds, err := builder.BuildPlanCommands(ctx, commentCmd)
+	Ok(t, err)
+	Equals(t, 1, len(cmds))
+}
+
+// Test that commands that would run more projects than the limit are refused
+// and that the server-side repo config can override the limit.
+func TestDefaultProjectCommandBuilder_MaxProjectsPerCommand(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmpDir, cleanup := DirStructure(t, map[string]interface{}{
+		"project1": map[string]interface{}{
+			"main.tf": nil,
+		},
+		"project2": map[string]interface{}{
+			"main.tf": nil,
+		},
+	})
+	defer cleanup()
+
+	workingDir := mocks.NewMockWorkingDir()
+	When(workingDir.Clone(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString())).ThenReturn(tmpDir, nil)
+	vcsClient := vcsmocks.NewMockClientProxy()
+	When(vcsClient.GetModifiedFiles(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest())).ThenReturn([]string{"project1/main.tf", "project2/main.tf"}, nil)
+
+	builder := &events.DefaultProjectCommandBuilder{
+		WorkingDirLocker:      events.NewDefaultWorkingDirLocker(),
+		WorkingDir:            workingDir,
+		ParserValidator:       &yaml.ParserValidator{},
+		VCSClient:             vcsClient,
+		ProjectFinder:         &events.DefaultProjectFinder{},
+		AllowRepoConfig:       true,
+		AllowRepoConfigFlag:   "allow-repo-config",
+		CommentBuilder:        &events.CommentParser{},
+		MaxProjectsPerCommand: 1,
+		ServerConfig: valid.ServerConfig{
+			Repos: []valid.Repo{
+				{ID: "github.com/owner/monorepo", MaxProjectsPerCommand: Int(2)},
+			},
+		},
+	}
+
+	ctx := &events.CommandContext{
+		BaseRepo: models.Repo{
+			FullName: "owner/repo",
+			VCSHost:  models.VCSHost{Hostname: "github.com"},
+		},
+		Log: logging.NewNoopLogger(),
+	}
+	_, err := builder.BuildAutoplanCommands(ctx)
+	ErrEquals(t, "refusing to plan 2 projects because the limit for this repo is 1: run plan on specific projects with -d, -w or -p instead", err)
+
+	_, err = builder.BuildPlanCommands(ctx, &events.CommentCommand{Name: events.PlanCommand})
+	ErrEquals(t, "refusing to plan 2 projects because the limit for this repo is 1: run plan on specific projects with -d, -w or -p instead", err)
+
+	t.Log("a specific project is always allowed")
+	cmds, err := builder.BuildPlanCommands(ctx, &events.CommentCommand{Name: events.PlanCommand, RepoRelDir: "project1"})
+	Ok(t, err)
+	Equals(t, 1, len(cmds))
+
+	t.Log("the repo's limit should override the server's")
+	ctx.BaseRepo.FullName = "owner/monorepo"
+	cmds, err = builder.BuildAutoplanCommands(ctx)
+	Ok(t, err)
+	Equals(t, 2, len(cmds))
+}
+
+func TestDefaultProjectCommandBuilder_ProjectDirs(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmpDir, cleanup := DirStructure(t, map[string]interface{}{
+		"prod": map[string]interface{}{
+			"app": map[string]interface{}{
+				"main.tf": nil,
+			},
+		},
+		"staging": map[string]interface{}{
+			"main.tf": nil,
+		},
+	})
+	defer cleanup()
+
+	workingDir := mocks.NewMockWorkingDir()
+	When(workingDir.Clone(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString())).ThenReturn(tmpDir, nil)
+	When(workingDir.GetWorkingDir(
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString())).ThenReturn(tmpDir, nil)
+	vcsClient := vcsmocks.NewMockClientProxy()
+	When(vcsClient.GetModifiedFiles(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest())).ThenReturn([]string{"prod/app/main.tf", "staging/main.tf"}, nil)
+
+	builder := &events.DefaultProjectCommandBuilder{
+		WorkingDirLocker:    events.NewDefaultWorkingDirLocker(),
+		WorkingDir:          workingDir,
+		ParserValidator:     &yaml.ParserValidator{},
+		VCSClient:           vcsClient,
+		ProjectFinder:       &events.DefaultProjectFinder{},
+		AllowRepoConfig:     true,
+		AllowRepoConfigFlag: "allow-repo-config",
+		CommentBuilder:      &events.CommentParser{},
+		ProjectDirs:         []string{"prod/**"},
+	}
+
+	ctx := &events.CommandContext{
+		BaseRepo: models.Repo{
+			FullName: "owner/repo",
+			VCSHost:  models.VCSHost{Hostname: "github.com"},
+		},
+		Log: logging.NewNoopLogger(),
+	}
+	cmds, err := builder.BuildAutoplanCommands(ctx)
+	Ok(t, err)
+	Equals(t, 1, len(cmds))
+	Equals(t, "prod/app", cmds[0].RepoRelDir)
+
+	t.Log("projects outside of the project dirs can't be run directly")
+	_, err = builder.BuildPlanCommands(ctx, &events.CommentCommand{Name: events.PlanCommand, RepoRelDir: "staging", Workspace: "default"})
+	ErrEquals(t, `dir "staging" isn't run by this Atlantis instance because it doesn't match any of prod/**`, err)
+
+	cmds, err = builder.BuildPlanCommands(ctx, &events.CommentCommand{Name: events.PlanCommand, RepoRelDir: "prod/app", Workspace: "default"})
+	Ok(t, err)
+	Equals(t, 1, len(cmds))
+}
+
+func TestDefaultProjectCommandBuilder_BuildApprovePoliciesCommands(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmpDir, cleanup := DirStructure(t, map[string]interface{}{
+		"default": map[string]interface{}{
+			"project1": map[string]interface{}{
+				"main.tf":            nil,
+				"default.tfplan":     nil,
+				"default.policyfail": nil,
+			},
+			"project2": map[string]interface{}{
+				"main.tf":        nil,
+				"default.tfplan": nil,
+			},
+		},
+	})
+	defer cleanup()
+	runCmd(t, filepath.Join(tmpDir, "default"), "git", "init")
+
+	workingDir := mocks.NewMockWorkingDir()
+	When(workingDir.GetPullDir(
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest())).
+		ThenReturn(tmpDir, nil)
+	When(workingDir.GetWorkingDir(
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString())).
+		ThenReturn(filepath.Join(tmpDir, "default"), nil)
+
+	builder := &events.DefaultProjectCommandBuilder{
+		WorkingDirLocker:    events.NewDefaultWorkingDirLocker(),
+		WorkingDir:          workingDir,
+		ParserValidator:     &yaml.ParserValidator{},
+		ProjectFinder:       &events.DefaultProjectFinder{},
+		AllowRepoConfig:     true,
+		AllowRepoConfigFlag: "allow-repo-config",
+		PendingPlanFinder:   &events.PendingPlanFinder{},
+		CommentBuilder:      &events.CommentParser{},
+		ServerConfig: valid.ServerConfig{
+			Repos: []valid.Repo{
+				{ID: "github.com/owner/repo", PolicyOwners: []string{"owner"}},
+			},
+		},
+	}
+	ctx := &events.CommandContext{
+		BaseRepo: models.Repo{
+			FullName: "owner/repo",
+			VCSHost:  models.VCSHost{Hostname: "github.com"},
+		},
+		User: models.User{Username: "mallory"},
+		Log:  logging.NewNoopLogger(),
+	}
+	cmd := &events.CommentCommand{Name: events.ApprovePoliciesCommand}
+
+	_, err := builder.BuildApprovePoliciesCommands(ctx, cmd)
+	ErrEquals(t, "user mallory is not a policy owner for this repo: policy owners are set with policy_owners in the server-side repo config", err)
+
+	t.Log("only the plans that failed should be approved")
+	ctx.User.Username = "owner"
+	ctxs, err := builder.BuildApprovePoliciesCommands(ctx, cmd)
+	Ok(t, err)
+	Equals(t, 1, len(ctxs))
+	Equals(t, "project1", ctxs[0].RepoRelDir)
+	Equals(t, "default", ctxs[0].Workspace)
+}
+
+// Test that the workflows, apply requirements and atlantis.yaml settings in
+// the server-side repo config are applied to the projects.
+func TestDefaultProjectCommandBuilder_ServerSideRepoConfig(t *testing.T) {
+	serverWorkflows := map[string]valid.Workflow{
+		"terragrunt": {Plan: &valid.Stage{Steps: []valid.Step{{StepName: "run", RunCommand: []string{"terragrunt", "plan"}}}}},
+		"custom":     {Apply: &valid.Stage{Steps: []valid.Step{{StepName: "apply"}}}},
+	}
+	cases := []struct {
+		description  string
+		atlantisYAML string
+		repoCfg      valid.Repo
+		expErr       string
+		expWorkflow  *string
+		expApplyReqs []string
+	}{
+		{
+			description: "no atlantis.yaml and no server config",
+			repoCfg:     valid.Repo{ID: "github.com/owner/repo", AllowCustomWorkflows: true},
+		},
+		{
+			description:  "no atlantis.yaml uses the repo's workflow and apply requirements",
+			repoCfg:      valid.Repo{ID: "github.com/owner/repo", Workflow: String("terragrunt"), ApplyRequirements: []string{"approved"}},
+			expWorkflow:  String("terragrunt"),
+			expApplyReqs: []string{"approved"},
+		},
+		{
+			description: "atlantis.yaml not allowed for the repo",
+			atlantisYAML: `
+version: 2
+projects:
+- dir: .`,
+			repoCfg: valid.Repo{ID: "github.com/owner/repo", AllowRepoConfig: Bool(false)},
+			expErr:  "atlantis.yaml files not allowed for this repo by the server-side repo config",
+		},
+		{
+			description: "project uses the repo's workflow and adds its apply requirements",
+			atlantisYAML: `
+version: 2
+projects:
+- dir: .
+  apply_requirements: [mergeable]`,
+			repoCfg:      valid.Repo{ID: "github.com/owner/repo", Workflow: String("terragrunt"), ApplyRequirements: []string{"approved"}},
+			expWorkflow:  String("terragrunt"),
+			expApplyReqs: []string{"approved", "mergeable"},
+		},
+		{
+			description: "project uses an allowed workflow",
+			atlantisYAML: `
+version: 2
+projects:
+- dir: .
+  workflow: custom`,
+			repoCfg:     valid.Repo{ID: "github.com/owner/repo", Workflow: String("terragrunt"), AllowedWorkflows: []string{"custom"}},
+			expWorkflow: String("custom"),
+		},
+		{
+			description: "project uses a workflow that isn't allowed",
+			atlantisYAML: `
+version: 2
+projects:
+- dir: .
+  workflow: custom`,
+			repoCfg: valid.Repo{ID: "github.com/owner/repo", Workflow: String("terragrunt")},
+			expErr:  "workflow \"custom\" is not allowed for this repo by the server-side repo config",
+		},
+		{
+			description: "custom workflows not allowed",
+			atlantisYAML: `
+version: 2
+projects:
+- dir: .
+  workflow: mine
+workflows:
+  mine:
+    plan:
+      steps: [plan]`,
+			repoCfg: valid.Repo{ID: "github.com/owner/repo", AllowCustomWorkflows: false},
+			expErr:  "atlantis.yaml files cannot define workflows for this repo because the server-side repo config doesn't allow custom workflows",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			tmpDir, cleanup := DirStructure(t, map[string]interface{}{
+				"main.tf": nil,
+			})
+			defer cleanup()
+			if c.atlantisYAML != "" {
+				Ok(t, ioutil.WriteFile(filepath.Join(tmpDir, "atlantis.yaml"), []byte(c.atlantisYAML), 0600))
+			}
+
+			workingDir := mocks.NewMockWorkingDir()
+			When(workingDir.Clone(
+				matchers.AnyPtrToLoggingSimpleLogger(),
+				matchers.AnyModelsRepo(),
+				matchers.AnyModelsRepo(),
+				matchers.AnyModelsPullRequest(),
+				AnyString())).ThenReturn(tmpDir, nil)
+			builder := &events.DefaultProjectCommandBuilder{
+				WorkingDirLocker:    events.NewDefaultWorkingDirLocker(),
+				WorkingDir:          workingDir,
+				ParserValidator:     &yaml.ParserValidator{ServerWorkflows: []string{"custom", "terragrunt"}},
+				ProjectFinder:       &events.DefaultProjectFinder{},
+				AllowRepoConfig:     true,
+				AllowRepoConfigFlag: "allow-repo-config",
+				CommentBuilder:      &events.CommentParser{},
+				ServerConfig: valid.ServerConfig{
+					Repos:     []valid.Repo{c.repoCfg},
+					Workflows: serverWorkflows,
+				},
+			}
+
+			cmds, err := builder.BuildPlanCommands(&events.CommandContext{
+				BaseRepo: models.Repo{
+					FullName: "owner/repo",
+					VCSHost:  models.VCSHost{Hostname: "github.com"},
+				},
+				Log: logging.NewNoopLogger(),
+			}, &events.CommentCommand{
+				RepoRelDir: ".",
+				Name:       events.PlanCommand,
+				Workspace:  "default",
+			})
+			if c.expErr != "" {
+				ErrEquals(t, c.expErr, err)
+				return
+			}
+			Ok(t, err)
+			Equals(t, 1, len(cmds))
+			projCfg := cmds[0].ProjectConfig
+			if c.expWorkflow == nil && c.expApplyReqs == nil {
+				Assert(t, projCfg == nil || projCfg.Workflow == nil, "exp no workflow")
+				return
+			}
+			Equals(t, c.expWorkflow, projCfg.Workflow)
+			Equals(t, c.expApplyReqs, projCfg.ApplyRequirements)
+			if c.expWorkflow != nil {
+				Equals(t, serverWorkflows[*c.expWorkflow], cmds[0].GlobalConfig.Workflows[*c.expWorkflow])
+			}
+		})
+	}
+}
+
+// Projects should only be autoplanned for pull requests into the branches
+// their branch regex, or the server-side repo config's, matches.
+func TestDefaultProjectCommandBuilder_AutoplanBranch(t *testing.T) {
+	atlantisYAML := `
+version: 2
+projects:
+- name: main
+  dir: .
+- name: release
+  dir: .
+  workspace: release
+  branch: /^release/.*$/`
+	cases := []struct {
+		description  string
+		atlantisYAML string
+		repoBranch   *string
+		baseBranch   string
+		expProjects  []string
+	}{
+		{
+			description:  "project branch matches",
+			atlantisYAML: atlantisYAML,
+			baseBranch:   "release/1.0",
+			expProjects:  []string{"main", "release"},
+		},
+		{
+			description:  "project branch doesn't match",
+			atlantisYAML: atlantisYAML,
+			baseBranch:   "feature",
+			expProjects:  []string{"main"},
+		},
+		{
+			description:  "server-side default for projects without a branch",
+			atlantisYAML: atlantisYAML,
+			repoBranch:   String("^main$"),
+			baseBranch:   "release/1.0",
+			expProjects:  []string{"release"},
+		},
+		{
+			description:  "base branch not known",
+			atlantisYAML: atlantisYAML,
+			repoBranch:   String("^main$"),
+			expProjects:  []string{"main", "release"},
+		},
+		{
+			description: "no atlantis.yaml and server-side branch matches",
+			repoBranch:  String("^main$"),
+			baseBranch:  "main",
+			expProjects: []string{""},
+		},
+		{
+			description: "no atlantis.yaml and server-side branch doesn't match",
+			repoBranch:  String("^main$"),
+			baseBranch:  "feature",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			tmpDir, cleanup := DirStructure(t, map[string]interface{}{
+				"main.tf": nil,
+			})
+			defer cleanup()
+			if c.atlantisYAML != "" {
+				Ok(t, ioutil.WriteFile(filepath.Join(tmpDir, "atlantis.yaml"), []byte(c.atlantisYAML), 0600))
+			}
+
+			workingDir := mocks.NewMockWorkingDir()
+			When(workingDir.Clone(
+				matchers.AnyPtrToLoggingSimpleLogger(),
+				matchers.AnyModelsRepo(),
+				matchers.AnyModelsRepo(),
+				matchers.AnyModelsPullRequest(),
+				AnyString())).ThenReturn(tmpDir, nil)
+			vcsClient := vcsmocks.NewMockClientProxy()
+			When(vcsClient.GetModifiedFiles(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest())).ThenReturn([]string{"main.tf"}, nil)
+			builder := &events.DefaultProjectCommandBuilder{
+				WorkingDirLocker:    events.NewDefaultWorkingDirLocker(),
+				WorkingDir:          workingDir,
+				ParserValidator:     &yaml.ParserValidator{},
+				VCSClient:           vcsClient,
+				ProjectFinder:       &events.DefaultProjectFinder{},
+				AllowRepoConfig:     true,
+				AllowRepoConfigFlag: "allow-repo-config",
+				CommentBuilder:      &events.CommentParser{},
+				ServerConfig: valid.ServerConfig{
+					Repos: []valid.Repo{{ID: "github.com/owner/repo", AllowCustomWorkflows: true, Branch: c.repoBranch}},
+				},
+			}
+
+			cmds, err := builder.BuildAutoplanCommands(&events.CommandContext{
+				BaseRepo: models.Repo{
+					FullName: "owner/repo",
+					VCSHost:  models.VCSHost{Hostname: "github.com"},
+				},
+				Pull: models.PullRequest{BaseBranch: c.baseBranch},
+				Log:  logging.NewNoopLogger(),
+			})
+			Ok(t, err)
+			var projects []string
+			for _, cmd := range cmds {
+				projects = append(projects, cmd.GetProjectName())
+			}
+			Equals(t, c.expProjects, projects)
+		})
+	}
+}
+
+// With terragrunt enabled, projects with a terragrunt.hcl file should be run
+// with terragrunt unless they're configured to use a workflow.
+func TestDefaultProjectCommandBuilder_Terragrunt(t *testing.T) {
+	cases := []struct {
+		description   string
+		repoCfg       valid.Repo
+		expTerragrunt []bool
+	}{
+		{
+			description:   "no workflow",
+			repoCfg:       valid.Repo{ID: "github.com/owner/repo"},
+			expTerragrunt: []bool{true, false},
+		},
+		{
+			description:   "server-side workflow",
+			repoCfg:       valid.Repo{ID: "github.com/owner/repo", Workflow: String("custom")},
+			expTerragrunt: []bool{false, false},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			tmpDir, cleanup := DirStructure(t, map[string]interface{}{
+				"project1": map[string]interface{}{
+					"terragrunt.hcl": nil,
+					"main.tf":        nil,
+				},
+				"project2": map[string]interface{}{
+					"main.tf": nil,
+				},
+			})
+			defer cleanup()
+			workingDir := mocks.NewMockWorkingDir()
+			When(workingDir.Clone(
+				matchers.AnyPtrToLoggingSimpleLogger(),
+				matchers.AnyModelsRepo(),
+				matchers.AnyModelsRepo(),
+				matchers.AnyModelsPullRequest(),
+				AnyString())).ThenReturn(tmpDir, nil)
+			vcsClient := vcsmocks.NewMockClientProxy()
+			When(vcsClient.GetModifiedFiles(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest())).ThenReturn([]string{"project1/terragrunt.hcl", "project2/main.tf"}, nil)
+
+			builder := &events.DefaultProjectCommandBuilder{
+				WorkingDirLocker:    events.NewDefaultWorkingDirLocker(),
+				WorkingDir:          workingDir,
+				ParserValidator:     &yaml.ParserValidator{},
+				VCSClient:           vcsClient,
+				ProjectFinder:       &events.DefaultProjectFinder{EnableTerragrunt: true},
+				AllowRepoConfig:     true,
+				AllowRepoConfigFlag: "allow-repo-config",
+				CommentBuilder:      &events.CommentParser{},
+				EnableTerragrunt:    true,
+				ServerConfig: valid.ServerConfig{
+					Repos:     []valid.Repo{c.repoCfg},
+					Workflows: map[string]valid.Workflow{"custom": {}},
+				},
+			}
+
+			ctxs, err := builder.BuildAutoplanCommands(&events.CommandContext{
+				BaseRepo: models.Repo{
+					FullName: "owner/repo",
+					VCSHost:  models.VCSHost{Hostname: "github.com"},
+				},
+				Log: logging.NewNoopLogger(),
+			})
+			Ok(t, err)
+			Equals(t, 2, len(ctxs))
+			for i, exp := range c.expTerragrunt {
+				Equals(t, exp, ctxs[i].Terragrunt)
+			}
+		})
+	}
+}
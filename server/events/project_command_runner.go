@@ -14,20 +14,35 @@
 package events
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/hashicorp/go-version"
 	"github.com/pkg/errors"
 	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/plans"
 	"github.com/runatlantis/atlantis/server/events/runtime"
+	"github.com/runatlantis/atlantis/server/events/terraform"
 	"github.com/runatlantis/atlantis/server/events/webhooks"
 	"github.com/runatlantis/atlantis/server/events/yaml/raw"
 	"github.com/runatlantis/atlantis/server/events/yaml/valid"
 	"github.com/runatlantis/atlantis/server/logging"
 )
 
+// maxTFLogLines is the maximum number of lines of a terraform log that we'll
+// comment.
+const maxTFLogLines = 500
+
+// DefaultDestroyApplyRequirements are what destroy plans must meet before
+// they can be applied unless the server-side repo config sets
+// destroy_apply_requirements.
+var DefaultDestroyApplyRequirements = []string{raw.ApprovedApplyRequirement}
+
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_lock_url_generator.go LockURLGenerator
 
 // LockURLGenerator generates urls to locks.
@@ -36,6 +51,14 @@ type LockURLGenerator interface {
 	GenerateLockURL(lockID string) string
 }
 
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_plan_url_generator.go PlanURLGenerator
+
+// PlanURLGenerator generates urls to the outputs of plans.
+type PlanURLGenerator interface {
+	// GeneratePlanURL returns the full URL to the plan with planID.
+	GeneratePlanURL(planID string) string
+}
+
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_step_runner.go StepRunner
 
 // StepRunner runs steps. Steps are individual pieces of execution like
@@ -50,7 +73,48 @@ type StepRunner interface {
 // WebhooksSender sends webhook.
 type WebhooksSender interface {
 	// Send sends the webhook.
-	Send(log *logging.SimpleLogger, res webhooks.ApplyResult) error
+	Send(log *logging.SimpleLogger, res webhooks.Result) error
+}
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_job_tracker.go JobTracker
+
+// JobTracker records the plans and applies we run so they can be viewed in
+// the UI.
+type JobTracker interface {
+	// Start records that job has started and returns its ID.
+	Start(job models.Job) string
+	// AppendOutput adds line to the output of the job with id.
+	AppendOutput(id string, line string)
+	// Finish records that the job with id has finished. success is whether
+	// it succeeded.
+	Finish(id string, success bool)
+}
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_audit_recorder.go AuditRecorder
+
+// AuditRecorder records the commands we run to the audit log.
+type AuditRecorder interface {
+	// Record adds event to the audit log.
+	Record(event models.AuditEvent)
+}
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_terraform_version_resolver.go TerraformVersionResolver
+
+// TerraformVersionResolver figures out which terraform version to run a
+// project with.
+type TerraformVersionResolver interface {
+	// ResolveVersion returns the version to run the project in projAbsPath
+	// with and makes sure it's available. configured is the version set in
+	// the project's config, if any. It returns nil if the default version
+	// should be used.
+	ResolveVersion(log *logging.SimpleLogger, projAbsPath string, configured *version.Version) (*version.Version, error)
+}
+
+// TerraformCloudRunReader reads the results of Terraform Cloud runs.
+type TerraformCloudRunReader interface {
+	// ReadRunChecks returns the cost estimate and policy checks of the run
+	// with id runID on the Terraform Cloud or Enterprise at hostname.
+	ReadRunChecks(hostname string, runID string) (*terraform.CloudRunChecks, error)
 }
 
 // PlanSuccess is the result of a successful plan.
@@ -59,10 +123,71 @@ type PlanSuccess struct {
 	TerraformOutput string
 	// LockURL is the full URL to the lock held by this plan.
 	LockURL string
+	// PlanURL is the full URL to view this plan's output in the UI. It's
+	// empty if the output wasn't saved.
+	PlanURL string
 	// RePlanCmd is the command that users should run to re-plan this project.
 	RePlanCmd string
 	// ApplyCmd is the command that users should run to apply this plan.
 	ApplyCmd string
+	// DestroyCount is the number of resources the plan will destroy.
+	DestroyCount int
+	// DestroyThresholdExceeded is true if the project is configured to warn on
+	// destroys and DestroyCount is over the destroy threshold.
+	DestroyThresholdExceeded bool
+	// Destroy is true if this is a destroy plan, ex. from atlantis plan
+	// -destroy. It must meet the destroy apply requirements to be applied.
+	Destroy bool
+	// PolicyCheckOutput is the output of checking the plan against the repo's
+	// policy sets. It's empty if the repo has no policy sets.
+	PolicyCheckOutput string
+	// PolicyCheckFailed is true if the plan failed its policy checks. It
+	// can't be applied until a policy owner runs atlantis approve_policies.
+	PolicyCheckFailed bool
+	// ResourceSummaries are how many resources of each type the plan will
+	// add, change and destroy. They're empty if the plan couldn't be
+	// summarized.
+	ResourceSummaries []runtime.ResourceTypeSummary
+	// TerraformCloud links the Terraform Cloud workspace the project is
+	// mapped to and the results of its run. It's nil if the project isn't
+	// mapped to a workspace.
+	TerraformCloud *TerraformCloudSummary
+	// CostEstimate is how the plan changes the project's monthly cost. It's
+	// nil if costs aren't estimated or the estimate failed.
+	CostEstimate *runtime.CostEstimate
+}
+
+// TerraformCloudSummary is the Terraform Cloud workspace a project is mapped
+// to and, if the plan ran there, the cost estimate and Sentinel policy checks
+// of its run.
+type TerraformCloudSummary struct {
+	Organization string
+	Workspace    string
+	WorkspaceURL string
+	// RunURL is empty if the plan didn't run in the workspace, ex. because
+	// the project doesn't use remote operations.
+	RunURL       string
+	CostEstimate *terraform.CloudCostEstimate
+	PolicyChecks []terraform.CloudPolicyCheck
+}
+
+// ImportSuccess is the result of a successful import.
+type ImportSuccess struct {
+	// TerraformOutput is the output from Terraform of running import.
+	TerraformOutput string
+	// RePlanCmd is the command that users should run to re-plan this project
+	// now that its plan has been discarded.
+	RePlanCmd string
+}
+
+// StateSuccess is the result of a successful state rm or mv.
+type StateSuccess struct {
+	// TerraformOutput is the output from Terraform of running the state
+	// subcommand.
+	TerraformOutput string
+	// RePlanCmd is the command that users should run to re-plan this project
+	// now that its plan has been discarded.
+	RePlanCmd string
 }
 
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_project_command_runner.go ProjectCommandRunner
@@ -74,6 +199,13 @@ type ProjectCommandRunner interface {
 	Plan(ctx models.ProjectCommandContext) ProjectResult
 	// Apply runs terraform apply for the project described by ctx.
 	Apply(ctx models.ProjectCommandContext) ProjectResult
+	// ApprovePolicies approves the plan described by ctx that failed its
+	// policy checks so it can be applied.
+	ApprovePolicies(ctx models.ProjectCommandContext) ProjectResult
+	// Import runs terraform import for the project described by ctx.
+	Import(ctx models.ProjectCommandContext) ProjectResult
+	// State runs terraform state rm or mv for the project described by ctx.
+	State(ctx models.ProjectCommandContext) ProjectResult
 }
 
 // DefaultProjectCommandRunner implements ProjectCommandRunner.
@@ -83,7 +215,11 @@ type DefaultProjectCommandRunner struct {
 	InitStepRunner           StepRunner
 	PlanStepRunner           StepRunner
 	ApplyStepRunner          StepRunner
+	ImportStepRunner         StepRunner
+	StateStepRunner          StepRunner
 	RunStepRunner            StepRunner
+	TerragruntStepRunner     StepRunner
+	PolicyCheckStepRunner    StepRunner
 	PullApprovedChecker      runtime.PullApprovedChecker
 	PullMergeableChecker     runtime.PullMergeableChecker
 	WorkingDir               WorkingDir
@@ -91,11 +227,57 @@ type DefaultProjectCommandRunner struct {
 	WorkingDirLocker         WorkingDirLocker
 	RequireApprovalOverride  bool
 	RequireMergeableOverride bool
+	// DestroyThreshold is the number of resources a plan can destroy before
+	// we warn about it. Only used for projects with warn_on_destroy set.
+	DestroyThreshold int
+	// TerraformVersionResolver resolves and downloads the terraform version
+	// each project needs. If nil, projects are run with the version in their
+	// config or the default version.
+	TerraformVersionResolver TerraformVersionResolver
+	// JobTracker records each plan and apply and their output. If nil, they
+	// aren't recorded.
+	JobTracker JobTracker
+	// ShowStepRunner converts plans to JSON so they can be summarized. If
+	// nil, plans aren't summarized.
+	ShowStepRunner StepRunner
+	// ExecutableName is the name that comments start with to run commands.
+	// Defaults to DefaultExecutableName.
+	ExecutableName string
+	// AuditRecorder records each plan, apply, import and state command to
+	// the audit log. If nil, they aren't recorded.
+	AuditRecorder AuditRecorder
+	// PlanStore saves the output of each successful plan so it can be viewed
+	// at the URL from PlanURLGenerator. If nil, outputs aren't saved.
+	PlanStore        plans.Store
+	PlanURLGenerator PlanURLGenerator
+	// TerraformCloudRuns reads the cost estimates and policy checks of the
+	// Terraform Cloud runs of projects mapped to Terraform Cloud workspaces.
+	// If nil, only the workspaces are linked.
+	TerraformCloudRuns TerraformCloudRunReader
+	// CostEstimateStepRunner estimates how each plan changes its project's
+	// monthly cost. If nil, costs aren't estimated.
+	CostEstimateStepRunner StepRunner
+	// PlanArtifacts exports each plan so it can be applied by another
+	// replica. If nil, plans are only kept in the pull request's clone.
+	PlanArtifacts *PlanArtifacts
+	// ExtraRepoCloner clones the extra_repos of projects before they're
+	// planned.
+	ExtraRepoCloner *ExtraRepoCloner
 }
 
 // Plan runs terraform plan for the project described by ctx.
 func (p *DefaultProjectCommandRunner) Plan(ctx models.ProjectCommandContext) ProjectResult {
+	startedAt := time.Now()
+	p.startJob(&ctx, PlanCommand)
 	planSuccess, failure, err := p.doPlan(ctx)
+	p.finishJob(ctx, failure, err)
+	p.recordAudit(ctx, PlanCommand, startedAt, failure, err)
+	if planSuccess != nil {
+		p.savePlanOutput(ctx, planSuccess)
+	}
+	if err != nil {
+		p.sendWebhook(ctx, webhooks.PlanErrorEvent, err)
+	}
 	return ProjectResult{
 		PlanSuccess: planSuccess,
 		Error:       err,
@@ -103,12 +285,51 @@ func (p *DefaultProjectCommandRunner) Plan(ctx models.ProjectCommandContext) Pro
 		RepoRelDir:  ctx.RepoRelDir,
 		Workspace:   ctx.Workspace,
 		ProjectName: ctx.GetProjectName(),
+		TFLog:       p.readTFLog(ctx),
+		JobID:       ctx.JobID,
 	}
 }
 
+// readTFLog returns the terraform log captured while planning if the plan was
+// run with TF_LOG set. The log file is removed once it's been read so it's
+// never confused with the log of a later plan.
+func (p *DefaultProjectCommandRunner) readTFLog(ctx models.ProjectCommandContext) string {
+	if ctx.TFLogLevel == "" {
+		return ""
+	}
+	repoDir, err := p.WorkingDir.GetWorkingDir(ctx.BaseRepo, ctx.Pull, ctx.Workspace)
+	if err != nil {
+		ctx.Log.Warn("unable to find working dir to read terraform log: %s", err)
+		return ""
+	}
+	logPath := filepath.Join(repoDir, ctx.RepoRelDir, runtime.GetTFLogFilename(ctx.Workspace, ctx.ProjectConfig))
+	tfLog, err := ioutil.ReadFile(logPath) // nolint: gosec
+	if err != nil {
+		if !os.IsNotExist(err) {
+			ctx.Log.Warn("unable to read terraform log: %s", err)
+		}
+		return ""
+	}
+	if err := os.Remove(logPath); err != nil {
+		ctx.Log.Warn("unable to remove terraform log: %s", err)
+	}
+
+	// TRACE logs can be huge so we only keep the end which is where any
+	// errors will be.
+	lines := strings.Split(strings.TrimSpace(string(tfLog)), "\n")
+	if len(lines) > maxTFLogLines {
+		lines = append([]string{fmt.Sprintf("[truncated, showing last %d lines]", maxTFLogLines)}, lines[len(lines)-maxTFLogLines:]...)
+	}
+	return strings.Join(lines, "\n")
+}
+
 // Apply runs terraform apply for the project described by ctx.
 func (p *DefaultProjectCommandRunner) Apply(ctx models.ProjectCommandContext) ProjectResult {
+	startedAt := time.Now()
+	p.startJob(&ctx, ApplyCommand)
 	applyOut, failure, err := p.doApply(ctx)
+	p.finishJob(ctx, failure, err)
+	p.recordAudit(ctx, ApplyCommand, startedAt, failure, err)
 	return ProjectResult{
 		Failure:      failure,
 		Error:        err,
@@ -116,9 +337,173 @@ func (p *DefaultProjectCommandRunner) Apply(ctx models.ProjectCommandContext) Pr
 		RepoRelDir:   ctx.RepoRelDir,
 		Workspace:    ctx.Workspace,
 		ProjectName:  ctx.GetProjectName(),
+		JobID:        ctx.JobID,
 	}
 }
 
+// Import runs terraform import for the project described by ctx. Like plan,
+// it locks the project for this pull request.
+func (p *DefaultProjectCommandRunner) Import(ctx models.ProjectCommandContext) ProjectResult {
+	startedAt := time.Now()
+	p.startJob(&ctx, ImportCommand)
+	importSuccess, failure, err := p.doImport(ctx)
+	p.finishJob(ctx, failure, err)
+	p.recordAudit(ctx, ImportCommand, startedAt, failure, err)
+	return ProjectResult{
+		ImportSuccess: importSuccess,
+		Error:         err,
+		Failure:       failure,
+		RepoRelDir:    ctx.RepoRelDir,
+		Workspace:     ctx.Workspace,
+		ProjectName:   ctx.GetProjectName(),
+		JobID:         ctx.JobID,
+	}
+}
+
+// State runs terraform state rm or mv for the project described by ctx. Like
+// plan, it locks the project for this pull request.
+func (p *DefaultProjectCommandRunner) State(ctx models.ProjectCommandContext) ProjectResult {
+	startedAt := time.Now()
+	p.startJob(&ctx, StateCommand)
+	stateSuccess, failure, err := p.doState(ctx)
+	p.finishJob(ctx, failure, err)
+	p.recordAudit(ctx, StateCommand, startedAt, failure, err)
+	return ProjectResult{
+		StateSuccess: stateSuccess,
+		Error:        err,
+		Failure:      failure,
+		RepoRelDir:   ctx.RepoRelDir,
+		Workspace:    ctx.Workspace,
+		ProjectName:  ctx.GetProjectName(),
+		JobID:        ctx.JobID,
+	}
+}
+
+// startJob starts tracking command for ctx's project and sets ctx.JobID.
+func (p *DefaultProjectCommandRunner) startJob(ctx *models.ProjectCommandContext, command CommandName) {
+	if p.JobTracker == nil {
+		return
+	}
+	ctx.JobID = p.JobTracker.Start(models.Job{
+		Command:      command.String(),
+		RepoFullName: ctx.BaseRepo.FullName,
+		PullNum:      ctx.Pull.Num,
+		PullURL:      ctx.Pull.URL,
+		RepoRelDir:   ctx.RepoRelDir,
+		Workspace:    ctx.Workspace,
+		ProjectName:  ctx.GetProjectName(),
+		User:         ctx.User.Username,
+		VCSHost:      ctx.BaseRepo.VCSHost,
+		HeadCommit:   ctx.Pull.HeadCommit,
+	})
+}
+
+// finishJob records that ctx's job finished with failure and err, which are
+// both empty if it succeeded.
+func (p *DefaultProjectCommandRunner) finishJob(ctx models.ProjectCommandContext, failure string, err error) {
+	if ctx.JobID == "" {
+		return
+	}
+	if failure != "" {
+		p.JobTracker.AppendOutput(ctx.JobID, failure)
+	}
+	if err != nil {
+		// Errors from running steps are followed by the steps' output which is
+		// already in the job's output.
+		p.JobTracker.AppendOutput(ctx.JobID, strings.SplitN(err.Error(), "\n", 2)[0])
+	}
+	p.JobTracker.Finish(ctx.JobID, failure == "" && err == nil)
+}
+
+// savePlanOutput saves the output of planSuccess and sets its PlanURL.
+// Failures are only logged since the plan itself succeeded.
+func (p *DefaultProjectCommandRunner) savePlanOutput(ctx models.ProjectCommandContext, planSuccess *PlanSuccess) {
+	if p.PlanStore == nil {
+		return
+	}
+	id := plans.ID(ctx.BaseRepo.FullName, ctx.Pull.Num, ctx.Pull.HeadCommit, ctx.RepoRelDir, ctx.Workspace)
+	err := p.PlanStore.SavePlanOutput(models.PlanOutput{
+		ID:           id,
+		RepoFullName: ctx.BaseRepo.FullName,
+		PullNum:      ctx.Pull.Num,
+		PullURL:      ctx.Pull.URL,
+		HeadCommit:   ctx.Pull.HeadCommit,
+		RepoRelDir:   ctx.RepoRelDir,
+		Workspace:    ctx.Workspace,
+		ProjectName:  ctx.GetProjectName(),
+		User:         ctx.User.Username,
+		Output:       planSuccess.TerraformOutput,
+		CreatedAt:    time.Now(),
+	})
+	if err != nil {
+		ctx.Log.Warn("unable to save plan output: %s", err)
+		return
+	}
+	if p.PlanURLGenerator != nil {
+		planSuccess.PlanURL = p.PlanURLGenerator.GeneratePlanURL(id)
+	}
+}
+
+// recordAudit records that command ran for ctx's project from startedAt and
+// finished with failure and err, which are both empty if it succeeded.
+func (p *DefaultProjectCommandRunner) recordAudit(ctx models.ProjectCommandContext, command CommandName, startedAt time.Time, failure string, err error) {
+	if p.AuditRecorder == nil {
+		return
+	}
+	errMsg := failure
+	if err != nil {
+		errMsg = strings.SplitN(err.Error(), "\n", 2)[0]
+	}
+	p.AuditRecorder.Record(models.AuditEvent{
+		Command:      command.String(),
+		User:         ctx.User.Username,
+		RepoFullName: ctx.BaseRepo.FullName,
+		PullNum:      ctx.Pull.Num,
+		HeadCommit:   ctx.Pull.HeadCommit,
+		RepoRelDir:   ctx.RepoRelDir,
+		Workspace:    ctx.Workspace,
+		ProjectName:  ctx.GetProjectName(),
+		ExtraArgs:    runtime.UnquoteCommentArgs(ctx.CommentArgs),
+		Success:      failure == "" && err == nil,
+		Error:        errMsg,
+		StartedAt:    startedAt,
+		DurationMS:   int64(time.Since(startedAt) / time.Millisecond),
+	})
+}
+
+// ApprovePolicies approves the plan described by ctx that failed its policy
+// checks so it can be applied.
+func (p *DefaultProjectCommandRunner) ApprovePolicies(ctx models.ProjectCommandContext) ProjectResult {
+	err := p.doApprovePolicies(ctx)
+	return ProjectResult{
+		PoliciesApproved: err == nil,
+		Error:            err,
+		RepoRelDir:       ctx.RepoRelDir,
+		Workspace:        ctx.Workspace,
+		ProjectName:      ctx.GetProjectName(),
+	}
+}
+
+func (p *DefaultProjectCommandRunner) doApprovePolicies(ctx models.ProjectCommandContext) error {
+	unlockFn, err := p.WorkingDirLocker.TryLockProject(ctx.BaseRepo.FullName, ctx.Pull.Num, ctx.Workspace, ctx.RepoRelDir)
+	if err != nil {
+		return err
+	}
+	defer unlockFn()
+
+	repoDir, err := p.WorkingDir.GetWorkingDir(ctx.BaseRepo, ctx.Pull, ctx.Workspace)
+	if err != nil {
+		return err
+	}
+	failedPath := filepath.Join(repoDir, ctx.RepoRelDir, runtime.GetPolicyCheckFailedFilename(ctx.Workspace, ctx.ProjectConfig))
+	if err := os.Remove(failedPath); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "approving policies")
+	}
+	p.exportArtifacts(ctx, filepath.Join(repoDir, ctx.RepoRelDir))
+	ctx.Log.Info("%s approved the failed policy checks", ctx.User.Username)
+	return nil
+}
+
 func (p *DefaultProjectCommandRunner) doPlan(ctx models.ProjectCommandContext) (*PlanSuccess, string, error) {
 	// Acquire Atlantis lock for this repo/dir/workspace.
 	lockAttempt, err := p.Locker.TryLock(ctx.Log, ctx.Pull, ctx.User, ctx.Workspace, models.NewProject(ctx.BaseRepo.FullName, ctx.RepoRelDir))
@@ -129,9 +514,12 @@ func (p *DefaultProjectCommandRunner) doPlan(ctx models.ProjectCommandContext) (
 		return nil, lockAttempt.LockFailureReason, nil
 	}
 	ctx.Log.Debug("acquired lock for project")
+	if lockAttempt.NewLock {
+		p.sendWebhook(ctx, webhooks.LockEvent, nil)
+	}
 
 	// Acquire internal lock for the directory we're going to operate in.
-	unlockFn, err := p.WorkingDirLocker.TryLock(ctx.BaseRepo.FullName, ctx.Pull.Num, ctx.Workspace)
+	unlockFn, err := p.WorkingDirLocker.TryLockProject(ctx.BaseRepo.FullName, ctx.Pull.Num, ctx.Workspace, ctx.RepoRelDir)
 	if err != nil {
 		return nil, "", err
 	}
@@ -145,50 +533,384 @@ func (p *DefaultProjectCommandRunner) doPlan(ctx models.ProjectCommandContext) (
 		}
 		return nil, "", cloneErr
 	}
+	if err := p.WorkingDir.CheckoutDirs(ctx.Log, repoDir, []string{ctx.RepoRelDir}); err != nil {
+		if unlockErr := lockAttempt.UnlockFn(); unlockErr != nil {
+			ctx.Log.Err("error unlocking state after plan error: %v", unlockErr)
+		}
+		return nil, "", err
+	}
+	if err := p.cloneExtraRepos(ctx, repoDir, true); err != nil {
+		if unlockErr := lockAttempt.UnlockFn(); unlockErr != nil {
+			ctx.Log.Err("error unlocking state after plan error: %v", unlockErr)
+		}
+		return nil, "", err
+	}
 	projAbsPath := filepath.Join(repoDir, ctx.RepoRelDir)
 
-	// Use default stage unless another workflow is defined in config
-	stage := p.defaultPlanStage()
-	if ctx.ProjectConfig != nil && ctx.ProjectConfig.Workflow != nil {
-		ctx.Log.Debug("project configured to use workflow %q", *ctx.ProjectConfig.Workflow)
-		configuredStage := ctx.GlobalConfig.GetPlanStage(*ctx.ProjectConfig.Workflow)
-		if configuredStage != nil {
-			ctx.Log.Debug("project will use the configured stage for that workflow")
-			stage = *configuredStage
+	if err := p.resolveTerraformVersion(&ctx, projAbsPath); err != nil {
+		if unlockErr := lockAttempt.UnlockFn(); unlockErr != nil {
+			ctx.Log.Err("error unlocking state after plan error: %v", unlockErr)
 		}
+		return nil, "", err
 	}
-	outputs, err := p.runSteps(stage.Steps, ctx, projAbsPath)
+	planStage := p.planStage(ctx)
+	outputs, err := p.runSteps(planStage.Steps, ctx, projAbsPath)
 	if err != nil {
 		if unlockErr := lockAttempt.UnlockFn(); unlockErr != nil {
 			ctx.Log.Err("error unlocking state after plan error: %v", unlockErr)
 		}
 		return nil, "", fmt.Errorf("%s\n%s", err, strings.Join(outputs, "\n"))
 	}
+	destroy := p.isDestroyPlan(ctx, planStage)
+	if err := p.markDestroyPlan(ctx, projAbsPath, destroy); err != nil {
+		if unlockErr := lockAttempt.UnlockFn(); unlockErr != nil {
+			ctx.Log.Err("error unlocking state after plan error: %v", unlockErr)
+		}
+		return nil, "", err
+	}
+
+	planOutput := strings.Join(outputs, "\n")
+	policyCheckOutput, policyCheckFailed, err := p.runPolicyCheck(ctx, projAbsPath)
+	if err != nil {
+		if unlockErr := lockAttempt.UnlockFn(); unlockErr != nil {
+			ctx.Log.Err("error unlocking state after plan error: %v", unlockErr)
+		}
+		return nil, "", err
+	}
+	summary, _ := runtime.ParsePlanSummary(planOutput)
+	planSuccess := &PlanSuccess{
+		LockURL:                  p.LockURLGenerator.GenerateLockURL(lockAttempt.LockKey),
+		TerraformOutput:          planOutput,
+		RePlanCmd:                ctx.RePlanCmd,
+		ApplyCmd:                 ctx.ApplyCmd,
+		DestroyCount:             summary.Destroy,
+		DestroyThresholdExceeded: ctx.ProjectConfig != nil && ctx.ProjectConfig.WarnOnDestroy && summary.Destroy > p.DestroyThreshold,
+		Destroy:                  destroy,
+		PolicyCheckOutput:        policyCheckOutput,
+		PolicyCheckFailed:        policyCheckFailed,
+		ResourceSummaries:        p.summarizePlan(ctx, projAbsPath),
+		TerraformCloud:           p.summarizeTerraformCloud(ctx, planOutput),
+		CostEstimate:             p.estimateCost(ctx, projAbsPath),
+	}
+	p.exportArtifacts(ctx, projAbsPath)
+	return planSuccess, "", nil
+}
+
+// exportArtifacts exports the plan artifacts in projAbsPath. It only warns if
+// they can't be exported since the plan can still be applied by this replica.
+func (p *DefaultProjectCommandRunner) exportArtifacts(ctx models.ProjectCommandContext, projAbsPath string) {
+	if p.PlanArtifacts == nil {
+		return
+	}
+	if err := p.PlanArtifacts.Export(ctx, projAbsPath); err != nil {
+		ctx.Log.Warn("unable to export plan artifacts: %s", err)
+	}
+}
+
+// cloneExtraRepos clones the extra_repos of ctx's project into repoDir. If
+// update is true, the ones already cloned are fetched again.
+func (p *DefaultProjectCommandRunner) cloneExtraRepos(ctx models.ProjectCommandContext, repoDir string, update bool) error {
+	if ctx.ProjectConfig == nil || len(ctx.ProjectConfig.ExtraRepos) == 0 {
+		return nil
+	}
+	if p.ExtraRepoCloner == nil {
+		return errors.New("this Atlantis server can't clone extra_repos")
+	}
+	return p.ExtraRepoCloner.Clone(ctx.Log, repoDir, ctx.ProjectConfig.ExtraRepos, update)
+}
+
+// estimateCost returns how the plan in projAbsPath changes the project's
+// monthly cost. Like summaries, estimates are best-effort so a plan that
+// can't be estimated, ex. because Infracost's API is down, still succeeds.
+func (p *DefaultProjectCommandRunner) estimateCost(ctx models.ProjectCommandContext, projAbsPath string) *runtime.CostEstimate {
+	if p.CostEstimateStepRunner == nil || ctx.DisableCostEstimation {
+		return nil
+	}
+	out, err := p.CostEstimateStepRunner.Run(ctx, nil, projAbsPath)
+	if err != nil {
+		ctx.Log.Warn("unable to estimate cost of plan: %s", err)
+		return nil
+	}
+	estimate, err := runtime.ParseInfracostJSON(out)
+	if err != nil {
+		ctx.Log.Warn("unable to estimate cost of plan: %s", err)
+		return nil
+	}
+	return estimate
+}
+
+// summarizeTerraformCloud links the Terraform Cloud workspace ctx's project is
+// mapped to and, if the plan in planOutput ran there, its run's cost estimate
+// and policy checks. Reading the run's results is best-effort since the plan
+// itself succeeded.
+func (p *DefaultProjectCommandRunner) summarizeTerraformCloud(ctx models.ProjectCommandContext, planOutput string) *TerraformCloudSummary {
+	if ctx.ProjectConfig == nil || ctx.ProjectConfig.TerraformCloud == nil {
+		return nil
+	}
+	tfc := ctx.ProjectConfig.TerraformCloud
+	summary := &TerraformCloudSummary{
+		Organization: tfc.Organization,
+		Workspace:    tfc.Workspace,
+		WorkspaceURL: tfc.WorkspaceURL(),
+	}
+	run := runtime.FindRemoteRun(planOutput)
+	if run == nil {
+		return summary
+	}
+	if !strings.EqualFold(run.Hostname, tfc.Hostname) || run.Organization != tfc.Organization || run.Workspace != tfc.Workspace {
+		ctx.Log.Warn("remote run %s is in workspace %s/%s, not %s/%s which the project is mapped to, so its results won't be linked", run.ID, run.Organization, run.Workspace, tfc.Organization, tfc.Workspace)
+		return summary
+	}
+	summary.RunURL = run.URL
+	if p.TerraformCloudRuns == nil {
+		return summary
+	}
+	checks, err := p.TerraformCloudRuns.ReadRunChecks(run.Hostname, run.ID)
+	if err != nil {
+		ctx.Log.Warn("unable to read cost estimate and policy checks of remote run %s: %s", run.ID, err)
+		return summary
+	}
+	summary.CostEstimate = checks.CostEstimate
+	summary.PolicyChecks = checks.PolicyChecks
+	return summary
+}
+
+// isDestroyPlan returns true if ctx's plan destroys all the project's
+// resources: it was asked for with atlantis plan -destroy, or -destroy was
+// passed to terraform by the comment or a plan step in stage.
+func (p *DefaultProjectCommandRunner) isDestroyPlan(ctx models.ProjectCommandContext, stage valid.Stage) bool {
+	if ctx.Destroy {
+		return true
+	}
+	// Comment args are quoted by the comment parser.
+	for _, arg := range ctx.CommentArgs {
+		if strings.Trim(arg, `"`) == "-destroy" {
+			return true
+		}
+	}
+	for _, step := range stage.Steps {
+		if step.StepName != "plan" {
+			continue
+		}
+		for _, arg := range step.ExtraArgs {
+			if arg == "-destroy" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// markDestroyPlan marks the plan in projAbsPath as a destroy plan so that
+// apply knows to check the destroy apply requirements, or removes the mark
+// left by an earlier destroy plan.
+func (p *DefaultProjectCommandRunner) markDestroyPlan(ctx models.ProjectCommandContext, projAbsPath string, destroy bool) error {
+	markerPath := filepath.Join(projAbsPath, runtime.GetDestroyPlanFilename(ctx.Workspace, ctx.ProjectConfig))
+	if !destroy {
+		if err := os.Remove(markerPath); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "removing destroy plan marker")
+		}
+		return nil
+	}
+	return errors.Wrap(ioutil.WriteFile(markerPath, nil, 0600), "marking destroy plan")
+}
+
+// summarizePlan returns how many resources of each type the plan in
+// projAbsPath changes. Plans that can't be shown as JSON, ex. because they
+// were made without a plan file or with Terraform older than 0.12, aren't
+// summarized rather than failing the plan.
+func (p *DefaultProjectCommandRunner) summarizePlan(ctx models.ProjectCommandContext, projAbsPath string) []runtime.ResourceTypeSummary {
+	if p.ShowStepRunner == nil {
+		return nil
+	}
+	planJSON, err := p.ShowStepRunner.Run(ctx, nil, projAbsPath)
+	if err != nil {
+		ctx.Log.Debug("not summarizing plan: %s", err)
+		return nil
+	}
+	// Keep the JSON so it's exported with the plan.
+	planJSONPath := filepath.Join(projAbsPath, runtime.GetPlanJSONFilename(ctx.Workspace, ctx.ProjectConfig))
+	if err := ioutil.WriteFile(planJSONPath, []byte(planJSON), 0600); err != nil {
+		ctx.Log.Warn("unable to write plan json: %s", err)
+	}
+	summaries, err := runtime.ParsePlanJSON(planJSON)
+	if err != nil {
+		ctx.Log.Warn("unable to summarize plan: %s", err)
+		return nil
+	}
+	return summaries
+}
 
-	return &PlanSuccess{
-		LockURL:         p.LockURLGenerator.GenerateLockURL(lockAttempt.LockKey),
-		TerraformOutput: strings.Join(outputs, "\n"),
+func (p *DefaultProjectCommandRunner) doImport(ctx models.ProjectCommandContext) (*ImportSuccess, string, error) {
+	output, failure, err := p.changeState(ctx, "import")
+	if failure != "" || err != nil {
+		return nil, failure, err
+	}
+	return &ImportSuccess{
+		TerraformOutput: output,
+		RePlanCmd:       ctx.RePlanCmd,
+	}, "", nil
+}
+
+func (p *DefaultProjectCommandRunner) doState(ctx models.ProjectCommandContext) (*StateSuccess, string, error) {
+	output, failure, err := p.changeState(ctx, "state")
+	if failure != "" || err != nil {
+		return nil, failure, err
+	}
+	return &StateSuccess{
+		TerraformOutput: output,
 		RePlanCmd:       ctx.RePlanCmd,
-		ApplyCmd:        ctx.ApplyCmd,
 	}, "", nil
 }
 
+// changeState runs the stepName step, which changes the state of ctx's
+// project, and returns its output. Since it changes the state, it takes the
+// same lock as plan.
+func (p *DefaultProjectCommandRunner) changeState(ctx models.ProjectCommandContext, stepName string) (string, string, error) {
+	lockAttempt, err := p.Locker.TryLock(ctx.Log, ctx.Pull, ctx.User, ctx.Workspace, models.NewProject(ctx.BaseRepo.FullName, ctx.RepoRelDir))
+	if err != nil {
+		return "", "", errors.Wrap(err, "acquiring lock")
+	}
+	if !lockAttempt.LockAcquired {
+		return "", lockAttempt.LockFailureReason, nil
+	}
+	ctx.Log.Debug("acquired lock for project")
+	if lockAttempt.NewLock {
+		p.sendWebhook(ctx, webhooks.LockEvent, nil)
+	}
+	// As with plan, the pull request keeps the lock if the state change
+	// succeeds.
+	unlockOnErr := func() {
+		if unlockErr := lockAttempt.UnlockFn(); unlockErr != nil {
+			ctx.Log.Err("error unlocking state after %s error: %v", stepName, unlockErr)
+		}
+	}
+
+	unlockFn, err := p.WorkingDirLocker.TryLockProject(ctx.BaseRepo.FullName, ctx.Pull.Num, ctx.Workspace, ctx.RepoRelDir)
+	if err != nil {
+		return "", "", err
+	}
+	defer unlockFn()
+
+	repoDir, err := p.WorkingDir.Clone(ctx.Log, ctx.BaseRepo, ctx.HeadRepo, ctx.Pull, ctx.Workspace)
+	if err != nil {
+		unlockOnErr()
+		return "", "", err
+	}
+	if err := p.WorkingDir.CheckoutDirs(ctx.Log, repoDir, []string{ctx.RepoRelDir}); err != nil {
+		unlockOnErr()
+		return "", "", err
+	}
+	projAbsPath := filepath.Join(repoDir, ctx.RepoRelDir)
+	if err := p.resolveTerraformVersion(&ctx, projAbsPath); err != nil {
+		unlockOnErr()
+		return "", "", err
+	}
+	outputs, err := p.runSteps(p.stateChangeStage(ctx, stepName).Steps, ctx, projAbsPath)
+	if err != nil {
+		unlockOnErr()
+		return "", "", fmt.Errorf("%s\n%s", err, strings.Join(outputs, "\n"))
+	}
+	return strings.Join(outputs, "\n"), "", nil
+}
+
+// runPolicyCheck checks the plan in projAbsPath against ctx's policy sets
+// and returns the output and whether the checks failed. If they failed, we
+// mark the plan so it can't be applied until a policy owner approves it.
+// An error is only returned if we couldn't mark the plan.
+func (p *DefaultProjectCommandRunner) runPolicyCheck(ctx models.ProjectCommandContext, projAbsPath string) (string, bool, error) {
+	failedPath := filepath.Join(projAbsPath, runtime.GetPolicyCheckFailedFilename(ctx.Workspace, ctx.ProjectConfig))
+	if len(ctx.PolicySets) == 0 {
+		// The repo could have had policies when it was last planned.
+		if err := os.Remove(failedPath); err != nil && !os.IsNotExist(err) {
+			return "", false, errors.Wrap(err, "removing failed policy check marker")
+		}
+		return "", false, nil
+	}
+
+	stage := p.defaultPolicyCheckStage()
+	if ctx.ProjectConfig != nil && ctx.ProjectConfig.Workflow != nil {
+		configuredStage := ctx.GlobalConfig.GetPolicyCheckStage(*ctx.ProjectConfig.Workflow)
+		if configuredStage != nil {
+			stage = *configuredStage
+		}
+	}
+	// The repo's atlantis.yaml can customize the stage so we make sure the
+	// policies are still checked.
+	hasPolicyCheck := false
+	for _, step := range stage.Steps {
+		if step.StepName == "policy_check" {
+			hasPolicyCheck = true
+		}
+	}
+	var outputs []string
+	var err error
+	if hasPolicyCheck {
+		outputs, err = p.runSteps(stage.Steps, ctx, projAbsPath)
+	} else {
+		err = errors.New("the policy_check stage must include the policy_check step")
+	}
+	if err != nil {
+		outputs = append(outputs, err.Error())
+		if writeErr := ioutil.WriteFile(failedPath, []byte(err.Error()), 0600); writeErr != nil {
+			return "", false, errors.Wrap(writeErr, "marking plan as failing its policy checks")
+		}
+		return strings.Join(outputs, "\n"), true, nil
+	}
+	if err := os.Remove(failedPath); err != nil && !os.IsNotExist(err) {
+		return "", false, errors.Wrap(err, "removing failed policy check marker")
+	}
+	return strings.Join(outputs, "\n"), false, nil
+}
+
 func (p *DefaultProjectCommandRunner) runSteps(steps []valid.Step, ctx models.ProjectCommandContext, absPath string) ([]string, error) {
 	var outputs []string
+	// env are the variables set by env steps so far, as KEY=value.
+	var env []string
 	for _, step := range steps {
 		var out string
 		var err error
+		// The output of terraform's init, plan and apply is streamed to the
+		// job as it's written. We add the output of the other steps once
+		// they've finished, after any of it that shouldn't be shown is
+		// filtered out.
+		stepCtx := ctx
+		streamed := ctx.JobID != "" && step.StepName != "policy_check" && step.StepName != "run" && step.StepName != "env"
+		if streamed {
+			stepCtx.CancelCtx = p.streamJobOutput(ctx)
+		}
+		if len(env) > 0 {
+			stepCtx.CancelCtx = terraform.WithEnv(backgroundIfNil(stepCtx.CancelCtx), env)
+		}
 		switch step.StepName {
 		case "init":
-			out, err = p.InitStepRunner.Run(ctx, step.ExtraArgs, absPath)
+			out, err = p.InitStepRunner.Run(stepCtx, step.ExtraArgs, absPath)
 		case "plan":
-			out, err = p.PlanStepRunner.Run(ctx, step.ExtraArgs, absPath)
+			out, err = p.PlanStepRunner.Run(stepCtx, step.ExtraArgs, absPath)
 		case "apply":
-			out, err = p.ApplyStepRunner.Run(ctx, step.ExtraArgs, absPath)
+			out, err = p.ApplyStepRunner.Run(stepCtx, step.ExtraArgs, absPath)
+		case "import":
+			out, err = p.ImportStepRunner.Run(stepCtx, step.ExtraArgs, absPath)
+		case "state":
+			out, err = p.StateStepRunner.Run(stepCtx, step.ExtraArgs, absPath)
+		case "policy_check":
+			out, err = p.PolicyCheckStepRunner.Run(stepCtx, step.ExtraArgs, absPath)
 		case "run":
-			out, err = p.RunStepRunner.Run(ctx, step.RunCommand, absPath)
+			out, err = p.RunStepRunner.Run(stepCtx, step.RunCommand, absPath)
+			out, err = filterRunStepOutput(step, out, err)
+		case "terragrunt":
+			out, err = p.TerragruntStepRunner.Run(stepCtx, step.RunCommand, absPath)
+		case "env":
+			var val string
+			val, err = p.envStepValue(stepCtx, step, absPath)
+			if err == nil {
+				env = append(env, fmt.Sprintf("%s=%s", step.EnvVarName, val))
+			}
 		}
 
+		if ctx.JobID != "" && !streamed && out != "" {
+			p.JobTracker.AppendOutput(ctx.JobID, out)
+		}
 		if out != "" {
 			outputs = append(outputs, out)
 		}
@@ -199,6 +921,92 @@ func (p *DefaultProjectCommandRunner) runSteps(steps []valid.Step, ctx models.Pr
 	return outputs, nil
 }
 
+// streamJobOutput returns ctx's CancelCtx set up to add the output of the
+// terraform commands run with it to ctx's job.
+func (p *DefaultProjectCommandRunner) streamJobOutput(ctx models.ProjectCommandContext) context.Context {
+	return terraform.WithOutputFunc(backgroundIfNil(ctx.CancelCtx), func(line string) {
+		p.JobTracker.AppendOutput(ctx.JobID, line)
+	})
+}
+
+// backgroundIfNil returns ctx, or an empty context if ctx is nil, so that it
+// can be given values.
+func backgroundIfNil(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
+
+// envStepValue returns the value of the variable the env step sets. If the
+// step has a command, the value is the command's output without surrounding
+// whitespace. The output is never added to the comment or the job since it
+// can be a secret, ex. credentials for assuming a role.
+func (p *DefaultProjectCommandRunner) envStepValue(ctx models.ProjectCommandContext, step valid.Step, absPath string) (string, error) {
+	if len(step.RunCommand) == 0 {
+		return step.EnvVarValue, nil
+	}
+	out, err := p.RunStepRunner.Run(ctx, step.RunCommand, absPath)
+	if err != nil {
+		// The error from the run step includes its output so we replace it.
+		ctx.Log.Debug("env step for %s failed: %s", step.EnvVarName, err)
+		return "", fmt.Errorf("running %q to set %s failed, its output is hidden since it can contain secrets", strings.Join(step.RunCommand, " "), step.EnvVarName)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// filterRunStepOutput drops the output of run steps that shouldn't be added to
+// the comment according to their show_output setting.
+func filterRunStepOutput(step valid.Step, out string, err error) (string, error) {
+	switch step.ShowOutput {
+	case valid.ShowOutputOnError:
+		if err == nil {
+			return "", nil
+		}
+	case valid.ShowOutputNever:
+		if err != nil {
+			// The error from the run step includes its output so we replace it.
+			err = fmt.Errorf("running %q failed, its output is hidden because show_output is %s", strings.Join(step.RunCommand, " "), valid.ShowOutputNever)
+		}
+		return "", err
+	}
+	return out, err
+}
+
+// checkApplyRequirements returns a failure message if any of requirements
+// or requirementGroups aren't met. action is what they must be met before,
+// ex. "running apply". met caches which requirements are met.
+func (p *DefaultProjectCommandRunner) checkApplyRequirements(ctx models.ProjectCommandContext, requirements []string, requirementGroups []valid.ApplyRequirementGroup, met map[string]bool, action string) (string, error) {
+	for _, req := range requirements {
+		ok, err := p.applyRequirementMet(ctx, req, met)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return fmt.Sprintf("Pull request must be %s before %s.", req, action), nil
+		}
+	}
+	for _, group := range requirementGroups {
+		var failed []string
+		for _, req := range group.Requirements {
+			ok, err := p.applyRequirementMet(ctx, req, met)
+			if err != nil {
+				return "", err
+			}
+			if !ok {
+				failed = append(failed, req)
+			}
+		}
+		if group.AnyOf && len(failed) == len(group.Requirements) {
+			return fmt.Sprintf("Pull request must be %s before %s (any_of group not met).", strings.Join(group.Requirements, " or "), action), nil
+		}
+		if !group.AnyOf && len(failed) > 0 {
+			return fmt.Sprintf("Pull request must be %s before %s (all_of group not met: %s).", strings.Join(group.Requirements, " and "), action, strings.Join(failed, ", ")), nil
+		}
+	}
+	return "", nil
+}
+
 func (p *DefaultProjectCommandRunner) doApply(ctx models.ProjectCommandContext) (applyOut string, failure string, err error) {
 	repoDir, err := p.WorkingDir.GetWorkingDir(ctx.BaseRepo, ctx.Pull, ctx.Workspace)
 	if err != nil {
@@ -209,8 +1017,13 @@ func (p *DefaultProjectCommandRunner) doApply(ctx models.ProjectCommandContext)
 	}
 	absPath := filepath.Join(repoDir, ctx.RepoRelDir)
 
+	if _, err := os.Stat(filepath.Join(absPath, runtime.GetPolicyCheckFailedFilename(ctx.Workspace, ctx.ProjectConfig))); err == nil {
+		return "", fmt.Sprintf("This plan failed its policy checks. A policy owner must comment `%s %s` before it can be applied.", executableName(p.ExecutableName), ApprovePoliciesCommand.String()), nil
+	}
+
 	// Figure out what our apply requirements are.
 	var applyRequirements []string
+	var applyRequirementGroups []valid.ApplyRequirementGroup
 	if p.RequireApprovalOverride || p.RequireMergeableOverride {
 		// If any server flags are set, they override project config.
 		if p.RequireMergeableOverride {
@@ -222,36 +1035,63 @@ func (p *DefaultProjectCommandRunner) doApply(ctx models.ProjectCommandContext)
 	} else if ctx.ProjectConfig != nil {
 		// Else we use the project config if it's set.
 		applyRequirements = ctx.ProjectConfig.ApplyRequirements
+		applyRequirementGroups = ctx.ProjectConfig.ApplyRequirementGroups
 	}
-	for _, req := range applyRequirements {
-		switch req {
-		case raw.ApprovedApplyRequirement:
-			approved, err := p.PullApprovedChecker.PullIsApproved(ctx.BaseRepo, ctx.Pull) // nolint: vetshadow
-			if err != nil {
-				return "", "", errors.Wrap(err, "checking if pull request was approved")
-			}
-			if !approved {
-				return "", "Pull request must be approved before running apply.", nil
-			}
-		case raw.MergeableApplyRequirement:
-			mergeable, err := p.PullMergeableChecker.PullIsMergeable(ctx.BaseRepo, ctx.Pull) // nolint: vetshadow
-			if err != nil {
-				return "", "", errors.Wrap(err, "checking if pull request is mergeable")
-			}
-			if !mergeable {
-				return "", "Pull request must be mergeable before running apply.", nil
-			}
+	// Cache results so requirements that appear more than once only hit the
+	// VCS host once.
+	met := make(map[string]bool)
+	if failure, err := p.checkApplyRequirements(ctx, applyRequirements, applyRequirementGroups, met, "running apply"); failure != "" || err != nil { // nolint: vetshadow
+		return "", failure, err
+	}
+	// Destroy plans have their own requirements on top, even if the server
+	// flags override the project's.
+	if _, err := os.Stat(filepath.Join(absPath, runtime.GetDestroyPlanFilename(ctx.Workspace, ctx.ProjectConfig))); err == nil {
+		destroyRequirements := DefaultDestroyApplyRequirements
+		var destroyRequirementGroups []valid.ApplyRequirementGroup
+		if ctx.ProjectConfig != nil && (len(ctx.ProjectConfig.DestroyApplyRequirements) > 0 || len(ctx.ProjectConfig.DestroyApplyRequirementGroups) > 0) {
+			destroyRequirements = ctx.ProjectConfig.DestroyApplyRequirements
+			destroyRequirementGroups = ctx.ProjectConfig.DestroyApplyRequirementGroups
+		}
+		if failure, err := p.checkApplyRequirements(ctx, destroyRequirements, destroyRequirementGroups, met, "applying a destroy plan"); failure != "" || err != nil { // nolint: vetshadow
+			return "", failure, err
 		}
 	}
+	// This pull normally already holds the project's lock from when it was
+	// planned. If another pull holds it then that pull has planned since, so
+	// this plan is out of date and this pull waits in line for the lock
+	// instead. Once it's given the lock it's re-planned.
+	lockAttempt, err := p.Locker.TryLock(ctx.Log, ctx.Pull, ctx.User, ctx.Workspace, models.NewProject(ctx.BaseRepo.FullName, ctx.RepoRelDir))
+	if err != nil {
+		return "", "", errors.Wrap(err, "acquiring lock")
+	}
+	if !lockAttempt.LockAcquired {
+		return "", lockAttempt.LockFailureReason, nil
+	}
+	if lockAttempt.NewLock {
+		p.sendWebhook(ctx, webhooks.LockEvent, nil)
+	}
+
 	// Acquire internal lock for the directory we're going to operate in.
-	unlockFn, err := p.WorkingDirLocker.TryLock(ctx.BaseRepo.FullName, ctx.Pull.Num, ctx.Workspace)
+	unlockFn, err := p.WorkingDirLocker.TryLockProject(ctx.BaseRepo.FullName, ctx.Pull.Num, ctx.Workspace, ctx.RepoRelDir)
 	if err != nil {
 		return "", "", err
 	}
 	defer unlockFn()
 
+	// The extra repos are missing if the plan was restored from the
+	// artifact store by this replica.
+	if err := p.cloneExtraRepos(ctx, repoDir, false); err != nil {
+		return "", "", err
+	}
+	if err := p.resolveTerraformVersion(&ctx, absPath); err != nil {
+		return "", "", err
+	}
+
 	// Use default stage unless another workflow is defined in config
 	stage := p.defaultApplyStage()
+	if ctx.Terragrunt {
+		stage = p.defaultTerragruntApplyStage()
+	}
 	if ctx.ProjectConfig != nil && ctx.ProjectConfig.Workflow != nil {
 		configuredStage := ctx.GlobalConfig.GetApplyStage(*ctx.ProjectConfig.Workflow)
 		if configuredStage != nil {
@@ -259,19 +1099,126 @@ func (p *DefaultProjectCommandRunner) doApply(ctx models.ProjectCommandContext)
 		}
 	}
 	outputs, err := p.runSteps(stage.Steps, ctx, absPath)
-	p.Webhooks.Send(ctx.Log, webhooks.ApplyResult{ // nolint: errcheck
-		Workspace: ctx.Workspace,
-		User:      ctx.User,
-		Repo:      ctx.BaseRepo,
-		Pull:      ctx.Pull,
-		Success:   err == nil,
-	})
+	p.sendWebhook(ctx, webhooks.ApplyEvent, err)
 	if err != nil {
 		return "", "", fmt.Errorf("%s\n%s", err, strings.Join(outputs, "\n"))
 	}
+	// Apply removes the plan file so it's removed from the store too.
+	p.exportArtifacts(ctx, absPath)
 	return strings.Join(outputs, "\n"), "", nil
 }
 
+// sendWebhook sends the webhooks for event on ctx's project. err is why the
+// command failed, if it did.
+func (p *DefaultProjectCommandRunner) sendWebhook(ctx models.ProjectCommandContext, event string, err error) {
+	if p.Webhooks == nil {
+		return
+	}
+	result := webhooks.Result{
+		Event:       event,
+		Workspace:   ctx.Workspace,
+		Directory:   ctx.RepoRelDir,
+		ProjectName: ctx.GetProjectName(),
+		User:        ctx.User,
+		Repo:        ctx.BaseRepo,
+		Pull:        ctx.Pull,
+		Success:     err == nil,
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	p.Webhooks.Send(ctx.Log, result) // nolint: errcheck
+}
+
+// applyRequirementMet returns true if the pull request meets req. Results
+// are cached in met.
+func (p *DefaultProjectCommandRunner) applyRequirementMet(ctx models.ProjectCommandContext, req string, met map[string]bool) (bool, error) {
+	if ok, cached := met[req]; cached {
+		return ok, nil
+	}
+	var ok bool
+	var err error
+	switch req {
+	case raw.ApprovedApplyRequirement:
+		ok, err = p.PullApprovedChecker.PullIsApproved(ctx.BaseRepo, ctx.Pull)
+		if err != nil {
+			return false, errors.Wrap(err, "checking if pull request was approved")
+		}
+	case raw.MergeableApplyRequirement:
+		ok, err = p.PullMergeableChecker.PullIsMergeable(ctx.BaseRepo, ctx.Pull)
+		if err != nil {
+			return false, errors.Wrap(err, "checking if pull request is mergeable")
+		}
+	default:
+		// Unknown requirements are rejected when the config is validated.
+		ok = true
+	}
+	met[req] = ok
+	return ok, nil
+}
+
+// PlanForDrift runs the project's plan stage in repoDir and returns the plan
+// output. Unlike plans for pull requests, it doesn't lock the project or run
+// policy checks since nothing can be applied from it.
+func (p *DefaultProjectCommandRunner) PlanForDrift(ctx models.ProjectCommandContext, repoDir string) (string, error) {
+	projAbsPath := filepath.Join(repoDir, ctx.RepoRelDir)
+	if err := p.resolveTerraformVersion(&ctx, projAbsPath); err != nil {
+		return "", err
+	}
+	outputs, err := p.runSteps(p.planStage(ctx).Steps, ctx, projAbsPath)
+	return strings.Join(outputs, "\n"), err
+}
+
+// resolveTerraformVersion sets ctx.TerraformVersion to the version the
+// project in projAbsPath should be run with, downloading it if needed.
+func (p *DefaultProjectCommandRunner) resolveTerraformVersion(ctx *models.ProjectCommandContext, projAbsPath string) error {
+	if p.TerraformVersionResolver == nil {
+		return nil
+	}
+	var configured *version.Version
+	if ctx.ProjectConfig != nil {
+		configured = ctx.ProjectConfig.TerraformVersion
+	}
+	v, err := p.TerraformVersionResolver.ResolveVersion(ctx.Log, projAbsPath, configured)
+	if err != nil {
+		return errors.Wrap(err, "getting terraform version")
+	}
+	ctx.TerraformVersion = v
+	return nil
+}
+
+// planStage returns the default stage unless another workflow is defined in
+// config.
+func (p *DefaultProjectCommandRunner) planStage(ctx models.ProjectCommandContext) valid.Stage {
+	stage := p.defaultPlanStage()
+	if ctx.Terragrunt {
+		stage = p.defaultTerragruntPlanStage()
+	}
+	if ctx.ProjectConfig != nil && ctx.ProjectConfig.Workflow != nil {
+		ctx.Log.Debug("project configured to use workflow %q", *ctx.ProjectConfig.Workflow)
+		configuredStage := ctx.GlobalConfig.GetPlanStage(*ctx.ProjectConfig.Workflow)
+		if configuredStage != nil {
+			ctx.Log.Debug("project will use the configured stage for that workflow")
+			stage = *configuredStage
+		}
+	}
+	return stage
+}
+
+// stateChangeStage returns the stage that runs the stepName step, which
+// changes the state, for ctx: the init steps of its plan stage, so terraform
+// is initialized the same way as for plans, and then stepName.
+func (p *DefaultProjectCommandRunner) stateChangeStage(ctx models.ProjectCommandContext, stepName string) valid.Stage {
+	var stage valid.Stage
+	for _, step := range p.planStage(ctx).Steps {
+		if step.StepName == "init" {
+			stage.Steps = append(stage.Steps, step)
+		}
+	}
+	stage.Steps = append(stage.Steps, valid.Step{StepName: stepName})
+	return stage
+}
+
 func (p DefaultProjectCommandRunner) defaultPlanStage() valid.Stage {
 	return valid.Stage{
 		Steps: []valid.Step{
@@ -285,6 +1232,16 @@ func (p DefaultProjectCommandRunner) defaultPlanStage() valid.Stage {
 	}
 }
 
+func (p DefaultProjectCommandRunner) defaultPolicyCheckStage() valid.Stage {
+	return valid.Stage{
+		Steps: []valid.Step{
+			{
+				StepName: "policy_check",
+			},
+		},
+	}
+}
+
 func (p DefaultProjectCommandRunner) defaultApplyStage() valid.Stage {
 	return valid.Stage{
 		Steps: []valid.Step{
@@ -294,3 +1251,29 @@ func (p DefaultProjectCommandRunner) defaultApplyStage() valid.Stage {
 		},
 	}
 }
+
+func (p DefaultProjectCommandRunner) defaultTerragruntPlanStage() valid.Stage {
+	return valid.Stage{
+		Steps: []valid.Step{
+			{
+				StepName:   "terragrunt",
+				RunCommand: []string{"init"},
+			},
+			{
+				StepName:   "terragrunt",
+				RunCommand: []string{"plan"},
+			},
+		},
+	}
+}
+
+func (p DefaultProjectCommandRunner) defaultTerragruntApplyStage() valid.Stage {
+	return valid.Stage{
+		Steps: []valid.Step{
+			{
+				StepName:   "terragrunt",
+				RunCommand: []string{"apply"},
+			},
+		},
+	}
+}
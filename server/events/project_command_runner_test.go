@@ -14,16 +14,26 @@
 package events_test
 
 import (
+	"errors"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/hashicorp/go-version"
 	. "github.com/petergtz/pegomock"
 	"github.com/runatlantis/atlantis/server/events"
 	"github.com/runatlantis/atlantis/server/events/mocks"
 	"github.com/runatlantis/atlantis/server/events/mocks/matchers"
 	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/plans"
+	planmocks "github.com/runatlantis/atlantis/server/events/plans/mocks"
+	planmatchers "github.com/runatlantis/atlantis/server/events/plans/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/runtime"
 	mocks2 "github.com/runatlantis/atlantis/server/events/runtime/mocks"
+	"github.com/runatlantis/atlantis/server/events/terraform"
+	"github.com/runatlantis/atlantis/server/events/webhooks"
 	"github.com/runatlantis/atlantis/server/events/yaml/valid"
 	"github.com/runatlantis/atlantis/server/logging"
 	. "github.com/runatlantis/atlantis/testing"
@@ -200,6 +210,653 @@ func TestDefaultProjectCommandRunner_Plan(t *testing.T) {
 	}
 }
 
+// Test that when plan is run with TF_LOG set, the captured log is added to
+// the result and then removed.
+func TestDefaultProjectCommandRunner_PlanTFLog(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	logPath := filepath.Join(tmp, "default.tflog")
+	Ok(t, ioutil.WriteFile(logPath, []byte("[DEBUG] line\n"), 0600))
+
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockLocker := mocks.NewMockProjectLocker()
+	runner := &events.DefaultProjectCommandRunner{
+		Locker:     mockLocker,
+		WorkingDir: mockWorkingDir,
+	}
+	ctx := models.ProjectCommandContext{
+		Log:        logging.NewNoopLogger(),
+		Workspace:  "default",
+		RepoRelDir: ".",
+		TFLogLevel: "DEBUG",
+	}
+	When(mockLocker.TryLock(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsPullRequest(),
+		matchers.AnyModelsUser(),
+		AnyString(),
+		matchers.AnyModelsProject(),
+	)).ThenReturn(&events.TryLockResponse{LockAcquired: false, LockFailureReason: "locked"}, nil)
+	When(mockWorkingDir.GetWorkingDir(ctx.BaseRepo, ctx.Pull, ctx.Workspace)).ThenReturn(tmp, nil)
+
+	res := runner.Plan(ctx)
+	Equals(t, "locked", res.Failure)
+	Equals(t, "[DEBUG] line", res.TFLog)
+	_, err := os.Stat(logPath)
+	Assert(t, os.IsNotExist(err), "exp log file to be removed")
+}
+
+// Test that the steps are run with the terraform version resolved for the
+// project.
+func TestDefaultProjectCommandRunner_PlanResolvesTerraformVersion(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	mockInit := mocks.NewMockStepRunner()
+	mockPlan := mocks.NewMockStepRunner()
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockLocker := mocks.NewMockProjectLocker()
+	mockResolver := mocks.NewMockTerraformVersionResolver()
+	runner := &events.DefaultProjectCommandRunner{
+		Locker:                   mockLocker,
+		LockURLGenerator:         mockURLGenerator{},
+		InitStepRunner:           mockInit,
+		PlanStepRunner:           mockPlan,
+		WorkingDir:               mockWorkingDir,
+		WorkingDirLocker:         events.NewDefaultWorkingDirLocker(),
+		TerraformVersionResolver: mockResolver,
+	}
+	configured, _ := version.NewVersion("0.12.3")
+	ctx := models.ProjectCommandContext{
+		Log:           logging.NewNoopLogger(),
+		Workspace:     "default",
+		RepoRelDir:    ".",
+		ProjectConfig: &valid.Project{Dir: ".", TerraformVersion: configured},
+	}
+	When(mockWorkingDir.Clone(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString(),
+	)).ThenReturn(tmp, nil)
+	When(mockLocker.TryLock(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsPullRequest(),
+		matchers.AnyModelsUser(),
+		AnyString(),
+		matchers.AnyModelsProject(),
+	)).ThenReturn(&events.TryLockResponse{LockAcquired: true, LockKey: "lock-key", UnlockFn: func() error { return nil }}, nil)
+	When(mockResolver.ResolveVersion(matchers.AnyPtrToLoggingSimpleLogger(), EqString(tmp), matchers.EqPtrToVersionVersion(configured))).
+		ThenReturn(configured, nil)
+	expCtx := ctx
+	expCtx.TerraformVersion = configured
+	When(mockInit.Run(expCtx, nil, tmp)).ThenReturn("", nil)
+	When(mockPlan.Run(expCtx, nil, tmp)).ThenReturn("plan", nil)
+
+	res := runner.Plan(ctx)
+	Assert(t, res.PlanSuccess != nil, "exp plan success")
+	Equals(t, "plan", res.PlanSuccess.TerraformOutput)
+	mockPlan.VerifyWasCalledOnce().Run(expCtx, nil, tmp)
+
+	t.Log("the project should fail to plan if its version can't be resolved")
+	When(mockResolver.ResolveVersion(matchers.AnyPtrToLoggingSimpleLogger(), EqString(tmp), matchers.EqPtrToVersionVersion(configured))).
+		ThenReturn(nil, errors.New("downloading terraform 0.12.3: unexpected status 404"))
+	res = runner.Plan(ctx)
+	ErrEquals(t, "getting terraform version: downloading terraform 0.12.3: unexpected status 404", res.Error)
+	mockPlan.VerifyWasCalledOnce().Run(expCtx, nil, tmp)
+}
+
+// Test that webhooks are sent when a plan claims a new lock and when it errors.
+func TestDefaultProjectCommandRunner_PlanWebhooks(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	mockInit := mocks.NewMockStepRunner()
+	mockPlan := mocks.NewMockStepRunner()
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockLocker := mocks.NewMockProjectLocker()
+	mockSender := mocks.NewMockWebhooksSender()
+	runner := &events.DefaultProjectCommandRunner{
+		Locker:           mockLocker,
+		LockURLGenerator: mockURLGenerator{},
+		InitStepRunner:   mockInit,
+		PlanStepRunner:   mockPlan,
+		WorkingDir:       mockWorkingDir,
+		WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
+		Webhooks:         mockSender,
+	}
+	ctx := models.ProjectCommandContext{
+		Log:        logging.NewNoopLogger(),
+		Workspace:  "default",
+		RepoRelDir: "staging",
+		User:       models.User{Username: "lkysow"},
+	}
+	When(mockWorkingDir.Clone(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString(),
+	)).ThenReturn(tmp, nil)
+	When(mockLocker.TryLock(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsPullRequest(),
+		matchers.AnyModelsUser(),
+		AnyString(),
+		matchers.AnyModelsProject(),
+	)).ThenReturn(&events.TryLockResponse{LockAcquired: true, NewLock: true, LockKey: "lock-key", UnlockFn: func() error { return nil }}, nil)
+	When(mockPlan.Run(ctx, nil, filepath.Join(tmp, "staging"))).ThenReturn("", errors.New("exit status 1"))
+
+	res := runner.Plan(ctx)
+	Assert(t, res.Error != nil, "exp plan error")
+	expResult := webhooks.Result{
+		Workspace: "default",
+		Directory: "staging",
+		User:      models.User{Username: "lkysow"},
+	}
+	lockResult := expResult
+	lockResult.Event = webhooks.LockEvent
+	lockResult.Success = true
+	errResult := expResult
+	errResult.Event = webhooks.PlanErrorEvent
+	errResult.Error = res.Error.Error()
+	mockSender.VerifyWasCalledOnce().Send(matchers.AnyPtrToLoggingSimpleLogger(), matchers.EqWebhooksResult(lockResult))
+	mockSender.VerifyWasCalledOnce().Send(matchers.AnyPtrToLoggingSimpleLogger(), matchers.EqWebhooksResult(errResult))
+}
+
+// Test that plans are tracked as jobs and that the output of run steps, which
+// isn't streamed, is added once they finish.
+func TestDefaultProjectCommandRunner_PlanJob(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	mockPlan := mocks.NewMockStepRunner()
+	mockRun := mocks.NewMockStepRunner()
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockTracker := mocks.NewMockJobTracker()
+	runner := &events.DefaultProjectCommandRunner{
+		Locker:           acquiringLocker(),
+		LockURLGenerator: mockURLGenerator{},
+		PlanStepRunner:   mockPlan,
+		RunStepRunner:    mockRun,
+		WorkingDir:       mockWorkingDir,
+		WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
+		JobTracker:       mockTracker,
+	}
+	workflow := "custom"
+	ctx := models.ProjectCommandContext{
+		Log:           logging.NewNoopLogger(),
+		Workspace:     "default",
+		RepoRelDir:    ".",
+		BaseRepo:      models.Repo{FullName: "owner/repo"},
+		Pull:          models.PullRequest{Num: 1, URL: "https://github.com/owner/repo/pull/1"},
+		User:          models.User{Username: "lkysow"},
+		ProjectConfig: &valid.Project{Dir: ".", Workflow: &workflow},
+		GlobalConfig: &valid.Config{
+			Workflows: map[string]valid.Workflow{
+				workflow: {
+					Plan: &valid.Stage{
+						Steps: []valid.Step{{StepName: "run", RunCommand: []string{"echo", "hi"}}, {StepName: "plan"}},
+					},
+				},
+			},
+		},
+	}
+	When(mockWorkingDir.Clone(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString(),
+	)).ThenReturn(tmp, nil)
+	When(mockTracker.Start(matchers.AnyModelsJob())).ThenReturn("job-id")
+	When(mockRun.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())).ThenReturn("hi", nil)
+	When(mockPlan.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())).ThenReturn("plan", nil)
+
+	res := runner.Plan(ctx)
+	Assert(t, res.PlanSuccess != nil, "exp plan success")
+	mockTracker.VerifyWasCalledOnce().Start(models.Job{
+		Command:      "plan",
+		RepoFullName: "owner/repo",
+		PullNum:      1,
+		PullURL:      "https://github.com/owner/repo/pull/1",
+		RepoRelDir:   ".",
+		Workspace:    "default",
+		User:         "lkysow",
+	})
+	mockTracker.VerifyWasCalledOnce().AppendOutput("job-id", "hi")
+	mockTracker.VerifyWasCalled(Never()).AppendOutput("job-id", "plan")
+	mockTracker.VerifyWasCalledOnce().Finish("job-id", true)
+
+	// The plan step's output is streamed with its CancelCtx.
+	planCtx, _, _ := mockPlan.VerifyWasCalledOnce().Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString()).GetCapturedArguments()
+	Equals(t, "job-id", planCtx.JobID)
+	Assert(t, planCtx.CancelCtx != nil, "exp plan step to be given a context to stream its output")
+
+	t.Log("when the plan errors, the job should fail")
+	When(mockPlan.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())).ThenReturn("", errors.New("exit status 1"))
+	res = runner.Plan(ctx)
+	Assert(t, res.Error != nil, "exp plan error")
+	mockTracker.VerifyWasCalledOnce().AppendOutput("job-id", "exit status 1")
+	mockTracker.VerifyWasCalledOnce().Finish("job-id", false)
+}
+
+// Test that plans are summarized by resource type when they can be shown as
+// JSON.
+func TestDefaultProjectCommandRunner_PlanResourceSummaries(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	mockPlan := mocks.NewMockStepRunner()
+	mockShow := mocks.NewMockStepRunner()
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	runner := &events.DefaultProjectCommandRunner{
+		Locker:           acquiringLocker(),
+		LockURLGenerator: mockURLGenerator{},
+		PlanStepRunner:   mockPlan,
+		ShowStepRunner:   mockShow,
+		WorkingDir:       mockWorkingDir,
+		WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
+	}
+	workflow := "custom"
+	ctx := models.ProjectCommandContext{
+		Log:           logging.NewNoopLogger(),
+		Workspace:     "default",
+		RepoRelDir:    ".",
+		ProjectConfig: &valid.Project{Dir: ".", Workflow: &workflow},
+		GlobalConfig: &valid.Config{
+			Workflows: map[string]valid.Workflow{
+				workflow: {
+					Plan: &valid.Stage{Steps: []valid.Step{{StepName: "plan"}}},
+				},
+			},
+		},
+	}
+	When(mockWorkingDir.Clone(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString(),
+	)).ThenReturn(tmp, nil)
+	When(mockPlan.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())).ThenReturn("plan", nil)
+	When(mockShow.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())).ThenReturn(`{"resource_changes": [{"type": "null_resource", "change": {"actions": ["create"]}}]}`, nil)
+
+	res := runner.Plan(ctx)
+	Assert(t, res.PlanSuccess != nil, "exp plan success")
+	Equals(t, []runtime.ResourceTypeSummary{{Type: "null_resource", PlanSummary: runtime.PlanSummary{Add: 1}}}, res.PlanSuccess.ResourceSummaries)
+
+	t.Log("when the plan can't be shown, it should still succeed without a summary")
+	When(mockShow.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())).ThenReturn("", errors.New("plan file not found"))
+	res = runner.Plan(ctx)
+	Assert(t, res.PlanSuccess != nil, "exp plan success")
+	Assert(t, res.PlanSuccess.ResourceSummaries == nil, "exp no summaries")
+}
+
+// Test that plans have their costs estimated unless the repo opted out, and
+// that failed estimates don't fail the plan.
+func TestDefaultProjectCommandRunner_PlanCostEstimate(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	mockPlan := mocks.NewMockStepRunner()
+	mockCost := mocks.NewMockStepRunner()
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	runner := &events.DefaultProjectCommandRunner{
+		Locker:                 acquiringLocker(),
+		LockURLGenerator:       mockURLGenerator{},
+		PlanStepRunner:         mockPlan,
+		CostEstimateStepRunner: mockCost,
+		WorkingDir:             mockWorkingDir,
+		WorkingDirLocker:       events.NewDefaultWorkingDirLocker(),
+	}
+	workflow := "custom"
+	ctx := models.ProjectCommandContext{
+		Log:           logging.NewNoopLogger(),
+		Workspace:     "default",
+		RepoRelDir:    ".",
+		ProjectConfig: &valid.Project{Dir: ".", Workflow: &workflow},
+		GlobalConfig: &valid.Config{
+			Workflows: map[string]valid.Workflow{
+				workflow: {
+					Plan: &valid.Stage{Steps: []valid.Step{{StepName: "plan"}}},
+				},
+			},
+		},
+	}
+	When(mockWorkingDir.Clone(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString(),
+	)).ThenReturn(tmp, nil)
+	When(mockPlan.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())).ThenReturn("plan", nil)
+	When(mockCost.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())).ThenReturn(`{"currency": "USD", "totalMonthlyCost": "20", "pastTotalMonthlyCost": "10", "diffTotalMonthlyCost": "10"}`, nil)
+
+	res := runner.Plan(ctx)
+	Assert(t, res.PlanSuccess != nil, "exp plan success")
+	Equals(t, &runtime.CostEstimate{Currency: "USD", PastMonthlyCost: 10, MonthlyCost: 20, DiffMonthlyCost: 10}, res.PlanSuccess.CostEstimate)
+
+	t.Log("when the estimate fails, the plan should still succeed without one")
+	When(mockCost.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())).ThenReturn("", errors.New("infracost: not found"))
+	res = runner.Plan(ctx)
+	Assert(t, res.PlanSuccess != nil, "exp plan success")
+	Assert(t, res.PlanSuccess.CostEstimate == nil, "exp no estimate")
+
+	t.Log("repos that opted out should not be estimated")
+	ctx.DisableCostEstimation = true
+	res = runner.Plan(ctx)
+	Assert(t, res.PlanSuccess != nil, "exp plan success")
+	mockCost.VerifyWasCalled(Times(2)).Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())
+}
+
+type fakeTerraformCloudRuns struct {
+	runIDs []string
+}
+
+func (f *fakeTerraformCloudRuns) ReadRunChecks(hostname string, runID string) (*terraform.CloudRunChecks, error) {
+	f.runIDs = append(f.runIDs, hostname+"/"+runID)
+	return &terraform.CloudRunChecks{
+		CostEstimate: &terraform.CloudCostEstimate{Status: "finished", ProposedMonthlyCost: "35.5", DeltaMonthlyCost: "25.5"},
+		PolicyChecks: []terraform.CloudPolicyCheck{{Status: "passed", Passed: 2}},
+	}, nil
+}
+
+// Test that plans of projects mapped to Terraform Cloud workspaces link the
+// workspace and the results of their run, if it ran in that workspace.
+func TestDefaultProjectCommandRunner_PlanTerraformCloud(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	mockPlan := mocks.NewMockStepRunner()
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	cloudRuns := &fakeTerraformCloudRuns{}
+	runner := &events.DefaultProjectCommandRunner{
+		Locker:             acquiringLocker(),
+		LockURLGenerator:   mockURLGenerator{},
+		PlanStepRunner:     mockPlan,
+		WorkingDir:         mockWorkingDir,
+		WorkingDirLocker:   events.NewDefaultWorkingDirLocker(),
+		TerraformCloudRuns: cloudRuns,
+	}
+	workflow := "custom"
+	ctx := models.ProjectCommandContext{
+		Log:        logging.NewNoopLogger(),
+		Workspace:  "default",
+		RepoRelDir: ".",
+		ProjectConfig: &valid.Project{
+			Dir:            ".",
+			Workflow:       &workflow,
+			TerraformCloud: &valid.TerraformCloud{Hostname: "app.terraform.io", Organization: "org", Workspace: "network"},
+		},
+		GlobalConfig: &valid.Config{
+			Workflows: map[string]valid.Workflow{
+				workflow: {
+					Plan: &valid.Stage{Steps: []valid.Step{{StepName: "plan"}}},
+				},
+			},
+		},
+	}
+	When(mockWorkingDir.Clone(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString(),
+	)).ThenReturn(tmp, nil)
+
+	When(mockPlan.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())).ThenReturn("Remote run: https://app.terraform.io/app/org/network/runs/run-abc\n\nplan", nil)
+	res := runner.Plan(ctx)
+	Assert(t, res.PlanSuccess != nil, "exp plan success")
+	Equals(t, &events.TerraformCloudSummary{
+		Organization: "org",
+		Workspace:    "network",
+		WorkspaceURL: "https://app.terraform.io/app/org/workspaces/network",
+		RunURL:       "https://app.terraform.io/app/org/network/runs/run-abc",
+		CostEstimate: &terraform.CloudCostEstimate{Status: "finished", ProposedMonthlyCost: "35.5", DeltaMonthlyCost: "25.5"},
+		PolicyChecks: []terraform.CloudPolicyCheck{{Status: "passed", Passed: 2}},
+	}, res.PlanSuccess.TerraformCloud)
+
+	t.Log("runs in other workspaces should not be linked")
+	When(mockPlan.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())).ThenReturn("Remote run: https://app.terraform.io/app/org/other/runs/run-def\n\nplan", nil)
+	res = runner.Plan(ctx)
+	Assert(t, res.PlanSuccess != nil, "exp plan success")
+	Equals(t, &events.TerraformCloudSummary{
+		Organization: "org",
+		Workspace:    "network",
+		WorkspaceURL: "https://app.terraform.io/app/org/workspaces/network",
+	}, res.PlanSuccess.TerraformCloud)
+	Equals(t, []string{"app.terraform.io/run-abc"}, cloudRuns.runIDs)
+}
+
+// Test that when a plan fails its policy checks, it's marked so that it can't
+// be applied.
+func TestDefaultProjectCommandRunner_PlanPolicyCheckFailed(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	mockInit := mocks.NewMockStepRunner()
+	mockPlan := mocks.NewMockStepRunner()
+	mockPolicyCheck := mocks.NewMockStepRunner()
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockLocker := mocks.NewMockProjectLocker()
+	runner := &events.DefaultProjectCommandRunner{
+		Locker:                mockLocker,
+		LockURLGenerator:      mockURLGenerator{},
+		InitStepRunner:        mockInit,
+		PlanStepRunner:        mockPlan,
+		PolicyCheckStepRunner: mockPolicyCheck,
+		WorkingDir:            mockWorkingDir,
+		WorkingDirLocker:      events.NewDefaultWorkingDirLocker(),
+	}
+	ctx := models.ProjectCommandContext{
+		Log:        logging.NewNoopLogger(),
+		Workspace:  "default",
+		RepoRelDir: ".",
+		PolicySets: []valid.PolicySet{{Name: "security", Path: "/policies/security"}},
+	}
+	When(mockWorkingDir.Clone(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString(),
+	)).ThenReturn(tmp, nil)
+	When(mockLocker.TryLock(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsPullRequest(),
+		matchers.AnyModelsUser(),
+		AnyString(),
+		matchers.AnyModelsProject(),
+	)).ThenReturn(&events.TryLockResponse{LockAcquired: true, LockKey: "lock-key"}, nil)
+	When(mockInit.Run(ctx, nil, tmp)).ThenReturn("init", nil)
+	When(mockPlan.Run(ctx, nil, tmp)).ThenReturn("plan", nil)
+	When(mockPolicyCheck.Run(ctx, nil, tmp)).ThenReturn("policy set: security\nFAIL", errors.New("policy set(s) failed: security"))
+
+	res := runner.Plan(ctx)
+	Assert(t, res.PlanSuccess != nil, "exp plan success")
+	Equals(t, "policy set: security\nFAIL\npolicy set(s) failed: security", res.PlanSuccess.PolicyCheckOutput)
+	Equals(t, true, res.PlanSuccess.PolicyCheckFailed)
+	Equals(t, models.FailedCommitStatus, res.Status())
+	_, err := os.Stat(filepath.Join(tmp, "default.policyfail"))
+	Ok(t, err)
+
+	t.Log("planning again with passing policies should remove the mark")
+	When(mockPolicyCheck.Run(ctx, nil, tmp)).ThenReturn("policy set: security\nPASS", nil)
+	res = runner.Plan(ctx)
+	Equals(t, false, res.PlanSuccess.PolicyCheckFailed)
+	_, err = os.Stat(filepath.Join(tmp, "default.policyfail"))
+	Assert(t, os.IsNotExist(err), "exp policy check mark to be removed")
+
+	t.Log("a workflow can't skip the policy check")
+	ctx.ProjectConfig = &valid.Project{Dir: ".", Workflow: String("skip")}
+	ctx.GlobalConfig = &valid.Config{
+		Version: 2,
+		Workflows: map[string]valid.Workflow{
+			"skip": {PolicyCheck: &valid.Stage{Steps: nil}},
+		},
+	}
+	When(mockInit.Run(ctx, nil, tmp)).ThenReturn("init", nil)
+	When(mockPlan.Run(ctx, nil, tmp)).ThenReturn("plan", nil)
+	res = runner.Plan(ctx)
+	Equals(t, true, res.PlanSuccess.PolicyCheckFailed)
+	Equals(t, "the policy_check stage must include the policy_check step", res.PlanSuccess.PolicyCheckOutput)
+}
+
+func TestDefaultProjectCommandRunner_ApplyPolicyCheckFailed(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	Ok(t, ioutil.WriteFile(filepath.Join(tmp, "default.policyfail"), nil, 0600))
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockApply := mocks.NewMockStepRunner()
+	runner := &events.DefaultProjectCommandRunner{
+		Locker:           acquiringLocker(),
+		ApplyStepRunner:  mockApply,
+		WorkingDir:       mockWorkingDir,
+		Webhooks:         mocks.NewMockWebhooksSender(),
+		WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
+	}
+	ctx := models.ProjectCommandContext{
+		Log:        logging.NewNoopLogger(),
+		Workspace:  "default",
+		RepoRelDir: ".",
+	}
+	When(mockWorkingDir.GetWorkingDir(ctx.BaseRepo, ctx.Pull, ctx.Workspace)).ThenReturn(tmp, nil)
+
+	res := runner.Apply(ctx)
+	Equals(t, "This plan failed its policy checks. A policy owner must comment `atlantis approve_policies` before it can be applied.", res.Failure)
+	mockApply.VerifyWasCalled(Never()).Run(ctx, nil, tmp)
+
+	t.Log("approving the policies should allow the apply")
+	res = runner.ApprovePolicies(ctx)
+	Ok(t, res.Error)
+	Equals(t, true, res.PoliciesApproved)
+	_, err := os.Stat(filepath.Join(tmp, "default.policyfail"))
+	Assert(t, os.IsNotExist(err), "exp policy check mark to be removed")
+
+	When(mockApply.Run(ctx, nil, tmp)).ThenReturn("apply", nil)
+	res = runner.Apply(ctx)
+	Equals(t, "apply", res.ApplySuccess)
+}
+
+// Test that destroy plans are marked so that apply checks the destroy apply
+// requirements.
+func TestDefaultProjectCommandRunner_PlanDestroy(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	mockPlan := mocks.NewMockStepRunner()
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	runner := &events.DefaultProjectCommandRunner{
+		Locker:           acquiringLocker(),
+		LockURLGenerator: mockURLGenerator{},
+		PlanStepRunner:   mockPlan,
+		WorkingDir:       mockWorkingDir,
+		WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
+	}
+	workflow := "custom"
+	ctx := models.ProjectCommandContext{
+		Log:           logging.NewNoopLogger(),
+		Workspace:     "default",
+		RepoRelDir:    ".",
+		Destroy:       true,
+		ProjectConfig: &valid.Project{Dir: ".", Workflow: &workflow},
+		GlobalConfig: &valid.Config{
+			Workflows: map[string]valid.Workflow{
+				workflow: {
+					Plan: &valid.Stage{Steps: []valid.Step{{StepName: "plan"}}},
+				},
+			},
+		},
+	}
+	When(mockWorkingDir.Clone(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString(),
+	)).ThenReturn(tmp, nil)
+	When(mockPlan.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())).ThenReturn("plan", nil)
+	markerPath := filepath.Join(tmp, "default.destroy")
+
+	res := runner.Plan(ctx)
+	Assert(t, res.PlanSuccess != nil, "exp plan success")
+	Equals(t, true, res.PlanSuccess.Destroy)
+	_, err := os.Stat(markerPath)
+	Ok(t, err)
+
+	t.Log("a regular plan should remove the mark")
+	ctx.Destroy = false
+	res = runner.Plan(ctx)
+	Equals(t, false, res.PlanSuccess.Destroy)
+	_, err = os.Stat(markerPath)
+	Assert(t, os.IsNotExist(err), "exp destroy plan mark to be removed")
+
+	t.Log("a workflow's plan step can make destroy plans")
+	ctx.GlobalConfig.Workflows[workflow] = valid.Workflow{
+		Plan: &valid.Stage{Steps: []valid.Step{{StepName: "plan", ExtraArgs: []string{"-destroy"}}}},
+	}
+	res = runner.Plan(ctx)
+	Equals(t, true, res.PlanSuccess.Destroy)
+
+	t.Log("so can the comment's terraform args")
+	ctx.GlobalConfig.Workflows[workflow] = valid.Workflow{
+		Plan: &valid.Stage{Steps: []valid.Step{{StepName: "plan"}}},
+	}
+	ctx.CommentArgs = []string{`"-destroy"`}
+	res = runner.Plan(ctx)
+	Equals(t, true, res.PlanSuccess.Destroy)
+}
+
+// Test that destroy plans must meet the destroy apply requirements, which
+// default to approved.
+func TestDefaultProjectCommandRunner_ApplyDestroyPlan(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	Ok(t, ioutil.WriteFile(filepath.Join(tmp, "default.destroy"), nil, 0600))
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockApply := mocks.NewMockStepRunner()
+	mockApproved := mocks2.NewMockPullApprovedChecker()
+	mockMergeable := mocks2.NewMockPullMergeableChecker()
+	runner := &events.DefaultProjectCommandRunner{
+		Locker:               acquiringLocker(),
+		ApplyStepRunner:      mockApply,
+		WorkingDir:           mockWorkingDir,
+		PullApprovedChecker:  mockApproved,
+		PullMergeableChecker: mockMergeable,
+		Webhooks:             mocks.NewMockWebhooksSender(),
+		WorkingDirLocker:     events.NewDefaultWorkingDirLocker(),
+	}
+	ctx := models.ProjectCommandContext{
+		Log:        logging.NewNoopLogger(),
+		Workspace:  "default",
+		RepoRelDir: ".",
+	}
+	When(mockWorkingDir.GetWorkingDir(ctx.BaseRepo, ctx.Pull, ctx.Workspace)).ThenReturn(tmp, nil)
+	When(mockApproved.PullIsApproved(ctx.BaseRepo, ctx.Pull)).ThenReturn(false, nil)
+	When(mockMergeable.PullIsMergeable(ctx.BaseRepo, ctx.Pull)).ThenReturn(false, nil)
+	When(mockApply.Run(ctx, nil, tmp)).ThenReturn("apply", nil)
+
+	res := runner.Apply(ctx)
+	Equals(t, "Pull request must be approved before applying a destroy plan.", res.Failure)
+	mockApply.VerifyWasCalled(Never()).Run(ctx, nil, tmp)
+
+	t.Log("the server-side repo config can set the destroy apply requirements")
+	ctx.ProjectConfig = &valid.Project{Dir: ".", DestroyApplyRequirements: []string{"mergeable"}}
+	res = runner.Apply(ctx)
+	Equals(t, "Pull request must be mergeable before applying a destroy plan.", res.Failure)
+
+	When(mockMergeable.PullIsMergeable(ctx.BaseRepo, ctx.Pull)).ThenReturn(true, nil)
+	When(mockApply.Run(ctx, nil, tmp)).ThenReturn("apply", nil)
+	res = runner.Apply(ctx)
+	Equals(t, "apply", res.ApplySuccess)
+}
+
 func TestDefaultProjectCommandRunner_ApplyNotCloned(t *testing.T) {
 	mockWorkingDir := mocks.NewMockWorkingDir()
 	runner := &events.DefaultProjectCommandRunner{
@@ -406,11 +1063,10 @@ func TestDefaultProjectCommandRunner_Apply(t *testing.T) {
 			mockApproved := mocks2.NewMockPullApprovedChecker()
 			mockMergeable := mocks2.NewMockPullMergeableChecker()
 			mockWorkingDir := mocks.NewMockWorkingDir()
-			mockLocker := mocks.NewMockProjectLocker()
 			mockSender := mocks.NewMockWebhooksSender()
 
 			runner := events.DefaultProjectCommandRunner{
-				Locker:               mockLocker,
+				Locker:               acquiringLocker(),
 				LockURLGenerator:     mockURLGenerator{},
 				InitStepRunner:       mockInit,
 				PlanStepRunner:       mockPlan,
@@ -467,8 +1123,655 @@ func TestDefaultProjectCommandRunner_Apply(t *testing.T) {
 	}
 }
 
+// Projects run with terragrunt should use the built-in terragrunt stages
+// unless their workflow sets its own.
+func TestDefaultProjectCommandRunner_Terragrunt(t *testing.T) {
+	RegisterMockTestingT(t)
+	mockTerragrunt := mocks.NewMockStepRunner()
+	mockInit := mocks.NewMockStepRunner()
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	runner := events.DefaultProjectCommandRunner{
+		Locker:               acquiringLocker(),
+		LockURLGenerator:     mockURLGenerator{},
+		InitStepRunner:       mockInit,
+		TerragruntStepRunner: mockTerragrunt,
+		WorkingDir:           mockWorkingDir,
+		WorkingDirLocker:     events.NewDefaultWorkingDirLocker(),
+	}
+	repoDir := "/tmp/mydir"
+	When(mockWorkingDir.Clone(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString(),
+	)).ThenReturn(repoDir, nil)
+	When(mockWorkingDir.GetWorkingDir(
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString(),
+	)).ThenReturn(repoDir, nil)
+
+	ctx := models.ProjectCommandContext{
+		Log:        logging.NewNoopLogger(),
+		Workspace:  "default",
+		RepoRelDir: ".",
+		Terragrunt: true,
+	}
+	When(mockTerragrunt.Run(ctx, []string{"init"}, repoDir)).ThenReturn("", nil)
+	When(mockTerragrunt.Run(ctx, []string{"plan"}, repoDir)).ThenReturn("plan", nil)
+	When(mockTerragrunt.Run(ctx, []string{"apply"}, repoDir)).ThenReturn("apply", nil)
+
+	res := runner.Plan(ctx)
+	Assert(t, res.PlanSuccess != nil, "exp plan success")
+	Equals(t, "plan", res.PlanSuccess.TerraformOutput)
+	mockTerragrunt.VerifyWasCalledOnce().Run(ctx, []string{"init"}, repoDir)
+	Equals(t, "apply", runner.Apply(ctx).ApplySuccess)
+
+	// A workflow's own stages win.
+	ctx.ProjectConfig = &valid.Project{Dir: ".", Workflow: String("custom")}
+	ctx.GlobalConfig = &valid.Config{
+		Workflows: map[string]valid.Workflow{
+			"custom": {Plan: &valid.Stage{Steps: []valid.Step{{StepName: "init"}}}},
+		},
+	}
+	When(mockInit.Run(ctx, nil, repoDir)).ThenReturn("init", nil)
+	res = runner.Plan(ctx)
+	Assert(t, res.PlanSuccess != nil, "exp plan success")
+	Equals(t, "init", res.PlanSuccess.TerraformOutput)
+}
+
+// Test that run step output is only added to the result according to each
+// step's show_output setting and that outputs stay in order.
+func TestDefaultProjectCommandRunner_RunStepShowOutput(t *testing.T) {
+	cases := []struct {
+		description string
+		showOutput  string
+		runErr      error
+		expOut      string
+		expErr      string
+	}{
+		{
+			description: "unset",
+			expOut:      "run\napply",
+		},
+		{
+			description: "always",
+			showOutput:  valid.ShowOutputAlways,
+			expOut:      "run\napply",
+		},
+		{
+			description: "on_error success",
+			showOutput:  valid.ShowOutputOnError,
+			expOut:      "apply",
+		},
+		{
+			description: "on_error failure",
+			showOutput:  valid.ShowOutputOnError,
+			runErr:      errors.New("failed"),
+			expErr:      "failed\nrun",
+		},
+		{
+			description: "never success",
+			showOutput:  valid.ShowOutputNever,
+			expOut:      "apply",
+		},
+		{
+			description: "never failure",
+			showOutput:  valid.ShowOutputNever,
+			runErr:      errors.New("failed: run"),
+			expErr:      "running \"scan\" failed, its output is hidden because show_output is never\n",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			mockApply := mocks.NewMockStepRunner()
+			mockRun := mocks.NewMockStepRunner()
+			mockWorkingDir := mocks.NewMockWorkingDir()
+			runner := events.DefaultProjectCommandRunner{
+				Locker:           acquiringLocker(),
+				ApplyStepRunner:  mockApply,
+				RunStepRunner:    mockRun,
+				WorkingDir:       mockWorkingDir,
+				Webhooks:         mocks.NewMockWebhooksSender(),
+				WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
+			}
+			repoDir := "/tmp/mydir"
+			When(mockWorkingDir.GetWorkingDir(
+				matchers.AnyModelsRepo(),
+				matchers.AnyModelsPullRequest(),
+				AnyString(),
+			)).ThenReturn(repoDir, nil)
+
+			runCommand := []string{"scan"}
+			ctx := models.ProjectCommandContext{
+				Log:        logging.NewNoopLogger(),
+				Workspace:  "default",
+				RepoRelDir: ".",
+				ProjectConfig: &valid.Project{
+					Dir:      ".",
+					Workflow: String("myworkflow"),
+				},
+				GlobalConfig: &valid.Config{
+					Version: 2,
+					Workflows: map[string]valid.Workflow{
+						"myworkflow": {
+							Apply: &valid.Stage{
+								Steps: []valid.Step{
+									{StepName: "run", RunCommand: runCommand, ShowOutput: c.showOutput},
+									{StepName: "apply"},
+								},
+							},
+						},
+					},
+				},
+			}
+			When(mockRun.Run(ctx, runCommand, repoDir)).ThenReturn("run", c.runErr)
+			When(mockApply.Run(ctx, nil, repoDir)).ThenReturn("apply", nil)
+
+			res := runner.Apply(ctx)
+			if c.expErr != "" {
+				ErrEquals(t, c.expErr, res.Error)
+				return
+			}
+			Ok(t, res.Error)
+			Equals(t, c.expOut, res.ApplySuccess)
+		})
+	}
+}
+
+// Test that env steps set variables for the steps after them and that their
+// output isn't added to the result.
+func TestDefaultProjectCommandRunner_EnvStep(t *testing.T) {
+	RegisterMockTestingT(t)
+	mockApply := mocks.NewMockStepRunner()
+	mockRun := mocks.NewMockStepRunner()
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	runner := events.DefaultProjectCommandRunner{
+		Locker:           acquiringLocker(),
+		ApplyStepRunner:  mockApply,
+		RunStepRunner:    mockRun,
+		WorkingDir:       mockWorkingDir,
+		Webhooks:         mocks.NewMockWebhooksSender(),
+		WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
+	}
+	repoDir := "/tmp/mydir"
+	When(mockWorkingDir.GetWorkingDir(
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString(),
+	)).ThenReturn(repoDir, nil)
+
+	ctx := models.ProjectCommandContext{
+		Log:        logging.NewNoopLogger(),
+		Workspace:  "default",
+		RepoRelDir: ".",
+		ProjectConfig: &valid.Project{
+			Dir:      ".",
+			Workflow: String("myworkflow"),
+		},
+		GlobalConfig: &valid.Config{
+			Version: 2,
+			Workflows: map[string]valid.Workflow{
+				"myworkflow": {
+					Apply: &valid.Stage{
+						Steps: []valid.Step{
+							{StepName: "env", EnvVarName: "AWS_PROFILE", EnvVarValue: "production"},
+							{StepName: "env", EnvVarName: "AWS_SESSION_TOKEN", RunCommand: []string{"assume-role"}},
+							{StepName: "apply"},
+						},
+					},
+				},
+			},
+		},
+	}
+	When(mockRun.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())).ThenReturn("token\n", nil)
+	When(mockApply.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())).ThenReturn("apply", nil)
+
+	res := runner.Apply(ctx)
+	Ok(t, res.Error)
+	Equals(t, "apply", res.ApplySuccess)
+	runCtx, _, _ := mockRun.VerifyWasCalledOnce().Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString()).GetCapturedArguments()
+	Equals(t, []string{"AWS_PROFILE=production"}, terraform.Env(runCtx.CancelCtx))
+	applyCtx, _, _ := mockApply.VerifyWasCalledOnce().Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString()).GetCapturedArguments()
+	Equals(t, []string{"AWS_PROFILE=production", "AWS_SESSION_TOKEN=token"}, terraform.Env(applyCtx.CancelCtx))
+
+	t.Log("when an env step's command fails, its output should be hidden")
+	When(mockRun.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())).ThenReturn("secret", errors.New("failed: secret"))
+	res = runner.Apply(ctx)
+	ErrEquals(t, "running \"assume-role\" to set AWS_SESSION_TOKEN failed, its output is hidden since it can contain secrets\n", res.Error)
+}
+
+// Test that apply requirement groups are evaluated with any_of/all_of
+// semantics and that the failure explains which group failed.
+func TestDefaultProjectCommandRunner_ApplyRequirementGroups(t *testing.T) {
+	cases := []struct {
+		description string
+		reqs        []string
+		groups      []valid.ApplyRequirementGroup
+		approved    bool
+		mergeable   bool
+		expFailure  string
+	}{
+		{
+			description: "any_of with one met",
+			groups:      []valid.ApplyRequirementGroup{{AnyOf: true, Requirements: []string{"approved", "mergeable"}}},
+			approved:    false,
+			mergeable:   true,
+			expFailure:  "",
+		},
+		{
+			description: "any_of with none met",
+			groups:      []valid.ApplyRequirementGroup{{AnyOf: true, Requirements: []string{"approved", "mergeable"}}},
+			approved:    false,
+			mergeable:   false,
+			expFailure:  "Pull request must be approved or mergeable before running apply (any_of group not met).",
+		},
+		{
+			description: "all_of with one not met",
+			groups:      []valid.ApplyRequirementGroup{{AnyOf: false, Requirements: []string{"approved", "mergeable"}}},
+			approved:    true,
+			mergeable:   false,
+			expFailure:  "Pull request must be approved and mergeable before running apply (all_of group not met: mergeable).",
+		},
+		{
+			description: "flat requirement is checked before groups",
+			reqs:        []string{"approved"},
+			groups:      []valid.ApplyRequirementGroup{{AnyOf: true, Requirements: []string{"mergeable"}}},
+			approved:    false,
+			mergeable:   false,
+			expFailure:  "Pull request must be approved before running apply.",
+		},
+		{
+			description: "flat requirement and group both met",
+			reqs:        []string{"approved"},
+			groups:      []valid.ApplyRequirementGroup{{AnyOf: true, Requirements: []string{"approved", "mergeable"}}},
+			approved:    true,
+			mergeable:   false,
+			expFailure:  "",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			mockApply := mocks.NewMockStepRunner()
+			mockApproved := mocks2.NewMockPullApprovedChecker()
+			mockMergeable := mocks2.NewMockPullMergeableChecker()
+			mockWorkingDir := mocks.NewMockWorkingDir()
+			runner := events.DefaultProjectCommandRunner{
+				Locker:               acquiringLocker(),
+				LockURLGenerator:     mockURLGenerator{},
+				ApplyStepRunner:      mockApply,
+				PullApprovedChecker:  mockApproved,
+				PullMergeableChecker: mockMergeable,
+				WorkingDir:           mockWorkingDir,
+				Webhooks:             mocks.NewMockWebhooksSender(),
+				WorkingDirLocker:     events.NewDefaultWorkingDirLocker(),
+			}
+
+			repoDir := "/tmp/mydir"
+			When(mockWorkingDir.GetWorkingDir(
+				matchers.AnyModelsRepo(),
+				matchers.AnyModelsPullRequest(),
+				AnyString(),
+			)).ThenReturn(repoDir, nil)
+
+			ctx := models.ProjectCommandContext{
+				Log: logging.NewNoopLogger(),
+				ProjectConfig: &valid.Project{
+					Dir:                    ".",
+					ApplyRequirements:      c.reqs,
+					ApplyRequirementGroups: c.groups,
+				},
+				Workspace:  "default",
+				RepoRelDir: ".",
+			}
+			When(mockApply.Run(ctx, nil, repoDir)).ThenReturn("apply", nil)
+			When(mockApproved.PullIsApproved(ctx.BaseRepo, ctx.Pull)).ThenReturn(c.approved, nil)
+			When(mockMergeable.PullIsMergeable(ctx.BaseRepo, ctx.Pull)).ThenReturn(c.mergeable, nil)
+
+			res := runner.Apply(ctx)
+			Equals(t, c.expFailure, res.Failure)
+			if c.expFailure == "" {
+				Equals(t, "apply", res.ApplySuccess)
+			} else {
+				mockApply.VerifyWasCalled(Never()).Run(ctx, nil, repoDir)
+			}
+			// Requirements are only checked once even if they're repeated.
+			if c.approved && len(c.reqs) > 0 {
+				mockApproved.VerifyWasCalledOnce().PullIsApproved(ctx.BaseRepo, ctx.Pull)
+			}
+		})
+	}
+}
+
 type mockURLGenerator struct{}
 
 func (m mockURLGenerator) GenerateLockURL(lockID string) string {
 	return "https://" + lockID
 }
+
+// If another pull holds the project's lock, the apply should wait in line for
+// it instead of running.
+func TestDefaultProjectCommandRunner_ApplyLocked(t *testing.T) {
+	RegisterMockTestingT(t)
+	mockApply := mocks.NewMockStepRunner()
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockLocker := mocks.NewMockProjectLocker()
+	runner := &events.DefaultProjectCommandRunner{
+		Locker:           mockLocker,
+		ApplyStepRunner:  mockApply,
+		WorkingDir:       mockWorkingDir,
+		Webhooks:         mocks.NewMockWebhooksSender(),
+		WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
+	}
+	ctx := models.ProjectCommandContext{
+		Log:        logging.NewNoopLogger(),
+		Workspace:  "default",
+		RepoRelDir: ".",
+		BaseRepo:   models.Repo{FullName: "owner/repo"},
+		Pull:       models.PullRequest{Num: 2},
+	}
+	When(mockWorkingDir.GetWorkingDir(ctx.BaseRepo, ctx.Pull, ctx.Workspace)).ThenReturn("/tmp/mydir", nil)
+	When(mockLocker.TryLock(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.EqModelsPullRequest(ctx.Pull),
+		matchers.AnyModelsUser(),
+		EqString("default"),
+		matchers.EqModelsProject(models.NewProject("owner/repo", ".")),
+	)).ThenReturn(&events.TryLockResponse{LockAcquired: false, LockFailureReason: "queued behind pull #1"}, nil)
+
+	res := runner.Apply(ctx)
+	Equals(t, "queued behind pull #1", res.Failure)
+	mockApply.VerifyWasCalled(Never()).Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())
+}
+
+// Import should run the init steps of the project's plan stage and then the
+// import, without planning.
+func TestDefaultProjectCommandRunner_Import(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	mockInit := mocks.NewMockStepRunner()
+	mockPlan := mocks.NewMockStepRunner()
+	mockImport := mocks.NewMockStepRunner()
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	runner := &events.DefaultProjectCommandRunner{
+		Locker:           acquiringLocker(),
+		InitStepRunner:   mockInit,
+		PlanStepRunner:   mockPlan,
+		ImportStepRunner: mockImport,
+		WorkingDir:       mockWorkingDir,
+		WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
+	}
+	workflow := "custom"
+	ctx := models.ProjectCommandContext{
+		Log:           logging.NewNoopLogger(),
+		Workspace:     "default",
+		RepoRelDir:    ".",
+		BaseRepo:      models.Repo{FullName: "owner/repo"},
+		Pull:          models.PullRequest{Num: 1},
+		ProjectConfig: &valid.Project{Dir: ".", Workflow: &workflow},
+		GlobalConfig: &valid.Config{
+			Workflows: map[string]valid.Workflow{
+				workflow: {
+					Plan: &valid.Stage{
+						Steps: []valid.Step{{StepName: "init", ExtraArgs: []string{"-backend-config=staging.hcl"}}, {StepName: "plan"}},
+					},
+				},
+			},
+		},
+		RePlanCmd:     "atlantis plan -d .",
+		ImportAddress: "aws_instance.web",
+		ImportID:      "i-abcd1234",
+	}
+	When(mockWorkingDir.Clone(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString(),
+	)).ThenReturn(tmp, nil)
+	When(mockInit.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())).ThenReturn("init", nil)
+	When(mockImport.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())).ThenReturn("Import successful!", nil)
+
+	res := runner.Import(ctx)
+	Ok(t, res.Error)
+	Equals(t, &events.ImportSuccess{TerraformOutput: "init\nImport successful!", RePlanCmd: "atlantis plan -d ."}, res.ImportSuccess)
+	mockInit.VerifyWasCalledOnce().Run(ctx, []string{"-backend-config=staging.hcl"}, tmp)
+	mockImport.VerifyWasCalledOnce().Run(ctx, nil, tmp)
+	mockPlan.VerifyWasCalled(Never()).Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())
+
+	t.Log("when the import fails we should get its output in the error")
+	When(mockImport.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())).ThenReturn("Error: resource already managed by Terraform", errors.New("exit status 1"))
+	res = runner.Import(ctx)
+	ErrEquals(t, "exit status 1\ninit\nError: resource already managed by Terraform", res.Error)
+	Assert(t, res.ImportSuccess == nil, "exp no import success")
+}
+
+// Import needs the same lock as plan so it waits in line if another pull
+// holds it.
+func TestDefaultProjectCommandRunner_ImportLocked(t *testing.T) {
+	RegisterMockTestingT(t)
+	mockImport := mocks.NewMockStepRunner()
+	mockLocker := mocks.NewMockProjectLocker()
+	runner := &events.DefaultProjectCommandRunner{
+		Locker:           mockLocker,
+		ImportStepRunner: mockImport,
+		WorkingDir:       mocks.NewMockWorkingDir(),
+		WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
+	}
+	ctx := models.ProjectCommandContext{
+		Log:        logging.NewNoopLogger(),
+		Workspace:  "default",
+		RepoRelDir: ".",
+		BaseRepo:   models.Repo{FullName: "owner/repo"},
+		Pull:       models.PullRequest{Num: 2},
+	}
+	When(mockLocker.TryLock(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.EqModelsPullRequest(ctx.Pull),
+		matchers.AnyModelsUser(),
+		EqString("default"),
+		matchers.EqModelsProject(models.NewProject("owner/repo", ".")),
+	)).ThenReturn(&events.TryLockResponse{LockAcquired: false, LockFailureReason: "queued behind pull #1"}, nil)
+
+	res := runner.Import(ctx)
+	Equals(t, "queued behind pull #1", res.Failure)
+	mockImport.VerifyWasCalled(Never()).Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())
+}
+
+func TestDefaultProjectCommandRunner_State(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	mockInit := mocks.NewMockStepRunner()
+	mockState := mocks.NewMockStepRunner()
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	runner := &events.DefaultProjectCommandRunner{
+		Locker:           acquiringLocker(),
+		InitStepRunner:   mockInit,
+		StateStepRunner:  mockState,
+		WorkingDir:       mockWorkingDir,
+		WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
+	}
+	ctx := models.ProjectCommandContext{
+		Log:             logging.NewNoopLogger(),
+		Workspace:       "default",
+		RepoRelDir:      ".",
+		BaseRepo:        models.Repo{FullName: "owner/repo"},
+		Pull:            models.PullRequest{Num: 1},
+		RePlanCmd:       "atlantis plan -d .",
+		StateSubcommand: "mv",
+		StateAddresses:  []string{"aws_instance.web", "aws_instance.app"},
+	}
+	When(mockWorkingDir.Clone(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString(),
+	)).ThenReturn(tmp, nil)
+	When(mockInit.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())).ThenReturn("init", nil)
+	When(mockState.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())).ThenReturn("Successfully moved 1 object(s).", nil)
+
+	res := runner.State(ctx)
+	Ok(t, res.Error)
+	Equals(t, &events.StateSuccess{TerraformOutput: "init\nSuccessfully moved 1 object(s).", RePlanCmd: "atlantis plan -d ."}, res.StateSuccess)
+	mockInit.VerifyWasCalledOnce().Run(ctx, nil, tmp)
+	mockState.VerifyWasCalledOnce().Run(ctx, nil, tmp)
+
+	t.Log("when the state command fails we should get its output in the error")
+	When(mockState.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())).ThenReturn("Error: Invalid target address", errors.New("exit status 1"))
+	res = runner.State(ctx)
+	ErrEquals(t, "exit status 1\ninit\nError: Invalid target address", res.Error)
+	Assert(t, res.StateSuccess == nil, "exp no state success")
+}
+
+// acquiringLocker returns a project locker that always acquires the lock.
+// Test that each plan is recorded to the audit log, whether it succeeds or
+// not.
+func TestDefaultProjectCommandRunner_PlanAudit(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	mockPlan := mocks.NewMockStepRunner()
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockRecorder := mocks.NewMockAuditRecorder()
+	runner := &events.DefaultProjectCommandRunner{
+		Locker:           acquiringLocker(),
+		LockURLGenerator: mockURLGenerator{},
+		PlanStepRunner:   mockPlan,
+		WorkingDir:       mockWorkingDir,
+		WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
+		AuditRecorder:    mockRecorder,
+	}
+	workflow := "custom"
+	ctx := models.ProjectCommandContext{
+		Log:           logging.NewNoopLogger(),
+		Workspace:     "default",
+		RepoRelDir:    ".",
+		BaseRepo:      models.Repo{FullName: "owner/repo"},
+		Pull:          models.PullRequest{Num: 1, HeadCommit: "abc123"},
+		User:          models.User{Username: "lkysow"},
+		CommentArgs:   []string{`"-var-file=staging.tfvars"`, `"-var"`, `"tags={\"a\"=1}"`},
+		ProjectConfig: &valid.Project{Dir: ".", Workflow: &workflow},
+		GlobalConfig: &valid.Config{
+			Workflows: map[string]valid.Workflow{
+				workflow: {Plan: &valid.Stage{Steps: []valid.Step{{StepName: "plan"}}}},
+			},
+		},
+	}
+	When(mockWorkingDir.Clone(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString(),
+	)).ThenReturn(tmp, nil)
+	When(mockPlan.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())).ThenReturn("plan", nil)
+
+	runner.Plan(ctx)
+	event := mockRecorder.VerifyWasCalledOnce().Record(matchers.AnyModelsAuditEvent()).GetCapturedArguments()
+	Equals(t, "plan", event.Command)
+	Equals(t, "lkysow", event.User)
+	Equals(t, "owner/repo", event.RepoFullName)
+	Equals(t, 1, event.PullNum)
+	Equals(t, "abc123", event.HeadCommit)
+	Equals(t, ".", event.RepoRelDir)
+	Equals(t, "default", event.Workspace)
+	Equals(t, []string{"-var-file=staging.tfvars", "-var", `tags={"a"=1}`}, event.ExtraArgs)
+	Equals(t, true, event.Success)
+	Equals(t, "", event.Error)
+	Assert(t, !event.StartedAt.IsZero(), "exp StartedAt to be set")
+
+	t.Log("failed plans should be recorded with their error")
+	mockRecorder = mocks.NewMockAuditRecorder()
+	runner.AuditRecorder = mockRecorder
+	When(mockPlan.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())).ThenReturn("", errors.New("plan failed\noutput"))
+	runner.Plan(ctx)
+	event = mockRecorder.VerifyWasCalledOnce().Record(matchers.AnyModelsAuditEvent()).GetCapturedArguments()
+	Equals(t, false, event.Success)
+	Equals(t, "plan failed", event.Error)
+}
+
+// Test that the output of successful plans is saved and linked to.
+func TestDefaultProjectCommandRunner_PlanSavesOutput(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	mockPlan := mocks.NewMockStepRunner()
+	mockWorkingDir := mocks.NewMockWorkingDir()
+	mockStore := planmocks.NewMockStore()
+	mockURLs := mocks.NewMockPlanURLGenerator()
+	runner := &events.DefaultProjectCommandRunner{
+		Locker:           acquiringLocker(),
+		LockURLGenerator: mockURLGenerator{},
+		PlanStepRunner:   mockPlan,
+		WorkingDir:       mockWorkingDir,
+		WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
+		PlanStore:        mockStore,
+		PlanURLGenerator: mockURLs,
+	}
+	workflow := "custom"
+	ctx := models.ProjectCommandContext{
+		Log:           logging.NewNoopLogger(),
+		Workspace:     "default",
+		RepoRelDir:    ".",
+		BaseRepo:      models.Repo{FullName: "owner/repo"},
+		Pull:          models.PullRequest{Num: 1, HeadCommit: "abc123", URL: "https://github.com/owner/repo/pull/1"},
+		User:          models.User{Username: "lkysow"},
+		ProjectConfig: &valid.Project{Dir: ".", Workflow: &workflow},
+		GlobalConfig: &valid.Config{
+			Workflows: map[string]valid.Workflow{
+				workflow: {Plan: &valid.Stage{Steps: []valid.Step{{StepName: "plan"}}}},
+			},
+		},
+	}
+	When(mockWorkingDir.Clone(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsRepo(),
+		matchers.AnyModelsPullRequest(),
+		AnyString(),
+	)).ThenReturn(tmp, nil)
+	When(mockPlan.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())).ThenReturn("plan output", nil)
+	id := plans.ID("owner/repo", 1, "abc123", ".", "default")
+	When(mockURLs.GeneratePlanURL(id)).ThenReturn("https://atlantis/plans/" + id)
+
+	res := runner.Plan(ctx)
+	Assert(t, res.PlanSuccess != nil, "exp plan success")
+	Equals(t, "https://atlantis/plans/"+id, res.PlanSuccess.PlanURL)
+	plan := mockStore.VerifyWasCalledOnce().SavePlanOutput(planmatchers.AnyModelsPlanOutput()).GetCapturedArguments()
+	Equals(t, id, plan.ID)
+	Equals(t, "plan output", plan.Output)
+	Equals(t, "abc123", plan.HeadCommit)
+	Equals(t, "lkysow", plan.User)
+
+	t.Log("if the output can't be saved there should be no link to it")
+	When(mockStore.SavePlanOutput(planmatchers.AnyModelsPlanOutput())).ThenReturn(errors.New("err"))
+	res = runner.Plan(ctx)
+	Assert(t, res.PlanSuccess != nil, "exp plan success")
+	Equals(t, "", res.PlanSuccess.PlanURL)
+
+	t.Log("failed plans shouldn't be saved")
+	mockStore = planmocks.NewMockStore()
+	runner.PlanStore = mockStore
+	When(mockPlan.Run(matchers.AnyModelsProjectCommandContext(), AnyStringSlice(), AnyString())).ThenReturn("", errors.New("plan failed"))
+	runner.Plan(ctx)
+	mockStore.VerifyWasCalled(Never()).SavePlanOutput(planmatchers.AnyModelsPlanOutput())
+}
+
+func acquiringLocker() *mocks.MockProjectLocker {
+	locker := mocks.NewMockProjectLocker()
+	When(locker.TryLock(
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		matchers.AnyModelsPullRequest(),
+		matchers.AnyModelsUser(),
+		AnyString(),
+		matchers.AnyModelsProject(),
+	)).ThenReturn(&events.TryLockResponse{LockAcquired: true, LockKey: "lock-key", UnlockFn: func() error { return nil }}, nil)
+	return locker
+}
@@ -14,9 +14,11 @@
 package events
 
 import (
+	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/docker/docker/pkg/fileutils"
@@ -34,31 +36,75 @@ type ProjectFinder interface {
 	DetermineProjectsViaConfig(log *logging.SimpleLogger, modifiedFiles []string, config valid.Config, repoDir string) ([]valid.Project, error)
 }
 
+// DefaultAutoplanFileList is the default list of file patterns that, when
+// modified, cause their projects to be planned in repos without an
+// atlantis.yaml file.
+const DefaultAutoplanFileList = "**/*.tf,**/*.tf.json,**/*.tfvars,**/*.tfvars.json"
+
 // DefaultProjectFinder implements ProjectFinder.
-type DefaultProjectFinder struct{}
+type DefaultProjectFinder struct {
+	// EnableTerragrunt is true if modified terragrunt.hcl files should also be
+	// mapped to projects.
+	EnableTerragrunt bool
+	// AutoplanFileList is a comma separated list of patterns, relative to the
+	// repo root, of the modified files to map to projects. Patterns starting
+	// with ! exclude files matched by earlier patterns. If empty,
+	// DefaultAutoplanFileList is used.
+	AutoplanFileList string
+	// AutodiscoverInclude are patterns, relative to the repo root, of the
+	// directories projects are discovered in. If empty, projects are
+	// discovered in every directory.
+	AutodiscoverInclude []string
+	// AutodiscoverExclude are patterns of the directories projects are never
+	// discovered in, even if they match AutodiscoverInclude.
+	AutodiscoverExclude []string
+}
 
 var excludeList = []string{"terraform.tfstate", "terraform.tfstate.backup"}
 
+// terragruntConfigFilename is the name of terragrunt's config file.
+const terragruntConfigFilename = "terragrunt.hcl"
+
+// terraformBlockRegex matches the terraform block of a terragrunt.hcl file
+// which is where it sets the module to run.
+var terraformBlockRegex = regexp.MustCompile(`(?m)^\s*terraform\s*\{`)
+
 // DetermineProjects returns the list of projects that were modified based on
 // the modifiedFiles. The list will be de-duplicated.
 func (p *DefaultProjectFinder) DetermineProjects(log *logging.SimpleLogger, modifiedFiles []string, repoFullName string, repoDir string) []models.Project {
 	var projects []models.Project
 
-	modifiedTerraformFiles := p.filterToTerraform(modifiedFiles)
+	modifiedTerraformFiles := p.filterToTerraform(log, modifiedFiles)
 	if len(modifiedTerraformFiles) == 0 {
 		return projects
 	}
-	log.Info("filtered modified files to %d .tf files: %v",
+	log.Info("filtered modified files to %d files matching the autoplan file list: %v",
 		len(modifiedTerraformFiles), modifiedTerraformFiles)
 
 	var dirs []string
+	var modifiedDirs []string
 	for _, modifiedFile := range modifiedTerraformFiles {
 		projectDir := p.getProjectDir(modifiedFile, repoDir)
 		if projectDir != "" {
 			dirs = append(dirs, projectDir)
 		}
+		modifiedDirs = append(modifiedDirs, path.Dir(modifiedFile))
+	}
+
+	// If a module that's called with a local path was modified, ex. a shared
+	// modules/ dir, the projects that call it are modified too.
+	graph, err := BuildModuleGraph(repoDir)
+	if err != nil {
+		log.Warn("unable to determine which projects call the modified modules: %s", err)
+	} else if dependents := graph.DependentProjects(p.unique(modifiedDirs)); len(dependents) > 0 {
+		log.Info("modified modules are called by project(s) at path(s): %v", strings.Join(dependents, ", "))
+		dirs = append(dirs, dependents...)
+	}
+	uniqueDirs, err := p.filterToDiscoverable(log, p.unique(dirs))
+	if err != nil {
+		log.Warn("not discovering any projects: %s", err)
+		return projects
 	}
-	uniqueDirs := p.unique(dirs)
 
 	// The list of modified files will include files that were deleted. We still
 	// want to run plan if a file was deleted since that often results in a
@@ -117,16 +163,94 @@ func (p *DefaultProjectFinder) DetermineProjectsViaConfig(log *logging.SimpleLog
 	return projects, nil
 }
 
-func (p *DefaultProjectFinder) filterToTerraform(files []string) []string {
+// filterToTerraform returns the files that match the autoplan file list, or
+// are terragrunt configs.
+func (p *DefaultProjectFinder) filterToTerraform(log *logging.SimpleLogger, files []string) []string {
+	fileList := p.AutoplanFileList
+	if fileList == "" {
+		fileList = DefaultAutoplanFileList
+	}
+	pm, err := fileutils.NewPatternMatcher(strings.Split(fileList, ","))
+	if err != nil {
+		log.Err("invalid autoplan file list %q: %s", fileList, err)
+		return nil
+	}
+
 	var filtered []string
 	for _, fileName := range files {
-		if !p.isInExcludeList(fileName) && strings.Contains(fileName, ".tf") {
+		if p.isInExcludeList(fileName) {
+			continue
+		}
+		match, err := pm.Matches(fileName)
+		if err != nil {
+			log.Debug("match err for file %q: %s", fileName, err)
+			continue
+		}
+		if match || p.isTerragruntConfig(fileName) {
 			filtered = append(filtered, fileName)
 		}
 	}
 	return filtered
 }
 
+func (p *DefaultProjectFinder) isTerragruntConfig(fileName string) bool {
+	return p.EnableTerragrunt && path.Base(fileName) == terragruntConfigFilename
+}
+
+// isTerragruntProject returns true if the terragrunt.hcl file in dir is for a
+// project rather than only being included by the configs of other projects,
+// ex. a root terragrunt.hcl that configures remote state for all of them. We
+// treat it as a project if it sets the module to run in a terraform block or
+// there are Terraform files next to it.
+func (p *DefaultProjectFinder) isTerragruntProject(dir string) bool {
+	contents, err := ioutil.ReadFile(filepath.Join(dir, terragruntConfigFilename))
+	if err != nil {
+		return false
+	}
+	if terraformBlockRegex.Match(contents) {
+		return true
+	}
+	tfFiles, _ := filepath.Glob(filepath.Join(dir, "*.tf"))
+	return len(tfFiles) > 0
+}
+
+// filterToDiscoverable returns the dirs that match AutodiscoverInclude and
+// don't match AutodiscoverExclude.
+func (p *DefaultProjectFinder) filterToDiscoverable(log *logging.SimpleLogger, dirs []string) ([]string, error) {
+	if len(p.AutodiscoverInclude) == 0 && len(p.AutodiscoverExclude) == 0 {
+		return dirs, nil
+	}
+	var include, exclude *fileutils.PatternMatcher
+	var err error
+	if len(p.AutodiscoverInclude) > 0 {
+		if include, err = fileutils.NewPatternMatcher(p.AutodiscoverInclude); err != nil {
+			return nil, errors.Wrapf(err, "parsing autodiscover include patterns %v", p.AutodiscoverInclude)
+		}
+	}
+	if len(p.AutodiscoverExclude) > 0 {
+		if exclude, err = fileutils.NewPatternMatcher(p.AutodiscoverExclude); err != nil {
+			return nil, errors.Wrapf(err, "parsing autodiscover exclude patterns %v", p.AutodiscoverExclude)
+		}
+	}
+	var discoverable []string
+	for _, dir := range dirs {
+		if include != nil {
+			if ok, err := include.Matches(dir); err != nil || !ok {
+				log.Debug("not discovering project at dir %q since it isn't in the autodiscover include patterns", dir)
+				continue
+			}
+		}
+		if exclude != nil {
+			if ok, err := exclude.Matches(dir); err != nil || ok {
+				log.Debug("not discovering project at dir %q since it's in the autodiscover exclude patterns", dir)
+				continue
+			}
+		}
+		discoverable = append(discoverable, dir)
+	}
+	return discoverable, nil
+}
+
 func (p *DefaultProjectFinder) isInExcludeList(fileName string) bool {
 	for _, s := range excludeList {
 		if strings.Contains(fileName, s) {
@@ -140,9 +264,16 @@ func (p *DefaultProjectFinder) isInExcludeList(fileName string) bool {
 // file, where the root of the Terraform project is. It also attempts to verify
 // if the root is valid by looking for a main.tf file. It returns a relative
 // path to the repo. If the project is at the root returns ".". If modified file
-// doesn't lead to a valid project path, returns an empty string.
+// doesn't lead to a valid project path, returns an empty string. A modified
+// terragrunt.hcl file leads to its own dir if that's a terragrunt project.
 func (p *DefaultProjectFinder) getProjectDir(modifiedFilePath string, repoDir string) string {
 	dir := path.Dir(modifiedFilePath)
+	if p.isTerragruntConfig(modifiedFilePath) {
+		if !p.isTerragruntProject(filepath.Join(repoDir, dir)) {
+			return ""
+		}
+		return dir
+	}
 	if path.Base(dir) == "env" {
 		// If the modified file was inside an env/ directory, we treat this
 		// specially and run plan one level up. This supports directory structures
@@ -179,7 +310,8 @@ func (p *DefaultProjectFinder) getProjectDir(modifiedFilePath string, repoDir st
 		// project was using this module so we can't suggest a project root, but we
 		// also detect that there's no main.tf in the parent folder of modules/
 		// so we won't suggest that as a project. So in this case we return nothing.
-		// The code below makes this happen.
+		// The code below makes this happen. The projects that call the module
+		// are found by DetermineProjects using the repo's ModuleGraph.
 
 		// Need to add a trailing slash before splitting on modules/ because if
 		// the input was modules/file.tf then path.Dir will be "modules" and so our
@@ -153,6 +153,18 @@ func TestDetermineProjects(t *testing.T) {
 			[]string{"project1"},
 			topLevelModules,
 		},
+		{
+			"Should ignore files that only have .tf in their names",
+			[]string{"docs/using.tf.md", ".tflint.hcl", "project1/main.tf.bak"},
+			nil,
+			nestedModules1,
+		},
+		{
+			"Should plan in the dir of modified .tf.json and .tfvars.json files",
+			[]string{"project1/main.tf.json", "project1/prod.tfvars.json"},
+			[]string{"project1"},
+			nestedModules1,
+		},
 		{
 			"Should ignore tfstate files and return an empty list",
 			[]string{"terraform.tfstate", "terraform.tfstate.backup", "parent/terraform.tfstate", "parent/terraform.tfstate.backup"},
@@ -221,6 +233,95 @@ func TestDetermineProjects(t *testing.T) {
 	}
 }
 
+// With terragrunt enabled, modified terragrunt.hcl files should map to their
+// dirs unless they're only included by other configs.
+func TestDetermineProjects_Terragrunt(t *testing.T) {
+	// Create dir structure:
+	// terragrunt.hcl # only remote state config
+	// live/
+	//   terragrunt.hcl # sets terraform.source
+	// local/
+	//   terragrunt.hcl # only inputs
+	//   main.tf
+	tmpDir, cleanup := DirStructure(t, map[string]interface{}{
+		"terragrunt.hcl": nil,
+		"live": map[string]interface{}{
+			"terragrunt.hcl": nil,
+		},
+		"local": map[string]interface{}{
+			"terragrunt.hcl": nil,
+			"main.tf":        nil,
+		},
+	})
+	defer cleanup()
+	Ok(t, ioutil.WriteFile(filepath.Join(tmpDir, "terragrunt.hcl"), []byte("remote_state {\n  backend = \"s3\"\n}\n"), 0600))
+	Ok(t, ioutil.WriteFile(filepath.Join(tmpDir, "live", "terragrunt.hcl"), []byte("include {\n  path = find_in_parent_folders()\n}\n\nterraform {\n  source = \"git::git@github.com:owner/modules.git//app\"\n}\n"), 0600))
+	modifiedFiles := []string{"terragrunt.hcl", "live/terragrunt.hcl", "local/terragrunt.hcl", "deleted/terragrunt.hcl"}
+
+	finder := events.DefaultProjectFinder{EnableTerragrunt: true}
+	var paths []string
+	for _, p := range finder.DetermineProjects(noopLogger, modifiedFiles, modifiedRepo, tmpDir) {
+		paths = append(paths, p.Path)
+	}
+	Equals(t, []string{"live", "local"}, paths)
+
+	// Without terragrunt enabled, terragrunt.hcl files aren't Terraform files.
+	Equals(t, 0, len(m.DetermineProjects(noopLogger, modifiedFiles, modifiedRepo, tmpDir)))
+}
+
+// A custom autoplan file list should replace the default one and support
+// exclusions.
+func TestDetermineProjects_AutoplanFileList(t *testing.T) {
+	setupTmpRepos(t)
+	finder := events.DefaultProjectFinder{AutoplanFileList: "**/*.tf, **/*.pkr.hcl, !project2/**"}
+	var paths []string
+	for _, p := range finder.DetermineProjects(noopLogger, []string{"project1/image.pkr.hcl", "project2/main.tf", "project1/prod.tfvars"}, modifiedRepo, topLevelModules) {
+		paths = append(paths, p.Path)
+	}
+	Equals(t, []string{"project1"}, paths)
+}
+
+// Projects are only discovered in dirs that match the include patterns and
+// not the exclude patterns.
+func TestDetermineProjects_Autodiscover(t *testing.T) {
+	repoDir, cleanup := TempDir(t)
+	defer cleanup()
+	modified := []string{"live/prod/main.tf", "live/legacy/main.tf", "sandbox/main.tf", "main.tf"}
+	for _, f := range modified {
+		Ok(t, os.MkdirAll(filepath.Join(repoDir, filepath.Dir(f)), 0700))
+		Ok(t, ioutil.WriteFile(filepath.Join(repoDir, f), nil, 0600))
+	}
+
+	cases := []struct {
+		include []string
+		exclude []string
+		exp     []string
+	}{
+		{nil, nil, []string{"live/prod", "live/legacy", "sandbox", "."}},
+		{[]string{"live/**"}, nil, []string{"live/prod", "live/legacy"}},
+		{[]string{"live"}, []string{"live/legacy"}, []string{"live/prod"}},
+		{nil, []string{"sandbox", "live/leg*"}, []string{"live/prod", "."}},
+	}
+	for _, c := range cases {
+		finder := events.DefaultProjectFinder{AutodiscoverInclude: c.include, AutodiscoverExclude: c.exclude}
+		var paths []string
+		for _, p := range finder.DetermineProjects(noopLogger, modified, modifiedRepo, repoDir) {
+			paths = append(paths, p.Path)
+		}
+		Equals(t, c.exp, paths)
+	}
+}
+
+func TestDetermineProjects_ModuleGraph(t *testing.T) {
+	repoDir, cleanup := moduleRepo(t)
+	defer cleanup()
+	var paths []string
+	for _, p := range m.DetermineProjects(noopLogger, []string{"modules/vpc/main.tf", "project3/main.tf"}, modifiedRepo, repoDir) {
+		paths = append(paths, p.Path)
+	}
+	Equals(t, []string{"project3", "project1", "project2"}, paths)
+}
+
 func TestDefaultProjectFinder_DetermineProjectsViaConfig(t *testing.T) {
 	// Create dir structure:
 	// main.tf
@@ -38,6 +38,12 @@ type ProjectLocker interface {
 // DefaultProjectLocker implements ProjectLocker.
 type DefaultProjectLocker struct {
 	Locker locking.Locker
+	// LockQueueNotifier is told when a lock is released by UnlockFn so the
+	// pulls waiting for it hear about it. If nil, they aren't told.
+	LockQueueNotifier LockQueueNotifier
+	// ExecutableName is the name that comments start with to run commands.
+	// Defaults to DefaultExecutableName.
+	ExecutableName string
 }
 
 // TryLockResponse is the result of trying to lock a project.
@@ -53,6 +59,9 @@ type TryLockResponse struct {
 	UnlockFn func() error
 	// LockKey is the key for the lock if the lock was acquired.
 	LockKey string
+	// NewLock is true if the lock was acquired by this call rather than
+	// already being held by the pull.
+	NewLock bool
 }
 
 // TryLock implements ProjectLocker.TryLock.
@@ -63,9 +72,16 @@ func (p *DefaultProjectLocker) TryLock(log *logging.SimpleLogger, pull models.Pu
 	}
 	if !lockAttempt.LockAcquired && lockAttempt.CurrLock.Pull.Num != pull.Num {
 		failureMsg := fmt.Sprintf(
-			"This project is currently locked by an unapplied plan from pull #%d. To continue, delete the lock from #%d or apply that plan and merge the pull request.\n\nOnce the lock is released, comment `atlantis plan` here to re-plan.",
+			"This project is currently locked by an unapplied plan from pull #%d. To continue, delete the lock from #%d or apply that plan and merge the pull request.",
 			lockAttempt.CurrLock.Pull.Num,
 			lockAttempt.CurrLock.Pull.Num)
+		if lockAttempt.QueuePosition > 0 {
+			failureMsg += fmt.Sprintf("\n\nThis pull request is queued behind pull #%d and is #%d in line for the lock. Once the lock is released it's given to the first pull request in line. We'll comment here as the line moves.",
+				lockAttempt.CurrLock.Pull.Num,
+				lockAttempt.QueuePosition)
+		} else {
+			failureMsg += fmt.Sprintf("\n\nOnce the lock is released, comment `%s plan` here to re-plan.", executableName(p.ExecutableName))
+		}
 		return &TryLockResponse{
 			LockAcquired:      false,
 			LockFailureReason: failureMsg,
@@ -75,9 +91,17 @@ func (p *DefaultProjectLocker) TryLock(log *logging.SimpleLogger, pull models.Pu
 	return &TryLockResponse{
 		LockAcquired: true,
 		UnlockFn: func() error {
-			_, err := p.Locker.Unlock(lockAttempt.LockKey)
-			return err
+			if _, err := p.Locker.Unlock(lockAttempt.LockKey); err != nil {
+				return err
+			}
+			if p.LockQueueNotifier != nil {
+				if err := p.LockQueueNotifier.Notify(lockAttempt.LockKey); err != nil {
+					log.Warn("unable to notify the pulls waiting for lock %q: %s", lockAttempt.LockKey, err)
+				}
+			}
+			return nil
 		},
 		LockKey: lockAttempt.LockKey,
+		NewLock: lockAttempt.LockAcquired,
 	}, nil
 }
@@ -20,6 +20,7 @@ import (
 	"github.com/runatlantis/atlantis/server/events"
 	"github.com/runatlantis/atlantis/server/events/locking"
 	"github.com/runatlantis/atlantis/server/events/locking/mocks"
+	emocks "github.com/runatlantis/atlantis/server/events/mocks"
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/logging"
 	. "github.com/runatlantis/atlantis/testing"
@@ -56,6 +57,34 @@ func TestDefaultProjectLocker_TryLockWhenLocked(t *testing.T) {
 	}, res)
 }
 
+func TestDefaultProjectLocker_TryLockWhenLockedInQueue(t *testing.T) {
+	mockLocker := mocks.NewMockLocker()
+	locker := events.DefaultProjectLocker{
+		Locker: mockLocker,
+	}
+	expProject := models.Project{}
+	expWorkspace := "default"
+	expPull := models.PullRequest{}
+	expUser := models.User{}
+
+	When(mockLocker.TryLock(expProject, expWorkspace, expPull, expUser)).ThenReturn(
+		locking.TryLockResponse{
+			LockAcquired: false,
+			CurrLock: models.ProjectLock{
+				Pull: models.PullRequest{Num: 2},
+			},
+			QueuePosition: 3,
+		},
+		nil,
+	)
+	res, err := locker.TryLock(logging.NewNoopLogger(), expPull, expUser, expWorkspace, expProject)
+	Ok(t, err)
+	Equals(t, false, res.LockAcquired)
+	Equals(t, "This project is currently locked by an unapplied plan from pull #2. To continue, delete the lock from #2 or apply that plan and merge the pull request.\n\n"+
+		"This pull request is queued behind pull #2 and is #3 in line for the lock. Once the lock is released it's given to the first pull request in line. We'll comment here as the line moves.",
+		res.LockFailureReason)
+}
+
 func TestDefaultProjectLocker_TryLockWhenLockedSamePull(t *testing.T) {
 	RegisterMockTestingT(t)
 	mockLocker := mocks.NewMockLocker()
@@ -84,6 +113,7 @@ func TestDefaultProjectLocker_TryLockWhenLockedSamePull(t *testing.T) {
 	res, err := locker.TryLock(logging.NewNoopLogger(), expPull, expUser, expWorkspace, expProject)
 	Ok(t, err)
 	Equals(t, true, res.LockAcquired)
+	Equals(t, false, res.NewLock)
 
 	// UnlockFn should work.
 	mockLocker.VerifyWasCalled(Never()).Unlock(lockKey)
@@ -95,8 +125,10 @@ func TestDefaultProjectLocker_TryLockWhenLockedSamePull(t *testing.T) {
 func TestDefaultProjectLocker_TryLockUnlocked(t *testing.T) {
 	RegisterMockTestingT(t)
 	mockLocker := mocks.NewMockLocker()
+	mockNotifier := emocks.NewMockLockQueueNotifier()
 	locker := events.DefaultProjectLocker{
-		Locker: mockLocker,
+		Locker:            mockLocker,
+		LockQueueNotifier: mockNotifier,
 	}
 	expProject := models.Project{}
 	expWorkspace := "default"
@@ -120,10 +152,12 @@ func TestDefaultProjectLocker_TryLockUnlocked(t *testing.T) {
 	res, err := locker.TryLock(logging.NewNoopLogger(), expPull, expUser, expWorkspace, expProject)
 	Ok(t, err)
 	Equals(t, true, res.LockAcquired)
+	Equals(t, true, res.NewLock)
 
-	// UnlockFn should work.
+	// UnlockFn should work and tell the pulls waiting for the lock.
 	mockLocker.VerifyWasCalled(Never()).Unlock(lockKey)
 	err = res.UnlockFn()
 	Ok(t, err)
 	mockLocker.VerifyWasCalledOnce().Unlock(lockKey)
+	mockNotifier.VerifyWasCalledOnce().Notify(lockKey)
 }
@@ -26,6 +26,18 @@ type ProjectResult struct {
 	PlanSuccess  *PlanSuccess
 	ApplySuccess string
 	ProjectName  string
+	// TFLog is the redacted terraform log if the command was run with
+	// TF_LOG set.
+	TFLog string
+	// PoliciesApproved is true if approve_policies was run successfully.
+	PoliciesApproved bool
+	// ImportSuccess is set if import was run successfully.
+	ImportSuccess *ImportSuccess
+	// StateSuccess is set if state rm or mv was run successfully.
+	StateSuccess *StateSuccess
+	// JobID is the ID of the job that recorded the command's output. It's
+	// empty if jobs aren't tracked.
+	JobID string
 }
 
 // Status returns the vcs commit status of this project result.
@@ -36,5 +48,8 @@ func (p ProjectResult) Status() models.CommitStatus {
 	if p.Failure != "" {
 		return models.FailedCommitStatus
 	}
+	if p.PlanSuccess != nil && p.PlanSuccess.PolicyCheckFailed {
+		return models.FailedCommitStatus
+	}
 	return models.SuccessCommitStatus
 }
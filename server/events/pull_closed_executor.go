@@ -21,6 +21,8 @@ import (
 	"text/template"
 
 	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/autoplan"
+	"github.com/runatlantis/atlantis/server/events/confirmations"
 	"github.com/runatlantis/atlantis/server/events/locking"
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/events/vcs"
@@ -41,6 +43,20 @@ type PullClosedExecutor struct {
 	Locker     locking.Locker
 	VCSClient  vcs.ClientProxy
 	WorkingDir WorkingDir
+	// LockQueueNotifier tells pulls waiting for the locks that this pull
+	// held that the locks were released.
+	LockQueueNotifier LockQueueNotifier
+	// CommitStatusUpdater resets the pull's commit status so it isn't left
+	// pending on a plan that can no longer be applied. If nil, the status is
+	// left as is.
+	CommitStatusUpdater CommitStatusUpdater
+	// AutoplanStore forgets that autoplanning was turned off for the pull so
+	// it doesn't stay in the database forever. If nil, there's nothing to
+	// forget.
+	AutoplanStore autoplan.Store
+	// ConfirmationStore drops the pull's apply that's waiting to be
+	// confirmed. If nil, there's nothing to drop.
+	ConfirmationStore confirmations.Store
 }
 
 type templatedProject struct {
@@ -53,31 +69,79 @@ var pullClosedTemplate = template.Must(template.New("").Parse(
 		"{{ range . }}\n" +
 		"- dir: `{{ .RepoRelDir }}` {{ .Workspaces }}{{ end }}"))
 
-// CleanUpPull cleans up after a closed pull request.
+// CleanUpPull cleans up after a closed pull request. Every step is run even
+// if an earlier one fails so that, for example, a working dir that can't be
+// deleted doesn't leave the pull's locks held forever.
 func (p *PullClosedExecutor) CleanUpPull(repo models.Repo, pull models.PullRequest) error {
+	var errs []error
+	// Deleting the working dir deletes the plans in it.
 	if err := p.WorkingDir.Delete(repo, pull); err != nil {
-		return errors.Wrap(err, "cleaning workspace")
+		errs = append(errs, errors.Wrap(err, "cleaning workspace"))
+	}
+	if p.AutoplanStore != nil {
+		if err := p.AutoplanStore.SetAutoplanDisabled(repo.FullName, pull.Num, false); err != nil {
+			errs = append(errs, errors.Wrap(err, "cleaning up autoplan setting"))
+		}
+	}
+	if p.ConfirmationStore != nil {
+		if _, err := p.ConfirmationStore.TakePendingApply(repo.FullName, pull.Num); err != nil {
+			errs = append(errs, errors.Wrap(err, "cleaning up pending apply"))
+		}
 	}
 
-	// Finally, delete locks. We do this last because when someone
+	// Then delete locks. We do this after the plans because when someone
 	// unlocks a project, right now we don't actually delete the plan
 	// so we might have plans laying around but no locks.
 	locks, err := p.Locker.UnlockByPull(repo.FullName, pull.Num)
 	if err != nil {
-		return errors.Wrap(err, "cleaning up locks")
+		errs = append(errs, errors.Wrap(err, "cleaning up locks"))
+		return combineErrs(errs)
 	}
 
-	// If there are no locks then there's no need to comment.
+	// If there are no locks then Atlantis never planned this pull so there's
+	// nothing else to clean up.
 	if len(locks) == 0 {
-		return nil
+		return combineErrs(errs)
+	}
+
+	for _, lock := range locks {
+		if err = p.LockQueueNotifier.Notify(locking.GenerateLockKey(lock.Project, lock.Workspace)); err != nil {
+			errs = append(errs, errors.Wrap(err, "notifying pulls waiting for lock"))
+		}
+	}
+
+	if p.CommitStatusUpdater != nil {
+		if err = p.CommitStatusUpdater.UpdatePullClosed(repo, pull); err != nil {
+			errs = append(errs, errors.Wrap(err, "resetting commit status"))
+		}
 	}
 
 	templateData := p.buildTemplateData(locks)
 	var buf bytes.Buffer
 	if err = pullClosedTemplate.Execute(&buf, templateData); err != nil {
-		return errors.Wrap(err, "rendering template for comment")
+		errs = append(errs, errors.Wrap(err, "rendering template for comment"))
+		return combineErrs(errs)
+	}
+	if err = p.VCSClient.CreateComment(repo, pull.Num, buf.String()); err != nil {
+		errs = append(errs, err)
+	}
+	return combineErrs(errs)
+}
+
+// combineErrs returns nil if errs is empty, the error itself if there's only
+// one, and otherwise a single error with all their messages.
+func combineErrs(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	var msgs []string
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
 	}
-	return p.VCSClient.CreateComment(repo, pull.Num, buf.String())
+	return errors.New(strings.Join(msgs, "; "))
 }
 
 // buildTemplateData formats the lock data into a slice that can easily be
@@ -19,6 +19,8 @@ import (
 
 	. "github.com/petergtz/pegomock"
 	"github.com/runatlantis/atlantis/server/events"
+	autoplanmocks "github.com/runatlantis/atlantis/server/events/autoplan/mocks"
+	confirmationmocks "github.com/runatlantis/atlantis/server/events/confirmations/mocks"
 	lockmocks "github.com/runatlantis/atlantis/server/events/locking/mocks"
 	"github.com/runatlantis/atlantis/server/events/mocks"
 	"github.com/runatlantis/atlantis/server/events/mocks/matchers"
@@ -29,16 +31,72 @@ import (
 )
 
 func TestCleanUpPullWorkspaceErr(t *testing.T) {
-	t.Log("when workspace.Delete returns an error, we return it")
+	t.Log("when workspace.Delete returns an error, we still delete the locks and return it")
 	RegisterMockTestingT(t)
 	w := mocks.NewMockWorkingDir()
+	l := lockmocks.NewMockLocker()
 	pce := events.PullClosedExecutor{
+		Locker:     l,
 		WorkingDir: w,
 	}
 	err := errors.New("err")
 	When(w.Delete(fixtures.GithubRepo, fixtures.Pull)).ThenReturn(err)
 	actualErr := pce.CleanUpPull(fixtures.GithubRepo, fixtures.Pull)
 	Equals(t, "cleaning workspace: err", actualErr.Error())
+	l.VerifyWasCalledOnce().UnlockByPull(fixtures.GithubRepo.FullName, fixtures.Pull.Num)
+}
+
+func TestCleanUpPullMultipleErrs(t *testing.T) {
+	t.Log("when several steps fail, we run the rest and return all the errors")
+	RegisterMockTestingT(t)
+	w := mocks.NewMockWorkingDir()
+	l := lockmocks.NewMockLocker()
+	cp := vcsmocks.NewMockClientProxy()
+	notifier := mocks.NewMockLockQueueNotifier()
+	pce := events.PullClosedExecutor{
+		Locker:            l,
+		VCSClient:         cp,
+		WorkingDir:        w,
+		LockQueueNotifier: notifier,
+	}
+	When(w.Delete(fixtures.GithubRepo, fixtures.Pull)).ThenReturn(errors.New("err"))
+	When(l.UnlockByPull(fixtures.GithubRepo.FullName, fixtures.Pull.Num)).ThenReturn([]models.ProjectLock{
+		{
+			Project:   models.NewProject("owner/repo", "path"),
+			Workspace: "default",
+		},
+	}, nil)
+	When(notifier.Notify("owner/repo/path/default")).ThenReturn(errors.New("err"))
+	actualErr := pce.CleanUpPull(fixtures.GithubRepo, fixtures.Pull)
+	ErrEquals(t, "cleaning workspace: err; notifying pulls waiting for lock: err", actualErr)
+	cp.VerifyWasCalledOnce().CreateComment(matchers.AnyModelsRepo(), AnyInt(), AnyString())
+}
+
+func TestCleanUpPullResetsCommitStatus(t *testing.T) {
+	t.Log("when locks were released, we reset the commit status")
+	RegisterMockTestingT(t)
+	l := lockmocks.NewMockLocker()
+	statusUpdater := mocks.NewMockCommitStatusUpdater()
+	pce := events.PullClosedExecutor{
+		Locker:              l,
+		VCSClient:           vcsmocks.NewMockClientProxy(),
+		WorkingDir:          mocks.NewMockWorkingDir(),
+		LockQueueNotifier:   mocks.NewMockLockQueueNotifier(),
+		CommitStatusUpdater: statusUpdater,
+	}
+
+	When(l.UnlockByPull(fixtures.GithubRepo.FullName, fixtures.Pull.Num)).ThenReturn(nil, nil)
+	Ok(t, pce.CleanUpPull(fixtures.GithubRepo, fixtures.Pull))
+	statusUpdater.VerifyWasCalled(Never()).UpdatePullClosed(fixtures.GithubRepo, fixtures.Pull)
+
+	When(l.UnlockByPull(fixtures.GithubRepo.FullName, fixtures.Pull.Num)).ThenReturn([]models.ProjectLock{
+		{
+			Project:   models.NewProject("owner/repo", "path"),
+			Workspace: "default",
+		},
+	}, nil)
+	Ok(t, pce.CleanUpPull(fixtures.GithubRepo, fixtures.Pull))
+	statusUpdater.VerifyWasCalledOnce().UpdatePullClosed(fixtures.GithubRepo, fixtures.Pull)
 }
 
 func TestCleanUpPullUnlockErr(t *testing.T) {
@@ -73,6 +131,37 @@ func TestCleanUpPullNoLocks(t *testing.T) {
 	cp.VerifyWasCalled(Never()).CreateComment(matchers.AnyModelsRepo(), AnyInt(), AnyString())
 }
 
+func TestCleanUpPullForgetsAutoplanSetting(t *testing.T) {
+	t.Log("we turn autoplanning back on so the pull's setting isn't kept forever")
+	RegisterMockTestingT(t)
+	l := lockmocks.NewMockLocker()
+	store := autoplanmocks.NewMockStore()
+	pce := events.PullClosedExecutor{
+		Locker:        l,
+		VCSClient:     vcsmocks.NewMockClientProxy(),
+		WorkingDir:    mocks.NewMockWorkingDir(),
+		AutoplanStore: store,
+	}
+	When(store.SetAutoplanDisabled(fixtures.GithubRepo.FullName, fixtures.Pull.Num, false)).ThenReturn(errors.New("err"))
+	ErrEquals(t, "cleaning up autoplan setting: err", pce.CleanUpPull(fixtures.GithubRepo, fixtures.Pull))
+	store.VerifyWasCalledOnce().SetAutoplanDisabled(fixtures.GithubRepo.FullName, fixtures.Pull.Num, false)
+	l.VerifyWasCalledOnce().UnlockByPull(fixtures.GithubRepo.FullName, fixtures.Pull.Num)
+}
+
+func TestCleanUpPullDropsPendingApply(t *testing.T) {
+	t.Log("we drop the pull's apply that's waiting to be confirmed")
+	RegisterMockTestingT(t)
+	store := confirmationmocks.NewMockStore()
+	pce := events.PullClosedExecutor{
+		Locker:            lockmocks.NewMockLocker(),
+		VCSClient:         vcsmocks.NewMockClientProxy(),
+		WorkingDir:        mocks.NewMockWorkingDir(),
+		ConfirmationStore: store,
+	}
+	Ok(t, pce.CleanUpPull(fixtures.GithubRepo, fixtures.Pull))
+	store.VerifyWasCalledOnce().TakePendingApply(fixtures.GithubRepo.FullName, fixtures.Pull.Num)
+}
+
 func TestCleanUpPullComments(t *testing.T) {
 	t.Log("should comment correctly")
 	RegisterMockTestingT(t)
@@ -143,9 +232,10 @@ func TestCleanUpPullComments(t *testing.T) {
 		cp := vcsmocks.NewMockClientProxy()
 		l := lockmocks.NewMockLocker()
 		pce := events.PullClosedExecutor{
-			Locker:     l,
-			VCSClient:  cp,
-			WorkingDir: w,
+			Locker:            l,
+			VCSClient:         cp,
+			WorkingDir:        w,
+			LockQueueNotifier: mocks.NewMockLockQueueNotifier(),
 		}
 		t.Log("testing: " + c.Description)
 		When(l.UnlockByPull(fixtures.GithubRepo.FullName, fixtures.Pull.Num)).ThenReturn(c.Locks, nil)
@@ -157,3 +247,31 @@ func TestCleanUpPullComments(t *testing.T) {
 		Equals(t, expected, comment)
 	}
 }
+
+func TestCleanUpPullNotifiesLockQueues(t *testing.T) {
+	t.Log("should notify the pulls waiting for each lock that was released")
+	RegisterMockTestingT(t)
+	w := mocks.NewMockWorkingDir()
+	l := lockmocks.NewMockLocker()
+	notifier := mocks.NewMockLockQueueNotifier()
+	pce := events.PullClosedExecutor{
+		Locker:            l,
+		VCSClient:         vcsmocks.NewMockClientProxy(),
+		WorkingDir:        w,
+		LockQueueNotifier: notifier,
+	}
+	When(l.UnlockByPull(fixtures.GithubRepo.FullName, fixtures.Pull.Num)).ThenReturn([]models.ProjectLock{
+		{
+			Project:   models.NewProject("owner/repo", "path"),
+			Workspace: "default",
+		},
+		{
+			Project:   models.NewProject("owner/repo", "path2"),
+			Workspace: "staging",
+		},
+	}, nil)
+	err := pce.CleanUpPull(fixtures.GithubRepo, fixtures.Pull)
+	Ok(t, err)
+	notifier.VerifyWasCalledOnce().Notify("owner/repo/path/default")
+	notifier.VerifyWasCalledOnce().Notify("owner/repo/path2/staging")
+}
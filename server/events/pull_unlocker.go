@@ -0,0 +1,53 @@
+package events
+
+import (
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/locking"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_pull_unlocker.go PullUnlocker
+
+// PullUnlocker discards the plans of an open pull request and releases its
+// locks for atlantis unlock.
+type PullUnlocker interface {
+	// UnlockPull deletes the pull request's plans and workspaces on disk and
+	// deletes all of its locks. It returns the locks it deleted.
+	UnlockPull(repo models.Repo, pull models.PullRequest) ([]models.ProjectLock, error)
+}
+
+// DefaultPullUnlocker implements PullUnlocker.
+type DefaultPullUnlocker struct {
+	Locker     locking.Locker
+	WorkingDir WorkingDir
+	// WorkingDirLocker stops us from deleting the workspaces while a command
+	// is running in them.
+	WorkingDirLocker WorkingDirLocker
+	// LockQueueNotifier tells pulls waiting for the locks that this pull
+	// held that the locks were released.
+	LockQueueNotifier LockQueueNotifier
+}
+
+func (d *DefaultPullUnlocker) UnlockPull(repo models.Repo, pull models.PullRequest) ([]models.ProjectLock, error) {
+	unlockDir, err := d.WorkingDirLocker.TryLockPull(repo.FullName, pull.Num)
+	if err != nil {
+		return nil, err
+	}
+	defer unlockDir()
+	if err := d.WorkingDir.Delete(repo, pull); err != nil {
+		return nil, errors.Wrap(err, "deleting plans")
+	}
+
+	// Delete the locks after the plans so a plan can't be applied once
+	// its lock has been given to another pull.
+	locks, err := d.Locker.UnlockByPull(repo.FullName, pull.Num)
+	if err != nil {
+		return nil, errors.Wrap(err, "deleting locks")
+	}
+	for _, lock := range locks {
+		if err := d.LockQueueNotifier.Notify(locking.GenerateLockKey(lock.Project, lock.Workspace)); err != nil {
+			return locks, errors.Wrap(err, "notifying pulls waiting for lock")
+		}
+	}
+	return locks, nil
+}
@@ -0,0 +1,86 @@
+package events_test
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	lockmocks "github.com/runatlantis/atlantis/server/events/locking/mocks"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/models/fixtures"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestUnlockPull(t *testing.T) {
+	t.Log("should delete the plans and locks and notify the pulls waiting for the locks")
+	RegisterMockTestingT(t)
+	w := mocks.NewMockWorkingDir()
+	l := lockmocks.NewMockLocker()
+	notifier := mocks.NewMockLockQueueNotifier()
+	unlocker := events.DefaultPullUnlocker{
+		Locker:            l,
+		WorkingDir:        w,
+		WorkingDirLocker:  events.NewDefaultWorkingDirLocker(),
+		LockQueueNotifier: notifier,
+	}
+	locks := []models.ProjectLock{
+		{
+			Project:   models.NewProject("owner/repo", "path"),
+			Workspace: "default",
+		},
+		{
+			Project:   models.NewProject("owner/repo", "path2"),
+			Workspace: "staging",
+		},
+	}
+	When(l.UnlockByPull(fixtures.GithubRepo.FullName, fixtures.Pull.Num)).ThenReturn(locks, nil)
+
+	unlocked, err := unlocker.UnlockPull(fixtures.GithubRepo, fixtures.Pull)
+	Ok(t, err)
+	Equals(t, locks, unlocked)
+	w.VerifyWasCalledOnce().Delete(fixtures.GithubRepo, fixtures.Pull)
+	notifier.VerifyWasCalledOnce().Notify("owner/repo/path/default")
+	notifier.VerifyWasCalledOnce().Notify("owner/repo/path2/staging")
+}
+
+func TestUnlockPull_CommandRunning(t *testing.T) {
+	t.Log("should not delete anything while a command is running for the pull")
+	RegisterMockTestingT(t)
+	w := mocks.NewMockWorkingDir()
+	l := lockmocks.NewMockLocker()
+	dirLocker := events.NewDefaultWorkingDirLocker()
+	unlocker := events.DefaultPullUnlocker{
+		Locker:            l,
+		WorkingDir:        w,
+		WorkingDirLocker:  dirLocker,
+		LockQueueNotifier: mocks.NewMockLockQueueNotifier(),
+	}
+	unlockDir, err := dirLocker.TryLock(fixtures.GithubRepo.FullName, fixtures.Pull.Num, "default")
+	Ok(t, err)
+	defer unlockDir()
+
+	_, err = unlocker.UnlockPull(fixtures.GithubRepo, fixtures.Pull)
+	ErrContains(t, "currently locked by another command", err)
+	w.VerifyWasCalled(Never()).Delete(fixtures.GithubRepo, fixtures.Pull)
+	l.VerifyWasCalled(Never()).UnlockByPull(fixtures.GithubRepo.FullName, fixtures.Pull.Num)
+}
+
+func TestUnlockPull_DeleteErr(t *testing.T) {
+	t.Log("should not delete the locks if the plans couldn't be deleted")
+	RegisterMockTestingT(t)
+	w := mocks.NewMockWorkingDir()
+	l := lockmocks.NewMockLocker()
+	unlocker := events.DefaultPullUnlocker{
+		Locker:            l,
+		WorkingDir:        w,
+		WorkingDirLocker:  events.NewDefaultWorkingDirLocker(),
+		LockQueueNotifier: mocks.NewMockLockQueueNotifier(),
+	}
+	When(w.Delete(fixtures.GithubRepo, fixtures.Pull)).ThenReturn(errors.New("err"))
+
+	_, err := unlocker.UnlockPull(fixtures.GithubRepo, fixtures.Pull)
+	ErrEquals(t, "deleting plans: err", err)
+	l.VerifyWasCalled(Never()).UnlockByPull(fixtures.GithubRepo.FullName, fixtures.Pull.Num)
+}
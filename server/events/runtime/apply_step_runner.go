@@ -1,24 +1,30 @@
 package runtime
 
 import (
-	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 
-	"github.com/hashicorp/go-version"
+	"github.com/pkg/errors"
 	"github.com/runatlantis/atlantis/server/events/models"
 )
 
 // ApplyStepRunner runs `terraform apply`.
 type ApplyStepRunner struct {
 	TerraformExecutor TerraformExec
+	// RemoteRuns confirms the runs of projects that use remote operations. If
+	// nil, their plans can't be applied.
+	RemoteRuns RemoteRunClient
 }
 
 func (a *ApplyStepRunner) Run(ctx models.ProjectCommandContext, extraArgs []string, path string) (string, error) {
 	if a.hasTargetFlag(ctx, extraArgs) {
-		return "", errors.New("cannot run apply with -target because we are applying an already generated plan. Instead, run -target with atlantis plan")
+		return "", errors.New("cannot run apply with -target because we are applying an already generated plan. Instead, run plan with -target")
+	}
+	if err := validateCommentArgs(ctx); err != nil {
+		return "", err
 	}
 
 	planPath := filepath.Join(path, GetPlanFilename(ctx.Workspace, ctx.ProjectConfig))
@@ -27,14 +33,22 @@ func (a *ApplyStepRunner) Run(ctx models.ProjectCommandContext, extraArgs []stri
 		return "", fmt.Errorf("no plan found at path %q and workspace %q–did you run plan?", ctx.RepoRelDir, ctx.Workspace)
 	}
 
-	// NOTE: we need to quote the plan path because Bitbucket Server can
-	// have spaces in its repo owner names which is part of the path.
-	tfApplyCmd := append(append(append([]string{"apply", "-input=false", "-no-color"}, extraArgs...), ctx.CommentArgs...), fmt.Sprintf("%q", planPath))
-	var tfVersion *version.Version
-	if ctx.ProjectConfig != nil && ctx.ProjectConfig.TerraformVersion != nil {
-		tfVersion = ctx.ProjectConfig.TerraformVersion
+	// A nil version means the default version.
+	tfVersion := GetTerraformVersion(ctx, nil)
+	planContents, err := ioutil.ReadFile(planPath) // nolint: gosec
+	if err != nil {
+		return "", errors.Wrap(err, "reading plan")
+	}
+	var out string
+	var tfErr error
+	if isRemotePlan(planContents) {
+		out, tfErr = a.runRemoteApply(ctx, extraArgs, path, planContents, tfVersion)
+	} else {
+		// NOTE: we need to quote the plan path because Bitbucket Server can
+		// have spaces in its repo owner names which is part of the path.
+		tfApplyCmd := append(append(append([]string{"apply", "-input=false", "-no-color"}, extraArgs...), ctx.CommentArgs...), fmt.Sprintf("%q", planPath))
+		out, tfErr = a.TerraformExecutor.RunCommandWithVersion(ctx.CancelCtx, ctx.Log, path, tfApplyCmd, tfVersion, ctx.Workspace)
 	}
-	out, tfErr := a.TerraformExecutor.RunCommandWithVersion(ctx.Log, path, tfApplyCmd, tfVersion, ctx.Workspace)
 
 	// If the apply was successful, delete the plan.
 	if tfErr == nil {
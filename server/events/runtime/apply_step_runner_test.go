@@ -56,16 +56,16 @@ func TestRun_Success(t *testing.T) {
 		TerraformExecutor: terraform,
 	}
 
-	When(terraform.RunCommandWithVersion(matchers.AnyPtrToLoggingSimpleLogger(), AnyString(), AnyStringSlice(), matchers2.AnyPtrToGoVersionVersion(), AnyString())).
+	When(terraform.RunCommandWithVersion(matchers2.AnyContextContext(), matchers.AnyPtrToLoggingSimpleLogger(), AnyString(), AnyStringSlice(), matchers2.AnyPtrToGoVersionVersion(), AnyString())).
 		ThenReturn("output", nil)
 	output, err := o.Run(models.ProjectCommandContext{
 		Workspace:   "workspace",
 		RepoRelDir:  ".",
-		CommentArgs: []string{"comment", "args"},
+		CommentArgs: []string{"-lock=false", "-parallelism=5"},
 	}, []string{"extra", "args"}, tmpDir)
 	Ok(t, err)
 	Equals(t, "output", output)
-	terraform.VerifyWasCalledOnce().RunCommandWithVersion(nil, tmpDir, []string{"apply", "-input=false", "-no-color", "extra", "args", "comment", "args", fmt.Sprintf("%q", planPath)}, nil, "workspace")
+	terraform.VerifyWasCalledOnce().RunCommandWithVersion(nil, nil, tmpDir, []string{"apply", "-input=false", "-no-color", "extra", "args", "-lock=false", "-parallelism=5", fmt.Sprintf("%q", planPath)}, nil, "workspace")
 	_, err = os.Stat(planPath)
 	Assert(t, os.IsNotExist(err), "planfile should be deleted")
 }
@@ -84,7 +84,7 @@ func TestRun_AppliesCorrectProjectPlan(t *testing.T) {
 		TerraformExecutor: terraform,
 	}
 
-	When(terraform.RunCommandWithVersion(matchers.AnyPtrToLoggingSimpleLogger(), AnyString(), AnyStringSlice(), matchers2.AnyPtrToGoVersionVersion(), AnyString())).
+	When(terraform.RunCommandWithVersion(matchers2.AnyContextContext(), matchers.AnyPtrToLoggingSimpleLogger(), AnyString(), AnyStringSlice(), matchers2.AnyPtrToGoVersionVersion(), AnyString())).
 		ThenReturn("output", nil)
 	projectName := "projectname"
 	output, err := o.Run(models.ProjectCommandContext{
@@ -93,11 +93,11 @@ func TestRun_AppliesCorrectProjectPlan(t *testing.T) {
 		ProjectConfig: &valid.Project{
 			Name: &projectName,
 		},
-		CommentArgs: []string{"comment", "args"},
+		CommentArgs: []string{"-lock=false", "-parallelism=5"},
 	}, []string{"extra", "args"}, tmpDir)
 	Ok(t, err)
 	Equals(t, "output", output)
-	terraform.VerifyWasCalledOnce().RunCommandWithVersion(nil, tmpDir, []string{"apply", "-input=false", "-no-color", "extra", "args", "comment", "args", fmt.Sprintf("%q", planPath)}, nil, "default")
+	terraform.VerifyWasCalledOnce().RunCommandWithVersion(nil, nil, tmpDir, []string{"apply", "-input=false", "-no-color", "extra", "args", "-lock=false", "-parallelism=5", fmt.Sprintf("%q", planPath)}, nil, "default")
 	_, err = os.Stat(planPath)
 	Assert(t, os.IsNotExist(err), "planfile should be deleted")
 }
@@ -116,19 +116,19 @@ func TestRun_UsesConfiguredTFVersion(t *testing.T) {
 	}
 	tfVersion, _ := version.NewVersion("0.11.0")
 
-	When(terraform.RunCommandWithVersion(matchers.AnyPtrToLoggingSimpleLogger(), AnyString(), AnyStringSlice(), matchers2.AnyPtrToGoVersionVersion(), AnyString())).
+	When(terraform.RunCommandWithVersion(matchers2.AnyContextContext(), matchers.AnyPtrToLoggingSimpleLogger(), AnyString(), AnyStringSlice(), matchers2.AnyPtrToGoVersionVersion(), AnyString())).
 		ThenReturn("output", nil)
 	output, err := o.Run(models.ProjectCommandContext{
 		Workspace:   "workspace",
 		RepoRelDir:  ".",
-		CommentArgs: []string{"comment", "args"},
+		CommentArgs: []string{"-lock=false", "-parallelism=5"},
 		ProjectConfig: &valid.Project{
 			TerraformVersion: tfVersion,
 		},
 	}, []string{"extra", "args"}, tmpDir)
 	Ok(t, err)
 	Equals(t, "output", output)
-	terraform.VerifyWasCalledOnce().RunCommandWithVersion(nil, tmpDir, []string{"apply", "-input=false", "-no-color", "extra", "args", "comment", "args", fmt.Sprintf("%q", planPath)}, tfVersion, "workspace")
+	terraform.VerifyWasCalledOnce().RunCommandWithVersion(nil, nil, tmpDir, []string{"apply", "-input=false", "-no-color", "extra", "args", "-lock=false", "-parallelism=5", fmt.Sprintf("%q", planPath)}, tfVersion, "workspace")
 	_, err = os.Stat(planPath)
 	Assert(t, os.IsNotExist(err), "planfile should be deleted")
 }
@@ -164,7 +164,7 @@ func TestRun_UsingTarget(t *testing.T) {
 		},
 		// Test false positives.
 		{
-			commentFlags: []string{"-targethahagotcha"},
+			commentFlags: []string{"-var-file=targethahagotcha"},
 			expErr:       false,
 		},
 		{
@@ -172,7 +172,7 @@ func TestRun_UsingTarget(t *testing.T) {
 			expErr:    false,
 		},
 		{
-			commentFlags: []string{"-targeted=weird"},
+			commentFlags: []string{"-var=-target=weird"},
 			expErr:       false,
 		},
 		{
@@ -204,7 +204,7 @@ func TestRun_UsingTarget(t *testing.T) {
 			}, c.extraArgs, tmpDir)
 			Equals(t, "", output)
 			if c.expErr {
-				ErrEquals(t, "cannot run apply with -target because we are applying an already generated plan. Instead, run -target with atlantis plan", err)
+				ErrEquals(t, "cannot run apply with -target because we are applying an already generated plan. Instead, run plan with -target", err)
 			} else {
 				Ok(t, err)
 			}
@@ -0,0 +1,149 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/hashicorp/go-version"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/terraform"
+)
+
+// DefaultInfracostCommand is the infracost binary we run if none is set. It's
+// looked up in $PATH.
+const DefaultInfracostCommand = "infracost"
+
+// CostEstimateStepRunner estimates how the plan changes the project's monthly
+// cost with Infracost. See https://www.infracost.io.
+type CostEstimateStepRunner struct {
+	TerraformExecutor TerraformExec
+	DefaultTFVersion  *version.Version
+	// InfracostCommand is the infracost binary to run. If empty,
+	// DefaultInfracostCommand is used. It reads its API key from the
+	// INFRACOST_API_KEY environment variable.
+	InfracostCommand string
+}
+
+// CostEstimate is how a plan changes the monthly cost of a project.
+type CostEstimate struct {
+	Currency        string
+	PastMonthlyCost float64
+	MonthlyCost     float64
+	DiffMonthlyCost float64
+	ResourceDiffs   []ResourceCostDiff
+}
+
+// ResourceCostDiff is how a plan changes the monthly cost of a resource.
+type ResourceCostDiff struct {
+	// Address is the resource's address, ex. aws_instance.web.
+	Address         string
+	DiffMonthlyCost float64
+}
+
+// Run converts the plan to JSON with `terraform show -json` and returns the
+// output of `infracost breakdown --format json` for it. extraArgs are
+// appended to the infracost command.
+func (c *CostEstimateStepRunner) Run(ctx models.ProjectCommandContext, extraArgs []string, path string) (string, error) {
+	planPath := filepath.Join(path, GetPlanFilename(ctx.Workspace, ctx.ProjectConfig))
+	planContents, err := ioutil.ReadFile(planPath) // nolint: gosec
+	if err != nil {
+		return "", fmt.Errorf("no plan found at path %q and workspace %q–did you run plan?", ctx.RepoRelDir, ctx.Workspace)
+	}
+	if isRemotePlan(planContents) {
+		return "", errors.New("costs can't be estimated for plans made with remote operations; use Terraform Cloud's cost estimation instead")
+	}
+
+	tfVersion := GetTerraformVersion(ctx, c.DefaultTFVersion)
+	planJSON, err := c.TerraformExecutor.RunCommandWithVersion(ctx.CancelCtx, ctx.Log, path, []string{"show", "-json", fmt.Sprintf("%q", planPath)}, tfVersion, ctx.Workspace)
+	if err != nil {
+		return "", errors.Wrap(err, "converting plan to json (cost estimates need Terraform 0.12 or later)")
+	}
+	planJSONPath := filepath.Join(path, GetPlanJSONFilename(ctx.Workspace, ctx.ProjectConfig))
+	if err := ioutil.WriteFile(planJSONPath, []byte(planJSON), 0600); err != nil {
+		return "", errors.Wrap(err, "writing plan json")
+	}
+
+	infracost := c.InfracostCommand
+	if infracost == "" {
+		infracost = DefaultInfracostCommand
+	}
+	args := append([]string{"breakdown", "--no-color", "--format", "json", "--path", planJSONPath}, extraArgs...)
+	cmd := exec.Command(infracost, args...) // #nosec
+	cmd.Dir = path
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := terraform.RunCancellable(ctx.CancelCtx, cmd); err != nil {
+		return "", fmt.Errorf("%s: running infracost: %s", err, stderr.String())
+	}
+	return stdout.String(), nil
+}
+
+// ParseInfracostJSON returns the cost estimate in breakdownJSON, the output of
+// `infracost breakdown --format json`. Resources whose cost doesn't change are
+// left out and the rest are sorted by how much their cost changes, most
+// expensive first. See https://www.infracost.io/docs/features/cli_commands/.
+func ParseInfracostJSON(breakdownJSON string) (*CostEstimate, error) {
+	var breakdown struct {
+		Currency             string  `json:"currency"`
+		TotalMonthlyCost     *string `json:"totalMonthlyCost"`
+		PastTotalMonthlyCost *string `json:"pastTotalMonthlyCost"`
+		DiffTotalMonthlyCost *string `json:"diffTotalMonthlyCost"`
+		Projects             []struct {
+			Diff struct {
+				Resources []struct {
+					Name        string  `json:"name"`
+					MonthlyCost *string `json:"monthlyCost"`
+				} `json:"resources"`
+			} `json:"diff"`
+		} `json:"projects"`
+	}
+	if err := json.Unmarshal([]byte(breakdownJSON), &breakdown); err != nil {
+		return nil, errors.Wrap(err, "parsing infracost json")
+	}
+
+	estimate := &CostEstimate{Currency: breakdown.Currency}
+	var err error
+	if estimate.MonthlyCost, err = parseCost(breakdown.TotalMonthlyCost); err != nil {
+		return nil, err
+	}
+	if estimate.PastMonthlyCost, err = parseCost(breakdown.PastTotalMonthlyCost); err != nil {
+		return nil, err
+	}
+	if estimate.DiffMonthlyCost, err = parseCost(breakdown.DiffTotalMonthlyCost); err != nil {
+		return nil, err
+	}
+	for _, project := range breakdown.Projects {
+		for _, r := range project.Diff.Resources {
+			diff, err := parseCost(r.MonthlyCost)
+			if err != nil {
+				return nil, err
+			}
+			if diff == 0 {
+				continue
+			}
+			estimate.ResourceDiffs = append(estimate.ResourceDiffs, ResourceCostDiff{Address: r.Name, DiffMonthlyCost: diff})
+		}
+	}
+	sort.SliceStable(estimate.ResourceDiffs, func(i, j int) bool {
+		return estimate.ResourceDiffs[i].DiffMonthlyCost > estimate.ResourceDiffs[j].DiffMonthlyCost
+	})
+	return estimate, nil
+}
+
+// parseCost parses a cost from infracost's JSON, which are decimal strings or
+// null if the cost is unknown.
+func parseCost(cost *string) (float64, error) {
+	if cost == nil || *cost == "" {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(*cost, 64)
+	return f, errors.Wrapf(err, "parsing cost %q", *cost)
+}
@@ -0,0 +1,96 @@
+package runtime_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	"github.com/runatlantis/atlantis/server/events/terraform/mocks"
+	matchers2 "github.com/runatlantis/atlantis/server/events/terraform/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+// fakeInfracost is a script that stands in for infracost. It prints its args
+// as JSON, or fails if there's no API key.
+var fakeInfracost = `#!/bin/sh
+if [ -z "$INFRACOST_API_KEY" ]; then
+  echo "No INFRACOST_API_KEY environment variable is set." >&2
+  exit 1
+fi
+echo "{\"args\": \"$*\"}"
+`
+
+func TestCostEstimateStepRunner_Run(t *testing.T) {
+	tmpDir, cleanup := TempDir(t)
+	defer cleanup()
+	Ok(t, ioutil.WriteFile(filepath.Join(tmpDir, "default.tfplan"), nil, 0600))
+	infracost := filepath.Join(tmpDir, "infracost")
+	Ok(t, ioutil.WriteFile(infracost, []byte(fakeInfracost), 0700))
+
+	RegisterMockTestingT(t)
+	terraform := mocks.NewMockClient()
+	When(terraform.RunCommandWithVersion(matchers2.AnyContextContext(), matchers.AnyPtrToLoggingSimpleLogger(), AnyString(), AnyStringSlice(), matchers2.AnyPtrToGoVersionVersion(), AnyString())).
+		ThenReturn(`{"format_version":"0.1"}`, nil)
+	r := runtime.CostEstimateStepRunner{
+		TerraformExecutor: terraform,
+		InfracostCommand:  infracost,
+	}
+	ctx := models.ProjectCommandContext{
+		Log:        logging.NewNoopLogger(),
+		RepoRelDir: ".",
+		Workspace:  "default",
+	}
+
+	Ok(t, os.Unsetenv("INFRACOST_API_KEY"))
+	_, err := r.Run(ctx, nil, tmpDir)
+	ErrContains(t, "running infracost: No INFRACOST_API_KEY environment variable is set.", err)
+
+	Ok(t, os.Setenv("INFRACOST_API_KEY", "key"))
+	defer os.Unsetenv("INFRACOST_API_KEY") // nolint: errcheck
+	jsonPath := filepath.Join(tmpDir, "default.tfplan.json")
+	out, err := r.Run(ctx, nil, tmpDir)
+	Ok(t, err)
+	Equals(t, `{"args": "breakdown --no-color --format json --path `+jsonPath+`"}`+"\n", out)
+	planJSON, err := ioutil.ReadFile(jsonPath)
+	Ok(t, err)
+	Equals(t, `{"format_version":"0.1"}`, string(planJSON))
+}
+
+func TestParseInfracostJSON(t *testing.T) {
+	estimate, err := runtime.ParseInfracostJSON(`{
+  "currency": "USD",
+  "totalMonthlyCost": "130.5",
+  "pastTotalMonthlyCost": "100",
+  "diffTotalMonthlyCost": "30.5",
+  "projects": [{
+    "diff": {
+      "resources": [
+        {"name": "aws_instance.old", "monthlyCost": "-20"},
+        {"name": "aws_instance.web", "monthlyCost": "50.5"},
+        {"name": "aws_s3_bucket.logs", "monthlyCost": null},
+        {"name": "aws_iam_role.web", "monthlyCost": "0"}
+      ]
+    }
+  }]
+}`)
+	Ok(t, err)
+	Equals(t, &runtime.CostEstimate{
+		Currency:        "USD",
+		PastMonthlyCost: 100,
+		MonthlyCost:     130.5,
+		DiffMonthlyCost: 30.5,
+		ResourceDiffs: []runtime.ResourceCostDiff{
+			{Address: "aws_instance.web", DiffMonthlyCost: 50.5},
+			{Address: "aws_instance.old", DiffMonthlyCost: -20},
+		},
+	}, estimate)
+
+	_, err = runtime.ParseInfracostJSON(`{"totalMonthlyCost": "lots"}`)
+	ErrEquals(t, `parsing cost "lots": strconv.ParseFloat: parsing "lots": invalid syntax`, err)
+}
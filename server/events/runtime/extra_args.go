@@ -0,0 +1,125 @@
+package runtime
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// AllowedExtraArgs are the terraform flags that comments can pass to
+// terraform after --, ex. atlantis plan -- -var-file=staging.tfvars. The
+// value is true if the flag takes a value, ex. -var foo=bar, which can also
+// be passed as -var=foo=bar. Other flags could make terraform read or write
+// files outside the repo, ex. -state, so they're rejected.
+var AllowedExtraArgs = map[string]bool{
+	"allow-missing-config": false,
+	"compact-warnings":     false,
+	"destroy":              false,
+	"dry-run":              false,
+	"lock":                 false,
+	"lock-timeout":         true,
+	"parallelism":          true,
+	"refresh":              false,
+	"refresh-only":         false,
+	"replace":              true,
+	"target":               true,
+	"var":                  true,
+	"var-file":             true,
+}
+
+// extraArgMetachars are the characters that extra args can't contain. The
+// args are quoted before they're run with a shell, but we reject anything a
+// shell would treat specially in case they're ever run unquoted.
+const extraArgMetachars = "$`\\;|&<>\n\r"
+
+// ValidateExtraArgs returns an error if args, which are the args a comment
+// passes to terraform after -- and aren't quoted yet, contain any shell
+// metacharacters, any flags that aren't in AllowedExtraArgs or any var files
+// outside the repo. repoRelDir is the directory, relative to the repo root, of
+// the project terraform is run in. If it's empty because the project isn't
+// known yet, var files are only checked to be relative paths.
+func ValidateExtraArgs(args []string, repoRelDir string) error {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if strings.ContainsAny(arg, extraArgMetachars) {
+			return fmt.Errorf("extra arg %q can't contain shell metacharacters", arg)
+		}
+		if !strings.HasPrefix(arg, "-") {
+			return fmt.Errorf("extra arg %q isn't a flag, only %s can be passed to terraform", arg, allowedExtraArgsList())
+		}
+		name := strings.TrimLeft(arg, "-")
+		hasValue := false
+		if idx := strings.Index(name, "="); idx != -1 {
+			name, hasValue = name[:idx], true
+		}
+		takesValue, ok := AllowedExtraArgs[name]
+		if !ok {
+			return fmt.Errorf("extra arg %q isn't allowed, only %s can be passed to terraform", arg, allowedExtraArgsList())
+		}
+		value := arg[strings.Index(arg, "=")+1:]
+		// The value can be the next arg, ex. -var foo=bar.
+		if takesValue && !hasValue && i+1 < len(args) {
+			i++
+			if strings.ContainsAny(args[i], extraArgMetachars) {
+				return fmt.Errorf("extra arg %q can't contain shell metacharacters", args[i])
+			}
+			value, hasValue = args[i], true
+		}
+		if name == "var-file" && hasValue {
+			if err := validateVarFile(value, repoRelDir); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateVarFile returns an error if varFile, which is relative to the
+// project in repoRelDir, could be outside the repo, ex. ../../etc/passwd.
+// Terraform's errors about var files it can't parse quote them so they'd be
+// commented on the pull request.
+func validateVarFile(varFile string, repoRelDir string) error {
+	if filepath.IsAbs(varFile) || strings.HasPrefix(varFile, "~") {
+		return fmt.Errorf("-var-file %q must be relative to the project's directory", varFile)
+	}
+	if repoRelDir == "" {
+		return nil
+	}
+	path := filepath.Join(repoRelDir, varFile)
+	if path == ".." || strings.HasPrefix(path, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("-var-file %q must be inside the repo", varFile)
+	}
+	return nil
+}
+
+// UnquoteCommentArgs returns args as they were commented, without the quotes
+// the comment parser adds so they're passed through the shell as is.
+func UnquoteCommentArgs(args []string) []string {
+	var unquoted []string
+	for _, arg := range args {
+		if len(arg) > 1 && strings.HasPrefix(arg, `"`) && strings.HasSuffix(arg, `"`) {
+			arg = strings.Replace(arg[1:len(arg)-1], `\"`, `"`, -1)
+		}
+		unquoted = append(unquoted, arg)
+	}
+	return unquoted
+}
+
+// validateCommentArgs returns an error if ctx's comment args aren't allowed
+// to be passed to terraform. Args from the workflow's steps are configured
+// by the repo or the server so they aren't checked.
+func validateCommentArgs(ctx models.ProjectCommandContext) error {
+	return ValidateExtraArgs(UnquoteCommentArgs(ctx.CommentArgs), ctx.RepoRelDir)
+}
+
+func allowedExtraArgsList() string {
+	var names []string
+	for name := range AllowedExtraArgs {
+		names = append(names, "-"+name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
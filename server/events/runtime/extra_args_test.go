@@ -0,0 +1,76 @@
+package runtime_test
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	"github.com/runatlantis/atlantis/server/events/terraform/mocks"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestValidateExtraArgs(t *testing.T) {
+	cases := []struct {
+		args   []string
+		expErr string
+	}{
+		{nil, ""},
+		{[]string{"-var", "foo=bar", "-var=baz=qux", "--var-file", "staging.tfvars", "-lock=false", "-parallelism", "5"}, ""},
+		{[]string{"-target=aws_instance.web[\"a\"]", "-replace", "aws_instance.app", "-refresh=false", "-destroy"}, ""},
+		// The value of -var can look like a flag.
+		{[]string{"-var", "-state=x"}, ""},
+		{[]string{"-state=/tmp/state"}, `extra arg "-state=/tmp/state" isn't allowed`},
+		{[]string{"-lock", "false"}, `extra arg "false" isn't a flag`},
+		{[]string{"-var-files=a.tfvars"}, `extra arg "-var-files=a.tfvars" isn't allowed`},
+		{[]string{"-var", "a=$(whoami)"}, `extra arg "a=$(whoami)" can't contain shell metacharacters`},
+		{[]string{"-var=a=`whoami`"}, "extra arg \"-var=a=`whoami`\" can't contain shell metacharacters"},
+		{[]string{"-lock=false;", "rm"}, `extra arg "-lock=false;" can't contain shell metacharacters`},
+		{[]string{"-lock=false", "&&"}, `extra arg "&&" can't contain shell metacharacters`},
+		{[]string{"-var", "a=b|c"}, `extra arg "a=b|c" can't contain shell metacharacters`},
+		{[]string{"-var-file=>x"}, `extra arg "-var-file=>x" can't contain shell metacharacters`},
+		{[]string{"-var-file=/etc/passwd"}, `-var-file "/etc/passwd" must be relative to the project's directory`},
+		{[]string{"-var-file", "../common.tfvars"}, ""},
+		{[]string{"-var-file=../../../../../etc/passwd"}, `-var-file "../../../../../etc/passwd" must be inside the repo`},
+		{[]string{"-var-file", "../../.."}, `-var-file "../../.." must be inside the repo`},
+		{[]string{"-var-file=modules/../../../x"}, `-var-file "modules/../../../x" must be inside the repo`},
+	}
+	for _, c := range cases {
+		t.Run(strings.Join(c.args, " "), func(t *testing.T) {
+			err := runtime.ValidateExtraArgs(c.args, "project")
+			if c.expErr == "" {
+				Ok(t, err)
+				return
+			}
+			Assert(t, err != nil, "exp error")
+			Assert(t, strings.HasPrefix(err.Error(), c.expErr), "exp error %q to start with %q", err.Error(), c.expErr)
+		})
+	}
+}
+
+func TestUnquoteCommentArgs(t *testing.T) {
+	Equals(t, []string{"-var", `a="b"`, "c"}, runtime.UnquoteCommentArgs([]string{`"-var"`, `"a=\"b\""`, "c"}))
+}
+
+// Comment args are validated before terraform is run, even if the comment
+// parser didn't check them.
+func TestPlanStepRunner_RejectsCommentArgs(t *testing.T) {
+	RegisterMockTestingT(t)
+	terraform := mocks.NewMockClient()
+	s := runtime.PlanStepRunner{TerraformExecutor: terraform}
+	_, err := s.Run(models.ProjectCommandContext{
+		Workspace:   "default",
+		CommentArgs: []string{`"-state-out=/tmp/state"`},
+	}, nil, "/path")
+	Assert(t, err != nil, "exp error")
+	Assert(t, strings.HasPrefix(err.Error(), `extra arg "-state-out=/tmp/state" isn't allowed`), "got %q", err.Error())
+
+	t.Log("var files are checked against the project's directory")
+	_, err = s.Run(models.ProjectCommandContext{
+		Workspace:   "default",
+		RepoRelDir:  "env/prod",
+		CommentArgs: []string{`"-var-file"`, `"../../../etc/passwd"`},
+	}, nil, "/path")
+	ErrEquals(t, `-var-file "../../../etc/passwd" must be inside the repo`, err)
+}
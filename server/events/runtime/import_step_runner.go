@@ -0,0 +1,65 @@
+package runtime
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// ImportStepRunner runs `terraform import`.
+type ImportStepRunner struct {
+	TerraformExecutor TerraformExec
+	DefaultTFVersion  *version.Version
+}
+
+// Run imports the resource at ctx.ImportAddress with ctx.ImportID into the
+// workspace's state. If it succeeds, the workspace's plan is deleted since it
+// was generated against the old state.
+func (i *ImportStepRunner) Run(ctx models.ProjectCommandContext, extraArgs []string, path string) (string, error) {
+	if err := validateCommentArgs(ctx); err != nil {
+		return "", err
+	}
+	tfVersion := GetTerraformVersion(ctx, i.DefaultTFVersion)
+
+	// We import into the same workspace, with the same vars, as plan would use.
+	planner := &PlanStepRunner{TerraformExecutor: i.TerraformExecutor, DefaultTFVersion: i.DefaultTFVersion}
+	if err := planner.switchWorkspace(ctx, path, tfVersion); err != nil {
+		return "", err
+	}
+	importCmd := planner.flatten([][]string{
+		{"import", "-input=false", "-no-color"},
+		planner.tfVars(ctx, tfVersion),
+		extraArgs,
+		ctx.CommentArgs,
+		getEnvFileArgs(ctx, path),
+		{shellQuote(ctx.ImportAddress), shellQuote(ctx.ImportID)},
+	})
+	out, err := i.TerraformExecutor.RunCommandWithVersion(ctx.CancelCtx, ctx.Log, filepath.Clean(path), importCmd, tfVersion, ctx.Workspace)
+	if err != nil {
+		return out, err
+	}
+	deleteStalePlan(ctx, path)
+	return out, nil
+}
+
+// deleteStalePlan deletes the workspace's planfile after its state has been
+// changed since the plan was generated against the old state.
+func deleteStalePlan(ctx models.ProjectCommandContext, path string) {
+	planPath := filepath.Join(path, GetPlanFilename(ctx.Workspace, ctx.ProjectConfig))
+	if err := os.Remove(planPath); err == nil {
+		ctx.Log.Info("state changed, deleted planfile since it's now out of date")
+	} else if !os.IsNotExist(err) {
+		ctx.Log.Warn("failed to delete planfile after changing state: %s", err)
+	}
+}
+
+// shellQuote quotes s so the shell terraform is run with passes it through
+// as is. Resource addresses often contain double quotes, ex.
+// aws_instance.web["a"], so we single quote it, which also stops $ and
+// backticks from being expanded.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
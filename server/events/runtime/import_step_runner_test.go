@@ -0,0 +1,91 @@
+package runtime_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/go-version"
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	"github.com/runatlantis/atlantis/server/events/terraform/mocks"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestImportStepRunner_Run(t *testing.T) {
+	tmpDir, cleanup := TempDir(t)
+	defer cleanup()
+	planPath := filepath.Join(tmpDir, "workspace.tfplan")
+	Ok(t, ioutil.WriteFile(planPath, nil, 0600))
+
+	RegisterMockTestingT(t)
+	terraform := mocks.NewMockClient()
+	tfVersion, _ := version.NewVersion("0.12.0")
+	logger := logging.NewNoopLogger()
+	s := runtime.ImportStepRunner{
+		TerraformExecutor: terraform,
+		DefaultTFVersion:  tfVersion,
+	}
+	When(terraform.RunCommandWithVersion(nil, logger, tmpDir, []string{"workspace", "show"}, tfVersion, "workspace")).ThenReturn("workspace\n", nil)
+	// The address and ID are single quoted so the shell doesn't interpret
+	// them.
+	expImportArgs := []string{"import",
+		"-input=false",
+		"-no-color",
+		"extra",
+		"args",
+		"-lock=false",
+		"-parallelism=5",
+		`'aws_instance.web["a"]'`,
+		`'it'\''s-$(id)'`}
+	When(terraform.RunCommandWithVersion(nil, logger, tmpDir, expImportArgs, tfVersion, "workspace")).ThenReturn("Import successful!", nil)
+
+	output, err := s.Run(models.ProjectCommandContext{
+		Log:           logger,
+		Workspace:     "workspace",
+		RepoRelDir:    ".",
+		CommentArgs:   []string{"-lock=false", "-parallelism=5"},
+		ImportAddress: `aws_instance.web["a"]`,
+		ImportID:      `it's-$(id)`,
+	}, []string{"extra", "args"}, tmpDir)
+	Ok(t, err)
+	Equals(t, "Import successful!", output)
+	_, err = os.Stat(planPath)
+	Assert(t, os.IsNotExist(err), "planfile should be deleted")
+}
+
+// If the import fails, the state hasn't changed so the plan should be kept.
+func TestImportStepRunner_RunErr(t *testing.T) {
+	tmpDir, cleanup := TempDir(t)
+	defer cleanup()
+	planPath := filepath.Join(tmpDir, "default.tfplan")
+	Ok(t, ioutil.WriteFile(planPath, nil, 0600))
+
+	RegisterMockTestingT(t)
+	terraform := mocks.NewMockClient()
+	tfVersion, _ := version.NewVersion("0.12.0")
+	logger := logging.NewNoopLogger()
+	s := runtime.ImportStepRunner{
+		TerraformExecutor: terraform,
+		DefaultTFVersion:  tfVersion,
+	}
+	When(terraform.RunCommandWithVersion(nil, logger, tmpDir, []string{"workspace", "show"}, tfVersion, "default")).ThenReturn("default\n", nil)
+	When(terraform.RunCommandWithVersion(nil, logger, tmpDir, []string{"import", "-input=false", "-no-color", "'aws_instance.web'", "'i-abcd1234'"}, tfVersion, "default")).
+		ThenReturn("Error: resource already managed by Terraform", errors.New("exit status 1"))
+
+	output, err := s.Run(models.ProjectCommandContext{
+		Log:           logger,
+		Workspace:     "default",
+		RepoRelDir:    ".",
+		ImportAddress: "aws_instance.web",
+		ImportID:      "i-abcd1234",
+	}, nil, tmpDir)
+	ErrEquals(t, "exit status 1", err)
+	Equals(t, "Error: resource already managed by Terraform", output)
+	_, err = os.Stat(planPath)
+	Ok(t, err)
+}
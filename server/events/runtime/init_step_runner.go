@@ -12,10 +12,7 @@ type InitStepRunner struct {
 }
 
 func (i *InitStepRunner) Run(ctx models.ProjectCommandContext, extraArgs []string, path string) (string, error) {
-	tfVersion := i.DefaultTFVersion
-	if ctx.ProjectConfig != nil && ctx.ProjectConfig.TerraformVersion != nil {
-		tfVersion = ctx.ProjectConfig.TerraformVersion
-	}
+	tfVersion := GetTerraformVersion(ctx, i.DefaultTFVersion)
 	terraformInitCmd := append([]string{"init", "-input=false", "-no-color"}, extraArgs...)
 
 	// If we're running < 0.9 we have to use `terraform get` instead of `init`.
@@ -24,7 +21,7 @@ func (i *InitStepRunner) Run(ctx models.ProjectCommandContext, extraArgs []strin
 		terraformInitCmd = append([]string{"get", "-no-color"}, extraArgs...)
 	}
 
-	out, err := i.TerraformExecutor.RunCommandWithVersion(ctx.Log, path, terraformInitCmd, tfVersion, ctx.Workspace)
+	out, err := i.TerraformExecutor.RunCommandWithVersion(ctx.CancelCtx, ctx.Log, path, terraformInitCmd, tfVersion, ctx.Workspace)
 	// Only include the init output if there was an error. Otherwise it's
 	// unnecessary and lengthens the comment.
 	if err != nil {
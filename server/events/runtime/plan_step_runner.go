@@ -25,19 +25,29 @@ type PlanStepRunner struct {
 }
 
 func (p *PlanStepRunner) Run(ctx models.ProjectCommandContext, extraArgs []string, path string) (string, error) {
-	tfVersion := p.DefaultTFVersion
-	if ctx.ProjectConfig != nil && ctx.ProjectConfig.TerraformVersion != nil {
-		tfVersion = ctx.ProjectConfig.TerraformVersion
+	if err := validateCommentArgs(ctx); err != nil {
+		return "", err
 	}
+	tfVersion := GetTerraformVersion(ctx, p.DefaultTFVersion)
 
 	// We only need to switch workspaces in version 0.9.*. In older versions,
 	// there is no such thing as a workspace so we don't need to do anything.
 	if err := p.switchWorkspace(ctx, path, tfVersion); err != nil {
 		return "", err
 	}
+	if usesRemoteOps(path) {
+		return p.runRemotePlan(ctx, extraArgs, path, tfVersion)
+	}
 
 	planCmd := p.buildPlanCmd(ctx, extraArgs, path, tfVersion)
-	output, err := p.TerraformExecutor.RunCommandWithVersion(ctx.Log, filepath.Clean(path), planCmd, tfVersion, ctx.Workspace)
+	var output string
+	var err error
+	if ctx.TFLogLevel != "" {
+		logPath := filepath.Join(path, GetTFLogFilename(ctx.Workspace, ctx.ProjectConfig))
+		output, err = p.TerraformExecutor.RunCommandWithLogLevel(ctx.CancelCtx, ctx.Log, filepath.Clean(path), planCmd, tfVersion, ctx.Workspace, ctx.TFLogLevel, logPath)
+	} else {
+		output, err = p.TerraformExecutor.RunCommandWithVersion(ctx.CancelCtx, ctx.Log, filepath.Clean(path), planCmd, tfVersion, ctx.Workspace)
+	}
 	if err != nil {
 		return output, err
 	}
@@ -69,7 +79,7 @@ func (p *PlanStepRunner) switchWorkspace(ctx models.ProjectCommandContext, path
 	// already in the right workspace then no need to switch. This will save us
 	// about ten seconds. This command is only available in > 0.10.
 	if !runningZeroPointNine {
-		workspaceShowOutput, err := p.TerraformExecutor.RunCommandWithVersion(ctx.Log, path, []string{workspaceCmd, "show"}, tfVersion, ctx.Workspace)
+		workspaceShowOutput, err := p.TerraformExecutor.RunCommandWithVersion(ctx.CancelCtx, ctx.Log, path, []string{workspaceCmd, "show"}, tfVersion, ctx.Workspace)
 		if err != nil {
 			return err
 		}
@@ -84,11 +94,11 @@ func (p *PlanStepRunner) switchWorkspace(ctx models.ProjectCommandContext, path
 	// To do this we can either select and catch the error or use list and then
 	// look for the workspace. Both commands take the same amount of time so
 	// that's why we're running select here.
-	_, err := p.TerraformExecutor.RunCommandWithVersion(ctx.Log, path, []string{workspaceCmd, "select", "-no-color", ctx.Workspace}, tfVersion, ctx.Workspace)
+	_, err := p.TerraformExecutor.RunCommandWithVersion(ctx.CancelCtx, ctx.Log, path, []string{workspaceCmd, "select", "-no-color", ctx.Workspace}, tfVersion, ctx.Workspace)
 	if err != nil {
 		// If terraform workspace select fails we run terraform workspace
 		// new to create a new workspace automatically.
-		_, err = p.TerraformExecutor.RunCommandWithVersion(ctx.Log, path, []string{workspaceCmd, "new", "-no-color", ctx.Workspace}, tfVersion, ctx.Workspace)
+		_, err = p.TerraformExecutor.RunCommandWithVersion(ctx.CancelCtx, ctx.Log, path, []string{workspaceCmd, "new", "-no-color", ctx.Workspace}, tfVersion, ctx.Workspace)
 		return err
 	}
 	return nil
@@ -98,29 +108,34 @@ func (p *PlanStepRunner) buildPlanCmd(ctx models.ProjectCommandContext, extraArg
 	tfVars := p.tfVars(ctx, tfVersion)
 	planFile := filepath.Join(path, GetPlanFilename(ctx.Workspace, ctx.ProjectConfig))
 
-	// Check if env/{workspace}.tfvars exist and include it. This is a use-case
-	// from Hootsuite where Atlantis was first created so we're keeping this as
-	// an homage and a favor so they don't need to refactor all their repos.
-	// It's also a nice way to structure your repos to reduce duplication.
-	var envFileArgs []string
-	envFile := filepath.Join(path, "env", ctx.Workspace+".tfvars")
-	if _, err := os.Stat(envFile); err == nil {
-		envFileArgs = []string{"-var-file", envFile}
-	}
-
 	argList := [][]string{
 		// NOTE: we need to quote the plan filename because Bitbucket Server can
 		// have spaces in its repo owner names.
 		{"plan", "-input=false", "-refresh", "-no-color", "-out", fmt.Sprintf("%q", planFile)},
 		tfVars,
+		destroyArgs(ctx),
+		targetArgs(ctx),
 		extraArgs,
 		ctx.CommentArgs,
-		envFileArgs,
+		getEnvFileArgs(ctx, path),
 	}
 
 	return p.flatten(argList)
 }
 
+// getEnvFileArgs returns the args to pass env/{workspace}.tfvars as a var file
+// if it exists. This is a use-case from Hootsuite where Atlantis was first
+// created so we're keeping this as an homage and a favor so they don't need to
+// refactor all their repos. It's also a nice way to structure your repos to
+// reduce duplication.
+func getEnvFileArgs(ctx models.ProjectCommandContext, path string) []string {
+	envFile := filepath.Join(path, "env", ctx.Workspace+".tfvars")
+	if _, err := os.Stat(envFile); err == nil {
+		return []string{"-var-file", envFile}
+	}
+	return nil
+}
+
 // tfVars returns a list of "-var", "key=value" pairs that identify who and which
 // repo this command is running for. This can be used for naming the
 // session name in AWS which will identify in CloudTrail the source of
@@ -33,11 +33,11 @@ func TestRun_NoWorkspaceIn08(t *testing.T) {
 		TerraformExecutor: terraform,
 	}
 
-	When(terraform.RunCommandWithVersion(matchers.AnyPtrToLoggingSimpleLogger(), AnyString(), AnyStringSlice(), matchers2.AnyPtrToGoVersionVersion(), AnyString())).
+	When(terraform.RunCommandWithVersion(matchers2.AnyContextContext(), matchers.AnyPtrToLoggingSimpleLogger(), AnyString(), AnyStringSlice(), matchers2.AnyPtrToGoVersionVersion(), AnyString())).
 		ThenReturn("output", nil)
 	output, err := s.Run(models.ProjectCommandContext{
 		Log:         logger,
-		CommentArgs: []string{"comment", "args"},
+		CommentArgs: []string{"-lock=false", "-parallelism=5"},
 		Workspace:   workspace,
 		RepoRelDir:  ".",
 		User:        models.User{Username: "username"},
@@ -54,6 +54,7 @@ func TestRun_NoWorkspaceIn08(t *testing.T) {
 
 	Equals(t, "output", output)
 	terraform.VerifyWasCalledOnce().RunCommandWithVersion(
+		nil,
 		logger,
 		"/path",
 		[]string{"plan",
@@ -74,13 +75,13 @@ func TestRun_NoWorkspaceIn08(t *testing.T) {
 			"atlantis_pull_num=2",
 			"extra",
 			"args",
-			"comment",
-			"args"},
+			"-lock=false",
+			"-parallelism=5"},
 		tfVersion,
 		workspace)
 
 	// Verify that no env or workspace commands were run
-	terraform.VerifyWasCalled(Never()).RunCommandWithVersion(logger,
+	terraform.VerifyWasCalled(Never()).RunCommandWithVersion(nil, logger,
 		"/path",
 		[]string{"env",
 			"select",
@@ -88,7 +89,7 @@ func TestRun_NoWorkspaceIn08(t *testing.T) {
 			"workspace"},
 		tfVersion,
 		workspace)
-	terraform.VerifyWasCalled(Never()).RunCommandWithVersion(logger,
+	terraform.VerifyWasCalled(Never()).RunCommandWithVersion(nil, logger,
 		"/path",
 		[]string{"workspace",
 			"select",
@@ -112,7 +113,7 @@ func TestRun_ErrWorkspaceIn08(t *testing.T) {
 		DefaultTFVersion:  tfVersion,
 	}
 
-	When(terraform.RunCommandWithVersion(matchers.AnyPtrToLoggingSimpleLogger(), AnyString(), AnyStringSlice(), matchers2.AnyPtrToGoVersionVersion(), AnyString())).
+	When(terraform.RunCommandWithVersion(matchers2.AnyContextContext(), matchers.AnyPtrToLoggingSimpleLogger(), AnyString(), AnyStringSlice(), matchers2.AnyPtrToGoVersionVersion(), AnyString())).
 		ThenReturn("output", nil)
 	_, err := s.Run(models.ProjectCommandContext{
 		Log:        logger,
@@ -160,14 +161,14 @@ func TestRun_SwitchesWorkspace(t *testing.T) {
 				DefaultTFVersion:  tfVersion,
 			}
 
-			When(terraform.RunCommandWithVersion(matchers.AnyPtrToLoggingSimpleLogger(), AnyString(), AnyStringSlice(), matchers2.AnyPtrToGoVersionVersion(), AnyString())).
+			When(terraform.RunCommandWithVersion(matchers2.AnyContextContext(), matchers.AnyPtrToLoggingSimpleLogger(), AnyString(), AnyStringSlice(), matchers2.AnyPtrToGoVersionVersion(), AnyString())).
 				ThenReturn("output", nil)
 			output, err := s.Run(models.ProjectCommandContext{
 				Log:         logger,
 				Workspace:   "workspace",
 				RepoRelDir:  ".",
 				User:        models.User{Username: "username"},
-				CommentArgs: []string{"comment", "args"},
+				CommentArgs: []string{"-lock=false", "-parallelism=5"},
 				Pull: models.PullRequest{
 					Num: 2,
 				},
@@ -181,7 +182,7 @@ func TestRun_SwitchesWorkspace(t *testing.T) {
 
 			Equals(t, "output", output)
 			// Verify that env select was called as well as plan.
-			terraform.VerifyWasCalledOnce().RunCommandWithVersion(logger,
+			terraform.VerifyWasCalledOnce().RunCommandWithVersion(nil, logger,
 				"/path",
 				[]string{c.expWorkspaceCmd,
 					"select",
@@ -189,7 +190,7 @@ func TestRun_SwitchesWorkspace(t *testing.T) {
 					"workspace"},
 				tfVersion,
 				"workspace")
-			terraform.VerifyWasCalledOnce().RunCommandWithVersion(logger,
+			terraform.VerifyWasCalledOnce().RunCommandWithVersion(nil, logger,
 				"/path",
 				[]string{"plan",
 					"-input=false",
@@ -209,8 +210,8 @@ func TestRun_SwitchesWorkspace(t *testing.T) {
 					"atlantis_pull_num=2",
 					"extra",
 					"args",
-					"comment",
-					"args"},
+					"-lock=false",
+					"-parallelism=5"},
 				tfVersion,
 				"workspace")
 		})
@@ -255,10 +256,10 @@ func TestRun_CreatesWorkspace(t *testing.T) {
 
 			// Ensure that we actually try to switch workspaces by making the
 			// output of `workspace show` to be a different name.
-			When(terraform.RunCommandWithVersion(logger, "/path", []string{"workspace", "show"}, tfVersion, "workspace")).ThenReturn("diffworkspace\n", nil)
+			When(terraform.RunCommandWithVersion(nil, logger, "/path", []string{"workspace", "show"}, tfVersion, "workspace")).ThenReturn("diffworkspace\n", nil)
 
 			expWorkspaceArgs := []string{c.expWorkspaceCommand, "select", "-no-color", "workspace"}
-			When(terraform.RunCommandWithVersion(logger, "/path", expWorkspaceArgs, tfVersion, "workspace")).ThenReturn("", errors.New("workspace does not exist"))
+			When(terraform.RunCommandWithVersion(nil, logger, "/path", expWorkspaceArgs, tfVersion, "workspace")).ThenReturn("", errors.New("workspace does not exist"))
 
 			expPlanArgs := []string{"plan",
 				"-input=false",
@@ -278,16 +279,16 @@ func TestRun_CreatesWorkspace(t *testing.T) {
 				"atlantis_pull_num=2",
 				"extra",
 				"args",
-				"comment",
-				"args"}
-			When(terraform.RunCommandWithVersion(logger, "/path", expPlanArgs, tfVersion, "workspace")).ThenReturn("output", nil)
+				"-lock=false",
+				"-parallelism=5"}
+			When(terraform.RunCommandWithVersion(nil, logger, "/path", expPlanArgs, tfVersion, "workspace")).ThenReturn("output", nil)
 
 			output, err := s.Run(models.ProjectCommandContext{
 				Log:         logger,
 				Workspace:   "workspace",
 				RepoRelDir:  ".",
 				User:        models.User{Username: "username"},
-				CommentArgs: []string{"comment", "args"},
+				CommentArgs: []string{"-lock=false", "-parallelism=5"},
 				Pull: models.PullRequest{
 					Num: 2,
 				},
@@ -301,8 +302,8 @@ func TestRun_CreatesWorkspace(t *testing.T) {
 
 			Equals(t, "output", output)
 			// Verify that env select was called as well as plan.
-			terraform.VerifyWasCalledOnce().RunCommandWithVersion(logger, "/path", expWorkspaceArgs, tfVersion, "workspace")
-			terraform.VerifyWasCalledOnce().RunCommandWithVersion(logger, "/path", expPlanArgs, tfVersion, "workspace")
+			terraform.VerifyWasCalledOnce().RunCommandWithVersion(nil, logger, "/path", expWorkspaceArgs, tfVersion, "workspace")
+			terraform.VerifyWasCalledOnce().RunCommandWithVersion(nil, logger, "/path", expPlanArgs, tfVersion, "workspace")
 		})
 	}
 }
@@ -318,7 +319,7 @@ func TestRun_NoWorkspaceSwitchIfNotNecessary(t *testing.T) {
 		TerraformExecutor: terraform,
 		DefaultTFVersion:  tfVersion,
 	}
-	When(terraform.RunCommandWithVersion(logger, "/path", []string{"workspace", "show"}, tfVersion, "workspace")).ThenReturn("workspace\n", nil)
+	When(terraform.RunCommandWithVersion(nil, logger, "/path", []string{"workspace", "show"}, tfVersion, "workspace")).ThenReturn("workspace\n", nil)
 
 	expPlanArgs := []string{"plan",
 		"-input=false",
@@ -338,16 +339,16 @@ func TestRun_NoWorkspaceSwitchIfNotNecessary(t *testing.T) {
 		"atlantis_pull_num=2",
 		"extra",
 		"args",
-		"comment",
-		"args"}
-	When(terraform.RunCommandWithVersion(logger, "/path", expPlanArgs, tfVersion, "workspace")).ThenReturn("output", nil)
+		"-lock=false",
+		"-parallelism=5"}
+	When(terraform.RunCommandWithVersion(nil, logger, "/path", expPlanArgs, tfVersion, "workspace")).ThenReturn("output", nil)
 
 	output, err := s.Run(models.ProjectCommandContext{
 		Log:         logger,
 		Workspace:   "workspace",
 		RepoRelDir:  ".",
 		User:        models.User{Username: "username"},
-		CommentArgs: []string{"comment", "args"},
+		CommentArgs: []string{"-lock=false", "-parallelism=5"},
 		Pull: models.PullRequest{
 			Num: 2,
 		},
@@ -360,10 +361,10 @@ func TestRun_NoWorkspaceSwitchIfNotNecessary(t *testing.T) {
 	Ok(t, err)
 
 	Equals(t, "output", output)
-	terraform.VerifyWasCalledOnce().RunCommandWithVersion(logger, "/path", expPlanArgs, tfVersion, "workspace")
+	terraform.VerifyWasCalledOnce().RunCommandWithVersion(nil, logger, "/path", expPlanArgs, tfVersion, "workspace")
 
 	// Verify that workspace select was never called.
-	terraform.VerifyWasCalled(Never()).RunCommandWithVersion(logger, "/path", []string{"workspace", "select", "-no-color", "workspace"}, tfVersion, "workspace")
+	terraform.VerifyWasCalled(Never()).RunCommandWithVersion(nil, logger, "/path", []string{"workspace", "select", "-no-color", "workspace"}, tfVersion, "workspace")
 }
 
 func TestRun_AddsEnvVarFile(t *testing.T) {
@@ -406,19 +407,19 @@ func TestRun_AddsEnvVarFile(t *testing.T) {
 		"atlantis_pull_num=2",
 		"extra",
 		"args",
-		"comment",
-		"args",
+		"-lock=false",
+		"-parallelism=5",
 		"-var-file",
 		envVarsFile,
 	}
-	When(terraform.RunCommandWithVersion(logger, tmpDir, expPlanArgs, tfVersion, "workspace")).ThenReturn("output", nil)
+	When(terraform.RunCommandWithVersion(nil, logger, tmpDir, expPlanArgs, tfVersion, "workspace")).ThenReturn("output", nil)
 
 	output, err := s.Run(models.ProjectCommandContext{
 		Log:         logger,
 		Workspace:   "workspace",
 		RepoRelDir:  ".",
 		User:        models.User{Username: "username"},
-		CommentArgs: []string{"comment", "args"},
+		CommentArgs: []string{"-lock=false", "-parallelism=5"},
 		Pull: models.PullRequest{
 			Num: 2,
 		},
@@ -431,8 +432,8 @@ func TestRun_AddsEnvVarFile(t *testing.T) {
 	Ok(t, err)
 
 	// Verify that env select was never called since we're in version >= 0.10
-	terraform.VerifyWasCalled(Never()).RunCommandWithVersion(logger, tmpDir, []string{"env", "select", "-no-color", "workspace"}, tfVersion, "workspace")
-	terraform.VerifyWasCalledOnce().RunCommandWithVersion(logger, tmpDir, expPlanArgs, tfVersion, "workspace")
+	terraform.VerifyWasCalled(Never()).RunCommandWithVersion(nil, logger, tmpDir, []string{"env", "select", "-no-color", "workspace"}, tfVersion, "workspace")
+	terraform.VerifyWasCalledOnce().RunCommandWithVersion(nil, logger, tmpDir, expPlanArgs, tfVersion, "workspace")
 	Equals(t, "output", output)
 }
 
@@ -447,7 +448,7 @@ func TestRun_UsesDiffPathForProject(t *testing.T) {
 		TerraformExecutor: terraform,
 		DefaultTFVersion:  tfVersion,
 	}
-	When(terraform.RunCommandWithVersion(logger, "/path", []string{"workspace", "show"}, tfVersion, "workspace")).ThenReturn("workspace\n", nil)
+	When(terraform.RunCommandWithVersion(nil, logger, "/path", []string{"workspace", "show"}, tfVersion, "workspace")).ThenReturn("workspace\n", nil)
 
 	expPlanArgs := []string{"plan",
 		"-input=false",
@@ -467,10 +468,10 @@ func TestRun_UsesDiffPathForProject(t *testing.T) {
 		"atlantis_pull_num=2",
 		"extra",
 		"args",
-		"comment",
-		"args",
+		"-lock=false",
+		"-parallelism=5",
 	}
-	When(terraform.RunCommandWithVersion(logger, "/path", expPlanArgs, tfVersion, "default")).ThenReturn("output", nil)
+	When(terraform.RunCommandWithVersion(nil, logger, "/path", expPlanArgs, tfVersion, "default")).ThenReturn("output", nil)
 
 	projectName := "projectname"
 	output, err := s.Run(models.ProjectCommandContext{
@@ -478,7 +479,7 @@ func TestRun_UsesDiffPathForProject(t *testing.T) {
 		Workspace:   "default",
 		RepoRelDir:  ".",
 		User:        models.User{Username: "username"},
-		CommentArgs: []string{"comment", "args"},
+		CommentArgs: []string{"-lock=false", "-parallelism=5"},
 		ProjectConfig: &valid.Project{
 			Name: &projectName,
 		},
@@ -531,6 +532,7 @@ Terraform will perform the following actions:
 		DefaultTFVersion:  tfVersion,
 	}
 	When(terraform.RunCommandWithVersion(
+		matchers2.AnyContextContext(),
 		matchers.AnyPtrToLoggingSimpleLogger(),
 		AnyString(),
 		AnyStringSlice(),
@@ -539,7 +541,7 @@ Terraform will perform the following actions:
 		Then(func(params []Param) ReturnValues {
 			// This code allows us to return different values depending on the
 			// tf command being run while still using the wildcard matchers above.
-			tfArgs := params[2].([]string)
+			tfArgs := params[3].([]string)
 			if stringSliceEquals(tfArgs, []string{"workspace", "show"}) {
 				return []ReturnValue{"default", nil}
 			} else if tfArgs[0] == "plan" {
@@ -584,6 +586,7 @@ func TestRun_OutputOnErr(t *testing.T) {
 	expOutput := "expected output"
 	expErrMsg := "error!"
 	When(terraform.RunCommandWithVersion(
+		matchers2.AnyContextContext(),
 		matchers.AnyPtrToLoggingSimpleLogger(),
 		AnyString(),
 		AnyStringSlice(),
@@ -592,7 +595,7 @@ func TestRun_OutputOnErr(t *testing.T) {
 		Then(func(params []Param) ReturnValues {
 			// This code allows us to return different values depending on the
 			// tf command being run while still using the wildcard matchers above.
-			tfArgs := params[2].([]string)
+			tfArgs := params[3].([]string)
 			if stringSliceEquals(tfArgs, []string{"workspace", "show"}) {
 				return []ReturnValue{"default\n", nil}
 			} else if tfArgs[0] == "plan" {
@@ -620,6 +623,7 @@ func TestRun_NoOptionalVarsIn012(t *testing.T) {
 	}
 
 	When(terraform.RunCommandWithVersion(
+		matchers2.AnyContextContext(),
 		matchers.AnyPtrToLoggingSimpleLogger(),
 		AnyString(),
 		AnyStringSlice(),
@@ -630,7 +634,7 @@ func TestRun_NoOptionalVarsIn012(t *testing.T) {
 		Workspace:   "default",
 		RepoRelDir:  ".",
 		User:        models.User{Username: "username"},
-		CommentArgs: []string{"comment", "args"},
+		CommentArgs: []string{"-lock=false", "-parallelism=5"},
 		Pull: models.PullRequest{
 			Num: 2,
 		},
@@ -651,10 +655,103 @@ func TestRun_NoOptionalVarsIn012(t *testing.T) {
 		fmt.Sprintf("%q", "/path/default.tfplan"),
 		"extra",
 		"args",
-		"comment",
+		"-lock=false",
+		"-parallelism=5",
+	}
+	terraform.VerifyWasCalledOnce().RunCommandWithVersion(nil, nil, "/path", expPlanArgs, tfVersion, "default")
+}
+
+// Test that destroy plans are run with -destroy and targeted plans with
+// -target.
+func TestRun_Destroy(t *testing.T) {
+	RegisterMockTestingT(t)
+	terraform := mocks.NewMockClient()
+
+	tfVersion, _ := version.NewVersion("0.12.0")
+	s := runtime.PlanStepRunner{
+		TerraformExecutor: terraform,
+		DefaultTFVersion:  tfVersion,
+	}
+
+	When(terraform.RunCommandWithVersion(
+		matchers2.AnyContextContext(),
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		AnyString(),
+		AnyStringSlice(),
+		matchers2.AnyPtrToGoVersionVersion(),
+		AnyString())).ThenReturn("output", nil)
+
+	_, err := s.Run(models.ProjectCommandContext{
+		Workspace:   "default",
+		RepoRelDir:  ".",
+		CommentArgs: []string{"-lock=false", "-parallelism=5"},
+		Destroy:     true,
+		Targets:     []string{"aws_instance.web", `aws_instance.db["key"]`},
+	}, []string{"extra", "args"}, "/path")
+	Ok(t, err)
+
+	expPlanArgs := []string{"plan",
+		"-input=false",
+		"-refresh",
+		"-no-color",
+		"-out",
+		fmt.Sprintf("%q", "/path/default.tfplan"),
+		"-destroy",
+		`"-target=aws_instance.web"`,
+		`"-target=aws_instance.db[\"key\"]"`,
+		"extra",
 		"args",
+		"-lock=false",
+		"-parallelism=5",
+	}
+	terraform.VerifyWasCalledOnce().RunCommandWithVersion(nil, nil, "/path", expPlanArgs, tfVersion, "default")
+}
+
+// Test that if a TF_LOG level is set, plan is run with that level and logs to
+// a file next to the plan file.
+func TestRun_TFLogLevel(t *testing.T) {
+	RegisterMockTestingT(t)
+	terraform := mocks.NewMockClient()
+
+	tfVersion, _ := version.NewVersion("0.12.0")
+	s := runtime.PlanStepRunner{
+		TerraformExecutor: terraform,
+		DefaultTFVersion:  tfVersion,
+	}
+
+	When(terraform.RunCommandWithVersion(
+		matchers2.AnyContextContext(),
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		AnyString(),
+		AnyStringSlice(),
+		matchers2.AnyPtrToGoVersionVersion(),
+		AnyString())).ThenReturn("default", nil)
+	When(terraform.RunCommandWithLogLevel(
+		matchers2.AnyContextContext(),
+		matchers.AnyPtrToLoggingSimpleLogger(),
+		AnyString(),
+		AnyStringSlice(),
+		matchers2.AnyPtrToGoVersionVersion(),
+		AnyString(),
+		AnyString(),
+		AnyString())).ThenReturn("output", nil)
+
+	output, err := s.Run(models.ProjectCommandContext{
+		Workspace:  "default",
+		RepoRelDir: ".",
+		TFLogLevel: "DEBUG",
+	}, nil, "/path")
+	Ok(t, err)
+	Equals(t, "output", output)
+
+	expPlanArgs := []string{"plan",
+		"-input=false",
+		"-refresh",
+		"-no-color",
+		"-out",
+		fmt.Sprintf("%q", "/path/default.tfplan"),
 	}
-	terraform.VerifyWasCalledOnce().RunCommandWithVersion(nil, "/path", expPlanArgs, tfVersion, "default")
+	terraform.VerifyWasCalledOnce().RunCommandWithLogLevel(nil, nil, "/path", expPlanArgs, tfVersion, "default", "DEBUG", "/path/default.tflog")
 }
 
 func stringSliceEquals(a, b []string) bool {
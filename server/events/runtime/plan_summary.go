@@ -0,0 +1,94 @@
+package runtime
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// planSummaryRegex matches the summary line terraform prints at the end of a
+// plan, ex. "Plan: 1 to add, 2 to change, 3 to destroy."
+var planSummaryRegex = regexp.MustCompile(`(?m)^Plan: (\d+) to add, (\d+) to change, (\d+) to destroy\.`)
+
+// PlanSummary is the number of resources a plan will add, change and destroy.
+type PlanSummary struct {
+	Add     int
+	Change  int
+	Destroy int
+}
+
+// ParsePlanSummary parses the summary line out of terraform plan output. It
+// returns false if there was no summary, ex. because there were no changes.
+func ParsePlanSummary(output string) (PlanSummary, bool) {
+	match := planSummaryRegex.FindStringSubmatch(output)
+	if match == nil {
+		return PlanSummary{}, false
+	}
+	// The regex only matches digits so these can't fail.
+	add, _ := strconv.Atoi(match[1])
+	change, _ := strconv.Atoi(match[2])
+	destroy, _ := strconv.Atoi(match[3])
+	return PlanSummary{Add: add, Change: change, Destroy: destroy}, true
+}
+
+// ResourceTypeSummary is the number of resources of one type that a plan will
+// add, change and destroy.
+type ResourceTypeSummary struct {
+	Type string
+	PlanSummary
+}
+
+// ParsePlanJSON returns how many resources of each type the plan in planJSON,
+// the output of `terraform show -json`, will add, change and destroy. Types
+// are sorted and those without changes are left out. Like in terraform's own
+// summary, replaced resources count as both added and destroyed. See
+// https://www.terraform.io/docs/internals/json-format.html.
+func ParsePlanJSON(planJSON string) ([]ResourceTypeSummary, error) {
+	var plan struct {
+		ResourceChanges []struct {
+			Type   string `json:"type"`
+			Change struct {
+				Actions []string `json:"actions"`
+			} `json:"change"`
+		} `json:"resource_changes"`
+	}
+	if err := json.Unmarshal([]byte(planJSON), &plan); err != nil {
+		return nil, errors.Wrap(err, "parsing plan json")
+	}
+
+	byType := make(map[string]*ResourceTypeSummary)
+	for _, rc := range plan.ResourceChanges {
+		var add, change, destroy int
+		for _, action := range rc.Change.Actions {
+			switch action {
+			case "create":
+				add = 1
+			case "update":
+				change = 1
+			case "delete":
+				destroy = 1
+			}
+		}
+		if add+change+destroy == 0 {
+			continue
+		}
+		summary, ok := byType[rc.Type]
+		if !ok {
+			summary = &ResourceTypeSummary{Type: rc.Type}
+			byType[rc.Type] = summary
+		}
+		summary.Add += add
+		summary.Change += change
+		summary.Destroy += destroy
+	}
+
+	var summaries []ResourceTypeSummary
+	for _, s := range byType {
+		summaries = append(summaries, *s)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Type < summaries[j].Type })
+	return summaries, nil
+}
@@ -0,0 +1,80 @@
+package runtime_test
+
+import (
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestParsePlanSummary(t *testing.T) {
+	cases := []struct {
+		description string
+		output      string
+		exp         runtime.PlanSummary
+		expOk       bool
+	}{
+		{
+			"changes",
+			`An execution plan has been generated and is shown below.
+
+- null_resource.a
++ null_resource.b
+
+Plan: 1 to add, 0 to change, 12 to destroy.`,
+			runtime.PlanSummary{Add: 1, Change: 0, Destroy: 12},
+			true,
+		},
+		{
+			"no changes",
+			"No changes. Infrastructure is up-to-date.",
+			runtime.PlanSummary{},
+			false,
+		},
+		{
+			"summary not at start of line",
+			"echo Plan: 1 to add, 0 to change, 1 to destroy.",
+			runtime.PlanSummary{},
+			false,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			summary, ok := runtime.ParsePlanSummary(c.output)
+			Equals(t, c.expOk, ok)
+			Equals(t, c.exp, summary)
+		})
+	}
+}
+
+func TestParsePlanJSON(t *testing.T) {
+	planJSON := `{
+  "format_version": "0.1",
+  "resource_changes": [
+    {"address": "aws_instance.web[0]", "type": "aws_instance", "change": {"actions": ["create"]}},
+    {"address": "aws_instance.web[1]", "type": "aws_instance", "change": {"actions": ["delete", "create"]}},
+    {"address": "aws_s3_bucket.logs", "type": "aws_s3_bucket", "change": {"actions": ["update"]}},
+    {"address": "aws_iam_role.old", "type": "aws_iam_role", "change": {"actions": ["delete"]}},
+    {"address": "null_resource.same", "type": "null_resource", "change": {"actions": ["no-op"]}},
+    {"address": "data.aws_ami.ubuntu", "type": "aws_ami", "change": {"actions": ["read"]}}
+  ]
+}`
+	summaries, err := runtime.ParsePlanJSON(planJSON)
+	Ok(t, err)
+	Equals(t, []runtime.ResourceTypeSummary{
+		{Type: "aws_iam_role", PlanSummary: runtime.PlanSummary{Destroy: 1}},
+		{Type: "aws_instance", PlanSummary: runtime.PlanSummary{Add: 2, Destroy: 1}},
+		{Type: "aws_s3_bucket", PlanSummary: runtime.PlanSummary{Change: 1}},
+	}, summaries)
+}
+
+func TestParsePlanJSON_NoChanges(t *testing.T) {
+	summaries, err := runtime.ParsePlanJSON(`{"format_version": "0.1"}`)
+	Ok(t, err)
+	Equals(t, 0, len(summaries))
+}
+
+func TestParsePlanJSON_Invalid(t *testing.T) {
+	_, err := runtime.ParsePlanJSON("Error: no plan")
+	ErrContains(t, "parsing plan json", err)
+}
@@ -0,0 +1,83 @@
+package runtime
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/terraform"
+)
+
+// DefaultConftestCommand is the conftest binary we run if none is set. It's
+// looked up in $PATH.
+const DefaultConftestCommand = "conftest"
+
+// PolicyCheckStepRunner checks the plan against the project's policy sets
+// with Conftest. See https://www.conftest.dev.
+type PolicyCheckStepRunner struct {
+	TerraformExecutor TerraformExec
+	DefaultTFVersion  *version.Version
+	// ConftestCommand is the conftest binary to run. If empty,
+	// DefaultConftestCommand is used.
+	ConftestCommand string
+}
+
+// Run converts the plan to JSON with `terraform show -json` and runs
+// `conftest test` against it for each policy set. extraArgs are appended to
+// the conftest command. If any policy set fails, an error listing them is
+// returned along with the output for all the sets.
+func (p *PolicyCheckStepRunner) Run(ctx models.ProjectCommandContext, extraArgs []string, path string) (string, error) {
+	planPath := filepath.Join(path, GetPlanFilename(ctx.Workspace, ctx.ProjectConfig))
+	planContents, err := ioutil.ReadFile(planPath) // nolint: gosec
+	if err != nil {
+		return "", fmt.Errorf("no plan found at path %q and workspace %q–did you run plan?", ctx.RepoRelDir, ctx.Workspace)
+	}
+	if isRemotePlan(planContents) {
+		return "", errors.New("policy checks can't be run on plans made with remote operations since Terraform Cloud doesn't save them locally; use its Sentinel policies instead")
+	}
+
+	tfVersion := GetTerraformVersion(ctx, p.DefaultTFVersion)
+	planJSON, err := p.TerraformExecutor.RunCommandWithVersion(ctx.CancelCtx, ctx.Log, path, []string{"show", "-json", fmt.Sprintf("%q", planPath)}, tfVersion, ctx.Workspace)
+	if err != nil {
+		return "", errors.Wrap(err, "converting plan to json (policy checks need Terraform 0.12 or later)")
+	}
+	planJSONPath := filepath.Join(path, GetPlanJSONFilename(ctx.Workspace, ctx.ProjectConfig))
+	if err := ioutil.WriteFile(planJSONPath, []byte(planJSON), 0600); err != nil {
+		return "", errors.Wrap(err, "writing plan json")
+	}
+
+	conftest := p.ConftestCommand
+	if conftest == "" {
+		conftest = DefaultConftestCommand
+	}
+	var outputs []string
+	var failed []string
+	for _, set := range ctx.PolicySets {
+		args := append([]string{"test", "--no-color", "--policy", set.Path}, extraArgs...)
+		args = append(args, planJSONPath)
+		cmd := exec.Command(conftest, args...) // #nosec
+		cmd.Dir = path
+		var out bytes.Buffer
+		cmd.Stdout = &out
+		cmd.Stderr = &out
+		runErr := terraform.RunCancellable(ctx.CancelCtx, cmd)
+		if runErr != nil {
+			ctx.Log.Info("policy set %q failed: %s", set.Name, runErr)
+			failed = append(failed, set.Name)
+		} else {
+			ctx.Log.Info("policy set %q passed", set.Name)
+		}
+		outputs = append(outputs, fmt.Sprintf("policy set: %s\n%s", set.Name, strings.TrimSpace(out.String())))
+	}
+	output := strings.Join(outputs, "\n\n")
+	if len(failed) > 0 {
+		return output, fmt.Errorf("policy set(s) failed: %s", strings.Join(failed, ", "))
+	}
+	return output, nil
+}
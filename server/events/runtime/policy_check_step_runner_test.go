@@ -0,0 +1,122 @@
+package runtime_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	"github.com/runatlantis/atlantis/server/events/terraform/mocks"
+	matchers2 "github.com/runatlantis/atlantis/server/events/terraform/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+// fakeConftest is a script that stands in for conftest. It prints its args
+// and fails if the policy path contains "fail".
+var fakeConftest = `#!/bin/sh
+echo "$@"
+case "$4" in
+  *fail*) exit 1 ;;
+esac
+`
+
+func TestPolicyCheckStepRunner_NoPlanFile(t *testing.T) {
+	tmpDir, cleanup := TempDir(t)
+	defer cleanup()
+	r := runtime.PolicyCheckStepRunner{}
+	_, err := r.Run(models.ProjectCommandContext{
+		RepoRelDir: ".",
+		Workspace:  "workspace",
+	}, nil, tmpDir)
+	ErrEquals(t, "no plan found at path \".\" and workspace \"workspace\"–did you run plan?", err)
+}
+
+func TestPolicyCheckStepRunner_ShowErr(t *testing.T) {
+	tmpDir, cleanup := TempDir(t)
+	defer cleanup()
+	Ok(t, ioutil.WriteFile(filepath.Join(tmpDir, "default.tfplan"), nil, 0600))
+
+	RegisterMockTestingT(t)
+	terraform := mocks.NewMockClient()
+	When(terraform.RunCommandWithVersion(matchers2.AnyContextContext(), matchers.AnyPtrToLoggingSimpleLogger(), AnyString(), AnyStringSlice(), matchers2.AnyPtrToGoVersionVersion(), AnyString())).
+		ThenReturn("", errors.New("unknown flag: -json"))
+	r := runtime.PolicyCheckStepRunner{TerraformExecutor: terraform}
+	_, err := r.Run(models.ProjectCommandContext{
+		Log:        logging.NewNoopLogger(),
+		RepoRelDir: ".",
+		Workspace:  "default",
+		PolicySets: []valid.PolicySet{{Name: "pass", Path: "/policies/pass"}},
+	}, nil, tmpDir)
+	ErrEquals(t, "converting plan to json (policy checks need Terraform 0.12 or later): unknown flag: -json", err)
+}
+
+func TestPolicyCheckStepRunner_Run(t *testing.T) {
+	cases := []struct {
+		description string
+		policySets  []valid.PolicySet
+		expOut      string
+		expErr      string
+	}{
+		{
+			description: "passing",
+			policySets:  []valid.PolicySet{{Name: "pass", Path: "/policies/pass"}},
+			expOut:      "policy set: pass\ntest --no-color --policy /policies/pass --all-namespaces JSON",
+		},
+		{
+			description: "one failing",
+			policySets: []valid.PolicySet{
+				{Name: "pass", Path: "/policies/pass"},
+				{Name: "fail", Path: "/policies/fail"},
+			},
+			expOut: "policy set: pass\ntest --no-color --policy /policies/pass --all-namespaces JSON\n\n" +
+				"policy set: fail\ntest --no-color --policy /policies/fail --all-namespaces JSON",
+			expErr: "policy set(s) failed: fail",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			tmpDir, cleanup := TempDir(t)
+			defer cleanup()
+			planPath := filepath.Join(tmpDir, "default.tfplan")
+			Ok(t, ioutil.WriteFile(planPath, nil, 0600))
+			conftest := filepath.Join(tmpDir, "conftest")
+			Ok(t, ioutil.WriteFile(conftest, []byte(fakeConftest), 0700))
+
+			RegisterMockTestingT(t)
+			terraform := mocks.NewMockClient()
+			When(terraform.RunCommandWithVersion(matchers2.AnyContextContext(), matchers.AnyPtrToLoggingSimpleLogger(), AnyString(), AnyStringSlice(), matchers2.AnyPtrToGoVersionVersion(), AnyString())).
+				ThenReturn(`{"format_version":"0.1"}`, nil)
+			r := runtime.PolicyCheckStepRunner{
+				TerraformExecutor: terraform,
+				ConftestCommand:   conftest,
+			}
+			logger := logging.NewNoopLogger()
+			out, err := r.Run(models.ProjectCommandContext{
+				Log:        logger,
+				RepoRelDir: ".",
+				Workspace:  "default",
+				PolicySets: c.policySets,
+			}, []string{"--all-namespaces"}, tmpDir)
+			if c.expErr != "" {
+				ErrEquals(t, c.expErr, err)
+			} else {
+				Ok(t, err)
+			}
+
+			jsonPath := filepath.Join(tmpDir, "default.tfplan.json")
+			Equals(t, strings.Replace(c.expOut, "JSON", jsonPath, -1), out)
+			terraform.VerifyWasCalledOnce().RunCommandWithVersion(nil, logger, tmpDir, []string{"show", "-json", fmt.Sprintf("%q", planPath)}, nil, "default")
+			planJSON, err := ioutil.ReadFile(jsonPath)
+			Ok(t, err)
+			Equals(t, `{"format_version":"0.1"}`, string(planJSON))
+		})
+	}
+}
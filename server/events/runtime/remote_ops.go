@@ -0,0 +1,227 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/terraform"
+)
+
+// remoteOpsHeader starts the plan files we write for projects that use remote
+// operations. The remote backend can't save plans so the file holds the plan's
+// output instead, which apply checks the new remote run's plan against.
+const remoteOpsHeader = "Atlantis: this plan was created by remote ops\n"
+
+// remoteApplyPrompt is the start of the line terraform asks for confirmation
+// of a remote apply with once the run's plan is done.
+const remoteApplyPrompt = "Do you want to perform these actions in workspace"
+
+// remotePolicyOverridePrompt is the start of the line terraform asks whether
+// to override soft failed policy checks with.
+const remotePolicyOverridePrompt = "Do you want to override the soft failed policy check?"
+
+var (
+	// remoteBackendRegex matches the remote backend and the cloud block that
+	// replaces it in Terraform 1.1.
+	remoteBackendRegex = regexp.MustCompile(`(?m)^\s*(backend\s+"remote"|cloud)\s*\{`)
+	// remoteRunURLRegex matches the URL of a remote run in terraform's output,
+	// ex. https://app.terraform.io/app/org/workspace/runs/run-abc123, and
+	// captures its hostname, organization, workspace and ID.
+	remoteRunURLRegex = regexp.MustCompile(`https://([^/\s]+)/app/([^/\s]+)/([^/\s]+)/runs/(run-[[:alnum:]]+)`)
+)
+
+// RemoteRun is a Terraform Cloud or Enterprise run whose URL is in
+// terraform's output.
+type RemoteRun struct {
+	URL          string
+	Hostname     string
+	Organization string
+	Workspace    string
+	ID           string
+}
+
+// FindRemoteRun returns the first remote run whose URL is in output, or nil if
+// there isn't one.
+func FindRemoteRun(output string) *RemoteRun {
+	m := remoteRunURLRegex.FindStringSubmatch(output)
+	if m == nil {
+		return nil
+	}
+	return &RemoteRun{URL: m[0], Hostname: m[1], Organization: m[2], Workspace: m[3], ID: m[4]}
+}
+
+// RemoteRunClient confirms and discards the remote runs of projects that use
+// Terraform Cloud or Enterprise remote operations.
+type RemoteRunClient interface {
+	ReadRun(hostname string, runID string) (*terraform.CloudRun, error)
+	ApplyRun(hostname string, runID string, comment string) error
+	DiscardRun(hostname string, runID string, comment string) error
+}
+
+// usesRemoteOps returns true if the terraform files in path configure the
+// remote backend, which runs plans and applies in Terraform Cloud or
+// Enterprise.
+func usesRemoteOps(path string) bool {
+	files, err := filepath.Glob(filepath.Join(path, "*.tf"))
+	if err != nil {
+		return false
+	}
+	for _, f := range files {
+		contents, err := ioutil.ReadFile(f) // nolint: gosec
+		if err == nil && remoteBackendRegex.Match(contents) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRemotePlan returns true if planContents is a plan file written for a
+// project that uses remote operations.
+func isRemotePlan(planContents []byte) bool {
+	return strings.HasPrefix(string(planContents), remoteOpsHeader)
+}
+
+// remotePlanSection returns the part of a remote run's output that lists the
+// changes, from "Terraform will perform the following actions" through the
+// "Plan:" line, with each line trimmed. It returns "" if output doesn't have a
+// plan.
+func remotePlanSection(output string) string {
+	var section []string
+	in := false
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "Terraform will perform the following actions") {
+			in = true
+		}
+		if !in {
+			continue
+		}
+		section = append(section, line)
+		if strings.HasPrefix(line, "Plan:") {
+			return strings.Join(section, "\n")
+		}
+	}
+	return ""
+}
+
+// runRemotePlan runs `terraform plan` for a project that uses remote
+// operations. The plan runs in Terraform Cloud, which streams its logs to
+// terraform's output. The remote backend can't save plans, and rejects -var
+// flags, so we plan without them and save the output as the plan for apply to
+// check the run it starts against.
+func (p *PlanStepRunner) runRemotePlan(ctx models.ProjectCommandContext, extraArgs []string, path string, tfVersion *version.Version) (string, error) {
+	planCmd := p.flatten([][]string{
+		{"plan", "-input=false", "-refresh", "-no-color"},
+		destroyArgs(ctx),
+		targetArgs(ctx),
+		extraArgs,
+		ctx.CommentArgs,
+	})
+	output, err := p.TerraformExecutor.RunCommandWithVersion(ctx.CancelCtx, ctx.Log, filepath.Clean(path), planCmd, tfVersion, ctx.Workspace)
+	if err != nil {
+		return output, err
+	}
+	planFile := filepath.Join(path, GetPlanFilename(ctx.Workspace, ctx.ProjectConfig))
+	if err := ioutil.WriteFile(planFile, []byte(remoteOpsHeader+output), 0600); err != nil {
+		return output, errors.Wrap(err, "saving remote plan")
+	}
+	formatted := p.fmtPlanOutput(output)
+	// Formatting removes the run's URL along with the rest of the preamble.
+	if run := FindRemoteRun(output); run != nil {
+		formatted = fmt.Sprintf("Remote run: %s\n\n%s", run.URL, strings.TrimLeft(formatted, "\n"))
+	}
+	return formatted, nil
+}
+
+// runRemoteApply runs `terraform apply` for a project that uses remote
+// operations. Terraform starts a new run in Terraform Cloud and waits for us
+// to confirm it once it's planned. We only confirm it, through the API so the
+// run records why, if its plan is the same as the plan in planContents that
+// was reviewed in the pull request, and otherwise discard it.
+func (a *ApplyStepRunner) runRemoteApply(ctx models.ProjectCommandContext, extraArgs []string, path string, planContents []byte, tfVersion *version.Version) (string, error) {
+	if a.RemoteRuns == nil {
+		return "", errors.New("applying a plan made with remote operations needs --tfe-token to confirm the run in Terraform Cloud")
+	}
+	expPlan := remotePlanSection(string(planContents))
+
+	// Terraform waits for our answer on stdin, which we keep open until it
+	// exits since it treats EOF as an error.
+	stdinR, stdinW := io.Pipe()
+	defer stdinW.Close() // nolint: errcheck
+	answer := func(s string) {
+		go stdinW.Write([]byte(s + "\n")) // nolint: errcheck
+	}
+
+	var lines []string
+	var hostname, runID string
+	var confirmErr error
+	onLine := func(line string) {
+		lines = append(lines, line)
+		if run := FindRemoteRun(line); run != nil && runID == "" {
+			hostname, runID = run.Hostname, run.ID
+		}
+		switch trimmed := strings.TrimSpace(line); {
+		case strings.HasPrefix(trimmed, remotePolicyOverridePrompt):
+			confirmErr = fmt.Errorf("run %s soft failed its policy checks; override them in Terraform Cloud", runID)
+			answer("no")
+		case strings.HasPrefix(trimmed, remoteApplyPrompt):
+			if confirmErr = a.confirmRemoteRun(ctx, hostname, runID, expPlan, strings.Join(lines, "\n")); confirmErr != nil {
+				// No makes terraform discard the run if we couldn't.
+				answer("no")
+			}
+		}
+	}
+	tfCtx := ctx.CancelCtx
+	if tfCtx == nil {
+		tfCtx = context.Background()
+	}
+	if prev := terraform.OutputFunc(tfCtx); prev != nil {
+		next := onLine
+		onLine = func(line string) {
+			prev(line)
+			next(line)
+		}
+	}
+	tfCtx = terraform.WithStdin(terraform.WithOutputFunc(tfCtx, onLine), stdinR)
+
+	applyCmd := append(append([]string{"apply", "-input=false", "-no-color"}, extraArgs...), ctx.CommentArgs...)
+	out, err := a.TerraformExecutor.RunCommandWithVersion(tfCtx, ctx.Log, path, applyCmd, tfVersion, ctx.Workspace)
+	if confirmErr != nil {
+		return out, confirmErr
+	}
+	return out, err
+}
+
+// confirmRemoteRun applies the remote run runID if its plan, at the end of
+// output, is expPlan and it's waiting to be confirmed. Otherwise it discards
+// the run if it can and returns why.
+func (a *ApplyStepRunner) confirmRemoteRun(ctx models.ProjectCommandContext, hostname string, runID string, expPlan string, output string) error {
+	if runID == "" {
+		return errors.New("unable to find the remote run's URL in terraform's output")
+	}
+	run, err := a.RemoteRuns.ReadRun(hostname, runID)
+	if err != nil {
+		return err
+	}
+	if !run.IsConfirmable {
+		return fmt.Errorf("run %s is %s so it can't be confirmed", runID, run.Status)
+	}
+	if plan := remotePlanSection(output); expPlan == "" || plan != expPlan {
+		ctx.Log.Warn("plan of remote run %s differs from the plan in the pull request, discarding it", runID)
+		discardErr := a.RemoteRuns.DiscardRun(hostname, runID, fmt.Sprintf("Discarded by Atlantis since the plan differs from the plan in %s#%d.", ctx.BaseRepo.FullName, ctx.Pull.Num))
+		if discardErr != nil {
+			ctx.Log.Warn("unable to discard run %s: %s", runID, discardErr)
+		}
+		return fmt.Errorf("plan of run %s differs from the plan in this pull request, which must have changed since it was planned; plan again", runID)
+	}
+	ctx.Log.Info("confirming remote run %s", runID)
+	return a.RemoteRuns.ApplyRun(hostname, runID, fmt.Sprintf("Applied by Atlantis for %s#%d by %s.", ctx.BaseRepo.FullName, ctx.Pull.Num, ctx.User.Username))
+}
@@ -0,0 +1,214 @@
+package runtime_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-version"
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	"github.com/runatlantis/atlantis/server/events/terraform"
+	"github.com/runatlantis/atlantis/server/events/terraform/mocks"
+	matchers2 "github.com/runatlantis/atlantis/server/events/terraform/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+const remotePlanOutput = `Running plan in the remote backend. Output will stream here. Pressing Ctrl-C
+will stop streaming the logs, but will not stop the plan running remotely.
+
+Preparing the remote plan...
+
+To view this run in a browser, visit:
+https://%s/app/org/prod/runs/%s
+
+Waiting for the plan to start...
+
+Terraform v0.12.6
+
+Configuring remote state backend...
+Initializing Terraform configuration...
+Refreshing Terraform state in-memory prior to plan...
+
+------------------------------------------------------------------------
+
+An execution plan has been generated and is shown below.
+Resource actions are indicated with the following symbols:
+  + create
+
+Terraform will perform the following actions:
+
+  # null_resource.%s will be created
+  + resource "null_resource" "%s" {
+      + id = (known after apply)
+    }
+
+Plan: 1 to add, 0 to change, 0 to destroy.
+`
+
+func remoteOutput(host string, runID string, resource string) string {
+	return strings.NewReplacer("%s/app", host+"/app", "runs/%s", "runs/"+runID, "null_resource.%s", "null_resource."+resource, `"null_resource" "%s"`, `"null_resource" "`+resource+`"`).Replace(remotePlanOutput)
+}
+
+func writeRemoteBackend(t *testing.T, dir string) {
+	Ok(t, ioutil.WriteFile(filepath.Join(dir, "main.tf"), []byte(`terraform {
+  backend "remote" {
+    organization = "org"
+    workspaces {
+      name = "prod"
+    }
+  }
+}`), 0600))
+}
+
+// Projects with the remote backend are planned without -out and -var and
+// their plan output is saved as the plan.
+func TestPlanStepRunner_RemoteOps(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmpDir, cleanup := TempDir(t)
+	defer cleanup()
+	writeRemoteBackend(t, tmpDir)
+
+	tfVersion, _ := version.NewVersion("0.11.14")
+	terraform := mocks.NewMockClient()
+	s := runtime.PlanStepRunner{TerraformExecutor: terraform, DefaultTFVersion: tfVersion}
+	output := remoteOutput("app.terraform.io", "run-abc123", "a")
+	When(terraform.RunCommandWithVersion(matchers2.AnyContextContext(), matchers.AnyPtrToLoggingSimpleLogger(), AnyString(), AnyStringSlice(), matchers2.AnyPtrToGoVersionVersion(), AnyString())).
+		ThenReturn(output, nil)
+
+	out, err := s.Run(models.ProjectCommandContext{
+		Workspace:   "default",
+		RepoRelDir:  ".",
+		CommentArgs: []string{"-lock=false"},
+		User:        models.User{Username: "user"},
+	}, []string{"extra"}, tmpDir)
+	Ok(t, err)
+	terraform.VerifyWasCalledOnce().RunCommandWithVersion(nil, nil, tmpDir, []string{"plan", "-input=false", "-refresh", "-no-color", "extra", "-lock=false"}, tfVersion, "default")
+	Assert(t, strings.HasPrefix(out, "Remote run: https://app.terraform.io/app/org/prod/runs/run-abc123\n\nAn execution plan"), "got output %q", out)
+
+	planContents, err := ioutil.ReadFile(filepath.Join(tmpDir, "default.tfplan"))
+	Ok(t, err)
+	Assert(t, strings.HasSuffix(string(planContents), output), "exp plan file to hold the plan output")
+}
+
+// fakeRunsAPI serves the Terraform Cloud runs API for runID, recording the
+// actions taken on it.
+func fakeRunsAPI(t *testing.T, runID string, status string, confirmable bool, actions *[]string) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Equals(t, "Bearer token", r.Header.Get("Authorization"))
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/api/v2/runs/"+runID:
+			w.Write([]byte(`{"data": {"id": "` + runID + `", "attributes": {"status": "` + status + `", "actions": {"is-confirmable": ` + map[bool]string{true: "true", false: "false"}[confirmable] + `, "is-discardable": true}}}}`)) // nolint: errcheck
+		case r.Method == "POST" && strings.HasPrefix(r.URL.Path, "/api/v2/runs/"+runID+"/actions/"):
+			var body map[string]string
+			Ok(t, json.NewDecoder(r.Body).Decode(&body))
+			*actions = append(*actions, strings.TrimPrefix(r.URL.Path, "/api/v2/runs/"+runID+"/actions/")+": "+body["comment"])
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			t.Errorf("got unexpected request %s %q", r.Method, r.URL.Path)
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+}
+
+func TestApplyStepRunner_RemoteOps(t *testing.T) {
+	cases := map[string]struct {
+		newResource string
+		status      string
+		confirmable bool
+		expActions  []string
+		expErr      string
+	}{
+		"same plan": {
+			newResource: "a",
+			status:      "planned",
+			confirmable: true,
+			expActions:  []string{"apply: Applied by Atlantis for owner/repo#1 by user."},
+		},
+		"plan changed": {
+			newResource: "b",
+			status:      "planned",
+			confirmable: true,
+			expActions:  []string{"discard: Discarded by Atlantis since the plan differs from the plan in owner/repo#1."},
+			expErr:      "plan of run run-abc123 differs from the plan in this pull request, which must have changed since it was planned; plan again",
+		},
+		"not confirmable": {
+			newResource: "a",
+			status:      "policy_checked",
+			expErr:      "run run-abc123 is policy_checked so it can't be confirmed",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			RegisterMockTestingT(t)
+			tmpDir, cleanup := TempDir(t)
+			defer cleanup()
+			var actions []string
+			api := fakeRunsAPI(t, "run-abc123", c.status, c.confirmable, &actions)
+			defer api.Close()
+			host := strings.TrimPrefix(api.URL, "https://")
+
+			planPath := filepath.Join(tmpDir, "default.tfplan")
+			Ok(t, ioutil.WriteFile(planPath, []byte("Atlantis: this plan was created by remote ops\n"+remoteOutput("app.terraform.io", "run-old", "a")), 0600))
+
+			tf := mocks.NewMockClient()
+			var streamed []string
+			ctx := terraform.WithOutputFunc(context.Background(), func(line string) { streamed = append(streamed, line) })
+			newOutput := strings.Replace(remoteOutput(host, "run-abc123", c.newResource), "Running plan", "Running apply", 1) +
+				"\nDo you want to perform these actions in workspace \"prod\"?\n  Terraform will perform the actions described above.\n"
+			When(tf.RunCommandWithVersion(matchers2.AnyContextContext(), matchers.AnyPtrToLoggingSimpleLogger(), AnyString(), AnyStringSlice(), matchers2.AnyPtrToGoVersionVersion(), AnyString())).
+				Then(func(params []Param) ReturnValues {
+					onLine := terraform.OutputFunc(params[0].(context.Context))
+					for _, line := range strings.Split(newOutput, "\n") {
+						onLine(line)
+					}
+					return []ReturnValue{newOutput, nil}
+				})
+
+			s := runtime.ApplyStepRunner{
+				TerraformExecutor: tf,
+				RemoteRuns:        &terraform.CloudClient{Token: "token", HTTPClient: api.Client()},
+			}
+			out, err := s.Run(models.ProjectCommandContext{
+				CancelCtx:  ctx,
+				Log:        logging.NewNoopLogger(),
+				Workspace:  "default",
+				RepoRelDir: ".",
+				BaseRepo:   models.Repo{FullName: "owner/repo"},
+				Pull:       models.PullRequest{Num: 1},
+				User:       models.User{Username: "user"},
+			}, nil, tmpDir)
+			Equals(t, newOutput, out)
+			Equals(t, c.expActions, actions)
+			// The output is still streamed to the job's output.
+			Equals(t, strings.Split(newOutput, "\n"), streamed)
+			_, _, _, args, _, _ := tf.VerifyWasCalledOnce().RunCommandWithVersion(matchers2.AnyContextContext(), matchers.AnyPtrToLoggingSimpleLogger(), AnyString(), AnyStringSlice(), matchers2.AnyPtrToGoVersionVersion(), AnyString()).GetCapturedArguments()
+			Equals(t, []string{"apply", "-input=false", "-no-color"}, args)
+			if c.expErr != "" {
+				ErrEquals(t, c.expErr, err)
+				return
+			}
+			Ok(t, err)
+			_, err = os.Stat(planPath)
+			Assert(t, os.IsNotExist(err), "planfile should be deleted")
+		})
+	}
+}
+
+func TestApplyStepRunner_RemoteOpsNeedsToken(t *testing.T) {
+	tmpDir, cleanup := TempDir(t)
+	defer cleanup()
+	Ok(t, ioutil.WriteFile(filepath.Join(tmpDir, "default.tfplan"), []byte("Atlantis: this plan was created by remote ops\n"), 0600))
+	s := runtime.ApplyStepRunner{}
+	_, err := s.Run(models.ProjectCommandContext{Workspace: "default", RepoRelDir: "."}, nil, tmpDir)
+	ErrEquals(t, "applying a plan made with remote operations needs --tfe-token to confirm the run in Terraform Cloud", err)
+}
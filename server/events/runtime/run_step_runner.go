@@ -1,6 +1,7 @@
 package runtime
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"os/exec"
@@ -10,6 +11,7 @@ import (
 	"github.com/hashicorp/go-version"
 	"github.com/pkg/errors"
 	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/terraform"
 )
 
 // RunStepRunner runs custom commands.
@@ -24,10 +26,7 @@ func (r *RunStepRunner) Run(ctx models.ProjectCommandContext, command []string,
 
 	cmd := exec.Command("sh", "-c", strings.Join(command, " ")) // #nosec
 	cmd.Dir = path
-	tfVersion := r.DefaultTFVersion.String()
-	if ctx.ProjectConfig != nil && ctx.ProjectConfig.TerraformVersion != nil {
-		tfVersion = ctx.ProjectConfig.TerraformVersion.String()
-	}
+	tfVersion := GetTerraformVersion(ctx, r.DefaultTFVersion).String()
 	baseEnvVars := os.Environ()
 	customEnvVars := map[string]string{
 		"WORKSPACE":                  ctx.Workspace,
@@ -47,15 +46,19 @@ func (r *RunStepRunner) Run(ctx models.ProjectCommandContext, command []string,
 	for key, val := range customEnvVars {
 		finalEnvVars = append(finalEnvVars, fmt.Sprintf("%s=%s", key, val))
 	}
-	cmd.Env = finalEnvVars
-	out, err := cmd.CombinedOutput()
+	// The variables set by env steps come last so they take precedence.
+	cmd.Env = append(finalEnvVars, terraform.Env(ctx.CancelCtx)...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := terraform.RunCancellable(ctx.CancelCtx, cmd)
 
 	commandStr := strings.Join(command, " ")
 	if err != nil {
-		err = fmt.Errorf("%s: running %q in %q: \n%s", err, commandStr, path, out)
+		err = fmt.Errorf("%s: running %q in %q: \n%s", err, commandStr, path, out.String())
 		ctx.Log.Debug("error: %s", err)
-		return string(out), err
+		return out.String(), err
 	}
 	ctx.Log.Info("successfully ran %q in %q", commandStr, path)
-	return string(out), nil
+	return out.String(), nil
 }
@@ -1,12 +1,14 @@
 package runtime_test
 
 import (
+	"context"
 	"strings"
 	"testing"
 
 	"github.com/hashicorp/go-version"
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/events/runtime"
+	"github.com/runatlantis/atlantis/server/events/terraform"
 	"github.com/runatlantis/atlantis/server/events/yaml/valid"
 	"github.com/runatlantis/atlantis/server/logging"
 	. "github.com/runatlantis/atlantis/testing"
@@ -95,3 +97,21 @@ func TestRunStepRunner_Run(t *testing.T) {
 		})
 	}
 }
+
+func TestRunStepRunner_RunEnv(t *testing.T) {
+	t.Log("the variables set by env steps should take precedence")
+	tmpDir, cleanup := TempDir(t)
+	defer cleanup()
+	defaultVersion, _ := version.NewVersion("0.8")
+	r := runtime.RunStepRunner{
+		DefaultTFVersion: defaultVersion,
+	}
+	ctx := models.ProjectCommandContext{
+		Log:       logging.NewNoopLogger(),
+		Workspace: "default",
+		CancelCtx: terraform.WithEnv(context.Background(), []string{"FOO=bar", "WORKSPACE=overridden"}),
+	}
+	out, err := r.Run(ctx, []string{"echo", "$FOO", "$WORKSPACE"}, tmpDir)
+	Ok(t, err)
+	Equals(t, "bar overridden\n", out)
+}
@@ -3,16 +3,25 @@
 package runtime
 
 import (
+	"context"
 	"fmt"
 	"regexp"
+	"strings"
 
 	"github.com/hashicorp/go-version"
+	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/events/yaml/valid"
 	"github.com/runatlantis/atlantis/server/logging"
 )
 
 type TerraformExec interface {
-	RunCommandWithVersion(log *logging.SimpleLogger, path string, args []string, v *version.Version, workspace string) (string, error)
+	RunCommandWithVersion(ctx context.Context, log *logging.SimpleLogger, path string, args []string, v *version.Version, workspace string) (string, error)
+	RunCommandWithLogLevel(ctx context.Context, log *logging.SimpleLogger, path string, args []string, v *version.Version, workspace string, logLevel string, logPath string) (string, error)
+}
+
+// TerragruntExec runs terragrunt with version v of terraform.
+type TerragruntExec interface {
+	RunTerragruntCommand(ctx context.Context, log *logging.SimpleLogger, path string, args []string, v *version.Version, workspace string) (string, error)
 }
 
 // MustConstraint returns a constraint. It panics on error.
@@ -24,6 +33,19 @@ func MustConstraint(constraint string) version.Constraints {
 	return c
 }
 
+// GetTerraformVersion returns the terraform version to run ctx's project
+// with: the version resolved for it before its steps were run, else the
+// version in its config, else defaultVersion.
+func GetTerraformVersion(ctx models.ProjectCommandContext, defaultVersion *version.Version) *version.Version {
+	if ctx.TerraformVersion != nil {
+		return ctx.TerraformVersion
+	}
+	if ctx.ProjectConfig != nil && ctx.ProjectConfig.TerraformVersion != nil {
+		return ctx.ProjectConfig.TerraformVersion
+	}
+	return defaultVersion
+}
+
 // invalidFilenameChars matches chars that are invalid for linux and windows
 // filenames.
 // From https://www.oreilly.com/library/view/regular-expressions-cookbook/9781449327453/ch08s25.html
@@ -40,3 +62,50 @@ func GetPlanFilename(workspace string, maybeCfg *valid.Project) string {
 	}
 	return invalidFilenameChars.ReplaceAllLiteralString(unescapedFilename, "-")
 }
+
+// GetTFLogFilename returns the filename (not the path) of the terraform log
+// captured when running plan with TF_LOG set. It sits next to the plan file.
+func GetTFLogFilename(workspace string, maybeCfg *valid.Project) string {
+	return strings.TrimSuffix(GetPlanFilename(workspace, maybeCfg), ".tfplan") + ".tflog"
+}
+
+// GetPlanJSONFilename returns the filename (not the path) of the plan
+// converted to JSON by `terraform show -json` that policies are checked
+// against. It sits next to the plan file.
+func GetPlanJSONFilename(workspace string, maybeCfg *valid.Project) string {
+	return GetPlanFilename(workspace, maybeCfg) + ".json"
+}
+
+// GetPolicyCheckFailedFilename returns the filename (not the path) of the
+// file that marks that the plan failed its policy checks. While it exists the
+// plan can't be applied. It sits next to the plan file.
+func GetPolicyCheckFailedFilename(workspace string, maybeCfg *valid.Project) string {
+	return strings.TrimSuffix(GetPlanFilename(workspace, maybeCfg), ".tfplan") + ".policyfail"
+}
+
+// GetDestroyPlanFilename returns the filename (not the path) of the file
+// that marks that the plan destroys all the project's resources so applying
+// it has the destroy apply requirements. It sits next to the plan file.
+func GetDestroyPlanFilename(workspace string, maybeCfg *valid.Project) string {
+	return strings.TrimSuffix(GetPlanFilename(workspace, maybeCfg), ".tfplan") + ".destroy"
+}
+
+// targetArgs returns the -target args for the resource addresses ctx's plan
+// targets. The addresses are quoted since they can contain quotes, ex.
+// aws_instance.web["key"].
+func targetArgs(ctx models.ProjectCommandContext) []string {
+	var args []string
+	for _, target := range ctx.Targets {
+		args = append(args, fmt.Sprintf(`"-target=%s"`, strings.Replace(target, `"`, `\"`, -1)))
+	}
+	return args
+}
+
+// destroyArgs returns the args to make plan a destroy plan if ctx asked for
+// one.
+func destroyArgs(ctx models.ProjectCommandContext) []string {
+	if ctx.Destroy {
+		return []string{"-destroy"}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package runtime
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/hashicorp/go-version"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// ShowStepRunner converts the project's plan to JSON with
+// `terraform show -json` so it can be summarized.
+type ShowStepRunner struct {
+	TerraformExecutor TerraformExec
+	DefaultTFVersion  *version.Version
+}
+
+// Run returns the JSON of the plan in path. extraArgs are ignored since
+// show is only run by Atlantis. It needs Terraform 0.12 or later.
+func (s *ShowStepRunner) Run(ctx models.ProjectCommandContext, extraArgs []string, path string) (string, error) {
+	planPath := filepath.Join(path, GetPlanFilename(ctx.Workspace, ctx.ProjectConfig))
+	planContents, err := ioutil.ReadFile(planPath) // nolint: gosec
+	if err != nil {
+		return "", fmt.Errorf("no plan found at path %q and workspace %q", ctx.RepoRelDir, ctx.Workspace)
+	}
+	if isRemotePlan(planContents) {
+		return "", errors.New("plans made with remote operations can't be shown")
+	}
+	tfVersion := GetTerraformVersion(ctx, s.DefaultTFVersion)
+	out, err := s.TerraformExecutor.RunCommandWithVersion(ctx.CancelCtx, ctx.Log, path, []string{"show", "-json", fmt.Sprintf("%q", planPath)}, tfVersion, ctx.Workspace)
+	if err != nil {
+		return "", errors.Wrap(err, "converting plan to json")
+	}
+	return out, nil
+}
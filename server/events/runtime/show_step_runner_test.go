@@ -0,0 +1,58 @@
+package runtime_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/go-version"
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	"github.com/runatlantis/atlantis/server/events/terraform/mocks"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestShowStepRunner_Run(t *testing.T) {
+	tmpDir, cleanup := TempDir(t)
+	defer cleanup()
+	planPath := filepath.Join(tmpDir, "staging.tfplan")
+	Ok(t, ioutil.WriteFile(planPath, nil, 0600))
+
+	RegisterMockTestingT(t)
+	terraform := mocks.NewMockClient()
+	tfVersion, _ := version.NewVersion("0.12.0")
+	logger := logging.NewNoopLogger()
+	s := runtime.ShowStepRunner{
+		TerraformExecutor: terraform,
+		DefaultTFVersion:  tfVersion,
+	}
+	When(terraform.RunCommandWithVersion(nil, logger, tmpDir, []string{"show", "-json", fmt.Sprintf("%q", planPath)}, tfVersion, "staging")).
+		ThenReturn(`{"format_version": "0.1"}`, nil)
+
+	output, err := s.Run(models.ProjectCommandContext{
+		Log:        logger,
+		Workspace:  "staging",
+		RepoRelDir: ".",
+	}, nil, tmpDir)
+	Ok(t, err)
+	Equals(t, `{"format_version": "0.1"}`, output)
+}
+
+func TestShowStepRunner_RunNoPlan(t *testing.T) {
+	tmpDir, cleanup := TempDir(t)
+	defer cleanup()
+
+	RegisterMockTestingT(t)
+	s := runtime.ShowStepRunner{
+		TerraformExecutor: mocks.NewMockClient(),
+	}
+	_, err := s.Run(models.ProjectCommandContext{
+		Log:        logging.NewNoopLogger(),
+		Workspace:  "default",
+		RepoRelDir: "dir",
+	}, nil, tmpDir)
+	ErrEquals(t, `no plan found at path "dir" and workspace "default"`, err)
+}
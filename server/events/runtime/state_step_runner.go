@@ -0,0 +1,47 @@
+package runtime
+
+import (
+	"path/filepath"
+
+	"github.com/hashicorp/go-version"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// StateStepRunner runs `terraform state rm` and `terraform state mv`.
+type StateStepRunner struct {
+	TerraformExecutor TerraformExec
+	DefaultTFVersion  *version.Version
+}
+
+// Run runs the ctx.StateSubcommand on ctx.StateAddresses in the workspace's
+// state. If it succeeds, the workspace's plan is deleted since it was
+// generated against the old state.
+func (s *StateStepRunner) Run(ctx models.ProjectCommandContext, extraArgs []string, path string) (string, error) {
+	if err := validateCommentArgs(ctx); err != nil {
+		return "", err
+	}
+	tfVersion := GetTerraformVersion(ctx, s.DefaultTFVersion)
+
+	planner := &PlanStepRunner{TerraformExecutor: s.TerraformExecutor, DefaultTFVersion: s.DefaultTFVersion}
+	if err := planner.switchWorkspace(ctx, path, tfVersion); err != nil {
+		return "", err
+	}
+	// Unlike import, the state subcommands don't read the configuration so
+	// they don't take any vars.
+	var addresses []string
+	for _, addr := range ctx.StateAddresses {
+		addresses = append(addresses, shellQuote(addr))
+	}
+	stateCmd := planner.flatten([][]string{
+		{"state", ctx.StateSubcommand},
+		extraArgs,
+		ctx.CommentArgs,
+		addresses,
+	})
+	out, err := s.TerraformExecutor.RunCommandWithVersion(ctx.CancelCtx, ctx.Log, filepath.Clean(path), stateCmd, tfVersion, ctx.Workspace)
+	if err != nil {
+		return out, err
+	}
+	deleteStalePlan(ctx, path)
+	return out, nil
+}
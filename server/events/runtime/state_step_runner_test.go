@@ -0,0 +1,101 @@
+package runtime_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/go-version"
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	"github.com/runatlantis/atlantis/server/events/terraform/mocks"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestStateStepRunner_Run(t *testing.T) {
+	cases := []struct {
+		subcommand string
+		addresses  []string
+		expArgs    []string
+	}{
+		{
+			"rm",
+			[]string{`aws_instance.web["a"]`, "aws_instance.app"},
+			[]string{"state", "rm", "extra", "args", "-lock=false", "-parallelism=5", `'aws_instance.web["a"]'`, "'aws_instance.app'"},
+		},
+		{
+			"mv",
+			[]string{"aws_instance.web", "module.web.aws_instance.web"},
+			[]string{"state", "mv", "extra", "args", "-lock=false", "-parallelism=5", "'aws_instance.web'", "'module.web.aws_instance.web'"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.subcommand, func(t *testing.T) {
+			tmpDir, cleanup := TempDir(t)
+			defer cleanup()
+			planPath := filepath.Join(tmpDir, "workspace.tfplan")
+			Ok(t, ioutil.WriteFile(planPath, nil, 0600))
+
+			RegisterMockTestingT(t)
+			terraform := mocks.NewMockClient()
+			tfVersion, _ := version.NewVersion("0.12.0")
+			logger := logging.NewNoopLogger()
+			s := runtime.StateStepRunner{
+				TerraformExecutor: terraform,
+				DefaultTFVersion:  tfVersion,
+			}
+			When(terraform.RunCommandWithVersion(nil, logger, tmpDir, []string{"workspace", "show"}, tfVersion, "workspace")).ThenReturn("workspace\n", nil)
+			When(terraform.RunCommandWithVersion(nil, logger, tmpDir, c.expArgs, tfVersion, "workspace")).ThenReturn("Successfully changed state", nil)
+
+			output, err := s.Run(models.ProjectCommandContext{
+				Log:             logger,
+				Workspace:       "workspace",
+				RepoRelDir:      ".",
+				CommentArgs:     []string{"-lock=false", "-parallelism=5"},
+				StateSubcommand: c.subcommand,
+				StateAddresses:  c.addresses,
+			}, []string{"extra", "args"}, tmpDir)
+			Ok(t, err)
+			Equals(t, "Successfully changed state", output)
+			_, err = os.Stat(planPath)
+			Assert(t, os.IsNotExist(err), "planfile should be deleted")
+		})
+	}
+}
+
+// If the state command fails, the state hasn't changed so the plan should be
+// kept.
+func TestStateStepRunner_RunErr(t *testing.T) {
+	tmpDir, cleanup := TempDir(t)
+	defer cleanup()
+	planPath := filepath.Join(tmpDir, "default.tfplan")
+	Ok(t, ioutil.WriteFile(planPath, nil, 0600))
+
+	RegisterMockTestingT(t)
+	terraform := mocks.NewMockClient()
+	tfVersion, _ := version.NewVersion("0.12.0")
+	logger := logging.NewNoopLogger()
+	s := runtime.StateStepRunner{
+		TerraformExecutor: terraform,
+		DefaultTFVersion:  tfVersion,
+	}
+	When(terraform.RunCommandWithVersion(nil, logger, tmpDir, []string{"workspace", "show"}, tfVersion, "default")).ThenReturn("default\n", nil)
+	When(terraform.RunCommandWithVersion(nil, logger, tmpDir, []string{"state", "rm", "'aws_instance.web'"}, tfVersion, "default")).
+		ThenReturn("Error: Invalid target address", errors.New("exit status 1"))
+
+	output, err := s.Run(models.ProjectCommandContext{
+		Log:             logger,
+		Workspace:       "default",
+		RepoRelDir:      ".",
+		StateSubcommand: "rm",
+		StateAddresses:  []string{"aws_instance.web"},
+	}, nil, tmpDir)
+	ErrEquals(t, "exit status 1", err)
+	Equals(t, "Error: Invalid target address", output)
+	_, err = os.Stat(planPath)
+	Ok(t, err)
+}
@@ -0,0 +1,80 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/hashicorp/go-version"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// terragruntLogRegex matches the lines terragrunt logs about what it's doing,
+// ex. "[terragrunt] 2020/06/01 12:00:00 Running command: terraform plan" or
+// "time=2021-01-01T12:00:00Z level=info msg=Downloading...", so they can be
+// dropped from the output we comment.
+var terragruntLogRegex = regexp.MustCompile(`(?m)^(\[terragrunt\] |time=\S+ level=\S+ ).*(\n|$)`)
+
+// TerragruntStepRunner runs `terragrunt` with the args of a terragrunt step.
+// When a terragrunt config sets a source, terragrunt runs terraform in a copy
+// of the module under .terragrunt-cache rather than in the project dir so
+// plans are always written to, and applied from, the absolute path of the
+// project's planfile.
+type TerragruntStepRunner struct {
+	TerragruntExecutor TerragruntExec
+	DefaultTFVersion   *version.Version
+}
+
+func (t *TerragruntStepRunner) Run(ctx models.ProjectCommandContext, args []string, path string) (string, error) {
+	if err := validateCommentArgs(ctx); err != nil {
+		return "", err
+	}
+	tfVersion := GetTerraformVersion(ctx, t.DefaultTFVersion)
+	planFile := filepath.Join(path, GetPlanFilename(ctx.Workspace, ctx.ProjectConfig))
+
+	var subcommand string
+	var rest []string
+	if len(args) > 0 {
+		subcommand, rest = args[0], args[1:]
+	}
+	// NOTE: we need to quote the plan filename because Bitbucket Server can
+	// have spaces in its repo owner names.
+	var cmd []string
+	switch subcommand {
+	case "init":
+		cmd = append([]string{"init", "-input=false", "-no-color"}, rest...)
+	case "plan":
+		cmd = append(append(append(append([]string{"plan", "-input=false", "-refresh", "-no-color", "-out", fmt.Sprintf("%q", planFile)}, destroyArgs(ctx)...), targetArgs(ctx)...), rest...), ctx.CommentArgs...)
+	case "apply":
+		if (&ApplyStepRunner{}).hasTargetFlag(ctx, rest) {
+			return "", errors.New("cannot run apply with -target because we are applying an already generated plan. Instead, run plan with -target")
+		}
+		if stat, err := os.Stat(planFile); err != nil || stat.IsDir() {
+			return "", fmt.Errorf("no plan found at path %q and workspace %q–did you run plan?", ctx.RepoRelDir, ctx.Workspace)
+		}
+		cmd = append(append(append([]string{"apply", "-input=false", "-no-color"}, rest...), ctx.CommentArgs...), fmt.Sprintf("%q", planFile))
+	default:
+		cmd = args
+	}
+
+	out, err := t.TerragruntExecutor.RunTerragruntCommand(ctx.CancelCtx, ctx.Log, path, cmd, tfVersion, ctx.Workspace)
+	if err != nil {
+		return out, err
+	}
+	out = terragruntLogRegex.ReplaceAllString(out, "")
+	switch subcommand {
+	case "init":
+		// Like terraform init, the output is only useful if there was an error.
+		return "", nil
+	case "plan":
+		return (&PlanStepRunner{}).fmtPlanOutput(out), nil
+	case "apply":
+		ctx.Log.Info("apply successful, deleting planfile")
+		if err := os.Remove(planFile); err != nil {
+			ctx.Log.Warn("failed to delete planfile after successful apply: %s", err)
+		}
+	}
+	return out, nil
+}
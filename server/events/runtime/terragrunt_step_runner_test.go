@@ -0,0 +1,101 @@
+package runtime_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/go-version"
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	"github.com/runatlantis/atlantis/server/events/terraform/mocks"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestTerragruntStepRunner_Run(t *testing.T) {
+	tmpDir, cleanup := TempDir(t)
+	defer cleanup()
+	planPath := filepath.Join(tmpDir, "default.tfplan")
+	quotedPlanPath := `"` + planPath + `"`
+
+	cases := []struct {
+		description string
+		args        []string
+		planExists  bool
+		expCmd      []string
+		tgOut       string
+		expOut      string
+		expErr      string
+	}{
+		{
+			description: "init only shows its output on error",
+			args:        []string{"init", "-upgrade"},
+			expCmd:      []string{"init", "-input=false", "-no-color", "-upgrade"},
+			tgOut:       "[terragrunt] 2020/06/01 12:00:00 Running command: terraform init\nTerraform has been successfully initialized!",
+			expOut:      "",
+		},
+		{
+			description: "plan writes to the absolute planfile path and drops terragrunt's logs",
+			args:        []string{"plan", "-var", "foo=bar"},
+			expCmd:      []string{"plan", "-input=false", "-refresh", "-no-color", "-out", quotedPlanPath, "-var", "foo=bar", "-lock=false", "-parallelism=5"},
+			tgOut:       "[terragrunt] 2020/06/01 12:00:00 Running command: terraform plan\ntime=2021-01-01T12:00:00Z level=info msg=Downloading\nRefreshing state...\n------------------------------------------------------------------------\n  + null_resource.test\nPlan: 1 to add, 0 to change, 0 to destroy.",
+			expOut:      "+ null_resource.test\nPlan: 1 to add, 0 to change, 0 to destroy.",
+		},
+		{
+			description: "apply applies the planfile",
+			args:        []string{"apply"},
+			planExists:  true,
+			expCmd:      []string{"apply", "-input=false", "-no-color", "-lock=false", "-parallelism=5", quotedPlanPath},
+			tgOut:       "[terragrunt] 2020/06/01 12:00:00 Running command: terraform apply\nApply complete!",
+			expOut:      "Apply complete!",
+		},
+		{
+			description: "apply without a plan",
+			args:        []string{"apply"},
+			expErr:      `no plan found at path "." and workspace "default"–did you run plan?`,
+		},
+		{
+			description: "other commands are run as is",
+			args:        []string{"validate-inputs"},
+			expCmd:      []string{"validate-inputs"},
+			tgOut:       "all inputs are used",
+			expOut:      "all inputs are used",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			if c.planExists {
+				Ok(t, ioutil.WriteFile(planPath, nil, 0600))
+			}
+			RegisterMockTestingT(t)
+			terraform := mocks.NewMockClient()
+			tfVersion, _ := version.NewVersion("0.12.0")
+			logger := logging.NewNoopLogger()
+			s := runtime.TerragruntStepRunner{
+				TerragruntExecutor: terraform,
+				DefaultTFVersion:   tfVersion,
+			}
+			When(terraform.RunTerragruntCommand(nil, logger, tmpDir, c.expCmd, tfVersion, "default")).ThenReturn(c.tgOut, nil)
+
+			out, err := s.Run(models.ProjectCommandContext{
+				Log:         logger,
+				Workspace:   "default",
+				RepoRelDir:  ".",
+				CommentArgs: []string{"-lock=false", "-parallelism=5"},
+			}, c.args, tmpDir)
+			if c.expErr != "" {
+				ErrEquals(t, c.expErr, err)
+				return
+			}
+			Ok(t, err)
+			Equals(t, c.expOut, out)
+			if c.planExists {
+				_, err = os.Stat(planPath)
+				Assert(t, os.IsNotExist(err), "planfile should be deleted")
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package runtime
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/terraform"
+)
+
+// WorkflowHookRunner runs pre and post-workflow hooks.
+type WorkflowHookRunner struct{}
+
+// Run runs command in path, the root of the pull request's clone, with
+// environment variables describing the pull request and the command the hook
+// is run around.
+func (w *WorkflowHookRunner) Run(ctx models.WorkflowHookCommandContext, command string, path string) (string, error) {
+	cmd := exec.Command("sh", "-c", command) // #nosec
+	cmd.Dir = path
+	customEnvVars := map[string]string{
+		"DIR":                path,
+		"BASE_REPO_NAME":     ctx.BaseRepo.Name,
+		"BASE_REPO_OWNER":    ctx.BaseRepo.Owner,
+		"HEAD_REPO_NAME":     ctx.HeadRepo.Name,
+		"HEAD_REPO_OWNER":    ctx.HeadRepo.Owner,
+		"HEAD_BRANCH_NAME":   ctx.Pull.Branch,
+		"HEAD_COMMIT":        ctx.Pull.HeadCommit,
+		"PULL_NUM":           fmt.Sprintf("%d", ctx.Pull.Num),
+		"PULL_URL":           ctx.Pull.URL,
+		"PULL_AUTHOR":        ctx.Pull.Author,
+		"USER_NAME":          ctx.User.Username,
+		"COMMAND_NAME":       ctx.CommandName,
+		"COMMAND_HAS_ERRORS": strconv.FormatBool(ctx.CommandHasErrors),
+	}
+	finalEnvVars := os.Environ()
+	for key, val := range customEnvVars {
+		finalEnvVars = append(finalEnvVars, fmt.Sprintf("%s=%s", key, val))
+	}
+	cmd.Env = finalEnvVars
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := terraform.RunCancellable(ctx.CancelCtx, cmd); err != nil {
+		err = fmt.Errorf("%s: running %q in %q: \n%s", err, command, path, out.String())
+		ctx.Log.Debug("error: %s", err)
+		return out.String(), err
+	}
+	ctx.Log.Info("successfully ran workflow hook %q in %q", command, path)
+	return out.String(), nil
+}
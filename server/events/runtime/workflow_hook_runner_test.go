@@ -0,0 +1,97 @@
+package runtime_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/runtime"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestWorkflowHookRunner_Run(t *testing.T) {
+	cases := []struct {
+		Command string
+		ExpOut  string
+		ExpErr  string
+	}{
+		{
+			Command: "echo hi",
+			ExpOut:  "hi\n",
+		},
+		{
+			Command: "echo hi >> file && cat file",
+			ExpOut:  "hi\n",
+		},
+		{
+			Command: "lkjlkj",
+			ExpErr:  "exit status 127: running \"lkjlkj\" in",
+		},
+		{
+			Command: "echo dir=$DIR command=$COMMAND_NAME has_errors=$COMMAND_HAS_ERRORS user=$USER_NAME",
+			ExpOut:  "dir=$DIR command=plan has_errors=true user=commenter\n",
+		},
+		{
+			Command: "echo base_repo_name=$BASE_REPO_NAME base_repo_owner=$BASE_REPO_OWNER head_repo_name=$HEAD_REPO_NAME head_repo_owner=$HEAD_REPO_OWNER head_branch_name=$HEAD_BRANCH_NAME head_commit=$HEAD_COMMIT pull_num=$PULL_NUM pull_url=$PULL_URL pull_author=$PULL_AUTHOR",
+			ExpOut:  "base_repo_name=basename base_repo_owner=baseowner head_repo_name=headname head_repo_owner=headowner head_branch_name=add-feat head_commit=abc123 pull_num=2 pull_url=https://github.com/baseowner/basename/pull/2 pull_author=acme\n",
+		},
+	}
+
+	r := runtime.WorkflowHookRunner{}
+	ctx := models.WorkflowHookCommandContext{
+		BaseRepo: models.Repo{
+			Name:  "basename",
+			Owner: "baseowner",
+		},
+		HeadRepo: models.Repo{
+			Name:  "headname",
+			Owner: "headowner",
+		},
+		Pull: models.PullRequest{
+			Num:        2,
+			HeadCommit: "abc123",
+			URL:        "https://github.com/baseowner/basename/pull/2",
+			Branch:     "add-feat",
+			Author:     "acme",
+		},
+		User:             models.User{Username: "commenter"},
+		Log:              logging.NewNoopLogger(),
+		CommandName:      "plan",
+		CommandHasErrors: true,
+	}
+	for _, c := range cases {
+		t.Run(c.Command, func(t *testing.T) {
+			tmpDir, cleanup := TempDir(t)
+			defer cleanup()
+			out, err := r.Run(ctx, c.Command, tmpDir)
+			if c.ExpErr != "" {
+				ErrContains(t, c.ExpErr, err)
+				return
+			}
+			Ok(t, err)
+			expOut := strings.Replace(c.ExpOut, "$DIR", tmpDir, -1)
+			Equals(t, expOut, out)
+		})
+	}
+}
+
+func TestWorkflowHookRunner_RunCancelled(t *testing.T) {
+	t.Log("a hook should be stopped when its command is cancelled")
+	tmpDir, cleanup := TempDir(t)
+	defer cleanup()
+	cancelCtx, cancel := context.WithCancel(context.Background())
+	ctx := models.WorkflowHookCommandContext{Log: logging.NewNoopLogger(), CancelCtx: cancelCtx}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	r := runtime.WorkflowHookRunner{}
+	_, err := r.Run(ctx, "sleep 30", tmpDir)
+	Assert(t, err != nil, "exp the cancelled hook to fail")
+	Assert(t, time.Since(start) < 10*time.Second, "exp the hook to be stopped")
+}
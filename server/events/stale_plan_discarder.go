@@ -0,0 +1,95 @@
+package events
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_stale_plan_discarder.go StalePlanDiscarder
+
+// StalePlanDiscarder discards plans that were made before new commits were
+// pushed to a pull request so they can't be applied.
+type StalePlanDiscarder interface {
+	// DiscardStalePlans deletes the workspaces of pull that aren't at its
+	// head commit, along with their plans. If any were deleted, the commit
+	// status is set to say that plan has to be run again. It returns the
+	// workspaces that were deleted.
+	DiscardStalePlans(log *logging.SimpleLogger, repo models.Repo, pull models.PullRequest) ([]string, error)
+}
+
+// DefaultStalePlanDiscarder implements StalePlanDiscarder.
+type DefaultStalePlanDiscarder struct {
+	WorkingDir WorkingDir
+	// WorkingDirLocker stops us from deleting a workspace while a command is
+	// running in it.
+	WorkingDirLocker    WorkingDirLocker
+	CommitStatusUpdater CommitStatusUpdater
+}
+
+func (d *DefaultStalePlanDiscarder) DiscardStalePlans(log *logging.SimpleLogger, repo models.Repo, pull models.PullRequest) ([]string, error) {
+	pullDir, err := d.WorkingDir.GetPullDir(repo, pull)
+	if os.IsNotExist(err) {
+		// Nothing has been planned for this pull.
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "getting pull dir")
+	}
+	workspaceDirs, err := ioutil.ReadDir(pullDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing workspaces")
+	}
+
+	var discarded []string
+	for _, workspaceDir := range workspaceDirs {
+		workspace := workspaceDir.Name()
+		unlock, err := d.WorkingDirLocker.TryLock(repo.FullName, pull.Num, workspace)
+		if err != nil {
+			// The command will finish with the old commit and its plan will
+			// be discarded on the next push.
+			log.Warn("not discarding plans in workspace %q since a command is running in it: %s", workspace, err)
+			continue
+		}
+		stale := d.isStale(log, filepath.Join(pullDir, workspace), pull.HeadCommit)
+		if stale {
+			err = d.WorkingDir.DeleteForWorkspace(repo, pull, workspace)
+		}
+		unlock()
+		if err != nil {
+			return discarded, errors.Wrapf(err, "deleting workspace %q", workspace)
+		}
+		if stale {
+			log.Info("discarded plans in workspace %q since it isn't at the new head commit %q", workspace, pull.HeadCommit)
+			discarded = append(discarded, workspace)
+		}
+	}
+
+	if len(discarded) > 0 {
+		if err := d.CommitStatusUpdater.UpdatePlanRequired(repo, pull); err != nil {
+			return discarded, errors.Wrap(err, "updating commit status")
+		}
+	}
+	return discarded, nil
+}
+
+// isStale returns true if the repo cloned at dir isn't at headCommit. If we
+// can't tell, it's treated as stale.
+func (d *DefaultStalePlanDiscarder) isStale(log *logging.SimpleLogger, dir string, headCommit string) bool {
+	revParseCmd := exec.Command("git", "rev-parse", "HEAD") // #nosec
+	revParseCmd.Dir = dir
+	output, err := revParseCmd.CombinedOutput()
+	if err != nil {
+		log.Warn("could not determine the commit of %q: git rev-parse HEAD: %s: %s", dir, err, string(output))
+		return true
+	}
+	// We're prefix matching here because BitBucket doesn't give us the full
+	// commit, only a 12 character prefix.
+	return !strings.HasPrefix(strings.TrimSpace(string(output)), headCommit)
+}
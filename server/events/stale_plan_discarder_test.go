@@ -0,0 +1,131 @@
+package events_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestDiscardStalePlans(t *testing.T) {
+	RegisterMockTestingT(t)
+	repoDir, oldCommit, cleanupRepo := initRepo(t)
+	defer cleanupRepo()
+	dataDir, cleanupData := TempDir(t)
+	defer cleanupData()
+	wd := &events.FileWorkspace{
+		DataDir:                 dataDir,
+		TestingOverrideCloneURL: repoDir,
+	}
+	statusUpdater := mocks.NewMockCommitStatusUpdater()
+	discarder := &events.DefaultStalePlanDiscarder{
+		WorkingDir:          wd,
+		WorkingDirLocker:    events.NewDefaultWorkingDirLocker(),
+		CommitStatusUpdater: statusUpdater,
+	}
+	repo := models.Repo{FullName: "owner/repo"}
+	pull := models.PullRequest{Num: 1, HeadCommit: oldCommit, Branch: "branch"}
+
+	t.Log("nothing is discarded if nothing has been planned")
+	discarded, err := discarder.DiscardStalePlans(logging.NewNoopLogger(), repo, pull)
+	Ok(t, err)
+	Equals(t, 0, len(discarded))
+
+	// Plan in two workspaces at the old commit.
+	for _, workspace := range []string{"default", "staging"} {
+		dir, err := wd.Clone(logging.NewNoopLogger(), repo, repo, pull, workspace)
+		Ok(t, err)
+		Ok(t, ioutil.WriteFile(filepath.Join(dir, workspace+".tfplan"), nil, 0600))
+	}
+
+	t.Log("plans at the head commit are kept")
+	discarded, err = discarder.DiscardStalePlans(logging.NewNoopLogger(), repo, pull)
+	Ok(t, err)
+	Equals(t, 0, len(discarded))
+	statusUpdater.VerifyWasCalled(Never()).UpdatePlanRequired(repo, pull)
+
+	t.Log("plans at an old commit are discarded")
+	runGit(t, repoDir, "-c", "user.name=atlantis", "-c", "user.email=atlantis@example.com", "commit", "--allow-empty", "-m", "second commit")
+	pull.HeadCommit = runGit(t, repoDir, "rev-parse", "HEAD")
+	discarded, err = discarder.DiscardStalePlans(logging.NewNoopLogger(), repo, pull)
+	Ok(t, err)
+	sort.Strings(discarded)
+	Equals(t, []string{"default", "staging"}, discarded)
+	for _, workspace := range []string{"default", "staging"} {
+		_, err := os.Stat(filepath.Join(dataDir, "repos", "owner/repo", "1", workspace))
+		Assert(t, os.IsNotExist(err), "exp workspace %q to be deleted", workspace)
+	}
+	statusUpdater.VerifyWasCalledOnce().UpdatePlanRequired(repo, pull)
+}
+
+func TestDiscardStalePlans_CommandRunning(t *testing.T) {
+	RegisterMockTestingT(t)
+	repoDir, oldCommit, cleanupRepo := initRepo(t)
+	defer cleanupRepo()
+	dataDir, cleanupData := TempDir(t)
+	defer cleanupData()
+	wd := &events.FileWorkspace{
+		DataDir:                 dataDir,
+		TestingOverrideCloneURL: repoDir,
+	}
+	dirLocker := events.NewDefaultWorkingDirLocker()
+	statusUpdater := mocks.NewMockCommitStatusUpdater()
+	discarder := &events.DefaultStalePlanDiscarder{
+		WorkingDir:          wd,
+		WorkingDirLocker:    dirLocker,
+		CommitStatusUpdater: statusUpdater,
+	}
+	repo := models.Repo{FullName: "owner/repo"}
+	pull := models.PullRequest{Num: 1, HeadCommit: oldCommit, Branch: "branch"}
+	for _, workspace := range []string{"default", "staging"} {
+		_, err := wd.Clone(logging.NewNoopLogger(), repo, repo, pull, workspace)
+		Ok(t, err)
+	}
+	unlock, err := dirLocker.TryLock(repo.FullName, pull.Num, "default")
+	Ok(t, err)
+	defer unlock()
+
+	t.Log("workspaces with a command running in them are skipped")
+	pull.HeadCommit = "newcommit"
+	discarded, err := discarder.DiscardStalePlans(logging.NewNoopLogger(), repo, pull)
+	Ok(t, err)
+	Equals(t, []string{"staging"}, discarded)
+	_, err = os.Stat(filepath.Join(dataDir, "repos", "owner/repo", "1", "default"))
+	Ok(t, err)
+}
+
+func TestDiscardStalePlans_StatusErr(t *testing.T) {
+	RegisterMockTestingT(t)
+	repoDir, oldCommit, cleanupRepo := initRepo(t)
+	defer cleanupRepo()
+	dataDir, cleanupData := TempDir(t)
+	defer cleanupData()
+	wd := &events.FileWorkspace{
+		DataDir:                 dataDir,
+		TestingOverrideCloneURL: repoDir,
+	}
+	statusUpdater := mocks.NewMockCommitStatusUpdater()
+	discarder := &events.DefaultStalePlanDiscarder{
+		WorkingDir:          wd,
+		WorkingDirLocker:    events.NewDefaultWorkingDirLocker(),
+		CommitStatusUpdater: statusUpdater,
+	}
+	repo := models.Repo{FullName: "owner/repo"}
+	pull := models.PullRequest{Num: 1, HeadCommit: oldCommit, Branch: "branch"}
+	_, err := wd.Clone(logging.NewNoopLogger(), repo, repo, pull, "default")
+	Ok(t, err)
+
+	pull.HeadCommit = "newcommit"
+	When(statusUpdater.UpdatePlanRequired(repo, pull)).ThenReturn(errors.New("err"))
+	_, err = discarder.DiscardStalePlans(logging.NewNoopLogger(), repo, pull)
+	ErrEquals(t, "updating commit status: err", err)
+}
@@ -0,0 +1,114 @@
+package terraform
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// killGracePeriod is how long we wait after interrupting a cancelled command
+// before killing it. Terraform uses the interrupt to stop cleanly, ex. to
+// release its state lock.
+const killGracePeriod = 30 * time.Second
+
+// ErrCancelled is returned when a command was stopped because it was
+// cancelled.
+var ErrCancelled = errors.New("command was cancelled")
+
+// RunCancellable starts cmd and waits for it to exit. If ctx is done before
+// then, cmd and any processes it started are interrupted and, if they haven't
+// exited after killGracePeriod, killed. ctx can be nil in which case this is
+// the same as cmd.Run().
+func RunCancellable(ctx context.Context, cmd *exec.Cmd) error {
+	if ctx == nil {
+		return cmd.Run()
+	}
+	// Put the command in its own process group so we can signal the
+	// processes it starts, ex. terraform run via sh -c, and not just cmd.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+	select {
+	case err := <-exited:
+		return err
+	case <-ctx.Done():
+	}
+
+	pgid := -cmd.Process.Pid
+	syscall.Kill(pgid, syscall.SIGINT) // nolint: errcheck
+	select {
+	case <-exited:
+	case <-time.After(killGracePeriod):
+		syscall.Kill(pgid, syscall.SIGKILL) // nolint: errcheck
+		<-exited
+	}
+	return ErrCancelled
+}
+
+// outputFuncKey is the context key for the function WithOutputFunc sets.
+type outputFuncKey struct{}
+
+// WithOutputFunc returns a copy of ctx that makes the terraform commands run
+// with it call fn with each line of their output as it's written. This is used
+// to stream the output while the command runs.
+func WithOutputFunc(ctx context.Context, fn func(line string)) context.Context {
+	return context.WithValue(ctx, outputFuncKey{}, fn)
+}
+
+// OutputFunc returns the function set by WithOutputFunc or nil if there isn't
+// one. ctx can be nil.
+func OutputFunc(ctx context.Context) func(line string) {
+	if ctx == nil {
+		return nil
+	}
+	fn, _ := ctx.Value(outputFuncKey{}).(func(line string))
+	return fn
+}
+
+// envKey is the context key for the environment variables WithEnv sets.
+type envKey struct{}
+
+// WithEnv returns a copy of ctx that makes the terraform commands run with it
+// also have env set, ex. the variables set by env steps. env is a list of
+// KEY=value strings. They take precedence over Atlantis's own environment.
+func WithEnv(ctx context.Context, env []string) context.Context {
+	return context.WithValue(ctx, envKey{}, env)
+}
+
+// Env returns the environment variables set by WithEnv. ctx can be nil.
+func Env(ctx context.Context) []string {
+	if ctx == nil {
+		return nil
+	}
+	env, _ := ctx.Value(envKey{}).([]string)
+	return env
+}
+
+// stdinKey is the context key for the reader WithStdin sets.
+type stdinKey struct{}
+
+// WithStdin returns a copy of ctx that makes the terraform commands run with
+// it read their input from r, ex. to answer a prompt. Commands run as
+// Kubernetes Jobs can't read input.
+func WithStdin(ctx context.Context, r io.Reader) context.Context {
+	return context.WithValue(ctx, stdinKey{}, r)
+}
+
+// stdin returns the reader set by WithStdin or nil if there isn't one. ctx can
+// be nil.
+func stdin(ctx context.Context) io.Reader {
+	if ctx == nil {
+		return nil
+	}
+	r, _ := ctx.Value(stdinKey{}).(io.Reader)
+	return r
+}
@@ -0,0 +1,33 @@
+package terraform_test
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/terraform"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestRunCancellable_NilContext(t *testing.T) {
+	Ok(t, terraform.RunCancellable(nil, exec.Command("true"))) // nolint: staticcheck
+}
+
+func TestRunCancellable_Exits(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	Ok(t, terraform.RunCancellable(ctx, exec.Command("true")))
+}
+
+func TestRunCancellable_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+	start := time.Now()
+	err := terraform.RunCancellable(ctx, exec.Command("sh", "-c", "sleep 60"))
+	Equals(t, terraform.ErrCancelled, err)
+	Assert(t, time.Since(start) < 10*time.Second, "exp command to be interrupted")
+}
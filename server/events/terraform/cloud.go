@@ -0,0 +1,199 @@
+package terraform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// CloudClient calls the Terraform Cloud and Terraform Enterprise API to
+// confirm or discard the remote runs started by `terraform apply` in
+// workspaces that use the remote backend, and to read the cost estimates and
+// policy checks of their runs.
+// See https://www.terraform.io/docs/cloud/api/run.html.
+type CloudClient struct {
+	// Token is a user or team token that can apply runs in the workspaces.
+	Token string
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// CloudRun is a remote run.
+type CloudRun struct {
+	ID string
+	// Status is the run's state, ex. planned or policy_soft_failed.
+	// See https://www.terraform.io/docs/cloud/api/run.html#run-states.
+	Status        string
+	IsConfirmable bool
+	IsDiscardable bool
+}
+
+// ReadRun returns the run with id runID on the Terraform Cloud or Enterprise
+// at hostname, ex. app.terraform.io.
+func (c *CloudClient) ReadRun(hostname string, runID string) (*CloudRun, error) {
+	var body struct {
+		Data struct {
+			ID         string `json:"id"`
+			Attributes struct {
+				Status  string `json:"status"`
+				Actions struct {
+					IsConfirmable bool `json:"is-confirmable"`
+					IsDiscardable bool `json:"is-discardable"`
+				} `json:"actions"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := c.do("GET", hostname, fmt.Sprintf("runs/%s", runID), nil, &body); err != nil {
+		return nil, errors.Wrapf(err, "reading run %s", runID)
+	}
+	return &CloudRun{
+		ID:            body.Data.ID,
+		Status:        body.Data.Attributes.Status,
+		IsConfirmable: body.Data.Attributes.Actions.IsConfirmable,
+		IsDiscardable: body.Data.Attributes.Actions.IsDiscardable,
+	}, nil
+}
+
+// CloudRunChecks are the results of the cost estimate and Sentinel policy
+// checks of a run.
+type CloudRunChecks struct {
+	// CostEstimate is nil if the organization doesn't estimate costs.
+	CostEstimate *CloudCostEstimate
+	// PolicyChecks is empty if no policy sets apply to the workspace.
+	PolicyChecks []CloudPolicyCheck
+}
+
+// CloudCostEstimate is the cost estimate of a run. The costs are in USD per
+// month.
+type CloudCostEstimate struct {
+	// Status is ex. finished or errored.
+	Status              string
+	PriorMonthlyCost    string
+	ProposedMonthlyCost string
+	DeltaMonthlyCost    string
+}
+
+// CloudPolicyCheck is a Sentinel policy check of a run and how many of its
+// policies passed and failed at each enforcement level.
+type CloudPolicyCheck struct {
+	// Status is ex. passed, soft_failed, hard_failed or overridden.
+	Status         string
+	Passed         int
+	AdvisoryFailed int
+	SoftFailed     int
+	HardFailed     int
+}
+
+// ReadRunChecks returns the cost estimate and policy checks of the run with id
+// runID.
+func (c *CloudClient) ReadRunChecks(hostname string, runID string) (*CloudRunChecks, error) {
+	var run struct {
+		Included []struct {
+			Type       string `json:"type"`
+			Attributes struct {
+				Status              string `json:"status"`
+				PriorMonthlyCost    string `json:"prior-monthly-cost"`
+				ProposedMonthlyCost string `json:"proposed-monthly-cost"`
+				DeltaMonthlyCost    string `json:"delta-monthly-cost"`
+			} `json:"attributes"`
+		} `json:"included"`
+	}
+	if err := c.do("GET", hostname, fmt.Sprintf("runs/%s?include=cost_estimate", runID), nil, &run); err != nil {
+		return nil, errors.Wrapf(err, "reading run %s", runID)
+	}
+	var policyChecks struct {
+		Data []struct {
+			Attributes struct {
+				Status string `json:"status"`
+				Result struct {
+					Passed         int `json:"passed"`
+					AdvisoryFailed int `json:"advisory-failed"`
+					SoftFailed     int `json:"soft-failed"`
+					HardFailed     int `json:"hard-failed"`
+				} `json:"result"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := c.do("GET", hostname, fmt.Sprintf("runs/%s/policy-checks", runID), nil, &policyChecks); err != nil {
+		return nil, errors.Wrapf(err, "reading policy checks of run %s", runID)
+	}
+
+	checks := &CloudRunChecks{}
+	for _, inc := range run.Included {
+		if inc.Type != "cost-estimates" {
+			continue
+		}
+		checks.CostEstimate = &CloudCostEstimate{
+			Status:              inc.Attributes.Status,
+			PriorMonthlyCost:    inc.Attributes.PriorMonthlyCost,
+			ProposedMonthlyCost: inc.Attributes.ProposedMonthlyCost,
+			DeltaMonthlyCost:    inc.Attributes.DeltaMonthlyCost,
+		}
+	}
+	for _, pc := range policyChecks.Data {
+		checks.PolicyChecks = append(checks.PolicyChecks, CloudPolicyCheck{
+			Status:         pc.Attributes.Status,
+			Passed:         pc.Attributes.Result.Passed,
+			AdvisoryFailed: pc.Attributes.Result.AdvisoryFailed,
+			SoftFailed:     pc.Attributes.Result.SoftFailed,
+			HardFailed:     pc.Attributes.Result.HardFailed,
+		})
+	}
+	return checks, nil
+}
+
+// ApplyRun confirms the run so its plan is applied. comment is shown on the
+// run.
+func (c *CloudClient) ApplyRun(hostname string, runID string, comment string) error {
+	return errors.Wrapf(c.do("POST", hostname, fmt.Sprintf("runs/%s/actions/apply", runID), map[string]string{"comment": comment}, nil), "applying run %s", runID)
+}
+
+// DiscardRun discards the run so its plan isn't applied. comment is shown on
+// the run.
+func (c *CloudClient) DiscardRun(hostname string, runID string, comment string) error {
+	return errors.Wrapf(c.do("POST", hostname, fmt.Sprintf("runs/%s/actions/discard", runID), map[string]string{"comment": comment}, nil), "discarding run %s", runID)
+}
+
+// do sends a request to path under the API at hostname with reqBody as its
+// JSON body, if it isn't nil, and decodes the response into respBody, if it
+// isn't nil.
+func (c *CloudClient) do(method string, hostname string, path string, reqBody interface{}, respBody interface{}) error {
+	var reqBytes []byte
+	if reqBody != nil {
+		var err error
+		if reqBytes, err = json.Marshal(reqBody); err != nil {
+			return err
+		}
+	}
+	url := fmt.Sprintf("https://%s/api/v2/%s", hostname, path)
+	req, err := http.NewRequest(method, url, bytes.NewReader(reqBytes))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Content-Type", "application/vnd.api+json")
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	respBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrapf(err, "reading response from %s %s", method, url)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d from %s %s: %s", resp.StatusCode, method, url, string(respBytes))
+	}
+	if respBody == nil {
+		return nil
+	}
+	return errors.Wrapf(json.Unmarshal(respBytes, respBody), "parsing response from %s %s", method, url)
+}
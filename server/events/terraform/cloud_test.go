@@ -0,0 +1,77 @@
+package terraform_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/terraform"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestCloudClient(t *testing.T) {
+	var requests []string
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Equals(t, "Bearer token", r.Header.Get("Authorization"))
+		Equals(t, "application/vnd.api+json", r.Header.Get("Content-Type"))
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/api/v2/runs/run-abc":
+			w.Write([]byte(`{"data": {"id": "run-abc", "attributes": {"status": "planned", "actions": {"is-confirmable": true, "is-discardable": true}}}}`)) // nolint: errcheck
+		case r.Method == "POST" && r.URL.Path == "/api/v2/runs/run-abc/actions/apply", r.Method == "POST" && r.URL.Path == "/api/v2/runs/run-abc/actions/discard":
+			var body map[string]string
+			Ok(t, json.NewDecoder(r.Body).Decode(&body))
+			requests = append(requests, r.URL.Path+": "+body["comment"])
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			http.Error(w, `{"errors": [{"status": "404", "title": "not found"}]}`, http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+	host := strings.TrimPrefix(testServer.URL, "https://")
+	client := &terraform.CloudClient{Token: "token", HTTPClient: testServer.Client()}
+
+	run, err := client.ReadRun(host, "run-abc")
+	Ok(t, err)
+	Equals(t, &terraform.CloudRun{ID: "run-abc", Status: "planned", IsConfirmable: true, IsDiscardable: true}, run)
+
+	Ok(t, client.ApplyRun(host, "run-abc", "applied"))
+	Ok(t, client.DiscardRun(host, "run-abc", "discarded"))
+	Equals(t, []string{"/api/v2/runs/run-abc/actions/apply: applied", "/api/v2/runs/run-abc/actions/discard: discarded"}, requests)
+
+	_, err = client.ReadRun(host, "run-missing")
+	ErrContains(t, "reading run run-missing: unexpected status 404", err)
+}
+
+func TestCloudClient_ReadRunChecks(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/v2/runs/run-abc" && r.URL.Query().Get("include") == "cost_estimate":
+			w.Write([]byte(`{"data": {"id": "run-abc"}, "included": [{"type": "cost-estimates", "attributes": {"status": "finished", "prior-monthly-cost": "10.0", "proposed-monthly-cost": "35.5", "delta-monthly-cost": "25.5"}}]}`)) // nolint: errcheck
+		case r.URL.Path == "/api/v2/runs/run-abc/policy-checks":
+			w.Write([]byte(`{"data": [{"attributes": {"status": "soft_failed", "result": {"passed": 3, "advisory-failed": 1, "soft-failed": 1, "hard-failed": 0}}}]}`)) // nolint: errcheck
+		case r.URL.Path == "/api/v2/runs/run-none":
+			w.Write([]byte(`{"data": {"id": "run-none"}}`)) // nolint: errcheck
+		case r.URL.Path == "/api/v2/runs/run-none/policy-checks":
+			w.Write([]byte(`{"data": []}`)) // nolint: errcheck
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+	host := strings.TrimPrefix(testServer.URL, "https://")
+	client := &terraform.CloudClient{Token: "token", HTTPClient: testServer.Client()}
+
+	checks, err := client.ReadRunChecks(host, "run-abc")
+	Ok(t, err)
+	Equals(t, &terraform.CloudRunChecks{
+		CostEstimate: &terraform.CloudCostEstimate{Status: "finished", PriorMonthlyCost: "10.0", ProposedMonthlyCost: "35.5", DeltaMonthlyCost: "25.5"},
+		PolicyChecks: []terraform.CloudPolicyCheck{{Status: "soft_failed", Passed: 3, AdvisoryFailed: 1, SoftFailed: 1}},
+	}, checks)
+
+	// Organizations without cost estimation or policy sets have neither.
+	checks, err = client.ReadRunChecks(host, "run-none")
+	Ok(t, err)
+	Equals(t, &terraform.CloudRunChecks{}, checks)
+}
@@ -0,0 +1,136 @@
+package terraform
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// HostExecutionMode runs terraform directly on the Atlantis host.
+	HostExecutionMode = "host"
+	// DockerExecutionMode runs each terraform command in its own container.
+	DockerExecutionMode = "docker"
+	// KubernetesExecutionMode runs each terraform command as a Kubernetes
+	// Job.
+	KubernetesExecutionMode = "kubernetes"
+)
+
+// ExecutionModes are the ways terraform can be run.
+var ExecutionModes = []string{HostExecutionMode, DockerExecutionMode, KubernetesExecutionMode}
+
+// DockerConfig configures running terraform in containers so that the code
+// in repos, ex. external data sources and local-exec provisioners, can't
+// access the Atlantis host. Each command is run in a new container that's
+// removed when the command exits.
+type DockerConfig struct {
+	// Image is the image to run. It must have sh and terraform, and
+	// terragrunt if it's used, in its $PATH.
+	Image string
+	// Mounts are extra volumes to mount in docker's -v format, ex.
+	// /home/atlantis/.aws:/home/atlantis/.aws:ro.
+	Mounts []string
+	// CPUs and Memory limit the container's resources in docker's --cpus and
+	// --memory formats, ex. 1.5 and 2g. If empty, they're unlimited.
+	CPUs   string
+	Memory string
+}
+
+// args returns the args to docker to run tfCmd with sh in dir. Only the
+// variables named in envNames are passed into the container, with the values
+// they have in docker's environment, so secrets aren't in its args. mounts are
+// the host paths terraform needs, which are mounted at the same paths in the
+// container.
+func (d *DockerConfig) args(tfCmd string, dir string, envNames []string, mounts []string) []string {
+	args := []string{
+		"run", "--rm", "--init",
+		// Run as our user so the files terraform writes, ex. plan files, are
+		// ours to read and delete.
+		"--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()),
+		"--workdir", dir,
+		"--entrypoint", "sh",
+		// Our user likely has no home dir in the image.
+		"-e", "HOME=/tmp",
+	}
+	for _, m := range mounts {
+		args = append(args, "-v", fmt.Sprintf("%s:%s", m, m))
+	}
+	for _, m := range d.Mounts {
+		args = append(args, "-v", m)
+	}
+	if d.CPUs != "" {
+		args = append(args, "--cpus", d.CPUs)
+	}
+	if d.Memory != "" {
+		args = append(args, "--memory", d.Memory)
+	}
+	seen := make(map[string]bool)
+	for _, name := range envNames {
+		if !seen[name] {
+			seen[name] = true
+			args = append(args, "-e", name)
+		}
+	}
+	return append(args, d.Image, "-c", tfCmd)
+}
+
+// command returns the command to run tfCmd in dir with env set. If docker is
+// configured, it's run in a container with only the variables in tfEnv, the
+// ones Atlantis sets for terraform, and the repo that dir is in, which terraform
+// needs to read the modules dir calls.
+func (c *DefaultClient) command(tfCmd string, dir string, env []string, tfEnv []string, stdin io.Reader) *exec.Cmd {
+	if c.docker == nil {
+		// We use 'sh -c' so that if extra_args have been specified with env vars,
+		// ex. -var-file=$WORKSPACE.tfvars, then they get substituted.
+		cmd := exec.Command("sh", "-c", tfCmd) // #nosec
+		cmd.Dir = dir
+		cmd.Env = env
+		cmd.Stdin = stdin
+		return cmd
+	}
+
+	mounts := []string{repoRoot(dir)}
+	var envNames []string
+	for _, e := range tfEnv {
+		envNames = append(envNames, strings.SplitN(e, "=", 2)[0])
+	}
+	if c.binDir != "" && fileExists(c.binDir) {
+		mounts = append(mounts, c.binDir)
+	}
+	if c.terraformPluginCacheDir != "" {
+		mounts = append(mounts, c.terraformPluginCacheDir)
+	}
+	if c.rcFile != "" {
+		mounts = append(mounts, c.rcFile)
+		envNames = append(envNames, "TF_CLI_CONFIG_FILE")
+		env = append(env, fmt.Sprintf("TF_CLI_CONFIG_FILE=%s", c.rcFile))
+	}
+	args := c.docker.args(tfCmd, dir, envNames, mounts)
+	if stdin != nil {
+		// docker only passes our stdin to the container if it's interactive.
+		args = append([]string{args[0], "--interactive"}, args[1:]...)
+	}
+	cmd := exec.Command("docker", args...) // #nosec
+	cmd.Dir = dir
+	cmd.Stdin = stdin
+	// The variables are read from docker's environment, which also needs
+	// $PATH and any DOCKER_ variables from ours.
+	cmd.Env = env
+	return cmd
+}
+
+// repoRoot returns the root of the git repo that dir is in, or dir if it isn't
+// in one.
+func repoRoot(dir string) string {
+	for d := dir; ; d = filepath.Dir(d) {
+		if fileExists(filepath.Join(d, ".git")) {
+			return d
+		}
+		if filepath.Dir(d) == d {
+			return dir
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package terraform
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/logging"
+	"golang.org/x/crypto/openpgp"
+)
+
+// DefaultDownloadURL is where terraform releases are downloaded from unless
+// another URL, ex. of an internal mirror, is configured.
+const DefaultDownloadURL = "https://releases.hashicorp.com"
+
+// binDirName is the directory inside the data dir that downloaded terraform
+// binaries are stored in.
+const binDirName = "bin"
+
+// EnsureVersion makes sure that terraform version v is available to run
+// commands with. If it's not already in our $PATH or our bin dir, it's
+// downloaded into our bin dir.
+func (c *DefaultClient) EnsureVersion(log *logging.SimpleLogger, v *version.Version) error {
+	if v.Equal(c.defaultVersion) {
+		return nil
+	}
+	// Projects are planned in parallel so we need to make sure two of them
+	// don't download the same version at once.
+	c.downloadMu.Lock()
+	defer c.downloadMu.Unlock()
+	executable := fmt.Sprintf("terraform%s", v.String())
+	if _, err := exec.LookPath(executable); err == nil {
+		log.Debug("%s already in $PATH, not downloading", executable)
+		return nil
+	}
+	dest := filepath.Join(c.binDir, executable)
+	if fileExists(dest) {
+		log.Debug("%s already downloaded to %q", executable, dest)
+		return nil
+	}
+
+	log.Info("downloading terraform %s to %q", v.String(), dest)
+	if err := c.download(v, dest); err != nil {
+		return errors.Wrapf(err, "downloading terraform %s", v.String())
+	}
+	log.Info("downloaded terraform %s", v.String())
+	return nil
+}
+
+// downloadClient returns the client to download releases with.
+func (c *DefaultClient) downloadClient() *http.Client {
+	if c.httpClient == nil {
+		return http.DefaultClient
+	}
+	return c.httpClient
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// download fetches the release zip for v for the OS and architecture we're
+// running on, verifies it against the release's checksums and extracts the
+// terraform binary inside it to dest.
+func (c *DefaultClient) download(v *version.Version, dest string) error {
+	filename := fmt.Sprintf("terraform_%s_%s_%s.zip", v.String(), runtime.GOOS, runtime.GOARCH)
+	expSum, err := c.releaseChecksum(v, filename)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/terraform/%s/%s", c.downloadURL, v.String(), filename)
+	resp, err := c.downloadClient().Get(url) // #nosec
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+
+	// zip needs random access so we write the archive to disk first.
+	archive, err := ioutil.TempFile("", "atlantis-terraform-download")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(archive.Name()) // nolint: errcheck
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(archive, hash), resp.Body)
+	archive.Close() // nolint: errcheck
+	if err != nil {
+		return errors.Wrapf(err, "reading %s", url)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != expSum {
+		return fmt.Errorf("checksum of %s was %s but expected %s", url, sum, expSum)
+	}
+
+	r, err := zip.OpenReader(archive.Name())
+	if err != nil {
+		return errors.Wrapf(err, "opening archive from %s", url)
+	}
+	defer r.Close() // nolint: errcheck
+	for _, f := range r.File {
+		if f.Name != "terraform" {
+			continue
+		}
+		return extractFile(f, dest)
+	}
+	return fmt.Errorf("no terraform binary in archive from %s", url)
+}
+
+// releaseChecksum returns the SHA256 checksum of filename that's published
+// with release v. Releases list the checksums of all their files in a
+// terraform_<version>_SHA256SUMS file, one per line as "<checksum>  <filename>".
+// The file is signed by HashiCorp so we check its signature before trusting
+// it, which also means a mirror can't change the binaries it serves.
+func (c *DefaultClient) releaseChecksum(v *version.Version, filename string) (string, error) {
+	url := fmt.Sprintf("%s/terraform/%s/terraform_%s_SHA256SUMS", c.downloadURL, v.String(), v.String())
+	sums, err := c.httpGet(url)
+	if err != nil {
+		return "", err
+	}
+	sig, err := c.httpGet(url + ".sig")
+	if err != nil {
+		return "", err
+	}
+	if _, err := openpgp.CheckDetachedSignature(c.releaseKeyring, bytes.NewReader(sums), bytes.NewReader(sig)); err != nil {
+		return "", errors.Wrapf(err, "verifying signature of %s", url)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(sums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == filename {
+			return fields[0], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", errors.Wrapf(err, "reading %s", url)
+	}
+	return "", fmt.Errorf("no checksum for %s in %s", filename, url)
+}
+
+// httpGet returns the body of url, which must respond with a 200.
+func (c *DefaultClient) httpGet(url string) ([]byte, error) {
+	resp, err := c.downloadClient().Get(url) // #nosec
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	return body, errors.Wrapf(err, "reading %s", url)
+}
+
+// extractFile writes f to dest as an executable. It's written to a temporary
+// file first so that a partial download is never picked up as the binary.
+func extractFile(f *zip.File, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+		return err
+	}
+	src, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close() // nolint: errcheck
+
+	tmp := dest + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0700)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, src) // #nosec
+	out.Close()                // nolint: errcheck
+	if err != nil {
+		os.Remove(tmp) // nolint: errcheck
+		return err
+	}
+	return os.Rename(tmp, dest)
+}
@@ -0,0 +1,170 @@
+package terraform
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-version"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+	"golang.org/x/crypto/openpgp"
+)
+
+func TestEnsureVersion_Downloads(t *testing.T) {
+	var requests []string
+	server := newReleasesServer(t, &requests, "")
+	defer server.Close()
+
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	c := &DefaultClient{
+		defaultVersion: version.Must(version.NewVersion("0.11.0")),
+		binDir:         filepath.Join(tmp, binDirName),
+		downloadURL:    server.URL,
+		releaseKeyring: testKeyring,
+	}
+	v := version.Must(version.NewVersion("0.11.99"))
+	Ok(t, c.EnsureVersion(logging.NewNoopLogger(), v))
+
+	contents, err := ioutil.ReadFile(filepath.Join(tmp, binDirName, "terraform0.11.99"))
+	Ok(t, err)
+	Equals(t, "binary", string(contents))
+	Equals(t, []string{
+		"/terraform/0.11.99/terraform_0.11.99_SHA256SUMS",
+		"/terraform/0.11.99/terraform_0.11.99_SHA256SUMS.sig",
+		fmt.Sprintf("/terraform/0.11.99/terraform_0.11.99_%s_%s.zip", runtime.GOOS, runtime.GOARCH),
+	}, requests)
+
+	t.Log("should not download again once it's in the bin dir")
+	Ok(t, c.EnsureVersion(logging.NewNoopLogger(), v))
+	Equals(t, 3, len(requests))
+}
+
+// If the checksums aren't signed by a key we trust, nothing should be
+// downloaded.
+func TestEnsureVersion_BadSignature(t *testing.T) {
+	var requests []string
+	server := newReleasesServer(t, &requests, "")
+	defer server.Close()
+	other, err := openpgp.NewEntity("other", "", "other@example.com", nil)
+	Ok(t, err)
+
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	c := &DefaultClient{
+		defaultVersion: version.Must(version.NewVersion("0.11.0")),
+		binDir:         filepath.Join(tmp, binDirName),
+		downloadURL:    server.URL,
+		releaseKeyring: openpgp.EntityList{other},
+	}
+	err = c.EnsureVersion(logging.NewNoopLogger(), version.Must(version.NewVersion("0.11.99")))
+	ErrContains(t, "verifying signature of "+server.URL+"/terraform/0.11.99/terraform_0.11.99_SHA256SUMS", err)
+	Equals(t, 2, len(requests))
+	Assert(t, !fileExists(filepath.Join(tmp, binDirName, "terraform0.11.99")), "exp no binary to be written")
+}
+
+// The release key should parse so that NewClient doesn't fail.
+func TestHashicorpReleaseKey(t *testing.T) {
+	keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(hashicorpReleaseKey))
+	Ok(t, err)
+	Equals(t, 1, len(keyring))
+	Equals(t, "34365D9472D7468F", fmt.Sprintf("%X", keyring[0].PrimaryKey.KeyId))
+}
+
+// If the archive doesn't match the release's checksum, it shouldn't be
+// extracted.
+func TestEnsureVersion_ChecksumMismatch(t *testing.T) {
+	var requests []string
+	server := newReleasesServer(t, &requests, "0000")
+	defer server.Close()
+
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	c := &DefaultClient{
+		defaultVersion: version.Must(version.NewVersion("0.11.0")),
+		binDir:         filepath.Join(tmp, binDirName),
+		downloadURL:    server.URL,
+		releaseKeyring: testKeyring,
+	}
+	err := c.EnsureVersion(logging.NewNoopLogger(), version.Must(version.NewVersion("0.11.99")))
+	ErrContains(t, "but expected 0000", err)
+	Assert(t, !fileExists(filepath.Join(tmp, binDirName, "terraform0.11.99")), "exp no binary to be written")
+}
+
+func TestEnsureVersion_NotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	c := &DefaultClient{
+		defaultVersion: version.Must(version.NewVersion("0.11.0")),
+		binDir:         filepath.Join(tmp, binDirName),
+		downloadURL:    server.URL,
+		releaseKeyring: testKeyring,
+	}
+	err := c.EnsureVersion(logging.NewNoopLogger(), version.Must(version.NewVersion("0.11.99")))
+	Assert(t, err != nil, "exp err")
+	Assert(t, !fileExists(filepath.Join(tmp, binDirName, "terraform0.11.99")), "exp no binary to be written")
+}
+
+// testKeyring holds the key newReleasesServer signs checksums with.
+var testKeyring openpgp.EntityList
+
+func init() {
+	signer, err := openpgp.NewEntity("test", "", "test@example.com", nil)
+	if err != nil {
+		panic(err)
+	}
+	testKeyring = openpgp.EntityList{signer}
+}
+
+// newReleasesServer serves a fake releases site. Every version's archive
+// contains a terraform binary whose contents are "binary". If checksum is
+// set, it's published as the archives' checksum instead of their real one.
+// Checksums are signed with the key in testKeyring. The path of each request
+// is appended to requests.
+func newReleasesServer(t *testing.T, requests *[]string, checksum string) *httptest.Server {
+	var archive bytes.Buffer
+	w := zip.NewWriter(&archive)
+	f, err := w.Create("terraform")
+	Ok(t, err)
+	_, err = f.Write([]byte("binary"))
+	Ok(t, err)
+	Ok(t, w.Close())
+	if checksum == "" {
+		checksum = fmt.Sprintf("%x", sha256.Sum256(archive.Bytes()))
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r.URL.Path)
+		switch {
+		case r.URL.Path == "/terraform/index.json":
+			rw.Write([]byte(`{"name":"terraform","versions":{"0.11.14":{},"0.12.0-rc1":{},"0.12.3":{},"0.12.10":{},"0.13.0":{}}}`)) // nolint: errcheck
+		case strings.HasSuffix(r.URL.Path, "_SHA256SUMS"):
+			rw.Write(releaseSums(r.URL.Path, checksum)) // nolint: errcheck
+		case strings.HasSuffix(r.URL.Path, "_SHA256SUMS.sig"):
+			sums := releaseSums(strings.TrimSuffix(r.URL.Path, ".sig"), checksum)
+			Ok(t, openpgp.DetachSign(rw, testKeyring[0], bytes.NewReader(sums), nil))
+		default:
+			rw.Write(archive.Bytes()) // nolint: errcheck
+		}
+	}))
+}
+
+// releaseSums returns the SHA256SUMS file served at path.
+func releaseSums(path string, checksum string) []byte {
+	v := strings.Split(path, "/")[2]
+	return []byte(fmt.Sprintf("abcd  terraform_%s_plan9_386.zip\n%s  terraform_%s_%s_%s.zip\n", v, checksum, v, runtime.GOOS, runtime.GOARCH))
+}
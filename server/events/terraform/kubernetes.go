@@ -0,0 +1,234 @@
+package terraform
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// jobStartTimeout is how long to wait for a Job's pod to start, ex. while
+// it's scheduled and its image is pulled.
+const jobStartTimeout = "10m"
+
+// jobStatusTimeout is how long to wait for a Job's status to be updated
+// after its pod exits.
+var jobStatusTimeout = time.Minute
+
+// jobPollInterval is how often we check a Job's status while waiting for it.
+var jobPollInterval = time.Second
+
+// KubernetesConfig configures running each terraform command as a Kubernetes
+// Job. Jobs are created with kubectl so it must be in the $PATH. The Jobs mount
+// the repo from the volume the data dir is on, so it must be ReadWriteMany, and
+// write their plan files to it for Atlantis to read.
+type KubernetesConfig struct {
+	// Kubeconfig is the kubeconfig file kubectl uses. If it's empty kubectl
+	// uses its defaults, ex. the in-cluster config when Atlantis runs in a
+	// pod.
+	Kubeconfig string
+	// Namespace is the namespace to create Jobs in. If it's empty kubectl's
+	// current namespace is used.
+	Namespace string
+	// Image is the image to run terraform in. It must have sh and terraform in
+	// its $PATH.
+	Image string
+	// DataPVC is the PersistentVolumeClaim that DataDir is on.
+	DataPVC string
+	// DataDir is where DataPVC is mounted in Atlantis's pod. Paths in it are
+	// mounted at the same paths in the Jobs' pods.
+	DataDir string
+	// CloneRoot is where repos are cloned. It must be in DataDir.
+	CloneRoot string
+	// ServiceAccounts maps repo full names, ex. owner/repo, to the service
+	// account to run their Jobs as so each repo can have its own cloud
+	// credentials. The * entry is used for other repos. If there isn't one,
+	// the namespace's default service account is used.
+	ServiceAccounts map[string]string
+}
+
+// runJob runs tfCmd in dir as a Job and returns its output. The output is
+// streamed to ctx's output func while the Job runs and the Job is deleted when
+// it finishes or ctx is cancelled. env is the environment to run kubectl with.
+// tfEnv are the variables that are set in the Job's container.
+func (c *DefaultClient) runJob(ctx context.Context, log *logging.SimpleLogger, tfCmd string, dir string, env []string, tfEnv []string) (string, error) {
+	k := c.kubernetes
+	mounts := []string{repoRoot(dir)}
+	if c.binDir != "" && fileExists(c.binDir) {
+		mounts = append(mounts, c.binDir)
+	}
+	if c.terraformPluginCacheDir != "" {
+		mounts = append(mounts, c.terraformPluginCacheDir)
+	}
+	name, err := jobName()
+	if err != nil {
+		return "", err
+	}
+	manifest, err := k.jobManifest(name, tfCmd, dir, tfEnv, mounts)
+	if err != nil {
+		return "", err
+	}
+
+	create := exec.Command("kubectl", k.kubectlArgs("create", "-f", "-")...) // #nosec
+	create.Env = env
+	create.Stdin = bytes.NewReader(manifest)
+	if out, err := create.CombinedOutput(); err != nil {
+		return "", errors.Wrapf(err, "creating job %s: %s", name, strings.TrimSpace(string(out)))
+	}
+	log.Debug("created job %s to run %q", name, tfCmd)
+	defer func() {
+		// Deleting the Job also deletes its pod, which stops terraform if ctx
+		// was cancelled.
+		del := exec.Command("kubectl", k.kubectlArgs("delete", "job", name, "--wait=false")...) // #nosec
+		del.Env = env
+		if out, err := del.CombinedOutput(); err != nil {
+			log.Warn("unable to delete job %s: %s: %s", name, err, strings.TrimSpace(string(out)))
+		}
+	}()
+
+	logs := exec.Command("kubectl", k.kubectlArgs("logs", "--follow", "--pod-running-timeout="+jobStartTimeout, "job/"+name)...) // #nosec
+	logs.Env = env
+	out, err := c.crashSafeExec(ctx, logs)
+	if err != nil {
+		return out, errors.Wrapf(err, "streaming logs of job %s", name)
+	}
+	succeeded, err := k.waitForJob(name, env)
+	if err != nil {
+		return out, err
+	}
+	if !succeeded {
+		return out, fmt.Errorf("job %s failed", name)
+	}
+	return out, nil
+}
+
+// waitForJob waits for Job name to finish and returns true if it succeeded.
+func (k *KubernetesConfig) waitForJob(name string, env []string) (bool, error) {
+	deadline := time.Now().Add(jobStatusTimeout)
+	for {
+		get := exec.Command("kubectl", k.kubectlArgs("get", "job", name, "-o", "jsonpath={.status.succeeded},{.status.failed}")...) // #nosec
+		get.Env = env
+		out, err := get.CombinedOutput()
+		if err != nil {
+			return false, errors.Wrapf(err, "getting status of job %s: %s", name, strings.TrimSpace(string(out)))
+		}
+		status := strings.SplitN(strings.TrimSpace(string(out)), ",", 2)
+		if status[0] != "" && status[0] != "0" {
+			return true, nil
+		}
+		if len(status) == 2 && status[1] != "" && status[1] != "0" {
+			return false, nil
+		}
+		if time.Now().After(deadline) {
+			return false, fmt.Errorf("job %s didn't finish within %s of its pod exiting", name, jobStatusTimeout)
+		}
+		time.Sleep(jobPollInterval)
+	}
+}
+
+// kubectlArgs returns args with the flags to connect to our cluster and
+// namespace.
+func (k *KubernetesConfig) kubectlArgs(args ...string) []string {
+	var flags []string
+	if k.Kubeconfig != "" {
+		flags = append(flags, "--kubeconfig", k.Kubeconfig)
+	}
+	if k.Namespace != "" {
+		flags = append(flags, "--namespace", k.Namespace)
+	}
+	return append(flags, args...)
+}
+
+// serviceAccount returns the service account to run the Jobs of the repo that
+// dir is in as, or an empty string for the default.
+func (k *KubernetesConfig) serviceAccount(dir string) string {
+	// Repos are cloned into <CloneRoot>/<repo full name>/<pull num>/<workspace>.
+	if rel, err := filepath.Rel(k.CloneRoot, filepath.Dir(filepath.Dir(repoRoot(dir)))); err == nil {
+		if sa, ok := k.ServiceAccounts[filepath.ToSlash(rel)]; ok {
+			return sa
+		}
+	}
+	return k.ServiceAccounts["*"]
+}
+
+// jobManifest returns the JSON manifest of a Job named name that runs tfCmd
+// with sh in dir. mounts are paths in DataDir that are mounted from DataPVC at
+// the same paths.
+func (k *KubernetesConfig) jobManifest(name string, tfCmd string, dir string, tfEnv []string, mounts []string) ([]byte, error) {
+	// Our user likely has no home dir in the image.
+	env := []map[string]string{{"name": "HOME", "value": "/tmp"}}
+	for _, e := range tfEnv {
+		parts := strings.SplitN(e, "=", 2)
+		if len(parts) == 2 {
+			env = append(env, map[string]string{"name": parts[0], "value": parts[1]})
+		}
+	}
+	var volumeMounts []map[string]interface{}
+	for _, m := range mounts {
+		rel, err := filepath.Rel(k.DataDir, m)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+			return nil, fmt.Errorf("can't mount %s in job since it isn't in the data dir %s", m, k.DataDir)
+		}
+		volumeMounts = append(volumeMounts, map[string]interface{}{
+			"name":      "data",
+			"mountPath": m,
+			"subPath":   filepath.ToSlash(rel),
+		})
+	}
+	podSpec := map[string]interface{}{
+		"restartPolicy": "Never",
+		// Run as our user so the files terraform writes, ex. plan files, are
+		// ours to read and delete.
+		"securityContext": map[string]interface{}{
+			"runAsUser":  os.Getuid(),
+			"runAsGroup": os.Getgid(),
+		},
+		"containers": []map[string]interface{}{{
+			"name":         "terraform",
+			"image":        k.Image,
+			"command":      []string{"sh", "-c", tfCmd},
+			"workingDir":   dir,
+			"env":          env,
+			"volumeMounts": volumeMounts,
+		}},
+		"volumes": []map[string]interface{}{{
+			"name":                  "data",
+			"persistentVolumeClaim": map[string]string{"claimName": k.DataPVC},
+		}},
+	}
+	if sa := k.serviceAccount(dir); sa != "" {
+		podSpec["serviceAccountName"] = sa
+	}
+	return json.Marshal(map[string]interface{}{
+		"apiVersion": "batch/v1",
+		"kind":       "Job",
+		"metadata": map[string]interface{}{
+			"name":   name,
+			"labels": map[string]string{"app.kubernetes.io/managed-by": "atlantis"},
+		},
+		"spec": map[string]interface{}{
+			// Terraform isn't safe to retry, ex. after a partial apply.
+			"backoffLimit": 0,
+			"template":     map[string]interface{}{"spec": podSpec},
+		},
+	})
+}
+
+// jobName returns a random name for a Job.
+func jobName() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "generating job name")
+	}
+	return "atlantis-terraform-" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,62 @@
+package terraform
+
+import (
+	"regexp"
+	"strings"
+)
+
+// LogLevels are the values terraform accepts for TF_LOG.
+var LogLevels = []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR"}
+
+// CommentLogLevels are the LogLevels that can be picked with plan --tf-log.
+// At TRACE and DEBUG providers log their HTTP requests, which can hold
+// credentials RedactLog doesn't recognize, so only --tf-log-level can set
+// them.
+var CommentLogLevels = []string{"INFO", "WARN", "ERROR"}
+
+// redacted replaces secrets in terraform logs.
+const redacted = "<redacted>"
+
+var (
+	// secretHeaderRegex matches HTTP headers that carry credentials, ex.
+	//     Authorization: Bearer abc123
+	secretHeaderRegex = regexp.MustCompile(`(?i)((?:authorization|x-amz-security-token|x-vault-token|private-token)"?\s*[:=]\s*"?)[^"\r\n]+`)
+	// secretKeyValueRegex matches key/value pairs whose key looks like it
+	// holds a secret, ex.
+	//     "secret_key": "abc123"
+	//     password=abc123
+	secretKeyValueRegex = regexp.MustCompile(`(?i)((?:[a-z0-9_-]*(?:password|secret|token|private_key|access_key)[a-z0-9_-]*)"?\s*[:=]\s*"?)[^\s"',&]+`)
+	// awsAccessKeyIDRegex matches AWS access key ids.
+	awsAccessKeyIDRegex = regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)
+)
+
+// NormalizeLogLevel upper-cases level and returns true if it's a valid
+// TF_LOG value.
+func NormalizeLogLevel(level string) (string, bool) {
+	level = strings.ToUpper(level)
+	for _, l := range LogLevels {
+		if l == level {
+			return level, true
+		}
+	}
+	return level, false
+}
+
+// IsCommentLogLevel returns true if level, which must be normalized, is in
+// CommentLogLevels.
+func IsCommentLogLevel(level string) bool {
+	for _, l := range CommentLogLevels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactLog removes anything that looks like a credential from a terraform
+// log so that it's safe to comment on a pull request.
+func RedactLog(log string) string {
+	log = secretHeaderRegex.ReplaceAllString(log, "${1}"+redacted)
+	log = secretKeyValueRegex.ReplaceAllString(log, "${1}"+redacted)
+	return awsAccessKeyIDRegex.ReplaceAllString(log, redacted)
+}
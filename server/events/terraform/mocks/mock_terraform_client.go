@@ -4,7 +4,8 @@
 package mocks
 
 import (
-	go_version "github.com/hashicorp/go-version"
+	context "context"
+	version "github.com/hashicorp/go-version"
 	pegomock "github.com/petergtz/pegomock"
 	logging "github.com/runatlantis/atlantis/server/logging"
 	"reflect"
@@ -19,26 +20,26 @@ func NewMockClient() *MockClient {
 	return &MockClient{fail: pegomock.GlobalFailHandler}
 }
 
-func (mock *MockClient) Version() *go_version.Version {
+func (mock *MockClient) Version() *version.Version {
 	if mock == nil {
 		panic("mock must not be nil. Use myMock := NewMockClient().")
 	}
 	params := []pegomock.Param{}
-	result := pegomock.GetGenericMockFrom(mock).Invoke("Version", params, []reflect.Type{reflect.TypeOf((**go_version.Version)(nil)).Elem()})
-	var ret0 *go_version.Version
+	result := pegomock.GetGenericMockFrom(mock).Invoke("Version", params, []reflect.Type{reflect.TypeOf((**version.Version)(nil)).Elem()})
+	var ret0 *version.Version
 	if len(result) != 0 {
 		if result[0] != nil {
-			ret0 = result[0].(*go_version.Version)
+			ret0 = result[0].(*version.Version)
 		}
 	}
 	return ret0
 }
 
-func (mock *MockClient) RunCommandWithVersion(log *logging.SimpleLogger, path string, args []string, v *go_version.Version, workspace string) (string, error) {
+func (mock *MockClient) RunCommandWithVersion(ctx context.Context, log *logging.SimpleLogger, path string, args []string, v *version.Version, workspace string) (string, error) {
 	if mock == nil {
 		panic("mock must not be nil. Use myMock := NewMockClient().")
 	}
-	params := []pegomock.Param{log, path, args, v, workspace}
+	params := []pegomock.Param{ctx, log, path, args, v, workspace}
 	result := pegomock.GetGenericMockFrom(mock).Invoke("RunCommandWithVersion", params, []reflect.Type{reflect.TypeOf((*string)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
 	var ret0 string
 	var ret1 error
@@ -53,6 +54,44 @@ func (mock *MockClient) RunCommandWithVersion(log *logging.SimpleLogger, path st
 	return ret0, ret1
 }
 
+func (mock *MockClient) RunCommandWithLogLevel(ctx context.Context, log *logging.SimpleLogger, path string, args []string, v *version.Version, workspace string, logLevel string, logPath string) (string, error) {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockClient().")
+	}
+	params := []pegomock.Param{ctx, log, path, args, v, workspace, logLevel, logPath}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("RunCommandWithLogLevel", params, []reflect.Type{reflect.TypeOf((*string)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 string
+	var ret1 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(string)
+		}
+		if result[1] != nil {
+			ret1 = result[1].(error)
+		}
+	}
+	return ret0, ret1
+}
+
+func (mock *MockClient) RunTerragruntCommand(ctx context.Context, log *logging.SimpleLogger, path string, args []string, v *version.Version, workspace string) (string, error) {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockClient().")
+	}
+	params := []pegomock.Param{ctx, log, path, args, v, workspace}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("RunTerragruntCommand", params, []reflect.Type{reflect.TypeOf((*string)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 string
+	var ret1 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(string)
+		}
+		if result[1] != nil {
+			ret1 = result[1].(error)
+		}
+	}
+	return ret0, ret1
+}
+
 func (mock *MockClient) VerifyWasCalledOnce() *VerifierClient {
 	return &VerifierClient{
 		mock:                   mock,
@@ -107,8 +146,8 @@ func (c *Client_Version_OngoingVerification) GetCapturedArguments() {
 func (c *Client_Version_OngoingVerification) GetAllCapturedArguments() {
 }
 
-func (verifier *VerifierClient) RunCommandWithVersion(log *logging.SimpleLogger, path string, args []string, v *go_version.Version, workspace string) *Client_RunCommandWithVersion_OngoingVerification {
-	params := []pegomock.Param{log, path, args, v, workspace}
+func (verifier *VerifierClient) RunCommandWithVersion(ctx context.Context, log *logging.SimpleLogger, path string, args []string, v *version.Version, workspace string) *Client_RunCommandWithVersion_OngoingVerification {
+	params := []pegomock.Param{ctx, log, path, args, v, workspace}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "RunCommandWithVersion", params, verifier.timeout)
 	return &Client_RunCommandWithVersion_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
 }
@@ -118,33 +157,139 @@ type Client_RunCommandWithVersion_OngoingVerification struct {
 	methodInvocations []pegomock.MethodInvocation
 }
 
-func (c *Client_RunCommandWithVersion_OngoingVerification) GetCapturedArguments() (*logging.SimpleLogger, string, []string, *go_version.Version, string) {
-	log, path, args, v, workspace := c.GetAllCapturedArguments()
-	return log[len(log)-1], path[len(path)-1], args[len(args)-1], v[len(v)-1], workspace[len(workspace)-1]
+func (c *Client_RunCommandWithVersion_OngoingVerification) GetCapturedArguments() (context.Context, *logging.SimpleLogger, string, []string, *version.Version, string) {
+	ctx, log, path, args, v, workspace := c.GetAllCapturedArguments()
+	return ctx[len(ctx)-1], log[len(log)-1], path[len(path)-1], args[len(args)-1], v[len(v)-1], workspace[len(workspace)-1]
 }
 
-func (c *Client_RunCommandWithVersion_OngoingVerification) GetAllCapturedArguments() (_param0 []*logging.SimpleLogger, _param1 []string, _param2 [][]string, _param3 []*go_version.Version, _param4 []string) {
+func (c *Client_RunCommandWithVersion_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []*logging.SimpleLogger, _param2 []string, _param3 [][]string, _param4 []*version.Version, _param5 []string) {
 	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
 	if len(params) > 0 {
-		_param0 = make([]*logging.SimpleLogger, len(params[0]))
+		_param0 = make([]context.Context, len(params[0]))
 		for u, param := range params[0] {
-			_param0[u] = param.(*logging.SimpleLogger)
+			_param0[u] = param.(context.Context)
 		}
-		_param1 = make([]string, len(params[1]))
+		_param1 = make([]*logging.SimpleLogger, len(params[1]))
 		for u, param := range params[1] {
-			_param1[u] = param.(string)
+			_param1[u] = param.(*logging.SimpleLogger)
 		}
-		_param2 = make([][]string, len(params[2]))
+		_param2 = make([]string, len(params[2]))
 		for u, param := range params[2] {
-			_param2[u] = param.([]string)
+			_param2[u] = param.(string)
 		}
-		_param3 = make([]*go_version.Version, len(params[3]))
+		_param3 = make([][]string, len(params[3]))
 		for u, param := range params[3] {
-			_param3[u] = param.(*go_version.Version)
+			_param3[u] = param.([]string)
 		}
-		_param4 = make([]string, len(params[4]))
+		_param4 = make([]*version.Version, len(params[4]))
 		for u, param := range params[4] {
-			_param4[u] = param.(string)
+			_param4[u] = param.(*version.Version)
+		}
+		_param5 = make([]string, len(params[5]))
+		for u, param := range params[5] {
+			_param5[u] = param.(string)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierClient) RunCommandWithLogLevel(ctx context.Context, log *logging.SimpleLogger, path string, args []string, v *version.Version, workspace string, logLevel string, logPath string) *Client_RunCommandWithLogLevel_OngoingVerification {
+	params := []pegomock.Param{ctx, log, path, args, v, workspace, logLevel, logPath}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "RunCommandWithLogLevel", params, verifier.timeout)
+	return &Client_RunCommandWithLogLevel_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type Client_RunCommandWithLogLevel_OngoingVerification struct {
+	mock              *MockClient
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *Client_RunCommandWithLogLevel_OngoingVerification) GetCapturedArguments() (context.Context, *logging.SimpleLogger, string, []string, *version.Version, string, string, string) {
+	ctx, log, path, args, v, workspace, logLevel, logPath := c.GetAllCapturedArguments()
+	return ctx[len(ctx)-1], log[len(log)-1], path[len(path)-1], args[len(args)-1], v[len(v)-1], workspace[len(workspace)-1], logLevel[len(logLevel)-1], logPath[len(logPath)-1]
+}
+
+func (c *Client_RunCommandWithLogLevel_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []*logging.SimpleLogger, _param2 []string, _param3 [][]string, _param4 []*version.Version, _param5 []string, _param6 []string, _param7 []string) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]context.Context, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(context.Context)
+		}
+		_param1 = make([]*logging.SimpleLogger, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(*logging.SimpleLogger)
+		}
+		_param2 = make([]string, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(string)
+		}
+		_param3 = make([][]string, len(params[3]))
+		for u, param := range params[3] {
+			_param3[u] = param.([]string)
+		}
+		_param4 = make([]*version.Version, len(params[4]))
+		for u, param := range params[4] {
+			_param4[u] = param.(*version.Version)
+		}
+		_param5 = make([]string, len(params[5]))
+		for u, param := range params[5] {
+			_param5[u] = param.(string)
+		}
+		_param6 = make([]string, len(params[6]))
+		for u, param := range params[6] {
+			_param6[u] = param.(string)
+		}
+		_param7 = make([]string, len(params[7]))
+		for u, param := range params[7] {
+			_param7[u] = param.(string)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierClient) RunTerragruntCommand(ctx context.Context, log *logging.SimpleLogger, path string, args []string, v *version.Version, workspace string) *Client_RunTerragruntCommand_OngoingVerification {
+	params := []pegomock.Param{ctx, log, path, args, v, workspace}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "RunTerragruntCommand", params, verifier.timeout)
+	return &Client_RunTerragruntCommand_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type Client_RunTerragruntCommand_OngoingVerification struct {
+	mock              *MockClient
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *Client_RunTerragruntCommand_OngoingVerification) GetCapturedArguments() (context.Context, *logging.SimpleLogger, string, []string, *version.Version, string) {
+	ctx, log, path, args, v, workspace := c.GetAllCapturedArguments()
+	return ctx[len(ctx)-1], log[len(log)-1], path[len(path)-1], args[len(args)-1], v[len(v)-1], workspace[len(workspace)-1]
+}
+
+func (c *Client_RunTerragruntCommand_OngoingVerification) GetAllCapturedArguments() (_param0 []context.Context, _param1 []*logging.SimpleLogger, _param2 []string, _param3 [][]string, _param4 []*version.Version, _param5 []string) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]context.Context, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(context.Context)
+		}
+		_param1 = make([]*logging.SimpleLogger, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(*logging.SimpleLogger)
+		}
+		_param2 = make([]string, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(string)
+		}
+		_param3 = make([][]string, len(params[3]))
+		for u, param := range params[3] {
+			_param3[u] = param.([]string)
+		}
+		_param4 = make([]*version.Version, len(params[4]))
+		for u, param := range params[4] {
+			_param4[u] = param.(*version.Version)
+		}
+		_param5 = make([]string, len(params[5]))
+		for u, param := range params[5] {
+			_param5[u] = param.(string)
 		}
 	}
 	return
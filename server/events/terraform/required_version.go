@@ -0,0 +1,127 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-version"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// releasesTTL is how long we cache the list of terraform releases for.
+const releasesTTL = time.Hour
+
+// requiredVersionRegex matches the required_version setting of a terraform
+// block, ex. `required_version = ">= 0.12, < 0.13"` => `>= 0.12, < 0.13`.
+// Lines where it's commented out are skipped. We match the text rather than
+// parsing the files because our HCL parser can't parse Terraform 0.12 syntax.
+var requiredVersionRegex = regexp.MustCompile(`(?m)^[^#/\n]*\brequired_version\s*=\s*"([^"]*)"`)
+
+// ResolveVersion returns the terraform version to run the project in
+// projAbsPath with and makes sure it's downloaded. If configured, the
+// project's terraform_version from atlantis.yaml, is set then it's used.
+// Otherwise, if the project's required_version isn't satisfied by our default
+// version, the newest release that satisfies it is used. It returns nil if
+// the default version should be used.
+func (c *DefaultClient) ResolveVersion(log *logging.SimpleLogger, projAbsPath string, configured *version.Version) (*version.Version, error) {
+	if configured != nil {
+		return configured, c.EnsureVersion(log, configured)
+	}
+
+	required, err := findRequiredVersion(projAbsPath)
+	if err != nil || required == "" {
+		return nil, err
+	}
+	constraints, err := version.NewConstraint(required)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing required_version %q", required)
+	}
+	if constraints.Check(c.defaultVersion) {
+		return nil, nil
+	}
+
+	releases, err := c.listReleases()
+	if err != nil {
+		return nil, errors.Wrapf(err, "listing terraform releases to find one matching required_version %q", required)
+	}
+	// Releases are sorted newest first.
+	for _, v := range releases {
+		if constraints.Check(v) {
+			log.Info("using terraform %s, the newest release matching required_version %q", v, required)
+			return v, c.EnsureVersion(log, v)
+		}
+	}
+	return nil, fmt.Errorf("no terraform release matches required_version %q", required)
+}
+
+// findRequiredVersion returns the required_version constraints set in the
+// .tf files in dir, joined with commas. If more than one file sets it, all
+// their constraints must be met. It returns an empty string if none do.
+func findRequiredVersion(dir string) (string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return "", err
+	}
+	var constraints []string
+	for _, file := range files {
+		contents, err := ioutil.ReadFile(file) // nolint: vetshadow
+		if err != nil {
+			return "", errors.Wrapf(err, "reading %s", file)
+		}
+		for _, match := range requiredVersionRegex.FindAllStringSubmatch(string(contents), -1) {
+			constraints = append(constraints, match[1])
+		}
+	}
+	return strings.Join(constraints, ", "), nil
+}
+
+// releasesIndex is the format of the index.json file listing all terraform
+// releases.
+type releasesIndex struct {
+	Versions map[string]interface{} `json:"versions"`
+}
+
+// listReleases returns all released terraform versions, newest first.
+// Pre-releases are skipped. The list is cached for releasesTTL.
+func (c *DefaultClient) listReleases() ([]*version.Version, error) {
+	c.releasesMu.Lock()
+	defer c.releasesMu.Unlock()
+	if c.releases != nil && time.Since(c.releasesFetchedAt) < releasesTTL {
+		return c.releases, nil
+	}
+
+	url := fmt.Sprintf("%s/terraform/index.json", c.downloadURL)
+	resp, err := c.downloadClient().Get(url) // #nosec
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+	var index releasesIndex
+	if err := json.NewDecoder(resp.Body).Decode(&index); err != nil {
+		return nil, errors.Wrapf(err, "parsing %s", url)
+	}
+
+	var releases []*version.Version
+	for s := range index.Versions {
+		v, err := version.NewVersion(s) // nolint: vetshadow
+		if err != nil || v.Prerelease() != "" {
+			continue
+		}
+		releases = append(releases, v)
+	}
+	sort.Sort(sort.Reverse(version.Collection(releases)))
+	c.releases = releases
+	c.releasesFetchedAt = time.Now()
+	return releases, nil
+}
@@ -0,0 +1,198 @@
+package terraform
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/hashicorp/go-version"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestFindRequiredVersion(t *testing.T) {
+	cases := []struct {
+		description string
+		files       map[string]string
+		exp         string
+	}{
+		{
+			"no required_version",
+			map[string]string{
+				"main.tf": `resource "null_resource" "a" {}`,
+			},
+			"",
+		},
+		{
+			"in a terraform block",
+			map[string]string{
+				"versions.tf": `
+terraform {
+  required_version = ">= 0.12, < 0.13"
+}`,
+			},
+			">= 0.12, < 0.13",
+		},
+		{
+			"set in more than one file",
+			map[string]string{
+				"a.tf": `terraform {
+  required_version = ">= 0.12"
+}`,
+				"b.tf": `terraform {
+	required_version="< 0.13"
+}`,
+			},
+			">= 0.12, < 0.13",
+		},
+		{
+			"only .tf files in the dir are read",
+			map[string]string{
+				"main.tf.bak":     `terraform { required_version = "0.11.0" }`,
+				"modules/main.tf": `terraform { required_version = "0.11.0" }`,
+			},
+			"",
+		},
+		{
+			"commented out",
+			map[string]string{
+				"main.tf": `terraform {
+  # required_version = "0.11.0"
+  // required_version = "0.11.0"
+}`,
+			},
+			"",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			dir, cleanup := writeFiles(t, c.files)
+			defer cleanup()
+			actual, err := findRequiredVersion(dir)
+			Ok(t, err)
+			Equals(t, c.exp, actual)
+		})
+	}
+}
+
+func TestResolveVersion(t *testing.T) {
+	cases := []struct {
+		description string
+		configured  string
+		required    string
+		exp         string
+		expErr      string
+	}{
+		{
+			description: "terraform_version is used over required_version",
+			configured:  "0.12.3",
+			required:    "0.13.0",
+			exp:         "0.12.3",
+		},
+		{
+			description: "no required_version uses the default version",
+			exp:         "",
+		},
+		{
+			description: "default version satisfies required_version",
+			required:    ">= 0.11",
+			exp:         "",
+		},
+		{
+			description: "newest matching release is used",
+			required:    "~> 0.12.0",
+			exp:         "0.12.10",
+		},
+		{
+			description: "pre-releases are skipped",
+			required:    "< 0.12.3, > 0.11.14",
+			expErr:      `no terraform release matches required_version "< 0.12.3, > 0.11.14"`,
+		},
+		{
+			description: "invalid required_version",
+			required:    "nope",
+			expErr:      `parsing required_version "nope": Malformed constraint: nope`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			var requests []string
+			server := newReleasesServer(t, &requests, "")
+			defer server.Close()
+
+			files := map[string]string{"main.tf": ""}
+			if c.required != "" {
+				files["main.tf"] = fmt.Sprintf("terraform {\n  required_version = %q\n}\n", c.required)
+			}
+			dir, cleanup := writeFiles(t, files)
+			defer cleanup()
+			client := &DefaultClient{
+				defaultVersion: version.Must(version.NewVersion("0.11.10")),
+				binDir:         filepath.Join(dir, binDirName),
+				downloadURL:    server.URL,
+				releaseKeyring: testKeyring,
+			}
+			var configured *version.Version
+			if c.configured != "" {
+				configured = version.Must(version.NewVersion(c.configured))
+			}
+
+			actual, err := client.ResolveVersion(logging.NewNoopLogger(), dir, configured)
+			if c.expErr != "" {
+				ErrEquals(t, c.expErr, err)
+				return
+			}
+			Ok(t, err)
+			if c.exp == "" {
+				Assert(t, actual == nil, "exp default version but got %s", actual)
+				return
+			}
+			Equals(t, c.exp, actual.String())
+			Assert(t, fileExists(filepath.Join(dir, binDirName, "terraform"+c.exp)), "exp terraform %s to be downloaded", c.exp)
+		})
+	}
+}
+
+// The list of releases should only be fetched once.
+func TestResolveVersion_CachesReleases(t *testing.T) {
+	var requests []string
+	server := newReleasesServer(t, &requests, "")
+	defer server.Close()
+	dir, cleanup := writeFiles(t, map[string]string{
+		"main.tf": `terraform { required_version = "0.13.0" }`,
+	})
+	defer cleanup()
+	client := &DefaultClient{
+		defaultVersion: version.Must(version.NewVersion("0.11.10")),
+		binDir:         filepath.Join(dir, binDirName),
+		downloadURL:    server.URL,
+		releaseKeyring: testKeyring,
+	}
+
+	for i := 0; i < 2; i++ {
+		v, err := client.ResolveVersion(logging.NewNoopLogger(), dir, nil)
+		Ok(t, err)
+		Equals(t, "0.13.0", v.String())
+	}
+	Equals(t, []string{
+		"/terraform/index.json",
+		"/terraform/0.13.0/terraform_0.13.0_SHA256SUMS",
+		"/terraform/0.13.0/terraform_0.13.0_SHA256SUMS.sig",
+		fmt.Sprintf("/terraform/0.13.0/terraform_0.13.0_%s_%s.zip", runtime.GOOS, runtime.GOARCH),
+	}, requests)
+}
+
+// writeFiles creates a temp dir containing files, keyed by their path
+// relative to the dir.
+func writeFiles(t *testing.T, files map[string]string) (string, func()) {
+	dir, cleanup := TempDir(t)
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		Ok(t, os.MkdirAll(filepath.Dir(path), 0700))
+		Ok(t, ioutil.WriteFile(path, []byte(content), 0600))
+	}
+	return dir, cleanup
+}
@@ -15,13 +15,17 @@
 package terraform
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/mitchellh/go-linereader"
 
@@ -29,18 +33,52 @@ import (
 	"github.com/mitchellh/go-homedir"
 	"github.com/pkg/errors"
 	"github.com/runatlantis/atlantis/server/logging"
+	"golang.org/x/crypto/openpgp"
 )
 
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_terraform_client.go Client
 
 type Client interface {
 	Version() *version.Version
-	RunCommandWithVersion(log *logging.SimpleLogger, path string, args []string, v *version.Version, workspace string) (string, error)
+	RunCommandWithVersion(ctx context.Context, log *logging.SimpleLogger, path string, args []string, v *version.Version, workspace string) (string, error)
+	RunCommandWithLogLevel(ctx context.Context, log *logging.SimpleLogger, path string, args []string, v *version.Version, workspace string, logLevel string, logPath string) (string, error)
+	RunTerragruntCommand(ctx context.Context, log *logging.SimpleLogger, path string, args []string, v *version.Version, workspace string) (string, error)
 }
 
 type DefaultClient struct {
-	defaultVersion          *version.Version
+	defaultVersion *version.Version
+	// terraformPluginCacheDir is where providers are cached between inits.
+	// If it's empty the cache is disabled.
 	terraformPluginCacheDir string
+	// pluginCacheMu ensures only one init writes to the plugin cache at a
+	// time.
+	pluginCacheMu sync.Mutex
+	// binDir is where terraform versions downloaded by EnsureVersion are
+	// stored.
+	binDir string
+	// downloadURL is the base URL terraform releases are downloaded from.
+	downloadURL string
+	// httpClient downloads terraform releases. If it's nil,
+	// http.DefaultClient is used.
+	httpClient *http.Client
+	// releaseKeyring holds the keys that the checksums of downloaded
+	// releases must be signed by.
+	releaseKeyring openpgp.EntityList
+	// downloadMu ensures only one version is downloaded at a time.
+	downloadMu sync.Mutex
+	// releases caches the versions listed by listReleases until
+	// releasesFetchedAt + releasesTTL.
+	releases          []*version.Version
+	releasesFetchedAt time.Time
+	releasesMu        sync.Mutex
+	// docker is how to run terraform in containers. If it's nil, terraform
+	// is run on the host.
+	docker *DockerConfig
+	// kubernetes is how to run terraform as Kubernetes Jobs. If it's nil,
+	// terraform isn't run as Jobs.
+	kubernetes *KubernetesConfig
+	// rcFile is the .terraformrc we generated with the TFE token, if any.
+	rcFile string
 }
 
 const terraformPluginCacheDirName = "plugin-cache"
@@ -53,13 +91,30 @@ const terraformPluginCacheDirName = "plugin-cache"
 //	   => 0.11.10
 var versionRegex = regexp.MustCompile("Terraform v(.*?)(\\s.*)?\n")
 
-func NewClient(dataDir string, tfeToken string) (*DefaultClient, error) {
-	_, err := exec.LookPath("terraform")
-	if err != nil {
+// NewClient returns a client for the terraform in our $PATH. Other versions
+// are downloaded with httpClient from downloadURL or DefaultDownloadURL if
+// it's empty. If
+// usePluginCache is true, providers are cached in dataDir so each init
+// doesn't download them again. If docker is set, terraform is run in
+// containers, including to find its version, rather than on the host. If
+// kubernetes is set, terraform is run as Kubernetes Jobs; its version is still
+// found from the terraform in our $PATH.
+func NewClient(dataDir string, tfeToken string, downloadURL string, httpClient *http.Client, usePluginCache bool, docker *DockerConfig, kubernetes *KubernetesConfig) (*DefaultClient, error) {
+	if kubernetes != nil {
+		if _, err := exec.LookPath("kubectl"); err != nil {
+			return nil, errors.New("kubectl not found in $PATH. It's needed to run terraform as Kubernetes Jobs")
+		}
+	}
+	versionCmd := exec.Command("terraform", "version") // #nosec
+	if docker != nil {
+		if _, err := exec.LookPath("docker"); err != nil {
+			return nil, errors.New("docker not found in $PATH. It's needed to run terraform in containers")
+		}
+		versionCmd = exec.Command("docker", "run", "--rm", "--entrypoint", "terraform", docker.Image, "version") // #nosec
+	} else if _, err := exec.LookPath("terraform"); err != nil {
 		return nil, errors.New("terraform not found in $PATH. \n\nDownload terraform from https://www.terraform.io/downloads.html")
 	}
-	versionOutBytes, err := exec.Command("terraform", "version").
-		Output() // #nosec
+	versionOutBytes, err := versionCmd.Output()
 	versionOutput := string(versionOutBytes)
 	if err != nil {
 		return nil, errors.Wrapf(err, "running terraform version: %s", versionOutput)
@@ -74,6 +129,7 @@ func NewClient(dataDir string, tfeToken string) (*DefaultClient, error) {
 	}
 
 	// If tfeToken is set, we try to create a ~/.terraformrc file.
+	var rcFile string
 	if tfeToken != "" {
 		home, err := homedir.Dir()
 		if err != nil {
@@ -82,18 +138,37 @@ func NewClient(dataDir string, tfeToken string) (*DefaultClient, error) {
 		if err := generateRCFile(tfeToken, home); err != nil {
 			return nil, err
 		}
+		rcFile = filepath.Join(home, ".terraformrc")
 	}
 
 	// We will run terraform with the TF_PLUGIN_CACHE_DIR env var set to this
 	// directory inside our data dir.
-	cacheDir := filepath.Join(dataDir, terraformPluginCacheDirName)
-	if err := os.MkdirAll(cacheDir, 0700); err != nil {
-		return nil, errors.Wrapf(err, "unable to create terraform plugin cache directory at %q", terraformPluginCacheDirName)
+	var cacheDir string
+	if usePluginCache {
+		cacheDir = filepath.Join(dataDir, terraformPluginCacheDirName)
+		if err := os.MkdirAll(cacheDir, 0700); err != nil {
+			return nil, errors.Wrapf(err, "unable to create terraform plugin cache directory at %q", terraformPluginCacheDirName)
+		}
+	}
+
+	keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(hashicorpReleaseKey))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading HashiCorp's release key")
+	}
+	if downloadURL == "" {
+		downloadURL = DefaultDownloadURL
 	}
 
 	return &DefaultClient{
 		defaultVersion:          v,
 		terraformPluginCacheDir: cacheDir,
+		binDir:                  filepath.Join(dataDir, binDirName),
+		downloadURL:             strings.TrimSuffix(downloadURL, "/"),
+		httpClient:              httpClient,
+		releaseKeyring:          keyring,
+		docker:                  docker,
+		kubernetes:              kubernetes,
+		rcFile:                  rcFile,
 	}, nil
 }
 
@@ -136,13 +211,69 @@ func (c *DefaultClient) Version() *version.Version {
 // If v is nil, will use the default version.
 // Workspace is the terraform workspace to run in. We won't switch workspaces
 // but will set the TERRAFORM_WORKSPACE environment variable.
-func (c *DefaultClient) RunCommandWithVersion(log *logging.SimpleLogger, path string, args []string, v *version.Version, workspace string) (string, error) {
+// If ctx is done before terraform exits, terraform is interrupted. ctx can be nil
+// if the command can't be cancelled.
+func (c *DefaultClient) RunCommandWithVersion(ctx context.Context, log *logging.SimpleLogger, path string, args []string, v *version.Version, workspace string) (string, error) {
+	return c.runCommand(ctx, log, path, args, v, workspace, nil, false)
+}
+
+// RunCommandWithLogLevel is the same as RunCommandWithVersion except that it
+// also runs terraform with TF_LOG set to logLevel. The log terraform writes is
+// redacted and then written to logPath rather than being mixed into the
+// returned output.
+func (c *DefaultClient) RunCommandWithLogLevel(ctx context.Context, log *logging.SimpleLogger, path string, args []string, v *version.Version, workspace string, logLevel string, logPath string) (string, error) {
+	// In a container, terraform can only write the log inside the repo.
+	var rawLogDir string
+	if c.docker != nil || c.kubernetes != nil {
+		rawLogDir = path
+	}
+	rawLog, err := ioutil.TempFile(rawLogDir, ".atlantis-tf-log")
+	if err != nil {
+		return "", errors.Wrap(err, "creating file for terraform log")
+	}
+	rawLog.Close()                 // nolint: errcheck
+	defer os.Remove(rawLog.Name()) // nolint: errcheck
+
+	out, runErr := c.runCommand(ctx, log, path, args, v, workspace, []string{
+		fmt.Sprintf("TF_LOG=%s", logLevel),
+		fmt.Sprintf("TF_LOG_PATH=%s", rawLog.Name()),
+	}, false)
+
+	// We still want to surface the log if terraform failed since that's
+	// usually when it's needed.
+	tfLog, err := ioutil.ReadFile(rawLog.Name())
+	if err != nil {
+		log.Err("unable to read terraform log: %s", err)
+	} else if err := ioutil.WriteFile(logPath, []byte(RedactLog(string(tfLog))), 0600); err != nil {
+		log.Err("unable to write terraform log to %q: %s", logPath, err)
+	}
+	return out, runErr
+}
+
+// RunTerragruntCommand runs terragrunt with the provided args in path. The
+// environment is the same as for RunCommandWithVersion and terragrunt is told
+// to run version v of terraform, or the default version if v is nil.
+func (c *DefaultClient) RunTerragruntCommand(ctx context.Context, log *logging.SimpleLogger, path string, args []string, v *version.Version, workspace string) (string, error) {
+	if _, err := exec.LookPath("terragrunt"); err != nil && c.docker == nil && c.kubernetes == nil {
+		return "", errors.New("terragrunt not found in $PATH. \n\nDownload terragrunt from https://terragrunt.gruntwork.io/docs/getting-started/install/")
+	}
+	return c.runCommand(ctx, log, path, args, v, workspace, nil, true)
+}
+
+// runCommand runs terraform, or terragrunt if terragrunt is true. extraEnv are
+// environment variables that take precedence over any of the same name in
+// Atlantis's environment.
+func (c *DefaultClient) runCommand(ctx context.Context, log *logging.SimpleLogger, path string, args []string, v *version.Version, workspace string, extraEnv []string, terragrunt bool) (string, error) {
 	tfExecutable := "terraform"
 	tfVersionStr := c.defaultVersion.String()
 	// if version is the same as the default, don't need to prepend the version name to the executable
 	if v != nil && !v.Equal(c.defaultVersion) {
 		tfExecutable = fmt.Sprintf("%s%s", tfExecutable, v.String())
 		tfVersionStr = v.String()
+		// Prefer versions we've downloaded over searching the $PATH.
+		if downloaded := filepath.Join(c.binDir, tfExecutable); c.binDir != "" && fileExists(downloaded) {
+			tfExecutable = downloaded
+		}
 	}
 
 	// We add custom variables so that if `extra_args` is specified with env
@@ -150,19 +281,53 @@ func (c *DefaultClient) RunCommandWithVersion(log *logging.SimpleLogger, path st
 	envVars := []string{
 		// Will de-emphasize specific commands to run in output.
 		"TF_IN_AUTOMATION=true",
-		// Cache plugins so terraform init runs faster.
-		fmt.Sprintf("TF_PLUGIN_CACHE_DIR=%s", c.terraformPluginCacheDir),
 		fmt.Sprintf("WORKSPACE=%s", workspace),
 		fmt.Sprintf("ATLANTIS_TERRAFORM_VERSION=%s", tfVersionStr),
 		fmt.Sprintf("DIR=%s", path),
 	}
+	if c.terraformPluginCacheDir != "" {
+		// Cache plugins so terraform init runs faster.
+		envVars = append(envVars, fmt.Sprintf("TF_PLUGIN_CACHE_DIR=%s", c.terraformPluginCacheDir))
+		// Terraform doesn't lock the cache so concurrent inits can corrupt
+		// it, ex. by one reading a provider while another is writing it.
+		if len(args) > 0 && args[0] == "init" {
+			c.pluginCacheMu.Lock()
+			defer c.pluginCacheMu.Unlock()
+		}
+	}
+	// These are the only variables passed into containers.
+	tfEnv := append([]string{}, envVars...)
+	tfEnv = append(tfEnv, Env(ctx)...)
+	tfEnv = append(tfEnv, extraEnv...)
 	// Append current Atlantis process's environment variables so PATH is
 	// preserved and any vars that users purposely exec'd Atlantis with.
 	envVars = append(envVars, os.Environ()...)
+	// Later values win so extraEnv overrides anything already set.
+	envVars = append(envVars, Env(ctx)...)
+	envVars = append(envVars, extraEnv...)
+
+	executable := tfExecutable
+	if terragrunt {
+		// Terragrunt runs terraform itself so we point it at the version we
+		// would have run.
+		executable = "terragrunt"
+		tfPath := fmt.Sprintf("TERRAGRUNT_TFPATH=%s", tfExecutable)
+		envVars = append(envVars, tfPath)
+		tfEnv = append(tfEnv, tfPath)
+	}
 
 	// append terraform executable name with args
-	tfCmd := fmt.Sprintf("%s %s", tfExecutable, strings.Join(args, " "))
-	out, err := c.crashSafeExec(tfCmd, path, envVars)
+	tfCmd := fmt.Sprintf("%s %s", executable, strings.Join(args, " "))
+	var out string
+	var err error
+	if c.kubernetes != nil {
+		if stdin(ctx) != nil {
+			return "", fmt.Errorf("%q needs input so it can't be run as a Kubernetes Job", tfCmd)
+		}
+		out, err = c.runJob(ctx, log, tfCmd, path, envVars, tfEnv)
+	} else {
+		out, err = c.crashSafeExec(ctx, c.command(tfCmd, path, envVars, tfEnv, stdin(ctx)))
+	}
 	if err != nil {
 		err = fmt.Errorf("%s: running %q in %q", err, tfCmd, path)
 		log.Debug("error: %s", err)
@@ -172,8 +337,8 @@ func (c *DefaultClient) RunCommandWithVersion(log *logging.SimpleLogger, path st
 	return out, err
 }
 
-// crashSafeExec executes tfCmd in dir with the env environment variables. It
-// returns any stderr and stdout output from the command as a combined string.
+// crashSafeExec runs cmd, which runs terraform. It returns any stderr and
+// stdout output from the command as a combined string.
 // It is "crash safe" in that it handles an edge case related to:
 //    https://github.com/golang/go/issues/18874
 // where when terraform itself panics, it leaves file descriptors open which
@@ -188,43 +353,43 @@ func (c *DefaultClient) RunCommandWithVersion(log *logging.SimpleLogger, path st
 // our pipe during a terraform panic and so again, we're left waiting
 // indefinitely. To handle this, I've hacked in detection of Terraform panic
 // output as a special case that causes us to exit the loop.
-func (c *DefaultClient) crashSafeExec(tfCmd string, dir string, env []string) (string, error) {
+func (c *DefaultClient) crashSafeExec(ctx context.Context, cmd *exec.Cmd) (string, error) {
 	pr, pw, err := os.Pipe()
 	if err != nil {
 		return "", errors.Wrap(err, "failed to initialize pipe for output")
 	}
-
-	// We use 'sh -c' so that if extra_args have been specified with env vars,
-	// ex. -var-file=$WORKSPACE.tfvars, then they get substituted.
-	cmd := exec.Command("sh", "-c", tfCmd) // #nosec
 	cmd.Stdout = pw
 	cmd.Stderr = pw
-	cmd.Dir = dir
-	cmd.Env = env
-
-	err = cmd.Start()
-	if err == nil {
-		err = cmd.Wait()
-	}
-	pw.Close() // nolint: errcheck
 
+	// We read the output while the command runs so that it can be streamed
+	// to the function set with WithOutputFunc.
+	onLine := OutputFunc(ctx)
 	lr := linereader.New(pr)
-	var outputLines []string
-	for line := range lr.Ch {
-		outputLines = append(outputLines, line)
-		// This checks if our output is a Terraform panic. If so, we break
-		// out of the loop because in this case, for some reason to do with
-		// terraform forking itself, we never receive an EOF and
-		// so this will block indefinitely.
-		if len(outputLines) >= 3 &&
-			strings.Join(
-				outputLines[len(outputLines)-3:], "\n") ==
-				tfCrashDelim {
-			break
+	done := make(chan []string, 1)
+	go func() {
+		var outputLines []string
+		for line := range lr.Ch {
+			outputLines = append(outputLines, line)
+			if onLine != nil {
+				onLine(line)
+			}
+			// This checks if our output is a Terraform panic. If so, we break
+			// out of the loop because in this case, for some reason to do with
+			// terraform forking itself, we never receive an EOF and
+			// so this will block indefinitely.
+			if len(outputLines) >= 3 &&
+				strings.Join(
+					outputLines[len(outputLines)-3:], "\n") ==
+					tfCrashDelim {
+				break
+			}
 		}
-	}
+		done <- outputLines
+	}()
 
-	return strings.Join(outputLines, "\n"), err
+	err = RunCancellable(ctx, cmd)
+	pw.Close() // nolint: errcheck
+	return strings.Join(<-done, "\n"), err
 }
 
 // MustConstraint will parse one or more constraints from the given
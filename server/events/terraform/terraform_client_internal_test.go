@@ -1,11 +1,18 @@
 package terraform
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/hashicorp/go-version"
+	"github.com/runatlantis/atlantis/server/logging"
 	. "github.com/runatlantis/atlantis/testing"
 )
 
@@ -106,7 +113,7 @@ func TestCrashSafeExec(t *testing.T) {
 		t.Run(c.cmd, func(t *testing.T) {
 			tmp, cleanup := TempDir(t)
 			defer cleanup()
-			out, err := client.crashSafeExec(c.cmd, tmp, nil)
+			out, err := client.crashSafeExec(nil, client.command(c.cmd, tmp, nil, nil, nil))
 			if c.expErr != "" {
 				ErrEquals(t, c.expErr, err)
 				Equals(t, c.expOut, out)
@@ -117,3 +124,270 @@ func TestCrashSafeExec(t *testing.T) {
 		})
 	}
 }
+
+// The output should be passed to the function set with WithOutputFunc while
+// the command is still running.
+func TestCrashSafeExec_StreamsOutput(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	var lines []string
+	ctx := WithOutputFunc(context.Background(), func(line string) {
+		lines = append(lines, line)
+		// The command waits for this file before it outputs its second line.
+		if line == "first" {
+			Ok(t, ioutil.WriteFile(filepath.Join(tmp, "continue"), nil, 0600))
+		}
+	})
+
+	client := DefaultClient{}
+	cmd := "echo first && i=0 && while [ ! -f continue ] && [ $i -lt 500 ]; do sleep 0.01; i=$((i+1)); done && [ -f continue ] && echo second"
+	out, err := client.crashSafeExec(ctx, client.command(cmd, tmp, nil, nil, nil))
+	Ok(t, err)
+	Equals(t, "first\nsecond", out)
+	Equals(t, []string{"first", "second"}, lines)
+}
+
+// Commands should read the input set with WithStdin.
+func TestCrashSafeExec_Stdin(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	ctx := WithStdin(context.Background(), strings.NewReader("yes\n"))
+	client := DefaultClient{}
+	out, err := client.crashSafeExec(ctx, client.command("read answer && echo \"got $answer\"", tmp, nil, nil, stdin(ctx)))
+	Ok(t, err)
+	Equals(t, "got yes", out)
+}
+
+// Terragrunt should be run with TERRAGRUNT_TFPATH set to the terraform
+// version it would have been run with.
+func TestRunTerragruntCommand(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	script := "#!/bin/sh\necho \"$TERRAGRUNT_TFPATH $WORKSPACE $@\"\n"
+	Ok(t, ioutil.WriteFile(filepath.Join(tmp, "terragrunt"), []byte(script), 0700)) // nolint: gosec
+	Ok(t, ioutil.WriteFile(filepath.Join(tmp, "terraform0.12.1"), nil, 0700))       // nolint: gosec
+	origPath := os.Getenv("PATH")
+	defer os.Setenv("PATH", origPath) // nolint: errcheck
+	Ok(t, os.Setenv("PATH", fmt.Sprintf("%s:%s", tmp, origPath)))
+
+	defaultVersion, _ := version.NewVersion("0.11.14")
+	client := DefaultClient{defaultVersion: defaultVersion, binDir: tmp}
+	logger := logging.NewNoopLogger()
+
+	out, err := client.RunTerragruntCommand(nil, logger, tmp, []string{"plan", "-no-color"}, nil, "staging")
+	Ok(t, err)
+	Equals(t, "terraform staging plan -no-color", out)
+
+	v, _ := version.NewVersion("0.12.1")
+	out, err = client.RunTerragruntCommand(nil, logger, tmp, []string{"apply"}, v, "default")
+	Ok(t, err)
+	Equals(t, filepath.Join(tmp, "terraform0.12.1")+" default apply", out)
+}
+
+// Inits should run one at a time while the plugin cache is enabled.
+func TestRunCommand_InitsDontOverlapWithPluginCache(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	lockDir := filepath.Join(tmp, "lock")
+	script := fmt.Sprintf("#!/bin/sh\nmkdir %s || exit 1\nsleep 0.2\nrmdir %s\necho \"$TF_PLUGIN_CACHE_DIR\"\n", lockDir, lockDir)
+	Ok(t, ioutil.WriteFile(filepath.Join(tmp, "terraform"), []byte(script), 0700)) // nolint: gosec
+	origPath := os.Getenv("PATH")
+	defer os.Setenv("PATH", origPath) // nolint: errcheck
+	Ok(t, os.Setenv("PATH", fmt.Sprintf("%s:%s", tmp, origPath)))
+
+	defaultVersion, _ := version.NewVersion("0.11.14")
+	client := DefaultClient{defaultVersion: defaultVersion, terraformPluginCacheDir: "/cache"}
+	logger := logging.NewNoopLogger()
+
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			out, err := client.RunCommandWithVersion(nil, logger, tmp, []string{"init"}, nil, "default")
+			if err == nil && out != "/cache" {
+				err = fmt.Errorf("exp TF_PLUGIN_CACHE_DIR to be set, got %q", out)
+			}
+			errs <- err
+		}()
+	}
+	for i := 0; i < 3; i++ {
+		Ok(t, <-errs)
+	}
+}
+
+func TestRunCommand_NoPluginCache(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	script := "#!/bin/sh\necho \"cache=$TF_PLUGIN_CACHE_DIR\"\n"
+	Ok(t, ioutil.WriteFile(filepath.Join(tmp, "terraform"), []byte(script), 0700)) // nolint: gosec
+	origPath := os.Getenv("PATH")
+	defer os.Setenv("PATH", origPath) // nolint: errcheck
+	Ok(t, os.Setenv("PATH", fmt.Sprintf("%s:%s", tmp, origPath)))
+	Ok(t, os.Unsetenv("TF_PLUGIN_CACHE_DIR"))
+
+	defaultVersion, _ := version.NewVersion("0.11.14")
+	client := DefaultClient{defaultVersion: defaultVersion}
+	out, err := client.RunCommandWithVersion(nil, logging.NewNoopLogger(), tmp, []string{"init"}, nil, "default")
+	Ok(t, err)
+	Equals(t, "cache=", out)
+}
+
+// In docker mode terraform should be run in a container with the repo
+// mounted and Atlantis's variables passed through docker's environment.
+func TestRunCommand_Docker(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	script := "#!/bin/sh\necho \"$@\"\necho \"WORKSPACE=$WORKSPACE\"\n"
+	Ok(t, ioutil.WriteFile(filepath.Join(tmp, "docker"), []byte(script), 0700)) // nolint: gosec
+	origPath := os.Getenv("PATH")
+	defer os.Setenv("PATH", origPath) // nolint: errcheck
+	Ok(t, os.Setenv("PATH", fmt.Sprintf("%s:%s", tmp, origPath)))
+	repoDir := filepath.Join(tmp, "repo")
+	projectDir := filepath.Join(repoDir, "project")
+	Ok(t, os.MkdirAll(filepath.Join(repoDir, ".git"), 0700))
+	Ok(t, os.MkdirAll(projectDir, 0700))
+
+	defaultVersion, _ := version.NewVersion("0.11.14")
+	client := DefaultClient{
+		defaultVersion:          defaultVersion,
+		terraformPluginCacheDir: "/cache",
+		docker: &DockerConfig{
+			Image:  "hashicorp/terraform",
+			Mounts: []string{"/creds:/tmp/creds:ro"},
+			CPUs:   "1.5",
+			Memory: "2g",
+		},
+	}
+	out, err := client.RunCommandWithVersion(nil, logging.NewNoopLogger(), projectDir, []string{"plan", "-var-file=$WORKSPACE.tfvars"}, nil, "staging")
+	Ok(t, err)
+	expArgs := fmt.Sprintf("run --rm --init --user %d:%d --workdir %s --entrypoint sh -e HOME=/tmp", os.Getuid(), os.Getgid(), projectDir) +
+		fmt.Sprintf(" -v %s:%s -v /cache:/cache -v /creds:/tmp/creds:ro --cpus 1.5 --memory 2g", repoDir, repoDir) +
+		" -e TF_IN_AUTOMATION -e WORKSPACE -e ATLANTIS_TERRAFORM_VERSION -e DIR -e TF_PLUGIN_CACHE_DIR" +
+		" hashicorp/terraform -c terraform plan -var-file=$WORKSPACE.tfvars"
+	Equals(t, expArgs+"\nWORKSPACE=staging", out)
+}
+
+func TestRepoRoot(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	Ok(t, os.MkdirAll(filepath.Join(tmp, "repo", ".git"), 0700))
+	Ok(t, os.MkdirAll(filepath.Join(tmp, "repo", "a", "b"), 0700))
+	Equals(t, filepath.Join(tmp, "repo"), repoRoot(filepath.Join(tmp, "repo", "a", "b")))
+	Equals(t, filepath.Join(tmp, "repo"), repoRoot(filepath.Join(tmp, "repo")))
+	Equals(t, tmp, repoRoot(tmp))
+}
+
+// In kubernetes mode terraform should be run as a Job whose logs are streamed
+// back and which is deleted once it finishes.
+func TestRunCommand_Kubernetes(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	script := fmt.Sprintf(`#!/bin/sh
+echo "$@" >> %[1]s/calls
+case "$*" in
+  *create*) cat > %[1]s/manifest.json ;;
+  *logs*) echo "Plan: 1 to add" ;;
+  *get*) cat %[1]s/status ;;
+esac
+`, tmp)
+	Ok(t, ioutil.WriteFile(filepath.Join(tmp, "kubectl"), []byte(script), 0700)) // nolint: gosec
+	origPath := os.Getenv("PATH")
+	defer os.Setenv("PATH", origPath) // nolint: errcheck
+	Ok(t, os.Setenv("PATH", fmt.Sprintf("%s:%s", tmp, origPath)))
+	origInterval := jobPollInterval
+	defer func() { jobPollInterval = origInterval }()
+	jobPollInterval = time.Millisecond
+
+	dataDir := filepath.Join(tmp, "data")
+	repoDir := filepath.Join(dataDir, "repos", "owner", "repo", "1", "default")
+	projectDir := filepath.Join(repoDir, "project")
+	Ok(t, os.MkdirAll(filepath.Join(repoDir, ".git"), 0700))
+	Ok(t, os.MkdirAll(projectDir, 0700))
+	defaultVersion, _ := version.NewVersion("0.11.14")
+	client := DefaultClient{
+		defaultVersion:          defaultVersion,
+		terraformPluginCacheDir: filepath.Join(dataDir, "plugin-cache"),
+		kubernetes: &KubernetesConfig{
+			Namespace:       "atlantis",
+			Image:           "hashicorp/terraform",
+			DataPVC:         "atlantis-data",
+			DataDir:         dataDir,
+			CloneRoot:       filepath.Join(dataDir, "repos"),
+			ServiceAccounts: map[string]string{"owner/repo": "repo-sa", "*": "default-sa"},
+		},
+	}
+	logger := logging.NewNoopLogger()
+
+	t.Log("the job succeeds")
+	Ok(t, ioutil.WriteFile(filepath.Join(tmp, "status"), []byte("1,"), 0600))
+	var lines []string
+	ctx := WithOutputFunc(context.Background(), func(line string) { lines = append(lines, line) })
+	out, err := client.RunCommandWithVersion(ctx, logger, projectDir, []string{"plan"}, nil, "default")
+	Ok(t, err)
+	Equals(t, "Plan: 1 to add", out)
+	Equals(t, []string{"Plan: 1 to add"}, lines)
+
+	calls, err := ioutil.ReadFile(filepath.Join(tmp, "calls"))
+	Ok(t, err)
+	callLines := strings.Split(strings.TrimSpace(string(calls)), "\n")
+	Equals(t, 4, len(callLines))
+	Equals(t, "--namespace atlantis create -f -", callLines[0])
+	Assert(t, strings.HasPrefix(callLines[1], "--namespace atlantis logs --follow --pod-running-timeout=10m job/atlantis-terraform-"), "got %q", callLines[1])
+	Assert(t, strings.HasPrefix(callLines[2], "--namespace atlantis get job atlantis-terraform-"), "got %q", callLines[2])
+	Assert(t, strings.HasPrefix(callLines[3], "--namespace atlantis delete job atlantis-terraform-"), "got %q", callLines[3])
+
+	var manifest struct {
+		Spec struct {
+			BackoffLimit int `json:"backoffLimit"`
+			Template     struct {
+				Spec struct {
+					ServiceAccountName string `json:"serviceAccountName"`
+					Containers         []struct {
+						Image        string              `json:"image"`
+						Command      []string            `json:"command"`
+						WorkingDir   string              `json:"workingDir"`
+						Env          []map[string]string `json:"env"`
+						VolumeMounts []map[string]string `json:"volumeMounts"`
+					} `json:"containers"`
+					Volumes []struct {
+						PersistentVolumeClaim map[string]string `json:"persistentVolumeClaim"`
+					} `json:"volumes"`
+				} `json:"spec"`
+			} `json:"template"`
+		} `json:"spec"`
+	}
+	manifestBytes, err := ioutil.ReadFile(filepath.Join(tmp, "manifest.json"))
+	Ok(t, err)
+	Ok(t, json.Unmarshal(manifestBytes, &manifest))
+	podSpec := manifest.Spec.Template.Spec
+	Equals(t, 0, manifest.Spec.BackoffLimit)
+	Equals(t, "repo-sa", podSpec.ServiceAccountName)
+	Equals(t, "atlantis-data", podSpec.Volumes[0].PersistentVolumeClaim["claimName"])
+	container := podSpec.Containers[0]
+	Equals(t, "hashicorp/terraform", container.Image)
+	Equals(t, []string{"sh", "-c", "terraform plan"}, container.Command)
+	Equals(t, projectDir, container.WorkingDir)
+	Assert(t, len(container.Env) > 2 && container.Env[2]["name"] == "WORKSPACE" && container.Env[2]["value"] == "default", "got env %v", container.Env)
+	Equals(t, []map[string]string{
+		{"name": "data", "mountPath": repoDir, "subPath": "repos/owner/repo/1/default"},
+		{"name": "data", "mountPath": filepath.Join(dataDir, "plugin-cache"), "subPath": "plugin-cache"},
+	}, container.VolumeMounts)
+
+	t.Log("the job fails")
+	Ok(t, ioutil.WriteFile(filepath.Join(tmp, "status"), []byte(",1"), 0600))
+	_, err = client.RunCommandWithVersion(nil, logger, projectDir, []string{"plan"}, nil, "default")
+	Assert(t, err != nil && strings.Contains(err.Error(), "failed"), "exp job to fail, got %v", err)
+}
+
+func TestKubernetesConfig_ServiceAccount(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	cloneRoot := filepath.Join(tmp, "repos")
+	for _, repo := range []string{"group/sub/repo", "owner/other"} {
+		Ok(t, os.MkdirAll(filepath.Join(cloneRoot, repo, "1", "default", ".git"), 0700))
+	}
+	k := KubernetesConfig{CloneRoot: cloneRoot, ServiceAccounts: map[string]string{"group/sub/repo": "sub-sa"}}
+	Equals(t, "sub-sa", k.serviceAccount(filepath.Join(cloneRoot, "group/sub/repo/1/default")))
+	Equals(t, "", k.serviceAccount(filepath.Join(cloneRoot, "owner/other/1/default")))
+	k.ServiceAccounts["*"] = "default-sa"
+	Equals(t, "default-sa", k.serviceAccount(filepath.Join(cloneRoot, "owner/other/1/default")))
+}
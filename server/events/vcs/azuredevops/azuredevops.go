@@ -0,0 +1,5 @@
+// Package azuredevops holds code for Azure DevOps (dev.azure.com) and its
+// Azure Repos git hosting.
+package azuredevops
+
+const BaseURL = "https://dev.azure.com"
@@ -0,0 +1,342 @@
+package azuredevops
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+	"github.com/runatlantis/atlantis/server/events/vcs/common"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+// maxCommentLength is the maximum number of chars allowed by Azure DevOps in
+// a single comment.
+const maxCommentLength = 150000
+
+// apiVersion is the version of the Azure DevOps REST API we use. Some of the
+// pull request APIs are still in preview so they need previewAPIVersion.
+const apiVersion = "5.0"
+const previewAPIVersion = "5.0-preview.1"
+
+// statusContextGenre groups the statuses we set on pull requests.
+const statusContextGenre = "terraform"
+
+// approvedVote is the lowest reviewer vote that means the reviewer approved.
+// 10 is approved and 5 is approved with suggestions.
+const approvedVote = 5
+
+type Client struct {
+	HttpClient  *http.Client
+	Username    string
+	Token       string
+	BaseURL     string
+	AtlantisURL string
+}
+
+// NewClient builds an Azure DevOps client. username and token are the user
+// and personal access token used as basic auth in the requests. atlantisURL
+// is the URL for Atlantis that will be linked to from the pull request
+// statuses.
+func NewClient(httpClient *http.Client, username string, token string, atlantisURL string) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		HttpClient:  httpClient,
+		Username:    username,
+		Token:       token,
+		BaseURL:     BaseURL,
+		AtlantisURL: atlantisURL,
+	}
+}
+
+// GetModifiedFiles returns the names of files that were modified in the pull request.
+// The names include the path to the file from the repo root, ex. parent/child/file.txt.
+func (c *Client) GetModifiedFiles(repo models.Repo, pull models.PullRequest) ([]string, error) {
+	// The changes in the latest iteration are compared to the target branch
+	// so they're all the changes in the pull request.
+	resp, err := c.makeRequest("GET", c.pullURL(repo, pull.Num, "/iterations", apiVersion), nil)
+	if err != nil {
+		return nil, err
+	}
+	var iterations Iterations
+	if err := c.unmarshal(resp, &iterations); err != nil {
+		return nil, err
+	}
+	if len(iterations.Value) == 0 {
+		return nil, nil
+	}
+	lastIteration := *iterations.Value[len(iterations.Value)-1].ID
+
+	var files []string
+	skip := 0
+	// We'll only loop 1000 times as a safety measure.
+	maxLoops := 1000
+	for i := 0; i < maxLoops; i++ {
+		path := fmt.Sprintf("%s&$skip=%d", c.pullURL(repo, pull.Num, fmt.Sprintf("/iterations/%d/changes", lastIteration), apiVersion), skip)
+		resp, err := c.makeRequest("GET", path, nil)
+		if err != nil {
+			return nil, err
+		}
+		var changes IterationChanges
+		if err := c.unmarshal(resp, &changes); err != nil {
+			return nil, err
+		}
+		for _, change := range changes.ChangeEntries {
+			if change.Item.Path != nil {
+				files = append(files, strings.TrimPrefix(*change.Item.Path, "/"))
+			}
+			// If the file was renamed, we'll want to run plan in the directory
+			// it was moved from as well.
+			if change.OriginalPath != nil {
+				files = append(files, strings.TrimPrefix(*change.OriginalPath, "/"))
+			}
+		}
+		if changes.NextSkip == nil || *changes.NextSkip == 0 {
+			break
+		}
+		skip = *changes.NextSkip
+	}
+
+	// Now ensure all files are unique.
+	hash := make(map[string]bool)
+	var unique []string
+	for _, f := range files {
+		if !hash[f] {
+			unique = append(unique, f)
+			hash[f] = true
+		}
+	}
+	return unique, nil
+}
+
+// CreateComment creates a comment on the pull request. It will write multiple
+// comments if a single comment is too long.
+func (c *Client) CreateComment(repo models.Repo, pullNum int, comment string) error {
+	sepEnd := "\n```\n**Warning**: Output length greater than max comment size. Continued in next comment."
+	sepStart := "Continued from previous comment.\n```diff\n"
+	comments := common.SplitComment(comment, maxCommentLength, sepEnd, sepStart)
+	for _, comment := range comments {
+		if err := c.postComment(repo, pullNum, comment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// postComment posts the comment as a new thread. It's a helper for
+// CreateComment().
+func (c *Client) postComment(repo models.Repo, pullNum int, comment string) error {
+	bodyBytes, err := json.Marshal(map[string]interface{}{
+		"comments": []map[string]interface{}{
+			{
+				"parentCommentId": 0,
+				"content":         comment,
+				"commentType":     1,
+			},
+		},
+		// Threads that are closed don't need to be resolved before the pull
+		// request can be completed.
+		"status": "closed",
+	})
+	if err != nil {
+		return errors.Wrap(err, "json encoding")
+	}
+	_, err = c.makeRequest("POST", c.pullURL(repo, pullNum, "/threads", apiVersion), bytes.NewBuffer(bodyBytes))
+	return err
+}
+
+// PullIsApproved returns true if any reviewer approved the pull request.
+func (c *Client) PullIsApproved(repo models.Repo, pull models.PullRequest) (bool, error) {
+	pullResp, err := c.getPull(repo, pull.Num)
+	if err != nil {
+		return false, err
+	}
+	for _, reviewer := range pullResp.Reviewers {
+		if reviewer.Vote != nil && *reviewer.Vote >= approvedVote {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// PullIsMergeable returns true if the pull request has no conflicts and can
+// be merged.
+func (c *Client) PullIsMergeable(repo models.Repo, pull models.PullRequest) (bool, error) {
+	pullResp, err := c.getPull(repo, pull.Num)
+	if err != nil {
+		return false, err
+	}
+	return pullResp.MergeStatus != nil && *pullResp.MergeStatus == "succeeded", nil
+}
+
+// UpdateStatus sets the status of the pull request.
+func (c *Client) UpdateStatus(repo models.Repo, pull models.PullRequest, status models.CommitStatus, src string, description string) error {
+	adState := "failed"
+	switch status {
+	case models.PendingCommitStatus:
+		adState = "pending"
+	case models.SuccessCommitStatus:
+		adState = "succeeded"
+	case models.FailedCommitStatus:
+		adState = "failed"
+	}
+
+	bodyBytes, err := json.Marshal(map[string]interface{}{
+		"state":       adState,
+		"description": description,
+		"targetUrl":   c.AtlantisURL,
+		"context": map[string]string{
+			"name":  src,
+			"genre": statusContextGenre,
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "json encoding")
+	}
+	_, err = c.makeRequest("POST", c.pullURL(repo, pull.Num, "/statuses", previewAPIVersion), bytes.NewBuffer(bodyBytes))
+	return err
+}
+
+// ReactToComment does nothing because Azure DevOps doesn't have an API for
+// reacting to pull request comments.
+func (c *Client) ReactToComment(repo models.Repo, pullNum int, commentID int64, reaction string) error {
+	return nil
+}
+
+// GetPullLabels returns the names of the active labels, which Azure DevOps
+// calls tags, on the pull request.
+func (c *Client) GetPullLabels(repo models.Repo, pull models.PullRequest) ([]string, error) {
+	resp, err := c.makeRequest("GET", c.pullURL(repo, pull.Num, "/labels", previewAPIVersion), nil)
+	if err != nil {
+		return nil, err
+	}
+	var labels Labels
+	if err := c.unmarshal(resp, &labels); err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, l := range labels.Value {
+		if l.Active == nil || *l.Active {
+			names = append(names, *l.Name)
+		}
+	}
+	return names, nil
+}
+
+// PullIsDraft returns true if the pull request is a draft.
+func (c *Client) PullIsDraft(repo models.Repo, pull models.PullRequest) (bool, error) {
+	pullResp, err := c.getPull(repo, pull.Num)
+	if err != nil {
+		return false, err
+	}
+	return pullResp.IsDraft != nil && *pullResp.IsDraft, nil
+}
+
+// HidePrevComments does nothing. Azure DevOps comments are in threads that
+// other users can reply to so we leave them alone.
+func (c *Client) HidePrevComments(repo models.Repo, pullNum int, shouldHide func(comment string) bool) error {
+	return nil
+}
+
+// MergePull completes the pull request. Rebase rebases it and fast-forwards
+// the target branch. It's only completed if its source branch hasn't changed
+// since it was applied.
+func (c *Client) MergePull(repo models.Repo, pull models.PullRequest, opts vcs.MergeOptions) error {
+	strategy := "noFastForward"
+	switch opts.Method {
+	case vcs.SquashMethod:
+		strategy = "squash"
+	case vcs.RebaseMethod:
+		strategy = "rebase"
+	}
+	bodyBytes, err := json.Marshal(map[string]interface{}{
+		"status":                "completed",
+		"lastMergeSourceCommit": map[string]string{"commitId": pull.HeadCommit},
+		"completionOptions": map[string]interface{}{
+			"mergeStrategy":      strategy,
+			"deleteSourceBranch": opts.DeleteSourceBranch,
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "json encoding")
+	}
+	_, err = c.makeRequest("PATCH", c.pullURL(repo, pull.Num, "", apiVersion), bytes.NewBuffer(bodyBytes))
+	return err
+}
+
+func (c *Client) getPull(repo models.Repo, pullNum int) (PullRequest, error) {
+	var pull PullRequest
+	resp, err := c.makeRequest("GET", c.pullURL(repo, pullNum, "", apiVersion), nil)
+	if err != nil {
+		return pull, err
+	}
+	err = c.unmarshal(resp, &pull)
+	return pull, err
+}
+
+// pullURL returns the API URL for path under the pull request. Azure DevOps
+// repos are owned by a project in an organization so repo.Owner is
+// {organization}/{project}.
+func (c *Client) pullURL(repo models.Repo, pullNum int, path string, version string) string {
+	var escapedOwner []string
+	for _, part := range strings.SplitN(repo.Owner, "/", 2) {
+		escapedOwner = append(escapedOwner, url.PathEscape(part))
+	}
+	return fmt.Sprintf("%s/%s/_apis/git/repositories/%s/pullRequests/%d%s?api-version=%s",
+		c.BaseURL, strings.Join(escapedOwner, "/"), url.PathEscape(repo.Name), pullNum, path, version)
+}
+
+func (c *Client) unmarshal(resp []byte, v interface{}) error {
+	if err := json.Unmarshal(resp, v); err != nil {
+		return errors.Wrapf(err, "Could not parse response %q", string(resp))
+	}
+	if err := validator.New().Struct(v); err != nil {
+		return errors.Wrapf(err, "API response %q was missing fields", string(resp))
+	}
+	return nil
+}
+
+// prepRequest adds the HTTP basic auth.
+func (c *Client) prepRequest(method string, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(c.Username, c.Token)
+	return req, nil
+}
+
+func (c *Client) makeRequest(method string, path string, reqBody io.Reader) ([]byte, error) {
+	req, err := c.prepRequest(method, path, reqBody)
+	if err != nil {
+		return nil, errors.Wrap(err, "constructing request")
+	}
+	if reqBody != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+	resp, err := c.HttpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	requestStr := fmt.Sprintf("%s %s", method, path)
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("making request %q unexpected status code: %d, body: %s", requestStr, resp.StatusCode, string(respBody))
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading response from request %q", requestStr)
+	}
+	return respBody, nil
+}
@@ -0,0 +1,221 @@
+package azuredevops_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs/azuredevops"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+var repo = models.Repo{
+	FullName: "org/project/repo",
+	Owner:    "org/project",
+	Name:     "repo",
+}
+
+// Should use the changes from the last iteration and follow the pagination.
+func TestClient_GetModifiedFilesPagination(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Equals(t, "user", basicAuthUser(r))
+		switch r.RequestURI {
+		case "/org/project/_apis/git/repositories/repo/pullRequests/1/iterations?api-version=5.0":
+			w.Write([]byte(`{"value": [{"id": 1}, {"id": 2}]}`)) // nolint: errcheck
+		// The first page of changes.
+		case "/org/project/_apis/git/repositories/repo/pullRequests/1/iterations/2/changes?api-version=5.0&$skip=0":
+			w.Write([]byte(`{"changeEntries": [{"item": {"path": "/file1.txt"}}, {"item": {"path": "/dir/file2.txt"}, "originalPath": "/file2.txt"}], "nextSkip": 2}`)) // nolint: errcheck
+		// The second page of changes.
+		case "/org/project/_apis/git/repositories/repo/pullRequests/1/iterations/2/changes?api-version=5.0&$skip=2":
+			w.Write([]byte(`{"changeEntries": [{"item": {"path": "/file1.txt"}}, {"item": {"path": "/file3.txt"}}], "nextSkip": 0}`)) // nolint: errcheck
+		default:
+			t.Errorf("got unexpected request at %q", r.RequestURI)
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+
+	client := azuredevops.NewClient(http.DefaultClient, "user", "token", "runatlantis.io")
+	client.BaseURL = testServer.URL
+
+	files, err := client.GetModifiedFiles(repo, models.PullRequest{Num: 1})
+	Ok(t, err)
+	Equals(t, []string{"file1.txt", "dir/file2.txt", "file2.txt", "file3.txt"}, files)
+}
+
+// Should set the status with our context and map the atlantis statuses to
+// Azure DevOps states.
+func TestClient_UpdateStatus(t *testing.T) {
+	cases := []struct {
+		status   models.CommitStatus
+		expState string
+	}{
+		{models.PendingCommitStatus, "pending"},
+		{models.SuccessCommitStatus, "succeeded"},
+		{models.FailedCommitStatus, "failed"},
+	}
+	for _, c := range cases {
+		t.Run(c.expState, func(t *testing.T) {
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Equals(t, "POST", r.Method)
+				Equals(t, "/org/project/_apis/git/repositories/repo/pullRequests/1/statuses?api-version=5.0-preview.1", r.RequestURI)
+				body, err := ioutil.ReadAll(r.Body)
+				Ok(t, err)
+				var status map[string]interface{}
+				Ok(t, json.Unmarshal(body, &status))
+				Equals(t, c.expState, status["state"])
+				Equals(t, "description", status["description"])
+				Equals(t, "https://atlantis.example.com", status["targetUrl"])
+				Equals(t, map[string]interface{}{"name": "atlantis/plan", "genre": "terraform"}, status["context"])
+				w.WriteHeader(http.StatusCreated)
+			}))
+			defer testServer.Close()
+
+			client := azuredevops.NewClient(http.DefaultClient, "user", "token", "https://atlantis.example.com")
+			client.BaseURL = testServer.URL
+			Ok(t, client.UpdateStatus(repo, models.PullRequest{Num: 1}, c.status, "atlantis/plan", "description"))
+		})
+	}
+}
+
+// Should be approved if any reviewer voted approve or approve with
+// suggestions.
+func TestClient_PullIsApproved(t *testing.T) {
+	cases := []struct {
+		votes       string
+		expApproved bool
+	}{
+		{`[]`, false},
+		{`[{"vote": 0}, {"vote": -5}]`, false},
+		{`[{"vote": -10}, {"vote": 5}]`, true},
+		{`[{"vote": 10}]`, true},
+	}
+	for _, c := range cases {
+		t.Run(c.votes, func(t *testing.T) {
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Equals(t, "/org/project/_apis/git/repositories/repo/pullRequests/1?api-version=5.0", r.RequestURI)
+				w.Write([]byte(pullJSON(`"reviewers": ` + c.votes))) // nolint: errcheck
+			}))
+			defer testServer.Close()
+
+			client := azuredevops.NewClient(http.DefaultClient, "user", "token", "runatlantis.io")
+			client.BaseURL = testServer.URL
+			approved, err := client.PullIsApproved(repo, models.PullRequest{Num: 1})
+			Ok(t, err)
+			Equals(t, c.expApproved, approved)
+		})
+	}
+}
+
+func TestClient_PullIsMergeable(t *testing.T) {
+	cases := []struct {
+		mergeStatus  string
+		expMergeable bool
+	}{
+		{"succeeded", true},
+		{"conflicts", false},
+		{"queued", false},
+	}
+	for _, c := range cases {
+		t.Run(c.mergeStatus, func(t *testing.T) {
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(pullJSON(`"mergeStatus": "` + c.mergeStatus + `"`))) // nolint: errcheck
+			}))
+			defer testServer.Close()
+
+			client := azuredevops.NewClient(http.DefaultClient, "user", "token", "runatlantis.io")
+			client.BaseURL = testServer.URL
+			mergeable, err := client.PullIsMergeable(repo, models.PullRequest{Num: 1})
+			Ok(t, err)
+			Equals(t, c.expMergeable, mergeable)
+		})
+	}
+}
+
+// Should post each comment as a closed thread.
+func TestClient_CreateComment(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Equals(t, "POST", r.Method)
+		Equals(t, "/org/project/_apis/git/repositories/repo/pullRequests/1/threads?api-version=5.0", r.RequestURI)
+		body, err := ioutil.ReadAll(r.Body)
+		Ok(t, err)
+		Equals(t, `{"comments":[{"commentType":1,"content":"comment","parentCommentId":0}],"status":"closed"}`, string(body))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	client := azuredevops.NewClient(http.DefaultClient, "user", "token", "runatlantis.io")
+	client.BaseURL = testServer.URL
+	Ok(t, client.CreateComment(repo, 1, "comment"))
+}
+
+// Should only return the labels that are active.
+func TestClient_GetPullLabels(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Equals(t, "/org/project/_apis/git/repositories/repo/pullRequests/1/labels?api-version=5.0-preview.1", r.RequestURI)
+		w.Write([]byte(`{"value": [{"name": "one", "active": true}, {"name": "two", "active": false}, {"name": "three"}]}`)) // nolint: errcheck
+	}))
+	defer testServer.Close()
+
+	client := azuredevops.NewClient(http.DefaultClient, "user", "token", "runatlantis.io")
+	client.BaseURL = testServer.URL
+	labels, err := client.GetPullLabels(repo, models.PullRequest{Num: 1})
+	Ok(t, err)
+	Equals(t, []string{"one", "three"}, labels)
+}
+
+// Should escape the organization, project and repo names in the URLs.
+func TestClient_EscapesNames(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Equals(t, "/my%20org/my%20project/_apis/git/repositories/my%20repo/pullRequests/1?api-version=5.0", r.RequestURI)
+		w.Write([]byte(pullJSON(`"isDraft": true`))) // nolint: errcheck
+	}))
+	defer testServer.Close()
+
+	client := azuredevops.NewClient(http.DefaultClient, "user", "token", "runatlantis.io")
+	client.BaseURL = testServer.URL
+	draft, err := client.PullIsDraft(models.Repo{
+		FullName: "my org/my project/my repo",
+		Owner:    "my org/my project",
+		Name:     "my repo",
+	}, models.PullRequest{Num: 1})
+	Ok(t, err)
+	Equals(t, true, draft)
+}
+
+func TestClient_ErrorStatus(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not allowed", http.StatusUnauthorized)
+	}))
+	defer testServer.Close()
+
+	client := azuredevops.NewClient(http.DefaultClient, "user", "token", "runatlantis.io")
+	client.BaseURL = testServer.URL
+	_, err := client.PullIsMergeable(repo, models.PullRequest{Num: 1})
+	ErrContains(t, "unexpected status code: 401", err)
+}
+
+// pullJSON returns a pull request API response with extraFields added.
+func pullJSON(extraFields string) string {
+	return `{
+  "pullRequestId": 1,
+  "status": "active",
+  "createdBy": {"uniqueName": "user@example.com"},
+  "sourceRefName": "refs/heads/branch",
+  "lastMergeSourceCommit": {"commitId": "abc123"},
+  "repository": {
+    "name": "repo",
+    "remoteUrl": "https://org@dev.azure.com/org/project/_git/repo",
+    "project": {"name": "project"}
+  },
+  ` + extraFields + `
+}`
+}
+
+func basicAuthUser(r *http.Request) string {
+	user, _, _ := r.BasicAuth()
+	return user
+}
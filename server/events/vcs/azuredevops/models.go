@@ -0,0 +1,95 @@
+package azuredevops
+
+const (
+	PullCreatedEvent   = "git.pullrequest.created"
+	PullUpdatedEvent   = "git.pullrequest.updated"
+	PullMergedEvent    = "git.pullrequest.merged"
+	PullCommentedEvent = "ms.vss-code.git-pullrequest-comment-event"
+)
+
+// Pull request statuses.
+const (
+	PullActiveStatus    = "active"
+	PullAbandonedStatus = "abandoned"
+	PullCompletedStatus = "completed"
+)
+
+// Event is the part of a service hook event that's common to all events.
+type Event struct {
+	EventType *string `json:"eventType,omitempty" validate:"required"`
+}
+
+type PullRequestEvent struct {
+	Event
+	Resource *PullRequest `json:"resource,omitempty" validate:"required"`
+}
+
+type CommentEvent struct {
+	Event
+	Resource *struct {
+		Comment     *Comment     `json:"comment,omitempty" validate:"required"`
+		PullRequest *PullRequest `json:"pullRequest,omitempty" validate:"required"`
+	} `json:"resource,omitempty" validate:"required"`
+}
+
+type PullRequest struct {
+	PullRequestID         *int        `json:"pullRequestId,omitempty" validate:"required"`
+	Status                *string     `json:"status,omitempty" validate:"required"`
+	CreatedBy             *Identity   `json:"createdBy,omitempty" validate:"required"`
+	SourceRefName         *string     `json:"sourceRefName,omitempty" validate:"required"`
+	TargetRefName         *string     `json:"targetRefName,omitempty"`
+	LastMergeSourceCommit *Commit     `json:"lastMergeSourceCommit,omitempty" validate:"required"`
+	Repository            *Repository `json:"repository,omitempty" validate:"required"`
+	IsDraft               *bool       `json:"isDraft,omitempty"`
+	MergeStatus           *string     `json:"mergeStatus,omitempty"`
+	Reviewers             []struct {
+		Vote *int `json:"vote,omitempty"`
+	} `json:"reviewers,omitempty"`
+}
+
+type Identity struct {
+	UniqueName *string `json:"uniqueName,omitempty" validate:"required"`
+}
+
+type Commit struct {
+	CommitID *string `json:"commitId,omitempty" validate:"required"`
+}
+
+type Repository struct {
+	Name      *string  `json:"name,omitempty" validate:"required"`
+	RemoteURL *string  `json:"remoteUrl,omitempty" validate:"required"`
+	Project   *Project `json:"project,omitempty" validate:"required"`
+}
+
+type Project struct {
+	Name *string `json:"name,omitempty" validate:"required"`
+}
+
+type Comment struct {
+	Content     *string   `json:"content,omitempty" validate:"required"`
+	CommentType *string   `json:"commentType,omitempty"`
+	Author      *Identity `json:"author,omitempty" validate:"required"`
+}
+
+type Iterations struct {
+	Value []struct {
+		ID *int `json:"id,omitempty" validate:"required"`
+	} `json:"value,omitempty" validate:"required"`
+}
+
+type IterationChanges struct {
+	ChangeEntries []struct {
+		Item struct {
+			Path *string `json:"path,omitempty"`
+		} `json:"item,omitempty"`
+		OriginalPath *string `json:"originalPath,omitempty"`
+	} `json:"changeEntries,omitempty"`
+	NextSkip *int `json:"nextSkip,omitempty"`
+}
+
+type Labels struct {
+	Value []struct {
+		Name   *string `json:"name,omitempty" validate:"required"`
+		Active *bool   `json:"active,omitempty"`
+	} `json:"value,omitempty" validate:"required"`
+}
@@ -0,0 +1,22 @@
+package azuredevops
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// ValidateWebhookPassword returns an error if r wasn't sent with password as
+// its basic auth password. Azure DevOps service hooks don't sign their
+// requests so a basic auth password is the only way to validate them.
+func ValidateWebhookPassword(r *http.Request, password []byte) error {
+	_, reqPassword, ok := r.BasicAuth()
+	if !ok {
+		return errors.New("request did not use basic auth")
+	}
+	if subtle.ConstantTimeCompare([]byte(reqPassword), password) != 1 {
+		return errors.New("basic auth password did not match")
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package azuredevops_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/vcs/azuredevops"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestValidateWebhookPassword(t *testing.T) {
+	req, err := http.NewRequest("POST", "/events", nil)
+	Ok(t, err)
+	req.SetBasicAuth("atlantis", "mypassword")
+	Ok(t, azuredevops.ValidateWebhookPassword(req, []byte("mypassword")))
+}
+
+func TestValidateWebhookPassword_Invalid(t *testing.T) {
+	req, err := http.NewRequest("POST", "/events", nil)
+	Ok(t, err)
+	req.SetBasicAuth("atlantis", "wrong")
+	err = azuredevops.ValidateWebhookPassword(req, []byte("mypassword"))
+	ErrEquals(t, "basic auth password did not match", err)
+}
+
+func TestValidateWebhookPassword_NoBasicAuth(t *testing.T) {
+	req, err := http.NewRequest("POST", "/events", nil)
+	Ok(t, err)
+	err = azuredevops.ValidateWebhookPassword(req, []byte("mypassword"))
+	ErrEquals(t, "request did not use basic auth", err)
+}
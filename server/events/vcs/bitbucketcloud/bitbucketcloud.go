@@ -4,3 +4,17 @@
 package bitbucketcloud
 
 const BaseURL = "https://api.bitbucket.org"
+
+// The token types we can authenticate with.
+const (
+	// TokenTypeAppPassword is an app password for a user. It's sent with
+	// HTTP basic auth with the user's username.
+	TokenTypeAppPassword = "app-password"
+	// TokenTypeAccessToken is a workspace, project or repository access
+	// token. It's sent as a bearer token.
+	TokenTypeAccessToken = "access-token"
+)
+
+// AccessTokenCloneUser is the username used to clone repos with an access
+// token.
+const AccessTokenCloneUser = "x-token-auth"
@@ -7,18 +7,31 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
 	"gopkg.in/go-playground/validator.v9"
 )
 
+// statusKeyPrefix starts the keys of the build statuses we set unless
+// StatusName is set.
+const statusKeyPrefix = "atlantis"
+
 type Client struct {
 	HttpClient  *http.Client
 	Username    string
 	Password    string
 	BaseURL     string
 	AtlantisURL string
+	// StatusName starts the keys of the build statuses we set. They're
+	// ignored when checking if a pull request is mergeable. Defaults to
+	// statusKeyPrefix.
+	StatusName string
+	// TokenType is how Password is sent, TokenTypeAppPassword or
+	// TokenTypeAccessToken. Defaults to TokenTypeAppPassword.
+	TokenType string
 }
 
 // NewClient builds a bitbucket cloud client. atlantisURL is the
@@ -41,8 +54,35 @@ func NewClient(httpClient *http.Client, username string, password string, atlant
 // GetModifiedFiles returns the names of files that were modified in the merge request.
 // The names include the path to the file from the repo root, ex. parent/child/file.txt.
 func (b *Client) GetModifiedFiles(repo models.Repo, pull models.PullRequest) ([]string, error) {
+	values, err := b.diffStat(repo, pull)
+	if err != nil {
+		return nil, err
+	}
 	var files []string
+	for _, v := range values {
+		if v.Old != nil {
+			files = append(files, *v.Old.Path)
+		}
+		if v.New != nil {
+			files = append(files, *v.New.Path)
+		}
+	}
 
+	// Now ensure all files are unique.
+	hash := make(map[string]bool)
+	var unique []string
+	for _, f := range files {
+		if !hash[f] {
+			unique = append(unique, f)
+			hash[f] = true
+		}
+	}
+	return unique, nil
+}
+
+// diffStat returns how each file changed in the pull request.
+func (b *Client) diffStat(repo models.Repo, pull models.PullRequest) ([]DiffStatValue, error) {
+	var values []DiffStatValue
 	nextPageURL := fmt.Sprintf("%s/2.0/repositories/%s/pullrequests/%d/diffstat", b.BaseURL, repo.FullName, pull.Num)
 	// We'll only loop 1000 times as a safety measure.
 	maxLoops := 1000
@@ -58,30 +98,13 @@ func (b *Client) GetModifiedFiles(repo models.Repo, pull models.PullRequest) ([]
 		if err := validator.New().Struct(diffStat); err != nil {
 			return nil, errors.Wrapf(err, "API response %q was missing fields", string(resp))
 		}
-		for _, v := range diffStat.Values {
-			if v.Old != nil {
-				files = append(files, *v.Old.Path)
-			}
-			if v.New != nil {
-				files = append(files, *v.New.Path)
-			}
-		}
+		values = append(values, diffStat.Values...)
 		if diffStat.Next == nil || *diffStat.Next == "" {
 			break
 		}
 		nextPageURL = *diffStat.Next
 	}
-
-	// Now ensure all files are unique.
-	hash := make(map[string]bool)
-	var unique []string
-	for _, f := range files {
-		if !hash[f] {
-			unique = append(unique, f)
-			hash[f] = true
-		}
-	}
-	return unique, nil
+	return values, nil
 }
 
 // CreateComment creates a comment on the merge request.
@@ -124,29 +147,62 @@ func (b *Client) PullIsApproved(repo models.Repo, pull models.PullRequest) (bool
 	return false, nil
 }
 
-// PullIsMergeable returns true if the merge request has no conflicts and can be merged.
+// PullIsMergeable returns true if the merge request has no conflicts and all
+// of its builds, other than ours which is in progress while we're applying,
+// were successful. The 2.0 API doesn't say whether a pull request can be
+// merged so we look for conflicts in its diffstat. The approvals needed to
+// merge are part of the branch restrictions, which only repo admins can read,
+// so they're left to the approved apply requirement.
 func (b *Client) PullIsMergeable(repo models.Repo, pull models.PullRequest) (bool, error) {
-	// NOTE: The 1.0 API is deprecated, but the 2.0 API does not provide this endpoint.
-	path := fmt.Sprintf("%s/1.0/repositories/%s/pullrequests/%d/conflict-status", b.BaseURL, repo.FullName, pull.Num)
-	resp, err := b.makeRequest("GET", path, nil)
+	values, err := b.diffStat(repo, pull)
 	if err != nil {
 		return false, err
 	}
-	var conflictStatus ConflictStatus
-	if err := json.Unmarshal(resp, &conflictStatus); err != nil {
-		return false, errors.Wrapf(err, "Could not parse response %q", string(resp))
+	for _, v := range values {
+		if v.Status != nil && (*v.Status == "merge conflict" || *v.Status == "local deleted" || *v.Status == "remote deleted") {
+			return false, nil
+		}
 	}
-	if err := validator.New().Struct(conflictStatus); err != nil {
-		return false, errors.Wrapf(err, "API response %q was missing fields", string(resp))
+
+	nextPageURL := fmt.Sprintf("%s/2.0/repositories/%s/pullrequests/%d/statuses", b.BaseURL, repo.FullName, pull.Num)
+	// We'll only loop 1000 times as a safety measure.
+	maxLoops := 1000
+	for i := 0; i < maxLoops; i++ {
+		resp, err := b.makeRequest("GET", nextPageURL, nil)
+		if err != nil {
+			return false, err
+		}
+		var statuses CommitStatuses
+		if err := json.Unmarshal(resp, &statuses); err != nil {
+			return false, errors.Wrapf(err, "Could not parse response %q", string(resp))
+		}
+		if err := validator.New().Struct(statuses); err != nil {
+			return false, errors.Wrapf(err, "API response %q was missing fields", string(resp))
+		}
+		for _, s := range statuses.Values {
+			if !b.isOwnStatus(*s.Key) && *s.State != "SUCCESSFUL" {
+				return false, nil
+			}
+		}
+		if statuses.Next == nil || *statuses.Next == "" {
+			break
+		}
+		nextPageURL = *statuses.Next
 	}
-	if !*conflictStatus.MergeImpossible && !*conflictStatus.IsConflicted {
-		return true, nil
+	return true, nil
+}
+
+// isOwnStatus returns true if the build status with the key key was set by us.
+func (b *Client) isOwnStatus(key string) bool {
+	prefix := b.StatusName
+	if prefix == "" {
+		prefix = statusKeyPrefix
 	}
-	return false, nil
+	return strings.HasPrefix(key, prefix)
 }
 
 // UpdateStatus updates the status of a commit.
-func (b *Client) UpdateStatus(repo models.Repo, pull models.PullRequest, status models.CommitStatus, description string) error {
+func (b *Client) UpdateStatus(repo models.Repo, pull models.PullRequest, status models.CommitStatus, src string, description string) error {
 	bbState := "FAILED"
 	switch status {
 	case models.PendingCommitStatus:
@@ -158,7 +214,8 @@ func (b *Client) UpdateStatus(repo models.Repo, pull models.PullRequest, status
 	}
 
 	bodyBytes, err := json.Marshal(map[string]string{
-		"key":         "atlantis",
+		"key":         src,
+		"name":        src,
 		"url":         b.AtlantisURL,
 		"state":       bbState,
 		"description": description,
@@ -172,13 +229,111 @@ func (b *Client) UpdateStatus(repo models.Repo, pull models.PullRequest, status
 	return err
 }
 
-// prepRequest adds the HTTP basic auth.
+// ReactToComment does nothing because Bitbucket Cloud doesn't have an API for
+// reacting to pull request comments.
+func (b *Client) ReactToComment(repo models.Repo, pullNum int, commentID int64, reaction string) error {
+	return nil
+}
+
+// GetPullLabels returns no labels because Bitbucket Cloud pull requests don't have
+// labels.
+func (b *Client) GetPullLabels(repo models.Repo, pull models.PullRequest) ([]string, error) {
+	return nil, nil
+}
+
+// PullIsDraft returns true if the pull request is a draft.
+func (b *Client) PullIsDraft(repo models.Repo, pull models.PullRequest) (bool, error) {
+	path := fmt.Sprintf("%s/2.0/repositories/%s/pullrequests/%d", b.BaseURL, repo.FullName, pull.Num)
+	resp, err := b.makeRequest("GET", path, nil)
+	if err != nil {
+		return false, err
+	}
+	var pullResp PullRequest
+	if err := json.Unmarshal(resp, &pullResp); err != nil {
+		return false, errors.Wrapf(err, "Could not parse response %q", string(resp))
+	}
+	return pullResp.Draft != nil && *pullResp.Draft, nil
+}
+
+// HidePrevComments deletes our comments that shouldHide returns true for
+// since Bitbucket Cloud can't hide comments.
+func (b *Client) HidePrevComments(repo models.Repo, pullNum int, shouldHide func(comment string) bool) error {
+	nextPageURL := fmt.Sprintf("%s/2.0/repositories/%s/pullrequests/%d/comments?sort=created_on", b.BaseURL, repo.FullName, pullNum)
+	var toDelete []int
+	// We'll only loop 1000 times as a safety measure.
+	maxLoops := 1000
+	for i := 0; i < maxLoops; i++ {
+		resp, err := b.makeRequest("GET", nextPageURL, nil)
+		if err != nil {
+			return err
+		}
+		var comments PullRequestComments
+		if err := json.Unmarshal(resp, &comments); err != nil {
+			return errors.Wrapf(err, "Could not parse response %q", string(resp))
+		}
+		if err := validator.New().Struct(comments); err != nil {
+			return errors.Wrapf(err, "API response %q was missing fields", string(resp))
+		}
+		for _, c := range comments.Values {
+			if c.Deleted != nil && *c.Deleted {
+				continue
+			}
+			if c.User == nil || c.User.Username == nil || *c.User.Username != b.Username {
+				continue
+			}
+			if shouldHide(*c.Content.Raw) {
+				toDelete = append(toDelete, *c.ID)
+			}
+		}
+		if comments.Next == nil || *comments.Next == "" {
+			break
+		}
+		nextPageURL = *comments.Next
+	}
+
+	for _, id := range toDelete {
+		path := fmt.Sprintf("%s/2.0/repositories/%s/pullrequests/%d/comments/%d", b.BaseURL, repo.FullName, pullNum, id)
+		if _, err := b.makeRequest("DELETE", path, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MergePull merges the pull request. Bitbucket Cloud can't rebase so rebase
+// fast-forwards instead.
+func (b *Client) MergePull(repo models.Repo, pull models.PullRequest, opts vcs.MergeOptions) error {
+	strategy := "merge_commit"
+	switch opts.Method {
+	case vcs.SquashMethod:
+		strategy = "squash"
+	case vcs.RebaseMethod:
+		strategy = "fast_forward"
+	}
+	bodyBytes, err := json.Marshal(map[string]interface{}{
+		"merge_strategy":      strategy,
+		"close_source_branch": opts.DeleteSourceBranch,
+	})
+	if err != nil {
+		return errors.Wrap(err, "json encoding")
+	}
+	path := fmt.Sprintf("%s/2.0/repositories/%s/pullrequests/%d/merge", b.BaseURL, repo.FullName, pull.Num)
+	_, err = b.makeRequest("POST", path, bytes.NewBuffer(bodyBytes))
+	return err
+}
+
+// prepRequest adds the HTTP basic auth, or the bearer token if we're using an
+// access token.
 func (b *Client) prepRequest(method string, path string, body io.Reader) (*http.Request, error) {
 	req, err := http.NewRequest(method, path, body)
 	if err != nil {
 		return nil, err
 	}
-	req.SetBasicAuth(b.Username, b.Password)
+	if b.TokenType == TokenTypeAccessToken {
+		req.Header.Set("Authorization", "Bearer "+b.Password)
+	} else {
+		req.SetBasicAuth(b.Username, b.Password)
+	}
 	return req, nil
 }
 
@@ -197,7 +352,7 @@ func (b *Client) makeRequest(method string, path string, reqBody io.Reader) ([]b
 	defer resp.Body.Close() // nolint: errcheck
 	requestStr := fmt.Sprintf("%s %s", method, path)
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
 		respBody, _ := ioutil.ReadAll(resp.Body)
 		return nil, fmt.Errorf("making request %q unexpected status code: %d, body: %s", requestStr, resp.StatusCode, string(respBody))
 	}
@@ -213,3 +213,196 @@ func TestClient_PullIsApproved(t *testing.T) {
 		})
 	}
 }
+
+func TestClient_PullIsDraft(t *testing.T) {
+	for _, exp := range []bool{true, false} {
+		testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Equals(t, "/2.0/repositories/owner/repo/pullrequests/1", r.RequestURI)
+			w.Write([]byte(fmt.Sprintf(`{"id": 1, "draft": %t}`, exp))) // nolint: errcheck
+		}))
+
+		client := bitbucketcloud.NewClient(http.DefaultClient, "user", "pass", "runatlantis.io")
+		client.BaseURL = testServer.URL
+		draft, err := client.PullIsDraft(models.Repo{FullName: "owner/repo"}, models.PullRequest{Num: 1})
+		Ok(t, err)
+		Equals(t, exp, draft)
+		testServer.Close()
+	}
+}
+
+func TestClient_PullIsMergeable(t *testing.T) {
+	cases := []struct {
+		description string
+		diffStat    string
+		statuses    string
+		exp         bool
+	}{
+		{
+			"no conflicts or builds",
+			`{"values": [{"status": "modified", "old": {"path": "main.tf"}, "new": {"path": "main.tf"}}]}`,
+			`{"values": []}`,
+			true,
+		},
+		{
+			"merge conflict",
+			`{"values": [{"status": "merge conflict", "old": {"path": "main.tf"}, "new": {"path": "main.tf"}}]}`,
+			`{"values": []}`,
+			false,
+		},
+		{
+			"deleted on one side",
+			`{"values": [{"status": "remote deleted", "old": {"path": "main.tf"}, "new": null}]}`,
+			`{"values": []}`,
+			false,
+		},
+		{
+			"our build is in progress",
+			`{"values": []}`,
+			`{"values": [{"key": "atlantis", "state": "INPROGRESS"}, {"key": "ci", "state": "SUCCESSFUL"}]}`,
+			true,
+		},
+		{
+			"other build failed",
+			`{"values": []}`,
+			`{"values": [{"key": "atlantis", "state": "SUCCESSFUL"}, {"key": "ci", "state": "FAILED"}]}`,
+			false,
+		},
+		{
+			"other build in progress",
+			`{"values": []}`,
+			`{"values": [{"key": "ci", "state": "INPROGRESS"}]}`,
+			false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.RequestURI {
+				case "/2.0/repositories/owner/repo/pullrequests/1/diffstat":
+					w.Write([]byte(c.diffStat)) // nolint: errcheck
+					return
+				case "/2.0/repositories/owner/repo/pullrequests/1/statuses":
+					w.Write([]byte(c.statuses)) // nolint: errcheck
+					return
+				default:
+					t.Errorf("got unexpected request at %q", r.RequestURI)
+					http.Error(w, "not found", http.StatusNotFound)
+					return
+				}
+			}))
+			defer testServer.Close()
+
+			client := bitbucketcloud.NewClient(http.DefaultClient, "user", "pass", "runatlantis.io")
+			client.BaseURL = testServer.URL
+
+			repo, err := models.NewRepo(models.BitbucketCloud, "owner/repo", "https://bitbucket.org/owner/repo.git", "user", "token")
+			Ok(t, err)
+			mergeable, err := client.PullIsMergeable(repo, models.PullRequest{
+				Num:      1,
+				BaseRepo: repo,
+			})
+			Ok(t, err)
+			Equals(t, c.exp, mergeable)
+		})
+	}
+}
+
+// PullIsMergeable should ignore the statuses that start with the client's
+// StatusName.
+func TestClient_PullIsMergeableStatusName(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.RequestURI {
+		case "/2.0/repositories/owner/repo/pullrequests/1/diffstat":
+			w.Write([]byte(`{"values": []}`)) // nolint: errcheck
+		case "/2.0/repositories/owner/repo/pullrequests/1/statuses":
+			w.Write([]byte(`{"values": [{"key": "atlantis-prod/apply", "state": "INPROGRESS"}, {"key": "atlantis/plan", "state": "SUCCESSFUL"}]}`)) // nolint: errcheck
+		default:
+			t.Errorf("got unexpected request at %q", r.RequestURI)
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+
+	client := bitbucketcloud.NewClient(http.DefaultClient, "user", "pass", "runatlantis.io")
+	client.BaseURL = testServer.URL
+	client.StatusName = "atlantis-prod"
+
+	repo, err := models.NewRepo(models.BitbucketCloud, "owner/repo", "https://bitbucket.org/owner/repo.git", "user", "token")
+	Ok(t, err)
+	mergeable, err := client.PullIsMergeable(repo, models.PullRequest{
+		Num:      1,
+		BaseRepo: repo,
+	})
+	Ok(t, err)
+	Equals(t, true, mergeable)
+}
+
+// HidePrevComments should delete our comments that shouldHide returns true
+// for, following pagination.
+func TestClient_HidePrevComments(t *testing.T) {
+	var serverURL string
+	var deleted []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.RequestURI {
+		case "GET /2.0/repositories/owner/repo/pullrequests/1/comments?sort=created_on":
+			resp := `{"values": [
+  {"id": 1, "content": {"raw": "Ran Plan for dir: old"}, "user": {"username": "user"}},
+  {"id": 2, "content": {"raw": "Ran Plan for dir: old"}, "user": {"username": "someone"}}
+], "next": "%s/2.0/repositories/owner/repo/pullrequests/1/comments?sort=created_on&page=2"}`
+			w.Write([]byte(fmt.Sprintf(resp, serverURL))) // nolint: errcheck
+		case "GET /2.0/repositories/owner/repo/pullrequests/1/comments?sort=created_on&page=2":
+			w.Write([]byte(`{"values": [
+  {"id": 3, "content": {"raw": "Ran Plan for dir: old"}, "user": {"username": "user"}, "deleted": true},
+  {"id": 4, "content": {"raw": "Ran Plan for dir: new"}, "user": {"username": "user"}}
+]}`)) // nolint: errcheck
+		case "DELETE /2.0/repositories/owner/repo/pullrequests/1/comments/1":
+			deleted = append(deleted, "1")
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("got unexpected request %s %q", r.Method, r.RequestURI)
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+	serverURL = testServer.URL
+
+	client := bitbucketcloud.NewClient(http.DefaultClient, "user", "pass", "runatlantis.io")
+	client.BaseURL = testServer.URL
+	repo, err := models.NewRepo(models.BitbucketCloud, "owner/repo", "https://bitbucket.org/owner/repo.git", "user", "token")
+	Ok(t, err)
+	var seen []string
+	err = client.HidePrevComments(repo, 1, func(comment string) bool {
+		seen = append(seen, comment)
+		return comment == "Ran Plan for dir: old"
+	})
+	Ok(t, err)
+	Equals(t, []string{"Ran Plan for dir: old", "Ran Plan for dir: new"}, seen)
+	Equals(t, []string{"1"}, deleted)
+}
+
+// Should send app passwords with basic auth and access tokens as bearer
+// tokens.
+func TestClient_Auth(t *testing.T) {
+	var auth string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.Write([]byte(`{"values": []}`)) // nolint: errcheck
+	}))
+	defer testServer.Close()
+
+	repo, err := models.NewRepo(models.BitbucketCloud, "owner/repo", "https://bitbucket.org/owner/repo.git", "user", "token")
+	Ok(t, err)
+	pull := models.PullRequest{Num: 1, BaseRepo: repo}
+
+	client := bitbucketcloud.NewClient(http.DefaultClient, "user", "pass", "runatlantis.io")
+	client.BaseURL = testServer.URL
+	_, err = client.GetModifiedFiles(repo, pull)
+	Ok(t, err)
+	Equals(t, "Basic dXNlcjpwYXNz", auth)
+
+	client.TokenType = bitbucketcloud.TokenTypeAccessToken
+	_, err = client.GetModifiedFiles(repo, pull)
+	Ok(t, err)
+	Equals(t, "Bearer pass", auth)
+}
@@ -32,6 +32,8 @@ type DiffStatValue struct {
 	Old *DiffStatFile `json:"old,omitempty"`
 	// New is the new file, this can be null.
 	New *DiffStatFile `json:"new,omitempty"`
+	// Status is how the file changed, ex. "modified" or "merge conflict".
+	Status *string `json:"status,omitempty"`
 }
 type DiffStatFile struct {
 	Path *string `json:"path,omitempty" validate:"required"`
@@ -47,9 +49,11 @@ type Repository struct {
 type PullRequest struct {
 	ID           *int          `json:"id,omitempty" validate:"required"`
 	Source       *Source       `json:"source,omitempty" validate:"required"`
+	Destination  *Destination  `json:"destination,omitempty"`
 	Participants []Participant `json:"participants,omitempty" validate:"required"`
 	Links        *Links        `json:"links,omitempty" validate:"required"`
 	State        *string       `json:"state,omitempty" validate:"required"`
+	Draft        *bool         `json:"draft,omitempty"`
 }
 type Links struct {
 	HTML *Link `json:"html,omitempty" validate:"required"`
@@ -68,6 +72,9 @@ type Source struct {
 	Commit     *Commit     `json:"commit,omitempty" validate:"required"`
 	Branch     *Branch     `json:"branch,omitempty" validate:"required"`
 }
+type Destination struct {
+	Branch *Branch `json:"branch,omitempty"`
+}
 type Branch struct {
 	Name *string `json:"name,omitempty" validate:"required"`
 }
@@ -81,7 +88,22 @@ type CommentContent struct {
 	Raw *string `json:"raw,omitempty" validate:"required"`
 }
 
-type ConflictStatus struct {
-	MergeImpossible *bool `json:"mergeimpossible,omitempty" validate:"required"`
-	IsConflicted    *bool `json:"isconflicted,omitempty" validate:"required"`
+type PullRequestComments struct {
+	Values []PullRequestComment `json:"values,omitempty" validate:"required"`
+	Next   *string              `json:"next,omitempty"`
+}
+type PullRequestComment struct {
+	ID      *int            `json:"id,omitempty" validate:"required"`
+	Content *CommentContent `json:"content,omitempty" validate:"required"`
+	Deleted *bool           `json:"deleted,omitempty"`
+	User    *Actor          `json:"user,omitempty"`
+}
+
+type CommitStatuses struct {
+	Values []CommitStatus `json:"values,omitempty" validate:"required"`
+	Next   *string        `json:"next,omitempty"`
+}
+type CommitStatus struct {
+	Key   *string `json:"key,omitempty" validate:"required"`
+	State *string `json:"state,omitempty" validate:"required"`
 }
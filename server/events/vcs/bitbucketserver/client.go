@@ -15,6 +15,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
 	"gopkg.in/go-playground/validator.v9"
 )
 
@@ -22,12 +23,20 @@ import (
 // single comment.
 const maxCommentLength = 32768
 
+// statusKeyPrefix starts the keys of the build statuses we set unless
+// StatusName is set.
+const statusKeyPrefix = "atlantis"
+
 type Client struct {
 	HttpClient  *http.Client
 	Username    string
 	Password    string
 	BaseURL     string
 	AtlantisURL string
+	// StatusName starts the keys of the build statuses we set. They're
+	// ignored when checking if a pull request is mergeable. Defaults to
+	// statusKeyPrefix.
+	StatusName string
 }
 
 // NewClient builds a bitbucket cloud client. Returns an error if the baseURL is
@@ -184,7 +193,10 @@ func (b *Client) PullIsApproved(repo models.Repo, pull models.PullRequest) (bool
 	return false, nil
 }
 
-// PullIsMergeable returns true if the merge request has no conflicts and can be merged.
+// PullIsMergeable returns true if the merge request has no conflicts and can be
+// merged. Bitbucket won't let a pull request be merged while our own build
+// status is in progress, which it is while we're applying, so if builds are
+// the only reason it can't be merged we check the other builds ourselves.
 func (b *Client) PullIsMergeable(repo models.Repo, pull models.PullRequest) (bool, error) {
 	projectKey, err := b.GetProjectKey(repo.Name, repo.SanitizedCloneURL)
 	if err != nil {
@@ -202,14 +214,63 @@ func (b *Client) PullIsMergeable(repo models.Repo, pull models.PullRequest) (boo
 	if err := validator.New().Struct(mergeStatus); err != nil {
 		return false, errors.Wrapf(err, "API response %q was missing fields", string(resp))
 	}
-	if *mergeStatus.CanMerge && !*mergeStatus.Conflicted {
+	if *mergeStatus.Conflicted {
+		return false, nil
+	}
+	if *mergeStatus.CanMerge {
 		return true, nil
 	}
-	return false, nil
+	for _, veto := range mergeStatus.Vetoes {
+		if veto.SummaryMessage == nil || !strings.Contains(strings.ToLower(*veto.SummaryMessage), "build") {
+			return false, nil
+		}
+	}
+	return b.otherBuildsPassed(pull.HeadCommit)
+}
+
+// otherBuildsPassed returns true if every build status on commit, other than
+// ours, is successful.
+func (b *Client) otherBuildsPassed(commit string) (bool, error) {
+	nextPageStart := 0
+	baseURL := fmt.Sprintf("%s/rest/build-status/1.0/commits/%s", b.BaseURL, commit)
+	// We'll only loop 1000 times as a safety measure.
+	maxLoops := 1000
+	for i := 0; i < maxLoops; i++ {
+		resp, err := b.makeRequest("GET", fmt.Sprintf("%s?start=%d", baseURL, nextPageStart), nil)
+		if err != nil {
+			return false, err
+		}
+		var statuses BuildStatuses
+		if err := json.Unmarshal(resp, &statuses); err != nil {
+			return false, errors.Wrapf(err, "Could not parse response %q", string(resp))
+		}
+		if err := validator.New().Struct(statuses); err != nil {
+			return false, errors.Wrapf(err, "API response %q was missing fields", string(resp))
+		}
+		for _, s := range statuses.Values {
+			if !b.isOwnStatus(*s.Key) && *s.State != "SUCCESSFUL" {
+				return false, nil
+			}
+		}
+		if *statuses.IsLastPage || statuses.NextPageStart == nil {
+			break
+		}
+		nextPageStart = *statuses.NextPageStart
+	}
+	return true, nil
+}
+
+// isOwnStatus returns true if the build status with the key key was set by us.
+func (b *Client) isOwnStatus(key string) bool {
+	prefix := b.StatusName
+	if prefix == "" {
+		prefix = statusKeyPrefix
+	}
+	return strings.HasPrefix(key, prefix)
 }
 
 // UpdateStatus updates the status of a commit.
-func (b *Client) UpdateStatus(repo models.Repo, pull models.PullRequest, status models.CommitStatus, description string) error {
+func (b *Client) UpdateStatus(repo models.Repo, pull models.PullRequest, status models.CommitStatus, src string, description string) error {
 	bbState := "FAILED"
 	switch status {
 	case models.PendingCommitStatus:
@@ -221,7 +282,8 @@ func (b *Client) UpdateStatus(repo models.Repo, pull models.PullRequest, status
 	}
 
 	bodyBytes, err := json.Marshal(map[string]string{
-		"key":         "atlantis",
+		"key":         src,
+		"name":        src,
 		"url":         b.AtlantisURL,
 		"state":       bbState,
 		"description": description,
@@ -235,7 +297,142 @@ func (b *Client) UpdateStatus(repo models.Repo, pull models.PullRequest, status
 	return err
 }
 
+// ReactToComment does nothing because Bitbucket Server doesn't have an API for
+// reacting to pull request comments.
+func (b *Client) ReactToComment(repo models.Repo, pullNum int, commentID int64, reaction string) error {
+	return nil
+}
+
+// GetPullLabels returns no labels because Bitbucket Server pull requests don't have
+// labels.
+func (b *Client) GetPullLabels(repo models.Repo, pull models.PullRequest) ([]string, error) {
+	return nil, nil
+}
+
+// PullIsDraft returns true if the pull request is a draft. Bitbucket Server
+// only has drafts since 8.18 so it's always false for older versions.
+func (b *Client) PullIsDraft(repo models.Repo, pull models.PullRequest) (bool, error) {
+	projectKey, err := b.GetProjectKey(repo.Name, repo.SanitizedCloneURL)
+	if err != nil {
+		return false, err
+	}
+	path := fmt.Sprintf("%s/rest/api/1.0/projects/%s/repos/%s/pull-requests/%d", b.BaseURL, projectKey, repo.Name, pull.Num)
+	resp, err := b.makeRequest("GET", path, nil)
+	if err != nil {
+		return false, err
+	}
+	var pullResp PullRequest
+	if err := json.Unmarshal(resp, &pullResp); err != nil {
+		return false, errors.Wrapf(err, "Could not parse response %q", string(resp))
+	}
+	return pullResp.Draft != nil && *pullResp.Draft, nil
+}
+
+// HidePrevComments deletes our comments that shouldHide returns true for
+// since Bitbucket Server can't hide comments. The pull request's activities
+// are listed newest first so they're reversed before calling shouldHide.
+func (b *Client) HidePrevComments(repo models.Repo, pullNum int, shouldHide func(comment string) bool) error {
+	projectKey, err := b.GetProjectKey(repo.Name, repo.SanitizedCloneURL)
+	if err != nil {
+		return err
+	}
+	pullURL := fmt.Sprintf("%s/rest/api/1.0/projects/%s/repos/%s/pull-requests/%d", b.BaseURL, projectKey, repo.Name, pullNum)
+
+	var comments []ActivityComment
+	nextPageStart := 0
+	// We'll only loop 1000 times as a safety measure.
+	maxLoops := 1000
+	for i := 0; i < maxLoops; i++ {
+		resp, err := b.makeRequest("GET", fmt.Sprintf("%s/activities?start=%d", pullURL, nextPageStart), nil)
+		if err != nil {
+			return err
+		}
+		var activities Activities
+		if err := json.Unmarshal(resp, &activities); err != nil {
+			return errors.Wrapf(err, "Could not parse response %q", string(resp))
+		}
+		if err := validator.New().Struct(activities); err != nil {
+			return errors.Wrapf(err, "API response %q was missing fields", string(resp))
+		}
+		for _, a := range activities.Values {
+			if a.Action == nil || *a.Action != "COMMENTED" || a.CommentAction == nil || *a.CommentAction != "ADDED" || a.Comment == nil {
+				continue
+			}
+			if a.Comment.Author == nil || a.Comment.Author.Username == nil || *a.Comment.Author.Username != b.Username {
+				continue
+			}
+			comments = append(comments, *a.Comment)
+		}
+		if *activities.IsLastPage {
+			break
+		}
+		nextPageStart = *activities.NextPageStart
+	}
+
+	for i := len(comments) - 1; i >= 0; i-- {
+		c := comments[i]
+		if !shouldHide(*c.Text) {
+			continue
+		}
+		path := fmt.Sprintf("%s/comments/%d?version=%d", pullURL, *c.ID, *c.Version)
+		if _, err := b.makeRequest("DELETE", path, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // prepRequest adds the HTTP basic auth.
+// MergePull merges the pull request. Rebase rebases it and fast-forwards the
+// base branch. Bitbucket Server can't delete the source branch while merging
+// so we delete it afterwards. Branch permissions stop protected branches from
+// being deleted.
+func (b *Client) MergePull(repo models.Repo, pull models.PullRequest, opts vcs.MergeOptions) error {
+	projectKey, err := b.GetProjectKey(repo.Name, repo.SanitizedCloneURL)
+	if err != nil {
+		return err
+	}
+	pullURL := fmt.Sprintf("%s/rest/api/1.0/projects/%s/repos/%s/pull-requests/%d", b.BaseURL, projectKey, repo.Name, pull.Num)
+	// Merging needs the pull request's current version so it fails if the
+	// pull request was changed since.
+	resp, err := b.makeRequest("GET", pullURL, nil)
+	if err != nil {
+		return err
+	}
+	var pullResp PullRequest
+	if err := json.Unmarshal(resp, &pullResp); err != nil {
+		return errors.Wrapf(err, "Could not parse response %q", string(resp))
+	}
+	if pullResp.Version == nil {
+		return fmt.Errorf("API response %q was missing the pull request's version", string(resp))
+	}
+
+	strategy := "no-ff"
+	switch opts.Method {
+	case vcs.SquashMethod:
+		strategy = "squash"
+	case vcs.RebaseMethod:
+		strategy = "rebase-ff-only"
+	}
+	bodyBytes, err := json.Marshal(map[string]string{"strategyId": strategy})
+	if err != nil {
+		return errors.Wrap(err, "json encoding")
+	}
+	if _, err := b.makeRequest("POST", fmt.Sprintf("%s/merge?version=%d", pullURL, *pullResp.Version), bytes.NewBuffer(bodyBytes)); err != nil {
+		return err
+	}
+	if !opts.DeleteSourceBranch {
+		return nil
+	}
+	bodyBytes, err = json.Marshal(map[string]interface{}{"name": "refs/heads/" + pull.Branch, "dryRun": false})
+	if err != nil {
+		return errors.Wrap(err, "json encoding")
+	}
+	branchesURL := fmt.Sprintf("%s/rest/branch-utils/1.0/projects/%s/repos/%s/branches", b.BaseURL, projectKey, repo.Name)
+	_, err = b.makeRequest("DELETE", branchesURL, bytes.NewBuffer(bodyBytes))
+	return errors.Wrapf(err, "deleting branch %q", pull.Branch)
+}
+
 func (b *Client) prepRequest(method string, path string, body io.Reader) (*http.Request, error) {
 	req, err := http.NewRequest(method, path, body)
 	if err != nil {
@@ -139,3 +139,139 @@ func TestClient_GetModifiedFilesOldNil(t *testing.T) {
 	Ok(t, err)
 	Equals(t, []string{"parent/child/file1.txt"}, files)
 }
+
+func TestClient_PullIsMergeable(t *testing.T) {
+	cases := []struct {
+		description string
+		mergeStatus string
+		builds      string
+		exp         bool
+	}{
+		{
+			"can merge",
+			`{"canMerge": true, "conflicted": false, "vetoes": []}`,
+			"",
+			true,
+		},
+		{
+			"conflicted",
+			`{"canMerge": false, "conflicted": true, "vetoes": []}`,
+			"",
+			false,
+		},
+		{
+			"needs approvals",
+			`{"canMerge": false, "conflicted": false, "vetoes": [{"summaryMessage": "Not all required reviewers have approved yet"}]}`,
+			"",
+			false,
+		},
+		{
+			"only our build is unsuccessful",
+			`{"canMerge": false, "conflicted": false, "vetoes": [{"summaryMessage": "Not all required builds are successful yet"}]}`,
+			`{"values": [{"key": "atlantis", "state": "INPROGRESS"}, {"key": "ci", "state": "SUCCESSFUL"}], "isLastPage": true}`,
+			true,
+		},
+		{
+			"other build failed",
+			`{"canMerge": false, "conflicted": false, "vetoes": [{"summaryMessage": "Not all required builds are successful yet"}]}`,
+			`{"values": [{"key": "atlantis", "state": "INPROGRESS"}, {"key": "ci", "state": "FAILED"}], "isLastPage": true}`,
+			false,
+		},
+		{
+			"builds and approvals",
+			`{"canMerge": false, "conflicted": false, "vetoes": [{"summaryMessage": "Not all required builds are successful yet"}, {"summaryMessage": "Not all required reviewers have approved yet"}]}`,
+			"",
+			false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.RequestURI {
+				case "/rest/api/1.0/projects/ow/repos/repo/pull-requests/1/merge":
+					w.Write([]byte(c.mergeStatus)) // nolint: errcheck
+					return
+				case "/rest/build-status/1.0/commits/sha?start=0":
+					if c.builds == "" {
+						t.Errorf("didn't expect builds to be checked")
+					}
+					w.Write([]byte(c.builds)) // nolint: errcheck
+					return
+				default:
+					t.Errorf("got unexpected request at %q", r.RequestURI)
+					http.Error(w, "not found", http.StatusNotFound)
+					return
+				}
+			}))
+			defer testServer.Close()
+
+			client, err := bitbucketserver.NewClient(http.DefaultClient, "user", "pass", testServer.URL, "runatlantis.io")
+			Ok(t, err)
+
+			mergeable, err := client.PullIsMergeable(models.Repo{
+				FullName:          "owner/repo",
+				Owner:             "owner",
+				Name:              "repo",
+				SanitizedCloneURL: fmt.Sprintf("%s/scm/ow/repo.git", testServer.URL),
+				VCSHost: models.VCSHost{
+					Type:     models.BitbucketServer,
+					Hostname: "bitbucket.example.com",
+				},
+			}, models.PullRequest{
+				Num:        1,
+				HeadCommit: "sha",
+			})
+			Ok(t, err)
+			Equals(t, c.exp, mergeable)
+		})
+	}
+}
+
+// HidePrevComments should delete our comments that shouldHide returns true
+// for, oldest first, at their current version.
+func TestClient_HidePrevComments(t *testing.T) {
+	var deleted []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.RequestURI {
+		case "GET /rest/api/1.0/projects/ow/repos/repo/pull-requests/1/activities?start=0":
+			// Activities are returned newest first.
+			w.Write([]byte(`{"values": [
+  {"action": "COMMENTED", "commentAction": "ADDED", "comment": {"id": 3, "version": 0, "text": "Ran Plan for dir: new", "author": {"name": "user"}}},
+  {"action": "APPROVED"},
+  {"action": "COMMENTED", "commentAction": "ADDED", "comment": {"id": 2, "version": 0, "text": "Ran Plan for dir: old", "author": {"name": "someone"}}}
+], "isLastPage": false, "nextPageStart": 3}`)) // nolint: errcheck
+		case "GET /rest/api/1.0/projects/ow/repos/repo/pull-requests/1/activities?start=3":
+			w.Write([]byte(`{"values": [
+  {"action": "COMMENTED", "commentAction": "ADDED", "comment": {"id": 1, "version": 2, "text": "Ran Plan for dir: old", "author": {"name": "user"}}}
+], "isLastPage": true}`)) // nolint: errcheck
+		case "DELETE /rest/api/1.0/projects/ow/repos/repo/pull-requests/1/comments/1?version=2":
+			deleted = append(deleted, "1")
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("got unexpected request %s %q", r.Method, r.RequestURI)
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+
+	client, err := bitbucketserver.NewClient(http.DefaultClient, "user", "pass", testServer.URL, "runatlantis.io")
+	Ok(t, err)
+	var seen []string
+	err = client.HidePrevComments(models.Repo{
+		FullName:          "owner/repo",
+		Owner:             "owner",
+		Name:              "repo",
+		SanitizedCloneURL: fmt.Sprintf("%s/scm/ow/repo.git", testServer.URL),
+		VCSHost: models.VCSHost{
+			Type:     models.BitbucketServer,
+			Hostname: "bitbucket.example.com",
+		},
+	}, 1, func(comment string) bool {
+		seen = append(seen, comment)
+		return comment == "Ran Plan for dir: old"
+	})
+	Ok(t, err)
+	Equals(t, []string{"Ran Plan for dir: old", "Ran Plan for dir: new"}, seen)
+	Equals(t, []string{"1"}, deleted)
+}
@@ -2,9 +2,14 @@ package bitbucketserver
 
 const (
 	PullCreatedHeader        = "pr:opened"
+	PullFromRefUpdatedHeader = "pr:from_ref_updated"
 	PullMergedHeader         = "pr:merged"
 	PullDeclinedHeader       = "pr:declined"
+	PullDeletedHeader        = "pr:deleted"
 	PullCommentCreatedHeader = "pr:comment:added"
+	// DiagnosticsPingHeader is sent when "Test connection" is clicked on the
+	// webhook in Bitbucket.
+	DiagnosticsPingHeader = "diagnostics:ping"
 )
 
 type CommentEvent struct {
@@ -26,6 +31,8 @@ type PullRequest struct {
 	FromRef   *Ref    `json:"fromRef,omitempty" validate:"required"`
 	ToRef     *Ref    `json:"toRef,omitempty" validate:"required"`
 	State     *string `json:"state,omitempty" validate:"required"`
+	Version   *int    `json:"version,omitempty"`
+	Draft     *bool   `json:"draft,omitempty"`
 	Reviewers []struct {
 		Approved *bool `json:"approved,omitempty" validate:"required"`
 	} `json:"reviewers,omitempty" validate:"required"`
@@ -71,4 +78,33 @@ type Changes struct {
 type MergeStatus struct {
 	CanMerge   *bool `json:"canMerge,omitempty" validate:"required"`
 	Conflicted *bool `json:"conflicted,omitempty" validate:"required"`
+	Vetoes     []struct {
+		SummaryMessage *string `json:"summaryMessage,omitempty"`
+	} `json:"vetoes,omitempty"`
+}
+
+type Activities struct {
+	Values []struct {
+		Action        *string          `json:"action,omitempty" validate:"required"`
+		CommentAction *string          `json:"commentAction,omitempty"`
+		Comment       *ActivityComment `json:"comment,omitempty"`
+	} `json:"values,omitempty" validate:"required"`
+	NextPageStart *int  `json:"nextPageStart,omitempty"`
+	IsLastPage    *bool `json:"isLastPage,omitempty" validate:"required"`
+}
+
+type ActivityComment struct {
+	ID      *int    `json:"id,omitempty" validate:"required"`
+	Version *int    `json:"version,omitempty" validate:"required"`
+	Text    *string `json:"text,omitempty" validate:"required"`
+	Author  *Actor  `json:"author,omitempty"`
+}
+
+type BuildStatuses struct {
+	Values []struct {
+		Key   *string `json:"key,omitempty" validate:"required"`
+		State *string `json:"state,omitempty" validate:"required"`
+	} `json:"values,omitempty" validate:"required"`
+	NextPageStart *int  `json:"nextPageStart,omitempty"`
+	IsLastPage    *bool `json:"isLastPage,omitempty" validate:"required"`
 }
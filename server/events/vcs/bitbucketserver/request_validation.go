@@ -2,9 +2,7 @@ package bitbucketserver
 
 import (
 	"crypto/hmac"
-	"crypto/sha1" // nolint: gosec
 	"crypto/sha256"
-	"crypto/sha512"
 	"encoding/hex"
 	"fmt"
 	"hash"
@@ -51,17 +49,12 @@ func messageMAC(signature string) ([]byte, func() hash.Hash, error) {
 		return nil, nil, fmt.Errorf("error parsing signature %q", signature)
 	}
 
-	var hashFunc func() hash.Hash
-	switch sigParts[0] {
-	case "sha1":
-		hashFunc = sha1.New
-	case "sha256":
-		hashFunc = sha256.New
-	case "sha512":
-		hashFunc = sha512.New
-	default:
-		return nil, nil, fmt.Errorf("unknown hash type prefix: %q", sigParts[0])
+	// Bitbucket Server only signs webhooks with HMAC-SHA256 so we don't
+	// accept weaker hashes.
+	if sigParts[0] != "sha256" {
+		return nil, nil, fmt.Errorf("unsupported hash type prefix: %q, expected \"sha256\"", sigParts[0])
 	}
+	hashFunc := sha256.New
 
 	buf, err := hex.DecodeString(sigParts[1])
 	if err != nil {
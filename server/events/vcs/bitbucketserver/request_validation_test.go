@@ -22,3 +22,13 @@ func TestValidateSignature_Invalid(t *testing.T) {
 	err := bitbucketserver.ValidateSignature([]byte(body), sig, []byte(secret))
 	ErrEquals(t, "payload signature check failed", err)
 }
+
+func TestValidateSignature_OnlySHA256(t *testing.T) {
+	err := bitbucketserver.ValidateSignature([]byte("body"), "sha1=9c222ccd8b494a3c3f2bb49c7f4e8396bdae5bd6", []byte("mysecret"))
+	ErrEquals(t, `unsupported hash type prefix: "sha1", expected "sha256"`, err)
+}
+
+func TestValidateSignature_Missing(t *testing.T) {
+	err := bitbucketserver.ValidateSignature([]byte("body"), "", []byte("mysecret"))
+	ErrEquals(t, "missing signature", err)
+}
@@ -25,5 +25,57 @@ type Client interface {
 	CreateComment(repo models.Repo, pullNum int, comment string) error
 	PullIsApproved(repo models.Repo, pull models.PullRequest) (bool, error)
 	PullIsMergeable(repo models.Repo, pull models.PullRequest) (bool, error)
-	UpdateStatus(repo models.Repo, pull models.PullRequest, state models.CommitStatus, description string) error
+	// UpdateStatus sets the status with the name src on the head commit of
+	// pull. Atlantis sets one status per command and one per project.
+	UpdateStatus(repo models.Repo, pull models.PullRequest, state models.CommitStatus, src string, description string) error
+	// ReactToComment adds reaction to the comment with id commentID on the
+	// pull request. Hosts that don't support reactions do nothing.
+	ReactToComment(repo models.Repo, pullNum int, commentID int64, reaction string) error
+	// GetPullLabels returns the names of the labels on the pull request.
+	// Hosts that don't support labels return no labels.
+	GetPullLabels(repo models.Repo, pull models.PullRequest) ([]string, error)
+	// PullIsDraft returns true if the pull request is a draft. Hosts that
+	// don't support drafts return false.
+	PullIsDraft(repo models.Repo, pull models.PullRequest) (bool, error)
+	// HidePrevComments hides each of our comments on the pull request that
+	// shouldHide returns true for. shouldHide is called with the comments'
+	// bodies, oldest first. Hosts that can't hide comments delete them and
+	// hosts that can't do either do nothing.
+	HidePrevComments(repo models.Repo, pullNum int, shouldHide func(comment string) bool) error
+	// MergePull merges the pull request with opts.
+	MergePull(repo models.Repo, pull models.PullRequest, opts MergeOptions) error
 }
+
+// MergeOptions are how MergePull merges a pull request.
+type MergeOptions struct {
+	// Method is one of MergeMethods. Hosts that don't support it use the
+	// closest method they do.
+	Method string
+	// DeleteSourceBranch is true if the pull request's source branch should
+	// be deleted once it's merged. Hosts don't delete protected branches.
+	DeleteSourceBranch bool
+}
+
+// Merge methods used by MergeOptions.
+const (
+	// MergeCommitMethod merges the pull request with a merge commit.
+	MergeCommitMethod = "merge"
+	// SquashMethod squashes the pull request's commits into one commit.
+	SquashMethod = "squash"
+	// RebaseMethod rebases the pull request's commits onto the base branch.
+	RebaseMethod = "rebase"
+)
+
+// MergeMethods are the methods MergePull supports.
+var MergeMethods = []string{MergeCommitMethod, SquashMethod, RebaseMethod}
+
+// Reactions used to acknowledge comment commands. They're named after GitLab's
+// award emoji and each client translates them to what its host supports.
+const (
+	// ReceivedReaction is added when a command comment is received.
+	ReceivedReaction = "eyes"
+	// SuccessReaction is added when a command finished successfully.
+	SuccessReaction = "white_check_mark"
+	// FailureReaction is added when a command finished with errors.
+	FailureReaction = "x"
+)
@@ -0,0 +1,304 @@
+package gitea
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+	"github.com/runatlantis/atlantis/server/events/vcs/common"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+// maxCommentLength is the maximum number of chars we put in a single comment.
+// Gitea doesn't have a limit but very long comments are slow to render.
+const maxCommentLength = 65536
+
+// pageSize is the number of items we request per page from list endpoints.
+// Gitea caps it at its MAX_RESPONSE_ITEMS setting which defaults to 50.
+const pageSize = 50
+
+type Client struct {
+	HttpClient  *http.Client
+	Token       string
+	BaseURL     string
+	AtlantisURL string
+}
+
+// NewClient builds a Gitea client. Returns an error if the baseURL is
+// malformed. httpClient is the client to use to make the requests, token is
+// the access token used to authenticate the requests, baseURL is the URL
+// Gitea is served at, ex. https://gitea.corp.com. It can include a path if
+// Gitea isn't served from the root of its domain. atlantisURL is the URL for
+// Atlantis that will be linked to from the commit statuses.
+func NewClient(httpClient *http.Client, token string, baseURL string, atlantisURL string) (*Client, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	parsedURL, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing %s", baseURL)
+	}
+	if parsedURL.Scheme == "" {
+		return nil, fmt.Errorf("must have 'http://' or 'https://' in base url %q", baseURL)
+	}
+	return &Client{
+		HttpClient:  httpClient,
+		Token:       token,
+		BaseURL:     strings.TrimSuffix(parsedURL.String(), "/"),
+		AtlantisURL: atlantisURL,
+	}, nil
+}
+
+// GetModifiedFiles returns the names of files that were modified in the pull request.
+// The names include the path to the file from the repo root, ex. parent/child/file.txt.
+func (c *Client) GetModifiedFiles(repo models.Repo, pull models.PullRequest) ([]string, error) {
+	var files []string
+	// We'll only loop 1000 times as a safety measure.
+	maxLoops := 1000
+	for page := 1; page <= maxLoops; page++ {
+		path := fmt.Sprintf("%s/files?page=%d&limit=%d", c.pullURL(repo, pull.Num), page, pageSize)
+		resp, err := c.makeRequest("GET", path, nil)
+		if err != nil {
+			return nil, err
+		}
+		var changedFiles []ChangedFile
+		if err := c.unmarshal(resp, &changedFiles); err != nil {
+			return nil, err
+		}
+		for _, f := range changedFiles {
+			files = append(files, *f.Filename)
+
+			// If the file was renamed, we'll want to run plan in the directory
+			// it was moved from as well.
+			if f.PreviousFilename != nil && *f.PreviousFilename != "" {
+				files = append(files, *f.PreviousFilename)
+			}
+		}
+		if len(changedFiles) < pageSize {
+			break
+		}
+	}
+	return files, nil
+}
+
+// CreateComment creates a comment on the pull request. It will write multiple
+// comments if a single comment is too long.
+func (c *Client) CreateComment(repo models.Repo, pullNum int, comment string) error {
+	sepEnd := "\n```\n</details>" +
+		"\n<br>\n\n**Warning**: Output length greater than max comment size. Continued in next comment."
+	sepStart := "Continued from previous comment.\n<details><summary>Show Output</summary>\n\n" +
+		"```diff\n"
+	comments := common.SplitComment(comment, maxCommentLength, sepEnd, sepStart)
+	for _, comment := range comments {
+		bodyBytes, err := json.Marshal(map[string]string{"body": comment})
+		if err != nil {
+			return errors.Wrap(err, "json encoding")
+		}
+		// Pull requests are issues in Gitea so their comments are created
+		// through the issues API.
+		path := fmt.Sprintf("%s/issues/%d/comments", c.repoURL(repo), pullNum)
+		if _, err := c.makeRequest("POST", path, bytes.NewBuffer(bodyBytes)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PullIsApproved returns true if the pull request has an approving review
+// that hasn't been dismissed.
+func (c *Client) PullIsApproved(repo models.Repo, pull models.PullRequest) (bool, error) {
+	resp, err := c.makeRequest("GET", fmt.Sprintf("%s/reviews", c.pullURL(repo, pull.Num)), nil)
+	if err != nil {
+		return false, err
+	}
+	var reviews []Review
+	if err := c.unmarshal(resp, &reviews); err != nil {
+		return false, err
+	}
+	for _, review := range reviews {
+		if *review.State == ApprovedReviewState && !review.Dismissed {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// PullIsMergeable returns true if Gitea found no conflicts when it tested
+// merging the pull request.
+func (c *Client) PullIsMergeable(repo models.Repo, pull models.PullRequest) (bool, error) {
+	giteaPull, err := c.GetPullRequest(repo, pull.Num)
+	if err != nil {
+		return false, err
+	}
+	return giteaPull.Mergeable != nil && *giteaPull.Mergeable, nil
+}
+
+// UpdateStatus sets the commit status of the pull request's head commit.
+func (c *Client) UpdateStatus(repo models.Repo, pull models.PullRequest, status models.CommitStatus, src string, description string) error {
+	giteaState := "error"
+	switch status {
+	case models.PendingCommitStatus:
+		giteaState = "pending"
+	case models.SuccessCommitStatus:
+		giteaState = "success"
+	case models.FailedCommitStatus:
+		giteaState = "failure"
+	}
+
+	bodyBytes, err := json.Marshal(map[string]string{
+		"state":       giteaState,
+		"description": description,
+		"target_url":  c.AtlantisURL,
+		"context":     src,
+	})
+	if err != nil {
+		return errors.Wrap(err, "json encoding")
+	}
+	path := fmt.Sprintf("%s/statuses/%s", c.repoURL(repo), url.PathEscape(pull.HeadCommit))
+	_, err = c.makeRequest("POST", path, bytes.NewBuffer(bodyBytes))
+	return err
+}
+
+// ReactToComment adds a reaction to the pull request comment. Gitea only
+// allows the reactions in its ui.REACTIONS setting, which by default are the
+// same as GitHub's, so ours are mapped to the closest one.
+func (c *Client) ReactToComment(repo models.Repo, pullNum int, commentID int64, reaction string) error {
+	content := reaction
+	switch reaction {
+	case vcs.SuccessReaction:
+		content = "+1"
+	case vcs.FailureReaction:
+		content = "confused"
+	}
+	bodyBytes, err := json.Marshal(map[string]string{"content": content})
+	if err != nil {
+		return errors.Wrap(err, "json encoding")
+	}
+	path := fmt.Sprintf("%s/issues/comments/%d/reactions", c.repoURL(repo), commentID)
+	_, err = c.makeRequest("POST", path, bytes.NewBuffer(bodyBytes))
+	return err
+}
+
+// GetPullLabels returns the names of the labels on the pull request.
+func (c *Client) GetPullLabels(repo models.Repo, pull models.PullRequest) ([]string, error) {
+	giteaPull, err := c.GetPullRequest(repo, pull.Num)
+	if err != nil {
+		return nil, err
+	}
+	var labels []string
+	for _, l := range giteaPull.Labels {
+		labels = append(labels, *l.Name)
+	}
+	return labels, nil
+}
+
+// PullIsDraft returns true if the pull request is a draft, which Gitea calls
+// work in progress.
+func (c *Client) PullIsDraft(repo models.Repo, pull models.PullRequest) (bool, error) {
+	giteaPull, err := c.GetPullRequest(repo, pull.Num)
+	if err != nil {
+		return false, err
+	}
+	return giteaPull.Draft != nil && *giteaPull.Draft, nil
+}
+
+// HidePrevComments does nothing because Gitea can't hide comments.
+func (c *Client) HidePrevComments(repo models.Repo, pullNum int, shouldHide func(comment string) bool) error {
+	return nil
+}
+
+// MergePull merges the pull request.
+func (c *Client) MergePull(repo models.Repo, pull models.PullRequest, opts vcs.MergeOptions) error {
+	bodyBytes, err := json.Marshal(map[string]interface{}{
+		"Do":                        opts.Method,
+		"delete_branch_after_merge": opts.DeleteSourceBranch,
+	})
+	if err != nil {
+		return errors.Wrap(err, "json encoding")
+	}
+	_, err = c.makeRequest("POST", fmt.Sprintf("%s/merge", c.pullURL(repo, pull.Num)), bytes.NewBuffer(bodyBytes))
+	return err
+}
+
+// GetPullRequest returns the pull request.
+func (c *Client) GetPullRequest(repo models.Repo, pullNum int) (*PullRequest, error) {
+	resp, err := c.makeRequest("GET", c.pullURL(repo, pullNum), nil)
+	if err != nil {
+		return nil, err
+	}
+	var pull PullRequest
+	if err := c.unmarshal(resp, &pull); err != nil {
+		return nil, err
+	}
+	return &pull, nil
+}
+
+func (c *Client) repoURL(repo models.Repo) string {
+	return fmt.Sprintf("%s/api/v1/repos/%s/%s", c.BaseURL, url.PathEscape(repo.Owner), url.PathEscape(repo.Name))
+}
+
+func (c *Client) pullURL(repo models.Repo, pullNum int) string {
+	return fmt.Sprintf("%s/pulls/%d", c.repoURL(repo), pullNum)
+}
+
+func (c *Client) unmarshal(resp []byte, v interface{}) error {
+	if err := json.Unmarshal(resp, v); err != nil {
+		return errors.Wrapf(err, "Could not parse response %q", string(resp))
+	}
+	// The list endpoints return arrays so we validate each of their elements.
+	var err error
+	if rv := reflect.Indirect(reflect.ValueOf(v)); rv.Kind() == reflect.Slice {
+		err = validator.New().Var(rv.Interface(), "dive")
+	} else {
+		err = validator.New().Struct(v)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "API response %q was missing fields", string(resp))
+	}
+	return nil
+}
+
+// prepRequest adds the token auth.
+func (c *Client) prepRequest(method string, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Authorization", "token "+c.Token)
+	return req, nil
+}
+
+func (c *Client) makeRequest(method string, path string, reqBody io.Reader) ([]byte, error) {
+	req, err := c.prepRequest(method, path, reqBody)
+	if err != nil {
+		return nil, errors.Wrap(err, "constructing request")
+	}
+	if reqBody != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+	resp, err := c.HttpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	requestStr := fmt.Sprintf("%s %s", method, path)
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("making request %q unexpected status code: %d, body: %s", requestStr, resp.StatusCode, string(respBody))
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading response from request %q", requestStr)
+	}
+	return respBody, nil
+}
@@ -0,0 +1,248 @@
+package gitea_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+	"github.com/runatlantis/atlantis/server/events/vcs/gitea"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+var repo = models.Repo{
+	FullName: "owner/repo",
+	Owner:    "owner",
+	Name:     "repo",
+}
+
+func TestNewClient_RequiresScheme(t *testing.T) {
+	_, err := gitea.NewClient(nil, "token", "gitea.example.com", "runatlantis.io")
+	ErrEquals(t, `must have 'http://' or 'https://' in base url "gitea.example.com"`, err)
+}
+
+// Should follow the pagination and include the previous names of renamed
+// files.
+func TestClient_GetModifiedFilesPagination(t *testing.T) {
+	// The first page needs to be full for us to request the second one.
+	var firstPage []string
+	for i := 0; i < 50; i++ {
+		firstPage = append(firstPage, fmt.Sprintf(`{"filename": "file%d.txt"}`, i))
+	}
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Equals(t, "token token", r.Header.Get("Authorization"))
+		switch r.RequestURI {
+		case "/api/v1/repos/owner/repo/pulls/1/files?page=1&limit=50":
+			w.Write([]byte("[" + strings.Join(firstPage, ",") + "]")) // nolint: errcheck
+		case "/api/v1/repos/owner/repo/pulls/1/files?page=2&limit=50":
+			w.Write([]byte(`[{"filename": "dir/new.txt", "previous_filename": "old.txt"}]`)) // nolint: errcheck
+		default:
+			t.Errorf("got unexpected request at %q", r.RequestURI)
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+
+	client, err := gitea.NewClient(http.DefaultClient, "token", testServer.URL, "runatlantis.io")
+	Ok(t, err)
+	files, err := client.GetModifiedFiles(repo, models.PullRequest{Num: 1})
+	Ok(t, err)
+	Equals(t, 52, len(files))
+	Equals(t, "file0.txt", files[0])
+	Equals(t, []string{"dir/new.txt", "old.txt"}, files[50:])
+}
+
+// Should use the sub-path Gitea is served from.
+func TestClient_BaseURLWithPath(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Equals(t, "/gitea/api/v1/repos/owner/repo/pulls/1", r.RequestURI)
+		w.Write([]byte(pullJSON(`"draft": true`))) // nolint: errcheck
+	}))
+	defer testServer.Close()
+
+	client, err := gitea.NewClient(http.DefaultClient, "token", testServer.URL+"/gitea/", "runatlantis.io")
+	Ok(t, err)
+	draft, err := client.PullIsDraft(repo, models.PullRequest{Num: 1})
+	Ok(t, err)
+	Equals(t, true, draft)
+}
+
+// Should set the status on the head commit with our context and map the
+// atlantis statuses to Gitea states.
+func TestClient_UpdateStatus(t *testing.T) {
+	cases := []struct {
+		status   models.CommitStatus
+		expState string
+	}{
+		{models.PendingCommitStatus, "pending"},
+		{models.SuccessCommitStatus, "success"},
+		{models.FailedCommitStatus, "failure"},
+	}
+	for _, c := range cases {
+		t.Run(c.expState, func(t *testing.T) {
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Equals(t, "POST", r.Method)
+				Equals(t, "/api/v1/repos/owner/repo/statuses/abc123", r.RequestURI)
+				body, err := ioutil.ReadAll(r.Body)
+				Ok(t, err)
+				var status map[string]string
+				Ok(t, json.Unmarshal(body, &status))
+				Equals(t, map[string]string{
+					"state":       c.expState,
+					"description": "description",
+					"target_url":  "https://atlantis.example.com",
+					"context":     "atlantis/plan",
+				}, status)
+				w.WriteHeader(http.StatusCreated)
+			}))
+			defer testServer.Close()
+
+			client, err := gitea.NewClient(http.DefaultClient, "token", testServer.URL, "https://atlantis.example.com")
+			Ok(t, err)
+			Ok(t, client.UpdateStatus(repo, models.PullRequest{Num: 1, HeadCommit: "abc123"}, c.status, "atlantis/plan", "description"))
+		})
+	}
+}
+
+// Should be approved only if there's an approving review that wasn't
+// dismissed.
+func TestClient_PullIsApproved(t *testing.T) {
+	cases := []struct {
+		reviews     string
+		expApproved bool
+	}{
+		{`[]`, false},
+		{`[{"state": "COMMENT"}, {"state": "REQUEST_CHANGES"}]`, false},
+		{`[{"state": "APPROVED", "dismissed": true}]`, false},
+		{`[{"state": "REQUEST_CHANGES"}, {"state": "APPROVED"}]`, true},
+	}
+	for _, c := range cases {
+		t.Run(c.reviews, func(t *testing.T) {
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Equals(t, "/api/v1/repos/owner/repo/pulls/1/reviews", r.RequestURI)
+				w.Write([]byte(c.reviews)) // nolint: errcheck
+			}))
+			defer testServer.Close()
+
+			client, err := gitea.NewClient(http.DefaultClient, "token", testServer.URL, "runatlantis.io")
+			Ok(t, err)
+			approved, err := client.PullIsApproved(repo, models.PullRequest{Num: 1})
+			Ok(t, err)
+			Equals(t, c.expApproved, approved)
+		})
+	}
+}
+
+func TestClient_PullIsMergeable(t *testing.T) {
+	cases := []struct {
+		mergeable    string
+		expMergeable bool
+	}{
+		{`"mergeable": true`, true},
+		{`"mergeable": false`, false},
+		{`"draft": false`, false},
+	}
+	for _, c := range cases {
+		t.Run(c.mergeable, func(t *testing.T) {
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(pullJSON(c.mergeable))) // nolint: errcheck
+			}))
+			defer testServer.Close()
+
+			client, err := gitea.NewClient(http.DefaultClient, "token", testServer.URL, "runatlantis.io")
+			Ok(t, err)
+			mergeable, err := client.PullIsMergeable(repo, models.PullRequest{Num: 1})
+			Ok(t, err)
+			Equals(t, c.expMergeable, mergeable)
+		})
+	}
+}
+
+// Should comment through the issues API.
+func TestClient_CreateComment(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Equals(t, "POST", r.Method)
+		Equals(t, "/api/v1/repos/owner/repo/issues/1/comments", r.RequestURI)
+		body, err := ioutil.ReadAll(r.Body)
+		Ok(t, err)
+		Equals(t, `{"body":"comment"}`, string(body))
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer testServer.Close()
+
+	client, err := gitea.NewClient(http.DefaultClient, "token", testServer.URL, "runatlantis.io")
+	Ok(t, err)
+	Ok(t, client.CreateComment(repo, 1, "comment"))
+}
+
+// Should map our reactions to the ones Gitea allows by default.
+func TestClient_ReactToComment(t *testing.T) {
+	cases := []struct {
+		reaction   string
+		expContent string
+	}{
+		{vcs.SuccessReaction, "+1"},
+		{vcs.FailureReaction, "confused"},
+	}
+	for _, c := range cases {
+		t.Run(c.reaction, func(t *testing.T) {
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Equals(t, "POST", r.Method)
+				Equals(t, "/api/v1/repos/owner/repo/issues/comments/5/reactions", r.RequestURI)
+				body, err := ioutil.ReadAll(r.Body)
+				Ok(t, err)
+				Equals(t, `{"content":"`+c.expContent+`"}`, string(body))
+				w.WriteHeader(http.StatusCreated)
+			}))
+			defer testServer.Close()
+
+			client, err := gitea.NewClient(http.DefaultClient, "token", testServer.URL, "runatlantis.io")
+			Ok(t, err)
+			Ok(t, client.ReactToComment(repo, 1, 5, c.reaction))
+		})
+	}
+}
+
+func TestClient_GetPullLabels(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Equals(t, "/api/v1/repos/owner/repo/pulls/1", r.RequestURI)
+		w.Write([]byte(pullJSON(`"labels": [{"name": "one"}, {"name": "two"}]`))) // nolint: errcheck
+	}))
+	defer testServer.Close()
+
+	client, err := gitea.NewClient(http.DefaultClient, "token", testServer.URL, "runatlantis.io")
+	Ok(t, err)
+	labels, err := client.GetPullLabels(repo, models.PullRequest{Num: 1})
+	Ok(t, err)
+	Equals(t, []string{"one", "two"}, labels)
+}
+
+func TestClient_ErrorStatus(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not allowed", http.StatusUnauthorized)
+	}))
+	defer testServer.Close()
+
+	client, err := gitea.NewClient(http.DefaultClient, "token", testServer.URL, "runatlantis.io")
+	Ok(t, err)
+	_, err = client.PullIsMergeable(repo, models.PullRequest{Num: 1})
+	ErrContains(t, "unexpected status code: 401", err)
+}
+
+// pullJSON returns a pull request API response with extraFields added.
+func pullJSON(extraFields string) string {
+	return `{
+  "number": 1,
+  "html_url": "https://gitea.example.com/owner/repo/pulls/1",
+  "state": "open",
+  "user": {"login": "user"},
+  "head": {"ref": "branch", "sha": "abc123", "repo": {"full_name": "owner/repo", "clone_url": "https://gitea.example.com/owner/repo.git"}},
+  "base": {"ref": "master", "sha": "def456", "repo": {"full_name": "owner/repo", "clone_url": "https://gitea.example.com/owner/repo.git"}},
+  ` + extraFields + `
+}`
+}
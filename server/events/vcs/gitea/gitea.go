@@ -0,0 +1,3 @@
+// Package gitea holds code for Gitea and for Forgejo, which is a fork of
+// Gitea with the same API and webhooks.
+package gitea
@@ -0,0 +1,95 @@
+package gitea
+
+// Webhook event types sent in the X-Gitea-Event header. Forgejo sends the
+// same header for compatibility.
+const (
+	PullRequestEventType  = "pull_request"
+	IssueCommentEventType = "issue_comment"
+)
+
+// Pull request event actions.
+const (
+	PullOpenedAction       = "opened"
+	PullSynchronizedAction = "synchronized"
+	PullClosedAction       = "closed"
+)
+
+// CommentCreatedAction is the action of issue comment events for new comments.
+const CommentCreatedAction = "created"
+
+// Pull request states.
+const (
+	PullOpenState   = "open"
+	PullClosedState = "closed"
+)
+
+// ApprovedReviewState is the state of reviews that approved the pull request.
+const ApprovedReviewState = "APPROVED"
+
+type PullRequestEvent struct {
+	Action      *string      `json:"action,omitempty" validate:"required"`
+	PullRequest *PullRequest `json:"pull_request,omitempty" validate:"required"`
+	Sender      *User        `json:"sender,omitempty" validate:"required"`
+}
+
+type IssueCommentEvent struct {
+	Action     *string     `json:"action,omitempty" validate:"required"`
+	Issue      *Issue      `json:"issue,omitempty" validate:"required"`
+	Comment    *Comment    `json:"comment,omitempty" validate:"required"`
+	Repository *Repository `json:"repository,omitempty" validate:"required"`
+	// IsPull is true if the comment is on a pull request rather than an
+	// issue.
+	IsPull bool `json:"is_pull,omitempty"`
+}
+
+type PullRequest struct {
+	Number    *int        `json:"number,omitempty" validate:"required"`
+	HTMLURL   *string     `json:"html_url,omitempty" validate:"required"`
+	State     *string     `json:"state,omitempty" validate:"required"`
+	User      *User       `json:"user,omitempty" validate:"required"`
+	Head      *PullBranch `json:"head,omitempty" validate:"required"`
+	Base      *PullBranch `json:"base,omitempty" validate:"required"`
+	Mergeable *bool       `json:"mergeable,omitempty"`
+	Draft     *bool       `json:"draft,omitempty"`
+	Labels    []Label     `json:"labels,omitempty" validate:"dive"`
+}
+
+type PullBranch struct {
+	Ref  *string     `json:"ref,omitempty" validate:"required"`
+	Sha  *string     `json:"sha,omitempty" validate:"required"`
+	Repo *Repository `json:"repo,omitempty" validate:"required"`
+}
+
+type Repository struct {
+	FullName *string `json:"full_name,omitempty" validate:"required"`
+	CloneURL *string `json:"clone_url,omitempty" validate:"required"`
+}
+
+type User struct {
+	Login *string `json:"login,omitempty" validate:"required"`
+}
+
+type Issue struct {
+	Number *int `json:"number,omitempty" validate:"required"`
+}
+
+type Comment struct {
+	ID   *int64  `json:"id,omitempty" validate:"required"`
+	Body *string `json:"body,omitempty" validate:"required"`
+	User *User   `json:"user,omitempty" validate:"required"`
+}
+
+type Label struct {
+	Name *string `json:"name,omitempty" validate:"required"`
+}
+
+type ChangedFile struct {
+	Filename         *string `json:"filename,omitempty" validate:"required"`
+	PreviousFilename *string `json:"previous_filename,omitempty"`
+}
+
+type Review struct {
+	State     *string `json:"state,omitempty" validate:"required"`
+	Dismissed bool    `json:"dismissed,omitempty"`
+	Stale     bool    `json:"stale,omitempty"`
+}
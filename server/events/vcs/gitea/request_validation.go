@@ -0,0 +1,29 @@
+package gitea
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// ValidateSignature returns an error if signature, which is the value of the
+// X-Gitea-Signature header, isn't the hex encoded HMAC-SHA256 of payload
+// using secretKey.
+func ValidateSignature(payload []byte, signature string, secretKey []byte) error {
+	if signature == "" {
+		return errors.New("missing signature")
+	}
+	messageMAC, err := hex.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("error decoding signature %q: %v", signature, err)
+	}
+	mac := hmac.New(sha256.New, secretKey)
+	mac.Write(payload)
+	if !hmac.Equal(messageMAC, mac.Sum(nil)) {
+		return errors.New("payload signature check failed")
+	}
+	return nil
+}
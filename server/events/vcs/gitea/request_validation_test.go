@@ -0,0 +1,23 @@
+package gitea_test
+
+import (
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/vcs/gitea"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestValidateSignature(t *testing.T) {
+	payload := []byte(`{"action":"opened"}`)
+	// Generated with: echo -n '{"action":"opened"}' | openssl dgst -sha256 -hmac secret
+	sig := "d42142b53efbc7cf5cd20b6e074eb33707e0de3b368f698e6d6f6c824ffb8d37"
+	Ok(t, gitea.ValidateSignature(payload, sig, []byte("secret")))
+}
+
+func TestValidateSignature_Invalid(t *testing.T) {
+	payload := []byte(`{"action":"opened"}`)
+	ErrEquals(t, "missing signature", gitea.ValidateSignature(payload, "", []byte("secret")))
+	ErrContains(t, `error decoding signature "zz"`, gitea.ValidateSignature(payload, "zz", []byte("secret")))
+	ErrEquals(t, "payload signature check failed", gitea.ValidateSignature(payload, "abcd", []byte("secret")))
+	ErrEquals(t, "payload signature check failed", gitea.ValidateSignature(payload, "d42142b53efbc7cf5cd20b6e074eb33707e0de3b368f698e6d6f6c824ffb8d37", []byte("wrong")))
+}
@@ -0,0 +1,212 @@
+package vcs
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+)
+
+// GithubAppCloneUser is the username used to clone repos with an app's
+// installation token.
+const GithubAppCloneUser = "x-access-token"
+
+// githubAppJWTLifetime is how long the JWTs we sign to authenticate as the app
+// are valid for. GitHub allows at most 10 minutes.
+const githubAppJWTLifetime = 9 * time.Minute
+
+// githubAppTokenRefreshWindow is how long before an installation token
+// expires that we fetch a new one. It needs to be long enough for a token
+// we've handed out, ex. in a clone url, to still be valid when it's used.
+const githubAppTokenRefreshWindow = 5 * time.Minute
+
+// GithubAppCredentials authenticates to GitHub as an installation of a
+// GitHub App. It exchanges a JWT signed with the app's private key for an
+// installation token and fetches a new one before it expires.
+type GithubAppCredentials struct {
+	appID int64
+	key   *rsa.PrivateKey
+	// apps is authenticated as the app, rather than the installation, so it
+	// can create installation tokens.
+	apps *github.AppsService
+	ctx  context.Context
+	// transport sends the requests authenticated with the credentials.
+	transport http.RoundTripper
+
+	// mutex guards the fields below.
+	mutex          sync.Mutex
+	installationID int64
+	token          string
+	expiresAt      time.Time
+}
+
+// NewGithubAppCredentials returns credentials for the app with id appID whose
+// private key is in PEM format at keyFile. hostname is the GitHub hostname,
+// ex. github.com. Requests are sent with httpClient's transport.
+func NewGithubAppCredentials(httpClient *http.Client, hostname string, appID int64, keyFile string) (*GithubAppCredentials, error) {
+	keyBytes, err := ioutil.ReadFile(keyFile) // nolint: gosec
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading GitHub App private key from %s", keyFile)
+	}
+	key, err := parseRSAPrivateKey(keyBytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing GitHub App private key from %s", keyFile)
+	}
+	creds := &GithubAppCredentials{
+		appID:     appID,
+		key:       key,
+		ctx:       context.Background(),
+		transport: baseTransport(httpClient),
+	}
+	appClient, err := newGithubClient(hostname, &http.Client{Transport: &githubAppJWTTransport{creds: creds}})
+	if err != nil {
+		return nil, err
+	}
+	creds.apps = appClient.client.Apps
+	return creds, nil
+}
+
+// NewGithubAppClient returns a GitHub client that authenticates with the
+// installation tokens from creds.
+func NewGithubAppClient(hostname string, creds *GithubAppCredentials) (*GithubClient, error) {
+	return newGithubClient(hostname, &http.Client{Transport: &githubAppTransport{creds: creds}})
+}
+
+// Token returns a valid installation token, fetching a new one if we don't
+// have one or the one we have is about to expire.
+func (c *GithubAppCredentials) Token() (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.token != "" && time.Until(c.expiresAt) > githubAppTokenRefreshWindow {
+		return c.token, nil
+	}
+
+	if c.installationID == 0 {
+		id, err := c.findInstallationID()
+		if err != nil {
+			return "", err
+		}
+		c.installationID = id
+	}
+	token, _, err := c.apps.CreateInstallationToken(c.ctx, c.installationID)
+	if err != nil {
+		return "", errors.Wrapf(err, "creating token for GitHub App installation %d", c.installationID)
+	}
+	c.token = token.GetToken()
+	c.expiresAt = token.GetExpiresAt()
+	return c.token, nil
+}
+
+// findInstallationID returns the id of the app's installation. We only
+// support apps that are installed once since otherwise we wouldn't know which
+// installation's token to use.
+func (c *GithubAppCredentials) findInstallationID() (int64, error) {
+	installations, _, err := c.apps.ListInstallations(c.ctx, nil)
+	if err != nil {
+		return 0, errors.Wrapf(err, "listing installations of GitHub App %d", c.appID)
+	}
+	if len(installations) != 1 {
+		return 0, fmt.Errorf("GitHub App %d must be installed on exactly one account but it's installed on %d", c.appID, len(installations))
+	}
+	return installations[0].GetID(), nil
+}
+
+// signJWT returns a JWT that authenticates as the app itself.
+func (c *GithubAppCredentials) signJWT() (string, error) {
+	// iat is set in the past to allow for clock drift between us and GitHub.
+	now := time.Now()
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]int64{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(githubAppJWTLifetime).Unix(),
+		"iss": c.appID,
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, c.key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", errors.Wrap(err, "signing GitHub App JWT")
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// parseRSAPrivateKey parses a PEM encoded RSA key in either PKCS1 format,
+// which is what GitHub generates, or PKCS8 format.
+func parseRSAPrivateKey(keyBytes []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(keyBytes)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("key is not an RSA key")
+	}
+	return key, nil
+}
+
+// githubAppJWTTransport authenticates requests as the app itself.
+type githubAppJWTTransport struct {
+	creds *GithubAppCredentials
+}
+
+func (t *githubAppJWTTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	jwt, err := t.creds.signJWT()
+	if err != nil {
+		return nil, err
+	}
+	return roundTripWithAuth(t.creds.transport, req, "Bearer "+jwt)
+}
+
+// githubAppTransport authenticates requests as the app's installation.
+type githubAppTransport struct {
+	creds *GithubAppCredentials
+}
+
+func (t *githubAppTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.creds.Token()
+	if err != nil {
+		return nil, err
+	}
+	return roundTripWithAuth(t.creds.transport, req, "token "+token)
+}
+
+// roundTripWithAuth sends a copy of req with transport with its Authorization
+// header set to auth. The copy is needed because RoundTrippers must not modify
+// requests.
+func roundTripWithAuth(transport http.RoundTripper, req *http.Request, auth string) (*http.Response, error) {
+	authed := new(http.Request)
+	*authed = *req
+	authed.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		authed.Header[k] = append([]string(nil), v...)
+	}
+	authed.Header.Set("Authorization", auth)
+	return transport.RoundTrip(authed)
+}
@@ -0,0 +1,173 @@
+package vcs_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestGithubAppCredentials_Token(t *testing.T) {
+	key, keyFile, cleanup := githubAppKey(t)
+	defer cleanup()
+
+	var tokenRequests int
+	expiresAt := time.Now().Add(time.Hour)
+	testServer := httptest.NewTLSServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api/v3/app/installations":
+				verifyGithubAppJWT(t, &key.PublicKey, r)
+				w.Write([]byte(`[{"id": 123}]`)) // nolint: errcheck
+			case "/api/v3/app/installations/123/access_tokens":
+				verifyGithubAppJWT(t, &key.PublicKey, r)
+				Equals(t, "POST", r.Method)
+				tokenRequests++
+				fmt.Fprintf(w, `{"token": "token%d", "expires_at": %q}`, tokenRequests, expiresAt.Format(time.RFC3339)) // nolint: errcheck
+			case "/api/v3/repos/owner/repo/pulls/1/files":
+				Equals(t, "token token1", r.Header.Get("Authorization"))
+				w.Write([]byte(`[{"filename": "main.tf"}]`)) // nolint: errcheck
+			default:
+				t.Errorf("got unexpected request at %q", r.RequestURI)
+				http.Error(w, "not found", http.StatusNotFound)
+			}
+		}))
+	defer testServer.Close()
+	defer disableSSLVerification()()
+
+	testServerURL, err := url.Parse(testServer.URL)
+	Ok(t, err)
+	creds, err := vcs.NewGithubAppCredentials(http.DefaultClient, testServerURL.Host, 1, keyFile)
+	Ok(t, err)
+
+	token, err := creds.Token()
+	Ok(t, err)
+	Equals(t, "token1", token)
+
+	t.Log("the token should be cached until it's about to expire")
+	token, err = creds.Token()
+	Ok(t, err)
+	Equals(t, "token1", token)
+	Equals(t, 1, tokenRequests)
+
+	t.Log("the client should authenticate with the installation token")
+	client, err := vcs.NewGithubAppClient(testServerURL.Host, creds)
+	Ok(t, err)
+	files, err := client.GetModifiedFiles(models.Repo{
+		FullName: "owner/repo",
+		Owner:    "owner",
+		Name:     "repo",
+	}, models.PullRequest{Num: 1})
+	Ok(t, err)
+	Equals(t, []string{"main.tf"}, files)
+}
+
+func TestGithubAppCredentials_TokenRefresh(t *testing.T) {
+	_, keyFile, cleanup := githubAppKey(t)
+	defer cleanup()
+
+	var tokenRequests int
+	testServer := httptest.NewTLSServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api/v3/app/installations":
+				w.Write([]byte(`[{"id": 123}]`)) // nolint: errcheck
+			case "/api/v3/app/installations/123/access_tokens":
+				tokenRequests++
+				// Return a token that's about to expire.
+				expiresAt := time.Now().Add(time.Minute)
+				fmt.Fprintf(w, `{"token": "token%d", "expires_at": %q}`, tokenRequests, expiresAt.Format(time.RFC3339)) // nolint: errcheck
+			default:
+				t.Errorf("got unexpected request at %q", r.RequestURI)
+				http.Error(w, "not found", http.StatusNotFound)
+			}
+		}))
+	defer testServer.Close()
+	defer disableSSLVerification()()
+
+	testServerURL, err := url.Parse(testServer.URL)
+	Ok(t, err)
+	creds, err := vcs.NewGithubAppCredentials(http.DefaultClient, testServerURL.Host, 1, keyFile)
+	Ok(t, err)
+
+	token, err := creds.Token()
+	Ok(t, err)
+	Equals(t, "token1", token)
+	token, err = creds.Token()
+	Ok(t, err)
+	Equals(t, "token2", token)
+}
+
+func TestGithubAppCredentials_MultipleInstallations(t *testing.T) {
+	_, keyFile, cleanup := githubAppKey(t)
+	defer cleanup()
+
+	testServer := httptest.NewTLSServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`[{"id": 123}, {"id": 456}]`)) // nolint: errcheck
+		}))
+	defer testServer.Close()
+	defer disableSSLVerification()()
+
+	testServerURL, err := url.Parse(testServer.URL)
+	Ok(t, err)
+	creds, err := vcs.NewGithubAppCredentials(http.DefaultClient, testServerURL.Host, 1, keyFile)
+	Ok(t, err)
+
+	_, err = creds.Token()
+	ErrEquals(t, "GitHub App 1 must be installed on exactly one account but it's installed on 2", err)
+}
+
+func TestNewGithubAppCredentials_InvalidKey(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	keyFile := filepath.Join(tmp, "key.pem")
+	Ok(t, ioutil.WriteFile(keyFile, []byte("not a key"), 0600))
+
+	_, err := vcs.NewGithubAppCredentials(http.DefaultClient, "github.com", 1, keyFile)
+	ErrEquals(t, fmt.Sprintf("parsing GitHub App private key from %s: no PEM data found", keyFile), err)
+}
+
+// githubAppKey generates a private key and writes it to a file in the format
+// GitHub uses.
+func githubAppKey(t *testing.T) (*rsa.PrivateKey, string, func()) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	Ok(t, err)
+	tmp, cleanup := TempDir(t)
+	keyFile := filepath.Join(tmp, "key.pem")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	Ok(t, ioutil.WriteFile(keyFile, keyPEM, 0600))
+	return key, keyFile, cleanup
+}
+
+// verifyGithubAppJWT checks that r is authenticated with a JWT signed by the
+// app's key.
+func verifyGithubAppJWT(t *testing.T, pub *rsa.PublicKey, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	Assert(t, strings.HasPrefix(auth, "Bearer "), "exp bearer auth but got %q", auth)
+	parts := strings.Split(strings.TrimPrefix(auth, "Bearer "), ".")
+	Equals(t, 3, len(parts))
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	Ok(t, err)
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	Ok(t, rsa.VerifyPKCS1v15(pub, crypto.SHA256, hash[:], sig))
+	claims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	Ok(t, err)
+	Assert(t, strings.Contains(string(claims), `"iss":1`), "exp iss claim in %s", claims)
+}
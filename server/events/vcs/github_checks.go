@@ -0,0 +1,136 @@
+package vcs
+
+import (
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// maxCheckRunTextLength is the most chars GitHub allows in a check run's
+// summary and text.
+const maxCheckRunTextLength = 65535
+
+// maxCheckRunAnnotations is the most annotations GitHub accepts in a single
+// request.
+const maxCheckRunAnnotations = 50
+
+// CheckRun is a GitHub check run on the head commit of a pull request.
+// See https://developer.github.com/v3/checks/runs/.
+type CheckRun struct {
+	// Name identifies the check run, ex. atlantis/plan: dir/default. Updating
+	// a check run with the same name on the same commit replaces it.
+	Name   string
+	Status models.CommitStatus
+	// Title and Summary are shown at the top of the check run's page in
+	// markdown. Text is shown below them.
+	Title   string
+	Summary string
+	Text    string
+	// ExternalID is our reference for the check run. It's sent back in the
+	// webhook when someone re-runs it.
+	ExternalID  string
+	DetailsURL  string
+	Annotations []CheckRunAnnotation
+}
+
+// CheckRunAnnotation marks a line of a file, ex. the line a terraform error
+// is on, in the pull request's diff.
+type CheckRunAnnotation struct {
+	// Path is relative to the repo root.
+	Path    string
+	Line    int
+	Title   string
+	Message string
+}
+
+// UpdateCheckRun creates or updates the check run named run.Name on the head
+// commit of pull. Check runs can only be created by GitHub Apps.
+func (g *GithubClient) UpdateCheckRun(repo models.Repo, pull models.PullRequest, run CheckRun) error {
+	status, conclusion := "in_progress", ""
+	switch run.Status {
+	case models.SuccessCommitStatus:
+		status, conclusion = "completed", "success"
+	case models.FailedCommitStatus:
+		status, conclusion = "completed", "failure"
+	}
+	output := &github.CheckRunOutput{
+		Title:   github.String(run.Title),
+		Summary: github.String(truncateCheckRunText(run.Summary)),
+	}
+	if run.Text != "" {
+		output.Text = github.String(truncateCheckRunText(run.Text))
+	}
+	for i, a := range run.Annotations {
+		if i == maxCheckRunAnnotations {
+			break
+		}
+		output.Annotations = append(output.Annotations, &github.CheckRunAnnotation{
+			Path:            github.String(a.Path),
+			StartLine:       github.Int(a.Line),
+			EndLine:         github.Int(a.Line),
+			AnnotationLevel: github.String("failure"),
+			Title:           github.String(a.Title),
+			Message:         github.String(a.Message),
+		})
+	}
+	var detailsURL, externalID *string
+	if run.DetailsURL != "" {
+		detailsURL = github.String(run.DetailsURL)
+	}
+	if run.ExternalID != "" {
+		externalID = github.String(run.ExternalID)
+	}
+	var completedAt *github.Timestamp
+	if conclusion != "" {
+		completedAt = &github.Timestamp{Time: time.Now()}
+	}
+
+	existing, _, err := g.client.Checks.ListCheckRunsForRef(g.ctx, repo.Owner, repo.Name, pull.HeadCommit, &github.ListCheckRunsOptions{
+		CheckName: github.String(run.Name),
+	})
+	if err != nil {
+		return errors.Wrap(err, "listing check runs")
+	}
+	if existing != nil && len(existing.CheckRuns) > 0 {
+		opts := github.UpdateCheckRunOptions{
+			Name:        run.Name,
+			DetailsURL:  detailsURL,
+			ExternalID:  externalID,
+			Status:      github.String(status),
+			CompletedAt: completedAt,
+			Output:      output,
+		}
+		if conclusion != "" {
+			opts.Conclusion = github.String(conclusion)
+		}
+		_, _, err = g.client.Checks.UpdateCheckRun(g.ctx, repo.Owner, repo.Name, existing.CheckRuns[0].GetID(), opts)
+		return errors.Wrap(err, "updating check run")
+	}
+	opts := github.CreateCheckRunOptions{
+		Name:        run.Name,
+		HeadBranch:  pull.Branch,
+		HeadSHA:     pull.HeadCommit,
+		DetailsURL:  detailsURL,
+		ExternalID:  externalID,
+		Status:      github.String(status),
+		CompletedAt: completedAt,
+		Output:      output,
+	}
+	if conclusion != "" {
+		opts.Conclusion = github.String(conclusion)
+	}
+	_, _, err = g.client.Checks.CreateCheckRun(g.ctx, repo.Owner, repo.Name, opts)
+	return errors.Wrap(err, "creating check run")
+}
+
+// truncateCheckRunText truncates s to the length GitHub allows, noting that
+// it was truncated.
+func truncateCheckRunText(s string) string {
+	if len(s) <= maxCheckRunTextLength {
+		return s
+	}
+	suffix := "\n\n...truncated"
+	return s[:maxCheckRunTextLength-len(suffix)] + suffix
+}
@@ -0,0 +1,80 @@
+package vcs_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+// We create the check run if the commit doesn't have one with its name and
+// otherwise update it.
+func TestGithubClient_UpdateCheckRun(t *testing.T) {
+	cases := map[string]struct {
+		existing  string
+		expMethod string
+		expURI    string
+	}{
+		"create": {`{"total_count": 0, "check_runs": []}`, "POST", "/api/v3/repos/owner/repo/check-runs"},
+		"update": {`{"total_count": 1, "check_runs": [{"id": 4}]}`, "PATCH", "/api/v3/repos/owner/repo/check-runs/4"},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var body map[string]interface{}
+			testServer := httptest.NewTLSServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					switch {
+					case r.Method == "GET" && r.RequestURI == "/api/v3/repos/owner/repo/commits/sha/check-runs?check_name=atlantis%2Fplan":
+						w.Write([]byte(c.existing)) // nolint: errcheck
+					case r.Method == c.expMethod && r.RequestURI == c.expURI:
+						Ok(t, json.NewDecoder(r.Body).Decode(&body))
+						w.Write([]byte(`{"id": 4}`)) // nolint: errcheck
+					default:
+						t.Errorf("got unexpected request %s %q", r.Method, r.RequestURI)
+						http.Error(w, "not found", http.StatusNotFound)
+					}
+				}))
+			defer testServer.Close()
+
+			testServerURL, err := url.Parse(testServer.URL)
+			Ok(t, err)
+			client, err := vcs.NewGithubClient(http.DefaultClient, testServerURL.Host, "user", "pass")
+			Ok(t, err)
+			defer disableSSLVerification()()
+
+			var annotations []vcs.CheckRunAnnotation
+			for i := 0; i < 60; i++ {
+				annotations = append(annotations, vcs.CheckRunAnnotation{Path: "main.tf", Line: i + 1, Title: "Error", Message: "error"})
+			}
+			err = client.UpdateCheckRun(models.Repo{Owner: "owner", Name: "repo"}, models.PullRequest{HeadCommit: "sha", Branch: "branch"}, vcs.CheckRun{
+				Name:        "atlantis/plan",
+				Status:      models.FailedCommitStatus,
+				Title:       "Plan Failed",
+				Summary:     "summary",
+				Text:        strings.Repeat("a", 70000),
+				ExternalID:  `{"command":"plan"}`,
+				Annotations: annotations,
+			})
+			Ok(t, err)
+
+			Equals(t, "atlantis/plan", body["name"])
+			Equals(t, "completed", body["status"])
+			Equals(t, "failure", body["conclusion"])
+			Equals(t, `{"command":"plan"}`, body["external_id"])
+			output := body["output"].(map[string]interface{})
+			Equals(t, "Plan Failed", output["title"])
+			Equals(t, "summary", output["summary"])
+			Equals(t, 65535, len(output["text"].(string)))
+			Equals(t, 50, len(output["annotations"].([]interface{})))
+			if name == "create" {
+				Equals(t, "sha", body["head_sha"])
+			}
+		})
+	}
+}
@@ -15,7 +15,9 @@ package vcs
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"net/url"
 	"strings"
 
@@ -30,19 +32,42 @@ import (
 // by GitHub.
 const maxCommentLength = 65536
 
+// githubReactionsPreview is the media type required to use the reactions API.
+const githubReactionsPreview = "application/vnd.github.squirrel-girl-preview"
+
+// githubDraftsPreview is the media type required to see if a pull request is
+// a draft.
+const githubDraftsPreview = "application/vnd.github.shadow-cat-preview+json"
+
 // GithubClient is used to perform GitHub actions.
 type GithubClient struct {
 	client *github.Client
 	ctx    context.Context
 }
 
-// NewGithubClient returns a valid GitHub client.
-func NewGithubClient(hostname string, user string, pass string) (*GithubClient, error) {
+// NewGithubClient returns a valid GitHub client. Its requests are sent with
+// httpClient's transport.
+func NewGithubClient(httpClient *http.Client, hostname string, user string, pass string) (*GithubClient, error) {
 	tp := github.BasicAuthTransport{
-		Username: strings.TrimSpace(user),
-		Password: strings.TrimSpace(pass),
+		Username:  strings.TrimSpace(user),
+		Password:  strings.TrimSpace(pass),
+		Transport: baseTransport(httpClient),
+	}
+	return newGithubClient(hostname, tp.Client())
+}
+
+// baseTransport returns the transport that httpClient sends requests with.
+func baseTransport(httpClient *http.Client) http.RoundTripper {
+	if httpClient == nil || httpClient.Transport == nil {
+		return http.DefaultTransport
 	}
-	client := github.NewClient(tp.Client())
+	return httpClient.Transport
+}
+
+// newGithubClient returns a GitHub client that makes requests with
+// httpClient, which is responsible for authentication.
+func newGithubClient(hostname string, httpClient *http.Client) (*GithubClient, error) {
+	client := github.NewClient(httpClient)
 	// If we're using github.com then we don't need to do any additional configuration
 	// for the client. It we're using Github Enterprise, then we need to manually
 	// set the base url for the API.
@@ -155,10 +180,34 @@ func (g *GithubClient) GetPullRequest(repo models.Repo, num int) (*github.PullRe
 	return pull, err
 }
 
+// IsTeamMember returns true if username is an active member of team, which
+// is the org and the team's slug, ex. myorg/infra. Our version of go-github
+// can only get teams by ID so we make the request ourselves.
+// See https://developer.github.com/v3/teams/members/#get-team-membership-by-slug.
+func (g *GithubClient) IsTeamMember(team string, username string) (bool, error) {
+	split := strings.SplitN(team, "/", 2)
+	if len(split) != 2 || split[0] == "" || split[1] == "" {
+		return false, fmt.Errorf("invalid team %q: must be org/team-slug", team)
+	}
+	u := fmt.Sprintf("orgs/%s/teams/%s/memberships/%s", url.PathEscape(split[0]), url.PathEscape(split[1]), url.PathEscape(username))
+	req, err := g.client.NewRequest("GET", u, nil)
+	if err != nil {
+		return false, err
+	}
+	var membership github.Membership
+	resp, err := g.client.Do(g.ctx, req, &membership)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return membership.GetState() == "active", nil
+}
+
 // UpdateStatus updates the status badge on the pull request.
 // See https://github.com/blog/1227-commit-status-api.
-func (g *GithubClient) UpdateStatus(repo models.Repo, pull models.PullRequest, state models.CommitStatus, description string) error {
-	const statusContext = "Atlantis"
+func (g *GithubClient) UpdateStatus(repo models.Repo, pull models.PullRequest, state models.CommitStatus, src string, description string) error {
 	ghState := "error"
 	switch state {
 	case models.PendingCommitStatus:
@@ -171,7 +220,211 @@ func (g *GithubClient) UpdateStatus(repo models.Repo, pull models.PullRequest, s
 	status := &github.RepoStatus{
 		State:       github.String(ghState),
 		Description: github.String(description),
-		Context:     github.String(statusContext)}
+		Context:     github.String(src)}
 	_, _, err := g.client.Repositories.CreateStatus(g.ctx, repo.Owner, repo.Name, pull.HeadCommit, status)
 	return err
 }
+
+// ReactToComment adds a reaction to the pull request comment. GitHub only
+// supports a fixed set of reactions so ours are mapped to the closest one.
+// See https://developer.github.com/v3/reactions/#create-reaction-for-an-issue-comment.
+func (g *GithubClient) ReactToComment(repo models.Repo, pullNum int, commentID int64, reaction string) error {
+	content := reaction
+	switch reaction {
+	case SuccessReaction:
+		content = "+1"
+	case FailureReaction:
+		content = "confused"
+	}
+	u := fmt.Sprintf("repos/%v/%v/issues/comments/%v/reactions", repo.Owner, repo.Name, commentID)
+	req, err := g.client.NewRequest("POST", u, &github.Reaction{Content: github.String(content)})
+	if err != nil {
+		return err
+	}
+	// The reactions API is still in preview.
+	req.Header.Set("Accept", githubReactionsPreview)
+	_, err = g.client.Do(g.ctx, req, nil)
+	return err
+}
+
+// CreateIssue opens an issue on repo.
+func (g *GithubClient) CreateIssue(repo models.Repo, title string, body string) error {
+	_, _, err := g.client.Issues.Create(g.ctx, repo.Owner, repo.Name, &github.IssueRequest{
+		Title: github.String(title),
+		Body:  github.String(body),
+	})
+	return err
+}
+
+// GetPullLabels returns the names of the labels on the pull request.
+func (g *GithubClient) GetPullLabels(repo models.Repo, pull models.PullRequest) ([]string, error) {
+	var labels []string
+	nextPage := 0
+	for {
+		opts := github.ListOptions{
+			PerPage: 100,
+		}
+		if nextPage != 0 {
+			opts.Page = nextPage
+		}
+		pageLabels, resp, err := g.client.Issues.ListLabelsByIssue(g.ctx, repo.Owner, repo.Name, pull.Num, &opts)
+		if err != nil {
+			return labels, err
+		}
+		for _, l := range pageLabels {
+			labels = append(labels, l.GetName())
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		nextPage = resp.NextPage
+	}
+	return labels, nil
+}
+
+// PullIsDraft returns true if the pull request is a draft. Our version of
+// go-github doesn't know about drafts so we decode the field ourselves.
+// See https://developer.github.com/v3/pulls/#get-a-single-pull-request.
+func (g *GithubClient) PullIsDraft(repo models.Repo, pull models.PullRequest) (bool, error) {
+	u := fmt.Sprintf("repos/%v/%v/pulls/%d", repo.Owner, repo.Name, pull.Num)
+	req, err := g.client.NewRequest("GET", u, nil)
+	if err != nil {
+		return false, err
+	}
+	// Draft pull requests are still in preview.
+	req.Header.Set("Accept", githubDraftsPreview)
+	var draftPull struct {
+		Draft bool `json:"draft"`
+	}
+	if _, err := g.client.Do(g.ctx, req, &draftPull); err != nil {
+		return false, err
+	}
+	return draftPull.Draft, nil
+}
+
+// HidePrevComments minimizes our comments that shouldHide returns true for and
+// that aren't already minimized. Comments can only be minimized with the
+// GraphQL API so both listing and minimizing use it.
+// See https://developer.github.com/v4/mutation/minimizecomment/.
+func (g *GithubClient) HidePrevComments(repo models.Repo, pullNum int, shouldHide func(comment string) bool) error {
+	var toMinimize []string
+	var cursor *string
+	for {
+		var resp struct {
+			Repository struct {
+				PullRequest struct {
+					Comments struct {
+						Nodes []struct {
+							ID              string `json:"id"`
+							Body            string `json:"body"`
+							IsMinimized     bool   `json:"isMinimized"`
+							ViewerDidAuthor bool   `json:"viewerDidAuthor"`
+						} `json:"nodes"`
+						PageInfo struct {
+							HasNextPage bool   `json:"hasNextPage"`
+							EndCursor   string `json:"endCursor"`
+						} `json:"pageInfo"`
+					} `json:"comments"`
+				} `json:"pullRequest"`
+			} `json:"repository"`
+		}
+		query := `query($owner: String!, $name: String!, $number: Int!, $cursor: String) {
+  repository(owner: $owner, name: $name) {
+    pullRequest(number: $number) {
+      comments(first: 100, after: $cursor) {
+        nodes { id body isMinimized viewerDidAuthor }
+        pageInfo { hasNextPage endCursor }
+      }
+    }
+  }
+}`
+		vars := map[string]interface{}{
+			"owner":  repo.Owner,
+			"name":   repo.Name,
+			"number": pullNum,
+			"cursor": cursor,
+		}
+		if err := g.graphql(query, vars, &resp); err != nil {
+			return errors.Wrap(err, "listing comments")
+		}
+		comments := resp.Repository.PullRequest.Comments
+		for _, c := range comments.Nodes {
+			// Already minimized comments still have to be passed to
+			// shouldHide so it sees every comment of ours in order.
+			if c.ViewerDidAuthor && shouldHide(c.Body) && !c.IsMinimized {
+				toMinimize = append(toMinimize, c.ID)
+			}
+		}
+		if !comments.PageInfo.HasNextPage {
+			break
+		}
+		cursor = &comments.PageInfo.EndCursor
+	}
+
+	for _, id := range toMinimize {
+		mutation := `mutation($id: ID!) {
+  minimizeComment(input: {subjectId: $id, classifier: OUTDATED}) { clientMutationId }
+}`
+		if err := g.graphql(mutation, map[string]interface{}{"id": id}, nil); err != nil {
+			return errors.Wrap(err, "minimizing comment")
+		}
+	}
+	return nil
+}
+
+// graphql runs query against the GraphQL API and decodes its data into v,
+// which can be nil. The GraphQL API is at /graphql on github.com and at
+// /api/graphql rather than /api/v3 on GitHub Enterprise.
+// MergePull merges the pull request. It's only merged if its head commit
+// hasn't changed since it was applied. GitHub can't delete the source branch
+// while merging so we delete it afterwards unless it's protected.
+func (g *GithubClient) MergePull(repo models.Repo, pull models.PullRequest, opts MergeOptions) error {
+	_, _, err := g.client.PullRequests.Merge(g.ctx, repo.Owner, repo.Name, pull.Num, "", &github.PullRequestOptions{
+		MergeMethod: opts.Method,
+		SHA:         pull.HeadCommit,
+	})
+	if err != nil || !opts.DeleteSourceBranch {
+		return err
+	}
+	branch, _, err := g.client.Repositories.GetBranch(g.ctx, repo.Owner, repo.Name, pull.Branch)
+	if err != nil {
+		return errors.Wrapf(err, "getting branch %q", pull.Branch)
+	}
+	if branch.GetProtected() {
+		return nil
+	}
+	_, err = g.client.Git.DeleteRef(g.ctx, repo.Owner, repo.Name, "heads/"+pull.Branch)
+	return errors.Wrapf(err, "deleting branch %q", pull.Branch)
+}
+
+func (g *GithubClient) graphql(query string, vars map[string]interface{}, v interface{}) error {
+	u := *g.client.BaseURL
+	u.Path = strings.TrimSuffix(u.Path, "v3/") + "graphql"
+	req, err := g.client.NewRequest("POST", u.String(), map[string]interface{}{
+		"query":     query,
+		"variables": vars,
+	})
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if _, err := g.client.Do(g.ctx, req, &resp); err != nil {
+		return err
+	}
+	if len(resp.Errors) > 0 {
+		var msgs []string
+		for _, e := range resp.Errors {
+			msgs = append(msgs, e.Message)
+		}
+		return errors.New(strings.Join(msgs, ", "))
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(resp.Data, v)
+}
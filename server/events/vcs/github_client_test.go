@@ -56,7 +56,7 @@ func TestGithubClient_GetModifiedFiles(t *testing.T) {
 
 	testServerURL, err := url.Parse(testServer.URL)
 	Ok(t, err)
-	client, err := vcs.NewGithubClient(testServerURL.Host, "user", "pass")
+	client, err := vcs.NewGithubClient(http.DefaultClient, testServerURL.Host, "user", "pass")
 	Ok(t, err)
 	defer disableSSLVerification()()
 
@@ -111,7 +111,7 @@ func TestGithubClient_GetModifiedFilesMovedFile(t *testing.T) {
 
 	testServerURL, err := url.Parse(testServer.URL)
 	Ok(t, err)
-	client, err := vcs.NewGithubClient(testServerURL.Host, "user", "pass")
+	client, err := vcs.NewGithubClient(http.DefaultClient, testServerURL.Host, "user", "pass")
 	Ok(t, err)
 	defer disableSSLVerification()()
 
@@ -159,7 +159,7 @@ func TestGithubClient_UpdateStatus(t *testing.T) {
 					case "/api/v3/repos/owner/repo/statuses/":
 						body, err := ioutil.ReadAll(r.Body)
 						Ok(t, err)
-						exp := fmt.Sprintf(`{"state":"%s","description":"description","context":"Atlantis"}%s`, c.expState, "\n")
+						exp := fmt.Sprintf(`{"state":"%s","description":"description","context":"atlantis/plan"}%s`, c.expState, "\n")
 						Equals(t, exp, string(body))
 						defer r.Body.Close() // nolint: errcheck
 						w.WriteHeader(http.StatusOK)
@@ -172,7 +172,7 @@ func TestGithubClient_UpdateStatus(t *testing.T) {
 
 			testServerURL, err := url.Parse(testServer.URL)
 			Ok(t, err)
-			client, err := vcs.NewGithubClient(testServerURL.Host, "user", "pass")
+			client, err := vcs.NewGithubClient(http.DefaultClient, testServerURL.Host, "user", "pass")
 			Ok(t, err)
 			defer disableSSLVerification()()
 
@@ -188,7 +188,7 @@ func TestGithubClient_UpdateStatus(t *testing.T) {
 				},
 			}, models.PullRequest{
 				Num: 1,
-			}, c.status, "description")
+			}, c.status, "atlantis/plan", "description")
 			Ok(t, err)
 		})
 	}
@@ -264,7 +264,7 @@ func TestGithubClient_PullIsMergeable(t *testing.T) {
 				}))
 			testServerURL, err := url.Parse(testServer.URL)
 			Ok(t, err)
-			client, err := vcs.NewGithubClient(testServerURL.Host, "user", "pass")
+			client, err := vcs.NewGithubClient(http.DefaultClient, testServerURL.Host, "user", "pass")
 			Ok(t, err)
 			defer disableSSLVerification()()
 
@@ -287,6 +287,252 @@ func TestGithubClient_PullIsMergeable(t *testing.T) {
 	}
 }
 
+// ReactToComment should map our reactions to GitHub reactions and use the
+// reactions preview API.
+func TestGithubClient_ReactToComment(t *testing.T) {
+	var body string
+	testServer := httptest.NewTLSServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.RequestURI {
+			case "/api/v3/repos/owner/repo/issues/comments/123/reactions":
+				Equals(t, "POST", r.Method)
+				Equals(t, "application/vnd.github.squirrel-girl-preview", r.Header.Get("Accept"))
+				bodyBytes, err := ioutil.ReadAll(r.Body)
+				Ok(t, err)
+				body = string(bodyBytes)
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte("{}")) // nolint: errcheck
+			default:
+				t.Errorf("got unexpected request at %q", r.RequestURI)
+				http.Error(w, "not found", http.StatusNotFound)
+			}
+		}))
+
+	testServerURL, err := url.Parse(testServer.URL)
+	Ok(t, err)
+	client, err := vcs.NewGithubClient(http.DefaultClient, testServerURL.Host, "user", "pass")
+	Ok(t, err)
+	defer disableSSLVerification()()
+
+	repo := models.Repo{
+		FullName: "owner/repo",
+		Owner:    "owner",
+		Name:     "repo",
+	}
+	err = client.ReactToComment(repo, 1, 123, vcs.SuccessReaction)
+	Ok(t, err)
+	Equals(t, `{"content":"+1"}`+"\n", body)
+}
+
+func TestGithubClient_CreateIssue(t *testing.T) {
+	var body string
+	testServer := httptest.NewTLSServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.RequestURI {
+			case "/api/v3/repos/owner/repo/issues":
+				Equals(t, "POST", r.Method)
+				bodyBytes, err := ioutil.ReadAll(r.Body)
+				Ok(t, err)
+				body = string(bodyBytes)
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte("{}")) // nolint: errcheck
+			default:
+				t.Errorf("got unexpected request at %q", r.RequestURI)
+				http.Error(w, "not found", http.StatusNotFound)
+			}
+		}))
+
+	testServerURL, err := url.Parse(testServer.URL)
+	Ok(t, err)
+	client, err := vcs.NewGithubClient(http.DefaultClient, testServerURL.Host, "user", "pass")
+	Ok(t, err)
+	defer disableSSLVerification()()
+
+	repo := models.Repo{
+		FullName: "owner/repo",
+		Owner:    "owner",
+		Name:     "repo",
+	}
+	err = client.CreateIssue(repo, "title", "body")
+	Ok(t, err)
+	Equals(t, `{"title":"title","body":"body"}`+"\n", body)
+}
+
+func TestGithubClient_PullIsDraft(t *testing.T) {
+	for _, draft := range []bool{true, false} {
+		t.Run(fmt.Sprintf("%t", draft), func(t *testing.T) {
+			testServer := httptest.NewTLSServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					switch r.RequestURI {
+					case "/api/v3/repos/owner/repo/pulls/1":
+						Equals(t, "application/vnd.github.shadow-cat-preview+json", r.Header.Get("Accept"))
+						w.Write([]byte(fmt.Sprintf(`{"number": 1, "draft": %t}`, draft))) // nolint: errcheck
+					default:
+						t.Errorf("got unexpected request at %q", r.RequestURI)
+						http.Error(w, "not found", http.StatusNotFound)
+					}
+				}))
+
+			testServerURL, err := url.Parse(testServer.URL)
+			Ok(t, err)
+			client, err := vcs.NewGithubClient(http.DefaultClient, testServerURL.Host, "user", "pass")
+			Ok(t, err)
+			defer disableSSLVerification()()
+
+			repo := models.Repo{
+				FullName: "owner/repo",
+				Owner:    "owner",
+				Name:     "repo",
+			}
+			isDraft, err := client.PullIsDraft(repo, models.PullRequest{Num: 1})
+			Ok(t, err)
+			Equals(t, draft, isDraft)
+		})
+	}
+}
+
+// HidePrevComments should only minimize our comments that aren't already
+// minimized, using the GraphQL API.
+func TestGithubClient_IsTeamMember(t *testing.T) {
+	testServer := httptest.NewTLSServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.RequestURI {
+			case "/api/v3/orgs/myorg/teams/infra/memberships/alice":
+				w.Write([]byte(`{"state": "active", "role": "member"}`)) // nolint: errcheck
+			case "/api/v3/orgs/myorg/teams/infra/memberships/bob":
+				w.Write([]byte(`{"state": "pending", "role": "member"}`)) // nolint: errcheck
+			case "/api/v3/orgs/myorg/teams/infra/memberships/carol":
+				http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+			default:
+				t.Errorf("got unexpected request at %q", r.RequestURI)
+				http.Error(w, "not found", http.StatusNotFound)
+			}
+		}))
+
+	testServerURL, err := url.Parse(testServer.URL)
+	Ok(t, err)
+	client, err := vcs.NewGithubClient(http.DefaultClient, testServerURL.Host, "user", "pass")
+	Ok(t, err)
+	defer disableSSLVerification()()
+
+	for username, exp := range map[string]bool{"alice": true, "bob": false, "carol": false} {
+		member, err := client.IsTeamMember("myorg/infra", username)
+		Ok(t, err)
+		Equals(t, exp, member)
+	}
+
+	_, err = client.IsTeamMember("infra", "alice")
+	ErrEquals(t, `invalid team "infra": must be org/team-slug`, err)
+}
+
+func TestGithubClient_HidePrevComments(t *testing.T) {
+	commentsResp := `{"data": {"repository": {"pullRequest": {"comments": {
+  "nodes": [
+    {"id": "1", "body": "Ran Plan for dir: ` + "`dir`" + ` workspace: ` + "`default`" + `", "isMinimized": false, "viewerDidAuthor": true},
+    {"id": "2", "body": "atlantis plan", "isMinimized": false, "viewerDidAuthor": false},
+    {"id": "3", "body": "Ran Plan for dir: ` + "`old`" + ` workspace: ` + "`default`" + `", "isMinimized": true, "viewerDidAuthor": true},
+    {"id": "4", "body": "Ran Apply for dir: ` + "`dir`" + ` workspace: ` + "`default`" + `", "isMinimized": false, "viewerDidAuthor": true}
+  ],
+  "pageInfo": {"hasNextPage": false, "endCursor": "abc"}
+}}}}}`
+	var minimized []string
+	testServer := httptest.NewTLSServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.RequestURI {
+			case "/api/graphql":
+				body, err := ioutil.ReadAll(r.Body)
+				Ok(t, err)
+				if strings.Contains(string(body), "minimizeComment") {
+					Assert(t, strings.Contains(string(body), "OUTDATED"), "exp comment to be minimized as outdated, got %q", string(body))
+					minimized = append(minimized, string(body))
+					w.Write([]byte(`{"data": {"minimizeComment": {"clientMutationId": null}}}`)) // nolint: errcheck
+					return
+				}
+				w.Write([]byte(commentsResp)) // nolint: errcheck
+			default:
+				t.Errorf("got unexpected request at %q", r.RequestURI)
+				http.Error(w, "not found", http.StatusNotFound)
+			}
+		}))
+
+	testServerURL, err := url.Parse(testServer.URL)
+	Ok(t, err)
+	client, err := vcs.NewGithubClient(http.DefaultClient, testServerURL.Host, "user", "pass")
+	Ok(t, err)
+	defer disableSSLVerification()()
+
+	repo := models.Repo{
+		FullName: "owner/repo",
+		Owner:    "owner",
+		Name:     "repo",
+	}
+	var seen []string
+	err = client.HidePrevComments(repo, 1, func(comment string) bool {
+		seen = append(seen, comment)
+		return strings.HasPrefix(comment, "Ran Plan")
+	})
+	Ok(t, err)
+	Equals(t, 3, len(seen))
+	Equals(t, 1, len(minimized))
+	Assert(t, strings.Contains(minimized[0], `"id":"1"`), "exp comment 1 to be minimized, got %q", minimized[0])
+}
+
+func TestGithubClient_MergePull(t *testing.T) {
+	var requests []string
+	testServer := httptest.NewTLSServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r.Method+" "+r.RequestURI)
+			switch r.Method + " " + r.RequestURI {
+			case "PUT /api/v3/repos/owner/repo/pulls/1/merge":
+				body, err := ioutil.ReadAll(r.Body)
+				Ok(t, err)
+				Assert(t, strings.Contains(string(body), `"merge_method":"squash"`), "exp squash merge, got %q", string(body))
+				w.Write([]byte(`{"merged": true}`)) // nolint: errcheck
+			case "GET /api/v3/repos/owner/repo/branches/feature":
+				w.Write([]byte(`{"name": "feature", "protected": false}`)) // nolint: errcheck
+			case "GET /api/v3/repos/owner/repo/branches/release":
+				w.Write([]byte(`{"name": "release", "protected": true}`)) // nolint: errcheck
+			case "DELETE /api/v3/repos/owner/repo/git/refs/heads/feature":
+				w.WriteHeader(http.StatusNoContent)
+			default:
+				t.Errorf("got unexpected request %q", r.Method+" "+r.RequestURI)
+				http.Error(w, "not found", http.StatusNotFound)
+			}
+		}))
+
+	testServerURL, err := url.Parse(testServer.URL)
+	Ok(t, err)
+	client, err := vcs.NewGithubClient(http.DefaultClient, testServerURL.Host, "user", "pass")
+	Ok(t, err)
+	defer disableSSLVerification()()
+
+	repo := models.Repo{
+		FullName: "owner/repo",
+		Owner:    "owner",
+		Name:     "repo",
+	}
+	opts := vcs.MergeOptions{Method: "squash"}
+	Ok(t, client.MergePull(repo, models.PullRequest{Num: 1, Branch: "feature"}, opts))
+	Equals(t, []string{"PUT /api/v3/repos/owner/repo/pulls/1/merge"}, requests)
+
+	requests = nil
+	opts.DeleteSourceBranch = true
+	Ok(t, client.MergePull(repo, models.PullRequest{Num: 1, Branch: "feature"}, opts))
+	Equals(t, []string{
+		"PUT /api/v3/repos/owner/repo/pulls/1/merge",
+		"GET /api/v3/repos/owner/repo/branches/feature",
+		"DELETE /api/v3/repos/owner/repo/git/refs/heads/feature",
+	}, requests)
+
+	t.Log("protected branches aren't deleted")
+	requests = nil
+	Ok(t, client.MergePull(repo, models.PullRequest{Num: 1, Branch: "release"}, opts))
+	Equals(t, []string{
+		"PUT /api/v3/repos/owner/repo/pulls/1/merge",
+		"GET /api/v3/repos/owner/repo/branches/release",
+	}, requests)
+}
+
 // disableSSLVerification disables ssl verification for the global http client
 // and returns a function to be called in a defer that will re-enable it.
 func disableSSLVerification() func() {
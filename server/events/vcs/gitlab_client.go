@@ -16,7 +16,9 @@ package vcs
 import (
 	"fmt"
 	"net"
+	"net/http"
 	"net/url"
+	"sort"
 	"strings"
 
 	"github.com/hashicorp/go-version"
@@ -31,8 +33,24 @@ type GitlabClient struct {
 	Client *gitlab.Client
 	// Version is set to the server version.
 	Version *version.Version
+	// StatusName starts the names of the commit statuses we set. They're
+	// ignored when checking if a merge request is mergeable. Defaults to
+	// gitlabStatusPrefix.
+	StatusName string
+	// PipelineStatuses is true if our commit statuses should be added to the
+	// merge request's head pipeline, where GitLab shows them in its external
+	// stage, rather than to a pipeline of their own.
+	PipelineStatuses bool
 }
 
+// gitlabStatusPrefix starts the names of the commit statuses we set unless
+// StatusName is set. Older versions of Atlantis set a single status named
+// "Atlantis".
+const gitlabStatusPrefix = "atlantis"
+
+// maxPerPage is the most results GitLab returns per page.
+const maxPerPage = 100
+
 // commonMarkSupported is a version constraint that is true when this version of
 // GitLab supports CommonMark, a markdown specification.
 // See https://about.gitlab.com/2018/07/22/gitlab-11-1-released/
@@ -41,10 +59,11 @@ var commonMarkSupported = MustConstraint(">=11.1")
 // gitlabClientUnderTest is true if we're running under go test.
 var gitlabClientUnderTest = false
 
-// NewGitlabClient returns a valid GitLab client.
-func NewGitlabClient(hostname string, token string, logger *logging.SimpleLogger) (*GitlabClient, error) {
+// NewGitlabClient returns a valid GitLab client that makes requests with
+// httpClient.
+func NewGitlabClient(httpClient *http.Client, hostname string, token string, logger *logging.SimpleLogger) (*GitlabClient, error) {
 	client := &GitlabClient{
-		Client: gitlab.NewClient(nil, token),
+		Client: gitlab.NewClient(httpClient, token),
 	}
 
 	// If not using gitlab.com we need to set the URL to the API.
@@ -93,7 +112,6 @@ func NewGitlabClient(hostname string, token string, logger *logging.SimpleLogger
 // GetModifiedFiles returns the names of files that were modified in the merge request.
 // The names include the path to the file from the repo root, ex. parent/child/file.txt.
 func (g *GitlabClient) GetModifiedFiles(repo models.Repo, pull models.PullRequest) ([]string, error) {
-	const maxPerPage = 100
 	var files []string
 	nextPage := 1
 	// Constructing the api url by hand so we can do pagination.
@@ -137,28 +155,69 @@ func (g *GitlabClient) CreateComment(repo models.Repo, pullNum int, comment stri
 	return err
 }
 
-// PullIsApproved returns true if the merge request was approved.
+// PullIsApproved returns true if the merge request was approved. It has to
+// have at least one approval and no approvals left, and on GitLab Premium
+// every approval rule has to have as many approvals as it requires.
 func (g *GitlabClient) PullIsApproved(repo models.Repo, pull models.PullRequest) (bool, error) {
 	approvals, _, err := g.Client.MergeRequests.GetMergeRequestApprovals(repo.FullName, pull.Num)
 	if err != nil {
 		return false, err
 	}
-	if approvals.ApprovalsLeft > 0 {
+	// If no approvals are required approvals_left is 0 even if no one has
+	// approved.
+	if approvals.ApprovalsLeft > 0 || len(approvals.ApprovedBy) == 0 {
 		return false, nil
 	}
+	return g.approvalRulesSatisfied(repo, pull)
+}
+
+// gitlabApprovalState is the approval state of a merge request. go-gitlab
+// doesn't have it because it's only on GitLab Premium.
+type gitlabApprovalState struct {
+	Rules []struct {
+		Name              string `json:"name"`
+		ApprovalsRequired int    `json:"approvals_required"`
+		ApprovedBy        []struct {
+			Username string `json:"username"`
+		} `json:"approved_by"`
+	} `json:"rules"`
+}
+
+// approvalRulesSatisfied returns true if every approval rule on the merge
+// request has as many approvals as it requires. approvals_left doesn't
+// always account for every rule, ex. when one user approves for two rules
+// that each need a different approver. If the approval state isn't
+// available, ex. on GitLab Core, there are no rules so they're satisfied.
+func (g *GitlabClient) approvalRulesSatisfied(repo models.Repo, pull models.PullRequest) (bool, error) {
+	apiURL := fmt.Sprintf("projects/%s/merge_requests/%d/approval_state", url.QueryEscape(repo.FullName), pull.Num)
+	req, err := g.Client.NewRequest("GET", apiURL, nil, nil)
+	if err != nil {
+		return false, err
+	}
+	var state gitlabApprovalState
+	resp, err := g.Client.Do(req, &state)
+	if resp != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusForbidden) {
+		return true, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "getting approval state")
+	}
+	for _, rule := range state.Rules {
+		if len(rule.ApprovedBy) < rule.ApprovalsRequired {
+			return false, nil
+		}
+	}
 	return true, nil
 }
 
 // PullIsMergeable returns true if the merge request can be merged.
 // In GitLab, there isn't a single field that tells us if the pull request is
-// mergeable so for now we check the merge_status and approvals_before_merge
-// fields. We aren't checking if there are unresolved discussions or failing
-// pipelines because those only block merges if the repo is set to require that.
-// In order to check if the repo required these, we'd need to make another API
-// call to get the repo settings. For now I'm going to leave this as is and if
-// some users require checking this as well then we can revisit.
-// It's also possible that GitLab implements their own "mergeable" field in
-// their API in the future.
+// mergeable so we check what would stop the merge button being clickable:
+// merge conflicts, via the merge_status field, work in progress and approvals
+// or approval rules that are still needed. If the project only allows merging when the pipeline
+// succeeds we check the commit statuses other than our own, which is pending
+// while we're applying, and if it only allows merging when all discussions are
+// resolved we check for unresolved discussions.
 // See:
 // - https://gitlab.com/gitlab-org/gitlab-ee/issues/3169
 // - https://gitlab.com/gitlab-org/gitlab-ce/issues/42344
@@ -167,16 +226,157 @@ func (g *GitlabClient) PullIsMergeable(repo models.Repo, pull models.PullRequest
 	if err != nil {
 		return false, err
 	}
-	if mr.MergeStatus == "can_be_merged" && mr.ApprovalsBeforeMerge <= 0 {
-		return true, nil
+	if mr.MergeStatus != "can_be_merged" || mr.WorkInProgress {
+		return false, nil
 	}
-	return false, nil
+
+	approvals, resp, err := g.Client.MergeRequests.GetMergeRequestApprovals(repo.FullName, pull.Num)
+	// Versions of GitLab Core before 13.2 don't have approvals.
+	if err != nil && (resp == nil || resp.StatusCode != http.StatusNotFound) {
+		return false, errors.Wrap(err, "getting approvals")
+	}
+	if err == nil && approvals.ApprovalsLeft > 0 {
+		return false, nil
+	}
+	if err == nil {
+		satisfied, err := g.approvalRulesSatisfied(repo, pull)
+		if err != nil || !satisfied {
+			return false, err
+		}
+	}
+
+	project, _, err := g.Client.Projects.GetProject(repo.FullName)
+	if err != nil {
+		return false, errors.Wrap(err, "getting project")
+	}
+	if project.OnlyAllowMergeIfPipelineSucceeds {
+		sha := mr.SHA
+		pipeline, err := g.headPipeline(repo, pull.Num)
+		if err != nil {
+			return false, err
+		}
+		// Merged results pipelines run on a merge commit rather than the
+		// merge request's head commit.
+		if pipeline != nil && pipeline.SHA != "" {
+			sha = pipeline.SHA
+		}
+		passed, err := g.statusesPassed(repo, sha)
+		if err != nil || !passed {
+			return false, err
+		}
+	}
+	if project.OnlyAllowMergeIfAllDiscussionsAreResolved {
+		resolved, err := g.discussionsResolved(repo, pull)
+		if err != nil || !resolved {
+			return false, err
+		}
+	}
+	return true, nil
 }
 
-// UpdateStatus updates the build status of a commit.
-func (g *GitlabClient) UpdateStatus(repo models.Repo, pull models.PullRequest, state models.CommitStatus, description string) error {
-	const statusContext = "Atlantis"
+// gitlabCommitStatus is a commit status. go-gitlab's CommitStatus doesn't
+// have allow_failure.
+type gitlabCommitStatus struct {
+	Name         string `json:"name"`
+	Status       string `json:"status"`
+	AllowFailure bool   `json:"allow_failure"`
+}
+
+// gitlabPipeline is a pipeline, which the vendored client doesn't have on
+// merge requests.
+type gitlabPipeline struct {
+	ID     int    `json:"id"`
+	SHA    string `json:"sha"`
+	Status string `json:"status"`
+}
 
+// headPipeline returns the latest pipeline of merge request pullNum or nil if
+// it has none.
+func (g *GitlabClient) headPipeline(repo models.Repo, pullNum int) (*gitlabPipeline, error) {
+	req, err := g.Client.NewRequest("GET", fmt.Sprintf("projects/%s/merge_requests/%d", url.QueryEscape(repo.FullName), pullNum), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	var mr struct {
+		HeadPipeline *gitlabPipeline `json:"head_pipeline"`
+	}
+	if _, err := g.Client.Do(req, &mr); err != nil {
+		return nil, errors.Wrap(err, "getting head pipeline")
+	}
+	return mr.HeadPipeline, nil
+}
+
+// statusesPassed returns true if all of sha's commit statuses, other than
+// ours, succeeded, were skipped or are allowed to fail.
+func (g *GitlabClient) statusesPassed(repo models.Repo, sha string) (bool, error) {
+	nextPage := 1
+	apiURL := fmt.Sprintf("projects/%s/repository/commits/%s/statuses", url.QueryEscape(repo.FullName), sha)
+	for {
+		opts := gitlab.ListOptions{
+			Page:    nextPage,
+			PerPage: maxPerPage,
+		}
+		req, err := g.Client.NewRequest("GET", apiURL, opts, nil)
+		if err != nil {
+			return false, err
+		}
+		var statuses []gitlabCommitStatus
+		resp, err := g.Client.Do(req, &statuses)
+		if err != nil {
+			return false, errors.Wrap(err, "getting commit statuses")
+		}
+		for _, s := range statuses {
+			if g.isOwnStatus(s.Name) || s.AllowFailure {
+				continue
+			}
+			if s.Status != "success" && s.Status != "skipped" {
+				return false, nil
+			}
+		}
+		if resp.NextPage == 0 {
+			return true, nil
+		}
+		nextPage = resp.NextPage
+	}
+}
+
+// isOwnStatus returns true if the commit status named name was set by us.
+func (g *GitlabClient) isOwnStatus(name string) bool {
+	prefix := g.StatusName
+	if prefix == "" {
+		prefix = gitlabStatusPrefix
+	}
+	return strings.HasPrefix(strings.ToLower(name), strings.ToLower(prefix))
+}
+
+// discussionsResolved returns true if none of the merge request's discussions
+// need resolving.
+func (g *GitlabClient) discussionsResolved(repo models.Repo, pull models.PullRequest) (bool, error) {
+	nextPage := 1
+	for {
+		discussions, resp, err := g.Client.Discussions.ListMergeRequestDiscussions(repo.FullName, pull.Num, &gitlab.ListMergeRequestDiscussionsOptions{
+			Page:    nextPage,
+			PerPage: maxPerPage,
+		})
+		if err != nil {
+			return false, errors.Wrap(err, "listing discussions")
+		}
+		for _, d := range discussions {
+			for _, n := range d.Notes {
+				if n.Resolvable && !n.Resolved {
+					return false, nil
+				}
+			}
+		}
+		if resp.NextPage == 0 {
+			return true, nil
+		}
+		nextPage = resp.NextPage
+	}
+}
+
+// UpdateStatus updates the build status of a commit.
+func (g *GitlabClient) UpdateStatus(repo models.Repo, pull models.PullRequest, state models.CommitStatus, src string, description string) error {
 	gitlabState := gitlab.Failed
 	switch state {
 	case models.PendingCommitStatus:
@@ -186,19 +386,182 @@ func (g *GitlabClient) UpdateStatus(repo models.Repo, pull models.PullRequest, s
 	case models.SuccessCommitStatus:
 		gitlabState = gitlab.Success
 	}
+	if g.PipelineStatuses {
+		return g.updatePipelineStatus(repo, pull, gitlabState, src, description)
+	}
 	_, _, err := g.Client.Commits.SetCommitStatus(repo.FullName, pull.HeadCommit, &gitlab.SetCommitStatusOptions{
 		State:       gitlabState,
-		Context:     gitlab.String(statusContext),
+		Context:     gitlab.String(src),
 		Description: gitlab.String(description),
 	})
 	return err
 }
 
+// updatePipelineStatus sets the commit status in the merge request's head
+// pipeline. The vendored client can't set its pipeline_id. If the head
+// pipeline isn't for the head commit, ex. it's a merged results pipeline or
+// hasn't been created yet, GitLab adds the status to a pipeline of its own.
+func (g *GitlabClient) updatePipelineStatus(repo models.Repo, pull models.PullRequest, state gitlab.BuildStateValue, src string, description string) error {
+	opts := struct {
+		State       gitlab.BuildStateValue `url:"state" json:"state"`
+		Ref         string                 `url:"ref,omitempty" json:"ref,omitempty"`
+		Context     string                 `url:"context" json:"context"`
+		Description string                 `url:"description" json:"description"`
+		PipelineID  int                    `url:"pipeline_id,omitempty" json:"pipeline_id,omitempty"`
+	}{
+		State:       state,
+		Ref:         pull.Branch,
+		Context:     src,
+		Description: description,
+	}
+	pipeline, err := g.headPipeline(repo, pull.Num)
+	if err != nil {
+		return err
+	}
+	if pipeline != nil && pipeline.SHA == pull.HeadCommit {
+		opts.PipelineID = pipeline.ID
+	}
+	req, err := g.Client.NewRequest("POST", fmt.Sprintf("projects/%s/statuses/%s", url.QueryEscape(repo.FullName), pull.HeadCommit), opts, nil)
+	if err != nil {
+		return err
+	}
+	_, err = g.Client.Do(req, nil)
+	return err
+}
+
+// ReactToComment awards an emoji to the merge request note.
+func (g *GitlabClient) ReactToComment(repo models.Repo, pullNum int, commentID int64, reaction string) error {
+	_, _, err := g.Client.AwardEmoji.CreateMergeRequestAwardEmojiOnNote(repo.FullName, pullNum, int(commentID), &gitlab.CreateAwardEmojiOptions{Name: reaction})
+	return err
+}
+
+// GetPullLabels returns the labels on the merge request.
+func (g *GitlabClient) GetPullLabels(repo models.Repo, pull models.PullRequest) ([]string, error) {
+	mr, err := g.GetMergeRequest(repo.FullName, pull.Num)
+	if err != nil {
+		return nil, err
+	}
+	return mr.Labels, nil
+}
+
+// PullIsDraft returns true if the merge request is marked as a work in
+// progress or a draft, ex. its title starts with "WIP:" or "Draft:". We check
+// the title too since older GitLab versions only know about WIP prefixes.
+func (g *GitlabClient) PullIsDraft(repo models.Repo, pull models.PullRequest) (bool, error) {
+	mr, err := g.GetMergeRequest(repo.FullName, pull.Num)
+	if err != nil {
+		return false, err
+	}
+	return mr.WorkInProgress || hasGitlabDraftPrefix(mr.Title), nil
+}
+
+// gitlabDraftPrefixes are the title prefixes that mark a merge request as a
+// draft, in lowercase.
+var gitlabDraftPrefixes = []string{"draft:", "[draft]", "(draft)", "wip:", "[wip]"}
+
+func hasGitlabDraftPrefix(title string) bool {
+	lower := strings.ToLower(strings.TrimSpace(title))
+	for _, prefix := range gitlabDraftPrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// HidePrevComments deletes our notes that shouldHide returns true for since
+// GitLab can't hide notes.
+func (g *GitlabClient) HidePrevComments(repo models.Repo, pullNum int, shouldHide func(comment string) bool) error {
+	user, _, err := g.Client.Users.CurrentUser()
+	if err != nil {
+		return errors.Wrap(err, "getting the current user")
+	}
+
+	var notes []*gitlab.Note
+	nextPage := 0
+	for {
+		opts := gitlab.ListMergeRequestNotesOptions{PerPage: 100}
+		if nextPage != 0 {
+			opts.Page = nextPage
+		}
+		pageNotes, resp, err := g.Client.Notes.ListMergeRequestNotes(repo.FullName, pullNum, &opts)
+		if err != nil {
+			return err
+		}
+		notes = append(notes, pageNotes...)
+		if resp.NextPage == 0 {
+			break
+		}
+		nextPage = resp.NextPage
+	}
+	// Notes are listed newest first.
+	sort.Slice(notes, func(i, j int) bool { return notes[i].ID < notes[j].ID })
+
+	for _, note := range notes {
+		if note.System || note.Author.Username != user.Username || !shouldHide(note.Body) {
+			continue
+		}
+		if _, err := g.Client.Notes.DeleteMergeRequestNote(repo.FullName, pullNum, note.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MergePull accepts the merge request. GitLab merges it with the project's
+// merge method so only squash changes how it's merged. It's only merged if
+// its head commit hasn't changed since it was applied.
+func (g *GitlabClient) MergePull(repo models.Repo, pull models.PullRequest, opts MergeOptions) error {
+	// The vendored client's options don't have squash.
+	body := struct {
+		Sha                      string `json:"sha"`
+		Squash                   bool   `json:"squash"`
+		ShouldRemoveSourceBranch bool   `json:"should_remove_source_branch"`
+	}{
+		Sha:                      pull.HeadCommit,
+		Squash:                   opts.Method == SquashMethod,
+		ShouldRemoveSourceBranch: opts.DeleteSourceBranch,
+	}
+	req, err := g.Client.NewRequest("PUT", fmt.Sprintf("projects/%s/merge_requests/%d/merge", url.QueryEscape(repo.FullName), pull.Num), body, nil)
+	if err != nil {
+		return err
+	}
+	_, err = g.Client.Do(req, nil)
+	return errors.Wrap(err, "accepting merge request")
+}
+
 func (g *GitlabClient) GetMergeRequest(repoFullName string, pullNum int) (*gitlab.MergeRequest, error) {
 	mr, _, err := g.Client.MergeRequests.GetMergeRequest(repoFullName, pullNum)
 	return mr, err
 }
 
+// IsTeamMember returns true if username is a member of group, which is the
+// group's full path, ex. group/subgroup. Members inherited from parent groups
+// count too.
+// See https://docs.gitlab.com/ce/api/members.html#get-a-member-of-a-group-or-project-including-inherited-members.
+func (g *GitlabClient) IsTeamMember(group string, username string) (bool, error) {
+	users, _, err := g.Client.Users.ListUsers(&gitlab.ListUsersOptions{Username: gitlab.String(username)})
+	if err != nil {
+		return false, errors.Wrapf(err, "looking up user %s", username)
+	}
+	if len(users) == 0 {
+		return false, nil
+	}
+	apiURL := fmt.Sprintf("groups/%s/members/all/%d", url.QueryEscape(group), users[0].ID)
+	req, err := g.Client.NewRequest("GET", apiURL, nil, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := g.Client.Do(req, &gitlab.GroupMember{})
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // GetVersion returns the version of the Gitlab server this client is using.
 func (g *GitlabClient) GetVersion() (*version.Version, error) {
 	req, err := g.Client.NewRequest("GET", "/version", nil, nil)
@@ -221,6 +584,15 @@ func (g *GitlabClient) GetVersion() (*version.Version, error) {
 	return parsedVersion, nil
 }
 
+// CreateIssue opens an issue on repo.
+func (g *GitlabClient) CreateIssue(repo models.Repo, title string, body string) error {
+	_, _, err := g.Client.Issues.CreateIssue(repo.FullName, &gitlab.CreateIssueOptions{
+		Title:       gitlab.String(title),
+		Description: gitlab.String(body),
+	})
+	return err
+}
+
 // SupportsCommonMark returns true if the version of Gitlab this client is
 // using supports the CommonMark markdown format.
 func (g *GitlabClient) SupportsCommonMark() bool {
@@ -1,9 +1,15 @@
 package vcs
 
 import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/hashicorp/go-version"
+	"github.com/lkysow/go-gitlab"
+	"github.com/runatlantis/atlantis/server/events/models"
 	. "github.com/runatlantis/atlantis/testing"
 )
 
@@ -47,7 +53,7 @@ func TestNewGitlabClient_BaseURL(t *testing.T) {
 
 	for _, c := range cases {
 		t.Run(c.Hostname, func(t *testing.T) {
-			client, err := NewGitlabClient(c.Hostname, "token", nil)
+			client, err := NewGitlabClient(nil, c.Hostname, "token", nil)
 			Ok(t, err)
 			Equals(t, c.ExpBaseURL, client.Client.BaseURL().String())
 		})
@@ -95,3 +101,349 @@ func TestGitlabClient_SupportsCommonMark(t *testing.T) {
 		})
 	}
 }
+
+func TestGitlabClient_PullIsMergeable(t *testing.T) {
+	cases := []struct {
+		description string
+		mr          string
+		// approvals is the approvals response. If empty, approvals 404.
+		approvals string
+		// approvalState is the approval_state response. If empty, it 404s.
+		approvalState string
+		project       string
+		statuses      string
+		// mergedResultsStatuses are the statuses of the merged results
+		// pipeline's merge commit.
+		mergedResultsStatuses string
+		discussions           string
+		exp                   bool
+	}{
+		{
+			description: "can be merged",
+			mr:          `{"merge_status": "can_be_merged", "sha": "sha"}`,
+			approvals:   `{"approvals_left": 0}`,
+			project:     `{}`,
+			exp:         true,
+		},
+		{
+			description: "conflicts",
+			mr:          `{"merge_status": "cannot_be_merged", "sha": "sha"}`,
+			exp:         false,
+		},
+		{
+			description: "work in progress",
+			mr:          `{"merge_status": "can_be_merged", "work_in_progress": true, "sha": "sha"}`,
+			exp:         false,
+		},
+		{
+			description: "needs approvals",
+			mr:          `{"merge_status": "can_be_merged", "sha": "sha"}`,
+			approvals:   `{"approvals_left": 1}`,
+			exp:         false,
+		},
+		{
+			description:   "approval rule needs approvals",
+			mr:            `{"merge_status": "can_be_merged", "sha": "sha"}`,
+			approvals:     `{"approvals_left": 0}`,
+			approvalState: `{"rules": [{"name": "security", "approvals_required": 2, "approved_by": [{"username": "alice"}]}]}`,
+			exp:           false,
+		},
+		{
+			description: "no approvals api",
+			mr:          `{"merge_status": "can_be_merged", "sha": "sha"}`,
+			project:     `{}`,
+			exp:         true,
+		},
+		{
+			description: "pipeline must succeed and a job failed",
+			mr:          `{"merge_status": "can_be_merged", "sha": "sha"}`,
+			approvals:   `{"approvals_left": 0}`,
+			project:     `{"only_allow_merge_if_pipeline_succeeds": true}`,
+			statuses:    `[{"name": "test", "status": "success"}, {"name": "lint", "status": "failed"}]`,
+			exp:         false,
+		},
+		{
+			description: "pipeline must succeed and only our status and allowed failures haven't",
+			mr:          `{"merge_status": "can_be_merged", "sha": "sha"}`,
+			approvals:   `{"approvals_left": 0}`,
+			project:     `{"only_allow_merge_if_pipeline_succeeds": true}`,
+			statuses:    `[{"name": "test", "status": "success"}, {"name": "lint", "status": "failed", "allow_failure": true}, {"name": "Atlantis", "status": "running"}]`,
+			exp:         true,
+		},
+		{
+			description:           "pipeline must succeed and the merged results pipeline failed",
+			mr:                    `{"merge_status": "can_be_merged", "sha": "sha", "head_pipeline": {"id": 2, "sha": "merge-sha", "status": "failed"}}`,
+			approvals:             `{"approvals_left": 0}`,
+			project:               `{"only_allow_merge_if_pipeline_succeeds": true}`,
+			statuses:              `[{"name": "test", "status": "success"}]`,
+			mergedResultsStatuses: `[{"name": "test", "status": "failed"}]`,
+			exp:                   false,
+		},
+		{
+			description: "discussions must be resolved and one isn't",
+			mr:          `{"merge_status": "can_be_merged", "sha": "sha"}`,
+			approvals:   `{"approvals_left": 0}`,
+			project:     `{"only_allow_merge_if_all_discussions_are_resolved": true}`,
+			discussions: `[{"notes": [{"resolvable": false}]}, {"notes": [{"resolvable": true, "resolved": true}, {"resolvable": true, "resolved": false}]}]`,
+			exp:         false,
+		},
+		{
+			description: "discussions must be resolved and they are",
+			mr:          `{"merge_status": "can_be_merged", "sha": "sha"}`,
+			approvals:   `{"approvals_left": 0}`,
+			project:     `{"only_allow_merge_if_all_discussions_are_resolved": true}`,
+			discussions: `[{"notes": [{"resolvable": true, "resolved": true}]}]`,
+			exp:         true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body string
+				switch r.URL.EscapedPath() {
+				case "/api/v4/projects/owner%2Frepo/merge_requests/1":
+					body = c.mr
+				case "/api/v4/projects/owner%2Frepo/merge_requests/1/approvals":
+					body = c.approvals
+				case "/api/v4/projects/owner%2Frepo/merge_requests/1/approval_state":
+					body = c.approvalState
+				case "/api/v4/projects/owner%2Frepo":
+					body = c.project
+				case "/api/v4/projects/owner%2Frepo/repository/commits/sha/statuses":
+					body = c.statuses
+				case "/api/v4/projects/owner%2Frepo/repository/commits/merge-sha/statuses":
+					body = c.mergedResultsStatuses
+				case "/api/v4/projects/owner%2Frepo/merge_requests/1/discussions":
+					body = c.discussions
+				default:
+					t.Errorf("got unexpected request at %q", r.RequestURI)
+				}
+				if body == "" {
+					http.Error(w, "not found", http.StatusNotFound)
+					return
+				}
+				w.Write([]byte(body)) // nolint: errcheck
+			}))
+			defer testServer.Close()
+
+			client := &GitlabClient{Client: gitlab.NewClient(nil, "token")}
+			Ok(t, client.Client.SetBaseURL(testServer.URL+"/api/v4/"))
+			mergeable, err := client.PullIsMergeable(models.Repo{FullName: "owner/repo"}, models.PullRequest{Num: 1})
+			Ok(t, err)
+			Equals(t, c.exp, mergeable)
+		})
+	}
+}
+
+// With PipelineStatuses our statuses are added to the head pipeline if it's
+// for the head commit.
+func TestGitlabClient_UpdateStatus_PipelineStatuses(t *testing.T) {
+	cases := map[string]struct {
+		mr            string
+		expPipelineID interface{}
+	}{
+		"head pipeline":           {`{"head_pipeline": {"id": 7, "sha": "sha"}}`, float64(7)},
+		"merged results pipeline": {`{"head_pipeline": {"id": 7, "sha": "merge-sha"}}`, nil},
+		"no pipeline":             {`{}`, nil},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var body map[string]interface{}
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.Method + " " + r.URL.EscapedPath() {
+				case "GET /api/v4/projects/owner%2Frepo/merge_requests/1":
+					w.Write([]byte(c.mr)) // nolint: errcheck
+				case "POST /api/v4/projects/owner%2Frepo/statuses/sha":
+					Ok(t, json.NewDecoder(r.Body).Decode(&body))
+					w.Write([]byte(`{}`)) // nolint: errcheck
+				default:
+					t.Errorf("got unexpected request %s %q", r.Method, r.RequestURI)
+					http.Error(w, "not found", http.StatusNotFound)
+				}
+			}))
+			defer testServer.Close()
+
+			client := &GitlabClient{Client: gitlab.NewClient(nil, "token"), PipelineStatuses: true}
+			Ok(t, client.Client.SetBaseURL(testServer.URL+"/api/v4/"))
+			err := client.UpdateStatus(models.Repo{FullName: "owner/repo"}, models.PullRequest{Num: 1, HeadCommit: "sha", Branch: "branch"}, models.PendingCommitStatus, "atlantis/plan", "Plan Pending")
+			Ok(t, err)
+			Equals(t, "pending", body["state"])
+			Equals(t, "branch", body["ref"])
+			Equals(t, "atlantis/plan", body["context"])
+			Equals(t, "Plan Pending", body["description"])
+			Equals(t, c.expPipelineID, body["pipeline_id"])
+		})
+	}
+}
+
+func TestGitlabClient_PullIsApproved(t *testing.T) {
+	cases := []struct {
+		description string
+		approvals   string
+		// approvalState is the approval_state response. If empty, it 404s
+		// like on GitLab Core.
+		approvalState string
+		exp           bool
+	}{
+		{
+			description: "no approvals required or given",
+			approvals:   `{"approvals_required": 0, "approvals_left": 0, "approved_by": []}`,
+			exp:         false,
+		},
+		{
+			description: "approved without rules",
+			approvals:   `{"approvals_required": 0, "approvals_left": 0, "approved_by": [{"user": {"username": "alice"}}]}`,
+			exp:         true,
+		},
+		{
+			description: "approvals left",
+			approvals:   `{"approvals_required": 2, "approvals_left": 1, "approved_by": [{"user": {"username": "alice"}}]}`,
+			exp:         false,
+		},
+		{
+			description:   "every rule approved",
+			approvals:     `{"approvals_required": 2, "approvals_left": 0, "approved_by": [{"user": {"username": "alice"}}, {"user": {"username": "bob"}}]}`,
+			approvalState: `{"rules": [{"name": "infra", "approvals_required": 1, "approved_by": [{"username": "alice"}]}, {"name": "security", "approvals_required": 1, "approved_by": [{"username": "bob"}]}]}`,
+			exp:           true,
+		},
+		{
+			description:   "a rule needs more approvals",
+			approvals:     `{"approvals_required": 2, "approvals_left": 0, "approved_by": [{"user": {"username": "alice"}}, {"user": {"username": "bob"}}]}`,
+			approvalState: `{"rules": [{"name": "infra", "approvals_required": 1, "approved_by": [{"username": "alice"}]}, {"name": "security", "approvals_required": 2, "approved_by": [{"username": "bob"}]}]}`,
+			exp:           false,
+		},
+		{
+			description:   "optional rules",
+			approvals:     `{"approvals_required": 0, "approvals_left": 0, "approved_by": [{"user": {"username": "alice"}}]}`,
+			approvalState: `{"rules": [{"name": "any", "approvals_required": 0, "approved_by": []}]}`,
+			exp:           true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body string
+				switch r.URL.EscapedPath() {
+				case "/api/v4/projects/owner%2Frepo/merge_requests/1/approvals":
+					body = c.approvals
+				case "/api/v4/projects/owner%2Frepo/merge_requests/1/approval_state":
+					body = c.approvalState
+				default:
+					t.Errorf("got unexpected request at %q", r.RequestURI)
+				}
+				if body == "" {
+					http.Error(w, "not found", http.StatusNotFound)
+					return
+				}
+				w.Write([]byte(body)) // nolint: errcheck
+			}))
+			defer testServer.Close()
+
+			client := &GitlabClient{Client: gitlab.NewClient(nil, "token")}
+			Ok(t, client.Client.SetBaseURL(testServer.URL+"/api/v4/"))
+			approved, err := client.PullIsApproved(models.Repo{FullName: "owner/repo"}, models.PullRequest{Num: 1})
+			Ok(t, err)
+			Equals(t, c.exp, approved)
+		})
+	}
+}
+
+func TestGitlabClient_PullIsDraft(t *testing.T) {
+	cases := []struct {
+		title          string
+		workInProgress bool
+		exp            bool
+	}{
+		{"Add VPC", false, false},
+		{"WIP: Add VPC", true, true},
+		{"Draft: Add VPC", false, true},
+		{"[Draft] Add VPC", false, true},
+		{"(draft) Add VPC", false, true},
+		{"Drafting the VPC", false, false},
+	}
+	for _, c := range cases {
+		t.Run(c.title, func(t *testing.T) {
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Equals(t, "/api/v4/projects/owner%2Frepo/merge_requests/1", r.URL.EscapedPath())
+				w.Write([]byte(fmt.Sprintf(`{"iid": 1, "title": %q, "work_in_progress": %t}`, c.title, c.workInProgress))) // nolint: errcheck
+			}))
+			defer testServer.Close()
+
+			client := &GitlabClient{Client: gitlab.NewClient(nil, "token")}
+			Ok(t, client.Client.SetBaseURL(testServer.URL+"/api/v4/"))
+			draft, err := client.PullIsDraft(models.Repo{FullName: "owner/repo"}, models.PullRequest{Num: 1})
+			Ok(t, err)
+			Equals(t, c.exp, draft)
+		})
+	}
+}
+
+func TestGitlabClient_IsTeamMember(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/api/v4/users":
+			switch r.URL.Query().Get("username") {
+			case "alice":
+				w.Write([]byte(`[{"id": 1, "username": "alice"}]`)) // nolint: errcheck
+			case "bob":
+				w.Write([]byte(`[{"id": 2, "username": "bob"}]`)) // nolint: errcheck
+			default:
+				w.Write([]byte(`[]`)) // nolint: errcheck
+			}
+		case "/api/v4/groups/mygroup%2Finfra/members/all/1":
+			w.Write([]byte(`{"id": 1, "username": "alice", "access_level": 30}`)) // nolint: errcheck
+		case "/api/v4/groups/mygroup%2Finfra/members/all/2":
+			http.Error(w, `{"message": "404 Not found"}`, http.StatusNotFound)
+		default:
+			t.Errorf("got unexpected request at %q", r.RequestURI)
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+
+	client := &GitlabClient{Client: gitlab.NewClient(nil, "token")}
+	Ok(t, client.Client.SetBaseURL(testServer.URL+"/api/v4/"))
+	for username, exp := range map[string]bool{"alice": true, "bob": false, "nobody": false} {
+		member, err := client.IsTeamMember("mygroup/infra", username)
+		Ok(t, err)
+		Equals(t, exp, member)
+	}
+}
+
+// HidePrevComments should delete our notes that shouldHide returns true for,
+// checking them oldest first.
+func TestGitlabClient_HidePrevComments(t *testing.T) {
+	var deleted []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.EscapedPath() {
+		case "GET /api/v4/user":
+			w.Write([]byte(`{"username": "atlantis"}`)) // nolint: errcheck
+		case "GET /api/v4/projects/owner%2Frepo/merge_requests/1/notes":
+			// Notes are returned newest first.
+			w.Write([]byte(`[
+  {"id": 4, "body": "Ran Plan for dir: new", "author": {"username": "atlantis"}},
+  {"id": 3, "body": "Ran Plan for dir: old", "author": {"username": "someone"}},
+  {"id": 2, "body": "added 1 commit", "system": true, "author": {"username": "atlantis"}},
+  {"id": 1, "body": "Ran Plan for dir: old", "author": {"username": "atlantis"}}
+]`)) // nolint: errcheck
+		case "DELETE /api/v4/projects/owner%2Frepo/merge_requests/1/notes/1":
+			deleted = append(deleted, "1")
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("got unexpected request %s %q", r.Method, r.RequestURI)
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+
+	client := &GitlabClient{Client: gitlab.NewClient(nil, "token")}
+	Ok(t, client.Client.SetBaseURL(testServer.URL+"/api/v4/"))
+	var seen []string
+	err := client.HidePrevComments(models.Repo{FullName: "owner/repo"}, 1, func(comment string) bool {
+		seen = append(seen, comment)
+		return comment == "Ran Plan for dir: old"
+	})
+	Ok(t, err)
+	Equals(t, []string{"Ran Plan for dir: old", "Ran Plan for dir: new"}, seen)
+	Equals(t, []string{"1"}, deleted)
+}
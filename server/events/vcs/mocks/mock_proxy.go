@@ -6,6 +6,7 @@ package mocks
 import (
 	pegomock "github.com/petergtz/pegomock"
 	models "github.com/runatlantis/atlantis/server/events/models"
+	vcs "github.com/runatlantis/atlantis/server/events/vcs"
 	"reflect"
 	"time"
 )
@@ -90,11 +91,11 @@ func (mock *MockClientProxy) PullIsMergeable(repo models.Repo, pull models.PullR
 	return ret0, ret1
 }
 
-func (mock *MockClientProxy) UpdateStatus(repo models.Repo, pull models.PullRequest, state models.CommitStatus, description string) error {
+func (mock *MockClientProxy) UpdateStatus(repo models.Repo, pull models.PullRequest, state models.CommitStatus, src string, description string) error {
 	if mock == nil {
 		panic("mock must not be nil. Use myMock := NewMockClientProxy().")
 	}
-	params := []pegomock.Param{repo, pull, state, description}
+	params := []pegomock.Param{repo, pull, state, src, description}
 	result := pegomock.GetGenericMockFrom(mock).Invoke("UpdateStatus", params, []reflect.Type{reflect.TypeOf((*error)(nil)).Elem()})
 	var ret0 error
 	if len(result) != 0 {
@@ -105,6 +106,89 @@ func (mock *MockClientProxy) UpdateStatus(repo models.Repo, pull models.PullRequ
 	return ret0
 }
 
+func (mock *MockClientProxy) ReactToComment(repo models.Repo, pullNum int, commentID int64, reaction string) error {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockClientProxy().")
+	}
+	params := []pegomock.Param{repo, pullNum, commentID, reaction}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("ReactToComment", params, []reflect.Type{reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(error)
+		}
+	}
+	return ret0
+}
+
+func (mock *MockClientProxy) GetPullLabels(repo models.Repo, pull models.PullRequest) ([]string, error) {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockClientProxy().")
+	}
+	params := []pegomock.Param{repo, pull}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("GetPullLabels", params, []reflect.Type{reflect.TypeOf((*[]string)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 []string
+	var ret1 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].([]string)
+		}
+		if result[1] != nil {
+			ret1 = result[1].(error)
+		}
+	}
+	return ret0, ret1
+}
+
+func (mock *MockClientProxy) PullIsDraft(repo models.Repo, pull models.PullRequest) (bool, error) {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockClientProxy().")
+	}
+	params := []pegomock.Param{repo, pull}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("PullIsDraft", params, []reflect.Type{reflect.TypeOf((*bool)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 bool
+	var ret1 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(bool)
+		}
+		if result[1] != nil {
+			ret1 = result[1].(error)
+		}
+	}
+	return ret0, ret1
+}
+
+func (mock *MockClientProxy) HidePrevComments(repo models.Repo, pullNum int, shouldHide func(comment string) bool) error {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockClientProxy().")
+	}
+	params := []pegomock.Param{repo, pullNum, shouldHide}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("HidePrevComments", params, []reflect.Type{reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(error)
+		}
+	}
+	return ret0
+}
+
+func (mock *MockClientProxy) MergePull(repo models.Repo, pull models.PullRequest, opts vcs.MergeOptions) error {
+	if mock == nil {
+		panic("mock must not be nil. Use myMock := NewMockClientProxy().")
+	}
+	params := []pegomock.Param{repo, pull, opts}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("MergePull", params, []reflect.Type{reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(error)
+		}
+	}
+	return ret0
+}
+
 func (mock *MockClientProxy) VerifyWasCalledOnce() *VerifierClientProxy {
 	return &VerifierClientProxy{
 		mock:                   mock,
@@ -270,8 +354,8 @@ func (c *ClientProxy_PullIsMergeable_OngoingVerification) GetAllCapturedArgument
 	return
 }
 
-func (verifier *VerifierClientProxy) UpdateStatus(repo models.Repo, pull models.PullRequest, state models.CommitStatus, description string) *ClientProxy_UpdateStatus_OngoingVerification {
-	params := []pegomock.Param{repo, pull, state, description}
+func (verifier *VerifierClientProxy) UpdateStatus(repo models.Repo, pull models.PullRequest, state models.CommitStatus, src string, description string) *ClientProxy_UpdateStatus_OngoingVerification {
+	params := []pegomock.Param{repo, pull, state, src, description}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "UpdateStatus", params, verifier.timeout)
 	return &ClientProxy_UpdateStatus_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
 }
@@ -281,12 +365,12 @@ type ClientProxy_UpdateStatus_OngoingVerification struct {
 	methodInvocations []pegomock.MethodInvocation
 }
 
-func (c *ClientProxy_UpdateStatus_OngoingVerification) GetCapturedArguments() (models.Repo, models.PullRequest, models.CommitStatus, string) {
-	repo, pull, state, description := c.GetAllCapturedArguments()
-	return repo[len(repo)-1], pull[len(pull)-1], state[len(state)-1], description[len(description)-1]
+func (c *ClientProxy_UpdateStatus_OngoingVerification) GetCapturedArguments() (models.Repo, models.PullRequest, models.CommitStatus, string, string) {
+	repo, pull, state, src, description := c.GetAllCapturedArguments()
+	return repo[len(repo)-1], pull[len(pull)-1], state[len(state)-1], src[len(src)-1], description[len(description)-1]
 }
 
-func (c *ClientProxy_UpdateStatus_OngoingVerification) GetAllCapturedArguments() (_param0 []models.Repo, _param1 []models.PullRequest, _param2 []models.CommitStatus, _param3 []string) {
+func (c *ClientProxy_UpdateStatus_OngoingVerification) GetAllCapturedArguments() (_param0 []models.Repo, _param1 []models.PullRequest, _param2 []models.CommitStatus, _param3 []string, _param4 []string) {
 	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
 	if len(params) > 0 {
 		_param0 = make([]models.Repo, len(params[0]))
@@ -305,6 +389,181 @@ func (c *ClientProxy_UpdateStatus_OngoingVerification) GetAllCapturedArguments()
 		for u, param := range params[3] {
 			_param3[u] = param.(string)
 		}
+		_param4 = make([]string, len(params[4]))
+		for u, param := range params[4] {
+			_param4[u] = param.(string)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierClientProxy) ReactToComment(repo models.Repo, pullNum int, commentID int64, reaction string) *ClientProxy_ReactToComment_OngoingVerification {
+	params := []pegomock.Param{repo, pullNum, commentID, reaction}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "ReactToComment", params, verifier.timeout)
+	return &ClientProxy_ReactToComment_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type ClientProxy_ReactToComment_OngoingVerification struct {
+	mock              *MockClientProxy
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *ClientProxy_ReactToComment_OngoingVerification) GetCapturedArguments() (models.Repo, int, int64, string) {
+	repo, pullNum, commentID, reaction := c.GetAllCapturedArguments()
+	return repo[len(repo)-1], pullNum[len(pullNum)-1], commentID[len(commentID)-1], reaction[len(reaction)-1]
+}
+
+func (c *ClientProxy_ReactToComment_OngoingVerification) GetAllCapturedArguments() (_param0 []models.Repo, _param1 []int, _param2 []int64, _param3 []string) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]models.Repo, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(models.Repo)
+		}
+		_param1 = make([]int, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(int)
+		}
+		_param2 = make([]int64, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(int64)
+		}
+		_param3 = make([]string, len(params[3]))
+		for u, param := range params[3] {
+			_param3[u] = param.(string)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierClientProxy) GetPullLabels(repo models.Repo, pull models.PullRequest) *ClientProxy_GetPullLabels_OngoingVerification {
+	params := []pegomock.Param{repo, pull}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "GetPullLabels", params, verifier.timeout)
+	return &ClientProxy_GetPullLabels_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type ClientProxy_GetPullLabels_OngoingVerification struct {
+	mock              *MockClientProxy
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *ClientProxy_GetPullLabels_OngoingVerification) GetCapturedArguments() (models.Repo, models.PullRequest) {
+	repo, pull := c.GetAllCapturedArguments()
+	return repo[len(repo)-1], pull[len(pull)-1]
+}
+
+func (c *ClientProxy_GetPullLabels_OngoingVerification) GetAllCapturedArguments() (_param0 []models.Repo, _param1 []models.PullRequest) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]models.Repo, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(models.Repo)
+		}
+		_param1 = make([]models.PullRequest, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(models.PullRequest)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierClientProxy) PullIsDraft(repo models.Repo, pull models.PullRequest) *ClientProxy_PullIsDraft_OngoingVerification {
+	params := []pegomock.Param{repo, pull}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "PullIsDraft", params, verifier.timeout)
+	return &ClientProxy_PullIsDraft_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type ClientProxy_PullIsDraft_OngoingVerification struct {
+	mock              *MockClientProxy
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *ClientProxy_PullIsDraft_OngoingVerification) GetCapturedArguments() (models.Repo, models.PullRequest) {
+	repo, pull := c.GetAllCapturedArguments()
+	return repo[len(repo)-1], pull[len(pull)-1]
+}
+
+func (c *ClientProxy_PullIsDraft_OngoingVerification) GetAllCapturedArguments() (_param0 []models.Repo, _param1 []models.PullRequest) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]models.Repo, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(models.Repo)
+		}
+		_param1 = make([]models.PullRequest, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(models.PullRequest)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierClientProxy) HidePrevComments(repo models.Repo, pullNum int, shouldHide func(comment string) bool) *ClientProxy_HidePrevComments_OngoingVerification {
+	params := []pegomock.Param{repo, pullNum, shouldHide}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "HidePrevComments", params, verifier.timeout)
+	return &ClientProxy_HidePrevComments_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type ClientProxy_HidePrevComments_OngoingVerification struct {
+	mock              *MockClientProxy
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *ClientProxy_HidePrevComments_OngoingVerification) GetCapturedArguments() (models.Repo, int, func(comment string) bool) {
+	repo, pullNum, shouldHide := c.GetAllCapturedArguments()
+	return repo[len(repo)-1], pullNum[len(pullNum)-1], shouldHide[len(shouldHide)-1]
+}
+
+func (c *ClientProxy_HidePrevComments_OngoingVerification) GetAllCapturedArguments() (_param0 []models.Repo, _param1 []int, _param2 []func(comment string) bool) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]models.Repo, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(models.Repo)
+		}
+		_param1 = make([]int, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(int)
+		}
+		_param2 = make([]func(comment string) bool, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(func(comment string) bool)
+		}
+	}
+	return
+}
+
+func (verifier *VerifierClientProxy) MergePull(repo models.Repo, pull models.PullRequest, opts vcs.MergeOptions) *ClientProxy_MergePull_OngoingVerification {
+	params := []pegomock.Param{repo, pull, opts}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "MergePull", params, verifier.timeout)
+	return &ClientProxy_MergePull_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type ClientProxy_MergePull_OngoingVerification struct {
+	mock              *MockClientProxy
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *ClientProxy_MergePull_OngoingVerification) GetCapturedArguments() (models.Repo, models.PullRequest, vcs.MergeOptions) {
+	repo, pull, opts := c.GetAllCapturedArguments()
+	return repo[len(repo)-1], pull[len(pull)-1], opts[len(opts)-1]
+}
+
+func (c *ClientProxy_MergePull_OngoingVerification) GetAllCapturedArguments() (_param0 []models.Repo, _param1 []models.PullRequest, _param2 []vcs.MergeOptions) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]models.Repo, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(models.Repo)
+		}
+		_param1 = make([]models.PullRequest, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(models.PullRequest)
+		}
+		_param2 = make([]vcs.MergeOptions, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(vcs.MergeOptions)
+		}
 	}
 	return
 }
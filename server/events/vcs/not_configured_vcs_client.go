@@ -38,7 +38,22 @@ func (a *NotConfiguredVCSClient) PullIsApproved(repo models.Repo, pull models.Pu
 func (a *NotConfiguredVCSClient) PullIsMergeable(repo models.Repo, pull models.PullRequest) (bool, error) {
 	return false, a.err()
 }
-func (a *NotConfiguredVCSClient) UpdateStatus(repo models.Repo, pull models.PullRequest, state models.CommitStatus, description string) error {
+func (a *NotConfiguredVCSClient) UpdateStatus(repo models.Repo, pull models.PullRequest, state models.CommitStatus, src string, description string) error {
+	return a.err()
+}
+func (a *NotConfiguredVCSClient) ReactToComment(repo models.Repo, pullNum int, commentID int64, reaction string) error {
+	return a.err()
+}
+func (a *NotConfiguredVCSClient) GetPullLabels(repo models.Repo, pull models.PullRequest) ([]string, error) {
+	return nil, a.err()
+}
+func (a *NotConfiguredVCSClient) PullIsDraft(repo models.Repo, pull models.PullRequest) (bool, error) {
+	return false, a.err()
+}
+func (a *NotConfiguredVCSClient) HidePrevComments(repo models.Repo, pullNum int, shouldHide func(comment string) bool) error {
+	return a.err()
+}
+func (a *NotConfiguredVCSClient) MergePull(repo models.Repo, pull models.PullRequest, opts MergeOptions) error {
 	return a.err()
 }
 func (a *NotConfiguredVCSClient) err() error {
@@ -26,7 +26,12 @@ type ClientProxy interface {
 	CreateComment(repo models.Repo, pullNum int, comment string) error
 	PullIsApproved(repo models.Repo, pull models.PullRequest) (bool, error)
 	PullIsMergeable(repo models.Repo, pull models.PullRequest) (bool, error)
-	UpdateStatus(repo models.Repo, pull models.PullRequest, state models.CommitStatus, description string) error
+	UpdateStatus(repo models.Repo, pull models.PullRequest, state models.CommitStatus, src string, description string) error
+	ReactToComment(repo models.Repo, pullNum int, commentID int64, reaction string) error
+	GetPullLabels(repo models.Repo, pull models.PullRequest) ([]string, error)
+	PullIsDraft(repo models.Repo, pull models.PullRequest) (bool, error)
+	HidePrevComments(repo models.Repo, pullNum int, shouldHide func(comment string) bool) error
+	MergePull(repo models.Repo, pull models.PullRequest, opts MergeOptions) error
 }
 
 // DefaultClientProxy proxies calls to the correct VCS client depending on which
@@ -37,7 +42,7 @@ type DefaultClientProxy struct {
 	clients map[models.VCSHostType]Client
 }
 
-func NewDefaultClientProxy(githubClient Client, gitlabClient Client, bitbucketCloudClient Client, bitbucketServerClient Client) *DefaultClientProxy {
+func NewDefaultClientProxy(githubClient Client, gitlabClient Client, bitbucketCloudClient Client, bitbucketServerClient Client, azuredevopsClient Client, giteaClient Client) *DefaultClientProxy {
 	if githubClient == nil {
 		githubClient = &NotConfiguredVCSClient{}
 	}
@@ -50,12 +55,20 @@ func NewDefaultClientProxy(githubClient Client, gitlabClient Client, bitbucketCl
 	if bitbucketServerClient == nil {
 		bitbucketServerClient = &NotConfiguredVCSClient{}
 	}
+	if azuredevopsClient == nil {
+		azuredevopsClient = &NotConfiguredVCSClient{}
+	}
+	if giteaClient == nil {
+		giteaClient = &NotConfiguredVCSClient{}
+	}
 	return &DefaultClientProxy{
 		clients: map[models.VCSHostType]Client{
 			models.Github:          githubClient,
 			models.Gitlab:          gitlabClient,
 			models.BitbucketCloud:  bitbucketCloudClient,
 			models.BitbucketServer: bitbucketServerClient,
+			models.AzureDevops:     azuredevopsClient,
+			models.Gitea:           giteaClient,
 		},
 	}
 }
@@ -76,6 +89,26 @@ func (d *DefaultClientProxy) PullIsMergeable(repo models.Repo, pull models.PullR
 	return d.clients[repo.VCSHost.Type].PullIsMergeable(repo, pull)
 }
 
-func (d *DefaultClientProxy) UpdateStatus(repo models.Repo, pull models.PullRequest, state models.CommitStatus, description string) error {
-	return d.clients[repo.VCSHost.Type].UpdateStatus(repo, pull, state, description)
+func (d *DefaultClientProxy) UpdateStatus(repo models.Repo, pull models.PullRequest, state models.CommitStatus, src string, description string) error {
+	return d.clients[repo.VCSHost.Type].UpdateStatus(repo, pull, state, src, description)
+}
+
+func (d *DefaultClientProxy) ReactToComment(repo models.Repo, pullNum int, commentID int64, reaction string) error {
+	return d.clients[repo.VCSHost.Type].ReactToComment(repo, pullNum, commentID, reaction)
+}
+
+func (d *DefaultClientProxy) GetPullLabels(repo models.Repo, pull models.PullRequest) ([]string, error) {
+	return d.clients[repo.VCSHost.Type].GetPullLabels(repo, pull)
+}
+
+func (d *DefaultClientProxy) PullIsDraft(repo models.Repo, pull models.PullRequest) (bool, error) {
+	return d.clients[repo.VCSHost.Type].PullIsDraft(repo, pull)
+}
+
+func (d *DefaultClientProxy) HidePrevComments(repo models.Repo, pullNum int, shouldHide func(comment string) bool) error {
+	return d.clients[repo.VCSHost.Type].HidePrevComments(repo, pullNum, shouldHide)
+}
+
+func (d *DefaultClientProxy) MergePull(repo models.Repo, pull models.PullRequest, opts MergeOptions) error {
+	return d.clients[repo.VCSHost.Type].MergePull(repo, pull, opts)
 }
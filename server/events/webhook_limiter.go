@@ -0,0 +1,111 @@
+package events
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Reasons WebhookLimiter rejects webhooks.
+const (
+	// RepoRateLimited is when the repo has started too many commands in the
+	// last minute.
+	RepoRateLimited = "rate_limited"
+	// QueueFull is when too many commands are already running or queued.
+	QueueFull = "queue_full"
+)
+
+// queueFullRetryAfter is how long we ask VCS hosts to wait before retrying a
+// webhook rejected because the queue is full.
+const queueFullRetryAfter = time.Minute
+
+// WebhookLimiter protects Atlantis from webhook storms, ex. a bot opening
+// pull requests on every repo in an org at once. It limits how many commands
+// each repo can start a minute and refuses new commands while too many are
+// already running or queued, rather than accepting them all until Atlantis
+// runs out of memory. It's in memory so each instance limits the webhooks it
+// receives.
+type WebhookLimiter struct {
+	// RepoRatePerMinute is how many commands each repo can start a minute.
+	// Bursts of up to that many are allowed. If 0 there's no limit.
+	RepoRatePerMinute int
+	// MaxQueueDepth is how many commands can be running or queued before new
+	// ones are refused. If 0 there's no limit.
+	MaxQueueDepth int
+	// QueueDepth returns how many commands are running or queued.
+	QueueDepth func() int
+
+	mu        sync.Mutex
+	buckets   map[string]*rateBucket
+	lastPrune time.Time
+}
+
+// rateBucket is a token bucket of the commands a repo can start.
+type rateBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// WebhookRejection is why a webhook was rejected and how long to wait before
+// retrying it.
+type WebhookRejection struct {
+	Reason     string
+	Message    string
+	RetryAfter time.Duration
+}
+
+// Allow records that a webhook for repo, ex. github.com/owner/repo, wants to
+// start a command. It returns nil if it can or why it can't.
+func (l *WebhookLimiter) Allow(repo string) *WebhookRejection {
+	// We check the queue first so webhooks rejected because it's full don't
+	// use up their repo's rate.
+	if l.MaxQueueDepth > 0 && l.QueueDepth != nil {
+		if depth := l.QueueDepth(); depth >= l.MaxQueueDepth {
+			return &WebhookRejection{
+				Reason:     QueueFull,
+				Message:    fmt.Sprintf("%d commands are already running or queued, the most allowed", depth),
+				RetryAfter: queueFullRetryAfter,
+			}
+		}
+	}
+	if l.RepoRatePerMinute <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if l.buckets == nil {
+		l.buckets = make(map[string]*rateBucket)
+	}
+	burst := float64(l.RepoRatePerMinute)
+	perSecond := burst / time.Minute.Seconds()
+	// Buckets that have refilled are the same as new ones so we prune them,
+	// at most once a minute so it doesn't cost every webhook a scan of the
+	// map.
+	if now.Sub(l.lastPrune) > time.Minute {
+		for k, b := range l.buckets {
+			if b.tokens+now.Sub(b.updated).Seconds()*perSecond >= burst {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastPrune = now
+	}
+	b, ok := l.buckets[repo]
+	if !ok {
+		b = &rateBucket{tokens: burst, updated: now}
+		l.buckets[repo] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.updated).Seconds()*perSecond)
+	b.updated = now
+	if b.tokens < 1 {
+		return &WebhookRejection{
+			Reason:     RepoRateLimited,
+			Message:    fmt.Sprintf("%s has started more than %d commands in the last minute", repo, l.RepoRatePerMinute),
+			RetryAfter: time.Duration(math.Ceil((1-b.tokens)/perSecond)) * time.Second,
+		}
+	}
+	b.tokens--
+	return nil
+}
@@ -0,0 +1,52 @@
+package events_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestWebhookLimiter_NoLimits(t *testing.T) {
+	l := &events.WebhookLimiter{QueueDepth: func() int { return 1000 }}
+	for i := 0; i < 100; i++ {
+		Assert(t, l.Allow("github.com/owner/repo") == nil, "exp webhook %d to be allowed", i)
+	}
+}
+
+func TestWebhookLimiter_RepoRate(t *testing.T) {
+	l := &events.WebhookLimiter{RepoRatePerMinute: 3}
+	for i := 0; i < 3; i++ {
+		Assert(t, l.Allow("github.com/owner/repo") == nil, "exp a burst of 3 webhooks to be allowed")
+	}
+	rejection := l.Allow("github.com/owner/repo")
+	Assert(t, rejection != nil, "exp the 4th webhook to be rejected")
+	Equals(t, events.RepoRateLimited, rejection.Reason)
+	Equals(t, "github.com/owner/repo has started more than 3 commands in the last minute", rejection.Message)
+	Assert(t, rejection.RetryAfter > 0 && rejection.RetryAfter <= 20*time.Second, "exp to retry within 20s when a token is refilled but got %s", rejection.RetryAfter)
+
+	t.Log("other repos have their own limit")
+	Assert(t, l.Allow("github.com/owner/other") == nil, "exp another repo's webhook to be allowed")
+}
+
+func TestWebhookLimiter_QueueDepth(t *testing.T) {
+	depth := 4
+	l := &events.WebhookLimiter{
+		RepoRatePerMinute: 1,
+		MaxQueueDepth:     5,
+		QueueDepth:        func() int { return depth },
+	}
+	Assert(t, l.Allow("github.com/owner/repo") == nil, "exp webhook to be allowed under the max depth")
+
+	depth = 5
+	rejection := l.Allow("github.com/owner/other")
+	Assert(t, rejection != nil, "exp webhook to be rejected at the max depth")
+	Equals(t, events.QueueFull, rejection.Reason)
+	Equals(t, "5 commands are already running or queued, the most allowed", rejection.Message)
+	Equals(t, time.Minute, rejection.RetryAfter)
+
+	t.Log("webhooks rejected because the queue is full shouldn't use up their repo's rate")
+	depth = 0
+	Assert(t, l.Allow("github.com/owner/other") == nil, "exp webhook to be allowed once the queue drains")
+}
@@ -7,14 +7,14 @@ import (
 	webhooks "github.com/runatlantis/atlantis/server/events/webhooks"
 )
 
-func AnyWebhooksApplyResult() webhooks.ApplyResult {
-	pegomock.RegisterMatcher(pegomock.NewAnyMatcher(reflect.TypeOf((*(webhooks.ApplyResult))(nil)).Elem()))
-	var nullValue webhooks.ApplyResult
+func AnyWebhooksResult() webhooks.Result {
+	pegomock.RegisterMatcher(pegomock.NewAnyMatcher(reflect.TypeOf((*(webhooks.Result))(nil)).Elem()))
+	var nullValue webhooks.Result
 	return nullValue
 }
 
-func EqWebhooksApplyResult(value webhooks.ApplyResult) webhooks.ApplyResult {
+func EqWebhooksResult(value webhooks.Result) webhooks.Result {
 	pegomock.RegisterMatcher(&pegomock.EqMatcher{Value: value})
-	var nullValue webhooks.ApplyResult
+	var nullValue webhooks.Result
 	return nullValue
 }
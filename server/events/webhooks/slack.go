@@ -14,7 +14,9 @@
 package webhooks
 
 import (
+	"bytes"
 	"regexp"
+	"text/template"
 
 	"fmt"
 
@@ -27,6 +29,15 @@ type SlackWebhook struct {
 	Client         SlackClient
 	WorkspaceRegex *regexp.Regexp
 	Channel        string
+	// Event is the event this webhook is sent for. If empty, it's sent for
+	// ApplyEvent.
+	Event string
+	// RepoRegex must match the repo's full name. If nil, all repos match.
+	RepoRegex *regexp.Regexp
+	// Template renders the message text. If nil, the default message is sent.
+	Template *template.Template
+	// ThreadPerPull posts the messages for each pull request in a thread.
+	ThreadPerPull bool
 }
 
 func NewSlack(r *regexp.Regexp, channel string, client SlackClient) (*SlackWebhook, error) {
@@ -49,10 +60,30 @@ func NewSlack(r *regexp.Regexp, channel string, client SlackClient) (*SlackWebho
 	}, nil
 }
 
-// Send sends the webhook to Slack if the workspace matches the regex.
-func (s *SlackWebhook) Send(log *logging.SimpleLogger, applyResult ApplyResult) error {
-	if !s.WorkspaceRegex.MatchString(applyResult.Workspace) {
+// Send sends the webhook to Slack if it's for result's event and the
+// workspace and repo match the regexes.
+func (s *SlackWebhook) Send(log *logging.SimpleLogger, result Result) error {
+	event := s.Event
+	if event == "" {
+		event = ApplyEvent
+	}
+	if result.Event != event {
+		return nil
+	}
+	if !s.WorkspaceRegex.MatchString(result.Workspace) {
 		return nil
 	}
-	return s.Client.PostMessage(s.Channel, applyResult)
+	if s.RepoRegex != nil && !s.RepoRegex.MatchString(result.Repo.FullName) {
+		return nil
+	}
+
+	var text string
+	if s.Template != nil {
+		buf := &bytes.Buffer{}
+		if err := s.Template.Execute(buf, result); err != nil {
+			return errors.Wrapf(err, "rendering %q webhook template", event)
+		}
+		text = buf.String()
+	}
+	return s.Client.PostMessage(s.Channel, result, text, s.ThreadPerPull)
 }
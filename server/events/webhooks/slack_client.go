@@ -15,6 +15,7 @@ package webhooks
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/nlopes/slack"
 )
@@ -22,6 +23,7 @@ import (
 const (
 	slackSuccessColour = "good"
 	slackFailureColour = "danger"
+	slackInfoColour    = "#439FE0"
 )
 
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_slack_client.go SlackClient
@@ -31,7 +33,11 @@ type SlackClient interface {
 	AuthTest() error
 	TokenIsSet() bool
 	ChannelExists(channelName string) (bool, error)
-	PostMessage(channel string, applyResult ApplyResult) error
+	// PostMessage posts a message about result to channel. If text is empty,
+	// the default message for res's event is posted. If threadPerPull is
+	// true, the message is posted in the thread for res's pull request,
+	// starting it if this is the first message about the pull.
+	PostMessage(channel string, res Result, text string, threadPerPull bool) error
 }
 
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_underlying_slack_client.go UnderlyingSlackClient
@@ -47,6 +53,11 @@ type UnderlyingSlackClient interface {
 type DefaultSlackClient struct {
 	Slack UnderlyingSlackClient
 	Token string
+
+	// threads maps a channel and pull request to the timestamp of the first
+	// message about the pull in that channel, which is the thread's id.
+	threads   map[string]string
+	threadsMu sync.Mutex
 }
 
 func NewSlackClient(token string) SlackClient {
@@ -78,38 +89,69 @@ func (d *DefaultSlackClient) ChannelExists(channelName string) (bool, error) {
 	return false, nil
 }
 
-func (d *DefaultSlackClient) PostMessage(channel string, applyResult ApplyResult) error {
+func (d *DefaultSlackClient) PostMessage(channel string, result Result, text string, threadPerPull bool) error {
 	params := slack.NewPostMessageParameters()
-	params.Attachments = d.createAttachments(applyResult)
+	if text == "" {
+		params.Attachments = d.createAttachments(result)
+	}
 	params.EscapeText = false
-	_, _, err := d.Slack.PostMessage(channel, "", params)
-	return err
+
+	if !threadPerPull {
+		_, _, err := d.Slack.PostMessage(channel, text, params)
+		return err
+	}
+
+	// We hold the lock while posting so that two messages about a new pull
+	// don't both start a thread.
+	d.threadsMu.Lock()
+	defer d.threadsMu.Unlock()
+	if d.threads == nil {
+		d.threads = make(map[string]string)
+	}
+	key := fmt.Sprintf("%s/%s/%d", channel, result.Repo.FullName, result.Pull.Num)
+	params.ThreadTimestamp = d.threads[key]
+	_, timestamp, err := d.Slack.PostMessage(channel, text, params)
+	if err != nil {
+		return err
+	}
+	if params.ThreadTimestamp == "" {
+		d.threads[key] = timestamp
+	}
+	return nil
 }
 
-func (d *DefaultSlackClient) createAttachments(applyResult ApplyResult) []slack.Attachment {
+func (d *DefaultSlackClient) createAttachments(result Result) []slack.Attachment {
 	var colour string
-	var successWord string
-	if applyResult.Success {
-		colour = slackSuccessColour
-		successWord = "succeeded"
-	} else {
+	var text string
+	switch result.Event {
+	case LockEvent:
+		colour = slackInfoColour
+		text = fmt.Sprintf("Lock claimed on `%s` for <%s|%s>", result.Directory, result.Pull.URL, result.Repo.FullName)
+	case PlanErrorEvent:
+		colour = slackFailureColour
+		text = fmt.Sprintf("Plan failed for <%s|%s>", result.Pull.URL, result.Repo.FullName)
+	default:
+		successWord := "failed"
 		colour = slackFailureColour
-		successWord = "failed"
+		if result.Success {
+			colour = slackSuccessColour
+			successWord = "succeeded"
+		}
+		text = fmt.Sprintf("Apply %s for <%s|%s>", successWord, result.Pull.URL, result.Repo.FullName)
 	}
 
-	text := fmt.Sprintf("Apply %s for <%s|%s>", successWord, applyResult.Pull.URL, applyResult.Repo.FullName)
 	attachment := slack.Attachment{
 		Color: colour,
 		Text:  text,
 		Fields: []slack.AttachmentField{
 			{
 				Title: "Workspace",
-				Value: applyResult.Workspace,
+				Value: result.Workspace,
 				Short: true,
 			},
 			{
 				Title: "User",
-				Value: applyResult.User.Username,
+				Value: result.User.Username,
 				Short: true,
 			},
 		},
@@ -22,6 +22,7 @@ import (
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/events/webhooks"
 	"github.com/runatlantis/atlantis/server/events/webhooks/mocks"
+	"github.com/runatlantis/atlantis/server/events/webhooks/mocks/matchers"
 
 	. "github.com/petergtz/pegomock"
 	. "github.com/runatlantis/atlantis/testing"
@@ -29,7 +30,7 @@ import (
 
 var underlying *mocks.MockUnderlyingSlackClient
 var client webhooks.DefaultSlackClient
-var result webhooks.ApplyResult
+var result webhooks.Result
 
 func TestAuthTest_Success(t *testing.T) {
 	t.Log("When the underylying client succeeds, function should succeed")
@@ -115,7 +116,7 @@ func TestPostMessage_Success(t *testing.T) {
 	expParams.EscapeText = false
 
 	channel := "somechannel"
-	err := client.PostMessage(channel, result)
+	err := client.PostMessage(channel, result, "", false)
 	Ok(t, err)
 	underlying.VerifyWasCalledOnce().PostMessage(channel, "", expParams)
 
@@ -124,7 +125,7 @@ func TestPostMessage_Success(t *testing.T) {
 	expParams.Attachments[0].Color = "danger"
 	expParams.Attachments[0].Text = "Apply failed for <url|runatlantis/atlantis>"
 
-	err = client.PostMessage(channel, result)
+	err = client.PostMessage(channel, result, "", false)
 	Ok(t, err)
 	underlying.VerifyWasCalledOnce().PostMessage(channel, "", expParams)
 }
@@ -156,10 +157,61 @@ func TestPostMessage_Error(t *testing.T) {
 	channel := "somechannel"
 	When(underlying.PostMessage(channel, "", expParams)).ThenReturn("", "", errors.New(""))
 
-	err := client.PostMessage(channel, result)
+	err := client.PostMessage(channel, result, "", false)
 	Assert(t, err != nil, "expected error")
 }
 
+func TestPostMessage_OtherEvents(t *testing.T) {
+	t.Log("Lock and plan error events should have their own default messages")
+	setup(t)
+	channel := "somechannel"
+
+	result.Event = webhooks.LockEvent
+	result.Directory = "staging"
+	Ok(t, client.PostMessage(channel, result, "", false))
+	_, _, params := underlying.VerifyWasCalledOnce().PostMessage(AnyString(), AnyString(), matchers.AnySlackPostMessageParameters()).GetCapturedArguments()
+	Equals(t, "#439FE0", params.Attachments[0].Color)
+	Equals(t, "Lock claimed on `staging` for <url|runatlantis/atlantis>", params.Attachments[0].Text)
+
+	result.Event = webhooks.PlanErrorEvent
+	result.Success = false
+	Ok(t, client.PostMessage(channel, result, "", false))
+	_, _, params = underlying.VerifyWasCalled(Times(2)).PostMessage(AnyString(), AnyString(), matchers.AnySlackPostMessageParameters()).GetCapturedArguments()
+	Equals(t, "danger", params.Attachments[0].Color)
+	Equals(t, "Plan failed for <url|runatlantis/atlantis>", params.Attachments[0].Text)
+}
+
+func TestPostMessage_Text(t *testing.T) {
+	t.Log("When given text, it should be posted instead of the default message")
+	setup(t)
+	expParams := slack.NewPostMessageParameters()
+	expParams.EscapeText = false
+
+	channel := "somechannel"
+	Ok(t, client.PostMessage(channel, result, "applied!", false))
+	underlying.VerifyWasCalledOnce().PostMessage(channel, "applied!", expParams)
+}
+
+func TestPostMessage_ThreadPerPull(t *testing.T) {
+	t.Log("Messages about the same pull should be posted in one thread")
+	setup(t)
+	channel := "somechannel"
+	first := slack.NewPostMessageParameters()
+	first.EscapeText = false
+	When(underlying.PostMessage(channel, "first", first)).ThenReturn(channel, "1234.5", nil)
+
+	Ok(t, client.PostMessage(channel, result, "first", true))
+	Ok(t, client.PostMessage(channel, result, "second", true))
+	threaded := first
+	threaded.ThreadTimestamp = "1234.5"
+	underlying.VerifyWasCalledOnce().PostMessage(channel, "second", threaded)
+
+	t.Log("A different pull should start its own thread")
+	result.Pull.Num = 2
+	Ok(t, client.PostMessage(channel, result, "first", true))
+	underlying.VerifyWasCalled(Times(2)).PostMessage(channel, "first", first)
+}
+
 func setup(t *testing.T) {
 	RegisterMockTestingT(t)
 	underlying = mocks.NewMockUnderlyingSlackClient()
@@ -167,7 +219,7 @@ func setup(t *testing.T) {
 		Slack: underlying,
 		Token: "sometoken",
 	}
-	result = webhooks.ApplyResult{
+	result = webhooks.Result{
 		Workspace: "production",
 		Repo: models.Repo{
 			FullName: "runatlantis/atlantis",
@@ -16,8 +16,10 @@ package webhooks_test
 import (
 	"regexp"
 	"testing"
+	"text/template"
 
 	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/events/webhooks"
 	"github.com/runatlantis/atlantis/server/events/webhooks/mocks"
 	"github.com/runatlantis/atlantis/server/logging"
@@ -37,13 +39,14 @@ func TestSend_PostMessage(t *testing.T) {
 		WorkspaceRegex: regex,
 		Channel:        channel,
 	}
-	result := webhooks.ApplyResult{
+	result := webhooks.Result{
+		Event:     webhooks.ApplyEvent,
 		Workspace: "production",
 	}
 
 	t.Log("PostMessage should be called, doesn't matter if it errors or not")
 	_ = hook.Send(logging.NewNoopLogger(), result)
-	client.VerifyWasCalledOnce().PostMessage(channel, result)
+	client.VerifyWasCalledOnce().PostMessage(channel, result, "", false)
 }
 
 func TestSend_NoopSuccess(t *testing.T) {
@@ -59,10 +62,58 @@ func TestSend_NoopSuccess(t *testing.T) {
 		WorkspaceRegex: regex,
 		Channel:        channel,
 	}
-	result := webhooks.ApplyResult{
+	result := webhooks.Result{
+		Event:     webhooks.ApplyEvent,
 		Workspace: "production",
 	}
 	err = hook.Send(logging.NewNoopLogger(), result)
 	Ok(t, err)
-	client.VerifyWasCalled(Never()).PostMessage(channel, result)
+	client.VerifyWasCalled(Never()).PostMessage(channel, result, "", false)
+}
+
+func TestSend_OnlyMatchingEventAndRepo(t *testing.T) {
+	t.Log("Only results for the webhook's event and matching repos should be sent")
+	RegisterMockTestingT(t)
+	client := mocks.NewMockSlackClient()
+	channel := "somechannel"
+	hook := webhooks.SlackWebhook{
+		Client:         client,
+		WorkspaceRegex: regexp.MustCompile(".*"),
+		RepoRegex:      regexp.MustCompile("^runatlantis/"),
+		Channel:        channel,
+		Event:          webhooks.LockEvent,
+	}
+
+	otherEvent := webhooks.Result{Event: webhooks.ApplyEvent, Repo: models.Repo{FullName: "runatlantis/atlantis"}}
+	otherRepo := webhooks.Result{Event: webhooks.LockEvent, Repo: models.Repo{FullName: "lkysow/atlantis"}}
+	matching := webhooks.Result{Event: webhooks.LockEvent, Repo: models.Repo{FullName: "runatlantis/atlantis"}}
+	for _, result := range []webhooks.Result{otherEvent, otherRepo, matching} {
+		Ok(t, hook.Send(logging.NewNoopLogger(), result))
+	}
+	client.VerifyWasCalled(Never()).PostMessage(channel, otherEvent, "", false)
+	client.VerifyWasCalled(Never()).PostMessage(channel, otherRepo, "", false)
+	client.VerifyWasCalledOnce().PostMessage(channel, matching, "", false)
+}
+
+func TestSend_Template(t *testing.T) {
+	t.Log("The template should be rendered with the result and posted in its thread")
+	RegisterMockTestingT(t)
+	client := mocks.NewMockSlackClient()
+	channel := "somechannel"
+	hook := webhooks.SlackWebhook{
+		Client:         client,
+		WorkspaceRegex: regexp.MustCompile(".*"),
+		Channel:        channel,
+		Event:          webhooks.PlanErrorEvent,
+		Template:       template.Must(template.New("").Parse("{{.User.Username}} broke {{.Directory}}: {{.Error}}")),
+		ThreadPerPull:  true,
+	}
+	result := webhooks.Result{
+		Event:     webhooks.PlanErrorEvent,
+		Directory: "staging",
+		User:      models.User{Username: "lkysow"},
+		Error:     "exit status 1",
+	}
+	Ok(t, hook.Send(logging.NewNoopLogger(), result))
+	client.VerifyWasCalledOnce().PostMessage(channel, result, "lkysow broke staging: exit status 1", true)
 }
@@ -16,6 +16,7 @@ package webhooks
 import (
 	"fmt"
 	"regexp"
+	"text/template"
 
 	"errors"
 
@@ -24,23 +25,46 @@ import (
 )
 
 const SlackKind = "slack"
-const ApplyEvent = "apply"
+
+const (
+	// ApplyEvent is sent after every apply, whether it succeeded or not.
+	ApplyEvent = "apply"
+	// PlanErrorEvent is sent when a plan errors.
+	PlanErrorEvent = "plan-error"
+	// LockEvent is sent when a pull claims a project's lock.
+	LockEvent = "lock"
+)
+
+// events is the list of events webhooks can be sent for.
+var events = []string{ApplyEvent, PlanErrorEvent, LockEvent}
 
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_sender.go Sender
 
 // Sender sends webhooks.
 type Sender interface {
 	// Send sends the webhook (if the implementation thinks it should).
-	Send(log *logging.SimpleLogger, applyResult ApplyResult) error
+	Send(log *logging.SimpleLogger, res Result) error
 }
 
-// ApplyResult is the result of a terraform apply.
-type ApplyResult struct {
+// Result describes an event we might send webhooks for. It's also what
+// message templates are rendered with.
+type Result struct {
+	// Event is the event that happened, ex. ApplyEvent.
+	Event string
+	// Workspace is the Terraform workspace of the project.
 	Workspace string
-	Repo      models.Repo
-	Pull      models.PullRequest
-	User      models.User
-	Success   bool
+	// Directory is the path of the project relative to the repo root.
+	Directory string
+	// ProjectName is the project's name from atlantis.yaml, if it has one.
+	ProjectName string
+	Repo        models.Repo
+	Pull        models.PullRequest
+	User        models.User
+	// Success is false if the apply or plan failed. It's always true for
+	// LockEvent.
+	Success bool
+	// Error is why the apply or plan failed, if it did.
+	Error string
 }
 
 // MultiWebhookSender sends multiple webhooks for each one it's configured for.
@@ -48,11 +72,22 @@ type MultiWebhookSender struct {
 	Webhooks []Sender
 }
 
+// Config configures a webhook.
 type Config struct {
-	Event          string
+	// Event is the event to send the webhook for, ex. ApplyEvent.
+	Event string
+	// WorkspaceRegex and RepoRegex must match the project's workspace and
+	// repo full name for the webhook to be sent. Empty regexes match
+	// everything.
 	WorkspaceRegex string
+	RepoRegex      string
 	Kind           string
 	Channel        string
+	// Template is a text/template used to render the message, with a Result
+	// as its data. If empty, the default message is sent.
+	Template string
+	// ThreadPerPull groups the messages for each pull request into a thread.
+	ThreadPerPull bool
 }
 
 func NewMultiWebhookSender(configs []Config, client SlackClient) (*MultiWebhookSender, error) {
@@ -62,11 +97,22 @@ func NewMultiWebhookSender(configs []Config, client SlackClient) (*MultiWebhookS
 		if err != nil {
 			return nil, err
 		}
+		repoRegex, err := regexp.Compile(c.RepoRegex)
+		if err != nil {
+			return nil, err
+		}
 		if c.Kind == "" || c.Event == "" {
 			return nil, errors.New("must specify \"kind\" and \"event\" keys for webhooks")
 		}
-		if c.Event != ApplyEvent {
-			return nil, fmt.Errorf("\"event: %s\" not supported. Only \"event: %s\" is supported right now", c.Event, ApplyEvent)
+		if !isValidEvent(c.Event) {
+			return nil, fmt.Errorf("\"event: %s\" not supported. Supported events are %q", c.Event, events)
+		}
+		var tmpl *template.Template
+		if c.Template != "" {
+			tmpl, err = template.New(c.Event).Parse(c.Template)
+			if err != nil {
+				return nil, fmt.Errorf("parsing \"template\" of %q webhook: %s", c.Event, err)
+			}
 		}
 		switch c.Kind {
 		case SlackKind:
@@ -80,6 +126,10 @@ func NewMultiWebhookSender(configs []Config, client SlackClient) (*MultiWebhookS
 			if err != nil {
 				return nil, err
 			}
+			slack.Event = c.Event
+			slack.RepoRegex = repoRegex
+			slack.Template = tmpl
+			slack.ThreadPerPull = c.ThreadPerPull
 			webhooks = append(webhooks, slack)
 		default:
 			return nil, fmt.Errorf("\"kind: %s\" not supported. Only \"kind: %s\" is supported right now", c.Kind, SlackKind)
@@ -92,7 +142,7 @@ func NewMultiWebhookSender(configs []Config, client SlackClient) (*MultiWebhookS
 }
 
 // Send sends the webhook using its Webhooks.
-func (w *MultiWebhookSender) Send(log *logging.SimpleLogger, result ApplyResult) error {
+func (w *MultiWebhookSender) Send(log *logging.SimpleLogger, result Result) error {
 	for _, w := range w.Webhooks {
 		if err := w.Send(log, result); err != nil {
 			log.Warn("error sending slack webhook: %s", err)
@@ -100,3 +150,12 @@ func (w *MultiWebhookSender) Send(log *logging.SimpleLogger, result ApplyResult)
 	}
 	return nil
 }
+
+func isValidEvent(event string) bool {
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
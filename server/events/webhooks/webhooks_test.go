@@ -56,6 +56,26 @@ func TestNewWebhooksManager_InvalidRegex(t *testing.T) {
 	Assert(t, strings.Contains(err.Error(), "error parsing regexp"), "expected regex error")
 }
 
+func TestNewWebhooksManager_InvalidRepoRegex(t *testing.T) {
+	t.Log("When given an invalid repo regex in a config, an error is returned")
+	RegisterMockTestingT(t)
+	client := mocks.NewMockSlackClient()
+	configs := validConfigs()
+	configs[0].RepoRegex = "("
+	_, err := webhooks.NewMultiWebhookSender(configs, client)
+	ErrContains(t, "error parsing regexp", err)
+}
+
+func TestNewWebhooksManager_InvalidTemplate(t *testing.T) {
+	t.Log("When given an invalid template in a config, an error is returned")
+	RegisterMockTestingT(t)
+	client := mocks.NewMockSlackClient()
+	configs := validConfigs()
+	configs[0].Template = "{{.Workspace"
+	_, err := webhooks.NewMultiWebhookSender(configs, client)
+	ErrContains(t, "parsing \"template\" of \"apply\" webhook", err)
+}
+
 func TestNewWebhooksManager_NoEvent(t *testing.T) {
 	t.Log("When the event key is not specified in a config, an error is returned")
 	RegisterMockTestingT(t)
@@ -78,7 +98,7 @@ func TestNewWebhooksManager_UnsupportedEvent(t *testing.T) {
 	configs[0].Event = unsupportedEvent
 	_, err := webhooks.NewMultiWebhookSender(configs, client)
 	Assert(t, err != nil, "expected error")
-	Equals(t, "\"event: badevent\" not supported. Only \"event: apply\" is supported right now", err.Error())
+	Equals(t, "\"event: badevent\" not supported. Supported events are [\"apply\" \"plan-error\" \"lock\"]", err.Error())
 }
 
 func TestNewWebhooksManager_NoKind(t *testing.T) {
@@ -162,7 +182,7 @@ func TestSend_SingleSuccess(t *testing.T) {
 		Webhooks: []webhooks.Sender{sender},
 	}
 	logger := logging.NewNoopLogger()
-	result := webhooks.ApplyResult{}
+	result := webhooks.Result{}
 	manager.Send(logger, result) // nolint: errcheck
 	sender.VerifyWasCalledOnce().Send(logger, result)
 }
@@ -179,7 +199,7 @@ func TestSend_MultipleSuccess(t *testing.T) {
 		Webhooks: []webhooks.Sender{senders[0], senders[1], senders[2]},
 	}
 	logger := logging.NewNoopLogger()
-	result := webhooks.ApplyResult{}
+	result := webhooks.Result{}
 	err := manager.Send(logger, result)
 	Ok(t, err)
 	for _, s := range senders {
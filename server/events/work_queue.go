@@ -0,0 +1,234 @@
+package events
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/workqueue"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// DefaultWorkQueuePollInterval is how often idle workers check the queue for
+// work.
+const DefaultWorkQueuePollInterval = time.Second
+
+// QueuedCommandRunner is a CommandRunner that enqueues commands in Queue for
+// WorkQueueWorkers, possibly in other processes, to run rather than running
+// them itself.
+type QueuedCommandRunner struct {
+	Queue  workqueue.Store
+	Logger *logging.SimpleLogger
+}
+
+// RunAutoplanCommand enqueues an autoplan.
+func (q *QueuedCommandRunner) RunAutoplanCommand(baseRepo models.Repo, headRepo models.Repo, pull models.PullRequest, user models.User) {
+	q.enqueue(models.WorkItem{
+		Kind:     models.AutoplanWorkItem,
+		BaseRepo: baseRepo,
+		HeadRepo: &headRepo,
+		Pull:     &pull,
+		User:     user,
+		PullNum:  pull.Num,
+	})
+}
+
+// RunCommentCommand enqueues a comment command.
+func (q *QueuedCommandRunner) RunCommentCommand(baseRepo models.Repo, maybeHeadRepo *models.Repo, maybePull *models.PullRequest, user models.User, pullNum int, cmd *CommentCommand) {
+	serialized, err := json.Marshal(cmd)
+	if err != nil {
+		q.Logger.Err("unable to serialize %s command for %s#%d: %s", cmd.Name.String(), baseRepo.FullName, pullNum, err)
+		return
+	}
+	q.enqueue(models.WorkItem{
+		Kind:     models.CommentWorkItem,
+		BaseRepo: baseRepo,
+		HeadRepo: maybeHeadRepo,
+		Pull:     maybePull,
+		User:     user,
+		PullNum:  pullNum,
+		Command:  serialized,
+	})
+}
+
+func (q *QueuedCommandRunner) enqueue(item models.WorkItem) {
+	item.EnqueuedAt = time.Now()
+	item.ID = workqueue.NewID(item.EnqueuedAt)
+	if err := q.Queue.EnqueueWork(item); err != nil {
+		q.Logger.Err("unable to enqueue %s for %s#%d: %s", item.Kind, item.BaseRepo.FullName, item.PullNum, err)
+		return
+	}
+	q.Logger.Debug("enqueued %s for %s#%d as %s", item.Kind, item.BaseRepo.FullName, item.PullNum, item.ID)
+}
+
+// WorkQueueWorkers claim the items in Queue and run them with CommandRunner.
+// A pull's items are run one at a time in the order they were enqueued and
+// items for different pulls are run in parallel by up to Count workers.
+type WorkQueueWorkers struct {
+	Queue         workqueue.Store
+	CommandRunner CommandRunner
+	// Name identifies this process's workers in the queue. It must be unique
+	// to the process. If it stays the same when the process restarts, ex. its
+	// hostname in a StatefulSet, the items it was running when it stopped are
+	// deleted straight away rather than once their claims expire.
+	Name         string
+	Count        int
+	PollInterval time.Duration
+	// ClaimTimeout is how long the claim on an item lasts without being
+	// renewed. Workers renew the claims on the items they're running every
+	// third of it, so an item whose claim expires was being run by a worker
+	// that was killed and may never restart, ex. a pod that was scaled away.
+	// Those items are deleted so that their pull's other items can run. If 0,
+	// claims never expire.
+	ClaimTimeout time.Duration
+	Logger       *logging.SimpleLogger
+
+	stop chan struct{}
+	done sync.WaitGroup
+}
+
+// Start starts the workers. Items that were claimed by Name are deleted
+// rather than run again since they were interrupted when this process
+// stopped, and rerunning them, ex. an apply, isn't safe. Items whose claims
+// expire are deleted for the same reason.
+func (w *WorkQueueWorkers) Start() {
+	w.dropInterrupted()
+	w.stop = make(chan struct{})
+	if w.ClaimTimeout > 0 {
+		w.done.Add(1)
+		go func() {
+			defer w.done.Done()
+			for {
+				select {
+				case <-w.stop:
+					return
+				case <-time.After(w.ClaimTimeout):
+					w.DropExpired()
+				}
+			}
+		}()
+	}
+	for i := 0; i < w.Count; i++ {
+		w.done.Add(1)
+		go func() {
+			defer w.done.Done()
+			for {
+				if !w.RunOnce() {
+					select {
+					case <-w.stop:
+						return
+					case <-time.After(w.PollInterval):
+					}
+				}
+				select {
+				case <-w.stop:
+					return
+				default:
+				}
+			}
+		}()
+	}
+}
+
+// Stop stops the workers from claiming more items. It doesn't wait for the
+// items being run; they're waited for by the CommandRunner's Drainer.
+func (w *WorkQueueWorkers) Stop() {
+	close(w.stop)
+}
+
+// RunOnce claims an item and runs it. It returns false if there was nothing to
+// claim.
+func (w *WorkQueueWorkers) RunOnce() bool {
+	item, err := w.Queue.ClaimWork(w.Name)
+	if err != nil {
+		w.Logger.Err("unable to claim work: %s", err)
+		return false
+	}
+	if item == nil {
+		return false
+	}
+	w.Logger.Debug("running %s for %s#%d enqueued at %s", item.Kind, item.BaseRepo.FullName, item.PullNum, item.EnqueuedAt)
+	stopRenewing := w.renewClaim(item.ID)
+	w.run(*item)
+	stopRenewing()
+	if err := w.Queue.CompleteWork(item.ID); err != nil {
+		w.Logger.Err("unable to complete work item %s: %s", item.ID, err)
+	}
+	return true
+}
+
+// renewClaim renews the claim on the item with id every third of
+// ClaimTimeout until the returned func is called.
+func (w *WorkQueueWorkers) renewClaim(id string) func() {
+	if w.ClaimTimeout <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(w.ClaimTimeout / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := w.Queue.RenewWork(id, w.Name); err != nil {
+					w.Logger.Warn("unable to renew the claim on work item %s: %s", id, err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func (w *WorkQueueWorkers) run(item models.WorkItem) {
+	switch item.Kind {
+	case models.AutoplanWorkItem:
+		if item.HeadRepo == nil || item.Pull == nil {
+			w.Logger.Err("work item %s is an autoplan without a pull", item.ID)
+			return
+		}
+		w.CommandRunner.RunAutoplanCommand(item.BaseRepo, *item.HeadRepo, *item.Pull, item.User)
+	case models.CommentWorkItem:
+		var cmd CommentCommand
+		if err := json.Unmarshal(item.Command, &cmd); err != nil {
+			w.Logger.Err("unable to deserialize the command of work item %s: %s", item.ID, err)
+			return
+		}
+		w.CommandRunner.RunCommentCommand(item.BaseRepo, item.HeadRepo, item.Pull, item.User, item.PullNum, &cmd)
+	default:
+		w.Logger.Err("work item %s has unknown kind %q", item.ID, item.Kind)
+	}
+}
+
+// dropInterrupted deletes the items claimed by Name.
+func (w *WorkQueueWorkers) dropInterrupted() {
+	items, err := w.Queue.ListWork()
+	if err != nil {
+		w.Logger.Err("unable to list work to find interrupted items: %s", err)
+		return
+	}
+	for _, item := range items {
+		if item.ClaimedBy != w.Name {
+			continue
+		}
+		w.Logger.Warn("not rerunning %s for %s#%d since it was interrupted when this process stopped", item.Kind, item.BaseRepo.FullName, item.PullNum)
+		if err := w.Queue.CompleteWork(item.ID); err != nil {
+			w.Logger.Err("unable to delete interrupted work item %s: %s", item.ID, err)
+		}
+	}
+}
+
+// DropExpired deletes the items whose claims have expired. Start's workers
+// call it every ClaimTimeout.
+func (w *WorkQueueWorkers) DropExpired() {
+	dropped, err := w.Queue.DropExpiredWork(w.ClaimTimeout)
+	if err != nil {
+		w.Logger.Err("unable to delete expired work items: %s", err)
+		return
+	}
+	for _, item := range dropped {
+		w.Logger.Warn("not rerunning %s for %s#%d since %s stopped renewing its claim at %s", item.Kind, item.BaseRepo.FullName, item.PullNum, item.ClaimedBy, item.ClaimedAt)
+	}
+}
@@ -0,0 +1,163 @@
+package events_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/locking/boltdb"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/workqueue"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func newWorkQueue(t *testing.T) (*boltdb.BoltLocker, func()) {
+	dataDir, cleanup := TempDir(t)
+	queue, err := boltdb.New(dataDir)
+	Ok(t, err)
+	return queue, cleanup
+}
+
+// Commands enqueued by the QueuedCommandRunner should be run by the workers
+// with the same arguments.
+func TestWorkQueueWorkers_RunsQueuedCommands(t *testing.T) {
+	RegisterMockTestingT(t)
+	queue, cleanup := newWorkQueue(t)
+	defer cleanup()
+	runner := mocks.NewMockCommandRunner()
+	queued := &events.QueuedCommandRunner{Queue: queue, Logger: logging.NewNoopLogger()}
+	workers := &events.WorkQueueWorkers{
+		Queue:         queue,
+		CommandRunner: runner,
+		Name:          "worker",
+		Logger:        logging.NewNoopLogger(),
+	}
+
+	baseRepo := models.Repo{FullName: "owner/repo"}
+	pull := models.PullRequest{Num: 1}
+	user := models.User{Username: "user"}
+	cmd := &events.CommentCommand{Name: events.ApplyCommand, RepoRelDir: "dir", Workspace: "staging", Flags: []string{"-no-color"}}
+	queued.RunAutoplanCommand(baseRepo, baseRepo, pull, user)
+	queued.RunCommentCommand(baseRepo, nil, nil, user, 1, cmd)
+
+	Assert(t, workers.RunOnce(), "exp the autoplan to be run")
+	runner.VerifyWasCalledOnce().RunAutoplanCommand(baseRepo, baseRepo, pull, user)
+	Assert(t, workers.RunOnce(), "exp the comment command to be run")
+	runner.VerifyWasCalledOnce().RunCommentCommand(baseRepo, nil, nil, user, 1, cmd)
+	Assert(t, !workers.RunOnce(), "exp nothing left to run")
+
+	items, err := queue.ListWork()
+	Ok(t, err)
+	Equals(t, 0, len(items))
+}
+
+// Items that were claimed by this process when it stopped shouldn't be run
+// again, but other workers' items should be left alone.
+func TestWorkQueueWorkers_DropsInterruptedItems(t *testing.T) {
+	RegisterMockTestingT(t)
+	queue, cleanup := newWorkQueue(t)
+	defer cleanup()
+	baseRepo := models.Repo{FullName: "owner/repo"}
+	Ok(t, queue.EnqueueWork(models.WorkItem{ID: "1", Kind: models.AutoplanWorkItem, BaseRepo: baseRepo, PullNum: 1}))
+	Ok(t, queue.EnqueueWork(models.WorkItem{ID: "2", Kind: models.AutoplanWorkItem, BaseRepo: baseRepo, PullNum: 2}))
+	claimed, err := queue.ClaimWork("worker")
+	Ok(t, err)
+	Equals(t, "1", claimed.ID)
+	claimed, err = queue.ClaimWork("other-worker")
+	Ok(t, err)
+	Equals(t, "2", claimed.ID)
+
+	runner := mocks.NewMockCommandRunner()
+	workers := &events.WorkQueueWorkers{
+		Queue:         queue,
+		CommandRunner: runner,
+		Name:          "worker",
+		PollInterval:  time.Millisecond,
+		Logger:        logging.NewNoopLogger(),
+	}
+	workers.Start()
+	workers.Stop()
+
+	items, err := queue.ListWork()
+	Ok(t, err)
+	Equals(t, 1, len(items))
+	Equals(t, "2", items[0].ID)
+	runner.VerifyWasCalled(Never()).RunAutoplanCommand(matchers.AnyModelsRepo(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyModelsUser())
+}
+
+// Items whose claims have expired were being run by workers that were killed
+// so they should be deleted, unblocking the pull's other items.
+func TestWorkQueueWorkers_DropsExpiredItems(t *testing.T) {
+	RegisterMockTestingT(t)
+	queue, cleanup := newWorkQueue(t)
+	defer cleanup()
+	baseRepo := models.Repo{FullName: "owner/repo"}
+	Ok(t, queue.EnqueueWork(models.WorkItem{ID: "1", Kind: models.AutoplanWorkItem, BaseRepo: baseRepo, PullNum: 1}))
+	Ok(t, queue.EnqueueWork(models.WorkItem{ID: "2", Kind: models.AutoplanWorkItem, BaseRepo: baseRepo, PullNum: 1}))
+	Ok(t, queue.EnqueueWork(models.WorkItem{ID: "3", Kind: models.AutoplanWorkItem, BaseRepo: baseRepo, PullNum: 2}))
+	_, err := queue.ClaimWork("dead-worker")
+	Ok(t, err)
+	_, err = queue.ClaimWork("live-worker")
+	Ok(t, err)
+
+	workers := &events.WorkQueueWorkers{
+		Queue:         queue,
+		CommandRunner: mocks.NewMockCommandRunner(),
+		Name:          "worker",
+		ClaimTimeout:  50 * time.Millisecond,
+		Logger:        logging.NewNoopLogger(),
+	}
+	time.Sleep(100 * time.Millisecond)
+	Ok(t, queue.RenewWork("3", "live-worker"))
+	workers.DropExpired()
+
+	items, err := queue.ListWork()
+	Ok(t, err)
+	Equals(t, 2, len(items))
+	Equals(t, "2", items[0].ID)
+	Equals(t, "3", items[1].ID)
+	claimed, err := queue.ClaimWork("worker")
+	Ok(t, err)
+	Equals(t, "2", claimed.ID)
+}
+
+// autoplanFuncRunner is a CommandRunner whose autoplans call autoplan.
+type autoplanFuncRunner struct {
+	autoplan func()
+}
+
+func (a autoplanFuncRunner) RunCommentCommand(models.Repo, *models.Repo, *models.PullRequest, models.User, int, *events.CommentCommand) {
+}
+
+func (a autoplanFuncRunner) RunAutoplanCommand(models.Repo, models.Repo, models.PullRequest, models.User) {
+	a.autoplan()
+}
+
+// The claims on the items being run should be renewed so they don't expire.
+func TestWorkQueueWorkers_RenewsClaims(t *testing.T) {
+	queue, cleanup := newWorkQueue(t)
+	defer cleanup()
+	timeout := 30 * time.Millisecond
+	var expired bool
+	workers := &events.WorkQueueWorkers{
+		Queue: queue,
+		CommandRunner: autoplanFuncRunner{func() {
+			time.Sleep(100 * time.Millisecond)
+			items, err := queue.ListWork()
+			Ok(t, err)
+			expired = workqueue.ClaimExpired(items[0], timeout, time.Now())
+		}},
+		Name:         "worker",
+		ClaimTimeout: timeout,
+		Logger:       logging.NewNoopLogger(),
+	}
+	baseRepo := models.Repo{FullName: "owner/repo"}
+	(&events.QueuedCommandRunner{Queue: queue, Logger: logging.NewNoopLogger()}).RunAutoplanCommand(baseRepo, baseRepo, models.PullRequest{Num: 1}, models.User{})
+
+	Assert(t, workers.RunOnce(), "exp the autoplan to be run")
+	Assert(t, !expired, "exp the claim to be renewed while the autoplan ran")
+}
@@ -0,0 +1,94 @@
+package events
+
+import (
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+)
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_workflow_hook_runner.go WorkflowHookRunner
+
+// WorkflowHookRunner runs a single pre or post-workflow hook.
+type WorkflowHookRunner interface {
+	// Run runs command in path and returns its output.
+	Run(ctx models.WorkflowHookCommandContext, command string, path string) (string, error)
+}
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_workflow_hooks_runner.go WorkflowHooksRunner
+
+// WorkflowHooksRunner runs the pre and post-workflow hooks configured for a
+// repo in the server-side repo config around each command.
+type WorkflowHooksRunner interface {
+	// RunPreHooks runs the pre-workflow hooks for ctx's repo before cmdName
+	// works out which projects to run in. It returns an error if any of them
+	// fail.
+	RunPreHooks(ctx *CommandContext, cmdName CommandName) error
+	// RunPostHooks runs the post-workflow hooks for ctx's repo after cmdName
+	// has finished with res. Failures are only logged since the command's
+	// results have already been commented.
+	RunPostHooks(ctx *CommandContext, cmdName CommandName, res CommandResult)
+}
+
+// DefaultWorkflowHooksRunner implements WorkflowHooksRunner. Hooks are run
+// in the root of the pull request's clone for the default workspace, in the
+// order they're configured.
+type DefaultWorkflowHooksRunner struct {
+	ServerConfig     valid.ServerConfig
+	WorkingDir       WorkingDir
+	WorkingDirLocker WorkingDirLocker
+	HookRunner       WorkflowHookRunner
+}
+
+func (w *DefaultWorkflowHooksRunner) RunPreHooks(ctx *CommandContext, cmdName CommandName) error {
+	repoCfg := w.ServerConfig.FindRepo(ctx.BaseRepo.FullName, ctx.BaseRepo.VCSHost.Hostname)
+	if repoCfg == nil || len(repoCfg.PreWorkflowHooks) == 0 {
+		return nil
+	}
+	hookCtx := w.hookCtx(ctx, cmdName, false)
+	hookCtx.CancelCtx = ctx.CancelCtx
+	return errors.Wrap(w.runHooks(ctx, repoCfg.PreWorkflowHooks, hookCtx), "running pre-workflow hooks")
+}
+
+func (w *DefaultWorkflowHooksRunner) RunPostHooks(ctx *CommandContext, cmdName CommandName, res CommandResult) {
+	repoCfg := w.ServerConfig.FindRepo(ctx.BaseRepo.FullName, ctx.BaseRepo.VCSHost.Hostname)
+	if repoCfg == nil || len(repoCfg.PostWorkflowHooks) == 0 {
+		return
+	}
+	if err := w.runHooks(ctx, repoCfg.PostWorkflowHooks, w.hookCtx(ctx, cmdName, res.HasErrors())); err != nil {
+		ctx.Log.Warn("running post-workflow hooks: %s", err)
+	}
+}
+
+// runHooks runs hooks one after the other, stopping at the first that fails.
+func (w *DefaultWorkflowHooksRunner) runHooks(ctx *CommandContext, hooks []valid.WorkflowHook, hookCtx models.WorkflowHookCommandContext) error {
+	unlockFn, err := w.WorkingDirLocker.TryLock(ctx.BaseRepo.FullName, ctx.Pull.Num, DefaultWorkspace)
+	if err != nil {
+		return err
+	}
+	defer unlockFn()
+
+	repoDir, err := w.WorkingDir.Clone(ctx.Log, ctx.BaseRepo, ctx.HeadRepo, ctx.Pull, DefaultWorkspace)
+	if err != nil {
+		return err
+	}
+	for _, hook := range hooks {
+		out, err := w.HookRunner.Run(hookCtx, hook.RunCommand, repoDir)
+		if err != nil {
+			return err
+		}
+		ctx.Log.Debug("output of workflow hook %q: %s", hook.RunCommand, out)
+	}
+	return nil
+}
+
+func (w *DefaultWorkflowHooksRunner) hookCtx(ctx *CommandContext, cmdName CommandName, hasErrors bool) models.WorkflowHookCommandContext {
+	return models.WorkflowHookCommandContext{
+		BaseRepo:         ctx.BaseRepo,
+		HeadRepo:         ctx.HeadRepo,
+		Pull:             ctx.Pull,
+		User:             ctx.User,
+		Log:              ctx.Log,
+		CommandName:      cmdName.String(),
+		CommandHasErrors: hasErrors,
+	}
+}
@@ -0,0 +1,109 @@
+package events_test
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/models/fixtures"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestDefaultWorkflowHooksRunner(t *testing.T) {
+	RegisterMockTestingT(t)
+	workingDir := mocks.NewMockWorkingDir()
+	hookRunner := mocks.NewMockWorkflowHookRunner()
+	runner := events.DefaultWorkflowHooksRunner{
+		ServerConfig: valid.ServerConfig{
+			Repos: []valid.Repo{
+				{
+					ID:                "github.com/" + fixtures.GithubRepo.FullName,
+					PreWorkflowHooks:  []valid.WorkflowHook{{RunCommand: "./generate.sh"}, {RunCommand: "./validate.sh"}},
+					PostWorkflowHooks: []valid.WorkflowHook{{RunCommand: "./cleanup.sh"}},
+				},
+			},
+		},
+		WorkingDir:       workingDir,
+		WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
+		HookRunner:       hookRunner,
+	}
+	ctx := &events.CommandContext{
+		BaseRepo: fixtures.GithubRepo,
+		HeadRepo: fixtures.GithubRepo,
+		Pull:     fixtures.Pull,
+		User:     fixtures.User,
+		Log:      logging.NewNoopLogger(),
+	}
+	When(workingDir.Clone(matchers.AnyPtrToLoggingSimpleLogger(), matchers.AnyModelsRepo(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), AnyString())).
+		ThenReturn("/repo", nil)
+
+	Ok(t, runner.RunPreHooks(ctx, events.PlanCommand))
+	_, _, _, _, workspace := workingDir.VerifyWasCalledOnce().Clone(matchers.AnyPtrToLoggingSimpleLogger(), matchers.AnyModelsRepo(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), AnyString()).GetCapturedArguments()
+	Equals(t, events.DefaultWorkspace, workspace)
+	hookCtx, _, _ := hookRunner.VerifyWasCalledOnce().Run(matchers.AnyModelsWorkflowHookCommandContext(), EqString("./generate.sh"), EqString("/repo")).GetCapturedArguments()
+	Equals(t, "plan", hookCtx.CommandName)
+	Equals(t, fixtures.Pull.Num, hookCtx.Pull.Num)
+	hookRunner.VerifyWasCalledOnce().Run(matchers.AnyModelsWorkflowHookCommandContext(), EqString("./validate.sh"), EqString("/repo"))
+
+	runner.RunPostHooks(ctx, events.ApplyCommand, events.CommandResult{Error: errors.New("err")})
+	hookCtx, _, _ = hookRunner.VerifyWasCalledOnce().Run(matchers.AnyModelsWorkflowHookCommandContext(), EqString("./cleanup.sh"), EqString("/repo")).GetCapturedArguments()
+	Equals(t, "apply", hookCtx.CommandName)
+	Assert(t, hookCtx.CommandHasErrors, "exp CommandHasErrors to be set")
+}
+
+// If a hook fails, the rest shouldn't run.
+func TestDefaultWorkflowHooksRunner_HookErr(t *testing.T) {
+	RegisterMockTestingT(t)
+	workingDir := mocks.NewMockWorkingDir()
+	hookRunner := mocks.NewMockWorkflowHookRunner()
+	runner := events.DefaultWorkflowHooksRunner{
+		ServerConfig: valid.ServerConfig{
+			Repos: []valid.Repo{
+				{
+					ID:               "github.com/*",
+					PreWorkflowHooks: []valid.WorkflowHook{{RunCommand: "./generate.sh"}, {RunCommand: "./validate.sh"}},
+				},
+			},
+		},
+		WorkingDir:       workingDir,
+		WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
+		HookRunner:       hookRunner,
+	}
+	ctx := &events.CommandContext{
+		BaseRepo: fixtures.GithubRepo,
+		HeadRepo: fixtures.GithubRepo,
+		Pull:     fixtures.Pull,
+		Log:      logging.NewNoopLogger(),
+	}
+	When(workingDir.Clone(matchers.AnyPtrToLoggingSimpleLogger(), matchers.AnyModelsRepo(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), AnyString())).
+		ThenReturn("/repo", nil)
+	When(hookRunner.Run(matchers.AnyModelsWorkflowHookCommandContext(), EqString("./generate.sh"), AnyString())).
+		ThenReturn("", errors.New("exit status 1"))
+
+	ErrEquals(t, "running pre-workflow hooks: exit status 1", runner.RunPreHooks(ctx, events.PlanCommand))
+	hookRunner.VerifyWasCalled(Never()).Run(matchers.AnyModelsWorkflowHookCommandContext(), EqString("./validate.sh"), AnyString())
+}
+
+// Repos without hooks shouldn't be cloned.
+func TestDefaultWorkflowHooksRunner_NoHooks(t *testing.T) {
+	RegisterMockTestingT(t)
+	workingDir := mocks.NewMockWorkingDir()
+	runner := events.DefaultWorkflowHooksRunner{
+		WorkingDir:       workingDir,
+		WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
+		HookRunner:       mocks.NewMockWorkflowHookRunner(),
+	}
+	ctx := &events.CommandContext{
+		BaseRepo: fixtures.GithubRepo,
+		Pull:     fixtures.Pull,
+		Log:      logging.NewNoopLogger(),
+	}
+	Ok(t, runner.RunPreHooks(ctx, events.PlanCommand))
+	runner.RunPostHooks(ctx, events.PlanCommand, events.CommandResult{})
+	workingDir.VerifyWasCalled(Never()).Clone(matchers.AnyPtrToLoggingSimpleLogger(), matchers.AnyModelsRepo(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), AnyString())
+}
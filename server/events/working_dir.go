@@ -14,11 +14,14 @@
 package events
 
 import (
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/runatlantis/atlantis/server/events/models"
@@ -27,6 +30,22 @@ import (
 
 const workingDirPrefix = "repos"
 
+// mirrorsDirPrefix is the dir in the data dir that FileWorkspace keeps its
+// repo mirrors in when CloneCache is enabled.
+const mirrorsDirPrefix = "mirrors"
+
+// DefaultCloneRetryDelay is how long FileWorkspace waits before retrying a
+// failed clone the first time. The delay doubles on each retry.
+const DefaultCloneRetryDelay = time.Second
+
+// mergeBaseRef is where shallow clones fetch the repo's default branch to so
+// we can find the commit the pull request's branch was created from.
+const mergeBaseRef = "refs/atlantis/base"
+
+// maxMergeBaseDeepens is how many times a shallow clone's history is deepened
+// while looking for the merge base before we fetch the full history instead.
+const maxMergeBaseDeepens = 10
+
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_working_dir.go WorkingDir
 
 // WorkingDir handles the workspace on disk for running commands.
@@ -41,14 +60,46 @@ type WorkingDir interface {
 	// Delete deletes the workspace for this repo and pull.
 	Delete(r models.Repo, p models.PullRequest) error
 	DeleteForWorkspace(r models.Repo, p models.PullRequest, workspace string) error
+	// CheckoutDirs checks out dirs, relative to the root of the repo cloned
+	// into repoDir, and the local modules they call if the repo was cloned
+	// with a sparse checkout. Otherwise it does nothing.
+	CheckoutDirs(log *logging.SimpleLogger, repoDir string, dirs []string) error
 }
 
 // FileWorkspace implements WorkingDir with the file system.
+// Repos are cloned into <CloneRoot>/<repo full name>/<pull num>/<workspace>
+// so that each repo, pull and workspace combination is isolated on disk.
 type FileWorkspace struct {
 	DataDir string
+	// CloneRoot is the directory that repos are cloned into. If it's empty
+	// then repos are cloned into DataDir/repos.
+	CloneRoot string
 	// TestingOverrideCloneURL can be used during testing to override the URL
 	// that is cloned. If it's empty then we clone normally.
 	TestingOverrideCloneURL string
+	// CloneRetries is how many times a failed git clone is retried, ex.
+	// because of a network blip, before the command fails.
+	CloneRetries int
+	// CloneRetryDelay defaults to DefaultCloneRetryDelay.
+	CloneRetryDelay time.Duration
+	// CheckoutDepth, if greater than 0, makes clones shallow with only this
+	// many commits of the branch's history. The history is deepened until it
+	// has the merge base with the repo's default branch.
+	CheckoutDepth int
+	// SparseCheckout is true if clones should only check out the files at the
+	// root of the repo until CheckoutDirs is called with the dirs to run in.
+	SparseCheckout bool
+	// CloneCache is true if a bare mirror of each repo should be kept in
+	// DataDir/mirrors. The mirror is fetched into before each clone and the
+	// clone copies its objects from the mirror so only what's changed since
+	// the last clone is fetched over the network.
+	CloneCache bool
+
+	// cloneLocks serializes clones and sparse checkouts in the same directory
+	// so that concurrent events for the same pull request don't run git in
+	// the same dir.
+	cloneLocks     map[string]*sync.Mutex
+	cloneLocksLock sync.Mutex
 }
 
 // Clone git clones headRepo, checks out the branch and then returns the absolute
@@ -62,6 +113,8 @@ func (w *FileWorkspace) Clone(
 	p models.PullRequest,
 	workspace string) (string, error) {
 	cloneDir := w.cloneDir(baseRepo, p, workspace)
+	unlock := w.lockCloneDir(cloneDir)
+	defer unlock()
 
 	// If the directory already exists, check if it's at the right commit.
 	// If so, then we do nothing.
@@ -110,21 +163,186 @@ func (w *FileWorkspace) forceClone(log *logging.SimpleLogger,
 	if w.TestingOverrideCloneURL != "" {
 		cloneURL = w.TestingOverrideCloneURL
 	}
-	cloneCmd := exec.Command("git", "clone", cloneURL, cloneDir) // #nosec
-	if output, err := cloneCmd.CombinedOutput(); err != nil {
-		return "", errors.Wrapf(err, "cloning %s: %s", headRepo.SanitizedCloneURL, string(output))
+	delay := w.CloneRetryDelay
+	if delay == 0 {
+		delay = DefaultCloneRetryDelay
+	}
+	cloneArgs := []string{"clone"}
+	if w.CheckoutDepth > 0 {
+		// Shallow clones only fetch the default branch unless we ask for the
+		// pull request's.
+		cloneArgs = append(cloneArgs, "--depth", strconv.Itoa(w.CheckoutDepth))
+		if p.Branch != "" {
+			cloneArgs = append(cloneArgs, "--branch", p.Branch)
+		}
+	}
+	if w.SparseCheckout {
+		// Only fetch the contents of files when they're checked out.
+		cloneArgs = append(cloneArgs, "--filter=blob:none", "--sparse")
+	}
+	if w.CloneCache {
+		mirrorDir, err := w.updateMirror(log, headRepo, cloneURL)
+		if err != nil {
+			log.Warn("cloning without the clone cache since it couldn't be updated: %s", err)
+		} else {
+			// --dissociate copies the objects so the clone doesn't break
+			// if the mirror is deleted.
+			cloneArgs = append(cloneArgs, "--reference", mirrorDir, "--dissociate")
+		}
+	}
+	cloneArgs = append(cloneArgs, cloneURL, cloneDir)
+	for attempt := 0; ; attempt++ {
+		cloneCmd := exec.Command("git", cloneArgs...) // #nosec
+		output, err := cloneCmd.CombinedOutput()
+		if err == nil {
+			break
+		}
+		if attempt >= w.CloneRetries {
+			return "", errors.Wrapf(err, "cloning %s: %s", headRepo.SanitizedCloneURL, string(output))
+		}
+		log.Warn("git clone failed, retrying in %s: %s: %s", delay, err, string(output))
+		time.Sleep(delay)
+		delay *= 2
+		// git clone leaves the dir empty when it fails but a partial clone
+		// would fail the next attempt.
+		if err := os.RemoveAll(cloneDir); err != nil {
+			return "", errors.Wrapf(err, "deleting dir %q before retrying clone", cloneDir)
+		}
 	}
 
-	// Check out the branch for this PR.
+	// Check out the branch for this PR. The -- after it stops git reading it
+	// as a path, and a branch that looks like an option is refused.
+	if strings.HasPrefix(p.Branch, "-") {
+		return "", errors.Errorf("refusing to check out branch %q since it starts with -", p.Branch)
+	}
 	log.Info("checking out branch %q", p.Branch)
-	checkoutCmd := exec.Command("git", "checkout", p.Branch) // #nosec
+	checkoutCmd := exec.Command("git", "checkout", p.Branch, "--") // #nosec
 	checkoutCmd.Dir = cloneDir
 	if err := checkoutCmd.Run(); err != nil {
 		return "", errors.Wrapf(err, "checking out branch %s", p.Branch)
 	}
+	if w.CheckoutDepth > 0 {
+		w.fetchMergeBase(log, cloneDir)
+	}
 	return cloneDir, nil
 }
 
+// updateMirror fetches the branches of the repo at cloneURL into its mirror,
+// creating the mirror if this is the first clone of repo, and returns the
+// mirror's path.
+func (w *FileWorkspace) updateMirror(log *logging.SimpleLogger, repo models.Repo, cloneURL string) (string, error) {
+	mirrorDir := filepath.Join(w.DataDir, mirrorsDirPrefix, repo.VCSHost.Hostname, repo.FullName+".git")
+	// Pulls for the same repo share its mirror.
+	unlock := w.lockCloneDir(mirrorDir)
+	defer unlock()
+
+	if _, err := os.Stat(mirrorDir); os.IsNotExist(err) {
+		log.Info("creating clone cache for %q in %q", repo.SanitizedCloneURL, mirrorDir)
+		if out, err := runGitCmd("", "init", "--bare", mirrorDir); err != nil {
+			return "", errors.Wrapf(err, "creating mirror in %q: %s", mirrorDir, out)
+		}
+	}
+	// We fetch from the URL rather than saving it as the mirror's remote
+	// since its credentials can change, ex. GitHub App tokens expire.
+	log.Debug("fetching %q into clone cache", repo.SanitizedCloneURL)
+	if out, err := runGitCmd(mirrorDir, "fetch", "--prune", cloneURL, "+refs/heads/*:refs/heads/*"); err != nil {
+		return "", errors.Wrapf(err, "fetching %s into mirror: %s", repo.SanitizedCloneURL, out)
+	}
+	return mirrorDir, nil
+}
+
+// fetchMergeBase deepens the shallow clone in cloneDir until it has the commit
+// that the pull request's branch was created from so that it can be diffed
+// against the base, ex. by custom run steps. We assume the base is the repo's
+// default branch. Clones can still be planned without the merge base so we
+// only log failures.
+func (w *FileWorkspace) fetchMergeBase(log *logging.SimpleLogger, cloneDir string) {
+	depth := strconv.Itoa(w.CheckoutDepth)
+	refspec := "+HEAD:" + mergeBaseRef
+	if out, err := runGitCmd(cloneDir, "fetch", "--depth", depth, "origin", refspec); err != nil {
+		log.Warn("unable to fetch default branch to find merge base: %s: %s", err, out)
+		return
+	}
+	for i := 0; i < maxMergeBaseDeepens; i++ {
+		if _, err := runGitCmd(cloneDir, "merge-base", "HEAD", mergeBaseRef); err == nil {
+			return
+		}
+		log.Debug("merge base isn't in the last %d commits, deepening clone", w.CheckoutDepth*(i+1))
+		if out, err := runGitCmd(cloneDir, "fetch", "--deepen", depth, "origin", refspec); err != nil {
+			log.Warn("unable to deepen clone to find merge base: %s: %s", err, out)
+			return
+		}
+	}
+	log.Info("merge base not found after deepening clone %d times, fetching full history", maxMergeBaseDeepens)
+	if out, err := runGitCmd(cloneDir, "fetch", "--unshallow", "origin", refspec); err != nil {
+		log.Warn("unable to fetch full history to find merge base: %s: %s", err, out)
+	}
+}
+
+// CheckoutDirs adds dirs, and the local modules they call, to the sparse
+// checkout of the repo in repoDir. The modules' own modules are added too.
+func (w *FileWorkspace) CheckoutDirs(log *logging.SimpleLogger, repoDir string, dirs []string) error {
+	if !w.SparseCheckout {
+		return nil
+	}
+	// Projects in the same clone can run at the same time so their sparse
+	// checkouts are serialized like clones.
+	unlock := w.lockCloneDir(repoDir)
+	defer unlock()
+	added := make(map[string]bool)
+	pending := dirs
+	for len(pending) > 0 {
+		var batch []string
+		for _, dir := range pending {
+			dir = filepath.Clean(dir)
+			if !added[dir] {
+				added[dir] = true
+				batch = append(batch, dir)
+			}
+		}
+		if len(batch) == 0 {
+			break
+		}
+		// The files at the root are always checked out so there's nothing
+		// to add for it, but its modules still need to be.
+		var add []string
+		for _, dir := range batch {
+			if dir != "." {
+				add = append(add, dir)
+			}
+		}
+		if len(add) > 0 {
+			log.Debug("adding %v to sparse checkout", add)
+			if out, err := runGitCmd(repoDir, append([]string{"sparse-checkout", "add"}, add...)...); err != nil {
+				return errors.Wrapf(err, "adding %s to sparse checkout: %s", strings.Join(add, ", "), out)
+			}
+		}
+
+		pending = nil
+		for _, dir := range batch {
+			files, err := filepath.Glob(filepath.Join(repoDir, dir, "*.tf*"))
+			if err != nil {
+				return errors.Wrapf(err, "listing files in %q", dir)
+			}
+			for _, file := range files {
+				contents, err := ioutil.ReadFile(file)
+				if err != nil {
+					return errors.Wrapf(err, "reading %q", file)
+				}
+				pending = append(pending, parseModuleSources(contents, dir)...)
+			}
+		}
+	}
+	return nil
+}
+
+func runGitCmd(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...) // #nosec
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
 // GetWorkingDir returns the path to the workspace for this repo and pull.
 func (w *FileWorkspace) GetWorkingDir(r models.Repo, p models.PullRequest, workspace string) (string, error) {
 	repoDir := w.cloneDir(r, p, workspace)
@@ -151,11 +369,39 @@ func (w *FileWorkspace) Delete(r models.Repo, p models.PullRequest) error {
 
 // DeleteForWorkspace deletes the working dir for this workspace.
 func (w *FileWorkspace) DeleteForWorkspace(r models.Repo, p models.PullRequest, workspace string) error {
-	return os.RemoveAll(w.cloneDir(r, p, workspace))
+	cloneDir := w.cloneDir(r, p, workspace)
+	unlock := w.lockCloneDir(cloneDir)
+	defer unlock()
+	return os.RemoveAll(cloneDir)
+}
+
+// lockCloneDir blocks until no other clone is running for cloneDir and
+// returns a function that releases the lock.
+func (w *FileWorkspace) lockCloneDir(cloneDir string) func() {
+	w.cloneLocksLock.Lock()
+	if w.cloneLocks == nil {
+		w.cloneLocks = make(map[string]*sync.Mutex)
+	}
+	l, ok := w.cloneLocks[cloneDir]
+	if !ok {
+		l = &sync.Mutex{}
+		w.cloneLocks[cloneDir] = l
+	}
+	w.cloneLocksLock.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+func (w *FileWorkspace) cloneRoot() string {
+	if w.CloneRoot != "" {
+		return w.CloneRoot
+	}
+	return filepath.Join(w.DataDir, workingDirPrefix)
 }
 
 func (w *FileWorkspace) repoPullDir(r models.Repo, p models.PullRequest) string {
-	return filepath.Join(w.DataDir, workingDirPrefix, r.FullName, strconv.Itoa(p.Num))
+	return filepath.Join(w.cloneRoot(), r.FullName, strconv.Itoa(p.Num))
 }
 
 func (w *FileWorkspace) cloneDir(r models.Repo, p models.PullRequest, workspace string) string {
@@ -0,0 +1,273 @@
+package events
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/locking"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// WorkingDirGCInterval is how often WorkingDirGC runs once started.
+const WorkingDirGCInterval = time.Hour
+
+// WorkingDirGC deletes the working dirs, and so the plan files, of closed
+// pulls that haven't been used for TTL, then deletes the least recently used
+// working dirs until the data dir uses at most MaxBytes.
+//
+// Working dirs are normally deleted when their pull is closed so this cleans
+// up after closed events we missed or cleanups that failed. A pull is treated
+// as closed if it holds no locks since closing a pull deletes its locks.
+type WorkingDirGC struct {
+	WorkingDir       *FileWorkspace
+	WorkingDirLocker WorkingDirLocker
+	Locker           locking.Locker
+	// TTL is how long the working dirs of a pull without locks are kept after
+	// they were last used. If it's 0 they're only deleted to enforce MaxBytes.
+	TTL time.Duration
+	// MaxBytes is how much disk the data dir, including the clone root, can
+	// use. If it's 0 there's no limit.
+	MaxBytes int64
+	Logger   *logging.SimpleLogger
+
+	stop chan struct{}
+	done sync.WaitGroup
+}
+
+// GCResult is what a WorkingDirGC run deleted.
+type GCResult struct {
+	DeletedDirs    int
+	ReclaimedBytes int64
+}
+
+// gcClone is the working dir of one repo, pull and workspace.
+type gcClone struct {
+	repoFullName string
+	pullNum      int
+	workspace    string
+	path         string
+	size         int64
+	lastUsed     time.Time
+	hasLocks     bool
+}
+
+// Start runs the GC now and then every WorkingDirGCInterval until Stop is
+// called.
+func (g *WorkingDirGC) Start() {
+	g.stop = make(chan struct{})
+	g.done.Add(1)
+	go func() {
+		defer g.done.Done()
+		ticker := time.NewTicker(WorkingDirGCInterval)
+		defer ticker.Stop()
+		for {
+			g.Run()
+			select {
+			case <-g.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops the GC. It waits for a run that's in progress to finish.
+func (g *WorkingDirGC) Stop() {
+	close(g.stop)
+	g.done.Wait()
+}
+
+// Run deletes the working dirs that have expired or are over the disk budget
+// once and logs what was reclaimed.
+func (g *WorkingDirGC) Run() GCResult {
+	var result GCResult
+	clones, err := g.listClones()
+	if err != nil {
+		g.Logger.Err("listing working dirs to garbage collect: %s", err)
+		return result
+	}
+
+	var kept []gcClone
+	for _, c := range clones {
+		if g.TTL > 0 && !c.hasLocks && time.Since(c.lastUsed) > g.TTL {
+			if g.delete(c, fmt.Sprintf("the pull has no locks and it hasn't been used since %s", c.lastUsed.Format(time.RFC3339)), &result) {
+				continue
+			}
+		}
+		kept = append(kept, c)
+	}
+
+	if g.MaxBytes > 0 {
+		usage, err := g.diskUsage()
+		if err != nil {
+			g.Logger.Err("calculating data dir disk usage: %s", err)
+		} else {
+			usage -= result.ReclaimedBytes
+			// Delete the dirs of pulls without locks first since they have no
+			// plans waiting to be applied, then the least recently used.
+			sort.SliceStable(kept, func(i, j int) bool {
+				if kept[i].hasLocks != kept[j].hasLocks {
+					return !kept[i].hasLocks
+				}
+				return kept[i].lastUsed.Before(kept[j].lastUsed)
+			})
+			for _, c := range kept {
+				if usage <= g.MaxBytes {
+					break
+				}
+				reason := fmt.Sprintf("the data dir is using %d bytes, more than the %d allowed", usage, g.MaxBytes)
+				if c.hasLocks {
+					reason += "; the pull's plans will need to be run again"
+				}
+				if g.delete(c, reason, &result) {
+					usage -= c.size
+				}
+			}
+			if usage > g.MaxBytes {
+				g.Logger.Warn("data dir is still using %d bytes, more than the %d allowed, after deleting every working dir that isn't in use", usage, g.MaxBytes)
+			}
+		}
+	}
+
+	if result.DeletedDirs > 0 {
+		g.Logger.Info("garbage collected %d working dirs, reclaiming %d bytes", result.DeletedDirs, result.ReclaimedBytes)
+	}
+	return result
+}
+
+// delete deletes c unless a command is using it and returns true if it was
+// deleted.
+func (g *WorkingDirGC) delete(c gcClone, reason string, result *GCResult) bool {
+	unlock, err := g.WorkingDirLocker.TryLock(c.repoFullName, c.pullNum, c.workspace)
+	if err != nil {
+		g.Logger.Debug("not deleting working dir %s since it's in use", c.path)
+		return false
+	}
+	defer unlock()
+	err = g.WorkingDir.DeleteForWorkspace(
+		models.Repo{FullName: c.repoFullName},
+		models.PullRequest{Num: c.pullNum},
+		c.workspace)
+	if err != nil {
+		g.Logger.Err("deleting working dir %s: %s", c.path, err)
+		return false
+	}
+	// Remove the pull's dir once its last workspace is deleted.
+	pullDir := filepath.Dir(c.path)
+	if entries, err := ioutil.ReadDir(pullDir); err == nil && len(entries) == 0 {
+		os.Remove(pullDir) // nolint: errcheck
+	}
+	g.Logger.Info("deleted working dir %s (%d bytes) since %s", c.path, c.size, reason)
+	result.DeletedDirs++
+	result.ReclaimedBytes += c.size
+	return true
+}
+
+// listClones returns the working dirs in the clone root. They're at
+// <repo full name>/<pull num>/<workspace> and are git repos.
+func (g *WorkingDirGC) listClones() ([]gcClone, error) {
+	locks, err := g.Locker.List()
+	if err != nil {
+		return nil, errors.Wrap(err, "listing locks")
+	}
+	lockedPulls := make(map[string]bool)
+	for _, l := range locks {
+		lockedPulls[fmt.Sprintf("%s/%d", l.Project.RepoFullName, l.Pull.Num)] = true
+	}
+
+	root := g.WorkingDir.cloneRoot()
+	var clones []gcClone
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if _, err := os.Stat(filepath.Join(path, ".git")); err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		if len(parts) < 4 {
+			return filepath.SkipDir
+		}
+		pullNum, err := strconv.Atoi(parts[len(parts)-2])
+		if err != nil {
+			return filepath.SkipDir
+		}
+		size, lastUsed, err := dirUsage(path)
+		if err != nil {
+			return err
+		}
+		repoFullName := strings.Join(parts[:len(parts)-2], "/")
+		clones = append(clones, gcClone{
+			repoFullName: repoFullName,
+			pullNum:      pullNum,
+			workspace:    parts[len(parts)-1],
+			path:         path,
+			size:         size,
+			lastUsed:     lastUsed,
+			hasLocks:     lockedPulls[fmt.Sprintf("%s/%d", repoFullName, pullNum)],
+		})
+		return filepath.SkipDir
+	})
+	return clones, err
+}
+
+// diskUsage returns the bytes used by the data dir and the clone root if it's
+// outside the data dir.
+func (g *WorkingDirGC) diskUsage() (int64, error) {
+	usage, _, err := dirUsage(g.WorkingDir.DataDir)
+	if err != nil {
+		return 0, err
+	}
+	root := g.WorkingDir.cloneRoot()
+	if rel, err := filepath.Rel(g.WorkingDir.DataDir, root); err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		rootUsage, _, err := dirUsage(root)
+		if err != nil && !os.IsNotExist(err) {
+			return 0, err
+		}
+		usage += rootUsage
+	}
+	return usage, nil
+}
+
+// dirUsage returns the total size of the files in dir and when the most
+// recently modified one was modified. Files that are deleted while it's
+// walking dir, ex. by a plan that's running, are skipped.
+func dirUsage(dir string) (int64, time.Time, error) {
+	var size int64
+	var lastModified time.Time
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path != dir {
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		if info.ModTime().After(lastModified) {
+			lastModified = info.ModTime()
+		}
+		return nil
+	})
+	return size, lastModified, err
+}
@@ -0,0 +1,122 @@
+package events_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	lockmocks "github.com/runatlantis/atlantis/server/events/locking/mocks"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+// gcClone creates a working dir for repoFullName, pull and workspace in
+// dataDir with size bytes of files that were last modified at lastUsed.
+func gcClone(t *testing.T, dataDir string, repoFullName string, pull string, workspace string, size int, lastUsed time.Time) string {
+	dir := filepath.Join(dataDir, "repos", repoFullName, pull, workspace)
+	Ok(t, os.MkdirAll(filepath.Join(dir, ".git"), 0700))
+	Ok(t, ioutil.WriteFile(filepath.Join(dir, "main.tf"), make([]byte, size), 0600))
+	Ok(t, filepath.Walk(dir, func(path string, _ os.FileInfo, err error) error {
+		Ok(t, err)
+		return os.Chtimes(path, lastUsed, lastUsed)
+	}))
+	return dir
+}
+
+func newWorkingDirGC(t *testing.T, dataDir string, locks map[string]models.ProjectLock) *events.WorkingDirGC {
+	RegisterMockTestingT(t)
+	locker := lockmocks.NewMockLocker()
+	When(locker.List()).ThenReturn(locks, nil)
+	return &events.WorkingDirGC{
+		WorkingDir:       &events.FileWorkspace{DataDir: dataDir},
+		WorkingDirLocker: events.NewDefaultWorkingDirLocker(),
+		Locker:           locker,
+		Logger:           logging.NewNoopLogger(),
+	}
+}
+
+func TestWorkingDirGC_DeletesExpiredPullsWithoutLocks(t *testing.T) {
+	dataDir, cleanup := TempDir(t)
+	defer cleanup()
+	old := time.Now().Add(-48 * time.Hour)
+	expired := gcClone(t, dataDir, "owner/repo", "1", "default", 10, old)
+	locked := gcClone(t, dataDir, "owner/repo", "2", "default", 10, old)
+	recent := gcClone(t, dataDir, "group/subgroup/repo", "3", "staging", 10, time.Now())
+
+	gc := newWorkingDirGC(t, dataDir, map[string]models.ProjectLock{
+		"owner/repo/./default": {
+			Project: models.Project{RepoFullName: "owner/repo", Path: "."},
+			Pull:    models.PullRequest{Num: 2},
+		},
+	})
+	gc.TTL = 24 * time.Hour
+	result := gc.Run()
+
+	Equals(t, events.GCResult{DeletedDirs: 1, ReclaimedBytes: 10}, result)
+	_, err := os.Stat(expired)
+	Assert(t, os.IsNotExist(err), "exp expired working dir to be deleted")
+	_, err = os.Stat(filepath.Dir(expired))
+	Assert(t, os.IsNotExist(err), "exp expired pull dir to be deleted")
+	_, err = os.Stat(locked)
+	Ok(t, err)
+	_, err = os.Stat(recent)
+	Ok(t, err)
+}
+
+func TestWorkingDirGC_SkipsDirsInUse(t *testing.T) {
+	dataDir, cleanup := TempDir(t)
+	defer cleanup()
+	dir := gcClone(t, dataDir, "owner/repo", "1", "default", 10, time.Now().Add(-48*time.Hour))
+
+	gc := newWorkingDirGC(t, dataDir, nil)
+	gc.TTL = 24 * time.Hour
+	unlock, err := gc.WorkingDirLocker.TryLock("owner/repo", 1, "default")
+	Ok(t, err)
+	defer unlock()
+
+	Equals(t, events.GCResult{}, gc.Run())
+	_, err = os.Stat(dir)
+	Ok(t, err)
+}
+
+// Over the budget we delete the dirs of pulls without locks first, then the
+// least recently used, until the data dir fits.
+func TestWorkingDirGC_EnforcesMaxBytes(t *testing.T) {
+	dataDir, cleanup := TempDir(t)
+	defer cleanup()
+	now := time.Now()
+	lockedOldest := gcClone(t, dataDir, "owner/repo", "1", "default", 100, now.Add(-3*time.Hour))
+	lockedNewest := gcClone(t, dataDir, "owner/repo", "1", "staging", 100, now.Add(-time.Hour))
+	unlocked := gcClone(t, dataDir, "owner/repo", "2", "default", 100, now)
+
+	gc := newWorkingDirGC(t, dataDir, map[string]models.ProjectLock{
+		"owner/repo/./default": {
+			Project: models.Project{RepoFullName: "owner/repo", Path: "."},
+			Pull:    models.PullRequest{Num: 1},
+		},
+	})
+	gc.MaxBytes = 150
+	result := gc.Run()
+
+	Equals(t, events.GCResult{DeletedDirs: 2, ReclaimedBytes: 200}, result)
+	_, err := os.Stat(unlocked)
+	Assert(t, os.IsNotExist(err), "exp working dir of pull without locks to be deleted")
+	_, err = os.Stat(lockedOldest)
+	Assert(t, os.IsNotExist(err), "exp least recently used working dir to be deleted")
+	_, err = os.Stat(lockedNewest)
+	Ok(t, err)
+}
+
+func TestWorkingDirGC_NoCloneRoot(t *testing.T) {
+	dataDir, cleanup := TempDir(t)
+	defer cleanup()
+	gc := newWorkingDirGC(t, dataDir, nil)
+	gc.TTL = time.Hour
+	gc.MaxBytes = 1
+	Equals(t, events.GCResult{}, gc.Run())
+}
@@ -15,6 +15,7 @@ package events
 
 import (
 	"fmt"
+	"path/filepath"
 	"strings"
 	"sync"
 )
@@ -32,6 +33,13 @@ type WorkingDirLocker interface {
 	// an error if the workspace is already locked. The error is expected to
 	// be printed to the pull request.
 	TryLock(repoFullName string, pullNum int, workspace string) (func(), error)
+	// TryLockProject tries to acquire a lock for the project at path in this
+	// repo, workspace and pull. Projects in the same workspace can be locked
+	// at the same time but not while the whole workspace is locked.
+	// It returns a function that should be used to unlock the project and
+	// an error if the project is already locked. The error is expected to
+	// be printed to the pull request.
+	TryLockProject(repoFullName string, pullNum int, workspace string, path string) (func(), error)
 	// TryLockPull tries to acquire a lock for all the workspaces in this repo
 	// and pull.
 	// It returns a function that should be used to unlock the workspace and
@@ -81,7 +89,7 @@ func (d *DefaultWorkingDirLocker) TryLock(repoFullName string, pullNum int, work
 	pullKey := d.pullKey(repoFullName, pullNum)
 	workspaceKey := d.workspaceKey(repoFullName, pullNum, workspace)
 	for _, l := range d.locks {
-		if l == pullKey || l == workspaceKey {
+		if l == pullKey || l == workspaceKey || strings.HasPrefix(l, workspaceKey+"/") {
 			return func() {}, fmt.Errorf("the %s workspace is currently locked by another"+
 				" command that is running for this pull request–"+
 				"wait until the previous command is complete and try again", workspace)
@@ -93,6 +101,28 @@ func (d *DefaultWorkingDirLocker) TryLock(repoFullName string, pullNum int, work
 	}, nil
 }
 
+func (d *DefaultWorkingDirLocker) TryLockProject(repoFullName string, pullNum int, workspace string, path string) (func(), error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	pullKey := d.pullKey(repoFullName, pullNum)
+	workspaceKey := d.workspaceKey(repoFullName, pullNum, workspace)
+	projectKey := d.projectKey(repoFullName, pullNum, workspace, path)
+	for _, l := range d.locks {
+		if l == pullKey || l == workspaceKey || l == projectKey {
+			return func() {}, fmt.Errorf("the %s dir in the %s workspace is currently locked by another"+
+				" command that is running for this pull request–"+
+				"wait until the previous command is complete and try again", path, workspace)
+		}
+	}
+	d.locks = append(d.locks, projectKey)
+	return func() {
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+		d.removeLock(projectKey)
+	}, nil
+}
+
 // Unlock unlocks the workspace for this pull.
 func (d *DefaultWorkingDirLocker) unlock(repoFullName string, pullNum int, workspace string) {
 	d.mutex.Lock()
@@ -125,6 +155,10 @@ func (d *DefaultWorkingDirLocker) workspaceKey(repo string, pull int, workspace
 	return fmt.Sprintf("%s/%s", d.pullKey(repo, pull), workspace)
 }
 
+func (d *DefaultWorkingDirLocker) projectKey(repo string, pull int, workspace string, path string) string {
+	return fmt.Sprintf("%s/%s", d.workspaceKey(repo, pull, workspace), filepath.Clean(path))
+}
+
 func (d *DefaultWorkingDirLocker) pullKey(repo string, pull int) string {
 	return fmt.Sprintf("%s/%d", repo, pull)
 }
@@ -196,3 +196,38 @@ func TestLockPull_WorkspaceFirst(t *testing.T) {
 	_, err = locker.TryLockPull("owner/repo", 1)
 	Ok(t, err)
 }
+
+func TestTryLockProject(t *testing.T) {
+	locker := events.NewDefaultWorkingDirLocker()
+
+	t.Log("different projects in the same workspace should be lockable at the same time")
+	unlock1, err := locker.TryLockProject(repo, 1, workspace, "dir1")
+	Ok(t, err)
+	unlock2, err := locker.TryLockProject(repo, 1, workspace, "dir2")
+	Ok(t, err)
+
+	t.Log("but not the same project twice")
+	_, err = locker.TryLockProject(repo, 1, workspace, "dir1")
+	ErrEquals(t, "the dir1 dir in the default workspace is currently locked by another"+
+		" command that is running for this pull request–"+
+		"wait until the previous command is complete and try again", err)
+
+	t.Log("and the whole workspace and pull shouldn't be lockable until both are unlocked")
+	_, err = locker.TryLock(repo, 1, workspace)
+	ErrContains(t, "currently locked", err)
+	_, err = locker.TryLockPull(repo, 1)
+	ErrContains(t, "currently locked", err)
+	unlock1()
+	_, err = locker.TryLock(repo, 1, workspace)
+	ErrContains(t, "currently locked", err)
+	unlock2()
+	unlock, err := locker.TryLock(repo, 1, workspace)
+	Ok(t, err)
+
+	t.Log("while the workspace is locked its projects can't be")
+	_, err = locker.TryLockProject(repo, 1, workspace, "dir1")
+	ErrContains(t, "currently locked", err)
+	unlock()
+	_, err = locker.TryLockProject(repo, 1, workspace, "dir1")
+	Ok(t, err)
+}
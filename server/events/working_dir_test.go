@@ -0,0 +1,287 @@
+package events_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+// Test that two concurrent clones for the same pull (ex. from two
+// synchronize events in quick succession) both succeed and leave the repo
+// at the right commit.
+func TestClone_ConcurrentSamePull(t *testing.T) {
+	repoDir, headCommit, cleanupRepo := initRepo(t)
+	defer cleanupRepo()
+	dataDir, cleanupData := TempDir(t)
+	defer cleanupData()
+
+	wd := &events.FileWorkspace{
+		DataDir:                 dataDir,
+		TestingOverrideCloneURL: repoDir,
+	}
+	repo := models.Repo{FullName: "owner/repo"}
+	pull := models.PullRequest{Num: 1, HeadCommit: headCommit, Branch: "branch"}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	dirs := make([]string, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			dirs[i], errs[i] = wd.Clone(logging.NewNoopLogger(), repo, repo, pull, "default")
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < 2; i++ {
+		Ok(t, errs[i])
+		Equals(t, filepath.Join(dataDir, "repos", "owner/repo", "1", "default"), dirs[i])
+	}
+	Equals(t, headCommit, runGit(t, dirs[0], "rev-parse", "HEAD"))
+}
+
+func TestClone_CloneRoot(t *testing.T) {
+	repoDir, headCommit, cleanupRepo := initRepo(t)
+	defer cleanupRepo()
+	cloneRoot, cleanupRoot := TempDir(t)
+	defer cleanupRoot()
+
+	wd := &events.FileWorkspace{
+		DataDir:                 "/does-not-exist",
+		CloneRoot:               cloneRoot,
+		TestingOverrideCloneURL: repoDir,
+	}
+	repo := models.Repo{FullName: "owner/repo"}
+	pull := models.PullRequest{Num: 2, HeadCommit: headCommit, Branch: "branch"}
+
+	dir, err := wd.Clone(logging.NewNoopLogger(), repo, repo, pull, "staging")
+	Ok(t, err)
+	Equals(t, filepath.Join(cloneRoot, "owner/repo", "2", "staging"), dir)
+
+	wdDir, err := wd.GetWorkingDir(repo, pull, "staging")
+	Ok(t, err)
+	Equals(t, dir, wdDir)
+
+	t.Log("a different pull for the same repo and workspace should not collide")
+	_, err = wd.GetWorkingDir(repo, models.PullRequest{Num: 3}, "staging")
+	Assert(t, err != nil, "exp err")
+}
+
+// Test that a branch that looks like an option isn't passed to git checkout.
+func TestClone_BranchLooksLikeOption(t *testing.T) {
+	repoDir, headCommit, cleanupRepo := initRepo(t)
+	defer cleanupRepo()
+	dataDir, cleanupData := TempDir(t)
+	defer cleanupData()
+
+	wd := &events.FileWorkspace{
+		DataDir:                 dataDir,
+		TestingOverrideCloneURL: repoDir,
+	}
+	repo := models.Repo{FullName: "owner/repo"}
+	pull := models.PullRequest{Num: 1, HeadCommit: headCommit + "0", Branch: "--orphan=x"}
+	_, err := wd.Clone(logging.NewNoopLogger(), repo, repo, pull, "default")
+	ErrEquals(t, `refusing to check out branch "--orphan=x" since it starts with -`, err)
+}
+
+// Test that a failed clone is retried and that it fails once it runs out of
+// retries.
+func TestClone_Retries(t *testing.T) {
+	repoDir, headCommit, cleanupRepo := initRepo(t)
+	defer cleanupRepo()
+	dataDir, cleanupData := TempDir(t)
+	defer cleanupData()
+	repo := models.Repo{FullName: "owner/repo"}
+	pull := models.PullRequest{Num: 1, HeadCommit: headCommit, Branch: "branch"}
+
+	wd := &events.FileWorkspace{
+		DataDir:                 dataDir,
+		TestingOverrideCloneURL: filepath.Join(repoDir, "does-not-exist"),
+		CloneRetries:            2,
+		CloneRetryDelay:         time.Millisecond,
+	}
+	_, err := wd.Clone(logging.NewNoopLogger(), repo, repo, pull, "default")
+	ErrContains(t, "cloning : fatal:", err)
+
+	t.Log("the clone should succeed once the repo is available")
+	missing := filepath.Join(repoDir, "later")
+	wd.TestingOverrideCloneURL = missing
+	wd.CloneRetries = 5
+	wd.CloneRetryDelay = 20 * time.Millisecond
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		runGit(t, repoDir, "clone", "--quiet", repoDir, missing)
+	}()
+	dir, err := wd.Clone(logging.NewNoopLogger(), repo, repo, pull, "default")
+	Ok(t, err)
+	Equals(t, headCommit, runGit(t, dir, "rev-parse", "HEAD"))
+}
+
+// Test that shallow clones only fetch the last commits of the branch but are
+// deepened until they include the merge base with the default branch.
+func TestClone_CheckoutDepth(t *testing.T) {
+	repoDir, cleanupRepo := TempDir(t)
+	defer cleanupRepo()
+	commit := func(msg string) {
+		runGit(t, repoDir, "-c", "user.name=atlantis", "-c", "user.email=atlantis@example.com", "commit", "--allow-empty", "-m", msg)
+	}
+	runGit(t, repoDir, "init")
+	runGit(t, repoDir, "checkout", "-b", "master")
+	for i := 0; i < 5; i++ {
+		commit(fmt.Sprintf("history %d", i))
+	}
+	commit("base")
+	mergeBase := runGit(t, repoDir, "rev-parse", "HEAD")
+	runGit(t, repoDir, "checkout", "-b", "branch")
+	for i := 0; i < 4; i++ {
+		commit(fmt.Sprintf("branch %d", i))
+	}
+	headCommit := runGit(t, repoDir, "rev-parse", "HEAD")
+	runGit(t, repoDir, "checkout", "master")
+	commit("master")
+	dataDir, cleanupData := TempDir(t)
+	defer cleanupData()
+
+	wd := &events.FileWorkspace{
+		DataDir: dataDir,
+		// Local clones ignore --depth unless they use a URL.
+		TestingOverrideCloneURL: "file://" + repoDir,
+		CheckoutDepth:           2,
+	}
+	repo := models.Repo{FullName: "owner/repo"}
+	pull := models.PullRequest{Num: 1, HeadCommit: headCommit, Branch: "branch"}
+	dir, err := wd.Clone(logging.NewNoopLogger(), repo, repo, pull, "default")
+	Ok(t, err)
+	Equals(t, headCommit, runGit(t, dir, "rev-parse", "HEAD"))
+	Equals(t, "true", runGit(t, dir, "rev-parse", "--is-shallow-repository"))
+	Equals(t, mergeBase, runGit(t, dir, "merge-base", "HEAD", "refs/atlantis/base"))
+}
+
+// Test that sparse clones only check out the files at the root until dirs
+// are checked out, and that checking out a dir also checks out its modules.
+func TestClone_SparseCheckout(t *testing.T) {
+	repoDir, cleanupRepo := TempDir(t)
+	defer cleanupRepo()
+	files := map[string]string{
+		"atlantis.yaml":       "version: 2",
+		"project1/main.tf":    `module "vpc" { source = "../modules/vpc" }`,
+		"project2/main.tf":    "",
+		"modules/vpc/main.tf": `module "subnet" { source = "../subnet" }`,
+		"modules/subnet/a.tf": "",
+		"modules/unused/b.tf": "",
+	}
+	for name, contents := range files {
+		Ok(t, os.MkdirAll(filepath.Join(repoDir, filepath.Dir(name)), 0700))
+		Ok(t, ioutil.WriteFile(filepath.Join(repoDir, name), []byte(contents), 0600))
+	}
+	runGit(t, repoDir, "init")
+	runGit(t, repoDir, "checkout", "-b", "branch")
+	runGit(t, repoDir, "config", "uploadpack.allowFilter", "true")
+	runGit(t, repoDir, "add", ".")
+	runGit(t, repoDir, "-c", "user.name=atlantis", "-c", "user.email=atlantis@example.com", "commit", "-m", "initial commit")
+	headCommit := runGit(t, repoDir, "rev-parse", "HEAD")
+	dataDir, cleanupData := TempDir(t)
+	defer cleanupData()
+
+	wd := &events.FileWorkspace{
+		DataDir:                 dataDir,
+		TestingOverrideCloneURL: "file://" + repoDir,
+		SparseCheckout:          true,
+	}
+	repo := models.Repo{FullName: "owner/repo"}
+	pull := models.PullRequest{Num: 1, HeadCommit: headCommit, Branch: "branch"}
+	dir, err := wd.Clone(logging.NewNoopLogger(), repo, repo, pull, "default")
+	Ok(t, err)
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+	Assert(t, exists("atlantis.yaml"), "exp files at the root to be checked out")
+	Assert(t, !exists("project1/main.tf"), "exp project1 not to be checked out")
+
+	Ok(t, wd.CheckoutDirs(logging.NewNoopLogger(), dir, []string{"project1"}))
+	for _, name := range []string{"project1/main.tf", "modules/vpc/main.tf", "modules/subnet/a.tf"} {
+		Assert(t, exists(name), "exp %s to be checked out", name)
+	}
+	Assert(t, !exists("project2/main.tf"), "exp project2 not to be checked out")
+	Assert(t, !exists("modules/unused/b.tf"), "exp unused module not to be checked out")
+
+	t.Log("CheckoutDirs does nothing without a sparse checkout")
+	wd.SparseCheckout = false
+	Ok(t, wd.CheckoutDirs(logging.NewNoopLogger(), "/does-not-exist", []string{"project2"}))
+}
+
+// Test that clones fetch into the repo's mirror and copy its objects, and that
+// cloning still works if the mirror can't be used.
+func TestClone_CloneCache(t *testing.T) {
+	repoDir, headCommit, cleanupRepo := initRepo(t)
+	defer cleanupRepo()
+	dataDir, cleanupData := TempDir(t)
+	defer cleanupData()
+
+	wd := &events.FileWorkspace{
+		DataDir:                 dataDir,
+		TestingOverrideCloneURL: repoDir,
+		CloneCache:              true,
+	}
+	repo := models.Repo{FullName: "owner/repo", VCSHost: models.VCSHost{Hostname: "github.com"}}
+	mirrorDir := filepath.Join(dataDir, "mirrors", "github.com", "owner/repo.git")
+	dir, err := wd.Clone(logging.NewNoopLogger(), repo, repo, models.PullRequest{Num: 1, HeadCommit: headCommit, Branch: "branch"}, "default")
+	Ok(t, err)
+	Equals(t, headCommit, runGit(t, dir, "rev-parse", "HEAD"))
+	Equals(t, headCommit, runGit(t, mirrorDir, "rev-parse", "refs/heads/branch"))
+	_, err = os.Stat(filepath.Join(dir, ".git", "objects", "info", "alternates"))
+	Assert(t, os.IsNotExist(err), "exp clone not to depend on the mirror")
+
+	t.Log("the next clone should fetch the new commits into the mirror")
+	runGit(t, repoDir, "-c", "user.name=atlantis", "-c", "user.email=atlantis@example.com", "commit", "--allow-empty", "-m", "second commit")
+	headCommit = runGit(t, repoDir, "rev-parse", "HEAD")
+	dir, err = wd.Clone(logging.NewNoopLogger(), repo, repo, models.PullRequest{Num: 2, HeadCommit: headCommit, Branch: "branch"}, "default")
+	Ok(t, err)
+	Equals(t, headCommit, runGit(t, dir, "rev-parse", "HEAD"))
+	Equals(t, headCommit, runGit(t, mirrorDir, "rev-parse", "refs/heads/branch"))
+
+	t.Log("a broken mirror shouldn't stop the clone")
+	Ok(t, os.RemoveAll(mirrorDir))
+	Ok(t, ioutil.WriteFile(mirrorDir, nil, 0600))
+	dir, err = wd.Clone(logging.NewNoopLogger(), repo, repo, models.PullRequest{Num: 3, HeadCommit: headCommit, Branch: "branch"}, "default")
+	Ok(t, err)
+	Equals(t, headCommit, runGit(t, dir, "rev-parse", "HEAD"))
+}
+
+// initRepo creates a git repo with a single commit on a branch named
+// "branch" and returns its path and the commit sha.
+func initRepo(t *testing.T) (string, string, func()) {
+	repoDir, cleanup := TempDir(t)
+	runGit(t, repoDir, "init")
+	runGit(t, repoDir, "checkout", "-b", "branch")
+	runGit(t, repoDir, "-c", "user.name=atlantis", "-c", "user.email=atlantis@example.com", "commit", "--allow-empty", "-m", "initial commit")
+	return repoDir, runGit(t, repoDir, "rev-parse", "HEAD"), cleanup
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", args...) // #nosec
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	Ok(t, errorWithOutput(err, out))
+	return strings.TrimSpace(string(out))
+}
+
+func errorWithOutput(err error, out []byte) error {
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, string(out))
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// Package workqueue lets the commands received in webhooks be run by workers,
+// possibly in other processes, rather than by the process that received them.
+// The queue is stored in the database so it outlives the processes and is
+// shared between them.
+package workqueue
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+const (
+	// AllRole runs the commands in the webhooks a process receives itself,
+	// without the queue.
+	AllRole = "all"
+	// WebhooksRole enqueues the commands in webhooks for workers to run.
+	WebhooksRole = "webhooks"
+	// WorkerRole runs the commands in the queue and enqueues the commands in
+	// the webhooks it receives.
+	WorkerRole = "worker"
+)
+
+// Roles are the parts of Atlantis a process can run.
+var Roles = []string{AllRole, WebhooksRole, WorkerRole}
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_store.go Store
+
+// Store persists the work queue. It's implemented by the locking backends so
+// the queue is stored in the same database as the locks.
+type Store interface {
+	// EnqueueWork adds item to the end of the queue.
+	EnqueueWork(item models.WorkItem) error
+	// ClaimWork gives worker the oldest waiting item for a pull that has no
+	// other item claimed and returns it, or nil if there isn't one. So a
+	// pull's commands are run one at a time and in the order they were
+	// received.
+	ClaimWork(worker string) (*models.WorkItem, error)
+	// RenewWork sets when the item with id was claimed to now if it's still
+	// claimed by worker, so that its claim doesn't expire while worker is
+	// running it.
+	RenewWork(id string, worker string) error
+	// CompleteWork deletes the item with id.
+	CompleteWork(id string) error
+	// DropExpiredWork deletes the items whose claims have expired, see
+	// ClaimExpired, and returns them. The check and the delete are done
+	// atomically so an item renewed in the meantime isn't dropped.
+	DropExpiredWork(timeout time.Duration) ([]models.WorkItem, error)
+	// ListWork returns all the items, oldest first.
+	ListWork() ([]models.WorkItem, error)
+}
+
+// NewID returns an ID for an item enqueued at enqueuedAt. IDs sort in the
+// order the items were enqueued.
+func NewID(enqueuedAt time.Time) string {
+	suffix := make([]byte, 4)
+	rand.Read(suffix) // nolint: errcheck
+	return fmt.Sprintf("%019d-%s", enqueuedAt.UnixNano(), hex.EncodeToString(suffix))
+}
+
+// ClaimExpired returns true if item is claimed but its claim hasn't been
+// renewed for longer than timeout, ex. because the worker running it was
+// killed and won't restart to drop it. Claims never expire if timeout is 0.
+func ClaimExpired(item models.WorkItem, timeout time.Duration, now time.Time) bool {
+	return item.ClaimedBy != "" && timeout > 0 && now.Sub(item.ClaimedAt) > timeout
+}
+
+// Claimable returns the index in items, which are oldest first, of the item
+// ClaimWork should give to a worker, or -1 if none can be claimed.
+func Claimable(items []models.WorkItem) int {
+	claimed := make(map[string]bool)
+	for _, item := range items {
+		if item.ClaimedBy != "" {
+			claimed[item.PullKey()] = true
+		}
+	}
+	for i, item := range items {
+		if item.ClaimedBy == "" && !claimed[item.PullKey()] {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,60 @@
+package workqueue_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/workqueue"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestNewID_SortsByEnqueueTime(t *testing.T) {
+	now := time.Now()
+	first := workqueue.NewID(now)
+	second := workqueue.NewID(now.Add(time.Nanosecond))
+	Assert(t, first < second, "exp %q to sort before %q", first, second)
+	Assert(t, workqueue.NewID(now) != first, "exp IDs enqueued at the same time to be unique")
+}
+
+func TestClaimable(t *testing.T) {
+	item := func(pullNum int, claimedBy string) models.WorkItem {
+		return models.WorkItem{BaseRepo: models.Repo{FullName: "owner/repo"}, PullNum: pullNum, ClaimedBy: claimedBy}
+	}
+	cases := []struct {
+		description string
+		items       []models.WorkItem
+		exp         int
+	}{
+		{"empty queue", nil, -1},
+		{"oldest item", []models.WorkItem{item(1, ""), item(2, "")}, 0},
+		{"skips claimed items", []models.WorkItem{item(1, "worker"), item(2, "")}, 1},
+		{"waits for the pull's claimed item", []models.WorkItem{item(1, "worker"), item(1, ""), item(2, "")}, 2},
+		{"waits even if the claimed item is newer", []models.WorkItem{item(1, ""), item(1, "worker")}, -1},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			Equals(t, c.exp, workqueue.Claimable(c.items))
+		})
+	}
+}
+
+func TestClaimExpired(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		description string
+		item        models.WorkItem
+		timeout     time.Duration
+		exp         bool
+	}{
+		{"unclaimed", models.WorkItem{}, time.Minute, false},
+		{"renewed recently", models.WorkItem{ClaimedBy: "worker", ClaimedAt: now.Add(-30 * time.Second)}, time.Minute, false},
+		{"not renewed", models.WorkItem{ClaimedBy: "worker", ClaimedAt: now.Add(-2 * time.Minute)}, time.Minute, true},
+		{"no timeout", models.WorkItem{ClaimedBy: "worker", ClaimedAt: now.Add(-2 * time.Minute)}, 0, false},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			Equals(t, c.exp, workqueue.ClaimExpired(c.item, c.timeout, now))
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/go-ozzo/ozzo-validation"
 	"github.com/pkg/errors"
@@ -16,7 +17,12 @@ import (
 // AtlantisYAMLFilename is the name of the config file for each repo.
 const AtlantisYAMLFilename = "atlantis.yaml"
 
-type ParserValidator struct{}
+type ParserValidator struct {
+	// ServerWorkflows are the names of the workflows defined in the
+	// server-side repo config. Projects can use them without defining them
+	// in their atlantis.yaml.
+	ServerWorkflows []string
+}
 
 // ReadConfig returns the parsed and validated atlantis.yaml config for repoDir.
 // If there was no config file, then this can be detected by checking the type
@@ -38,13 +44,35 @@ func (p *ParserValidator) ReadConfig(repoDir string) (valid.Config, error) {
 	}
 
 	// If the config file exists, parse it.
-	config, err := p.parseAndValidate(configData)
+	config, err := p.parseAndValidate(repoDir, configData)
 	if err != nil {
 		return valid.Config{}, errors.Wrapf(err, "parsing %s", AtlantisYAMLFilename)
 	}
 	return config, err
 }
 
+// ReadServerConfig returns the parsed and validated server-side repo config
+// at configFile.
+func (p *ParserValidator) ReadServerConfig(configFile string) (valid.ServerConfig, error) {
+	configData, err := ioutil.ReadFile(configFile) // nolint: gosec
+	if err != nil {
+		return valid.ServerConfig{}, errors.Wrapf(err, "unable to read %s", configFile)
+	}
+
+	var rawConfig raw.ServerConfig
+	if err := yaml.UnmarshalStrict(configData, &rawConfig); err != nil {
+		return valid.ServerConfig{}, errors.Wrapf(err, "parsing %s", configFile)
+	}
+
+	// Set ErrorTag to yaml so it uses the YAML field names in error messages.
+	validation.ErrorTag = "yaml"
+
+	if err := rawConfig.Validate(); err != nil {
+		return valid.ServerConfig{}, errors.Wrapf(err, "parsing %s", configFile)
+	}
+	return rawConfig.ToValid(), nil
+}
+
 func (p *ParserValidator) HasConfigFile(repoDir string) (bool, error) {
 	_, err := os.Stat(p.configFilePath(repoDir))
 	if os.IsNotExist(err) {
@@ -60,7 +88,7 @@ func (p *ParserValidator) configFilePath(repoDir string) string {
 	return filepath.Join(repoDir, AtlantisYAMLFilename)
 }
 
-func (p *ParserValidator) parseAndValidate(configData []byte) (valid.Config, error) {
+func (p *ParserValidator) parseAndValidate(repoDir string, configData []byte) (valid.Config, error) {
 	var rawConfig raw.Config
 	if err := yaml.UnmarshalStrict(configData, &rawConfig); err != nil {
 		return valid.Config{}, err
@@ -72,6 +100,11 @@ func (p *ParserValidator) parseAndValidate(configData []byte) (valid.Config, err
 	if err := rawConfig.Validate(); err != nil {
 		return valid.Config{}, err
 	}
+	projects, err := p.expandProjects(repoDir, rawConfig.Projects)
+	if err != nil {
+		return valid.Config{}, err
+	}
+	rawConfig.Projects = projects
 
 	// Top level validation.
 	if err := p.validateWorkflows(rawConfig); err != nil {
@@ -82,10 +115,36 @@ func (p *ParserValidator) parseAndValidate(configData []byte) (valid.Config, err
 	if err := p.validateProjectNames(validConfig); err != nil {
 		return valid.Config{}, err
 	}
+	if err := p.validateProjectDependencies(validConfig); err != nil {
+		return valid.Config{}, err
+	}
 
 	return validConfig, nil
 }
 
+// expandProjects replaces the projects with a matrix by the projects they
+// generate in repoDir and validates those.
+func (p *ParserValidator) expandProjects(repoDir string, projects []raw.Project) ([]raw.Project, error) {
+	var expanded []raw.Project
+	for i, project := range projects {
+		if project.Matrix == nil {
+			expanded = append(expanded, project)
+			continue
+		}
+		generated, err := project.Expand(repoDir)
+		if err != nil {
+			return nil, fmt.Errorf("projects: (%d: (matrix: %s.).).", i, err)
+		}
+		for _, g := range generated {
+			if err := g.Validate(); err != nil {
+				return nil, fmt.Errorf("project generated by projects[%d] for dir %q and workspace %q: %s", i, *g.Dir, *g.Workspace, err)
+			}
+		}
+		expanded = append(expanded, generated...)
+	}
+	return expanded, nil
+}
+
 func (p *ParserValidator) validateProjectNames(config valid.Config) error {
 	// First, validate that all names are unique.
 	seen := make(map[string]bool)
@@ -123,6 +182,59 @@ func (p *ParserValidator) validateProjectNames(config valid.Config) error {
 	return nil
 }
 
+// validateProjectDependencies validates that projects only depend on named
+// projects and that there are no cycles, which would mean no project could be
+// applied first.
+func (p *ParserValidator) validateProjectDependencies(config valid.Config) error {
+	deps := make(map[string][]string)
+	for _, project := range config.Projects {
+		if project.Name != nil {
+			deps[*project.Name] = project.DependsOn
+		}
+	}
+	for _, project := range config.Projects {
+		if len(project.DependsOn) > 0 && project.Name == nil {
+			return fmt.Errorf("project with dir: %q workspace: %q has depends_on so it must have a name", project.Dir, project.Workspace)
+		}
+		for _, dep := range project.DependsOn {
+			if _, ok := deps[dep]; !ok {
+				return fmt.Errorf("project %q depends on %q but there is no project with that name", project.GetName(), dep)
+			}
+		}
+	}
+
+	// Depth-first search for cycles. Projects in visiting are on the
+	// current path so reaching one of them again means there's a cycle.
+	const visiting, visited = 1, 2
+	state := make(map[string]int)
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		path = append(path, name)
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("projects can't depend on each other in a cycle: %s", strings.Join(path, " -> "))
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		for _, dep := range deps[name] {
+			if err := visit(dep, path); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+	for _, project := range config.Projects {
+		if project.Name != nil {
+			if err := visit(*project.Name, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (p *ParserValidator) validateWorkflows(config raw.Config) error {
 	for _, project := range config.Projects {
 		if err := p.validateWorkflowExists(project, config.Workflows); err != nil {
@@ -142,5 +254,10 @@ func (p *ParserValidator) validateWorkflowExists(project raw.Project, workflows
 			return nil
 		}
 	}
+	for _, k := range p.ServerWorkflows {
+		if k == workflow {
+			return nil
+		}
+	}
 	return fmt.Errorf("workflow %q is not defined", workflow)
 }
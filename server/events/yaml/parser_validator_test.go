@@ -104,7 +104,7 @@ version: 0
 projects:
 - dir: "."
 `,
-			expErr: "version: must equal 2.",
+			expErr: "version: must equal 2 or 3.",
 		},
 		{
 			description: "empty version",
@@ -113,7 +113,22 @@ version: ~
 projects:
 - dir: "."
 `,
-			expErr: "version: must equal 2.",
+			expErr: "version: must equal 2 or 3.",
+		},
+
+		{
+			description: "automerge",
+			input: `
+version: 2
+automerge: true
+delete_source_branch_on_merge: true
+projects:`,
+			exp: valid.Config{
+				Version:                   2,
+				Automerge:                 true,
+				DeleteSourceBranchOnMerge: true,
+				Workflows:                 map[string]valid.Workflow{},
+			},
 		},
 
 		// Projects key.
@@ -410,6 +425,129 @@ projects:
 				Workflows: map[string]valid.Workflow{},
 			},
 		},
+		{
+			description: "project depends on another project",
+			input: `
+version: 2
+projects:
+- name: compute
+  dir: compute
+  depends_on: [network]
+- name: network
+  dir: network`,
+			exp: valid.Config{
+				Version: 2,
+				Projects: []valid.Project{
+					{
+						Name:      String("compute"),
+						Dir:       "compute",
+						Workspace: "default",
+						Autoplan: valid.Autoplan{
+							WhenModified: []string{"**/*.tf*"},
+							Enabled:      true,
+						},
+						DependsOn: []string{"network"},
+					},
+					{
+						Name:      String("network"),
+						Dir:       "network",
+						Workspace: "default",
+						Autoplan: valid.Autoplan{
+							WhenModified: []string{"**/*.tf*"},
+							Enabled:      true,
+						},
+					},
+				},
+				Workflows: map[string]valid.Workflow{},
+			},
+		},
+		{
+			description: "project depends on a project that doesn't exist",
+			input: `
+version: 2
+projects:
+- name: compute
+  dir: compute
+  depends_on: [network]`,
+			expErr: "project \"compute\" depends on \"network\" but there is no project with that name",
+		},
+		{
+			description: "unnamed project with depends_on",
+			input: `
+version: 2
+projects:
+- dir: compute
+  depends_on: [network]
+- name: network
+  dir: network`,
+			expErr: "project with dir: \"compute\" workspace: \"default\" has depends_on so it must have a name",
+		},
+		{
+			description: "projects that depend on each other",
+			input: `
+version: 2
+projects:
+- name: a
+  dir: a
+  depends_on: [b]
+- name: b
+  dir: b
+  depends_on: [c]
+- name: c
+  dir: c
+  depends_on: [a]`,
+			expErr: "projects can't depend on each other in a cycle: a -> b -> c -> a",
+		},
+		{
+			description: "project depends on itself",
+			input: `
+version: 2
+projects:
+- name: a
+  dir: a
+  depends_on: [a]`,
+			expErr: "projects can't depend on each other in a cycle: a -> a",
+		},
+		{
+			description: "project with extra repos",
+			input: `
+version: 2
+projects:
+- dir: live/prod
+  extra_repos:
+  - repo: github.com/owner/modules
+    ref: v1.2.0
+    dir: modules`,
+			exp: valid.Config{
+				Version: 2,
+				Projects: []valid.Project{
+					{
+						Dir:       "live/prod",
+						Workspace: "default",
+						Autoplan: valid.Autoplan{
+							WhenModified: []string{"**/*.tf*"},
+							Enabled:      true,
+						},
+						ExtraRepos: []valid.ExtraRepo{
+							{Repo: "github.com/owner/modules", Ref: "v1.2.0", Dir: "modules"},
+						},
+					},
+				},
+				Workflows: map[string]valid.Workflow{},
+			},
+		},
+		{
+			description: "extra repo cloned outside the repo",
+			input: `
+version: 2
+projects:
+- dir: live/prod
+  extra_repos:
+  - repo: github.com/owner/modules
+    ref: v1.2.0
+    dir: ../modules`,
+			expErr: "projects: (0: (extra_repos: (0: (dir: must be a subdirectory of the repo and cannot contain '..'.).).).).",
+		},
 	}
 
 	tmpDir, cleanup := TempDir(t)
@@ -432,6 +570,114 @@ projects:
 	}
 }
 
+func TestReadConfig_Matrix(t *testing.T) {
+	defaultAutoplan := valid.Autoplan{
+		WhenModified: []string{"**/*.tf*"},
+		Enabled:      true,
+	}
+	cases := []struct {
+		description string
+		input       string
+		expErr      string
+		exp         []valid.Project
+	}{
+		{
+			description: "dirs and workspaces",
+			input: `
+version: 3
+projects:
+- name: "{dir}-{workspace}"
+  matrix:
+    dir: [stacks/*]
+    workspace: [staging, production]
+  apply_requirements: [approved]`,
+			exp: []valid.Project{
+				{Name: String("stacks/dns-staging"), Dir: "stacks/dns", Workspace: "staging", Autoplan: defaultAutoplan, ApplyRequirements: []string{"approved"}},
+				{Name: String("stacks/dns-production"), Dir: "stacks/dns", Workspace: "production", Autoplan: defaultAutoplan, ApplyRequirements: []string{"approved"}},
+				{Name: String("stacks/vpc-staging"), Dir: "stacks/vpc", Workspace: "staging", Autoplan: defaultAutoplan, ApplyRequirements: []string{"approved"}},
+				{Name: String("stacks/vpc-production"), Dir: "stacks/vpc", Workspace: "production", Autoplan: defaultAutoplan, ApplyRequirements: []string{"approved"}},
+			},
+		},
+		{
+			description: "anchors mixed with plain projects",
+			input: `
+version: 3
+projects:
+- dir: network
+  autoplan: &autoplan
+    when_modified: ["*.tf", "../modules/**/*.tf"]
+- matrix:
+    dir: [stacks/vpc]
+  autoplan: *autoplan`,
+			exp: []valid.Project{
+				{Dir: "network", Workspace: "default", Autoplan: valid.Autoplan{WhenModified: []string{"*.tf", "../modules/**/*.tf"}, Enabled: true}},
+				{Dir: "stacks/vpc", Workspace: "default", Autoplan: valid.Autoplan{WhenModified: []string{"*.tf", "../modules/**/*.tf"}, Enabled: true}},
+			},
+		},
+		{
+			description: "matrix in version 2",
+			input: `
+version: 2
+projects:
+- matrix:
+    dir: [stacks/*]`,
+			expErr: "projects: 0: matrix requires version 3.",
+		},
+		{
+			description: "dir pattern matches nothing",
+			input: `
+version: 3
+projects:
+- matrix:
+    dir: [modules/*]`,
+			expErr: `projects: (0: (matrix: dir "modules/*" matches no directories.).).`,
+		},
+		{
+			description: "invalid generated project",
+			input: `
+version: 3
+projects:
+- dir: network
+- name: "{dir} {workspace}"
+  matrix:
+    dir: [stacks/*]`,
+			expErr: `project generated by projects[1] for dir "stacks/dns" and workspace "default": name: "stacks/dns default" is not allowed: must contain only URL safe characters.`,
+		},
+		{
+			description: "generated names aren't unique",
+			input: `
+version: 3
+projects:
+- name: stack
+  matrix:
+    dir: [stacks/*]`,
+			expErr: `found two or more projects with name "stack"; project names must be unique`,
+		},
+	}
+
+	tmpDir, cleanup := TempDir(t)
+	defer cleanup()
+	for _, dir := range []string{"stacks/vpc", "stacks/dns", "network"} {
+		Ok(t, os.MkdirAll(filepath.Join(tmpDir, dir), 0700))
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			err := ioutil.WriteFile(filepath.Join(tmpDir, "atlantis.yaml"), []byte(c.input), 0600)
+			Ok(t, err)
+
+			r := yaml.ParserValidator{}
+			act, err := r.ReadConfig(tmpDir)
+			if c.expErr != "" {
+				ErrEquals(t, "parsing atlantis.yaml: "+c.expErr, err)
+				return
+			}
+			Ok(t, err)
+			Equals(t, c.exp, act.Projects)
+		})
+	}
+}
+
 func TestReadConfig_Successes(t *testing.T) {
 	basicProjects := []valid.Project{
 		{
@@ -695,3 +941,591 @@ workflows:
 // String is a helper routine that allocates a new string value
 // to store v and returns a pointer to it.
 func String(v string) *string { return &v }
+
+func Int(v int) *int { return &v }
+
+func Bool(v bool) *bool { return &v }
+
+func TestReadServerConfig(t *testing.T) {
+	cases := []struct {
+		description string
+		input       string
+		exp         valid.ServerConfig
+		expErr      string
+	}{
+		{
+			description: "empty file",
+			input:       "",
+			exp:         valid.ServerConfig{},
+		},
+		{
+			description: "repos",
+			input: `
+repos:
+- id: github.com/governed/*
+  require_atlantis_yaml: true
+- id: github.com/governed/legacy
+  require_atlantis_yaml: false
+- id: github.com/other/repo
+  max_projects_per_command: 100`,
+			exp: valid.ServerConfig{
+				Repos: []valid.Repo{
+					{ID: "github.com/governed/*", RequireAtlantisYAML: true, AllowCustomWorkflows: true},
+					{ID: "github.com/governed/legacy", RequireAtlantisYAML: false, AllowCustomWorkflows: true},
+					{ID: "github.com/other/repo", RequireAtlantisYAML: false, MaxProjectsPerCommand: Int(100), AllowCustomWorkflows: true},
+				},
+			},
+		},
+		{
+			description: "missing id",
+			input: `
+repos:
+- require_atlantis_yaml: true`,
+			expErr: "repos: (0: (id: cannot be blank.).).",
+		},
+		{
+			description: "id with scheme",
+			input: `
+repos:
+- id: https://github.com/owner/repo`,
+			expErr: "repos: (0: (id: cannot contain ://, should be hostname and repo name only, ex. github.com/owner/repo.).).",
+		},
+		{
+			description: "negative max projects",
+			input: `
+repos:
+- id: github.com/owner/repo
+  max_projects_per_command: -1`,
+			expErr: "repos: (0: (max_projects_per_command: cannot be negative.).).",
+		},
+		{
+			description: "policy sets",
+			input: `
+repos:
+- id: github.com/owner/repo
+  policy_sets:
+  - name: security
+    path: /policies/security
+  - name: cost
+    path: /policies/cost
+  policy_owners: [alice, bob]`,
+			exp: valid.ServerConfig{
+				Repos: []valid.Repo{
+					{
+						ID:                   "github.com/owner/repo",
+						AllowCustomWorkflows: true,
+						PolicySets: []valid.PolicySet{
+							{Name: "security", Path: "/policies/security"},
+							{Name: "cost", Path: "/policies/cost"},
+						},
+						PolicyOwners: []string{"alice", "bob"},
+					},
+				},
+			},
+		},
+		{
+			description: "allow apply from",
+			input: `
+repos:
+- id: github.com/owner/repo
+  allow_apply_from: [myorg/infra, myorg/sre]`,
+			exp: valid.ServerConfig{
+				Repos: []valid.Repo{
+					{
+						ID:                   "github.com/owner/repo",
+						AllowCustomWorkflows: true,
+						AllowApplyFrom:       []string{"myorg/infra", "myorg/sre"},
+					},
+				},
+			},
+		},
+		{
+			description: "plan only and apply branches",
+			input: `
+repos:
+- id: github.com/owner/sandbox
+  plan_only: true
+- id: github.com/owner/repo
+  apply_branches: [main, release/*, /^hotfix-\d+$/]`,
+			exp: valid.ServerConfig{
+				Repos: []valid.Repo{
+					{
+						ID:                   "github.com/owner/sandbox",
+						AllowCustomWorkflows: true,
+						PlanOnly:             true,
+					},
+					{
+						ID:                   "github.com/owner/repo",
+						AllowCustomWorkflows: true,
+						ApplyBranches:        []string{"main", "release/*", `/^hotfix-\d+$/`},
+					},
+				},
+			},
+		},
+		{
+			description: "workflows and repo settings",
+			input: `
+workflows:
+  terragrunt:
+    plan:
+      steps: [init, plan]
+  custom:
+    apply:
+      steps: [apply]
+repos:
+- id: /github.com/myorg/(infra|network)/
+  workflow: terragrunt
+  allowed_workflows: [custom]
+  allow_repo_config: true
+  allow_custom_workflows: false
+  apply_requirements: [approved, {any_of: [approved, mergeable]}]`,
+			exp: valid.ServerConfig{
+				Workflows: map[string]valid.Workflow{
+					"terragrunt": {
+						Plan: &valid.Stage{Steps: []valid.Step{{StepName: "init"}, {StepName: "plan"}}},
+					},
+					"custom": {
+						Apply: &valid.Stage{Steps: []valid.Step{{StepName: "apply"}}},
+					},
+				},
+				Repos: []valid.Repo{
+					{
+						ID:                     "/github.com/myorg/(infra|network)/",
+						AllowRepoConfig:        Bool(true),
+						AllowCustomWorkflows:   false,
+						Workflow:               String("terragrunt"),
+						AllowedWorkflows:       []string{"custom"},
+						ApplyRequirements:      []string{"approved"},
+						ApplyRequirementGroups: []valid.ApplyRequirementGroup{{AnyOf: true, Requirements: []string{"approved", "mergeable"}}},
+					},
+				},
+			},
+		},
+		{
+			description: "undefined workflow",
+			input: `
+repos:
+- id: github.com/owner/repo
+  workflow: missing`,
+			expErr: "repos: 0: workflow \"missing\" is not defined.",
+		},
+		{
+			description: "undefined allowed workflow",
+			input: `
+workflows:
+  defined:
+repos:
+- id: github.com/owner/repo
+  allowed_workflows: [defined, missing]`,
+			expErr: "repos: 0: workflow \"missing\" is not defined.",
+		},
+		{
+			description: "invalid regex",
+			input: `
+repos:
+- id: /github.com/(owner/
+  require_atlantis_yaml: true`,
+			expErr: "repos: (0: (id: regex \"github.com/(owner\" could not be parsed: error parsing regexp: missing closing ): `github.com/(owner`.).).",
+		},
+		{
+			description: "branch",
+			input: `
+repos:
+- id: github.com/owner/repo
+  branch: /^main$/`,
+			exp: valid.ServerConfig{
+				Repos: []valid.Repo{
+					{
+						ID:                   "github.com/owner/repo",
+						AllowCustomWorkflows: true,
+						Branch:               String("^main$"),
+					},
+				},
+			},
+		},
+		{
+			description: "branch workspaces",
+			input: `
+repos:
+- id: github.com/owner/repo
+  branch_workspaces:
+    staging: staging
+    main: production`,
+			exp: valid.ServerConfig{
+				Repos: []valid.Repo{
+					{
+						ID:                   "github.com/owner/repo",
+						AllowCustomWorkflows: true,
+						BranchWorkspaces:     map[string]string{"staging": "staging", "main": "production"},
+					},
+				},
+			},
+		},
+		{
+			description: "autoplan on",
+			input: `
+repos:
+- id: github.com/owner/repo
+  autoplan_on: [opened, ready_for_review]
+- id: github.com/owner/other
+  autoplan_on: []`,
+			exp: valid.ServerConfig{
+				Repos: []valid.Repo{
+					{
+						ID:                   "github.com/owner/repo",
+						AllowCustomWorkflows: true,
+						AutoplanOn:           []string{"opened", "ready_for_review"},
+					},
+					{
+						ID:                   "github.com/owner/other",
+						AllowCustomWorkflows: true,
+						AutoplanOn:           []string{},
+					},
+				},
+			},
+		},
+		{
+			description: "invalid autoplan on event",
+			input: `
+repos:
+- id: github.com/owner/repo
+  autoplan_on: [synchronize]`,
+			expErr: "repos: (0: (autoplan_on: \"synchronize\" is not a valid event, only opened, pushed, ready_for_review are supported.).).",
+		},
+		{
+			description: "invalid branch workspace",
+			input: `
+repos:
+- id: github.com/owner/repo
+  branch_workspaces:
+    staging: ../staging`,
+			expErr: "repos: (0: (branch_workspaces: invalid workspace \"../staging\" for branch \"staging\".).).",
+		},
+		{
+			description: "branch not wrapped in /'s",
+			input: `
+repos:
+- id: github.com/owner/repo
+  branch: main`,
+			expErr: "repos: (0: (branch: \"main\" must be a regex wrapped in /'s, ex. /^main$/.).).",
+		},
+		{
+			description: "invalid apply branch regex",
+			input: `
+repos:
+- id: github.com/owner/repo
+  apply_branches: [/(main/]`,
+			expErr: "repos: (0: (apply_branches: regex \"(main\" could not be parsed: error parsing regexp: missing closing ): `(main`.).).",
+		},
+		{
+			description: "require atlantis.yaml but don't allow it",
+			input: `
+repos:
+- id: github.com/owner/repo
+  require_atlantis_yaml: true
+  allow_repo_config: false`,
+			expErr: "repos: (0: (allow_repo_config: cannot be false when require_atlantis_yaml is true.).).",
+		},
+		{
+			description: "invalid apply requirement",
+			input: `
+repos:
+- id: github.com/owner/repo
+  apply_requirements: [reviewed]`,
+			expErr: "repos: (0: (apply_requirements: \"reviewed\" not supported, only approved and mergeable are supported.).).",
+		},
+		{
+			description: "drift detection",
+			input: `
+repos:
+- id: github.com/owner/repo
+  drift_detection:
+    notifiers:
+    - kind: vcs_issue
+    - kind: slack
+      channel: infra
+    - kind: webhook
+      url: https://example.com/drift
+- id: github.com/owner/disabled
+  drift_detection:
+    enabled: false`,
+			exp: valid.ServerConfig{
+				Repos: []valid.Repo{
+					{
+						ID:                   "github.com/owner/repo",
+						AllowCustomWorkflows: true,
+						DriftDetection: &valid.DriftDetection{
+							Notifiers: []valid.DriftNotifier{
+								{Kind: "vcs_issue"},
+								{Kind: "slack", Channel: "infra"},
+								{Kind: "webhook", URL: "https://example.com/drift"},
+							},
+						},
+					},
+					{
+						ID:                   "github.com/owner/disabled",
+						AllowCustomWorkflows: true,
+					},
+				},
+			},
+		},
+		{
+			description: "drift detection with wildcard id",
+			input: `
+repos:
+- id: github.com/owner/*
+  drift_detection: {}`,
+			expErr: "repos: (0: (drift_detection: can only be enabled for a single repo, not for an id with a wildcard or regex.).).",
+		},
+		{
+			description: "drift notifier invalid kind",
+			input: `
+repos:
+- id: github.com/owner/repo
+  drift_detection:
+    notifiers:
+    - kind: email`,
+			expErr: "repos: (0: (drift_detection: (notifiers: (0: (kind: \"email\" is not a valid kind, only vcs_issue, slack, webhook are supported.).).).).).",
+		},
+		{
+			description: "slack drift notifier missing channel",
+			input: `
+repos:
+- id: github.com/owner/repo
+  drift_detection:
+    notifiers:
+    - kind: slack`,
+			expErr: "repos: (0: (drift_detection: (notifiers: (0: (channel: is required for slack notifiers.).).).).).",
+		},
+		{
+			description: "workflow hooks",
+			input: `
+repos:
+- id: /.*/
+  pre_workflow_hooks:
+  - run: ./generate-backends.sh
+  - run: terragrunt-atlantis-config generate --output atlantis.yaml
+  post_workflow_hooks:
+  - run: rm -rf .terragrunt-cache`,
+			exp: valid.ServerConfig{
+				Repos: []valid.Repo{
+					{
+						ID:                   "/.*/",
+						AllowCustomWorkflows: true,
+						PreWorkflowHooks: []valid.WorkflowHook{
+							{RunCommand: "./generate-backends.sh"},
+							{RunCommand: "terragrunt-atlantis-config generate --output atlantis.yaml"},
+						},
+						PostWorkflowHooks: []valid.WorkflowHook{
+							{RunCommand: "rm -rf .terragrunt-cache"},
+						},
+					},
+				},
+			},
+		},
+		{
+			description: "destroy apply requirements",
+			input: `
+repos:
+- id: github.com/owner/repo
+  destroy_apply_requirements: [{all_of: [approved, mergeable]}]`,
+			exp: valid.ServerConfig{
+				Repos: []valid.Repo{
+					{
+						ID:                            "github.com/owner/repo",
+						AllowCustomWorkflows:          true,
+						DestroyApplyRequirementGroups: []valid.ApplyRequirementGroup{{Requirements: []string{"approved", "mergeable"}}},
+					},
+				},
+			},
+		},
+		{
+			description: "invalid destroy apply requirement",
+			input: `
+repos:
+- id: github.com/owner/repo
+  destroy_apply_requirements: [reviewed]`,
+			expErr: "repos: (0: (destroy_apply_requirements: \"reviewed\" not supported, only approved and mergeable are supported.).).",
+		},
+		{
+			description: "detect workspaces",
+			input: `
+repos:
+- id: github.com/owner/repo
+  detect_workspaces: true`,
+			exp: valid.ServerConfig{
+				Repos: []valid.Repo{
+					{
+						ID:                   "github.com/owner/repo",
+						AllowCustomWorkflows: true,
+						DetectWorkspaces:     true,
+					},
+				},
+			},
+		},
+		{
+			description: "cost estimation opt-out",
+			input: `
+repos:
+- id: github.com/owner/repo
+  cost_estimation: false`,
+			exp: valid.ServerConfig{
+				Repos: []valid.Repo{
+					{
+						ID:                    "github.com/owner/repo",
+						AllowCustomWorkflows:  true,
+						DisableCostEstimation: true,
+					},
+				},
+			},
+		},
+		{
+			description: "workflow hook missing run",
+			input: `
+repos:
+- id: github.com/owner/repo
+  post_workflow_hooks:
+  - {}`,
+			expErr: "repos: (0: (post_workflow_hooks: (0: (run: cannot be blank.).).).).",
+		},
+		{
+			description: "policy set missing path",
+			input: `
+repos:
+- id: github.com/owner/repo
+  policy_sets:
+  - name: security`,
+			expErr: "repos: (0: (policy_sets: (0: (path: cannot be blank.).).).).",
+		},
+		{
+			description: "policy set relative path",
+			input: `
+repos:
+- id: github.com/owner/repo
+  policy_sets:
+  - name: security
+    path: policies/security`,
+			expErr: "repos: (0: (policy_sets: (0: (path: must be an absolute path.).).).).",
+		},
+		{
+			description: "duplicate policy set names",
+			input: `
+repos:
+- id: github.com/owner/repo
+  policy_sets:
+  - name: security
+    path: /policies/a
+  - name: security
+    path: /policies/b`,
+			expErr: "repos: (0: (policy_sets: found two policy sets with the name \"security\", each policy set must have a unique name.).).",
+		},
+		{
+			description: "unknown key",
+			input: `
+repos:
+- id: github.com/owner/repo
+  unknown: true`,
+			expErr: "yaml: unmarshal errors:\n  line 3: field unknown not found in struct raw.Repo",
+		},
+	}
+
+	tmpDir, cleanup := TempDir(t)
+	defer cleanup()
+	configFile := filepath.Join(tmpDir, "repos.yaml")
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			err := ioutil.WriteFile(configFile, []byte(c.input), 0600)
+			Ok(t, err)
+
+			r := yaml.ParserValidator{}
+			act, err := r.ReadServerConfig(configFile)
+			if c.expErr != "" {
+				ErrEquals(t, "parsing "+configFile+": "+c.expErr, err)
+				return
+			}
+			Ok(t, err)
+			Equals(t, c.exp, act)
+		})
+	}
+}
+
+func TestServerConfig_FindRepo(t *testing.T) {
+	cfg := valid.ServerConfig{
+		Repos: []valid.Repo{
+			{ID: "github.com/governed/*", RequireAtlantisYAML: true},
+			{ID: "github.com/governed/legacy", RequireAtlantisYAML: false},
+		},
+	}
+	Equals(t, &cfg.Repos[0], cfg.FindRepo("Governed/repo", "github.com"))
+	Equals(t, &cfg.Repos[1], cfg.FindRepo("governed/legacy", "github.com"))
+	Assert(t, cfg.FindRepo("governed/repo", "gitlab.com") == nil, "exp no match for other hostname")
+
+	t.Log("ids wrapped in /'s are regexes")
+	cfg.Repos = append(cfg.Repos, valid.Repo{ID: "/^github.com/myorg/(infra|network)$/"})
+	Equals(t, &cfg.Repos[2], cfg.FindRepo("MyOrg/network", "github.com"))
+	Assert(t, cfg.FindRepo("myorg/infra-old", "github.com") == nil, "exp no match for anchored regex")
+}
+
+func TestServerConfig_WorkflowNames(t *testing.T) {
+	cfg := valid.ServerConfig{
+		Workflows: map[string]valid.Workflow{"b": {}, "a": {}},
+	}
+	Equals(t, []string{"a", "b"}, cfg.WorkflowNames())
+}
+
+func TestRepo_IsWorkflowAllowed(t *testing.T) {
+	repo := valid.Repo{Workflow: String("default"), AllowedWorkflows: []string{"other"}}
+	Assert(t, repo.IsWorkflowAllowed("default"), "exp the repo's workflow to be allowed")
+	Assert(t, repo.IsWorkflowAllowed("other"), "exp allowed workflows to be allowed")
+	Assert(t, !repo.IsWorkflowAllowed("unknown"), "exp other workflows not to be allowed")
+}
+
+func TestRepo_CheckChangesAllowed(t *testing.T) {
+	Ok(t, valid.Repo{}.CheckChangesAllowed(""))
+	ErrEquals(t, "this repo is plan-only in the server-side repo config", valid.Repo{PlanOnly: true}.CheckChangesAllowed("main"))
+
+	repo := valid.Repo{ApplyBranches: []string{"main", "release/*", "/^hotfix-[0-9]+$/"}}
+	for _, branch := range []string{"main", "release/1.0", "hotfix-12"} {
+		Ok(t, repo.CheckChangesAllowed(branch))
+	}
+	ErrEquals(t, "only pull requests into main, release/*, /^hotfix-[0-9]+$/ can be applied in this repo, not ones into feature", repo.CheckChangesAllowed("feature"))
+	ErrEquals(t, "only pull requests into main, release/*, /^hotfix-[0-9]+$/ can be applied in this repo, not ones into mainline", repo.CheckChangesAllowed("mainline"))
+	ErrEquals(t, "only pull requests into main, release/*, /^hotfix-[0-9]+$/ can be applied in this repo and this pull request's base branch isn't known", repo.CheckChangesAllowed(""))
+}
+
+func TestRepo_AutoplansOn(t *testing.T) {
+	Assert(t, valid.Repo{}.AutoplansOn("pushed"), "exp repos without autoplan_on to be autoplanned on every event")
+	repo := valid.Repo{AutoplanOn: []string{"opened", "ready_for_review"}}
+	Assert(t, repo.AutoplansOn("opened"), "exp listed events to be autoplanned on")
+	Assert(t, !repo.AutoplansOn("pushed"), "exp other events not to be autoplanned on")
+	Assert(t, !valid.Repo{AutoplanOn: []string{}}.AutoplansOn("opened"), "exp an empty autoplan_on to never autoplan")
+}
+
+func TestReadConfig_ServerWorkflows(t *testing.T) {
+	tmpDir, cleanup := TempDir(t)
+	defer cleanup()
+	err := ioutil.WriteFile(filepath.Join(tmpDir, "atlantis.yaml"), []byte(`
+version: 2
+projects:
+- dir: .
+  workflow: terragrunt`), 0600)
+	Ok(t, err)
+
+	r := yaml.ParserValidator{}
+	_, err = r.ReadConfig(tmpDir)
+	ErrEquals(t, "parsing atlantis.yaml: workflow \"terragrunt\" is not defined", err)
+
+	t.Log("projects can use workflows defined in the server-side repo config")
+	r.ServerWorkflows = []string{"terragrunt"}
+	cfg, err := r.ReadConfig(tmpDir)
+	Ok(t, err)
+	Equals(t, String("terragrunt"), cfg.Projects[0].Workflow)
+}
+
+func TestRepo_IsPolicyOwner(t *testing.T) {
+	repo := valid.Repo{PolicyOwners: []string{"alice", "Bob"}}
+	Assert(t, repo.IsPolicyOwner("alice"), "exp alice to be an owner")
+	Assert(t, repo.IsPolicyOwner("bob"), "exp usernames to be case insensitive")
+	Assert(t, !repo.IsPolicyOwner("mallory"), "exp mallory not to be an owner")
+	Assert(t, !valid.Repo{}.IsPolicyOwner("alice"), "exp no owners if none are set")
+}
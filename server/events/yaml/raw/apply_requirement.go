@@ -0,0 +1,117 @@
+package raw
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+)
+
+const (
+	AnyOfApplyRequirementKey = "any_of"
+	AllOfApplyRequirementKey = "all_of"
+)
+
+// ApplyRequirement is a single element of apply_requirements. In YAML, it can
+// be set as
+// 1. A single string naming a requirement:
+//    - approved
+// 2. A map with a single any_of or all_of key for a group of requirements:
+//    - any_of: [approved, mergeable]
+// Since the top-level list must be met in its entirety, a flat list of
+// strings means "all of".
+type ApplyRequirement struct {
+	// Name will be set in case #1 above.
+	Name string
+	// AnyOf will be set in case #2 above if the key was any_of.
+	AnyOf []string `yaml:"any_of,omitempty"`
+	// AllOf will be set in case #2 above if the key was all_of.
+	AllOf []string `yaml:"all_of,omitempty"`
+}
+
+func (a *ApplyRequirement) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	// First try to unmarshal as a single string, ex.
+	// apply_requirements:
+	// - approved
+	// We validate if it's a legal string later.
+	var name string
+	if err := unmarshal(&name); err == nil {
+		a.Name = name
+		return nil
+	}
+
+	// Otherwise this should be a group, ex.
+	// apply_requirements:
+	// - any_of: [approved, mergeable]
+	// We validate that only one of the keys is set later.
+	type group struct {
+		AnyOf []string `yaml:"any_of"`
+		AllOf []string `yaml:"all_of"`
+	}
+	var g group
+	if err := unmarshal(&g); err != nil {
+		return err
+	}
+	if g.AnyOf == nil && g.AllOf == nil {
+		return fmt.Errorf("apply requirement groups must set one of %s or %s", AnyOfApplyRequirementKey, AllOfApplyRequirementKey)
+	}
+	a.AnyOf = g.AnyOf
+	a.AllOf = g.AllOf
+	return nil
+}
+
+func (a ApplyRequirement) Validate() error {
+	if a.isGroup() {
+		if a.Name != "" || (a.AnyOf != nil && a.AllOf != nil) {
+			return fmt.Errorf("apply requirement groups must set only one of %s or %s", AnyOfApplyRequirementKey, AllOfApplyRequirementKey)
+		}
+		reqs := a.AnyOf
+		key := AnyOfApplyRequirementKey
+		if a.AllOf != nil {
+			reqs = a.AllOf
+			key = AllOfApplyRequirementKey
+		}
+		if len(reqs) == 0 {
+			return fmt.Errorf("%s cannot be empty", key)
+		}
+		for _, r := range reqs {
+			if err := validApplyRequirementName(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if a.Name == "" {
+		return errors.New("apply requirement cannot be empty")
+	}
+	return validApplyRequirementName(a.Name)
+}
+
+func (a ApplyRequirement) isGroup() bool {
+	return a.AnyOf != nil || a.AllOf != nil
+}
+
+func validApplyRequirementName(r string) error {
+	if r != ApprovedApplyRequirement && r != MergeableApplyRequirement {
+		return fmt.Errorf("%q not supported, only %s and %s are supported", r, ApprovedApplyRequirement, MergeableApplyRequirement)
+	}
+	return nil
+}
+
+// applyRequirementsToValid splits reqs into the requirements that must all
+// be met and the groups of requirements.
+func applyRequirementsToValid(reqs []ApplyRequirement) ([]string, []valid.ApplyRequirementGroup) {
+	var names []string
+	var groups []valid.ApplyRequirementGroup
+	for _, r := range reqs {
+		switch {
+		case r.AnyOf != nil:
+			groups = append(groups, valid.ApplyRequirementGroup{AnyOf: true, Requirements: r.AnyOf})
+		case r.AllOf != nil:
+			groups = append(groups, valid.ApplyRequirementGroup{AnyOf: false, Requirements: r.AllOf})
+		default:
+			names = append(names, r.Name)
+		}
+	}
+	return names, groups
+}
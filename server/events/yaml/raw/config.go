@@ -2,6 +2,7 @@ package raw
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/go-ozzo/ozzo-validation"
 	"github.com/runatlantis/atlantis/server/events/yaml/valid"
@@ -9,25 +10,38 @@ import (
 
 // Config is the representation for the whole config file at the top level.
 type Config struct {
-	Version   *int                `yaml:"version,omitempty"`
-	Projects  []Project           `yaml:"projects,omitempty"`
-	Workflows map[string]Workflow `yaml:"workflows,omitempty"`
+	Version                   *int                `yaml:"version,omitempty"`
+	Projects                  []Project           `yaml:"projects,omitempty"`
+	Workflows                 map[string]Workflow `yaml:"workflows,omitempty"`
+	Automerge                 *bool               `yaml:"automerge,omitempty"`
+	DeleteSourceBranchOnMerge *bool               `yaml:"delete_source_branch_on_merge,omitempty"`
 }
 
 func (c Config) Validate() error {
-	equals2 := func(value interface{}) error {
+	equals2Or3 := func(value interface{}) error {
 		asIntPtr := value.(*int)
 		if asIntPtr == nil {
 			return errors.New("is required. If you've just upgraded Atlantis you need to rewrite your atlantis.yaml for version 2. See www.runatlantis.io/docs/upgrading-atlantis-yaml-to-version-2.html")
 		}
-		if *asIntPtr != 2 {
-			return errors.New("must equal 2")
+		if *asIntPtr != 2 && *asIntPtr != 3 {
+			return errors.New("must equal 2 or 3")
+		}
+		return nil
+	}
+	matrixRequiresVersion3 := func(value interface{}) error {
+		if c.Version == nil || *c.Version >= 3 {
+			return nil
+		}
+		for i, p := range value.([]Project) {
+			if p.Matrix != nil {
+				return fmt.Errorf("%d: matrix requires version 3", i)
+			}
 		}
 		return nil
 	}
 	return validation.ValidateStruct(&c,
-		validation.Field(&c.Version, validation.By(equals2)),
-		validation.Field(&c.Projects),
+		validation.Field(&c.Version, validation.By(equals2Or3)),
+		validation.Field(&c.Projects, validation.By(matrixRequiresVersion3)),
 		validation.Field(&c.Workflows),
 	)
 }
@@ -43,8 +57,10 @@ func (c Config) ToValid() valid.Config {
 		validWorkflows[k] = v.ToValid()
 	}
 	return valid.Config{
-		Version:   *c.Version,
-		Projects:  validProjects,
-		Workflows: validWorkflows,
+		Version:                   *c.Version,
+		Projects:                  validProjects,
+		Workflows:                 validWorkflows,
+		Automerge:                 c.Automerge != nil && *c.Automerge,
+		DeleteSourceBranchOnMerge: c.DeleteSourceBranchOnMerge != nil && *c.DeleteSourceBranchOnMerge,
 	}
 }
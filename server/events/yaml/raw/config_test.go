@@ -123,7 +123,7 @@ workflows:
 							WhenModified: []string{},
 							Enabled:      Bool(false),
 						},
-						ApplyRequirements: []string{"mergeable"},
+						ApplyRequirements: []raw.ApplyRequirement{{Name: "mergeable"}},
 					},
 				},
 				Workflows: map[string]raw.Workflow{
@@ -171,7 +171,7 @@ func TestConfig_Validate(t *testing.T) {
 			input: raw.Config{
 				Version: Int(1),
 			},
-			expErr: "version: must equal 2.",
+			expErr: "version: must equal 2 or 3.",
 		},
 	}
 	validation.ErrorTag = "yaml"
@@ -0,0 +1,63 @@
+package raw
+
+import (
+	"errors"
+	"path"
+	"strings"
+
+	"github.com/go-ozzo/ozzo-validation"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+)
+
+// ExtraRepo is another repo, ex. of shared modules, that's cloned into the
+// project's workspace before it's planned.
+type ExtraRepo struct {
+	// Repo is the repo's hostname and full name, ex. github.com/owner/modules.
+	Repo string `yaml:"repo,omitempty"`
+	// Ref is the branch, tag or commit to clone.
+	Ref string `yaml:"ref,omitempty"`
+	// Dir is where the repo is cloned, relative to the root of the project's
+	// repo.
+	Dir string `yaml:"dir,omitempty"`
+}
+
+func (e ExtraRepo) Validate() error {
+	validRepo := func(value interface{}) error {
+		parts := strings.Split(value.(string), "/")
+		if len(parts) < 3 {
+			return errors.New("must be a hostname and repo name, ex. github.com/owner/repo")
+		}
+		for _, p := range parts {
+			if p == "" || p == "." || p == ".." {
+				return errors.New("must be a hostname and repo name, ex. github.com/owner/repo")
+			}
+		}
+		return nil
+	}
+	validRef := func(value interface{}) error {
+		if strings.HasPrefix(value.(string), "-") {
+			return errors.New("cannot start with '-'")
+		}
+		return nil
+	}
+	validDir := func(value interface{}) error {
+		dir := value.(string)
+		if path.IsAbs(dir) || path.Clean(dir) == "." || strings.Contains(dir, "..") {
+			return errors.New("must be a subdirectory of the repo and cannot contain '..'")
+		}
+		return nil
+	}
+	return validation.ValidateStruct(&e,
+		validation.Field(&e.Repo, validation.Required, validation.By(validRepo)),
+		validation.Field(&e.Ref, validation.Required, validation.By(validRef)),
+		validation.Field(&e.Dir, validation.Required, validation.By(validDir)),
+	)
+}
+
+func (e ExtraRepo) ToValid() valid.ExtraRepo {
+	return valid.ExtraRepo{
+		Repo: e.Repo,
+		Ref:  e.Ref,
+		Dir:  path.Clean(e.Dir),
+	}
+}
@@ -0,0 +1,51 @@
+package raw_test
+
+import (
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/yaml/raw"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+	. "github.com/runatlantis/atlantis/testing"
+	"gopkg.in/yaml.v2"
+)
+
+func TestExtraRepo_UnmarshalYAML(t *testing.T) {
+	var e raw.ExtraRepo
+	err := yaml.UnmarshalStrict([]byte(`
+repo: github.com/owner/modules
+ref: v1.2.0
+dir: modules
+`), &e)
+	Ok(t, err)
+	Equals(t, raw.ExtraRepo{Repo: "github.com/owner/modules", Ref: "v1.2.0", Dir: "modules"}, e)
+}
+
+func TestExtraRepo_Validate(t *testing.T) {
+	Ok(t, raw.ExtraRepo{Repo: "github.com/owner/modules", Ref: "v1.2.0", Dir: "vendor/modules"}.Validate())
+	Ok(t, raw.ExtraRepo{Repo: "gitlab.com/group/subgroup/modules", Ref: "main", Dir: "modules"}.Validate())
+
+	cases := []struct {
+		extra  raw.ExtraRepo
+		expErr string
+	}{
+		{raw.ExtraRepo{Ref: "main", Dir: "modules"}, "repo: cannot be blank."},
+		{raw.ExtraRepo{Repo: "owner/modules", Ref: "main", Dir: "modules"}, "repo: must be a hostname and repo name, ex. github.com/owner/repo."},
+		{raw.ExtraRepo{Repo: "github.com/owner/../modules", Ref: "main", Dir: "modules"}, "repo: must be a hostname and repo name, ex. github.com/owner/repo."},
+		{raw.ExtraRepo{Repo: "github.com/owner/modules", Dir: "modules"}, "ref: cannot be blank."},
+		{raw.ExtraRepo{Repo: "github.com/owner/modules", Ref: "--upload-pack=touch", Dir: "modules"}, "ref: cannot start with '-'."},
+		{raw.ExtraRepo{Repo: "github.com/owner/modules", Ref: "main"}, "dir: cannot be blank."},
+		{raw.ExtraRepo{Repo: "github.com/owner/modules", Ref: "main", Dir: "."}, "dir: must be a subdirectory of the repo and cannot contain '..'."},
+		{raw.ExtraRepo{Repo: "github.com/owner/modules", Ref: "main", Dir: "../modules"}, "dir: must be a subdirectory of the repo and cannot contain '..'."},
+		{raw.ExtraRepo{Repo: "github.com/owner/modules", Ref: "main", Dir: "/modules"}, "dir: must be a subdirectory of the repo and cannot contain '..'."},
+	}
+	for _, c := range cases {
+		t.Run(c.expErr, func(t *testing.T) {
+			ErrEquals(t, c.expErr, c.extra.Validate())
+		})
+	}
+}
+
+func TestExtraRepo_ToValid(t *testing.T) {
+	Equals(t, valid.ExtraRepo{Repo: "github.com/owner/modules", Ref: "v1.2.0", Dir: "vendor/modules"},
+		raw.ExtraRepo{Repo: "github.com/owner/modules", Ref: "v1.2.0", Dir: "vendor/modules/"}.ToValid())
+}
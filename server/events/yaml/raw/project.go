@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/url"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/go-ozzo/ozzo-validation"
@@ -19,16 +20,39 @@ const (
 )
 
 type Project struct {
-	Name              *string   `yaml:"name,omitempty"`
-	Dir               *string   `yaml:"dir,omitempty"`
-	Workspace         *string   `yaml:"workspace,omitempty"`
-	Workflow          *string   `yaml:"workflow,omitempty"`
-	TerraformVersion  *string   `yaml:"terraform_version,omitempty"`
-	Autoplan          *Autoplan `yaml:"autoplan,omitempty"`
-	ApplyRequirements []string  `yaml:"apply_requirements,omitempty"`
+	Name              *string            `yaml:"name,omitempty"`
+	Dir               *string            `yaml:"dir,omitempty"`
+	Workspace         *string            `yaml:"workspace,omitempty"`
+	Workflow          *string            `yaml:"workflow,omitempty"`
+	TerraformVersion  *string            `yaml:"terraform_version,omitempty"`
+	Autoplan          *Autoplan          `yaml:"autoplan,omitempty"`
+	ApplyRequirements []ApplyRequirement `yaml:"apply_requirements,omitempty"`
+	WarnOnDestroy     *bool              `yaml:"warn_on_destroy,omitempty"`
+	DependsOn         []string           `yaml:"depends_on,omitempty"`
+	TerraformCloud    *TerraformCloud    `yaml:"terraform_cloud,omitempty"`
+	ExtraRepos        []ExtraRepo        `yaml:"extra_repos,omitempty"`
+	Matrix            *ProjectMatrix     `yaml:"matrix,omitempty"`
+	// Branch is a regex wrapped in /'s that the base branch of pull requests
+	// must match for the project to be autoplanned.
+	Branch *string `yaml:"branch,omitempty"`
 }
 
 func (p Project) Validate() error {
+	// The projects a matrix generates are validated once they're expanded.
+	if p.Matrix != nil {
+		unset := func(value interface{}) error {
+			if value.(*string) != nil {
+				return errors.New("cannot be set with matrix")
+			}
+			return nil
+		}
+		return validation.ValidateStruct(&p,
+			validation.Field(&p.Dir, validation.By(unset)),
+			validation.Field(&p.Workspace, validation.By(unset)),
+			validation.Field(&p.Matrix),
+		)
+	}
+
 	hasDotDot := func(value interface{}) error {
 		if strings.Contains(*value.(*string), "..") {
 			return errors.New("cannot contain '..'")
@@ -36,10 +60,10 @@ func (p Project) Validate() error {
 		return nil
 	}
 	validApplyReq := func(value interface{}) error {
-		reqs := value.([]string)
+		reqs := value.([]ApplyRequirement)
 		for _, r := range reqs {
-			if r != ApprovedApplyRequirement && r != MergeableApplyRequirement {
-				return fmt.Errorf("%q not supported, only %s and %s are supported", r, ApprovedApplyRequirement, MergeableApplyRequirement)
+			if err := r.Validate(); err != nil {
+				return err
 			}
 		}
 		return nil
@@ -70,9 +94,28 @@ func (p Project) Validate() error {
 		validation.Field(&p.ApplyRequirements, validation.By(validApplyReq)),
 		validation.Field(&p.TerraformVersion, validation.By(validTFVersion)),
 		validation.Field(&p.Name, validation.By(validName)),
+		validation.Field(&p.TerraformCloud),
+		validation.Field(&p.ExtraRepos),
+		validation.Field(&p.Branch, validation.By(validBranchRegex)),
 	)
 }
 
+// validBranchRegex validates a branch regex wrapped in /'s, ex. /^main$/.
+func validBranchRegex(value interface{}) error {
+	strPtr := value.(*string)
+	if strPtr == nil {
+		return nil
+	}
+	expr, ok := valid.IDRegex(*strPtr)
+	if !ok {
+		return fmt.Errorf("%q must be a regex wrapped in /'s, ex. /^main$/", *strPtr)
+	}
+	if _, err := regexp.Compile(expr); err != nil {
+		return fmt.Errorf("regex %q could not be parsed: %s", expr, err)
+	}
+	return nil
+}
+
 func (p Project) ToValid() valid.Project {
 	var v valid.Project
 	cleanedDir := filepath.Clean(*p.Dir)
@@ -98,13 +141,39 @@ func (p Project) ToValid() valid.Project {
 	}
 
 	// There are no default apply requirements.
-	v.ApplyRequirements = p.ApplyRequirements
+	v.ApplyRequirements, v.ApplyRequirementGroups = applyRequirementsToValid(p.ApplyRequirements)
 
 	v.Name = p.Name
 
+	// By default we don't warn on destroys.
+	v.WarnOnDestroy = p.WarnOnDestroy != nil && *p.WarnOnDestroy
+
+	v.DependsOn = p.DependsOn
+
+	if p.TerraformCloud != nil {
+		tfc := p.TerraformCloud.ToValid()
+		v.TerraformCloud = &tfc
+	}
+
+	for _, e := range p.ExtraRepos {
+		v.ExtraRepos = append(v.ExtraRepos, e.ToValid())
+	}
+
+	v.Branch = branchRegexToValid(p.Branch)
+
 	return v
 }
 
+// branchRegexToValid returns the regex in branch without its /'s or nil if
+// branch isn't set.
+func branchRegexToValid(branch *string) *string {
+	if branch == nil {
+		return nil
+	}
+	expr, _ := valid.IDRegex(*branch)
+	return &expr
+}
+
 // validProjectName returns true if the project name is valid.
 // Since the name might be used in URLs and definitely in files we don't
 // support any characters that must be url escaped *except* for '/' because
@@ -0,0 +1,135 @@
+package raw
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-ozzo/ozzo-validation"
+	"github.com/pkg/errors"
+)
+
+// ProjectMatrix generates a project for every combination of its dirs and
+// workspaces. Dirs can be glob patterns, ex. modules/*, that match
+// directories in the repo.
+type ProjectMatrix struct {
+	Dirs       []string `yaml:"dir,omitempty"`
+	Workspaces []string `yaml:"workspace,omitempty"`
+}
+
+func (m ProjectMatrix) Validate() error {
+	validDirs := func(value interface{}) error {
+		for _, dir := range value.([]string) {
+			if dir == "" {
+				return errors.New("cannot contain an empty dir")
+			}
+			if strings.Contains(dir, "..") {
+				return errors.New("cannot contain '..'")
+			}
+			if _, err := filepath.Match(dir, ""); err != nil {
+				return fmt.Errorf("%q is not a valid pattern", dir)
+			}
+		}
+		return nil
+	}
+	validWorkspaces := func(value interface{}) error {
+		for _, ws := range value.([]string) {
+			if ws == "" {
+				return errors.New("cannot contain an empty workspace")
+			}
+		}
+		return nil
+	}
+	return validation.ValidateStruct(&m,
+		validation.Field(&m.Dirs, validation.Required, validation.By(validDirs)),
+		validation.Field(&m.Workspaces, validation.By(validWorkspaces)),
+	)
+}
+
+// Expand returns the projects generated by p's matrix with the dir patterns
+// matched against repoDir. {dir} and {workspace} in p's name are replaced
+// with each project's dir and workspace. If p has no matrix it's returned
+// as is.
+func (p Project) Expand(repoDir string) ([]Project, error) {
+	if p.Matrix == nil {
+		return []Project{p}, nil
+	}
+	dirs, err := p.Matrix.matchDirs(repoDir)
+	if err != nil {
+		return nil, err
+	}
+	workspaces := p.Matrix.Workspaces
+	if len(workspaces) == 0 {
+		workspaces = []string{DefaultWorkspace}
+	}
+
+	var projects []Project
+	for _, dir := range dirs {
+		for _, ws := range workspaces {
+			dir, ws := dir, ws
+			generated := p
+			generated.Matrix = nil
+			generated.Dir = &dir
+			generated.Workspace = &ws
+			if p.Name != nil {
+				name := strings.NewReplacer("{dir}", dir, "{workspace}", ws).Replace(*p.Name)
+				generated.Name = &name
+			}
+			projects = append(projects, generated)
+		}
+	}
+	return projects, nil
+}
+
+// matchDirs returns the dirs relative to repoDir that m's dirs match, in
+// order and without duplicates. A dir that isn't a pattern is returned even
+// if it doesn't exist, like a project's dir. Patterns don't match hidden
+// directories, ex. .git.
+func (m ProjectMatrix) matchDirs(repoDir string) ([]string, error) {
+	var dirs []string
+	seen := make(map[string]bool)
+	add := func(dir string) {
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	for _, pattern := range m.Dirs {
+		if !strings.ContainsAny(pattern, `*?[\`) {
+			add(filepath.ToSlash(filepath.Clean(pattern)))
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(repoDir, pattern))
+		if err != nil {
+			return nil, errors.Wrapf(err, "matching dir %q", pattern)
+		}
+		found := false
+		for _, match := range matches {
+			if info, err := os.Stat(match); err != nil || !info.IsDir() {
+				continue
+			}
+			rel, err := filepath.Rel(repoDir, match)
+			if err != nil || isHiddenPath(rel) {
+				continue
+			}
+			add(filepath.ToSlash(rel))
+			found = true
+		}
+		if !found {
+			return nil, fmt.Errorf("dir %q matches no directories", pattern)
+		}
+	}
+	return dirs, nil
+}
+
+// isHiddenPath returns true if any element of the relative path rel starts
+// with a '.'.
+func isHiddenPath(rel string) bool {
+	for _, elem := range strings.Split(filepath.ToSlash(rel), "/") {
+		if strings.HasPrefix(elem, ".") && elem != "." {
+			return true
+		}
+	}
+	return false
+}
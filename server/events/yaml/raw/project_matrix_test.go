@@ -0,0 +1,106 @@
+package raw_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-ozzo/ozzo-validation"
+	"github.com/runatlantis/atlantis/server/events/yaml/raw"
+	. "github.com/runatlantis/atlantis/testing"
+	"gopkg.in/yaml.v2"
+)
+
+func TestProjectMatrix_UnmarshalYAML(t *testing.T) {
+	var p raw.Project
+	err := yaml.UnmarshalStrict([]byte(`
+name: "{dir}-{workspace}"
+matrix:
+  dir: [modules/*, network]
+  workspace: [staging, production]
+`), &p)
+	Ok(t, err)
+	Equals(t, raw.Project{
+		Name: String("{dir}-{workspace}"),
+		Matrix: &raw.ProjectMatrix{
+			Dirs:       []string{"modules/*", "network"},
+			Workspaces: []string{"staging", "production"},
+		},
+	}, p)
+}
+
+func TestProjectMatrix_Validate(t *testing.T) {
+	validation.ErrorTag = "yaml"
+	Ok(t, raw.Project{Matrix: &raw.ProjectMatrix{Dirs: []string{"modules/*"}}}.Validate())
+
+	cases := []struct {
+		project raw.Project
+		expErr  string
+	}{
+		{raw.Project{Matrix: &raw.ProjectMatrix{}}, "matrix: (dir: cannot be blank.)."},
+		{raw.Project{Matrix: &raw.ProjectMatrix{Dirs: []string{"../modules"}}}, "matrix: (dir: cannot contain '..'.)."},
+		{raw.Project{Matrix: &raw.ProjectMatrix{Dirs: []string{""}}}, "matrix: (dir: cannot contain an empty dir.)."},
+		{raw.Project{Matrix: &raw.ProjectMatrix{Dirs: []string{"modules/["}}}, `matrix: (dir: "modules/[" is not a valid pattern.).`},
+		{raw.Project{Matrix: &raw.ProjectMatrix{Dirs: []string{"."}, Workspaces: []string{""}}}, "matrix: (workspace: cannot contain an empty workspace.)."},
+		{raw.Project{Dir: String("."), Matrix: &raw.ProjectMatrix{Dirs: []string{"."}}}, "dir: cannot be set with matrix."},
+		{raw.Project{Workspace: String("staging"), Matrix: &raw.ProjectMatrix{Dirs: []string{"."}}}, "workspace: cannot be set with matrix."},
+	}
+	for _, c := range cases {
+		t.Run(c.expErr, func(t *testing.T) {
+			ErrEquals(t, c.expErr, c.project.Validate())
+		})
+	}
+}
+
+func TestProject_Expand(t *testing.T) {
+	tmpDir, cleanup := TempDir(t)
+	defer cleanup()
+	for _, dir := range []string{"modules/vpc", "modules/dns", "modules/.hidden", "network"} {
+		Ok(t, os.MkdirAll(filepath.Join(tmpDir, dir), 0700))
+	}
+	_, err := os.Create(filepath.Join(tmpDir, "modules", "README.md"))
+	Ok(t, err)
+
+	t.Run("no matrix", func(t *testing.T) {
+		p := raw.Project{Dir: String(".")}
+		projects, err := p.Expand(tmpDir)
+		Ok(t, err)
+		Equals(t, []raw.Project{p}, projects)
+	})
+
+	t.Run("dirs and workspaces", func(t *testing.T) {
+		p := raw.Project{
+			Name:     String("{dir}-{workspace}"),
+			Workflow: String("custom"),
+			Matrix: &raw.ProjectMatrix{
+				Dirs:       []string{"modules/*", "network", "modules/vpc"},
+				Workspaces: []string{"staging", "production"},
+			},
+		}
+		projects, err := p.Expand(tmpDir)
+		Ok(t, err)
+		var exp []raw.Project
+		for _, dir := range []string{"modules/dns", "modules/vpc", "network"} {
+			for _, ws := range []string{"staging", "production"} {
+				exp = append(exp, raw.Project{
+					Name:      String(dir + "-" + ws),
+					Dir:       String(dir),
+					Workspace: String(ws),
+					Workflow:  String("custom"),
+				})
+			}
+		}
+		Equals(t, exp, projects)
+	})
+
+	t.Run("default workspace", func(t *testing.T) {
+		projects, err := raw.Project{Matrix: &raw.ProjectMatrix{Dirs: []string{"missing"}}}.Expand(tmpDir)
+		Ok(t, err)
+		Equals(t, []raw.Project{{Dir: String("missing"), Workspace: String("default")}}, projects)
+	})
+
+	t.Run("pattern matches nothing", func(t *testing.T) {
+		_, err := raw.Project{Matrix: &raw.ProjectMatrix{Dirs: []string{"stacks/*"}}}.Expand(tmpDir)
+		ErrEquals(t, `dir "stacks/*" matches no directories`, err)
+	})
+}
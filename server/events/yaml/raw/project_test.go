@@ -53,7 +53,44 @@ apply_requirements:
 					WhenModified: []string{},
 					Enabled:      Bool(false),
 				},
-				ApplyRequirements: []string{"mergeable"},
+				ApplyRequirements: []raw.ApplyRequirement{{Name: "mergeable"}},
+			},
+		},
+		{
+			description: "apply requirement groups",
+			input: `
+dir: mydir
+apply_requirements:
+- mergeable
+- any_of: [approved, mergeable]
+- all_of: [approved]`,
+			exp: raw.Project{
+				Dir: String("mydir"),
+				ApplyRequirements: []raw.ApplyRequirement{
+					{Name: "mergeable"},
+					{AnyOf: []string{"approved", "mergeable"}},
+					{AllOf: []string{"approved"}},
+				},
+			},
+		},
+		{
+			description: "warn on destroy",
+			input: `
+dir: mydir
+warn_on_destroy: true`,
+			exp: raw.Project{
+				Dir:           String("mydir"),
+				WarnOnDestroy: Bool(true),
+			},
+		},
+		{
+			description: "depends on",
+			input: `
+dir: mydir
+depends_on: [network, dns]`,
+			exp: raw.Project{
+				Dir:       String("mydir"),
+				DependsOn: []string{"network", "dns"},
 			},
 		},
 	}
@@ -99,7 +136,7 @@ func TestProject_Validate(t *testing.T) {
 			description: "apply reqs with unsupported",
 			input: raw.Project{
 				Dir:               String("."),
-				ApplyRequirements: []string{"unsupported"},
+				ApplyRequirements: []raw.ApplyRequirement{{Name: "unsupported"}},
 			},
 			expErr: "apply_requirements: \"unsupported\" not supported, only approved and mergeable are supported.",
 		},
@@ -107,7 +144,7 @@ func TestProject_Validate(t *testing.T) {
 			description: "apply reqs with approved requirement",
 			input: raw.Project{
 				Dir:               String("."),
-				ApplyRequirements: []string{"approved"},
+				ApplyRequirements: []raw.ApplyRequirement{{Name: "approved"}},
 			},
 			expErr: "",
 		},
@@ -115,7 +152,7 @@ func TestProject_Validate(t *testing.T) {
 			description: "apply reqs with mergeable requirement",
 			input: raw.Project{
 				Dir:               String("."),
-				ApplyRequirements: []string{"mergeable"},
+				ApplyRequirements: []raw.ApplyRequirement{{Name: "mergeable"}},
 			},
 			expErr: "",
 		},
@@ -123,10 +160,42 @@ func TestProject_Validate(t *testing.T) {
 			description: "apply reqs with mergeable and approved requirements",
 			input: raw.Project{
 				Dir:               String("."),
-				ApplyRequirements: []string{"mergeable", "approved"},
+				ApplyRequirements: []raw.ApplyRequirement{{Name: "mergeable"}, {Name: "approved"}},
 			},
 			expErr: "",
 		},
+		{
+			description: "apply reqs with any_of group",
+			input: raw.Project{
+				Dir:               String("."),
+				ApplyRequirements: []raw.ApplyRequirement{{AnyOf: []string{"mergeable", "approved"}}},
+			},
+			expErr: "",
+		},
+		{
+			description: "apply reqs with unsupported requirement in group",
+			input: raw.Project{
+				Dir:               String("."),
+				ApplyRequirements: []raw.ApplyRequirement{{AllOf: []string{"approved", "unsupported"}}},
+			},
+			expErr: "apply_requirements: \"unsupported\" not supported, only approved and mergeable are supported.",
+		},
+		{
+			description: "apply reqs with empty group",
+			input: raw.Project{
+				Dir:               String("."),
+				ApplyRequirements: []raw.ApplyRequirement{{AnyOf: []string{}}},
+			},
+			expErr: "apply_requirements: any_of cannot be empty.",
+		},
+		{
+			description: "apply reqs with both any_of and all_of in one group",
+			input: raw.Project{
+				Dir:               String("."),
+				ApplyRequirements: []raw.ApplyRequirement{{AnyOf: []string{"approved"}, AllOf: []string{"mergeable"}}},
+			},
+			expErr: "apply_requirements: apply requirement groups must set only one of any_of or all_of.",
+		},
 		{
 			description: "empty tf version string",
 			input: raw.Project{
@@ -199,6 +268,30 @@ func TestProject_Validate(t *testing.T) {
 			},
 			expErr: `name: "namewith\\" is not allowed: must contain only URL safe characters.`,
 		},
+		{
+			description: "branch regex",
+			input: raw.Project{
+				Dir:    String("."),
+				Branch: String("/^(main|release/.*)$/"),
+			},
+			expErr: "",
+		},
+		{
+			description: "branch not wrapped in /'s",
+			input: raw.Project{
+				Dir:    String("."),
+				Branch: String("main"),
+			},
+			expErr: `branch: "main" must be a regex wrapped in /'s, ex. /^main$/.`,
+		},
+		{
+			description: "invalid branch regex",
+			input: raw.Project{
+				Dir:    String("."),
+				Branch: String("/(main/"),
+			},
+			expErr: "branch: regex \"(main\" could not be parsed: error parsing regexp: missing closing ): `(main`.",
+		},
 	}
 	validation.ErrorTag = "yaml"
 	for _, c := range cases {
@@ -249,7 +342,7 @@ func TestProject_ToValid(t *testing.T) {
 					WhenModified: []string{"hi"},
 					Enabled:      Bool(false),
 				},
-				ApplyRequirements: []string{"approved"},
+				ApplyRequirements: []raw.ApplyRequirement{{Name: "approved"}},
 				Name:              String("myname"),
 			},
 			exp: valid.Project{
@@ -265,6 +358,30 @@ func TestProject_ToValid(t *testing.T) {
 				Name:              String("myname"),
 			},
 		},
+		{
+			description: "apply requirement groups",
+			input: raw.Project{
+				Dir: String("."),
+				ApplyRequirements: []raw.ApplyRequirement{
+					{Name: "mergeable"},
+					{AnyOf: []string{"approved", "mergeable"}},
+					{AllOf: []string{"approved"}},
+				},
+			},
+			exp: valid.Project{
+				Dir:       ".",
+				Workspace: "default",
+				Autoplan: valid.Autoplan{
+					WhenModified: []string{"**/*.tf*"},
+					Enabled:      true,
+				},
+				ApplyRequirements: []string{"mergeable"},
+				ApplyRequirementGroups: []valid.ApplyRequirementGroup{
+					{AnyOf: true, Requirements: []string{"approved", "mergeable"}},
+					{AnyOf: false, Requirements: []string{"approved"}},
+				},
+			},
+		},
 		{
 			description: "tf version without 'v'",
 			input: raw.Project{
@@ -339,6 +456,22 @@ func TestProject_ToValid(t *testing.T) {
 				},
 			},
 		},
+		{
+			description: "branch",
+			input: raw.Project{
+				Dir:    String("."),
+				Branch: String("/^main$/"),
+			},
+			exp: valid.Project{
+				Dir:       ".",
+				Workspace: "default",
+				Autoplan: valid.Autoplan{
+					WhenModified: []string{"**/*.tf*"},
+					Enabled:      true,
+				},
+				Branch: String("^main$"),
+			},
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.description, func(t *testing.T) {
@@ -0,0 +1,380 @@
+package raw
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/go-ozzo/ozzo-validation"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+)
+
+// ServerConfig is the representation of the server-side repo config file
+// passed to Atlantis with --repo-config. Unlike atlantis.yaml, it's controlled
+// by whoever runs Atlantis rather than by each repo.
+type ServerConfig struct {
+	Repos []Repo `yaml:"repos,omitempty"`
+	// Workflows are custom workflows that repos can use without defining
+	// them in their atlantis.yaml.
+	Workflows map[string]Workflow `yaml:"workflows,omitempty"`
+}
+
+// Repo is the server-side config for the repos matching ID.
+type Repo struct {
+	// ID is the hostname and full name of the repo, ex.
+	// github.com/runatlantis/atlantis. It can end in a * to match many repos,
+	// ex. github.com/runatlantis/*, or be a regex wrapped in /'s, ex.
+	// /github.com/runatlantis/.*/.
+	ID                    string `yaml:"id"`
+	RequireAtlantisYAML   *bool  `yaml:"require_atlantis_yaml,omitempty"`
+	MaxProjectsPerCommand *int   `yaml:"max_projects_per_command,omitempty"`
+	AllowRepoConfig       *bool  `yaml:"allow_repo_config,omitempty"`
+	AllowCustomWorkflows  *bool  `yaml:"allow_custom_workflows,omitempty"`
+	// Workflow is the server-side workflow that projects use unless they set
+	// their own. AllowedWorkflows are the other server-side workflows they
+	// can set.
+	Workflow          *string            `yaml:"workflow,omitempty"`
+	AllowedWorkflows  []string           `yaml:"allowed_workflows,omitempty"`
+	ApplyRequirements []ApplyRequirement `yaml:"apply_requirements,omitempty"`
+	// DestroyApplyRequirements replace the default requirements that destroy
+	// plans must also meet before they can be applied.
+	DestroyApplyRequirements []ApplyRequirement `yaml:"destroy_apply_requirements,omitempty"`
+	// PolicySets are checked against every plan for the repo. PolicyOwners
+	// are the users who can approve plans that fail them.
+	PolicySets   []PolicySet `yaml:"policy_sets,omitempty"`
+	PolicyOwners []string    `yaml:"policy_owners,omitempty"`
+	// AllowApplyFrom are the GitHub teams or GitLab groups whose members can
+	// apply in the repo.
+	AllowApplyFrom []string `yaml:"allow_apply_from,omitempty"`
+	// DriftDetection opts the repo into the scheduled drift detection run
+	// with --drift-detection-cron.
+	DriftDetection *DriftDetection `yaml:"drift_detection,omitempty"`
+	// PreWorkflowHooks are run before each command works out which projects
+	// to run in. PostWorkflowHooks are run after it's finished.
+	PreWorkflowHooks  []WorkflowHook `yaml:"pre_workflow_hooks,omitempty"`
+	PostWorkflowHooks []WorkflowHook `yaml:"post_workflow_hooks,omitempty"`
+	// DetectWorkspaces plans the workspaces that have an env/{workspace}.tfvars
+	// file in repos without an atlantis.yaml file.
+	DetectWorkspaces *bool `yaml:"detect_workspaces,omitempty"`
+	// CostEstimation set to false opts the repo out of the cost estimates
+	// made with --enable-cost-estimation.
+	CostEstimation *bool `yaml:"cost_estimation,omitempty"`
+	// PlanOnly set to true disables apply, import and state for the repo.
+	// ApplyBranches only allows them for pull requests into these base
+	// branches.
+	PlanOnly      *bool    `yaml:"plan_only,omitempty"`
+	ApplyBranches []string `yaml:"apply_branches,omitempty"`
+	// Branch is the default for projects' branch regex, ex. /^main$/, and is
+	// also used for repos without an atlantis.yaml file.
+	Branch *string `yaml:"branch,omitempty"`
+	// BranchWorkspaces maps base branches to the workspace that projects
+	// without an atlantis.yaml file and comments without -w run in for pull
+	// requests into them, ex. staging: staging.
+	BranchWorkspaces map[string]string `yaml:"branch_workspaces,omitempty"`
+	// AutoplanOn are the events pull requests are autoplanned on, ex.
+	// [opened, ready_for_review]. An empty list turns autoplanning off.
+	AutoplanOn []string `yaml:"autoplan_on,omitempty"`
+}
+
+// WorkflowHook is a shell command to run around each command.
+type WorkflowHook struct {
+	Run string `yaml:"run"`
+}
+
+// DriftDetection is how drift detection is configured for a repo.
+type DriftDetection struct {
+	Enabled   *bool           `yaml:"enabled,omitempty"`
+	Notifiers []DriftNotifier `yaml:"notifiers,omitempty"`
+}
+
+// DriftNotifier is where we report drift to.
+type DriftNotifier struct {
+	// Kind is one of valid.DriftNotifierKinds.
+	Kind string `yaml:"kind"`
+	// Channel is the Slack channel to post to, without the #.
+	Channel string `yaml:"channel,omitempty"`
+	// URL is the URL to POST the webhook to.
+	URL string `yaml:"url,omitempty"`
+}
+
+// PolicySet is a directory of Conftest policies.
+type PolicySet struct {
+	Name string `yaml:"name"`
+	Path string `yaml:"path"`
+}
+
+func (s ServerConfig) Validate() error {
+	workflowsExist := func(value interface{}) error {
+		for i, r := range value.([]Repo) {
+			names := r.AllowedWorkflows
+			if r.Workflow != nil {
+				names = append([]string{*r.Workflow}, names...)
+			}
+			for _, name := range names {
+				if _, ok := s.Workflows[name]; !ok {
+					return fmt.Errorf("%d: workflow %q is not defined", i, name)
+				}
+			}
+		}
+		return nil
+	}
+	return validation.ValidateStruct(&s,
+		validation.Field(&s.Repos, validation.By(workflowsExist)),
+		validation.Field(&s.Workflows),
+	)
+}
+
+func (s ServerConfig) ToValid() valid.ServerConfig {
+	var repos []valid.Repo
+	for _, r := range s.Repos {
+		repos = append(repos, r.ToValid())
+	}
+	var workflows map[string]valid.Workflow
+	if len(s.Workflows) > 0 {
+		workflows = make(map[string]valid.Workflow)
+		for k, v := range s.Workflows {
+			workflows[k] = v.ToValid()
+		}
+	}
+	return valid.ServerConfig{Repos: repos, Workflows: workflows}
+}
+
+func (r Repo) Validate() error {
+	validID := func(value interface{}) error {
+		id := value.(string)
+		if strings.Contains(id, "://") {
+			return errors.New("cannot contain ://, should be hostname and repo name only, ex. github.com/owner/repo")
+		}
+		if expr, ok := valid.IDRegex(id); ok {
+			if _, err := regexp.Compile(expr); err != nil {
+				return fmt.Errorf("regex %q could not be parsed: %s", expr, err)
+			}
+		}
+		return nil
+	}
+	notNegative := func(value interface{}) error {
+		if max := value.(*int); max != nil && *max < 0 {
+			return errors.New("cannot be negative")
+		}
+		return nil
+	}
+	uniqueNames := func(value interface{}) error {
+		seen := make(map[string]bool)
+		for _, set := range value.([]PolicySet) {
+			if seen[set.Name] {
+				return fmt.Errorf("found two policy sets with the name %q, each policy set must have a unique name", set.Name)
+			}
+			seen[set.Name] = true
+		}
+		return nil
+	}
+	validApplyReqs := func(value interface{}) error {
+		for _, req := range value.([]ApplyRequirement) {
+			if err := req.Validate(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	requireAllowed := func(value interface{}) error {
+		if allow := value.(*bool); allow != nil && !*allow && r.RequireAtlantisYAML != nil && *r.RequireAtlantisYAML {
+			return errors.New("cannot be false when require_atlantis_yaml is true")
+		}
+		return nil
+	}
+	validBranches := func(value interface{}) error {
+		for _, branch := range value.([]string) {
+			if branch == "" {
+				return errors.New("cannot contain an empty branch")
+			}
+			if expr, ok := valid.IDRegex(branch); ok {
+				if _, err := regexp.Compile(expr); err != nil {
+					return fmt.Errorf("regex %q could not be parsed: %s", expr, err)
+				}
+			}
+		}
+		return nil
+	}
+	validBranchWorkspaces := func(value interface{}) error {
+		for branch, workspace := range value.(map[string]string) {
+			// Workspaces are in clone paths so they're validated like the -w
+			// comment flag.
+			if workspace == "" || workspace != url.PathEscape(workspace) || strings.Contains(workspace, "..") {
+				return fmt.Errorf("invalid workspace %q for branch %q", workspace, branch)
+			}
+		}
+		return nil
+	}
+	validAutoplanEvents := func(value interface{}) error {
+		for _, event := range value.([]string) {
+			if !isAutoplanEvent(event) {
+				return fmt.Errorf("%q is not a valid event, only %s are supported", event, strings.Join(valid.AutoplanEvents, ", "))
+			}
+		}
+		return nil
+	}
+	exactID := func(value interface{}) error {
+		if d := value.(*DriftDetection); d == nil || (d.Enabled != nil && !*d.Enabled) {
+			return nil
+		}
+		if _, ok := valid.IDRegex(r.ID); ok || strings.HasSuffix(r.ID, "*") {
+			return errors.New("can only be enabled for a single repo, not for an id with a wildcard or regex")
+		}
+		return nil
+	}
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.ID, validation.Required, validation.By(validID)),
+		validation.Field(&r.MaxProjectsPerCommand, validation.By(notNegative)),
+		validation.Field(&r.AllowRepoConfig, validation.By(requireAllowed)),
+		validation.Field(&r.ApplyRequirements, validation.By(validApplyReqs)),
+		validation.Field(&r.DestroyApplyRequirements, validation.By(validApplyReqs)),
+		validation.Field(&r.PolicySets, validation.By(uniqueNames)),
+		validation.Field(&r.DriftDetection, validation.By(exactID)),
+		validation.Field(&r.ApplyBranches, validation.By(validBranches)),
+		validation.Field(&r.Branch, validation.By(validBranchRegex)),
+		validation.Field(&r.BranchWorkspaces, validation.By(validBranchWorkspaces)),
+		validation.Field(&r.AutoplanOn, validation.By(validAutoplanEvents)),
+		validation.Field(&r.PreWorkflowHooks),
+		validation.Field(&r.PostWorkflowHooks),
+	)
+}
+
+func (r Repo) ToValid() valid.Repo {
+	var policySets []valid.PolicySet
+	for _, p := range r.PolicySets {
+		policySets = append(policySets, p.ToValid())
+	}
+	applyReqs, applyReqGroups := applyRequirementsToValid(r.ApplyRequirements)
+	destroyReqs, destroyReqGroups := applyRequirementsToValid(r.DestroyApplyRequirements)
+	var preHooks, postHooks []valid.WorkflowHook
+	for _, h := range r.PreWorkflowHooks {
+		preHooks = append(preHooks, h.ToValid())
+	}
+	for _, h := range r.PostWorkflowHooks {
+		postHooks = append(postHooks, h.ToValid())
+	}
+	return valid.Repo{
+		ID: r.ID,
+		// By default, repos can fall back to auto-discovering projects.
+		RequireAtlantisYAML:   r.RequireAtlantisYAML != nil && *r.RequireAtlantisYAML,
+		MaxProjectsPerCommand: r.MaxProjectsPerCommand,
+		AllowRepoConfig:       r.AllowRepoConfig,
+		// By default, atlantis.yaml files can define their own workflows.
+		AllowCustomWorkflows:          r.AllowCustomWorkflows == nil || *r.AllowCustomWorkflows,
+		Workflow:                      r.Workflow,
+		AllowedWorkflows:              r.AllowedWorkflows,
+		ApplyRequirements:             applyReqs,
+		ApplyRequirementGroups:        applyReqGroups,
+		DestroyApplyRequirements:      destroyReqs,
+		DestroyApplyRequirementGroups: destroyReqGroups,
+		PolicySets:                    policySets,
+		PolicyOwners:                  r.PolicyOwners,
+		AllowApplyFrom:                r.AllowApplyFrom,
+		DriftDetection:                r.DriftDetection.ToValid(),
+		PreWorkflowHooks:              preHooks,
+		PostWorkflowHooks:             postHooks,
+		DetectWorkspaces:              r.DetectWorkspaces != nil && *r.DetectWorkspaces,
+		DisableCostEstimation:         r.CostEstimation != nil && !*r.CostEstimation,
+		PlanOnly:                      r.PlanOnly != nil && *r.PlanOnly,
+		ApplyBranches:                 r.ApplyBranches,
+		Branch:                        branchRegexToValid(r.Branch),
+		BranchWorkspaces:              r.BranchWorkspaces,
+		AutoplanOn:                    r.AutoplanOn,
+	}
+}
+
+func isAutoplanEvent(event string) bool {
+	for _, e := range valid.AutoplanEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func (h WorkflowHook) Validate() error {
+	return validation.ValidateStruct(&h,
+		validation.Field(&h.Run, validation.Required),
+	)
+}
+
+func (h WorkflowHook) ToValid() valid.WorkflowHook {
+	return valid.WorkflowHook{
+		RunCommand: h.Run,
+	}
+}
+
+func (p PolicySet) Validate() error {
+	absolute := func(value interface{}) error {
+		if !filepath.IsAbs(value.(string)) {
+			return errors.New("must be an absolute path")
+		}
+		return nil
+	}
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Name, validation.Required),
+		validation.Field(&p.Path, validation.Required, validation.By(absolute)),
+	)
+}
+
+func (p PolicySet) ToValid() valid.PolicySet {
+	return valid.PolicySet{
+		Name: p.Name,
+		Path: p.Path,
+	}
+}
+
+func (d DriftDetection) Validate() error {
+	return validation.ValidateStruct(&d,
+		validation.Field(&d.Notifiers),
+	)
+}
+
+// ToValid returns nil if drift detection isn't enabled. It's enabled by
+// default once the drift_detection key is set.
+func (d *DriftDetection) ToValid() *valid.DriftDetection {
+	if d == nil || (d.Enabled != nil && !*d.Enabled) {
+		return nil
+	}
+	v := &valid.DriftDetection{}
+	for _, n := range d.Notifiers {
+		v.Notifiers = append(v.Notifiers, n.ToValid())
+	}
+	return v
+}
+
+func (n DriftNotifier) Validate() error {
+	kindValid := func(value interface{}) error {
+		kind := value.(string)
+		for _, k := range valid.DriftNotifierKinds {
+			if k == kind {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not a valid kind, only %s are supported", kind, strings.Join(valid.DriftNotifierKinds, ", "))
+	}
+	requiredFor := func(kind string) validation.Rule {
+		return validation.By(func(value interface{}) error {
+			if n.Kind == kind && value.(string) == "" {
+				return fmt.Errorf("is required for %s notifiers", kind)
+			}
+			return nil
+		})
+	}
+	return validation.ValidateStruct(&n,
+		validation.Field(&n.Kind, validation.Required, validation.By(kindValid)),
+		validation.Field(&n.Channel, requiredFor(valid.SlackDriftNotifier)),
+		validation.Field(&n.URL, requiredFor(valid.WebhookDriftNotifier)),
+	)
+}
+
+func (n DriftNotifier) ToValid() valid.DriftNotifier {
+	return valid.DriftNotifier{
+		Kind:    n.Kind,
+		Channel: n.Channel,
+		URL:     n.URL,
+	}
+}
@@ -3,6 +3,7 @@ package raw
 import (
 	"errors"
 	"fmt"
+	"regexp"
 	"sort"
 	"strings"
 
@@ -17,8 +18,30 @@ const (
 	PlanStepName  = "plan"
 	ApplyStepName = "apply"
 	InitStepName  = "init"
+	ShowOutputKey = "show_output"
 )
 
+// PolicyCheckStepName is the step that checks the plan against the repo's
+// policy sets with Conftest.
+const PolicyCheckStepName = "policy_check"
+
+// TerragruntStepName is the step that runs a terragrunt command. Like a run
+// step its value is the command, without the leading terragrunt.
+const TerragruntStepName = "terragrunt"
+
+// EnvStepName is the step that sets an environment variable for the steps
+// after it. The variable's name is set with EnvNameKey and its value with
+// either EnvValueKey or, to use a command's output, EnvCommandKey.
+const (
+	EnvStepName   = "env"
+	EnvNameKey    = "name"
+	EnvValueKey   = "value"
+	EnvCommandKey = "command"
+)
+
+// envVarNameRegex matches valid environment variable names.
+var envVarNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
 // Step represents a single action/command to perform. In YAML, it can be set as
 // 1. A single string for a built-in command:
 //    - init
@@ -26,8 +49,15 @@ const (
 // 2. A map for a built-in command and extra_args:
 //    - plan:
 //        extra_args: [-var-file=staging.tfvars]
-// 3. A map for a custom run command:
+// 3. A map for a custom run command, optionally with show_output:
 //    - run: my custom command
+//      show_output: always
+//    or a terragrunt command:
+//    - terragrunt: plan
+// 4. A map for an env step:
+//    - env:
+//        name: AWS_PROFILE
+//        value: production
 // Here we parse step in the most generic fashion possible. See fields for more
 // details.
 type Step struct {
@@ -38,6 +68,8 @@ type Step struct {
 	Map map[string]map[string][]string
 	// StringVal will be set in case #3 above.
 	StringVal map[string]string
+	// EnvVal will be set in case #4 above.
+	EnvVal map[string]map[string]string
 }
 
 func (s *Step) UnmarshalYAML(unmarshal func(interface{}) error) error {
@@ -65,6 +97,20 @@ func (s *Step) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		return nil
 	}
 
+	// Try to unmarshal as an env step, ex.
+	// steps:
+	// - env:
+	//     name: AWS_PROFILE
+	//     value: production
+	// We validate if the key is env later. This is tried before run steps,
+	// which can't be parsed the same way, so that if neither can we return
+	// the run step's error.
+	var envStep map[string]map[string]string
+	if err = unmarshal(&envStep); err == nil {
+		s.EnvVal = envStep
+		return nil
+	}
+
 	// Try to unmarshal as a custom run step, ex.
 	// steps:
 	// - run: my command
@@ -82,7 +128,7 @@ func (s *Step) UnmarshalYAML(unmarshal func(interface{}) error) error {
 func (s Step) Validate() error {
 	validStep := func(value interface{}) error {
 		str := *value.(*string)
-		if str != InitStepName && str != PlanStepName && str != ApplyStepName {
+		if str != InitStepName && str != PlanStepName && str != ApplyStepName && str != PolicyCheckStepName {
 			return fmt.Errorf("%q is not a valid step type", str)
 		}
 		return nil
@@ -102,7 +148,7 @@ func (s Step) Validate() error {
 				len(keys), strings.Join(keys, ","))
 		}
 		for stepName, args := range elem {
-			if stepName != InitStepName && stepName != PlanStepName && stepName != ApplyStepName {
+			if stepName != InitStepName && stepName != PlanStepName && stepName != ApplyStepName && stepName != PolicyCheckStepName {
 				return fmt.Errorf("%q is not a valid step type", stepName)
 			}
 			var argKeys []string
@@ -128,6 +174,11 @@ func (s Step) Validate() error {
 		elem := value.(map[string]string)
 		var keys []string
 		for k := range elem {
+			// show_output is an option of the run step rather than its own
+			// step so it doesn't count towards the single key.
+			if k == ShowOutputKey {
+				continue
+			}
 			keys = append(keys, k)
 		}
 		// Sort so tests can be deterministic.
@@ -137,10 +188,24 @@ func (s Step) Validate() error {
 			return fmt.Errorf("step element can only contain a single key, found %d: %s",
 				len(keys), strings.Join(keys, ","))
 		}
+		if len(keys) == 0 {
+			return fmt.Errorf("%s can only be set on %s steps", ShowOutputKey, RunStepName)
+		}
 		for stepName, args := range elem {
-			if stepName != RunStepName {
+			if stepName == ShowOutputKey {
+				if args != valid.ShowOutputAlways && args != valid.ShowOutputOnError && args != valid.ShowOutputNever {
+					return fmt.Errorf("%q is not a valid value for %s, only %s, %s and %s are supported", args, ShowOutputKey, valid.ShowOutputAlways, valid.ShowOutputOnError, valid.ShowOutputNever)
+				}
+				continue
+			}
+			if stepName != RunStepName && stepName != TerragruntStepName {
 				return fmt.Errorf("%q is not a valid step type", stepName)
 			}
+			if stepName == TerragruntStepName {
+				if _, ok := elem[ShowOutputKey]; ok {
+					return fmt.Errorf("%s can only be set on %s steps", ShowOutputKey, RunStepName)
+				}
+			}
 			_, err := shlex.Split(args)
 			if err != nil {
 				return fmt.Errorf("unable to parse as shell command: %s", err)
@@ -149,6 +214,49 @@ func (s Step) Validate() error {
 		return nil
 	}
 
+	envStep := func(value interface{}) error {
+		elem := value.(map[string]map[string]string)
+		var keys []string
+		for k := range elem {
+			keys = append(keys, k)
+		}
+		// Sort so tests can be deterministic.
+		sort.Strings(keys)
+
+		if len(keys) > 1 {
+			return fmt.Errorf("step element can only contain a single key, found %d: %s",
+				len(keys), strings.Join(keys, ","))
+		}
+		for stepName, args := range elem {
+			if stepName != EnvStepName {
+				return fmt.Errorf("%q is not a valid step type", stepName)
+			}
+			for k := range args {
+				if k != EnvNameKey && k != EnvValueKey && k != EnvCommandKey {
+					return fmt.Errorf("env steps only support %s, %s and %s keys, found %q", EnvNameKey, EnvValueKey, EnvCommandKey, k)
+				}
+			}
+			if !envVarNameRegex.MatchString(args[EnvNameKey]) {
+				return fmt.Errorf("env steps must set %s to a valid environment variable name, found %q", EnvNameKey, args[EnvNameKey])
+			}
+			_, hasValue := args[EnvValueKey]
+			command, hasCommand := args[EnvCommandKey]
+			if hasValue == hasCommand {
+				return fmt.Errorf("env steps must set one of %s or %s", EnvValueKey, EnvCommandKey)
+			}
+			if hasCommand {
+				split, err := shlex.Split(command)
+				if err != nil {
+					return fmt.Errorf("unable to parse as shell command: %s", err)
+				}
+				if len(split) == 0 {
+					return fmt.Errorf("env steps can't have an empty %s", EnvCommandKey)
+				}
+			}
+		}
+		return nil
+	}
+
 	if s.Key != nil {
 		return validation.Validate(s.Key, validation.By(validStep))
 	}
@@ -158,6 +266,9 @@ func (s Step) Validate() error {
 	if len(s.StringVal) > 0 {
 		return validation.Validate(s.StringVal, validation.By(runStep))
 	}
+	if len(s.EnvVal) > 0 {
+		return validation.Validate(s.EnvVal, validation.By(envStep))
+	}
 	return errors.New("step element is empty")
 }
 
@@ -183,17 +294,39 @@ func (s Step) ToValid() valid.Step {
 
 	// This will trigger in case #3 (see Step docs).
 	if len(s.StringVal) > 0 {
-		// After validation we assume there's only one key and it's a valid
-		// step name so we just use the first one.
-		for _, v := range s.StringVal {
-			// We ignore the error here because it should have been checked in
-			// Validate().
-			split, _ := shlex.Split(v)
+		// After validation we assume the only keys are run and optionally
+		// show_output, or terragrunt.
+		// We ignore the error here because it should have been checked in
+		// Validate().
+		if cmd, ok := s.StringVal[TerragruntStepName]; ok {
+			split, _ := shlex.Split(cmd)
 			return valid.Step{
-				StepName:   RunStepName,
+				StepName:   TerragruntStepName,
 				RunCommand: split,
 			}
 		}
+		split, _ := shlex.Split(s.StringVal[RunStepName])
+		return valid.Step{
+			StepName:   RunStepName,
+			RunCommand: split,
+			ShowOutput: s.StringVal[ShowOutputKey],
+		}
+	}
+
+	// This will trigger in case #4 (see Step docs).
+	if len(s.EnvVal) > 0 {
+		// After validation we assume the only key is env and that either
+		// value or command is set.
+		args := s.EnvVal[EnvStepName]
+		step := valid.Step{
+			StepName:    EnvStepName,
+			EnvVarName:  args[EnvNameKey],
+			EnvVarValue: args[EnvValueKey],
+		}
+		if command, ok := args[EnvCommandKey]; ok {
+			step.RunCommand, _ = shlex.Split(command)
+		}
+		return step
 	}
 
 	panic("step was not valid. This is a bug!")
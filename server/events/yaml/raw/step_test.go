@@ -85,6 +85,18 @@ run: my command`,
 				},
 			},
 		},
+		{
+			description: "run step with show_output",
+			input: `
+run: my command
+show_output: always`,
+			exp: raw.Step{
+				StringVal: map[string]string{
+					"run":         "my command",
+					"show_output": "always",
+				},
+			},
+		},
 		{
 			description: "run step multiple top-level keys",
 			input: `
@@ -98,6 +110,23 @@ key: value`,
 			},
 		},
 
+		// Env-step style
+		{
+			description: "env step",
+			input: `
+env:
+  name: AWS_PROFILE
+  value: production`,
+			exp: raw.Step{
+				EnvVal: map[string]map[string]string{
+					"env": {
+						"name":  "AWS_PROFILE",
+						"value": "production",
+					},
+				},
+			},
+		},
+
 		// Empty
 		{
 			description: "empty",
@@ -162,6 +191,24 @@ func TestStep_Validate(t *testing.T) {
 			},
 			expErr: "",
 		},
+		{
+			description: "policy_check step",
+			input: raw.Step{
+				Key: String("policy_check"),
+			},
+			expErr: "",
+		},
+		{
+			description: "policy_check extra_args",
+			input: raw.Step{
+				Map: MapType{
+					"policy_check": {
+						"extra_args": []string{"--all-namespaces"},
+					},
+				},
+			},
+			expErr: "",
+		},
 		{
 			description: "init extra_args",
 			input: raw.Step{
@@ -267,6 +314,54 @@ func TestStep_Validate(t *testing.T) {
 			},
 			expErr: "built-in steps only support a single extra_args key, found \"invalid\" in step init",
 		},
+		{
+			description: "run step with show_output",
+			input: raw.Step{
+				StringVal: map[string]string{
+					"run":         "my command",
+					"show_output": "on_error",
+				},
+			},
+			expErr: "",
+		},
+		{
+			description: "invalid show_output",
+			input: raw.Step{
+				StringVal: map[string]string{
+					"run":         "my command",
+					"show_output": "sometimes",
+				},
+			},
+			expErr: "\"sometimes\" is not a valid value for show_output, only always, on_error and never are supported",
+		},
+		{
+			description: "show_output without run",
+			input: raw.Step{
+				StringVal: map[string]string{
+					"show_output": "always",
+				},
+			},
+			expErr: "show_output can only be set on run steps",
+		},
+		{
+			description: "terragrunt step",
+			input: raw.Step{
+				StringVal: map[string]string{
+					"terragrunt": "plan -var foo=bar",
+				},
+			},
+			expErr: "",
+		},
+		{
+			description: "terragrunt step with show_output",
+			input: raw.Step{
+				StringVal: map[string]string{
+					"terragrunt":  "plan",
+					"show_output": "always",
+				},
+			},
+			expErr: "show_output can only be set on run steps",
+		},
 		{
 			description: "unparseable shell command",
 			input: raw.Step{
@@ -276,6 +371,69 @@ func TestStep_Validate(t *testing.T) {
 			},
 			expErr: "unable to parse as shell command: EOF found when expecting closing quote.",
 		},
+		{
+			description: "env step with value",
+			input: raw.Step{
+				EnvVal: map[string]map[string]string{
+					"env": {"name": "AWS_PROFILE", "value": "production"},
+				},
+			},
+			expErr: "",
+		},
+		{
+			description: "env step with command",
+			input: raw.Step{
+				EnvVal: map[string]map[string]string{
+					"env": {"name": "AWS_PROFILE", "command": "echo production"},
+				},
+			},
+			expErr: "",
+		},
+		{
+			description: "env step with value and command",
+			input: raw.Step{
+				EnvVal: map[string]map[string]string{
+					"env": {"name": "AWS_PROFILE", "value": "production", "command": "echo production"},
+				},
+			},
+			expErr: "env steps must set one of value or command",
+		},
+		{
+			description: "env step with empty command",
+			input: raw.Step{
+				EnvVal: map[string]map[string]string{
+					"env": {"name": "AWS_PROFILE", "command": " "},
+				},
+			},
+			expErr: "env steps can't have an empty command",
+		},
+		{
+			description: "env step with invalid name",
+			input: raw.Step{
+				EnvVal: map[string]map[string]string{
+					"env": {"name": "AWS-PROFILE", "value": "production"},
+				},
+			},
+			expErr: "env steps must set name to a valid environment variable name, found \"AWS-PROFILE\"",
+		},
+		{
+			description: "env step with unknown key",
+			input: raw.Step{
+				EnvVal: map[string]map[string]string{
+					"env": {"name": "AWS_PROFILE", "value": "production", "export": "true"},
+				},
+			},
+			expErr: "env steps only support name, value and command keys, found \"export\"",
+		},
+		{
+			description: "invalid step with env style",
+			input: raw.Step{
+				EnvVal: map[string]map[string]string{
+					"run": {"name": "AWS_PROFILE", "value": "production"},
+				},
+			},
+			expErr: "\"run\" is not a valid step type",
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.description, func(t *testing.T) {
@@ -376,6 +534,58 @@ func TestStep_ToValid(t *testing.T) {
 				RunCommand: []string{"my", "run command"},
 			},
 		},
+		{
+			description: "run step with show_output",
+			input: raw.Step{
+				StringVal: map[string]string{
+					"run":         "my command",
+					"show_output": "never",
+				},
+			},
+			exp: valid.Step{
+				StepName:   "run",
+				RunCommand: []string{"my", "command"},
+				ShowOutput: "never",
+			},
+		},
+		{
+			description: "terragrunt step",
+			input: raw.Step{
+				StringVal: map[string]string{
+					"terragrunt": "plan -var foo=bar",
+				},
+			},
+			exp: valid.Step{
+				StepName:   "terragrunt",
+				RunCommand: []string{"plan", "-var", "foo=bar"},
+			},
+		},
+		{
+			description: "env step with value",
+			input: raw.Step{
+				EnvVal: map[string]map[string]string{
+					"env": {"name": "AWS_PROFILE", "value": "production"},
+				},
+			},
+			exp: valid.Step{
+				StepName:    "env",
+				EnvVarName:  "AWS_PROFILE",
+				EnvVarValue: "production",
+			},
+		},
+		{
+			description: "env step with command",
+			input: raw.Step{
+				EnvVal: map[string]map[string]string{
+					"env": {"name": "AWS_PROFILE", "command": "cat 'profile file'"},
+				},
+			},
+			exp: valid.Step{
+				StepName:   "env",
+				EnvVarName: "AWS_PROFILE",
+				RunCommand: []string{"cat", "profile file"},
+			},
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.description, func(t *testing.T) {
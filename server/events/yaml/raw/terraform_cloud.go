@@ -0,0 +1,37 @@
+package raw
+
+import (
+	"github.com/go-ozzo/ozzo-validation"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+)
+
+// DefaultTerraformCloudHostname is the hostname of Terraform Cloud. Terraform
+// Enterprise users set their own.
+const DefaultTerraformCloudHostname = "app.terraform.io"
+
+// TerraformCloud maps a project to the Terraform Cloud or Enterprise
+// workspace it runs in.
+type TerraformCloud struct {
+	Hostname     *string `yaml:"hostname,omitempty"`
+	Organization string  `yaml:"organization,omitempty"`
+	Workspace    string  `yaml:"workspace,omitempty"`
+}
+
+func (t TerraformCloud) Validate() error {
+	return validation.ValidateStruct(&t,
+		validation.Field(&t.Organization, validation.Required),
+		validation.Field(&t.Workspace, validation.Required),
+	)
+}
+
+func (t TerraformCloud) ToValid() valid.TerraformCloud {
+	v := valid.TerraformCloud{
+		Hostname:     DefaultTerraformCloudHostname,
+		Organization: t.Organization,
+		Workspace:    t.Workspace,
+	}
+	if t.Hostname != nil && *t.Hostname != "" {
+		v.Hostname = *t.Hostname
+	}
+	return v
+}
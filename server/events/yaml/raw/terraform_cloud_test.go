@@ -0,0 +1,35 @@
+package raw_test
+
+import (
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/yaml/raw"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+	. "github.com/runatlantis/atlantis/testing"
+	"gopkg.in/yaml.v2"
+)
+
+func TestTerraformCloud_UnmarshalYAML(t *testing.T) {
+	var tfc raw.TerraformCloud
+	err := yaml.UnmarshalStrict([]byte(`
+hostname: tfe.example.com
+organization: org
+workspace: network-prod
+`), &tfc)
+	Ok(t, err)
+	Equals(t, raw.TerraformCloud{Hostname: String("tfe.example.com"), Organization: "org", Workspace: "network-prod"}, tfc)
+}
+
+func TestTerraformCloud_Validate(t *testing.T) {
+	Ok(t, raw.TerraformCloud{Organization: "org", Workspace: "ws"}.Validate())
+	ErrEquals(t, "organization: cannot be blank.", raw.TerraformCloud{Workspace: "ws"}.Validate())
+	ErrEquals(t, "workspace: cannot be blank.", raw.TerraformCloud{Organization: "org"}.Validate())
+}
+
+func TestTerraformCloud_ToValid(t *testing.T) {
+	// Hostname defaults to Terraform Cloud.
+	Equals(t, valid.TerraformCloud{Hostname: "app.terraform.io", Organization: "org", Workspace: "ws"},
+		raw.TerraformCloud{Organization: "org", Workspace: "ws"}.ToValid())
+	Equals(t, valid.TerraformCloud{Hostname: "tfe.example.com", Organization: "org", Workspace: "ws"},
+		raw.TerraformCloud{Hostname: String("tfe.example.com"), Organization: "org", Workspace: "ws"}.ToValid())
+}
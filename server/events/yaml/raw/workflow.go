@@ -6,14 +6,16 @@ import (
 )
 
 type Workflow struct {
-	Apply *Stage `yaml:"apply,omitempty"`
-	Plan  *Stage `yaml:"plan,omitempty"`
+	Apply       *Stage `yaml:"apply,omitempty"`
+	Plan        *Stage `yaml:"plan,omitempty"`
+	PolicyCheck *Stage `yaml:"policy_check,omitempty"`
 }
 
 func (w Workflow) Validate() error {
 	return validation.ValidateStruct(&w,
 		validation.Field(&w.Apply),
 		validation.Field(&w.Plan),
+		validation.Field(&w.PolicyCheck),
 	)
 }
 
@@ -27,5 +29,9 @@ func (w Workflow) ToValid() valid.Workflow {
 		plan := w.Plan.ToValid()
 		v.Plan = &plan
 	}
+	if w.PolicyCheck != nil {
+		policyCheck := w.PolicyCheck.ToValid()
+		v.PolicyCheck = &policyCheck
+	}
 	return v
 }
@@ -60,6 +60,17 @@ apply:
 				},
 			},
 		},
+		{
+			description: "policy_check set",
+			input: `
+policy_check:
+  steps: [policy_check]`,
+			exp: raw.Workflow{
+				PolicyCheck: &raw.Stage{
+					Steps: []raw.Step{{Key: String("policy_check")}},
+				},
+			},
+		},
 		{
 			description: "steps set to empty slice",
 			input: `
@@ -0,0 +1,274 @@
+package valid
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ServerConfig is the server-side repo config after it's been parsed and
+// validated.
+type ServerConfig struct {
+	Repos []Repo
+	// Workflows are custom workflows that repos can use without defining
+	// them in their atlantis.yaml.
+	Workflows map[string]Workflow
+}
+
+// Repo is the server-side config for the repos matching ID.
+type Repo struct {
+	ID                  string
+	RequireAtlantisYAML bool
+	// MaxProjectsPerCommand overrides --max-projects-per-command if set.
+	MaxProjectsPerCommand *int
+	// AllowRepoConfig overrides --allow-repo-config if set.
+	AllowRepoConfig *bool
+	// AllowCustomWorkflows is true if the repo's atlantis.yaml can define
+	// its own workflows.
+	AllowCustomWorkflows bool
+	// Workflow is the server-side workflow projects use unless they set
+	// their own.
+	Workflow *string
+	// AllowedWorkflows are the other server-side workflows projects can set.
+	AllowedWorkflows []string
+	// ApplyRequirements and ApplyRequirementGroups must be met before any of
+	// the repo's projects can be applied, on top of the projects' own.
+	ApplyRequirements      []string
+	ApplyRequirementGroups []ApplyRequirementGroup
+	// DestroyApplyRequirements and DestroyApplyRequirementGroups must also
+	// be met before destroy plans can be applied. If both are empty, destroy
+	// plans must be approved.
+	DestroyApplyRequirements      []string
+	DestroyApplyRequirementGroups []ApplyRequirementGroup
+	// PolicySets are the policies plans are checked against.
+	PolicySets []PolicySet
+	// PolicyOwners are the users who can approve plans that failed their
+	// policy checks.
+	PolicyOwners []string
+	// AllowApplyFrom overrides --allow-apply-from if set.
+	AllowApplyFrom []string
+	// DriftDetection is nil unless the repo is part of the scheduled drift
+	// detection run.
+	DriftDetection *DriftDetection
+	// PreWorkflowHooks are run before Atlantis works out which projects a
+	// command runs for. PostWorkflowHooks are run after the command's results
+	// have been commented.
+	PreWorkflowHooks  []WorkflowHook
+	PostWorkflowHooks []WorkflowHook
+	// DetectWorkspaces is true if projects found without an atlantis.yaml
+	// file are planned in each workspace with an env/{workspace}.tfvars file
+	// rather than just the default workspace.
+	DetectWorkspaces bool
+	// DisableCostEstimation is true if the repo's plans shouldn't have their
+	// costs estimated even though --enable-cost-estimation is set.
+	DisableCostEstimation bool
+	// PlanOnly is true if apply, import and state are disabled for the
+	// repo.
+	PlanOnly bool
+	// ApplyBranches are the base branches of the pull requests that can be
+	// applied. They can end in a * or be a regex wrapped in /'s. If empty,
+	// pull requests into any branch can.
+	ApplyBranches []string
+	// Branch is the regex, without its /'s, that the base branch of pull
+	// requests must match for projects that don't set their own to be
+	// autoplanned. If nil, pull requests into any branch are.
+	Branch *string
+	// BranchWorkspaces maps base branches to the workspace that's used
+	// instead of the default workspace for pull requests into them.
+	BranchWorkspaces map[string]string
+	// AutoplanOn are the AutoplanEvents that pull requests are autoplanned
+	// on. If nil, they're autoplanned on all of them and if empty, they're
+	// never autoplanned.
+	AutoplanOn []string
+}
+
+const (
+	// AutoplanOnOpened autoplans pull requests when they're opened.
+	AutoplanOnOpened = "opened"
+	// AutoplanOnPushed autoplans pull requests when commits are pushed to
+	// them.
+	AutoplanOnPushed = "pushed"
+	// AutoplanOnReadyForReview autoplans draft pull requests when they're
+	// marked as ready for review.
+	AutoplanOnReadyForReview = "ready_for_review"
+)
+
+// AutoplanEvents are the events pull requests can be autoplanned on.
+var AutoplanEvents = []string{AutoplanOnOpened, AutoplanOnPushed, AutoplanOnReadyForReview}
+
+// WorkflowHook is a shell command run in the root of the pull request's
+// clone around each command.
+type WorkflowHook struct {
+	RunCommand string
+}
+
+const (
+	// VCSIssueDriftNotifier opens an issue on the repo.
+	VCSIssueDriftNotifier = "vcs_issue"
+	// SlackDriftNotifier posts to a Slack channel.
+	SlackDriftNotifier = "slack"
+	// WebhookDriftNotifier POSTs the report as JSON to a URL.
+	WebhookDriftNotifier = "webhook"
+)
+
+// DriftNotifierKinds are the kinds of drift notifiers we support.
+var DriftNotifierKinds = []string{VCSIssueDriftNotifier, SlackDriftNotifier, WebhookDriftNotifier}
+
+// DriftDetection is how drift is reported for a repo.
+type DriftDetection struct {
+	Notifiers []DriftNotifier
+}
+
+// DriftNotifier is where we report drift to.
+type DriftNotifier struct {
+	// Kind is one of DriftNotifierKinds.
+	Kind string
+	// Channel is the Slack channel for slack notifiers.
+	Channel string
+	// URL is the URL webhook notifiers POST to.
+	URL string
+}
+
+// PolicySet is a directory of Conftest policies that plans are checked
+// against.
+type PolicySet struct {
+	Name string
+	// Path is the absolute path to the directory holding the policies.
+	Path string
+}
+
+// IsWorkflowAllowed returns true if the repo's projects can use the
+// server-side workflow name.
+func (r Repo) IsWorkflowAllowed(name string) bool {
+	if r.Workflow != nil && *r.Workflow == name {
+		return true
+	}
+	for _, w := range r.AllowedWorkflows {
+		if w == name {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckChangesAllowed returns an error explaining why commands that change
+// state, ex. apply, can't run for a pull request into baseBranch, or nil if
+// they can. baseBranch is empty if it isn't known.
+func (r Repo) CheckChangesAllowed(baseBranch string) error {
+	if r.PlanOnly {
+		return errors.New("this repo is plan-only in the server-side repo config")
+	}
+	if len(r.ApplyBranches) == 0 {
+		return nil
+	}
+	for _, pattern := range r.ApplyBranches {
+		if baseBranch != "" && branchMatches(pattern, baseBranch) {
+			return nil
+		}
+	}
+	if baseBranch == "" {
+		return fmt.Errorf("only pull requests into %s can be applied in this repo and this pull request's base branch isn't known", strings.Join(r.ApplyBranches, ", "))
+	}
+	return fmt.Errorf("only pull requests into %s can be applied in this repo, not ones into %s", strings.Join(r.ApplyBranches, ", "), baseBranch)
+}
+
+// branchMatches returns true if branch matches pattern, which can end in a *
+// or be a regex wrapped in /'s.
+func branchMatches(pattern string, branch string) bool {
+	if expr, ok := IDRegex(pattern); ok {
+		// The regex was validated when the config was parsed.
+		match, _ := regexp.MatchString(expr, branch)
+		return match
+	}
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(branch, strings.TrimSuffix(pattern, "*"))
+	}
+	return branch == pattern
+}
+
+// AutoplansOn returns true if the repo's pull requests are autoplanned on
+// event, one of AutoplanEvents.
+func (r Repo) AutoplansOn(event string) bool {
+	if r.AutoplanOn == nil {
+		return true
+	}
+	for _, e := range r.AutoplanOn {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPolicyOwner returns true if username can approve plans that failed
+// their policy checks.
+func (r Repo) IsPolicyOwner(username string) bool {
+	for _, owner := range r.PolicyOwners {
+		if strings.EqualFold(owner, username) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindRepo returns the config for the repo repoFullName on vcsHostname or nil
+// if no config matches. If more than one matches, the last one wins so that
+// specific repos can be listed after wildcards.
+func (s ServerConfig) FindRepo(repoFullName string, vcsHostname string) *Repo {
+	candidate := strings.ToLower(fmt.Sprintf("%s/%s", vcsHostname, repoFullName))
+	var found *Repo
+	for i := range s.Repos {
+		if s.Repos[i].matches(candidate) {
+			found = &s.Repos[i]
+		}
+	}
+	return found
+}
+
+// DriftDetectionRepos returns the repos that have drift detection enabled.
+// Their IDs are always a single repo.
+func (s ServerConfig) DriftDetectionRepos() []Repo {
+	var repos []Repo
+	for _, r := range s.Repos {
+		if r.DriftDetection != nil {
+			repos = append(repos, r)
+		}
+	}
+	return repos
+}
+
+// WorkflowNames returns the names of the server-side workflows.
+func (s ServerConfig) WorkflowNames() []string {
+	var names []string
+	for name := range s.Workflows {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IDRegex returns the regex in id and true if id is a regex wrapped in /'s,
+// ex. /github.com/owner/.*/.
+func IDRegex(id string) (string, bool) {
+	if len(id) < 2 || !strings.HasPrefix(id, "/") || !strings.HasSuffix(id, "/") {
+		return "", false
+	}
+	return id[1 : len(id)-1], true
+}
+
+// matches returns true if candidate matches the repo's ID. The ID can end in
+// a * to match anything after it or be a regex wrapped in /'s.
+func (r Repo) matches(candidate string) bool {
+	if expr, ok := IDRegex(r.ID); ok {
+		// The regex was validated when the config was parsed.
+		match, _ := regexp.MatchString("(?i)"+expr, candidate)
+		return match
+	}
+	id := strings.ToLower(r.ID)
+	if strings.HasSuffix(id, "*") {
+		return strings.HasPrefix(candidate, strings.TrimSuffix(id, "*"))
+	}
+	return candidate == id
+}
@@ -2,7 +2,11 @@
 // after it's been parsed and validated.
 package valid
 
-import "github.com/hashicorp/go-version"
+import (
+	"fmt"
+
+	"github.com/hashicorp/go-version"
+)
 
 // Config is the atlantis.yaml config after it's been parsed and validated.
 type Config struct {
@@ -11,6 +15,12 @@ type Config struct {
 	Version   int
 	Projects  []Project
 	Workflows map[string]Workflow
+	// Automerge is true if the pull request should be merged once all of its
+	// plans have been applied.
+	Automerge bool
+	// DeleteSourceBranchOnMerge is true if the pull request's source branch
+	// should be deleted once it's automerged.
+	DeleteSourceBranchOnMerge bool
 }
 
 func (c Config) GetPlanStage(workflowName string) *Stage {
@@ -31,6 +41,17 @@ func (c Config) GetApplyStage(workflowName string) *Stage {
 	return nil
 }
 
+// GetPolicyCheckStage returns the policy_check stage of the workflow or nil
+// if it doesn't have one.
+func (c Config) GetPolicyCheckStage(workflowName string) *Stage {
+	for name, flow := range c.Workflows {
+		if name == workflowName {
+			return flow.PolicyCheck
+		}
+	}
+	return nil
+}
+
 func (c Config) FindProjectsByDirWorkspace(dir string, workspace string) []Project {
 	var ps []Project
 	for _, p := range c.Projects {
@@ -62,13 +83,72 @@ func (c Config) FindProjectByName(name string) *Project {
 }
 
 type Project struct {
-	Dir               string
-	Workspace         string
-	Name              *string
-	Workflow          *string
-	TerraformVersion  *version.Version
-	Autoplan          Autoplan
+	Dir              string
+	Workspace        string
+	Name             *string
+	Workflow         *string
+	TerraformVersion *version.Version
+	Autoplan         Autoplan
+	// ApplyRequirements must all be met before apply can be run.
 	ApplyRequirements []string
+	// ApplyRequirementGroups must also each be met before apply can be run.
+	ApplyRequirementGroups []ApplyRequirementGroup
+	// DestroyApplyRequirements and DestroyApplyRequirementGroups must also be
+	// met before a destroy plan can be applied. They come from the
+	// server-side repo config. If both are empty, destroy plans must be
+	// approved.
+	DestroyApplyRequirements      []string
+	DestroyApplyRequirementGroups []ApplyRequirementGroup
+	// WarnOnDestroy is true if plans for this project should be flagged when
+	// they destroy more resources than the server's destroy threshold.
+	WarnOnDestroy bool
+	// DependsOn are the names of the projects that must be applied before
+	// this one.
+	DependsOn []string
+	// TerraformCloud is the Terraform Cloud workspace the project is mapped
+	// to, or nil if it isn't mapped to one.
+	TerraformCloud *TerraformCloud
+	// ExtraRepos are the other repos cloned into the project's workspace
+	// before it's planned.
+	ExtraRepos []ExtraRepo
+	// Branch is the regex, without its /'s, that the base branch of pull
+	// requests must match for the project to be autoplanned. If nil, the
+	// server-side repo config's is used.
+	Branch *string
+}
+
+// ExtraRepo is another repo cloned into a project's workspace, ex. one with
+// the modules the project calls.
+type ExtraRepo struct {
+	// Repo is the repo's hostname and full name, ex. github.com/owner/modules.
+	Repo string
+	// Ref is the branch, tag or commit that's cloned.
+	Ref string
+	// Dir is where the repo is cloned, relative to the repo root.
+	Dir string
+}
+
+// TerraformCloud is a Terraform Cloud or Enterprise workspace. The cost
+// estimates and policy checks of its runs are linked in plan comments.
+type TerraformCloud struct {
+	// Hostname is ex. app.terraform.io.
+	Hostname     string
+	Organization string
+	Workspace    string
+}
+
+// WorkspaceURL returns the URL of the workspace in the Terraform Cloud UI.
+func (t TerraformCloud) WorkspaceURL() string {
+	return fmt.Sprintf("https://%s/app/%s/workspaces/%s", t.Hostname, t.Organization, t.Workspace)
+}
+
+// ApplyRequirementGroup is a group of apply requirements, ex. "any of
+// approved or mergeable".
+type ApplyRequirementGroup struct {
+	// AnyOf is true if only one of Requirements needs to be met. Otherwise
+	// all of them must be met.
+	AnyOf        bool
+	Requirements []string
 }
 
 // GetName returns the name of the project or an empty string if there is no
@@ -90,12 +170,34 @@ type Stage struct {
 }
 
 type Step struct {
-	StepName   string
-	ExtraArgs  []string
+	StepName  string
+	ExtraArgs []string
+	// RunCommand is the command of a run step, or the terragrunt args of a
+	// terragrunt step.
 	RunCommand []string
+	// ShowOutput is when the output of a run step is added to the comment.
+	// If empty, the output is always added.
+	ShowOutput string
+	// EnvVarName is the environment variable an env step sets for the steps
+	// after it. Its value is EnvVarValue unless the step has a RunCommand, in
+	// which case it's the command's output.
+	EnvVarName  string
+	EnvVarValue string
 }
 
+const (
+	// ShowOutputAlways adds the run step's output to the comment whether or
+	// not it succeeded.
+	ShowOutputAlways = "always"
+	// ShowOutputOnError only adds the run step's output if it failed.
+	ShowOutputOnError = "on_error"
+	// ShowOutputNever never adds the run step's output.
+	ShowOutputNever = "never"
+)
+
 type Workflow struct {
 	Apply *Stage
 	Plan  *Stage
+	// PolicyCheck is run after Plan if the repo has policy sets.
+	PolicyCheck *Stage
 }
@@ -14,22 +14,30 @@
 package server
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 
 	"github.com/google/go-github/github"
 	"github.com/lkysow/go-gitlab"
 	"github.com/pkg/errors"
 	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/autoplan"
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/events/vcs"
+	"github.com/runatlantis/atlantis/server/events/vcs/azuredevops"
 	"github.com/runatlantis/atlantis/server/events/vcs/bitbucketcloud"
 	"github.com/runatlantis/atlantis/server/events/vcs/bitbucketserver"
+	"github.com/runatlantis/atlantis/server/events/vcs/gitea"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
 	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/metrics"
 )
 
 const githubHeader = "X-Github-Event"
+const githubDeliveryHeader = "X-Github-Delivery"
 const gitlabHeader = "X-Gitlab-Event"
 
 // bitbucketEventTypeHeader is the same in both cloud and server.
@@ -38,14 +46,27 @@ const bitbucketCloudRequestIDHeader = "X-Request-UUID"
 const bitbucketServerRequestIDHeader = "X-Request-ID"
 const bitbucketServerSignatureHeader = "X-Hub-Signature"
 
+// azuredevopsHeader is set on Azure DevOps service hook requests. Azure DevOps
+// doesn't have a header for the event type so we use its request ID header.
+const azuredevopsHeader = "Request-Id"
+
+// Gitea, and Forgejo for compatibility, also send GitHub's headers so we
+// need to check for Gitea's headers first.
+const giteaHeader = "X-Gitea-Event"
+const giteaRequestIDHeader = "X-Gitea-Delivery"
+const giteaSignatureHeader = "X-Gitea-Signature"
+
 // EventsController handles all webhook requests which signify 'events' in the
 // VCS host, ex. GitHub.
 type EventsController struct {
 	CommandRunner events.CommandRunner
 	PullCleaner   events.PullCleaner
-	Logger        *logging.SimpleLogger
-	Parser        events.EventParsing
-	CommentParser events.CommentParsing
+	// StalePlanDiscarder discards the plans made before new commits were
+	// pushed to a pull request. If nil, they're kept until the next plan.
+	StalePlanDiscarder events.StalePlanDiscarder
+	Logger             *logging.SimpleLogger
+	Parser             events.EventParsing
+	CommentParser      events.CommentParsing
 	// GithubWebhookSecret is the secret added to this webhook via the GitHub
 	// UI that identifies this call as coming from GitHub. If empty, no
 	// request validation is done.
@@ -69,15 +90,72 @@ type EventsController struct {
 	// UI that identifies this call as coming from Bitbucket. If empty, no
 	// request validation is done.
 	BitbucketWebhookSecret []byte
+	// AzureDevopsWebhookPassword is the basic auth password set on the
+	// Azure DevOps service hooks that identifies calls as coming from Azure
+	// DevOps. If empty, no request validation is done.
+	AzureDevopsWebhookPassword []byte
+	// GiteaWebhookSecret is the secret added to this webhook via the Gitea
+	// UI that identifies this call as coming from Gitea. If empty, no
+	// request validation is done.
+	GiteaWebhookSecret []byte
+	// DisableAutoplanLabels are pull request labels that, if any are on a pull
+	// request, cause us to skip autoplanning it.
+	DisableAutoplanLabels []string
+	// AutoplanStore records the pull requests that autoplanning has been
+	// turned off for with atlantis autoplan off. If nil, every pull request
+	// is autoplanned.
+	AutoplanStore autoplan.Store
+	// SkipDraftPRs is true if we should skip autoplanning pull requests that
+	// are drafts.
+	SkipDraftPRs bool
+	// ServerConfig is the server-side repo config. Repos in it can choose
+	// which pull request events they're autoplanned on.
+	ServerConfig valid.ServerConfig
+	// Metrics records the webhooks we receive. If nil, nothing is recorded.
+	Metrics *metrics.Metrics
+	// DeliveryDeduplicator skips the webhooks that were redelivered after
+	// we processed them. If nil, every delivery is processed.
+	DeliveryDeduplicator *events.DeliveryDeduplicator
+	// WebhookLimiter rejects the webhooks that would start commands when
+	// their repo is sending too many or too many commands are waiting. If
+	// nil, they're never rejected.
+	WebhookLimiter *events.WebhookLimiter
 }
 
 // Post handles POST webhook requests.
 func (e *EventsController) Post(w http.ResponseWriter, r *http.Request) {
-	if r.Header.Get(githubHeader) != "" {
+	if id := deliveryID(r); id != "" && e.DeliveryDeduplicator != nil {
+		if e.DeliveryDeduplicator.Seen(id) {
+			e.respond(w, logging.Info, http.StatusOK, "Ignoring duplicate delivery %s", id)
+			return
+		}
+		// If we didn't process the delivery, ex. its signature was wrong or
+		// we failed to get the pull request's labels, a redelivery should
+		// be processed.
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			if rec.status >= http.StatusBadRequest {
+				e.DeliveryDeduplicator.Forget(id)
+			}
+		}()
+		w = rec
+	}
+
+	if r.Header.Get(giteaHeader) != "" {
+		if !e.supportsHost(models.Gitea) {
+			e.respond(w, logging.Debug, http.StatusBadRequest, "Ignoring request since not configured to support Gitea")
+			return
+		}
+		e.Metrics.WebhookReceived(models.Gitea.String())
+		e.Logger.Debug("handling Gitea post")
+		e.handleGiteaPost(w, r)
+		return
+	} else if r.Header.Get(githubHeader) != "" {
 		if !e.supportsHost(models.Github) {
 			e.respond(w, logging.Debug, http.StatusBadRequest, "Ignoring request since not configured to support GitHub")
 			return
 		}
+		e.Metrics.WebhookReceived(models.Github.String())
 		e.Logger.Debug("handling GitHub post")
 		e.handleGithubPost(w, r)
 		return
@@ -86,6 +164,7 @@ func (e *EventsController) Post(w http.ResponseWriter, r *http.Request) {
 			e.respond(w, logging.Debug, http.StatusBadRequest, "Ignoring request since not configured to support GitLab")
 			return
 		}
+		e.Metrics.WebhookReceived(models.Gitlab.String())
 		e.Logger.Debug("handling GitLab post")
 		e.handleGitlabPost(w, r)
 		return
@@ -97,6 +176,7 @@ func (e *EventsController) Post(w http.ResponseWriter, r *http.Request) {
 				e.respond(w, logging.Debug, http.StatusBadRequest, "Ignoring request since not configured to support Bitbucket Cloud")
 				return
 			}
+			e.Metrics.WebhookReceived(models.BitbucketCloud.String())
 			e.Logger.Debug("handling Bitbucket Cloud post")
 			e.handleBitbucketCloudPost(w, r)
 			return
@@ -105,10 +185,20 @@ func (e *EventsController) Post(w http.ResponseWriter, r *http.Request) {
 				e.respond(w, logging.Debug, http.StatusBadRequest, "Ignoring request since not configured to support Bitbucket Server")
 				return
 			}
+			e.Metrics.WebhookReceived(models.BitbucketServer.String())
 			e.Logger.Debug("handling Bitbucket Server post")
 			e.handleBitbucketServerPost(w, r)
 			return
 		}
+	} else if r.Header.Get(azuredevopsHeader) != "" {
+		if !e.supportsHost(models.AzureDevops) {
+			e.respond(w, logging.Debug, http.StatusBadRequest, "Ignoring request since not configured to support Azure DevOps")
+			return
+		}
+		e.Metrics.WebhookReceived(models.AzureDevops.String())
+		e.Logger.Debug("handling Azure DevOps post")
+		e.handleAzureDevopsPost(w, r)
+		return
 	}
 	e.respond(w, logging.Debug, http.StatusBadRequest, "Ignoring request")
 }
@@ -122,7 +212,7 @@ func (e *EventsController) handleGithubPost(w http.ResponseWriter, r *http.Reque
 	}
 	e.Logger.Debug("request valid")
 
-	githubReqID := "X-Github-Delivery=" + r.Header.Get("X-Github-Delivery")
+	githubReqID := githubDeliveryHeader + "=" + r.Header.Get(githubDeliveryHeader)
 	event, _ := github.ParseWebHook(github.WebHookType(r), payload)
 	switch event := event.(type) {
 	case *github.IssueCommentEvent:
@@ -131,6 +221,9 @@ func (e *EventsController) handleGithubPost(w http.ResponseWriter, r *http.Reque
 	case *github.PullRequestEvent:
 		e.Logger.Debug("handling as pull request event")
 		e.HandleGithubPullRequestEvent(w, event, githubReqID)
+	case *github.CheckRunEvent:
+		e.Logger.Debug("handling as check run event")
+		e.HandleGithubCheckRunEvent(w, event, githubReqID)
 	default:
 		e.respond(w, logging.Debug, http.StatusOK, "Ignoring unsupported event %s", githubReqID)
 	}
@@ -176,7 +269,7 @@ func (e *EventsController) handleBitbucketServerPost(w http.ResponseWriter, r *h
 		}
 	}
 	switch eventType {
-	case bitbucketserver.PullCreatedHeader, bitbucketserver.PullMergedHeader, bitbucketserver.PullDeclinedHeader:
+	case bitbucketserver.PullCreatedHeader, bitbucketserver.PullFromRefUpdatedHeader, bitbucketserver.PullMergedHeader, bitbucketserver.PullDeclinedHeader, bitbucketserver.PullDeletedHeader:
 		e.Logger.Debug("handling as pull request state changed event")
 		e.handleBitbucketServerPullRequestEvent(w, eventType, body, reqID)
 		return
@@ -184,11 +277,86 @@ func (e *EventsController) handleBitbucketServerPost(w http.ResponseWriter, r *h
 		e.Logger.Debug("handling as comment created event")
 		e.HandleBitbucketServerCommentEvent(w, body, reqID)
 		return
+	case bitbucketserver.DiagnosticsPingHeader:
+		// We got here so the signature, if there's a secret, was valid.
+		e.respond(w, logging.Info, http.StatusOK, "Pong %s=%s", bitbucketServerRequestIDHeader, reqID)
+		return
 	default:
 		e.respond(w, logging.Debug, http.StatusOK, "Ignoring unsupported event type %s %s=%s", eventType, bitbucketServerRequestIDHeader, reqID)
 	}
 }
 
+func (e *EventsController) handleGiteaPost(w http.ResponseWriter, r *http.Request) {
+	eventType := r.Header.Get(giteaHeader)
+	reqID := r.Header.Get(giteaRequestIDHeader)
+	sig := r.Header.Get(giteaSignatureHeader)
+	defer r.Body.Close() // nolint: errcheck
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		e.respond(w, logging.Error, http.StatusBadRequest, "Unable to read body: %s %s=%s", err, giteaRequestIDHeader, reqID)
+		return
+	}
+	if len(e.GiteaWebhookSecret) > 0 {
+		if err := gitea.ValidateSignature(body, sig, e.GiteaWebhookSecret); err != nil {
+			e.respond(w, logging.Warn, http.StatusBadRequest, "%s", errors.Wrap(err, "request did not pass validation").Error())
+			return
+		}
+	}
+	switch eventType {
+	case gitea.PullRequestEventType:
+		var event gitea.PullRequestEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			e.respond(w, logging.Error, http.StatusBadRequest, "Error parsing pull data: %s %s=%s", err, giteaRequestIDHeader, reqID)
+			return
+		}
+		e.Logger.Debug("handling as pull request event")
+		e.HandleGiteaPullRequestEvent(w, event, reqID)
+	case gitea.IssueCommentEventType:
+		var event gitea.IssueCommentEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			e.respond(w, logging.Error, http.StatusBadRequest, "Error parsing comment data: %s %s=%s", err, giteaRequestIDHeader, reqID)
+			return
+		}
+		e.Logger.Debug("handling as comment event")
+		e.HandleGiteaCommentEvent(w, event, reqID)
+	default:
+		e.respond(w, logging.Debug, http.StatusOK, "Ignoring unsupported event type %s %s=%s", eventType, giteaRequestIDHeader, reqID)
+	}
+}
+
+func (e *EventsController) handleAzureDevopsPost(w http.ResponseWriter, r *http.Request) {
+	reqID := r.Header.Get(azuredevopsHeader)
+	if len(e.AzureDevopsWebhookPassword) > 0 {
+		if err := azuredevops.ValidateWebhookPassword(r, e.AzureDevopsWebhookPassword); err != nil {
+			e.respond(w, logging.Warn, http.StatusBadRequest, "%s", errors.Wrap(err, "request did not pass validation").Error())
+			return
+		}
+	}
+	defer r.Body.Close() // nolint: errcheck
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		e.respond(w, logging.Error, http.StatusBadRequest, "Unable to read body: %s %s=%s", err, azuredevopsHeader, reqID)
+		return
+	}
+	var event azuredevops.Event
+	if err := json.Unmarshal(body, &event); err != nil || event.EventType == nil {
+		e.respond(w, logging.Error, http.StatusBadRequest, "Unable to determine event type %s=%s", azuredevopsHeader, reqID)
+		return
+	}
+	switch *event.EventType {
+	case azuredevops.PullCreatedEvent, azuredevops.PullUpdatedEvent, azuredevops.PullMergedEvent:
+		e.Logger.Debug("handling as pull request state changed event")
+		e.handleAzureDevopsPullRequestEvent(w, body, reqID)
+		return
+	case azuredevops.PullCommentedEvent:
+		e.Logger.Debug("handling as comment created event")
+		e.HandleAzureDevopsCommentEvent(w, body, reqID)
+		return
+	default:
+		e.respond(w, logging.Debug, http.StatusOK, "Ignoring unsupported event type %s %s=%s", *event.EventType, azuredevopsHeader, reqID)
+	}
+}
+
 // HandleGithubCommentEvent handles comment events from GitHub where Atlantis
 // commands can come from. It's exported to make testing easier.
 func (e *EventsController) HandleGithubCommentEvent(w http.ResponseWriter, event *github.IssueCommentEvent, githubReqID string) {
@@ -205,7 +373,71 @@ func (e *EventsController) HandleGithubCommentEvent(w http.ResponseWriter, event
 
 	// We pass in nil for maybeHeadRepo because the head repo data isn't
 	// available in the GithubIssueComment event.
-	e.handleCommentEvent(w, baseRepo, nil, nil, user, pullNum, event.Comment.GetBody(), models.Github)
+	e.handleCommentEvent(w, baseRepo, nil, nil, user, pullNum, event.Comment.GetBody(), event.Comment.GetID(), models.Github)
+}
+
+// HandleGithubCheckRunEvent plans again when someone re-runs one of our check
+// runs from the Checks UI. It's exported to make testing easier.
+func (e *EventsController) HandleGithubCheckRunEvent(w http.ResponseWriter, event *github.CheckRunEvent, githubReqID string) {
+	if event.GetAction() != "rerequested" {
+		e.respond(w, logging.Debug, http.StatusOK, "Ignoring check run event since action was not rerequested %s", githubReqID)
+		return
+	}
+	checkRun := event.GetCheckRun()
+	if checkRun.GetExternalID() == "" || len(checkRun.PullRequests) == 0 {
+		e.respond(w, logging.Debug, http.StatusOK, "Ignoring check run event since the check run isn't for a pull request %s", githubReqID)
+		return
+	}
+	cmd, err := events.ParseCheckRunExternalID(checkRun.GetExternalID())
+	if err != nil {
+		e.respond(w, logging.Error, http.StatusBadRequest, "Failed parsing event: %v %s", err, githubReqID)
+		return
+	}
+	baseRepo, err := e.Parser.ParseGithubRepo(event.GetRepo())
+	if err != nil {
+		e.respond(w, logging.Error, http.StatusBadRequest, "Failed parsing event: %v %s", err, githubReqID)
+		return
+	}
+	pullNum := checkRun.PullRequests[0].GetNumber()
+	user := models.User{Username: event.GetSender().GetLogin()}
+
+	if !e.RepoWhitelistChecker.IsWhitelisted(baseRepo.FullName, baseRepo.VCSHost.Hostname) {
+		e.respond(w, logging.Warn, http.StatusForbidden, "Repo not whitelisted")
+		return
+	}
+	if e.rejectWebhook(w, baseRepo) {
+		return
+	}
+
+	e.Logger.Info("check run %q was re-run, running %s", checkRun.GetName(), cmd)
+	fmt.Fprintln(w, "Processing...")
+	if !e.TestingMode {
+		go e.CommandRunner.RunCommentCommand(baseRepo, nil, nil, user, pullNum, cmd)
+	} else {
+		e.CommandRunner.RunCommentCommand(baseRepo, nil, nil, user, pullNum, cmd)
+	}
+}
+
+// HandleGiteaCommentEvent handles comment events from Gitea.
+func (e *EventsController) HandleGiteaCommentEvent(w http.ResponseWriter, event gitea.IssueCommentEvent, reqID string) {
+	if event.Action == nil || *event.Action != gitea.CommentCreatedAction {
+		e.respond(w, logging.Debug, http.StatusOK, "Ignoring comment event since action was not created %s=%s", giteaRequestIDHeader, reqID)
+		return
+	}
+	if !event.IsPull {
+		e.respond(w, logging.Debug, http.StatusOK, "Ignoring comment event since it was not on a pull request %s=%s", giteaRequestIDHeader, reqID)
+		return
+	}
+
+	baseRepo, user, pullNum, err := e.Parser.ParseGiteaIssueCommentEvent(event)
+	if err != nil {
+		e.respond(w, logging.Error, http.StatusBadRequest, "Failed parsing event: %v %s=%s", err, giteaRequestIDHeader, reqID)
+		return
+	}
+
+	// We pass in nil for maybeHeadRepo because the head repo data isn't
+	// available in the comment event.
+	e.handleCommentEvent(w, baseRepo, nil, nil, user, pullNum, *event.Comment.Body, *event.Comment.ID, models.Gitea)
 }
 
 // HandleBitbucketCloudCommentEvent handles comment events from Bitbucket.
@@ -215,7 +447,7 @@ func (e *EventsController) HandleBitbucketCloudCommentEvent(w http.ResponseWrite
 		e.respond(w, logging.Error, http.StatusBadRequest, "Error parsing pull data: %s %s=%s", err, bitbucketCloudRequestIDHeader, reqID)
 		return
 	}
-	e.handleCommentEvent(w, baseRepo, &headRepo, &pull, user, pull.Num, comment, models.BitbucketCloud)
+	e.handleCommentEvent(w, baseRepo, &headRepo, &pull, user, pull.Num, comment, 0, models.BitbucketCloud)
 }
 
 // HandleBitbucketServerCommentEvent handles comment events from Bitbucket.
@@ -225,7 +457,17 @@ func (e *EventsController) HandleBitbucketServerCommentEvent(w http.ResponseWrit
 		e.respond(w, logging.Error, http.StatusBadRequest, "Error parsing pull data: %s %s=%s", err, bitbucketCloudRequestIDHeader, reqID)
 		return
 	}
-	e.handleCommentEvent(w, baseRepo, &headRepo, &pull, user, pull.Num, comment, models.BitbucketCloud)
+	e.handleCommentEvent(w, baseRepo, &headRepo, &pull, user, pull.Num, comment, 0, models.BitbucketCloud)
+}
+
+// HandleAzureDevopsCommentEvent handles comment events from Azure DevOps.
+func (e *EventsController) HandleAzureDevopsCommentEvent(w http.ResponseWriter, body []byte, reqID string) {
+	pull, baseRepo, headRepo, user, comment, err := e.Parser.ParseAzureDevopsPullCommentEvent(body)
+	if err != nil {
+		e.respond(w, logging.Error, http.StatusBadRequest, "Error parsing pull data: %s %s=%s", err, azuredevopsHeader, reqID)
+		return
+	}
+	e.handleCommentEvent(w, baseRepo, &headRepo, &pull, user, pull.Num, comment, 0, models.AzureDevops)
 }
 
 func (e *EventsController) handleBitbucketCloudPullRequestEvent(w http.ResponseWriter, eventType string, body []byte, reqID string) {
@@ -250,6 +492,16 @@ func (e *EventsController) handleBitbucketServerPullRequestEvent(w http.Response
 	e.handlePullRequestEvent(w, baseRepo, headRepo, pull, user, pullEventType)
 }
 
+func (e *EventsController) handleAzureDevopsPullRequestEvent(w http.ResponseWriter, body []byte, reqID string) {
+	pull, pullEventType, baseRepo, headRepo, user, err := e.Parser.ParseAzureDevopsPullEvent(body)
+	if err != nil {
+		e.respond(w, logging.Error, http.StatusBadRequest, "Error parsing pull data: %s %s=%s", err, azuredevopsHeader, reqID)
+		return
+	}
+	e.Logger.Info("identified event as type %q", pullEventType.String())
+	e.handlePullRequestEvent(w, baseRepo, headRepo, pull, user, pullEventType)
+}
+
 // HandleGithubPullRequestEvent will delete any locks associated with the pull
 // request if the event is a pull request closed event. It's exported to make
 // testing easier.
@@ -263,6 +515,27 @@ func (e *EventsController) HandleGithubPullRequestEvent(w http.ResponseWriter, p
 	e.handlePullRequestEvent(w, baseRepo, headRepo, pull, user, pullEventType)
 }
 
+// HandleGiteaPullRequestEvent will delete any locks associated with the pull
+// request if the event is a pull request closed event. It's exported to make
+// testing easier.
+func (e *EventsController) HandleGiteaPullRequestEvent(w http.ResponseWriter, event gitea.PullRequestEvent, reqID string) {
+	pull, pullEventType, baseRepo, headRepo, user, err := e.Parser.ParseGiteaPullEvent(event)
+	if err != nil {
+		e.respond(w, logging.Error, http.StatusBadRequest, "Error parsing pull data: %s %s=%s", err, giteaRequestIDHeader, reqID)
+		return
+	}
+	e.Logger.Info("identified event as type %q", pullEventType.String())
+	e.handlePullRequestEvent(w, baseRepo, headRepo, pull, user, pullEventType)
+}
+
+// autoplanEvents maps the pull request events that can cause an autoplan to
+// their names in the server-side repo config's autoplan_on key.
+var autoplanEvents = map[models.PullRequestEventType]string{
+	models.OpenedPullEvent:         valid.AutoplanOnOpened,
+	models.UpdatedPullEvent:        valid.AutoplanOnPushed,
+	models.ReadyForReviewPullEvent: valid.AutoplanOnReadyForReview,
+}
+
 func (e *EventsController) handlePullRequestEvent(w http.ResponseWriter, baseRepo models.Repo, headRepo models.Repo, pull models.PullRequest, user models.User, eventType models.PullRequestEventType) {
 	if !e.RepoWhitelistChecker.IsWhitelisted(baseRepo.FullName, baseRepo.VCSHost.Hostname) {
 		// If the repo isn't whitelisted and we receive an opened pull request
@@ -277,8 +550,55 @@ func (e *EventsController) handlePullRequestEvent(w http.ResponseWriter, baseRep
 	}
 
 	switch eventType {
-	case models.OpenedPullEvent, models.UpdatedPullEvent:
+	case models.OpenedPullEvent, models.UpdatedPullEvent, models.ReadyForReviewPullEvent:
+		// New commits make the existing plans stale so we discard them,
+		// even if we don't autoplan the new commits.
+		if eventType == models.UpdatedPullEvent && e.StalePlanDiscarder != nil {
+			if _, err := e.StalePlanDiscarder.DiscardStalePlans(e.Logger, baseRepo, pull); err != nil {
+				e.Logger.Err("unable to discard stale plans for repo %s, pull %d: %s", baseRepo.FullName, pull.Num, err)
+			}
+		}
+		if repoCfg := e.ServerConfig.FindRepo(baseRepo.FullName, baseRepo.VCSHost.Hostname); repoCfg != nil && !repoCfg.AutoplansOn(autoplanEvents[eventType]) {
+			e.respond(w, logging.Info, http.StatusOK, "Ignoring autoplan since the server-side repo config doesn't autoplan on %q events", autoplanEvents[eventType])
+			return
+		}
 		// If the pull request was opened or updated, we will try to autoplan.
+		// Unless the pull request has been labelled to disable autoplanning.
+		label, err := e.findDisableAutoplanLabel(baseRepo, pull)
+		if err != nil {
+			e.respond(w, logging.Error, http.StatusInternalServerError, "Error getting pull request labels: %s", err)
+			return
+		}
+		if label != "" {
+			e.respond(w, logging.Info, http.StatusOK, "Ignoring autoplan since pull request has label %q", label)
+			return
+		}
+		if e.AutoplanStore != nil {
+			disabled, err := e.AutoplanStore.AutoplanDisabled(baseRepo.FullName, pull.Num)
+			if err != nil {
+				e.respond(w, logging.Error, http.StatusInternalServerError, "Error checking if autoplanning is off for pull request: %s", err)
+				return
+			}
+			if disabled {
+				e.respond(w, logging.Info, http.StatusOK, "Ignoring autoplan since it's been turned off for this pull request")
+				return
+			}
+		}
+		if e.SkipDraftPRs {
+			draft, err := e.VCSClient.PullIsDraft(baseRepo, pull)
+			if err != nil {
+				e.respond(w, logging.Error, http.StatusInternalServerError, "Error checking if pull request is a draft: %s", err)
+				return
+			}
+			if draft {
+				e.respond(w, logging.Info, http.StatusOK, "Ignoring autoplan since pull request is a draft")
+				return
+			}
+		}
+
+		if e.rejectWebhook(w, baseRepo) {
+			return
+		}
 
 		// Respond with success and then actually execute the command asynchronously.
 		// We use a goroutine so that this function returns and the connection is
@@ -342,10 +662,10 @@ func (e *EventsController) HandleGitlabCommentEvent(w http.ResponseWriter, event
 		e.respond(w, logging.Error, http.StatusBadRequest, "Error parsing webhook: %s", err)
 		return
 	}
-	e.handleCommentEvent(w, baseRepo, &headRepo, nil, user, event.MergeRequest.IID, event.ObjectAttributes.Note, models.Gitlab)
+	e.handleCommentEvent(w, baseRepo, &headRepo, nil, user, event.MergeRequest.IID, event.ObjectAttributes.Note, int64(event.ObjectAttributes.ID), models.Gitlab)
 }
 
-func (e *EventsController) handleCommentEvent(w http.ResponseWriter, baseRepo models.Repo, maybeHeadRepo *models.Repo, maybePull *models.PullRequest, user models.User, pullNum int, comment string, vcsHost models.VCSHostType) {
+func (e *EventsController) handleCommentEvent(w http.ResponseWriter, baseRepo models.Repo, maybeHeadRepo *models.Repo, maybePull *models.PullRequest, user models.User, pullNum int, comment string, commentID int64, vcsHost models.VCSHostType) {
 	parseResult := e.CommentParser.Parse(comment, vcsHost)
 	if parseResult.Ignore {
 		truncated := comment
@@ -378,6 +698,19 @@ func (e *EventsController) handleCommentEvent(w http.ResponseWriter, baseRepo mo
 		return
 	}
 
+	if e.rejectWebhook(w, baseRepo) {
+		return
+	}
+
+	// Acknowledge that we've received the command. The command runner will
+	// react again once the command is complete.
+	if commentID != 0 && parseResult.Command != nil {
+		parseResult.Command.CommentID = commentID
+		if err := e.VCSClient.ReactToComment(baseRepo, pullNum, commentID, vcs.ReceivedReaction); err != nil {
+			e.Logger.Warn("unable to react to comment: %s", err)
+		}
+	}
+
 	e.Logger.Debug("executing command")
 	fmt.Fprintln(w, "Processing...")
 	if !e.TestingMode {
@@ -404,6 +737,27 @@ func (e *EventsController) HandleGitlabMergeRequestEvent(w http.ResponseWriter,
 	e.handlePullRequestEvent(w, baseRepo, headRepo, pull, user, pullEventType)
 }
 
+// findDisableAutoplanLabel returns the first label on the pull request that
+// disables autoplanning or an empty string if there are none. We only fetch
+// the labels if disable autoplan labels are configured.
+func (e *EventsController) findDisableAutoplanLabel(baseRepo models.Repo, pull models.PullRequest) (string, error) {
+	if len(e.DisableAutoplanLabels) == 0 {
+		return "", nil
+	}
+	labels, err := e.VCSClient.GetPullLabels(baseRepo, pull)
+	if err != nil {
+		return "", err
+	}
+	for _, l := range labels {
+		for _, disableLabel := range e.DisableAutoplanLabels {
+			if l == disableLabel {
+				return l, nil
+			}
+		}
+	}
+	return "", nil
+}
+
 // supportsHost returns true if h is in e.SupportedVCSHosts and false otherwise.
 func (e *EventsController) supportsHost(h models.VCSHostType) bool {
 	for _, supported := range e.SupportedVCSHosts {
@@ -414,6 +768,30 @@ func (e *EventsController) supportsHost(h models.VCSHostType) bool {
 	return false
 }
 
+// deliveryID returns the ID of the webhook delivery, which is the same when
+// it's redelivered, or "" if the VCS host doesn't send one. Only GitHub and
+// Gitea keep the ID when redelivering.
+func deliveryID(r *http.Request) string {
+	if id := r.Header.Get(giteaRequestIDHeader); id != "" && r.Header.Get(giteaHeader) != "" {
+		return giteaRequestIDHeader + "=" + id
+	}
+	if id := r.Header.Get(githubDeliveryHeader); id != "" && r.Header.Get(githubHeader) != "" {
+		return githubDeliveryHeader + "=" + id
+	}
+	return ""
+}
+
+// statusRecorder records the status code written to the response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
 func (e *EventsController) respond(w http.ResponseWriter, lvl logging.LogLevel, code int, format string, args ...interface{}) {
 	response := fmt.Sprintf(format, args...)
 	e.Logger.Log(lvl, response)
@@ -421,6 +799,23 @@ func (e *EventsController) respond(w http.ResponseWriter, lvl logging.LogLevel,
 	fmt.Fprintln(w, response)
 }
 
+// rejectWebhook responds with 429 Too Many Requests and returns true if
+// WebhookLimiter won't let baseRepo start a command. The VCS host, or someone
+// redelivering the webhook, should retry after the Retry-After header.
+func (e *EventsController) rejectWebhook(w http.ResponseWriter, baseRepo models.Repo) bool {
+	if e.WebhookLimiter == nil {
+		return false
+	}
+	rejection := e.WebhookLimiter.Allow(fmt.Sprintf("%s/%s", baseRepo.VCSHost.Hostname, baseRepo.FullName))
+	if rejection == nil {
+		return false
+	}
+	e.Metrics.WebhookRejected(rejection.Reason)
+	w.Header().Set("Retry-After", strconv.Itoa(int(rejection.RetryAfter.Seconds())))
+	e.respond(w, logging.Warn, http.StatusTooManyRequests, "Rejecting webhook since %s", rejection.Message)
+	return true
+}
+
 // commentNotWhitelisted comments on the pull request that the repo is not
 // whitelisted unless whitelist error comments are disabled.
 func (e *EventsController) commentNotWhitelisted(baseRepo models.Repo, pullNum int) {
@@ -273,7 +273,7 @@ func setupE2E(t *testing.T) (server.EventsController, *vcsmocks.MockClientProxy,
 		GitlabUser:  "gitlab-user",
 		GitlabToken: "gitlab-token",
 	}
-	terraformClient, err := terraform.NewClient(dataDir, "")
+	terraformClient, err := terraform.NewClient(dataDir, "", "", nil, true, nil, nil)
 	Ok(t, err)
 	boltdb, err := boltdb.New(dataDir)
 	Ok(t, err)
@@ -289,6 +289,7 @@ func setupE2E(t *testing.T) (server.EventsController, *vcsmocks.MockClientProxy,
 	defaultTFVersion := terraformClient.Version()
 	locker := events.NewDefaultWorkingDirLocker()
 	commandRunner := &events.DefaultCommandRunner{
+		CommandCanceller: events.NewDefaultCommandCanceller(),
 		ProjectCommandRunner: &events.DefaultProjectCommandRunner{
 			Locker:           projectLocker,
 			LockURLGenerator: &mockLockURLGenerator{},
@@ -366,7 +367,7 @@ func (m *mockLockURLGenerator) GenerateLockURL(lockID string) string {
 
 type mockWebhookSender struct{}
 
-func (w *mockWebhookSender) Send(log *logging.SimpleLogger, result webhooks.ApplyResult) error {
+func (w *mockWebhookSender) Send(log *logging.SimpleLogger, result webhooks.Result) error {
 	return nil
 }
 
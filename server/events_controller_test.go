@@ -15,6 +15,11 @@ package server_test
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha1" // nolint: gosec
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -28,10 +33,13 @@ import (
 	. "github.com/petergtz/pegomock"
 	"github.com/runatlantis/atlantis/server"
 	"github.com/runatlantis/atlantis/server/events"
+	autoplanmocks "github.com/runatlantis/atlantis/server/events/autoplan/mocks"
 	emocks "github.com/runatlantis/atlantis/server/events/mocks"
 	"github.com/runatlantis/atlantis/server/events/mocks/matchers"
 	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs/gitea"
 	vcsmocks "github.com/runatlantis/atlantis/server/events/vcs/mocks"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
 	"github.com/runatlantis/atlantis/server/logging"
 	"github.com/runatlantis/atlantis/server/mocks"
 	. "github.com/runatlantis/atlantis/testing"
@@ -39,6 +47,8 @@ import (
 
 const githubHeader = "X-Github-Event"
 const gitlabHeader = "X-Gitlab-Event"
+const azuredevopsHeader = "Request-Id"
+const giteaHeader = "X-Gitea-Event"
 
 var secret = []byte("secret")
 
@@ -73,6 +83,28 @@ func TestPost_UnsupportedVCSGitlab(t *testing.T) {
 	responseContains(t, w, http.StatusBadRequest, "Ignoring request since not configured to support GitLab")
 }
 
+func TestPost_UnsupportedVCSAzureDevops(t *testing.T) {
+	t.Log("when the request is for an unsupported vcs a 400 is returned")
+	e, _, _, _, _, _, _, _ := setup(t)
+	e.SupportedVCSHosts = nil
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+	req.Header.Set(azuredevopsHeader, "value")
+	w := httptest.NewRecorder()
+	e.Post(w, req)
+	responseContains(t, w, http.StatusBadRequest, "Ignoring request since not configured to support Azure DevOps")
+}
+
+func TestPost_UnsupportedVCSGitea(t *testing.T) {
+	t.Log("when the request is for an unsupported vcs a 400 is returned")
+	e, _, _, _, _, _, _, _ := setup(t)
+	e.SupportedVCSHosts = nil
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+	req.Header.Set(giteaHeader, "value")
+	w := httptest.NewRecorder()
+	e.Post(w, req)
+	responseContains(t, w, http.StatusBadRequest, "Ignoring request since not configured to support Gitea")
+}
+
 func TestPost_InvalidGithubSecret(t *testing.T) {
 	t.Log("when the github payload can't be validated a 400 is returned")
 	e, v, _, _, _, _, _, _ := setup(t)
@@ -95,6 +127,83 @@ func TestPost_InvalidGitlabSecret(t *testing.T) {
 	responseContains(t, w, http.StatusBadRequest, "err")
 }
 
+func TestPost_InvalidAzureDevopsPassword(t *testing.T) {
+	t.Log("when the azure devops basic auth password doesn't match a 400 is returned")
+	e, _, _, _, _, _, _, _ := setup(t)
+	e.SupportedVCSHosts = []models.VCSHostType{models.AzureDevops}
+	e.AzureDevopsWebhookPassword = []byte("password")
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+	req.Header.Set(azuredevopsHeader, "value")
+	req.SetBasicAuth("atlantis", "wrong")
+	e.Post(w, req)
+	responseContains(t, w, http.StatusBadRequest, "request did not pass validation: basic auth password did not match")
+}
+
+func TestPost_InvalidGiteaSignature(t *testing.T) {
+	t.Log("when the gitea payload signature doesn't match a 400 is returned")
+	e, _, _, _, _, _, _, _ := setup(t)
+	e.SupportedVCSHosts = []models.VCSHostType{models.Gitea}
+	e.GiteaWebhookSecret = secret
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer([]byte(`{"action": "created"}`)))
+	req.Header.Set(giteaHeader, "issue_comment")
+	req.Header.Set("X-Gitea-Signature", "abcd")
+	e.Post(w, req)
+	responseContains(t, w, http.StatusBadRequest, "request did not pass validation: payload signature check failed")
+}
+
+func TestPost_GiteaHeadersTakePrecedence(t *testing.T) {
+	t.Log("when the request has both gitea and github headers it is handled as a gitea request")
+	e, _, _, _, _, _, _, _ := setup(t)
+	e.SupportedVCSHosts = []models.VCSHostType{models.Github, models.Gitea}
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer([]byte(`{}`)))
+	req.Header.Set(giteaHeader, "push")
+	req.Header.Set("X-Gitea-Delivery", "delivery-id")
+	req.Header.Set(githubHeader, "push")
+	e.Post(w, req)
+	responseContains(t, w, http.StatusOK, "Ignoring unsupported event type push X-Gitea-Delivery=delivery-id")
+}
+
+func TestPost_BitbucketServerSignature(t *testing.T) {
+	body := []byte(`{"test": true}`)
+	mac := hmac.New(sha256.New, []byte("bb-secret"))
+	mac.Write(body) // nolint: errcheck
+	validSig := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	weakMAC := hmac.New(sha1.New, []byte("bb-secret"))
+	weakMAC.Write(body) // nolint: errcheck
+
+	cases := map[string]struct {
+		secret    string
+		sig       string
+		expStatus int
+		expBody   string
+	}{
+		"valid":         {"bb-secret", validSig, http.StatusOK, "Pong X-Request-ID=req-id"},
+		"no secret set": {"", "", http.StatusOK, "Pong X-Request-ID=req-id"},
+		"missing":       {"bb-secret", "", http.StatusBadRequest, "request did not pass validation: missing signature"},
+		"wrong secret":  {"other-secret", validSig, http.StatusBadRequest, "request did not pass validation: payload signature check failed"},
+		"sha1":          {"bb-secret", "sha1=" + hex.EncodeToString(weakMAC.Sum(nil)), http.StatusBadRequest, `unsupported hash type prefix: "sha1"`},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			e, _, _, _, _, _, _, _ := setup(t)
+			e.SupportedVCSHosts = []models.VCSHostType{models.BitbucketServer}
+			e.BitbucketWebhookSecret = []byte(c.secret)
+			req, _ := http.NewRequest("POST", "", bytes.NewBuffer(body))
+			req.Header.Set("X-Event-Key", "diagnostics:ping")
+			req.Header.Set("X-Request-ID", "req-id")
+			if c.sig != "" {
+				req.Header.Set("X-Hub-Signature", c.sig)
+			}
+			w := httptest.NewRecorder()
+			e.Post(w, req)
+			responseContains(t, w, c.expStatus, c.expBody)
+		})
+	}
+}
+
 func TestPost_UnsupportedGithubEvent(t *testing.T) {
 	t.Log("when the event type is an unsupported github event we ignore it")
 	e, v, _, _, _, _, _, _ := setup(t)
@@ -181,6 +290,42 @@ func TestPost_GithubCommentInvalidCommand(t *testing.T) {
 	responseContains(t, w, http.StatusOK, "Ignoring non-command comment: \"\"")
 }
 
+func TestPost_GithubDuplicateDelivery(t *testing.T) {
+	t.Log("when a delivery is redelivered we ignore it")
+	e, v, _, p, _, _, _, cp := setup(t)
+	e.DeliveryDeduplicator = &events.DeliveryDeduplicator{}
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+	req.Header.Set(githubHeader, "issue_comment")
+	req.Header.Set("X-Github-Delivery", "delivery-1")
+	When(v.Validate(req, secret)).ThenReturn([]byte(`{"action": "created"}`), nil)
+	When(p.ParseGithubIssueCommentEvent(matchers.AnyPtrToGithubIssueCommentEvent())).ThenReturn(models.Repo{}, models.User{}, 1, nil)
+	When(cp.Parse("", models.Github)).ThenReturn(events.CommentParseResult{Ignore: true})
+	w := httptest.NewRecorder()
+	e.Post(w, req)
+	responseContains(t, w, http.StatusOK, "Ignoring non-command comment")
+
+	w = httptest.NewRecorder()
+	e.Post(w, req)
+	responseContains(t, w, http.StatusOK, "Ignoring duplicate delivery X-Github-Delivery=delivery-1")
+	v.VerifyWasCalledOnce().Validate(req, secret)
+}
+
+func TestPost_GithubFailedDeliveryRedelivered(t *testing.T) {
+	t.Log("when we fail to process a delivery we process its redelivery")
+	e, v, _, _, _, _, _, _ := setup(t)
+	e.DeliveryDeduplicator = &events.DeliveryDeduplicator{}
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+	req.Header.Set(githubHeader, "issue_comment")
+	req.Header.Set("X-Github-Delivery", "delivery-1")
+	When(v.Validate(req, secret)).ThenReturn(nil, errors.New("err"))
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		e.Post(w, req)
+		responseContains(t, w, http.StatusBadRequest, "err")
+	}
+	v.VerifyWasCalled(Times(2)).Validate(req, secret)
+}
+
 func TestPost_GitlabCommentNotWhitelisted(t *testing.T) {
 	t.Log("when the event is a gitlab comment from a repo that isn't whitelisted we comment with an error")
 	RegisterMockTestingT(t)
@@ -340,6 +485,60 @@ func TestPost_GitlabCommentSuccess(t *testing.T) {
 	cr.VerifyWasCalledOnce().RunCommentCommand(models.Repo{}, &models.Repo{}, nil, models.User{}, 0, nil)
 }
 
+func TestPost_AzureDevopsCommentSuccess(t *testing.T) {
+	t.Log("when the event is an azure devops comment with a valid command we call the command handler")
+	e, _, _, p, cr, _, _, cp := setup(t)
+	e.SupportedVCSHosts = []models.VCSHostType{models.AzureDevops}
+	body := `{"eventType": "ms.vss-code.git-pullrequest-comment-event"}`
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer([]byte(body)))
+	req.Header.Set(azuredevopsHeader, "value")
+	baseRepo := models.Repo{}
+	user := models.User{}
+	cmd := events.CommentCommand{}
+	pull := models.PullRequest{Num: 1}
+	When(p.ParseAzureDevopsPullCommentEvent([]byte(body))).ThenReturn(pull, baseRepo, baseRepo, user, "atlantis plan", nil)
+	When(cp.Parse("atlantis plan", models.AzureDevops)).ThenReturn(events.CommentParseResult{Command: &cmd})
+	w := httptest.NewRecorder()
+	e.Post(w, req)
+	responseContains(t, w, http.StatusOK, "Processing...")
+
+	cr.VerifyWasCalledOnce().RunCommentCommand(baseRepo, &baseRepo, &pull, user, 1, &cmd)
+}
+
+func TestPost_GiteaCommentNotOnPull(t *testing.T) {
+	t.Log("when the event is a gitea comment on an issue we ignore it")
+	e, _, _, _, cr, _, _, _ := setup(t)
+	e.SupportedVCSHosts = []models.VCSHostType{models.Gitea}
+	body := `{"action": "created", "issue": {"number": 1}, "comment": {"id": 2, "body": "atlantis plan", "user": {"login": "user"}}, "is_pull": false}`
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer([]byte(body)))
+	req.Header.Set(giteaHeader, "issue_comment")
+	w := httptest.NewRecorder()
+	e.Post(w, req)
+	responseContains(t, w, http.StatusOK, "Ignoring comment event since it was not on a pull request")
+	cr.VerifyWasCalled(Never()).RunCommentCommand(matchers.AnyModelsRepo(), matchers.AnyPtrToModelsRepo(), matchers.AnyPtrToModelsPullRequest(), matchers.AnyModelsUser(), AnyInt(), matchers.AnyPtrToEventsCommentCommand())
+}
+
+func TestPost_GiteaCommentSuccess(t *testing.T) {
+	t.Log("when the event is a gitea comment with a valid command we call the command handler")
+	e, _, _, p, cr, _, _, cp := setup(t)
+	e.SupportedVCSHosts = []models.VCSHostType{models.Gitea}
+	body := `{"action": "created", "issue": {"number": 1}, "comment": {"id": 2, "body": "atlantis plan", "user": {"login": "user"}}, "is_pull": true}`
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer([]byte(body)))
+	req.Header.Set(giteaHeader, "issue_comment")
+	var event gitea.IssueCommentEvent
+	Ok(t, json.Unmarshal([]byte(body), &event))
+	baseRepo := models.Repo{}
+	user := models.User{}
+	cmd := events.CommentCommand{}
+	When(p.ParseGiteaIssueCommentEvent(event)).ThenReturn(baseRepo, user, 1, nil)
+	When(cp.Parse("atlantis plan", models.Gitea)).ThenReturn(events.CommentParseResult{Command: &cmd})
+	w := httptest.NewRecorder()
+	e.Post(w, req)
+	responseContains(t, w, http.StatusOK, "Processing...")
+
+	cr.VerifyWasCalledOnce().RunCommentCommand(baseRepo, nil, nil, user, 1, &cmd)
+}
+
 func TestPost_GithubCommentSuccess(t *testing.T) {
 	t.Log("when the event is a github comment with a valid command we call the command handler")
 	e, v, _, p, cr, _, _, cp := setup(t)
@@ -581,6 +780,246 @@ func TestPost_PullOpenedOrUpdated(t *testing.T) {
 	}
 }
 
+func TestPost_PullOpenedWithDisableAutoplanLabel(t *testing.T) {
+	t.Log("when the pull request has a disable autoplan label we don't autoplan")
+	e, v, _, p, cr, _, vcsClient, _ := setup(t)
+	e.DisableAutoplanLabels = []string{"wip", "do-not-plan"}
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+	req.Header.Set(githubHeader, "pull_request")
+	When(v.Validate(req, secret)).ThenReturn([]byte(`{"action": "opened"}`), nil)
+	repo := models.Repo{}
+	pull := models.PullRequest{State: models.OpenPullState}
+	When(p.ParseGithubPullEvent(matchers.AnyPtrToGithubPullRequestEvent())).ThenReturn(pull, models.OpenedPullEvent, repo, repo, models.User{}, nil)
+	When(vcsClient.GetPullLabels(repo, pull)).ThenReturn([]string{"bug", "do-not-plan"}, nil)
+	w := httptest.NewRecorder()
+	e.Post(w, req)
+	responseContains(t, w, http.StatusOK, "Ignoring autoplan since pull request has label \"do-not-plan\"")
+	cr.VerifyWasCalled(Never()).RunAutoplanCommand(matchers.AnyModelsRepo(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyModelsUser())
+}
+
+func TestPost_PullUpdatedWithAutoplanOff(t *testing.T) {
+	t.Log("when autoplanning has been turned off for the pull request we don't autoplan")
+	e, v, _, p, cr, _, _, _ := setup(t)
+	store := autoplanmocks.NewMockStore()
+	e.AutoplanStore = store
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+	req.Header.Set(githubHeader, "pull_request")
+	When(v.Validate(req, secret)).ThenReturn([]byte(`{"action": "synchronize"}`), nil)
+	repo := models.Repo{FullName: "owner/repo"}
+	pull := models.PullRequest{State: models.OpenPullState, Num: 1}
+	When(p.ParseGithubPullEvent(matchers.AnyPtrToGithubPullRequestEvent())).ThenReturn(pull, models.UpdatedPullEvent, repo, repo, models.User{}, nil)
+	When(store.AutoplanDisabled("owner/repo", 1)).ThenReturn(true, nil)
+	w := httptest.NewRecorder()
+	e.Post(w, req)
+	responseContains(t, w, http.StatusOK, "Ignoring autoplan since it's been turned off for this pull request")
+	cr.VerifyWasCalled(Never()).RunAutoplanCommand(matchers.AnyModelsRepo(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyModelsUser())
+
+	t.Log("once it's turned back on we autoplan again")
+	When(store.AutoplanDisabled("owner/repo", 1)).ThenReturn(false, nil)
+	w = httptest.NewRecorder()
+	e.Post(w, req)
+	responseContains(t, w, http.StatusOK, "Processing...")
+	cr.VerifyWasCalledOnce().RunAutoplanCommand(repo, repo, pull, models.User{})
+}
+
+func TestPost_PullUpdatedWithoutDisableAutoplanLabel(t *testing.T) {
+	t.Log("when the pull request doesn't have a disable autoplan label we autoplan")
+	e, v, _, p, cr, _, vcsClient, _ := setup(t)
+	e.DisableAutoplanLabels = []string{"wip"}
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+	req.Header.Set(githubHeader, "pull_request")
+	When(v.Validate(req, secret)).ThenReturn([]byte(`{"action": "synchronize"}`), nil)
+	repo := models.Repo{}
+	pull := models.PullRequest{State: models.OpenPullState}
+	When(p.ParseGithubPullEvent(matchers.AnyPtrToGithubPullRequestEvent())).ThenReturn(pull, models.UpdatedPullEvent, repo, repo, models.User{}, nil)
+	When(vcsClient.GetPullLabels(repo, pull)).ThenReturn([]string{"bug"}, nil)
+	w := httptest.NewRecorder()
+	e.Post(w, req)
+	responseContains(t, w, http.StatusOK, "Processing...")
+	cr.VerifyWasCalledOnce().RunAutoplanCommand(repo, repo, pull, models.User{})
+}
+
+func TestPost_PullUpdatedDiscardsStalePlans(t *testing.T) {
+	t.Log("when new commits are pushed we discard the stale plans, even if we don't autoplan")
+	e, v, _, p, cr, _, vcsClient, _ := setup(t)
+	discarder := emocks.NewMockStalePlanDiscarder()
+	e.StalePlanDiscarder = discarder
+	e.DisableAutoplanLabels = []string{"wip"}
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+	req.Header.Set(githubHeader, "pull_request")
+	When(v.Validate(req, secret)).ThenReturn([]byte(`{"action": "synchronize"}`), nil)
+	repo := models.Repo{}
+	pull := models.PullRequest{State: models.OpenPullState, HeadCommit: "new"}
+	When(p.ParseGithubPullEvent(matchers.AnyPtrToGithubPullRequestEvent())).ThenReturn(pull, models.UpdatedPullEvent, repo, repo, models.User{}, nil)
+	When(vcsClient.GetPullLabels(repo, pull)).ThenReturn([]string{"wip"}, nil)
+	w := httptest.NewRecorder()
+	e.Post(w, req)
+	responseContains(t, w, http.StatusOK, "Ignoring autoplan since pull request has label \"wip\"")
+	discarder.VerifyWasCalledOnce().DiscardStalePlans(matchers.AnyPtrToLoggingSimpleLogger(), matchers.EqModelsRepo(repo), matchers.EqModelsPullRequest(pull))
+	cr.VerifyWasCalled(Never()).RunAutoplanCommand(repo, repo, pull, models.User{})
+}
+
+func TestPost_GithubCommentRateLimited(t *testing.T) {
+	t.Log("when the repo has started too many commands we reject the comment with a 429")
+	e, v, _, p, cr, _, _, cp := setup(t)
+	e.WebhookLimiter = &events.WebhookLimiter{RepoRatePerMinute: 1}
+	baseRepo := models.Repo{FullName: "owner/repo", VCSHost: models.VCSHost{Hostname: "github.com"}}
+	user := models.User{}
+	cmd := events.CommentCommand{}
+	When(p.ParseGithubIssueCommentEvent(matchers.AnyPtrToGithubIssueCommentEvent())).ThenReturn(baseRepo, user, 1, nil)
+	When(cp.Parse("", models.Github)).ThenReturn(events.CommentParseResult{Command: &cmd})
+
+	for i, exp := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+		req.Header.Set(githubHeader, "issue_comment")
+		When(v.Validate(req, secret)).ThenReturn([]byte(`{"action": "created"}`), nil)
+		w := httptest.NewRecorder()
+		e.Post(w, req)
+		Equals(t, exp, w.Result().StatusCode)
+		if i == 1 {
+			responseContains(t, w, http.StatusTooManyRequests, "Rejecting webhook since github.com/owner/repo has started more than 1 commands in the last minute")
+			Equals(t, "60", w.Header().Get("Retry-After"))
+		}
+	}
+	cr.VerifyWasCalledOnce().RunCommentCommand(baseRepo, nil, nil, user, 1, &cmd)
+}
+
+func TestPost_GithubCheckRunRerequested(t *testing.T) {
+	t.Log("when one of our check runs is re-run we plan its project again")
+	e, v, _, p, cr, _, _, _ := setup(t)
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+	req.Header.Set(githubHeader, "check_run")
+	When(v.Validate(req, secret)).ThenReturn([]byte(`{"action": "rerequested", "sender": {"login": "user"}, "check_run": {"name": "atlantis/plan: dir/staging", "external_id": "{\"command\":\"plan\",\"dir\":\"dir\",\"workspace\":\"staging\"}", "pull_requests": [{"number": 2}]}}`), nil)
+	repo := models.Repo{FullName: "owner/repo"}
+	When(p.ParseGithubRepo(matchers.AnyPtrToGithubRepository())).ThenReturn(repo, nil)
+	w := httptest.NewRecorder()
+	e.Post(w, req)
+	responseContains(t, w, http.StatusOK, "Processing...")
+	cr.VerifyWasCalledOnce().RunCommentCommand(repo, nil, nil, models.User{Username: "user"}, 2, events.NewCommentCommand("dir", nil, events.PlanCommand, false, "staging", ""))
+}
+
+func TestPost_GithubCheckRunIgnored(t *testing.T) {
+	cases := map[string]string{
+		"not rerequested": `{"action": "created", "check_run": {"external_id": "{}", "pull_requests": [{"number": 2}]}}`,
+		"no external ID":  `{"action": "rerequested", "check_run": {"pull_requests": [{"number": 2}]}}`,
+		"no pull request": `{"action": "rerequested", "check_run": {"external_id": "{}"}}`,
+	}
+	for name, payload := range cases {
+		t.Run(name, func(t *testing.T) {
+			e, v, _, _, cr, _, _, _ := setup(t)
+			req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+			req.Header.Set(githubHeader, "check_run")
+			When(v.Validate(req, secret)).ThenReturn([]byte(payload), nil)
+			w := httptest.NewRecorder()
+			e.Post(w, req)
+			responseContains(t, w, http.StatusOK, "Ignoring check run event")
+			cr.VerifyWasCalled(Never()).RunCommentCommand(matchers.AnyModelsRepo(), matchers.AnyPtrToModelsRepo(), matchers.AnyPtrToModelsPullRequest(), matchers.AnyModelsUser(), AnyInt(), matchers.AnyPtrToEventsCommentCommand())
+		})
+	}
+}
+
+func TestPost_PullOpenedQueueFull(t *testing.T) {
+	t.Log("when too many commands are waiting we reject autoplans with a 429")
+	e, v, _, p, cr, _, _, _ := setup(t)
+	e.WebhookLimiter = &events.WebhookLimiter{MaxQueueDepth: 2, QueueDepth: func() int { return 2 }}
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+	req.Header.Set(githubHeader, "pull_request")
+	When(v.Validate(req, secret)).ThenReturn([]byte(`{"action": "opened"}`), nil)
+	repo := models.Repo{}
+	pull := models.PullRequest{State: models.OpenPullState}
+	When(p.ParseGithubPullEvent(matchers.AnyPtrToGithubPullRequestEvent())).ThenReturn(pull, models.OpenedPullEvent, repo, repo, models.User{}, nil)
+	w := httptest.NewRecorder()
+	e.Post(w, req)
+	responseContains(t, w, http.StatusTooManyRequests, "Rejecting webhook since 2 commands are already running or queued, the most allowed")
+	cr.VerifyWasCalled(Never()).RunAutoplanCommand(matchers.AnyModelsRepo(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyModelsUser())
+}
+
+func TestPost_PullOpenedDraftWithSkipDraftPRs(t *testing.T) {
+	t.Log("when the pull request is a draft and we're skipping drafts we don't autoplan")
+	e, v, _, p, cr, _, vcsClient, _ := setup(t)
+	e.SkipDraftPRs = true
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+	req.Header.Set(githubHeader, "pull_request")
+	When(v.Validate(req, secret)).ThenReturn([]byte(`{"action": "opened"}`), nil)
+	repo := models.Repo{}
+	pull := models.PullRequest{State: models.OpenPullState}
+	When(p.ParseGithubPullEvent(matchers.AnyPtrToGithubPullRequestEvent())).ThenReturn(pull, models.OpenedPullEvent, repo, repo, models.User{}, nil)
+	When(vcsClient.PullIsDraft(repo, pull)).ThenReturn(true, nil)
+	w := httptest.NewRecorder()
+	e.Post(w, req)
+	responseContains(t, w, http.StatusOK, "Ignoring autoplan since pull request is a draft")
+	cr.VerifyWasCalled(Never()).RunAutoplanCommand(matchers.AnyModelsRepo(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyModelsUser())
+}
+
+func TestPost_PullUpdatedNotDraftWithSkipDraftPRs(t *testing.T) {
+	t.Log("when the pull request isn't a draft we autoplan even if we're skipping drafts")
+	e, v, _, p, cr, _, vcsClient, _ := setup(t)
+	e.SkipDraftPRs = true
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+	req.Header.Set(githubHeader, "pull_request")
+	When(v.Validate(req, secret)).ThenReturn([]byte(`{"action": "ready_for_review"}`), nil)
+	repo := models.Repo{}
+	pull := models.PullRequest{State: models.OpenPullState}
+	When(p.ParseGithubPullEvent(matchers.AnyPtrToGithubPullRequestEvent())).ThenReturn(pull, models.UpdatedPullEvent, repo, repo, models.User{}, nil)
+	When(vcsClient.PullIsDraft(repo, pull)).ThenReturn(false, nil)
+	w := httptest.NewRecorder()
+	e.Post(w, req)
+	responseContains(t, w, http.StatusOK, "Processing...")
+	cr.VerifyWasCalledOnce().RunAutoplanCommand(repo, repo, pull, models.User{})
+}
+
+func TestPost_PullOpenedDraftCheckDisabled(t *testing.T) {
+	t.Log("when we're not skipping drafts we don't check if the pull request is a draft")
+	e, v, _, p, cr, _, vcsClient, _ := setup(t)
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+	req.Header.Set(githubHeader, "pull_request")
+	When(v.Validate(req, secret)).ThenReturn([]byte(`{"action": "opened"}`), nil)
+	repo := models.Repo{}
+	pull := models.PullRequest{State: models.OpenPullState}
+	When(p.ParseGithubPullEvent(matchers.AnyPtrToGithubPullRequestEvent())).ThenReturn(pull, models.OpenedPullEvent, repo, repo, models.User{}, nil)
+	w := httptest.NewRecorder()
+	e.Post(w, req)
+	responseContains(t, w, http.StatusOK, "Processing...")
+	vcsClient.VerifyWasCalled(Never()).PullIsDraft(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest())
+	cr.VerifyWasCalledOnce().RunAutoplanCommand(repo, repo, pull, models.User{})
+}
+
+func TestPost_PullEventsAutoplannedOn(t *testing.T) {
+	repo := models.Repo{FullName: "owner/repo", VCSHost: models.VCSHost{Hostname: "github.com"}}
+	pull := models.PullRequest{State: models.OpenPullState}
+	cases := []struct {
+		autoplanOn  []string
+		eventType   models.PullRequestEventType
+		expAutoplan bool
+	}{
+		{nil, models.UpdatedPullEvent, true},
+		{nil, models.ReadyForReviewPullEvent, true},
+		{[]string{"opened", "ready_for_review"}, models.OpenedPullEvent, true},
+		{[]string{"opened", "ready_for_review"}, models.ReadyForReviewPullEvent, true},
+		{[]string{"opened", "ready_for_review"}, models.UpdatedPullEvent, false},
+		{[]string{}, models.OpenedPullEvent, false},
+	}
+	for _, c := range cases {
+		t.Run(fmt.Sprintf("%v %s", c.autoplanOn, c.eventType), func(t *testing.T) {
+			e, v, _, p, cr, _, _, _ := setup(t)
+			e.ServerConfig = valid.ServerConfig{Repos: []valid.Repo{{ID: "github.com/owner/repo", AutoplanOn: c.autoplanOn}}}
+			req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+			req.Header.Set(githubHeader, "pull_request")
+			When(v.Validate(req, secret)).ThenReturn([]byte(`{"action": "opened"}`), nil)
+			When(p.ParseGithubPullEvent(matchers.AnyPtrToGithubPullRequestEvent())).ThenReturn(pull, c.eventType, repo, repo, models.User{}, nil)
+			w := httptest.NewRecorder()
+			e.Post(w, req)
+			if c.expAutoplan {
+				responseContains(t, w, http.StatusOK, "Processing...")
+				cr.VerifyWasCalledOnce().RunAutoplanCommand(repo, repo, pull, models.User{})
+			} else {
+				responseContains(t, w, http.StatusOK, "Ignoring autoplan since the server-side repo config doesn't autoplan on")
+				cr.VerifyWasCalled(Never()).RunAutoplanCommand(matchers.AnyModelsRepo(), matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyModelsUser())
+			}
+		})
+	}
+}
+
 func setup(t *testing.T) (server.EventsController, *mocks.MockGithubRequestValidator, *mocks.MockGitlabRequestParserValidator, *emocks.MockEventParsing, *emocks.MockCommandRunner, *emocks.MockPullCleaner, *vcsmocks.MockClientProxy, *emocks.MockCommentParsing) {
 	RegisterMockTestingT(t)
 	v := mocks.NewMockGithubRequestValidator()
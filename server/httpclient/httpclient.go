@@ -0,0 +1,139 @@
+// Package httpclient builds the HTTP clients Atlantis makes outbound requests,
+// ex. to VCS hosts and to download terraform, with.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Config configures the TLS and proxy settings of a client. Its zero value is
+// the settings of http.DefaultClient: the system's CAs and the proxy in the
+// HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables.
+type Config struct {
+	// CAFiles are paths to PEM encoded bundles of CAs that are trusted as
+	// well as the system's. Empty paths are skipped.
+	CAFiles []string
+	// CertFile and KeyFile are the client certificate and its key presented
+	// to servers that require mutual TLS.
+	CertFile string
+	KeyFile  string
+	// ProxyURL, if set, is the proxy requests are sent through instead of
+	// the one in the environment. Hosts in NO_PROXY still bypass it.
+	ProxyURL string
+}
+
+// New returns a client with the settings in c. If c is the zero value, it
+// returns http.DefaultClient.
+func New(c Config) (*http.Client, error) {
+	var caFiles []string
+	for _, f := range c.CAFiles {
+		if f != "" {
+			caFiles = append(caFiles, f)
+		}
+	}
+	if len(caFiles) == 0 && c.CertFile == "" && c.KeyFile == "" && c.ProxyURL == "" {
+		return http.DefaultClient, nil
+	}
+	// Clone the default transport so we keep its proxy and timeout settings.
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "loading client certificate from %s and key from %s", c.CertFile, c.KeyFile)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if len(caFiles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for _, f := range caFiles {
+			pem, err := ioutil.ReadFile(f) // nolint: gosec
+			if err != nil {
+				return nil, errors.Wrapf(err, "reading CA bundle from %s", f)
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, errors.Errorf("no PEM encoded certificates found in CA bundle %s", f)
+			}
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport.TLSClientConfig = tlsConfig
+	if c.ProxyURL != "" {
+		proxyURL, err := url.Parse(c.ProxyURL)
+		if err != nil || proxyURL.Scheme == "" || proxyURL.Host == "" {
+			return nil, errors.Errorf("proxy URL %q must be an absolute URL, ex. http://proxy:3128", c.ProxyURL)
+		}
+		noProxy := getEnvAny("NO_PROXY", "no_proxy")
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			if bypassProxy(req.URL.Host, noProxy) {
+				return nil, nil
+			}
+			return proxyURL, nil
+		}
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// bypassProxy returns true if host, which can have a port, matches an entry
+// in noProxy, a comma separated list of hostnames, domains starting with a
+// ".", IPs and CIDRs, or "*" to match everything. This is how Go reads
+// NO_PROXY for the proxy in the environment.
+func bypassProxy(host string, noProxy string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if h, _, err := net.SplitHostPort(entry); err == nil {
+			entry = h
+		}
+		switch {
+		case entry == "":
+			continue
+		case entry == "*":
+			return true
+		case ip != nil:
+			if _, cidr, err := net.ParseCIDR(entry); err == nil && cidr.Contains(ip) {
+				return true
+			}
+			if ip.Equal(net.ParseIP(entry)) {
+				return true
+			}
+		default:
+			domain := strings.TrimPrefix(entry, "*")
+			if !strings.HasPrefix(domain, ".") {
+				if host == domain {
+					return true
+				}
+				domain = "." + domain
+			}
+			if strings.HasSuffix(host, domain) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func getEnvAny(names ...string) string {
+	for _, n := range names {
+		if val := os.Getenv(n); val != "" {
+			return val
+		}
+	}
+	return ""
+}
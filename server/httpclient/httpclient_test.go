@@ -0,0 +1,137 @@
+package httpclient_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/httpclient"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+// The client should present its certificate to servers that require one and
+// trust the servers signed by the CA bundle.
+func TestNew_MutualTLS(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	certFile, keyFile, clientCert := writeClientCert(t, tmp)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	testServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName)) // nolint: errcheck
+	}))
+	testServer.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	testServer.StartTLS()
+	defer testServer.Close()
+
+	caFile := filepath.Join(tmp, "ca.pem")
+	Ok(t, ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: testServer.Certificate().Raw}), 0600))
+
+	client, err := httpclient.New(httpclient.Config{CertFile: certFile, KeyFile: keyFile, CAFiles: []string{caFile}})
+	Ok(t, err)
+	resp, err := client.Get(testServer.URL)
+	Ok(t, err)
+	defer resp.Body.Close() // nolint: errcheck
+	body, err := ioutil.ReadAll(resp.Body)
+	Ok(t, err)
+	Equals(t, "atlantis", string(body))
+
+	// Without the client certificate the server rejects us.
+	client, err = httpclient.New(httpclient.Config{CAFiles: []string{caFile}})
+	Ok(t, err)
+	_, err = client.Get(testServer.URL)
+	Assert(t, err != nil, "exp error without a client certificate")
+}
+
+func TestNew_Default(t *testing.T) {
+	client, err := httpclient.New(httpclient.Config{CAFiles: []string{""}})
+	Ok(t, err)
+	Equals(t, http.DefaultClient, client)
+}
+
+func TestNew_Errors(t *testing.T) {
+	tmp, cleanup := TempDir(t)
+	defer cleanup()
+	notPEM := filepath.Join(tmp, "not-pem")
+	Ok(t, ioutil.WriteFile(notPEM, []byte("not pem"), 0600))
+
+	_, err := httpclient.New(httpclient.Config{CertFile: notPEM, KeyFile: notPEM})
+	ErrContains(t, "loading client certificate", err)
+	_, err = httpclient.New(httpclient.Config{CAFiles: []string{filepath.Join(tmp, "missing")}})
+	ErrContains(t, "reading CA bundle", err)
+	_, err = httpclient.New(httpclient.Config{CAFiles: []string{notPEM}})
+	ErrContains(t, "no PEM encoded certificates found in CA bundle", err)
+	_, err = httpclient.New(httpclient.Config{ProxyURL: "proxy:3128"})
+	ErrEquals(t, `proxy URL "proxy:3128" must be an absolute URL, ex. http://proxy:3128`, err)
+}
+
+func TestNew_ProxyURL(t *testing.T) {
+	orig := os.Getenv("NO_PROXY")
+	defer os.Setenv("NO_PROXY", orig) // nolint: errcheck
+	Ok(t, os.Setenv("NO_PROXY", "internal.example.com,.corp.example.com,10.0.0.0/8"))
+
+	client, err := httpclient.New(httpclient.Config{ProxyURL: "http://proxy:3128"})
+	Ok(t, err)
+	proxy := client.Transport.(*http.Transport).Proxy
+	cases := map[string]string{
+		"https://github.com/owner/repo":   "http://proxy:3128",
+		"https://internal.example.com":    "",
+		"https://a.internal.example.com":  "",
+		"https://git.corp.example.com:22": "",
+		"https://corp.example.com":        "http://proxy:3128",
+		"https://10.1.2.3":                "",
+		"https://11.1.2.3":                "http://proxy:3128",
+	}
+	for reqURL, exp := range cases {
+		t.Run(reqURL, func(t *testing.T) {
+			req, err := http.NewRequest("GET", reqURL, nil)
+			Ok(t, err)
+			proxyURL, err := proxy(req)
+			Ok(t, err)
+			if exp == "" {
+				Assert(t, proxyURL == nil, "exp no proxy but got %s", proxyURL)
+				return
+			}
+			Equals(t, exp, proxyURL.String())
+		})
+	}
+}
+
+// writeClientCert writes a self-signed client certificate and its key to dir.
+func writeClientCert(t *testing.T, dir string) (string, string, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Ok(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "atlantis"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Ok(t, err)
+	cert, err := x509.ParseCertificate(der)
+	Ok(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	Ok(t, err)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	Ok(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	Ok(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile, cert
+}
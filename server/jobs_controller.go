@@ -0,0 +1,299 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/runatlantis/atlantis/server/events/jobs"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+	"golang.org/x/net/websocket"
+)
+
+// JobsController handles all requests relating to the jobs UI.
+type JobsController struct {
+	AtlantisVersion   string
+	AtlantisURL       *url.URL
+	Jobs              *jobs.Tracker
+	Logger            *logging.SimpleLogger
+	JobsTemplate      TemplateWriter
+	JobDetailTemplate TemplateWriter
+}
+
+// jobOutputResponse is the response of the GET /jobs/{id}/output route.
+type jobOutputResponse struct {
+	// Output is the job's output after the requested offset.
+	Output string `json:"output"`
+	// Offset is the offset to request the output after next time.
+	Offset   int    `json:"offset"`
+	Status   string `json:"status"`
+	Running  bool   `json:"running"`
+	Duration string `json:"duration"`
+}
+
+// jobOutputMessage is a message sent over the GET /jobs/{id}/ws websocket.
+type jobOutputMessage struct {
+	// Output is the output written since the last message.
+	Output string `json:"output"`
+	// Offset is the offset of the end of Output in the job's output.
+	Offset int `json:"offset"`
+}
+
+// ListJobs is the GET /jobs route. It renders the jobs grouped by pull
+// request.
+func (j *JobsController) ListJobs(w http.ResponseWriter, _ *http.Request) {
+	list, err := j.Jobs.List()
+	if err != nil {
+		j.respond(w, logging.Error, http.StatusInternalServerError, "Failed listing jobs: %s", err)
+		return
+	}
+
+	// Jobs are listed newest first so pulls are added in the order of their
+	// newest job.
+	var pulls []JobsPullData
+	pullIdx := make(map[string]int)
+	for _, job := range list {
+		key := fmt.Sprintf("%s#%d", job.RepoFullName, job.PullNum)
+		i, ok := pullIdx[key]
+		if !ok {
+			i = len(pulls)
+			pullIdx[key] = i
+			pulls = append(pulls, JobsPullData{
+				RepoFullName: job.RepoFullName,
+				PullNum:      job.PullNum,
+				PullURL:      job.PullURL,
+			})
+		}
+		pulls[i].Jobs = append(pulls[i].Jobs, JobIndexData{
+			JobPath:     fmt.Sprintf("/jobs/%s", job.ID),
+			Command:     job.Command,
+			RepoRelDir:  job.RepoRelDir,
+			Workspace:   job.Workspace,
+			ProjectName: job.ProjectName,
+			User:        job.User,
+			Status:      job.Status.String(),
+			StartedAt:   job.StartedAt,
+			Duration:    formatDuration(job),
+		})
+	}
+	err = j.JobsTemplate.Execute(w, JobsIndexData{
+		Pulls:           pulls,
+		AtlantisVersion: j.AtlantisVersion,
+		CleanedBasePath: j.AtlantisURL.Path,
+	})
+	if err != nil {
+		j.Logger.Err("%s", err)
+	}
+}
+
+// GetJob is the GET /jobs/{id} route. It renders the job detail view which
+// polls for the job's output while it's running.
+func (j *JobsController) GetJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := j.getJob(w, r)
+	if !ok {
+		return
+	}
+	err := j.JobDetailTemplate.Execute(w, JobDetailData{
+		ID:              job.ID,
+		Command:         job.Command,
+		RepoFullName:    job.RepoFullName,
+		PullNum:         job.PullNum,
+		PullURL:         job.PullURL,
+		RepoRelDir:      job.RepoRelDir,
+		Workspace:       job.Workspace,
+		ProjectName:     job.ProjectName,
+		User:            job.User,
+		Status:          job.Status.String(),
+		Running:         job.Status == models.RunningJobStatus,
+		StartedAt:       job.StartedAt,
+		Duration:        formatDuration(*job),
+		Output:          job.Output,
+		OutputOffset:    len(job.Output),
+		AtlantisVersion: j.AtlantisVersion,
+		CleanedBasePath: j.AtlantisURL.Path,
+	})
+	if err != nil {
+		j.Logger.Err("%s", err)
+	}
+}
+
+// GetJobOutput is the GET /jobs/{id}/output route. It returns the job's status
+// and its output after the offset query param as JSON.
+func (j *JobsController) GetJobOutput(w http.ResponseWriter, r *http.Request) {
+	offset, ok := j.parseOffset(w, r)
+	if !ok {
+		return
+	}
+	job, ok := j.getJob(w, r)
+	if !ok {
+		return
+	}
+	data, err := json.Marshal(jobOutputResponse{
+		Output:   outputAfter(job.Output, offset),
+		Offset:   len(job.Output),
+		Status:   job.Status.String(),
+		Running:  job.Status == models.RunningJobStatus,
+		Duration: formatDuration(*job),
+	})
+	if err != nil {
+		j.respond(w, logging.Error, http.StatusInternalServerError, "Failed serializing job output: %s", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data) // nolint: errcheck
+}
+
+// JobOutputWebsocket is the GET /jobs/{id}/ws route. It upgrades the
+// connection to a websocket and streams the job's output after the offset
+// query param as it's written. Each message is a JSON jobOutputMessage. The
+// socket is closed once the job finishes, or straight away if it isn't running
+// in this process, after which the client can use the GET /jobs/{id}/output
+// route for its status and any output it missed.
+func (j *JobsController) JobOutputWebsocket(w http.ResponseWriter, r *http.Request) {
+	offset, ok := j.parseOffset(w, r)
+	if !ok {
+		return
+	}
+	id, ok := mux.Vars(r)["id"]
+	if !ok || id == "" {
+		j.respond(w, logging.Warn, http.StatusBadRequest, "No job id in request")
+		return
+	}
+	job, output, unsubscribe, err := j.Jobs.Subscribe(id)
+	defer unsubscribe()
+	if err != nil {
+		j.respond(w, logging.Error, http.StatusInternalServerError, "Failed getting job: %s", err)
+		return
+	}
+	if job == nil {
+		j.respond(w, logging.Info, http.StatusNotFound, "No job found with id %q", id)
+		return
+	}
+
+	websocket.Server{
+		Handshake: j.checkOrigin,
+		Handler: func(ws *websocket.Conn) {
+			j.streamOutput(ws, job.Output, offset, output)
+		},
+	}.ServeHTTP(w, r)
+}
+
+// streamOutput sends the output written so far after offset to ws and then
+// each chunk from output until it's closed or ws is.
+func (j *JobsController) streamOutput(ws *websocket.Conn, written string, offset int, output <-chan string) {
+	defer ws.Close() // nolint: errcheck
+	if err := j.send(ws, outputAfter(written, offset), len(written)); err != nil || output == nil {
+		return
+	}
+
+	// We don't expect any messages from the client but we need to read to
+	// find out if it's gone away.
+	disconnected := make(chan struct{})
+	go func() {
+		io.Copy(ioutil.Discard, ws) // nolint: errcheck
+		close(disconnected)
+	}()
+	offset = len(written)
+	for {
+		select {
+		case chunk, ok := <-output:
+			if !ok {
+				return
+			}
+			offset += len(chunk)
+			if err := j.send(ws, chunk, offset); err != nil {
+				return
+			}
+		case <-disconnected:
+			return
+		}
+	}
+}
+
+// send sends output to ws. offset is the offset of the end of output.
+func (j *JobsController) send(ws *websocket.Conn, output string, offset int) error {
+	err := websocket.JSON.Send(ws, jobOutputMessage{Output: output, Offset: offset})
+	if err != nil {
+		j.Logger.Debug("unable to send job output: %s", err)
+	}
+	return err
+}
+
+// checkOrigin makes sure websocket connections come from the Atlantis UI
+// rather than another site the user has open, since browsers don't apply
+// the same-origin policy to websockets.
+func (j *JobsController) checkOrigin(config *websocket.Config, r *http.Request) error {
+	origin, err := websocket.Origin(config, r)
+	if err != nil {
+		return err
+	}
+	if origin == nil || (origin.Host != r.Host && (j.AtlantisURL == nil || origin.Host != j.AtlantisURL.Host)) {
+		return fmt.Errorf("origin %v not allowed", origin)
+	}
+	return nil
+}
+
+// parseOffset returns the offset query param of r or 0 if it isn't set. If
+// it's invalid, it responds with an error and returns false.
+func (j *JobsController) parseOffset(w http.ResponseWriter, r *http.Request) (int, bool) {
+	o := r.URL.Query().Get("offset")
+	if o == "" {
+		return 0, true
+	}
+	offset, err := strconv.Atoi(o)
+	if err != nil || offset < 0 {
+		j.respond(w, logging.Warn, http.StatusBadRequest, "Invalid offset %q", o)
+		return 0, false
+	}
+	return offset, true
+}
+
+// outputAfter returns output after offset. The output only grows so an offset
+// past its end means there's nothing new.
+func outputAfter(output string, offset int) string {
+	if offset > len(output) {
+		return ""
+	}
+	return output[offset:]
+}
+
+// getJob returns the job with the id in r's route. If there isn't one, it
+// responds with an error and returns false.
+func (j *JobsController) getJob(w http.ResponseWriter, r *http.Request) (*models.Job, bool) {
+	id, ok := mux.Vars(r)["id"]
+	if !ok || id == "" {
+		j.respond(w, logging.Warn, http.StatusBadRequest, "No job id in request")
+		return nil, false
+	}
+	job, err := j.Jobs.Get(id)
+	if err != nil {
+		j.respond(w, logging.Error, http.StatusInternalServerError, "Failed getting job: %s", err)
+		return nil, false
+	}
+	if job == nil {
+		j.respond(w, logging.Info, http.StatusNotFound, "No job found with id %q", id)
+		return nil, false
+	}
+	return job, true
+}
+
+// respond is a helper function to respond and log the response. lvl is the log
+// level to log at, code is the HTTP response code.
+func (j *JobsController) respond(w http.ResponseWriter, lvl logging.LogLevel, responseCode int, format string, args ...interface{}) {
+	response := fmt.Sprintf(format, args...)
+	j.Logger.Log(lvl, "%s", response)
+	w.WriteHeader(responseCode)
+	fmt.Fprintln(w, response)
+}
+
+// formatDuration returns how long job ran for, to the second.
+func formatDuration(job models.Job) string {
+	return job.Duration().Round(time.Second).String()
+}
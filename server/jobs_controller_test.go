@@ -0,0 +1,317 @@
+package server_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server"
+	"github.com/runatlantis/atlantis/server/events/jobs"
+	"github.com/runatlantis/atlantis/server/events/jobs/mocks"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+	sMocks "github.com/runatlantis/atlantis/server/mocks"
+	. "github.com/runatlantis/atlantis/testing"
+	"golang.org/x/net/websocket"
+)
+
+func TestListJobs(t *testing.T) {
+	t.Log("Jobs should be grouped by pull request, ordered by their newest job")
+	RegisterMockTestingT(t)
+	store := mocks.NewMockStore()
+	started := time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)
+	When(store.ListJobs()).ThenReturn([]models.Job{
+		{ID: "3", Command: "apply", RepoFullName: "owner/repo", PullNum: 1, PullURL: "url1", RepoRelDir: ".", Workspace: "default", User: "lkysow", Status: models.SucceededJobStatus, StartedAt: started, FinishedAt: started.Add(90 * time.Second)},
+		{ID: "2", Command: "plan", RepoFullName: "owner/repo", PullNum: 2, PullURL: "url2", RepoRelDir: "dir", Workspace: "staging", ProjectName: "proj", User: "lkysow", Status: models.FailedJobStatus, StartedAt: started, FinishedAt: started.Add(time.Second)},
+		{ID: "1", Command: "plan", RepoFullName: "owner/repo", PullNum: 1, PullURL: "url1", RepoRelDir: ".", Workspace: "default", User: "lkysow", Status: models.SucceededJobStatus, StartedAt: started, FinishedAt: started.Add(2 * time.Second)},
+	}, nil)
+	tmpl := sMocks.NewMockTemplateWriter()
+	atlantisURL, err := url.Parse("https://example.com/basepath")
+	Ok(t, err)
+	jc := server.JobsController{
+		AtlantisVersion: "1300135",
+		AtlantisURL:     atlantisURL,
+		Jobs:            jobs.NewTracker(store, logging.NewNoopLogger()),
+		Logger:          logging.NewNoopLogger(),
+		JobsTemplate:    tmpl,
+	}
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+	w := httptest.NewRecorder()
+	jc.ListJobs(w, req)
+	tmpl.VerifyWasCalledOnce().Execute(w, server.JobsIndexData{
+		Pulls: []server.JobsPullData{
+			{
+				RepoFullName: "owner/repo",
+				PullNum:      1,
+				PullURL:      "url1",
+				Jobs: []server.JobIndexData{
+					{JobPath: "/jobs/3", Command: "apply", RepoRelDir: ".", Workspace: "default", User: "lkysow", Status: "succeeded", StartedAt: started, Duration: "1m30s"},
+					{JobPath: "/jobs/1", Command: "plan", RepoRelDir: ".", Workspace: "default", User: "lkysow", Status: "succeeded", StartedAt: started, Duration: "2s"},
+				},
+			},
+			{
+				RepoFullName: "owner/repo",
+				PullNum:      2,
+				PullURL:      "url2",
+				Jobs: []server.JobIndexData{
+					{JobPath: "/jobs/2", Command: "plan", RepoRelDir: "dir", Workspace: "staging", ProjectName: "proj", User: "lkysow", Status: "failed", StartedAt: started, Duration: "1s"},
+				},
+			},
+		},
+		AtlantisVersion: "1300135",
+		CleanedBasePath: "/basepath",
+	})
+	responseContains(t, w, http.StatusOK, "")
+}
+
+func TestListJobs_StoreErr(t *testing.T) {
+	t.Log("If there is an error listing jobs, a 500 is returned")
+	RegisterMockTestingT(t)
+	store := mocks.NewMockStore()
+	When(store.ListJobs()).ThenReturn(nil, errors.New("err"))
+	jc := server.JobsController{
+		Jobs:   jobs.NewTracker(store, logging.NewNoopLogger()),
+		Logger: logging.NewNoopLogger(),
+	}
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+	w := httptest.NewRecorder()
+	jc.ListJobs(w, req)
+	responseContains(t, w, http.StatusInternalServerError, "Failed listing jobs: err")
+}
+
+func TestGetJob_None(t *testing.T) {
+	t.Log("If there is no job with that ID we get a 404")
+	RegisterMockTestingT(t)
+	store := mocks.NewMockStore()
+	When(store.GetJob("id")).ThenReturn(nil, nil)
+	jc := server.JobsController{
+		Jobs:   jobs.NewTracker(store, logging.NewNoopLogger()),
+		Logger: logging.NewNoopLogger(),
+	}
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+	req = mux.SetURLVars(req, map[string]string{"id": "id"})
+	w := httptest.NewRecorder()
+	jc.GetJob(w, req)
+	responseContains(t, w, http.StatusNotFound, "No job found with id \"id\"")
+}
+
+func TestGetJob_Success(t *testing.T) {
+	t.Log("Should be able to render a finished job")
+	RegisterMockTestingT(t)
+	store := mocks.NewMockStore()
+	started := time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)
+	When(store.GetJob("id")).ThenReturn(&models.Job{
+		ID:           "id",
+		Command:      "plan",
+		RepoFullName: "owner/repo",
+		PullNum:      1,
+		PullURL:      "url",
+		RepoRelDir:   ".",
+		Workspace:    "default",
+		User:         "lkysow",
+		Status:       models.SucceededJobStatus,
+		StartedAt:    started,
+		FinishedAt:   started.Add(5 * time.Second),
+		Output:       "output\n",
+	}, nil)
+	tmpl := sMocks.NewMockTemplateWriter()
+	atlantisURL, err := url.Parse("https://example.com/basepath")
+	Ok(t, err)
+	jc := server.JobsController{
+		AtlantisVersion:   "1300135",
+		AtlantisURL:       atlantisURL,
+		Jobs:              jobs.NewTracker(store, logging.NewNoopLogger()),
+		Logger:            logging.NewNoopLogger(),
+		JobDetailTemplate: tmpl,
+	}
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+	req = mux.SetURLVars(req, map[string]string{"id": "id"})
+	w := httptest.NewRecorder()
+	jc.GetJob(w, req)
+	tmpl.VerifyWasCalledOnce().Execute(w, server.JobDetailData{
+		ID:              "id",
+		Command:         "plan",
+		RepoFullName:    "owner/repo",
+		PullNum:         1,
+		PullURL:         "url",
+		RepoRelDir:      ".",
+		Workspace:       "default",
+		User:            "lkysow",
+		Status:          "succeeded",
+		Running:         false,
+		StartedAt:       started,
+		Duration:        "5s",
+		Output:          "output\n",
+		OutputOffset:    7,
+		AtlantisVersion: "1300135",
+		CleanedBasePath: "/basepath",
+	})
+	responseContains(t, w, http.StatusOK, "")
+}
+
+func TestGetJobOutput(t *testing.T) {
+	t.Log("Should return the output of a running job after the offset")
+	RegisterMockTestingT(t)
+	tracker := jobs.NewTracker(mocks.NewMockStore(), logging.NewNoopLogger())
+	id := tracker.Start(models.Job{Command: "plan"})
+	tracker.AppendOutput(id, "line1")
+	tracker.AppendOutput(id, "line2")
+	jc := server.JobsController{
+		Jobs:   tracker,
+		Logger: logging.NewNoopLogger(),
+	}
+
+	cases := []struct {
+		offset    string
+		expOutput string
+	}{
+		{"", "line1\nline2\n"},
+		{"6", "line2\n"},
+		{"12", ""},
+		{"100", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.offset, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/jobs/"+id+"/output?offset="+c.offset, bytes.NewBuffer(nil))
+			req = mux.SetURLVars(req, map[string]string{"id": id})
+			w := httptest.NewRecorder()
+			jc.GetJobOutput(w, req)
+			Equals(t, http.StatusOK, w.Result().StatusCode)
+			var resp struct {
+				Output  string `json:"output"`
+				Offset  int    `json:"offset"`
+				Status  string `json:"status"`
+				Running bool   `json:"running"`
+			}
+			Ok(t, json.NewDecoder(w.Result().Body).Decode(&resp))
+			Equals(t, c.expOutput, resp.Output)
+			Equals(t, 12, resp.Offset)
+			Equals(t, "running", resp.Status)
+			Equals(t, true, resp.Running)
+		})
+	}
+}
+
+func TestJobOutputWebsocket(t *testing.T) {
+	t.Log("Should stream the output after the offset until the job finishes")
+	RegisterMockTestingT(t)
+	tracker := jobs.NewTracker(mocks.NewMockStore(), logging.NewNoopLogger())
+	id := tracker.Start(models.Job{})
+	tracker.AppendOutput(id, "line1")
+	tracker.AppendOutput(id, "line2")
+	s := newJobsServer(t, tracker)
+	defer s.Close()
+
+	ws := dialJobWebsocket(t, s.URL, id, "6")
+	defer ws.Close() // nolint: errcheck
+	var msg struct {
+		Output string `json:"output"`
+		Offset int    `json:"offset"`
+	}
+	Ok(t, websocket.JSON.Receive(ws, &msg))
+	Equals(t, "line2\n", msg.Output)
+	Equals(t, 12, msg.Offset)
+
+	tracker.AppendOutput(id, "line3")
+	Ok(t, websocket.JSON.Receive(ws, &msg))
+	Equals(t, "line3\n", msg.Output)
+	Equals(t, 18, msg.Offset)
+
+	tracker.Finish(id, true)
+	err := websocket.JSON.Receive(ws, &msg)
+	Equals(t, io.EOF, err)
+}
+
+func TestJobOutputWebsocket_Finished(t *testing.T) {
+	t.Log("If the job isn't running, its output should be sent and the socket closed")
+	RegisterMockTestingT(t)
+	store := mocks.NewMockStore()
+	When(store.GetJob("id")).ThenReturn(&models.Job{ID: "id", Status: models.SucceededJobStatus, Output: "output\n"}, nil)
+	s := newJobsServer(t, jobs.NewTracker(store, logging.NewNoopLogger()))
+	defer s.Close()
+
+	ws := dialJobWebsocket(t, s.URL, "id", "")
+	defer ws.Close() // nolint: errcheck
+	var msg struct {
+		Output string `json:"output"`
+		Offset int    `json:"offset"`
+	}
+	Ok(t, websocket.JSON.Receive(ws, &msg))
+	Equals(t, "output\n", msg.Output)
+	Equals(t, 7, msg.Offset)
+	Equals(t, io.EOF, websocket.JSON.Receive(ws, &msg))
+}
+
+func TestJobOutputWebsocket_OtherOrigin(t *testing.T) {
+	t.Log("Websocket connections from other sites should be rejected")
+	RegisterMockTestingT(t)
+	tracker := jobs.NewTracker(mocks.NewMockStore(), logging.NewNoopLogger())
+	id := tracker.Start(models.Job{})
+	s := newJobsServer(t, tracker)
+	defer s.Close()
+
+	_, err := websocket.Dial(strings.Replace(s.URL, "http", "ws", 1)+"/jobs/"+id+"/ws", "", "https://evil.com")
+	Assert(t, err != nil, "exp error")
+}
+
+func TestJobOutputWebsocket_None(t *testing.T) {
+	t.Log("If there is no job with that ID we get a 404 rather than a websocket")
+	RegisterMockTestingT(t)
+	store := mocks.NewMockStore()
+	When(store.GetJob("id")).ThenReturn(nil, nil)
+	jc := server.JobsController{
+		Jobs:   jobs.NewTracker(store, logging.NewNoopLogger()),
+		Logger: logging.NewNoopLogger(),
+	}
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+	req = mux.SetURLVars(req, map[string]string{"id": "id"})
+	w := httptest.NewRecorder()
+	jc.JobOutputWebsocket(w, req)
+	responseContains(t, w, http.StatusNotFound, "No job found with id \"id\"")
+}
+
+// newJobsServer returns a test server serving the websocket route for jobs
+// tracked by tracker.
+func newJobsServer(t *testing.T, tracker *jobs.Tracker) *httptest.Server {
+	jc := &server.JobsController{
+		Jobs:   tracker,
+		Logger: logging.NewNoopLogger(),
+	}
+	router := mux.NewRouter()
+	router.HandleFunc("/jobs/{id}/ws", jc.JobOutputWebsocket)
+	return httptest.NewServer(router)
+}
+
+// dialJobWebsocket connects to the websocket of the job with id on the server
+// at serverURL, as the Atlantis UI would.
+func dialJobWebsocket(t *testing.T, serverURL string, id string, offset string) *websocket.Conn {
+	t.Helper()
+	ws, err := websocket.Dial(strings.Replace(serverURL, "http", "ws", 1)+"/jobs/"+id+"/ws?offset="+offset, "", serverURL)
+	Ok(t, err)
+	return ws
+}
+
+func TestGetJobOutput_InvalidOffset(t *testing.T) {
+	t.Log("If the offset is invalid then we should get a 400")
+	RegisterMockTestingT(t)
+	tracker := jobs.NewTracker(mocks.NewMockStore(), logging.NewNoopLogger())
+	id := tracker.Start(models.Job{})
+	jc := server.JobsController{
+		Jobs:   tracker,
+		Logger: logging.NewNoopLogger(),
+	}
+	req, _ := http.NewRequest("GET", "/jobs/"+id+"/output?offset=-1", bytes.NewBuffer(nil))
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+	w := httptest.NewRecorder()
+	jc.GetJobOutput(w, req)
+	responseContains(t, w, http.StatusBadRequest, "Invalid offset \"-1\"")
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/runatlantis/atlantis/server/events"
@@ -11,6 +12,7 @@ import (
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/events/vcs"
 	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/oidc"
 )
 
 // LocksController handles all requests relating to Atlantis locks.
@@ -23,6 +25,11 @@ type LocksController struct {
 	LockDetailTemplate TemplateWriter
 	WorkingDir         events.WorkingDir
 	WorkingDirLocker   events.WorkingDirLocker
+	LockQueueNotifier  events.LockQueueNotifier
+	ApplyLocker        locking.ApplyLocker
+	// AuditRecorder records the locks deleted in the UI to the audit log. If
+	// nil, they aren't recorded.
+	AuditRecorder events.AuditRecorder
 }
 
 // GetLock is the GET /locks/{id} route. It renders the lock detail view.
@@ -80,7 +87,22 @@ func (l *LocksController) DeleteLock(w http.ResponseWriter, r *http.Request) {
 		l.respond(w, logging.Warn, http.StatusBadRequest, "Invalid lock id %q. Failed with error: %s", id, err)
 		return
 	}
+	startedAt := time.Now()
 	lock, err := l.Locker.Unlock(idUnencoded)
+	if err == nil && lock != nil && l.AuditRecorder != nil {
+		l.AuditRecorder.Record(models.AuditEvent{
+			Command:      events.UnlockCommand.String(),
+			User:         uiUsername(r),
+			RepoFullName: lock.Project.RepoFullName,
+			PullNum:      lock.Pull.Num,
+			HeadCommit:   lock.Pull.HeadCommit,
+			RepoRelDir:   lock.Project.Path,
+			Workspace:    lock.Workspace,
+			Success:      true,
+			StartedAt:    startedAt,
+			DurationMS:   int64(time.Since(startedAt) / time.Millisecond),
+		})
+	}
 	if err != nil {
 		l.respond(w, logging.Error, http.StatusInternalServerError, "deleting lock failed with: %s", err)
 		return
@@ -89,6 +111,9 @@ func (l *LocksController) DeleteLock(w http.ResponseWriter, r *http.Request) {
 		l.respond(w, logging.Info, http.StatusNotFound, "No lock found at id %q", idUnencoded)
 		return
 	}
+	if err := l.LockQueueNotifier.Notify(idUnencoded); err != nil {
+		l.Logger.Err("unable to notify pulls waiting for lock: %s", err)
+	}
 
 	// NOTE: Because BaseRepo was added to the PullRequest model later, previous
 	// installations of Atlantis will have locks in their DB that do not have
@@ -119,6 +144,57 @@ func (l *LocksController) DeleteLock(w http.ResponseWriter, r *http.Request) {
 	l.respond(w, logging.Info, http.StatusOK, "Deleted lock id %q", id)
 }
 
+// uiUsername returns who made r from the UI: the user logged in with OIDC, or
+// the basic auth username. It's empty if the UI doesn't require logging in.
+func uiUsername(r *http.Request) string {
+	if u, ok := oidc.UserFromContext(r.Context()); ok {
+		return u.Name
+	}
+	username, _, _ := r.BasicAuth()
+	return username
+}
+
+// ApplyLockRequestHeader must be set to XMLHttpRequest on requests to the
+// /applies/lock routes. A page on another site can't set it without a CORS
+// preflight so it can't toggle the lock with the admin's cookies.
+const ApplyLockRequestHeader = "X-Requested-With"
+
+// LockApplies is the POST /applies/lock route. It stops all applies until
+// they're unlocked.
+func (l *LocksController) LockApplies(w http.ResponseWriter, r *http.Request) {
+	if !l.isXHR(w, r) {
+		return
+	}
+	lock, err := l.ApplyLocker.LockApplies()
+	if err != nil {
+		l.respond(w, logging.Error, http.StatusInternalServerError, "Failed locking applies: %s", err)
+		return
+	}
+	l.respond(w, logging.Info, http.StatusOK, "Locked applies at %s", lock.Time)
+}
+
+// UnlockApplies is the DELETE /applies/lock route. It lets applies run again.
+func (l *LocksController) UnlockApplies(w http.ResponseWriter, r *http.Request) {
+	if !l.isXHR(w, r) {
+		return
+	}
+	if err := l.ApplyLocker.UnlockApplies(); err != nil {
+		l.respond(w, logging.Error, http.StatusInternalServerError, "Failed unlocking applies: %s", err)
+		return
+	}
+	l.respond(w, logging.Info, http.StatusOK, "Unlocked applies")
+}
+
+// isXHR returns true if r has ApplyLockRequestHeader set. If not, it responds
+// with a 403.
+func (l *LocksController) isXHR(w http.ResponseWriter, r *http.Request) bool {
+	if r.Header.Get(ApplyLockRequestHeader) != "XMLHttpRequest" {
+		l.respond(w, logging.Warn, http.StatusForbidden, "Missing %s: XMLHttpRequest header", ApplyLockRequestHeader)
+		return false
+	}
+	return true
+}
+
 // respond is a helper function to respond and log the response. lvl is the log
 // level to log at, code is the HTTP response code.
 func (l *LocksController) respond(w http.ResponseWriter, lvl logging.LogLevel, responseCode int, format string, args ...interface{}) {
@@ -8,6 +8,7 @@ import (
 	"net/url"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 	. "github.com/petergtz/pegomock"
@@ -178,9 +179,10 @@ func TestDeleteLock_OldFormat(t *testing.T) {
 	l := mocks.NewMockLocker()
 	When(l.Unlock("id")).ThenReturn(&models.ProjectLock{}, nil)
 	lc := server.LocksController{
-		Locker:    l,
-		Logger:    logging.NewNoopLogger(),
-		VCSClient: cp,
+		Locker:            l,
+		Logger:            logging.NewNoopLogger(),
+		VCSClient:         cp,
+		LockQueueNotifier: mocks2.NewMockLockQueueNotifier(),
 	}
 	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
 	req = mux.SetURLVars(req, map[string]string{"id": "id"})
@@ -205,11 +207,12 @@ func TestDeleteLock_CommentFailed(t *testing.T) {
 		},
 	}, nil)
 	lc := server.LocksController{
-		Locker:           l,
-		Logger:           logging.NewNoopLogger(),
-		VCSClient:        cp,
-		WorkingDir:       workingDir,
-		WorkingDirLocker: workingDirLocker,
+		Locker:            l,
+		Logger:            logging.NewNoopLogger(),
+		VCSClient:         cp,
+		WorkingDir:        workingDir,
+		WorkingDirLocker:  workingDirLocker,
+		LockQueueNotifier: mocks2.NewMockLockQueueNotifier(),
 	}
 	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
 	req = mux.SetURLVars(req, map[string]string{"id": "id"})
@@ -224,6 +227,7 @@ func TestDeleteLock_CommentSuccess(t *testing.T) {
 
 	cp := vcsmocks.NewMockClientProxy()
 	l := mocks.NewMockLocker()
+	notifier := mocks2.NewMockLockQueueNotifier()
 	workingDir := mocks2.NewMockWorkingDir()
 	workingDirLocker := events.NewDefaultWorkingDirLocker()
 	pull := models.PullRequest{
@@ -238,11 +242,12 @@ func TestDeleteLock_CommentSuccess(t *testing.T) {
 		},
 	}, nil)
 	lc := server.LocksController{
-		Locker:           l,
-		Logger:           logging.NewNoopLogger(),
-		VCSClient:        cp,
-		WorkingDirLocker: workingDirLocker,
-		WorkingDir:       workingDir,
+		Locker:            l,
+		Logger:            logging.NewNoopLogger(),
+		VCSClient:         cp,
+		WorkingDirLocker:  workingDirLocker,
+		WorkingDir:        workingDir,
+		LockQueueNotifier: notifier,
 	}
 	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
 	req = mux.SetURLVars(req, map[string]string{"id": "id"})
@@ -253,4 +258,60 @@ func TestDeleteLock_CommentSuccess(t *testing.T) {
 		"**Warning**: The plan for dir: `path` workspace: `workspace` was **discarded** via the Atlantis UI.\n\n"+
 			"To `apply` this plan you must run `plan` again.")
 	workingDir.VerifyWasCalledOnce().DeleteForWorkspace(pull.BaseRepo, pull, "workspace")
+	notifier.VerifyWasCalledOnce().Notify("id")
+}
+
+func TestLockApplies(t *testing.T) {
+	t.Log("locking applies should lock them and say when")
+	RegisterMockTestingT(t)
+	l := mocks.NewMockApplyLocker()
+	lockTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	When(l.LockApplies()).ThenReturn(models.ApplyLock{Time: lockTime}, nil)
+	lc := server.LocksController{
+		Logger:      logging.NewNoopLogger(),
+		ApplyLocker: l,
+	}
+	req, _ := http.NewRequest("POST", "", bytes.NewBuffer(nil))
+	req.Header.Set(server.ApplyLockRequestHeader, "XMLHttpRequest")
+	w := httptest.NewRecorder()
+	lc.LockApplies(w, req)
+	responseContains(t, w, http.StatusOK, "Locked applies at 2020-01-02 03:04:05 +0000 UTC")
+}
+
+func TestLockApplies_CrossSite(t *testing.T) {
+	t.Log("requests without the XMLHttpRequest header, like a form posted from another site, should be rejected")
+	RegisterMockTestingT(t)
+	l := mocks.NewMockApplyLocker()
+	lc := server.LocksController{
+		Logger:      logging.NewNoopLogger(),
+		ApplyLocker: l,
+	}
+	for _, method := range []string{"POST", "DELETE"} {
+		req, _ := http.NewRequest(method, "", bytes.NewBuffer(nil))
+		w := httptest.NewRecorder()
+		if method == "POST" {
+			lc.LockApplies(w, req)
+		} else {
+			lc.UnlockApplies(w, req)
+		}
+		responseContains(t, w, http.StatusForbidden, "Missing X-Requested-With: XMLHttpRequest header")
+	}
+	l.VerifyWasCalled(Never()).LockApplies()
+	l.VerifyWasCalled(Never()).UnlockApplies()
+}
+
+func TestUnlockApplies_Err(t *testing.T) {
+	t.Log("if applies can't be unlocked, a 500 is returned")
+	RegisterMockTestingT(t)
+	l := mocks.NewMockApplyLocker()
+	When(l.UnlockApplies()).ThenReturn(errors.New("err"))
+	lc := server.LocksController{
+		Logger:      logging.NewNoopLogger(),
+		ApplyLocker: l,
+	}
+	req, _ := http.NewRequest("DELETE", "", bytes.NewBuffer(nil))
+	req.Header.Set(server.ApplyLockRequestHeader, "XMLHttpRequest")
+	w := httptest.NewRecorder()
+	lc.UnlockApplies(w, req)
+	responseContains(t, w, http.StatusInternalServerError, "Failed unlocking applies: err")
 }
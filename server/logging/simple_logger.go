@@ -21,6 +21,7 @@ import (
 	"log"
 	"os"
 	"runtime"
+	"sync"
 	"time"
 	"unicode"
 )
@@ -57,6 +58,9 @@ type SimpleLogger struct {
 	Logger      *log.Logger
 	KeepHistory bool
 	Level       LogLevel
+	// historyMu guards History since projects can be planned in parallel
+	// using the same logger.
+	historyMu sync.Mutex
 }
 
 type LogLevel int
@@ -178,6 +182,8 @@ func (l *SimpleLogger) GetLevel() LogLevel {
 }
 
 func (l *SimpleLogger) saveToHistory(level string, msg string) {
+	l.historyMu.Lock()
+	defer l.historyMu.Unlock()
 	l.History.WriteString(fmt.Sprintf("[%s] %s\n", level, msg))
 }
 
@@ -0,0 +1,146 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// counterVec is a counter with a single label that counts separately for
+// each value of the label.
+type counterVec struct {
+	name  string
+	help  string
+	label string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func newCounterVec(name string, help string, label string) *counterVec {
+	return &counterVec{
+		name:   name,
+		help:   help,
+		label:  label,
+		values: make(map[string]float64),
+	}
+}
+
+func (c *counterVec) inc(labelValue string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[labelValue]++
+}
+
+func (c *counterVec) write(buf *bytes.Buffer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	writeHeader(buf, c.name, c.help, "counter")
+	for _, v := range sortedKeys(c.values) {
+		fmt.Fprintf(buf, "%s{%s} %s\n", c.name, labelPair(c.label, v), formatFloat(c.values[v]))
+	}
+}
+
+// histogramVec is a histogram with a single label that counts observations
+// into buckets separately for each value of the label.
+type histogramVec struct {
+	name    string
+	help    string
+	label   string
+	buckets []float64
+
+	mu     sync.Mutex
+	values map[string]*histogram
+}
+
+type histogram struct {
+	// counts[i] is the number of observations <= buckets[i]. They're
+	// cumulative like the Prometheus format expects.
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func newHistogramVec(name string, help string, label string, buckets []float64) *histogramVec {
+	return &histogramVec{
+		name:    name,
+		help:    help,
+		label:   label,
+		buckets: buckets,
+		values:  make(map[string]*histogram),
+	}
+}
+
+func (h *histogramVec) observe(labelValue string, v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hist, ok := h.values[labelValue]
+	if !ok {
+		hist = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.values[labelValue] = hist
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			hist.counts[i]++
+		}
+	}
+	hist.count++
+	hist.sum += v
+}
+
+func (h *histogramVec) write(buf *bytes.Buffer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	writeHeader(buf, h.name, h.help, "histogram")
+	keys := make([]string, 0, len(h.values))
+	for k := range h.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, v := range keys {
+		hist := h.values[v]
+		label := labelPair(h.label, v)
+		for i, upper := range h.buckets {
+			fmt.Fprintf(buf, "%s_bucket{%s,le=%q} %d\n", h.name, label, formatFloat(upper), hist.counts[i])
+		}
+		fmt.Fprintf(buf, "%s_bucket{%s,le=\"+Inf\"} %d\n", h.name, label, hist.count)
+		fmt.Fprintf(buf, "%s_sum{%s} %s\n", h.name, label, formatFloat(hist.sum))
+		fmt.Fprintf(buf, "%s_count{%s} %d\n", h.name, label, hist.count)
+	}
+}
+
+func writeGauge(buf *bytes.Buffer, name string, help string, v float64) {
+	writeHeader(buf, name, help, "gauge")
+	fmt.Fprintf(buf, "%s %s\n", name, formatFloat(v))
+}
+
+func writeHeader(buf *bytes.Buffer, name string, help string, metricType string) {
+	help = strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+// labelPair formats the label for the exposition format, escaping the value.
+func labelPair(label string, value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+	return fmt.Sprintf(`%s="%s"`, label, value)
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,132 @@
+// Package metrics records metrics about what Atlantis is doing and serves
+// them in the Prometheus text format so they can be scraped by Prometheus.
+// See https://prometheus.io/docs/instrumenting/exposition_formats/.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/locking"
+)
+
+// ContentType is the content type of the Prometheus text format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DurationBuckets are the upper bounds in seconds of the buckets we count
+// command durations in. Terraform commands take anywhere from seconds to
+// tens of minutes.
+var DurationBuckets = []float64{1, 5, 10, 30, 60, 120, 300, 600, 1200, 1800, 3600}
+
+// Metrics records the metrics for a running Atlantis server. All its methods
+// are safe to call on a nil *Metrics, which records nothing, so callers don't
+// need to check if metrics are enabled.
+type Metrics struct {
+	// Locker is used to count the active locks and the pulls waiting for
+	// them when the metrics are scraped.
+	Locker locking.Backend
+
+	webhookEvents   *counterVec
+	webhookRejects  *counterVec
+	commands        *counterVec
+	commandFailures *counterVec
+	commandDuration *histogramVec
+}
+
+// New returns metrics that report on the locks in locker.
+func New(locker locking.Backend) *Metrics {
+	return &Metrics{
+		Locker: locker,
+		webhookEvents: newCounterVec(
+			"atlantis_webhook_events_total",
+			"Number of webhook events received from each VCS host.",
+			"vcs"),
+		webhookRejects: newCounterVec(
+			"atlantis_webhooks_rejected_total",
+			"Number of webhooks that would have started a command but were rejected, by reason.",
+			"reason"),
+		commands: newCounterVec(
+			"atlantis_project_commands_total",
+			"Number of project plans and applies that were run.",
+			"command"),
+		commandFailures: newCounterVec(
+			"atlantis_project_command_failures_total",
+			"Number of project plans and applies that failed.",
+			"command"),
+		commandDuration: newHistogramVec(
+			"atlantis_project_command_duration_seconds",
+			"How long project plans and applies took to run.",
+			"command",
+			DurationBuckets),
+	}
+}
+
+// WebhookReceived records that we received a webhook event from vcsHost,
+// ex. Github.
+func (m *Metrics) WebhookReceived(vcsHost string) {
+	if m == nil {
+		return
+	}
+	m.webhookEvents.inc(vcsHost)
+}
+
+// WebhookRejected records that we rejected a webhook for reason, ex.
+// rate_limited.
+func (m *Metrics) WebhookRejected(reason string) {
+	if m == nil {
+		return
+	}
+	m.webhookRejects.inc(reason)
+}
+
+// CommandRun records that a project command, ex. plan, ran for duration.
+// failed should be true if the command errored or failed.
+func (m *Metrics) CommandRun(command string, duration time.Duration, failed bool) {
+	if m == nil {
+		return
+	}
+	m.commands.inc(command)
+	if failed {
+		m.commandFailures.inc(command)
+	}
+	m.commandDuration.observe(command, duration.Seconds())
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	var buf bytes.Buffer
+	if err := m.write(&buf); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "Error collecting metrics: %s", err)
+		return
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.Write(buf.Bytes()) // nolint: errcheck
+}
+
+func (m *Metrics) write(buf *bytes.Buffer) error {
+	m.webhookEvents.write(buf)
+	m.webhookRejects.write(buf)
+	m.commands.write(buf)
+	m.commandFailures.write(buf)
+	m.commandDuration.write(buf)
+
+	locks, err := m.Locker.List()
+	if err != nil {
+		return errors.Wrap(err, "listing locks")
+	}
+	queued := 0
+	for _, l := range locks {
+		queue, err := m.Locker.GetQueue(l.Project, l.Workspace)
+		if err != nil {
+			return errors.Wrap(err, "getting lock queue")
+		}
+		queued += len(queue)
+	}
+	writeGauge(buf, "atlantis_locks_active", "Number of projects and workspaces that are locked.", float64(len(locks)))
+	writeGauge(buf, "atlantis_lock_queue_depth", "Number of pulls waiting for a lock held by another pull.", float64(queued))
+	return nil
+}
@@ -0,0 +1,133 @@
+package metrics_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events/locking/mocks"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/metrics"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+var project = models.NewProject("owner/repo", "path")
+
+func TestNilMetrics(t *testing.T) {
+	t.Log("recording on nil metrics should do nothing")
+	var m *metrics.Metrics
+	m.WebhookReceived("Github")
+	m.WebhookRejected("rate_limited")
+	m.CommandRun("plan", time.Second, false)
+}
+
+func TestServeHTTP_NoEvents(t *testing.T) {
+	RegisterMockTestingT(t)
+	backend := mocks.NewMockBackend()
+	When(backend.List()).ThenReturn(nil, nil)
+	m := metrics.New(backend)
+
+	code, contentType, body := scrape(m)
+	Equals(t, http.StatusOK, code)
+	Equals(t, metrics.ContentType, contentType)
+	Equals(t, `# HELP atlantis_webhook_events_total Number of webhook events received from each VCS host.
+# TYPE atlantis_webhook_events_total counter
+# HELP atlantis_webhooks_rejected_total Number of webhooks that would have started a command but were rejected, by reason.
+# TYPE atlantis_webhooks_rejected_total counter
+# HELP atlantis_project_commands_total Number of project plans and applies that were run.
+# TYPE atlantis_project_commands_total counter
+# HELP atlantis_project_command_failures_total Number of project plans and applies that failed.
+# TYPE atlantis_project_command_failures_total counter
+# HELP atlantis_project_command_duration_seconds How long project plans and applies took to run.
+# TYPE atlantis_project_command_duration_seconds histogram
+# HELP atlantis_locks_active Number of projects and workspaces that are locked.
+# TYPE atlantis_locks_active gauge
+atlantis_locks_active 0
+# HELP atlantis_lock_queue_depth Number of pulls waiting for a lock held by another pull.
+# TYPE atlantis_lock_queue_depth gauge
+atlantis_lock_queue_depth 0
+`, body)
+}
+
+func TestServeHTTP(t *testing.T) {
+	RegisterMockTestingT(t)
+	backend := mocks.NewMockBackend()
+	locks := []models.ProjectLock{
+		{Project: project, Workspace: "default"},
+		{Project: project, Workspace: "staging"},
+	}
+	When(backend.List()).ThenReturn(locks, nil)
+	When(backend.GetQueue(project, "default")).ThenReturn([]models.ProjectLock{{}, {}}, nil)
+	When(backend.GetQueue(project, "staging")).ThenReturn([]models.ProjectLock{{}}, nil)
+	m := metrics.New(backend)
+
+	m.WebhookReceived("Github")
+	m.WebhookReceived("Github")
+	m.WebhookReceived("Gitlab")
+	m.WebhookRejected("rate_limited")
+	m.CommandRun("plan", 3*time.Second, false)
+	m.CommandRun("plan", 90*time.Second, true)
+	m.CommandRun("apply", 500*time.Millisecond, false)
+
+	code, _, body := scrape(m)
+	Equals(t, http.StatusOK, code)
+	for _, exp := range []string{
+		`atlantis_webhook_events_total{vcs="Github"} 2` + "\n",
+		`atlantis_webhook_events_total{vcs="Gitlab"} 1` + "\n",
+		`atlantis_webhooks_rejected_total{reason="rate_limited"} 1` + "\n",
+		`atlantis_project_commands_total{command="apply"} 1` + "\n",
+		`atlantis_project_commands_total{command="plan"} 2` + "\n",
+		`atlantis_project_command_failures_total{command="plan"} 1` + "\n",
+		`atlantis_project_command_duration_seconds_bucket{command="plan",le="1"} 0` + "\n" +
+			`atlantis_project_command_duration_seconds_bucket{command="plan",le="5"} 1` + "\n" +
+			`atlantis_project_command_duration_seconds_bucket{command="plan",le="10"} 1` + "\n" +
+			`atlantis_project_command_duration_seconds_bucket{command="plan",le="30"} 1` + "\n" +
+			`atlantis_project_command_duration_seconds_bucket{command="plan",le="60"} 1` + "\n" +
+			`atlantis_project_command_duration_seconds_bucket{command="plan",le="120"} 2` + "\n",
+		`atlantis_project_command_duration_seconds_bucket{command="plan",le="+Inf"} 2` + "\n" +
+			`atlantis_project_command_duration_seconds_sum{command="plan"} 93` + "\n" +
+			`atlantis_project_command_duration_seconds_count{command="plan"} 2` + "\n",
+		`atlantis_project_command_duration_seconds_bucket{command="apply",le="1"} 1` + "\n",
+		"atlantis_locks_active 2\n",
+		"atlantis_lock_queue_depth 3\n",
+	} {
+		Assert(t, strings.Contains(body, exp), "exp body to contain %q but was:\n%s", exp, body)
+	}
+	Assert(t, !strings.Contains(body, `atlantis_project_command_failures_total{command="apply"}`), "exp no apply failures")
+}
+
+func TestServeHTTP_EscapesLabels(t *testing.T) {
+	RegisterMockTestingT(t)
+	backend := mocks.NewMockBackend()
+	When(backend.List()).ThenReturn(nil, nil)
+	m := metrics.New(backend)
+	m.WebhookReceived("a\"b\\c\nd")
+
+	_, _, body := scrape(m)
+	exp := `atlantis_webhook_events_total{vcs="a\"b\\c\nd"} 1` + "\n"
+	Assert(t, strings.Contains(body, exp), "exp body to contain %q but was:\n%s", exp, body)
+}
+
+func TestServeHTTP_LockErr(t *testing.T) {
+	RegisterMockTestingT(t)
+	backend := mocks.NewMockBackend()
+	When(backend.List()).ThenReturn(nil, errors.New("err"))
+	m := metrics.New(backend)
+
+	code, _, body := scrape(m)
+	Equals(t, http.StatusInternalServerError, code)
+	Equals(t, "Error collecting metrics: listing locks: err", body)
+}
+
+func scrape(m *metrics.Metrics) (int, string, string) {
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, req)
+	body, _ := ioutil.ReadAll(w.Result().Body)
+	return w.Result().StatusCode, w.Result().Header.Get("Content-Type"), string(body)
+}
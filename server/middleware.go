@@ -14,6 +14,9 @@
 package server
 
 import (
+	"crypto/subtle"
+	"fmt"
+	"net"
 	"net/http"
 	"strings"
 
@@ -46,3 +49,140 @@ func (l *RequestLogger) ServeHTTP(rw http.ResponseWriter, r *http.Request, next
 func (l *RequestLogger) shouldLog(r *http.Request) bool {
 	return !strings.HasPrefix(r.URL.RequestURI(), "/static")
 }
+
+// BasicAuth is middleware that requires HTTP basic auth with Username and
+// Password on all routes except the ones that authenticate requests
+// themselves or must be reachable without credentials.
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+// ServeHTTP implements the middleware function. It responds with HTTP 401 if
+// the request needs credentials and they're missing or wrong.
+func (b *BasicAuth) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if b.isPublic(r) {
+		next(rw, r)
+		return
+	}
+	user, pass, ok := r.BasicAuth()
+	// Compare both in constant time so the response time doesn't say which
+	// was wrong.
+	userOK := subtle.ConstantTimeCompare([]byte(user), []byte(b.Username)) == 1
+	passOK := subtle.ConstantTimeCompare([]byte(pass), []byte(b.Password)) == 1
+	if !ok || !userOK || !passOK {
+		rw.Header().Set("WWW-Authenticate", `Basic realm="atlantis"`)
+		http.Error(rw, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	next(rw, r)
+}
+
+// isPublic returns true if r doesn't need basic auth. Webhooks are
+// authenticated with the webhook secrets and the API with the API secret, and
+// /healthz is used by load balancers.
+func (b *BasicAuth) isPublic(r *http.Request) bool {
+	return r.URL.Path == "/events" || r.URL.Path == "/healthz" || strings.HasPrefix(r.URL.Path, "/api/")
+}
+
+// WebhookIPAllowlist is middleware that rejects webhooks, ex. from Bitbucket
+// Cloud which can't sign them, that don't come from Allowed.
+type WebhookIPAllowlist struct {
+	Allowed []*net.IPNet
+	// TrustedProxies are the proxies, ex. load balancers, in front of
+	// Atlantis. If a webhook comes from one, its source is the address before
+	// the trusted proxies in its X-Forwarded-For header. If empty, the
+	// header is ignored since anyone can set it.
+	TrustedProxies []*net.IPNet
+	Logger         *logging.SimpleLogger
+}
+
+// ServeHTTP implements the middleware function. It responds with HTTP 403 if
+// the request is a webhook from an address that isn't allowed.
+func (a *WebhookIPAllowlist) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if r.URL.Path != "/events" {
+		next(rw, r)
+		return
+	}
+	ip := a.sourceIP(r)
+	if ip == nil {
+		a.Logger.Warn("rejecting webhook since its source address %q, X-Forwarded-For %q, couldn't be parsed", r.RemoteAddr, r.Header.Get("X-Forwarded-For"))
+		http.Error(rw, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if !containsIP(a.Allowed, ip) {
+		a.Logger.Warn("rejecting webhook from %s since it isn't in the allowed IPs", ip)
+		http.Error(rw, "Forbidden", http.StatusForbidden)
+		return
+	}
+	next(rw, r)
+}
+
+// sourceIP returns the address r was sent from. The X-Forwarded-For header is
+// read right to left, ex. "client, proxy1" from proxy2, skipping the trusted
+// proxies that appended to it.
+func (a *WebhookIPAllowlist) sourceIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !containsIP(a.TrustedProxies, ip) {
+		return ip
+	}
+	var forwarded []string
+	for _, h := range r.Header["X-Forwarded-For"] {
+		forwarded = append(forwarded, strings.Split(h, ",")...)
+	}
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip = net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if ip == nil {
+			return nil
+		}
+		if !containsIP(a.TrustedProxies, ip) {
+			return ip
+		}
+	}
+	// Every address was a trusted proxy so the webhook came from one.
+	return ip
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseCIDRs parses a comma separated list of CIDRs, ex.
+// "104.192.136.0/21,34.198.203.127". Addresses without a prefix length match
+// only themselves.
+func ParseCIDRs(list string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("%q is not an IP address or CIDR", s)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP address or CIDR", s)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
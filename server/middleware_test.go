@@ -0,0 +1,103 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestBasicAuth(t *testing.T) {
+	cases := []struct {
+		description string
+		path        string
+		user        string
+		pass        string
+		expCode     int
+	}{
+		{"no credentials", "/", "", "", http.StatusUnauthorized},
+		{"wrong password", "/", "atlantis", "wrong", http.StatusUnauthorized},
+		{"wrong user", "/locks?id=1", "other", "password", http.StatusUnauthorized},
+		{"correct credentials", "/", "atlantis", "password", http.StatusOK},
+		{"metrics", "/metrics", "", "", http.StatusUnauthorized},
+		{"webhooks", "/events", "", "", http.StatusOK},
+		{"health check", "/healthz", "", "", http.StatusOK},
+		{"api", "/api/plan", "", "", http.StatusOK},
+	}
+	b := &server.BasicAuth{Username: "atlantis", Password: "password"}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			r := httptest.NewRequest("GET", c.path, nil)
+			if c.user != "" {
+				r.SetBasicAuth(c.user, c.pass)
+			}
+			w := httptest.NewRecorder()
+			b.ServeHTTP(w, r, func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			Equals(t, c.expCode, w.Code)
+			if c.expCode == http.StatusUnauthorized {
+				Equals(t, `Basic realm="atlantis"`, w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestWebhookIPAllowlist(t *testing.T) {
+	allowed, err := server.ParseCIDRs("104.192.136.0/21, 2001:db8::/32")
+	Ok(t, err)
+	proxies, err := server.ParseCIDRs("10.0.0.1,10.0.0.2")
+	Ok(t, err)
+	cases := []struct {
+		description   string
+		path          string
+		remoteAddr    string
+		xForwardedFor string
+		expCode       int
+	}{
+		{"allowed", "/events", "104.192.137.1:1234", "", http.StatusOK},
+		{"allowed ipv6", "/events", "[2001:db8::1]:1234", "", http.StatusOK},
+		{"not allowed", "/events", "1.2.3.4:1234", "", http.StatusForbidden},
+		{"not a webhook", "/", "1.2.3.4:1234", "", http.StatusOK},
+		{"forwarded by trusted proxy", "/events", "10.0.0.1:1234", "104.192.137.1", http.StatusOK},
+		{"forwarded by trusted proxies", "/events", "10.0.0.1:1234", "104.192.137.1, 10.0.0.2", http.StatusOK},
+		{"forwarded from not allowed", "/events", "10.0.0.1:1234", "1.2.3.4", http.StatusForbidden},
+		{"spoofed before trusted proxy", "/events", "10.0.0.1:1234", "104.192.137.1, 1.2.3.4", http.StatusForbidden},
+		{"forwarded by untrusted proxy", "/events", "1.2.3.4:1234", "104.192.137.1", http.StatusForbidden},
+		{"unparseable forwarded address", "/events", "10.0.0.1:1234", "unknown", http.StatusForbidden},
+	}
+	a := &server.WebhookIPAllowlist{Allowed: allowed, TrustedProxies: proxies, Logger: logging.NewNoopLogger()}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			r := httptest.NewRequest("POST", c.path, nil)
+			r.RemoteAddr = c.remoteAddr
+			if c.xForwardedFor != "" {
+				r.Header.Set("X-Forwarded-For", c.xForwardedFor)
+			}
+			w := httptest.NewRecorder()
+			a.ServeHTTP(w, r, func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			Equals(t, c.expCode, w.Code)
+		})
+	}
+}
+
+func TestParseCIDRs(t *testing.T) {
+	nets, err := server.ParseCIDRs("104.192.136.0/21,34.198.203.127, 2001:db8::1")
+	Ok(t, err)
+	Equals(t, 3, len(nets))
+	Equals(t, "104.192.136.0/21", nets[0].String())
+	Equals(t, "34.198.203.127/32", nets[1].String())
+	Equals(t, "2001:db8::1/128", nets[2].String())
+
+	nets, err = server.ParseCIDRs("")
+	Ok(t, err)
+	Equals(t, 0, len(nets))
+
+	_, err = server.ParseCIDRs("10.0.0.0/33")
+	ErrEquals(t, `"10.0.0.0/33" is not an IP address or CIDR`, err)
+}
@@ -0,0 +1,485 @@
+// Package oidc logs users into the web UI with an OpenID Connect provider,
+// ex. Okta, Google or Keycloak. See https://openid.net/connect/.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// The routes that Authenticator handles. They're relative to the Atlantis URL.
+const (
+	LoginPath    = "/auth/login"
+	CallbackPath = "/auth/callback"
+	LogoutPath   = "/auth/logout"
+)
+
+const (
+	// sessionCookie stores the logged in user.
+	sessionCookie = "atlantis_session"
+	// loginCookie stores the state of a login in progress.
+	loginCookie = "atlantis_login"
+	// sessionDuration is how long users stay logged in for.
+	sessionDuration = 8 * time.Hour
+	// loginDuration is how long users have to log in with the provider.
+	loginDuration = 10 * time.Minute
+)
+
+// Config configures an Authenticator.
+type Config struct {
+	// Issuer is the URL of the provider, ex. https://accounts.google.com. Its
+	// discovery document must be at Issuer/.well-known/openid-configuration.
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// AtlantisURL is where Atlantis is served. Users are sent back to
+	// CallbackPath under it after logging in.
+	AtlantisURL *url.URL
+	// AdminGroups are the groups in the ID token's groups claim that are
+	// allowed to take destructive actions. If empty, all users are.
+	AdminGroups []string
+	Logger      *logging.SimpleLogger
+	// HTTPClient is used to call the provider. Defaults to
+	// http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// Authenticator makes users log in before they can use the web UI.
+type Authenticator struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	atlantisURL  *url.URL
+	adminGroups  []string
+	logger       *logging.SimpleLogger
+	httpClient   *http.Client
+	authURL      string
+	tokenURL     string
+	keys         *keySet
+	// sessionKey signs session cookies. It's derived from the client secret
+	// so that all Atlantis instances sharing it accept each other's cookies.
+	sessionKey []byte
+}
+
+// User is a logged in user.
+type User struct {
+	// Name is the user's email or, if the provider doesn't include it, their
+	// username or subject.
+	Name   string    `json:"name"`
+	Groups []string  `json:"groups"`
+	Expiry time.Time `json:"expiry"`
+}
+
+// userContextKey is the key of the logged in User in the context of the
+// requests that RequireLogin and RequireAdmin let through.
+type userContextKey struct{}
+
+// UserFromContext returns the user that logged in to make the request with
+// ctx, if any.
+func UserFromContext(ctx context.Context) (User, bool) {
+	u, ok := ctx.Value(userContextKey{}).(User)
+	return u, ok
+}
+
+// loginState is stored in the login cookie while the user logs in with the
+// provider.
+type loginState struct {
+	State    string    `json:"state"`
+	Nonce    string    `json:"nonce"`
+	Redirect string    `json:"redirect"`
+	Expiry   time.Time `json:"expiry"`
+}
+
+// New returns an Authenticator for the provider at cfg.Issuer. An error is
+// returned if its discovery document can't be fetched.
+func New(cfg Config) (*Authenticator, error) {
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	issuer := strings.TrimSuffix(cfg.Issuer, "/")
+	discoveryURL := issuer + "/.well-known/openid-configuration"
+	var discovery struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+	if err := getJSON(httpClient, discoveryURL, &discovery); err != nil {
+		return nil, errors.Wrap(err, "getting OIDC discovery document")
+	}
+	// The issuer in tokens is the one from the discovery document, which
+	// must be the same as the one we were configured with.
+	if strings.TrimSuffix(discovery.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("issuer in %s is %q, not %q", discoveryURL, discovery.Issuer, cfg.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("%s is missing authorization_endpoint, token_endpoint or jwks_uri", discoveryURL)
+	}
+
+	sessionKey := sha256.Sum256([]byte("atlantis-session:" + cfg.ClientSecret))
+	return &Authenticator{
+		issuer:       discovery.Issuer,
+		clientID:     cfg.ClientID,
+		clientSecret: cfg.ClientSecret,
+		atlantisURL:  cfg.AtlantisURL,
+		adminGroups:  cfg.AdminGroups,
+		logger:       cfg.Logger,
+		httpClient:   httpClient,
+		authURL:      discovery.AuthorizationEndpoint,
+		tokenURL:     discovery.TokenEndpoint,
+		keys:         &keySet{url: discovery.JWKSURI, httpClient: httpClient},
+		sessionKey:   sessionKey[:],
+	}, nil
+}
+
+// RequireLogin wraps next so that users must be logged in to use it. Users
+// that aren't are redirected to log in if the request is a GET, otherwise
+// it's rejected.
+func (a *Authenticator) RequireLogin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, ok := a.user(r)
+		if !ok {
+			a.loginRequired(w, r)
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), userContextKey{}, u)))
+	}
+}
+
+// RequireAdmin wraps next, a destructive action, so that users must be
+// logged in and in one of the admin groups, if any are configured, to use it.
+func (a *Authenticator) RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, ok := a.user(r)
+		if !ok {
+			a.loginRequired(w, r)
+			return
+		}
+		if !a.isAdmin(u) {
+			a.logger.Warn("%s isn't in any of the admin groups so can't %s %s", u.Name, r.Method, r.URL.Path)
+			http.Error(w, fmt.Sprintf("%s isn't allowed to do this: only members of %s are", u.Name, strings.Join(a.adminGroups, ", ")), http.StatusForbidden)
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), userContextKey{}, u)))
+	}
+}
+
+// Login is the GET LoginPath route. It sends the user to log in with the
+// provider. The redirect query param is where they're sent once they're
+// logged in.
+func (a *Authenticator) Login(w http.ResponseWriter, r *http.Request) {
+	state, err := randomString()
+	if err != nil {
+		a.respondErr(w, http.StatusInternalServerError, "generating state: %s", err)
+		return
+	}
+	nonce, err := randomString()
+	if err != nil {
+		a.respondErr(w, http.StatusInternalServerError, "generating nonce: %s", err)
+		return
+	}
+	login := loginState{
+		State:    state,
+		Nonce:    nonce,
+		Redirect: a.safeRedirect(r.URL.Query().Get("redirect")),
+		Expiry:   time.Now().Add(loginDuration),
+	}
+	if err := a.setCookie(w, loginCookie, login, login.Expiry); err != nil {
+		a.respondErr(w, http.StatusInternalServerError, "saving login state: %s", err)
+		return
+	}
+
+	params := url.Values{
+		"response_type": {"code"},
+		"client_id":     {a.clientID},
+		"redirect_uri":  {a.callbackURL()},
+		"scope":         {"openid email profile"},
+		"state":         {state},
+		"nonce":         {nonce},
+	}
+	sep := "?"
+	if strings.Contains(a.authURL, "?") {
+		sep = "&"
+	}
+	http.Redirect(w, r, a.authURL+sep+params.Encode(), http.StatusFound)
+}
+
+// Callback is the GET CallbackPath route that the provider sends users back
+// to once they've logged in. It exchanges the code it's called with for an
+// ID token and saves the user in the session cookie.
+func (a *Authenticator) Callback(w http.ResponseWriter, r *http.Request) {
+	var login loginState
+	if !a.readCookie(r, loginCookie, &login) || time.Now().After(login.Expiry) {
+		a.respondErr(w, http.StatusBadRequest, "login expired or wasn't started by Atlantis, try again")
+		return
+	}
+	query := r.URL.Query()
+	if !hmac.Equal([]byte(query.Get("state")), []byte(login.State)) {
+		a.respondErr(w, http.StatusBadRequest, "state doesn't match, try logging in again")
+		return
+	}
+	if errCode := query.Get("error"); errCode != "" {
+		a.respondErr(w, http.StatusUnauthorized, "logging in failed: %s %s", errCode, query.Get("error_description"))
+		return
+	}
+
+	idToken, err := a.exchangeCode(query.Get("code"))
+	if err != nil {
+		a.respondErr(w, http.StatusUnauthorized, "exchanging code for token: %s", err)
+		return
+	}
+	c, err := a.verifyIDToken(idToken, login.Nonce)
+	if err != nil {
+		a.respondErr(w, http.StatusUnauthorized, "verifying ID token: %s", err)
+		return
+	}
+
+	u := User{Name: c.Email, Groups: c.Groups, Expiry: time.Now().Add(sessionDuration)}
+	if u.Name == "" {
+		u.Name = c.PreferredUsername
+	}
+	if u.Name == "" {
+		u.Name = c.Subject
+	}
+	if err := a.setCookie(w, sessionCookie, u, u.Expiry); err != nil {
+		a.respondErr(w, http.StatusInternalServerError, "saving session: %s", err)
+		return
+	}
+	a.clearCookie(w, loginCookie)
+	a.logger.Info("%s logged in", u.Name)
+	http.Redirect(w, r, login.Redirect, http.StatusFound)
+}
+
+// Logout is the GET LogoutPath route. It deletes the session cookie. Users
+// stay logged in with the provider.
+func (a *Authenticator) Logout(w http.ResponseWriter, r *http.Request) {
+	a.clearCookie(w, sessionCookie)
+	http.Redirect(w, r, a.basePath()+"/", http.StatusFound)
+}
+
+// user returns the user logged in by r's session cookie.
+func (a *Authenticator) user(r *http.Request) (User, bool) {
+	var u User
+	if !a.readCookie(r, sessionCookie, &u) || time.Now().After(u.Expiry) {
+		return u, false
+	}
+	return u, true
+}
+
+func (a *Authenticator) isAdmin(u User) bool {
+	if len(a.adminGroups) == 0 {
+		return true
+	}
+	for _, g := range u.Groups {
+		for _, admin := range a.adminGroups {
+			if g == admin {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (a *Authenticator) loginRequired(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		loginURL := a.basePath() + LoginPath + "?" + url.Values{"redirect": {a.basePath() + r.URL.RequestURI()}}.Encode()
+		http.Redirect(w, r, loginURL, http.StatusFound)
+		return
+	}
+	http.Error(w, "log in to Atlantis first", http.StatusUnauthorized)
+}
+
+// exchangeCode exchanges code for an ID token at the provider's token
+// endpoint.
+func (a *Authenticator) exchangeCode(code string) (string, error) {
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {a.callbackURL()},
+	}
+	req, err := http.NewRequest(http.MethodPost, a.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(a.clientID), url.QueryEscape(a.clientSecret))
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", errors.Wrapf(err, "parsing response with status %d", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d: %s %s", resp.StatusCode, body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", errors.New("response didn't include an id_token")
+	}
+	return body.IDToken, nil
+}
+
+// safeRedirect returns redirect if it's a path under our base path,
+// otherwise the index, so that we can't be used to send users to other sites.
+// Browsers treat backslashes like slashes so ex. /\evil.com is rejected like
+// //evil.com is.
+func (a *Authenticator) safeRedirect(redirect string) string {
+	if strings.Contains(redirect, `\`) || (len(redirect) > 1 && redirect[1] == '/') {
+		return a.basePath() + "/"
+	}
+	u, err := url.Parse(redirect)
+	if err != nil || u.IsAbs() || u.Host != "" || !strings.HasPrefix(u.Path, a.basePath()+"/") {
+		return a.basePath() + "/"
+	}
+	return redirect
+}
+
+func (a *Authenticator) basePath() string {
+	return strings.TrimSuffix(a.atlantisURL.Path, "/")
+}
+
+func (a *Authenticator) callbackURL() string {
+	u := *a.atlantisURL
+	u.Path = a.basePath() + CallbackPath
+	return u.String()
+}
+
+// setCookie stores v in cookie name, signed so that it can't be changed.
+func (a *Authenticator) setCookie(w http.ResponseWriter, name string, v interface{}, expiry time.Time) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    encoded + "." + a.sign(name, encoded),
+		Path:     a.basePath() + "/",
+		Expires:  expiry,
+		HttpOnly: true,
+		Secure:   a.atlantisURL.Scheme == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// readCookie reads cookie name into v. It returns false if the cookie isn't
+// set or its signature isn't valid.
+func (a *Authenticator) readCookie(r *http.Request, name string, v interface{}) bool {
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return false
+	}
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 2 || !hmac.Equal([]byte(parts[1]), []byte(a.sign(name, parts[0]))) {
+		return false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(payload, v) == nil
+}
+
+func (a *Authenticator) clearCookie(w http.ResponseWriter, name string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Path:     a.basePath() + "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   a.atlantisURL.Scheme == "https",
+	})
+}
+
+// sign returns the signature of cookie name's value. The name is signed too
+// so one cookie's value can't be used as another's.
+func (a *Authenticator) sign(name string, value string) string {
+	mac := hmac.New(sha256.New, a.sessionKey)
+	mac.Write([]byte(name + "=" + value)) // nolint: errcheck
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (a *Authenticator) respondErr(w http.ResponseWriter, code int, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	a.logger.Warn("OIDC login failed: %s", msg)
+	http.Error(w, msg, code)
+}
+
+// keySet fetches and caches the provider's signing keys.
+type keySet struct {
+	url        string
+	httpClient *http.Client
+	mu         sync.Mutex
+	keys       map[string]crypto.PublicKey
+}
+
+// get returns the key with kid. If we don't have it, the keys are fetched
+// again in case the provider has rotated them.
+func (k *keySet) get(kid string) (crypto.PublicKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if key, ok := k.keys[kid]; ok {
+		return key, nil
+	}
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := getJSON(k.httpClient, k.url, &jwks); err != nil {
+		return nil, errors.Wrap(err, "getting signing keys")
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, j := range jwks.Keys {
+		// Keys we don't support can't have signed the token.
+		if key, err := j.publicKey(); err == nil {
+			keys[j.Kid] = key
+		}
+	}
+	k.keys = keys
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("no signing key with ID %q", kid)
+	}
+	return key, nil
+}
+
+func getJSON(client *http.Client, url string, v interface{}) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+	return errors.Wrapf(json.NewDecoder(resp.Body).Decode(v), "parsing %s", url)
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,351 @@
+package oidc_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/oidc"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+var signingKey *rsa.PrivateKey
+
+func init() {
+	var err error
+	signingKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// provider is a fake OIDC provider.
+type provider struct {
+	server *httptest.Server
+	// claims are added to, or override, the claims of the ID tokens it
+	// issues.
+	claims map[string]interface{}
+	// nonce is the nonce of the last login that was started.
+	nonce string
+}
+
+func newProvider(t *testing.T) *provider {
+	p := &provider{claims: map[string]interface{}{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{ // nolint: errcheck
+			"issuer":                 p.server.URL,
+			"authorization_endpoint": p.server.URL + "/authorize",
+			"token_endpoint":         p.server.URL + "/token",
+			"jwks_uri":               p.server.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{ // nolint: errcheck
+			"keys": []map[string]string{{
+				"kid": "key1",
+				"kty": "RSA",
+				"n":   base64.RawURLEncoding.EncodeToString(signingKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(signingKey.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != "atlantis" || secret != "secret" || r.FormValue("code") != "code" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "invalid_grant"}`)) // nolint: errcheck
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": p.idToken(t)}) // nolint: errcheck
+	})
+	p.server = httptest.NewServer(mux)
+	return p
+}
+
+// idToken returns an ID token for the last login that was started.
+func (p *provider) idToken(t *testing.T) string {
+	c := map[string]interface{}{
+		"iss":    p.server.URL,
+		"aud":    "atlantis",
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"sub":    "1234",
+		"email":  "lkysow@example.com",
+		"groups": []string{"dev"},
+		"nonce":  p.nonce,
+	}
+	for k, v := range p.claims {
+		c[k] = v
+	}
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": "key1"})
+	Ok(t, err)
+	payload, err := json.Marshal(c)
+	Ok(t, err)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, signingKey, crypto.SHA256, digest[:])
+	Ok(t, err)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func newAuthenticator(t *testing.T, p *provider, adminGroups []string) *oidc.Authenticator {
+	atlantisURL, err := url.Parse("https://atlantis.example.com/basepath")
+	Ok(t, err)
+	a, err := oidc.New(oidc.Config{
+		Issuer:       p.server.URL,
+		ClientID:     "atlantis",
+		ClientSecret: "secret",
+		AtlantisURL:  atlantisURL,
+		AdminGroups:  adminGroups,
+		Logger:       logging.NewNoopLogger(),
+	})
+	Ok(t, err)
+	return a
+}
+
+// login logs in through a and returns the response from the callback.
+func login(t *testing.T, a *oidc.Authenticator, p *provider, redirect string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	a.Login(w, httptest.NewRequest("GET", "/basepath/auth/login?redirect="+url.QueryEscape(redirect), nil))
+	Equals(t, http.StatusFound, w.Code)
+	authURL, err := url.Parse(w.Header().Get("Location"))
+	Ok(t, err)
+	Equals(t, p.server.URL+"/authorize", authURL.Scheme+"://"+authURL.Host+authURL.Path)
+	query := authURL.Query()
+	Equals(t, "atlantis", query.Get("client_id"))
+	Equals(t, "https://atlantis.example.com/basepath/auth/callback", query.Get("redirect_uri"))
+	p.nonce = query.Get("nonce")
+
+	callback := httptest.NewRequest("GET", "/basepath/auth/callback?code=code&state="+url.QueryEscape(query.Get("state")), nil)
+	for _, c := range w.Result().Cookies() {
+		callback.AddCookie(c)
+	}
+	w = httptest.NewRecorder()
+	a.Callback(w, callback)
+	return w
+}
+
+// withCookies returns a request with the cookies set by w.
+func withCookies(method string, target string, w *httptest.ResponseRecorder) *http.Request {
+	r := httptest.NewRequest(method, target, nil)
+	for _, c := range w.Result().Cookies() {
+		if c.MaxAge >= 0 {
+			r.AddCookie(c)
+		}
+	}
+	return r
+}
+
+func okHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Write([]byte("ok")) // nolint: errcheck
+}
+
+func TestNew_IssuerMismatch(t *testing.T) {
+	p := newProvider(t)
+	defer p.server.Close()
+	_, err := oidc.New(oidc.Config{Issuer: p.server.URL + "/other", Logger: logging.NewNoopLogger()})
+	ErrContains(t, "getting OIDC discovery document", err)
+
+	// Our test server serves the discovery document under any path prefix
+	// so we check the issuer in it against the one we were configured with.
+	_, err = oidc.New(oidc.Config{Issuer: strings.Replace(p.server.URL, "127.0.0.1", "localhost", 1), Logger: logging.NewNoopLogger()})
+	ErrContains(t, "issuer in", err)
+}
+
+func TestRequireLogin_RedirectsToLogin(t *testing.T) {
+	p := newProvider(t)
+	defer p.server.Close()
+	a := newAuthenticator(t, p, nil)
+
+	w := httptest.NewRecorder()
+	a.RequireLogin(okHandler)(w, httptest.NewRequest("GET", "/jobs?page=2", nil))
+	Equals(t, http.StatusFound, w.Code)
+	Equals(t, "/basepath/auth/login?redirect=%2Fbasepath%2Fjobs%3Fpage%3D2", w.Header().Get("Location"))
+
+	t.Log("requests other than GETs can't be redirected so they're rejected")
+	w = httptest.NewRecorder()
+	a.RequireLogin(okHandler)(w, httptest.NewRequest("DELETE", "/locks?id=1", nil))
+	Equals(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestLogin(t *testing.T) {
+	p := newProvider(t)
+	defer p.server.Close()
+	a := newAuthenticator(t, p, nil)
+
+	w := login(t, a, p, "/basepath/jobs")
+	Equals(t, http.StatusFound, w.Code)
+	Equals(t, "/basepath/jobs", w.Header().Get("Location"))
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "atlantis_session" {
+			Assert(t, c.HttpOnly && c.Secure, "session cookie should be HttpOnly and Secure")
+			Equals(t, "/basepath/", c.Path)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	a.RequireLogin(okHandler)(rec, withCookies("GET", "/basepath/jobs", w))
+	Equals(t, http.StatusOK, rec.Code)
+	Equals(t, "ok", rec.Body.String())
+
+	t.Log("the user should be in the request's context")
+	var u oidc.User
+	a.RequireLogin(func(_ http.ResponseWriter, r *http.Request) {
+		u, _ = oidc.UserFromContext(r.Context())
+	})(httptest.NewRecorder(), withCookies("GET", "/basepath/jobs", w))
+	Equals(t, "lkysow@example.com", u.Name)
+
+	t.Log("users are logged out by logout")
+	rec = httptest.NewRecorder()
+	a.Logout(rec, withCookies("GET", "/basepath/auth/logout", w))
+	Equals(t, "/basepath/", rec.Header().Get("Location"))
+	cookies := rec.Result().Cookies()
+	Equals(t, 1, len(cookies))
+	Equals(t, "atlantis_session", cookies[0].Name)
+	Assert(t, cookies[0].MaxAge < 0, "session cookie should be deleted")
+}
+
+func TestLogin_UnsafeRedirect(t *testing.T) {
+	p := newProvider(t)
+	defer p.server.Close()
+	a := newAuthenticator(t, p, nil)
+	for _, redirect := range []string{"https://evil.com/basepath/", "//evil.com/basepath/", "/other", `/\evil.com`, `/basepath/\evil.com`, `\\evil.com/basepath/`} {
+		t.Run(redirect, func(t *testing.T) {
+			w := login(t, a, p, redirect)
+			Equals(t, http.StatusFound, w.Code)
+			Equals(t, "/basepath/", w.Header().Get("Location"))
+		})
+	}
+}
+
+func TestCallback_InvalidToken(t *testing.T) {
+	cases := []struct {
+		description string
+		claims      map[string]interface{}
+		expErr      string
+	}{
+		{
+			"wrong nonce",
+			map[string]interface{}{"nonce": "other"},
+			"nonce doesn't match",
+		},
+		{
+			"wrong audience",
+			map[string]interface{}{"aud": []string{"other"}},
+			"issued for [other] instead of \"atlantis\"",
+		},
+		{
+			"wrong issuer",
+			map[string]interface{}{"iss": "https://evil.com"},
+			"issued by \"https://evil.com\"",
+		},
+		{
+			"expired",
+			map[string]interface{}{"exp": time.Now().Add(-time.Minute).Unix()},
+			"token has expired",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			p := newProvider(t)
+			defer p.server.Close()
+			p.claims = c.claims
+			a := newAuthenticator(t, p, nil)
+			w := login(t, a, p, "/basepath/")
+			Equals(t, http.StatusUnauthorized, w.Code)
+			Assert(t, strings.Contains(w.Body.String(), c.expErr), "expected %q to contain %q", w.Body.String(), c.expErr)
+
+			rec := httptest.NewRecorder()
+			a.RequireLogin(okHandler)(rec, withCookies("GET", "/basepath/", w))
+			Equals(t, http.StatusFound, rec.Code)
+		})
+	}
+}
+
+func TestCallback_WrongState(t *testing.T) {
+	p := newProvider(t)
+	defer p.server.Close()
+	a := newAuthenticator(t, p, nil)
+
+	w := httptest.NewRecorder()
+	a.Login(w, httptest.NewRequest("GET", "/basepath/auth/login", nil))
+	callback := withCookies("GET", "/basepath/auth/callback?code=code&state=wrong", w)
+	w = httptest.NewRecorder()
+	a.Callback(w, callback)
+	Equals(t, http.StatusBadRequest, w.Code)
+
+	t.Log("a callback without a login started by us is rejected")
+	w = httptest.NewRecorder()
+	a.Callback(w, httptest.NewRequest("GET", "/basepath/auth/callback?code=code&state=wrong", nil))
+	Equals(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRequireLogin_TamperedCookie(t *testing.T) {
+	p := newProvider(t)
+	defer p.server.Close()
+	a := newAuthenticator(t, p, []string{"admins"})
+	w := login(t, a, p, "/basepath/")
+
+	var session *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "atlantis_session" {
+			session = c
+		}
+	}
+	Assert(t, session != nil, "session cookie should be set")
+	// Try to add ourselves to the admin group.
+	parts := strings.Split(session.Value, ".")
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	Ok(t, err)
+	payload = []byte(strings.Replace(string(payload), `"dev"`, `"admins"`, 1))
+	session.Value = base64.RawURLEncoding.EncodeToString(payload) + "." + parts[1]
+
+	r := httptest.NewRequest("DELETE", "/basepath/locks?id=1", nil)
+	r.AddCookie(session)
+	rec := httptest.NewRecorder()
+	a.RequireAdmin(okHandler)(rec, r)
+	Equals(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestRequireAdmin(t *testing.T) {
+	p := newProvider(t)
+	defer p.server.Close()
+
+	t.Log("users not in an admin group are forbidden")
+	a := newAuthenticator(t, p, []string{"admins"})
+	w := login(t, a, p, "/basepath/")
+	rec := httptest.NewRecorder()
+	a.RequireAdmin(okHandler)(rec, withCookies("DELETE", "/basepath/locks?id=1", w))
+	Equals(t, http.StatusForbidden, rec.Code)
+	Equals(t, "lkysow@example.com isn't allowed to do this: only members of admins are\n", rec.Body.String())
+	// They can still use the rest of the UI.
+	rec = httptest.NewRecorder()
+	a.RequireLogin(okHandler)(rec, withCookies("GET", "/basepath/", w))
+	Equals(t, http.StatusOK, rec.Code)
+
+	t.Log("users in an admin group are allowed")
+	p.claims = map[string]interface{}{"groups": []string{"dev", "admins"}}
+	w = login(t, a, p, "/basepath/")
+	rec = httptest.NewRecorder()
+	a.RequireAdmin(okHandler)(rec, withCookies("DELETE", "/basepath/locks?id=1", w))
+	Equals(t, http.StatusOK, rec.Code)
+
+	t.Log("all users are allowed if there are no admin groups")
+	p.claims = nil
+	a = newAuthenticator(t, p, nil)
+	w = login(t, a, p, "/basepath/")
+	rec = httptest.NewRecorder()
+	a.RequireAdmin(okHandler)(rec, withCookies("DELETE", "/basepath/locks?id=1", w))
+	Equals(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,214 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// signingAlgs are the ID token signing algorithms we support, keyed by their
+// JWS name.
+var signingAlgs = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+// claims are the claims of an ID token that we use.
+type claims struct {
+	Issuer            string   `json:"iss"`
+	Audience          audience `json:"aud"`
+	Expiry            int64    `json:"exp"`
+	Nonce             string   `json:"nonce"`
+	Subject           string   `json:"sub"`
+	Email             string   `json:"email"`
+	PreferredUsername string   `json:"preferred_username"`
+	Groups            []string `json:"groups"`
+}
+
+// audience is the aud claim, which can be a string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+func (a audience) contains(s string) bool {
+	for _, aud := range a {
+		if aud == s {
+			return true
+		}
+	}
+	return false
+}
+
+// jwk is a key from the provider's JSON Web Key Set.
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	// N and E are set for RSA keys.
+	N string `json:"n"`
+	E string `json:"e"`
+	// Crv, X and Y are set for EC keys.
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey returns k as an *rsa.PublicKey or *ecdsa.PublicKey.
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding n")
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding e")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding x")
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding y")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// verifyIDToken checks that token was signed by the provider for us and
+// hasn't expired, and returns its claims. nonce must match the nonce we sent
+// when the user started logging in.
+func (a *Authenticator) verifyIDToken(token string, nonce string) (claims, error) {
+	var c claims
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return c, errors.New("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return c, errors.Wrap(err, "decoding header")
+	}
+	hash, ok := signingAlgs[header.Alg]
+	if !ok {
+		return c, fmt.Errorf("unsupported signing algorithm %q", header.Alg)
+	}
+	key, err := a.keys.get(header.Kid)
+	if err != nil {
+		return c, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return c, errors.Wrap(err, "decoding signature")
+	}
+	if err := verifySignature(key, header.Alg, hash, parts[0]+"."+parts[1], sig); err != nil {
+		return c, err
+	}
+
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return c, errors.Wrap(err, "decoding claims")
+	}
+	if c.Issuer != a.issuer {
+		return c, fmt.Errorf("issued by %q instead of %q", c.Issuer, a.issuer)
+	}
+	if !c.Audience.contains(a.clientID) {
+		return c, fmt.Errorf("issued for %v instead of %q", []string(c.Audience), a.clientID)
+	}
+	if time.Now().Unix() >= c.Expiry {
+		return c, errors.New("token has expired")
+	}
+	if c.Nonce != nonce {
+		return c, errors.New("nonce doesn't match")
+	}
+	return c, nil
+}
+
+func verifySignature(key crypto.PublicKey, alg string, hash crypto.Hash, signed string, sig []byte) error {
+	h := hash.New()
+	h.Write([]byte(signed)) // nolint: errcheck
+	digest := h.Sum(nil)
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return fmt.Errorf("%s token signed with an RSA key", alg)
+		}
+		return errors.Wrap(rsa.VerifyPKCS1v15(k, hash, digest, sig), "invalid signature")
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(alg, "ES") {
+			return fmt.Errorf("%s token signed with an EC key", alg)
+		}
+		// ES signatures are r and s concatenated, each the size of the
+		// curve.
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errors.New("invalid signature")
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported key %T", key)
+	}
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
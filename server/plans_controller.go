@@ -0,0 +1,65 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/mux"
+	"github.com/runatlantis/atlantis/server/events/plans"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// PlansController handles the requests to view the outputs of plans.
+type PlansController struct {
+	AtlantisVersion    string
+	AtlantisURL        *url.URL
+	PlanStore          plans.Store
+	Logger             *logging.SimpleLogger
+	PlanDetailTemplate TemplateWriter
+}
+
+// GetPlan is the GET /plans/{id} route. It renders the full output of the
+// plan with id.
+func (p *PlansController) GetPlan(w http.ResponseWriter, r *http.Request) {
+	id, ok := mux.Vars(r)["id"]
+	if !ok || id == "" {
+		p.respond(w, logging.Warn, http.StatusBadRequest, "No plan id in request")
+		return
+	}
+	plan, err := p.PlanStore.GetPlanOutput(id)
+	if err != nil {
+		p.respond(w, logging.Error, http.StatusInternalServerError, "Failed getting plan: %s", err)
+		return
+	}
+	if plan == nil {
+		p.respond(w, logging.Info, http.StatusNotFound, "No plan found with id %q. Plans are deleted after %d days.", id, int(plans.Retention.Hours()/24))
+		return
+	}
+	err = p.PlanDetailTemplate.Execute(w, PlanDetailData{
+		RepoFullName:    plan.RepoFullName,
+		PullNum:         plan.PullNum,
+		PullURL:         plan.PullURL,
+		HeadCommit:      plan.HeadCommit,
+		RepoRelDir:      plan.RepoRelDir,
+		Workspace:       plan.Workspace,
+		ProjectName:     plan.ProjectName,
+		User:            plan.User,
+		CreatedAt:       plan.CreatedAt,
+		Output:          plan.Output,
+		AtlantisVersion: p.AtlantisVersion,
+		CleanedBasePath: p.AtlantisURL.Path,
+	})
+	if err != nil {
+		p.Logger.Err("%s", err)
+	}
+}
+
+// respond is a helper function to respond and log the response. lvl is the log
+// level to log at, code is the HTTP response code.
+func (p *PlansController) respond(w http.ResponseWriter, lvl logging.LogLevel, responseCode int, format string, args ...interface{}) {
+	response := fmt.Sprintf(format, args...)
+	p.Logger.Log(lvl, "%s", response)
+	w.WriteHeader(responseCode)
+	fmt.Fprintln(w, response)
+}
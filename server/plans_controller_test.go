@@ -0,0 +1,93 @@
+package server_test
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/plans/mocks"
+	"github.com/runatlantis/atlantis/server/logging"
+	sMocks "github.com/runatlantis/atlantis/server/mocks"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestGetPlan(t *testing.T) {
+	RegisterMockTestingT(t)
+	store := mocks.NewMockStore()
+	created := time.Now()
+	When(store.GetPlanOutput("id")).ThenReturn(&models.PlanOutput{
+		ID:           "id",
+		RepoFullName: "owner/repo",
+		PullNum:      1,
+		PullURL:      "url",
+		HeadCommit:   "abc123",
+		RepoRelDir:   ".",
+		Workspace:    "default",
+		User:         "lkysow",
+		Output:       "output",
+		CreatedAt:    created,
+	}, nil)
+	tmpl := sMocks.NewMockTemplateWriter()
+	pc := newPlansController(t, store, tmpl)
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+	req = mux.SetURLVars(req, map[string]string{"id": "id"})
+	w := httptest.NewRecorder()
+	pc.GetPlan(w, req)
+	tmpl.VerifyWasCalledOnce().Execute(w, server.PlanDetailData{
+		RepoFullName:    "owner/repo",
+		PullNum:         1,
+		PullURL:         "url",
+		HeadCommit:      "abc123",
+		RepoRelDir:      ".",
+		Workspace:       "default",
+		User:            "lkysow",
+		CreatedAt:       created,
+		Output:          "output",
+		AtlantisVersion: "1300135",
+		CleanedBasePath: "/basepath",
+	})
+	responseContains(t, w, http.StatusOK, "")
+}
+
+func TestGetPlan_NotFound(t *testing.T) {
+	RegisterMockTestingT(t)
+	store := mocks.NewMockStore()
+	pc := newPlansController(t, store, sMocks.NewMockTemplateWriter())
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+	req = mux.SetURLVars(req, map[string]string{"id": "missing"})
+	w := httptest.NewRecorder()
+	pc.GetPlan(w, req)
+	responseContains(t, w, http.StatusNotFound, `No plan found with id "missing". Plans are deleted after 30 days.`)
+}
+
+func TestGetPlan_StoreErr(t *testing.T) {
+	RegisterMockTestingT(t)
+	store := mocks.NewMockStore()
+	When(store.GetPlanOutput("id")).ThenReturn(nil, errors.New("err"))
+	pc := newPlansController(t, store, sMocks.NewMockTemplateWriter())
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+	req = mux.SetURLVars(req, map[string]string{"id": "id"})
+	w := httptest.NewRecorder()
+	pc.GetPlan(w, req)
+	responseContains(t, w, http.StatusInternalServerError, "Failed getting plan: err")
+}
+
+func newPlansController(t *testing.T, store *mocks.MockStore, tmpl *sMocks.MockTemplateWriter) server.PlansController {
+	atlantisURL, err := url.Parse("https://example.com/basepath")
+	Ok(t, err)
+	return server.PlansController{
+		AtlantisVersion:    "1300135",
+		AtlantisURL:        atlantisURL,
+		PlanStore:          store,
+		Logger:             logging.NewNoopLogger(),
+		PlanDetailTemplate: tmpl,
+	}
+}
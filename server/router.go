@@ -33,3 +33,9 @@ func (r *Router) GenerateLockURL(lockID string) string {
 	// golang likes to double escape the lockURL path when using url.Parse().
 	return r.AtlantisURL.String() + lockURL.String()
 }
+
+// GeneratePlanURL returns a fully qualified URL to view the plan with planID.
+// Plan IDs are hex so they don't need escaping.
+func (r *Router) GeneratePlanURL(planID string) string {
+	return r.AtlantisURL.String() + "/plans/" + planID
+}
@@ -60,3 +60,12 @@ func TestRouter_GenerateLockURL(t *testing.T) {
 		})
 	}
 }
+
+func TestRouter_GeneratePlanURL(t *testing.T) {
+	for _, u := range []string{"https://example.com/basepath", "https://example.com/basepath/"} {
+		atlantisURL, err := server.ParseAtlantisURL(u)
+		Ok(t, err)
+		router := &server.Router{AtlantisURL: atlantisURL}
+		Equals(t, "https://example.com/basepath/plans/abc123", router.GeneratePlanURL("abc123"))
+	}
+}
@@ -25,25 +25,39 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/elazarl/go-bindata-assetfs"
 	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-version"
+	"github.com/nlopes/slack"
 	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/cron"
 	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/artifacts"
+	"github.com/runatlantis/atlantis/server/events/audit"
+	"github.com/runatlantis/atlantis/server/events/db"
+	"github.com/runatlantis/atlantis/server/events/jobs"
 	"github.com/runatlantis/atlantis/server/events/locking"
-	"github.com/runatlantis/atlantis/server/events/locking/boltdb"
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/events/runtime"
 	"github.com/runatlantis/atlantis/server/events/terraform"
 	"github.com/runatlantis/atlantis/server/events/vcs"
+	"github.com/runatlantis/atlantis/server/events/vcs/azuredevops"
 	"github.com/runatlantis/atlantis/server/events/vcs/bitbucketcloud"
 	"github.com/runatlantis/atlantis/server/events/vcs/bitbucketserver"
+	"github.com/runatlantis/atlantis/server/events/vcs/gitea"
 	"github.com/runatlantis/atlantis/server/events/webhooks"
+	"github.com/runatlantis/atlantis/server/events/workqueue"
 	"github.com/runatlantis/atlantis/server/events/yaml"
+	"github.com/runatlantis/atlantis/server/events/yaml/valid"
+	"github.com/runatlantis/atlantis/server/httpclient"
 	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/metrics"
+	"github.com/runatlantis/atlantis/server/oidc"
 	"github.com/runatlantis/atlantis/server/static"
 	"github.com/urfave/cli"
 	"github.com/urfave/negroni"
@@ -69,35 +83,78 @@ type Server struct {
 	CommandRunner      *events.DefaultCommandRunner
 	Logger             *logging.SimpleLogger
 	Locker             locking.Locker
+	ApplyLocker        locking.ApplyLocker
 	EventsController   *EventsController
 	LocksController    *LocksController
+	JobsController     *JobsController
+	PlansController    *PlansController
+	APIController      *APIController
 	IndexTemplate      TemplateWriter
 	LockDetailTemplate TemplateWriter
+	Metrics            *metrics.Metrics
+	DriftScheduler     *events.DriftScheduler
+	// WorkingDirGC deletes expired working dirs and enforces the data dir's
+	// disk budget. It's nil if neither is configured.
+	WorkingDirGC *events.WorkingDirGC
+	// WorkQueueWorkers run the commands in the work queue. It's nil unless
+	// this process is a worker.
+	WorkQueueWorkers *events.WorkQueueWorkers
+	// Drainer lets us wait for the running commands when shutting down, up
+	// to DrainTimeout.
+	Drainer      *events.Drainer
+	DrainTimeout time.Duration
+	// JobTracker's jobs that are still running after DrainTimeout are
+	// saved as failed.
+	JobTracker *jobs.Tracker
+	// InterruptedJobRecoverer records the jobs that were running when
+	// Atlantis last stopped without draining, ex. because it crashed.
+	InterruptedJobRecoverer *events.InterruptedJobRecoverer
+	// Authenticator makes users log in to use the web UI. If nil, they
+	// don't have to.
+	Authenticator *oidc.Authenticator
+	// BasicAuth requires HTTP basic auth for the web UI. If nil, it isn't
+	// required.
+	BasicAuth *BasicAuth
+	// WebhookIPAllowlist rejects webhooks from addresses that aren't allowed.
+	// If nil, webhooks can come from anywhere.
+	WebhookIPAllowlist *WebhookIPAllowlist
 	SSLCertFile        string
 	SSLKeyFile         string
 }
 
 // Config holds config for server that isn't passed in by the user.
 type Config struct {
-	AllowForkPRsFlag    string
-	AllowRepoConfigFlag string
-	AtlantisURLFlag     string
-	AtlantisVersion     string
+	AllowForkPRsFlag       string
+	AllowRepoConfigFlag    string
+	RepoConfigFlag         string
+	DriftDetectionCronFlag string
+	AtlantisURLFlag        string
+	AtlantisVersion        string
 }
 
 // WebhookConfig is nested within UserConfig. It's used to configure webhooks.
 type WebhookConfig struct {
-	// Event is the type of event we should send this webhook for, ex. apply.
+	// Event is the type of event we should send this webhook for, ex. apply,
+	// plan-error or lock.
 	Event string `mapstructure:"event"`
 	// WorkspaceRegex is a regex that is used to match against the workspace
 	// that is being modified for this event. If the regex matches, we'll
 	// send the webhook, ex. "production.*".
 	WorkspaceRegex string `mapstructure:"workspace-regex"`
+	// RepoRegex is a regex that is matched against the repo's full name, ex.
+	// "runatlantis/.*". If empty, all repos match.
+	RepoRegex string `mapstructure:"repo-regex"`
 	// Kind is the type of webhook we should send, ex. slack.
 	Kind string `mapstructure:"kind"`
 	// Channel is the channel to send this webhook to. It only applies to
 	// slack webhooks. Should be without '#'.
 	Channel string `mapstructure:"channel"`
+	// Template is a Go text/template for the message. If empty, the default
+	// message is sent.
+	Template string `mapstructure:"template"`
+	// ThreadPerPull groups the messages about each pull request into one
+	// Slack thread.
+	ThreadPerPull bool `mapstructure:"thread-per-pr"`
 }
 
 // NewServer returns a new server. If there are issues starting the server or
@@ -107,13 +164,43 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 	logger := logging.NewSimpleLogger("server", false, userConfig.ToLogLevel())
 	var supportedVCSHosts []models.VCSHostType
 	var githubClient *vcs.GithubClient
+	var githubAppCredentials *vcs.GithubAppCredentials
 	var gitlabClient *vcs.GitlabClient
 	var bitbucketCloudClient *bitbucketcloud.Client
 	var bitbucketServerClient *bitbucketserver.Client
-	if userConfig.GithubUser != "" {
+	var azuredevopsClient *azuredevops.Client
+	var giteaClient *gitea.Client
+	outboundHTTPClient, err := httpclient.New(httpclient.Config{CAFiles: []string{userConfig.SSLCAFile}})
+	if err != nil {
+		return nil, errors.Wrap(err, "setting up HTTP client")
+	}
+	vcsHTTPClient, err := httpclient.New(httpclient.Config{
+		CAFiles:  []string{userConfig.SSLCAFile, userConfig.VCSCAFile},
+		CertFile: userConfig.VCSTLSCertFile,
+		KeyFile:  userConfig.VCSTLSKeyFile,
+		ProxyURL: userConfig.VCSProxyURL,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "setting up VCS HTTP client")
+	}
+	if err := setGitEnv(userConfig); err != nil {
+		return nil, err
+	}
+	if userConfig.GithubAppID != 0 {
+		supportedVCSHosts = append(supportedVCSHosts, models.Github)
+		var err error
+		githubAppCredentials, err = vcs.NewGithubAppCredentials(vcsHTTPClient, userConfig.GithubHostname, int64(userConfig.GithubAppID), userConfig.GithubAppKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		githubClient, err = vcs.NewGithubAppClient(userConfig.GithubHostname, githubAppCredentials)
+		if err != nil {
+			return nil, err
+		}
+	} else if userConfig.GithubUser != "" {
 		supportedVCSHosts = append(supportedVCSHosts, models.Github)
 		var err error
-		githubClient, err = vcs.NewGithubClient(userConfig.GithubHostname, userConfig.GithubUser, userConfig.GithubToken)
+		githubClient, err = vcs.NewGithubClient(vcsHTTPClient, userConfig.GithubHostname, userConfig.GithubUser, userConfig.GithubToken)
 		if err != nil {
 			return nil, err
 		}
@@ -121,24 +208,28 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 	if userConfig.GitlabUser != "" {
 		supportedVCSHosts = append(supportedVCSHosts, models.Gitlab)
 		var err error
-		gitlabClient, err = vcs.NewGitlabClient(userConfig.GitlabHostname, userConfig.GitlabToken, logger)
+		gitlabClient, err = vcs.NewGitlabClient(vcsHTTPClient, userConfig.GitlabHostname, userConfig.GitlabToken, logger)
 		if err != nil {
 			return nil, err
 		}
+		gitlabClient.StatusName = userConfig.VCSStatusName
+		gitlabClient.PipelineStatuses = userConfig.GitlabPipelineStatuses
 	}
 	if userConfig.BitbucketUser != "" {
 		if userConfig.BitbucketBaseURL == bitbucketcloud.BaseURL {
 			supportedVCSHosts = append(supportedVCSHosts, models.BitbucketCloud)
 			bitbucketCloudClient = bitbucketcloud.NewClient(
-				http.DefaultClient,
+				vcsHTTPClient,
 				userConfig.BitbucketUser,
 				userConfig.BitbucketToken,
 				userConfig.AtlantisURL)
+			bitbucketCloudClient.StatusName = userConfig.VCSStatusName
+			bitbucketCloudClient.TokenType = userConfig.BitbucketTokenType
 		} else {
 			supportedVCSHosts = append(supportedVCSHosts, models.BitbucketServer)
 			var err error
 			bitbucketServerClient, err = bitbucketserver.NewClient(
-				http.DefaultClient,
+				vcsHTTPClient,
 				userConfig.BitbucketUser,
 				userConfig.BitbucketToken,
 				userConfig.BitbucketBaseURL,
@@ -146,6 +237,27 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 			if err != nil {
 				return nil, errors.Wrapf(err, "setting up Bitbucket Server client")
 			}
+			bitbucketServerClient.StatusName = userConfig.VCSStatusName
+		}
+	}
+	if userConfig.AzureDevopsUser != "" {
+		supportedVCSHosts = append(supportedVCSHosts, models.AzureDevops)
+		azuredevopsClient = azuredevops.NewClient(
+			vcsHTTPClient,
+			userConfig.AzureDevopsUser,
+			userConfig.AzureDevopsToken,
+			userConfig.AtlantisURL)
+	}
+	if userConfig.GiteaUser != "" {
+		supportedVCSHosts = append(supportedVCSHosts, models.Gitea)
+		var err error
+		giteaClient, err = gitea.NewClient(
+			vcsHTTPClient,
+			userConfig.GiteaToken,
+			userConfig.GiteaBaseURL,
+			userConfig.AtlantisURL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "setting up Gitea client")
 		}
 	}
 
@@ -156,6 +268,9 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 			Event:          c.Event,
 			Kind:           c.Kind,
 			WorkspaceRegex: c.WorkspaceRegex,
+			RepoRegex:      c.RepoRegex,
+			Template:       c.Template,
+			ThreadPerPull:  c.ThreadPerPull,
 		}
 		webhooksConfig = append(webhooksConfig, config)
 	}
@@ -163,29 +278,102 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "initializing webhooks")
 	}
-	vcsClient := vcs.NewDefaultClientProxy(githubClient, gitlabClient, bitbucketCloudClient, bitbucketServerClient)
-	commitStatusUpdater := &events.DefaultCommitStatusUpdater{Client: vcsClient}
-	terraformClient, err := terraform.NewClient(userConfig.DataDir, userConfig.TFEToken)
+	vcsClient := vcs.NewDefaultClientProxy(githubClient, gitlabClient, bitbucketCloudClient, bitbucketServerClient, azuredevopsClient, giteaClient)
+	statusUpdater := &events.DefaultCommitStatusUpdater{
+		Client:        vcsClient,
+		FailOnDestroy: userConfig.FailOnDestroy,
+		StatusName:    userConfig.VCSStatusName,
+	}
+	var commitStatusUpdater events.CommitStatusUpdater = statusUpdater
+	if userConfig.UseChecksAPI {
+		commitStatusUpdater = &events.ChecksCommitStatusUpdater{
+			Checks:   githubClient,
+			Statuses: statusUpdater,
+		}
+	}
+	// Projects that use remote operations are applied by confirming their
+	// runs with the TFE token.
+	var remoteRuns runtime.RemoteRunClient
+	var terraformCloudRuns events.TerraformCloudRunReader
+	if userConfig.TFEToken != "" {
+		cloudClient := &terraform.CloudClient{Token: userConfig.TFEToken, HTTPClient: outboundHTTPClient}
+		remoteRuns = cloudClient
+		terraformCloudRuns = cloudClient
+	}
+	terraformClient, err := terraform.NewClient(userConfig.DataDir, userConfig.TFEToken, userConfig.TFDownloadURL, outboundHTTPClient, userConfig.TFPluginCache, userConfig.ToDockerConfig(), userConfig.ToKubernetesConfig())
 	// The flag.Lookup call is to detect if we're running in a unit test. If we
 	// are, then we don't error out because we don't have/want terraform
 	// installed on our CI system where the unit tests run.
 	if err != nil && flag.Lookup("test.v") == nil {
 		return nil, errors.Wrap(err, "initializing terraform")
 	}
+	if terraformClient != nil {
+		downloadTerraformVersions(logger, terraformClient, userConfig.ToTFDownloadVersions())
+	}
 	markdownRenderer := &events.MarkdownRenderer{
 		GitlabSupportsCommonMark: gitlabClient.SupportsCommonMark(),
+		ExecutableName:           userConfig.ExecutableName,
+		DryRun:                   userConfig.DryRun,
 	}
-	boltdb, err := boltdb.New(userConfig.DataDir)
+	database, err := db.New(userConfig.DBType, db.Config{
+		DataDir:          userConfig.DataDir,
+		RedisHost:        userConfig.RedisHost,
+		RedisPassword:    userConfig.RedisPassword,
+		ConnectionString: userConfig.DBConnectionString,
+	})
 	if err != nil {
 		return nil, err
 	}
-	lockingClient := locking.NewClient(boltdb)
+	lockingClient := locking.NewClient(database)
+	jobTracker := jobs.NewTracker(database, logger)
+	// Jobs record the host they ran on so that when instances share the
+	// database each only recovers its own interrupted jobs. BoltDB can't be
+	// shared so there, any running job was ours.
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, errors.Wrap(err, "getting hostname")
+	}
+	jobTracker.Instance = hostname
+	recoverInstance := hostname
+	if userConfig.DBType == "" || userConfig.DBType == db.BoltDB {
+		recoverInstance = ""
+	}
+	// auditLog stays nil if the audit log is disabled.
+	var auditLog *audit.Log
+	if userConfig.EnableAuditLog {
+		auditLog = &audit.Log{Store: database, Logger: logger}
+		if userConfig.AuditLogFile != "" {
+			sink, err := audit.NewFileSink(userConfig.AuditLogFile)
+			if err != nil {
+				return nil, err
+			}
+			auditLog.Sinks = append(auditLog.Sinks, sink)
+		}
+		if userConfig.AuditSyslog != "" {
+			sink, err := audit.NewSyslogSink(userConfig.AuditSyslog)
+			if err != nil {
+				return nil, err
+			}
+			auditLog.Sinks = append(auditLog.Sinks, sink)
+		}
+	}
+	// serverMetrics stays nil if Prometheus is disabled which records nothing.
+	var serverMetrics *metrics.Metrics
+	if userConfig.EnablePrometheus {
+		serverMetrics = metrics.New(database)
+	}
 	workingDirLocker := events.NewDefaultWorkingDirLocker()
 	workingDir := &events.FileWorkspace{
-		DataDir: userConfig.DataDir,
+		DataDir:        userConfig.DataDir,
+		CloneRoot:      userConfig.CloneRoot,
+		CloneRetries:   userConfig.MaxCloneAttempts - 1,
+		CheckoutDepth:  userConfig.CheckoutDepth,
+		SparseCheckout: userConfig.SparseCheckout,
+		CloneCache:     userConfig.EnableCloneCache,
 	}
 	projectLocker := &events.DefaultProjectLocker{
-		Locker: lockingClient,
+		Locker:         lockingClient,
+		ExecutableName: userConfig.ExecutableName,
 	}
 	parsedURL, err := ParseAtlantisURL(userConfig.AtlantisURL)
 	if err != nil {
@@ -199,78 +387,312 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		LockViewRouteName:         LockViewRouteName,
 		Underlying:                underlyingRouter,
 	}
+	lockQueueNotifier := &events.DefaultLockQueueNotifier{
+		Locker:         lockingClient,
+		VCSClient:      vcsClient,
+		ExecutableName: userConfig.ExecutableName,
+	}
+	projectLocker.LockQueueNotifier = lockQueueNotifier
 	pullClosedExecutor := &events.PullClosedExecutor{
-		VCSClient:  vcsClient,
-		Locker:     lockingClient,
-		WorkingDir: workingDir,
+		VCSClient:           vcsClient,
+		Locker:              lockingClient,
+		WorkingDir:          workingDir,
+		LockQueueNotifier:   lockQueueNotifier,
+		CommitStatusUpdater: commitStatusUpdater,
+		AutoplanStore:       database,
+		ConfirmationStore:   database,
 	}
 	eventParser := &events.EventParser{
-		GithubUser:         userConfig.GithubUser,
-		GithubToken:        userConfig.GithubToken,
-		GitlabUser:         userConfig.GitlabUser,
-		GitlabToken:        userConfig.GitlabToken,
-		BitbucketUser:      userConfig.BitbucketUser,
-		BitbucketToken:     userConfig.BitbucketToken,
-		BitbucketServerURL: userConfig.BitbucketBaseURL,
+		GithubUser:           userConfig.GithubUser,
+		GithubToken:          userConfig.GithubToken,
+		GithubAppCredentials: githubAppCredentials,
+		GitlabUser:           userConfig.GitlabUser,
+		GitlabToken:          userConfig.GitlabToken,
+		BitbucketUser:        userConfig.BitbucketUser,
+		BitbucketToken:       userConfig.BitbucketToken,
+		BitbucketServerURL:   userConfig.BitbucketBaseURL,
+		BitbucketTokenType:   userConfig.BitbucketTokenType,
+		AzureDevopsUser:      userConfig.AzureDevopsUser,
+		AzureDevopsToken:     userConfig.AzureDevopsToken,
+		GiteaUser:            userConfig.GiteaUser,
+		GiteaToken:           userConfig.GiteaToken,
 	}
 	commentParser := &events.CommentParser{
-		GithubUser:  userConfig.GithubUser,
-		GithubToken: userConfig.GithubToken,
-		GitlabUser:  userConfig.GitlabUser,
-		GitlabToken: userConfig.GitlabToken,
+		GithubUser:      userConfig.GithubUser,
+		GithubToken:     userConfig.GithubToken,
+		GitlabUser:      userConfig.GitlabUser,
+		GitlabToken:     userConfig.GitlabToken,
+		AzureDevopsUser: userConfig.AzureDevopsUser,
+		GiteaUser:       userConfig.GiteaUser,
+		ExecutableName:  userConfig.ExecutableName,
+		// Zero-valued parsers allow -target so the flag is inverted.
+		DisableTargetFlag: !userConfig.AllowTargetFlag,
+		DisableExtraArgs:  userConfig.DisableExtraArgs,
+	}
+	parserValidator := &yaml.ParserValidator{}
+	var serverConfig valid.ServerConfig
+	if userConfig.RepoConfig != "" {
+		serverConfig, err = parserValidator.ReadServerConfig(userConfig.RepoConfig)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading --%s", config.RepoConfigFlag)
+		}
+		parserValidator.ServerWorkflows = serverConfig.WorkflowNames()
 	}
 	defaultTfVersion := terraformClient.Version()
+	// The level has already been validated so we just need it upper-cased.
+	tfLogLevel, _ := terraform.NormalizeLogLevel(userConfig.TFLogLevel)
+	projectFinder := &events.DefaultProjectFinder{
+		EnableTerragrunt:    userConfig.EnableTerragrunt,
+		AutoplanFileList:    userConfig.AutoplanFileList,
+		AutodiscoverInclude: userConfig.ToAutodiscoverInclude(),
+		AutodiscoverExclude: userConfig.ToAutodiscoverExclude(),
+	}
+	projectCommandBuilder := &events.DefaultProjectCommandBuilder{
+		ParserValidator:       parserValidator,
+		ServerConfig:          serverConfig,
+		MaxProjectsPerCommand: userConfig.MaxProjectsPerCommand,
+		ProjectFinder:         projectFinder,
+		VCSClient:             vcsClient,
+		WorkingDir:            workingDir,
+		WorkingDirLocker:      workingDirLocker,
+		AllowRepoConfig:       userConfig.AllowRepoConfig,
+		AllowRepoConfigFlag:   config.AllowRepoConfigFlag,
+		PendingPlanFinder:     &events.PendingPlanFinder{},
+		CommentBuilder:        commentParser,
+		TFLogLevel:            tfLogLevel,
+		EnableTerragrunt:      userConfig.EnableTerragrunt,
+		ProjectDirs:           userConfig.ToProjectDirs(),
+	}
+	projectCommandRunner := &events.DefaultProjectCommandRunner{
+		ExecutableName:   userConfig.ExecutableName,
+		Locker:           projectLocker,
+		LockURLGenerator: router,
+		InitStepRunner: &runtime.InitStepRunner{
+			TerraformExecutor: terraformClient,
+			DefaultTFVersion:  defaultTfVersion,
+		},
+		PlanStepRunner: &runtime.PlanStepRunner{
+			TerraformExecutor: terraformClient,
+			DefaultTFVersion:  defaultTfVersion,
+		},
+		ApplyStepRunner: &runtime.ApplyStepRunner{
+			TerraformExecutor: terraformClient,
+			RemoteRuns:        remoteRuns,
+		},
+		ImportStepRunner: &runtime.ImportStepRunner{
+			TerraformExecutor: terraformClient,
+			DefaultTFVersion:  defaultTfVersion,
+		},
+		StateStepRunner: &runtime.StateStepRunner{
+			TerraformExecutor: terraformClient,
+			DefaultTFVersion:  defaultTfVersion,
+		},
+		RunStepRunner: &runtime.RunStepRunner{
+			DefaultTFVersion: defaultTfVersion,
+		},
+		TerragruntStepRunner: &runtime.TerragruntStepRunner{
+			TerragruntExecutor: terraformClient,
+			DefaultTFVersion:   defaultTfVersion,
+		},
+		PolicyCheckStepRunner: &runtime.PolicyCheckStepRunner{
+			TerraformExecutor: terraformClient,
+			DefaultTFVersion:  defaultTfVersion,
+		},
+		ShowStepRunner: &runtime.ShowStepRunner{
+			TerraformExecutor: terraformClient,
+			DefaultTFVersion:  defaultTfVersion,
+		},
+		PullApprovedChecker:      vcsClient,
+		PullMergeableChecker:     vcsClient,
+		WorkingDir:               workingDir,
+		Webhooks:                 webhooksManager,
+		WorkingDirLocker:         workingDirLocker,
+		RequireApprovalOverride:  userConfig.RequireApproval,
+		RequireMergeableOverride: userConfig.RequireMergeable,
+		DestroyThreshold:         userConfig.DestroyThreshold,
+		TerraformCloudRuns:       terraformCloudRuns,
+	}
+	// terraformClient is only nil in unit tests, and then we want the field to
+	// be a nil interface rather than an interface holding a nil pointer.
+	if terraformClient != nil {
+		projectCommandRunner.TerraformVersionResolver = terraformClient
+	}
+	if userConfig.EnableCostEstimation {
+		projectCommandRunner.CostEstimateStepRunner = &runtime.CostEstimateStepRunner{
+			TerraformExecutor: terraformClient,
+			DefaultTFVersion:  defaultTfVersion,
+		}
+	}
+	if store := newArtifactStore(userConfig, outboundHTTPClient); store != nil {
+		planArtifacts := &events.PlanArtifacts{Store: store, WorkingDir: workingDir}
+		projectCommandRunner.PlanArtifacts = planArtifacts
+		projectCommandBuilder.PlanArtifacts = planArtifacts
+	}
+	projectCommandRunner.JobTracker = jobTracker
+	projectCommandRunner.PlanStore = database
+	projectCommandRunner.PlanURLGenerator = router
+	drainer := &events.Drainer{}
+	interruptedJobRecoverer := &events.InterruptedJobRecoverer{
+		Store:               database,
+		Instance:            recoverInstance,
+		WorkingDir:          workingDir,
+		VCSClient:           vcsClient,
+		CommitStatusUpdater: commitStatusUpdater,
+		ExecutableName:      userConfig.ExecutableName,
+		Logger:              logger,
+	}
 	commandRunner := &events.DefaultCommandRunner{
+		Drainer:                  drainer,
 		VCSClient:                vcsClient,
 		GithubPullGetter:         githubClient,
 		GitlabMergeRequestGetter: gitlabClient,
+		GiteaPullGetter:          giteaClient,
 		CommitStatusUpdater:      commitStatusUpdater,
 		EventParser:              eventParser,
 		MarkdownRenderer:         markdownRenderer,
 		Logger:                   logger,
 		AllowForkPRs:             userConfig.AllowForkPRs,
 		AllowForkPRsFlag:         config.AllowForkPRsFlag,
-		ProjectCommandBuilder: &events.DefaultProjectCommandBuilder{
-			ParserValidator:     &yaml.ParserValidator{},
-			ProjectFinder:       &events.DefaultProjectFinder{},
-			VCSClient:           vcsClient,
-			WorkingDir:          workingDir,
-			WorkingDirLocker:    workingDirLocker,
-			AllowRepoConfig:     userConfig.AllowRepoConfig,
-			AllowRepoConfigFlag: config.AllowRepoConfigFlag,
-			PendingPlanFinder:   &events.PendingPlanFinder{},
-			CommentBuilder:      commentParser,
+		CommandCanceller:         events.NewDefaultCommandCanceller(),
+		PullUnlocker: &events.DefaultPullUnlocker{
+			Locker:            lockingClient,
+			WorkingDir:        workingDir,
+			WorkingDirLocker:  workingDirLocker,
+			LockQueueNotifier: lockQueueNotifier,
 		},
-		ProjectCommandRunner: &events.DefaultProjectCommandRunner{
-			Locker:           projectLocker,
-			LockURLGenerator: router,
-			InitStepRunner: &runtime.InitStepRunner{
-				TerraformExecutor: terraformClient,
-				DefaultTFVersion:  defaultTfVersion,
-			},
-			PlanStepRunner: &runtime.PlanStepRunner{
-				TerraformExecutor: terraformClient,
-				DefaultTFVersion:  defaultTfVersion,
-			},
-			ApplyStepRunner: &runtime.ApplyStepRunner{
-				TerraformExecutor: terraformClient,
-			},
-			RunStepRunner: &runtime.RunStepRunner{
-				DefaultTFVersion: defaultTfVersion,
-			},
-			PullApprovedChecker:      vcsClient,
-			PullMergeableChecker:     vcsClient,
-			WorkingDir:               workingDir,
-			Webhooks:                 webhooksManager,
-			WorkingDirLocker:         workingDirLocker,
-			RequireApprovalOverride:  userConfig.RequireApproval,
-			RequireMergeableOverride: userConfig.RequireMergeable,
+		ParallelPoolSize:      userConfig.ParallelPoolSize,
+		Metrics:               serverMetrics,
+		ProjectCommandBuilder: projectCommandBuilder,
+		ProjectCommandRunner:  projectCommandRunner,
+		HidePrevPlanComments:  userConfig.HidePrevPlanComments,
+		ApplyLocker:           lockingClient,
+		ExecutableName:        userConfig.ExecutableName,
+		ApplyPermissionChecker: &events.DefaultApplyPermissionChecker{
+			AllowApplyFrom:    userConfig.ToAllowApplyFrom(),
+			ServerConfig:      serverConfig,
+			GithubTeamGetter:  githubClient,
+			GitlabGroupGetter: gitlabClient,
+		},
+		WorkflowHooksRunner: &events.DefaultWorkflowHooksRunner{
+			ServerConfig:     serverConfig,
+			WorkingDir:       workingDir,
+			WorkingDirLocker: workingDirLocker,
+			HookRunner:       &runtime.WorkflowHookRunner{},
+		},
+		DryRun:       userConfig.DryRun,
+		ServerConfig: serverConfig,
+		Automerger: &events.Automerger{
+			VCSClient:          vcsClient,
+			WorkingDir:         workingDir,
+			PendingPlanFinder:  &events.PendingPlanFinder{},
+			Enabled:            userConfig.Automerge,
+			MergeMethod:        userConfig.AutomergeMethod,
+			DeleteSourceBranch: userConfig.AutomergeDeleteSourceBranch,
 		},
+		AutoplanStore:            database,
+		RequireApplyConfirmation: userConfig.RequireApplyConfirmation,
+		ConfirmationStore:        database,
+	}
+	// The notifier re-plans the pulls it gives locks to so it needs the
+	// command runner, which is built from things that need the notifier.
+	lockQueueNotifier.CommandRunner = commandRunner
+	// Unless this process runs everything itself, the commands in webhooks
+	// are queued for the workers.
+	var webhookCommandRunner events.CommandRunner = commandRunner
+	if userConfig.Role != "" && userConfig.Role != workqueue.AllRole {
+		webhookCommandRunner = &events.QueuedCommandRunner{Queue: database, Logger: logger}
+	}
+	var webhookLimiter *events.WebhookLimiter
+	if userConfig.WebhookRateLimit > 0 || userConfig.MaxQueuedCommands > 0 {
+		webhookLimiter = &events.WebhookLimiter{
+			RepoRatePerMinute: userConfig.WebhookRateLimit,
+			MaxQueueDepth:     userConfig.MaxQueuedCommands,
+			QueueDepth:        drainer.Running,
+		}
+		if _, ok := webhookCommandRunner.(*events.QueuedCommandRunner); ok {
+			webhookLimiter.QueueDepth = func() int {
+				items, err := database.ListWork()
+				if err != nil {
+					// We'd rather accept webhooks than reject them all
+					// while the database is unavailable.
+					logger.Err("unable to get work queue depth: %s", err)
+					return 0
+				}
+				return len(items)
+			}
+		}
+	}
+	var workQueueWorkers *events.WorkQueueWorkers
+	if userConfig.Role == workqueue.WorkerRole {
+		workQueueWorkers = &events.WorkQueueWorkers{
+			Queue:         database,
+			CommandRunner: commandRunner,
+			Name:          hostname,
+			Count:         userConfig.WorkerCount,
+			PollInterval:  events.DefaultWorkQueuePollInterval,
+			ClaimTimeout:  userConfig.ToWorkerClaimTimeout(),
+			Logger:        logger,
+		}
 	}
 	repoWhitelist, err := events.NewRepoWhitelistChecker(userConfig.RepoWhitelist)
 	if err != nil {
 		return nil, err
 	}
+	projectCommandRunner.ExtraRepoCloner = &events.ExtraRepoCloner{
+		Resolver: &cloneHostResolver{
+			hosts:     newCloneHosts(userConfig),
+			parser:    eventParser,
+			whitelist: repoWhitelist,
+		},
+	}
+	var driftScheduler *events.DriftScheduler
+	if userConfig.DriftDetectionCron != "" {
+		schedule, err := cron.Parse(userConfig.DriftDetectionCron)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing --%s", config.DriftDetectionCronFlag)
+		}
+		driftRepos, err := newDriftRepos(userConfig, serverConfig, repoWhitelist)
+		if err != nil {
+			return nil, err
+		}
+		if len(driftRepos) == 0 {
+			logger.Warn("--%s is set but no repos enable drift_detection in --%s", config.DriftDetectionCronFlag, config.RepoConfigFlag)
+		}
+		issueClients := make(map[models.VCSHostType]events.IssueCreator)
+		if githubClient != nil {
+			issueClients[models.Github] = githubClient
+		}
+		if gitlabClient != nil {
+			issueClients[models.Gitlab] = gitlabClient
+		}
+		driftScheduler = &events.DriftScheduler{
+			Schedule: schedule,
+			Repos:    driftRepos,
+			Detector: &events.DriftDetector{
+				DataDir:               userConfig.DataDir,
+				ProjectCommandBuilder: projectCommandBuilder,
+				ProjectCommandRunner:  projectCommandRunner,
+			},
+			Parser: eventParser,
+			Notifiers: map[string]events.DriftNotifier{
+				valid.VCSIssueDriftNotifier: &events.VCSIssueDriftNotifier{Clients: issueClients},
+				valid.SlackDriftNotifier:    &events.SlackDriftNotifier{Slack: slack.New(userConfig.SlackToken)},
+				valid.WebhookDriftNotifier:  &events.WebhookDriftNotifier{HTTPClient: outboundHTTPClient},
+			},
+			Logger: logger,
+		}
+	}
+	var workingDirGC *events.WorkingDirGC
+	if userConfig.WorkingDirTTL != "" || userConfig.DataDirMaxGB > 0 {
+		workingDirGC = &events.WorkingDirGC{
+			WorkingDir:       workingDir,
+			WorkingDirLocker: workingDirLocker,
+			Locker:           lockingClient,
+			TTL:              userConfig.ToWorkingDirTTL(),
+			MaxBytes:         int64(userConfig.DataDirMaxGB) << 30,
+			Logger:           logger,
+		}
+	}
 	locksController := &LocksController{
 		AtlantisVersion:    config.AtlantisVersion,
 		AtlantisURL:        parsedURL,
@@ -280,10 +702,51 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		LockDetailTemplate: lockTemplate,
 		WorkingDir:         workingDir,
 		WorkingDirLocker:   workingDirLocker,
+		LockQueueNotifier:  lockQueueNotifier,
+		ApplyLocker:        lockingClient,
+	}
+	apiController := &APIController{
+		APISecret:             []byte(userConfig.APISecret),
+		Locker:                lockingClient,
+		ApplyLocker:           lockingClient,
+		Logger:                logger,
+		Parser:                eventParser,
+		ProjectCommandBuilder: projectCommandBuilder,
+		ProjectCommandRunner:  projectCommandRunner,
+		RepoWhitelistChecker:  repoWhitelist,
+		CloneHosts:            newCloneHosts(userConfig),
+		AuditLog:              auditLog,
+		DryRun:                userConfig.DryRun,
+		ServerConfig:          serverConfig,
+	}
+	if auditLog != nil {
+		projectCommandRunner.AuditRecorder = auditLog
+		commandRunner.AuditRecorder = auditLog
+		locksController.AuditRecorder = auditLog
+	}
+	jobsController := &JobsController{
+		AtlantisVersion:   config.AtlantisVersion,
+		AtlantisURL:       parsedURL,
+		Jobs:              jobTracker,
+		Logger:            logger,
+		JobsTemplate:      jobsTemplate,
+		JobDetailTemplate: jobDetailTemplate,
+	}
+	plansController := &PlansController{
+		AtlantisVersion:    config.AtlantisVersion,
+		AtlantisURL:        parsedURL,
+		PlanStore:          database,
+		Logger:             logger,
+		PlanDetailTemplate: planDetailTemplate,
 	}
 	eventsController := &EventsController{
-		CommandRunner:                commandRunner,
-		PullCleaner:                  pullClosedExecutor,
+		CommandRunner: webhookCommandRunner,
+		PullCleaner:   pullClosedExecutor,
+		StalePlanDiscarder: &events.DefaultStalePlanDiscarder{
+			WorkingDir:          workingDir,
+			WorkingDirLocker:    workingDirLocker,
+			CommitStatusUpdater: commitStatusUpdater,
+		},
 		Parser:                       eventParser,
 		CommentParser:                commentParser,
 		Logger:                       logger,
@@ -296,43 +759,144 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		SupportedVCSHosts:            supportedVCSHosts,
 		VCSClient:                    vcsClient,
 		BitbucketWebhookSecret:       []byte(userConfig.BitbucketWebhookSecret),
+		AzureDevopsWebhookPassword:   []byte(userConfig.AzureDevopsWebhookPassword),
+		GiteaWebhookSecret:           []byte(userConfig.GiteaWebhookSecret),
+		DisableAutoplanLabels:        userConfig.DisableAutoplanLabels(),
+		AutoplanStore:                database,
+		SkipDraftPRs:                 userConfig.SkipDraftPRs || !userConfig.PlanDrafts,
+		ServerConfig:                 serverConfig,
+		Metrics:                      serverMetrics,
+		DeliveryDeduplicator:         &events.DeliveryDeduplicator{},
+		WebhookLimiter:               webhookLimiter,
+	}
+	var authenticator *oidc.Authenticator
+	if userConfig.WebOIDCIssuer != "" {
+		authenticator, err = oidc.New(oidc.Config{
+			Issuer:       userConfig.WebOIDCIssuer,
+			ClientID:     userConfig.WebOIDCClientID,
+			ClientSecret: userConfig.WebOIDCClientSecret,
+			AtlantisURL:  parsedURL,
+			AdminGroups:  userConfig.ToWebOIDCAdminGroups(),
+			Logger:       logger,
+			HTTPClient:   outboundHTTPClient,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "setting up OIDC login")
+		}
+	}
+	var basicAuth *BasicAuth
+	if userConfig.WebBasicAuth {
+		basicAuth = &BasicAuth{Username: userConfig.WebUsername, Password: userConfig.WebPassword}
+	}
+	var webhookIPAllowlist *WebhookIPAllowlist
+	if userConfig.WebhookAllowIPs != "" {
+		allowed, err := ParseCIDRs(userConfig.WebhookAllowIPs)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing webhook allowed IPs")
+		}
+		trustedProxies, err := ParseCIDRs(userConfig.WebhookTrustedProxies)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing webhook trusted proxies")
+		}
+		webhookIPAllowlist = &WebhookIPAllowlist{Allowed: allowed, TrustedProxies: trustedProxies, Logger: logger}
 	}
 	return &Server{
-		AtlantisVersion:    config.AtlantisVersion,
-		AtlantisURL:        parsedURL,
-		Router:             underlyingRouter,
-		Port:               userConfig.Port,
-		CommandRunner:      commandRunner,
-		Logger:             logger,
-		Locker:             lockingClient,
-		EventsController:   eventsController,
-		LocksController:    locksController,
-		IndexTemplate:      indexTemplate,
-		LockDetailTemplate: lockTemplate,
-		SSLKeyFile:         userConfig.SSLKeyFile,
-		SSLCertFile:        userConfig.SSLCertFile,
+		AtlantisVersion:         config.AtlantisVersion,
+		AtlantisURL:             parsedURL,
+		Router:                  underlyingRouter,
+		Port:                    userConfig.Port,
+		CommandRunner:           commandRunner,
+		Logger:                  logger,
+		Locker:                  lockingClient,
+		ApplyLocker:             lockingClient,
+		EventsController:        eventsController,
+		LocksController:         locksController,
+		JobsController:          jobsController,
+		PlansController:         plansController,
+		APIController:           apiController,
+		IndexTemplate:           indexTemplate,
+		LockDetailTemplate:      lockTemplate,
+		Metrics:                 serverMetrics,
+		DriftScheduler:          driftScheduler,
+		WorkingDirGC:            workingDirGC,
+		WorkQueueWorkers:        workQueueWorkers,
+		Drainer:                 drainer,
+		DrainTimeout:            userConfig.ToDrainTimeout(),
+		JobTracker:              jobTracker,
+		InterruptedJobRecoverer: interruptedJobRecoverer,
+		Authenticator:           authenticator,
+		BasicAuth:               basicAuth,
+		WebhookIPAllowlist:      webhookIPAllowlist,
+		SSLKeyFile:              userConfig.SSLKeyFile,
+		SSLCertFile:             userConfig.SSLCertFile,
 	}, nil
 }
 
 // Start creates the routes and starts serving traffic.
 func (s *Server) Start() error {
-	s.Router.HandleFunc("/", s.Index).Methods("GET").MatcherFunc(func(r *http.Request, rm *mux.RouteMatch) bool {
+	s.Router.HandleFunc("/", s.requireLogin(s.Index)).Methods("GET").MatcherFunc(func(r *http.Request, rm *mux.RouteMatch) bool {
 		return r.URL.Path == "/" || r.URL.Path == "/index.html"
 	})
 	s.Router.HandleFunc("/healthz", s.Healthz).Methods("GET")
 	s.Router.PathPrefix("/static/").Handler(http.FileServer(&assetfs.AssetFS{Asset: static.Asset, AssetDir: static.AssetDir, AssetInfo: static.AssetInfo}))
 	s.Router.HandleFunc("/events", s.EventsController.Post).Methods("POST")
-	s.Router.HandleFunc("/locks", s.LocksController.DeleteLock).Methods("DELETE").Queries("id", "{id:.*}")
-	s.Router.HandleFunc("/lock", s.LocksController.GetLock).Methods("GET").
+	s.Router.HandleFunc("/locks", s.requireAdmin(s.LocksController.DeleteLock)).Methods("DELETE").Queries("id", "{id:.*}")
+	s.Router.HandleFunc("/lock", s.requireLogin(s.LocksController.GetLock)).Methods("GET").
 		Queries(LockViewRouteIDQueryParam, fmt.Sprintf("{%s}", LockViewRouteIDQueryParam)).Name(LockViewRouteName)
+	s.Router.HandleFunc("/applies/lock", s.requireAdmin(s.LocksController.LockApplies)).Methods("POST")
+	s.Router.HandleFunc("/applies/lock", s.requireAdmin(s.LocksController.UnlockApplies)).Methods("DELETE")
+	s.Router.HandleFunc("/jobs", s.requireLogin(s.JobsController.ListJobs)).Methods("GET")
+	s.Router.HandleFunc("/jobs/{id}", s.requireLogin(s.JobsController.GetJob)).Methods("GET")
+	s.Router.HandleFunc("/jobs/{id}/output", s.requireLogin(s.JobsController.GetJobOutput)).Methods("GET")
+	s.Router.HandleFunc("/jobs/{id}/ws", s.requireLogin(s.JobsController.JobOutputWebsocket)).Methods("GET")
+	s.Router.HandleFunc("/plans/{id}", s.requireLogin(s.PlansController.GetPlan)).Methods("GET")
+	if s.Authenticator != nil {
+		s.Router.HandleFunc(oidc.LoginPath, s.Authenticator.Login).Methods("GET")
+		s.Router.HandleFunc(oidc.CallbackPath, s.Authenticator.Callback).Methods("GET")
+		s.Router.HandleFunc(oidc.LogoutPath, s.Authenticator.Logout).Methods("GET")
+	}
+	s.Router.HandleFunc("/api/plan", s.APIController.Plan).Methods("POST")
+	s.Router.HandleFunc("/api/apply", s.APIController.Apply).Methods("POST")
+	s.Router.HandleFunc("/api/locks/global", s.APIController.LockApplies).Methods("POST")
+	s.Router.HandleFunc("/api/locks/global", s.APIController.UnlockApplies).Methods("DELETE")
+	s.Router.HandleFunc("/api/audit", s.APIController.ListAuditEvents).Methods("GET")
+	if s.Metrics != nil {
+		s.Router.Handle("/metrics", s.Metrics).Methods("GET")
+	}
 	n := negroni.New(&negroni.Recovery{
 		Logger:     log.New(os.Stdout, "", log.LstdFlags),
 		PrintStack: false,
 		StackAll:   false,
 		StackSize:  1024 * 8,
 	}, NewRequestLogger(s.Logger))
+	if s.WebhookIPAllowlist != nil {
+		n.Use(s.WebhookIPAllowlist)
+	}
+	if s.BasicAuth != nil {
+		n.Use(s.BasicAuth)
+	}
 	n.UseHandler(s.Router)
 
+	// Recover before starting anything that runs jobs, otherwise we could
+	// mistake the jobs we start for interrupted ones.
+	if s.InterruptedJobRecoverer != nil {
+		if err := s.InterruptedJobRecoverer.Recover(); err != nil {
+			s.Logger.Err("unable to recover interrupted jobs: %s", err)
+		}
+	}
+
+	if s.DriftScheduler != nil {
+		s.DriftScheduler.Start()
+		defer s.DriftScheduler.Stop()
+	}
+	if s.WorkingDirGC != nil {
+		s.WorkingDirGC.Start()
+		defer s.WorkingDirGC.Stop()
+	}
+	if s.WorkQueueWorkers != nil {
+		s.WorkQueueWorkers.Start()
+	}
+
 	// Ensure server gracefully drains connections when stopped.
 	stop := make(chan os.Signal, 1)
 	// Stop on SIGINTs and SIGTERMs.
@@ -356,13 +920,192 @@ func (s *Server) Start() error {
 	<-stop
 
 	s.Logger.Warn("Received interrupt. Safely shutting down")
-	ctx, _ := context.WithTimeout(context.Background(), 5*time.Second) // nolint: vet
-	if err := server.Shutdown(ctx); err != nil {
-		return cli.NewExitError(fmt.Sprintf("while shutting down: %s", err), 1)
+	ctx, cancel := context.WithTimeout(context.Background(), s.DrainTimeout)
+	defer cancel()
+	// We stop accepting webhooks first, waiting for the requests in
+	// progress, which includes API plans and applies, and then wait for the
+	// commands the webhooks started.
+	shutdownErr := server.Shutdown(ctx)
+	// Workers stop claiming work so the drain below waits for the commands
+	// they're running.
+	if s.WorkQueueWorkers != nil {
+		s.WorkQueueWorkers.Stop()
+	}
+	if s.Drainer != nil {
+		s.Logger.Info("waiting up to %s for %d running commands to finish", s.DrainTimeout, s.Drainer.Running())
+		if !s.Drainer.Drain(ctx) {
+			s.Logger.Warn("%d commands were still running after %s", s.Drainer.Running(), s.DrainTimeout)
+		}
+	}
+	if s.JobTracker != nil {
+		if n := s.JobTracker.Interrupt("Atlantis shut down before this job finished."); n > 0 {
+			s.Logger.Warn("saved %d unfinished jobs as failed", n)
+		}
+	}
+	if shutdownErr != nil {
+		return cli.NewExitError(fmt.Sprintf("while shutting down: %s", shutdownErr), 1)
 	}
 	return nil
 }
 
+// CloneHost is a VCS host we can clone repos from without having received a
+// webhook for them. Repo full names are appended to its BaseURL to get their
+// clone URLs.
+type CloneHost struct {
+	HostType models.VCSHostType
+	BaseURL  string
+}
+
+// newCloneHosts returns the VCS hosts we can clone from, keyed by hostname.
+// Bitbucket Server and Azure DevOps aren't included since their clone URLs
+// can't be built from the repo name.
+func newCloneHosts(userConfig UserConfig) map[string]CloneHost {
+	hosts := make(map[string]CloneHost)
+	addHost := func(hostType models.VCSHostType, baseURL string) {
+		if !strings.Contains(baseURL, "://") {
+			baseURL = "https://" + baseURL
+		}
+		if u, err := url.Parse(baseURL); err == nil {
+			hosts[strings.ToLower(u.Host)] = CloneHost{hostType, strings.TrimSuffix(baseURL, "/")}
+		}
+	}
+	if userConfig.GithubUser != "" || userConfig.GithubAppID != 0 {
+		addHost(models.Github, userConfig.GithubHostname)
+	}
+	if userConfig.GitlabUser != "" {
+		addHost(models.Gitlab, userConfig.GitlabHostname)
+	}
+	if userConfig.BitbucketUser != "" && userConfig.BitbucketBaseURL == bitbucketcloud.BaseURL {
+		addHost(models.BitbucketCloud, "bitbucket.org")
+	}
+	if userConfig.GiteaUser != "" {
+		addHost(models.Gitea, userConfig.GiteaBaseURL)
+	}
+	return hosts
+}
+
+// newArtifactStore returns the store plans are exported to, or nil if
+// --artifact-backend isn't set. The flags have already been validated.
+func newArtifactStore(userConfig UserConfig, httpClient *http.Client) artifacts.Store {
+	switch userConfig.ArtifactBackend {
+	case artifacts.S3Backend:
+		return &artifacts.S3Store{
+			Bucket:      userConfig.ArtifactBucket,
+			Region:      userConfig.ArtifactS3Region,
+			Prefix:      userConfig.ArtifactPrefix,
+			Endpoint:    userConfig.ArtifactS3Endpoint,
+			Credentials: &artifacts.S3EnvCredentials{Region: userConfig.ArtifactS3Region, HTTPClient: httpClient},
+			HTTPClient:  httpClient,
+		}
+	case artifacts.GCSBackend:
+		return &artifacts.GCSStore{
+			Bucket:     userConfig.ArtifactBucket,
+			Prefix:     userConfig.ArtifactPrefix,
+			Tokens:     &artifacts.GCSDefaultTokens{HTTPClient: httpClient},
+			HTTPClient: httpClient,
+		}
+	}
+	return nil
+}
+
+// cloneHostResolver resolves the extra_repos of projects to repos on the VCS
+// hosts we can clone from, with our credentials for them.
+type cloneHostResolver struct {
+	hosts     map[string]CloneHost
+	parser    *events.EventParser
+	whitelist *events.RepoWhitelistChecker
+}
+
+// Resolve returns the repo for id, ex. github.com/owner/modules. The repo
+// must be in the repo whitelist so that atlantis.yaml files can't clone any
+// repo our credentials can read.
+func (c *cloneHostResolver) Resolve(id string) (models.Repo, error) {
+	split := strings.SplitN(id, "/", 2)
+	if len(split) != 2 {
+		return models.Repo{}, fmt.Errorf("repository %q isn't a hostname and repo name, ex. github.com/owner/repo", id)
+	}
+	hostname, fullName := strings.ToLower(split[0]), split[1]
+	host, ok := c.hosts[hostname]
+	if !ok {
+		return models.Repo{}, fmt.Errorf("Atlantis isn't configured to clone from %s: only GitHub, GitLab, Bitbucket Cloud and Gitea repos are supported", hostname)
+	}
+	if !c.whitelist.IsWhitelisted(fullName, hostname) {
+		return models.Repo{}, fmt.Errorf("repository %q isn't in the repo whitelist", id)
+	}
+	return c.parser.ParseRepoCloneURL(host.HostType, fullName, fmt.Sprintf("%s/%s.git", host.BaseURL, fullName))
+}
+
+// setGitEnv configures git, which we clone with and which terraform downloads
+// git modules with, through its environment since it doesn't use our HTTP
+// clients. git only trusts a single CA bundle so it's given --vcs-ca-file, or
+// --ssl-ca-file if that isn't set.
+func setGitEnv(userConfig UserConfig) error {
+	caFile := userConfig.VCSCAFile
+	if caFile == "" {
+		caFile = userConfig.SSLCAFile
+	}
+	env := map[string]string{
+		"GIT_SSL_CERT":   userConfig.VCSTLSCertFile,
+		"GIT_SSL_KEY":    userConfig.VCSTLSKeyFile,
+		"GIT_SSL_CAINFO": caFile,
+	}
+	if userConfig.VCSProxyURL != "" {
+		// git reads extra config from GIT_CONFIG_KEY_<n> and
+		// GIT_CONFIG_VALUE_<n> for n below GIT_CONFIG_COUNT, so we add ours
+		// after any that are already set.
+		count, _ := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT")) // nolint: errcheck
+		env[fmt.Sprintf("GIT_CONFIG_KEY_%d", count)] = "http.proxy"
+		env[fmt.Sprintf("GIT_CONFIG_VALUE_%d", count)] = userConfig.VCSProxyURL
+		env["GIT_CONFIG_COUNT"] = strconv.Itoa(count + 1)
+	}
+	for name, val := range env {
+		if val == "" {
+			continue
+		}
+		if err := os.Setenv(name, val); err != nil {
+			return errors.Wrapf(err, "setting %s", name)
+		}
+	}
+	return nil
+}
+
+// newDriftRepos returns the repos that enable drift detection in the
+// server-side repo config. It errors if any of them can't be cloned or
+// notified with the VCS hosts and tokens we've been configured with.
+func newDriftRepos(userConfig UserConfig, serverConfig valid.ServerConfig, repoWhitelist *events.RepoWhitelistChecker) ([]events.DriftRepo, error) {
+	hosts := newCloneHosts(userConfig)
+	var repos []events.DriftRepo
+	for _, r := range serverConfig.DriftDetectionRepos() {
+		split := strings.SplitN(r.ID, "/", 2)
+		if len(split) != 2 {
+			return nil, fmt.Errorf("drift detection is enabled for %q but it isn't a hostname and repo name, ex. github.com/owner/repo", r.ID)
+		}
+		hostname, fullName := strings.ToLower(split[0]), split[1]
+		host, ok := hosts[hostname]
+		if !ok {
+			return nil, fmt.Errorf("drift detection is enabled for %q but Atlantis isn't configured to clone from %s: only GitHub, GitLab, Bitbucket Cloud and Gitea repos are supported", r.ID, hostname)
+		}
+		if !repoWhitelist.IsWhitelisted(fullName, hostname) {
+			return nil, fmt.Errorf("drift detection is enabled for %q but it isn't in the repo whitelist", r.ID)
+		}
+		for _, n := range r.DriftDetection.Notifiers {
+			if n.Kind == valid.SlackDriftNotifier && userConfig.SlackToken == "" {
+				return nil, fmt.Errorf("drift detection for %q has a slack notifier but no Slack token is set", r.ID)
+			}
+			if n.Kind == valid.VCSIssueDriftNotifier && host.HostType != models.Github && host.HostType != models.Gitlab {
+				return nil, fmt.Errorf("drift detection for %q has a vcs_issue notifier but issues can only be opened on GitHub and GitLab repos", r.ID)
+			}
+		}
+		repos = append(repos, events.DriftRepo{
+			VCSHostType:  host.HostType,
+			RepoFullName: fullName,
+			CloneURL:     fmt.Sprintf("%s/%s.git", host.BaseURL, fullName),
+			Notifiers:    r.DriftDetection.Notifiers,
+		})
+	}
+	return repos, nil
+}
+
 // Index is the / route.
 func (s *Server) Index(w http.ResponseWriter, _ *http.Request) {
 	locks, err := s.Locker.List()
@@ -384,8 +1127,21 @@ func (s *Server) Index(w http.ResponseWriter, _ *http.Request) {
 			Time:         v.Time,
 		})
 	}
+	var applyLock ApplyLockIndexData
+	if s.ApplyLocker != nil {
+		lock, err := s.ApplyLocker.GetApplyLock()
+		if err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintf(w, "Could not retrieve apply lock: %s", err)
+			return
+		}
+		if lock != nil {
+			applyLock = ApplyLockIndexData{Locked: true, Time: lock.Time}
+		}
+	}
 	err = s.IndexTemplate.Execute(w, IndexData{
 		Locks:           lockResults,
+		ApplyLock:       applyLock,
 		AtlantisVersion: s.AtlantisVersion,
 		CleanedBasePath: s.AtlantisURL.Path,
 	})
@@ -394,6 +1150,24 @@ func (s *Server) Index(w http.ResponseWriter, _ *http.Request) {
 	}
 }
 
+// requireLogin wraps h so that users must be logged in to use it, if OIDC
+// login is enabled.
+func (s *Server) requireLogin(h http.HandlerFunc) http.HandlerFunc {
+	if s.Authenticator == nil {
+		return h
+	}
+	return s.Authenticator.RequireLogin(h)
+}
+
+// requireAdmin wraps h, a destructive action, so that users must be logged in
+// and in an admin group to use it, if OIDC login is enabled.
+func (s *Server) requireAdmin(h http.HandlerFunc) http.HandlerFunc {
+	if s.Authenticator == nil {
+		return h
+	}
+	return s.Authenticator.RequireAdmin(h)
+}
+
 // Healthz returns the health check response. It always returns a 200 currently.
 func (s *Server) Healthz(w http.ResponseWriter, _ *http.Request) {
 	data, err := json.MarshalIndent(&struct {
@@ -410,6 +1184,22 @@ func (s *Server) Healthz(w http.ResponseWriter, _ *http.Request) {
 	w.Write(data) // nolint: errcheck
 }
 
+// downloadTerraformVersions downloads each of versions so they're ready
+// before we serve any requests. Failures are logged rather than returned
+// because a missing version only affects projects that use it.
+func downloadTerraformVersions(logger *logging.SimpleLogger, client *terraform.DefaultClient, versions []string) {
+	for _, v := range versions {
+		parsed, err := version.NewVersion(v)
+		if err != nil {
+			logger.Warn("not downloading terraform %q: %s", v, err)
+			continue
+		}
+		if err := client.EnsureVersion(logger, parsed); err != nil {
+			logger.Warn("unable to download terraform %s, continuing: %s", v, err)
+		}
+	}
+}
+
 // ParseAtlantisURL parses the user-passed atlantis URL to ensure it is valid
 // and we can use it in our templates.
 // It removes any trailing slashes from the path so we can concatenate it
@@ -1,42 +1,401 @@
 package server
 
-import "github.com/runatlantis/atlantis/server/logging"
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/terraform"
+	"github.com/runatlantis/atlantis/server/logging"
+)
 
 // UserConfig holds config values passed in by the user.
 // The mapstructure tags correspond to flags in cmd/server.go and are used when
 // the config is parsed from a YAML file.
 type UserConfig struct {
-	AllowForkPRs           bool   `mapstructure:"allow-fork-prs"`
-	AllowRepoConfig        bool   `mapstructure:"allow-repo-config"`
-	AtlantisURL            string `mapstructure:"atlantis-url"`
-	BitbucketBaseURL       string `mapstructure:"bitbucket-base-url"`
-	BitbucketToken         string `mapstructure:"bitbucket-token"`
+	// AllowApplyFrom is a comma separated list of the teams or groups whose
+	// members can apply. If empty, anyone can apply.
+	AllowApplyFrom  string `mapstructure:"allow-apply-from"`
+	AllowForkPRs    bool   `mapstructure:"allow-fork-prs"`
+	AllowRepoConfig bool   `mapstructure:"allow-repo-config"`
+	// AllowTargetFlag is true if comments can pass -target to plan.
+	AllowTargetFlag bool   `mapstructure:"allow-target-flag"`
+	APISecret       string `mapstructure:"api-secret"`
+	// ArtifactBackend is the object store plans are exported to, see the
+	// artifacts package. If empty, plans aren't exported.
+	ArtifactBackend    string `mapstructure:"artifact-backend"`
+	ArtifactBucket     string `mapstructure:"artifact-bucket"`
+	ArtifactPrefix     string `mapstructure:"artifact-prefix"`
+	ArtifactS3Endpoint string `mapstructure:"artifact-s3-endpoint"`
+	ArtifactS3Region   string `mapstructure:"artifact-s3-region"`
+	AtlantisURL        string `mapstructure:"atlantis-url"`
+	// AuditLogFile and AuditSyslog are where else the audit log is written
+	// to, see the audit package.
+	AuditLogFile        string `mapstructure:"audit-log-file"`
+	AuditSyslog         string `mapstructure:"audit-syslog"`
+	AutodiscoverExclude string `mapstructure:"autodiscover-exclude"`
+	AutodiscoverInclude string `mapstructure:"autodiscover-include"`
+	// Automerge is true if pull requests are merged with AutomergeMethod once
+	// all of their plans are applied.
+	Automerge       bool   `mapstructure:"automerge"`
+	AutomergeMethod string `mapstructure:"automerge-method"`
+	// AutomergeDeleteSourceBranch is true if automerged pull requests'
+	// source branches are deleted.
+	AutomergeDeleteSourceBranch bool   `mapstructure:"automerge-delete-source-branch"`
+	AutoplanFileList            string `mapstructure:"autoplan-file-list"`
+	AzureDevopsToken            string `mapstructure:"azuredevops-token"`
+	AzureDevopsUser             string `mapstructure:"azuredevops-user"`
+	AzureDevopsWebhookPassword  string `mapstructure:"azuredevops-webhook-password"`
+	BitbucketBaseURL            string `mapstructure:"bitbucket-base-url"`
+	BitbucketToken              string `mapstructure:"bitbucket-token"`
+	// BitbucketTokenType is bitbucketcloud.TokenTypeAppPassword or
+	// bitbucketcloud.TokenTypeAccessToken.
+	BitbucketTokenType     string `mapstructure:"bitbucket-token-type"`
 	BitbucketUser          string `mapstructure:"bitbucket-user"`
 	BitbucketWebhookSecret string `mapstructure:"bitbucket-webhook-secret"`
-	DataDir                string `mapstructure:"data-dir"`
-	GithubHostname         string `mapstructure:"gh-hostname"`
-	GithubToken            string `mapstructure:"gh-token"`
-	GithubUser             string `mapstructure:"gh-user"`
-	GithubWebhookSecret    string `mapstructure:"gh-webhook-secret"`
-	GitlabHostname         string `mapstructure:"gitlab-hostname"`
+	// CheckoutDepth is how many commits of history to clone. 0 means all of
+	// it.
+	CheckoutDepth int    `mapstructure:"checkout-depth"`
+	CloneRoot     string `mapstructure:"clone-root"`
+	DataDir       string `mapstructure:"data-dir"`
+	// DataDirMaxGB is how many gigabytes the data dir and clone root can use
+	// before working dirs are deleted. 0 means no limit.
+	DataDirMaxGB int `mapstructure:"data-dir-max-gb"`
+	// DBConnectionString is used to connect to Postgres if DBType is
+	// postgres.
+	DBConnectionString string `mapstructure:"db-connection-string"`
+	// DBType is the type of database to store locks and jobs in, see the db
+	// package.
+	DBType string `mapstructure:"db-type"`
+	// DestroyThreshold is the number of resources a plan can destroy before
+	// we warn about it.
+	DestroyThreshold int `mapstructure:"destroy-threshold"`
+	// DisableAutoplanLabel is a comma separated list of labels that disable
+	// autoplanning when any of them are on a pull request.
+	DisableAutoplanLabel string `mapstructure:"disable-autoplan-label"`
+	// DisableExtraArgs is true if comments can't pass extra args to
+	// terraform after --.
+	DisableExtraArgs bool `mapstructure:"disable-extra-args"`
+	// DockerCPUs and DockerMemory limit the resources of terraform's
+	// containers if ExecutionMode is docker.
+	DockerCPUs   string `mapstructure:"docker-cpus"`
+	DockerMemory string `mapstructure:"docker-memory"`
+	// DockerImage is the image to run terraform in if ExecutionMode is
+	// docker.
+	DockerImage string `mapstructure:"docker-image"`
+	// DockerMounts is a comma separated list of extra volumes to mount in
+	// terraform's containers.
+	DockerMounts string `mapstructure:"docker-mounts"`
+	// DrainTimeout is how long to wait for running commands when shutting
+	// down, ex. 5m.
+	DrainTimeout string `mapstructure:"drain-timeout"`
+	// DriftDetectionCron is the cron schedule to detect drift on. If empty,
+	// drift isn't detected.
+	DriftDetectionCron string `mapstructure:"drift-detection-cron"`
+	// DryRun is true if we only plan and never apply or otherwise change
+	// state.
+	DryRun bool `mapstructure:"dry-run"`
+	// EnableAuditLog is true if we should record the commands we run to the
+	// audit log.
+	EnableAuditLog bool `mapstructure:"enable-audit-log"`
+	// EnableCloneCache is true if clones should fetch from a mirror of the
+	// repo in the data dir.
+	EnableCloneCache bool `mapstructure:"enable-clone-cache"`
+	// EnableCostEstimation is true if plans should have their costs estimated
+	// with Infracost.
+	EnableCostEstimation bool `mapstructure:"enable-cost-estimation"`
+	// EnablePrometheus is true if we should serve Prometheus metrics at
+	// /metrics.
+	EnablePrometheus bool `mapstructure:"enable-prometheus"`
+	// EnableTerragrunt is true if projects with a terragrunt.hcl file should
+	// be detected and run with terragrunt.
+	EnableTerragrunt bool `mapstructure:"enable-terragrunt"`
+	// ExecutableName is the name comments must start with to run commands,
+	// ex. atlantis plan.
+	ExecutableName string `mapstructure:"executable-name"`
+	// ExecutionMode is where terraform is run, one of
+	// terraform.ExecutionModes.
+	ExecutionMode string `mapstructure:"execution-mode"`
+	// Kubeconfig, KubernetesImage, KubernetesNamespace, KubernetesPVC and
+	// KubernetesServiceAccounts configure the Jobs terraform is run as if
+	// ExecutionMode is kubernetes.
+	Kubeconfig          string `mapstructure:"kubeconfig"`
+	KubernetesImage     string `mapstructure:"kubernetes-image"`
+	KubernetesNamespace string `mapstructure:"kubernetes-namespace"`
+	KubernetesPVC       string `mapstructure:"kubernetes-pvc"`
+	// KubernetesServiceAccounts is a comma separated list of repo=sa pairs.
+	KubernetesServiceAccounts string `mapstructure:"kubernetes-service-accounts"`
+	// FailOnDestroy is true if plans over the destroy threshold should set a
+	// failing commit status.
+	FailOnDestroy      bool   `mapstructure:"fail-on-destroy"`
+	GiteaBaseURL       string `mapstructure:"gitea-base-url"`
+	GiteaToken         string `mapstructure:"gitea-token"`
+	GiteaUser          string `mapstructure:"gitea-user"`
+	GiteaWebhookSecret string `mapstructure:"gitea-webhook-secret"`
+	// GithubAppID is the id of the GitHub App to authenticate as. 0 means
+	// we authenticate with GithubToken instead.
+	GithubAppID         int    `mapstructure:"gh-app-id"`
+	GithubAppKeyFile    string `mapstructure:"gh-app-key-file"`
+	GithubHostname      string `mapstructure:"gh-hostname"`
+	GithubToken         string `mapstructure:"gh-token"`
+	GithubUser          string `mapstructure:"gh-user"`
+	GithubWebhookSecret string `mapstructure:"gh-webhook-secret"`
+	GitlabHostname      string `mapstructure:"gitlab-hostname"`
+	// GitlabPipelineStatuses is true if our commit statuses should be added
+	// to the merge request's pipeline rather than to one of their own.
+	GitlabPipelineStatuses bool   `mapstructure:"gitlab-pipeline-statuses"`
 	GitlabToken            string `mapstructure:"gitlab-token"`
 	GitlabUser             string `mapstructure:"gitlab-user"`
 	GitlabWebhookSecret    string `mapstructure:"gitlab-webhook-secret"`
-	LogLevel               string `mapstructure:"log-level"`
-	Port                   int    `mapstructure:"port"`
-	RepoWhitelist          string `mapstructure:"repo-whitelist"`
+	// HidePrevPlanComments is true if our earlier plan comments should be
+	// hidden, or deleted if the VCS host can't hide them, once all of their
+	// projects have been planned again.
+	HidePrevPlanComments bool `mapstructure:"hide-prev-plan-comments"`
+	// LockingDB is the deprecated name of DBType.
+	LockingDB string `mapstructure:"locking-db"`
+	LogLevel  string `mapstructure:"log-level"`
+	// MaxCloneAttempts is how many times a git clone is tried before the
+	// command fails.
+	MaxCloneAttempts int `mapstructure:"max-clone-attempts"`
+	// MaxProjectsPerCommand is the most projects a single command can run.
+	// 0 means no limit.
+	MaxProjectsPerCommand int `mapstructure:"max-projects-per-command"`
+	// MaxQueuedCommands is how many commands can be running or queued before
+	// webhooks that would start more are rejected. 0 means no limit.
+	MaxQueuedCommands int `mapstructure:"max-queued-commands"`
+	// ParallelPoolSize is how many projects are planned at the same time.
+	ParallelPoolSize int `mapstructure:"parallel-pool-size"`
+	// PlanDrafts is false if we should skip autoplanning draft pull requests.
+	PlanDrafts bool `mapstructure:"plan-drafts"`
+	Port       int  `mapstructure:"port"`
+	// ProjectDirs is a comma separated list of patterns of the directories
+	// we run projects in. If empty, we run projects in every directory.
+	ProjectDirs string `mapstructure:"project-dirs"`
+	// RedisHost and RedisPassword are used to connect to Redis if DBType is
+	// redis.
+	RedisHost     string `mapstructure:"redis-host"`
+	RedisPassword string `mapstructure:"redis-password"`
+	// RepoConfig is the path to the server-side repo config file. If empty,
+	// there is no server-side repo config.
+	RepoConfig    string `mapstructure:"repo-config"`
+	RepoWhitelist string `mapstructure:"repo-whitelist"`
+	// RequireApplyConfirmation is whether applies only run once they're
+	// confirmed with an atlantis confirm comment.
+	RequireApplyConfirmation bool `mapstructure:"require-apply-confirmation"`
 	// RequireApproval is whether to require pull request approval before
 	// allowing terraform apply's to be run.
 	RequireApproval bool `mapstructure:"require-approval"`
 	// RequireMergeable is whether to require pull requests to be mergeable before
 	// allowing terraform apply's to run.
-	RequireMergeable       bool            `mapstructure:"require-mergeable"`
-	SilenceWhitelistErrors bool            `mapstructure:"silence-whitelist-errors"`
-	SlackToken             string          `mapstructure:"slack-token"`
-	SSLCertFile            string          `mapstructure:"ssl-cert-file"`
-	SSLKeyFile             string          `mapstructure:"ssl-key-file"`
-	TFEToken               string          `mapstructure:"tfe-token"`
-	Webhooks               []WebhookConfig `mapstructure:"webhooks"`
+	RequireMergeable bool `mapstructure:"require-mergeable"`
+	// Role is what this process does, one of workqueue.Roles.
+	Role                   string `mapstructure:"role"`
+	SilenceWhitelistErrors bool   `mapstructure:"silence-whitelist-errors"`
+	// SkipDraftPRs is true if we should skip autoplanning draft pull
+	// requests.
+	SkipDraftPRs bool   `mapstructure:"skip-draft-prs"`
+	SlackToken   string `mapstructure:"slack-token"`
+	// SparseCheckout is true if clones should only check out the dirs of the
+	// projects being run.
+	SparseCheckout bool `mapstructure:"sparse-checkout"`
+	// SSLCAFile is the path to a bundle of CAs that the servers we make
+	// requests to, ex. VCS hosts and terraform's releases, can be signed by.
+	SSLCAFile   string `mapstructure:"ssl-ca-file"`
+	SSLCertFile string `mapstructure:"ssl-cert-file"`
+	SSLKeyFile  string `mapstructure:"ssl-key-file"`
+	// TFDownloadURL is the base URL terraform versions are downloaded from.
+	TFDownloadURL string `mapstructure:"tf-download-url"`
+	// TFDownloadVersions is a comma separated list of terraform versions to
+	// download at startup.
+	TFDownloadVersions string `mapstructure:"tf-download-versions"`
+	TFEToken           string `mapstructure:"tfe-token"`
+	// TFLogLevel is the TF_LOG level to run plans with. If empty, TF_LOG
+	// isn't set.
+	TFLogLevel string `mapstructure:"tf-log-level"`
+	// TFPluginCache is true if providers should be cached in the data dir
+	// between terraform inits.
+	TFPluginCache bool `mapstructure:"tf-plugin-cache"`
+	// UseChecksAPI is true if plans and applies on GitHub should be reported
+	// as check runs rather than commit statuses.
+	UseChecksAPI bool `mapstructure:"use-checks-api"`
+	// VCSCAFile is the path to a bundle of CAs that VCS hosts' certificates
+	// can be signed by.
+	VCSCAFile string `mapstructure:"vcs-ca-file"`
+	// VCSProxyURL is the proxy that requests to VCS hosts are sent through
+	// instead of the one in HTTPS_PROXY.
+	VCSProxyURL string `mapstructure:"vcs-proxy-url"`
+	// VCSStatusName starts the names of the commit statuses we set, ex.
+	// atlantis sets atlantis/plan.
+	VCSStatusName string `mapstructure:"vcs-status-name"`
+	// VCSTLSCertFile and VCSTLSKeyFile are the client certificate and its key
+	// that we present to VCS hosts that require mutual TLS.
+	VCSTLSCertFile string `mapstructure:"vcs-tls-cert-file"`
+	VCSTLSKeyFile  string `mapstructure:"vcs-tls-key-file"`
+	// WebBasicAuth is true if the web UI and other routes that don't
+	// authenticate requests themselves should require HTTP basic auth with
+	// WebUsername and WebPassword.
+	WebBasicAuth bool `mapstructure:"web-basic-auth"`
+	// WebOIDCAdminGroups is a comma separated list of the groups that can
+	// take destructive actions in the web UI. If empty, all logged in users
+	// can.
+	WebOIDCAdminGroups  string `mapstructure:"web-oidc-admin-groups"`
+	WebOIDCClientID     string `mapstructure:"web-oidc-client-id"`
+	WebOIDCClientSecret string `mapstructure:"web-oidc-client-secret"`
+	// WebOIDCIssuer is the OIDC provider users log in to the web UI with. If
+	// empty, the web UI doesn't require a login.
+	WebOIDCIssuer string          `mapstructure:"web-oidc-issuer"`
+	WebPassword   string          `mapstructure:"web-password"`
+	WebUsername   string          `mapstructure:"web-username"`
+	Webhooks      []WebhookConfig `mapstructure:"webhooks"`
+	// WebhookAllowIPs is a comma separated list of the IPs and CIDRs that
+	// webhooks must come from. If empty, they can come from anywhere.
+	WebhookAllowIPs string `mapstructure:"webhook-allow-ips"`
+	// WebhookRateLimit is how many commands each repo's webhooks can start a
+	// minute. 0 means no limit.
+	WebhookRateLimit int `mapstructure:"webhook-rate-limit"`
+	// WebhookTrustedProxies is a comma separated list of the IPs and CIDRs of
+	// the proxies in front of Atlantis whose X-Forwarded-For headers are
+	// trusted when checking WebhookAllowIPs.
+	WebhookTrustedProxies string `mapstructure:"webhook-trusted-proxies"`
+	// WorkerClaimTimeout is how long the claim on a queued command lasts
+	// without being renewed by the worker running it, ex. 5m.
+	WorkerClaimTimeout string `mapstructure:"worker-claim-timeout"`
+	// WorkerCount is how many commands are run at the same time if Role is
+	// worker.
+	WorkerCount int `mapstructure:"worker-count"`
+	// WorkingDirTTL is how long the working dirs of pulls without locks are
+	// kept after they were last used, ex. 72h. If empty, they're kept until
+	// the pull is closed.
+	WorkingDirTTL string `mapstructure:"working-dir-ttl"`
+}
+
+// ToAllowApplyFrom returns the teams in AllowApplyFrom.
+func (u UserConfig) ToAllowApplyFrom() []string {
+	var teams []string
+	for _, t := range strings.Split(u.AllowApplyFrom, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			teams = append(teams, t)
+		}
+	}
+	return teams
+}
+
+// ToWorkingDirTTL returns WorkingDirTTL as a duration or 0 if it's empty. It's
+// validated when the server starts.
+func (u UserConfig) ToWorkingDirTTL() time.Duration {
+	d, _ := time.ParseDuration(u.WorkingDirTTL)
+	return d
+}
+
+// ToDockerConfig returns how to run terraform in containers or nil if
+// ExecutionMode isn't docker.
+func (u UserConfig) ToDockerConfig() *terraform.DockerConfig {
+	if u.ExecutionMode != terraform.DockerExecutionMode {
+		return nil
+	}
+	var mounts []string
+	for _, m := range strings.Split(u.DockerMounts, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			mounts = append(mounts, m)
+		}
+	}
+	return &terraform.DockerConfig{
+		Image:  u.DockerImage,
+		Mounts: mounts,
+		CPUs:   u.DockerCPUs,
+		Memory: u.DockerMemory,
+	}
+}
+
+// ToKubernetesConfig returns how to run terraform as Kubernetes Jobs or nil if
+// ExecutionMode isn't kubernetes.
+func (u UserConfig) ToKubernetesConfig() *terraform.KubernetesConfig {
+	if u.ExecutionMode != terraform.KubernetesExecutionMode {
+		return nil
+	}
+	serviceAccounts := make(map[string]string)
+	for _, pair := range strings.Split(u.KubernetesServiceAccounts, ",") {
+		if parts := strings.SplitN(strings.TrimSpace(pair), "=", 2); len(parts) == 2 {
+			serviceAccounts[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+	// This is the same default as events.FileWorkspace's.
+	cloneRoot := u.CloneRoot
+	if cloneRoot == "" {
+		cloneRoot = filepath.Join(u.DataDir, "repos")
+	}
+	return &terraform.KubernetesConfig{
+		Kubeconfig:      u.Kubeconfig,
+		Namespace:       u.KubernetesNamespace,
+		Image:           u.KubernetesImage,
+		DataPVC:         u.KubernetesPVC,
+		DataDir:         u.DataDir,
+		CloneRoot:       cloneRoot,
+		ServiceAccounts: serviceAccounts,
+	}
+}
+
+// ToDrainTimeout returns DrainTimeout as a duration. It's validated when the
+// server starts so it's 0 if it's invalid.
+func (u UserConfig) ToDrainTimeout() time.Duration {
+	d, _ := time.ParseDuration(u.DrainTimeout)
+	return d
+}
+
+// ToWorkerClaimTimeout returns WorkerClaimTimeout as a duration. It's
+// validated when the server starts so it's 0 if it's invalid.
+func (u UserConfig) ToWorkerClaimTimeout() time.Duration {
+	d, _ := time.ParseDuration(u.WorkerClaimTimeout)
+	return d
+}
+
+// ToWebOIDCAdminGroups returns the groups in WebOIDCAdminGroups.
+func (u UserConfig) ToWebOIDCAdminGroups() []string {
+	var groups []string
+	for _, g := range strings.Split(u.WebOIDCAdminGroups, ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+// DisableAutoplanLabels returns the labels in DisableAutoplanLabel.
+func (u UserConfig) DisableAutoplanLabels() []string {
+	var labels []string
+	for _, l := range strings.Split(u.DisableAutoplanLabel, ",") {
+		if l = strings.TrimSpace(l); l != "" {
+			labels = append(labels, l)
+		}
+	}
+	return labels
+}
+
+// ToProjectDirs returns the patterns in ProjectDirs.
+func (u UserConfig) ToProjectDirs() []string {
+	return splitPatterns(u.ProjectDirs)
+}
+
+// ToAutodiscoverInclude returns the patterns in AutodiscoverInclude.
+func (u UserConfig) ToAutodiscoverInclude() []string {
+	return splitPatterns(u.AutodiscoverInclude)
+}
+
+// ToAutodiscoverExclude returns the patterns in AutodiscoverExclude.
+func (u UserConfig) ToAutodiscoverExclude() []string {
+	return splitPatterns(u.AutodiscoverExclude)
+}
+
+// splitPatterns returns the non-empty patterns in the comma separated list
+// patterns.
+func splitPatterns(patterns string) []string {
+	var split []string
+	for _, p := range strings.Split(patterns, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			split = append(split, p)
+		}
+	}
+	return split
 }
 
 // ToLogLevel returns the LogLevel object corresponding to the user-passed
@@ -54,3 +413,15 @@ func (u UserConfig) ToLogLevel() logging.LogLevel {
 	}
 	return logging.Info
 }
+
+// ToTFDownloadVersions returns the terraform versions that should be
+// downloaded at startup.
+func (u UserConfig) ToTFDownloadVersions() []string {
+	var versions []string
+	for _, v := range strings.Split(u.TFDownloadVersions, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			versions = append(versions, v)
+		}
+	}
+	return versions
+}
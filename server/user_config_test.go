@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/runatlantis/atlantis/server"
+	"github.com/runatlantis/atlantis/server/events/terraform"
 	"github.com/runatlantis/atlantis/server/logging"
 	. "github.com/runatlantis/atlantis/testing"
 )
@@ -44,3 +45,45 @@ func TestUserConfig_ToLogLevel(t *testing.T) {
 		})
 	}
 }
+
+func TestUserConfig_ToDockerConfig(t *testing.T) {
+	Assert(t, server.UserConfig{ExecutionMode: "host", DockerImage: "image"}.ToDockerConfig() == nil, "exp no docker config in host mode")
+	Equals(t, &terraform.DockerConfig{
+		Image:  "image",
+		Mounts: []string{"/a:/a:ro", "/b:/c"},
+		CPUs:   "2",
+		Memory: "1g",
+	}, server.UserConfig{
+		ExecutionMode: "docker",
+		DockerImage:   "image",
+		DockerMounts:  "/a:/a:ro, /b:/c,",
+		DockerCPUs:    "2",
+		DockerMemory:  "1g",
+	}.ToDockerConfig())
+}
+
+func TestUserConfig_ToKubernetesConfig(t *testing.T) {
+	Assert(t, server.UserConfig{ExecutionMode: "docker"}.ToKubernetesConfig() == nil, "exp no kubernetes config in docker mode")
+	Equals(t, &terraform.KubernetesConfig{
+		Kubeconfig:      "/kubeconfig",
+		Namespace:       "atlantis",
+		Image:           "image",
+		DataPVC:         "data",
+		DataDir:         "/data",
+		CloneRoot:       "/data/repos",
+		ServiceAccounts: map[string]string{"owner/repo": "repo-sa", "*": "default-sa"},
+	}, server.UserConfig{
+		ExecutionMode:             "kubernetes",
+		Kubeconfig:                "/kubeconfig",
+		KubernetesNamespace:       "atlantis",
+		KubernetesImage:           "image",
+		KubernetesPVC:             "data",
+		KubernetesServiceAccounts: "owner/repo=repo-sa, * = default-sa,",
+		DataDir:                   "/data",
+	}.ToKubernetesConfig())
+}
+
+func TestUserConfig_DisableAutoplanLabels(t *testing.T) {
+	Equals(t, []string(nil), server.UserConfig{}.DisableAutoplanLabels())
+	Equals(t, []string{"wip", "do not plan"}, server.UserConfig{DisableAutoplanLabel: "wip, do not plan,"}.DisableAutoplanLabels())
+}
@@ -37,9 +37,17 @@ type LockIndexData struct {
 	Time         time.Time
 }
 
+// ApplyLockIndexData holds the fields needed to display whether applies are
+// locked in the index view.
+type ApplyLockIndexData struct {
+	Locked bool
+	Time   time.Time
+}
+
 // IndexData holds the data for rendering the index page
 type IndexData struct {
 	Locks           []LockIndexData
+	ApplyLock       ApplyLockIndexData
 	AtlantisVersion string
 	// CleanedBasePath is the path Atlantis is accessible at externally. If
 	// not using a path-based proxy, this will be an empty string. Never ends
@@ -60,6 +68,16 @@ var indexTemplate = template.Must(template.New("index.html.tmpl").Parse(`
   <script>
     $(document).ready(function () {
       $("p.js-discard-success").toggle(document.URL.indexOf("discard=true") !== -1);
+      $("#applyLockToggle").click(function() {
+        $.ajax({
+          url: '{{ .CleanedBasePath }}/applies/lock',
+          type: $(this).attr('data-locked') === 'true' ? 'DELETE' : 'POST',
+          headers: {'X-Requested-With': 'XMLHttpRequest'},
+          success: function(result) {
+            window.location.reload();
+          }
+        });
+      });
     });
     setTimeout(function() {
         $("p.js-discard-success").fadeOut('slow');
@@ -83,6 +101,20 @@ var indexTemplate = template.Must(template.New("index.html.tmpl").Parse(`
   </nav>
   <div class="navbar-spacer"></div>
   <br>
+  <section>
+    <p class="title-heading small"><strong>Applies</strong></p>
+    <div class="twelve columns content lock-row">
+    {{ if .ApplyLock.Locked }}
+      <div class="list-title">Applies are locked so <code>atlantis apply</code> is disabled.</div>
+      <div class="list-status"><code>Locked</code></div>
+      <div class="list-timestamp"><span class="heading-font-size">{{.ApplyLock.Time}}</span></div>
+      <a class="button button-default" id="applyLockToggle" data-locked="true">Unlock Applies</a>
+    {{ else }}
+      <div class="list-title">Applies are enabled.</div>
+      <a class="button button-default" id="applyLockToggle" data-locked="false">Lock Applies</a>
+    {{ end }}
+    </div>
+  </section>
   <section>
     <p class="title-heading small"><strong>Locks</strong></p>
     {{ if .Locks }}
@@ -100,6 +132,14 @@ var indexTemplate = template.Must(template.New("index.html.tmpl").Parse(`
     <p class="placeholder">No locks found.</p>
     {{ end }}
   </section>
+  <section>
+    <p class="title-heading small"><strong>Jobs</strong></p>
+    <a href="{{ .CleanedBasePath }}/jobs">
+      <div class="twelve columns button content">
+        <div class="list-title">View recent plans and applies</div>
+      </div>
+    </a>
+  </section>
 </div>
 <footer>
 v{{ .AtlantisVersion }}
@@ -226,3 +266,275 @@ v{{ .AtlantisVersion }}
 </body>
 </html>
 `))
+
+// JobIndexData holds the fields needed to display a job in the jobs view.
+type JobIndexData struct {
+	JobPath     string
+	Command     string
+	RepoRelDir  string
+	Workspace   string
+	ProjectName string
+	User        string
+	Status      string
+	StartedAt   time.Time
+	Duration    string
+}
+
+// JobsPullData holds the jobs run for a pull request in the jobs view.
+type JobsPullData struct {
+	RepoFullName string
+	PullNum      int
+	PullURL      string
+	Jobs         []JobIndexData
+}
+
+// JobsIndexData holds the data for rendering the jobs view.
+type JobsIndexData struct {
+	// Pulls are ordered by their most recent job, newest first.
+	Pulls           []JobsPullData
+	AtlantisVersion string
+	// CleanedBasePath is the path Atlantis is accessible at externally. If
+	// not using a path-based proxy, this will be an empty string. Never ends
+	// in a '/' (hence "cleaned").
+	CleanedBasePath string
+}
+
+var jobsTemplate = template.Must(template.New("jobs.html.tmpl").Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>atlantis</title>
+  <meta name="description" content="">
+  <meta name="author" content="">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <link rel="stylesheet" href="{{ .CleanedBasePath }}/static/css/normalize.css">
+  <link rel="stylesheet" href="{{ .CleanedBasePath }}/static/css/skeleton.css">
+  <link rel="stylesheet" href="{{ .CleanedBasePath }}/static/css/custom.css">
+  <link rel="icon" type="image/png" href="{{ .CleanedBasePath }}/static/images/atlantis-icon.png">
+</head>
+<body>
+<div class="container">
+  <section class="header">
+    <a title="atlantis" href="{{ .CleanedBasePath }}/"><img src="{{ .CleanedBasePath }}/static/images/atlantis-icon.png"/></a>
+    <p class="title-heading">atlantis</p>
+  </section>
+  <div class="navbar-spacer"></div>
+  <br>
+  <section>
+    <p class="title-heading small"><strong>Jobs</strong></p>
+    {{ if .Pulls }}
+    {{ $basePath := .CleanedBasePath }}
+    {{ range .Pulls }}
+      <h6><a href="{{ .PullURL }}" target="_blank"><strong>{{ .RepoFullName }}</strong> - <span class="heading-font-size">#{{ .PullNum }}</span></a></h6>
+      {{ range .Jobs }}
+      <a href="{{ $basePath }}{{ .JobPath }}">
+        <div class="twelve columns button content lock-row">
+        <div class="list-title">{{ .Command }} dir: <code>{{ .RepoRelDir }}</code> workspace: <code>{{ .Workspace }}</code>{{ if .ProjectName }} project: <code>{{ .ProjectName }}</code>{{ end }} by {{ .User }}</div>
+        <div class="list-status"><code>{{ .Status }}</code></div>
+        <div class="list-timestamp"><span class="heading-font-size">{{ .StartedAt.Format "2006-01-02 15:04:05" }} ({{ .Duration }})</span></div>
+        </div>
+      </a>
+      {{ end }}
+    {{ end }}
+    {{ else }}
+    <p class="placeholder">No jobs found.</p>
+    {{ end }}
+  </section>
+</div>
+<footer>
+v{{ .AtlantisVersion }}
+</footer>
+</body>
+</html>
+`))
+
+// JobDetailData holds the fields needed to display the job detail view.
+type JobDetailData struct {
+	ID           string
+	Command      string
+	RepoFullName string
+	PullNum      int
+	PullURL      string
+	RepoRelDir   string
+	Workspace    string
+	ProjectName  string
+	User         string
+	Status       string
+	Running      bool
+	StartedAt    time.Time
+	Duration     string
+	Output       string
+	// OutputOffset is the length of Output in bytes. It's where the page
+	// starts polling for more output from.
+	OutputOffset    int
+	AtlantisVersion string
+	// CleanedBasePath is the path Atlantis is accessible at externally. If
+	// not using a path-based proxy, this will be an empty string. Never ends
+	// in a '/' (hence "cleaned").
+	CleanedBasePath string
+}
+
+var jobDetailTemplate = template.Must(template.New("job.html.tmpl").Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>atlantis</title>
+  <meta name="description" content="">
+  <meta name="author" content="">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <link rel="stylesheet" href="{{ .CleanedBasePath }}/static/css/normalize.css">
+  <link rel="stylesheet" href="{{ .CleanedBasePath }}/static/css/skeleton.css">
+  <link rel="stylesheet" href="{{ .CleanedBasePath }}/static/css/custom.css">
+  <link rel="icon" type="image/png" href="{{ .CleanedBasePath }}/static/images/atlantis-icon.png">
+  <script src="{{ .CleanedBasePath }}/static/js/jquery-3.2.1.min.js"></script>
+  <style>
+    #jobOutput {
+      max-height: 70vh;
+      overflow: auto;
+      white-space: pre-wrap;
+      word-wrap: break-word;
+    }
+  </style>
+</head>
+<body>
+  <div class="container">
+    <section class="header">
+    <a title="atlantis" href="{{ .CleanedBasePath }}/"><img src="{{ .CleanedBasePath }}/static/images/atlantis-icon.png"/></a>
+    <p class="title-heading">atlantis</p>
+    <p class="title-heading"><strong>{{ .Command }} {{ .RepoFullName }} #{{ .PullNum }}</strong> <code id="jobStatus">{{ .Status }}</code></p>
+    </section>
+    <div class="navbar-spacer"></div>
+    <br>
+    <section>
+      <div class="twelve columns">
+        <h6><code>Pull Request Link</code>: <a href="{{ .PullURL }}" target="_blank"><strong>{{ .PullURL }}</strong></a></h6>
+        <h6><code>Directory</code>: <strong>{{ .RepoRelDir }}</strong></h6>
+        <h6><code>Workspace</code>: <strong>{{ .Workspace }}</strong></h6>
+        {{ if .ProjectName }}<h6><code>Project</code>: <strong>{{ .ProjectName }}</strong></h6>{{ end }}
+        <h6><code>Run By</code>: <strong>{{ .User }}</strong></h6>
+        <h6><code>Started</code>: <strong>{{ .StartedAt.Format "2006-01-02 15:04:05" }}</strong></h6>
+        <h6><code>Duration</code>: <strong id="jobDuration">{{ .Duration }}</strong></h6>
+        <pre><code id="jobOutput">{{ .Output }}</code></pre>
+      </div>
+    </section>
+  </div>
+<footer>
+v{{ .AtlantisVersion }}
+</footer>
+{{ if .Running }}
+<script>
+  // Stream the output over a websocket until the job finishes. Once the
+  // socket closes, poll for the job's status and any output we missed. If
+  // websockets aren't available we just poll.
+  var offset = {{ .OutputOffset }};
+  var output = $("#jobOutput");
+  function appendOutput(data) {
+    var atBottom = output.scrollTop() + output.innerHeight() >= output[0].scrollHeight - 5;
+    output.append(document.createTextNode(data.output));
+    if (atBottom) {
+      output.scrollTop(output[0].scrollHeight);
+    }
+    offset = data.offset;
+  }
+  function poll() {
+    $.getJSON('{{ .CleanedBasePath }}/jobs/{{ .ID }}/output', {offset: offset}, function(data) {
+      appendOutput(data);
+      $("#jobStatus").text(data.status);
+      $("#jobDuration").text(data.duration);
+      if (data.running) {
+        setTimeout(poll, 1000);
+      }
+    }).fail(function() {
+      setTimeout(poll, 5000);
+    });
+  }
+  function stream() {
+    if (!window.WebSocket) {
+      setTimeout(poll, 1000);
+      return;
+    }
+    var scheme = window.location.protocol === "https:" ? "wss://" : "ws://";
+    var ws = new WebSocket(scheme + window.location.host + '{{ .CleanedBasePath }}/jobs/{{ .ID }}/ws?offset=' + offset);
+    ws.onmessage = function(event) {
+      appendOutput(JSON.parse(event.data));
+    };
+    ws.onclose = function() {
+      poll();
+    };
+  }
+  stream();
+</script>
+{{ end }}
+</body>
+</html>
+`))
+
+// PlanDetailData holds the fields needed to display the output of a plan.
+type PlanDetailData struct {
+	RepoFullName    string
+	PullNum         int
+	PullURL         string
+	HeadCommit      string
+	RepoRelDir      string
+	Workspace       string
+	ProjectName     string
+	User            string
+	CreatedAt       time.Time
+	Output          string
+	AtlantisVersion string
+	// CleanedBasePath is the path Atlantis is accessible at externally. If
+	// not using a path-based proxy, this will be an empty string. Never ends
+	// in a '/' (hence "cleaned").
+	CleanedBasePath string
+}
+
+var planDetailTemplate = template.Must(template.New("plan.html.tmpl").Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>atlantis</title>
+  <meta name="description" content="">
+  <meta name="author" content="">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <link rel="stylesheet" href="{{ .CleanedBasePath }}/static/css/normalize.css">
+  <link rel="stylesheet" href="{{ .CleanedBasePath }}/static/css/skeleton.css">
+  <link rel="stylesheet" href="{{ .CleanedBasePath }}/static/css/custom.css">
+  <link rel="icon" type="image/png" href="{{ .CleanedBasePath }}/static/images/atlantis-icon.png">
+  <style>
+    #planOutput {
+      white-space: pre-wrap;
+      word-wrap: break-word;
+    }
+  </style>
+</head>
+<body>
+  <div class="container">
+    <section class="header">
+    <a title="atlantis" href="{{ .CleanedBasePath }}/"><img src="{{ .CleanedBasePath }}/static/images/atlantis-icon.png"/></a>
+    <p class="title-heading">atlantis</p>
+    <p class="title-heading"><strong>plan {{ .RepoFullName }} #{{ .PullNum }}</strong></p>
+    </section>
+    <div class="navbar-spacer"></div>
+    <br>
+    <section>
+      <div class="twelve columns">
+        <h6><code>Pull Request Link</code>: <a href="{{ .PullURL }}" target="_blank"><strong>{{ .PullURL }}</strong></a></h6>
+        <h6><code>Commit</code>: <strong>{{ .HeadCommit }}</strong></h6>
+        <h6><code>Directory</code>: <strong>{{ .RepoRelDir }}</strong></h6>
+        <h6><code>Workspace</code>: <strong>{{ .Workspace }}</strong></h6>
+        {{ if .ProjectName }}<h6><code>Project</code>: <strong>{{ .ProjectName }}</strong></h6>{{ end }}
+        <h6><code>Planned By</code>: <strong>{{ .User }}</strong></h6>
+        <h6><code>Planned</code>: <strong>{{ .CreatedAt.Format "2006-01-02 15:04:05" }}</strong></h6>
+        <pre><code id="planOutput">{{ .Output }}</code></pre>
+      </div>
+    </section>
+  </div>
+<footer>
+v{{ .AtlantisVersion }}
+</footer>
+</body>
+</html>
+`))